@@ -0,0 +1,73 @@
+// Command bench holds tools for load-testing HydraDNS.
+//
+// bench --serve runs a synthetic upstream DNS responder with configurable
+// latency, jitter, loss, truncation and server failures, so the forwarding
+// resolver's retries, TCP fallback and failover can be exercised
+// reproducibly without real upstream resolvers:
+//
+//	bench --serve --listen 127.0.0.2:53 --latency 20ms --jitter 5ms --loss 0.1
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	flags := flag.NewFlagSet("bench", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: bench --serve [flags]")
+		flags.PrintDefaults()
+	}
+	serve := flags.Bool("serve", false, "Run a synthetic upstream DNS responder")
+	var cfg responderConfig
+	flags.StringVar(&cfg.Listen, "listen", "127.0.0.2:53",
+		"UDP and TCP address to answer on; HydraDNS queries upstreams on port 53")
+	flags.DurationVar(&cfg.Latency, "latency", 0, "Delay before each response")
+	flags.DurationVar(&cfg.Jitter, "jitter", 0, "Random variation of -latency, up to this much either way")
+	flags.Float64Var(&cfg.Loss, "loss", 0, "Fraction of UDP queries dropped without a response (0-1)")
+	flags.Float64Var(&cfg.Truncate, "truncate", 0, "Fraction of UDP responses sent truncated, forcing a TCP retry (0-1)")
+	flags.Float64Var(&cfg.ServFail, "servfail", 0, "Fraction of queries answered with SERVFAIL (0-1)")
+	flags.StringVar(&cfg.A, "a", "192.0.2.1", "Address returned for A queries")
+	flags.StringVar(&cfg.AAAA, "aaaa", "2001:db8::1", "Address returned for AAAA queries")
+	ttl := flags.Uint("ttl", 60, "TTL of returned records")
+	flags.BoolVar(&cfg.NoTCP, "no-tcp", false, "Do not answer over TCP")
+	stats := flags.Duration("stats", 10*time.Second, "Interval between statistics lines (0 disables)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if !*serve {
+		flags.Usage()
+		return errors.New("bench: --serve is the only mode")
+	}
+	cfg.TTL = uint32(min(*ttl, 1<<31-1))
+
+	r, err := newResponder(cfg)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if err := r.listen(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "bench: answering on %s\n", cfg.Listen)
+	r.serve(ctx, *stats)
+	fmt.Fprintf(os.Stderr, "bench: %s\n", r.stats())
+	return nil
+}
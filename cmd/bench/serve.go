@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jroosing/hydradns/internal/dns"
+	"github.com/jroosing/hydradns/internal/helpers"
+)
+
+const (
+	// responderUDPSize is the EDNS UDP payload size the responder advertises.
+	responderUDPSize = 1232
+	// tcpIdleTimeout closes TCP connections idle for this long.
+	tcpIdleTimeout = 30 * time.Second
+)
+
+// responderConfig configures the synthetic upstream responder.
+type responderConfig struct {
+	Listen   string
+	Latency  time.Duration
+	Jitter   time.Duration
+	Loss     float64 // Fraction of UDP queries dropped
+	Truncate float64 // Fraction of UDP responses sent with TC set and no answers
+	ServFail float64 // Fraction of queries answered with SERVFAIL
+	A        string
+	AAAA     string
+	TTL      uint32
+	NoTCP    bool
+}
+
+// responder answers every A and AAAA query with a fixed address, and other
+// queries with NOERROR and no records, misbehaving as configured.
+type responder struct {
+	cfg  responderConfig
+	a    net.IP
+	aaaa net.IP
+
+	udp net.PacketConn
+	tcp net.Listener
+
+	queries    atomic.Uint64
+	tcpQueries atomic.Uint64
+	dropped    atomic.Uint64
+	truncated  atomic.Uint64
+	servfails  atomic.Uint64
+}
+
+func newResponder(cfg responderConfig) (*responder, error) {
+	for name, v := range map[string]float64{"loss": cfg.Loss, "truncate": cfg.Truncate, "servfail": cfg.ServFail} {
+		if v < 0 || v > 1 {
+			return nil, fmt.Errorf("-%s must be between 0 and 1, got %v", name, v)
+		}
+	}
+	if cfg.Latency < 0 || cfg.Jitter < 0 {
+		return nil, errors.New("-latency and -jitter must not be negative")
+	}
+	r := &responder{cfg: cfg, a: net.ParseIP(cfg.A).To4(), aaaa: net.ParseIP(cfg.AAAA)}
+	if r.a == nil {
+		return nil, fmt.Errorf("-a %q is not an IPv4 address", cfg.A)
+	}
+	if r.aaaa == nil || r.aaaa.To4() != nil {
+		return nil, fmt.Errorf("-aaaa %q is not an IPv6 address", cfg.AAAA)
+	}
+	return r, nil
+}
+
+// listen opens the UDP socket and, unless disabled, the TCP listener.
+func (r *responder) listen() error {
+	udp, err := net.ListenPacket("udp", r.cfg.Listen)
+	if err != nil {
+		return err
+	}
+	r.udp = udp
+	if r.cfg.NoTCP {
+		return nil
+	}
+	tcp, err := net.Listen("tcp", r.cfg.Listen)
+	if err != nil {
+		udp.Close()
+		return err
+	}
+	r.tcp = tcp
+	return nil
+}
+
+// serve answers queries until ctx is done, printing statistics to stderr
+// every statsInterval if it is positive.
+func (r *responder) serve(ctx context.Context, statsInterval time.Duration) {
+	var wg sync.WaitGroup
+	wg.Go(r.serveUDP)
+	if r.tcp != nil {
+		wg.Go(r.serveTCP)
+	}
+	if statsInterval > 0 {
+		wg.Go(func() {
+			t := time.NewTicker(statsInterval)
+			defer t.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-t.C:
+					fmt.Fprintf(os.Stderr, "bench: %s\n", r.stats())
+				}
+			}
+		})
+	}
+
+	<-ctx.Done()
+	r.udp.Close()
+	if r.tcp != nil {
+		r.tcp.Close()
+	}
+	wg.Wait()
+}
+
+// stats summarizes the counters.
+func (r *responder) stats() string {
+	return fmt.Sprintf("queries=%d tcp=%d dropped=%d truncated=%d servfail=%d",
+		r.queries.Load(), r.tcpQueries.Load(), r.dropped.Load(), r.truncated.Load(), r.servfails.Load())
+}
+
+func (r *responder) serveUDP() {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	buf := make([]byte, dns.MaxIncomingDNSMessageSize)
+	for {
+		n, peer, err := r.udp.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		r.queries.Add(1)
+		if chance(r.cfg.Loss) {
+			r.dropped.Add(1)
+			continue
+		}
+		msg := append([]byte(nil), buf[:n]...)
+		wg.Go(func() {
+			time.Sleep(r.delay())
+			if resp := r.respond(msg, true); resp != nil {
+				_, _ = r.udp.WriteTo(resp, peer)
+			}
+		})
+	}
+}
+
+func (r *responder) serveTCP() {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := r.tcp.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		wg.Go(func() { r.handleTCP(conn) })
+	}
+}
+
+// handleTCP answers the length-prefixed queries on conn in order.
+func (r *responder) handleTCP(conn net.Conn) {
+	defer conn.Close()
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(tcpIdleTimeout))
+		var prefix [2]byte
+		if _, err := io.ReadFull(conn, prefix[:]); err != nil {
+			return
+		}
+		msg := make([]byte, binary.BigEndian.Uint16(prefix[:]))
+		if _, err := io.ReadFull(conn, msg); err != nil {
+			return
+		}
+		r.queries.Add(1)
+		r.tcpQueries.Add(1)
+
+		time.Sleep(r.delay())
+		resp := r.respond(msg, false)
+		if resp == nil {
+			return
+		}
+		out := binary.BigEndian.AppendUint16(make([]byte, 0, 2+len(resp)), helpers.ClampIntToUint16(len(resp)))
+		if _, err := conn.Write(append(out, resp...)); err != nil {
+			return
+		}
+	}
+}
+
+// delay returns the latency for one response: the configured latency
+// varied uniformly by up to the jitter either way.
+func (r *responder) delay() time.Duration {
+	d := r.cfg.Latency
+	if r.cfg.Jitter > 0 {
+		d += rand.N(2*r.cfg.Jitter+1) - r.cfg.Jitter
+	}
+	return max(d, 0)
+}
+
+// respond builds the response to msg, or returns nil for a message that is
+// not a query with one question.
+func (r *responder) respond(msg []byte, udp bool) []byte {
+	req, err := dns.ParsePacket(msg)
+	if err != nil || req.Header.IsResponse() || len(req.Questions) != 1 {
+		return nil
+	}
+	q := req.Questions[0]
+	resp := dns.Packet{
+		Header:    dns.Header{ID: req.Header.ID, Flags: dns.QRFlag | dns.RAFlag | req.Header.Flags&dns.RDFlag},
+		Questions: req.Questions,
+	}
+	if dns.ExtractOPT(req.Additionals) != nil {
+		resp.Additionals = []dns.Record{dns.CreateOPT(responderUDPSize).Record()}
+	}
+
+	switch {
+	case chance(r.cfg.ServFail):
+		r.servfails.Add(1)
+		resp.Header.Flags |= uint16(dns.RCodeServFail)
+	case udp && chance(r.cfg.Truncate):
+		r.truncated.Add(1)
+		resp.Header.Flags |= dns.TCFlag
+	case dns.RecordType(q.Type) == dns.TypeA:
+		h := dns.NewRRHeader(q.Name, dns.ClassIN, r.cfg.TTL)
+		resp.Answers = []dns.Record{dns.NewIPRecord(h, r.a)}
+	case dns.RecordType(q.Type) == dns.TypeAAAA:
+		h := dns.NewRRHeader(q.Name, dns.ClassIN, r.cfg.TTL)
+		resp.Answers = []dns.Record{dns.NewIPRecord(h, r.aaaa)}
+	}
+
+	out, err := resp.Marshal()
+	if err != nil {
+		return nil
+	}
+	return out
+}
+
+// chance reports true with probability p.
+func chance(p float64) bool {
+	return p > 0 && rand.Float64() < p
+}
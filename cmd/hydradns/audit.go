@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/jroosing/hydradns/internal/audit"
+)
+
+// runVerifyAudit implements `hydradns verify-audit FILE`: it checks the hash
+// chain of an outbound query audit log and reports the first entry that was
+// altered, removed, or reordered.
+func runVerifyAudit(args []string) error {
+	flags := flag.NewFlagSet("verify-audit", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: hydradns verify-audit FILE")
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("verify-audit needs exactly one audit log file")
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	n, err := audit.Verify(f)
+	if err != nil {
+		return fmt.Errorf("audit log %s is not intact after %d valid entries: %w", flags.Arg(0), n, err)
+	}
+	fmt.Printf("%s: %d entries, hash chain intact\n", flags.Arg(0), n)
+	return nil
+}
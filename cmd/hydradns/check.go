@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/jroosing/hydradns/internal/check"
+)
+
+// runCheck implements `hydradns check`: it validates the configuration stored
+// in the database, optionally fetches blocklists, probes the upstream servers,
+// and prints a report. It returns an error when any check fails so the exit
+// status can gate a service restart.
+func runCheck(args []string) error {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	var dbPath string
+	flags.StringVar(&dbPath, "db", defaultDatabasePath(), "Path to SQLite database file (env "+DatabasePathEnv+")")
+	flags.StringVar(&dbPath, "config", defaultDatabasePath(), "Alias for -db")
+	fetch := flags.Bool("fetch-blocklists", false, "Download and parse every configured blocklist")
+	skipUpstreams := flags.Bool("skip-upstreams", false, "Do not probe upstream DNS servers")
+	timeout := flags.Duration("timeout", check.DefaultTimeout, "Timeout for each network probe")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	report := &check.Report{}
+	defer func() { _, _ = report.WriteTo(os.Stdout) }()
+
+	// Never create a fresh database: checking defaults says nothing about the
+	// configuration the service will run with.
+	if _, err := os.Stat(dbPath); errors.Is(err, fs.ErrNotExist) {
+		report.Add("database", check.StatusFail, "%s does not exist", dbPath)
+		return errors.New("check failed")
+	}
+
+	db, err := openDatabase(dbPath)
+	if err != nil {
+		report.Add("database", check.StatusFail, "%v", err)
+		return errors.New("check failed")
+	}
+	defer db.Close()
+
+	cfg, err := db.ExportToConfig(context.Background())
+	if err != nil {
+		report.Add("database", check.StatusFail, "%v", err)
+		return errors.New("check failed")
+	}
+	report.Add("database", check.StatusOK, "%s", dbPath)
+	if err := cfg.ApplyEnv(os.LookupEnv); err != nil {
+		report.Add("environment", check.StatusFail, "%v", err)
+		return errors.New("check failed")
+	}
+
+	res := check.Run(context.Background(), cfg, check.Options{
+		FetchBlocklists: *fetch,
+		SkipUpstreams:   *skipUpstreams,
+		Timeout:         *timeout,
+	})
+	report.Results = append(report.Results, res.Results...)
+	if report.Failed() {
+		return fmt.Errorf("check failed: %d problem(s) found", report.Failures())
+	}
+	return nil
+}
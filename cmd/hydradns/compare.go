@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/jroosing/hydradns/internal/check"
+	"github.com/jroosing/hydradns/internal/dns"
+)
+
+// runCompareUpstreams implements `hydradns compare-upstreams NAME`: it sends
+// one query to every configured upstream, or those given with -upstreams,
+// and prints their answers side by side. It returns an error when the
+// upstreams disagree so scripts can tell.
+func runCompareUpstreams(args []string) error {
+	flags := flag.NewFlagSet("compare-upstreams", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: hydradns compare-upstreams [flags] NAME")
+		flags.PrintDefaults()
+	}
+	var dbPath string
+	flags.StringVar(&dbPath, "db", defaultDatabasePath(), "Path to SQLite database file (env "+DatabasePathEnv+")")
+	flags.StringVar(&dbPath, "config", defaultDatabasePath(), "Alias for -db")
+	qtypeFlag := flags.String("type", "A", "Record type to query")
+	upstreamsFlag := flags.String("upstreams", "", "Comma-separated upstream IPs to compare instead of the configured ones")
+	timeout := flags.Duration("timeout", check.DefaultTimeout, "Timeout for each upstream")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("compare-upstreams needs exactly one name")
+	}
+	name, err := dns.CanonicalName(flags.Arg(0))
+	if err == nil {
+		_, err = dns.EncodeName(name)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid name %q: %w", flags.Arg(0), err)
+	}
+	qtype, err := dns.ParseRecordType(*qtypeFlag)
+	if err != nil {
+		return err
+	}
+
+	var upstreams []string
+	for u := range strings.SplitSeq(*upstreamsFlag, ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			upstreams = append(upstreams, u)
+		}
+	}
+	if len(upstreams) == 0 {
+		if upstreams, err = configuredUpstreams(dbPath); err != nil {
+			return err
+		}
+	}
+
+	c := check.CompareUpstreams(context.Background(), upstreams, name, qtype, *timeout)
+	if _, err := c.WriteTo(os.Stdout); err != nil {
+		return err
+	}
+	if !c.Consistent() {
+		return errors.New("upstreams differ")
+	}
+	return nil
+}
+
+// configuredUpstreams returns the upstream servers of the configuration in
+// the database at dbPath, with environment overrides applied.
+func configuredUpstreams(dbPath string) ([]string, error) {
+	// Never create a fresh database: its default upstreams are not the ones
+	// the service uses.
+	if _, err := os.Stat(dbPath); errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%s does not exist; pass -db or -upstreams", dbPath)
+	}
+	db, err := openDatabase(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	cfg, err := db.ExportToConfig(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.ApplyEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+	return cfg.Upstream.Servers, nil
+}
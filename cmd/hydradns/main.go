@@ -2,38 +2,67 @@ package main
 
 import (
 	"context"
-	"errors"
 	"flag"
 	"fmt"
 	"log/slog"
-	"net/http"
+	"net/netip"
 	"os"
 	"os/signal"
+	"slices"
 	"syscall"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jroosing/hydradns/internal/api"
 	"github.com/jroosing/hydradns/internal/api/handlers"
+	"github.com/jroosing/hydradns/internal/api/middleware"
+	"github.com/jroosing/hydradns/internal/blockpage"
 	"github.com/jroosing/hydradns/internal/cluster"
 	"github.com/jroosing/hydradns/internal/config"
 	"github.com/jroosing/hydradns/internal/database"
 	"github.com/jroosing/hydradns/internal/logging"
+	"github.com/jroosing/hydradns/internal/resolvers"
 	"github.com/jroosing/hydradns/internal/server"
 )
 
 const (
 	// DefaultDatabasePath is the default location for the HydraDNS database.
 	DefaultDatabasePath = "hydradns.db"
+	// DatabasePathEnv overrides DefaultDatabasePath; the -db flag takes precedence.
+	DatabasePathEnv = config.EnvPrefix + "DB"
+
+	// queryEventBuffer is the number of query events buffered per
+	// /api/v1/events client before events are dropped.
+	queryEventBuffer = 256
 )
 
 func main() {
-	if err := run(); err != nil {
+	var err error
+	switch command(os.Args) {
+	case "check":
+		err = runCheck(os.Args[2:])
+	case "verify-audit":
+		err = runVerifyAudit(os.Args[2:])
+	case "compare-upstreams":
+		err = runCompareUpstreams(os.Args[2:])
+	default:
+		err = run()
+	}
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
 		os.Exit(1)
 	}
 }
 
+// command returns the subcommand named by the first argument, or "" when
+// there is none.
+func command(args []string) string {
+	if len(args) < 2 {
+		return ""
+	}
+	return args[1]
+}
+
 // cliFlags holds parsed command-line flag values.
 type cliFlags struct {
 	dbPath         string
@@ -47,12 +76,14 @@ type cliFlags struct {
 	clusterPrimary string
 	clusterSecret  string
 	clusterNodeID  string
+	apiKeyFile     string
+	quickstart     bool
 }
 
 // parseFlags parses command-line flags and returns the values.
 func parseFlags() cliFlags {
 	var f cliFlags
-	flag.StringVar(&f.dbPath, "db", DefaultDatabasePath, "Path to SQLite database file")
+	flag.StringVar(&f.dbPath, "db", defaultDatabasePath(), "Path to SQLite database file (env "+DatabasePathEnv+")")
 	flag.StringVar(&f.host, "host", "", "Override DNS server bind host")
 	flag.IntVar(&f.port, "port", 0, "Override DNS server bind port")
 	flag.IntVar(&f.workers, "workers", -1, "Clamp GOMAXPROCS (can only reduce; -1 means default/auto)")
@@ -63,10 +94,137 @@ func parseFlags() cliFlags {
 	flag.StringVar(&f.clusterPrimary, "cluster-primary", "", "Primary node URL for secondary mode")
 	flag.StringVar(&f.clusterSecret, "cluster-secret", "", "Shared secret for cluster authentication")
 	flag.StringVar(&f.clusterNodeID, "cluster-node-id", "", "Unique node ID (auto-generated if empty)")
+	flag.StringVar(&f.apiKeyFile, "api-key-file", "", "Write the API key generated on first run to this file instead of printing it")
+	flag.BoolVar(&f.quickstart, "quickstart", false,
+		"Try HydraDNS without setup: temporary database, DNS on port 5353, filtering with the built-in blocklist")
 	flag.Parse()
 	return f
 }
 
+// defaultDatabasePath returns the database path from the environment, or
+// DefaultDatabasePath.
+func defaultDatabasePath() string {
+	if p := os.Getenv(DatabasePathEnv); p != "" {
+		return p
+	}
+	return DefaultDatabasePath
+}
+
+// openDatabase opens the database at path with connection settings from
+// HYDRADNS_DB_* environment variables.
+func openDatabase(path string) (*database.DB, error) {
+	var opts database.Options
+	if err := opts.ApplyEnv(os.LookupEnv); err != nil {
+		return nil, fmt.Errorf("invalid environment override: %w", err)
+	}
+	return database.OpenWithOptions(path, opts)
+}
+
+// openRunDatabase opens the database to serve from and returns a function
+// closing it. In quickstart mode it is a throwaway database seeded with the
+// embedded settings, and DNS moves to the quickstart port unless -port is
+// given.
+func openRunDatabase(f *cliFlags) (*database.DB, func(), error) {
+	if !f.quickstart {
+		db, err := openDatabase(f.dbPath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open database: %w", err)
+		}
+		return db, func() { _ = db.Close() }, nil
+	}
+
+	qs, err := quickstartConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	db, path, cleanup, err := openQuickstartDatabase(context.Background(), qs)
+	if err != nil {
+		return nil, nil, err
+	}
+	f.dbPath = path
+	if f.port == 0 {
+		f.port = qs.Server.Port
+	}
+	return db, cleanup, nil
+}
+
+// loadConfig exports the configuration from the database and applies
+// HYDRADNS_* environment overrides, then validates the result. It is used at
+// startup and on every reload so overrides survive configuration changes made
+// through the API.
+func loadConfig(ctx context.Context, db *database.DB) (*config.Config, error) {
+	cfg, err := db.ExportToConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export config: %w", err)
+	}
+	if err := cfg.ApplyEnv(os.LookupEnv); err != nil {
+		return nil, fmt.Errorf("invalid environment override: %w", err)
+	}
+	// Report every invalid setting up front rather than failing later
+	// inside whichever component happens to use it first
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// bootstrapDatabase generates the management API key for a database that
+// has none yet and marks first-run setup as pending. Defaults are already seeded
+// by the migrations.
+func bootstrapDatabase(ctx context.Context, db *database.DB) (string, error) {
+	key, err := middleware.GenerateAPIKey()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	if err := db.Bootstrap(ctx, key); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// announceAPIKey hands the generated API key to the operator, either in a
+// file or once on stderr. Nothing is shown when an environment override
+// replaced the key, since the generated one is not in effect.
+func announceAPIKey(logger *slog.Logger, generated, effective, file string) error {
+	switch {
+	case effective != generated:
+		logger.Info("new database bootstrapped; API key set by environment override")
+	case file != "":
+		if err := os.WriteFile(file, []byte(generated+"\n"), 0o600); err != nil {
+			return fmt.Errorf("failed to write API key file: %w", err)
+		}
+		logger.Warn("new database bootstrapped; API key written to file", "file", file)
+	default:
+		fmt.Fprintf(os.Stderr, "\nGenerated API key (shown only once):\n\n    %s\n\n"+
+			"Send it in the X-API-Key header and complete setup with POST /api/v1/setup.\n\n", generated)
+		logger.Warn("new database bootstrapped; API key printed to stderr")
+	}
+	return nil
+}
+
+// blockPageTokens returns the check of the tokens accepted by the block
+// page's allow button: unexpired API tokens with the block-page scope. It
+// returns nil, allowing anyone, when the API itself is unprotected.
+func blockPageTokens(db *database.DB, apiKey string, logger *slog.Logger) blockpage.TokenCheck {
+	if apiKey == "" {
+		return nil
+	}
+	return func(ctx context.Context, token string) bool {
+		if token == "" {
+			return false
+		}
+		t, ok, err := db.GetAPITokenByHash(ctx, middleware.HashToken(token))
+		if err != nil {
+			logger.Error("failed to look up block page token", "err", err)
+			return false
+		}
+		if !ok || (!t.ExpiresAt.IsZero() && !time.Now().Before(t.ExpiresAt)) {
+			return false
+		}
+		return slices.Contains(t.Scopes, middleware.BlockPageScope)
+	}
+}
+
 // applyCLIOverrides applies command-line overrides to the config.
 func applyCLIOverrides(cfg *config.Config, f cliFlags) {
 	if f.host != "" {
@@ -110,28 +268,43 @@ func run() error {
 	flags := parseFlags()
 
 	// Open database (creates with defaults if new)
-	db, err := database.Open(flags.dbPath)
+	db, closeDB, err := openRunDatabase(&flags)
 	if err != nil {
-		return fmt.Errorf("failed to open database: %w", err)
+		return err
 	}
-	defer db.Close()
+	defer closeDB()
 
-	// Export database config to config.Config for compatibility
-	cfg, err := db.ExportToConfig(context.Background())
+	// First run: protect the API with a generated key instead of starting
+	// open. Checked on every start, so a first run that died before storing
+	// the key is completed instead of leaving the API open
+	var generatedKey string
+	pending, err := db.BootstrapPending(context.Background())
 	if err != nil {
-		return fmt.Errorf("failed to load config from database: %w", err)
+		return err
+	}
+	if pending {
+		if generatedKey, err = bootstrapDatabase(context.Background(), db); err != nil {
+			return err
+		}
 	}
 
-	// Apply command-line overrides (these don't persist to database)
+	// Load config from the database, then layer environment and command-line
+	// overrides on top (neither persists to the database)
+	cfg, err := loadConfig(context.Background(), db)
+	if err != nil {
+		return err
+	}
 	applyCLIOverrides(cfg, flags)
 
-	logger := logging.Configure(logging.Config{
+	logControl := logging.NewController(logging.Config{
 		Level:            cfg.Logging.Level,
 		Structured:       cfg.Logging.Structured,
 		StructuredFormat: cfg.Logging.StructuredFormat,
 		IncludePID:       cfg.Logging.IncludePID,
 		ExtraFields:      cfg.Logging.ExtraFields,
 	})
+	logger := logControl.Logger()
+	slog.SetDefault(logger)
 	logger.Info("HydraDNS starting",
 		"database", flags.dbPath,
 		"host", cfg.Server.Host,
@@ -139,6 +312,14 @@ func run() error {
 		"workers", cfg.Server.Workers.String(),
 		"tcp", cfg.Server.EnableTCP,
 	)
+	if flags.quickstart {
+		logger.Warn("quickstart mode: settings live in a temporary database that is removed on exit")
+	}
+	if generatedKey != "" {
+		if err := announceAPIKey(logger, generatedKey, cfg.API.APIKey, flags.apiKeyFile); err != nil {
+			return err
+		}
+	}
 	logger.Info("rate limits", "effective", server.FormatRateLimitsLog(server.RateLimitSettings{
 		CleanupSeconds:   cfg.RateLimit.CleanupSeconds,
 		MaxIPEntries:     cfg.RateLimit.MaxIPEntries,
@@ -149,13 +330,18 @@ func run() error {
 		PrefixBurst:      cfg.RateLimit.PrefixBurst,
 		IPQPS:            cfg.RateLimit.IPQPS,
 		IPBurst:          cfg.RateLimit.IPBurst,
+		Slip:             cfg.RateLimit.Slip,
+		IPv4PrefixLen:    cfg.RateLimit.IPv4PrefixLen,
+		IPv6PrefixLen:    cfg.RateLimit.IPv6PrefixLen,
+		ExemptLocal:      cfg.RateLimit.ExemptLocal,
 	}))
 
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
-	// Build shared filtering policy engine (even if disabled) for API + DNS path
-	policy := server.BuildPolicyEngine(cfg, logger)
+	// Build shared filtering policy engine (even if disabled) for API + DNS path.
+	// Blocklist downloads are cached in the database for conditional refetches.
+	policy := server.BuildPolicyEngine(cfg, logger, db)
 
 	// Create runner early to get DNS stats collector
 	runner := server.NewRunner(logger)
@@ -165,65 +351,446 @@ func run() error {
 	apiSrv := api.New(cfg, db, logger)
 	apiSrv.Handler().SetPolicyEngine(policy)
 
+	// Cluster members, tracked by the primary and passed on to secondaries,
+	// are published in DNS when cluster discovery is enabled
+	members := newClusterMembers(cfg)
+	apiSrv.Handler().SetClusterMembers(members)
+	runner.SetClusterMembersFunc(clusterMembersFunc(members))
+
 	// Wire DNS stats from runner to API handler
 	dnsStats := runner.DNSStats()
 	apiSrv.Handler().SetDNSStatsFunc(func() handlers.DNSStatsSnapshot {
 		snapshot := dnsStats.Snapshot()
-		return handlers.DNSStatsSnapshot{
+		out := handlers.DNSStatsSnapshot{
 			QueriesTotal: snapshot.QueriesTotal,
 			QueriesUDP:   snapshot.QueriesUDP,
 			QueriesTCP:   snapshot.QueriesTCP,
 			ResponsesNX:  snapshot.ResponsesNX,
 			ResponsesErr: snapshot.ResponsesErr,
+			Panics:       snapshot.Panics,
 			AvgLatencyMs: snapshot.AvgLatencyMs,
+			Parse: map[string]handlers.ParseStatsSnapshot{
+				"udp": handlers.ParseStatsSnapshot(snapshot.ParseUDP),
+				"tcp": handlers.ParseStatsSnapshot(snapshot.ParseTCP),
+			},
+			TCPConnsByIP: runner.TCPConnectionsPerIP(),
+		}
+		if counts := runner.AnomalyCounts(); counts != nil {
+			out.Anomalies = make(map[string]uint64, len(counts))
+			for kind, n := range counts {
+				out.Anomalies[string(kind)] = n
+			}
+		}
+		if rules := runner.QTypeRuleStats(); rules != nil {
+			out.QTypeRules = make(map[string]uint64, len(rules))
+			for _, r := range rules {
+				out.QTypeRules[r.Rule] += r.Matched
+			}
+		}
+		if c, ok := runner.CacheStats(); ok {
+			out.Cache = &handlers.CacheStatsSnapshot{
+				EvictionPolicy: c.Policy.String(),
+				Entries:        c.Entries,
+				MaxEntries:     c.MaxEntries,
+				Bytes:          c.Bytes,
+				MaxBytes:       c.MaxBytes,
+				Oversized:      c.Oversized,
+				Hits:           c.Hits,
+				Misses:         c.Misses,
+				NegativeHits:   c.NegativeHits,
+				Evictions:      c.Evictions,
+				Expirations:    c.Expirations,
+				HitRatio:       c.HitRatio,
+			}
+			if sc, ok := runner.SharedCacheStats(); ok {
+				out.Cache.Shared = &handlers.SharedCacheSnapshot{
+					Backend: sc.Backend,
+					Hits:    sc.Hits,
+					Misses:  sc.Misses,
+					Errors:  sc.Errors,
+					Stores:  sc.Stores,
+					Dropped: sc.Dropped,
+				}
+			}
+		}
+		for _, l := range runner.UDPListenerStats() {
+			out.UDPListeners = append(out.UDPListeners, handlers.UDPListenerSnapshot{
+				Listener:       l.Listener,
+				PacketsRead:    l.PacketsRead,
+				PacketsHandled: l.PacketsHandled,
+				PacketsDropped: l.PacketsDropped,
+				RateLimited:    l.RateLimited,
+				Slipped:        l.Slipped,
+				Duplicates:     l.Duplicates,
+				QueueDepth:     l.QueueDepth,
+				QueueCapacity:  l.QueueCapacity,
+				KernelDrops:    l.KernelDrops,
+				RecvQueueBytes: l.RecvQueueBytes,
+				RecvBuffer:     l.RecvBuffer,
+				SendBuffer:     l.SendBuffer,
+			})
+		}
+		return out
+	})
+
+	// Wire live query events from runner to API handler
+	queryEvents := runner.QueryEvents()
+	apiSrv.Handler().SetQueryEventsFunc(func(ctx context.Context) <-chan handlers.QueryEventSnapshot {
+		in, cancel := queryEvents.Subscribe(queryEventBuffer)
+		out := make(chan handlers.QueryEventSnapshot, queryEventBuffer)
+		go func() {
+			defer close(out)
+			defer cancel()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case ev := <-in:
+					select {
+					case out <- handlers.QueryEventSnapshot{
+						Time:      ev.Time,
+						Transport: ev.Transport,
+						Client:    ev.Client,
+						Name:      ev.Name,
+						Type:      ev.Type.String(),
+						RCode:     ev.RCode.String(),
+						Source:    ev.Source,
+						Duration:  ev.Duration,
+						TraceID:   ev.TraceID,
+					}:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}()
+		return out
+	})
+
+	// Wire anomaly alerts from runner to API handler
+	apiSrv.Handler().SetAnomaliesFunc(func() []handlers.AnomalySnapshot {
+		anomalies := runner.RecentAnomalies()
+		out := make([]handlers.AnomalySnapshot, 0, len(anomalies))
+		for _, a := range anomalies {
+			out = append(out, handlers.AnomalySnapshot{
+				Time:   a.Time,
+				Client: a.Client,
+				Kind:   string(a.Kind),
+				Name:   a.Name,
+				Detail: a.Detail,
+			})
+		}
+		return out
+	})
+
+	// Wire query log privacy changes from the API to the runner. The level
+	// was checked by the API.
+	apiSrv.Handler().SetPrivacyFunc(func(p config.PrivacyConfig) {
+		level, _ := server.ParsePrivacyLevel(p.Level)
+		runner.SetPrivacy(level, p.Anonymize == "hash")
+	})
+
+	// Packet captures started from the API record on the runner's listeners
+	// and upstream exchanges
+	apiSrv.Handler().SetPacketCapture(runner.PacketCapture())
+
+	// Log level and format can be changed from the API without a restart
+	apiSrv.Handler().SetLogController(logControl)
+
+	// Wire health canary results from runner to API handler
+	apiSrv.Handler().SetCanaryFunc(func() *handlers.CanarySnapshot {
+		status, ok := runner.CanaryStatus()
+		if !ok {
+			return nil
+		}
+		out := &handlers.CanarySnapshot{
+			Healthy:      status.Healthy,
+			LastRound:    status.LastRound,
+			FailedRounds: status.FailedRounds,
+			Checks:       make([]handlers.CanaryCheckSnapshot, 0, len(status.Checks)),
+		}
+		for _, ch := range status.Checks {
+			out.Checks = append(out.Checks, handlers.CanaryCheckSnapshot{
+				Domain:              ch.Domain,
+				OK:                  ch.OK,
+				RCode:               ch.RCode,
+				Latency:             ch.Latency,
+				Error:               ch.Error,
+				CheckedAt:           ch.CheckedAt,
+				LastSuccess:         ch.LastSuccess,
+				ConsecutiveFailures: ch.ConsecutiveFailures,
+			})
+		}
+		return out
+	})
+
+	// Wire the shadow upstream comparison from runner to API handler
+	apiSrv.Handler().SetShadowFunc(func() *handlers.ShadowSnapshot {
+		status, ok := runner.ShadowStatus()
+		if !ok {
+			return nil
+		}
+		out := &handlers.ShadowSnapshot{
+			Servers:        status.Servers,
+			Percent:        status.Percent,
+			Mirrored:       status.Mirrored,
+			Matched:        status.Matched,
+			Diverged:       status.Diverged,
+			Failed:         status.Failed,
+			Skipped:        status.Skipped,
+			PrimaryLatency: status.PrimaryLatency,
+			ShadowLatency:  status.ShadowLatency,
+			Recent:         make([]handlers.ShadowDivergenceSnapshot, 0, len(status.Recent)),
+		}
+		for _, d := range status.Recent {
+			out.Recent = append(out.Recent, handlers.ShadowDivergenceSnapshot{
+				Time:    d.Time,
+				Name:    d.Name,
+				Type:    d.Type,
+				TraceID: d.TraceID,
+				Primary: handlers.ShadowAnswerSnapshot(d.Primary),
+				Shadow:  handlers.ShadowAnswerSnapshot(d.Shadow),
+			})
 		}
+		return out
+	})
+
+	// Wire DNS advertisement and client coverage from runner to API handler
+	apiSrv.Handler().SetAdvertiseFunc(func() *handlers.AdvertiseSnapshot {
+		status, ok := runner.AdvertiseStatus()
+		if !ok {
+			return nil
+		}
+		out := &handlers.AdvertiseSnapshot{
+			Port:        status.Port,
+			Changed:     status.Changed,
+			Delivered:   status.Delivered,
+			LastAttempt: status.LastAttempt,
+			LastSuccess: status.LastSuccess,
+			LastError:   status.LastError,
+			Interfaces:  make([]handlers.AdvertiseInterfaceSnapshot, 0, len(status.Interfaces)),
+		}
+		for _, iface := range status.Interfaces {
+			snap := handlers.AdvertiseInterfaceSnapshot{Name: iface.Name}
+			for _, ip := range iface.IPv4 {
+				snap.IPv4 = append(snap.IPv4, ip.String())
+			}
+			for _, ip := range iface.IPv6 {
+				snap.IPv6 = append(snap.IPv6, ip.String())
+			}
+			out.Interfaces = append(out.Interfaces, snap)
+		}
+		return out
+	})
+	apiSrv.Handler().SetCoverageFunc(func() *handlers.CoverageSnapshot {
+		status, ok := runner.CoverageStatus()
+		if !ok {
+			return nil
+		}
+		out := &handlers.CoverageSnapshot{
+			ClientSubnets: status.ClientSubnets,
+			Untracked:     status.Untracked,
+			Uncovered:     make([]handlers.UncoveredSubnetSnapshot, 0, len(status.Uncovered)),
+		}
+		for _, u := range status.Uncovered {
+			out.Uncovered = append(out.Uncovered, handlers.UncoveredSubnetSnapshot(u))
+		}
+		return out
+	})
+
+	// Wire custom DNS address health from runner to API handler
+	apiSrv.Handler().SetRecordHealthFunc(func() []handlers.RecordHealthSnapshot {
+		status := runner.RecordHealthStatus()
+		out := make([]handlers.RecordHealthSnapshot, 0, len(status))
+		for _, st := range status {
+			out = append(out, handlers.RecordHealthSnapshot{
+				Name:                st.Name,
+				Addr:                st.Addr.String(),
+				Check:               st.Check,
+				Healthy:             st.Healthy,
+				ConsecutiveFailures: st.ConsecutiveFailures,
+				Error:               st.Error,
+				CheckedAt:           st.CheckedAt,
+				ChangedAt:           st.ChangedAt,
+			})
+		}
+		return out
+	})
+
+	// Wire component states from runner to API handler
+	apiSrv.Handler().SetComponentsFunc(func() []handlers.ComponentSnapshot {
+		components := runner.Components()
+		out := make([]handlers.ComponentSnapshot, 0, len(components))
+		for _, c := range components {
+			out = append(out, handlers.ComponentSnapshot{
+				Name:     c.Name,
+				Required: c.Policy == server.Required,
+				State:    string(c.State),
+				Error:    c.Error,
+				Since:    c.Since,
+			})
+		}
+		return out
+	})
+
+	// Wire readiness from runner to API handler
+	apiSrv.Handler().SetReadinessFunc(runner.NotReady)
+
+	// Wire per-upstream statistics from runner to API handler
+	apiSrv.Handler().SetUpstreamStatusFunc(func() []handlers.UpstreamStatusSnapshot {
+		statuses := runner.UpstreamStatus()
+		out := make([]handlers.UpstreamStatusSnapshot, 0, len(statuses))
+		for _, s := range statuses {
+			ip, _ := netip.ParseAddr(s.Address)
+			geo := runner.GeoIPLookup(ip)
+			out = append(out, handlers.UpstreamStatusSnapshot{
+				Address:             s.Address,
+				Healthy:             s.Healthy,
+				Queries:             s.Queries,
+				Successes:           s.Successes,
+				Failures:            s.Failures,
+				SuccessRate:         s.SuccessRate,
+				ConsecutiveFailures: s.ConsecutiveFailures,
+				RTTP50Ms:            float64(s.RTTP50) / float64(time.Millisecond),
+				RTTP95Ms:            float64(s.RTTP95) / float64(time.Millisecond),
+				LastSuccess:         s.LastSuccess,
+				LastFailure:         s.LastFailure,
+				LastError:           s.LastError,
+				MismatchedResponses: s.MismatchedResponses,
+				Country:             geo.Country,
+				ASN:                 geo.ASN,
+				ASOrg:               geo.ASOrg,
+			})
+		}
+		return out
 	})
 
 	// Wire custom DNS reload function
 	apiSrv.Handler().SetCustomDNSReloadFunc(func() error {
-		// Re-export custom DNS from database to config
-		updatedCfg, err := db.ExportToConfig(ctx)
+		updatedCfg, err := loadConfig(ctx, db)
 		if err != nil {
-			return fmt.Errorf("failed to export config: %w", err)
+			return err
 		}
 		return runner.ReloadCustomDNS(updatedCfg)
 	})
 
-	logger.Info("web UI and API starting", "addr", apiSrv.Addr())
+	// Wire upstream reload function
+	apiSrv.Handler().SetUpstreamReloadFunc(func() error {
+		updatedCfg, err := loadConfig(ctx, db)
+		if err != nil {
+			return err
+		}
+		return runner.ReloadUpstreams(updatedCfg)
+	})
 
-	go func() {
-		serveErr := apiSrv.ListenAndServe()
-		if serveErr == nil || errors.Is(serveErr, http.ErrServerClosed) {
-			return
+	// Wire cache zone override changes
+	apiSrv.Handler().SetCacheReloadFunc(func() error {
+		updatedCfg, err := loadConfig(ctx, db)
+		if err != nil {
+			return err
 		}
-		logger.Error("API server error", "err", serveErr)
-		cancel()
-	}()
+		return runner.ReloadNegativeCacheRules(updatedCfg)
+	})
+
+	// Wire cache purging for temporary allows
+	apiSrv.Handler().SetCachePurgeFunc(runner.PurgeCache)
+
+	// The API starts once DNS is being served and stops before the listeners
+	runner.AddComponent(server.Component{
+		Name: "api",
+		Start: func(context.Context) error {
+			logger.Info("web UI and API starting", "addr", apiSrv.Addr())
+			return nil
+		},
+		Run: func(ctx context.Context) error {
+			serveErr := make(chan error, 1)
+			go func() { serveErr <- apiSrv.ListenAndServe() }()
+			select {
+			case err := <-serveErr:
+				return err
+			case <-ctx.Done():
+				return nil
+			}
+		},
+		Stop: func(ctx context.Context) error {
+			defer logger.Info("web UI and API stopped")
+			return apiSrv.Shutdown(ctx)
+		},
+	})
 
-	// Start cluster syncer if in secondary mode
-	var syncer *cluster.Syncer
+	// Block page failures are logged but do not stop DNS service
+	if cfg.BlockPage.Enabled {
+		bp := blockpage.New(cfg.BlockPage, blockPageTokens(db, cfg.API.APIKey, logger), policy, logger)
+		runner.AddComponent(server.Component{Name: "blockpage", Policy: server.Optional, Run: bp.Run})
+	}
+
+	// Start cluster syncer if in secondary mode. A node that cannot sync
+	// keeps serving its last imported config.
 	if cfg.Cluster.Mode == config.ClusterModeSecondary {
-		syncer = startClusterSyncer(ctx, cfg, db, logger, apiSrv.Handler(), runner)
+		var syncer *cluster.Syncer
+		runner.AddComponent(server.Component{
+			Name:   "cluster-syncer",
+			Policy: server.Optional,
+			Start: func(context.Context) error {
+				s, err := startClusterSyncer(ctx, cfg, db, logger, apiSrv.Handler(), runner, members)
+				syncer = s
+				return err
+			},
+			Stop: func(context.Context) error {
+				syncer.Stop()
+				return nil
+			},
+		})
 	} else if cfg.Cluster.Mode != "" && cfg.Cluster.Mode != config.ClusterModeStandalone {
 		logger.Info("cluster mode", "mode", cfg.Cluster.Mode, "node_id", cfg.Cluster.NodeID)
 	}
 
-	err = runner.RunWithContext(ctx, cfg)
-
-	// Stop cluster syncer if running
-	if syncer != nil {
-		syncer.Stop()
+	if err := runner.RunWithContext(ctx, cfg); err != nil {
+		return fmt.Errorf("server exited with error: %w", err)
 	}
+	return nil
+}
 
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
-	_ = apiSrv.Shutdown(shutdownCtx)
-	shutdownCancel()
-	logger.Info("web UI and API stopped")
+// newClusterMembers returns the cluster member list holding this node. Its
+// address is the configured discovery address, or the DNS listen address
+// when that is a single address.
+func newClusterMembers(cfg *config.Config) *cluster.Members {
+	role := cfg.Cluster.Mode
+	if role == "" {
+		role = config.ClusterModeStandalone
+	}
+	self := cluster.Member{
+		NodeID:  cfg.Cluster.NodeID,
+		Role:    role,
+		Address: cfg.ClusterDiscovery.Address,
+		DNSPort: cfg.Server.Port,
+		APIPort: cfg.API.Port,
+	}
+	if ip, err := netip.ParseAddr(cfg.Server.Host); self.Address == "" && err == nil && !ip.IsUnspecified() {
+		self.Address = ip.Unmap().String()
+	}
+	return cluster.NewMembers(self, time.Duration(cfg.ClusterDiscovery.MemberTimeout)*time.Second)
+}
 
-	if err != nil {
-		return fmt.Errorf("server exited with error: %w", err)
+// clusterMembersFunc returns the members of m as published by the cluster
+// discovery resolver.
+func clusterMembersFunc(m *cluster.Members) resolvers.ClusterMembersFunc {
+	return func() ([]resolvers.ClusterMember, uint64) {
+		list, generation := m.List()
+		out := make([]resolvers.ClusterMember, len(list))
+		for i, member := range list {
+			// Addresses were checked when recorded; unset ones stay invalid
+			addr, _ := netip.ParseAddr(member.Address)
+			out[i] = resolvers.ClusterMember{
+				NodeID:  member.NodeID,
+				Role:    string(member.Role),
+				Addr:    addr,
+				DNSPort: uint16(member.DNSPort),
+				APIPort: uint16(member.APIPort),
+			}
+		}
+		return out, generation
 	}
-	return nil
 }
 
 // startClusterSyncer initializes and starts the cluster syncer for secondary mode.
@@ -234,7 +801,8 @@ func startClusterSyncer(
 	logger *slog.Logger,
 	h *handlers.Handler,
 	runner *server.Runner,
-) *cluster.Syncer {
+	members *cluster.Members,
+) (*cluster.Syncer, error) {
 	logger.InfoContext(ctx, "starting cluster syncer",
 		"primary_url", cfg.Cluster.PrimaryURL,
 		"node_id", cfg.Cluster.NodeID,
@@ -252,14 +820,23 @@ func startClusterSyncer(
 
 	// Reload function: refreshes runtime components after config import
 	reloadFunc := func() error {
-		// Re-export config from database and reload custom DNS
-		updatedCfg, err := db.ExportToConfig(ctx)
+		updatedCfg, err := loadConfig(ctx, db)
 		if err != nil {
-			return fmt.Errorf("failed to export config: %w", err)
+			return err
 		}
 		if err := runner.ReloadCustomDNS(updatedCfg); err != nil {
 			return fmt.Errorf("failed to reload custom DNS: %w", err)
 		}
+		if err := runner.ReloadUpstreams(updatedCfg); err != nil {
+			return fmt.Errorf("failed to reload upstreams: %w", err)
+		}
+		h.SetCustomDNS(updatedCfg.CustomDNS)
+		// Temporary allows synced from the primary; they lapse on their own
+		if pe := h.GetPolicyEngine(); pe != nil {
+			for domain, expiry := range updatedCfg.Filtering.TemporaryAllows {
+				pe.AllowUntil(domain, expiry)
+			}
+		}
 		logger.DebugContext(ctx, "config imported and reloaded")
 		return nil
 	}
@@ -271,17 +848,42 @@ func startClusterSyncer(
 
 	syncer, err := cluster.NewSyncer(&cfg.Cluster, logger, importFunc, reloadFunc, versionFunc)
 	if err != nil {
-		logger.ErrorContext(ctx, "failed to create cluster syncer", "err", err)
-		return nil
+		return nil, fmt.Errorf("failed to create cluster syncer: %w", err)
 	}
 
+	// Sections function: returns the section hashes applied by past imports,
+	// so primary changes are picked up even when local edits bumped the
+	// local version
+	syncer.SetSectionsFunc(func() (map[string]string, error) {
+		applied, err := db.GetAppliedSections(ctx)
+		if err != nil {
+			return nil, err
+		}
+		hashes := make(map[string]string, len(applied))
+		for name, section := range applied {
+			hashes[name] = section.Hash
+		}
+		return hashes, nil
+	})
+
+	// Apply changes function: applies incremental changes from the primary,
+	// so later syncs do not fetch the full export
+	syncer.SetApplyChangesFunc(func(changes *cluster.Changes) error {
+		if err := db.ApplyClusterChanges(ctx, changes); err != nil {
+			return err
+		}
+		return db.SetVersion(ctx, changes.Version)
+	})
+
+	// Describe this node to the primary and take over its member list
+	syncer.SetMembers(members)
+
 	// Set syncer on handler for API access
 	h.SetClusterSyncer(syncer)
 
 	if err := syncer.Start(ctx); err != nil {
-		logger.ErrorContext(ctx, "failed to start cluster syncer", "err", err)
-		return nil
+		return nil, fmt.Errorf("failed to start cluster syncer: %w", err)
 	}
 
-	return syncer
+	return syncer, nil
 }
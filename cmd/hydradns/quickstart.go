@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/jroosing/hydradns/internal/config"
+	"github.com/jroosing/hydradns/internal/database"
+)
+
+// quickstartSettings holds the settings of --quickstart: an unprivileged
+// DNS port and filtering with the built-in default blocklist.
+//
+//go:embed quickstart.json
+var quickstartSettings []byte
+
+// quickstartConfig returns the embedded quickstart settings.
+func quickstartConfig() (*config.Config, error) {
+	var cfg config.Config
+	if err := json.Unmarshal(quickstartSettings, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid embedded quickstart settings: %w", err)
+	}
+	return &cfg, nil
+}
+
+// openQuickstartDatabase creates a database in a new temporary directory,
+// so that trying HydraDNS leaves no state behind, and stores the filtering
+// settings of qs in it in place of the default blocklists. The returned function closes the database and
+// removes the directory.
+func openQuickstartDatabase(ctx context.Context, qs *config.Config) (*database.DB, string, func(), error) {
+	dir, err := os.MkdirTemp("", "hydradns-quickstart-")
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to create quickstart directory: %w", err)
+	}
+	path := filepath.Join(dir, "hydradns.db")
+	db, err := openDatabase(path)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, "", nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	cleanup := func() {
+		_ = db.Close()
+		_ = os.RemoveAll(dir)
+	}
+
+	if err := db.SetFilteringConfigTyped(ctx, &qs.Filtering); err != nil {
+		cleanup()
+		return nil, "", nil, err
+	}
+	// The default remote lists stay available but off, so nothing is
+	// downloaded before filtering works
+	seeded, err := db.GetBlocklists(ctx)
+	if err != nil {
+		cleanup()
+		return nil, "", nil, err
+	}
+	for _, bl := range seeded {
+		if err := db.EnableBlocklist(ctx, bl.Name, false); err != nil {
+			cleanup()
+			return nil, "", nil, err
+		}
+	}
+	for _, bl := range qs.Filtering.Blocklists {
+		if err := db.AddBlocklist(ctx, bl.Name, bl.URL, bl.Format); err != nil {
+			cleanup()
+			return nil, "", nil, err
+		}
+	}
+	return db, path, cleanup, nil
+}
@@ -170,6 +170,16 @@ func TestRoutes_WithAPIKey_InvalidKey(t *testing.T) {
 	assert.Equal(t, http.StatusUnauthorized, w.Code)
 }
 
+func TestRoutes_WithAPIKey_ReadyIsPublic(t *testing.T) {
+	cfg := createTestConfig()
+	cfg.API.APIKey = "secret-key"
+	server := api.New(cfg, nil, nil)
+
+	w := performRequest(server.Engine(), http.MethodGet, "/api/v1/ready", "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
 func TestRoutes_WithAPIKey_MissingKey(t *testing.T) {
 	cfg := createTestConfig()
 	cfg.API.APIKey = "secret-key"
@@ -184,6 +194,59 @@ func TestRoutes_WithAPIKey_MissingKey(t *testing.T) {
 	assert.Equal(t, http.StatusUnauthorized, w.Code)
 }
 
+func TestRoutes_WithAPIKey_SetupStatusIsPublic(t *testing.T) {
+	cfg := createTestConfig()
+	cfg.API.APIKey = "secret-key"
+	server := api.New(cfg, nil, nil)
+
+	// Without a database the handler answers 503, but it is reached without a key
+	w := performRequest(server.Engine(), http.MethodGet, "/api/v1/setup", "")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	w = performRequest(server.Engine(), http.MethodPost, "/api/v1/setup", `{}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestRoutes_SecondaryRejectsSyncedChanges(t *testing.T) {
+	cfg := createTestConfig()
+	cfg.Cluster.Mode = config.ClusterModeSecondary
+	cfg.Cluster.PrimaryURL = "http://primary:8080"
+	server := api.New(cfg, nil, nil)
+
+	writes := []struct{ method, path, body string }{
+		{http.MethodPut, "/api/v1/upstreams", `{"servers":["1.1.1.1"]}`},
+		{http.MethodPut, "/api/v1/filtering/enabled", `{"enabled":true}`},
+		{http.MethodPut, "/api/v1/filtering/blocklists/ads/enabled", `{"enabled":false}`},
+		// Only local custom DNS records may be removed on a secondary
+		{http.MethodDelete, "/api/v1/custom-dns/cnames/www.home", ""},
+	}
+	for _, tt := range writes {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			w := performRequest(server.Engine(), tt.method, tt.path, tt.body)
+			require.Equal(t, http.StatusConflict, w.Code)
+
+			var resp models.ReadOnlyErrorResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Equal(t, "http://primary:8080", resp.PrimaryURL)
+			assert.NotEmpty(t, resp.Error)
+		})
+	}
+
+	// Reads stay available
+	w := performRequest(server.Engine(), http.MethodGet, "/api/v1/upstreams", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestRoutes_PrimaryAcceptsChanges(t *testing.T) {
+	cfg := createTestConfig()
+	cfg.Cluster.Mode = config.ClusterModePrimary
+	server := api.New(cfg, nil, nil)
+
+	// Without a database the handler answers 503, but it is reached
+	w := performRequest(server.Engine(), http.MethodPut, "/api/v1/upstreams", `{"servers":["1.1.1.1"]}`)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
 func TestRoutes_NoAPIKey_NoAuth(t *testing.T) {
 	cfg := createTestConfig()
 	cfg.API.APIKey = "" // No API key configured
@@ -228,6 +291,26 @@ func TestRoutes_SwaggerEndpoint(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 }
 
+func TestRoutes_OpenAPISpec(t *testing.T) {
+	cfg := createTestConfig()
+	cfg.API.APIKey = "secret"
+	server := api.New(cfg, nil, nil)
+
+	// The spec is public, like the Swagger UI.
+	w := performRequest(server.Engine(), http.MethodGet, "/openapi.json", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+
+	var spec map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &spec))
+	assert.Equal(t, "2.0", spec["swagger"])
+	assert.Contains(t, spec, "paths")
+
+	w = performRequest(server.Engine(), http.MethodGet, "/openapi.yaml", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "swagger:")
+}
+
 // ============================================================================
 // Not Found Tests
 // ============================================================================
@@ -253,3 +336,31 @@ func TestRoutes_PutConfig_NotImplemented(t *testing.T) {
 
 	assert.Equal(t, http.StatusNotImplemented, w.Code)
 }
+
+// ============================================================================
+// Limit Tests
+// ============================================================================
+
+func TestRoutes_RateLimitsAPI(t *testing.T) {
+	cfg := createTestConfig()
+	cfg.API.RateLimitRPS = 1
+	cfg.API.RateLimitBurst = 2
+	server := api.New(cfg, nil, nil)
+
+	assert.Equal(t, http.StatusOK, performRequest(server.Engine(), http.MethodGet, "/api/v1/health", "").Code)
+	assert.Equal(t, http.StatusOK, performRequest(server.Engine(), http.MethodGet, "/api/v1/health", "").Code)
+	assert.Equal(t, http.StatusTooManyRequests, performRequest(server.Engine(), http.MethodGet, "/api/v1/health", "").Code)
+
+	// Documentation is not rate limited.
+	assert.Equal(t, http.StatusOK, performRequest(server.Engine(), http.MethodGet, "/openapi.json", "").Code)
+}
+
+func TestRoutes_LimitsBodySize(t *testing.T) {
+	cfg := createTestConfig()
+	cfg.API.MaxBodyBytes = 64
+	server := api.New(cfg, nil, nil)
+
+	body := `{"domains": ["` + strings.Repeat("a", 100) + `.example.com"]}`
+	w := performRequest(server.Engine(), http.MethodPost, "/api/v1/filtering/whitelist", body)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
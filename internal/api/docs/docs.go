@@ -22,43 +22,59 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/cluster/config": {
+        "/advertise": {
             "get": {
+                "description": "Reports the DNS addresses advertised to the router or DHCP server and whether the webhook accepted\nthem, and the client subnets that queried from outside the configured client subnets.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "system"
+                ],
+                "summary": "DNS advertisement and client coverage",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.AdvertiseResponse"
+                        }
+                    }
+                },
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
-                ],
-                "description": "Returns the current cluster configuration (secrets redacted)",
+                ]
+            }
+        },
+        "/anomalies": {
+            "get": {
+                "description": "Lists the latest alerts (up to 100, newest first) for clients showing DNS tunneling indicators: high NXDOMAIN or TXT/NULL query ratios, or long or high-entropy labels. Empty when anomaly detection is disabled.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "cluster"
+                    "system"
                 ],
-                "summary": "Get cluster configuration",
+                "summary": "Recent anomaly alerts",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ClusterConfigRequest"
-                        }
-                    },
-                    "500": {
-                        "description": "Internal Server Error",
-                        "schema": {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.AnomaliesResponse"
                         }
                     }
-                }
-            },
-            "put": {
+                },
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
-                ],
-                "description": "Sets the cluster mode and configuration for this node",
+                ]
+            }
+        },
+        "/batch": {
+            "post": {
+                "description": "Applies custom DNS and whitelist/blacklist changes all or nothing. Operations are checked in order, each against the state left by the ones before it; when any cannot be applied, every error is returned and nothing changes. Otherwise all changes are written in a single transaction and the resolvers reload once. Operations: add_host, update_host (name, ips), delete_host (name), add_cname, update_cname (name, target), delete_cname (name), whitelist_add, whitelist_remove, blacklist_add, blacklist_remove (domains). On a cluster secondary, as with the single-record endpoints, changes are local and only local records and domains can be deleted.",
                 "consumes": [
                     "application/json"
                 ],
@@ -66,17 +82,17 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "cluster"
+                    "batch"
                 ],
-                "summary": "Configure cluster settings",
+                "summary": "Apply a batch of changes",
                 "parameters": [
                     {
-                        "description": "Cluster configuration",
-                        "name": "config",
+                        "description": "Operations to apply",
+                        "name": "batch",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ClusterConfigRequest"
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.BatchRequest"
                         }
                     }
                 ],
@@ -84,13 +100,13 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.SetClusterConfigResponse"
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.BatchResponse"
                         }
                     },
                     "400": {
-                        "description": "Bad Request",
+                        "description": "Invalid operations; nothing applied",
                         "schema": {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.BatchResponse"
                         }
                     },
                     "500": {
@@ -98,62 +114,88 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
                         }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
                     }
-                }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
             }
         },
-        "/cluster/export": {
+        "/cache/overrides": {
             "get": {
-                "description": "Returns configuration data for secondary nodes to import (primary only)",
+                "description": "Lists the negative and SERVFAIL cache settings overridden for a zone and its subdomains, ordered by zone",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "cluster"
+                    "cache"
                 ],
-                "summary": "Export configuration for cluster sync",
+                "summary": "List cache zone overrides",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_cluster.ExportData"
-                        }
-                    },
-                    "403": {
-                        "description": "Forbidden",
-                        "schema": {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
-                        }
-                    },
-                    "500": {
-                        "description": "Internal Server Error",
-                        "schema": {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.CacheOverridesResponse"
                         }
                     }
-                }
-            }
-        },
-        "/cluster/status": {
-            "get": {
+                },
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
+                ]
+            }
+        },
+        "/cache/overrides/{zone}": {
+            "put": {
+                "description": "Adds or replaces the negative and SERVFAIL cache settings for a zone and its subdomains. Unset fields\ninherit the global setting. Responses already cached keep their TTL. Cache settings are node-local and are not synced.",
+                "consumes": [
+                    "application/json"
                 ],
-                "description": "Returns the current cluster mode and synchronization status",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "cluster"
+                    "cache"
+                ],
+                "summary": "Set the cache override of a zone",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Zone",
+                        "name": "zone",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Cache settings for the zone",
+                        "name": "override",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.SetCacheOverrideRequest"
+                        }
+                    }
                 ],
-                "summary": "Get cluster status",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ClusterStatusResponse"
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_config.CacheZoneOverride"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
                         }
                     },
                     "500": {
@@ -161,25 +203,38 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
                         }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
                     }
-                }
-            }
-        },
-        "/cluster/sync": {
-            "post": {
+                },
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
-                ],
-                "description": "Triggers an immediate configuration sync from the primary node",
+                ]
+            },
+            "delete": {
+                "description": "Applies the global negative and SERVFAIL cache settings to the zone again",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "cluster"
+                    "cache"
+                ],
+                "summary": "Remove the cache override of a zone",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Zone",
+                        "name": "zone",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "Force immediate sync (secondary only)",
                 "responses": {
                     "200": {
                         "description": "OK",
@@ -187,8 +242,8 @@ const docTemplate = `{
                             "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.StatusResponse"
                         }
                     },
-                    "403": {
-                        "description": "Forbidden",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
                             "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
                         }
@@ -198,47 +253,53 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
                         }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
                     }
-                }
-            }
-        },
-        "/config": {
-            "get": {
+                },
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
-                ],
-                "description": "Returns the current server configuration (sensitive fields redacted)",
+                ]
+            }
+        },
+        "/capture": {
+            "get": {
+                "description": "Returns whether a packet capture is running and how many messages the latest one recorded",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "config"
+                    "system"
                 ],
-                "summary": "Get current configuration",
+                "summary": "Get packet capture status",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ConfigResponse"
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.CaptureStatusResponse"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "503": {
+                        "description": "Service Unavailable",
                         "schema": {
                             "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
                         }
                     }
-                }
-            },
-            "put": {
+                },
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
-                ],
-                "description": "Updates server configuration (requires restart for some settings)",
+                ]
+            },
+            "post": {
+                "description": "Records the next count raw DNS messages exchanged with clients and upstream servers, optionally only those about a name (and its subdomains) or a client address or prefix. A running capture is replaced. Client addresses are anonymized or dropped according to the query-log privacy level, and captures are refused at level none. Download the result as a pcap file from /capture/pcap. Requires the admin API key.",
                 "consumes": [
                     "application/json"
                 ],
@@ -246,25 +307,25 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "config"
+                    "system"
                 ],
-                "summary": "Update configuration",
+                "summary": "Start a packet capture",
                 "parameters": [
                     {
-                        "description": "Configuration update",
-                        "name": "config",
+                        "description": "What to capture",
+                        "name": "capture",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ConfigResponse"
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.StartCaptureRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "202": {
+                        "description": "Accepted",
                         "schema": {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.StatusResponse"
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.CaptureStatusResponse"
                         }
                     },
                     "400": {
@@ -273,111 +334,123 @@ const docTemplate = `{
                             "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
                         }
                     },
-                    "501": {
-                        "description": "Not Implemented",
+                    "403": {
+                        "description": "Captures refused at the current privacy level",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
                         "schema": {
                             "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
                         }
                     }
-                }
-            }
-        },
-        "/config/reload": {
-            "post": {
+                },
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
-                ],
-                "description": "Triggers a hot reload of configuration from disk",
+                ]
+            },
+            "delete": {
+                "description": "Stops the running packet capture early, keeping what it recorded for download",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "config"
+                    "system"
                 ],
-                "summary": "Reload configuration",
+                "summary": "Stop the packet capture",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.StatusResponse"
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.CaptureStatusResponse"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "503": {
+                        "description": "Service Unavailable",
                         "schema": {
                             "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
                         }
                     }
-                }
-            }
-        },
-        "/custom-dns": {
-            "get": {
+                },
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
-                ],
-                "description": "Returns all configured custom DNS host and CNAME records",
+                ]
+            }
+        },
+        "/capture/pcap": {
+            "get": {
+                "description": "Returns the messages recorded by the latest packet capture as a pcap file for Wireshark or tcpdump, including those of a capture still running",
                 "produces": [
-                    "application/json"
+                    "application/vnd.tcpdump.pcap"
                 ],
                 "tags": [
-                    "custom-dns"
+                    "system"
                 ],
-                "summary": "List all custom DNS records",
+                "summary": "Download the packet capture",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.CustomDNSRecordsResponse"
+                            "type": "file"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
                         "schema": {
                             "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
                         }
                     }
-                }
-            }
-        },
-        "/custom-dns/cnames": {
-            "post": {
+                },
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
-                ],
-                "description": "Adds a new custom DNS CNAME record",
-                "consumes": [
-                    "application/json"
-                ],
+                ]
+            }
+        },
+        "/cluster/changes": {
+            "get": {
+                "description": "Returns the changes to the synced configuration after a position in the primary's change journal, so secondaries do not fetch the full export on every sync (primary only). Answers 410 Gone when the journal no longer has these changes; the secondary then fetches the full export.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "custom-dns"
+                    "cluster"
                 ],
-                "summary": "Add a CNAME record",
+                "summary": "Export configuration changes for cluster sync",
                 "parameters": [
                     {
-                        "description": "CNAME record to add",
-                        "name": "record",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.AddCNAMERequest"
-                        }
+                        "type": "string",
+                        "description": "Journal ID from the previous export or changes",
+                        "name": "journal",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Sequence number from the previous export or changes",
+                        "name": "since",
+                        "in": "query",
+                        "required": true
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.CustomDNSOperationResponse"
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_cluster.Changes"
                         }
                     },
                     "400": {
@@ -386,8 +459,14 @@ const docTemplate = `{
                             "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
                         }
                     },
-                    "409": {
-                        "description": "CNAME already exists",
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "Gone",
                         "schema": {
                             "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
                         }
@@ -401,14 +480,38 @@ const docTemplate = `{
                 }
             }
         },
-        "/custom-dns/cnames/{alias}": {
-            "put": {
+        "/cluster/config": {
+            "get": {
+                "description": "Returns the current cluster configuration (secrets redacted)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "cluster"
+                ],
+                "summary": "Get cluster configuration",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ClusterConfigRequest"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    }
+                },
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
-                ],
-                "description": "Updates the target for an existing custom DNS CNAME",
+                ]
+            },
+            "put": {
+                "description": "Sets the cluster mode and configuration for this node",
                 "consumes": [
                     "application/json"
                 ],
@@ -416,24 +519,17 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "custom-dns"
+                    "cluster"
                 ],
-                "summary": "Update a CNAME record",
+                "summary": "Configure cluster settings",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "CNAME alias",
-                        "name": "alias",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "description": "Updated target",
-                        "name": "record",
+                        "description": "Cluster configuration",
+                        "name": "config",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.UpdateCNAMERequest"
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ClusterConfigRequest"
                         }
                     }
                 ],
@@ -441,7 +537,7 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.CustomDNSOperationResponse"
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.SetClusterConfigResponse"
                         }
                     },
                     "400": {
@@ -450,52 +546,39 @@ const docTemplate = `{
                             "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
-                        "schema": {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
-                        }
-                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
                             "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
                         }
                     }
-                }
-            },
-            "delete": {
+                },
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
-                ],
-                "description": "Removes a custom DNS CNAME record",
+                ]
+            }
+        },
+        "/cluster/export": {
+            "get": {
+                "description": "Returns configuration data for secondary nodes to import (primary only)",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "custom-dns"
-                ],
-                "summary": "Delete a CNAME record",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "CNAME alias",
-                        "name": "alias",
-                        "in": "path",
-                        "required": true
-                    }
+                    "cluster"
                 ],
+                "summary": "Export configuration for cluster sync",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.CustomDNSOperationResponse"
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_cluster.ExportData"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "403": {
+                        "description": "Forbidden",
                         "schema": {
                             "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
                         }
@@ -509,14 +592,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/custom-dns/hosts": {
+        "/cluster/promote": {
             "post": {
-                "security": [
-                    {
-                        "ApiKeyAuth": []
-                    }
-                ],
-                "description": "Adds a new custom DNS host record (A/AAAA)",
+                "description": "Converts this secondary into the primary when the primary is lost: stops the syncer, switches the cluster mode to primary (bumping the config version), and records the promotion in the audit trail. Refused while the primary still serves configuration or if this node never synced from it, unless force is set. Other secondaries must be pointed at the new primary.",
                 "consumes": [
                     "application/json"
                 ],
@@ -524,25 +602,25 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "custom-dns"
+                    "cluster"
                 ],
-                "summary": "Add a host record",
+                "summary": "Promote this secondary to primary",
                 "parameters": [
                     {
-                        "description": "Host record to add",
-                        "name": "record",
+                        "description": "Promotion confirmation",
+                        "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.AddHostRequest"
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ClusterPromoteRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.CustomDNSOperationResponse"
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ClusterPromotion"
                         }
                     },
                     "400": {
@@ -551,8 +629,14 @@ const docTemplate = `{
                             "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
                         }
                     },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    },
                     "409": {
-                        "description": "Host already exists",
+                        "description": "Conflict",
                         "schema": {
                             "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
                         }
@@ -563,62 +647,60 @@ const docTemplate = `{
                             "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
                         }
                     }
-                }
-            }
-        },
-        "/custom-dns/hosts/{name}": {
-            "put": {
+                },
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
-                ],
-                "description": "Updates the IP addresses for an existing custom DNS host",
-                "consumes": [
-                    "application/json"
-                ],
+                ]
+            }
+        },
+        "/cluster/promotions": {
+            "get": {
+                "description": "Returns the audit trail of promotions of this node from secondary to primary, newest first",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "custom-dns"
-                ],
-                "summary": "Update a host record",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "Host name",
-                        "name": "name",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "description": "Updated IP addresses",
-                        "name": "record",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.UpdateHostRequest"
-                        }
-                    }
+                    "cluster"
                 ],
+                "summary": "List promotions to primary",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.CustomDNSOperationResponse"
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ClusterPromotionsResponse"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "500": {
+                        "description": "Internal Server Error",
                         "schema": {
                             "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
                         }
-                    },
-                    "404": {
-                        "description": "Not Found",
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/cluster/status": {
+            "get": {
+                "description": "Returns the current cluster mode and synchronization status",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "cluster"
+                ],
+                "summary": "Get cluster status",
+                "responses": {
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ClusterStatusResponse"
                         }
                     },
                     "500": {
@@ -627,40 +709,33 @@ const docTemplate = `{
                             "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
                         }
                     }
-                }
-            },
-            "delete": {
+                },
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
-                ],
-                "description": "Removes a custom DNS host record",
+                ]
+            }
+        },
+        "/cluster/sync": {
+            "post": {
+                "description": "Triggers an immediate configuration sync from the primary node",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "custom-dns"
-                ],
-                "summary": "Delete a host record",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "Host name",
-                        "name": "name",
-                        "in": "path",
-                        "required": true
-                    }
+                    "cluster"
                 ],
+                "summary": "Force immediate sync (secondary only)",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.CustomDNSOperationResponse"
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.StatusResponse"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "403": {
+                        "description": "Forbidden",
                         "schema": {
                             "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
                         }
@@ -671,64 +746,64 @@ const docTemplate = `{
                             "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
                         }
                     }
-                }
-            }
-        },
-        "/filtering/blacklist": {
-            "get": {
+                },
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
-                ],
-                "description": "Returns all domains in the blacklist",
+                ]
+            }
+        },
+        "/config": {
+            "get": {
+                "description": "Returns the current server configuration (sensitive fields redacted)",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "filtering"
+                    "config"
                 ],
-                "summary": "Get blacklist domains",
+                "summary": "Get current configuration",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.DomainListResponse"
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ConfigResponse"
                         }
                     },
-                    "503": {
-                        "description": "Service Unavailable",
+                    "500": {
+                        "description": "Internal Server Error",
                         "schema": {
                             "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
                         }
                     }
-                }
-            },
-            "post": {
+                },
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
-                ],
-                "description": "Adds one or more domains to the blacklist",
-                "consumes": [
-                    "application/json"
+                ]
+            },
+            "put": {
+                "description": "Updates server configuration (requires restart for some settings)",
+                "consumes": [
+                    "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "filtering"
+                    "config"
                 ],
-                "summary": "Add domains to blacklist",
+                "summary": "Update configuration",
                 "parameters": [
                     {
-                        "description": "Domains to add",
-                        "name": "domains",
+                        "description": "Configuration update",
+                        "name": "config",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.DomainRequest"
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ConfigResponse"
                         }
                     }
                 ],
@@ -745,42 +820,30 @@ const docTemplate = `{
                             "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
                         }
                     },
-                    "503": {
-                        "description": "Service Unavailable",
+                    "501": {
+                        "description": "Not Implemented",
                         "schema": {
                             "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
                         }
                     }
-                }
-            },
-            "delete": {
+                },
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
-                ],
-                "description": "Removes one or more domains from the blacklist",
-                "consumes": [
-                    "application/json"
-                ],
+                ]
+            }
+        },
+        "/config/reload": {
+            "post": {
+                "description": "Triggers a hot reload of configuration from disk",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "filtering"
-                ],
-                "summary": "Remove domains from blacklist",
-                "parameters": [
-                    {
-                        "description": "Domains to remove",
-                        "name": "domains",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.DomainDeleteRequest"
-                        }
-                    }
+                    "config"
                 ],
+                "summary": "Reload configuration",
                 "responses": {
                     "200": {
                         "description": "OK",
@@ -788,60 +851,54 @@ const docTemplate = `{
                             "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.StatusResponse"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
-                        "schema": {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
-                        }
-                    },
-                    "501": {
-                        "description": "Not Implemented",
+                    "500": {
+                        "description": "Internal Server Error",
                         "schema": {
                             "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
                         }
                     }
-                }
-            }
-        },
-        "/filtering/blocklists": {
-            "get": {
+                },
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
-                ],
-                "description": "Returns all configured blocklists",
+                ]
+            }
+        },
+        "/custom-dns": {
+            "get": {
+                "description": "Returns all configured custom DNS host and CNAME records",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "filtering"
+                    "custom-dns"
                 ],
-                "summary": "Get blocklists",
+                "summary": "List all custom DNS records",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.BlocklistsResponse"
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.CustomDNSRecordsResponse"
                         }
                     },
-                    "503": {
-                        "description": "Service Unavailable",
+                    "500": {
+                        "description": "Internal Server Error",
                         "schema": {
                             "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
                         }
                     }
-                }
-            }
-        },
-        "/filtering/blocklists/{name}/enabled": {
-            "put": {
+                },
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
-                ],
-                "description": "Toggles a specific blocklist on or off (takes effect after restart until hot-reload is implemented)",
+                ]
+            }
+        },
+        "/custom-dns/cnames": {
+            "post": {
+                "description": "Adds a new custom DNS CNAME record",
                 "consumes": [
                     "application/json"
                 ],
@@ -849,32 +906,25 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "filtering"
+                    "custom-dns"
                 ],
-                "summary": "Enable or disable a blocklist",
+                "summary": "Add a CNAME record",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Blocklist name",
-                        "name": "name",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "description": "Enable state",
-                        "name": "enabled",
+                        "description": "CNAME record to add",
+                        "name": "record",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.FilteringEnabledRequest"
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.AddCNAMERequest"
                         }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "201": {
+                        "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.StatusResponse"
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.CustomDNSOperationResponse"
                         }
                     },
                     "400": {
@@ -883,44 +933,62 @@ const docTemplate = `{
                             "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
                         }
                     },
-                    "503": {
-                        "description": "Service Unavailable",
+                    "409": {
+                        "description": "CNAME already exists",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
                         "schema": {
                             "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
                         }
                     }
-                }
-            }
-        },
-        "/filtering/blocklists/{name}/refresh": {
-            "post": {
+                },
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
+                ]
+            }
+        },
+        "/custom-dns/cnames/{alias}": {
+            "put": {
+                "description": "Updates the target for an existing custom DNS CNAME",
+                "consumes": [
+                    "application/json"
                 ],
-                "description": "Marks a blocklist as refreshed (updates last_fetched); engine reload pending future hot-reload",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "filtering"
+                    "custom-dns"
                 ],
-                "summary": "Refresh a blocklist",
+                "summary": "Update a CNAME record",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Blocklist name",
-                        "name": "name",
+                        "description": "CNAME alias",
+                        "name": "alias",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "description": "Updated target",
+                        "name": "record",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.UpdateCNAMERequest"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.StatusResponse"
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.CustomDNSOperationResponse"
                         }
                     },
                     "400": {
@@ -929,134 +997,234 @@ const docTemplate = `{
                             "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
                         }
                     },
-                    "503": {
-                        "description": "Service Unavailable",
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
                         "schema": {
                             "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
                         }
                     }
-                }
-            }
-        },
-        "/filtering/enabled": {
-            "put": {
+                },
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
-                ],
-                "description": "Toggles the filtering engine on or off",
-                "consumes": [
-                    "application/json"
-                ],
+                ]
+            },
+            "delete": {
+                "description": "Removes a custom DNS CNAME record",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "filtering"
+                    "custom-dns"
                 ],
-                "summary": "Enable or disable filtering",
+                "summary": "Delete a CNAME record",
                 "parameters": [
                     {
-                        "description": "Enable state",
-                        "name": "enabled",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.FilteringEnabledRequest"
-                        }
+                        "type": "string",
+                        "description": "CNAME alias",
+                        "name": "alias",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.StatusResponse"
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.CustomDNSOperationResponse"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
                             "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
                         }
                     },
-                    "503": {
-                        "description": "Service Unavailable",
+                    "409": {
+                        "description": "Record is synced from the primary",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ReadOnlyErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
                         "schema": {
                             "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
                         }
                     }
-                }
-            }
-        },
-        "/filtering/stats": {
-            "get": {
+                },
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
-                ],
-                "description": "Returns detailed filtering statistics",
+                ]
+            }
+        },
+        "/custom-dns/export": {
+            "get": {
+                "description": "Exports all custom DNS hosts and CNAMEs as JSON, as CSV rows of type,name,value, or as a hosts file. CNAMEs cannot be expressed in a hosts file and are left out of that format.",
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "text/plain"
                 ],
                 "tags": [
-                    "filtering"
+                    "custom-dns"
+                ],
+                "summary": "Export custom DNS records",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "json",
+                        "description": "Export format: json, csv, or hosts",
+                        "name": "format",
+                        "in": "query"
+                    }
                 ],
-                "summary": "Get filtering statistics",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.FilteringStatsResponse"
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.CustomDNSBulk"
                         }
                     },
-                    "503": {
-                        "description": "Service Unavailable",
+                    "400": {
+                        "description": "Bad Request",
                         "schema": {
                             "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
                         }
                     }
-                }
-            }
-        },
-        "/filtering/whitelist": {
-            "get": {
+                },
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
-                ],
-                "description": "Returns all domains in the whitelist",
+                ]
+            }
+        },
+        "/custom-dns/health": {
+            "get": {
+                "description": "Lists the health of every address of the custom DNS hosts with a health check. Unhealthy addresses are left out of answers until a probe passes again. Empty when no health checks are configured.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "filtering"
+                    "custom-dns"
                 ],
-                "summary": "Get whitelist domains",
+                "summary": "Custom DNS address health",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.DomainListResponse"
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.RecordHealthResponse"
                         }
-                    },
-                    "503": {
-                        "description": "Service Unavailable",
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/custom-dns/history": {
+            "get": {
+                "description": "Lists the saved versions of the custom DNS records, newest first. A version is the records as they were before the change it names; rolling back to it undoes that change and every later one. The newest 100 versions are kept.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "custom-dns"
+                ],
+                "summary": "Custom DNS change history",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.CustomDNSHistoryResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
                         "schema": {
                             "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
                         }
                     }
-                }
-            },
-            "post": {
+                },
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
+                ]
+            }
+        },
+        "/custom-dns/history/{id}/rollback": {
+            "post": {
+                "description": "Restores the custom DNS records of a saved version, undoing the change it names and every later one. The records replaced are saved as a new version, so the rollback can be undone too.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "custom-dns"
+                ],
+                "summary": "Roll back custom DNS",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Version ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "description": "Adds one or more domains to the whitelist",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.CustomDNSOperationResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Node is a cluster secondary",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ReadOnlyErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/custom-dns/hosts": {
+            "post": {
+                "description": "Adds a new custom DNS host record (A/AAAA)",
                 "consumes": [
                     "application/json"
                 ],
@@ -1064,25 +1232,25 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "filtering"
+                    "custom-dns"
                 ],
-                "summary": "Add domains to whitelist",
+                "summary": "Add a host record",
                 "parameters": [
                     {
-                        "description": "Domains to add",
-                        "name": "domains",
+                        "description": "Host record to add",
+                        "name": "record",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.DomainRequest"
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.AddHostRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "201": {
+                        "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.StatusResponse"
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.CustomDNSOperationResponse"
                         }
                     },
                     "400": {
@@ -1091,21 +1259,29 @@ const docTemplate = `{
                             "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
                         }
                     },
-                    "503": {
-                        "description": "Service Unavailable",
+                    "409": {
+                        "description": "Host already exists",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
                         "schema": {
                             "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
                         }
                     }
-                }
-            },
-            "delete": {
+                },
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
-                ],
-                "description": "Removes one or more domains from the whitelist",
+                ]
+            }
+        },
+        "/custom-dns/hosts/{name}": {
+            "put": {
+                "description": "Updates the IP addresses for an existing custom DNS host",
                 "consumes": [
                     "application/json"
                 ],
@@ -1113,17 +1289,24 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "filtering"
+                    "custom-dns"
                 ],
-                "summary": "Remove domains from whitelist",
+                "summary": "Update a host record",
                 "parameters": [
                     {
-                        "description": "Domains to remove",
-                        "name": "domains",
+                        "type": "string",
+                        "description": "Host name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Updated IP addresses",
+                        "name": "record",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.DomainDeleteRequest"
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.UpdateHostRequest"
                         }
                     }
                 ],
@@ -1131,7 +1314,7 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.StatusResponse"
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.CustomDNSOperationResponse"
                         }
                     },
                     "400": {
@@ -1140,628 +1323,4498 @@ const docTemplate = `{
                             "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
                         }
                     },
-                    "501": {
-                        "description": "Not Implemented",
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
                         "schema": {
                             "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
                         }
                     }
-                }
-            }
-        },
-        "/health": {
-            "get": {
-                "description": "Returns server health status",
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            },
+            "delete": {
+                "description": "Removes a custom DNS host record",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "system"
+                    "custom-dns"
+                ],
+                "summary": "Delete a host record",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Host name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "Health check",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.StatusResponse"
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.CustomDNSOperationResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Record is synced from the primary",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ReadOnlyErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
                         }
                     }
-                }
-            }
-        },
-        "/stats": {
-            "get": {
+                },
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
+                ]
+            }
+        },
+        "/custom-dns/import": {
+            "post": {
+                "description": "Imports custom DNS hosts and CNAMEs in bulk, in the formats of the export. Every record is validated first; when any is invalid, the errors are returned and nothing is imported. In merge mode (default) imported names replace the records of the same name and other records are kept; in replace mode the import replaces all records (not allowed on a cluster secondary). With dry_run=true nothing is changed, and the response tells what would be.",
+                "consumes": [
+                    "application/json",
+                    "text/plain"
                 ],
-                "description": "Returns runtime statistics including system CPU usage, memory usage, and DNS metrics",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "system"
+                    "custom-dns"
+                ],
+                "summary": "Import custom DNS records",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "json",
+                        "description": "Import format: json, csv, or hosts",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "merge",
+                        "description": "merge or replace",
+                        "name": "mode",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Validate and report without importing",
+                        "name": "dry_run",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Records in the given format",
+                        "name": "records",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.CustomDNSBulk"
+                        }
+                    }
                 ],
-                "summary": "Server statistics",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ServerStatsResponse"
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.CustomDNSImportResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid records; nothing imported",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.CustomDNSImportResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Replace on a cluster secondary",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ReadOnlyErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
                         }
                     }
-                }
-            }
-        }
-    },
-    "definitions": {
-        "github_com_jroosing_hydradns_internal_api_models.APIConfigResponse": {
-            "type": "object",
-            "properties": {
-                "enabled": {
-                    "type": "boolean"
-                },
-                "host": {
-                    "type": "string"
                 },
-                "port": {
-                    "type": "integer"
-                }
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/custom-dns/steering": {
+            "get": {
+                "description": "Lists the custom hosts whose answers are steered by client subnet and weight",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "custom-dns"
+                ],
+                "summary": "List custom DNS steering",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.SteeringResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/custom-dns/steering/{name}": {
+            "put": {
+                "description": "Replaces how the answers for a custom host are steered by client subnet and weight. Steering is node-local and is not synced.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "custom-dns"
+                ],
+                "summary": "Set the steering of a custom host",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Host name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Steered addresses",
+                        "name": "steering",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.SetSteeringRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.CustomDNSOperationResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            },
+            "delete": {
+                "description": "Answers all addresses of a custom host again, in answer order",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "custom-dns"
+                ],
+                "summary": "Remove the steering of a custom host",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Host name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.CustomDNSOperationResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/events": {
+            "get": {
+                "description": "Server-Sent Events stream for dashboards. A \"stats\" event carrying DNS statistics is sent on connect and then every interval; a \"query\" event is sent for each answered query. Query events are dropped, not delayed, when the client reads too slowly.",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "system"
+                ],
+                "summary": "Live query and statistics stream",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Statistics interval, e.g. 2s (default 5s, minimum 1s)",
+                        "name": "interval",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include query events (default true)",
+                        "name": "queries",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "event: query",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.QueryEvent"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/filtering/allow-temporarily": {
+            "post": {
+                "description": "Allows a blocked domain (not its subdomains) for a limited time and drops its cached responses. The allow is stored with the whitelist until it expires, so it survives restarts and is synced to cluster secondaries; allows made on a secondary stay local to it. A whitelisted domain is left unchanged.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "filtering"
+                ],
+                "summary": "Allow a domain temporarily",
+                "parameters": [
+                    {
+                        "description": "Domain and duration (at most 24h)",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.TemporaryAllowRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.TemporaryAllowResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/filtering/blacklist": {
+            "get": {
+                "description": "Returns the domains in the blacklist: all of them, or a page of those matching a search",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "filtering"
+                ],
+                "summary": "Get blacklist domains",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only domains containing this text",
+                        "name": "search",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only domains matching this regular expression",
+                        "name": "regex",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "domain",
+                        "description": "domain or added",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "asc",
+                        "description": "asc or desc",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Matching domains to skip",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum domains to return; all when unset",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.DomainListResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            },
+            "post": {
+                "description": "Adds one or more domains to the blacklist",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "filtering"
+                ],
+                "summary": "Add domains to blacklist",
+                "parameters": [
+                    {
+                        "description": "Domains to add",
+                        "name": "domains",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.DomainRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.StatusResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Domain already listed",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            },
+            "delete": {
+                "description": "Removes one or more domains from the blacklist",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "filtering"
+                ],
+                "summary": "Remove domains from blacklist",
+                "parameters": [
+                    {
+                        "description": "Domains to remove",
+                        "name": "domains",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.DomainDeleteRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.StatusResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Domain is synced from the primary",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ReadOnlyErrorResponse"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/filtering/blocklists": {
+            "get": {
+                "description": "Returns all configured blocklists",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "filtering"
+                ],
+                "summary": "Get blocklists",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.BlocklistsResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/filtering/blocklists/{name}/enabled": {
+            "put": {
+                "description": "Toggles a specific blocklist on or off (takes effect after restart until hot-reload is implemented)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "filtering"
+                ],
+                "summary": "Enable or disable a blocklist",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Blocklist name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Enable state",
+                        "name": "enabled",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.FilteringEnabledRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.StatusResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Node is a secondary",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ReadOnlyErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/filtering/blocklists/{name}/refresh": {
+            "post": {
+                "description": "Marks a blocklist as refreshed (updates last_fetched); engine reload pending future hot-reload",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "filtering"
+                ],
+                "summary": "Refresh a blocklist",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Blocklist name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.StatusResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/filtering/check": {
+            "get": {
+                "description": "Reports whether a query for domain would be blocked and by which step, list and rule, without resolving it or counting it in the statistics. With client, the client's captive portal redirect is taken into account too. Filtering itself is the same for all clients.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "filtering"
+                ],
+                "summary": "Check how a domain is filtered",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Domain to check",
+                        "name": "domain",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Client IP address",
+                        "name": "client",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.FilteringCheckResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/filtering/enabled": {
+            "put": {
+                "description": "Toggles the filtering engine on or off",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "filtering"
+                ],
+                "summary": "Enable or disable filtering",
+                "parameters": [
+                    {
+                        "description": "Enable state",
+                        "name": "enabled",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.FilteringEnabledRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.StatusResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Node is a secondary",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ReadOnlyErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/filtering/rpz": {
+            "get": {
+                "description": "Returns the effective whitelist/blacklist as a Response Policy Zone in master file format, for other resolvers to consume",
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "filtering"
+                ],
+                "summary": "Export filtering policy as RPZ",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "rpz.hydradns",
+                        "description": "Zone origin",
+                        "name": "zone",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "RPZ zone file",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/filtering/stats": {
+            "get": {
+                "description": "Returns detailed filtering statistics",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "filtering"
+                ],
+                "summary": "Get filtering statistics",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.FilteringStatsResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/filtering/stats/lists": {
+            "get": {
+                "description": "Returns how many queries each list (the blacklist, each blocklist, threat intelligence, newly registered domains) blocked since startup and its most blocked domains, most blocking list first. A query matching several lists counts for each; unique counts the queries no other list would have blocked.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "filtering"
+                ],
+                "summary": "Get blocks per list",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Most blocked domains per list (at most 100)",
+                        "name": "top",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ListBlockStatsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/filtering/ttl": {
+            "get": {
+                "description": "Returns the TTLs of block page answers and of blocked NXDOMAIN and NODATA answers",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "filtering"
+                ],
+                "summary": "Get block TTLs",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.FilteringTTLs"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            },
+            "put": {
+                "description": "Sets the TTLs of block page answers and of blocked NXDOMAIN and NODATA answers. Short TTLs keep clients from caching a block for long after the domain is unblocked.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "filtering"
+                ],
+                "summary": "Set block TTLs",
+                "parameters": [
+                    {
+                        "description": "TTLs in seconds",
+                        "name": "ttls",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.FilteringTTLs"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.FilteringTTLs"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Node is a secondary",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ReadOnlyErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/filtering/whitelist": {
+            "get": {
+                "description": "Returns the domains in the whitelist: all of them, or a page of those matching a search",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "filtering"
+                ],
+                "summary": "Get whitelist domains",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only domains containing this text",
+                        "name": "search",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only domains matching this regular expression",
+                        "name": "regex",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "domain",
+                        "description": "domain or added",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "asc",
+                        "description": "asc or desc",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Matching domains to skip",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum domains to return; all when unset",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.DomainListResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            },
+            "post": {
+                "description": "Adds one or more domains to the whitelist",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "filtering"
+                ],
+                "summary": "Add domains to whitelist",
+                "parameters": [
+                    {
+                        "description": "Domains to add",
+                        "name": "domains",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.DomainRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.StatusResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Domain already listed",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            },
+            "delete": {
+                "description": "Removes one or more domains from the whitelist",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "filtering"
+                ],
+                "summary": "Remove domains from whitelist",
+                "parameters": [
+                    {
+                        "description": "Domains to remove",
+                        "name": "domains",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.DomainDeleteRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.StatusResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Domain is synced from the primary",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ReadOnlyErrorResponse"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/health": {
+            "get": {
+                "description": "Liveness check: returns ok while the process is up, including during startup and shutdown. Use /ready to decide whether to send traffic.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "system"
+                ],
+                "summary": "Health check",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.StatusResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/health/deep": {
+            "get": {
+                "description": "Reports the state of each server component and whether DNS resolution works, from the health canary's latest self-queries. Returns 503 once no canary domain has resolved for canary.failure_threshold rounds in a row. An optional component that failed makes the status degraded.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "system"
+                ],
+                "summary": "Deep health check",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.DeepHealthResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.DeepHealthResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/logging": {
+            "get": {
+                "description": "Returns the current log level and format, and when a temporary level change ends",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "system"
+                ],
+                "summary": "Get log level and format",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.LoggingResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            },
+            "put": {
+                "description": "Changes the log level and switches between structured and plain text output without a restart, and saves them. With a duration, only the level changes, on this node and for that long (at most 24h), without being saved; the previous level is then restored. Setting a level ends a temporary change.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "system"
+                ],
+                "summary": "Change log level and format",
+                "parameters": [
+                    {
+                        "description": "Level, format, and optional duration",
+                        "name": "logging",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.LoggingRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.LoggingResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/privacy": {
+            "get": {
+                "description": "Returns what the query logs, live query events, and anomaly alerts keep about clients and query names",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "system"
+                ],
+                "summary": "Get query log privacy",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.PrivacyConfig"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            },
+            "put": {
+                "description": "Changes what the query logs, live query events, and anomaly alerts keep about clients and query names. Recorded anomaly alerts are redacted again at the new level, so raising it also anonymizes them.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "system"
+                ],
+                "summary": "Set query log privacy",
+                "parameters": [
+                    {
+                        "description": "Privacy level",
+                        "name": "privacy",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.PrivacyConfig"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.PrivacyConfig"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/ready": {
+            "get": {
+                "description": "Readiness check for load balancers and orchestrators: returns 503 with the reasons while components are starting, blocklists are still loading for the first time, or the server is draining for shutdown. Needs no API key.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "system"
+                ],
+                "summary": "Readiness check",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ReadinessResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ReadinessResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/setup": {
+            "get": {
+                "description": "Reports whether first-run setup is pending. A fresh database is\nbootstrapped with a generated API key, printed once at startup;\nthis endpoint does not require it.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "setup"
+                ],
+                "summary": "First-run setup status",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.SetupStatusResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Applies the initial upstream servers and filtering state and marks\nfirst-run setup as completed. Can only be called once. On a secondary,\nupstreams and filtering come from the primary and cannot be set here.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "setup"
+                ],
+                "summary": "Complete first-run setup",
+                "parameters": [
+                    {
+                        "description": "Initial configuration",
+                        "name": "setup",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.SetupRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.SetupStatusResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/stats": {
+            "get": {
+                "description": "Returns runtime statistics including system CPU usage, memory usage, and DNS metrics",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "system"
+                ],
+                "summary": "Server statistics",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ServerStatsResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/tokens": {
+            "get": {
+                "description": "Lists the scoped API tokens of this node. Token values are never returned. Requires the admin API key.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tokens"
+                ],
+                "summary": "List API tokens",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.APITokenListResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            },
+            "post": {
+                "description": "Creates a named API token limited to the given scopes, for automation that should not hold the admin key. The token value is only returned in this response. Requires the admin API key.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tokens"
+                ],
+                "summary": "Create an API token",
+                "parameters": [
+                    {
+                        "description": "Token name, scopes, and optional expiry",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.CreateAPITokenRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.CreateAPITokenResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Token name already exists",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/tokens/{name}": {
+            "delete": {
+                "description": "Revokes the named API token. Requires the admin API key.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tokens"
+                ],
+                "summary": "Delete an API token",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Token name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.StatusResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/upstreams": {
+            "get": {
+                "description": "Returns the upstream DNS servers in failover order",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "upstreams"
+                ],
+                "summary": "List upstream servers",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.UpstreamsResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            },
+            "put": {
+                "description": "Adds, removes, or reorders upstream DNS servers at runtime. The list\norder is the failover order. Changes are persisted and applied without a restart.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "upstreams"
+                ],
+                "summary": "Replace upstream servers",
+                "parameters": [
+                    {
+                        "description": "Upstream servers in priority order",
+                        "name": "upstreams",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.UpdateUpstreamsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.UpstreamsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Node is a secondary",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ReadOnlyErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/upstreams/compare": {
+            "get": {
+                "description": "Sends one query to every configured upstream in parallel, with the DNSSEC OK bit set, and returns their\nanswers, RTTs, and DNSSEC status side by side. Upstreams whose response code or records (TTLs and order aside)\ndiffer from the majority are flagged.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "upstreams"
+                ],
+                "summary": "Compare upstream answers",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Name to query",
+                        "name": "name",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Record type (default A)",
+                        "name": "type",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.UpstreamCompareResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ErrorResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/upstreams/shadow": {
+            "get": {
+                "description": "Reports how the shadow upstreams compare with the configured ones on mirrored live queries: how many\nanswers matched, diverged, or failed, the mean upstream latency of each side, and the most recent divergences.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "upstreams"
+                ],
+                "summary": "Shadow upstream comparison",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.UpstreamShadowResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        },
+        "/upstreams/status": {
+            "get": {
+                "description": "Returns rolling RTT percentiles (p50/p95), success rate, and consecutive\nfailure counts for each configured upstream, in failover order",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "upstreams"
+                ],
+                "summary": "Upstream server status",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.UpstreamStatusResponse"
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ]
+            }
+        }
+    },
+    "definitions": {
+        "github_com_jroosing_hydradns_internal_api_models.APIConfigResponse": {
+            "type": "object",
+            "properties": {
+                "enabled": {
+                    "type": "boolean"
+                },
+                "host": {
+                    "type": "string"
+                },
+                "max_body_bytes": {
+                    "type": "integer"
+                },
+                "port": {
+                    "type": "integer"
+                },
+                "rate_limit_burst": {
+                    "type": "integer"
+                },
+                "rate_limit_rps": {
+                    "type": "number"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.APITokenListResponse": {
+            "type": "object",
+            "properties": {
+                "tokens": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.APITokenResponse"
+                    }
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.APITokenResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "expired": {
+                    "type": "boolean"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
             }
         },
         "github_com_jroosing_hydradns_internal_api_models.AddCNAMERequest": {
             "type": "object",
             "required": [
-                "alias",
+                "alias",
+                "target"
+            ],
+            "properties": {
+                "alias": {
+                    "type": "string"
+                },
+                "target": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.AddHostRequest": {
+            "type": "object",
+            "required": [
+                "ips",
+                "name"
+            ],
+            "properties": {
+                "ips": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.AdvertiseInterface": {
+            "type": "object",
+            "properties": {
+                "ipv4": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "ipv6": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.AdvertiseResponse": {
+            "type": "object",
+            "properties": {
+                "changed": {
+                    "description": "Changed is when the listening addresses last changed",
+                    "type": "string"
+                },
+                "coverage": {
+                    "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ClientCoverage"
+                },
+                "delivered": {
+                    "description": "Delivered is set once the webhook accepted the current addresses",
+                    "type": "boolean"
+                },
+                "enabled": {
+                    "type": "boolean"
+                },
+                "interfaces": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.AdvertiseInterface"
+                    }
+                },
+                "last_attempt": {
+                    "type": "string"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "last_success": {
+                    "type": "string"
+                },
+                "port": {
+                    "type": "integer"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.AnomaliesResponse": {
+            "type": "object",
+            "properties": {
+                "anomalies": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.Anomaly"
+                    }
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.Anomaly": {
+            "type": "object",
+            "properties": {
+                "client": {
+                    "type": "string"
+                },
+                "detail": {
+                    "type": "string"
+                },
+                "kind": {
+                    "description": "Kind is one of nxdomain_rate, txt_rate, long_label, high_entropy",
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "time": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.BatchError": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "index": {
+                    "type": "integer"
+                },
+                "op": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.BatchOperation": {
+            "type": "object",
+            "properties": {
+                "domains": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "ips": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "op": {
+                    "type": "string"
+                },
+                "target": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.BatchRequest": {
+            "type": "object",
+            "required": [
+                "operations"
+            ],
+            "properties": {
+                "operations": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.BatchOperation"
+                    }
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.BatchResponse": {
+            "type": "object",
+            "properties": {
+                "applied": {
+                    "description": "Applied counts the operations applied: all of them, or none when\nthere are errors.",
+                    "type": "integer"
+                },
+                "errors": {
+                    "description": "Errors lists every operation that cannot be applied.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.BatchError"
+                    }
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.BlockedDomainCount": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer"
+                },
+                "domain": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.Blocklist": {
+            "type": "object",
+            "properties": {
+                "enabled": {
+                    "type": "boolean"
+                },
+                "format": {
+                    "type": "string"
+                },
+                "last_fetched": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.BlocklistsResponse": {
+            "type": "object",
+            "properties": {
+                "blocklists": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.Blocklist"
+                    }
+                },
+                "count": {
+                    "type": "integer"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.CPUStats": {
+            "type": "object",
+            "properties": {
+                "idle_percent": {
+                    "type": "number"
+                },
+                "num_cpu": {
+                    "type": "integer"
+                },
+                "used_percent": {
+                    "type": "number"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.CacheOverridesResponse": {
+            "type": "object",
+            "properties": {
+                "overrides": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_jroosing_hydradns_internal_config.CacheZoneOverride"
+                    }
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.CacheStats": {
+            "type": "object",
+            "properties": {
+                "bytes": {
+                    "description": "Bytes is the total size of cached responses; MaxBytes is the budget,\n0 when unlimited.",
+                    "type": "integer"
+                },
+                "entries": {
+                    "type": "integer"
+                },
+                "eviction_policy": {
+                    "type": "string"
+                },
+                "evictions": {
+                    "description": "Evictions counts live entries removed to make room for new ones.",
+                    "type": "integer"
+                },
+                "expirations": {
+                    "description": "Expirations counts entries removed after their TTL ran out.",
+                    "type": "integer"
+                },
+                "hit_ratio": {
+                    "type": "number"
+                },
+                "hits": {
+                    "type": "integer"
+                },
+                "max_bytes": {
+                    "type": "integer"
+                },
+                "max_entries": {
+                    "type": "integer"
+                },
+                "misses": {
+                    "type": "integer"
+                },
+                "negative_hits": {
+                    "description": "NegativeHits counts hits on NXDOMAIN, NODATA, and SERVFAIL responses;\nthey are included in Hits.",
+                    "type": "integer"
+                },
+                "oversized": {
+                    "description": "Oversized counts responses not cached because they exceed\ncache.max_entry_bytes or cache.max_bytes.",
+                    "type": "integer"
+                },
+                "shared": {
+                    "description": "Shared counts the shared redis or memcached tier; omitted when none\nis configured.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.SharedCacheStats"
+                        }
+                    ]
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.CanaryCheck": {
+            "type": "object",
+            "properties": {
+                "checked_at": {
+                    "type": "string"
+                },
+                "consecutive_failures": {
+                    "type": "integer"
+                },
+                "domain": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "last_success": {
+                    "type": "string"
+                },
+                "latency_ms": {
+                    "type": "number"
+                },
+                "ok": {
+                    "type": "boolean"
+                },
+                "rcode": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.CanaryStatus": {
+            "type": "object",
+            "properties": {
+                "checks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.CanaryCheck"
+                    }
+                },
+                "failed_rounds": {
+                    "description": "Consecutive rounds in which no domain resolved",
+                    "type": "integer"
+                },
+                "healthy": {
+                    "type": "boolean"
+                },
+                "last_round": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.CaptureStatusResponse": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "boolean"
+                },
+                "captured": {
+                    "type": "integer"
+                },
+                "client": {
+                    "type": "string"
+                },
+                "count": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "stopped_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.ClientCoverage": {
+            "type": "object",
+            "properties": {
+                "client_subnets": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "enabled": {
+                    "type": "boolean"
+                },
+                "uncovered": {
+                    "description": "Most queries first",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.UncoveredSubnet"
+                    }
+                },
+                "untracked": {
+                    "description": "Untracked counts queries from uncovered subnets not listed because\ntoo many were already tracked",
+                    "type": "integer"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.ClusterConfigRequest": {
+            "type": "object",
+            "required": [
+                "mode"
+            ],
+            "properties": {
+                "mode": {
+                    "description": "Mode is the cluster mode: \"standalone\", \"primary\", or \"secondary\".",
+                    "type": "string",
+                    "enum": [
+                        "standalone",
+                        "primary",
+                        "secondary"
+                    ]
+                },
+                "node_id": {
+                    "description": "NodeID is a unique identifier for this node (auto-generated if empty).",
+                    "type": "string"
+                },
+                "primary_url": {
+                    "description": "PrimaryURL is the URL of the primary node's API (required for secondary mode).\nExample: \"http://primary.homelab.local:8080\"",
+                    "type": "string"
+                },
+                "shared_secret": {
+                    "description": "SharedSecret is used to authenticate sync requests between nodes.",
+                    "type": "string"
+                },
+                "sync_interval": {
+                    "description": "SyncInterval is how often secondary nodes poll for config changes.\nDefault: \"30s\". Example: \"1m\", \"5m\".",
+                    "type": "string"
+                },
+                "sync_timeout": {
+                    "description": "SyncTimeout is the HTTP timeout for sync requests.\nDefault: \"10s\".",
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.ClusterConfigResponse": {
+            "type": "object",
+            "properties": {
+                "mode": {
+                    "type": "string"
+                },
+                "node_id": {
+                    "type": "string"
+                },
+                "primary_url": {
+                    "type": "string"
+                },
+                "sync_interval": {
+                    "type": "string"
+                },
+                "sync_timeout": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.ClusterMember": {
+            "type": "object",
+            "properties": {
+                "address": {
+                    "description": "Address is the node's IP address; empty when not known.",
+                    "type": "string"
+                },
+                "api_port": {
+                    "type": "integer"
+                },
+                "dns_port": {
+                    "description": "DNSPort and APIPort are the node's DNS and API ports.",
+                    "type": "integer"
+                },
+                "last_seen": {
+                    "description": "LastSeen is when the primary last heard from the node.",
+                    "type": "string"
+                },
+                "node_id": {
+                    "type": "string"
+                },
+                "role": {
+                    "description": "Role is \"primary\" or \"secondary\".",
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.ClusterPromoteRequest": {
+            "type": "object",
+            "properties": {
+                "confirm": {
+                    "description": "Confirm must be true; it guards against accidental promotions.",
+                    "type": "boolean"
+                },
+                "force": {
+                    "description": "Force promotes even if the primary is still reachable or this node\nnever synced from it.",
+                    "type": "boolean"
+                },
+                "reason": {
+                    "description": "Reason is recorded in the promotion audit trail.",
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.ClusterPromotion": {
+            "type": "object",
+            "properties": {
+                "client_ip": {
+                    "type": "string"
+                },
+                "config_version": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "forced": {
+                    "type": "boolean"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "node_id": {
+                    "type": "string"
+                },
+                "previous_primary_url": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "requested_by": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.ClusterPromotionsResponse": {
+            "type": "object",
+            "properties": {
+                "promotions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ClusterPromotion"
+                    }
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.ClusterSectionStatus": {
+            "type": "object",
+            "properties": {
+                "applied_at": {
+                    "description": "AppliedAt is when the imported section last changed (secondary only).",
+                    "type": "string"
+                },
+                "applied_hash": {
+                    "description": "AppliedHash is the hash last imported from the primary (secondary only).",
+                    "type": "string"
+                },
+                "applied_version": {
+                    "description": "AppliedVersion is the primary config version the section last changed\nat when imported (secondary only).",
+                    "type": "integer"
+                },
+                "hash": {
+                    "description": "Hash identifies the section's current content on this node.",
+                    "type": "string"
+                },
+                "locally_modified": {
+                    "description": "LocallyModified is set when the section changed on this node since it\nwas imported, e.g. by local records.",
+                    "type": "boolean"
+                },
+                "name": {
+                    "description": "Name is the section name.",
+                    "type": "string"
+                },
+                "out_of_sync": {
+                    "description": "OutOfSync is set when the primary's section differs from the one last\nimported, e.g. while a sync is pending or failing.",
+                    "type": "boolean"
+                },
+                "primary_hash": {
+                    "description": "PrimaryHash is the primary's hash as of the last sync (secondary only).",
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.ClusterStatusResponse": {
+            "type": "object",
+            "properties": {
+                "config_version": {
+                    "description": "ConfigVersion is the current local configuration version.",
+                    "type": "integer"
+                },
+                "error_count": {
+                    "description": "ErrorCount is the total number of sync errors.",
+                    "type": "integer"
+                },
+                "last_sync_error": {
+                    "description": "LastSyncError is the error message from the last sync attempt (if any).",
+                    "type": "string"
+                },
+                "last_sync_kind": {
+                    "description": "LastSyncKind is \"full\" when the last successful sync fetched the\nfull export from the primary, \"changes\" when it fetched only the\nchanges since the previous sync.",
+                    "type": "string"
+                },
+                "last_sync_time": {
+                    "description": "LastSyncTime is when the last successful sync occurred (only for secondary).",
+                    "type": "string"
+                },
+                "last_sync_version": {
+                    "description": "LastSyncVersion is the config version from the last successful sync.",
+                    "type": "integer"
+                },
+                "members": {
+                    "description": "Members lists the cluster members, this node first. Secondaries\nlearn the other members from the primary.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ClusterMember"
+                    }
+                },
+                "mode": {
+                    "description": "Mode is the cluster mode: \"standalone\", \"primary\", or \"secondary\".",
+                    "type": "string"
+                },
+                "next_sync_time": {
+                    "description": "NextSyncTime is when the next sync is scheduled (only for secondary).",
+                    "type": "string"
+                },
+                "node_id": {
+                    "description": "NodeID is this node's unique identifier.",
+                    "type": "string"
+                },
+                "primary_url": {
+                    "description": "PrimaryURL is the URL of the primary node (only for secondary mode).",
+                    "type": "string"
+                },
+                "sections": {
+                    "description": "Sections reports each synced configuration section and, on a\nsecondary, whether it drifted from the primary.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ClusterSectionStatus"
+                    }
+                },
+                "sync_count": {
+                    "description": "SyncCount is the total number of successful syncs.",
+                    "type": "integer"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.ComponentHealth": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "required": {
+                    "description": "A required component failing stops the server",
+                    "type": "boolean"
+                },
+                "since": {
+                    "type": "string"
+                },
+                "state": {
+                    "description": "pending, starting, running, stopping, stopped, or failed",
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.ConfigResponse": {
+            "type": "object",
+            "properties": {
+                "advertise": {
+                    "$ref": "#/definitions/github_com_jroosing_hydradns_internal_config.AdvertiseConfig"
+                },
+                "anomaly": {
+                    "$ref": "#/definitions/github_com_jroosing_hydradns_internal_config.AnomalyConfig"
+                },
+                "api": {
+                    "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.APIConfigResponse"
+                },
+                "audit": {
+                    "$ref": "#/definitions/github_com_jroosing_hydradns_internal_config.AuditConfig"
+                },
+                "block_page": {
+                    "$ref": "#/definitions/github_com_jroosing_hydradns_internal_config.BlockPageConfig"
+                },
+                "cache": {
+                    "$ref": "#/definitions/github_com_jroosing_hydradns_internal_config.CacheConfig"
+                },
+                "canary": {
+                    "$ref": "#/definitions/github_com_jroosing_hydradns_internal_config.CanaryConfig"
+                },
+                "cluster": {
+                    "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ClusterConfigResponse"
+                },
+                "custom_dns": {
+                    "$ref": "#/definitions/github_com_jroosing_hydradns_internal_config.CustomDNSConfig"
+                },
+                "filtering": {
+                    "$ref": "#/definitions/github_com_jroosing_hydradns_internal_config.FilteringConfig"
+                },
+                "geoip": {
+                    "$ref": "#/definitions/github_com_jroosing_hydradns_internal_config.GeoIPConfig"
+                },
+                "identity": {
+                    "$ref": "#/definitions/github_com_jroosing_hydradns_internal_config.IdentityConfig"
+                },
+                "logging": {
+                    "$ref": "#/definitions/github_com_jroosing_hydradns_internal_config.LoggingConfig"
+                },
+                "nrd": {
+                    "$ref": "#/definitions/github_com_jroosing_hydradns_internal_config.NRDConfig"
+                },
+                "rate_limit": {
+                    "$ref": "#/definitions/github_com_jroosing_hydradns_internal_config.RateLimitConfig"
+                },
+                "server": {
+                    "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ServerConfigResponse"
+                },
+                "shadow": {
+                    "$ref": "#/definitions/github_com_jroosing_hydradns_internal_config.ShadowConfig"
+                },
+                "threat_intel": {
+                    "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ThreatIntelConfigResponse"
+                },
+                "upstream": {
+                    "$ref": "#/definitions/github_com_jroosing_hydradns_internal_config.UpstreamConfig"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.CreateAPITokenRequest": {
+            "type": "object",
+            "required": [
+                "name",
+                "scopes"
+            ],
+            "properties": {
+                "expires_in": {
+                    "description": "ExpiresIn is a Go duration after which the token stops working\n(e.g. \"720h\"); empty for no expiry",
+                    "type": "string"
+                },
+                "name": {
+                    "description": "Name identifies the token, e.g. \"home-assistant\"",
+                    "type": "string"
+                },
+                "scopes": {
+                    "description": "Scopes limit the token to API sections: \"filtering\", \"custom-dns\",\n\"upstreams\", or \"stats\", optionally suffixed with \":read\" for GET only.\n\"block-page\" grants only the allow button of the block page",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.CreateAPITokenResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "expired": {
+                    "type": "boolean"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "token": {
+                    "description": "Token is the secret to send in the X-API-Key header. It cannot be\nretrieved again.",
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.CustomDNSBulk": {
+            "type": "object",
+            "properties": {
+                "cnames": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "hosts": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.CustomDNSCountsResponse": {
+            "type": "object",
+            "properties": {
+                "cnames": {
+                    "type": "integer"
+                },
+                "hosts": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.CustomDNSHistoryResponse": {
+            "type": "object",
+            "properties": {
+                "versions": {
+                    "description": "Versions are newest first.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.CustomDNSVersion"
+                    }
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.CustomDNSImportError": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "line": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.CustomDNSImportResponse": {
+            "type": "object",
+            "properties": {
+                "added": {
+                    "description": "Added, Updated, and Removed count the names that were (or, in a\ndry run, would be) changed; Unchanged those imported as they were.",
+                    "type": "integer"
+                },
+                "cnames": {
+                    "type": "integer"
+                },
+                "dry_run": {
+                    "type": "boolean"
+                },
+                "errors": {
+                    "description": "Errors lists every invalid record; nothing is imported when any is.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.CustomDNSImportError"
+                    }
+                },
+                "hosts": {
+                    "description": "Hosts and CNAMEs count the names imported.",
+                    "type": "integer"
+                },
+                "mode": {
+                    "type": "string"
+                },
+                "removed": {
+                    "type": "integer"
+                },
+                "unchanged": {
+                    "type": "integer"
+                },
+                "updated": {
+                    "type": "integer"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.CustomDNSOperationResponse": {
+            "type": "object",
+            "properties": {
+                "data": {},
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.CustomDNSRecordsResponse": {
+            "type": "object",
+            "properties": {
+                "cnames": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "count": {
+                    "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.CustomDNSCountsResponse"
+                },
+                "hosts": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        }
+                    }
+                },
+                "local": {
+                    "description": "Local lists the names with local records, which cluster sync keeps\non a secondary.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.CustomDNSVersion": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "description": "Action is the change made after the snapshot, e.g.\n\"delete host nas.home.arpa\".",
+                    "type": "string"
+                },
+                "cnames": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "hosts": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        }
+                    }
+                },
+                "id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.DNSStatsResponse": {
+            "type": "object",
+            "properties": {
+                "anomalies": {
+                    "description": "Anomalies is the number of anomaly alerts raised per kind, while\nanomaly detection is enabled.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer",
+                        "format": "int64"
+                    }
+                },
+                "avg_latency_ms": {
+                    "type": "number"
+                },
+                "cache": {
+                    "description": "Cache reports the response cache while the DNS server runs.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.CacheStats"
+                        }
+                    ]
+                },
+                "panics": {
+                    "description": "Panics recovered while serving queries",
+                    "type": "integer"
+                },
+                "parse_errors": {
+                    "description": "Parse counts requests rejected by the DNS parser, keyed by transport.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ParseStats"
+                    }
+                },
+                "qtype_rules": {
+                    "description": "QTypeRules is the number of queries answered by each qtype rule,\nkeyed by a description such as \"ANY -\u003e refused\".",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer",
+                        "format": "int64"
+                    }
+                },
+                "queries_tcp": {
+                    "type": "integer"
+                },
+                "queries_total": {
+                    "type": "integer"
+                },
+                "queries_udp": {
+                    "type": "integer"
+                },
+                "responses_error": {
+                    "type": "integer"
+                },
+                "responses_nxdomain": {
+                    "type": "integer"
+                },
+                "tcp_connections_per_ip": {
+                    "description": "TCPConnections is the number of open TCP connections per client IP.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "udp_listeners": {
+                    "description": "UDPListeners has one entry per SO_REUSEPORT socket while the DNS server runs.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.UDPListenerStats"
+                    }
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.DeepHealthResponse": {
+            "type": "object",
+            "properties": {
+                "canary": {
+                    "description": "Canary holds the health canary's latest results; omitted when the\ncanary is disabled.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.CanaryStatus"
+                        }
+                    ]
+                },
+                "components": {
+                    "description": "Components lists the server's components in start order.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ComponentHealth"
+                    }
+                },
+                "status": {
+                    "description": "Status is \"ok\"; \"degraded\" when an optional component failed and the\nserver runs without it; or \"failing\" when DNS resolution is broken.",
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.DomainDeleteRequest": {
+            "type": "object",
+            "required": [
+                "domains"
+            ],
+            "properties": {
+                "domains": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.DomainListResponse": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "description": "Count is the number of domains returned.",
+                    "type": "integer"
+                },
+                "domains": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "local": {
+                    "description": "Local lists the returned domains added on this node, which cluster\nsync keeps on a secondary.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "matched": {
+                    "type": "integer"
+                },
+                "offset": {
+                    "type": "integer"
+                },
+                "total": {
+                    "description": "Total counts the domains in the list and Matched those matching the\nsearch. Domains is the page of matches starting at Offset, holding\nat most Limit domains when a limit was given.",
+                    "type": "integer"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.DomainRequest": {
+            "type": "object",
+            "required": [
+                "domains"
+            ],
+            "properties": {
+                "domains": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.ErrorResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.FilteringCheckResponse": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "description": "Action is allow, block, log or redirect (captive portal).",
+                    "type": "string"
+                },
+                "blocked": {
+                    "description": "Blocked is true when the client would not get the real answer: the\ndomain is blocked, or the client is redirected to the captive portal.",
+                    "type": "boolean"
+                },
+                "client": {
+                    "type": "string"
+                },
+                "domain": {
+                    "type": "string"
+                },
+                "list": {
+                    "description": "List and Rule are the list and rule that decided, if any.",
+                    "type": "string"
+                },
+                "lists": {
+                    "description": "Lists names every list blocking the domain, even when an earlier step\nsuch as the whitelist decided.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "reason": {
+                    "description": "Reason is the step that decided: captive_portal, disabled, whitelist,\ntemporary_allow, blacklist, temporary_block, nrd or no_match.",
+                    "type": "string"
+                },
+                "rule": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.FilteringEnabledRequest": {
+            "type": "object",
+            "properties": {
+                "enabled": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.FilteringStatsResponse": {
+            "type": "object",
+            "properties": {
+                "blacklist_size": {
+                    "type": "integer"
+                },
+                "enabled": {
+                    "type": "boolean"
+                },
+                "queries_allowed": {
+                    "type": "integer"
+                },
+                "queries_blocked": {
+                    "type": "integer"
+                },
+                "queries_total": {
+                    "type": "integer"
+                },
+                "whitelist_size": {
+                    "type": "integer"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.FilteringTTLs": {
+            "type": "object",
+            "required": [
+                "block_ttl",
+                "negative_ttl"
+            ],
+            "properties": {
+                "block_ttl": {
+                    "type": "integer",
+                    "maximum": 86400,
+                    "minimum": 1
+                },
+                "negative_ttl": {
+                    "type": "integer",
+                    "maximum": 86400,
+                    "minimum": 1
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.ListBlockStats": {
+            "type": "object",
+            "properties": {
+                "blocked": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "top_domains": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.BlockedDomainCount"
+                    }
+                },
+                "unique": {
+                    "type": "integer"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.ListBlockStatsResponse": {
+            "type": "object",
+            "properties": {
+                "lists": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ListBlockStats"
+                    }
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.LoggingRequest": {
+            "type": "object",
+            "properties": {
+                "duration": {
+                    "description": "Duration, a Go duration string such as \"15m\" (at most 24h), makes\nthe level change temporary: the previous level is restored after\nit. Temporary changes are not saved.",
+                    "type": "string"
+                },
+                "level": {
+                    "description": "Level is DEBUG, INFO, WARN, or ERROR",
+                    "type": "string"
+                },
+                "structured": {
+                    "description": "Structured selects structured output in the configured format\n(logging.structured_format) instead of plain text",
+                    "type": "boolean"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.LoggingResponse": {
+            "type": "object",
+            "properties": {
+                "level": {
+                    "type": "string"
+                },
+                "revert_at": {
+                    "description": "RevertAt is when a temporary level change ends, and RevertLevel the\nlevel restored then",
+                    "type": "string"
+                },
+                "revert_level": {
+                    "type": "string"
+                },
+                "structured": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.MemoryStats": {
+            "type": "object",
+            "properties": {
+                "free_mb": {
+                    "type": "number"
+                },
+                "total_mb": {
+                    "type": "number"
+                },
+                "used_mb": {
+                    "type": "number"
+                },
+                "used_percent": {
+                    "type": "number"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.ParseStats": {
+            "type": "object",
+            "properties": {
+                "malformed": {
+                    "type": "integer"
+                },
+                "oversized_messages": {
+                    "type": "integer"
+                },
+                "oversized_names": {
+                    "type": "integer"
+                },
+                "pointer_loops": {
+                    "type": "integer"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.PrivacyConfig": {
+            "type": "object",
+            "required": [
+                "level"
+            ],
+            "properties": {
+                "anonymize": {
+                    "description": "Anonymize is truncate (to the /24 or /48 network) or hash; default\ntruncate.",
+                    "type": "string",
+                    "enum": [
+                        "truncate",
+                        "hash"
+                    ]
+                },
+                "level": {
+                    "description": "Level is full, anonymized (anonymized client addresses), domains\n(query names only), or none.",
+                    "type": "string",
+                    "enum": [
+                        "full",
+                        "anonymized",
+                        "domains",
+                        "none"
+                    ]
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.QueryEvent": {
+            "type": "object",
+            "properties": {
+                "client": {
+                    "type": "string"
+                },
+                "duration_ms": {
+                    "type": "number"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "rcode": {
+                    "type": "string"
+                },
+                "source": {
+                    "type": "string"
+                },
+                "time": {
+                    "type": "string"
+                },
+                "trace_id": {
+                    "description": "TraceID matches the query's log entries",
+                    "type": "string"
+                },
+                "transport": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.ReadOnlyErrorResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "primary_url": {
+                    "description": "PrimaryURL is the primary node where the change should be made.",
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.ReadinessResponse": {
+            "type": "object",
+            "properties": {
+                "reasons": {
+                    "description": "Reasons says why the server is not ready, e.g. \"blocklists are\nloading\" or \"shutting down\"; omitted when ready.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "status": {
+                    "description": "Status is \"ready\" or \"not_ready\".",
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.RecordHealth": {
+            "type": "object",
+            "properties": {
+                "addr": {
+                    "type": "string"
+                },
+                "changed_at": {
+                    "description": "ChangedAt is when healthy last changed; omitted if it never did.",
+                    "type": "string"
+                },
+                "check": {
+                    "description": "Check describes the probe, e.g. \"tcp:5432\" or \"http:8080/healthz\".",
+                    "type": "string"
+                },
+                "checked_at": {
+                    "description": "CheckedAt is omitted before the first probe.",
+                    "type": "string"
+                },
+                "consecutive_failures": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "healthy": {
+                    "type": "boolean"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.RecordHealthResponse": {
+            "type": "object",
+            "properties": {
+                "records": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.RecordHealth"
+                    }
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.ServerConfigResponse": {
+            "type": "object",
+            "properties": {
+                "enable_tcp": {
+                    "type": "boolean"
+                },
+                "host": {
+                    "type": "string"
+                },
+                "listener_profiles": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_jroosing_hydradns_internal_config.ListenerProfile"
+                    }
+                },
+                "max_cname_chain": {
+                    "type": "integer"
+                },
+                "max_concurrency": {
+                    "type": "integer"
+                },
+                "port": {
+                    "type": "integer"
+                },
+                "proxy_protocol_trusted": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "qtype_rules": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_jroosing_hydradns_internal_config.QTypeRule"
+                    }
+                },
+                "recursion_clients": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "shutdown_drain": {
+                    "type": "string"
+                },
+                "tcp_fallback": {
+                    "type": "boolean"
+                },
+                "tcp_idle_timeout": {
+                    "type": "string"
+                },
+                "tcp_listeners": {
+                    "type": "integer"
+                },
+                "tcp_max_conns_per_ip": {
+                    "type": "integer"
+                },
+                "tcp_max_queries_per_conn": {
+                    "type": "integer"
+                },
+                "tcp_read_timeout": {
+                    "type": "string"
+                },
+                "udp_listeners": {
+                    "type": "integer"
+                },
+                "udp_recv_buffer": {
+                    "type": "integer"
+                },
+                "udp_send_buffer": {
+                    "type": "integer"
+                },
+                "upstream_socket_pool_size": {
+                    "type": "integer"
+                },
+                "workers": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.ServerStatsResponse": {
+            "type": "object",
+            "properties": {
+                "api_panics": {
+                    "description": "APIPanics counts panics recovered in API handlers since startup.",
+                    "type": "integer"
+                },
+                "cpu": {
+                    "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.CPUStats"
+                },
+                "dns": {
+                    "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.DNSStatsResponse"
+                },
+                "filtering": {
+                    "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.FilteringStatsResponse"
+                },
+                "memory": {
+                    "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.MemoryStats"
+                },
+                "start_time": {
+                    "type": "string"
+                },
+                "uptime": {
+                    "type": "string"
+                },
+                "uptime_seconds": {
+                    "type": "integer"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.SetCacheOverrideRequest": {
+            "type": "object",
+            "properties": {
+                "disable_negative": {
+                    "type": "boolean"
+                },
+                "negative_ttl": {
+                    "type": "string"
+                },
+                "servfail_ttl": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.SetClusterConfigResponse": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "description": "Message provides additional information about the configuration change.",
+                    "type": "string"
+                },
+                "mode": {
+                    "description": "Mode is the new cluster mode.",
+                    "type": "string"
+                },
+                "node_id": {
+                    "description": "NodeID is this node's identifier.",
+                    "type": "string"
+                },
+                "requires_restart": {
+                    "description": "RequiresRestart indicates if a restart is needed for changes to take effect.",
+                    "type": "boolean"
+                },
+                "status": {
+                    "description": "Status indicates the result of the operation.",
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.SetSteeringRequest": {
+            "type": "object",
+            "required": [
+                "addrs"
+            ],
+            "properties": {
+                "addrs": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "$ref": "#/definitions/github_com_jroosing_hydradns_internal_config.SteeredAddr"
+                    }
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.SetupRequest": {
+            "type": "object",
+            "properties": {
+                "filtering_enabled": {
+                    "description": "FilteringEnabled turns domain filtering on or off.",
+                    "type": "boolean"
+                },
+                "upstreams": {
+                    "description": "Upstreams replaces the upstream servers, in failover order.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.SetupStatusResponse": {
+            "type": "object",
+            "properties": {
+                "setup_pending": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.ShadowAnswer": {
+            "type": "object",
+            "properties": {
+                "answers": {
+                    "description": "Answers are the answer records other than RRSIGs as zone-file lines\nwithout TTLs, sorted",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "latency_ms": {
+                    "type": "number"
+                },
+                "rcode": {
+                    "type": "string"
+                },
+                "upstream": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.ShadowDivergence": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "primary": {
+                    "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ShadowAnswer"
+                },
+                "shadow": {
+                    "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ShadowAnswer"
+                },
+                "time": {
+                    "type": "string"
+                },
+                "trace_id": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.SharedCacheStats": {
+            "type": "object",
+            "properties": {
+                "backend": {
+                    "type": "string"
+                },
+                "dropped": {
+                    "description": "Dropped counts stores skipped because too many were in flight.",
+                    "type": "integer"
+                },
+                "errors": {
+                    "description": "Errors counts failed lookups and stores, e.g. timeouts.",
+                    "type": "integer"
+                },
+                "hits": {
+                    "description": "Hits counts queries answered from the shared cache after missing the\nlocal one; Misses counts those that went upstream.",
+                    "type": "integer"
+                },
+                "misses": {
+                    "type": "integer"
+                },
+                "stores": {
+                    "type": "integer"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.StartCaptureRequest": {
+            "type": "object",
+            "required": [
+                "count"
+            ],
+            "properties": {
+                "client": {
+                    "description": "Client limits the capture to messages exchanged with a client\naddress or prefix, and upstream messages sent on its behalf; empty\nfor any client",
+                    "type": "string"
+                },
+                "count": {
+                    "description": "Count is how many DNS messages to record, queries and responses on\nboth the client and upstream sides (1-10000)",
+                    "type": "integer",
+                    "maximum": 10000,
+                    "minimum": 1
+                },
+                "name": {
+                    "description": "Name limits the capture to messages about the name or its\nsubdomains; empty for any name",
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.StatusResponse": {
+            "type": "object",
+            "properties": {
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.SteeringResponse": {
+            "type": "object",
+            "properties": {
+                "steering": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_jroosing_hydradns_internal_config.HostSteering"
+                    }
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.TemporaryAllowRequest": {
+            "type": "object",
+            "required": [
+                "domain",
+                "duration"
+            ],
+            "properties": {
+                "domain": {
+                    "type": "string"
+                },
+                "duration": {
+                    "description": "Duration is a Go duration string such as \"10m\" or \"1h\".",
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.TemporaryAllowResponse": {
+            "type": "object",
+            "properties": {
+                "cache_purged": {
+                    "description": "CachePurged is the number of cached responses dropped for the domain.",
+                    "type": "integer"
+                },
+                "domain": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.ThreatIntelConfigResponse": {
+            "type": "object",
+            "properties": {
+                "block_ttl": {
+                    "type": "string"
+                },
+                "cache_ttl": {
+                    "type": "string"
+                },
+                "enabled": {
+                    "type": "boolean"
+                },
+                "provider": {
+                    "type": "string"
+                },
+                "rate_limit": {
+                    "type": "number"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.UDPListenerStats": {
+            "type": "object",
+            "properties": {
+                "duplicates": {
+                    "description": "Duplicates counts retransmitted queries answered together with the\nidentical query already being resolved; they are included in\nPacketsHandled.",
+                    "type": "integer"
+                },
+                "kernel_drops": {
+                    "description": "KernelDrops counts datagrams the kernel dropped before the server\nread them, mostly because the receive buffer was full. It and\nRecvQueueBytes are reported on Linux only.",
+                    "type": "integer"
+                },
+                "listener": {
+                    "type": "integer"
+                },
+                "packets_dropped": {
+                    "type": "integer"
+                },
+                "packets_handled": {
+                    "type": "integer"
+                },
+                "packets_read": {
+                    "type": "integer"
+                },
+                "queue_capacity": {
+                    "type": "integer"
+                },
+                "queue_depth": {
+                    "type": "integer"
+                },
+                "rate_limited": {
+                    "type": "integer"
+                },
+                "recv_buffer": {
+                    "description": "RecvBuffer and SendBuffer are the socket buffer sizes in bytes\ngranted by the kernel.",
+                    "type": "integer"
+                },
+                "recv_queue_bytes": {
+                    "type": "integer"
+                },
+                "send_buffer": {
+                    "type": "integer"
+                },
+                "slipped": {
+                    "description": "Slipped counts rate-limited queries answered with TC=1 instead of\ndropped; they are included in RateLimited.",
+                    "type": "integer"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.UncoveredSubnet": {
+            "type": "object",
+            "properties": {
+                "first_seen": {
+                    "type": "string"
+                },
+                "last_seen": {
+                    "type": "string"
+                },
+                "queries": {
+                    "type": "integer"
+                },
+                "subnet": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.UpdateCNAMERequest": {
+            "type": "object",
+            "required": [
                 "target"
             ],
             "properties": {
-                "alias": {
-                    "type": "string"
-                },
-                "target": {
-                    "type": "string"
+                "target": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_api_models.UpdateHostRequest": {
+            "type": "object",
+            "required": [
+                "ips"
+            ],
+            "properties": {
+                "ips": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "type": "string"
+                    }
                 }
             }
         },
-        "github_com_jroosing_hydradns_internal_api_models.AddHostRequest": {
+        "github_com_jroosing_hydradns_internal_api_models.UpdateUpstreamsRequest": {
             "type": "object",
             "required": [
-                "ips",
-                "name"
+                "servers"
             ],
             "properties": {
-                "ips": {
+                "servers": {
                     "type": "array",
+                    "maxItems": 3,
                     "minItems": 1,
                     "items": {
                         "type": "string"
                     }
-                },
-                "name": {
-                    "type": "string"
                 }
             }
         },
-        "github_com_jroosing_hydradns_internal_api_models.Blocklist": {
+        "github_com_jroosing_hydradns_internal_api_models.UpstreamAnswer": {
             "type": "object",
             "properties": {
-                "enabled": {
+                "address": {
+                    "type": "string"
+                },
+                "answers": {
+                    "description": "Answers are the answer records other than RRSIGs as zone-file lines,\nsorted",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "differs": {
+                    "description": "Differs is set when the response code or records, TTLs aside, differ\nfrom those most upstreams returned",
                     "type": "boolean"
                 },
-                "format": {
+                "dnssec": {
+                    "description": "DNSSEC is validated (AD flag set), signed (RRSIGs without AD), or\nunsigned",
                     "type": "string"
                 },
-                "last_fetched": {
+                "error": {
+                    "description": "Error is set when the upstream did not answer",
                     "type": "string"
                 },
-                "name": {
+                "extended_error_code": {
+                    "type": "integer"
+                },
+                "extended_error_text": {
                     "type": "string"
                 },
-                "url": {
+                "rcode": {
                     "type": "string"
+                },
+                "rtt_ms": {
+                    "type": "number"
                 }
             }
         },
-        "github_com_jroosing_hydradns_internal_api_models.BlocklistsResponse": {
+        "github_com_jroosing_hydradns_internal_api_models.UpstreamCompareResponse": {
             "type": "object",
             "properties": {
-                "blocklists": {
+                "consistent": {
+                    "description": "Consistent is set when every upstream that answered returned the same\nresponse code and records",
+                    "type": "boolean"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                },
+                "upstreams": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.Blocklist"
+                        "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.UpstreamAnswer"
                     }
-                },
-                "count": {
-                    "type": "integer"
                 }
             }
         },
-        "github_com_jroosing_hydradns_internal_api_models.CPUStats": {
+        "github_com_jroosing_hydradns_internal_api_models.UpstreamShadowResponse": {
             "type": "object",
             "properties": {
-                "idle_percent": {
-                    "type": "number"
+                "diverged": {
+                    "type": "integer"
                 },
-                "num_cpu": {
+                "enabled": {
+                    "type": "boolean"
+                },
+                "failed": {
                     "type": "integer"
                 },
-                "used_percent": {
+                "matched": {
+                    "type": "integer"
+                },
+                "mirrored": {
+                    "description": "Mirrored counts the queries sent to the shadow upstreams; each is\nmatched, diverged, or failed when no shadow upstream answered",
+                    "type": "integer"
+                },
+                "percent": {
                     "type": "number"
+                },
+                "primary_latency_ms": {
+                    "description": "PrimaryLatencyMs and ShadowLatencyMs are the mean upstream times of\nthe compared queries",
+                    "type": "number"
+                },
+                "recent": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ShadowDivergence"
+                    }
+                },
+                "servers": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "shadow_latency_ms": {
+                    "type": "number"
+                },
+                "skipped": {
+                    "description": "Skipped counts sampled queries not mirrored because too many shadow\nqueries were running",
+                    "type": "integer"
                 }
             }
         },
-        "github_com_jroosing_hydradns_internal_api_models.ClusterConfigRequest": {
+        "github_com_jroosing_hydradns_internal_api_models.UpstreamStatus": {
             "type": "object",
-            "required": [
-                "mode"
-            ],
             "properties": {
-                "mode": {
-                    "description": "Mode is the cluster mode: \"standalone\", \"primary\", or \"secondary\".",
-                    "type": "string",
-                    "enum": [
-                        "standalone",
-                        "primary",
-                        "secondary"
-                    ]
+                "address": {
+                    "type": "string"
                 },
-                "node_id": {
-                    "description": "NodeID is a unique identifier for this node (auto-generated if empty).",
+                "as_org": {
                     "type": "string"
                 },
-                "primary_url": {
-                    "description": "PrimaryURL is the URL of the primary node's API (required for secondary mode).\nExample: \"http://primary.homelab.local:8080\"",
+                "asn": {
+                    "description": "Set when a GeoIP ASN database is loaded",
+                    "type": "integer"
+                },
+                "consecutive_failures": {
+                    "type": "integer"
+                },
+                "country": {
+                    "description": "Set when a GeoIP country database is loaded",
                     "type": "string"
                 },
-                "shared_secret": {
-                    "description": "SharedSecret is used to authenticate sync requests between nodes.",
+                "failures": {
+                    "type": "integer"
+                },
+                "healthy": {
+                    "type": "boolean"
+                },
+                "last_error": {
                     "type": "string"
                 },
-                "sync_interval": {
-                    "description": "SyncInterval is how often secondary nodes poll for config changes.\nDefault: \"30s\". Example: \"1m\", \"5m\".",
+                "last_failure": {
                     "type": "string"
                 },
-                "sync_timeout": {
-                    "description": "SyncTimeout is the HTTP timeout for sync requests.\nDefault: \"10s\".",
+                "last_success": {
                     "type": "string"
+                },
+                "mismatched_responses": {
+                    "type": "integer"
+                },
+                "queries": {
+                    "type": "integer"
+                },
+                "rtt_p50_ms": {
+                    "type": "number"
+                },
+                "rtt_p95_ms": {
+                    "type": "number"
+                },
+                "success_rate": {
+                    "type": "number"
+                },
+                "successes": {
+                    "type": "integer"
                 }
             }
         },
-        "github_com_jroosing_hydradns_internal_api_models.ClusterConfigResponse": {
+        "github_com_jroosing_hydradns_internal_api_models.UpstreamStatusResponse": {
             "type": "object",
             "properties": {
-                "mode": {
-                    "type": "string"
-                },
-                "node_id": {
-                    "type": "string"
-                },
-                "primary_url": {
-                    "type": "string"
-                },
-                "sync_interval": {
-                    "type": "string"
-                },
-                "sync_timeout": {
-                    "type": "string"
+                "upstreams": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.UpstreamStatus"
+                    }
                 }
             }
         },
-        "github_com_jroosing_hydradns_internal_api_models.ClusterStatusResponse": {
+        "github_com_jroosing_hydradns_internal_api_models.UpstreamsResponse": {
             "type": "object",
             "properties": {
-                "config_version": {
-                    "description": "ConfigVersion is the current local configuration version.",
-                    "type": "integer"
-                },
-                "error_count": {
-                    "description": "ErrorCount is the total number of sync errors.",
-                    "type": "integer"
+                "servers": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_cluster.Change": {
+            "type": "object",
+            "properties": {
+                "deleted": {
+                    "description": "Deleted is set when the host, CNAME or domain was removed.",
+                    "type": "boolean"
                 },
-                "last_sync_error": {
-                    "description": "LastSyncError is the error message from the last sync attempt (if any).",
+                "expires_at": {
+                    "description": "Temporary allow expiry",
                     "type": "string"
                 },
-                "last_sync_time": {
-                    "description": "LastSyncTime is when the last successful sync occurred (only for secondary).",
-                    "type": "string"
+                "filtering": {
+                    "description": "Filtering settings, without domain lists",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_config.FilteringConfig"
+                        }
+                    ]
                 },
-                "last_sync_version": {
-                    "description": "LastSyncVersion is the config version from the last successful sync.",
-                    "type": "integer"
+                "ips": {
+                    "description": "Host addresses",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
                 },
-                "mode": {
-                    "description": "Mode is the cluster mode: \"standalone\", \"primary\", or \"secondary\".",
+                "key": {
+                    "description": "Key is the host name, CNAME alias or list or allowed domain; empty\nfor settings.",
                     "type": "string"
                 },
-                "next_sync_time": {
-                    "description": "NextSyncTime is when the next sync is scheduled (only for secondary).",
+                "kind": {
                     "type": "string"
                 },
-                "node_id": {
-                    "description": "NodeID is this node's unique identifier.",
+                "section": {
                     "type": "string"
                 },
-                "primary_url": {
-                    "description": "PrimaryURL is the URL of the primary node (only for secondary mode).",
+                "seq": {
+                    "description": "Seq orders changes within a journal.",
+                    "type": "integer"
+                },
+                "target": {
+                    "description": "CNAME target",
                     "type": "string"
                 },
-                "sync_count": {
-                    "description": "SyncCount is the total number of successful syncs.",
-                    "type": "integer"
+                "upstream": {
+                    "description": "Upstream settings",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_config.UpstreamConfig"
+                        }
+                    ]
                 }
             }
         },
-        "github_com_jroosing_hydradns_internal_api_models.ConfigResponse": {
+        "github_com_jroosing_hydradns_internal_cluster.Changes": {
             "type": "object",
             "properties": {
-                "api": {
-                    "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.APIConfigResponse"
+                "changes": {
+                    "description": "Changes are in order; only the last change of each entry is kept.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_jroosing_hydradns_internal_cluster.Change"
+                    }
                 },
-                "cluster": {
-                    "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ClusterConfigResponse"
+                "journal": {
+                    "description": "Journal identifies the primary's journal; sequence numbers of other\njournals (e.g. from before a primary restart) do not apply.",
+                    "type": "string"
                 },
-                "custom_dns": {
-                    "$ref": "#/definitions/github_com_jroosing_hydradns_internal_config.CustomDNSConfig"
+                "members": {
+                    "description": "Members lists the cluster members known to the primary (see Members).",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_jroosing_hydradns_internal_cluster.Member"
+                    }
                 },
-                "filtering": {
-                    "$ref": "#/definitions/github_com_jroosing_hydradns_internal_config.FilteringConfig"
+                "node_id": {
+                    "type": "string"
                 },
-                "logging": {
-                    "$ref": "#/definitions/github_com_jroosing_hydradns_internal_config.LoggingConfig"
+                "sections": {
+                    "description": "Sections holds the primary's section hashes after the changes.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
                 },
-                "rate_limit": {
-                    "$ref": "#/definitions/github_com_jroosing_hydradns_internal_config.RateLimitConfig"
+                "seq": {
+                    "type": "integer"
                 },
-                "server": {
-                    "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.ServerConfigResponse"
+                "since": {
+                    "type": "integer"
                 },
-                "upstream": {
-                    "$ref": "#/definitions/github_com_jroosing_hydradns_internal_config.UpstreamConfig"
+                "timestamp": {
+                    "type": "string"
+                },
+                "version": {
+                    "description": "Version, Timestamp and NodeID are as in ExportData.",
+                    "type": "integer"
                 }
             }
         },
-        "github_com_jroosing_hydradns_internal_api_models.CustomDNSCountsResponse": {
+        "github_com_jroosing_hydradns_internal_cluster.ExportData": {
             "type": "object",
             "properties": {
-                "cnames": {
-                    "type": "integer"
+                "custom_dns": {
+                    "description": "CustomDNS contains custom DNS records (hosts and CNAMEs).",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_config.CustomDNSConfig"
+                        }
+                    ]
+                },
+                "filtering": {
+                    "description": "Filtering contains domain filtering configuration.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_config.FilteringConfig"
+                        }
+                    ]
+                },
+                "journal": {
+                    "description": "Journal and Seq are the primary's journal position this export\ncorresponds to; later syncs fetch the changes after it (see Journal).",
+                    "type": "string"
                 },
-                "hosts": {
-                    "type": "integer"
+                "members": {
+                    "description": "Members lists the cluster members known to the primary (see Members).",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_jroosing_hydradns_internal_cluster.Member"
+                    }
                 },
-                "total": {
-                    "type": "integer"
-                }
-            }
-        },
-        "github_com_jroosing_hydradns_internal_api_models.CustomDNSOperationResponse": {
-            "type": "object",
-            "properties": {
-                "data": {},
-                "message": {
+                "node_id": {
+                    "description": "NodeID is the primary node's identifier.",
                     "type": "string"
-                }
-            }
-        },
-        "github_com_jroosing_hydradns_internal_api_models.CustomDNSRecordsResponse": {
-            "type": "object",
-            "properties": {
-                "cnames": {
+                },
+                "sections": {
+                    "description": "Sections holds the hash of each section above, keyed by section name\n(see SectionHashes).",
                     "type": "object",
                     "additionalProperties": {
                         "type": "string"
                     }
                 },
-                "count": {
-                    "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.CustomDNSCountsResponse"
+                "seq": {
+                    "type": "integer"
                 },
-                "hosts": {
-                    "type": "object",
-                    "additionalProperties": {
-                        "type": "array",
-                        "items": {
-                            "type": "string"
+                "timestamp": {
+                    "description": "Timestamp is when this export was generated.",
+                    "type": "string"
+                },
+                "upstream": {
+                    "description": "Upstream contains upstream DNS server configuration.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_config.UpstreamConfig"
                         }
-                    }
+                    ]
+                },
+                "version": {
+                    "description": "Version is the configuration version from the primary node.",
+                    "type": "integer"
                 }
             }
         },
-        "github_com_jroosing_hydradns_internal_api_models.DNSStatsResponse": {
+        "github_com_jroosing_hydradns_internal_cluster.Member": {
             "type": "object",
             "properties": {
-                "avg_latency_ms": {
-                    "type": "number"
+                "address": {
+                    "description": "Address is the node's IP address; empty when not known.",
+                    "type": "string"
                 },
-                "queries_tcp": {
+                "api_port": {
                     "type": "integer"
                 },
-                "queries_total": {
+                "dns_port": {
+                    "description": "DNSPort and APIPort are the node's DNS and management API ports;\n0 when not known.",
                     "type": "integer"
                 },
-                "queries_udp": {
-                    "type": "integer"
+                "last_seen": {
+                    "description": "LastSeen is when the primary last heard from the node.",
+                    "type": "string"
                 },
-                "responses_error": {
-                    "type": "integer"
+                "node_id": {
+                    "type": "string"
                 },
-                "responses_nxdomain": {
-                    "type": "integer"
+                "role": {
+                    "$ref": "#/definitions/github_com_jroosing_hydradns_internal_config.ClusterMode"
                 }
             }
         },
-        "github_com_jroosing_hydradns_internal_api_models.DomainDeleteRequest": {
+        "github_com_jroosing_hydradns_internal_config.AdvertiseConfig": {
             "type": "object",
-            "required": [
-                "domains"
-            ],
             "properties": {
-                "domains": {
+                "client_subnets": {
+                    "description": "ClientSubnets are the IP addresses or CIDR prefixes expected to query\nthis server",
                     "type": "array",
-                    "minItems": 1,
                     "items": {
                         "type": "string"
                     }
-                }
-            }
-        },
-        "github_com_jroosing_hydradns_internal_api_models.DomainListResponse": {
-            "type": "object",
-            "properties": {
-                "count": {
-                    "type": "integer"
                 },
-                "domains": {
-                    "type": "array",
-                    "items": {
-                        "type": "string"
-                    }
-                }
-            }
-        },
-        "github_com_jroosing_hydradns_internal_api_models.DomainRequest": {
-            "type": "object",
-            "required": [
-                "domains"
-            ],
-            "properties": {
-                "domains": {
+                "enabled": {
+                    "type": "boolean"
+                },
+                "interfaces": {
+                    "description": "Interfaces limits the advertised addresses to these network\ninterfaces (default: all but loopback)",
                     "type": "array",
-                    "minItems": 1,
                     "items": {
                         "type": "string"
                     }
-                }
-            }
-        },
-        "github_com_jroosing_hydradns_internal_api_models.ErrorResponse": {
-            "type": "object",
-            "properties": {
-                "error": {
+                },
+                "interval": {
+                    "description": "Interval is how often the listening addresses are checked for\nchanges, at least 1s (default: \"30s\")",
+                    "type": "string"
+                },
+                "webhook_secret": {
+                    "description": "WebhookSecret, when set, signs each request body with HMAC-SHA256 in\nthe X-HydraDNS-Signature header",
+                    "type": "string"
+                },
+                "webhook_url": {
+                    "description": "WebhookURL receives the addresses as JSON; required when enabled",
                     "type": "string"
                 }
             }
         },
-        "github_com_jroosing_hydradns_internal_api_models.FilteringEnabledRequest": {
-            "type": "object",
-            "properties": {
-                "enabled": {
-                    "type": "boolean"
-                }
-            }
-        },
-        "github_com_jroosing_hydradns_internal_api_models.FilteringStatsResponse": {
+        "github_com_jroosing_hydradns_internal_config.AnomalyConfig": {
             "type": "object",
             "properties": {
-                "blacklist_size": {
-                    "type": "integer"
-                },
                 "enabled": {
                     "type": "boolean"
                 },
-                "queries_allowed": {
-                    "type": "integer"
-                },
-                "queries_blocked": {
+                "max_label_length": {
+                    "description": "MaxLabelLength flags query names with a longer label (default: 50)",
                     "type": "integer"
                 },
-                "queries_total": {
-                    "type": "integer"
+                "min_label_entropy": {
+                    "description": "MinLabelEntropy flags labels of 24 or more characters whose Shannon\nentropy in bits per character reaches this value (default: 4.0)",
+                    "type": "number"
                 },
-                "whitelist_size": {
+                "min_queries": {
+                    "description": "MinQueries is the number of queries a client must send in a window\nbefore its NXDOMAIN and TXT ratios are judged (default: 50)",
                     "type": "integer"
-                }
-            }
-        },
-        "github_com_jroosing_hydradns_internal_api_models.MemoryStats": {
-            "type": "object",
-            "properties": {
-                "free_mb": {
-                    "type": "number"
                 },
-                "total_mb": {
+                "nxdomain_ratio": {
+                    "description": "NXDOMAINRatio flags clients whose share of NXDOMAIN answers reaches\nthis value (default: 0.5)",
                     "type": "number"
                 },
-                "used_mb": {
+                "txt_ratio": {
+                    "description": "TXTRatio flags clients whose share of TXT and NULL queries reaches\nthis value (default: 0.5)",
                     "type": "number"
                 },
-                "used_percent": {
-                    "type": "number"
+                "window": {
+                    "description": "Window is the per-client period over which query ratios are measured,\nand the minimum time between two alerts of one kind for a client\n(default: \"1m\")",
+                    "type": "string"
                 }
             }
         },
-        "github_com_jroosing_hydradns_internal_api_models.ServerConfigResponse": {
+        "github_com_jroosing_hydradns_internal_config.AuditConfig": {
             "type": "object",
             "properties": {
-                "enable_tcp": {
-                    "type": "boolean"
-                },
-                "host": {
-                    "type": "string"
-                },
-                "max_concurrency": {
-                    "type": "integer"
-                },
-                "port": {
-                    "type": "integer"
-                },
-                "tcp_fallback": {
+                "enabled": {
                     "type": "boolean"
                 },
-                "upstream_socket_pool_size": {
-                    "type": "integer"
-                },
-                "workers": {
+                "path": {
+                    "description": "Path is the audit log file; required when enabled",
                     "type": "string"
                 }
             }
         },
-        "github_com_jroosing_hydradns_internal_api_models.ServerStatsResponse": {
+        "github_com_jroosing_hydradns_internal_config.BlockPageConfig": {
             "type": "object",
             "properties": {
-                "cpu": {
-                    "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.CPUStats"
-                },
-                "dns": {
-                    "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.DNSStatsResponse"
-                },
-                "filtering": {
-                    "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.FilteringStatsResponse"
-                },
-                "memory": {
-                    "$ref": "#/definitions/github_com_jroosing_hydradns_internal_api_models.MemoryStats"
+                "enabled": {
+                    "type": "boolean"
                 },
-                "start_time": {
+                "http_addr": {
+                    "description": "HTTPAddr is the HTTP listen address (default: \":80\")",
                     "type": "string"
                 },
-                "uptime": {
+                "https_addr": {
+                    "description": "HTTPSAddr is the HTTPS listen address, used only when TLSCert and\nTLSKey are set (default: \":443\")",
                     "type": "string"
                 },
-                "uptime_seconds": {
-                    "type": "integer"
-                }
-            }
-        },
-        "github_com_jroosing_hydradns_internal_api_models.SetClusterConfigResponse": {
-            "type": "object",
-            "properties": {
-                "message": {
-                    "description": "Message provides additional information about the configuration change.",
+                "ipv4": {
+                    "description": "IPv4 and IPv6 are returned for blocked A and AAAA queries and should\nreach this node. At least one is required when enabled.",
                     "type": "string"
                 },
-                "mode": {
-                    "description": "Mode is the new cluster mode.",
+                "ipv6": {
                     "type": "string"
                 },
-                "node_id": {
-                    "description": "NodeID is this node's identifier.",
+                "tls_cert": {
                     "type": "string"
                 },
-                "requires_restart": {
-                    "description": "RequiresRestart indicates if a restart is needed for changes to take effect.",
-                    "type": "boolean"
-                },
-                "status": {
-                    "description": "Status indicates the result of the operation.",
+                "tls_key": {
                     "type": "string"
                 }
             }
         },
-        "github_com_jroosing_hydradns_internal_api_models.StatusResponse": {
+        "github_com_jroosing_hydradns_internal_config.BlocklistConfig": {
             "type": "object",
             "properties": {
-                "status": {
+                "format": {
+                    "description": "\"auto\", \"adblock\", \"hosts\", \"domains\"",
                     "type": "string"
-                }
-            }
-        },
-        "github_com_jroosing_hydradns_internal_api_models.UpdateCNAMERequest": {
-            "type": "object",
-            "required": [
-                "target"
-            ],
-            "properties": {
-                "target": {
+                },
+                "name": {
+                    "type": "string"
+                },
+                "url": {
                     "type": "string"
                 }
             }
         },
-        "github_com_jroosing_hydradns_internal_api_models.UpdateHostRequest": {
+        "github_com_jroosing_hydradns_internal_config.CacheConfig": {
             "type": "object",
-            "required": [
-                "ips"
-            ],
             "properties": {
-                "ips": {
+                "aggressive_nsec": {
+                    "description": "AggressiveNSEC answers queries from NSEC records of earlier\nDNSSEC-validated negative answers (RFC 8198) instead of asking\nupstream. Requires a validating upstream resolver.",
+                    "type": "boolean"
+                },
+                "bypass_domains": {
+                    "description": "BypassDomains are never cached, e.g. dynamic DNS names or health\nchecks that must always reflect upstream. Each entry covers the name\nand everything below it.\nExample: [\"dyn.example.com\", \"health.corp.example\"]",
                     "type": "array",
-                    "minItems": 1,
                     "items": {
                         "type": "string"
                     }
-                }
-            }
-        },
-        "github_com_jroosing_hydradns_internal_cluster.ExportData": {
-            "type": "object",
-            "properties": {
-                "custom_dns": {
-                    "description": "CustomDNS contains custom DNS records (hosts and CNAMEs).",
-                    "allOf": [
-                        {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_config.CustomDNSConfig"
-                        }
-                    ]
                 },
-                "filtering": {
-                    "description": "Filtering contains domain filtering configuration.",
-                    "allOf": [
-                        {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_config.FilteringConfig"
-                        }
-                    ]
+                "disable_negative": {
+                    "description": "DisableNegative turns off caching of NXDOMAIN, NODATA, and SERVFAIL responses.",
+                    "type": "boolean"
                 },
-                "node_id": {
-                    "description": "NodeID is the primary node's identifier.",
+                "eviction_policy": {
+                    "description": "EvictionPolicy picks the entry removed when the cache is full:\n\"lru\" (least recently used, default), \"lfu\" (least frequently used),\nor \"ttl\" (closest to expiry)",
+                    "type": "string"
+                },
+                "max_bytes": {
+                    "description": "MaxBytes caps the total size of cached responses in bytes\n(default: 67108864, 64 MiB); -1 removes the cap",
+                    "type": "integer"
+                },
+                "max_entries": {
+                    "description": "MaxEntries is the maximum number of cached responses (default: 20000)",
+                    "type": "integer"
+                },
+                "max_entry_bytes": {
+                    "description": "MaxEntryBytes is the largest response that is cached, in bytes\n(default: 16384); larger responses are always forwarded. -1 removes\nthe limit",
+                    "type": "integer"
+                },
+                "negative_ttl": {
+                    "description": "NegativeTTL is the NXDOMAIN/NODATA TTL when the response has no SOA (default: \"5m\")",
                     "type": "string"
                 },
-                "timestamp": {
-                    "description": "Timestamp is when this export was generated.",
+                "servfail_ttl": {
+                    "description": "ServfailTTL is how long upstream SERVFAIL responses are cached (default: \"30s\")",
                     "type": "string"
                 },
-                "upstream": {
-                    "description": "Upstream contains upstream DNS server configuration.",
+                "shared": {
+                    "description": "Shared is an optional external cache that HydraDNS nodes behind a\nload balancer share as a second tier behind the in-memory cache.",
                     "allOf": [
                         {
-                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_config.UpstreamConfig"
+                            "$ref": "#/definitions/github_com_jroosing_hydradns_internal_config.SharedCacheConfig"
                         }
                     ]
                 },
-                "version": {
-                    "description": "Version is the configuration version from the primary node.",
-                    "type": "integer"
+                "zone_overrides": {
+                    "description": "ZoneOverrides apply different settings to names at or below a zone.\nThe most specific (longest) matching zone wins.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_jroosing_hydradns_internal_config.CacheZoneOverride"
+                    }
                 }
             }
         },
-        "github_com_jroosing_hydradns_internal_config.BlocklistConfig": {
+        "github_com_jroosing_hydradns_internal_config.CacheZoneOverride": {
             "type": "object",
             "properties": {
-                "format": {
-                    "description": "\"auto\", \"adblock\", \"hosts\", \"domains\"",
+                "disable_negative": {
+                    "type": "boolean"
+                },
+                "negative_ttl": {
                     "type": "string"
                 },
-                "name": {
+                "servfail_ttl": {
                     "type": "string"
                 },
-                "url": {
+                "zone": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_config.CanaryConfig": {
+            "type": "object",
+            "properties": {
+                "domains": {
+                    "description": "Domains are the names resolved in each round (default: example.com\nand iana.org). They must not be blocked by filtering.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "enabled": {
+                    "type": "boolean"
+                },
+                "failure_threshold": {
+                    "description": "FailureThreshold is the number of failed rounds in a row after which\nDNS is reported down (default: 3)",
+                    "type": "integer"
+                },
+                "interval": {
+                    "description": "Interval is the time between rounds (default: \"30s\")",
+                    "type": "string"
+                },
+                "timeout": {
+                    "description": "Timeout bounds each canary query (default: \"5s\")",
                     "type": "string"
                 }
             }
         },
+        "github_com_jroosing_hydradns_internal_config.ClusterMode": {
+            "type": "string",
+            "enum": [
+                "standalone",
+                "primary",
+                "secondary"
+            ],
+            "x-enum-varnames": [
+                "ClusterModeStandalone",
+                "ClusterModePrimary",
+                "ClusterModeSecondary"
+            ]
+        },
         "github_com_jroosing_hydradns_internal_config.CustomDNSConfig": {
             "type": "object",
             "properties": {
+                "answer_order": {
+                    "description": "AnswerOrder orders the addresses of names with several A or AAAA\nrecords in each response: fixed (default, configured order),\nround_robin (rotated by one per query), or random. Per node.",
+                    "type": "string"
+                },
                 "cnames": {
                     "description": "CNAMEs maps alias names to canonical names\nExample: \"www.homelab.local\": \"homelab.local\"",
                     "type": "object",
@@ -1769,6 +5822,13 @@ const docTemplate = `{
                         "type": "string"
                     }
                 },
+                "health_checks": {
+                    "description": "HealthChecks probe the addresses of custom hosts. Addresses that fail\nare left out of answers until they pass again. Per node.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_jroosing_hydradns_internal_config.RecordHealthCheck"
+                    }
+                },
                 "hosts": {
                     "description": "Hosts maps domain names to IP addresses (IPv4 or IPv6)\nExample: \"homelab.local\": \"192.168.1.10\" or \"server.local\": \"2001:db8::1\"",
                     "type": "object",
@@ -1778,6 +5838,37 @@ const docTemplate = `{
                             "type": "string"
                         }
                     }
+                },
+                "search_domains": {
+                    "description": "SearchDomains are appended, in order, to single-label queries that\nmatch no custom record, so \"nas\" answers as \"nas.home.arpa\" for\nclients without working search settings. Search domains are per node.\nExample: [\"home.arpa\", \"lan\"]",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "steering": {
+                    "description": "Steering selects which addresses of custom hosts are answered, by\nclient subnet and weight, e.g. to steer traffic between two sites.\nPer node.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_jroosing_hydradns_internal_config.HostSteering"
+                    }
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_config.EDNSOptionRule": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "description": "Action is \"pass\", \"strip\", or \"rewrite\".",
+                    "type": "string"
+                },
+                "option": {
+                    "description": "Option is \"ecs\", \"cookie\", \"padding\", \"ede\", or a numeric option code.",
+                    "type": "string"
+                },
+                "value": {
+                    "description": "Value is the rewritten option data: a CIDR prefix for ecs\n(e.g. \"192.0.2.0/24\"), hex-encoded bytes for other options.",
+                    "type": "string"
                 }
             }
         },
@@ -1790,6 +5881,10 @@ const docTemplate = `{
                         "type": "string"
                     }
                 },
+                "block_ttl": {
+                    "description": "BlockTTL is the TTL in seconds of block page answers, and\nNegativeTTL the negative caching TTL of blocked NXDOMAIN and NODATA\nanswers (default: 10 each). Short TTLs keep clients from caching a\nblock for long after the domain is unblocked.",
+                    "type": "integer"
+                },
                 "blocklists": {
                     "type": "array",
                     "items": {
@@ -1805,9 +5900,19 @@ const docTemplate = `{
                 "log_blocked": {
                     "type": "boolean"
                 },
+                "negative_ttl": {
+                    "type": "integer"
+                },
                 "refresh_interval": {
                     "type": "string"
                 },
+                "temporary_allows": {
+                    "description": "TemporaryAllows maps domains allowed for a limited time, but not\ntheir subdomains, to when the allow expires. Set through\nPOST /api/v1/filtering/allow-temporarily.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
                 "whitelist_domains": {
                     "type": "array",
                     "items": {
@@ -1816,6 +5921,85 @@ const docTemplate = `{
                 }
             }
         },
+        "github_com_jroosing_hydradns_internal_config.GeoIPConfig": {
+            "type": "object",
+            "properties": {
+                "asn_db": {
+                    "description": "ASNDB is the path of an ASN .mmdb database",
+                    "type": "string"
+                },
+                "block_asns": {
+                    "description": "BlockASNs blocks answers containing an address in these autonomous\nsystems (requires ASNDB)",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "block_countries": {
+                    "description": "BlockCountries blocks answers containing an address in these\ncountries, as ISO 3166-1 alpha-2 codes (requires CountryDB)",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "country_db": {
+                    "description": "CountryDB is the path of a Country (or City) .mmdb database",
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_config.HostSteering": {
+            "type": "object",
+            "properties": {
+                "addrs": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_jroosing_hydradns_internal_config.SteeredAddr"
+                    }
+                },
+                "name": {
+                    "description": "Name is the custom host being steered.",
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_config.IdentityConfig": {
+            "type": "object",
+            "properties": {
+                "hostname": {
+                    "description": "Hostname answers hostname.bind",
+                    "type": "string"
+                },
+                "server_id": {
+                    "description": "ServerID answers id.server (RFC 4892); defaults to Hostname",
+                    "type": "string"
+                },
+                "version": {
+                    "description": "Version answers version.bind and version.server",
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_config.ListenerProfile": {
+            "type": "object",
+            "properties": {
+                "host": {
+                    "description": "Host is the bind address (default: server.host)",
+                    "type": "string"
+                },
+                "name": {
+                    "description": "Name identifies the profile in logs and stats",
+                    "type": "string"
+                },
+                "port": {
+                    "type": "integer"
+                },
+                "unfiltered": {
+                    "description": "Unfiltered answers queries on this listener without the filtering\npolicy",
+                    "type": "boolean"
+                }
+            }
+        },
         "github_com_jroosing_hydradns_internal_config.LoggingConfig": {
             "type": "object",
             "properties": {
@@ -1831,6 +6015,14 @@ const docTemplate = `{
                 "level": {
                     "type": "string"
                 },
+                "slow_query_max_per_minute": {
+                    "description": "SlowQueryMaxPerMinute caps the slow queries logged per minute; the\nnumber left out is logged with the next one (default: 60)",
+                    "type": "integer"
+                },
+                "slow_query_threshold": {
+                    "description": "SlowQueryThreshold logs queries answered slower than this end to\nend, or whose upstream exchange took longer, whatever the log level\n(default: \"0s\", disabled)",
+                    "type": "string"
+                },
                 "structured": {
                     "type": "boolean"
                 },
@@ -1839,6 +6031,45 @@ const docTemplate = `{
                 }
             }
         },
+        "github_com_jroosing_hydradns_internal_config.NRDConfig": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "description": "Action is \"flag\" to log new domains (default) or \"block\" to block them",
+                    "type": "string"
+                },
+                "days": {
+                    "description": "Days is how long a domain counts as new after it was first seen\n(default: 30)",
+                    "type": "integer"
+                },
+                "enabled": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_config.QTypeRule": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "description": "Action is the response: \"refused\" (default), \"nxdomain\", \"nodata\"\n(NOERROR without answers), or \"drop\" (no response)",
+                    "type": "string"
+                },
+                "clients": {
+                    "description": "Clients limits the rule to these IP addresses or CIDR prefixes\n(default: none, meaning all clients)",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "qtypes": {
+                    "description": "QTypes are type mnemonics (\"ANY\", \"TXT\") or numbers (\"TYPE65535\")",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
         "github_com_jroosing_hydradns_internal_config.RateLimitConfig": {
             "type": "object",
             "properties": {
@@ -1846,6 +6077,10 @@ const docTemplate = `{
                     "description": "CleanupSeconds is how often stale entries are cleaned up (default: 60)",
                     "type": "number"
                 },
+                "exempt_local": {
+                    "description": "ExemptLocal exempts link-local and unique local (fc00::/7) clients\nfrom the prefix and per-IP limits (default: false)",
+                    "type": "boolean"
+                },
                 "global_burst": {
                     "description": "GlobalBurst is the global burst size (default: 100000)",
                     "type": "integer"
@@ -1862,6 +6097,14 @@ const docTemplate = `{
                     "description": "IPQPS is the per-IP QPS limit (default: 3000, 0 = disabled)",
                     "type": "number"
                 },
+                "ipv4_prefix_len": {
+                    "description": "IPv4PrefixLen is the IPv4 network size the prefix limit groups\nclients by (default: 24)",
+                    "type": "integer"
+                },
+                "ipv6_prefix_len": {
+                    "description": "IPv6PrefixLen is the IPv6 network size the prefix limit groups\nclients by (default: 64)",
+                    "type": "integer"
+                },
                 "max_ip_entries": {
                     "description": "MaxIPEntries is the maximum number of tracked IPs (default: 65536)",
                     "type": "integer"
@@ -1877,12 +6120,141 @@ const docTemplate = `{
                 "prefix_qps": {
                     "description": "PrefixQPS is the per-prefix QPS limit (default: 10000, 0 = disabled)",
                     "type": "number"
+                },
+                "slip": {
+                    "description": "Slip answers every Nth UDP query refused by the prefix or IP limit with\nan empty truncated (TC=1) response instead of dropping it, so real\nclients behind a spoofed source retry over TCP (default: 0 = always\ndrop, 1 = always truncate)",
+                    "type": "integer"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_config.RecordHealthCheck": {
+            "type": "object",
+            "properties": {
+                "failures": {
+                    "description": "Failures is the number of failed probes in a row after which an\naddress is left out (default: 2). One passing probe brings it back.",
+                    "type": "integer"
+                },
+                "interval": {
+                    "description": "Interval is the time between probes of an address (default: \"10s\").",
+                    "type": "string"
+                },
+                "name": {
+                    "description": "Name is the custom host whose addresses are probed.",
+                    "type": "string"
+                },
+                "path": {
+                    "description": "Path is the HTTP request path (default: \"/\").",
+                    "type": "string"
+                },
+                "port": {
+                    "type": "integer"
+                },
+                "timeout": {
+                    "description": "Timeout bounds each probe (default: \"2s\").",
+                    "type": "string"
+                },
+                "type": {
+                    "description": "Type is \"tcp\" or \"http\".",
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_config.ShadowConfig": {
+            "type": "object",
+            "properties": {
+                "enabled": {
+                    "type": "boolean"
+                },
+                "percent": {
+                    "description": "Percent is the share of upstream-answered queries mirrored, above 0\nand at most 100 (default: 10)",
+                    "type": "number"
+                },
+                "servers": {
+                    "description": "Servers are the shadow upstream IP addresses, queried on port 53 in\nfailover order; required when enabled",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "timeout": {
+                    "description": "Timeout bounds each shadow query (default: \"2s\")",
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_config.SharedCacheConfig": {
+            "type": "object",
+            "properties": {
+                "address": {
+                    "description": "Address is the server's host:port, e.g. \"10.0.0.5:6379\"",
+                    "type": "string"
+                },
+                "backend": {
+                    "description": "Backend is \"redis\" or \"memcached\"; empty disables the shared cache",
+                    "type": "string"
+                },
+                "key_prefix": {
+                    "description": "KeyPrefix starts every key, separating HydraDNS entries from other\nusers of the server (default: \"hydradns\")",
+                    "type": "string"
+                },
+                "password": {
+                    "description": "Password authenticates to redis (AUTH). Not supported by memcached.",
+                    "type": "string"
+                },
+                "timeout": {
+                    "description": "Timeout bounds each lookup or store; a slow or unreachable server\nthen costs at most this much per cache miss (default: \"50ms\")",
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_config.SteeredAddr": {
+            "type": "object",
+            "properties": {
+                "addr": {
+                    "type": "string"
+                },
+                "clients": {
+                    "description": "Clients are the IP addresses or CIDR prefixes this address is\nanswered to. Empty means clients matching no other address.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "weight": {
+                    "description": "Weight is the address's share of answers. Addresses without a weight\nare only answered when no matched address has one.",
+                    "type": "integer"
+                }
+            }
+        },
+        "github_com_jroosing_hydradns_internal_config.TransportRule": {
+            "type": "object",
+            "properties": {
+                "domain": {
+                    "type": "string"
+                },
+                "transport": {
+                    "description": "Transport is \"tcp\" or \"doh\". Queries never fall back to plain UDP.",
+                    "type": "string"
                 }
             }
         },
         "github_com_jroosing_hydradns_internal_config.UpstreamConfig": {
             "type": "object",
             "properties": {
+                "doh_servers": {
+                    "description": "DoHServers are DNS-over-HTTPS endpoints (RFC 8484), e.g.\n\"https://1.1.1.1/dns-query\", tried in order for names a transport\nrule sends over DoH.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "edns_options": {
+                    "description": "EDNSOptions controls which EDNS options are forwarded upstream.\nOptions without a rule pass through unchanged.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_jroosing_hydradns_internal_config.EDNSOptionRule"
+                    }
+                },
                 "max_retries": {
                     "description": "Max retries per upstream on timeout",
                     "type": "integer"
@@ -1893,10 +6265,25 @@ const docTemplate = `{
                         "type": "string"
                     }
                 },
+                "stage_timeout": {
+                    "description": "StageTimeout bounds the upstream stage of the resolver chain (e.g.\n\"4s\"), across all retries and servers. Empty: only the query's own\ndeadline applies.",
+                    "type": "string"
+                },
+                "strategy": {
+                    "description": "Strategy picks the order upstreams are tried in: sequential (default),\nround_robin, latency, or random.",
+                    "type": "string"
+                },
                 "tcp_timeout": {
                     "description": "Timeout for TCP queries (e.g., \"5s\")",
                     "type": "string"
                 },
+                "transport_rules": {
+                    "description": "TransportRules force the transport used for names at or below a\ndomain, whatever the servers and strategy above.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_jroosing_hydradns_internal_config.TransportRule"
+                    }
+                },
                 "udp_timeout": {
                     "description": "Timeout for UDP queries (e.g., \"3s\")",
                     "type": "string"
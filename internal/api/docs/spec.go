@@ -0,0 +1,17 @@
+package docs
+
+import _ "embed"
+
+// The generated spec files are embedded so the API server can serve them
+// as-is, for tooling that wants the document rather than the Swagger UI.
+// Regenerate them with `make docs`.
+
+// SpecJSON is the OpenAPI (Swagger 2.0) document in JSON.
+//
+//go:embed swagger.json
+var SpecJSON []byte
+
+// SpecYAML is the OpenAPI (Swagger 2.0) document in YAML.
+//
+//go:embed swagger.yaml
+var SpecYAML []byte
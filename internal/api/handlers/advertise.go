@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jroosing/hydradns/internal/api/models"
+)
+
+// GetAdvertise godoc
+// @Summary DNS advertisement and client coverage
+// @Description Reports the DNS addresses advertised to the router or DHCP server and whether the webhook accepted
+// @Description them, and the client subnets that queried from outside the configured client subnets.
+// @Tags system
+// @Produce json
+// @Success 200 {object} models.AdvertiseResponse
+// @Security ApiKeyAuth
+// @Router /advertise [get]
+func (h *Handler) GetAdvertise(c *gin.Context) {
+	resp := models.AdvertiseResponse{
+		Interfaces: []models.AdvertiseInterface{},
+		Coverage: models.ClientCoverage{
+			ClientSubnets: []string{},
+			Uncovered:     []models.UncoveredSubnet{},
+		},
+	}
+
+	var adv *AdvertiseSnapshot
+	if fn := h.GetAdvertiseFunc(); fn != nil {
+		adv = fn()
+	}
+	if adv != nil {
+		resp.Enabled = true
+		resp.Port = adv.Port
+		resp.Changed = optionalTime(adv.Changed)
+		resp.Delivered = adv.Delivered
+		resp.LastAttempt = optionalTime(adv.LastAttempt)
+		resp.LastSuccess = optionalTime(adv.LastSuccess)
+		resp.LastError = adv.LastError
+		for _, iface := range adv.Interfaces {
+			resp.Interfaces = append(resp.Interfaces, models.AdvertiseInterface{
+				Name: iface.Name,
+				IPv4: nonNilStrings(iface.IPv4),
+				IPv6: nonNilStrings(iface.IPv6),
+			})
+		}
+	}
+
+	var cov *CoverageSnapshot
+	if fn := h.GetCoverageFunc(); fn != nil {
+		cov = fn()
+	}
+	if cov != nil {
+		resp.Coverage.Enabled = true
+		resp.Coverage.ClientSubnets = nonNilStrings(cov.ClientSubnets)
+		resp.Coverage.Untracked = cov.Untracked
+		for _, u := range cov.Uncovered {
+			resp.Coverage.Uncovered = append(resp.Coverage.Uncovered, models.UncoveredSubnet(u))
+		}
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// nonNilStrings returns s, or an empty slice for nil so it is encoded as
+// [] rather than null.
+func nonNilStrings(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jroosing/hydradns/internal/api/models"
+)
+
+// ListAnomalies godoc
+// @Summary Recent anomaly alerts
+// @Description Lists the latest alerts (up to 100, newest first) for clients showing DNS tunneling indicators: high NXDOMAIN or TXT/NULL query ratios, or long or high-entropy labels. Empty when anomaly detection is disabled.
+// @Tags system
+// @Produce json
+// @Success 200 {object} models.AnomaliesResponse
+// @Security ApiKeyAuth
+// @Router /anomalies [get]
+func (h *Handler) ListAnomalies(c *gin.Context) {
+	resp := models.AnomaliesResponse{Anomalies: []models.Anomaly{}}
+
+	if fn := h.GetAnomaliesFunc(); fn != nil {
+		for _, a := range fn() {
+			resp.Anomalies = append(resp.Anomalies, models.Anomaly{
+				Time:   a.Time,
+				Client: a.Client,
+				Kind:   a.Kind,
+				Name:   a.Name,
+				Detail: a.Detail,
+			})
+		}
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
@@ -4,26 +4,59 @@
 //
 // System Health:
 //   - GET /api/v1/health - Health check status
+//   - GET /api/v1/health/deep - Component health and DNS resolution health from the health canary
 //   - GET /api/v1/stats - Server statistics (uptime, memory, goroutines, filtering stats)
+//   - GET /api/v1/events - Live query events and statistics (Server-Sent Events)
 //   - GET /api/v1/config - Current configuration (sensitive values redacted)
 //
-// Zones (Authoritative DNS):
-//   - GET /api/v1/zones - List all loaded zones
-//   - GET /api/v1/zones/:name - Get zone details with all records
+// Setup:
+//   - GET /api/v1/setup - First-run setup status (no API key required)
+//   - POST /api/v1/setup - Complete first-run setup
+//
+// Upstreams:
+//   - GET /api/v1/upstreams - List upstream servers in failover order
+//   - PUT /api/v1/upstreams - Replace/reorder upstream servers at runtime
+//   - GET /api/v1/upstreams/status - Per-upstream RTT percentiles and availability
+//   - GET /api/v1/upstreams/compare - One query sent to every upstream, answers side by side
 //
 // Filtering (Domain Filtering):
 //   - GET /api/v1/filtering/stats - Filtering statistics (queries blocked/allowed)
 //   - PUT /api/v1/filtering/enabled - Enable/disable filtering at runtime
+//   - GET /api/v1/filtering/ttl - TTLs of block page and blocked negative answers
+//   - PUT /api/v1/filtering/ttl - Change those TTLs at runtime
 //   - GET /api/v1/filtering/whitelist - List whitelisted domains
 //   - POST /api/v1/filtering/whitelist - Add domains to whitelist
 //   - GET /api/v1/filtering/blacklist - List blacklisted domains
 //   - POST /api/v1/filtering/blacklist - Add domains to blacklist
+//   - GET /api/v1/filtering/rpz - Effective policy as an RPZ zone file
+//   - POST /api/v1/filtering/allow-temporarily - Allow a blocked domain for a limited time
+//
+// Privacy (admin key only):
+//   - GET /api/v1/privacy - What query logs keep about clients and names
+//   - PUT /api/v1/privacy - Change it, redacting recorded alerts when raised
+//
+// Logging (admin key only):
+//   - GET /api/v1/logging - Current log level and format
+//   - PUT /api/v1/logging - Change them at runtime, or the level for a limited time
+//
+// Packet Capture (admin key only):
+//   - POST /api/v1/capture - Record the next N DNS messages matching a name/client filter
+//   - GET /api/v1/capture - Status of the latest capture
+//   - DELETE /api/v1/capture - Stop the running capture
+//   - GET /api/v1/capture/pcap - Download the latest capture as a pcap file
+//
+// API Tokens (admin key only):
+//   - GET /api/v1/tokens - List scoped API tokens
+//   - POST /api/v1/tokens - Create a scoped API token
+//   - DELETE /api/v1/tokens/{name} - Revoke an API token
 //
 // Authentication:
 //
 // All endpoints except /health support optional API key authentication via
 // the X-API-Key header. If configured, the API key is required for all
 // endpoints except /health and /config (which may be public depending on policy).
+// Scoped API tokens are accepted in the same header for the sections they
+// were granted (see middleware.RequireAuth).
 //
 // Security Considerations:
 //
@@ -52,14 +85,18 @@
 package handlers
 
 import (
+	"context"
 	"log/slog"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/jroosing/hydradns/internal/capture"
 	"github.com/jroosing/hydradns/internal/cluster"
 	"github.com/jroosing/hydradns/internal/config"
 	"github.com/jroosing/hydradns/internal/database"
 	"github.com/jroosing/hydradns/internal/filtering"
+	"github.com/jroosing/hydradns/internal/logging"
 )
 
 // DNSStatsSnapshot contains a point-in-time snapshot of DNS statistics.
@@ -69,24 +106,300 @@ type DNSStatsSnapshot struct {
 	QueriesTCP   uint64
 	ResponsesNX  uint64
 	ResponsesErr uint64
+	Panics       uint64
 	AvgLatencyMs float64
+	UDPListeners []UDPListenerSnapshot
+	Parse        map[string]ParseStatsSnapshot // Keyed by transport ("udp", "tcp")
+	TCPConnsByIP map[string]int                // Active TCP connections per client IP
+	Anomalies    map[string]uint64             // Anomaly alerts raised per kind; nil when detection is off
+	QTypeRules   map[string]uint64             // Queries answered per qtype rule; nil without rules
+	Cache        *CacheStatsSnapshot           // Response cache counters; nil when the server is not running
+}
+
+// CacheStatsSnapshot contains a point-in-time snapshot of the response cache.
+type CacheStatsSnapshot struct {
+	EvictionPolicy string
+	Entries        int
+	MaxEntries     int
+	Bytes          int
+	MaxBytes       int
+	Oversized      uint64
+	Hits           uint64
+	Misses         uint64
+	NegativeHits   uint64
+	Evictions      uint64
+	Expirations    uint64
+	HitRatio       float64
+	Shared         *SharedCacheSnapshot // nil without a shared cache
+}
+
+// SharedCacheSnapshot contains a point-in-time snapshot of the shared cache tier.
+type SharedCacheSnapshot struct {
+	Backend string
+	Hits    uint64
+	Misses  uint64
+	Errors  uint64
+	Stores  uint64
+	Dropped uint64
+}
+
+// ParseStatsSnapshot contains counters for requests rejected by the DNS parser.
+type ParseStatsSnapshot struct {
+	Malformed         uint64
+	PointerLoops      uint64
+	OversizedNames    uint64
+	OversizedMessages uint64
+}
+
+// UDPListenerSnapshot contains a point-in-time snapshot of one UDP listener socket.
+type UDPListenerSnapshot struct {
+	Listener       int
+	PacketsRead    uint64
+	PacketsHandled uint64
+	PacketsDropped uint64
+	RateLimited    uint64
+	Slipped        uint64
+	Duplicates     uint64
+	QueueDepth     int
+	QueueCapacity  int
+	KernelDrops    uint64
+	RecvQueueBytes int
+	RecvBuffer     int
+	SendBuffer     int
 }
 
 // DNSStatsFunc is a function that returns DNS statistics.
 type DNSStatsFunc func() DNSStatsSnapshot
 
+// QueryEventSnapshot describes one answered query.
+type QueryEventSnapshot struct {
+	Time      time.Time
+	Transport string
+	Client    string
+	Name      string
+	Type      string
+	RCode     string
+	Source    string
+	Duration  time.Duration
+	TraceID   string
+}
+
+// QueryEventsFunc subscribes to live query events. The returned channel is
+// closed after ctx is done.
+type QueryEventsFunc func(ctx context.Context) <-chan QueryEventSnapshot
+
+// UpstreamStatusSnapshot contains a point-in-time snapshot of one upstream's health.
+type UpstreamStatusSnapshot struct {
+	Address             string
+	Healthy             bool
+	Queries             uint64
+	Successes           uint64
+	Failures            uint64
+	SuccessRate         float64
+	ConsecutiveFailures uint64
+	RTTP50Ms            float64
+	RTTP95Ms            float64
+	LastSuccess         time.Time
+	LastFailure         time.Time
+	LastError           string
+	MismatchedResponses uint64
+	Country             string // GeoIP country code; empty without GeoIP
+	ASN                 uint32 // GeoIP autonomous system number; 0 without GeoIP
+	ASOrg               string
+}
+
+// UpstreamStatusFunc is a function that returns per-upstream statistics.
+type UpstreamStatusFunc func() []UpstreamStatusSnapshot
+
+// CanarySnapshot contains the health canary's latest results.
+type CanarySnapshot struct {
+	Healthy      bool
+	LastRound    time.Time
+	FailedRounds int
+	Checks       []CanaryCheckSnapshot
+}
+
+// CanaryCheckSnapshot is the latest result for one canary domain.
+type CanaryCheckSnapshot struct {
+	Domain              string
+	OK                  bool
+	RCode               string
+	Latency             time.Duration
+	Error               string
+	CheckedAt           time.Time
+	LastSuccess         time.Time
+	ConsecutiveFailures int
+}
+
+// CanaryFunc is a function that returns the health canary's latest results,
+// or nil when the canary is disabled.
+type CanaryFunc func() *CanarySnapshot
+
+// ShadowAnswerSnapshot is one side of a query compared with the shadow
+// upstreams.
+type ShadowAnswerSnapshot struct {
+	Upstream string
+	RCode    string
+	Answers  []string
+	Latency  time.Duration
+}
+
+// ShadowDivergenceSnapshot is a query the shadow upstreams answered
+// differently.
+type ShadowDivergenceSnapshot struct {
+	Time    time.Time
+	Name    string
+	Type    string
+	TraceID string
+	Primary ShadowAnswerSnapshot
+	Shadow  ShadowAnswerSnapshot
+}
+
+// ShadowSnapshot summarizes how the shadow upstreams compare with the
+// primary ones.
+type ShadowSnapshot struct {
+	Servers        []string
+	Percent        float64
+	Mirrored       uint64
+	Matched        uint64
+	Diverged       uint64
+	Failed         uint64
+	Skipped        uint64
+	PrimaryLatency time.Duration
+	ShadowLatency  time.Duration
+	Recent         []ShadowDivergenceSnapshot
+}
+
+// ShadowFunc is a function that returns the shadow upstream comparison, or
+// nil when shadowing is disabled.
+type ShadowFunc func() *ShadowSnapshot
+
+// AdvertiseInterfaceSnapshot lists the DNS addresses advertised on one
+// network interface.
+type AdvertiseInterfaceSnapshot struct {
+	Name string
+	IPv4 []string
+	IPv6 []string
+}
+
+// AdvertiseSnapshot reports the advertised DNS addresses and the last
+// webhook delivery.
+type AdvertiseSnapshot struct {
+	Port        int
+	Interfaces  []AdvertiseInterfaceSnapshot
+	Changed     time.Time
+	Delivered   bool
+	LastAttempt time.Time
+	LastSuccess time.Time
+	LastError   string
+}
+
+// AdvertiseFunc is a function that returns the DNS advertisement state, or
+// nil when advertisement is disabled.
+type AdvertiseFunc func() *AdvertiseSnapshot
+
+// UncoveredSubnetSnapshot is a client network outside the configured
+// client subnets.
+type UncoveredSubnetSnapshot struct {
+	Subnet    string
+	Queries   uint64
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+// CoverageSnapshot reports the clients that queried from outside the
+// configured client subnets.
+type CoverageSnapshot struct {
+	ClientSubnets []string
+	Uncovered     []UncoveredSubnetSnapshot
+	Untracked     uint64
+}
+
+// CoverageFunc is a function that returns the client subnet coverage, or
+// nil when no client subnets are configured.
+type CoverageFunc func() *CoverageSnapshot
+
+// ComponentSnapshot is the state of one server component.
+type ComponentSnapshot struct {
+	Name     string
+	Required bool
+	State    string
+	Error    string
+	Since    time.Time
+}
+
+// ComponentsFunc is a function that returns the state of every server
+// component, in start order.
+type ComponentsFunc func() []ComponentSnapshot
+
+// ReadinessFunc is a function that returns why the server should not
+// receive traffic yet, or nil when it is ready.
+type ReadinessFunc func() []string
+
+// AnomalySnapshot describes one anomaly alert raised for a client.
+type AnomalySnapshot struct {
+	Time   time.Time
+	Client string
+	Kind   string
+	Name   string
+	Detail string
+}
+
+// AnomaliesFunc is a function that returns recent anomaly alerts, newest first.
+type AnomaliesFunc func() []AnomalySnapshot
+
+// PrivacyFunc applies a query log privacy configuration to the running
+// server.
+type PrivacyFunc func(config.PrivacyConfig)
+
+// RecordHealthSnapshot is the health of one address of a custom DNS host
+// with a health check.
+type RecordHealthSnapshot struct {
+	Name                string
+	Addr                string
+	Check               string
+	Healthy             bool
+	ConsecutiveFailures int
+	Error               string
+	CheckedAt           time.Time
+	ChangedAt           time.Time
+}
+
+// RecordHealthFunc is a function that returns the health of every checked
+// custom DNS address.
+type RecordHealthFunc func() []RecordHealthSnapshot
+
 // Handler contains dependencies for API handlers.
 type Handler struct {
 	cfg       *config.Config
 	db        *database.DB
 	logger    *slog.Logger
 	startTime time.Time
+	apiPanics atomic.Uint64 // Panics recovered in API handlers
 
 	// Runtime components (set after server starts)
 	policyEngine        *filtering.PolicyEngine
-	customDNSReloadFunc func() error    // Callback to reload custom DNS resolver
-	dnsStatsFunc        DNSStatsFunc    // Function to get DNS query statistics
-	clusterSyncer       *cluster.Syncer // Cluster syncer for secondary mode
+	customDNSReloadFunc func() error        // Callback to reload custom DNS resolver
+	upstreamReloadFunc  func() error        // Callback to apply upstream server changes
+	cacheReloadFunc     func() error        // Callback to apply cache zone override changes
+	dnsStatsFunc        DNSStatsFunc        // Function to get DNS query statistics
+	upstreamStatusFunc  UpstreamStatusFunc  // Function to get per-upstream statistics
+	queryEventsFunc     QueryEventsFunc     // Function to subscribe to live query events
+	anomaliesFunc       AnomaliesFunc       // Function to get recent anomaly alerts
+	recordHealthFunc    RecordHealthFunc    // Function to get custom DNS address health
+	canaryFunc          CanaryFunc          // Function to get health canary results
+	shadowFunc          ShadowFunc          // Function to get the shadow upstream comparison
+	advertiseFunc       AdvertiseFunc       // Function to get the DNS advertisement state
+	coverageFunc        CoverageFunc        // Function to get the client subnet coverage
+	componentsFunc      ComponentsFunc      // Function to get server component states
+	readinessFunc       ReadinessFunc       // Function to get why the server is not ready
+	cachePurgeFunc      func(string) int    // Callback to drop cached responses for a name
+	privacyFunc         PrivacyFunc         // Callback to apply query log privacy changes
+	clusterSyncer       *cluster.Syncer     // Cluster syncer for secondary mode
+	clusterJournal      *cluster.Journal    // Changes served to secondaries
+	clusterMembers      *cluster.Members    // Cluster members for discovery
+	packetCapture       *capture.Recorder   // Packet captures started from the API
+	logController       *logging.Controller // Log level and format changed from the API
 	mu                  sync.RWMutex
 }
 
@@ -97,9 +410,16 @@ func New(cfg *config.Config, db *database.DB, logger *slog.Logger) *Handler {
 		db:        db,
 		logger:    logger,
 		startTime: time.Now(),
+
+		clusterJournal: cluster.NewJournal(0),
 	}
 }
 
+// RecordPanic counts a panic recovered in an API handler.
+func (h *Handler) RecordPanic() {
+	h.apiPanics.Add(1)
+}
+
 // DB returns the database connection for handlers that need it.
 func (h *Handler) DB() *database.DB {
 	return h.db
@@ -127,6 +447,30 @@ func (h *Handler) SetCustomDNSReloadFunc(reloadFunc func() error) {
 	h.customDNSReloadFunc = reloadFunc
 }
 
+// SetCustomDNS replaces the custom DNS records served by the API, e.g. after a
+// secondary imported configuration from the cluster primary.
+func (h *Handler) SetCustomDNS(customDNS config.CustomDNSConfig) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.cfg.CustomDNS = customDNS
+}
+
+// SetUpstreamReloadFunc sets the callback function for applying upstream changes.
+// This enables the API to hot-swap upstream servers without a restart.
+func (h *Handler) SetUpstreamReloadFunc(reloadFunc func() error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.upstreamReloadFunc = reloadFunc
+}
+
+// SetCacheReloadFunc sets the callback function for applying cache zone
+// override changes to the running resolver.
+func (h *Handler) SetCacheReloadFunc(reloadFunc func() error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.cacheReloadFunc = reloadFunc
+}
+
 // SetDNSStatsFunc sets the function to retrieve DNS statistics.
 func (h *Handler) SetDNSStatsFunc(fn DNSStatsFunc) {
 	h.mu.Lock()
@@ -141,6 +485,171 @@ func (h *Handler) GetDNSStatsFunc() DNSStatsFunc {
 	return h.dnsStatsFunc
 }
 
+// SetUpstreamStatusFunc sets the function to retrieve per-upstream statistics.
+func (h *Handler) SetUpstreamStatusFunc(fn UpstreamStatusFunc) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.upstreamStatusFunc = fn
+}
+
+// GetUpstreamStatusFunc retrieves the per-upstream statistics function.
+func (h *Handler) GetUpstreamStatusFunc() UpstreamStatusFunc {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.upstreamStatusFunc
+}
+
+// SetQueryEventsFunc sets the function to subscribe to live query events.
+func (h *Handler) SetQueryEventsFunc(fn QueryEventsFunc) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.queryEventsFunc = fn
+}
+
+// GetQueryEventsFunc retrieves the live query events function.
+func (h *Handler) GetQueryEventsFunc() QueryEventsFunc {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.queryEventsFunc
+}
+
+// SetAnomaliesFunc sets the function to retrieve recent anomaly alerts.
+func (h *Handler) SetAnomaliesFunc(fn AnomaliesFunc) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.anomaliesFunc = fn
+}
+
+// SetPrivacyFunc sets the callback applying query log privacy changes.
+func (h *Handler) SetPrivacyFunc(fn PrivacyFunc) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.privacyFunc = fn
+}
+
+// GetAnomaliesFunc retrieves the recent anomaly alerts function.
+func (h *Handler) GetAnomaliesFunc() AnomaliesFunc {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.anomaliesFunc
+}
+
+// SetRecordHealthFunc sets the function to retrieve custom DNS address
+// health.
+func (h *Handler) SetRecordHealthFunc(fn RecordHealthFunc) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.recordHealthFunc = fn
+}
+
+// GetRecordHealthFunc retrieves the custom DNS address health function.
+func (h *Handler) GetRecordHealthFunc() RecordHealthFunc {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.recordHealthFunc
+}
+
+// SetCanaryFunc sets the function to retrieve health canary results.
+func (h *Handler) SetCanaryFunc(fn CanaryFunc) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.canaryFunc = fn
+}
+
+// GetCanaryFunc retrieves the health canary results function.
+func (h *Handler) GetCanaryFunc() CanaryFunc {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.canaryFunc
+}
+
+// SetShadowFunc sets the function to retrieve the shadow upstream
+// comparison.
+func (h *Handler) SetShadowFunc(fn ShadowFunc) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.shadowFunc = fn
+}
+
+// GetShadowFunc retrieves the shadow upstream comparison function.
+func (h *Handler) GetShadowFunc() ShadowFunc {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.shadowFunc
+}
+
+// SetAdvertiseFunc sets the function to retrieve the DNS advertisement
+// state.
+func (h *Handler) SetAdvertiseFunc(fn AdvertiseFunc) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.advertiseFunc = fn
+}
+
+// GetAdvertiseFunc retrieves the DNS advertisement state function.
+func (h *Handler) GetAdvertiseFunc() AdvertiseFunc {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.advertiseFunc
+}
+
+// SetCoverageFunc sets the function to retrieve the client subnet coverage.
+func (h *Handler) SetCoverageFunc(fn CoverageFunc) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.coverageFunc = fn
+}
+
+// GetCoverageFunc retrieves the client subnet coverage function.
+func (h *Handler) GetCoverageFunc() CoverageFunc {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.coverageFunc
+}
+
+// SetComponentsFunc sets the function to retrieve server component states.
+func (h *Handler) SetComponentsFunc(fn ComponentsFunc) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.componentsFunc = fn
+}
+
+// GetComponentsFunc retrieves the server component states function.
+func (h *Handler) GetComponentsFunc() ComponentsFunc {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.componentsFunc
+}
+
+// SetReadinessFunc sets the function to retrieve server readiness.
+func (h *Handler) SetReadinessFunc(fn ReadinessFunc) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.readinessFunc = fn
+}
+
+// GetReadinessFunc retrieves the server readiness function.
+func (h *Handler) GetReadinessFunc() ReadinessFunc {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.readinessFunc
+}
+
+// SetCachePurgeFunc sets the callback that drops cached DNS responses for a
+// name, returning the number of entries removed.
+func (h *Handler) SetCachePurgeFunc(fn func(string) int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.cachePurgeFunc = fn
+}
+
+// GetCachePurgeFunc retrieves the cache purge callback.
+func (h *Handler) GetCachePurgeFunc() func(string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.cachePurgeFunc
+}
+
 // SetClusterSyncer sets the cluster syncer for secondary mode.
 func (h *Handler) SetClusterSyncer(syncer *cluster.Syncer) {
 	h.mu.Lock()
@@ -148,6 +657,43 @@ func (h *Handler) SetClusterSyncer(syncer *cluster.Syncer) {
 	h.clusterSyncer = syncer
 }
 
+// SetClusterMembers sets the cluster member list. On the primary, nodes
+// fetching the export are recorded in it and the list is sent along.
+func (h *Handler) SetClusterMembers(m *cluster.Members) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.clusterMembers = m
+}
+
+// SetPacketCapture sets the recorder packet captures are started on.
+func (h *Handler) SetPacketCapture(rec *capture.Recorder) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.packetCapture = rec
+}
+
+// GetPacketCapture retrieves the packet capture recorder.
+func (h *Handler) GetPacketCapture() *capture.Recorder {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.packetCapture
+}
+
+// SetLogController sets the controller of the server's log level and
+// format.
+func (h *Handler) SetLogController(c *logging.Controller) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.logController = c
+}
+
+// GetLogController retrieves the log controller.
+func (h *Handler) GetLogController() *logging.Controller {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.logController
+}
+
 // GetClusterSyncer retrieves the cluster syncer.
 func (h *Handler) GetClusterSyncer() *cluster.Syncer {
 	h.mu.RLock()
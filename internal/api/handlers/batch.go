@@ -0,0 +1,387 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jroosing/hydradns/internal/api/models"
+	"github.com/jroosing/hydradns/internal/database"
+)
+
+// ApplyBatch godoc
+// @Summary Apply a batch of changes
+// @Description Applies custom DNS and whitelist/blacklist changes all or nothing. Operations are checked in order, each against the state left by the ones before it; when any cannot be applied, every error is returned and nothing changes. Otherwise all changes are written in a single transaction and the resolvers reload once. Operations: add_host, update_host (name, ips), delete_host (name), add_cname, update_cname (name, target), delete_cname (name), whitelist_add, whitelist_remove, blacklist_add, blacklist_remove (domains). On a cluster secondary, as with the single-record endpoints, changes are local and only local records and domains can be deleted.
+// @Tags batch
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param batch body models.BatchRequest true "Operations to apply"
+// @Success 200 {object} models.BatchResponse
+// @Failure 400 {object} models.BatchResponse "Invalid operations; nothing applied"
+// @Failure 500 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
+// @Router /batch [post]
+func (h *Handler) ApplyBatch(c *gin.Context) {
+	var req models.BatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request: " + err.Error()})
+		return
+	}
+	if h.db == nil {
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Error: "database not available"})
+		return
+	}
+
+	ctx := context.Background()
+	pe := h.GetPolicyEngine()
+	secondary := h.isSecondary()
+
+	// The lock is held until the batch is written, so that no other change
+	// lands between checking the operations and applying them
+	h.mu.Lock()
+	state, err := h.loadBatchState(ctx, secondary)
+	if err != nil {
+		h.mu.Unlock()
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	var resp models.BatchResponse
+	for i, op := range req.Operations {
+		if err := state.apply(op); err != nil {
+			resp.Errors = append(resp.Errors, models.BatchError{Index: i, Op: op.Op, Error: err.Error()})
+		}
+	}
+	if len(resp.Errors) > 0 {
+		h.mu.Unlock()
+		c.JSON(http.StatusBadRequest, resp)
+		return
+	}
+
+	batch := state.diff()
+	customDNSChanged := len(state.touched) > 0
+	if customDNSChanged {
+		h.saveCustomDNSVersion(ctx, fmt.Sprintf("batch of %d operations", len(req.Operations)))
+	}
+	if err := h.db.ApplyBatch(ctx, batch); err != nil {
+		h.mu.Unlock()
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to apply batch: " + err.Error()})
+		return
+	}
+
+	if customDNSChanged {
+		h.cfg.CustomDNS.Hosts = state.hosts
+		h.cfg.CustomDNS.CNAMEs = state.cnames
+	}
+	reloadFunc := h.customDNSReloadFunc
+	h.mu.Unlock()
+
+	if pe != nil {
+		for _, d := range batch.WhitelistRemove {
+			pe.RemoveFromWhitelist(d)
+		}
+		for _, d := range batch.WhitelistAdd {
+			pe.AddToWhitelist(d)
+		}
+		for _, d := range batch.BlacklistRemove {
+			pe.RemoveFromBlacklist(d)
+		}
+		for _, d := range batch.BlacklistAdd {
+			pe.AddToBlacklist(d)
+		}
+	}
+
+	// Trigger a single resolver reload (outside of lock to avoid deadlock)
+	if customDNSChanged {
+		h.triggerReload(reloadFunc)
+	}
+
+	if h.logger != nil {
+		h.logger.Info("applied batch", "operations", len(req.Operations))
+	}
+	resp.Applied = len(req.Operations)
+	c.JSON(http.StatusOK, resp)
+}
+
+// batchList is the working copy of a whitelist or blacklist in a batch.
+type batchList struct {
+	name    string
+	initial map[string]struct{}
+	domains map[string]struct{}
+	added   map[string]struct{}
+	local   map[string]struct{}
+}
+
+// batchState is the state a batch is checked and applied against. It
+// starts as a copy of the current records and lists.
+type batchState struct {
+	secondary bool
+	hosts     map[string][]string
+	cnames    map[string]string
+	// localNames are the custom DNS names a secondary may delete
+	localNames map[string]struct{}
+	// touched are the custom DNS names, as stored, whose records change
+	touched   map[string]struct{}
+	whitelist *batchList
+	blacklist *batchList
+}
+
+// loadBatchState copies the current state. It must be called with h.mu held.
+func (h *Handler) loadBatchState(ctx context.Context, secondary bool) (*batchState, error) {
+	s := &batchState{
+		secondary:  secondary,
+		hosts:      maps.Clone(h.cfg.CustomDNS.Hosts),
+		cnames:     maps.Clone(h.cfg.CustomDNS.CNAMEs),
+		localNames: map[string]struct{}{},
+		touched:    map[string]struct{}{},
+	}
+	if s.hosts == nil {
+		s.hosts = make(map[string][]string)
+	}
+	if s.cnames == nil {
+		s.cnames = make(map[string]string)
+	}
+
+	if s.secondary {
+		local, err := h.db.GetLocalCustomDNSNames(ctx)
+		if err != nil {
+			return nil, err
+		}
+		s.localNames = setOf(local)
+	}
+
+	var err error
+	if s.whitelist, err = loadBatchList(ctx, h.whitelistOps()); err != nil {
+		return nil, err
+	}
+	if s.blacklist, err = loadBatchList(ctx, h.blacklistOps()); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// loadBatchList copies the domains of a list.
+func loadBatchList(ctx context.Context, ops listOps) (*batchList, error) {
+	resp, err := domainList(ctx, ops)
+	if err != nil {
+		return nil, err
+	}
+	return &batchList{
+		name:    ops.name,
+		initial: setOf(resp.Domains),
+		domains: setOf(resp.Domains),
+		added:   map[string]struct{}{},
+		local:   setOf(resp.Local),
+	}, nil
+}
+
+// apply applies op to the state, or returns why it cannot be applied.
+func (s *batchState) apply(op models.BatchOperation) error {
+	switch op.Op {
+	case models.BatchAddHost, models.BatchUpdateHost:
+		name, err := canonicalName(strings.TrimSpace(op.Name))
+		if err != nil {
+			return err
+		}
+		if err := validateIPs(op.IPs); err != nil {
+			return err
+		}
+		key, exists := findName(s.hosts, name)
+		if op.Op == models.BatchAddHost && exists {
+			return fmt.Errorf("host already exists: %s", key)
+		}
+		if op.Op == models.BatchUpdateHost && !exists {
+			return fmt.Errorf("host not found: %s", name)
+		}
+		if exists {
+			delete(s.hosts, key)
+			s.touch(key)
+		}
+		s.hosts[name] = slices.Clone(op.IPs)
+		s.touch(name)
+	case models.BatchAddCNAME, models.BatchUpdateCNAME:
+		alias, err := canonicalName(strings.TrimSpace(op.Name))
+		if err != nil {
+			return err
+		}
+		target, err := canonicalName(strings.TrimSpace(op.Target))
+		if err != nil {
+			return err
+		}
+		key, exists := findName(s.cnames, alias)
+		if op.Op == models.BatchAddCNAME && exists {
+			return fmt.Errorf("CNAME already exists: %s", key)
+		}
+		if op.Op == models.BatchUpdateCNAME && !exists {
+			return fmt.Errorf("CNAME not found: %s", alias)
+		}
+		if exists {
+			delete(s.cnames, key)
+			s.touch(key)
+		}
+		s.cnames[alias] = target
+		s.touch(alias)
+	case models.BatchDeleteHost:
+		name, err := canonicalName(strings.TrimSpace(op.Name))
+		if err != nil {
+			return err
+		}
+		key, exists := findName(s.hosts, name)
+		if !exists {
+			return fmt.Errorf("host not found: %s", name)
+		}
+		if err := s.checkLocalName(name); err != nil {
+			return err
+		}
+		delete(s.hosts, key)
+		s.touch(key)
+	case models.BatchDeleteCNAME:
+		alias, err := canonicalName(strings.TrimSpace(op.Name))
+		if err != nil {
+			return err
+		}
+		key, exists := findName(s.cnames, alias)
+		if !exists {
+			return fmt.Errorf("CNAME not found: %s", alias)
+		}
+		if err := s.checkLocalName(alias); err != nil {
+			return err
+		}
+		delete(s.cnames, key)
+		s.touch(key)
+	case models.BatchWhitelistAdd:
+		return s.whitelist.add(op.Domains, s.secondary)
+	case models.BatchWhitelistRemove:
+		return s.whitelist.remove(op.Domains, s.secondary)
+	case models.BatchBlacklistAdd:
+		return s.blacklist.add(op.Domains, s.secondary)
+	case models.BatchBlacklistRemove:
+		return s.blacklist.remove(op.Domains, s.secondary)
+	default:
+		return fmt.Errorf("unknown operation %q", op.Op)
+	}
+	return nil
+}
+
+// touch records that the records of name change. Records changed on a
+// secondary are local, so later operations of the batch may delete them.
+func (s *batchState) touch(name string) {
+	s.touched[name] = struct{}{}
+	if s.secondary {
+		s.localNames[name] = struct{}{}
+	}
+}
+
+// checkLocalName returns an error when this node is a secondary and name
+// has no local records. Synced records would be restored by the next sync.
+func (s *batchState) checkLocalName(name string) error {
+	if !s.secondary {
+		return nil
+	}
+	if _, ok := findName(s.localNames, name); ok {
+		return nil
+	}
+	return fmt.Errorf("%s is synced from the primary; only local records can be deleted on a secondary", name)
+}
+
+// add adds domains to the list.
+func (l *batchList) add(domains []string, secondary bool) error {
+	if len(domains) == 0 {
+		return fmt.Errorf("at least one domain is required")
+	}
+	canonical, err := canonicalDomains(domains)
+	if err != nil {
+		return err
+	}
+	for _, d := range canonical {
+		l.domains[d] = struct{}{}
+		l.added[d] = struct{}{}
+		if secondary {
+			l.local[d] = struct{}{}
+		}
+	}
+	return nil
+}
+
+// remove removes domains from the list. A secondary may only remove its
+// local domains.
+func (l *batchList) remove(domains []string, secondary bool) error {
+	if len(domains) == 0 {
+		return fmt.Errorf("at least one domain is required")
+	}
+	canonical, err := canonicalDomains(domains)
+	if err != nil {
+		return err
+	}
+	for _, d := range canonical {
+		if _, ok := l.domains[d]; !ok {
+			return fmt.Errorf("%s domain not found: %s", l.name, d)
+		}
+		if _, ok := l.local[d]; secondary && !ok {
+			return fmt.Errorf("%s is synced from the primary; only local domains can be removed on a secondary", d)
+		}
+	}
+	for _, d := range canonical {
+		delete(l.domains, d)
+		delete(l.added, d)
+	}
+	return nil
+}
+
+// changes returns the domains added to and removed from the list.
+func (l *batchList) changes() (add, remove []string) {
+	add = slices.Sorted(maps.Keys(l.added))
+	for d := range l.initial {
+		if _, ok := l.domains[d]; !ok {
+			remove = append(remove, d)
+		}
+	}
+	slices.Sort(remove)
+	return add, remove
+}
+
+// canonicalDomains returns the canonical forms of domains.
+func canonicalDomains(domains []string) ([]string, error) {
+	canonical := make([]string, len(domains))
+	for i, d := range domains {
+		c, err := canonicalName(d)
+		if err != nil {
+			return nil, err
+		}
+		canonical[i] = c
+	}
+	return canonical, nil
+}
+
+// diff returns the database changes that turn the stored state into s:
+// every touched name is rewritten with the records it ends up with.
+func (s *batchState) diff() database.Batch {
+	b := database.Batch{
+		CustomDNS: database.CustomDNSImport{
+			Hosts:  map[string][]string{},
+			CNAMEs: map[string]string{},
+			Local:  s.secondary,
+		},
+	}
+	for _, name := range slices.Sorted(maps.Keys(s.touched)) {
+		ips, isHost := s.hosts[name]
+		target, isCNAME := s.cnames[name]
+		if isHost {
+			b.CustomDNS.Hosts[name] = ips
+		}
+		if isCNAME {
+			b.CustomDNS.CNAMEs[name] = target
+		}
+		if !isHost && !isCNAME {
+			b.CustomDNS.Remove = append(b.CustomDNS.Remove, name)
+		}
+	}
+	b.WhitelistAdd, b.WhitelistRemove = s.whitelist.changes()
+	b.BlacklistAdd, b.BlacklistRemove = s.blacklist.changes()
+	return b
+}
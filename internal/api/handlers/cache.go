@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jroosing/hydradns/internal/api/models"
+	"github.com/jroosing/hydradns/internal/config"
+)
+
+// ListCacheOverrides godoc
+// @Summary List cache zone overrides
+// @Description Lists the negative and SERVFAIL cache settings overridden for a zone and its subdomains, ordered by zone
+// @Tags cache
+// @Produce json
+// @Success 200 {object} models.CacheOverridesResponse
+// @Security ApiKeyAuth
+// @Router /cache/overrides [get]
+func (h *Handler) ListCacheOverrides(c *gin.Context) {
+	h.mu.RLock()
+	overrides := slices.Clone(h.cfg.Cache.ZoneOverrides)
+	h.mu.RUnlock()
+
+	if overrides == nil {
+		overrides = []config.CacheZoneOverride{}
+	}
+	c.JSON(http.StatusOK, models.CacheOverridesResponse{Overrides: overrides})
+}
+
+// SetCacheOverride godoc
+// @Summary Set the cache override of a zone
+// @Description Adds or replaces the negative and SERVFAIL cache settings for a zone and its subdomains. Unset fields
+// @Description inherit the global setting. Responses already cached keep their TTL. Cache settings are node-local and are not synced.
+// @Tags cache
+// @Accept json
+// @Produce json
+// @Param zone path string true "Zone"
+// @Param override body models.SetCacheOverrideRequest true "Cache settings for the zone"
+// @Success 200 {object} config.CacheZoneOverride
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /cache/overrides/{zone} [put]
+func (h *Handler) SetCacheOverride(c *gin.Context) {
+	if h.db == nil {
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Error: "database not available"})
+		return
+	}
+
+	var req models.SetCacheOverrideRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request: " + err.Error()})
+		return
+	}
+	if req.DisableNegative == nil && req.ServfailTTL == "" && req.NegativeTTL == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "at least one of disable_negative, servfail_ttl, or negative_ttl is required"})
+		return
+	}
+
+	o := config.CacheZoneOverride{
+		Zone:            c.Param("zone"),
+		DisableNegative: req.DisableNegative,
+		ServfailTTL:     req.ServfailTTL,
+		NegativeTTL:     req.NegativeTTL,
+	}
+	if err := o.Normalize(); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	// Persist first so the runtime never diverges from the database
+	if err := h.db.SetCacheZoneOverride(c.Request.Context(), o); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to persist cache override: " + err.Error()})
+		return
+	}
+
+	h.mu.Lock()
+	overrides := h.cfg.Cache.ZoneOverrides
+	if i := slices.IndexFunc(overrides, func(e config.CacheZoneOverride) bool { return e.Zone == o.Zone }); i >= 0 {
+		overrides[i] = o
+	} else {
+		overrides = append(overrides, o)
+		slices.SortFunc(overrides, func(a, b config.CacheZoneOverride) int { return strings.Compare(a.Zone, b.Zone) })
+	}
+	h.cfg.Cache.ZoneOverrides = overrides
+	reloadFunc := h.cacheReloadFunc
+	h.mu.Unlock()
+
+	if !h.reloadCache(c, reloadFunc) {
+		return
+	}
+	c.JSON(http.StatusOK, o)
+}
+
+// DeleteCacheOverride godoc
+// @Summary Remove the cache override of a zone
+// @Description Applies the global negative and SERVFAIL cache settings to the zone again
+// @Tags cache
+// @Produce json
+// @Param zone path string true "Zone"
+// @Success 200 {object} models.StatusResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /cache/overrides/{zone} [delete]
+func (h *Handler) DeleteCacheOverride(c *gin.Context) {
+	if h.db == nil {
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Error: "database not available"})
+		return
+	}
+
+	o := config.CacheZoneOverride{Zone: c.Param("zone")}
+	if err := o.Normalize(); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	h.mu.RLock()
+	exists := slices.ContainsFunc(h.cfg.Cache.ZoneOverrides, func(e config.CacheZoneOverride) bool { return e.Zone == o.Zone })
+	h.mu.RUnlock()
+	if !exists {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "No cache override for zone: " + o.Zone})
+		return
+	}
+
+	if err := h.db.DeleteCacheZoneOverride(c.Request.Context(), o.Zone); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to delete cache override: " + err.Error()})
+		return
+	}
+
+	h.mu.Lock()
+	h.cfg.Cache.ZoneOverrides = slices.DeleteFunc(h.cfg.Cache.ZoneOverrides, func(e config.CacheZoneOverride) bool { return e.Zone == o.Zone })
+	reloadFunc := h.cacheReloadFunc
+	h.mu.Unlock()
+
+	if !h.reloadCache(c, reloadFunc) {
+		return
+	}
+	c.JSON(http.StatusOK, models.StatusResponse{Status: "ok"})
+}
+
+// reloadCache applies cache override changes to the running resolver and
+// writes a 500 response if that fails.
+func (h *Handler) reloadCache(c *gin.Context, reloadFunc func() error) bool {
+	if reloadFunc == nil {
+		h.logWarn("cache overrides updated but no reload function registered")
+		return true
+	}
+	if err := reloadFunc(); err != nil {
+		h.logError("failed to apply cache overrides", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to apply cache overrides: " + err.Error()})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,108 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jroosing/hydradns/internal/api/models"
+	"github.com/jroosing/hydradns/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// =============================================================================
+// Cache Override Tests
+// =============================================================================
+
+func TestSetCacheOverride_PersistsAndReloads(t *testing.T) {
+	cfg := &config.Config{}
+	h := createCustomDNSTestHandler(t, cfg)
+
+	reloads := 0
+	h.SetCacheReloadFunc(func() error {
+		reloads++
+		return nil
+	})
+
+	router := gin.New()
+	router.PUT("/cache/overrides/:zone", h.SetCacheOverride)
+	router.GET("/cache/overrides", h.ListCacheOverrides)
+
+	w := performRequest(router, http.MethodPut, "/cache/overrides/Corp.LAN.", `{"disable_negative":true}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = performRequest(router, http.MethodPut, "/cache/overrides/ads.example", `{"negative_ttl":"1m"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// Replacing keeps one override per zone
+	w = performRequest(router, http.MethodPut, "/cache/overrides/ads.example", `{"servfail_ttl":"10s"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, 3, reloads)
+
+	disabled := true
+	want := []config.CacheZoneOverride{
+		{Zone: "ads.example", ServfailTTL: "10s"},
+		{Zone: "corp.lan", DisableNegative: &disabled},
+	}
+
+	w = performRequest(router, http.MethodGet, "/cache/overrides", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp models.CacheOverridesResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, want, resp.Overrides)
+
+	stored, err := h.DB().GetCacheConfig(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, want, stored.ZoneOverrides)
+}
+
+func TestSetCacheOverride_Validation(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"no settings", `{}`},
+		{"bad duration", `{"negative_ttl":"soon"}`},
+		{"negative ttl", `{"servfail_ttl":"-1s"}`},
+		{"malformed json", `{"negative_ttl":`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			h := createCustomDNSTestHandler(t, cfg)
+
+			router := gin.New()
+			router.PUT("/cache/overrides/:zone", h.SetCacheOverride)
+
+			w := performRequest(router, http.MethodPut, "/cache/overrides/corp.lan", tt.body)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Empty(t, cfg.Cache.ZoneOverrides)
+		})
+	}
+}
+
+func TestDeleteCacheOverride(t *testing.T) {
+	cfg := &config.Config{}
+	h := createCustomDNSTestHandler(t, cfg)
+
+	router := gin.New()
+	router.PUT("/cache/overrides/:zone", h.SetCacheOverride)
+	router.DELETE("/cache/overrides/:zone", h.DeleteCacheOverride)
+
+	w := performRequest(router, http.MethodPut, "/cache/overrides/corp.lan", `{"negative_ttl":"0s"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = performRequest(router, http.MethodDelete, "/cache/overrides/CORP.lan", "")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Empty(t, cfg.Cache.ZoneOverrides)
+
+	stored, err := h.DB().GetCacheConfig(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, stored.ZoneOverrides)
+
+	w = performRequest(router, http.MethodDelete, "/cache/overrides/corp.lan", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
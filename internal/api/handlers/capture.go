@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jroosing/hydradns/internal/api/models"
+	"github.com/jroosing/hydradns/internal/capture"
+)
+
+// StartCapture godoc
+// @Summary Start a packet capture
+// @Description Records the next count raw DNS messages exchanged with clients and upstream servers, optionally only those about a name (and its subdomains) or a client address or prefix. A running capture is replaced. Client addresses are anonymized or dropped according to the query-log privacy level, and captures are refused at level none. Download the result as a pcap file from /capture/pcap. Requires the admin API key.
+// @Tags system
+// @Accept json
+// @Produce json
+// @Param capture body models.StartCaptureRequest true "What to capture"
+// @Success 202 {object} models.CaptureStatusResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse "Captures refused at the current privacy level"
+// @Failure 503 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /capture [post]
+func (h *Handler) StartCapture(c *gin.Context) {
+	rec := h.GetPacketCapture()
+	if rec == nil {
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Error: "packet capture not available"})
+		return
+	}
+
+	var req models.StartCaptureRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	filter := capture.Filter{Name: strings.TrimSpace(req.Name)}
+	if req.Client != "" {
+		client, err := parseClientPrefix(req.Client)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "invalid client: " + err.Error()})
+			return
+		}
+		filter.Client = client
+	}
+	if err := rec.Start(filter, req.Count); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, capture.ErrRefused) {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if h.logger != nil {
+		h.logger.Info("packet capture started", "count", req.Count, "name", filter.Name, "client", req.Client)
+	}
+	c.JSON(http.StatusAccepted, captureStatusResponse(rec.Status()))
+}
+
+// GetCapture godoc
+// @Summary Get packet capture status
+// @Description Returns whether a packet capture is running and how many messages the latest one recorded
+// @Tags system
+// @Produce json
+// @Success 200 {object} models.CaptureStatusResponse
+// @Failure 503 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /capture [get]
+func (h *Handler) GetCapture(c *gin.Context) {
+	rec := h.GetPacketCapture()
+	if rec == nil {
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Error: "packet capture not available"})
+		return
+	}
+	c.JSON(http.StatusOK, captureStatusResponse(rec.Status()))
+}
+
+// StopCapture godoc
+// @Summary Stop the packet capture
+// @Description Stops the running packet capture early, keeping what it recorded for download
+// @Tags system
+// @Produce json
+// @Success 200 {object} models.CaptureStatusResponse
+// @Failure 503 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /capture [delete]
+func (h *Handler) StopCapture(c *gin.Context) {
+	rec := h.GetPacketCapture()
+	if rec == nil {
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Error: "packet capture not available"})
+		return
+	}
+	rec.Stop()
+	c.JSON(http.StatusOK, captureStatusResponse(rec.Status()))
+}
+
+// DownloadCapture godoc
+// @Summary Download the packet capture
+// @Description Returns the messages recorded by the latest packet capture as a pcap file for Wireshark or tcpdump, including those of a capture still running
+// @Tags system
+// @Produce application/vnd.tcpdump.pcap
+// @Success 200 {file} binary
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /capture/pcap [get]
+func (h *Handler) DownloadCapture(c *gin.Context) {
+	rec := h.GetPacketCapture()
+	if rec == nil {
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Error: "packet capture not available"})
+		return
+	}
+	if rec.Status().StartedAt.IsZero() {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "no packet capture has been started"})
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := rec.WritePCAP(&buf); err != nil {
+		h.logError("failed to write packet capture", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "failed to write packet capture"})
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="hydradns.pcap"`)
+	c.Data(http.StatusOK, "application/vnd.tcpdump.pcap", buf.Bytes())
+}
+
+// parseClientPrefix parses a client address or CIDR prefix.
+func parseClientPrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		return netip.ParsePrefix(s)
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// captureStatusResponse converts a capture status to its API model.
+func captureStatusResponse(s capture.Status) models.CaptureStatusResponse {
+	out := models.CaptureStatusResponse{
+		Active:   s.Active,
+		Name:     s.Filter.Name,
+		Count:    s.Limit,
+		Captured: s.Captured,
+	}
+	if s.Filter.Client.IsValid() {
+		out.Client = s.Filter.Client.String()
+	}
+	if !s.StartedAt.IsZero() {
+		out.StartedAt = &s.StartedAt
+	}
+	if !s.StoppedAt.IsZero() {
+		out.StoppedAt = &s.StoppedAt
+	}
+	return out
+}
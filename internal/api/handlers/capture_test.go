@@ -0,0 +1,124 @@
+package handlers_test
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/netip"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jroosing/hydradns/internal/api/handlers"
+	"github.com/jroosing/hydradns/internal/api/models"
+	"github.com/jroosing/hydradns/internal/capture"
+	"github.com/jroosing/hydradns/internal/config"
+	"github.com/jroosing/hydradns/internal/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// captureRouter serves the packet capture endpoints with rec.
+func captureRouter(rec *capture.Recorder) *gin.Engine {
+	h := handlers.New(&config.Config{}, nil, nil)
+	if rec != nil {
+		h.SetPacketCapture(rec)
+	}
+	router := gin.New()
+	router.GET("/capture", h.GetCapture)
+	router.POST("/capture", h.StartCapture)
+	router.DELETE("/capture", h.StopCapture)
+	router.GET("/capture/pcap", h.DownloadCapture)
+	return router
+}
+
+func TestCapture_StartRecordAndDownload(t *testing.T) {
+	rec := capture.NewRecorder()
+	router := captureRouter(rec)
+
+	w := performRequest(router, http.MethodGet, "/capture/pcap", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = performRequest(router, http.MethodPost, "/capture", `{"count":1,"name":"example.com","client":"192.0.2.10"}`)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	var status models.CaptureStatusResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.True(t, status.Active)
+	assert.Equal(t, "example.com", status.Name)
+	assert.Equal(t, "192.0.2.10/32", status.Client)
+	assert.Equal(t, 1, status.Count)
+
+	msg, err := dns.Packet{
+		Header:    dns.Header{ID: 1},
+		Questions: []dns.Question{{Name: "www.example.com", Type: uint16(dns.TypeA), Class: uint16(dns.ClassIN)}},
+	}.Marshal()
+	require.NoError(t, err)
+	client := netip.MustParseAddrPort("192.0.2.10:40000")
+	rec.RecordClient("udp", client.Addr(), client, netip.MustParseAddrPort("192.0.2.1:53"), msg)
+
+	w = performRequest(router, http.MethodGet, "/capture", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.False(t, status.Active, "stops at the count")
+	assert.Equal(t, 1, status.Captured)
+	assert.NotNil(t, status.StoppedAt)
+
+	w = performRequest(router, http.MethodGet, "/capture/pcap", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/vnd.tcpdump.pcap", w.Header().Get("Content-Type"))
+	body := w.Body.Bytes()
+	require.Greater(t, len(body), 24+16)
+	assert.Equal(t, uint32(0xa1b2c3d4), binary.LittleEndian.Uint32(body))
+	assert.Equal(t, msg, body[len(body)-len(msg):])
+}
+
+func TestCapture_Stop(t *testing.T) {
+	rec := capture.NewRecorder()
+	router := captureRouter(rec)
+
+	w := performRequest(router, http.MethodPost, "/capture", `{"count":100}`)
+	require.Equal(t, http.StatusAccepted, w.Code)
+	assert.True(t, rec.Capturing())
+
+	w = performRequest(router, http.MethodDelete, "/capture", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.False(t, rec.Capturing())
+
+	// An empty capture still downloads as a valid pcap file
+	w = performRequest(router, http.MethodGet, "/capture/pcap", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Len(t, w.Body.Bytes(), 24)
+}
+
+func TestCapture_RejectsInvalidRequests(t *testing.T) {
+	router := captureRouter(capture.NewRecorder())
+
+	for _, body := range []string{
+		`{}`,
+		`{"count":0}`,
+		`{"count":10001}`,
+		`{"count":10,"client":"not-an-ip"}`,
+		`{"count":10,"client":"10.0.0.0/33"}`,
+	} {
+		w := performRequest(router, http.MethodPost, "/capture", body)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+}
+
+func TestCapture_RefusedByPrivacy(t *testing.T) {
+	rec := capture.NewRecorder()
+	rec.SetRedaction(func() capture.Redaction { return capture.RedactRefuse })
+	router := captureRouter(rec)
+
+	w := performRequest(router, http.MethodPost, "/capture", `{"count":10}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.False(t, rec.Capturing())
+}
+
+func TestCapture_UnavailableWithoutRecorder(t *testing.T) {
+	router := captureRouter(nil)
+
+	w := performRequest(router, http.MethodGet, "/capture", "")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	w = performRequest(router, http.MethodPost, "/capture", `{"count":1}`)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
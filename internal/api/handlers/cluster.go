@@ -1,16 +1,67 @@
 package handlers
 
 import (
+	"context"
 	"net/http"
+	"net/netip"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/jroosing/hydradns/internal/api/middleware"
 	"github.com/jroosing/hydradns/internal/api/models"
 	"github.com/jroosing/hydradns/internal/cluster"
 	"github.com/jroosing/hydradns/internal/config"
+	"github.com/jroosing/hydradns/internal/database"
 )
 
+// RejectOnSecondary is middleware for endpoints that change configuration
+// synced from the primary (upstreams, filtering settings). On a secondary
+// such changes would be overwritten by the next sync, so they are rejected
+// with 409 Conflict and the primary's URL.
+func (h *Handler) RejectOnSecondary(c *gin.Context) {
+	if h.abortIfSecondary(c) {
+		return
+	}
+	c.Next()
+}
+
+// abortIfSecondary answers 409 Conflict and reports true when this node is a
+// secondary.
+func (h *Handler) abortIfSecondary(c *gin.Context) bool {
+	if !h.isSecondary() {
+		return false
+	}
+	h.abortReadOnly(c, "this node is a secondary; make configuration changes on the primary")
+	return true
+}
+
+// isSecondary reports whether this node is a cluster secondary. Custom DNS
+// records and whitelist/blacklist domains written on a secondary are stored
+// as local, so cluster sync keeps them.
+func (h *Handler) isSecondary() bool {
+	if h.cfg == nil {
+		return false
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.cfg.Cluster.Mode == config.ClusterModeSecondary
+}
+
+// abortReadOnly answers 409 Conflict with msg and the primary's URL.
+func (h *Handler) abortReadOnly(c *gin.Context, msg string) {
+	h.mu.RLock()
+	primaryURL := h.cfg.Cluster.PrimaryURL
+	h.mu.RUnlock()
+
+	c.AbortWithStatusJSON(http.StatusConflict, models.ReadOnlyErrorResponse{
+		Error:      msg,
+		PrimaryURL: primaryURL,
+	})
+}
+
 // GetClusterStatus godoc
 // @Summary Get cluster status
 // @Description Returns the current cluster mode and synchronization status
@@ -27,7 +78,7 @@ func (h *Handler) GetClusterStatus(c *gin.Context) {
 	}
 
 	h.mu.RLock()
-	syncer := h.clusterSyncer
+	syncer, members := h.clusterSyncer, h.clusterMembers
 	h.mu.RUnlock()
 
 	resp := models.ClusterStatusResponse{
@@ -43,20 +94,112 @@ func (h *Handler) GetClusterStatus(c *gin.Context) {
 	}
 
 	// If we have a syncer (secondary mode), include sync status
+	var primarySections map[string]string
 	if syncer != nil {
 		status := syncer.Status()
+		primarySections = status.PrimarySections
 		resp.PrimaryURL = status.PrimaryURL
 		resp.LastSyncTime = status.LastSyncTime
 		resp.LastSyncVersion = status.LastSyncVersion
+		resp.LastSyncKind = status.LastSyncKind
 		resp.LastSyncError = status.LastSyncError
 		resp.NextSyncTime = status.NextSyncTime
 		resp.SyncCount = status.SyncCount
 		resp.ErrorCount = status.ErrorCount
 	}
 
+	resp.Sections = h.sectionStatuses(c.Request.Context(), primarySections)
+
+	if members != nil {
+		list, _ := members.List()
+		resp.Members = make([]models.ClusterMember, len(list))
+		for i, m := range list {
+			resp.Members[i] = models.ClusterMember{
+				NodeID:   m.NodeID,
+				Role:     string(m.Role),
+				Address:  m.Address,
+				DNSPort:  m.DNSPort,
+				APIPort:  m.APIPort,
+				LastSeen: m.LastSeen,
+			}
+		}
+	}
+
 	c.JSON(http.StatusOK, resp)
 }
 
+// sectionStatuses reports the hash of each synced configuration section. On a
+// secondary the hashes are compared with those last applied from the primary
+// and with the primary's current hashes (nil before the first sync).
+func (h *Handler) sectionStatuses(ctx context.Context, primary map[string]string) []models.ClusterSectionStatus {
+	local, applied, err := h.localSections(ctx)
+	if err != nil {
+		h.logError("failed to compute cluster section hashes", err)
+		return nil
+	}
+
+	statuses := make([]models.ClusterSectionStatus, 0, len(local))
+	for _, name := range cluster.SectionNames() {
+		st := models.ClusterSectionStatus{Name: name, Hash: local[name]}
+		if a, ok := applied[name]; ok {
+			st.AppliedHash = a.Hash
+			st.AppliedVersion = a.Version
+			st.AppliedAt = a.AppliedAt
+			st.LocallyModified = st.Hash != a.Hash
+		}
+		if p, ok := primary[name]; ok {
+			st.PrimaryHash = p
+			st.OutOfSync = p != st.AppliedHash
+		}
+		statuses = append(statuses, st)
+	}
+	return statuses
+}
+
+// localSections returns this node's section hashes and, on a secondary, the
+// sections applied from the primary. A secondary hashes its database, which
+// holds the imported configuration plus any local records; other nodes hash
+// the configuration they export.
+func (h *Handler) localSections(ctx context.Context) (map[string]string, map[string]database.AppliedSection, error) {
+	if !h.isSecondary() || h.db == nil {
+		h.mu.RLock()
+		defer h.mu.RUnlock()
+		return exportData(h.cfg).SectionHashes(), nil, nil
+	}
+
+	cfg, err := h.db.ExportToConfig(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	applied, err := h.db.GetAppliedSections(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return exportData(cfg).SectionHashes(), applied, nil
+}
+
+// exportData returns the synced sections of cfg. Expired temporary allows
+// are left out, so secondaries drop them on their next sync.
+func exportData(cfg *config.Config) *cluster.ExportData {
+	filtering := cfg.Filtering
+	filtering.TemporaryAllows = nil
+	now := time.Now()
+	for domain, expiry := range cfg.Filtering.TemporaryAllows {
+		if !now.Before(expiry) {
+			continue
+		}
+		if filtering.TemporaryAllows == nil {
+			filtering.TemporaryAllows = make(map[string]time.Time)
+		}
+		filtering.TemporaryAllows[domain] = expiry
+	}
+	return &cluster.ExportData{
+		Upstream:  cfg.Upstream,
+		CustomDNS: cfg.CustomDNS,
+		Filtering: filtering,
+	}
+}
+
 // GetClusterExport godoc
 // @Summary Export configuration for cluster sync
 // @Description Returns configuration data for secondary nodes to import (primary only)
@@ -67,30 +210,10 @@ func (h *Handler) GetClusterStatus(c *gin.Context) {
 // @Failure 500 {object} models.ErrorResponse
 // @Router /cluster/export [get]
 func (h *Handler) GetClusterExport(c *gin.Context) {
-	if h.cfg == nil || h.db == nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "config unavailable"})
-		return
-	}
-
-	// Only allow export from primary or standalone mode
-	if h.cfg.Cluster.Mode == config.ClusterModeSecondary {
-		c.JSON(http.StatusForbidden, models.ErrorResponse{
-			Error: "export not allowed from secondary node",
-		})
+	if !h.allowClusterExport(c) {
 		return
 	}
 
-	// Validate shared secret if configured
-	if h.cfg.Cluster.SharedSecret != "" {
-		secret := c.GetHeader("X-Cluster-Secret")
-		if secret != h.cfg.Cluster.SharedSecret {
-			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
-				Error: "invalid cluster secret",
-			})
-			return
-		}
-	}
-
 	// Get configuration version
 	version, err := h.db.GetVersion(c.Request.Context())
 	if err != nil {
@@ -101,14 +224,15 @@ func (h *Handler) GetClusterExport(c *gin.Context) {
 	}
 
 	// Build export data
-	data := cluster.ExportData{
-		Version:   version,
-		Timestamp: time.Now().UTC(),
-		NodeID:    h.cfg.Cluster.NodeID,
-		Upstream:  h.cfg.Upstream,
-		CustomDNS: h.cfg.CustomDNS,
-		Filtering: h.cfg.Filtering,
-	}
+	h.mu.RLock()
+	data := exportData(h.cfg)
+	data.Sections = data.SectionHashes()
+	data.Journal, data.Seq = h.clusterJournal.Observe(data)
+	h.mu.RUnlock()
+	data.Version = version
+	data.Timestamp = time.Now().UTC()
+	data.NodeID = h.cfg.Cluster.NodeID
+	data.Members = h.clusterMembersSeen(c)
 
 	// Log the sync request
 	requestingNode := c.GetHeader("X-Node-Id")
@@ -122,6 +246,139 @@ func (h *Handler) GetClusterExport(c *gin.Context) {
 	c.JSON(http.StatusOK, data)
 }
 
+// GetClusterChanges godoc
+// @Summary Export configuration changes for cluster sync
+// @Description Returns the changes to the synced configuration after a position in the primary's change journal, so secondaries do not fetch the full export on every sync (primary only). Answers 410 Gone when the journal no longer has these changes; the secondary then fetches the full export.
+// @Tags cluster
+// @Produce json
+// @Param journal query string true "Journal ID from the previous export or changes"
+// @Param since query int true "Sequence number from the previous export or changes"
+// @Success 200 {object} cluster.Changes
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 410 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /cluster/changes [get]
+func (h *Handler) GetClusterChanges(c *gin.Context) {
+	if !h.allowClusterExport(c) {
+		return
+	}
+
+	journal := c.Query("journal")
+	since, err := strconv.ParseInt(c.Query("since"), 10, 64)
+	if journal == "" || err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "journal and since are required"})
+		return
+	}
+
+	version, err := h.db.GetVersion(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "failed to get config version",
+		})
+		return
+	}
+
+	h.mu.RLock()
+	data := exportData(h.cfg)
+	sections := data.SectionHashes()
+	id, seq := h.clusterJournal.Observe(data)
+	h.mu.RUnlock()
+
+	changes, ok := h.clusterJournal.Since(journal, since)
+	if !ok {
+		c.JSON(http.StatusGone, models.ErrorResponse{
+			Error: "changes no longer available; fetch the full export",
+		})
+		return
+	}
+
+	if requestingNode := c.GetHeader("X-Node-Id"); requestingNode != "" && len(changes) > 0 {
+		h.logger.Info("cluster changes requested",
+			"requesting_node", requestingNode,
+			"since", since,
+			"changes", len(changes),
+		)
+	}
+
+	c.JSON(http.StatusOK, cluster.Changes{
+		Journal:   id,
+		Since:     since,
+		Seq:       seq,
+		Version:   version,
+		Timestamp: time.Now().UTC(),
+		NodeID:    h.cfg.Cluster.NodeID,
+		Sections:  sections,
+		Changes:   changes,
+		Members:   h.clusterMembersSeen(c),
+	})
+}
+
+// clusterMembersSeen records the node requesting the export as a cluster
+// member and returns the members to send along, or nil when members are not
+// tracked. The node describes itself in the X-Node-* headers; without
+// X-Node-Address, the address the request came from is published.
+func (h *Handler) clusterMembersSeen(c *gin.Context) []cluster.Member {
+	h.mu.RLock()
+	members := h.clusterMembers
+	h.mu.RUnlock()
+	if members == nil {
+		return nil
+	}
+
+	if nodeID := c.GetHeader("X-Node-Id"); nodeID != "" {
+		m := cluster.Member{NodeID: nodeID, Role: config.ClusterModeSecondary, Address: c.ClientIP()}
+		if ip, err := netip.ParseAddr(c.GetHeader("X-Node-Address")); err == nil {
+			m.Address = ip.Unmap().String()
+		}
+		m.DNSPort = headerPort(c, "X-Node-Dns-Port")
+		m.APIPort = headerPort(c, "X-Node-Api-Port")
+		members.Seen(m)
+	}
+	list, _ := members.List()
+	return list
+}
+
+// headerPort returns the port in header name, or 0 if it holds none.
+func headerPort(c *gin.Context, name string) int {
+	port, err := strconv.Atoi(c.GetHeader(name))
+	if err != nil || port < 1 || port > 65535 {
+		return 0
+	}
+	return port
+}
+
+// allowClusterExport reports whether the configuration may be exported to
+// the requesting secondary, answering the request otherwise: only primary
+// and standalone nodes export, and only with the shared secret if one is
+// configured.
+func (h *Handler) allowClusterExport(c *gin.Context) bool {
+	if h.cfg == nil || h.db == nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "config unavailable"})
+		return false
+	}
+
+	// Only allow export from primary or standalone mode
+	if h.cfg.Cluster.Mode == config.ClusterModeSecondary {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error: "export not allowed from secondary node",
+		})
+		return false
+	}
+
+	// Validate shared secret if configured
+	if h.cfg.Cluster.SharedSecret != "" {
+		secret := c.GetHeader("X-Cluster-Secret")
+		if secret != h.cfg.Cluster.SharedSecret {
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+				Error: "invalid cluster secret",
+			})
+			return false
+		}
+	}
+	return true
+}
+
 // PostClusterSync godoc
 // @Summary Force immediate sync (secondary only)
 // @Description Triggers an immediate configuration sync from the primary node
@@ -166,6 +423,163 @@ func (h *Handler) PostClusterSync(c *gin.Context) {
 	c.JSON(http.StatusOK, models.StatusResponse{Status: "sync completed"})
 }
 
+// PromoteCluster godoc
+// @Summary Promote this secondary to primary
+// @Description Converts this secondary into the primary when the primary is lost: stops the syncer, switches the cluster mode to primary (bumping the config version), and records the promotion in the audit trail. Refused while the primary still serves configuration or if this node never synced from it, unless force is set. Other secondaries must be pointed at the new primary.
+// @Tags cluster
+// @Accept json
+// @Produce json
+// @Param request body models.ClusterPromoteRequest true "Promotion confirmation"
+// @Success 200 {object} models.ClusterPromotion
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /cluster/promote [post]
+func (h *Handler) PromoteCluster(c *gin.Context) {
+	if h.cfg == nil || h.db == nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "config unavailable"})
+		return
+	}
+
+	var req models.ClusterPromoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "invalid request: " + err.Error()})
+		return
+	}
+	if !req.Confirm {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "confirm must be true to promote this node"})
+		return
+	}
+
+	h.mu.RLock()
+	clusterCfg := h.cfg.Cluster
+	syncer := h.clusterSyncer
+	h.mu.RUnlock()
+
+	if clusterCfg.Mode != config.ClusterModeSecondary {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error: "promotion only available in secondary mode",
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	if !req.Force {
+		applied, err := h.db.GetAppliedSections(ctx)
+		if err != nil {
+			h.logError("failed to read applied cluster sections", err)
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "failed to read sync state"})
+			return
+		}
+		if len(applied) == 0 {
+			c.JSON(http.StatusConflict, models.ErrorResponse{
+				Error: "this node never synced from the primary; set force to promote it anyway",
+			})
+			return
+		}
+		// Two primaries would split the cluster
+		if clusterCfg.PrimaryURL != "" && cluster.CheckPrimary(ctx, &clusterCfg) == nil {
+			c.JSON(http.StatusConflict, models.ErrorResponse{
+				Error: "the primary at " + clusterCfg.PrimaryURL + " is still serving configuration; stop it first or set force",
+			})
+			return
+		}
+	}
+
+	// Stop syncing first, so no sync from the old primary lands after the
+	// promotion
+	if syncer != nil {
+		syncer.Stop()
+	}
+
+	requestedBy := c.GetString(middleware.TokenNameKey)
+	if requestedBy == "" {
+		requestedBy = "admin"
+	}
+	p, err := h.db.PromoteToPrimary(ctx, database.Promotion{
+		NodeID:             clusterCfg.NodeID,
+		PreviousPrimaryURL: clusterCfg.PrimaryURL,
+		Reason:             req.Reason,
+		Forced:             req.Force,
+		RequestedBy:        requestedBy,
+		ClientIP:           c.ClientIP(),
+	})
+	if err != nil {
+		h.logError("failed to promote node", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "failed to promote node; restart it to resume syncing",
+		})
+		return
+	}
+
+	h.mu.Lock()
+	h.cfg.Cluster.Mode = config.ClusterModePrimary
+	h.cfg.Cluster.PrimaryURL = ""
+	h.clusterSyncer = nil
+	members := h.clusterMembers
+	h.mu.Unlock()
+	if members != nil {
+		members.SetRole(config.ClusterModePrimary)
+	}
+
+	h.logger.Warn("node promoted to primary",
+		"node_id", p.NodeID,
+		"previous_primary", p.PreviousPrimaryURL,
+		"reason", p.Reason,
+		"forced", p.Forced,
+		"requested_by", p.RequestedBy,
+		"client_ip", p.ClientIP,
+		"config_version", p.ConfigVersion,
+	)
+
+	c.JSON(http.StatusOK, promotionModel(p))
+}
+
+// GetClusterPromotions godoc
+// @Summary List promotions to primary
+// @Description Returns the audit trail of promotions of this node from secondary to primary, newest first
+// @Tags cluster
+// @Produce json
+// @Success 200 {object} models.ClusterPromotionsResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /cluster/promotions [get]
+func (h *Handler) GetClusterPromotions(c *gin.Context) {
+	if h.db == nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "config unavailable"})
+		return
+	}
+
+	promotions, err := h.db.GetPromotions(c.Request.Context())
+	if err != nil {
+		h.logError("failed to read cluster promotions", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "failed to read promotions"})
+		return
+	}
+
+	resp := models.ClusterPromotionsResponse{Promotions: make([]models.ClusterPromotion, len(promotions))}
+	for i, p := range promotions {
+		resp.Promotions[i] = promotionModel(p)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func promotionModel(p database.Promotion) models.ClusterPromotion {
+	return models.ClusterPromotion{
+		ID:                 p.ID,
+		NodeID:             p.NodeID,
+		PreviousPrimaryURL: p.PreviousPrimaryURL,
+		Reason:             p.Reason,
+		Forced:             p.Forced,
+		RequestedBy:        p.RequestedBy,
+		ClientIP:           p.ClientIP,
+		ConfigVersion:      p.ConfigVersion,
+		CreatedAt:          p.CreatedAt,
+	}
+}
+
 // PutClusterConfig godoc
 // @Summary Configure cluster settings
 // @Description Sets the cluster mode and configuration for this node
@@ -234,7 +648,9 @@ func (h *Handler) PutClusterConfig(c *gin.Context) {
 	}
 
 	// Update in-memory config
+	h.mu.Lock()
 	h.cfg.Cluster = *clusterCfg
+	h.mu.Unlock()
 
 	h.logger.Info("cluster configuration updated",
 		"mode", req.Mode,
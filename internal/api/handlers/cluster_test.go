@@ -8,14 +8,18 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jroosing/hydradns/internal/api/handlers"
 	"github.com/jroosing/hydradns/internal/api/models"
+	"github.com/jroosing/hydradns/internal/cluster"
 	"github.com/jroosing/hydradns/internal/config"
 	"github.com/jroosing/hydradns/internal/database"
+	"github.com/jroosing/hydradns/internal/filtering"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -450,3 +454,496 @@ func TestClusterConfig_PersistsToDatabase(t *testing.T) {
 	assert.Equal(t, "secret123", savedCfg.SharedSecret)
 	assert.Equal(t, "5m", savedCfg.SyncInterval)
 }
+
+// ============================================================================
+// Local Records Tests
+// ============================================================================
+
+// primaryExport returns cluster export data as a primary would send it.
+func primaryExport() *cluster.ExportData {
+	return &cluster.ExportData{
+		Version: 7,
+		Upstream: config.UpstreamConfig{
+			Servers:    []string{"1.1.1.1"},
+			UDPTimeout: "3s",
+			TCPTimeout: "5s",
+			MaxRetries: 3,
+		},
+		CustomDNS: config.CustomDNSConfig{
+			Hosts: map[string][]string{
+				"nas.home":    {"10.0.0.1"},
+				"router.home": {"10.9.9.9"},
+			},
+			CNAMEs: map[string]string{"www.home": "nas.home"},
+		},
+		Filtering: config.FilteringConfig{
+			BlacklistDomains: []string{"ads.example"},
+		},
+	}
+}
+
+// createSecondaryTestHandler returns a secondary's handler and database,
+// synced once from primaryExport.
+func createSecondaryTestHandler(t *testing.T) (*handlers.Handler, *database.DB, *gin.Engine) {
+	h := createClusterTestHandler(t, config.ClusterModeSecondary)
+	db := h.DB()
+
+	data := primaryExport()
+	require.NoError(t, db.ImportFromCluster(context.Background(), data))
+	h.SetCustomDNS(data.CustomDNS)
+
+	router := gin.New()
+	router.GET("/custom-dns", h.ListCustomDNS)
+	router.POST("/custom-dns/hosts", h.AddHost)
+	router.PUT("/custom-dns/hosts/:name", h.UpdateHost)
+	router.DELETE("/custom-dns/hosts/:name", h.DeleteHost)
+	router.DELETE("/custom-dns/cnames/:alias", h.DeleteCNAME)
+	router.POST("/filtering/whitelist", h.AddWhitelist)
+	router.GET("/filtering/blacklist", h.GetBlacklist)
+	router.POST("/filtering/blacklist", h.AddBlacklist)
+	router.DELETE("/filtering/blacklist", h.RemoveBlacklist)
+	return h, db, router
+}
+
+func TestLocalRecords_SurviveClusterImport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	_, db, router := createSecondaryTestHandler(t)
+	ctx := context.Background()
+
+	w := clusterPerformRequest(router, http.MethodPost, "/custom-dns/hosts",
+		`{"name":"printer.home","ips":["10.0.0.50"]}`, nil)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	w = clusterPerformRequest(router, http.MethodPut, "/custom-dns/hosts/router.home",
+		`{"ips":["10.0.0.254"]}`, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = clusterPerformRequest(router, http.MethodPost, "/filtering/whitelist",
+		`{"domains":["intranet.example"]}`, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// Sync again: synced rows are replaced, local ones kept
+	require.NoError(t, db.ImportFromCluster(ctx, primaryExport()))
+
+	hosts, err := db.GetAllHosts(ctx)
+	require.NoError(t, err)
+	got := make(map[string]database.CustomDNSHost)
+	for _, host := range hosts {
+		got[host.Hostname+" "+host.IPAddress] = host
+	}
+	assert.Len(t, got, 3)
+	assert.False(t, got["nas.home 10.0.0.1"].Local)
+	assert.True(t, got["printer.home 10.0.0.50"].Local)
+	assert.True(t, got["router.home 10.0.0.254"].Local, "local override must win over the primary's record")
+	assert.NotContains(t, got, "router.home 10.9.9.9")
+
+	whitelist, err := db.GetWhitelistDomains(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"intranet.example"}, whitelist)
+
+	blacklist, err := db.GetBlacklistDomains(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ads.example"}, blacklist)
+}
+
+func TestLocalRecords_ListReportsLocalNames(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	_, _, router := createSecondaryTestHandler(t)
+
+	w := clusterPerformRequest(router, http.MethodPost, "/custom-dns/hosts",
+		`{"name":"printer.home","ips":["10.0.0.50"]}`, nil)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	w = clusterPerformRequest(router, http.MethodGet, "/custom-dns", "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp models.CustomDNSRecordsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 3, resp.Count.Hosts)
+	assert.Equal(t, []string{"printer.home"}, resp.Local)
+}
+
+func TestLocalRecords_SecondaryDeletesOnlyLocal(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	_, _, router := createSecondaryTestHandler(t)
+
+	// Synced records and domains are read-only
+	w := clusterPerformRequest(router, http.MethodDelete, "/custom-dns/hosts/nas.home", "", nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = clusterPerformRequest(router, http.MethodDelete, "/custom-dns/cnames/www.home", "", nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = clusterPerformRequest(router, http.MethodDelete, "/filtering/blacklist",
+		`{"domains":["ads.example"]}`, nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	var resp models.ReadOnlyErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Contains(t, resp.Error, "ads.example")
+
+	// Local entries can be removed
+	w = clusterPerformRequest(router, http.MethodPut, "/custom-dns/hosts/nas.home", `{"ips":["10.0.0.2"]}`, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	w = clusterPerformRequest(router, http.MethodDelete, "/custom-dns/hosts/nas.home", "", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = clusterPerformRequest(router, http.MethodPost, "/filtering/blacklist",
+		`{"domains":["tracker.example"]}`, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var list models.DomainListResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Equal(t, []string{"tracker.example"}, list.Local)
+
+	w = clusterPerformRequest(router, http.MethodDelete, "/filtering/blacklist",
+		`{"domains":["tracker.example"]}`, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestLocalRecords_PrimaryRecordsAreNotLocal(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := createClusterTestHandler(t, config.ClusterModePrimary)
+
+	router := gin.New()
+	router.POST("/custom-dns/hosts", h.AddHost)
+	router.POST("/filtering/whitelist", h.AddWhitelist)
+
+	w := clusterPerformRequest(router, http.MethodPost, "/custom-dns/hosts",
+		`{"name":"nas.home","ips":["10.0.0.1"]}`, nil)
+	require.Equal(t, http.StatusCreated, w.Code)
+	w = clusterPerformRequest(router, http.MethodPost, "/filtering/whitelist",
+		`{"domains":["intranet.example"]}`, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var list models.DomainListResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Empty(t, list.Local)
+
+	names, err := h.DB().GetLocalCustomDNSNames(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, names)
+}
+
+// ============================================================================
+// Section Drift Tests
+// ============================================================================
+
+// getSections returns the section statuses reported by GET /cluster/status.
+func getSections(t *testing.T, h *handlers.Handler) map[string]models.ClusterSectionStatus {
+	router := gin.New()
+	router.GET("/cluster/status", h.GetClusterStatus)
+
+	w := clusterPerformRequest(router, http.MethodGet, "/cluster/status", "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp models.ClusterStatusResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+	sections := make(map[string]models.ClusterSectionStatus)
+	for _, s := range resp.Sections {
+		sections[s.Name] = s
+	}
+	return sections
+}
+
+func TestClusterExport_IncludesSectionHashes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := createClusterTestHandler(t, config.ClusterModePrimary)
+	router := gin.New()
+	router.GET("/cluster/export", h.GetClusterExport)
+
+	w := clusterPerformRequest(router, http.MethodGet, "/cluster/export", "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var data cluster.ExportData
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &data))
+	assert.Equal(t, data.SectionHashes(), data.Sections)
+
+	// The primary reports the hashes it exports, without drift fields
+	sections := getSections(t, h)
+	require.Len(t, sections, 3)
+	for name, s := range sections {
+		assert.Equal(t, data.Sections[name], s.Hash, name)
+		assert.Empty(t, s.AppliedHash, name)
+		assert.False(t, s.OutOfSync || s.LocallyModified, name)
+	}
+}
+
+func TestGetClusterStatus_SecondarySectionDrift(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h, _, router := createSecondaryTestHandler(t)
+	data := primaryExport()
+
+	// Freshly synced: the database matches what was applied
+	sections := getSections(t, h)
+	require.Len(t, sections, 3)
+	for name, s := range sections {
+		assert.Equal(t, data.SectionHashes()[name], s.Hash, name)
+		assert.Equal(t, s.Hash, s.AppliedHash, name)
+		assert.Equal(t, data.Version, s.AppliedVersion, name)
+		assert.False(t, s.LocallyModified, name)
+	}
+
+	// A local record modifies only the custom DNS section
+	w := clusterPerformRequest(router, http.MethodPost, "/custom-dns/hosts",
+		`{"name":"printer.home","ips":["10.0.0.50"]}`, nil)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	sections = getSections(t, h)
+	assert.True(t, sections[cluster.SectionCustomDNS].LocallyModified)
+	assert.False(t, sections[cluster.SectionFiltering].LocallyModified)
+	assert.False(t, sections[cluster.SectionUpstream].LocallyModified)
+}
+
+func TestImportFromCluster_KeepsVersionOfUnchangedSections(t *testing.T) {
+	h := createClusterTestHandler(t, config.ClusterModeSecondary)
+	db := h.DB()
+	ctx := context.Background()
+
+	data := primaryExport()
+	require.NoError(t, db.ImportFromCluster(ctx, data))
+
+	data.Version = 9
+	data.Filtering.WhitelistDomains = []string{"intranet.example"}
+	require.NoError(t, db.ImportFromCluster(ctx, data))
+
+	applied, err := db.GetAppliedSections(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(9), applied[cluster.SectionFiltering].Version)
+	assert.Equal(t, int64(7), applied[cluster.SectionUpstream].Version)
+	assert.Equal(t, int64(7), applied[cluster.SectionCustomDNS].Version)
+	assert.Equal(t, data.SectionHashes()[cluster.SectionFiltering], applied[cluster.SectionFiltering].Hash)
+}
+
+// ============================================================================
+// Incremental Sync Tests
+// ============================================================================
+
+func TestClusterChanges_SyncsIncrementally(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	primary := createClusterTestHandler(t, config.ClusterModePrimary)
+	router := gin.New()
+	router.GET("/cluster/export", primary.GetClusterExport)
+	router.GET("/cluster/changes", primary.GetClusterChanges)
+	router.POST("/custom-dns/hosts", primary.AddHost)
+	secondary := createClusterTestHandler(t, config.ClusterModeSecondary).DB()
+	ctx := context.Background()
+
+	w := clusterPerformRequest(router, http.MethodGet, "/cluster/export", "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var data cluster.ExportData
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &data))
+	require.NotEmpty(t, data.Journal)
+	require.NoError(t, secondary.ImportFromCluster(ctx, &data))
+
+	w = clusterPerformRequest(router, http.MethodPost, "/custom-dns/hosts",
+		`{"name":"printer.home","ips":["10.0.0.50"]}`, nil)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	getChanges := func(journal string, since int64) *httptest.ResponseRecorder {
+		return clusterPerformRequest(router, http.MethodGet,
+			"/cluster/changes?journal="+journal+"&since="+strconv.FormatInt(since, 10), "", nil)
+	}
+	w = getChanges(data.Journal, data.Seq)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var changes cluster.Changes
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &changes))
+	require.Len(t, changes.Changes, 1)
+	assert.Equal(t, cluster.ChangeHost, changes.Changes[0].Kind)
+	assert.Equal(t, "printer.home", changes.Changes[0].Key)
+
+	require.NoError(t, secondary.ApplyClusterChanges(ctx, &changes))
+	cfg, err := secondary.ExportToConfig(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.50"}, cfg.CustomDNS.Hosts["printer.home"])
+	synced := (&cluster.ExportData{Upstream: cfg.Upstream, CustomDNS: cfg.CustomDNS, Filtering: cfg.Filtering}).SectionHashes()
+	assert.Equal(t, changes.Sections, synced, "the secondary matches the primary after applying the changes")
+
+	w = getChanges(changes.Journal, changes.Seq)
+	require.Equal(t, http.StatusOK, w.Code)
+	var none cluster.Changes
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &none))
+	assert.Empty(t, none.Changes)
+
+	assert.Equal(t, http.StatusGone, getChanges("other-journal", 0).Code, "unknown journal needs a full export")
+	w = clusterPerformRequest(router, http.MethodGet, "/cluster/changes", "", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestClusterChanges_SyncTemporaryAllows(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	primary := createClusterTestHandler(t, config.ClusterModePrimary)
+	pe := filtering.NewPolicyEngine(filtering.PolicyEngineConfig{Enabled: true})
+	defer pe.Close()
+	primary.SetPolicyEngine(pe)
+	router := gin.New()
+	router.GET("/cluster/export", primary.GetClusterExport)
+	router.GET("/cluster/changes", primary.GetClusterChanges)
+	router.POST("/filtering/allow-temporarily", primary.AllowTemporarily)
+	secondary := createClusterTestHandler(t, config.ClusterModeSecondary).DB()
+	ctx := context.Background()
+
+	// A full sync carries existing allows
+	w := clusterPerformRequest(router, http.MethodPost, "/filtering/allow-temporarily",
+		`{"domain":"a.example","duration":"1h"}`, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = clusterPerformRequest(router, http.MethodGet, "/cluster/export", "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var data cluster.ExportData
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &data))
+	require.NoError(t, secondary.ImportFromCluster(ctx, &data))
+	allows, err := secondary.GetTemporaryAllows(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, allows, "a.example")
+
+	// Later allows are synced incrementally
+	w = clusterPerformRequest(router, http.MethodPost, "/filtering/allow-temporarily",
+		`{"domain":"b.example","duration":"10m"}`, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp models.TemporaryAllowResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+	w = clusterPerformRequest(router, http.MethodGet,
+		"/cluster/changes?journal="+data.Journal+"&since="+strconv.FormatInt(data.Seq, 10), "", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var changes cluster.Changes
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &changes))
+	require.Len(t, changes.Changes, 1)
+	assert.Equal(t, cluster.ChangeTemporaryAllow, changes.Changes[0].Kind)
+	require.NoError(t, secondary.ApplyClusterChanges(ctx, &changes))
+
+	cfg, err := secondary.ExportToConfig(ctx)
+	require.NoError(t, err)
+	assert.True(t, resp.ExpiresAt.Equal(cfg.Filtering.TemporaryAllows["b.example"]))
+	assert.Empty(t, cfg.Filtering.WhitelistDomains, "temporary allows are not whitelisted")
+	synced := (&cluster.ExportData{Upstream: cfg.Upstream, CustomDNS: cfg.CustomDNS, Filtering: cfg.Filtering}).SectionHashes()
+	assert.Equal(t, changes.Sections, synced)
+}
+
+// ============================================================================
+// Promotion Tests
+// ============================================================================
+
+// createPromotionTestHandler returns a secondary's handler, synced once from
+// primaryExport, whose primary is primaryURL.
+func createPromotionTestHandler(t *testing.T, primaryURL string) (*handlers.Handler, *gin.Engine) {
+	h, _, _ := createSecondaryTestHandler(t)
+	router := gin.New()
+	router.PUT("/cluster/config", h.PutClusterConfig)
+	router.GET("/cluster/config", h.GetClusterConfig)
+	router.POST("/cluster/promote", h.PromoteCluster)
+	router.GET("/cluster/promotions", h.GetClusterPromotions)
+
+	w := clusterPerformRequest(router, http.MethodPut, "/cluster/config",
+		`{"mode":"secondary","node_id":"secondary-1","primary_url":"`+primaryURL+`","sync_timeout":"2s"}`, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	return h, router
+}
+
+func TestPromoteCluster_PromotesSecondary(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	lost := httptest.NewServer(http.NotFoundHandler())
+	lost.Close()
+	h, router := createPromotionTestHandler(t, lost.URL)
+	ctx := context.Background()
+	before, err := h.DB().GetVersion(ctx)
+	require.NoError(t, err)
+
+	w := clusterPerformRequest(router, http.MethodPost, "/cluster/promote", `{"reason":"primary lost"}`, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "confirmation is required")
+
+	w = clusterPerformRequest(router, http.MethodPost, "/cluster/promote", `{"confirm":true,"reason":"primary lost"}`, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var p models.ClusterPromotion
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+	assert.Equal(t, lost.URL, p.PreviousPrimaryURL)
+	assert.Equal(t, "admin", p.RequestedBy)
+	assert.False(t, p.Forced)
+	assert.Greater(t, p.ConfigVersion, before)
+
+	stored, err := h.DB().GetClusterConfig(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, config.ClusterModePrimary, stored.Mode)
+	assert.Empty(t, stored.PrimaryURL)
+
+	w = clusterPerformRequest(router, http.MethodGet, "/cluster/config", "", nil)
+	var resp models.ClusterConfigRequest
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "primary", resp.Mode)
+
+	w = clusterPerformRequest(router, http.MethodGet, "/cluster/promotions", "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var list models.ClusterPromotionsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list.Promotions, 1)
+	assert.Equal(t, "primary lost", list.Promotions[0].Reason)
+
+	w = clusterPerformRequest(router, http.MethodPost, "/cluster/promote", `{"confirm":true}`, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code, "a primary cannot be promoted")
+}
+
+func TestPromoteCluster_RefusesWhilePrimaryServes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(primaryExport())
+	}))
+	defer primary.Close()
+	_, router := createPromotionTestHandler(t, primary.URL)
+
+	w := clusterPerformRequest(router, http.MethodPost, "/cluster/promote", `{"confirm":true}`, nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = clusterPerformRequest(router, http.MethodPost, "/cluster/promote", `{"confirm":true,"force":true}`, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var p models.ClusterPromotion
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+	assert.True(t, p.Forced)
+}
+
+func TestPromoteCluster_RefusesNeverSyncedNode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := createClusterTestHandler(t, config.ClusterModeSecondary)
+	router := gin.New()
+	router.POST("/cluster/promote", h.PromoteCluster)
+
+	w := clusterPerformRequest(router, http.MethodPost, "/cluster/promote", `{"confirm":true}`, nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+// ============================================================================
+// Cluster Membership Tests
+// ============================================================================
+
+func TestClusterExport_RecordsMembers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := createClusterTestHandler(t, config.ClusterModePrimary)
+	h.SetClusterMembers(cluster.NewMembers(cluster.Member{
+		NodeID:  "test-node-1",
+		Role:    config.ClusterModePrimary,
+		Address: "192.0.2.53",
+		DNSPort: 53,
+	}, time.Minute))
+	router := gin.New()
+	router.GET("/cluster/export", h.GetClusterExport)
+	router.GET("/cluster/status", h.GetClusterStatus)
+
+	w := clusterPerformRequest(router, http.MethodGet, "/cluster/export", "", map[string]string{
+		"X-Node-Id":       "secondary-1",
+		"X-Node-Dns-Port": "5353",
+		"X-Node-Api-Port": "not-a-port",
+	})
+	require.Equal(t, http.StatusOK, w.Code)
+	var data cluster.ExportData
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &data))
+	require.Len(t, data.Members, 2)
+	assert.Equal(t, "test-node-1", data.Members[0].NodeID)
+	secondary := data.Members[1]
+	assert.Equal(t, "secondary-1", secondary.NodeID)
+	assert.Equal(t, config.ClusterModeSecondary, secondary.Role)
+	assert.Equal(t, "192.0.2.1", secondary.Address, "the request's address is used without X-Node-Address")
+	assert.Equal(t, 5353, secondary.DNSPort)
+	assert.Zero(t, secondary.APIPort)
+
+	w = clusterPerformRequest(router, http.MethodGet, "/cluster/status", "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var status models.ClusterStatusResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	require.Len(t, status.Members, 2)
+	assert.Equal(t, "secondary", status.Members[1].Role)
+}
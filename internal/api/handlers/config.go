@@ -5,6 +5,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/jroosing/hydradns/internal/api/models"
+	"github.com/jroosing/hydradns/internal/config"
 )
 
 // GetConfig godoc
@@ -31,16 +32,51 @@ func (h *Handler) GetConfig(c *gin.Context) {
 			UpstreamSocketPoolSize: h.cfg.Server.UpstreamSocketPoolSize,
 			EnableTCP:              h.cfg.Server.EnableTCP,
 			TCPFallback:            h.cfg.Server.TCPFallback,
+			MaxCNAMEChain:          h.cfg.Server.MaxCNAMEChain,
+			UDPListeners:           h.cfg.Server.UDPListeners,
+			UDPRecvBuffer:          h.cfg.Server.UDPRecvBuffer,
+			UDPSendBuffer:          h.cfg.Server.UDPSendBuffer,
+			TCPReadTimeout:         h.cfg.Server.TCPReadTimeout,
+			TCPIdleTimeout:         h.cfg.Server.TCPIdleTimeout,
+			TCPMaxConnsPerIP:       h.cfg.Server.TCPMaxConnsPerIP,
+			TCPMaxQueriesPerConn:   h.cfg.Server.TCPMaxQueriesPerConn,
+			TCPListeners:           h.cfg.Server.TCPListeners,
+			ShutdownDrain:          h.cfg.Server.ShutdownDrain,
+			ProxyProtocolTrusted:   h.cfg.Server.ProxyProtocolTrusted,
+			RecursionClients:       h.cfg.Server.RecursionClients,
+			ListenerProfiles:       h.cfg.Server.ListenerProfiles,
+			QTypeRules:             h.cfg.Server.QTypeRules,
 		},
 		Upstream:  h.cfg.Upstream,
 		CustomDNS: h.cfg.CustomDNS,
+		Cache:     cacheConfigResponse(h.cfg.Cache),
 		Logging:   h.cfg.Logging,
 		Filtering: h.cfg.Filtering,
+		BlockPage: h.cfg.BlockPage,
+		GeoIP:     h.cfg.GeoIP,
+		Anomaly:   h.cfg.Anomaly,
+		ThreatIntel: models.ThreatIntelConfigResponse{
+			Enabled:   h.cfg.ThreatIntel.Enabled,
+			Provider:  h.cfg.ThreatIntel.Provider,
+			URL:       h.cfg.ThreatIntel.URL,
+			BlockTTL:  h.cfg.ThreatIntel.BlockTTL,
+			CacheTTL:  h.cfg.ThreatIntel.CacheTTL,
+			RateLimit: h.cfg.ThreatIntel.RateLimit,
+		},
+		NRD:       h.cfg.NRD,
+		Identity:  h.cfg.Identity,
+		Audit:     h.cfg.Audit,
+		Canary:    h.cfg.Canary,
+		Shadow:    h.cfg.Shadow,
+		Advertise: advertiseConfigResponse(h.cfg.Advertise),
 		RateLimit: h.cfg.RateLimit,
 		API: models.APIConfigResponse{
-			Enabled: h.cfg.API.Enabled,
-			Host:    h.cfg.API.Host,
-			Port:    h.cfg.API.Port,
+			Enabled:        h.cfg.API.Enabled,
+			Host:           h.cfg.API.Host,
+			Port:           h.cfg.API.Port,
+			RateLimitRPS:   h.cfg.API.RateLimitRPS,
+			RateLimitBurst: h.cfg.API.RateLimitBurst,
+			MaxBodyBytes:   h.cfg.API.MaxBodyBytes,
 		},
 		Cluster: models.ClusterConfigResponse{
 			Mode:         string(h.cfg.Cluster.Mode),
@@ -54,6 +90,20 @@ func (h *Handler) GetConfig(c *gin.Context) {
 	c.JSON(http.StatusOK, resp)
 }
 
+// cacheConfigResponse returns the cache config without the shared cache
+// password.
+func cacheConfigResponse(cfg config.CacheConfig) config.CacheConfig {
+	cfg.Shared.Password = ""
+	return cfg
+}
+
+// advertiseConfigResponse returns the advertise config without the webhook
+// secret.
+func advertiseConfigResponse(cfg config.AdvertiseConfig) config.AdvertiseConfig {
+	cfg.WebhookSecret = ""
+	return cfg
+}
+
 // PutConfig godoc
 // @Summary Update configuration
 // @Description Updates server configuration (requires restart for some settings)
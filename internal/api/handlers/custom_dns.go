@@ -5,10 +5,13 @@ import (
 	"maps"
 	"net/http"
 	"net/netip"
+	"slices"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jroosing/hydradns/internal/api/models"
+	"github.com/jroosing/hydradns/internal/config"
+	"github.com/jroosing/hydradns/internal/dns"
 )
 
 // ListCustomDNS returns all custom DNS records (hosts and CNAMEs).
@@ -21,6 +24,15 @@ import (
 // @Failure 500 {object} models.ErrorResponse
 // @Router /custom-dns [get]
 func (h *Handler) ListCustomDNS(c *gin.Context) {
+	var local []string
+	if h.db != nil {
+		var err error
+		if local, err = h.db.GetLocalCustomDNSNames(c.Request.Context()); err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+			return
+		}
+	}
+
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
@@ -38,6 +50,7 @@ func (h *Handler) ListCustomDNS(c *gin.Context) {
 			CNAMEs: len(cnames),
 			Total:  len(hosts) + len(cnames),
 		},
+		Local: local,
 	}
 
 	c.JSON(http.StatusOK, resp)
@@ -70,18 +83,25 @@ func (h *Handler) AddHost(c *gin.Context) {
 		return
 	}
 
+	name, err := canonicalName(name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
 	// Validate IPs
 	if err := validateIPs(req.IPs); err != nil {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
 		return
 	}
 
+	local := h.isSecondary()
 	h.mu.Lock()
 
-	// Check if host already exists
-	if _, exists := h.cfg.CustomDNS.Hosts[name]; exists {
+	// Check if host already exists, in any spelling
+	if existing, exists := findName(h.cfg.CustomDNS.Hosts, name); exists {
 		h.mu.Unlock()
-		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Host already exists: " + name})
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Host already exists: " + existing})
 		return
 	}
 
@@ -97,8 +117,9 @@ func (h *Handler) AddHost(c *gin.Context) {
 
 	// Persist to database
 	ctx := context.Background()
+	h.saveCustomDNSVersion(ctx, "add host "+name)
 	for _, ip := range req.IPs {
-		if err := h.db.AddHost(ctx, name, ip); err != nil {
+		if err := h.db.AddHost(ctx, name, ip, local); err != nil {
 			c.JSON(
 				http.StatusInternalServerError,
 				models.ErrorResponse{Error: "Failed to persist host: " + err.Error()},
@@ -137,6 +158,12 @@ func (h *Handler) UpdateHost(c *gin.Context) {
 		return
 	}
 
+	name, err := canonicalName(name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
 	var req models.UpdateHostRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request: " + err.Error()})
@@ -149,16 +176,20 @@ func (h *Handler) UpdateHost(c *gin.Context) {
 		return
 	}
 
+	// On a secondary the update becomes a local override of the synced host
+	local := h.isSecondary()
 	h.mu.Lock()
 
 	// Check if host exists
-	if _, exists := h.cfg.CustomDNS.Hosts[name]; !exists {
+	key, exists := findName(h.cfg.CustomDNS.Hosts, name)
+	if !exists {
 		h.mu.Unlock()
 		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Host not found: " + name})
 		return
 	}
 
-	// Update the host
+	// Update the host, storing it under the canonical name
+	delete(h.cfg.CustomDNS.Hosts, key)
 	h.cfg.CustomDNS.Hosts[name] = req.IPs
 
 	// Get reload function before releasing lock
@@ -167,12 +198,13 @@ func (h *Handler) UpdateHost(c *gin.Context) {
 
 	// Persist to database: delete existing and add new
 	ctx := context.Background()
-	if err := h.db.DeleteAllHostsForHostname(ctx, name); err != nil {
+	h.saveCustomDNSVersion(ctx, "update host "+name)
+	if err := h.db.DeleteAllHostsForHostname(ctx, key); err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to update host: " + err.Error()})
 		return
 	}
 	for _, ip := range req.IPs {
-		if err := h.db.AddHost(ctx, name, ip); err != nil {
+		if err := h.db.AddHost(ctx, name, ip, local); err != nil {
 			c.JSON(
 				http.StatusInternalServerError,
 				models.ErrorResponse{Error: "Failed to persist host: " + err.Error()},
@@ -200,6 +232,7 @@ func (h *Handler) UpdateHost(c *gin.Context) {
 // @Success 200 {object} models.CustomDNSOperationResponse
 // @Failure 404 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
+// @Failure 409 {object} models.ReadOnlyErrorResponse "Record is synced from the primary"
 // @Router /custom-dns/hosts/{name} [delete]
 func (h *Handler) DeleteHost(c *gin.Context) {
 	name := c.Param("name")
@@ -208,17 +241,28 @@ func (h *Handler) DeleteHost(c *gin.Context) {
 		return
 	}
 
+	name, err := canonicalName(name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if h.abortIfSynced(c, name) {
+		return
+	}
+
 	h.mu.Lock()
 
 	// Check if host exists
-	if _, exists := h.cfg.CustomDNS.Hosts[name]; !exists {
+	key, exists := findName(h.cfg.CustomDNS.Hosts, name)
+	if !exists {
 		h.mu.Unlock()
 		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Host not found: " + name})
 		return
 	}
 
 	// Delete the host
-	delete(h.cfg.CustomDNS.Hosts, name)
+	delete(h.cfg.CustomDNS.Hosts, key)
 
 	// Get reload function before releasing lock
 	reloadFunc := h.customDNSReloadFunc
@@ -226,7 +270,8 @@ func (h *Handler) DeleteHost(c *gin.Context) {
 
 	// Persist to database
 	ctx := context.Background()
-	if err := h.db.DeleteAllHostsForHostname(ctx, name); err != nil {
+	h.saveCustomDNSVersion(ctx, "delete host "+key)
+	if err := h.db.DeleteAllHostsForHostname(ctx, key); err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to delete host: " + err.Error()})
 		return
 	}
@@ -267,12 +312,24 @@ func (h *Handler) AddCNAME(c *gin.Context) {
 		return
 	}
 
+	alias, err := canonicalName(alias)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	target, err = canonicalName(target)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	local := h.isSecondary()
 	h.mu.Lock()
 
-	// Check if CNAME already exists
-	if _, exists := h.cfg.CustomDNS.CNAMEs[alias]; exists {
+	// Check if CNAME already exists, in any spelling
+	if existing, exists := findName(h.cfg.CustomDNS.CNAMEs, alias); exists {
 		h.mu.Unlock()
-		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "CNAME already exists: " + alias})
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "CNAME already exists: " + existing})
 		return
 	}
 
@@ -288,7 +345,8 @@ func (h *Handler) AddCNAME(c *gin.Context) {
 
 	// Persist to database
 	ctx := context.Background()
-	if err := h.db.AddCNAME(ctx, alias, target); err != nil {
+	h.saveCustomDNSVersion(ctx, "add CNAME "+alias)
+	if err := h.db.AddCNAME(ctx, alias, target, local); err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to persist CNAME: " + err.Error()})
 		return
 	}
@@ -323,6 +381,12 @@ func (h *Handler) UpdateCNAME(c *gin.Context) {
 		return
 	}
 
+	alias, err := canonicalName(alias)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
 	var req models.UpdateCNAMERequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request: " + err.Error()})
@@ -335,16 +399,26 @@ func (h *Handler) UpdateCNAME(c *gin.Context) {
 		return
 	}
 
+	target, err = canonicalName(target)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	// On a secondary the update becomes a local override of the synced CNAME
+	local := h.isSecondary()
 	h.mu.Lock()
 
 	// Check if CNAME exists
-	if _, exists := h.cfg.CustomDNS.CNAMEs[alias]; !exists {
+	key, exists := findName(h.cfg.CustomDNS.CNAMEs, alias)
+	if !exists {
 		h.mu.Unlock()
 		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "CNAME not found: " + alias})
 		return
 	}
 
-	// Update the CNAME
+	// Update the CNAME, storing it under the canonical alias
+	delete(h.cfg.CustomDNS.CNAMEs, key)
 	h.cfg.CustomDNS.CNAMEs[alias] = target
 
 	// Get reload function before releasing lock
@@ -353,7 +427,14 @@ func (h *Handler) UpdateCNAME(c *gin.Context) {
 
 	// Persist to database (AddCNAME replaces existing)
 	ctx := context.Background()
-	if err := h.db.AddCNAME(ctx, alias, target); err != nil {
+	h.saveCustomDNSVersion(ctx, "update CNAME "+alias)
+	if key != alias {
+		if err := h.db.DeleteCNAME(ctx, key); err != nil && !strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to update CNAME: " + err.Error()})
+			return
+		}
+	}
+	if err := h.db.AddCNAME(ctx, alias, target, local); err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to update CNAME: " + err.Error()})
 		return
 	}
@@ -377,6 +458,7 @@ func (h *Handler) UpdateCNAME(c *gin.Context) {
 // @Success 200 {object} models.CustomDNSOperationResponse
 // @Failure 404 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
+// @Failure 409 {object} models.ReadOnlyErrorResponse "Record is synced from the primary"
 // @Router /custom-dns/cnames/{alias} [delete]
 func (h *Handler) DeleteCNAME(c *gin.Context) {
 	alias := c.Param("alias")
@@ -385,17 +467,28 @@ func (h *Handler) DeleteCNAME(c *gin.Context) {
 		return
 	}
 
+	alias, err := canonicalName(alias)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if h.abortIfSynced(c, alias) {
+		return
+	}
+
 	h.mu.Lock()
 
 	// Check if CNAME exists
-	if _, exists := h.cfg.CustomDNS.CNAMEs[alias]; !exists {
+	key, exists := findName(h.cfg.CustomDNS.CNAMEs, alias)
+	if !exists {
 		h.mu.Unlock()
 		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "CNAME not found: " + alias})
 		return
 	}
 
 	// Delete the CNAME
-	delete(h.cfg.CustomDNS.CNAMEs, alias)
+	delete(h.cfg.CustomDNS.CNAMEs, key)
 
 	// Get reload function before releasing lock
 	reloadFunc := h.customDNSReloadFunc
@@ -403,7 +496,8 @@ func (h *Handler) DeleteCNAME(c *gin.Context) {
 
 	// Persist to database (best-effort: ignore "not found" since record may only exist in-memory)
 	ctx := context.Background()
-	if err := h.db.DeleteCNAME(ctx, alias); err != nil && !strings.Contains(err.Error(), "not found") {
+	h.saveCustomDNSVersion(ctx, "delete CNAME "+key)
+	if err := h.db.DeleteCNAME(ctx, key); err != nil && !strings.Contains(err.Error(), "not found") {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to delete CNAME: " + err.Error()})
 		return
 	}
@@ -416,6 +510,38 @@ func (h *Handler) DeleteCNAME(c *gin.Context) {
 	})
 }
 
+// abortIfSynced answers 409 Conflict and reports true when this node is a
+// secondary and name has no local records. Synced records would be restored
+// by the next sync, so a secondary may only delete its local records.
+func (h *Handler) abortIfSynced(c *gin.Context, name string) bool {
+	if !h.isSecondary() {
+		return false
+	}
+
+	if h.db != nil {
+		local, err := h.db.GetLocalCustomDNSNames(c.Request.Context())
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+			return true
+		}
+		if _, ok := findName(setOf(local), name); ok {
+			return false
+		}
+	}
+
+	h.abortReadOnly(c, name+" is synced from the primary; only local records can be deleted on a secondary")
+	return true
+}
+
+// setOf returns a set holding items.
+func setOf(items []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(items))
+	for _, item := range items {
+		set[item] = struct{}{}
+	}
+	return set
+}
+
 // validateIPs checks that all provided IPs are valid IPv4 or IPv6 addresses.
 func validateIPs(ips []string) error {
 	if len(ips) == 0 {
@@ -434,6 +560,32 @@ func validateIPs(ips []string) error {
 	return nil
 }
 
+// canonicalName validates a domain name and returns its canonical form
+// (lowercase, A-labels, no trailing dot), the form custom DNS and filtering
+// entries are stored and matched in.
+func canonicalName(name string) (string, error) {
+	c, err := dns.CanonicalName(name)
+	if err != nil || c == "" {
+		return "", &ValidationError{Message: "Invalid domain name: " + name}
+	}
+	return c, nil
+}
+
+// findName returns the key in m whose canonical form is name. Records
+// written before names were canonicalized may be stored in another case
+// or with a trailing dot.
+func findName[V any](m map[string]V, name string) (string, bool) {
+	if _, ok := m[name]; ok {
+		return name, true
+	}
+	for k := range m {
+		if c, err := dns.CanonicalName(k); err == nil && c == name {
+			return k, true
+		}
+	}
+	return "", false
+}
+
 // ValidationError represents a validation error.
 type ValidationError struct {
 	Message string
@@ -479,3 +631,184 @@ func (h *Handler) logError(msg string, err error) {
 		h.logger.Error(msg, "err", err)
 	}
 }
+
+// ListRecordHealth godoc
+// @Summary Custom DNS address health
+// @Description Lists the health of every address of the custom DNS hosts with a health check. Unhealthy addresses are left out of answers until a probe passes again. Empty when no health checks are configured.
+// @Tags custom-dns
+// @Produce json
+// @Success 200 {object} models.RecordHealthResponse
+// @Security ApiKeyAuth
+// @Router /custom-dns/health [get]
+func (h *Handler) ListRecordHealth(c *gin.Context) {
+	resp := models.RecordHealthResponse{Records: []models.RecordHealth{}}
+
+	if fn := h.GetRecordHealthFunc(); fn != nil {
+		for _, r := range fn() {
+			rec := models.RecordHealth{
+				Name:                r.Name,
+				Addr:                r.Addr,
+				Check:               r.Check,
+				Healthy:             r.Healthy,
+				ConsecutiveFailures: r.ConsecutiveFailures,
+				Error:               r.Error,
+			}
+			if !r.CheckedAt.IsZero() {
+				rec.CheckedAt = &r.CheckedAt
+			}
+			if !r.ChangedAt.IsZero() {
+				rec.ChangedAt = &r.ChangedAt
+			}
+			resp.Records = append(resp.Records, rec)
+		}
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// ListSteering godoc
+// @Summary List custom DNS steering
+// @Description Lists the custom hosts whose answers are steered by client subnet and weight
+// @Tags custom-dns
+// @Produce json
+// @Success 200 {object} models.SteeringResponse
+// @Security ApiKeyAuth
+// @Router /custom-dns/steering [get]
+func (h *Handler) ListSteering(c *gin.Context) {
+	h.mu.RLock()
+	steering := slices.Clone(h.cfg.CustomDNS.Steering)
+	h.mu.RUnlock()
+
+	if steering == nil {
+		steering = []config.HostSteering{}
+	}
+	c.JSON(http.StatusOK, models.SteeringResponse{Steering: steering})
+}
+
+// SetSteering godoc
+// @Summary Set the steering of a custom host
+// @Description Replaces how the answers for a custom host are steered by client subnet and weight. Steering is node-local and is not synced.
+// @Tags custom-dns
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param name path string true "Host name"
+// @Param steering body models.SetSteeringRequest true "Steered addresses"
+// @Success 200 {object} models.CustomDNSOperationResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /custom-dns/steering/{name} [put]
+func (h *Handler) SetSteering(c *gin.Context) {
+	var req models.SetSteeringRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request: " + err.Error()})
+		return
+	}
+
+	s := config.HostSteering{Name: c.Param("name"), Addrs: req.Addrs}
+	if err := s.Normalize(); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	h.mu.Lock()
+
+	key, exists := findName(h.cfg.CustomDNS.Hosts, s.Name)
+	if !exists {
+		h.mu.Unlock()
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Host not found: " + s.Name})
+		return
+	}
+	for _, a := range s.Addrs {
+		if !hostHasAddr(h.cfg.CustomDNS.Hosts[key], a.Addr) {
+			h.mu.Unlock()
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: a.Addr + " is not an address of " + s.Name})
+			return
+		}
+	}
+
+	i := slices.IndexFunc(h.cfg.CustomDNS.Steering, func(e config.HostSteering) bool { return e.Name == s.Name })
+	if i >= 0 {
+		h.cfg.CustomDNS.Steering[i] = s
+	} else {
+		h.cfg.CustomDNS.Steering = append(h.cfg.CustomDNS.Steering, s)
+	}
+
+	// Get reload function before releasing lock
+	reloadFunc := h.customDNSReloadFunc
+	h.mu.Unlock()
+
+	if err := h.db.SetHostSteering(context.Background(), s); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to persist steering: " + err.Error()})
+		return
+	}
+
+	// Trigger resolver reload (outside of lock to avoid deadlock)
+	h.triggerReload(reloadFunc)
+
+	c.JSON(http.StatusOK, models.CustomDNSOperationResponse{
+		Message: "Steering updated successfully",
+		Data:    s,
+	})
+}
+
+// DeleteSteering godoc
+// @Summary Remove the steering of a custom host
+// @Description Answers all addresses of a custom host again, in answer order
+// @Tags custom-dns
+// @Produce json
+// @Security ApiKeyAuth
+// @Param name path string true "Host name"
+// @Success 200 {object} models.CustomDNSOperationResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /custom-dns/steering/{name} [delete]
+func (h *Handler) DeleteSteering(c *gin.Context) {
+	name, err := canonicalName(c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	h.mu.Lock()
+
+	i := slices.IndexFunc(h.cfg.CustomDNS.Steering, func(e config.HostSteering) bool { return e.Name == name })
+	if i < 0 {
+		h.mu.Unlock()
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "No steering for host: " + name})
+		return
+	}
+	h.cfg.CustomDNS.Steering = slices.Delete(h.cfg.CustomDNS.Steering, i, i+1)
+
+	// Get reload function before releasing lock
+	reloadFunc := h.customDNSReloadFunc
+	h.mu.Unlock()
+
+	if err := h.db.DeleteHostSteering(context.Background(), name); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to delete steering: " + err.Error()})
+		return
+	}
+
+	// Trigger resolver reload (outside of lock to avoid deadlock)
+	h.triggerReload(reloadFunc)
+
+	c.JSON(http.StatusOK, models.CustomDNSOperationResponse{
+		Message: "Steering deleted successfully",
+	})
+}
+
+// hostHasAddr reports whether ips, the addresses of a host, include addr.
+func hostHasAddr(ips []string, addr string) bool {
+	want, err := netip.ParseAddr(addr)
+	if err != nil {
+		return false
+	}
+	for _, ip := range ips {
+		if got, err := netip.ParseAddr(strings.TrimSpace(ip)); err == nil && got.Unmap() == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,418 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jroosing/hydradns/internal/api/models"
+	"github.com/jroosing/hydradns/internal/database"
+	"github.com/jroosing/hydradns/internal/dns"
+)
+
+// Formats of custom DNS exports and imports.
+const (
+	bulkFormatJSON  = "json"  // models.CustomDNSBulk
+	bulkFormatCSV   = "csv"   // type,name,value rows, e.g. "A,nas.lan,10.0.0.5"
+	bulkFormatHosts = "hosts" // /etc/hosts lines: address, then names
+)
+
+// Modes of custom DNS imports.
+const (
+	importModeMerge   = "merge"   // Imported names replace those records only
+	importModeReplace = "replace" // Imported records replace all records
+)
+
+// bulkFormat returns the format query parameter, or "" after answering 400
+// Bad Request when it is not a known format.
+func bulkFormat(c *gin.Context) string {
+	format := strings.ToLower(c.DefaultQuery("format", bulkFormatJSON))
+	switch format {
+	case bulkFormatJSON, bulkFormatCSV, bulkFormatHosts:
+		return format
+	}
+	c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "format must be json, csv, or hosts"})
+	return ""
+}
+
+// ExportCustomDNS godoc
+// @Summary Export custom DNS records
+// @Description Exports all custom DNS hosts and CNAMEs as JSON, as CSV rows of type,name,value, or as a hosts file. CNAMEs cannot be expressed in a hosts file and are left out of that format.
+// @Tags custom-dns
+// @Produce json
+// @Produce plain
+// @Security ApiKeyAuth
+// @Param format query string false "Export format: json, csv, or hosts" default(json)
+// @Success 200 {object} models.CustomDNSBulk
+// @Failure 400 {object} models.ErrorResponse
+// @Router /custom-dns/export [get]
+func (h *Handler) ExportCustomDNS(c *gin.Context) {
+	format := bulkFormat(c)
+	if format == "" {
+		return
+	}
+
+	h.mu.RLock()
+	hosts := make(map[string][]string, len(h.cfg.CustomDNS.Hosts))
+	for name, ips := range h.cfg.CustomDNS.Hosts {
+		hosts[name] = slices.Clone(ips)
+	}
+	cnames := maps.Clone(h.cfg.CustomDNS.CNAMEs)
+	h.mu.RUnlock()
+	if cnames == nil {
+		cnames = map[string]string{}
+	}
+
+	switch format {
+	case bulkFormatJSON:
+		c.JSON(http.StatusOK, models.CustomDNSBulk{Hosts: hosts, CNAMEs: cnames})
+	case bulkFormatCSV:
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		_ = w.Write([]string{"type", "name", "value"})
+		for _, name := range slices.Sorted(maps.Keys(hosts)) {
+			for _, ip := range hosts[name] {
+				_ = w.Write([]string{hostRecordType(ip), name, ip})
+			}
+		}
+		for _, alias := range slices.Sorted(maps.Keys(cnames)) {
+			_ = w.Write([]string{database.RecordTypeCNAME, alias, cnames[alias]})
+		}
+		w.Flush()
+		c.Header("Content-Disposition", `attachment; filename="custom-dns.csv"`)
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+	case bulkFormatHosts:
+		var buf bytes.Buffer
+		buf.WriteString("# HydraDNS custom DNS hosts\n")
+		for _, name := range slices.Sorted(maps.Keys(hosts)) {
+			for _, ip := range hosts[name] {
+				fmt.Fprintf(&buf, "%s\t%s\n", ip, name)
+			}
+		}
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", buf.Bytes())
+	}
+}
+
+// ImportCustomDNS godoc
+// @Summary Import custom DNS records
+// @Description Imports custom DNS hosts and CNAMEs in bulk, in the formats of the export. Every record is validated first; when any is invalid, the errors are returned and nothing is imported. In merge mode (default) imported names replace the records of the same name and other records are kept; in replace mode the import replaces all records (not allowed on a cluster secondary). With dry_run=true nothing is changed, and the response tells what would be.
+// @Tags custom-dns
+// @Accept json
+// @Accept plain
+// @Produce json
+// @Security ApiKeyAuth
+// @Param format query string false "Import format: json, csv, or hosts" default(json)
+// @Param mode query string false "merge or replace" default(merge)
+// @Param dry_run query bool false "Validate and report without importing"
+// @Param records body models.CustomDNSBulk true "Records in the given format"
+// @Success 200 {object} models.CustomDNSImportResponse
+// @Failure 400 {object} models.CustomDNSImportResponse "Invalid records; nothing imported"
+// @Failure 409 {object} models.ReadOnlyErrorResponse "Replace on a cluster secondary"
+// @Failure 500 {object} models.ErrorResponse
+// @Router /custom-dns/import [post]
+func (h *Handler) ImportCustomDNS(c *gin.Context) {
+	format := bulkFormat(c)
+	if format == "" {
+		return
+	}
+	mode := strings.ToLower(c.DefaultQuery("mode", importModeMerge))
+	if mode != importModeMerge && mode != importModeReplace {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "mode must be merge or replace"})
+		return
+	}
+	dryRun, err := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "dry_run must be true or false"})
+		return
+	}
+
+	local := h.isSecondary()
+	if local && mode == importModeReplace {
+		h.abortReadOnly(c, "replace imports would remove records synced from the primary; import on the primary or use merge")
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request: " + err.Error()})
+		return
+	}
+
+	resp := models.CustomDNSImportResponse{DryRun: dryRun, Mode: mode}
+	imp, errs := parseCustomDNSBulk(format, body)
+	if len(errs) > 0 {
+		resp.Errors = errs
+		c.JSON(http.StatusBadRequest, resp)
+		return
+	}
+	resp.Hosts = len(imp.hosts)
+	resp.CNAMEs = len(imp.cnames)
+
+	h.mu.Lock()
+	plan := planCustomDNSImport(h.cfg.CustomDNS.Hosts, h.cfg.CustomDNS.CNAMEs, imp, mode == importModeReplace)
+	resp.Added, resp.Updated, resp.Removed, resp.Unchanged = plan.added, plan.updated, plan.removed, plan.unchanged
+	if dryRun {
+		h.mu.Unlock()
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	h.cfg.CustomDNS.Hosts = plan.hosts
+	h.cfg.CustomDNS.CNAMEs = plan.cnames
+	reloadFunc := h.customDNSReloadFunc
+	h.mu.Unlock()
+
+	// Persist to database
+	ctx := context.Background()
+	h.saveCustomDNSVersion(ctx, fmt.Sprintf("import %d records (%s)", resp.Hosts+resp.CNAMEs, mode))
+	err = h.db.ImportCustomDNS(ctx, database.CustomDNSImport{
+		Replace: mode == importModeReplace,
+		Remove:  plan.remove,
+		Hosts:   imp.hosts,
+		CNAMEs:  imp.cnames,
+		Local:   local,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to import custom DNS: " + err.Error()})
+		return
+	}
+
+	// Trigger resolver reload (outside of lock to avoid deadlock)
+	h.triggerReload(reloadFunc)
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// customDNSBulk holds validated imported records under canonical names.
+type customDNSBulk struct {
+	hosts  map[string][]string
+	cnames map[string]string
+}
+
+// bulkRecord is one record of an import before validation. Type is A, AAAA,
+// CNAME, or "" for an address of either family.
+type bulkRecord struct {
+	line  int
+	typ   string
+	name  string
+	value string
+}
+
+// parseCustomDNSBulk parses and validates an import in format. It returns
+// every problem found, not just the first.
+func parseCustomDNSBulk(format string, body []byte) (customDNSBulk, []models.CustomDNSImportError) {
+	var records []bulkRecord
+	var errs []models.CustomDNSImportError
+	switch format {
+	case bulkFormatJSON:
+		var in models.CustomDNSBulk
+		if err := json.Unmarshal(body, &in); err != nil {
+			return customDNSBulk{}, []models.CustomDNSImportError{{Error: "invalid JSON: " + err.Error()}}
+		}
+		for _, name := range slices.Sorted(maps.Keys(in.Hosts)) {
+			if len(in.Hosts[name]) == 0 {
+				errs = append(errs, models.CustomDNSImportError{Name: name, Error: "at least one IP address is required"})
+			}
+			for _, ip := range in.Hosts[name] {
+				records = append(records, bulkRecord{name: name, value: ip})
+			}
+		}
+		for _, alias := range slices.Sorted(maps.Keys(in.CNAMEs)) {
+			records = append(records, bulkRecord{typ: database.RecordTypeCNAME, name: alias, value: in.CNAMEs[alias]})
+		}
+	case bulkFormatCSV:
+		r := csv.NewReader(bytes.NewReader(body))
+		r.Comment = '#'
+		r.FieldsPerRecord = -1
+		r.TrimLeadingSpace = true
+		for {
+			row, err := r.Read()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			line, _ := r.FieldPos(0)
+			if err != nil {
+				return customDNSBulk{}, append(errs, models.CustomDNSImportError{Line: line, Error: err.Error()})
+			}
+			if len(row) != 3 {
+				errs = append(errs, models.CustomDNSImportError{Line: line, Error: "expected type,name,value"})
+				continue
+			}
+			typ := strings.ToUpper(strings.TrimSpace(row[0]))
+			if typ == "TYPE" && len(records) == 0 {
+				continue // Header
+			}
+			records = append(records, bulkRecord{line: line, typ: typ, name: row[1], value: row[2]})
+		}
+	case bulkFormatHosts:
+		s := bufio.NewScanner(bytes.NewReader(body))
+		for line := 1; s.Scan(); line++ {
+			text, _, _ := strings.Cut(s.Text(), "#")
+			fields := strings.Fields(text)
+			if len(fields) == 0 {
+				continue
+			}
+			if len(fields) == 1 {
+				errs = append(errs, models.CustomDNSImportError{Line: line, Error: "expected an address followed by names"})
+				continue
+			}
+			for _, name := range fields[1:] {
+				records = append(records, bulkRecord{line: line, name: name, value: fields[0]})
+			}
+		}
+		if err := s.Err(); err != nil {
+			return customDNSBulk{}, append(errs, models.CustomDNSImportError{Error: err.Error()})
+		}
+	}
+
+	out := customDNSBulk{hosts: map[string][]string{}, cnames: map[string]string{}}
+	for _, rec := range records {
+		if err := out.add(rec); err != nil {
+			errs = append(errs, models.CustomDNSImportError{Line: rec.line, Name: rec.name, Error: err.Error()})
+		}
+	}
+	for name := range out.hosts {
+		if _, ok := out.cnames[name]; ok {
+			errs = append(errs, models.CustomDNSImportError{Name: name, Error: "name has both addresses and a CNAME"})
+		}
+	}
+	return out, errs
+}
+
+// add validates rec and adds it under its canonical name.
+func (b customDNSBulk) add(rec bulkRecord) error {
+	name, err := canonicalName(strings.TrimSpace(rec.name))
+	if err != nil {
+		return err
+	}
+
+	switch rec.typ {
+	case "", database.RecordTypeA, database.RecordTypeAAAA:
+		ip, err := netip.ParseAddr(strings.TrimSpace(rec.value))
+		if err != nil {
+			return fmt.Errorf("invalid IP address: %s", rec.value)
+		}
+		ip = ip.Unmap()
+		if rec.typ != "" && hostRecordType(ip.String()) != rec.typ {
+			return fmt.Errorf("%s is not an address for an %s record", rec.value, rec.typ)
+		}
+		if !slices.Contains(b.hosts[name], ip.String()) {
+			b.hosts[name] = append(b.hosts[name], ip.String())
+		}
+	case database.RecordTypeCNAME:
+		target, err := canonicalName(strings.TrimSpace(rec.value))
+		if err != nil {
+			return err
+		}
+		if target == name {
+			return errors.New("CNAME points to itself")
+		}
+		if prev, ok := b.cnames[name]; ok && prev != target {
+			return fmt.Errorf("conflicting CNAME targets %s and %s", prev, target)
+		}
+		b.cnames[name] = target
+	default:
+		return fmt.Errorf("unsupported record type %q (want A, AAAA, or CNAME)", rec.typ)
+	}
+	return nil
+}
+
+// customDNSImportPlan is the outcome of an import: the resulting records,
+// the stored names to remove, and what changes.
+type customDNSImportPlan struct {
+	hosts  map[string][]string
+	cnames map[string]string
+	// remove are stored spellings of imported names that differ from
+	// their canonical name.
+	remove                             []string
+	added, updated, removed, unchanged int
+}
+
+// planCustomDNSImport works out the records after importing imp into the
+// current hosts and cnames, replacing all of them when replace is set.
+func planCustomDNSImport(hosts map[string][]string, cnames map[string]string, imp customDNSBulk, replace bool) customDNSImportPlan {
+	plan := customDNSImportPlan{hosts: map[string][]string{}, cnames: map[string]string{}}
+	if !replace {
+		for name, ips := range hosts {
+			plan.hosts[name] = ips
+		}
+		maps.Copy(plan.cnames, cnames)
+	}
+
+	// Drop every current record of an imported name, in any spelling
+	imported := make(map[string]bool, len(imp.hosts)+len(imp.cnames))
+	for name := range imp.hosts {
+		imported[name] = true
+	}
+	for name := range imp.cnames {
+		imported[name] = true
+	}
+	for name := range imported {
+		hostKey, isHost := findName(hosts, name)
+		cnameKey, isCNAME := findName(cnames, name)
+		switch {
+		case !isHost && !isCNAME:
+			plan.added++
+		case isHost && slices.Equal(hosts[hostKey], imp.hosts[name]) && hostKey == name && !isCNAME,
+			isCNAME && cnames[cnameKey] == imp.cnames[name] && cnameKey == name && !isHost:
+			plan.unchanged++
+		default:
+			plan.updated++
+		}
+		if isHost {
+			delete(plan.hosts, hostKey)
+			if hostKey != name {
+				plan.remove = append(plan.remove, hostKey)
+			}
+		}
+		if isCNAME {
+			delete(plan.cnames, cnameKey)
+			if cnameKey != name {
+				plan.remove = append(plan.remove, cnameKey)
+			}
+		}
+	}
+	if replace {
+		for name := range hosts {
+			if !nameIn(imported, name) {
+				plan.removed++
+			}
+		}
+		for name := range cnames {
+			if !nameIn(imported, name) {
+				plan.removed++
+			}
+		}
+	}
+
+	maps.Copy(plan.hosts, imp.hosts)
+	maps.Copy(plan.cnames, imp.cnames)
+	return plan
+}
+
+// nameIn reports whether the canonical form of name is in names.
+func nameIn(names map[string]bool, name string) bool {
+	if c, err := dns.CanonicalName(name); err == nil {
+		name = c
+	}
+	return names[name]
+}
+
+// hostRecordType returns the record type of an address: A or AAAA.
+func hostRecordType(ip string) string {
+	if addr, err := netip.ParseAddr(ip); err == nil && addr.Unmap().Is4() {
+		return database.RecordTypeA
+	}
+	return database.RecordTypeAAAA
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jroosing/hydradns/internal/api/models"
+	"github.com/jroosing/hydradns/internal/database"
+)
+
+// ListCustomDNSHistory godoc
+// @Summary Custom DNS change history
+// @Description Lists the saved versions of the custom DNS records, newest first. A version is the records as they were before the change it names; rolling back to it undoes that change and every later one. The newest 100 versions are kept.
+// @Tags custom-dns
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} models.CustomDNSHistoryResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /custom-dns/history [get]
+func (h *Handler) ListCustomDNSHistory(c *gin.Context) {
+	versions, err := h.db.GetCustomDNSHistory(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	resp := models.CustomDNSHistoryResponse{Versions: make([]models.CustomDNSVersion, 0, len(versions))}
+	for _, v := range versions {
+		out := models.CustomDNSVersion{
+			ID:        v.ID,
+			Action:    v.Action,
+			CreatedAt: v.CreatedAt,
+			Hosts:     map[string][]string{},
+			CNAMEs:    map[string]string{},
+		}
+		for _, r := range v.Records {
+			if r.Type == database.RecordTypeCNAME {
+				out.CNAMEs[r.Source] = r.Target
+			} else {
+				out.Hosts[r.Source] = append(out.Hosts[r.Source], r.Target)
+			}
+		}
+		resp.Versions = append(resp.Versions, out)
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// RollbackCustomDNS godoc
+// @Summary Roll back custom DNS
+// @Description Restores the custom DNS records of a saved version, undoing the change it names and every later one. The records replaced are saved as a new version, so the rollback can be undone too.
+// @Tags custom-dns
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "Version ID"
+// @Success 200 {object} models.CustomDNSOperationResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ReadOnlyErrorResponse "Node is a cluster secondary"
+// @Failure 500 {object} models.ErrorResponse
+// @Router /custom-dns/history/{id}/rollback [post]
+func (h *Handler) RollbackCustomDNS(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid version ID: " + c.Param("id")})
+		return
+	}
+
+	ctx := c.Request.Context()
+	found, err := h.db.RollbackCustomDNS(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to roll back custom DNS: " + err.Error()})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: fmt.Sprintf("Custom DNS version not found: %d", id)})
+		return
+	}
+
+	// Load the restored records into the running configuration
+	hosts, err := h.db.GetAllHosts(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	cnames, err := h.db.GetAllCNAMEs(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	hostsMap := make(map[string][]string)
+	for _, host := range hosts {
+		hostsMap[host.Hostname] = append(hostsMap[host.Hostname], host.IPAddress)
+	}
+	cnamesMap := make(map[string]string, len(cnames))
+	for _, cname := range cnames {
+		cnamesMap[cname.Alias] = cname.Target
+	}
+
+	h.mu.Lock()
+	h.cfg.CustomDNS.Hosts = hostsMap
+	h.cfg.CustomDNS.CNAMEs = cnamesMap
+	reloadFunc := h.customDNSReloadFunc
+	h.mu.Unlock()
+
+	// Trigger resolver reload (outside of lock to avoid deadlock)
+	h.triggerReload(reloadFunc)
+
+	c.JSON(http.StatusOK, models.CustomDNSOperationResponse{
+		Message: fmt.Sprintf("Custom DNS rolled back to version %d", id),
+		Data: models.CustomDNSCountsResponse{
+			Hosts:  len(hostsMap),
+			CNAMEs: len(cnamesMap),
+			Total:  len(hostsMap) + len(cnamesMap),
+		},
+	})
+}
+
+// saveCustomDNSVersion snapshots the custom DNS records before the change
+// described by action. The change goes ahead when the snapshot fails; it
+// just cannot be rolled back.
+func (h *Handler) saveCustomDNSVersion(ctx context.Context, action string) {
+	if h.db == nil {
+		return
+	}
+	if err := h.db.SaveCustomDNSVersion(ctx, action); err != nil {
+		h.logError("failed to save custom DNS version", err)
+	}
+}
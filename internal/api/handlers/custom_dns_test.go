@@ -2,10 +2,14 @@ package handlers_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"path/filepath"
+	"sync"
 	"testing"
 
 	"github.com/gin-gonic/gin"
@@ -100,6 +104,57 @@ func TestAddHost_Success(t *testing.T) {
 	assert.Equal(t, []string{"192.168.1.10", "2001:db8::1"}, cfg.CustomDNS.Hosts["test.local"])
 }
 
+func TestAddHost_IDNStoredAsALabel(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{
+		CustomDNS: config.CustomDNSConfig{
+			Hosts:  make(map[string][]string),
+			CNAMEs: make(map[string]string),
+		},
+	}
+
+	h := createCustomDNSTestHandler(t, cfg)
+
+	router := gin.New()
+	router.POST("/custom-dns/hosts", h.AddHost)
+	router.DELETE("/custom-dns/hosts/:name", h.DeleteHost)
+
+	body, _ := json.Marshal(models.AddHostRequest{Name: "файл.lan", IPs: []string{"10.0.0.5"}})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/custom-dns/hosts", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, []string{"10.0.0.5"}, cfg.CustomDNS.Hosts["xn--80asg7a.lan"])
+
+	// The Unicode name addresses the same record.
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodDelete, "/custom-dns/hosts/"+url.PathEscape("файл.lan"), nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, cfg.CustomDNS.Hosts)
+}
+
+func TestAddHost_InvalidIDN(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	h := handlers.New(&config.Config{}, nil, nil)
+
+	router := gin.New()
+	router.POST("/custom-dns/hosts", h.AddHost)
+
+	body, _ := json.Marshal(models.AddHostRequest{Name: "bad_ü.lan", IPs: []string{"10.0.0.5"}})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/custom-dns/hosts", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
 func TestAddHost_Conflict(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -130,6 +185,59 @@ func TestAddHost_Conflict(t *testing.T) {
 	assert.Equal(t, http.StatusConflict, w.Code)
 }
 
+func TestAddHost_CaseOnlyConflict(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{
+		CustomDNS: config.CustomDNSConfig{
+			Hosts: map[string][]string{
+				"test.local": {"192.168.1.10"},
+			},
+		},
+	}
+
+	h := handlers.New(cfg, nil, nil)
+
+	router := gin.New()
+	router.POST("/custom-dns/hosts", h.AddHost)
+
+	for _, name := range []string{"Test.Local", "TEST.LOCAL."} {
+		body, _ := json.Marshal(models.AddHostRequest{Name: name, IPs: []string{"192.168.1.20"}})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/custom-dns/hosts", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusConflict, w.Code, name)
+	}
+	assert.Len(t, cfg.CustomDNS.Hosts, 1)
+}
+
+func TestAddHost_StoresCanonicalName(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{
+		CustomDNS: config.CustomDNSConfig{
+			Hosts:  make(map[string][]string),
+			CNAMEs: make(map[string]string),
+		},
+	}
+
+	h := createCustomDNSTestHandler(t, cfg)
+
+	router := gin.New()
+	router.POST("/custom-dns/hosts", h.AddHost)
+
+	body, _ := json.Marshal(models.AddHostRequest{Name: "NAS.Home.Lan.", IPs: []string{"10.0.0.5"}})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/custom-dns/hosts", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, map[string][]string{"nas.home.lan": {"10.0.0.5"}}, cfg.CustomDNS.Hosts)
+}
+
 func TestAddHost_InvalidIP(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -158,6 +266,69 @@ func TestAddHost_InvalidIP(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 }
 
+func TestAddHost_ConcurrentWrites(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// A second connection to the same file stands in for another process
+	// (e.g. "hydradns check") holding locks
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db, err := database.Open(dbPath)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	other, err := database.Open(dbPath)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = other.Close() })
+
+	h := handlers.New(&config.Config{}, db, nil)
+	router := gin.New()
+	router.POST("/custom-dns/hosts", h.AddHost)
+	router.GET("/filtering/whitelist", h.GetWhitelist)
+
+	const writers = 25
+	codes := make(chan int, 2*writers)
+	errs := make(chan error, writers)
+	var wg sync.WaitGroup
+	for i := range writers {
+		wg.Go(func() {
+			body, _ := json.Marshal(models.AddHostRequest{
+				Name: fmt.Sprintf("host%d.lan", i),
+				IPs:  []string{fmt.Sprintf("10.0.0.%d", i+1)},
+			})
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/custom-dns/hosts", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+			codes <- w.Code
+		})
+		wg.Go(func() {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/filtering/whitelist", nil)
+			router.ServeHTTP(w, req)
+			codes <- w.Code
+		})
+		wg.Go(func() {
+			errs <- other.AddWhitelistDomain(context.Background(), fmt.Sprintf("site%d.example", i), false)
+		})
+	}
+	wg.Wait()
+	close(codes)
+	close(errs)
+
+	for code := range codes {
+		assert.Less(t, code, 300)
+	}
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+
+	hosts, err := db.GetAllHosts(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, hosts, writers)
+	domains, err := db.GetWhitelistDomains(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, domains, writers)
+}
+
 func TestUpdateHost_Success(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -190,6 +361,32 @@ func TestUpdateHost_Success(t *testing.T) {
 	assert.Equal(t, []string{"192.168.1.20", "10.0.0.1"}, cfg.CustomDNS.Hosts["test.local"])
 }
 
+func TestUpdateHost_LegacyKeyIsCanonicalized(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{
+		CustomDNS: config.CustomDNSConfig{
+			Hosts: map[string][]string{
+				"Test.Local.": {"192.168.1.10"},
+			},
+		},
+	}
+
+	h := createCustomDNSTestHandler(t, cfg)
+
+	router := gin.New()
+	router.PUT("/custom-dns/hosts/:name", h.UpdateHost)
+
+	body, _ := json.Marshal(models.UpdateHostRequest{IPs: []string{"192.168.1.20"}})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPut, "/custom-dns/hosts/test.local", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, map[string][]string{"test.local": {"192.168.1.20"}}, cfg.CustomDNS.Hosts)
+}
+
 func TestUpdateHost_NotFound(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -433,3 +630,409 @@ func TestDeleteCNAME_NotFound(t *testing.T) {
 
 	assert.Equal(t, http.StatusNotFound, w.Code)
 }
+
+func TestSetSteering_Success(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{
+		CustomDNS: config.CustomDNSConfig{
+			Hosts: map[string][]string{"app.lan": {"10.0.1.5", "10.0.2.5"}},
+		},
+	}
+
+	h := createCustomDNSTestHandler(t, cfg)
+
+	router := gin.New()
+	router.PUT("/custom-dns/steering/:name", h.SetSteering)
+	router.GET("/custom-dns/steering", h.ListSteering)
+
+	body, _ := json.Marshal(models.SetSteeringRequest{Addrs: []config.SteeredAddr{
+		{Addr: "10.0.1.5", Weight: 3, Clients: []string{"192.168.1.0/24"}},
+		{Addr: "10.0.2.5", Weight: 1},
+	}})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPut, "/custom-dns/steering/APP.lan", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Len(t, cfg.CustomDNS.Steering, 1)
+	assert.Equal(t, "app.lan", cfg.CustomDNS.Steering[0].Name)
+
+	// Replacing keeps one entry per host
+	body, _ = json.Marshal(models.SetSteeringRequest{Addrs: []config.SteeredAddr{{Addr: "10.0.2.5", Weight: 1}}})
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPut, "/custom-dns/steering/app.lan", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/custom-dns/steering", nil)
+	router.ServeHTTP(w, req)
+
+	var resp models.SteeringResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, []config.HostSteering{
+		{Name: "app.lan", Addrs: []config.SteeredAddr{{Addr: "10.0.2.5", Weight: 1}}},
+	}, resp.Steering)
+}
+
+func TestSetSteering_Invalid(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{
+		CustomDNS: config.CustomDNSConfig{
+			Hosts: map[string][]string{"app.lan": {"10.0.1.5"}},
+		},
+	}
+
+	h := handlers.New(cfg, nil, nil)
+
+	router := gin.New()
+	router.PUT("/custom-dns/steering/:name", h.SetSteering)
+
+	tests := map[string]struct {
+		name  string
+		addrs []config.SteeredAddr
+		code  int
+	}{
+		"unknown host":    {"other.lan", []config.SteeredAddr{{Addr: "10.0.1.5"}}, http.StatusNotFound},
+		"foreign address": {"app.lan", []config.SteeredAddr{{Addr: "10.0.9.9"}}, http.StatusBadRequest},
+		"bad client":      {"app.lan", []config.SteeredAddr{{Addr: "10.0.1.5", Clients: []string{"lan"}}}, http.StatusBadRequest},
+		"no addrs":        {"app.lan", nil, http.StatusBadRequest},
+	}
+	for name, tt := range tests {
+		body, _ := json.Marshal(models.SetSteeringRequest{Addrs: tt.addrs})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPut, "/custom-dns/steering/"+tt.name, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		assert.Equal(t, tt.code, w.Code, name)
+	}
+	assert.Empty(t, cfg.CustomDNS.Steering)
+}
+
+func TestDeleteSteering(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{
+		CustomDNS: config.CustomDNSConfig{
+			Hosts: map[string][]string{"app.lan": {"10.0.1.5"}},
+			Steering: []config.HostSteering{
+				{Name: "app.lan", Addrs: []config.SteeredAddr{{Addr: "10.0.1.5", Weight: 1}}},
+			},
+		},
+	}
+
+	h := createCustomDNSTestHandler(t, cfg)
+
+	router := gin.New()
+	router.DELETE("/custom-dns/steering/:name", h.DeleteSteering)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodDelete, "/custom-dns/steering/app.lan", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, cfg.CustomDNS.Steering)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodDelete, "/custom-dns/steering/app.lan", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestCustomDNSHistory_RollbackUndoesDelete(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{
+		CustomDNS: config.CustomDNSConfig{
+			Hosts:  make(map[string][]string),
+			CNAMEs: make(map[string]string),
+		},
+	}
+
+	h := createCustomDNSTestHandler(t, cfg)
+
+	router := gin.New()
+	router.POST("/custom-dns/hosts", h.AddHost)
+	router.DELETE("/custom-dns/hosts/:name", h.DeleteHost)
+	router.POST("/custom-dns/cnames", h.AddCNAME)
+	router.GET("/custom-dns/history", h.ListCustomDNSHistory)
+	router.POST("/custom-dns/history/:id/rollback", h.RollbackCustomDNS)
+
+	do := func(method, path string, body any) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		if body != nil {
+			require.NoError(t, json.NewEncoder(&buf).Encode(body))
+		}
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, &buf)
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	require.Equal(t, http.StatusCreated, do(http.MethodPost, "/custom-dns/hosts",
+		models.AddHostRequest{Name: "nas.lan", IPs: []string{"10.0.0.5"}}).Code)
+	require.Equal(t, http.StatusCreated, do(http.MethodPost, "/custom-dns/cnames",
+		models.AddCNAMERequest{Alias: "files.lan", Target: "nas.lan"}).Code)
+	require.Equal(t, http.StatusOK, do(http.MethodDelete, "/custom-dns/hosts/nas.lan", nil).Code)
+	assert.Empty(t, cfg.CustomDNS.Hosts)
+
+	w := do(http.MethodGet, "/custom-dns/history", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var history models.CustomDNSHistoryResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &history))
+	require.Len(t, history.Versions, 3)
+	latest := history.Versions[0]
+	assert.Equal(t, "delete host nas.lan", latest.Action)
+	assert.Equal(t, map[string][]string{"nas.lan": {"10.0.0.5"}}, latest.Hosts)
+	assert.Equal(t, map[string]string{"files.lan": "nas.lan"}, latest.CNAMEs)
+	assert.Empty(t, history.Versions[2].Hosts, "the first version is the empty initial state")
+
+	w = do(http.MethodPost, fmt.Sprintf("/custom-dns/history/%d/rollback", latest.ID), nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"10.0.0.5"}, cfg.CustomDNS.Hosts["nas.lan"])
+	assert.Equal(t, "nas.lan", cfg.CustomDNS.CNAMEs["files.lan"])
+
+	// The rollback is saved too, so it can be undone
+	w = do(http.MethodGet, "/custom-dns/history", nil)
+	var after models.CustomDNSHistoryResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &after))
+	require.Len(t, after.Versions, 4)
+	assert.Equal(t, fmt.Sprintf("rollback to version %d", latest.ID), after.Versions[0].Action)
+	assert.Empty(t, after.Versions[0].Hosts)
+
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPost, "/custom-dns/history/999/rollback", nil).Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/custom-dns/history/latest/rollback", nil).Code)
+}
+
+func TestImportCustomDNS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{
+		CustomDNS: config.CustomDNSConfig{
+			Hosts:  map[string][]string{"nas.lan": {"10.0.0.5"}, "Printer.LAN": {"10.0.0.9"}},
+			CNAMEs: map[string]string{"files.lan": "nas.lan"},
+		},
+	}
+
+	h := createCustomDNSTestHandler(t, cfg)
+
+	router := gin.New()
+	router.POST("/custom-dns/import", h.ImportCustomDNS)
+
+	do := func(query, body string) (int, models.CustomDNSImportResponse) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/custom-dns/import?"+query, bytes.NewBufferString(body))
+		router.ServeHTTP(w, req)
+		var resp models.CustomDNSImportResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return w.Code, resp
+	}
+
+	csvBody := "type,name,value\n" +
+		"A,nas.lan,10.0.0.5\n" +
+		"A,printer.lan,10.0.0.10\n" +
+		"AAAA,tv.lan,fd00::7\n" +
+		"CNAME,media.lan,tv.lan\n"
+
+	// A dry run reports the changes without making them
+	code, resp := do("format=csv&dry_run=true", csvBody)
+	require.Equal(t, http.StatusOK, code)
+	assert.True(t, resp.DryRun)
+	assert.Equal(t, 3, resp.Hosts)
+	assert.Equal(t, 1, resp.CNAMEs)
+	assert.Equal(t, 2, resp.Added)
+	assert.Equal(t, 1, resp.Updated, "printer.lan changes address")
+	assert.Equal(t, 1, resp.Unchanged)
+	assert.NotContains(t, cfg.CustomDNS.Hosts, "tv.lan")
+
+	code, _ = do("format=csv", csvBody)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"10.0.0.10"}, cfg.CustomDNS.Hosts["printer.lan"])
+	assert.NotContains(t, cfg.CustomDNS.Hosts, "Printer.LAN", "the legacy spelling is replaced")
+	assert.Equal(t, []string{"fd00::7"}, cfg.CustomDNS.Hosts["tv.lan"])
+	assert.Equal(t, "tv.lan", cfg.CustomDNS.CNAMEs["media.lan"])
+	assert.Equal(t, "nas.lan", cfg.CustomDNS.CNAMEs["files.lan"], "merge keeps other records")
+
+	// Replace keeps only the imported records
+	code, resp = do("format=hosts&mode=replace", "# router\n10.0.0.1 router.lan gw.lan\n")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 2, resp.Added)
+	assert.Equal(t, 5, resp.Removed)
+	assert.Equal(t, map[string][]string{"router.lan": {"10.0.0.1"}, "gw.lan": {"10.0.0.1"}}, cfg.CustomDNS.Hosts)
+	assert.Empty(t, cfg.CustomDNS.CNAMEs)
+}
+
+func TestImportCustomDNS_RejectsInvalidRecords(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{CustomDNS: config.CustomDNSConfig{Hosts: map[string][]string{"nas.lan": {"10.0.0.5"}}}}
+	h := createCustomDNSTestHandler(t, cfg)
+
+	router := gin.New()
+	router.POST("/custom-dns/import", h.ImportCustomDNS)
+
+	body := "A,ok.lan,10.0.0.1\n" +
+		"A,v6.lan,fd00::1\n" +
+		"MX,mail.lan,10.0.0.2\n" +
+		"CNAME,ok.lan,nas.lan\n"
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/custom-dns/import?format=csv", bytes.NewBufferString(body))
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	var resp models.CustomDNSImportResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Errors, 3)
+	assert.Equal(t, 2, resp.Errors[0].Line)
+	assert.Equal(t, 3, resp.Errors[1].Line)
+	assert.Equal(t, "ok.lan", resp.Errors[2].Name, "addresses and a CNAME for one name")
+	assert.Equal(t, map[string][]string{"nas.lan": {"10.0.0.5"}}, cfg.CustomDNS.Hosts, "nothing is imported")
+}
+
+func TestExportCustomDNS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{
+		CustomDNS: config.CustomDNSConfig{
+			Hosts:  map[string][]string{"nas.lan": {"10.0.0.5", "fd00::5"}},
+			CNAMEs: map[string]string{"files.lan": "nas.lan"},
+		},
+	}
+	h := createCustomDNSTestHandler(t, cfg)
+
+	router := gin.New()
+	router.GET("/custom-dns/export", h.ExportCustomDNS)
+
+	get := func(format string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/custom-dns/export?format="+format, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("csv")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "type,name,value\nA,nas.lan,10.0.0.5\nAAAA,nas.lan,fd00::5\nCNAME,files.lan,nas.lan\n", w.Body.String())
+
+	w = get("hosts")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "10.0.0.5\tnas.lan\nfd00::5\tnas.lan\n")
+
+	w = get("json")
+	require.Equal(t, http.StatusOK, w.Code)
+	var bulk models.CustomDNSBulk
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &bulk))
+	assert.Equal(t, cfg.CustomDNS.Hosts, bulk.Hosts)
+	assert.Equal(t, cfg.CustomDNS.CNAMEs, bulk.CNAMEs)
+
+	assert.Equal(t, http.StatusBadRequest, get("yaml").Code)
+}
+
+func TestApplyBatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{
+		CustomDNS: config.CustomDNSConfig{
+			Hosts:  map[string][]string{"old-nas.lan": {"10.0.0.5"}, "Printer.LAN": {"10.0.0.9"}},
+			CNAMEs: map[string]string{"files.lan": "old-nas.lan"},
+		},
+	}
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	h := handlers.New(cfg, db, nil)
+	reloads := 0
+	h.SetCustomDNSReloadFunc(func() error { reloads++; return nil })
+
+	router := gin.New()
+	router.POST("/batch", h.ApplyBatch)
+
+	body := models.BatchRequest{Operations: []models.BatchOperation{
+		{Op: models.BatchDeleteHost, Name: "old-nas.lan"},
+		{Op: models.BatchAddHost, Name: "nas.lan", IPs: []string{"10.0.0.6"}},
+		{Op: models.BatchUpdateHost, Name: "printer.lan", IPs: []string{"10.0.0.10"}},
+		{Op: models.BatchUpdateCNAME, Name: "files.lan", Target: "nas.lan"},
+		{Op: models.BatchAddCNAME, Name: "tmp.lan", Target: "nas.lan"},
+		{Op: models.BatchDeleteCNAME, Name: "tmp.lan"},
+		{Op: models.BatchWhitelistAdd, Domains: []string{"updates.example.com", "cdn.example.com"}},
+		{Op: models.BatchWhitelistRemove, Domains: []string{"cdn.example.com"}},
+	}}
+	var buf bytes.Buffer
+	require.NoError(t, json.NewEncoder(&buf).Encode(body))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/batch", &buf)
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp models.BatchResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 8, resp.Applied)
+	assert.Equal(t, 1, reloads, "one reload for the whole batch")
+
+	want := map[string][]string{"nas.lan": {"10.0.0.6"}, "printer.lan": {"10.0.0.10"}}
+	assert.Equal(t, want, cfg.CustomDNS.Hosts)
+	assert.Equal(t, map[string]string{"files.lan": "nas.lan"}, cfg.CustomDNS.CNAMEs)
+
+	// The database holds the same state
+	hosts, err := db.GetAllHosts(context.Background())
+	require.NoError(t, err)
+	stored := map[string][]string{}
+	for _, host := range hosts {
+		stored[host.Hostname] = append(stored[host.Hostname], host.IPAddress)
+	}
+	assert.Equal(t, want, stored)
+	whitelist, err := db.GetWhitelistDomains(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"updates.example.com"}, whitelist)
+}
+
+func TestApplyBatch_RejectsInvalidOperations(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{CustomDNS: config.CustomDNSConfig{Hosts: map[string][]string{"nas.lan": {"10.0.0.5"}}}}
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	h := handlers.New(cfg, db, nil)
+	reloads := 0
+	h.SetCustomDNSReloadFunc(func() error { reloads++; return nil })
+
+	router := gin.New()
+	router.POST("/batch", h.ApplyBatch)
+
+	body := models.BatchRequest{Operations: []models.BatchOperation{
+		{Op: models.BatchAddHost, Name: "tv.lan", IPs: []string{"10.0.0.7"}},
+		{Op: models.BatchAddHost, Name: "TV.lan", IPs: []string{"10.0.0.8"}},
+		{Op: models.BatchDeleteHost, Name: "nas.lan"},
+		{Op: models.BatchBlacklistAdd, Domains: []string{"ads.example.com"}},
+		{Op: models.BatchUpdateCNAME, Name: "files.lan", Target: "nas.lan"},
+		{Op: "rename_host", Name: "nas.lan"},
+	}}
+	var buf bytes.Buffer
+	require.NoError(t, json.NewEncoder(&buf).Encode(body))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/batch", &buf)
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	var resp models.BatchResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Zero(t, resp.Applied)
+	require.Len(t, resp.Errors, 3)
+	assert.Equal(t, 1, resp.Errors[0].Index, "tv.lan was added by the first operation")
+	assert.Equal(t, 4, resp.Errors[1].Index)
+	assert.Equal(t, 5, resp.Errors[2].Index)
+
+	// Nothing is applied
+	assert.Equal(t, map[string][]string{"nas.lan": {"10.0.0.5"}}, cfg.CustomDNS.Hosts)
+	blacklist, err := db.GetBlacklistDomains(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, blacklist)
+	assert.Zero(t, reloads)
+}
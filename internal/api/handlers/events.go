@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jroosing/hydradns/internal/api/models"
+)
+
+const (
+	defaultEventStatsInterval = 5 * time.Second
+	minEventStatsInterval     = time.Second
+)
+
+// StreamEvents godoc
+// @Summary Live query and statistics stream
+// @Description Server-Sent Events stream for dashboards. A "stats" event carrying DNS statistics is sent on connect and then every interval; a "query" event is sent for each answered query. Query events are dropped, not delayed, when the client reads too slowly.
+// @Tags system
+// @Produce text/event-stream
+// @Param interval query string false "Statistics interval, e.g. 2s (default 5s, minimum 1s)"
+// @Param queries query bool false "Include query events (default true)"
+// @Success 200 {object} models.QueryEvent "event: query"
+// @Failure 400 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /events [get]
+func (h *Handler) StreamEvents(c *gin.Context) {
+	interval := defaultEventStatsInterval
+	if raw := c.Query("interval"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < minEventStatsInterval {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "interval must be a duration of at least 1s"})
+			return
+		}
+		interval = d
+	}
+	withQueries := true
+	if raw := c.Query("queries"); raw != "" {
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "queries must be true or false"})
+			return
+		}
+		withQueries = v
+	}
+
+	ctx := c.Request.Context()
+	var queries <-chan QueryEventSnapshot
+	if fn := h.GetQueryEventsFunc(); withQueries && fn != nil {
+		queries = fn(ctx)
+	}
+
+	// The stream is long-lived; lift the server's write timeout for it.
+	_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // Disable proxy buffering (nginx)
+	c.Status(http.StatusOK)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	c.SSEvent("stats", h.getDNSStats())
+	c.Writer.Flush()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev, ok := <-queries:
+			if !ok {
+				queries = nil
+				continue
+			}
+			c.SSEvent("query", queryEventResponse(ev))
+		case <-ticker.C:
+			c.SSEvent("stats", h.getDNSStats())
+		}
+		c.Writer.Flush()
+	}
+}
+
+func queryEventResponse(ev QueryEventSnapshot) models.QueryEvent {
+	return models.QueryEvent{
+		Time:       ev.Time,
+		Transport:  ev.Transport,
+		Client:     ev.Client,
+		Name:       ev.Name,
+		Type:       ev.Type,
+		RCode:      ev.RCode,
+		Source:     ev.Source,
+		DurationMs: float64(ev.Duration.Microseconds()) / 1000,
+		TraceID:    ev.TraceID,
+	}
+}
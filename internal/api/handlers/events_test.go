@@ -0,0 +1,152 @@
+package handlers_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jroosing/hydradns/internal/api/handlers"
+	"github.com/jroosing/hydradns/internal/api/models"
+	"github.com/jroosing/hydradns/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sseEvent is one parsed Server-Sent Event.
+type sseEvent struct {
+	name string
+	data string
+}
+
+// readSSE returns the next event from the stream.
+func readSSE(t *testing.T, sc *bufio.Scanner) sseEvent {
+	t.Helper()
+	var ev sseEvent
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case line == "":
+			if ev.name != "" {
+				return ev
+			}
+		case strings.HasPrefix(line, "event:"):
+			ev.name = line[len("event:"):]
+		case strings.HasPrefix(line, "data:"):
+			ev.data += line[len("data:"):]
+		}
+	}
+	require.NoError(t, sc.Err())
+	t.Fatal("stream ended")
+	return ev
+}
+
+func TestStreamEvents(t *testing.T) {
+	h := handlers.New(&config.Config{}, nil, nil)
+	h.SetDNSStatsFunc(func() handlers.DNSStatsSnapshot {
+		return handlers.DNSStatsSnapshot{QueriesTotal: 42}
+	})
+
+	queries := make(chan handlers.QueryEventSnapshot, 1)
+	subscribed := make(chan context.Context, 1)
+	h.SetQueryEventsFunc(func(ctx context.Context) <-chan handlers.QueryEventSnapshot {
+		subscribed <- ctx
+		return queries
+	})
+
+	router := gin.New()
+	router.GET("/events", h.StreamEvents)
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/events", nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "text/event-stream")
+	sc := bufio.NewScanner(resp.Body)
+
+	// Statistics are sent on connect.
+	ev := readSSE(t, sc)
+	require.Equal(t, "stats", ev.name)
+	var stats models.DNSStatsResponse
+	require.NoError(t, json.Unmarshal([]byte(ev.data), &stats))
+	assert.Equal(t, uint64(42), stats.QueriesTotal)
+
+	queries <- handlers.QueryEventSnapshot{
+		Transport: "udp",
+		Client:    "192.0.2.1",
+		Name:      "example.com",
+		Type:      "A",
+		RCode:     "NOERROR",
+		Source:    "cache",
+		Duration:  1500 * time.Microsecond,
+		TraceID:   "00000000feedf00d",
+	}
+	ev = readSSE(t, sc)
+	require.Equal(t, "query", ev.name)
+	var q models.QueryEvent
+	require.NoError(t, json.Unmarshal([]byte(ev.data), &q))
+	assert.Equal(t, "example.com", q.Name)
+	assert.Equal(t, "NOERROR", q.RCode)
+	assert.InDelta(t, 1.5, q.DurationMs, 1e-9)
+	assert.Equal(t, "00000000feedf00d", q.TraceID)
+
+	// Closing the stream ends the subscription.
+	subCtx := <-subscribed
+	cancel()
+	select {
+	case <-subCtx.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("subscription not cancelled")
+	}
+}
+
+func TestStreamEvents_InvalidParameters(t *testing.T) {
+	h := handlers.New(&config.Config{}, nil, nil)
+	router := gin.New()
+	router.GET("/events", h.StreamEvents)
+
+	for _, query := range []string{"interval=soon", "interval=100ms", "queries=maybe"} {
+		w := performRequest(router, http.MethodGet, "/events?"+query, "")
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestListAnomalies(t *testing.T) {
+	h := handlers.New(&config.Config{}, nil, nil)
+	router := gin.New()
+	router.GET("/anomalies", h.ListAnomalies)
+
+	// Detection off: empty list
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/anomalies", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"anomalies":[]}`, w.Body.String())
+
+	at := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	h.SetAnomaliesFunc(func() []handlers.AnomalySnapshot {
+		return []handlers.AnomalySnapshot{
+			{Time: at, Client: "192.0.2.1", Kind: "long_label", Name: "x.example", Detail: "label length 60"},
+		}
+	})
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/anomalies", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp models.AnomaliesResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, []models.Anomaly{
+		{Time: at, Client: "192.0.2.1", Kind: "long_label", Name: "x.example", Detail: "label length 60"},
+	}, resp.Anomalies)
+}
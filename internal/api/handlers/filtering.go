@@ -1,19 +1,32 @@
 package handlers
 
 import (
+	"cmp"
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/netip"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jroosing/hydradns/internal/api/models"
+	"github.com/jroosing/hydradns/internal/database"
+	"github.com/jroosing/hydradns/internal/dns"
 	"github.com/jroosing/hydradns/internal/filtering"
+	"github.com/jroosing/hydradns/internal/helpers"
 )
 
 // listOps defines operations for a domain list (whitelist or blacklist).
 type listOps struct {
 	name             string
-	getFromDB        func(context.Context) ([]string, error)
-	addToDB          func(context.Context, string) error
+	getEntriesFromDB func(context.Context) ([]database.DomainEntry, error)
+	getLocalFromDB   func(context.Context) ([]string, error)
+	addToDB          func(context.Context, string, bool) error
 	deleteFromDB     func(context.Context, string) error
 	addToEngine      func(*filtering.PolicyEngine, string)
 	removeFromEngine func(*filtering.PolicyEngine, string)
@@ -22,7 +35,8 @@ type listOps struct {
 func (h *Handler) whitelistOps() listOps {
 	return listOps{
 		name:             "whitelist",
-		getFromDB:        h.db.GetWhitelistDomains,
+		getEntriesFromDB: h.db.GetWhitelistEntries,
+		getLocalFromDB:   h.db.GetLocalWhitelistDomains,
 		addToDB:          h.db.AddWhitelistDomain,
 		deleteFromDB:     h.db.DeleteWhitelistDomain,
 		addToEngine:      func(pe *filtering.PolicyEngine, d string) { pe.AddToWhitelist(d) },
@@ -33,7 +47,8 @@ func (h *Handler) whitelistOps() listOps {
 func (h *Handler) blacklistOps() listOps {
 	return listOps{
 		name:             "blacklist",
-		getFromDB:        h.db.GetBlacklistDomains,
+		getEntriesFromDB: h.db.GetBlacklistEntries,
+		getLocalFromDB:   h.db.GetLocalBlacklistDomains,
 		addToDB:          h.db.AddBlacklistDomain,
 		deleteFromDB:     h.db.DeleteBlacklistDomain,
 		addToEngine:      func(pe *filtering.PolicyEngine, d string) { pe.AddToBlacklist(d) },
@@ -47,13 +62,19 @@ func (h *Handler) getDomainList(c *gin.Context, ops listOps) {
 		return
 	}
 
-	domains, err := ops.getFromDB(c.Request.Context())
+	q, err := parseDomainListQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	entries, err := ops.getEntriesFromDB(c.Request.Context())
 	if err != nil {
 		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Error: err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, models.DomainListResponse{Domains: domains, Count: len(domains)})
+	c.JSON(http.StatusOK, q.page(entries))
 }
 
 func (h *Handler) addToDomainList(c *gin.Context, ops listOps) {
@@ -69,8 +90,33 @@ func (h *Handler) addToDomainList(c *gin.Context, ops listOps) {
 		return
 	}
 
-	for _, domain := range req.Domains {
-		if err := ops.addToDB(c.Request.Context(), domain); err != nil {
+	domains := make([]string, len(req.Domains))
+	for i, domain := range req.Domains {
+		domain, err := canonicalName(domain)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+			return
+		}
+		domains[i] = domain
+	}
+
+	// Reject the request if any domain is already listed, before adding any
+	entries, err := ops.getEntriesFromDB(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	for _, e := range entries {
+		if slices.Contains(domains, e.Domain) {
+			c.JSON(http.StatusConflict, models.ErrorResponse{Error: e.Domain + " is already in the " + ops.name})
+			return
+		}
+	}
+
+	// Domains added on a secondary are local and survive cluster sync
+	local := h.isSecondary()
+	for _, domain := range domains {
+		if err := ops.addToDB(c.Request.Context(), domain, local); err != nil {
 			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
 			return
 		}
@@ -83,12 +129,12 @@ func (h *Handler) addToDomainList(c *gin.Context, ops listOps) {
 		h.logger.Info("added domains to "+ops.name, "count", len(req.Domains))
 	}
 
-	domains, err := ops.getFromDB(c.Request.Context())
+	resp, err := domainList(c.Request.Context(), ops)
 	if err != nil {
 		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Error: err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, models.DomainListResponse{Domains: domains, Count: len(domains)})
+	c.JSON(http.StatusOK, resp)
 }
 
 func (h *Handler) removeFromDomainList(c *gin.Context, ops listOps) {
@@ -104,7 +150,33 @@ func (h *Handler) removeFromDomainList(c *gin.Context, ops listOps) {
 		return
 	}
 
-	for _, domain := range req.Domains {
+	domains := make([]string, len(req.Domains))
+	for i, domain := range req.Domains {
+		domain, err := canonicalName(domain)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+			return
+		}
+		domains[i] = domain
+	}
+
+	// A secondary may only remove its local domains; synced ones would be
+	// restored by the next sync
+	if h.isSecondary() {
+		local, err := ops.getLocalFromDB(c.Request.Context())
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Error: err.Error()})
+			return
+		}
+		for _, domain := range domains {
+			if !slices.Contains(local, domain) {
+				h.abortReadOnly(c, domain+" is synced from the primary; only local domains can be removed on a secondary")
+				return
+			}
+		}
+	}
+
+	for _, domain := range domains {
 		if err := ops.deleteFromDB(c.Request.Context(), domain); err != nil {
 			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
 			return
@@ -114,20 +186,141 @@ func (h *Handler) removeFromDomainList(c *gin.Context, ops listOps) {
 		}
 	}
 
-	domains, err := ops.getFromDB(c.Request.Context())
+	resp, err := domainList(c.Request.Context(), ops)
 	if err != nil {
 		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Error: err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, models.DomainListResponse{Domains: domains, Count: len(domains)})
+	c.JSON(http.StatusOK, resp)
+}
+
+// domainList returns all domains of a list and which of them are local.
+func domainList(ctx context.Context, ops listOps) (models.DomainListResponse, error) {
+	entries, err := ops.getEntriesFromDB(ctx)
+	if err != nil {
+		return models.DomainListResponse{}, err
+	}
+	return domainListQuery{}.page(entries), nil
+}
+
+// Sort keys of domain list queries.
+const (
+	domainSortDomain = "domain"
+	domainSortAdded  = "added"
+)
+
+// domainListQuery selects a page of a whitelist or blacklist. The zero
+// value selects the whole list, sorted by domain.
+type domainListQuery struct {
+	search string         // substring the domains contain
+	regex  *regexp.Regexp // pattern the domains match
+	sort   string         // domainSortDomain or domainSortAdded
+	desc   bool
+	offset int
+	limit  int // 0 for no limit
+}
+
+// parseDomainListQuery reads a domainListQuery from the search, regex,
+// sort, order, offset, and limit query parameters.
+func parseDomainListQuery(c *gin.Context) (domainListQuery, error) {
+	q := domainListQuery{
+		search: strings.ToLower(strings.TrimSpace(c.Query("search"))),
+		sort:   strings.ToLower(c.DefaultQuery("sort", domainSortDomain)),
+	}
+	if q.sort != domainSortDomain && q.sort != domainSortAdded {
+		return q, errors.New("sort must be domain or added")
+	}
+	switch strings.ToLower(c.DefaultQuery("order", "asc")) {
+	case "asc":
+	case "desc":
+		q.desc = true
+	default:
+		return q, errors.New("order must be asc or desc")
+	}
+	if raw := c.Query("regex"); raw != "" {
+		re, err := regexp.Compile(raw)
+		if err != nil {
+			return q, fmt.Errorf("invalid regex: %w", err)
+		}
+		q.regex = re
+	}
+	var err error
+	if q.offset, err = nonNegativeQuery(c, "offset"); err != nil {
+		return q, err
+	}
+	if q.limit, err = nonNegativeQuery(c, "limit"); err != nil {
+		return q, err
+	}
+	return q, nil
+}
+
+// nonNegativeQuery returns the query parameter key as a non-negative
+// integer, or 0 when it is not set.
+func nonNegativeQuery(c *gin.Context, key string) (int, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative integer", key)
+	}
+	return n, nil
+}
+
+// page returns the entries matching q, sorted, from q.offset on.
+// entries must be sorted by domain.
+func (q domainListQuery) page(entries []database.DomainEntry) models.DomainListResponse {
+	resp := models.DomainListResponse{Total: len(entries), Offset: q.offset, Limit: q.limit}
+
+	matched := make([]database.DomainEntry, 0, len(entries))
+	for _, e := range entries {
+		if q.search != "" && !strings.Contains(e.Domain, q.search) {
+			continue
+		}
+		if q.regex != nil && !q.regex.MatchString(e.Domain) {
+			continue
+		}
+		matched = append(matched, e)
+	}
+	resp.Matched = len(matched)
+
+	if q.sort == domainSortAdded {
+		slices.SortStableFunc(matched, func(a, b database.DomainEntry) int { return cmp.Compare(a.ID, b.ID) })
+	}
+	if q.desc {
+		slices.Reverse(matched)
+	}
+
+	matched = matched[min(q.offset, len(matched)):]
+	if q.limit > 0 && len(matched) > q.limit {
+		matched = matched[:q.limit]
+	}
+
+	resp.Domains = make([]string, len(matched))
+	for i, e := range matched {
+		resp.Domains[i] = e.Domain
+		if e.Local {
+			resp.Local = append(resp.Local, e.Domain)
+		}
+	}
+	resp.Count = len(resp.Domains)
+	return resp
 }
 
 // GetWhitelist godoc
 // @Summary Get whitelist domains
-// @Description Returns all domains in the whitelist
+// @Description Returns the domains in the whitelist: all of them, or a page of those matching a search
 // @Tags filtering
 // @Produce json
+// @Param search query string false "Only domains containing this text"
+// @Param regex query string false "Only domains matching this regular expression"
+// @Param sort query string false "domain or added" default(domain)
+// @Param order query string false "asc or desc" default(asc)
+// @Param offset query int false "Matching domains to skip" default(0)
+// @Param limit query int false "Maximum domains to return; all when unset"
 // @Success 200 {object} models.DomainListResponse
+// @Failure 400 {object} models.ErrorResponse
 // @Failure 503 {object} models.ErrorResponse
 // @Security ApiKeyAuth
 // @Router /filtering/whitelist [get]
@@ -144,6 +337,7 @@ func (h *Handler) GetWhitelist(c *gin.Context) {
 // @Param domains body models.DomainRequest true "Domains to add"
 // @Success 200 {object} models.StatusResponse
 // @Failure 400 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse "Domain already listed"
 // @Failure 503 {object} models.ErrorResponse
 // @Security ApiKeyAuth
 // @Router /filtering/whitelist [post]
@@ -161,6 +355,7 @@ func (h *Handler) AddWhitelist(c *gin.Context) {
 // @Success 200 {object} models.StatusResponse
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 501 {object} models.ErrorResponse
+// @Failure 409 {object} models.ReadOnlyErrorResponse "Domain is synced from the primary"
 // @Security ApiKeyAuth
 // @Router /filtering/whitelist [delete]
 func (h *Handler) RemoveWhitelist(c *gin.Context) {
@@ -169,10 +364,17 @@ func (h *Handler) RemoveWhitelist(c *gin.Context) {
 
 // GetBlacklist godoc
 // @Summary Get blacklist domains
-// @Description Returns all domains in the blacklist
+// @Description Returns the domains in the blacklist: all of them, or a page of those matching a search
 // @Tags filtering
 // @Produce json
+// @Param search query string false "Only domains containing this text"
+// @Param regex query string false "Only domains matching this regular expression"
+// @Param sort query string false "domain or added" default(domain)
+// @Param order query string false "asc or desc" default(asc)
+// @Param offset query int false "Matching domains to skip" default(0)
+// @Param limit query int false "Maximum domains to return; all when unset"
 // @Success 200 {object} models.DomainListResponse
+// @Failure 400 {object} models.ErrorResponse
 // @Failure 503 {object} models.ErrorResponse
 // @Security ApiKeyAuth
 // @Router /filtering/blacklist [get]
@@ -189,6 +391,7 @@ func (h *Handler) GetBlacklist(c *gin.Context) {
 // @Param domains body models.DomainRequest true "Domains to add"
 // @Success 200 {object} models.StatusResponse
 // @Failure 400 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse "Domain already listed"
 // @Failure 503 {object} models.ErrorResponse
 // @Security ApiKeyAuth
 // @Router /filtering/blacklist [post]
@@ -206,6 +409,7 @@ func (h *Handler) AddBlacklist(c *gin.Context) {
 // @Success 200 {object} models.StatusResponse
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 501 {object} models.ErrorResponse
+// @Failure 409 {object} models.ReadOnlyErrorResponse "Domain is synced from the primary"
 // @Security ApiKeyAuth
 // @Router /filtering/blacklist [delete]
 func (h *Handler) RemoveBlacklist(c *gin.Context) {
@@ -242,6 +446,247 @@ func (h *Handler) FilteringStats(c *gin.Context) {
 	})
 }
 
+// Bounds of the top query parameter of ListBlockStats.
+const (
+	defaultTopBlockedDomains = 10
+	maxTopBlockedDomains     = 100
+)
+
+// ListBlockStats godoc
+// @Summary Get blocks per list
+// @Description Returns how many queries each list (the blacklist, each blocklist, threat intelligence, newly registered domains) blocked since startup and its most blocked domains, most blocking list first. A query matching several lists counts for each; unique counts the queries no other list would have blocked.
+// @Tags filtering
+// @Produce json
+// @Param top query int false "Most blocked domains per list (at most 100)" default(10)
+// @Success 200 {object} models.ListBlockStatsResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /filtering/stats/lists [get]
+func (h *Handler) ListBlockStats(c *gin.Context) {
+	pe := h.GetPolicyEngine()
+	if pe == nil {
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Error: "filtering not enabled"})
+		return
+	}
+
+	top := defaultTopBlockedDomains
+	if raw := c.Query("top"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 || n > maxTopBlockedDomains {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "top must be between 0 and 100"})
+			return
+		}
+		top = n
+	}
+
+	stats := pe.BlockStats(top)
+	resp := models.ListBlockStatsResponse{Lists: make([]models.ListBlockStats, len(stats))}
+	for i, s := range stats {
+		domains := make([]models.BlockedDomainCount, len(s.TopDomains))
+		for j, d := range s.TopDomains {
+			domains[j] = models.BlockedDomainCount{Domain: d.Domain, Count: d.Count}
+		}
+		resp.Lists[i] = models.ListBlockStats{
+			Name:       s.Name,
+			Blocked:    s.Blocked,
+			Unique:     s.Unique,
+			TopDomains: domains,
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// CheckFiltering godoc
+// @Summary Check how a domain is filtered
+// @Description Reports whether a query for domain would be blocked and by which step, list and rule, without resolving it or counting it in the statistics. With client, the client's captive portal redirect is taken into account too. Filtering itself is the same for all clients.
+// @Tags filtering
+// @Produce json
+// @Param domain query string true "Domain to check"
+// @Param client query string false "Client IP address"
+// @Success 200 {object} models.FilteringCheckResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /filtering/check [get]
+func (h *Handler) CheckFiltering(c *gin.Context) {
+	domain, err := canonicalName(c.Query("domain"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	var client netip.Addr
+	if raw := c.Query("client"); raw != "" {
+		client, err = netip.ParseAddr(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid client IP address: " + raw})
+			return
+		}
+		client = client.Unmap()
+	}
+
+	pe := h.GetPolicyEngine()
+	if pe == nil {
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Error: "filtering not enabled"})
+		return
+	}
+
+	d := pe.Check(domain)
+	resp := models.FilteringCheckResponse{
+		Domain:  domain,
+		Blocked: d.Action == filtering.ActionBlock,
+		Action:  d.Action.String(),
+		Reason:  d.Reason,
+		List:    d.ListName,
+		Rule:    d.Rule,
+		Lists:   d.Lists,
+	}
+	if client.IsValid() {
+		resp.Client = client.String()
+		if h.redirectsToPortal(client, domain) {
+			resp.Blocked = true
+			resp.Action = "redirect"
+			resp.Reason = "captive_portal"
+			resp.List = ""
+			resp.Rule = ""
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// redirectsToPortal reports whether the captive portal answers queries for
+// domain from client, as resolvers.CaptivePortalResolver does.
+func (h *Handler) redirectsToPortal(client netip.Addr, domain string) bool {
+	h.mu.RLock()
+	portal := h.cfg.CaptivePortal
+	h.mu.RUnlock()
+
+	if !portal.Enabled || !slices.ContainsFunc(portal.ClientPrefixes(), func(p netip.Prefix) bool {
+		return p.Contains(client)
+	}) {
+		return false
+	}
+	for _, allowed := range portal.AllowDomains {
+		zone, err := dns.CanonicalName(allowed)
+		if err == nil && (domain == zone || strings.HasSuffix(domain, "."+zone)) {
+			return false
+		}
+	}
+	return true
+}
+
+// GetFilteringRPZ godoc
+// @Summary Export filtering policy as RPZ
+// @Description Returns the effective whitelist/blacklist as a Response Policy Zone in master file format, for other resolvers to consume
+// @Tags filtering
+// @Produce plain
+// @Param zone query string false "Zone origin" default(rpz.hydradns)
+// @Success 200 {string} string "RPZ zone file"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /filtering/rpz [get]
+func (h *Handler) GetFilteringRPZ(c *gin.Context) {
+	pe := h.GetPolicyEngine()
+	if pe == nil {
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Error: "filtering not enabled"})
+		return
+	}
+
+	origin := c.DefaultQuery("zone", filtering.DefaultRPZOrigin)
+	serial := helpers.ClampIntToUint32(int(time.Now().Unix()))
+
+	c.Header("Content-Type", "text/dns; charset=utf-8")
+	if err := pe.WriteRPZ(c.Writer, origin, serial); err != nil {
+		if !c.Writer.Written() {
+			c.Writer.Header().Del("Content-Type")
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+			return
+		}
+		h.logError("failed to write RPZ export", err)
+	}
+}
+
+// maxTemporaryAllow caps how long a domain can be allowed temporarily;
+// longer exceptions belong on the whitelist.
+const maxTemporaryAllow = 24 * time.Hour
+
+// AllowTemporarily godoc
+// @Summary Allow a domain temporarily
+// @Description Allows a blocked domain (not its subdomains) for a limited time and drops its cached responses. The allow is stored with the whitelist until it expires, so it survives restarts and is synced to cluster secondaries; allows made on a secondary stay local to it. A whitelisted domain is left unchanged.
+// @Tags filtering
+// @Accept json
+// @Produce json
+// @Param request body models.TemporaryAllowRequest true "Domain and duration (at most 24h)"
+// @Success 200 {object} models.TemporaryAllowResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /filtering/allow-temporarily [post]
+func (h *Handler) AllowTemporarily(c *gin.Context) {
+	pe := h.GetPolicyEngine()
+	if pe == nil {
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Error: "filtering not available"})
+		return
+	}
+	if h.db == nil {
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Error: "database not available"})
+		return
+	}
+
+	var req models.TemporaryAllowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	domain, err := canonicalName(req.Domain)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	d, err := time.ParseDuration(req.Duration)
+	if err != nil || d <= 0 || d > maxTemporaryAllow {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "duration must be a positive duration of at most " + maxTemporaryAllow.String(),
+		})
+		return
+	}
+
+	// Stored to the second, as the whitelist keeps it
+	expiresAt := time.Now().Add(d).Truncate(time.Second)
+	// Allows made on a secondary are local and survive cluster sync
+	if err := h.db.AllowWhitelistDomainUntil(c.Request.Context(), domain, expiresAt, h.isSecondary()); err != nil {
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	pe.AllowUntil(domain, expiresAt)
+
+	// Export the allow to cluster secondaries
+	if allows, err := h.db.GetTemporaryAllows(c.Request.Context()); err != nil {
+		h.logError("failed to reload temporary allows", err)
+	} else if h.cfg != nil {
+		h.mu.Lock()
+		h.cfg.Filtering.TemporaryAllows = allows
+		h.mu.Unlock()
+	}
+
+	// Drop cached answers so the domain resolves fresh right away
+	purged := 0
+	if purge := h.GetCachePurgeFunc(); purge != nil {
+		purged = purge(domain)
+	}
+
+	if h.logger != nil {
+		h.logger.Info("domain allowed temporarily", "domain", domain, "duration", d, "cache_purged", purged)
+	}
+
+	c.JSON(http.StatusOK, models.TemporaryAllowResponse{
+		Domain:      domain,
+		ExpiresAt:   expiresAt,
+		CachePurged: purged,
+	})
+}
+
 // GetBlocklists lists all configured remote blocklists.
 // @Summary Get blocklists
 // @Description Returns all configured blocklists
@@ -288,6 +733,7 @@ func (h *Handler) GetBlocklists(c *gin.Context) {
 // @Success 200 {object} models.StatusResponse
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 503 {object} models.ErrorResponse
+// @Failure 409 {object} models.ReadOnlyErrorResponse "Node is a secondary"
 // @Security ApiKeyAuth
 // @Router /filtering/blocklists/{name}/enabled [put]
 func (h *Handler) SetBlocklistEnabled(c *gin.Context) {
@@ -365,6 +811,7 @@ func (h *Handler) RefreshBlocklist(c *gin.Context) {
 // @Success 200 {object} models.StatusResponse
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 503 {object} models.ErrorResponse
+// @Failure 409 {object} models.ReadOnlyErrorResponse "Node is a secondary"
 // @Security ApiKeyAuth
 // @Router /filtering/enabled [put]
 func (h *Handler) SetFilteringEnabled(c *gin.Context) {
@@ -402,3 +849,69 @@ func (h *Handler) SetFilteringEnabled(c *gin.Context) {
 
 	c.JSON(http.StatusOK, models.StatusResponse{Status: "ok"})
 }
+
+// GetFilteringTTLs godoc
+// @Summary Get block TTLs
+// @Description Returns the TTLs of block page answers and of blocked NXDOMAIN and NODATA answers
+// @Tags filtering
+// @Produce json
+// @Success 200 {object} models.FilteringTTLs
+// @Failure 503 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /filtering/ttl [get]
+func (h *Handler) GetFilteringTTLs(c *gin.Context) {
+	pe := h.GetPolicyEngine()
+	if pe == nil {
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Error: "filtering not available"})
+		return
+	}
+
+	blockTTL, negativeTTL := pe.BlockTTLs()
+	c.JSON(http.StatusOK, models.FilteringTTLs{BlockTTL: int(blockTTL), NegativeTTL: int(negativeTTL)})
+}
+
+// SetFilteringTTLs godoc
+// @Summary Set block TTLs
+// @Description Sets the TTLs of block page answers and of blocked NXDOMAIN and NODATA answers. Short TTLs keep clients from caching a block for long after the domain is unblocked.
+// @Tags filtering
+// @Accept json
+// @Produce json
+// @Param ttls body models.FilteringTTLs true "TTLs in seconds"
+// @Success 200 {object} models.FilteringTTLs
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
+// @Failure 409 {object} models.ReadOnlyErrorResponse "Node is a secondary"
+// @Security ApiKeyAuth
+// @Router /filtering/ttl [put]
+func (h *Handler) SetFilteringTTLs(c *gin.Context) {
+	pe := h.GetPolicyEngine()
+	if pe == nil {
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Error: "filtering not available"})
+		return
+	}
+
+	var req models.FilteringTTLs
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	// Persist to database if available
+	if h.db != nil {
+		if err := h.db.SetFilteringTTLs(c.Request.Context(), req.BlockTTL, req.NegativeTTL); err != nil {
+			c.JSON(
+				http.StatusServiceUnavailable,
+				models.ErrorResponse{Error: "failed to persist setting: " + err.Error()},
+			)
+			return
+		}
+	}
+
+	pe.SetBlockTTLs(helpers.ClampIntToUint32(req.BlockTTL), helpers.ClampIntToUint32(req.NegativeTTL))
+
+	if h.logger != nil {
+		h.logger.Info("block TTLs changed", "block_ttl", req.BlockTTL, "negative_ttl", req.NegativeTTL)
+	}
+
+	c.JSON(http.StatusOK, req)
+}
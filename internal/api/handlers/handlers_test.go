@@ -2,6 +2,7 @@
 package handlers_test
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -9,6 +10,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jroosing/hydradns/internal/api/handlers"
@@ -75,6 +77,115 @@ func TestHealth_ReturnsOK(t *testing.T) {
 	assert.Equal(t, "ok", resp.Status)
 }
 
+func TestReady(t *testing.T) {
+	h := createTestHandler(t)
+	router := gin.New()
+	router.GET("/ready", h.Ready)
+
+	w := performRequest(router, http.MethodGet, "/ready", "")
+	assert.Equal(t, http.StatusOK, w.Code, "ready without a readiness source")
+
+	reasons := []string{"blocklists are loading"}
+	h.SetReadinessFunc(func() []string { return reasons })
+	w = performRequest(router, http.MethodGet, "/ready", "")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	var resp models.ReadinessResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, models.ReadinessResponse{Status: "not_ready", Reasons: reasons}, resp)
+
+	reasons = nil
+	w = performRequest(router, http.MethodGet, "/ready", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var ready models.ReadinessResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &ready))
+	assert.Equal(t, models.ReadinessResponse{Status: "ready"}, ready)
+}
+
+func TestDeepHealth_NoCanary(t *testing.T) {
+	h := createTestHandler(t)
+	router := gin.New()
+	router.GET("/health/deep", h.DeepHealth)
+
+	w := performRequest(router, http.MethodGet, "/health/deep", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp models.DeepHealthResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "ok", resp.Status)
+	assert.Nil(t, resp.Canary)
+}
+
+func TestDeepHealth_ReportsComponents(t *testing.T) {
+	since := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	h := createTestHandler(t)
+	h.SetComponentsFunc(func() []handlers.ComponentSnapshot {
+		return []handlers.ComponentSnapshot{
+			{Name: "dns-udp", Required: true, State: "running", Since: since},
+			{Name: "cluster-syncer", State: "failed", Error: "primary unreachable", Since: since},
+		}
+	})
+	router := gin.New()
+	router.GET("/health/deep", h.DeepHealth)
+
+	w := performRequest(router, http.MethodGet, "/health/deep", "")
+	assert.Equal(t, http.StatusOK, w.Code, "an optional component failing does not fail the health check")
+
+	var resp models.DeepHealthResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "degraded", resp.Status)
+	require.Len(t, resp.Components, 2)
+	assert.Equal(t, models.ComponentHealth{Name: "dns-udp", Required: true, State: "running", Since: since}, resp.Components[0])
+	assert.Equal(t, "primary unreachable", resp.Components[1].Error)
+}
+
+func TestDeepHealth_ReportsCanary(t *testing.T) {
+	checked := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name       string
+		healthy    bool
+		wantCode   int
+		wantStatus string
+	}{
+		{name: "healthy", healthy: true, wantCode: http.StatusOK, wantStatus: "ok"},
+		{name: "failing", healthy: false, wantCode: http.StatusServiceUnavailable, wantStatus: "failing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := createTestHandler(t)
+			h.SetCanaryFunc(func() *handlers.CanarySnapshot {
+				return &handlers.CanarySnapshot{
+					Healthy:      tt.healthy,
+					LastRound:    checked,
+					FailedRounds: 3,
+					Checks: []handlers.CanaryCheckSnapshot{
+						{Domain: "example.com", Error: "i/o timeout", Latency: 1500 * time.Microsecond,
+							CheckedAt: checked, ConsecutiveFailures: 3},
+					},
+				}
+			})
+			router := gin.New()
+			router.GET("/health/deep", h.DeepHealth)
+
+			w := performRequest(router, http.MethodGet, "/health/deep", "")
+			assert.Equal(t, tt.wantCode, w.Code)
+
+			var resp models.DeepHealthResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Equal(t, tt.wantStatus, resp.Status)
+			require.NotNil(t, resp.Canary)
+			assert.Equal(t, tt.healthy, resp.Canary.Healthy)
+			require.Len(t, resp.Canary.Checks, 1)
+
+			check := resp.Canary.Checks[0]
+			assert.Equal(t, "example.com", check.Domain)
+			assert.Equal(t, "i/o timeout", check.Error)
+			assert.InDelta(t, 1.5, check.LatencyMs, 1e-9)
+			assert.Equal(t, 3, check.ConsecutiveFailures)
+			assert.Nil(t, check.LastSuccess)
+		})
+	}
+}
+
 // ============================================================================
 // Stats Endpoint Tests
 // ============================================================================
@@ -154,6 +265,139 @@ func TestGetWhitelist_WithPolicyEngine(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestListBlockStats(t *testing.T) {
+	h := createTestHandler(t)
+	router := gin.New()
+	router.GET("/filtering/stats/lists", h.ListBlockStats)
+
+	w := performRequest(router, http.MethodGet, "/filtering/stats/lists", "")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	pe := filtering.NewPolicyEngine(filtering.PolicyEngineConfig{
+		Enabled:          true,
+		BlockAction:      filtering.ActionBlock,
+		BlacklistDomains: []string{"ads.example.com"},
+	})
+	defer pe.Close()
+	h.SetPolicyEngine(pe)
+	pe.Evaluate("ads.example.com")
+	pe.Evaluate("cdn.ads.example.com")
+
+	w = performRequest(router, http.MethodGet, "/filtering/stats/lists?top=1", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp models.ListBlockStatsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Lists, 1)
+	assert.Equal(t, "blacklist", resp.Lists[0].Name)
+	assert.Equal(t, uint64(2), resp.Lists[0].Blocked)
+	assert.Len(t, resp.Lists[0].TopDomains, 1)
+
+	w = performRequest(router, http.MethodGet, "/filtering/stats/lists?top=1000", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCheckFiltering(t *testing.T) {
+	cfg := &config.Config{CaptivePortal: config.CaptivePortalConfig{
+		Enabled:      true,
+		Clients:      []string{"10.99.0.0/16"},
+		IPv4:         "10.99.0.1",
+		AllowDomains: []string{"portal.example.net"},
+	}}
+	h := handlers.New(cfg, nil, nil)
+	router := gin.New()
+	router.GET("/filtering/check", h.CheckFiltering)
+
+	w := performRequest(router, http.MethodGet, "/filtering/check?domain=ads.example.com", "")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	pe := filtering.NewPolicyEngine(filtering.PolicyEngineConfig{
+		Enabled:          true,
+		BlockAction:      filtering.ActionBlock,
+		WhitelistDomains: []string{"ok.ads.example.com"},
+		BlacklistDomains: []string{"ads.example.com"},
+	})
+	defer pe.Close()
+	h.SetPolicyEngine(pe)
+
+	check := func(query string) models.FilteringCheckResponse {
+		w := performRequest(router, http.MethodGet, "/filtering/check?"+query, "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var resp models.FilteringCheckResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp
+	}
+
+	resp := check("domain=CDN.Ads.Example.com.")
+	assert.Equal(t, "cdn.ads.example.com", resp.Domain)
+	assert.True(t, resp.Blocked)
+	assert.Equal(t, "block", resp.Action)
+	assert.Equal(t, "blacklist", resp.Reason)
+	assert.Equal(t, "blacklist", resp.List)
+	assert.Equal(t, []string{"blacklist"}, resp.Lists)
+
+	resp = check("domain=ok.ads.example.com&client=192.168.1.20")
+	assert.False(t, resp.Blocked)
+	assert.Equal(t, "whitelist", resp.Reason)
+	assert.Equal(t, []string{"blacklist"}, resp.Lists, "lists that would block a whitelisted domain")
+
+	resp = check("domain=www.example.org&client=10.99.1.2")
+	assert.True(t, resp.Blocked)
+	assert.Equal(t, "redirect", resp.Action)
+	assert.Equal(t, "captive_portal", resp.Reason)
+
+	resp = check("domain=login.portal.example.net&client=10.99.1.2")
+	assert.False(t, resp.Blocked)
+	assert.Equal(t, "no_match", resp.Reason)
+
+	assert.Equal(t, uint64(0), pe.Stats().QueriesTotal, "checks are not counted")
+
+	w = performRequest(router, http.MethodGet, "/filtering/check?domain=example.com&client=nope", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = performRequest(router, http.MethodGet, "/filtering/check", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetBlacklist_SearchSortAndPage(t *testing.T) {
+	h := createTestHandler(t)
+	router := gin.New()
+	router.GET("/filtering/blacklist", h.GetBlacklist)
+	router.POST("/filtering/blacklist", h.AddBlacklist)
+
+	for _, d := range []string{"tracker.example.com", "ads.example.com", "ads.example.net", "metrics.example.com"} {
+		w := performRequest(router, http.MethodPost, "/filtering/blacklist", `{"domains":["`+d+`"]}`)
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	get := func(query string) models.DomainListResponse {
+		w := performRequest(router, http.MethodGet, "/filtering/blacklist?"+query, "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var resp models.DomainListResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp
+	}
+
+	resp := get("")
+	assert.Equal(t, []string{"ads.example.com", "ads.example.net", "metrics.example.com", "tracker.example.com"}, resp.Domains)
+	assert.Equal(t, 4, resp.Total)
+
+	resp = get("search=ADS")
+	assert.Equal(t, []string{"ads.example.com", "ads.example.net"}, resp.Domains)
+	assert.Equal(t, 2, resp.Matched)
+
+	resp = get(`regex=\.com$&sort=added&order=desc&offset=1&limit=1`)
+	assert.Equal(t, []string{"ads.example.com"}, resp.Domains, "second newest .com domain")
+	assert.Equal(t, 4, resp.Total)
+	assert.Equal(t, 3, resp.Matched)
+	assert.Equal(t, 1, resp.Count)
+
+	assert.Empty(t, get("offset=10").Domains)
+
+	for _, query := range []string{"regex=(", "sort=size", "order=up", "limit=-1", "offset=x"} {
+		w := performRequest(router, http.MethodGet, "/filtering/blacklist?"+query, "")
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
 func TestAddWhitelist_NoPolicyEngine(t *testing.T) {
 	h := createTestHandler(t)
 	router := gin.New()
@@ -188,6 +432,22 @@ func TestAddWhitelist_Success(t *testing.T) {
 	assert.GreaterOrEqual(t, resp.Count, 2)
 }
 
+func TestAddWhitelist_RejectsListedDomain(t *testing.T) {
+	h := createTestHandler(t)
+	router := gin.New()
+	router.POST("/filtering/whitelist", h.AddWhitelist)
+
+	w := performRequest(router, http.MethodPost, "/filtering/whitelist", `{"domains":["example.com"]}`)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = performRequest(router, http.MethodPost, "/filtering/whitelist", `{"domains":["new.example","Example.COM."]}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	entries, err := h.DB().GetWhitelistEntries(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "a rejected request adds none of its domains")
+}
+
 func TestAddWhitelist_InvalidJSON(t *testing.T) {
 	h := createTestHandler(t)
 	pe := filtering.NewPolicyEngine(filtering.PolicyEngineConfig{Enabled: true})
@@ -326,6 +586,270 @@ func TestSetFilteringEnabled_Success(t *testing.T) {
 	assert.Equal(t, "ok", resp.Status)
 }
 
+func TestFilteringTTLs(t *testing.T) {
+	h := createTestHandler(t)
+	pe := filtering.NewPolicyEngine(filtering.PolicyEngineConfig{Enabled: true, BlockTTL: 10, NegativeTTL: 10})
+	defer pe.Close()
+	h.SetPolicyEngine(pe)
+
+	router := gin.New()
+	router.GET("/filtering/ttl", h.GetFilteringTTLs)
+	router.PUT("/filtering/ttl", h.SetFilteringTTLs)
+
+	w := performRequest(router, http.MethodPut, "/filtering/ttl", `{"block_ttl":60,"negative_ttl":0}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = performRequest(router, http.MethodPut, "/filtering/ttl", `{"block_ttl":60,"negative_ttl":30}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	blockTTL, negativeTTL := pe.BlockTTLs()
+	assert.Equal(t, uint32(60), blockTTL)
+	assert.Equal(t, uint32(30), negativeTTL)
+
+	stored, err := h.DB().GetFilteringConfig(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 60, stored.BlockTTL)
+	assert.Equal(t, 30, stored.NegativeTTL)
+
+	w = performRequest(router, http.MethodGet, "/filtering/ttl", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp models.FilteringTTLs
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, models.FilteringTTLs{BlockTTL: 60, NegativeTTL: 30}, resp)
+}
+
+func TestPrivacy(t *testing.T) {
+	h := createTestHandler(t)
+	var applied config.PrivacyConfig
+	h.SetPrivacyFunc(func(p config.PrivacyConfig) { applied = p })
+
+	router := gin.New()
+	router.GET("/privacy", h.GetPrivacy)
+	router.PUT("/privacy", h.SetPrivacy)
+
+	w := performRequest(router, http.MethodPut, "/privacy", `{"level":"partial"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = performRequest(router, http.MethodPut, "/privacy", `{"level":"anonymized"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, config.PrivacyConfig{Level: "anonymized", Anonymize: "truncate"}, applied)
+
+	stored, err := h.DB().GetPrivacyConfig(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, applied, *stored)
+
+	w = performRequest(router, http.MethodGet, "/privacy", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp models.PrivacyConfig
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, models.PrivacyConfig{Level: "anonymized", Anonymize: "truncate"}, resp)
+}
+
+func TestGetAdvertise(t *testing.T) {
+	h := createTestHandler(t)
+	router := gin.New()
+	router.GET("/advertise", h.GetAdvertise)
+
+	stored, err := h.DB().GetAdvertiseConfig(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, config.AdvertiseConfig{Interval: "30s"}, *stored)
+
+	// Disabled
+	w := performRequest(router, http.MethodGet, "/advertise", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp models.AdvertiseResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.False(t, resp.Enabled)
+	assert.False(t, resp.Coverage.Enabled)
+	assert.NotNil(t, resp.Interfaces)
+	assert.NotNil(t, resp.Coverage.Uncovered)
+
+	changed := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	h.SetAdvertiseFunc(func() *handlers.AdvertiseSnapshot {
+		return &handlers.AdvertiseSnapshot{
+			Port:        53,
+			Interfaces:  []handlers.AdvertiseInterfaceSnapshot{{Name: "eth0", IPv4: []string{"192.168.1.2"}}},
+			Changed:     changed,
+			LastAttempt: changed,
+			LastError:   "webhook returned 502 Bad Gateway",
+		}
+	})
+	h.SetCoverageFunc(func() *handlers.CoverageSnapshot {
+		return &handlers.CoverageSnapshot{
+			ClientSubnets: []string{"192.168.1.0/24"},
+			Uncovered:     []handlers.UncoveredSubnetSnapshot{{Subnet: "10.0.5.0/24", Queries: 3, FirstSeen: changed, LastSeen: changed}},
+		}
+	})
+	w = performRequest(router, http.MethodGet, "/advertise", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	resp = models.AdvertiseResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.True(t, resp.Enabled)
+	assert.False(t, resp.Delivered)
+	assert.Equal(t, "webhook returned 502 Bad Gateway", resp.LastError)
+	assert.Nil(t, resp.LastSuccess)
+	require.Len(t, resp.Interfaces, 1)
+	assert.Equal(t, []string{"192.168.1.2"}, resp.Interfaces[0].IPv4)
+	assert.NotNil(t, resp.Interfaces[0].IPv6)
+	assert.True(t, resp.Coverage.Enabled)
+	require.Len(t, resp.Coverage.Uncovered, 1)
+	assert.Equal(t, uint64(3), resp.Coverage.Uncovered[0].Queries)
+}
+
+func TestGetFilteringRPZ_NoPolicyEngine(t *testing.T) {
+	h := createTestHandler(t)
+	router := gin.New()
+	router.GET("/filtering/rpz", h.GetFilteringRPZ)
+
+	w := performRequest(router, http.MethodGet, "/filtering/rpz", "")
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestGetFilteringRPZ_Success(t *testing.T) {
+	h := createTestHandler(t)
+	pe := filtering.NewPolicyEngine(filtering.PolicyEngineConfig{
+		Enabled:          true,
+		BlacklistDomains: []string{"ads.example.com"},
+	})
+	defer pe.Close()
+	h.SetPolicyEngine(pe)
+
+	router := gin.New()
+	router.GET("/filtering/rpz", h.GetFilteringRPZ)
+
+	w := performRequest(router, http.MethodGet, "/filtering/rpz?zone=policy.lan", "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/dns")
+	assert.Contains(t, w.Body.String(), "$ORIGIN policy.lan.\n")
+	assert.Contains(t, w.Body.String(), "ads.example.com CNAME .\n")
+}
+
+func TestGetFilteringRPZ_InvalidZone(t *testing.T) {
+	h := createTestHandler(t)
+	pe := filtering.NewPolicyEngine(filtering.PolicyEngineConfig{Enabled: true})
+	defer pe.Close()
+	h.SetPolicyEngine(pe)
+
+	router := gin.New()
+	router.GET("/filtering/rpz", h.GetFilteringRPZ)
+
+	w := performRequest(router, http.MethodGet, "/filtering/rpz?zone=bad..zone", "")
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+}
+
+func TestAllowTemporarily_Success(t *testing.T) {
+	h := createTestHandler(t)
+	pe := filtering.NewPolicyEngine(filtering.PolicyEngineConfig{
+		Enabled:          true,
+		BlockAction:      filtering.ActionBlock,
+		BlacklistDomains: []string{"ads.example.com"},
+	})
+	defer pe.Close()
+	h.SetPolicyEngine(pe)
+
+	var purged []string
+	h.SetCachePurgeFunc(func(name string) int {
+		purged = append(purged, name)
+		return 2
+	})
+
+	router := gin.New()
+	router.POST("/filtering/allow-temporarily", h.AllowTemporarily)
+
+	before := time.Now()
+	w := performRequest(router, http.MethodPost, "/filtering/allow-temporarily",
+		`{"domain":"Ads.Example.com.","duration":"10m"}`)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp models.TemporaryAllowResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "ads.example.com", resp.Domain)
+	assert.Equal(t, 2, resp.CachePurged)
+	assert.WithinDuration(t, before.Add(10*time.Minute), resp.ExpiresAt, 5*time.Second)
+	assert.Equal(t, []string{"ads.example.com"}, purged)
+	assert.Equal(t, filtering.ActionAllow, pe.Evaluate("ads.example.com").Action)
+
+	// The allow is stored apart from the whitelist and survives a rebuild
+	ctx := context.Background()
+	allows, err := h.DB().GetTemporaryAllows(ctx)
+	require.NoError(t, err)
+	assert.True(t, resp.ExpiresAt.Equal(allows["ads.example.com"]))
+	whitelist, err := h.DB().GetWhitelistDomains(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, whitelist)
+
+	rebuilt := filtering.NewPolicyEngine(filtering.PolicyEngineConfig{
+		Enabled:          true,
+		BlockAction:      filtering.ActionBlock,
+		BlacklistDomains: []string{"ads.example.com"},
+		TemporaryAllows:  allows,
+	})
+	defer rebuilt.Close()
+	assert.Equal(t, filtering.ActionAllow, rebuilt.Evaluate("ads.example.com").Action)
+	assert.Equal(t, filtering.ActionBlock, rebuilt.Evaluate("sub.ads.example.com").Action)
+}
+
+func TestAllowTemporarily_WhitelistTakesPrecedence(t *testing.T) {
+	h := createTestHandler(t)
+	pe := filtering.NewPolicyEngine(filtering.PolicyEngineConfig{Enabled: true})
+	defer pe.Close()
+	h.SetPolicyEngine(pe)
+	ctx := context.Background()
+
+	router := gin.New()
+	router.POST("/filtering/allow-temporarily", h.AllowTemporarily)
+	router.POST("/filtering/whitelist", h.AddWhitelist)
+
+	// Whitelisting a temporarily allowed domain makes it permanent
+	w := performRequest(router, http.MethodPost, "/filtering/allow-temporarily",
+		`{"domain":"a.example.com","duration":"10m"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	w = performRequest(router, http.MethodPost, "/filtering/whitelist", `{"domains":["a.example.com"]}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// A temporary allow leaves a whitelisted domain permanent
+	w = performRequest(router, http.MethodPost, "/filtering/allow-temporarily",
+		`{"domain":"a.example.com","duration":"10m"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	allows, err := h.DB().GetTemporaryAllows(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, allows)
+	whitelist, err := h.DB().GetWhitelistDomains(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.example.com"}, whitelist)
+}
+
+func TestAllowTemporarily_InvalidDuration(t *testing.T) {
+	h := createTestHandler(t)
+	pe := filtering.NewPolicyEngine(filtering.PolicyEngineConfig{Enabled: true})
+	defer pe.Close()
+	h.SetPolicyEngine(pe)
+
+	router := gin.New()
+	router.POST("/filtering/allow-temporarily", h.AllowTemporarily)
+
+	for _, d := range []string{"soon", "0s", "-5m", "48h"} {
+		w := performRequest(router, http.MethodPost, "/filtering/allow-temporarily",
+			`{"domain":"ads.example.com","duration":"`+d+`"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code, d)
+	}
+}
+
+func TestAllowTemporarily_NoPolicyEngine(t *testing.T) {
+	h := createTestHandler(t)
+	router := gin.New()
+	router.POST("/filtering/allow-temporarily", h.AllowTemporarily)
+
+	w := performRequest(router, http.MethodPost, "/filtering/allow-temporarily",
+		`{"domain":"ads.example.com","duration":"10m"}`)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
 // ============================================================================
 // Config Endpoint Tests
 // ============================================================================
@@ -346,6 +870,65 @@ func TestGetConfig_Success(t *testing.T) {
 	assert.Equal(t, 5353, resp.Server.Port)
 }
 
+func TestGetConfig_RedactsThreatIntelAuthKey(t *testing.T) {
+	cfg := &config.Config{
+		ThreatIntel: config.ThreatIntelConfig{
+			Enabled:  true,
+			Provider: "urlhaus",
+			AuthKey:  "s3cret",
+		},
+	}
+	h := handlers.New(cfg, nil, nil)
+	router := gin.New()
+	router.GET("/config", h.GetConfig)
+
+	w := performRequest(router, http.MethodGet, "/config", "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "s3cret")
+	assert.NotContains(t, w.Body.String(), "auth_key")
+}
+
+func TestGetConfig_RedactsAdvertiseWebhookSecret(t *testing.T) {
+	cfg := &config.Config{
+		Advertise: config.AdvertiseConfig{
+			Enabled:       true,
+			WebhookURL:    "https://router.lan/hooks/dns",
+			WebhookSecret: "s3cret",
+		},
+	}
+	h := handlers.New(cfg, nil, nil)
+	router := gin.New()
+	router.GET("/config", h.GetConfig)
+
+	w := performRequest(router, http.MethodGet, "/config", "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "s3cret")
+	assert.Contains(t, w.Body.String(), "https://router.lan/hooks/dns")
+	assert.Equal(t, "s3cret", cfg.Advertise.WebhookSecret, "the handler's config is not modified")
+}
+
+func TestGetConfig_RedactsSharedCachePassword(t *testing.T) {
+	cfg := &config.Config{
+		Cache: config.CacheConfig{Shared: config.SharedCacheConfig{
+			Backend:  config.SharedCacheRedis,
+			Address:  "10.0.0.5:6379",
+			Password: "s3cret",
+		}},
+	}
+	h := handlers.New(cfg, nil, nil)
+	router := gin.New()
+	router.GET("/config", h.GetConfig)
+
+	w := performRequest(router, http.MethodGet, "/config", "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "s3cret")
+	assert.Contains(t, w.Body.String(), "10.0.0.5:6379")
+	assert.Equal(t, "s3cret", cfg.Cache.Shared.Password, "the handler's config is not modified")
+}
+
 func TestPutConfig_NotImplemented(t *testing.T) {
 	h := createTestHandler(t)
 	router := gin.New()
@@ -13,7 +13,7 @@ import (
 
 // Health godoc
 // @Summary Health check
-// @Description Returns server health status
+// @Description Liveness check: returns ok while the process is up, including during startup and shutdown. Use /ready to decide whether to send traffic.
 // @Tags system
 // @Produce json
 // @Success 200 {object} models.StatusResponse
@@ -22,6 +22,90 @@ func (h *Handler) Health(c *gin.Context) {
 	c.JSON(http.StatusOK, models.StatusResponse{Status: "ok"})
 }
 
+// Ready godoc
+// @Summary Readiness check
+// @Description Readiness check for load balancers and orchestrators: returns 503 with the reasons while components are starting, blocklists are still loading for the first time, or the server is draining for shutdown. Needs no API key.
+// @Tags system
+// @Produce json
+// @Success 200 {object} models.ReadinessResponse
+// @Failure 503 {object} models.ReadinessResponse
+// @Router /ready [get]
+func (h *Handler) Ready(c *gin.Context) {
+	var reasons []string
+	if fn := h.GetReadinessFunc(); fn != nil {
+		reasons = fn()
+	}
+	if len(reasons) > 0 {
+		c.JSON(http.StatusServiceUnavailable, models.ReadinessResponse{Status: "not_ready", Reasons: reasons})
+		return
+	}
+	c.JSON(http.StatusOK, models.ReadinessResponse{Status: "ready"})
+}
+
+// DeepHealth godoc
+// @Summary Deep health check
+// @Description Reports the state of each server component and whether DNS resolution works, from the health canary's latest self-queries. Returns 503 once no canary domain has resolved for canary.failure_threshold rounds in a row. An optional component that failed makes the status degraded.
+// @Tags system
+// @Produce json
+// @Success 200 {object} models.DeepHealthResponse
+// @Failure 503 {object} models.DeepHealthResponse
+// @Security ApiKeyAuth
+// @Router /health/deep [get]
+func (h *Handler) DeepHealth(c *gin.Context) {
+	resp := models.DeepHealthResponse{Status: "ok"}
+
+	if fn := h.GetComponentsFunc(); fn != nil {
+		for _, comp := range fn() {
+			resp.Components = append(resp.Components, models.ComponentHealth{
+				Name:     comp.Name,
+				Required: comp.Required,
+				State:    comp.State,
+				Error:    comp.Error,
+				Since:    comp.Since,
+			})
+			if comp.State == "failed" {
+				resp.Status = "degraded"
+			}
+		}
+	}
+
+	var snap *CanarySnapshot
+	if fn := h.GetCanaryFunc(); fn != nil {
+		snap = fn()
+	}
+	if snap == nil {
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+
+	canary := &models.CanaryStatus{
+		Healthy:      snap.Healthy,
+		LastRound:    optionalTime(snap.LastRound),
+		FailedRounds: snap.FailedRounds,
+		Checks:       make([]models.CanaryCheck, 0, len(snap.Checks)),
+	}
+	for _, ch := range snap.Checks {
+		canary.Checks = append(canary.Checks, models.CanaryCheck{
+			Domain:              ch.Domain,
+			OK:                  ch.OK,
+			RCode:               ch.RCode,
+			LatencyMs:           float64(ch.Latency.Microseconds()) / 1000,
+			Error:               ch.Error,
+			CheckedAt:           optionalTime(ch.CheckedAt),
+			LastSuccess:         optionalTime(ch.LastSuccess),
+			ConsecutiveFailures: ch.ConsecutiveFailures,
+		})
+	}
+	resp.Canary = canary
+
+	if !snap.Healthy {
+		resp.Status = "failing"
+		c.JSON(http.StatusServiceUnavailable, resp)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
 // Stats godoc
 // @Summary Server statistics
 // @Description Returns runtime statistics including system CPU usage, memory usage, and DNS metrics
@@ -58,6 +142,7 @@ func (h *Handler) Stats(c *gin.Context) {
 		CPU:           cpuStats,
 		Memory:        memStats,
 		DNSStats:      h.getDNSStats(),
+		APIPanics:     h.apiPanics.Load(),
 	}
 
 	pe := h.GetPolicyEngine()
@@ -84,12 +169,77 @@ func (h *Handler) getDNSStats() models.DNSStatsResponse {
 		return models.DNSStatsResponse{}
 	}
 	snapshot := fn()
-	return models.DNSStatsResponse{
+	resp := models.DNSStatsResponse{
 		QueriesTotal: snapshot.QueriesTotal,
 		QueriesUDP:   snapshot.QueriesUDP,
 		QueriesTCP:   snapshot.QueriesTCP,
 		ResponsesNX:  snapshot.ResponsesNX,
 		ResponsesErr: snapshot.ResponsesErr,
+		Panics:       snapshot.Panics,
 		AvgLatencyMs: snapshot.AvgLatencyMs,
 	}
+	if len(snapshot.TCPConnsByIP) > 0 {
+		resp.TCPConnections = snapshot.TCPConnsByIP
+	}
+	if len(snapshot.Anomalies) > 0 {
+		resp.Anomalies = snapshot.Anomalies
+	}
+	if len(snapshot.QTypeRules) > 0 {
+		resp.QTypeRules = snapshot.QTypeRules
+	}
+	if c := snapshot.Cache; c != nil {
+		resp.Cache = &models.CacheStats{
+			EvictionPolicy: c.EvictionPolicy,
+			Entries:        c.Entries,
+			MaxEntries:     c.MaxEntries,
+			Bytes:          c.Bytes,
+			MaxBytes:       c.MaxBytes,
+			Oversized:      c.Oversized,
+			Hits:           c.Hits,
+			Misses:         c.Misses,
+			NegativeHits:   c.NegativeHits,
+			Evictions:      c.Evictions,
+			Expirations:    c.Expirations,
+			HitRatio:       c.HitRatio,
+		}
+		if sc := c.Shared; sc != nil {
+			resp.Cache.Shared = &models.SharedCacheStats{
+				Backend: sc.Backend,
+				Hits:    sc.Hits,
+				Misses:  sc.Misses,
+				Errors:  sc.Errors,
+				Stores:  sc.Stores,
+				Dropped: sc.Dropped,
+			}
+		}
+	}
+	for _, l := range snapshot.UDPListeners {
+		resp.UDPListeners = append(resp.UDPListeners, models.UDPListenerStats{
+			Listener:       l.Listener,
+			PacketsRead:    l.PacketsRead,
+			PacketsHandled: l.PacketsHandled,
+			PacketsDropped: l.PacketsDropped,
+			RateLimited:    l.RateLimited,
+			Slipped:        l.Slipped,
+			Duplicates:     l.Duplicates,
+			QueueDepth:     l.QueueDepth,
+			QueueCapacity:  l.QueueCapacity,
+			KernelDrops:    l.KernelDrops,
+			RecvQueueBytes: l.RecvQueueBytes,
+			RecvBuffer:     l.RecvBuffer,
+			SendBuffer:     l.SendBuffer,
+		})
+	}
+	for transport, p := range snapshot.Parse {
+		if resp.Parse == nil {
+			resp.Parse = make(map[string]models.ParseStats, len(snapshot.Parse))
+		}
+		resp.Parse[transport] = models.ParseStats{
+			Malformed:         p.Malformed,
+			PointerLoops:      p.PointerLoops,
+			OversizedNames:    p.OversizedNames,
+			OversizedMessages: p.OversizedMessages,
+		}
+	}
+	return resp
 }
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jroosing/hydradns/internal/api/models"
+	"github.com/jroosing/hydradns/internal/logging"
+)
+
+// maxTemporaryLogLevel caps how long a temporary level change lasts.
+const maxTemporaryLogLevel = 24 * time.Hour
+
+// GetLogging godoc
+// @Summary Get log level and format
+// @Description Returns the current log level and format, and when a temporary level change ends
+// @Tags system
+// @Produce json
+// @Success 200 {object} models.LoggingResponse
+// @Failure 503 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /logging [get]
+func (h *Handler) GetLogging(c *gin.Context) {
+	ctl := h.GetLogController()
+	if ctl == nil {
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Error: "log control not available"})
+		return
+	}
+	c.JSON(http.StatusOK, loggingResponse(ctl.Status()))
+}
+
+// SetLogging godoc
+// @Summary Change log level and format
+// @Description Changes the log level and switches between structured and plain text output without a restart, and saves them. With a duration, only the level changes, on this node and for that long (at most 24h), without being saved; the previous level is then restored. Setting a level ends a temporary change.
+// @Tags system
+// @Accept json
+// @Produce json
+// @Param logging body models.LoggingRequest true "Level, format, and optional duration"
+// @Success 200 {object} models.LoggingResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /logging [put]
+func (h *Handler) SetLogging(c *gin.Context) {
+	ctl := h.GetLogController()
+	if ctl == nil {
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Error: "log control not available"})
+		return
+	}
+
+	var req models.LoggingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if req.Level == "" && req.Structured == nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "set level or structured"})
+		return
+	}
+	var level slog.Level
+	if req.Level != "" {
+		var err error
+		if level, err = logging.ParseLevel(req.Level); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+			return
+		}
+	}
+
+	if req.Duration != "" {
+		if req.Level == "" || req.Structured != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "duration applies to a level change only"})
+			return
+		}
+		d, err := time.ParseDuration(req.Duration)
+		if err != nil || d <= 0 || d > maxTemporaryLogLevel {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error: "duration must be a positive duration of at most " + maxTemporaryLogLevel.String(),
+			})
+			return
+		}
+		revertAt := ctl.SetLevelFor(level, d)
+		if h.logger != nil {
+			h.logger.Info("log level changed temporarily", "level", level, "until", revertAt)
+		}
+		c.JSON(http.StatusOK, loggingResponse(ctl.Status()))
+		return
+	}
+
+	// Save the level a temporary change would restore, not the temporary one
+	current := ctl.Status()
+	saved, structured := current.Level, current.Structured
+	if !current.RevertAt.IsZero() {
+		saved = current.RevertLevel
+	}
+	if req.Level != "" {
+		saved = level
+	}
+	if req.Structured != nil {
+		structured = *req.Structured
+	}
+	if h.db != nil {
+		if err := h.db.SetLoggingLevel(c.Request.Context(), saved.String(), structured); err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "failed to persist setting: " + err.Error()})
+			return
+		}
+	}
+	if h.cfg != nil {
+		h.mu.Lock()
+		h.cfg.Logging.Level = saved.String()
+		h.cfg.Logging.Structured = structured
+		h.mu.Unlock()
+	}
+
+	if req.Level != "" {
+		ctl.SetLevel(level)
+	}
+	if req.Structured != nil {
+		ctl.SetStructured(structured)
+	}
+	if h.logger != nil {
+		h.logger.Info("logging changed", "level", saved, "structured", structured)
+	}
+	c.JSON(http.StatusOK, loggingResponse(ctl.Status()))
+}
+
+// loggingResponse converts a log level status to its API model.
+func loggingResponse(s logging.LevelStatus) models.LoggingResponse {
+	out := models.LoggingResponse{Level: s.Level.String(), Structured: s.Structured}
+	if !s.RevertAt.IsZero() {
+		out.RevertAt = &s.RevertAt
+		out.RevertLevel = s.RevertLevel.String()
+	}
+	return out
+}
@@ -0,0 +1,107 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jroosing/hydradns/internal/api/handlers"
+	"github.com/jroosing/hydradns/internal/api/models"
+	"github.com/jroosing/hydradns/internal/config"
+	"github.com/jroosing/hydradns/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loggingRouter serves the logging endpoints with ctl.
+func loggingRouter(cfg *config.Config, ctl *logging.Controller) *gin.Engine {
+	h := handlers.New(cfg, nil, nil)
+	if ctl != nil {
+		h.SetLogController(ctl)
+	}
+	router := gin.New()
+	router.GET("/logging", h.GetLogging)
+	router.PUT("/logging", h.SetLogging)
+	return router
+}
+
+func TestLogging_SetLevelAndFormat(t *testing.T) {
+	cfg := &config.Config{}
+	ctl := logging.NewController(logging.Config{Level: "INFO", StructuredFormat: "json", Output: &bytes.Buffer{}})
+	router := loggingRouter(cfg, ctl)
+
+	w := performRequest(router, http.MethodGet, "/logging", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp models.LoggingResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, models.LoggingResponse{Level: "INFO"}, resp)
+
+	w = performRequest(router, http.MethodPut, "/logging", `{"level":"debug","structured":true}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, models.LoggingResponse{Level: "DEBUG", Structured: true}, resp)
+	assert.Equal(t, slog.LevelDebug, ctl.Status().Level)
+	assert.Equal(t, "DEBUG", cfg.Logging.Level)
+	assert.True(t, cfg.Logging.Structured)
+}
+
+func TestLogging_TemporaryLevel(t *testing.T) {
+	cfg := &config.Config{Logging: config.LoggingConfig{Level: "WARN"}}
+	ctl := logging.NewController(logging.Config{Level: "WARN", Output: &bytes.Buffer{}})
+	router := loggingRouter(cfg, ctl)
+
+	w := performRequest(router, http.MethodPut, "/logging", `{"level":"DEBUG","duration":"15m"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp models.LoggingResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "DEBUG", resp.Level)
+	assert.Equal(t, "WARN", resp.RevertLevel)
+	require.NotNil(t, resp.RevertAt)
+	assert.Equal(t, "WARN", cfg.Logging.Level, "temporary changes are not saved")
+
+	// Changing only the format keeps the temporary level running and saves
+	// the level it reverts to
+	w = performRequest(router, http.MethodPut, "/logging", `{"structured":true}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, slog.LevelDebug, ctl.Status().Level)
+	assert.Equal(t, "WARN", cfg.Logging.Level)
+
+	// Setting a level ends it
+	w = performRequest(router, http.MethodPut, "/logging", `{"level":"ERROR"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	resp = models.LoggingResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Nil(t, resp.RevertAt)
+	assert.Equal(t, "ERROR", cfg.Logging.Level)
+}
+
+func TestLogging_RejectsInvalidRequests(t *testing.T) {
+	ctl := logging.NewController(logging.Config{Level: "INFO", Output: &bytes.Buffer{}})
+	router := loggingRouter(&config.Config{}, ctl)
+
+	for _, body := range []string{
+		`{}`,
+		`{"level":"verbose"}`,
+		`{"duration":"10m"}`,
+		`{"level":"DEBUG","structured":true,"duration":"10m"}`,
+		`{"level":"DEBUG","duration":"0s"}`,
+		`{"level":"DEBUG","duration":"25h"}`,
+		`{"level":"DEBUG","duration":"soon"}`,
+	} {
+		w := performRequest(router, http.MethodPut, "/logging", body)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+	assert.Equal(t, slog.LevelInfo, ctl.Status().Level)
+}
+
+func TestLogging_UnavailableWithoutController(t *testing.T) {
+	router := loggingRouter(&config.Config{}, nil)
+
+	w := performRequest(router, http.MethodGet, "/logging", "")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	w = performRequest(router, http.MethodPut, "/logging", `{"level":"DEBUG"}`)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jroosing/hydradns/internal/api/models"
+	"github.com/jroosing/hydradns/internal/config"
+)
+
+// GetPrivacy godoc
+// @Summary Get query log privacy
+// @Description Returns what the query logs, live query events, and anomaly alerts keep about clients and query names
+// @Tags system
+// @Produce json
+// @Success 200 {object} models.PrivacyConfig
+// @Failure 500 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /privacy [get]
+func (h *Handler) GetPrivacy(c *gin.Context) {
+	if h.cfg == nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "config unavailable"})
+		return
+	}
+
+	h.mu.RLock()
+	p := h.cfg.Privacy
+	h.mu.RUnlock()
+
+	c.JSON(http.StatusOK, models.PrivacyConfig{Level: p.Level, Anonymize: p.Anonymize})
+}
+
+// SetPrivacy godoc
+// @Summary Set query log privacy
+// @Description Changes what the query logs, live query events, and anomaly alerts keep about clients and query names. Recorded anomaly alerts are redacted again at the new level, so raising it also anonymizes them.
+// @Tags system
+// @Accept json
+// @Produce json
+// @Param privacy body models.PrivacyConfig true "Privacy level"
+// @Success 200 {object} models.PrivacyConfig
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /privacy [put]
+func (h *Handler) SetPrivacy(c *gin.Context) {
+	if h.cfg == nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "config unavailable"})
+		return
+	}
+
+	var req models.PrivacyConfig
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	p := config.PrivacyConfig{Level: req.Level, Anonymize: req.Anonymize}
+	if p.Anonymize == "" {
+		p.Anonymize = "truncate"
+	}
+
+	if h.db != nil {
+		if err := h.db.SetPrivacyConfig(c.Request.Context(), &p); err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "failed to persist setting: " + err.Error()})
+			return
+		}
+	}
+
+	h.mu.Lock()
+	h.cfg.Privacy = p
+	fn := h.privacyFunc
+	h.mu.Unlock()
+	if fn != nil {
+		fn(p)
+	}
+
+	if h.logger != nil {
+		h.logger.Info("query log privacy changed", "level", p.Level, "anonymize", p.Anonymize)
+	}
+
+	c.JSON(http.StatusOK, models.PrivacyConfig{Level: p.Level, Anonymize: p.Anonymize})
+}
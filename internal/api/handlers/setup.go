@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jroosing/hydradns/internal/api/models"
+	"github.com/jroosing/hydradns/internal/database"
+)
+
+// GetSetupStatus godoc
+// @Summary First-run setup status
+// @Description Reports whether first-run setup is pending. A fresh database is
+// @Description bootstrapped with a generated API key, printed once at startup;
+// @Description this endpoint does not require it.
+// @Tags setup
+// @Produce json
+// @Success 200 {object} models.SetupStatusResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
+// @Router /setup [get]
+func (h *Handler) GetSetupStatus(c *gin.Context) {
+	if h.db == nil {
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Error: "database not available"})
+		return
+	}
+
+	pending, err := h.db.SetupPending(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.SetupStatusResponse{SetupPending: pending})
+}
+
+// PostSetup godoc
+// @Summary Complete first-run setup
+// @Description Applies the initial upstream servers and filtering state and marks
+// @Description first-run setup as completed. Can only be called once. On a secondary,
+// @Description upstreams and filtering come from the primary and cannot be set here.
+// @Tags setup
+// @Accept json
+// @Produce json
+// @Param setup body models.SetupRequest true "Initial configuration"
+// @Success 200 {object} models.SetupStatusResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /setup [post]
+func (h *Handler) PostSetup(c *gin.Context) {
+	if h.db == nil {
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Error: "database not available"})
+		return
+	}
+	ctx := c.Request.Context()
+
+	pending, err := h.db.SetupPending(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if !pending {
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: database.ErrSetupCompleted.Error()})
+		return
+	}
+
+	var req models.SetupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request: " + err.Error()})
+		return
+	}
+
+	// Upstreams and filtering are synced from the primary on a secondary
+	if (req.Upstreams != nil || req.FilteringEnabled != nil) && h.abortIfSecondary(c) {
+		return
+	}
+
+	var servers []string
+	if req.Upstreams != nil {
+		if servers, err = validateUpstreams(req.Upstreams); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+			return
+		}
+	}
+
+	// Claim setup and store the settings atomically, so a concurrent
+	// request that loses gets 409 without overwriting them
+	if err := h.db.CompleteSetup(ctx, servers, req.FilteringEnabled); err != nil {
+		if errors.Is(err, database.ErrSetupCompleted) {
+			c.JSON(http.StatusConflict, models.ErrorResponse{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to complete setup: " + err.Error()})
+		return
+	}
+
+	// Apply to the running server
+	h.mu.Lock()
+	if h.cfg != nil {
+		if servers != nil {
+			h.cfg.Upstream.Servers = slices.Clone(servers)
+		}
+		if req.FilteringEnabled != nil {
+			h.cfg.Filtering.Enabled = *req.FilteringEnabled
+		}
+	}
+	reloadFunc := h.upstreamReloadFunc
+	pe := h.policyEngine
+	h.mu.Unlock()
+
+	if pe != nil && req.FilteringEnabled != nil {
+		pe.SetEnabled(*req.FilteringEnabled)
+	}
+	if servers != nil && reloadFunc != nil {
+		if err := reloadFunc(); err != nil {
+			h.logError("failed to reload upstream servers", err)
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to apply upstreams: " + err.Error()})
+			return
+		}
+	}
+
+	if h.logger != nil {
+		h.logger.Info("first-run setup completed")
+	}
+	c.JSON(http.StatusOK, models.SetupStatusResponse{SetupPending: false})
+}
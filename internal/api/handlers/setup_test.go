@@ -0,0 +1,157 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jroosing/hydradns/internal/api/models"
+	"github.com/jroosing/hydradns/internal/config"
+	"github.com/jroosing/hydradns/internal/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupRouter(t *testing.T, cfg *config.Config, bootstrap bool) (*gin.Engine, *database.DB) {
+	t.Helper()
+	h := createCustomDNSTestHandler(t, cfg)
+	if bootstrap {
+		require.NoError(t, h.DB().Bootstrap(context.Background(), "generated-key"))
+	}
+	router := gin.New()
+	router.GET("/setup", h.GetSetupStatus)
+	router.POST("/setup", h.PostSetup)
+	return router, h.DB()
+}
+
+func TestDatabase_BootstrapPendingUntilKeyStored(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	db, err := database.Open(path)
+	require.NoError(t, err)
+	pending, err := db.BootstrapPending(ctx)
+	require.NoError(t, err)
+	assert.True(t, pending)
+	require.NoError(t, db.Close())
+
+	// A start that died before storing the key bootstraps again
+	db, err = database.Open(path)
+	require.NoError(t, err)
+	pending, err = db.BootstrapPending(ctx)
+	require.NoError(t, err)
+	assert.True(t, pending)
+	require.NoError(t, db.Bootstrap(ctx, "generated-key"))
+	require.NoError(t, db.Close())
+
+	db, err = database.Open(path)
+	require.NoError(t, err)
+	defer db.Close()
+	pending, err = db.BootstrapPending(ctx)
+	require.NoError(t, err)
+	assert.False(t, pending)
+}
+
+func TestGetSetupStatus(t *testing.T) {
+	router, _ := setupRouter(t, &config.Config{}, false)
+
+	w := performRequest(router, http.MethodGet, "/setup", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp models.SetupStatusResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.False(t, resp.SetupPending, "existing databases are not bootstrapped")
+
+	router, _ = setupRouter(t, &config.Config{}, true)
+	w = performRequest(router, http.MethodGet, "/setup", "")
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.True(t, resp.SetupPending)
+}
+
+func TestBootstrap_StoresAPIKey(t *testing.T) {
+	_, db := setupRouter(t, &config.Config{}, true)
+
+	cfg, err := db.ExportToConfig(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "generated-key", cfg.API.APIKey)
+}
+
+func TestPostSetup_AppliesConfigOnce(t *testing.T) {
+	cfg := &config.Config{Upstream: config.UpstreamConfig{Servers: []string{"9.9.9.9"}}}
+	router, db := setupRouter(t, cfg, true)
+
+	w := performRequest(router, http.MethodPost, "/setup", `{"upstreams":["1.1.1.1","8.8.8.8"],"filtering_enabled":true}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	ctx := context.Background()
+	pending, err := db.SetupPending(ctx)
+	require.NoError(t, err)
+	assert.False(t, pending)
+
+	stored, err := db.GetUpstreamServers(ctx)
+	require.NoError(t, err)
+	require.Len(t, stored, 2)
+	assert.Equal(t, "1.1.1.1", stored[0].ServerAddress)
+	enabled, err := db.GetFilteringEnabled(ctx)
+	require.NoError(t, err)
+	assert.True(t, enabled)
+	assert.Equal(t, []string{"1.1.1.1", "8.8.8.8"}, cfg.Upstream.Servers)
+	assert.True(t, cfg.Filtering.Enabled)
+
+	w = performRequest(router, http.MethodPost, "/setup", `{}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestCompleteSetup_LoserWritesNothing(t *testing.T) {
+	_, db := setupRouter(t, &config.Config{}, true)
+	ctx := context.Background()
+	enabled, disabled := true, false
+
+	require.NoError(t, db.CompleteSetup(ctx, []string{"1.1.1.1"}, &enabled))
+	err := db.CompleteSetup(ctx, []string{"9.9.9.9"}, &disabled)
+	require.ErrorIs(t, err, database.ErrSetupCompleted)
+
+	stored, err := db.GetUpstreamServers(ctx)
+	require.NoError(t, err)
+	require.Len(t, stored, 1)
+	assert.Equal(t, "1.1.1.1", stored[0].ServerAddress)
+	filtering, err := db.GetFilteringEnabled(ctx)
+	require.NoError(t, err)
+	assert.True(t, filtering)
+}
+
+func TestPostSetup_NotPending(t *testing.T) {
+	router, _ := setupRouter(t, &config.Config{}, false)
+
+	w := performRequest(router, http.MethodPost, "/setup", `{"filtering_enabled":true}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestPostSetup_InvalidUpstreamKeepsSetupPending(t *testing.T) {
+	router, db := setupRouter(t, &config.Config{}, true)
+
+	w := performRequest(router, http.MethodPost, "/setup", `{"upstreams":["dns.google"]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	pending, err := db.SetupPending(context.Background())
+	require.NoError(t, err)
+	assert.True(t, pending)
+}
+
+func TestPostSetup_SecondaryRejectsSyncedSettings(t *testing.T) {
+	cfg := &config.Config{Cluster: config.ClusterConfig{Mode: config.ClusterModeSecondary, PrimaryURL: "http://primary:8080"}}
+	router, db := setupRouter(t, cfg, true)
+
+	w := performRequest(router, http.MethodPost, "/setup", `{"upstreams":["1.1.1.1"]}`)
+	require.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "http://primary:8080")
+
+	// Completing setup without synced settings is allowed
+	w = performRequest(router, http.MethodPost, "/setup", `{}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	pending, err := db.SetupPending(context.Background())
+	require.NoError(t, err)
+	assert.False(t, pending)
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jroosing/hydradns/internal/api/middleware"
+	"github.com/jroosing/hydradns/internal/api/models"
+	"github.com/jroosing/hydradns/internal/database"
+)
+
+// tokenNamePattern restricts token names to characters that are safe in a
+// URL path segment.
+var tokenNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// ListAPITokens godoc
+// @Summary List API tokens
+// @Description Lists the scoped API tokens of this node. Token values are never returned. Requires the admin API key.
+// @Tags tokens
+// @Produce json
+// @Success 200 {object} models.APITokenListResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /tokens [get]
+func (h *Handler) ListAPITokens(c *gin.Context) {
+	tokens, err := h.db.ListAPITokens(c.Request.Context())
+	if err != nil {
+		h.logError("failed to list API tokens", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "failed to list API tokens"})
+		return
+	}
+
+	resp := models.APITokenListResponse{Tokens: make([]models.APITokenResponse, 0, len(tokens))}
+	now := time.Now()
+	for _, t := range tokens {
+		resp.Tokens = append(resp.Tokens, apiTokenResponse(t, now))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// CreateAPIToken godoc
+// @Summary Create an API token
+// @Description Creates a named API token limited to the given scopes, for automation that should not hold the admin key. The token value is only returned in this response. Requires the admin API key.
+// @Tags tokens
+// @Accept json
+// @Produce json
+// @Param request body models.CreateAPITokenRequest true "Token name, scopes, and optional expiry"
+// @Success 201 {object} models.CreateAPITokenResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse "Token name already exists"
+// @Failure 500 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /tokens [post]
+func (h *Handler) CreateAPIToken(c *gin.Context) {
+	var req models.CreateAPITokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if !tokenNamePattern.MatchString(req.Name) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "name must be 1-64 letters, digits, '.', '_' or '-'",
+		})
+		return
+	}
+	if len(req.Scopes) == 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "at least one scope is required"})
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !middleware.ValidScope(scope) {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "unknown scope: " + scope})
+			return
+		}
+	}
+
+	var expiresAt time.Time
+	if req.ExpiresIn != "" {
+		d, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || d <= 0 {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "expires_in must be a positive duration"})
+			return
+		}
+		expiresAt = time.Now().Add(d).Truncate(time.Second)
+	}
+
+	secret, err := middleware.GenerateAPIKey()
+	if err != nil {
+		h.logError("failed to generate API token", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "failed to generate API token"})
+		return
+	}
+
+	token := database.APIToken{
+		Name:      req.Name,
+		TokenHash: middleware.HashToken(secret),
+		Scopes:    req.Scopes,
+		ExpiresAt: expiresAt,
+	}
+	if err := h.db.CreateAPIToken(c.Request.Context(), token); err != nil {
+		if errors.Is(err, database.ErrAPITokenExists) {
+			c.JSON(http.StatusConflict, models.ErrorResponse{Error: "API token already exists: " + req.Name})
+			return
+		}
+		h.logError("failed to create API token", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "failed to create API token"})
+		return
+	}
+
+	if h.logger != nil {
+		h.logger.Info("API token created", "name", req.Name, "scopes", req.Scopes, "expires_at", expiresAt)
+	}
+
+	c.JSON(http.StatusCreated, models.CreateAPITokenResponse{
+		APITokenResponse: apiTokenResponse(token, time.Now()),
+		Token:            secret,
+	})
+}
+
+// DeleteAPIToken godoc
+// @Summary Delete an API token
+// @Description Revokes the named API token. Requires the admin API key.
+// @Tags tokens
+// @Produce json
+// @Param name path string true "Token name"
+// @Success 200 {object} models.StatusResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /tokens/{name} [delete]
+func (h *Handler) DeleteAPIToken(c *gin.Context) {
+	name := c.Param("name")
+	deleted, err := h.db.DeleteAPIToken(c.Request.Context(), name)
+	if err != nil {
+		h.logError("failed to delete API token", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "failed to delete API token"})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "API token not found: " + name})
+		return
+	}
+
+	if h.logger != nil {
+		h.logger.Info("API token deleted", "name", name)
+	}
+	c.JSON(http.StatusOK, models.StatusResponse{Status: "deleted"})
+}
+
+// LookupAPIToken finds an API token by hash for middleware.RequireAuth.
+// Lookup errors are logged and treated as an unknown token.
+func (h *Handler) LookupAPIToken(ctx context.Context, hash string) (middleware.Token, bool) {
+	if h.db == nil {
+		return middleware.Token{}, false
+	}
+	t, ok, err := h.db.GetAPITokenByHash(ctx, hash)
+	if err != nil {
+		h.logError("failed to look up API token", err)
+		return middleware.Token{}, false
+	}
+	if !ok {
+		return middleware.Token{}, false
+	}
+	return middleware.Token{Name: t.Name, Scopes: t.Scopes, ExpiresAt: t.ExpiresAt}, true
+}
+
+func apiTokenResponse(t database.APIToken, now time.Time) models.APITokenResponse {
+	resp := models.APITokenResponse{
+		Name:      t.Name,
+		Scopes:    t.Scopes,
+		CreatedAt: t.CreatedAt,
+	}
+	if !t.ExpiresAt.IsZero() {
+		expiresAt := t.ExpiresAt
+		resp.ExpiresAt = &expiresAt
+		resp.Expired = !now.Before(expiresAt)
+	}
+	return resp
+}
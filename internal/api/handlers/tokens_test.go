@@ -0,0 +1,105 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jroosing/hydradns/internal/api/handlers"
+	"github.com/jroosing/hydradns/internal/api/middleware"
+	"github.com/jroosing/hydradns/internal/api/models"
+	"github.com/jroosing/hydradns/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func tokensRouter(h *handlers.Handler) *gin.Engine {
+	router := gin.New()
+	router.GET("/tokens", h.ListAPITokens)
+	router.POST("/tokens", h.CreateAPIToken)
+	router.DELETE("/tokens/:name", h.DeleteAPIToken)
+	return router
+}
+
+func TestCreateAPIToken_Success(t *testing.T) {
+	h := createCustomDNSTestHandler(t, &config.Config{})
+	router := tokensRouter(h)
+
+	w := performRequest(router, http.MethodPost, "/tokens",
+		`{"name":"ci","scopes":["custom-dns","stats:read"],"expires_in":"24h"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	var resp models.CreateAPITokenResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "ci", resp.Name)
+	assert.Equal(t, []string{"custom-dns", "stats:read"}, resp.Scopes)
+	assert.Regexp(t, `^[0-9a-f]{64}$`, resp.Token)
+	require.NotNil(t, resp.ExpiresAt)
+	assert.False(t, resp.Expired)
+
+	// The token can be looked up by hash, but is not stored in the clear.
+	tok, ok := h.LookupAPIToken(context.Background(), middleware.HashToken(resp.Token))
+	require.True(t, ok)
+	assert.Equal(t, "ci", tok.Name)
+	assert.Equal(t, []string{"custom-dns", "stats:read"}, tok.Scopes)
+	_, ok = h.LookupAPIToken(context.Background(), resp.Token)
+	assert.False(t, ok)
+}
+
+func TestCreateAPIToken_Validation(t *testing.T) {
+	h := createCustomDNSTestHandler(t, &config.Config{})
+	router := tokensRouter(h)
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"missing scopes", `{"name":"ci"}`},
+		{"unknown scope", `{"name":"ci","scopes":["config"]}`},
+		{"invalid name", `{"name":"ci/bad","scopes":["stats"]}`},
+		{"invalid expiry", `{"name":"ci","scopes":["stats"],"expires_in":"soon"}`},
+		{"negative expiry", `{"name":"ci","scopes":["stats"],"expires_in":"-1h"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := performRequest(router, http.MethodPost, "/tokens", tt.body)
+			assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+		})
+	}
+}
+
+func TestCreateAPIToken_Conflict(t *testing.T) {
+	h := createCustomDNSTestHandler(t, &config.Config{})
+	router := tokensRouter(h)
+
+	body := `{"name":"ci","scopes":["stats"]}`
+	require.Equal(t, http.StatusCreated, performRequest(router, http.MethodPost, "/tokens", body).Code)
+	assert.Equal(t, http.StatusConflict, performRequest(router, http.MethodPost, "/tokens", body).Code)
+}
+
+func TestListAndDeleteAPITokens(t *testing.T) {
+	h := createCustomDNSTestHandler(t, &config.Config{})
+	router := tokensRouter(h)
+
+	w := performRequest(router, http.MethodPost, "/tokens", `{"name":"ci","scopes":["stats"]}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created models.CreateAPITokenResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+
+	w = performRequest(router, http.MethodGet, "/tokens", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), created.Token)
+	var list models.APITokenListResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list.Tokens, 1)
+	assert.Equal(t, "ci", list.Tokens[0].Name)
+	assert.Nil(t, list.Tokens[0].ExpiresAt)
+
+	assert.Equal(t, http.StatusOK, performRequest(router, http.MethodDelete, "/tokens/ci", "").Code)
+	assert.Equal(t, http.StatusNotFound, performRequest(router, http.MethodDelete, "/tokens/ci", "").Code)
+
+	_, ok := h.LookupAPIToken(context.Background(), middleware.HashToken(created.Token))
+	assert.False(t, ok)
+}
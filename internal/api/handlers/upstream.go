@@ -0,0 +1,309 @@
+package handlers
+
+import (
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jroosing/hydradns/internal/api/models"
+	"github.com/jroosing/hydradns/internal/check"
+	"github.com/jroosing/hydradns/internal/dns"
+)
+
+// maxUpstreamServers mirrors the forwarding resolver's upstream limit.
+const maxUpstreamServers = 3
+
+// GetUpstreams godoc
+// @Summary List upstream servers
+// @Description Returns the upstream DNS servers in failover order
+// @Tags upstreams
+// @Produce json
+// @Success 200 {object} models.UpstreamsResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /upstreams [get]
+func (h *Handler) GetUpstreams(c *gin.Context) {
+	if h.cfg == nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "config unavailable"})
+		return
+	}
+
+	h.mu.RLock()
+	servers := slices.Clone(h.cfg.Upstream.Servers)
+	h.mu.RUnlock()
+
+	if servers == nil {
+		servers = []string{}
+	}
+	c.JSON(http.StatusOK, models.UpstreamsResponse{Servers: servers})
+}
+
+// PutUpstreams godoc
+// @Summary Replace upstream servers
+// @Description Adds, removes, or reorders upstream DNS servers at runtime. The list
+// @Description order is the failover order. Changes are persisted and applied without a restart.
+// @Tags upstreams
+// @Accept json
+// @Produce json
+// @Param upstreams body models.UpdateUpstreamsRequest true "Upstream servers in priority order"
+// @Success 200 {object} models.UpstreamsResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
+// @Failure 409 {object} models.ReadOnlyErrorResponse "Node is a secondary"
+// @Security ApiKeyAuth
+// @Router /upstreams [put]
+func (h *Handler) PutUpstreams(c *gin.Context) {
+	if h.db == nil {
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Error: "database not available"})
+		return
+	}
+
+	var req models.UpdateUpstreamsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request: " + err.Error()})
+		return
+	}
+
+	servers, err := validateUpstreams(req.Servers)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	// Persist first so the runtime never diverges from the database
+	if err := h.db.SetUpstreamServers(c.Request.Context(), servers); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to persist upstreams: " + err.Error()})
+		return
+	}
+
+	h.mu.Lock()
+	if h.cfg != nil {
+		h.cfg.Upstream.Servers = slices.Clone(servers)
+	}
+	reloadFunc := h.upstreamReloadFunc
+	h.mu.Unlock()
+
+	// Apply to the running resolver (outside of lock to avoid deadlock)
+	if reloadFunc == nil {
+		h.logWarn("upstream servers updated but no reload function registered")
+	} else if err := reloadFunc(); err != nil {
+		h.logError("failed to reload upstream servers", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to apply upstreams: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.UpstreamsResponse{Servers: servers})
+}
+
+// GetUpstreamStatus godoc
+// @Summary Upstream server status
+// @Description Returns rolling RTT percentiles (p50/p95), success rate, and consecutive
+// @Description failure counts for each configured upstream, in failover order
+// @Tags upstreams
+// @Produce json
+// @Success 200 {object} models.UpstreamStatusResponse
+// @Security ApiKeyAuth
+// @Router /upstreams/status [get]
+func (h *Handler) GetUpstreamStatus(c *gin.Context) {
+	resp := models.UpstreamStatusResponse{Upstreams: []models.UpstreamStatus{}}
+
+	fn := h.GetUpstreamStatusFunc()
+	if fn == nil {
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+
+	for _, s := range fn() {
+		resp.Upstreams = append(resp.Upstreams, models.UpstreamStatus{
+			Address:             s.Address,
+			Healthy:             s.Healthy,
+			Queries:             s.Queries,
+			Successes:           s.Successes,
+			Failures:            s.Failures,
+			SuccessRate:         s.SuccessRate,
+			ConsecutiveFailures: s.ConsecutiveFailures,
+			RTTP50Ms:            s.RTTP50Ms,
+			RTTP95Ms:            s.RTTP95Ms,
+			LastSuccess:         optionalTime(s.LastSuccess),
+			LastFailure:         optionalTime(s.LastFailure),
+			LastError:           s.LastError,
+			MismatchedResponses: s.MismatchedResponses,
+			Country:             s.Country,
+			ASN:                 s.ASN,
+			ASOrg:               s.ASOrg,
+		})
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// CompareUpstreams godoc
+// @Summary Compare upstream answers
+// @Description Sends one query to every configured upstream in parallel, with the DNSSEC OK bit set, and returns their
+// @Description answers, RTTs, and DNSSEC status side by side. Upstreams whose response code or records (TTLs and order aside)
+// @Description differ from the majority are flagged.
+// @Tags upstreams
+// @Produce json
+// @Param name query string true "Name to query"
+// @Param type query string false "Record type (default A)"
+// @Success 200 {object} models.UpstreamCompareResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security ApiKeyAuth
+// @Router /upstreams/compare [get]
+func (h *Handler) CompareUpstreams(c *gin.Context) {
+	if h.cfg == nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "config unavailable"})
+		return
+	}
+	name, err := canonicalName(c.Query("name"))
+	if err == nil {
+		_, err = dns.EncodeName(name)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	qtype := dns.TypeA
+	if raw := c.Query("type"); raw != "" {
+		if qtype, err = dns.ParseRecordType(raw); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+			return
+		}
+	}
+
+	h.mu.RLock()
+	servers := slices.Clone(h.cfg.Upstream.Servers)
+	h.mu.RUnlock()
+
+	cmp := check.CompareUpstreams(c.Request.Context(), servers, name, qtype, check.DefaultTimeout)
+	resp := models.UpstreamCompareResponse{
+		Name:       cmp.Name,
+		Type:       cmp.Type.String(),
+		Consistent: cmp.Consistent(),
+		Upstreams:  make([]models.UpstreamAnswer, 0, len(cmp.Upstreams)),
+	}
+	for _, a := range cmp.Upstreams {
+		out := models.UpstreamAnswer{
+			Address: a.Upstream,
+			RTTMs:   float64(a.RTT) / float64(time.Millisecond),
+			Answers: a.Answers,
+			DNSSEC:  string(a.DNSSEC),
+			Differs: a.Differs,
+		}
+		if a.Err != nil {
+			out.Error = a.Err.Error()
+		} else {
+			out.RCode = a.RCode.String()
+		}
+		if out.Answers == nil {
+			out.Answers = []string{}
+		}
+		if a.ExtendedError != nil {
+			code := int(a.ExtendedError.InfoCode)
+			out.ExtendedErrorCode = &code
+			out.ExtendedErrorText = a.ExtendedError.ExtraText
+		}
+		resp.Upstreams = append(resp.Upstreams, out)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetUpstreamShadow godoc
+// @Summary Shadow upstream comparison
+// @Description Reports how the shadow upstreams compare with the configured ones on mirrored live queries: how many
+// @Description answers matched, diverged, or failed, the mean upstream latency of each side, and the most recent divergences.
+// @Tags upstreams
+// @Produce json
+// @Success 200 {object} models.UpstreamShadowResponse
+// @Security ApiKeyAuth
+// @Router /upstreams/shadow [get]
+func (h *Handler) GetUpstreamShadow(c *gin.Context) {
+	var snap *ShadowSnapshot
+	if fn := h.GetShadowFunc(); fn != nil {
+		snap = fn()
+	}
+	if snap == nil {
+		c.JSON(http.StatusOK, models.UpstreamShadowResponse{Recent: []models.ShadowDivergence{}})
+		return
+	}
+	resp := models.UpstreamShadowResponse{
+		Enabled:          true,
+		Servers:          snap.Servers,
+		Percent:          snap.Percent,
+		Mirrored:         snap.Mirrored,
+		Matched:          snap.Matched,
+		Diverged:         snap.Diverged,
+		Failed:           snap.Failed,
+		Skipped:          snap.Skipped,
+		PrimaryLatencyMs: float64(snap.PrimaryLatency) / float64(time.Millisecond),
+		ShadowLatencyMs:  float64(snap.ShadowLatency) / float64(time.Millisecond),
+		Recent:           make([]models.ShadowDivergence, 0, len(snap.Recent)),
+	}
+	for _, d := range snap.Recent {
+		resp.Recent = append(resp.Recent, models.ShadowDivergence{
+			Time:    d.Time,
+			Name:    d.Name,
+			Type:    d.Type,
+			TraceID: d.TraceID,
+			Primary: shadowAnswer(d.Primary),
+			Shadow:  shadowAnswer(d.Shadow),
+		})
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// shadowAnswer converts one side of a shadow comparison to its API model.
+func shadowAnswer(a ShadowAnswerSnapshot) models.ShadowAnswer {
+	answers := a.Answers
+	if answers == nil {
+		answers = []string{}
+	}
+	return models.ShadowAnswer{
+		Upstream:  a.Upstream,
+		RCode:     a.RCode,
+		Answers:   answers,
+		LatencyMs: float64(a.Latency) / float64(time.Millisecond),
+	}
+}
+
+// optionalTime returns nil for the zero time so it is omitted from JSON.
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// validateUpstreams trims, validates, and de-duplicates an upstream list.
+// Upstreams are IP addresses; the port is always 53.
+func validateUpstreams(raw []string) ([]string, error) {
+	if len(raw) == 0 {
+		return nil, &ValidationError{Message: "At least one upstream server is required"}
+	}
+	if len(raw) > maxUpstreamServers {
+		return nil, &ValidationError{Message: "At most 3 upstream servers are supported"}
+	}
+
+	servers := make([]string, 0, len(raw))
+	for _, s := range raw {
+		trimmed := strings.TrimSpace(s)
+		if trimmed == "" {
+			return nil, &ValidationError{Message: "Upstream server cannot be empty"}
+		}
+		addr, err := netip.ParseAddr(trimmed)
+		if err != nil {
+			return nil, &ValidationError{Message: "Invalid upstream server IP address: " + trimmed}
+		}
+		normalized := addr.String()
+		if slices.Contains(servers, normalized) {
+			return nil, &ValidationError{Message: "Duplicate upstream server: " + trimmed}
+		}
+		servers = append(servers, normalized)
+	}
+	return servers, nil
+}
@@ -0,0 +1,224 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jroosing/hydradns/internal/api/handlers"
+	"github.com/jroosing/hydradns/internal/api/models"
+	"github.com/jroosing/hydradns/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetUpstreams(t *testing.T) {
+	cfg := &config.Config{Upstream: config.UpstreamConfig{Servers: []string{"9.9.9.9", "1.1.1.1"}}}
+	h := createCustomDNSTestHandler(t, cfg)
+
+	router := gin.New()
+	router.GET("/upstreams", h.GetUpstreams)
+
+	w := performRequest(router, http.MethodGet, "/upstreams", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp models.UpstreamsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, []string{"9.9.9.9", "1.1.1.1"}, resp.Servers)
+}
+
+func TestPutUpstreams_ReordersPersistsAndReloads(t *testing.T) {
+	cfg := &config.Config{Upstream: config.UpstreamConfig{Servers: []string{"9.9.9.9", "1.1.1.1"}}}
+	h := createCustomDNSTestHandler(t, cfg)
+
+	reloads := 0
+	h.SetUpstreamReloadFunc(func() error {
+		reloads++
+		return nil
+	})
+
+	router := gin.New()
+	router.PUT("/upstreams", h.PutUpstreams)
+
+	w := performRequest(router, http.MethodPut, "/upstreams", `{"servers":[" 1.1.1.1 ","2606:4700:4700::1111"]}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp models.UpstreamsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, []string{"1.1.1.1", "2606:4700:4700::1111"}, resp.Servers)
+	assert.Equal(t, []string{"1.1.1.1", "2606:4700:4700::1111"}, cfg.Upstream.Servers)
+	assert.Equal(t, 1, reloads)
+
+	stored, err := h.DB().GetUpstreamServers(context.Background())
+	require.NoError(t, err)
+	require.Len(t, stored, 2)
+	assert.Equal(t, "1.1.1.1", stored[0].ServerAddress)
+	assert.Equal(t, "2606:4700:4700::1111", stored[1].ServerAddress)
+}
+
+func TestPutUpstreams_Validation(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"empty list", `{"servers":[]}`},
+		{"too many", `{"servers":["1.1.1.1","8.8.8.8","9.9.9.9","8.8.4.4"]}`},
+		{"hostname", `{"servers":["dns.google"]}`},
+		{"blank entry", `{"servers":["  "]}`},
+		{"duplicate", `{"servers":["1.1.1.1","1.1.1.1"]}`},
+		{"malformed json", `{"servers":`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Upstream: config.UpstreamConfig{Servers: []string{"9.9.9.9"}}}
+			h := createCustomDNSTestHandler(t, cfg)
+
+			router := gin.New()
+			router.PUT("/upstreams", h.PutUpstreams)
+
+			w := performRequest(router, http.MethodPut, "/upstreams", tt.body)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Equal(t, []string{"9.9.9.9"}, cfg.Upstream.Servers)
+		})
+	}
+}
+
+func TestGetUpstreamStatus_NoFunc(t *testing.T) {
+	h := handlers.New(&config.Config{}, nil, nil)
+
+	router := gin.New()
+	router.GET("/upstreams/status", h.GetUpstreamStatus)
+
+	w := performRequest(router, http.MethodGet, "/upstreams/status", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp models.UpstreamStatusResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Empty(t, resp.Upstreams)
+}
+
+func TestGetUpstreamStatus_ReportsSnapshot(t *testing.T) {
+	h := handlers.New(&config.Config{}, nil, nil)
+	lastOK := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	h.SetUpstreamStatusFunc(func() []handlers.UpstreamStatusSnapshot {
+		return []handlers.UpstreamStatusSnapshot{
+			{Address: "9.9.9.9", Healthy: true, Queries: 10, Successes: 9, Failures: 1,
+				SuccessRate: 0.9, RTTP50Ms: 12.5, RTTP95Ms: 40, LastSuccess: lastOK},
+			{Address: "1.1.1.1", Healthy: false, Queries: 3, Failures: 3,
+				ConsecutiveFailures: 3, LastError: "i/o timeout"},
+		}
+	})
+
+	router := gin.New()
+	router.GET("/upstreams/status", h.GetUpstreamStatus)
+
+	w := performRequest(router, http.MethodGet, "/upstreams/status", "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp models.UpstreamStatusResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Upstreams, 2)
+
+	first := resp.Upstreams[0]
+	assert.Equal(t, "9.9.9.9", first.Address)
+	assert.True(t, first.Healthy)
+	assert.InDelta(t, 0.9, first.SuccessRate, 1e-9)
+	assert.InDelta(t, 12.5, first.RTTP50Ms, 1e-9)
+	require.NotNil(t, first.LastSuccess)
+	assert.True(t, lastOK.Equal(*first.LastSuccess))
+	assert.Nil(t, first.LastFailure)
+
+	second := resp.Upstreams[1]
+	assert.False(t, second.Healthy)
+	assert.Equal(t, uint64(3), second.ConsecutiveFailures)
+	assert.Equal(t, "i/o timeout", second.LastError)
+	assert.Nil(t, second.LastSuccess)
+}
+
+func TestCompareUpstreams_ReportsEachUpstream(t *testing.T) {
+	// Nothing listens on these addresses; each upstream reports its failure
+	cfg := &config.Config{Upstream: config.UpstreamConfig{Servers: []string{"127.0.0.154", "127.0.0.157"}}}
+	h := handlers.New(cfg, nil, nil)
+
+	router := gin.New()
+	router.GET("/upstreams/compare", h.CompareUpstreams)
+
+	w := performRequest(router, http.MethodGet, "/upstreams/compare?name=Example.COM.&type=aaaa", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp models.UpstreamCompareResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "example.com", resp.Name)
+	assert.Equal(t, "AAAA", resp.Type)
+	assert.True(t, resp.Consistent, "failures are not differences")
+	require.Len(t, resp.Upstreams, 2)
+	for i, a := range resp.Upstreams {
+		assert.Equal(t, cfg.Upstream.Servers[i], a.Address)
+		assert.NotEmpty(t, a.Error)
+		assert.NotNil(t, a.Answers)
+		assert.False(t, a.Differs)
+	}
+}
+
+func TestCompareUpstreams_RejectsInvalidQuery(t *testing.T) {
+	h := handlers.New(&config.Config{}, nil, nil)
+	router := gin.New()
+	router.GET("/upstreams/compare", h.CompareUpstreams)
+
+	for _, path := range []string{
+		"/upstreams/compare",
+		"/upstreams/compare?name=bad..name",
+		"/upstreams/compare?name=example.com&type=BOGUS",
+	} {
+		w := performRequest(router, http.MethodGet, path, "")
+		assert.Equal(t, http.StatusBadRequest, w.Code, path)
+	}
+}
+
+func TestGetUpstreamShadow(t *testing.T) {
+	h := handlers.New(&config.Config{}, nil, nil)
+	router := gin.New()
+	router.GET("/upstreams/shadow", h.GetUpstreamShadow)
+
+	// Disabled
+	w := performRequest(router, http.MethodGet, "/upstreams/shadow", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp models.UpstreamShadowResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.False(t, resp.Enabled)
+
+	h.SetShadowFunc(func() *handlers.ShadowSnapshot {
+		return &handlers.ShadowSnapshot{
+			Servers:        []string{"9.9.9.9"},
+			Percent:        10,
+			Mirrored:       3,
+			Matched:        1,
+			Diverged:       1,
+			Failed:         1,
+			PrimaryLatency: 12 * time.Millisecond,
+			ShadowLatency:  30 * time.Millisecond,
+			Recent: []handlers.ShadowDivergenceSnapshot{{
+				Name:    "example.com",
+				Type:    "A",
+				TraceID: "00000000feedf00d",
+				Primary: handlers.ShadowAnswerSnapshot{Upstream: "1.1.1.1", RCode: "NOERROR", Answers: []string{"example.com.\tIN\tA\t192.0.2.1"}},
+				Shadow:  handlers.ShadowAnswerSnapshot{Upstream: "9.9.9.9", RCode: "NXDOMAIN"},
+			}},
+		}
+	})
+	w = performRequest(router, http.MethodGet, "/upstreams/shadow", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	resp = models.UpstreamShadowResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.True(t, resp.Enabled)
+	assert.Equal(t, uint64(1), resp.Diverged)
+	assert.InDelta(t, 30, resp.ShadowLatencyMs, 1e-9)
+	require.Len(t, resp.Recent, 1)
+	assert.Equal(t, "00000000feedf00d", resp.Recent[0].TraceID)
+	assert.Equal(t, "NXDOMAIN", resp.Recent[0].Shadow.RCode)
+	assert.NotNil(t, resp.Recent[0].Shadow.Answers)
+}
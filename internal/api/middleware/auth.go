@@ -3,21 +3,132 @@
 package middleware
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"net/http"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jroosing/hydradns/internal/api/models"
 )
 
+// APIPrefix is the path prefix scopes are matched under.
+const APIPrefix = "/api/v1/"
+
+// TokenNameKey is the gin context key holding the name of the API token
+// that authenticated a request. It is unset for the admin key.
+const TokenNameKey = "api_token"
+
+// scopePaths maps each token scope to the API path sections it grants.
+// Appending ":read" to a scope limits it to GET requests.
+//
+// Configuration, cache overrides, cluster, setup, batch changes (which span
+// scopes), logging, packet capture, DNS advertisement, and token management
+// are never granted to tokens; they require the admin key.
+var scopePaths = map[string][]string{
+	"filtering":  {"filtering"},
+	"custom-dns": {"custom-dns"},
+	"upstreams":  {"upstreams"},
+	"stats":      {"health", "stats", "events", "anomalies"},
+}
+
+// BlockPageScope grants no API section. It lets a token lift blocks with
+// the allow button of the block page, which never accepts the admin key.
+const BlockPageScope = "block-page"
+
+// Token is an API token as seen by RequireAuth.
+type Token struct {
+	Name      string
+	Scopes    []string
+	ExpiresAt time.Time // Zero if the token does not expire
+}
+
+// TokenLookup finds the API token with the given hash (see HashToken).
+type TokenLookup func(ctx context.Context, hash string) (Token, bool)
+
 // RequireAPIKey enforces a simple shared-secret API key.
 // Clients must send `X-API-Key: <key>`.
 func RequireAPIKey(expected string) gin.HandlerFunc {
+	return RequireAuth(expected, nil)
+}
+
+// RequireAuth accepts the admin API key, or a scoped API token found by
+// lookup, in the X-API-Key header. Tokens are rejected with 401 once
+// expired and with 403 outside their scopes. An empty admin key disables
+// authentication, tokens included.
+func RequireAuth(adminKey string, lookup TokenLookup) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		got := c.GetHeader("X-API-Key")
-		if expected == "" || got == expected {
+		if adminKey == "" || subtle.ConstantTimeCompare([]byte(got), []byte(adminKey)) == 1 {
 			c.Next()
 			return
 		}
+
+		if got != "" && lookup != nil {
+			if tok, ok := lookup(c.Request.Context(), HashToken(got)); ok {
+				if !tok.ExpiresAt.IsZero() && !time.Now().Before(tok.ExpiresAt) {
+					c.AbortWithStatusJSON(http.StatusUnauthorized, models.ErrorResponse{Error: "token expired"})
+					return
+				}
+				if !ScopesAllow(tok.Scopes, c.Request.Method, c.Request.URL.Path) {
+					c.AbortWithStatusJSON(http.StatusForbidden, models.ErrorResponse{Error: "token scope does not allow this request"})
+					return
+				}
+				c.Set(TokenNameKey, tok.Name)
+				c.Next()
+				return
+			}
+		}
 		c.AbortWithStatusJSON(http.StatusUnauthorized, models.ErrorResponse{Error: "unauthorized"})
 	}
 }
+
+// ValidScope reports whether scope can be granted to a token.
+func ValidScope(scope string) bool {
+	if scope == BlockPageScope {
+		return true
+	}
+	_, ok := scopePaths[strings.TrimSuffix(scope, ":read")]
+	return ok
+}
+
+// ScopesAllow reports whether any of scopes grants a request for path.
+func ScopesAllow(scopes []string, method, path string) bool {
+	rest, ok := strings.CutPrefix(path, APIPrefix)
+	if !ok {
+		return false
+	}
+	section, _, _ := strings.Cut(rest, "/")
+
+	for _, scope := range scopes {
+		name, readOnly := strings.CutSuffix(scope, ":read")
+		if readOnly && method != http.MethodGet && method != http.MethodHead {
+			continue
+		}
+		if slices.Contains(scopePaths[name], section) {
+			return true
+		}
+	}
+	return false
+}
+
+// HashToken returns the hex-encoded SHA-256 hash under which an API token
+// is stored.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateAPIKey returns a random 256-bit API key, hex encoded.
+func GenerateAPIKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jroosing/hydradns/internal/api/models"
+)
+
+// Limiter decides whether another request for key is allowed.
+type Limiter interface {
+	Allow(key string) bool
+}
+
+// RateLimit rejects requests refused by l with 429 Too Many Requests.
+// Requests are keyed by the connection's remote address rather than
+// X-Forwarded-For, which any client can set.
+func RateLimit(l Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !l.Allow(c.RemoteIP()) {
+			c.Header("Retry-After", "1")
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, models.ErrorResponse{Error: "rate limit exceeded"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// BodyLimit rejects request bodies larger than maxBytes. Bodies that
+// declare a larger Content-Length get 413 Request Entity Too Large up
+// front; otherwise reading past the limit fails, which handlers report
+// as a bad request.
+func BodyLimit(maxBytes int64) gin.HandlerFunc {
+	tooLarge := models.ErrorResponse{Error: "request body exceeds " + strconv.FormatInt(maxBytes, 10) + " bytes"}
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > maxBytes {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, tooLarge)
+			return
+		}
+		if c.Request.Body != nil {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+		}
+		c.Next()
+	}
+}
@@ -1,18 +1,33 @@
 package middleware
 
 import (
+	"fmt"
 	"log/slog"
+	"math/rand/v2"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// RequestIDHeader carries the ID that correlates an API request with its
+// log entry. A client-supplied ID is kept; otherwise one is generated.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLen caps client-supplied request IDs.
+const maxRequestIDLen = 64
+
 func SlogRequestLogger(logger *slog.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
 		path := c.Request.URL.Path
 		method := c.Request.Method
 
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = fmt.Sprintf("%016x", rand.Uint64())
+		}
+		c.Header(RequestIDHeader, requestID)
+
 		c.Next()
 
 		latency := time.Since(start)
@@ -25,7 +40,22 @@ func SlogRequestLogger(logger *slog.Logger) gin.HandlerFunc {
 				"status", status,
 				"latency_ms", latency.Milliseconds(),
 				"client_ip", c.ClientIP(),
+				"request_id", requestID,
 			)
 		}
 	}
 }
+
+// validRequestID reports whether id is a non-empty, printable ASCII ID
+// short enough to log.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := range len(id) {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
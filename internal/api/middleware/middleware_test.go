@@ -2,19 +2,37 @@
 package middleware_test
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jroosing/hydradns/internal/api/middleware"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func init() {
 	gin.SetMode(gin.TestMode)
 }
 
+// ============================================================================
+// GenerateAPIKey Tests
+// ============================================================================
+
+func TestGenerateAPIKey(t *testing.T) {
+	a, err := middleware.GenerateAPIKey()
+	require.NoError(t, err)
+	b, err := middleware.GenerateAPIKey()
+	require.NoError(t, err)
+
+	assert.Regexp(t, `^[0-9a-f]{64}$`, a)
+	assert.NotEqual(t, a, b)
+}
+
 // ============================================================================
 // RequireAPIKey Middleware Tests
 // ============================================================================
@@ -100,6 +118,176 @@ func TestRequireAPIKey_EmptyExpected_WithProvidedKey(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 }
 
+// ============================================================================
+// RequireAuth Middleware Tests
+// ============================================================================
+
+func tokenRouter(t *testing.T, tokens map[string]middleware.Token) *gin.Engine {
+	t.Helper()
+	lookup := func(_ context.Context, hash string) (middleware.Token, bool) {
+		for secret, tok := range tokens {
+			if middleware.HashToken(secret) == hash {
+				return tok, true
+			}
+		}
+		return middleware.Token{}, false
+	}
+
+	router := gin.New()
+	router.Use(middleware.RequireAuth("admin-key", lookup))
+	router.Any("/api/v1/*path", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"token": c.GetString(middleware.TokenNameKey)})
+	})
+	return router
+}
+
+func authRequest(router *gin.Engine, method, path, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("X-Api-Key", key)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRequireAuth_AdminKeyAllowsEverything(t *testing.T) {
+	router := tokenRouter(t, nil)
+
+	assert.Equal(t, http.StatusOK, authRequest(router, http.MethodPut, "/api/v1/config", "admin-key").Code)
+	assert.Equal(t, http.StatusOK, authRequest(router, http.MethodPost, "/api/v1/tokens", "admin-key").Code)
+}
+
+func TestRequireAuth_TokenWithinScope(t *testing.T) {
+	router := tokenRouter(t, map[string]middleware.Token{
+		"ci-secret": {Name: "ci", Scopes: []string{"custom-dns"}},
+	})
+
+	w := authRequest(router, http.MethodPost, "/api/v1/custom-dns/hosts", "ci-secret")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"token":"ci"`)
+}
+
+func TestRequireAuth_TokenOutsideScope(t *testing.T) {
+	router := tokenRouter(t, map[string]middleware.Token{
+		"ci-secret": {Name: "ci", Scopes: []string{"custom-dns"}},
+	})
+
+	assert.Equal(t, http.StatusForbidden, authRequest(router, http.MethodGet, "/api/v1/filtering/stats", "ci-secret").Code)
+	assert.Equal(t, http.StatusForbidden, authRequest(router, http.MethodGet, "/api/v1/config", "ci-secret").Code)
+	assert.Equal(t, http.StatusForbidden, authRequest(router, http.MethodGet, "/api/v1/tokens", "ci-secret").Code)
+}
+
+func TestRequireAuth_ReadOnlyScope(t *testing.T) {
+	router := tokenRouter(t, map[string]middleware.Token{
+		"mon-secret": {Name: "monitoring", Scopes: []string{"filtering:read", "stats"}},
+	})
+
+	assert.Equal(t, http.StatusOK, authRequest(router, http.MethodGet, "/api/v1/filtering/stats", "mon-secret").Code)
+	assert.Equal(t, http.StatusOK, authRequest(router, http.MethodGet, "/api/v1/stats", "mon-secret").Code)
+	assert.Equal(t, http.StatusForbidden, authRequest(router, http.MethodPost, "/api/v1/filtering/blacklist", "mon-secret").Code)
+}
+
+func TestRequireAuth_ExpiredToken(t *testing.T) {
+	router := tokenRouter(t, map[string]middleware.Token{
+		"old-secret": {Name: "old", Scopes: []string{"stats"}, ExpiresAt: time.Now().Add(-time.Minute)},
+	})
+
+	assert.Equal(t, http.StatusUnauthorized, authRequest(router, http.MethodGet, "/api/v1/stats", "old-secret").Code)
+}
+
+func TestRequireAuth_UnknownToken(t *testing.T) {
+	router := tokenRouter(t, map[string]middleware.Token{
+		"ci-secret": {Name: "ci", Scopes: []string{"stats"}},
+	})
+
+	assert.Equal(t, http.StatusUnauthorized, authRequest(router, http.MethodGet, "/api/v1/stats", "other").Code)
+	assert.Equal(t, http.StatusUnauthorized, authRequest(router, http.MethodGet, "/api/v1/stats", "").Code)
+}
+
+func TestValidScope(t *testing.T) {
+	assert.True(t, middleware.ValidScope("filtering"))
+	assert.True(t, middleware.ValidScope("custom-dns:read"))
+	assert.False(t, middleware.ValidScope("config"))
+	assert.False(t, middleware.ValidScope("tokens"))
+	assert.False(t, middleware.ValidScope(""))
+
+	// The block page scope grants no API section
+	assert.True(t, middleware.ValidScope(middleware.BlockPageScope))
+	assert.False(t, middleware.ValidScope(middleware.BlockPageScope+":read"))
+	assert.False(t, middleware.ScopesAllow([]string{middleware.BlockPageScope}, http.MethodGet, "/api/v1/filtering"))
+}
+
+// ============================================================================
+// Limit Middleware Tests
+// ============================================================================
+
+// countLimiter allows the first n requests per key.
+type countLimiter struct {
+	n    int
+	seen map[string]int
+}
+
+func (l *countLimiter) Allow(key string) bool {
+	l.seen[key]++
+	return l.seen[key] <= l.n
+}
+
+func TestRateLimit_RejectsOverLimit(t *testing.T) {
+	limiter := &countLimiter{n: 2, seen: map[string]int{}}
+	router := gin.New()
+	router.Use(middleware.RateLimit(limiter))
+	router.GET("/test", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+
+	send := func(remote, forwarded string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.RemoteAddr = remote
+		if forwarded != "" {
+			req.Header.Set("X-Forwarded-For", forwarded)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, send("192.0.2.1:1000", "").Code)
+	assert.Equal(t, http.StatusOK, send("192.0.2.1:1001", "").Code)
+
+	// A forged X-Forwarded-For does not get a fresh bucket.
+	w := send("192.0.2.1:1002", "198.51.100.9")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	assert.Equal(t, http.StatusOK, send("192.0.2.2:1000", "").Code)
+}
+
+func TestBodyLimit(t *testing.T) {
+	router := gin.New()
+	router.Use(middleware.BodyLimit(16))
+	router.POST("/test", func(c *gin.Context) {
+		var body map[string]any
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, body)
+	})
+
+	send := func(body string, chunked bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/test", strings.NewReader(body))
+		if chunked {
+			req.ContentLength = -1
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, send(`{"a":1}`, false).Code)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, send(`{"a":"0123456789abcdef"}`, false).Code)
+	assert.Equal(t, http.StatusBadRequest, send(`{"a":"0123456789abcdef"}`, true).Code)
+}
+
 // ============================================================================
 // SlogRequestLogger Middleware Tests
 // ============================================================================
@@ -184,6 +372,28 @@ func TestSlogRequestLogger_ErrorStatus(t *testing.T) {
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
 }
 
+func TestSlogRequestLogger_RequestID(t *testing.T) {
+	router := gin.New()
+	router.Use(middleware.SlogRequestLogger(nil))
+	router.GET("/test", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set(middleware.RequestIDHeader, "deploy-42")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, "deploy-42", w.Header().Get(middleware.RequestIDHeader), "client ID is kept")
+
+	for _, supplied := range []string{"", "has space", strings.Repeat("x", 65)} {
+		req = httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.Header.Set(middleware.RequestIDHeader, supplied)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Len(t, w.Header().Get(middleware.RequestIDHeader), 16, "generated for %q", supplied)
+	}
+}
+
 // ============================================================================
 // Integration Tests
 // ============================================================================
@@ -211,3 +421,36 @@ func TestMiddlewareChain(t *testing.T) {
 	router.ServeHTTP(w2, req2)
 	assert.Equal(t, http.StatusUnauthorized, w2.Code)
 }
+
+// ============================================================================
+// Recovery Middleware Tests
+// ============================================================================
+
+func TestRecovery_RespondsInternalServerError(t *testing.T) {
+	var panics int
+	router := gin.New()
+	router.Use(middleware.Recovery(nil, func() { panics++ }))
+	router.GET("/boom", func(*gin.Context) { panic("nil map write") })
+	router.GET("/ok", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"status": "ok"}) })
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/boom", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "internal server error")
+	assert.Equal(t, 1, panics)
+
+	// The router keeps serving
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ok", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestRecovery_RepanicsAbortHandler(t *testing.T) {
+	router := gin.New()
+	router.Use(middleware.Recovery(nil, func() { t.Error("abort counted as a panic") }))
+	router.GET("/abort", func(*gin.Context) { panic(http.ErrAbortHandler) })
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/abort", nil))
+	})
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jroosing/hydradns/internal/api/models"
+)
+
+// Recovery recovers from panics in later handlers, logs the stack trace,
+// and responds 500 Internal Server Error, so one bad request cannot take
+// down the API server. onPanic, if non-nil, is called for every recovered
+// panic, for metrics.
+//
+// http.ErrAbortHandler is re-panicked: it is how handlers abort a response
+// on purpose, and net/http handles it without logging.
+func Recovery(logger *slog.Logger, onPanic func()) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if err, ok := v.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(v)
+			}
+			if onPanic != nil {
+				onPanic()
+			}
+			if logger != nil {
+				logger.Error("recovered from panic in API handler",
+					"method", c.Request.Method,
+					"path", c.Request.URL.Path,
+					"panic", v,
+					"stack", string(debug.Stack()),
+				)
+			}
+			if c.Writer.Written() {
+				c.Abort()
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrorResponse{Error: "internal server error"})
+		}()
+		c.Next()
+	}
+}
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// AdvertiseInterface lists the DNS addresses advertised on one network
+// interface.
+type AdvertiseInterface struct {
+	Name string   `json:"name"`
+	IPv4 []string `json:"ipv4"`
+	IPv6 []string `json:"ipv6"`
+}
+
+// UncoveredSubnet is a client network outside the configured client
+// subnets, redacted as the privacy level prescribes.
+type UncoveredSubnet struct {
+	Subnet    string    `json:"subnet"`
+	Queries   uint64    `json:"queries"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// ClientCoverage reports the clients that queried from outside the
+// configured client subnets.
+type ClientCoverage struct {
+	Enabled       bool              `json:"enabled"`
+	ClientSubnets []string          `json:"client_subnets"`
+	Uncovered     []UncoveredSubnet `json:"uncovered"` // Most queries first
+	// Untracked counts queries from uncovered subnets not listed because
+	// too many were already tracked
+	Untracked uint64 `json:"untracked"`
+}
+
+// AdvertiseResponse is the response for GET /advertise.
+type AdvertiseResponse struct {
+	Enabled    bool                 `json:"enabled"`
+	Port       int                  `json:"port,omitempty"`
+	Interfaces []AdvertiseInterface `json:"interfaces"`
+	// Changed is when the listening addresses last changed
+	Changed *time.Time `json:"changed,omitempty"`
+	// Delivered is set once the webhook accepted the current addresses
+	Delivered   bool           `json:"delivered"`
+	LastAttempt *time.Time     `json:"last_attempt,omitempty"`
+	LastSuccess *time.Time     `json:"last_success,omitempty"`
+	LastError   string         `json:"last_error,omitempty"`
+	Coverage    ClientCoverage `json:"coverage"`
+}
@@ -0,0 +1,48 @@
+package models
+
+// Batch operations (see BatchOperation.Op).
+const (
+	BatchAddHost         = "add_host"
+	BatchUpdateHost      = "update_host"
+	BatchDeleteHost      = "delete_host"
+	BatchAddCNAME        = "add_cname"
+	BatchUpdateCNAME     = "update_cname"
+	BatchDeleteCNAME     = "delete_cname"
+	BatchWhitelistAdd    = "whitelist_add"
+	BatchWhitelistRemove = "whitelist_remove"
+	BatchBlacklistAdd    = "blacklist_add"
+	BatchBlacklistRemove = "blacklist_remove"
+)
+
+// BatchOperation is one change of a batch. Name is the host name or CNAME
+// alias of custom DNS operations; IPs and Target are their new addresses
+// and target. Domains are the domains of whitelist and blacklist operations.
+type BatchOperation struct {
+	Op      string   `json:"op"`
+	Name    string   `json:"name,omitempty"`
+	IPs     []string `json:"ips,omitempty"`
+	Target  string   `json:"target,omitempty"`
+	Domains []string `json:"domains,omitempty"`
+}
+
+// BatchRequest is the request body for POST /batch.
+type BatchRequest struct {
+	Operations []BatchOperation `json:"operations" binding:"required,min=1"`
+}
+
+// BatchError is a problem with one operation of a batch. Index is the
+// position of the operation in the request, counting from 0.
+type BatchError struct {
+	Index int    `json:"index"`
+	Op    string `json:"op"`
+	Error string `json:"error"`
+}
+
+// BatchResponse is the response for POST /batch.
+type BatchResponse struct {
+	// Applied counts the operations applied: all of them, or none when
+	// there are errors.
+	Applied int `json:"applied"`
+	// Errors lists every operation that cannot be applied.
+	Errors []BatchError `json:"errors,omitempty"`
+}
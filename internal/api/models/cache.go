@@ -0,0 +1,16 @@
+package models
+
+import "github.com/jroosing/hydradns/internal/config"
+
+// CacheOverridesResponse is the response for GET /cache/overrides.
+type CacheOverridesResponse struct {
+	Overrides []config.CacheZoneOverride `json:"overrides"`
+}
+
+// SetCacheOverrideRequest is the request body for PUT /cache/overrides/{zone}.
+// Unset fields inherit the global cache setting.
+type SetCacheOverrideRequest struct {
+	DisableNegative *bool  `json:"disable_negative,omitempty"`
+	ServfailTTL     string `json:"servfail_ttl,omitempty"`
+	NegativeTTL     string `json:"negative_ttl,omitempty"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// StartCaptureRequest is the request body for POST /capture.
+type StartCaptureRequest struct {
+	// Count is how many DNS messages to record, queries and responses on
+	// both the client and upstream sides (1-10000)
+	Count int `json:"count" binding:"required,min=1,max=10000"`
+	// Name limits the capture to messages about the name or its
+	// subdomains; empty for any name
+	Name string `json:"name,omitempty"`
+	// Client limits the capture to messages exchanged with a client
+	// address or prefix, and upstream messages sent on its behalf; empty
+	// for any client
+	Client string `json:"client,omitempty"`
+}
+
+// CaptureStatusResponse describes the latest packet capture.
+type CaptureStatusResponse struct {
+	Active    bool       `json:"active"`
+	Name      string     `json:"name,omitempty"`
+	Client    string     `json:"client,omitempty"`
+	Count     int        `json:"count"`
+	Captured  int        `json:"captured"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+	StoppedAt *time.Time `json:"stopped_at,omitempty"`
+}
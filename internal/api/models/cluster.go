@@ -22,6 +22,11 @@ type ClusterStatusResponse struct {
 	// LastSyncVersion is the config version from the last successful sync.
 	LastSyncVersion int64 `json:"last_sync_version,omitempty"`
 
+	// LastSyncKind is "full" when the last successful sync fetched the
+	// full export from the primary, "changes" when it fetched only the
+	// changes since the previous sync.
+	LastSyncKind string `json:"last_sync_kind,omitempty"`
+
 	// LastSyncError is the error message from the last sync attempt (if any).
 	LastSyncError string `json:"last_sync_error,omitempty"`
 
@@ -33,6 +38,60 @@ type ClusterStatusResponse struct {
 
 	// ErrorCount is the total number of sync errors.
 	ErrorCount int64 `json:"error_count,omitempty"`
+
+	// Sections reports each synced configuration section and, on a
+	// secondary, whether it drifted from the primary.
+	Sections []ClusterSectionStatus `json:"sections,omitempty"`
+
+	// Members lists the cluster members, this node first. Secondaries
+	// learn the other members from the primary.
+	Members []ClusterMember `json:"members,omitempty"`
+}
+
+// ClusterMember is one node of the cluster, as published by cluster
+// discovery.
+type ClusterMember struct {
+	NodeID string `json:"node_id"`
+	// Role is "primary" or "secondary".
+	Role string `json:"role"`
+	// Address is the node's IP address; empty when not known.
+	Address string `json:"address,omitempty"`
+	// DNSPort and APIPort are the node's DNS and API ports.
+	DNSPort int `json:"dns_port,omitempty"`
+	APIPort int `json:"api_port,omitempty"`
+	// LastSeen is when the primary last heard from the node.
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// ClusterSectionStatus reports one synced configuration section
+// ("custom_dns", "filtering" or "upstream").
+type ClusterSectionStatus struct {
+	// Name is the section name.
+	Name string `json:"name"`
+
+	// Hash identifies the section's current content on this node.
+	Hash string `json:"hash"`
+
+	// AppliedHash is the hash last imported from the primary (secondary only).
+	AppliedHash string `json:"applied_hash,omitempty"`
+
+	// AppliedVersion is the primary config version the section last changed
+	// at when imported (secondary only).
+	AppliedVersion int64 `json:"applied_version,omitempty"`
+
+	// AppliedAt is when the imported section last changed (secondary only).
+	AppliedAt string `json:"applied_at,omitempty"`
+
+	// PrimaryHash is the primary's hash as of the last sync (secondary only).
+	PrimaryHash string `json:"primary_hash,omitempty"`
+
+	// OutOfSync is set when the primary's section differs from the one last
+	// imported, e.g. while a sync is pending or failing.
+	OutOfSync bool `json:"out_of_sync,omitempty"`
+
+	// LocallyModified is set when the section changed on this node since it
+	// was imported, e.g. by local records.
+	LocallyModified bool `json:"locally_modified,omitempty"`
 }
 
 // ClusterConfigRequest represents a request to configure cluster settings.
@@ -76,3 +135,41 @@ type SetClusterConfigResponse struct {
 	// RequiresRestart indicates if a restart is needed for changes to take effect.
 	RequiresRestart bool `json:"requires_restart"`
 }
+
+// ReadOnlyErrorResponse is returned when a change is rejected because this
+// node is a secondary whose configuration is synced from the primary.
+type ReadOnlyErrorResponse struct {
+	Error string `json:"error"`
+
+	// PrimaryURL is the primary node where the change should be made.
+	PrimaryURL string `json:"primary_url"`
+}
+
+// ClusterPromoteRequest is the request body for POST /cluster/promote.
+type ClusterPromoteRequest struct {
+	// Confirm must be true; it guards against accidental promotions.
+	Confirm bool `json:"confirm"`
+	// Force promotes even if the primary is still reachable or this node
+	// never synced from it.
+	Force bool `json:"force"`
+	// Reason is recorded in the promotion audit trail.
+	Reason string `json:"reason"`
+}
+
+// ClusterPromotion is a promotion of this node from secondary to primary.
+type ClusterPromotion struct {
+	ID                 int64  `json:"id"`
+	NodeID             string `json:"node_id"`
+	PreviousPrimaryURL string `json:"previous_primary_url"`
+	Reason             string `json:"reason,omitempty"`
+	Forced             bool   `json:"forced"`
+	RequestedBy        string `json:"requested_by"`
+	ClientIP           string `json:"client_ip,omitempty"`
+	ConfigVersion      int64  `json:"config_version"`
+	CreatedAt          string `json:"created_at"`
+}
+
+// ClusterPromotionsResponse is the response for GET /cluster/promotions.
+type ClusterPromotionsResponse struct {
+	Promotions []ClusterPromotion `json:"promotions"`
+}
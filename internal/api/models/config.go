@@ -4,9 +4,12 @@ import "github.com/jroosing/hydradns/internal/config"
 
 // APIConfigResponse is a redacted version of APIConfig (no api_key exposed).
 type APIConfigResponse struct {
-	Enabled bool   `json:"enabled"`
-	Host    string `json:"host"`
-	Port    int    `json:"port"`
+	Enabled        bool    `json:"enabled"`
+	Host           string  `json:"host"`
+	Port           int     `json:"port"`
+	RateLimitRPS   float64 `json:"rate_limit_rps"`
+	RateLimitBurst int     `json:"rate_limit_burst"`
+	MaxBodyBytes   int     `json:"max_body_bytes"`
 }
 
 // ClusterConfigResponse is a redacted version of ClusterConfig (no shared_secret exposed).
@@ -18,25 +21,60 @@ type ClusterConfigResponse struct {
 	SyncTimeout  string `json:"sync_timeout"`
 }
 
+// ThreatIntelConfigResponse is a redacted version of ThreatIntelConfig (no auth_key exposed).
+type ThreatIntelConfigResponse struct {
+	Enabled   bool    `json:"enabled"`
+	Provider  string  `json:"provider"`
+	URL       string  `json:"url,omitempty"`
+	BlockTTL  string  `json:"block_ttl"`
+	CacheTTL  string  `json:"cache_ttl"`
+	RateLimit float64 `json:"rate_limit"`
+}
+
 // ServerConfigResponse wraps ServerConfig with workers as string.
 type ServerConfigResponse struct {
-	Host                   string `json:"host"`
-	Port                   int    `json:"port"`
-	Workers                string `json:"workers"`
-	MaxConcurrency         int    `json:"max_concurrency"`
-	UpstreamSocketPoolSize int    `json:"upstream_socket_pool_size"`
-	EnableTCP              bool   `json:"enable_tcp"`
-	TCPFallback            bool   `json:"tcp_fallback"`
+	Host                   string                   `json:"host"`
+	Port                   int                      `json:"port"`
+	Workers                string                   `json:"workers"`
+	MaxConcurrency         int                      `json:"max_concurrency"`
+	UpstreamSocketPoolSize int                      `json:"upstream_socket_pool_size"`
+	EnableTCP              bool                     `json:"enable_tcp"`
+	TCPFallback            bool                     `json:"tcp_fallback"`
+	MaxCNAMEChain          int                      `json:"max_cname_chain"`
+	UDPListeners           int                      `json:"udp_listeners"`
+	UDPRecvBuffer          int                      `json:"udp_recv_buffer"`
+	UDPSendBuffer          int                      `json:"udp_send_buffer"`
+	TCPReadTimeout         string                   `json:"tcp_read_timeout"`
+	TCPIdleTimeout         string                   `json:"tcp_idle_timeout"`
+	TCPMaxConnsPerIP       int                      `json:"tcp_max_conns_per_ip"`
+	TCPMaxQueriesPerConn   int                      `json:"tcp_max_queries_per_conn"`
+	TCPListeners           int                      `json:"tcp_listeners"`
+	ShutdownDrain          string                   `json:"shutdown_drain"`
+	ProxyProtocolTrusted   []string                 `json:"proxy_protocol_trusted,omitempty"`
+	RecursionClients       []string                 `json:"recursion_clients,omitempty"`
+	ListenerProfiles       []config.ListenerProfile `json:"listener_profiles,omitempty"`
+	QTypeRules             []config.QTypeRule       `json:"qtype_rules,omitempty"`
 }
 
 // ConfigResponse is the API response for GET /config.
 type ConfigResponse struct {
-	Server    ServerConfigResponse   `json:"server"`
-	Upstream  config.UpstreamConfig  `json:"upstream"`
-	CustomDNS config.CustomDNSConfig `json:"custom_dns"`
-	Logging   config.LoggingConfig   `json:"logging"`
-	Filtering config.FilteringConfig `json:"filtering"`
-	RateLimit config.RateLimitConfig `json:"rate_limit"`
-	API       APIConfigResponse      `json:"api"`
-	Cluster   ClusterConfigResponse  `json:"cluster"`
+	Server      ServerConfigResponse      `json:"server"`
+	Upstream    config.UpstreamConfig     `json:"upstream"`
+	CustomDNS   config.CustomDNSConfig    `json:"custom_dns"`
+	Cache       config.CacheConfig        `json:"cache"`
+	Logging     config.LoggingConfig      `json:"logging"`
+	Filtering   config.FilteringConfig    `json:"filtering"`
+	BlockPage   config.BlockPageConfig    `json:"block_page"`
+	GeoIP       config.GeoIPConfig        `json:"geoip"`
+	Anomaly     config.AnomalyConfig      `json:"anomaly"`
+	ThreatIntel ThreatIntelConfigResponse `json:"threat_intel"`
+	NRD         config.NRDConfig          `json:"nrd"`
+	Identity    config.IdentityConfig     `json:"identity"`
+	Audit       config.AuditConfig        `json:"audit"`
+	Canary      config.CanaryConfig       `json:"canary"`
+	Shadow      config.ShadowConfig       `json:"shadow"`
+	Advertise   config.AdvertiseConfig    `json:"advertise"`
+	RateLimit   config.RateLimitConfig    `json:"rate_limit"`
+	API         APIConfigResponse         `json:"api"`
+	Cluster     ClusterConfigResponse     `json:"cluster"`
 }
@@ -1,10 +1,19 @@
 package models
 
+import (
+	"time"
+
+	"github.com/jroosing/hydradns/internal/config"
+)
+
 // CustomDNSRecordsResponse is the response for GET /custom-dns.
 type CustomDNSRecordsResponse struct {
 	Hosts  map[string][]string     `json:"hosts"`
 	CNAMEs map[string]string       `json:"cnames"`
 	Count  CustomDNSCountsResponse `json:"count"`
+	// Local lists the names with local records, which cluster sync keeps
+	// on a secondary.
+	Local []string `json:"local,omitempty"`
 }
 
 // CustomDNSCountsResponse contains counts of custom DNS entries.
@@ -53,3 +62,84 @@ type CustomDNSOperationResponse struct {
 	Message string `json:"message"`
 	Data    any    `json:"data,omitempty"`
 }
+
+// RecordHealth is the health of one address of a custom DNS host with a
+// health check.
+type RecordHealth struct {
+	Name string `json:"name"`
+	Addr string `json:"addr"`
+	// Check describes the probe, e.g. "tcp:5432" or "http:8080/healthz".
+	Check               string `json:"check"`
+	Healthy             bool   `json:"healthy"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	Error               string `json:"error,omitempty"`
+	// CheckedAt is omitted before the first probe.
+	CheckedAt *time.Time `json:"checked_at,omitempty"`
+	// ChangedAt is when healthy last changed; omitted if it never did.
+	ChangedAt *time.Time `json:"changed_at,omitempty"`
+}
+
+// RecordHealthResponse is the response for GET /custom-dns/health.
+type RecordHealthResponse struct {
+	Records []RecordHealth `json:"records"`
+}
+
+// SteeringResponse is the response for GET /custom-dns/steering.
+type SteeringResponse struct {
+	Steering []config.HostSteering `json:"steering"`
+}
+
+// SetSteeringRequest is the request body for PUT /custom-dns/steering/{name}.
+type SetSteeringRequest struct {
+	Addrs []config.SteeredAddr `json:"addrs" binding:"required,min=1"`
+}
+
+// CustomDNSVersion is a snapshot of the custom DNS records taken before a
+// change made through the API.
+type CustomDNSVersion struct {
+	ID int64 `json:"id"`
+	// Action is the change made after the snapshot, e.g.
+	// "delete host nas.home.arpa".
+	Action    string              `json:"action"`
+	CreatedAt string              `json:"created_at"`
+	Hosts     map[string][]string `json:"hosts"`
+	CNAMEs    map[string]string   `json:"cnames"`
+}
+
+// CustomDNSHistoryResponse is the response for GET /custom-dns/history.
+type CustomDNSHistoryResponse struct {
+	// Versions are newest first.
+	Versions []CustomDNSVersion `json:"versions"`
+}
+
+// CustomDNSBulk is the JSON format of GET /custom-dns/export and POST
+// /custom-dns/import.
+type CustomDNSBulk struct {
+	Hosts  map[string][]string `json:"hosts"`
+	CNAMEs map[string]string   `json:"cnames"`
+}
+
+// CustomDNSImportError is a problem with one imported record. Line is the
+// line of the record in CSV and hosts-file imports, and 0 for JSON.
+type CustomDNSImportError struct {
+	Line  int    `json:"line,omitempty"`
+	Name  string `json:"name,omitempty"`
+	Error string `json:"error"`
+}
+
+// CustomDNSImportResponse is the response for POST /custom-dns/import.
+type CustomDNSImportResponse struct {
+	DryRun bool   `json:"dry_run"`
+	Mode   string `json:"mode"`
+	// Hosts and CNAMEs count the names imported.
+	Hosts  int `json:"hosts"`
+	CNAMEs int `json:"cnames"`
+	// Added, Updated, and Removed count the names that were (or, in a
+	// dry run, would be) changed; Unchanged those imported as they were.
+	Added     int `json:"added"`
+	Updated   int `json:"updated"`
+	Removed   int `json:"removed"`
+	Unchanged int `json:"unchanged"`
+	// Errors lists every invalid record; nothing is imported when any is.
+	Errors []CustomDNSImportError `json:"errors,omitempty"`
+}
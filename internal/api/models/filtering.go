@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // FilteringStatsResponse contains filtering statistics.
 type FilteringStatsResponse struct {
 	Enabled        bool   `json:"enabled"`
@@ -10,10 +12,64 @@ type FilteringStatsResponse struct {
 	BlacklistSize  int    `json:"blacklist_size"`
 }
 
+// FilteringCheckResponse is the response for GET /filtering/check. It
+// explains how a query for Domain from Client would be treated, without
+// resolving it.
+type FilteringCheckResponse struct {
+	Domain string `json:"domain"`
+	Client string `json:"client,omitempty"`
+	// Blocked is true when the client would not get the real answer: the
+	// domain is blocked, or the client is redirected to the captive portal.
+	Blocked bool `json:"blocked"`
+	// Action is allow, block, log or redirect (captive portal).
+	Action string `json:"action"`
+	// Reason is the step that decided: captive_portal, disabled, whitelist,
+	// temporary_allow, blacklist, temporary_block, nrd or no_match.
+	Reason string `json:"reason"`
+	// List and Rule are the list and rule that decided, if any.
+	List string `json:"list,omitempty"`
+	Rule string `json:"rule,omitempty"`
+	// Lists names every list blocking the domain, even when an earlier step
+	// such as the whitelist decided.
+	Lists []string `json:"lists,omitempty"`
+}
+
+// ListBlockStatsResponse is the response for GET /filtering/stats/lists.
+type ListBlockStatsResponse struct {
+	Lists []ListBlockStats `json:"lists"`
+}
+
+// ListBlockStats counts the queries one list blocked since startup. A query
+// matching several lists counts for each; Unique counts the queries no other
+// list would have blocked.
+type ListBlockStats struct {
+	Name       string               `json:"name"`
+	Blocked    uint64               `json:"blocked"`
+	Unique     uint64               `json:"unique"`
+	TopDomains []BlockedDomainCount `json:"top_domains"`
+}
+
+// BlockedDomainCount is a domain and how often a list blocked it.
+type BlockedDomainCount struct {
+	Domain string `json:"domain"`
+	Count  uint64 `json:"count"`
+}
+
 // DomainListResponse contains a list of domains.
 type DomainListResponse struct {
 	Domains []string `json:"domains"`
-	Count   int      `json:"count"`
+	// Count is the number of domains returned.
+	Count int `json:"count"`
+	// Total counts the domains in the list and Matched those matching the
+	// search. Domains is the page of matches starting at Offset, holding
+	// at most Limit domains when a limit was given.
+	Total   int `json:"total"`
+	Matched int `json:"matched"`
+	Offset  int `json:"offset"`
+	Limit   int `json:"limit,omitempty"`
+	// Local lists the returned domains added on this node, which cluster
+	// sync keeps on a secondary.
+	Local []string `json:"local,omitempty"`
 }
 
 // DomainRequest is used to add/remove domains from lists.
@@ -26,11 +82,33 @@ type DomainDeleteRequest struct {
 	Domains []string `json:"domains" binding:"required,min=1"`
 }
 
+// TemporaryAllowRequest allows a domain for a limited time.
+type TemporaryAllowRequest struct {
+	Domain string `json:"domain" binding:"required"`
+	// Duration is a Go duration string such as "10m" or "1h".
+	Duration string `json:"duration" binding:"required"`
+}
+
+// TemporaryAllowResponse describes an active temporary allow.
+type TemporaryAllowResponse struct {
+	Domain    string    `json:"domain"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// CachePurged is the number of cached responses dropped for the domain.
+	CachePurged int `json:"cache_purged"`
+}
+
 // FilteringEnabledRequest toggles filtering on/off.
 type FilteringEnabledRequest struct {
 	Enabled bool `json:"enabled"`
 }
 
+// FilteringTTLs are the TTLs, in seconds, of block page answers and of
+// blocked negative (NXDOMAIN and NODATA) answers.
+type FilteringTTLs struct {
+	BlockTTL    int `json:"block_ttl" binding:"required,min=1,max=86400"`
+	NegativeTTL int `json:"negative_ttl" binding:"required,min=1,max=86400"`
+}
+
 // Blocklist represents a configured remote blocklist.
 type Blocklist struct {
 	Name        string  `json:"name"`
@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// ReadinessResponse is the response for GET /ready.
+type ReadinessResponse struct {
+	// Status is "ready" or "not_ready".
+	Status string `json:"status"`
+	// Reasons says why the server is not ready, e.g. "blocklists are
+	// loading" or "shutting down"; omitted when ready.
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// DeepHealthResponse is the response for GET /health/deep.
+type DeepHealthResponse struct {
+	// Status is "ok"; "degraded" when an optional component failed and the
+	// server runs without it; or "failing" when DNS resolution is broken.
+	Status string `json:"status"`
+	// Components lists the server's components in start order.
+	Components []ComponentHealth `json:"components,omitempty"`
+	// Canary holds the health canary's latest results; omitted when the
+	// canary is disabled.
+	Canary *CanaryStatus `json:"canary,omitempty"`
+}
+
+// CanaryStatus is the health canary's view of DNS resolution.
+type CanaryStatus struct {
+	Healthy      bool          `json:"healthy"`
+	LastRound    *time.Time    `json:"last_round,omitempty"`
+	FailedRounds int           `json:"failed_rounds"` // Consecutive rounds in which no domain resolved
+	Checks       []CanaryCheck `json:"checks"`
+}
+
+// CanaryCheck is the latest result for one canary domain.
+type CanaryCheck struct {
+	Domain              string     `json:"domain"`
+	OK                  bool       `json:"ok"`
+	RCode               string     `json:"rcode,omitempty"`
+	LatencyMs           float64    `json:"latency_ms"`
+	Error               string     `json:"error,omitempty"`
+	CheckedAt           *time.Time `json:"checked_at,omitempty"`
+	LastSuccess         *time.Time `json:"last_success,omitempty"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+}
+
+// ComponentHealth is the state of one server component.
+type ComponentHealth struct {
+	Name     string    `json:"name"`
+	Required bool      `json:"required"` // A required component failing stops the server
+	State    string    `json:"state"`    // pending, starting, running, stopping, stopped, or failed
+	Error    string    `json:"error,omitempty"`
+	Since    time.Time `json:"since"`
+}
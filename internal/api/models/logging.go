@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// LoggingRequest is the request body for PUT /logging. Omitted fields are
+// left unchanged.
+type LoggingRequest struct {
+	// Level is DEBUG, INFO, WARN, or ERROR
+	Level string `json:"level,omitempty"`
+	// Structured selects structured output in the configured format
+	// (logging.structured_format) instead of plain text
+	Structured *bool `json:"structured,omitempty"`
+	// Duration, a Go duration string such as "15m" (at most 24h), makes
+	// the level change temporary: the previous level is restored after
+	// it. Temporary changes are not saved.
+	Duration string `json:"duration,omitempty"`
+}
+
+// LoggingResponse describes the current log level and format.
+type LoggingResponse struct {
+	Level      string `json:"level"`
+	Structured bool   `json:"structured"`
+	// RevertAt is when a temporary level change ends, and RevertLevel the
+	// level restored then
+	RevertAt    *time.Time `json:"revert_at,omitempty"`
+	RevertLevel string     `json:"revert_level,omitempty"`
+}
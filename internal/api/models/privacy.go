@@ -0,0 +1,12 @@
+package models
+
+// PrivacyConfig controls what the client query logs keep: the debug query
+// log, live query events, and recorded anomaly alerts.
+type PrivacyConfig struct {
+	// Level is full, anonymized (anonymized client addresses), domains
+	// (query names only), or none.
+	Level string `json:"level" binding:"required,oneof=full anonymized domains none"`
+	// Anonymize is truncate (to the /24 or /48 network) or hash; default
+	// truncate.
+	Anonymize string `json:"anonymize" binding:"omitempty,oneof=truncate hash"`
+}
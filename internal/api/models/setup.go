@@ -0,0 +1,15 @@
+package models
+
+// SetupStatusResponse reports whether first-run setup is still pending.
+type SetupStatusResponse struct {
+	SetupPending bool `json:"setup_pending"`
+}
+
+// SetupRequest completes first-run setup. Omitted fields keep the seeded
+// defaults.
+type SetupRequest struct {
+	// Upstreams replaces the upstream servers, in failover order.
+	Upstreams []string `json:"upstreams,omitempty"`
+	// FilteringEnabled turns domain filtering on or off.
+	FilteringEnabled *bool `json:"filtering_enabled,omitempty"`
+}
@@ -26,6 +26,8 @@ type ServerStatsResponse struct {
 	Memory         MemoryStats             `json:"memory"`
 	DNSStats       DNSStatsResponse        `json:"dns"`
 	FilteringStats *FilteringStatsResponse `json:"filtering,omitempty"`
+	// APIPanics counts panics recovered in API handlers since startup.
+	APIPanics uint64 `json:"api_panics"`
 }
 
 // DNSStatsResponse contains DNS query statistics.
@@ -35,5 +37,130 @@ type DNSStatsResponse struct {
 	QueriesTCP   uint64  `json:"queries_tcp"`
 	ResponsesNX  uint64  `json:"responses_nxdomain"`
 	ResponsesErr uint64  `json:"responses_error"`
+	Panics       uint64  `json:"panics"` // Panics recovered while serving queries
 	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	// UDPListeners has one entry per SO_REUSEPORT socket while the DNS server runs.
+	UDPListeners []UDPListenerStats `json:"udp_listeners,omitempty"`
+	// Parse counts requests rejected by the DNS parser, keyed by transport.
+	Parse map[string]ParseStats `json:"parse_errors,omitempty"`
+	// TCPConnections is the number of open TCP connections per client IP.
+	TCPConnections map[string]int `json:"tcp_connections_per_ip,omitempty"`
+	// Anomalies is the number of anomaly alerts raised per kind, while
+	// anomaly detection is enabled.
+	Anomalies map[string]uint64 `json:"anomalies,omitempty"`
+	// QTypeRules is the number of queries answered by each qtype rule,
+	// keyed by a description such as "ANY -> refused".
+	QTypeRules map[string]uint64 `json:"qtype_rules,omitempty"`
+	// Cache reports the response cache while the DNS server runs.
+	Cache *CacheStats `json:"cache,omitempty"`
+}
+
+// CacheStats contains response cache counters. A high eviction count
+// relative to hits means cache.max_entries or cache.max_bytes is too small
+// for the working set.
+type CacheStats struct {
+	EvictionPolicy string `json:"eviction_policy"`
+	Entries        int    `json:"entries"`
+	MaxEntries     int    `json:"max_entries"`
+	// Bytes is the total size of cached responses; MaxBytes is the budget,
+	// 0 when unlimited.
+	Bytes    int `json:"bytes"`
+	MaxBytes int `json:"max_bytes"`
+	// Oversized counts responses not cached because they exceed
+	// cache.max_entry_bytes or cache.max_bytes.
+	Oversized uint64 `json:"oversized"`
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	// NegativeHits counts hits on NXDOMAIN, NODATA, and SERVFAIL responses;
+	// they are included in Hits.
+	NegativeHits uint64 `json:"negative_hits"`
+	// Evictions counts live entries removed to make room for new ones.
+	Evictions uint64 `json:"evictions"`
+	// Expirations counts entries removed after their TTL ran out.
+	Expirations uint64  `json:"expirations"`
+	HitRatio    float64 `json:"hit_ratio"`
+	// Shared counts the shared redis or memcached tier; omitted when none
+	// is configured.
+	Shared *SharedCacheStats `json:"shared,omitempty"`
+}
+
+// SharedCacheStats contains counters for the cache shared between nodes.
+type SharedCacheStats struct {
+	Backend string `json:"backend"`
+	// Hits counts queries answered from the shared cache after missing the
+	// local one; Misses counts those that went upstream.
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+	// Errors counts failed lookups and stores, e.g. timeouts.
+	Errors uint64 `json:"errors"`
+	Stores uint64 `json:"stores"`
+	// Dropped counts stores skipped because too many were in flight.
+	Dropped uint64 `json:"dropped"`
+}
+
+// ParseStats contains counters for requests the DNS parser rejected on one
+// transport. Malformed counts every rejection; the other counters break out
+// specific causes and are included in Malformed.
+type ParseStats struct {
+	Malformed         uint64 `json:"malformed"`
+	PointerLoops      uint64 `json:"pointer_loops"`
+	OversizedNames    uint64 `json:"oversized_names"`
+	OversizedMessages uint64 `json:"oversized_messages"`
+}
+
+// UDPListenerStats contains counters for one UDP listener socket.
+type UDPListenerStats struct {
+	Listener       int    `json:"listener"`
+	PacketsRead    uint64 `json:"packets_read"`
+	PacketsHandled uint64 `json:"packets_handled"`
+	PacketsDropped uint64 `json:"packets_dropped"`
+	RateLimited    uint64 `json:"rate_limited"`
+	// Slipped counts rate-limited queries answered with TC=1 instead of
+	// dropped; they are included in RateLimited.
+	Slipped uint64 `json:"slipped"`
+	// Duplicates counts retransmitted queries answered together with the
+	// identical query already being resolved; they are included in
+	// PacketsHandled.
+	Duplicates    uint64 `json:"duplicates"`
+	QueueDepth    int    `json:"queue_depth"`
+	QueueCapacity int    `json:"queue_capacity"`
+	// KernelDrops counts datagrams the kernel dropped before the server
+	// read them, mostly because the receive buffer was full. It and
+	// RecvQueueBytes are reported on Linux only.
+	KernelDrops    uint64 `json:"kernel_drops"`
+	RecvQueueBytes int    `json:"recv_queue_bytes"`
+	// RecvBuffer and SendBuffer are the socket buffer sizes in bytes
+	// granted by the kernel.
+	RecvBuffer int `json:"recv_buffer"`
+	SendBuffer int `json:"send_buffer"`
+}
+
+// QueryEvent is the data of a "query" event on GET /events.
+type QueryEvent struct {
+	Time       time.Time `json:"time"`
+	Transport  string    `json:"transport"`
+	Client     string    `json:"client"`
+	Name       string    `json:"name"`
+	Type       string    `json:"type"`
+	RCode      string    `json:"rcode"`
+	Source     string    `json:"source"`
+	DurationMs float64   `json:"duration_ms"`
+	// TraceID matches the query's log entries
+	TraceID string `json:"trace_id,omitempty"`
+}
+
+// Anomaly is an alert raised for a client showing DNS tunneling or other
+// anomalous query patterns.
+type Anomaly struct {
+	Time   time.Time `json:"time"`
+	Client string    `json:"client"`
+	// Kind is one of nxdomain_rate, txt_rate, long_label, high_entropy
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Detail string `json:"detail"`
+}
+
+// AnomaliesResponse is the response for GET /anomalies.
+type AnomaliesResponse struct {
+	Anomalies []Anomaly `json:"anomalies"`
 }
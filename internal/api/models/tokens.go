@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// CreateAPITokenRequest is the request body for POST /tokens.
+type CreateAPITokenRequest struct {
+	// Name identifies the token, e.g. "home-assistant"
+	Name string `json:"name" binding:"required"`
+	// Scopes limit the token to API sections: "filtering", "custom-dns",
+	// "upstreams", or "stats", optionally suffixed with ":read" for GET only.
+	// "block-page" grants only the allow button of the block page
+	Scopes []string `json:"scopes" binding:"required"`
+	// ExpiresIn is a Go duration after which the token stops working
+	// (e.g. "720h"); empty for no expiry
+	ExpiresIn string `json:"expires_in,omitempty"`
+}
+
+// APITokenResponse describes an API token. The token value is only returned
+// when it is created.
+type APITokenResponse struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Expired   bool       `json:"expired"`
+	CreatedAt string     `json:"created_at,omitempty"`
+}
+
+// CreateAPITokenResponse is returned by POST /tokens.
+type CreateAPITokenResponse struct {
+	APITokenResponse
+
+	// Token is the secret to send in the X-API-Key header. It cannot be
+	// retrieved again.
+	Token string `json:"token"`
+}
+
+// APITokenListResponse is returned by GET /tokens.
+type APITokenListResponse struct {
+	Tokens []APITokenResponse `json:"tokens"`
+}
@@ -0,0 +1,110 @@
+package models
+
+import "time"
+
+// UpstreamsResponse lists the configured upstream DNS servers in priority order.
+type UpstreamsResponse struct {
+	Servers []string `json:"servers"`
+}
+
+// UpdateUpstreamsRequest replaces the upstream server list.
+// The order of Servers is the failover order (first = preferred).
+type UpdateUpstreamsRequest struct {
+	Servers []string `json:"servers" binding:"required,min=1,max=3"`
+}
+
+// UpstreamStatus contains latency and availability statistics for one upstream.
+type UpstreamStatus struct {
+	Address             string     `json:"address"`
+	Healthy             bool       `json:"healthy"`
+	Queries             uint64     `json:"queries"`
+	Successes           uint64     `json:"successes"`
+	Failures            uint64     `json:"failures"`
+	SuccessRate         float64    `json:"success_rate"`
+	ConsecutiveFailures uint64     `json:"consecutive_failures"`
+	RTTP50Ms            float64    `json:"rtt_p50_ms"`
+	RTTP95Ms            float64    `json:"rtt_p95_ms"`
+	LastSuccess         *time.Time `json:"last_success,omitempty"`
+	LastFailure         *time.Time `json:"last_failure,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+	MismatchedResponses uint64     `json:"mismatched_responses"`
+	Country             string     `json:"country,omitempty"` // Set when a GeoIP country database is loaded
+	ASN                 uint32     `json:"asn,omitempty"`     // Set when a GeoIP ASN database is loaded
+	ASOrg               string     `json:"as_org,omitempty"`
+}
+
+// UpstreamStatusResponse is the response for GET /upstreams/status.
+type UpstreamStatusResponse struct {
+	Upstreams []UpstreamStatus `json:"upstreams"`
+}
+
+// UpstreamAnswer is how one upstream answered a compared query.
+type UpstreamAnswer struct {
+	Address string `json:"address"`
+	// Error is set when the upstream did not answer
+	Error string  `json:"error,omitempty"`
+	RTTMs float64 `json:"rtt_ms"`
+	RCode string  `json:"rcode,omitempty"`
+	// Answers are the answer records other than RRSIGs as zone-file lines,
+	// sorted
+	Answers []string `json:"answers"`
+	// DNSSEC is validated (AD flag set), signed (RRSIGs without AD), or
+	// unsigned
+	DNSSEC            string `json:"dnssec,omitempty"`
+	ExtendedErrorCode *int   `json:"extended_error_code,omitempty"`
+	ExtendedErrorText string `json:"extended_error_text,omitempty"`
+	// Differs is set when the response code or records, TTLs aside, differ
+	// from those most upstreams returned
+	Differs bool `json:"differs"`
+}
+
+// UpstreamCompareResponse is the response for GET /upstreams/compare.
+type UpstreamCompareResponse struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// Consistent is set when every upstream that answered returned the same
+	// response code and records
+	Consistent bool             `json:"consistent"`
+	Upstreams  []UpstreamAnswer `json:"upstreams"`
+}
+
+// ShadowAnswer is one side of a query compared with the shadow upstreams.
+type ShadowAnswer struct {
+	Upstream string `json:"upstream"`
+	RCode    string `json:"rcode"`
+	// Answers are the answer records other than RRSIGs as zone-file lines
+	// without TTLs, sorted
+	Answers   []string `json:"answers"`
+	LatencyMs float64  `json:"latency_ms"`
+}
+
+// ShadowDivergence is a query the shadow upstreams answered differently.
+type ShadowDivergence struct {
+	Time    time.Time    `json:"time"`
+	Name    string       `json:"name"`
+	Type    string       `json:"type"`
+	TraceID string       `json:"trace_id"`
+	Primary ShadowAnswer `json:"primary"`
+	Shadow  ShadowAnswer `json:"shadow"`
+}
+
+// UpstreamShadowResponse is the response for GET /upstreams/shadow.
+type UpstreamShadowResponse struct {
+	Enabled bool     `json:"enabled"`
+	Servers []string `json:"servers,omitempty"`
+	Percent float64  `json:"percent,omitempty"`
+	// Mirrored counts the queries sent to the shadow upstreams; each is
+	// matched, diverged, or failed when no shadow upstream answered
+	Mirrored uint64 `json:"mirrored"`
+	Matched  uint64 `json:"matched"`
+	Diverged uint64 `json:"diverged"`
+	Failed   uint64 `json:"failed"`
+	// Skipped counts sampled queries not mirrored because too many shadow
+	// queries were running
+	Skipped uint64 `json:"skipped"`
+	// PrimaryLatencyMs and ShadowLatencyMs are the mean upstream times of
+	// the compared queries
+	PrimaryLatencyMs float64            `json:"primary_latency_ms"`
+	ShadowLatencyMs  float64            `json:"shadow_latency_ms"`
+	Recent           []ShadowDivergence `json:"recent"`
+}
@@ -1,34 +1,108 @@
 package api
 
 import (
+	"net/http"
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/jroosing/hydradns/internal/api/handlers"
 	"github.com/jroosing/hydradns/internal/api/middleware"
 	"github.com/jroosing/hydradns/internal/config"
+	"github.com/jroosing/hydradns/internal/server"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 
-	_ "github.com/jroosing/hydradns/internal/api/docs" // swagger docs
+	"github.com/jroosing/hydradns/internal/api/docs"
 )
 
+// apiRateLimitMaxClients bounds the client IPs tracked by the API rate
+// limiter.
+const apiRateLimitMaxClients = 4096
+
 func RegisterRoutes(r *gin.Engine, h *handlers.Handler, cfg *config.Config) {
 	// Swagger UI at /swagger/*
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
+	// Raw OpenAPI document, embedded in the binary, for client generators.
+	r.GET("/openapi.json", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json", docs.SpecJSON)
+	})
+	r.GET("/openapi.yaml", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/yaml", docs.SpecYAML)
+	})
+
+	// Setup status is public so a UI can detect a fresh install before the
+	// generated API key has been entered.
+	r.GET("/api/v1/setup", h.GetSetupStatus)
+
+	// Readiness is public so orchestrator probes need no API key; it only
+	// says whether this node should receive traffic.
+	r.GET("/api/v1/ready", h.Ready)
+
 	api := r.Group("/api/v1")
 
-	// Optional API key protection.
+	// Limits come before authentication so failed key guesses count too.
+	if cfg != nil && cfg.API.RateLimitRPS > 0 {
+		api.Use(middleware.RateLimit(server.NewTokenBucketRateLimiter(server.TokenBucketConfig{
+			Rate:            cfg.API.RateLimitRPS,
+			Burst:           cfg.API.RateLimitBurst,
+			CleanupInterval: time.Minute,
+			MaxEntries:      apiRateLimitMaxClients,
+		})))
+	}
+	if cfg != nil && cfg.API.MaxBodyBytes > 0 {
+		api.Use(middleware.BodyLimit(int64(cfg.API.MaxBodyBytes)))
+	}
+
+	// Optional API key protection. Scoped API tokens reach only the
+	// sections they were granted; everything else needs the admin key.
 	if cfg != nil && cfg.API.APIKey != "" {
-		api.Use(middleware.RequireAPIKey(cfg.API.APIKey))
+		api.Use(middleware.RequireAuth(cfg.API.APIKey, h.LookupAPIToken))
 	}
 
+	api.POST("/setup", h.PostSetup)
+
 	api.GET("/health", h.Health)
+	api.GET("/health/deep", h.DeepHealth)
 	api.GET("/stats", h.Stats)
+	api.GET("/events", h.StreamEvents)
+	api.GET("/anomalies", h.ListAnomalies)
 
 	api.GET("/config", h.GetConfig)
 	api.PUT("/config", h.PutConfig)
 	api.POST("/config/reload", h.ReloadConfig)
 
+	api.GET("/privacy", h.GetPrivacy)
+	api.PUT("/privacy", h.SetPrivacy)
+
+	// Log level and format (admin key only)
+	api.GET("/logging", h.GetLogging)
+	api.PUT("/logging", h.SetLogging)
+
+	// DNS advertisement and client coverage (per node, admin key only)
+	api.GET("/advertise", h.GetAdvertise)
+
+	// Negative and SERVFAIL cache overrides (per node, admin key only)
+	api.GET("/cache/overrides", h.ListCacheOverrides)
+	api.PUT("/cache/overrides/:zone", h.SetCacheOverride)
+	api.DELETE("/cache/overrides/:zone", h.DeleteCacheOverride)
+
+	// Packet capture (per node, admin key only)
+	api.GET("/capture", h.GetCapture)
+	api.POST("/capture", h.StartCapture)
+	api.DELETE("/capture", h.StopCapture)
+	api.GET("/capture/pcap", h.DownloadCapture)
+
+	// Endpoints that change configuration synced from the primary are wrapped
+	// in h.RejectOnSecondary so edits on a secondary are not silently lost.
+	// Custom DNS and whitelist/blacklist edits on a secondary are stored as
+	// local entries instead, which cluster sync keeps.
+	api.GET("/upstreams", h.GetUpstreams)
+	api.PUT("/upstreams", h.RejectOnSecondary, h.PutUpstreams)
+	api.GET("/upstreams/status", h.GetUpstreamStatus)
+	api.GET("/upstreams/compare", h.CompareUpstreams)
+	api.GET("/upstreams/shadow", h.GetUpstreamShadow)
+
 	api.GET("/filtering/whitelist", h.GetWhitelist)
 	api.POST("/filtering/whitelist", h.AddWhitelist)
 	api.DELETE("/filtering/whitelist", h.RemoveWhitelist)
@@ -37,25 +111,50 @@ func RegisterRoutes(r *gin.Engine, h *handlers.Handler, cfg *config.Config) {
 	api.POST("/filtering/blacklist", h.AddBlacklist)
 	api.DELETE("/filtering/blacklist", h.RemoveBlacklist)
 	api.GET("/filtering/blocklists", h.GetBlocklists)
-	api.PUT("/filtering/blocklists/:name/enabled", h.SetBlocklistEnabled)
+	api.PUT("/filtering/blocklists/:name/enabled", h.RejectOnSecondary, h.SetBlocklistEnabled)
 	api.POST("/filtering/blocklists/:name/refresh", h.RefreshBlocklist)
 
 	api.GET("/filtering/stats", h.FilteringStats)
-	api.PUT("/filtering/enabled", h.SetFilteringEnabled)
+	api.GET("/filtering/stats/lists", h.ListBlockStats)
+	api.GET("/filtering/check", h.CheckFiltering)
+	api.GET("/filtering/rpz", h.GetFilteringRPZ)
+	api.POST("/filtering/allow-temporarily", h.AllowTemporarily)
+	api.PUT("/filtering/enabled", h.RejectOnSecondary, h.SetFilteringEnabled)
+	api.GET("/filtering/ttl", h.GetFilteringTTLs)
+	api.PUT("/filtering/ttl", h.RejectOnSecondary, h.SetFilteringTTLs)
 
 	// Custom DNS endpoints
 	api.GET("/custom-dns", h.ListCustomDNS)
+	api.GET("/custom-dns/health", h.ListRecordHealth)
+	api.GET("/custom-dns/steering", h.ListSteering)
+	api.PUT("/custom-dns/steering/:name", h.SetSteering)
+	api.DELETE("/custom-dns/steering/:name", h.DeleteSteering)
 	api.POST("/custom-dns/hosts", h.AddHost)
 	api.PUT("/custom-dns/hosts/:name", h.UpdateHost)
 	api.DELETE("/custom-dns/hosts/:name", h.DeleteHost)
 	api.POST("/custom-dns/cnames", h.AddCNAME)
 	api.PUT("/custom-dns/cnames/:alias", h.UpdateCNAME)
 	api.DELETE("/custom-dns/cnames/:alias", h.DeleteCNAME)
+	api.GET("/custom-dns/export", h.ExportCustomDNS)
+	api.POST("/custom-dns/import", h.ImportCustomDNS)
+	api.GET("/custom-dns/history", h.ListCustomDNSHistory)
+	api.POST("/custom-dns/history/:id/rollback", h.RejectOnSecondary, h.RollbackCustomDNS)
+
+	// Atomic custom DNS and whitelist/blacklist changes (admin key only)
+	api.POST("/batch", h.ApplyBatch)
+
+	// API tokens (per node, admin key only)
+	api.GET("/tokens", h.ListAPITokens)
+	api.POST("/tokens", h.CreateAPIToken)
+	api.DELETE("/tokens/:name", h.DeleteAPIToken)
 
 	// Cluster endpoints
 	api.GET("/cluster/status", h.GetClusterStatus)
 	api.GET("/cluster/config", h.GetClusterConfig)
 	api.PUT("/cluster/config", h.PutClusterConfig)
 	api.GET("/cluster/export", h.GetClusterExport)
+	api.GET("/cluster/changes", h.GetClusterChanges)
 	api.POST("/cluster/sync", h.PostClusterSync)
+	api.POST("/cluster/promote", h.PromoteCluster)
+	api.GET("/cluster/promotions", h.GetClusterPromotions)
 }
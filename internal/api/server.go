@@ -42,10 +42,10 @@ func New(cfg *config.Config, db *database.DB, logger *slog.Logger) *Server {
 
 	gin.SetMode(gin.ReleaseMode)
 	engine := gin.New()
-	engine.Use(gin.Recovery())
+	h := handlers.New(cfg, db, logger)
+	engine.Use(middleware.Recovery(logger, h.RecordPanic))
 	engine.Use(middleware.SlogRequestLogger(logger))
 
-	h := handlers.New(cfg, db, logger)
 	RegisterRoutes(engine, h, cfg)
 
 	// Mount SPA (frontend) - always enabled, web UI is mandatory
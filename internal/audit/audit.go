@@ -0,0 +1,205 @@
+// Package audit writes a tamper-evident log of the queries HydraDNS sends to
+// upstream servers, for environments that must audit DNS egress.
+//
+// The log is a file of JSON lines, one per upstream query, kept separate
+// from the client query logs. Each entry carries a sequence number and the
+// SHA-256 hash of the previous entry, and its own hash covers both, so
+// editing, removing, or reordering entries breaks the chain from that point
+// on. Verify checks a log.
+//
+// The chain proves integrity relative to the log itself: someone able to
+// rewrite the whole file can rebuild it. Ship the log (or its latest hash)
+// to write-once storage to guard against that.
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// genesisHash is the previous hash of the first entry in a log.
+var genesisHash = strings.Repeat("0", sha256.Size*2)
+
+// maxLineSize bounds the length of one log line read back.
+const maxLineSize = 64 * 1024
+
+// Entry is one upstream query in the audit log.
+type Entry struct {
+	Seq       uint64 `json:"seq"`
+	Time      string `json:"time"` // RFC 3339, UTC
+	Domain    string `json:"domain"`
+	QType     string `json:"qtype"`
+	Upstream  string `json:"upstream"`
+	Transport string `json:"transport"` // "udp" or "tcp"
+	// Prev is the hash of the previous entry
+	Prev string `json:"prev"`
+	// Hash is the SHA-256 of this entry's JSON encoding without Hash
+	Hash string `json:"hash,omitempty"`
+}
+
+// computeHash returns the hash of e with its Hash field cleared.
+func (e Entry) computeHash() (string, error) {
+	e.Hash = ""
+	b, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Logger appends entries to an audit log file.
+type Logger struct {
+	mu     sync.Mutex
+	f      *os.File
+	seq    uint64
+	prev   string
+	closed bool
+	err    error // first write error, reported by Err
+}
+
+// Open opens the audit log at path, creating it if needed, and continues
+// the hash chain of the entries already in it. The existing entries are
+// not verified; use Verify for that.
+func Open(path string) (*Logger, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	last, err := lastEntry(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to read audit log %s: %w", path, err)
+	}
+
+	l := &Logger{f: f, prev: genesisHash}
+	if last != nil {
+		l.seq = last.Seq
+		l.prev = last.Hash
+	}
+	return l, nil
+}
+
+// lastEntry returns the last entry in the log, or nil if it is empty.
+func lastEntry(r io.Reader) (*Entry, error) {
+	var last *Entry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), maxLineSize)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		last = &e
+	}
+	return last, scanner.Err()
+}
+
+// Record appends an entry for a query for domain of type qtype sent to
+// upstream over transport. Write errors are kept for Err rather than
+// returned, so a full disk does not fail DNS resolution; the chain stays
+// intact because a failed entry is not counted.
+func (l *Logger) Record(domain, qtype, upstream, transport string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return
+	}
+
+	e := Entry{
+		Seq:       l.seq + 1,
+		Time:      time.Now().UTC().Format(time.RFC3339Nano),
+		Domain:    domain,
+		QType:     qtype,
+		Upstream:  upstream,
+		Transport: transport,
+		Prev:      l.prev,
+	}
+	hash, err := e.computeHash()
+	if err == nil {
+		e.Hash = hash
+		var b []byte
+		if b, err = json.Marshal(e); err == nil {
+			_, err = l.f.Write(append(b, '\n'))
+		}
+	}
+	if err != nil {
+		if l.err == nil {
+			l.err = err
+		}
+		return
+	}
+	l.seq = e.Seq
+	l.prev = e.Hash
+}
+
+// Err returns the first error that kept an entry from being written.
+func (l *Logger) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
+
+// Close syncs and closes the log file. Later Record calls are ignored.
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return nil
+	}
+	l.closed = true
+	return errors.Join(l.f.Sync(), l.f.Close())
+}
+
+// Verify checks the hash chain of the audit log read from r and returns the
+// number of entries it holds. The error names the first line that was
+// altered, removed, or reordered.
+func Verify(r io.Reader) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), maxLineSize)
+
+	prev := genesisHash
+	var seq uint64
+	count := 0
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return count, fmt.Errorf("line %d: %w", line, err)
+		}
+		if e.Seq != seq+1 {
+			return count, fmt.Errorf("line %d: sequence %d follows %d", line, e.Seq, seq)
+		}
+		if e.Prev != prev {
+			return count, fmt.Errorf("line %d: previous hash does not match", line)
+		}
+		hash, err := e.computeHash()
+		if err != nil {
+			return count, fmt.Errorf("line %d: %w", line, err)
+		}
+		if hash != e.Hash {
+			return count, fmt.Errorf("line %d: entry hash does not match", line)
+		}
+		seq = e.Seq
+		prev = e.Hash
+		count++
+	}
+	if err := scanner.Err(); err != nil {
+		return count, err
+	}
+	return count, nil
+}
@@ -0,0 +1,132 @@
+package audit_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jroosing/hydradns/internal/audit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeLog records the given domains in a new audit log and returns its path.
+func writeLog(t *testing.T, domains ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := audit.Open(path)
+	require.NoError(t, err)
+	for _, d := range domains {
+		l.Record(d, "A", "9.9.9.9", "udp")
+	}
+	require.NoError(t, l.Err())
+	require.NoError(t, l.Close())
+	return path
+}
+
+func verifyFile(t *testing.T, path string) (int, error) {
+	t.Helper()
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	return audit.Verify(f)
+}
+
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+}
+
+func writeLines(t *testing.T, path string, lines []string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600))
+}
+
+// ============================================================================
+// Logger Tests
+// ============================================================================
+
+func TestLogger_WritesVerifiableChain(t *testing.T) {
+	path := writeLog(t, "a.example", "b.example", "c.example")
+
+	n, err := verifyFile(t, path)
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	lines := readLines(t, path)
+	require.Len(t, lines, 3)
+	assert.Contains(t, lines[0], `"domain":"a.example"`)
+	assert.Contains(t, lines[0], `"upstream":"9.9.9.9"`)
+	assert.Contains(t, lines[0], `"transport":"udp"`)
+}
+
+func TestLogger_ContinuesChainAfterReopen(t *testing.T) {
+	path := writeLog(t, "a.example")
+
+	l, err := audit.Open(path)
+	require.NoError(t, err)
+	l.Record("b.example", "AAAA", "1.1.1.1", "tcp")
+	require.NoError(t, l.Close())
+
+	n, err := verifyFile(t, path)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+}
+
+func TestLogger_IgnoresRecordAfterClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := audit.Open(path)
+	require.NoError(t, err)
+	require.NoError(t, l.Close())
+
+	l.Record("a.example", "A", "9.9.9.9", "udp")
+
+	n, err := verifyFile(t, path)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+// ============================================================================
+// Verify Tests
+// ============================================================================
+
+func TestVerify_DetectsTampering(t *testing.T) {
+	tests := map[string]func([]string) []string{
+		"edited entry": func(lines []string) []string {
+			lines[1] = strings.Replace(lines[1], "b.example", "x.example", 1)
+			return lines
+		},
+		"removed entry": func(lines []string) []string {
+			return append(lines[:1], lines[2:]...)
+		},
+		"reordered entries": func(lines []string) []string {
+			lines[1], lines[2] = lines[2], lines[1]
+			return lines
+		},
+		"truncated head": func(lines []string) []string {
+			return lines[1:]
+		},
+	}
+	for name, tamper := range tests {
+		path := writeLog(t, "a.example", "b.example", "c.example")
+		writeLines(t, path, tamper(readLines(t, path)))
+
+		_, err := verifyFile(t, path)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestVerify_ReportsValidPrefix(t *testing.T) {
+	path := writeLog(t, "a.example", "b.example", "c.example")
+	lines := readLines(t, path)
+	lines[2] = strings.Replace(lines[2], "c.example", "x.example", 1)
+	writeLines(t, path, lines)
+
+	n, err := verifyFile(t, path)
+	require.Error(t, err)
+	assert.Equal(t, 2, n)
+	assert.Contains(t, err.Error(), "line 3")
+}
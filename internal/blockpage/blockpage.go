@@ -0,0 +1,269 @@
+// Package blockpage serves the page browsers show for domains blocked by
+// HydraDNS.
+//
+// When the block page is enabled, blocked A and AAAA queries resolve to this
+// node (see resolvers.FilteringResolver.SetBlockPageAddrs), so a browser
+// opening a blocked site requests it from this server. Every request is
+// answered with a "blocked by HydraDNS" page naming the domain from the Host
+// header (or the TLS server name).
+//
+// The page offers to allow the domain for AllowDuration. When the API is
+// protected, the request must carry an API token with the block-page scope,
+// which grants nothing else; the admin API key is never accepted, since the
+// page is usually served over plain HTTP. Attempts are rate limited per
+// client address.
+package blockpage
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jroosing/hydradns/internal/config"
+	"github.com/jroosing/hydradns/internal/dns"
+	"github.com/jroosing/hydradns/internal/filtering"
+	"github.com/jroosing/hydradns/internal/server"
+)
+
+// AllowDuration is how long the allow button lifts a block.
+const AllowDuration = time.Hour
+
+// AllowPath is the form target of the allow button.
+const AllowPath = "/.hydradns/allow"
+
+// shutdownTimeout bounds graceful shutdown of the listeners.
+const shutdownTimeout = 5 * time.Second
+
+// Allow attempts per client: a burst of allowBurst, then one every
+// allowInterval. allowMaxClients bounds the tracked client addresses.
+const (
+	allowBurst      = 5
+	allowInterval   = 10 * time.Second
+	allowMaxClients = 4096
+)
+
+// TokenCheck reports whether token may lift blocks from the block page.
+type TokenCheck func(ctx context.Context, token string) bool
+
+// Server serves the block page over HTTP and, with a certificate, HTTPS.
+type Server struct {
+	cfg     config.BlockPageConfig
+	tokens  TokenCheck
+	policy  *filtering.PolicyEngine
+	limiter *server.TokenBucketRateLimiter
+	logger  *slog.Logger
+}
+
+// New creates a block page server. tokens gates the allow button; if nil,
+// anyone who sees the page can allow the domain, like the unprotected API.
+// A nil policy hides the allow button. A nil logger uses slog.Default().
+func New(cfg config.BlockPageConfig, tokens TokenCheck, policy *filtering.PolicyEngine, logger *slog.Logger) *Server {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	limiter := server.NewTokenBucketRateLimiter(server.TokenBucketConfig{
+		Rate:            1 / allowInterval.Seconds(),
+		Burst:           allowBurst,
+		CleanupInterval: time.Minute,
+		MaxEntries:      allowMaxClients,
+	})
+	return &Server{cfg: cfg, tokens: tokens, policy: policy, limiter: limiter, logger: logger}
+}
+
+// Handler returns the HTTP handler serving the block page.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+AllowPath, s.handleAllow)
+	mux.HandleFunc("/", s.handleBlocked)
+	return mux
+}
+
+// Run serves the block page on the HTTP address and, when a certificate is
+// configured, the HTTPS address. It blocks until ctx is canceled or a
+// listener fails.
+func (s *Server) Run(ctx context.Context) error {
+	handler := s.Handler()
+	servers := []*http.Server{newHTTPServer(s.cfg.HTTPAddr, handler)}
+	tls := s.cfg.TLSCert != "" && s.cfg.TLSKey != ""
+	if tls {
+		servers = append(servers, newHTTPServer(s.cfg.HTTPSAddr, handler))
+	}
+
+	errc := make(chan error, len(servers))
+	go func() { errc <- servers[0].ListenAndServe() }()
+	if tls {
+		go func() { errc <- servers[1].ListenAndServeTLS(s.cfg.TLSCert, s.cfg.TLSKey) }()
+	}
+
+	s.logger.Info("block page starting", "http_addr", s.cfg.HTTPAddr, "https", tls, "https_addr", s.cfg.HTTPSAddr)
+
+	var err error
+	select {
+	case <-ctx.Done():
+	case err = <-errc:
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	for _, srv := range servers {
+		_ = srv.Shutdown(shutdownCtx)
+	}
+
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+func newHTTPServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       time.Minute,
+	}
+}
+
+// pageData fills the block page template.
+type pageData struct {
+	Domain    string
+	CanAllow  bool
+	NeedsKey  bool // An allow token must be entered
+	AllowPath string
+	Duration  string
+	Allowed   bool
+	Error     string
+}
+
+func (s *Server) handleBlocked(w http.ResponseWriter, r *http.Request) {
+	domain := requestDomain(r)
+	s.render(w, http.StatusForbidden, s.pageData(domain, ""))
+}
+
+func (s *Server) handleAllow(w http.ResponseWriter, r *http.Request) {
+	domain, err := dns.CanonicalName(r.PostFormValue("domain"))
+	if err != nil || domain == "" || s.policy == nil {
+		s.render(w, http.StatusBadRequest, s.pageData(requestDomain(r), "Invalid domain."))
+		return
+	}
+
+	// Limited before the token check so failed guesses count too
+	if !s.limiter.Allow(clientAddr(r)) {
+		w.Header().Set("Retry-After", "10")
+		s.render(w, http.StatusTooManyRequests, s.pageData(domain, "Too many attempts. Try again later."))
+		return
+	}
+
+	if s.tokens != nil && !s.tokens(r.Context(), r.PostFormValue("token")) {
+		s.logger.Warn("block page allow rejected", "domain", domain, "client", r.RemoteAddr)
+		s.render(w, http.StatusUnauthorized, s.pageData(domain, "Invalid allow token."))
+		return
+	}
+
+	s.policy.AllowFor(domain, AllowDuration)
+	s.logger.Info("domain allowed from block page", "domain", domain, "duration", AllowDuration, "client", r.RemoteAddr)
+
+	data := s.pageData(domain, "")
+	data.Allowed = true
+	s.render(w, http.StatusOK, data)
+}
+
+func (s *Server) pageData(domain, errMsg string) pageData {
+	return pageData{
+		Domain:    domain,
+		CanAllow:  s.policy != nil && domain != "",
+		NeedsKey:  s.tokens != nil,
+		AllowPath: AllowPath,
+		Duration:  "1 hour",
+		Error:     errMsg,
+	}
+}
+
+func (s *Server) render(w http.ResponseWriter, status int, data pageData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := pageTemplate.Execute(w, data); err != nil {
+		s.logger.Debug("block page render failed", "err", err)
+	}
+}
+
+// clientAddr returns the address of the connection a request came in on,
+// without the port, so attempts are limited per client.
+func clientAddr(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// requestDomain returns the blocked domain the browser asked for: the TLS
+// server name or the Host header, canonicalized. It is empty if neither is
+// a valid name.
+func requestDomain(r *http.Request) string {
+	host := r.Host
+	if r.TLS != nil && r.TLS.ServerName != "" {
+		host = r.TLS.ServerName
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if net.ParseIP(strings.Trim(host, "[]")) != nil {
+		return ""
+	}
+	domain, err := dns.CanonicalName(host)
+	if err != nil {
+		return ""
+	}
+	return domain
+}
+
+var pageTemplate = template.Must(template.New("blockpage").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Blocked by HydraDNS</title>
+<style>
+body { margin: 0; min-height: 100vh; display: flex; align-items: center; justify-content: center;
+  font-family: system-ui, -apple-system, sans-serif; background: #0f172a; color: #e2e8f0; }
+main { max-width: 32rem; padding: 2rem; background: #1e293b; border-radius: 12px; box-shadow: 0 10px 30px rgba(0,0,0,.4); }
+h1 { margin-top: 0; font-size: 1.5rem; }
+code { background: #334155; padding: .1rem .4rem; border-radius: 4px; word-break: break-all; }
+form { margin-top: 1.5rem; display: flex; gap: .5rem; flex-wrap: wrap; }
+input { flex: 1; padding: .5rem; border-radius: 6px; border: 1px solid #475569; background: #0f172a; color: inherit; }
+button { padding: .5rem 1rem; border: 0; border-radius: 6px; background: #38bdf8; color: #0f172a; font-weight: 600; cursor: pointer; }
+.error { color: #f87171; }
+.ok { color: #4ade80; }
+footer { margin-top: 1.5rem; font-size: .8rem; color: #94a3b8; }
+</style>
+</head>
+<body>
+<main>
+{{if .Allowed}}
+<h1>Domain allowed</h1>
+<p class="ok"><code>{{.Domain}}</code> is allowed for {{.Duration}}.</p>
+<p>It may take a few seconds before the site loads. <a href="/">Reload</a></p>
+{{else}}
+<h1>This domain was blocked by HydraDNS</h1>
+{{if .Domain}}<p><code>{{.Domain}}</code> is on a blocklist of this network's DNS server.</p>
+{{else}}<p>The requested site is on a blocklist of this network's DNS server.</p>{{end}}
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+{{if .CanAllow}}
+<form method="post" action="{{.AllowPath}}">
+<input type="hidden" name="domain" value="{{.Domain}}">
+{{if .NeedsKey}}<input type="password" name="token" placeholder="Allow token" autocomplete="current-password" required>{{end}}
+<button type="submit">Allow for {{.Duration}}</button>
+</form>
+{{end}}
+{{end}}
+<footer>HydraDNS</footer>
+</main>
+</body>
+</html>
+`))
@@ -0,0 +1,135 @@
+package blockpage_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/jroosing/hydradns/internal/blockpage"
+	"github.com/jroosing/hydradns/internal/config"
+	"github.com/jroosing/hydradns/internal/filtering"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newPolicy(t *testing.T) *filtering.PolicyEngine {
+	t.Helper()
+	pe := filtering.NewPolicyEngine(filtering.PolicyEngineConfig{
+		Enabled:          true,
+		BlockAction:      filtering.ActionBlock,
+		BlacklistDomains: []string{"ads.example.com"},
+	})
+	t.Cleanup(func() { _ = pe.Close() })
+	return pe
+}
+
+// allowToken accepts only the token "allow-token".
+func allowToken(_ context.Context, token string) bool {
+	return token == "allow-token"
+}
+
+func postAllow(t *testing.T, h http.Handler, domain, token string) *httptest.ResponseRecorder {
+	t.Helper()
+	return postAllowFrom(t, h, "192.0.2.1:1234", domain, token)
+}
+
+func postAllowFrom(t *testing.T, h http.Handler, client, domain, token string) *httptest.ResponseRecorder {
+	t.Helper()
+	form := url.Values{"domain": {domain}, "token": {token}}
+	req := httptest.NewRequest(http.MethodPost, blockpage.AllowPath, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Host = "ads.example.com"
+	req.RemoteAddr = client
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+// ============================================================================
+// Block Page Tests
+// ============================================================================
+
+func TestBlockPage_ShowsDomain(t *testing.T) {
+	s := blockpage.New(config.BlockPageConfig{}, allowToken, newPolicy(t), nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/some/path", nil)
+	req.Host = "ADS.example.com:80"
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	body := w.Body.String()
+	assert.Contains(t, body, "ads.example.com")
+	assert.Contains(t, body, blockpage.AllowPath)
+	assert.Contains(t, body, `name="token"`)
+	assert.NotContains(t, body, "api_key")
+}
+
+func TestBlockPage_IPHostHidesAllowButton(t *testing.T) {
+	s := blockpage.New(config.BlockPageConfig{}, nil, newPolicy(t), nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Host = "192.0.2.10"
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.NotContains(t, w.Body.String(), blockpage.AllowPath)
+}
+
+// ============================================================================
+// Allow Tests
+// ============================================================================
+
+func TestBlockPage_AllowRequiresToken(t *testing.T) {
+	pe := newPolicy(t)
+	h := blockpage.New(config.BlockPageConfig{}, allowToken, pe, nil).Handler()
+
+	w := postAllow(t, h, "ads.example.com", "wrong")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, filtering.ActionBlock, pe.Evaluate("ads.example.com").Action)
+
+	w = postAllow(t, h, "ads.example.com", "allow-token")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "is allowed for")
+	assert.Equal(t, filtering.ActionAllow, pe.Evaluate("ads.example.com").Action)
+}
+
+func TestBlockPage_AllowRateLimitedPerClient(t *testing.T) {
+	pe := newPolicy(t)
+	h := blockpage.New(config.BlockPageConfig{}, allowToken, pe, nil).Handler()
+
+	for range 5 {
+		w := postAllow(t, h, "ads.example.com", "guess")
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+	}
+	// Even the right token is refused once the client is over its limit
+	w := postAllow(t, h, "ads.example.com", "allow-token")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	assert.Equal(t, filtering.ActionBlock, pe.Evaluate("ads.example.com").Action)
+
+	// Other clients are limited separately
+	w = postAllowFrom(t, h, "192.0.2.2:1234", "ads.example.com", "allow-token")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestBlockPage_AllowWithoutAPIKey(t *testing.T) {
+	pe := newPolicy(t)
+	h := blockpage.New(config.BlockPageConfig{}, nil, pe, nil).Handler()
+
+	w := postAllow(t, h, "ads.example.com", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, filtering.ActionAllow, pe.Evaluate("ads.example.com").Action)
+}
+
+func TestBlockPage_AllowRejectsInvalidDomain(t *testing.T) {
+	h := blockpage.New(config.BlockPageConfig{}, nil, newPolicy(t), nil).Handler()
+
+	w := postAllow(t, h, "", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,326 @@
+// Package capture records raw DNS messages into pcap files, so traffic can
+// be inspected with Wireshark or tcpdump without installing a packet
+// capture tool on the DNS host.
+//
+// A capture is started with a limit and an optional filter, records the
+// messages exchanged with clients and with upstream servers as they are
+// sent and received, and stops once the limit is reached. The messages are
+// kept in memory until the next capture starts; WritePCAP writes them out
+// with synthetic IP and UDP or TCP headers carrying the real addresses and
+// ports.
+package capture
+
+import (
+	"errors"
+	"net/netip"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jroosing/hydradns/internal/dns"
+)
+
+// MaxPackets bounds the packets one capture records.
+const MaxPackets = 10000
+
+// maxBytes bounds the DNS message bytes one capture keeps in memory. The
+// capture stops when it is reached, whatever its limit.
+const maxBytes = 32 << 20
+
+// ErrInvalidLimit is returned by Start for a limit outside 1..MaxPackets.
+var ErrInvalidLimit = errors.New("capture: packet limit must be between 1 and 10000")
+
+// ErrRefused is returned by Start while the redaction refuses captures.
+var ErrRefused = errors.New("capture: packet captures are not allowed at the current privacy level")
+
+// Redaction is what a capture may keep of the clients it records, from
+// least to most private.
+type Redaction int
+
+const (
+	// RedactNone keeps client addresses.
+	RedactNone Redaction = iota
+	// RedactTruncate truncates client addresses to their /24 (IPv4) or
+	// /48 (IPv6) network.
+	RedactTruncate
+	// RedactClients replaces client addresses by the unspecified address.
+	RedactClients
+	// RedactRefuse allows no capture at all.
+	RedactRefuse
+)
+
+// Prefix lengths client addresses are truncated to by RedactTruncate.
+const (
+	truncateIPv4Bits = 24
+	truncateIPv6Bits = 48
+)
+
+// redactAddr returns addr as redaction allows it to be recorded.
+func redactAddr(addr netip.AddrPort, redaction Redaction) netip.AddrPort {
+	ip := addr.Addr().Unmap()
+	switch redaction {
+	case RedactTruncate:
+		bits := truncateIPv4Bits
+		if ip.Is6() {
+			bits = truncateIPv6Bits
+		}
+		prefix, _ := ip.Prefix(bits)
+		ip = prefix.Addr()
+	case RedactClients, RedactRefuse:
+		ip = netip.IPv4Unspecified()
+		if addr.Addr().Is6() && !addr.Addr().Is4In6() {
+			ip = netip.IPv6Unspecified()
+		}
+	default:
+		return addr
+	}
+	return netip.AddrPortFrom(ip, addr.Port())
+}
+
+// Side is the side of the server a message was exchanged on.
+type Side string
+
+const (
+	SideClient   Side = "client"   // Between a client and HydraDNS
+	SideUpstream Side = "upstream" // Between HydraDNS and an upstream server
+)
+
+// Filter selects the messages a capture records. The zero Filter records
+// every message.
+type Filter struct {
+	// Name records messages whose question is for the name or a subdomain.
+	Name string
+	// Client records messages exchanged with clients in the prefix, and
+	// upstream messages sent to answer them.
+	Client netip.Prefix
+}
+
+// matches reports whether a message about name for client passes f.
+func (f Filter) matches(name string, client netip.Addr) bool {
+	if f.Name != "" {
+		name = strings.ToLower(strings.TrimSuffix(name, "."))
+		if name != f.Name && !strings.HasSuffix(name, "."+f.Name) {
+			return false
+		}
+	}
+	if f.Client.IsValid() && (!client.IsValid() || !f.Client.Contains(client.Unmap())) {
+		return false
+	}
+	return true
+}
+
+// Packet is one recorded DNS message.
+type Packet struct {
+	Time      time.Time
+	Side      Side
+	Transport string // "udp" or "tcp"
+	Src       netip.AddrPort
+	Dst       netip.AddrPort
+	Data      []byte // DNS message, without the TCP length prefix
+}
+
+// Status describes the latest capture.
+type Status struct {
+	Active    bool
+	Filter    Filter
+	Limit     int
+	Captured  int
+	StartedAt time.Time // Zero before the first capture
+	StoppedAt time.Time // Zero while active
+}
+
+// Recorder records DNS messages while a capture runs. Record costs one
+// atomic load while no capture runs, so it can sit on the query path.
+//
+// A nil Recorder records nothing. Safe for concurrent use.
+type Recorder struct {
+	active    atomic.Bool // Fast path for Record
+	redaction func() Redaction
+
+	mu      sync.Mutex
+	status  Status
+	bytes   int
+	packets []Packet
+}
+
+// NewRecorder creates a Recorder with no capture running.
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// SetRedaction sets the source of the redaction applied to the client
+// addresses of recorded messages; by default they are kept. Must be
+// called before the first capture starts.
+func (r *Recorder) SetRedaction(fn func() Redaction) {
+	r.redaction = fn
+}
+
+// currentRedaction returns the redaction to apply now.
+func (r *Recorder) currentRedaction() Redaction {
+	if r.redaction == nil {
+		return RedactNone
+	}
+	return r.redaction()
+}
+
+// Start begins a capture of the next limit messages matching filter,
+// discarding the packets of the previous capture. A running capture is
+// replaced. It fails with ErrRefused while the redaction refuses captures.
+func (r *Recorder) Start(filter Filter, limit int) error {
+	if limit < 1 || limit > MaxPackets {
+		return ErrInvalidLimit
+	}
+	if r.currentRedaction() == RedactRefuse {
+		return ErrRefused
+	}
+	filter.Name = strings.ToLower(strings.TrimSuffix(filter.Name, "."))
+	if p := filter.Client; p.IsValid() && p.Addr().Is4In6() {
+		filter.Client = netip.PrefixFrom(p.Addr().Unmap(), max(p.Bits()-96, 0))
+	}
+	filter.Client = filter.Client.Masked()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = Status{Active: true, Filter: filter, Limit: limit, StartedAt: time.Now()}
+	r.bytes = 0
+	r.packets = nil
+	r.active.Store(true)
+	return nil
+}
+
+// Stop ends the running capture, keeping what it recorded.
+func (r *Recorder) Stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stopLocked()
+}
+
+// stopLocked ends the running capture. r.mu must be held.
+func (r *Recorder) stopLocked() {
+	if !r.status.Active {
+		return
+	}
+	r.active.Store(false)
+	r.status.Active = false
+	r.status.StoppedAt = time.Now()
+}
+
+// Capturing reports whether a capture is running.
+func (r *Recorder) Capturing() bool {
+	return r != nil && r.active.Load()
+}
+
+// Status returns the state of the latest capture.
+func (r *Recorder) Status() Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
+
+// Packets returns the packets of the latest capture, in the order they
+// were recorded.
+func (r *Recorder) Packets() []Packet {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]Packet, len(r.packets))
+	copy(out, r.packets)
+	return out
+}
+
+// RecordClient records msg, exchanged with client over transport ("udp"
+// or "tcp"), if a capture running wants it. msg is copied.
+func (r *Recorder) RecordClient(transport string, client netip.Addr, src, dst netip.AddrPort, msg []byte) {
+	r.record(SideClient, transport, client, src, dst, msg)
+}
+
+// RecordUpstream records msg, exchanged with an upstream server over
+// transport to answer client, if a capture running wants it. client is
+// invalid for queries the server makes itself. msg is copied.
+func (r *Recorder) RecordUpstream(transport string, client netip.Addr, src, dst netip.AddrPort, msg []byte) {
+	r.record(SideUpstream, transport, client, src, dst, msg)
+}
+
+// Redact applies the current redaction to the packets already recorded,
+// so raising the privacy level also anonymizes an earlier capture. When
+// captures are refused, the running one is stopped and its packets are
+// dropped.
+func (r *Recorder) Redact() {
+	redaction := r.currentRedaction()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if redaction == RedactRefuse {
+		r.stopLocked()
+		r.packets = nil
+		r.bytes = 0
+		r.status.Captured = 0
+		return
+	}
+	for i := range r.packets {
+		r.packets[i].redact(redaction)
+	}
+}
+
+// redact applies redaction to the client address of a client-side
+// packet: the source of a query, the destination of a response. Upstream
+// packets carry no client address.
+func (p *Packet) redact(redaction Redaction) {
+	if p.Side != SideClient || len(p.Data) < dns.HeaderSize {
+		return
+	}
+	if p.Data[2]&0x80 == 0 {
+		p.Src = redactAddr(p.Src, redaction)
+	} else {
+		p.Dst = redactAddr(p.Dst, redaction)
+	}
+}
+
+// record adds a message to the running capture if it passes the filter.
+func (r *Recorder) record(side Side, transport string, client netip.Addr, src, dst netip.AddrPort, msg []byte) {
+	if !r.Capturing() {
+		return
+	}
+	name := questionName(msg)
+	now := time.Now()
+	redaction := r.currentRedaction()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if redaction == RedactRefuse {
+		r.stopLocked()
+		return
+	}
+	if !r.status.Active || !r.status.Filter.matches(name, client) {
+		return
+	}
+	p := Packet{
+		Time:      now,
+		Side:      side,
+		Transport: transport,
+		Src:       src,
+		Dst:       dst,
+		Data:      append([]byte(nil), msg...),
+	}
+	p.redact(redaction)
+	r.packets = append(r.packets, p)
+	r.bytes += len(msg)
+	r.status.Captured = len(r.packets)
+	if r.status.Captured >= r.status.Limit || r.bytes >= maxBytes {
+		r.stopLocked()
+	}
+}
+
+// questionName returns the name of the first question of msg, or "" when
+// it has none.
+func questionName(msg []byte) string {
+	off := 0
+	h, err := dns.ParseHeader(msg, &off)
+	if err != nil || h.QDCount == 0 {
+		return ""
+	}
+	q, err := dns.ParseQuestion(msg, &off)
+	if err != nil {
+		return ""
+	}
+	return q.Name
+}
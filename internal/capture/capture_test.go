@@ -0,0 +1,273 @@
+package capture_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/netip"
+	"testing"
+
+	"github.com/jroosing/hydradns/internal/capture"
+	"github.com/jroosing/hydradns/internal/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// query returns a DNS query for name.
+func query(t *testing.T, name string) []byte {
+	t.Helper()
+	b, err := dns.Packet{
+		Header:    dns.Header{ID: 0x1234, Flags: dns.RDFlag},
+		Questions: []dns.Question{{Name: name, Type: uint16(dns.TypeA), Class: uint16(dns.ClassIN)}},
+	}.Marshal()
+	require.NoError(t, err)
+	return b
+}
+
+var (
+	client   = netip.MustParseAddrPort("192.0.2.10:40000")
+	listener = netip.MustParseAddrPort("192.0.2.1:53")
+	upstream = netip.MustParseAddrPort("198.51.100.53:53")
+)
+
+// ============================================================================
+// Recorder Tests
+// ============================================================================
+
+func TestRecorder_NilAndIdleRecordNothing(t *testing.T) {
+	var nilRec *capture.Recorder
+	assert.False(t, nilRec.Capturing())
+	nilRec.RecordClient("udp", client.Addr(), client, listener, query(t, "example.com"))
+
+	r := capture.NewRecorder()
+	r.RecordClient("udp", client.Addr(), client, listener, query(t, "example.com"))
+	assert.Empty(t, r.Packets())
+	assert.False(t, r.Status().Active)
+}
+
+func TestRecorder_StopsAtLimit(t *testing.T) {
+	r := capture.NewRecorder()
+	require.NoError(t, r.Start(capture.Filter{}, 2))
+	assert.True(t, r.Capturing())
+
+	msg := query(t, "example.com")
+	r.RecordClient("udp", client.Addr(), client, listener, msg)
+	r.RecordUpstream("udp", client.Addr(), netip.MustParseAddrPort("192.0.2.1:50000"), upstream, msg)
+	r.RecordClient("udp", client.Addr(), listener, client, msg)
+
+	status := r.Status()
+	assert.False(t, status.Active)
+	assert.Equal(t, 2, status.Captured)
+	assert.False(t, status.StoppedAt.IsZero())
+
+	packets := r.Packets()
+	require.Len(t, packets, 2)
+	assert.Equal(t, capture.SideClient, packets[0].Side)
+	assert.Equal(t, capture.SideUpstream, packets[1].Side)
+	assert.Equal(t, msg, packets[0].Data)
+
+	// Recorded messages are copies
+	msg[0] = 0xff
+	assert.NotEqual(t, msg[0], r.Packets()[0].Data[0])
+}
+
+func TestRecorder_Filter(t *testing.T) {
+	r := capture.NewRecorder()
+	require.NoError(t, r.Start(capture.Filter{
+		Name:   "Example.COM.",
+		Client: netip.MustParsePrefix("192.0.2.0/24"),
+	}, 10))
+
+	other := netip.MustParseAddr("203.0.113.5")
+	r.RecordClient("udp", client.Addr(), client, listener, query(t, "www.example.com"))
+	r.RecordClient("udp", client.Addr(), client, listener, query(t, "example.com"))
+	r.RecordClient("udp", client.Addr(), client, listener, query(t, "notexample.com"))
+	r.RecordClient("udp", other, netip.AddrPortFrom(other, 1), listener, query(t, "example.com"))
+	// Upstream queries the server makes itself have no client
+	r.RecordUpstream("udp", netip.Addr{}, listener, upstream, query(t, "example.com"))
+	// IPv4-mapped client addresses match IPv4 prefixes
+	mapped := netip.AddrFrom16(client.Addr().As16())
+	r.RecordUpstream("tcp", mapped, listener, upstream, query(t, "example.com"))
+
+	assert.Equal(t, 3, r.Status().Captured)
+	assert.True(t, r.Capturing())
+}
+
+func TestRecorder_StartReplacesCapture(t *testing.T) {
+	r := capture.NewRecorder()
+	assert.ErrorIs(t, r.Start(capture.Filter{}, 0), capture.ErrInvalidLimit)
+	assert.ErrorIs(t, r.Start(capture.Filter{}, capture.MaxPackets+1), capture.ErrInvalidLimit)
+
+	require.NoError(t, r.Start(capture.Filter{}, 5))
+	r.RecordClient("udp", client.Addr(), client, listener, query(t, "example.com"))
+	r.Stop()
+	assert.Len(t, r.Packets(), 1)
+	assert.False(t, r.Capturing())
+
+	require.NoError(t, r.Start(capture.Filter{}, 5))
+	assert.Empty(t, r.Packets())
+}
+
+func TestRecorder_RedactsClientAddresses(t *testing.T) {
+	redaction := capture.RedactTruncate
+	r := capture.NewRecorder()
+	r.SetRedaction(func() capture.Redaction { return redaction })
+	require.NoError(t, r.Start(capture.Filter{}, 10))
+
+	msg := query(t, "example.com")
+	resp := append([]byte(nil), msg...)
+	resp[2] |= 0x80
+	v6 := netip.MustParseAddrPort("[2001:db8:1:2::10]:40000")
+	r.RecordClient("udp", client.Addr(), client, listener, msg)
+	r.RecordClient("udp", client.Addr(), listener, client, resp)
+	r.RecordClient("udp", v6.Addr(), v6, listener, msg)
+	r.RecordUpstream("udp", client.Addr(), listener, upstream, msg)
+
+	packets := r.Packets()
+	require.Len(t, packets, 4)
+	assert.Equal(t, "192.0.2.0:40000", packets[0].Src.String())
+	assert.Equal(t, listener, packets[0].Dst)
+	assert.Equal(t, listener, packets[1].Src)
+	assert.Equal(t, "192.0.2.0:40000", packets[1].Dst.String())
+	assert.Equal(t, "[2001:db8:1::]:40000", packets[2].Src.String())
+	assert.Equal(t, listener, packets[3].Src, "upstream packets carry no client address")
+
+	// Raising the level redacts what was recorded before
+	redaction = capture.RedactClients
+	r.Redact()
+	packets = r.Packets()
+	assert.Equal(t, "0.0.0.0:40000", packets[0].Src.String())
+	assert.Equal(t, "0.0.0.0:40000", packets[1].Dst.String())
+	assert.Equal(t, "[::]:40000", packets[2].Src.String())
+}
+
+func TestRecorder_RefusedByRedaction(t *testing.T) {
+	redaction := capture.RedactNone
+	r := capture.NewRecorder()
+	r.SetRedaction(func() capture.Redaction { return redaction })
+	require.NoError(t, r.Start(capture.Filter{}, 10))
+	r.RecordClient("udp", client.Addr(), client, listener, query(t, "example.com"))
+	assert.Equal(t, client, r.Packets()[0].Src)
+
+	redaction = capture.RedactRefuse
+	r.Redact()
+	assert.False(t, r.Capturing())
+	assert.Empty(t, r.Packets())
+	assert.ErrorIs(t, r.Start(capture.Filter{}, 10), capture.ErrRefused)
+}
+
+// ============================================================================
+// PCAP Tests
+// ============================================================================
+
+// pcapRecord is one packet read back from a pcap file.
+type pcapRecord struct {
+	ip []byte
+}
+
+// readPCAP parses a little-endian pcap file with raw IP link type.
+func readPCAP(t *testing.T, b []byte) []pcapRecord {
+	t.Helper()
+	require.GreaterOrEqual(t, len(b), 24)
+	assert.Equal(t, uint32(0xa1b2c3d4), binary.LittleEndian.Uint32(b[0:]))
+	assert.Equal(t, uint16(2), binary.LittleEndian.Uint16(b[4:]))
+	assert.Equal(t, uint16(4), binary.LittleEndian.Uint16(b[6:]))
+	assert.Equal(t, uint32(101), binary.LittleEndian.Uint32(b[20:]))
+
+	var out []pcapRecord
+	for b = b[24:]; len(b) > 0; {
+		require.GreaterOrEqual(t, len(b), 16)
+		n := int(binary.LittleEndian.Uint32(b[8:]))
+		require.Equal(t, n, int(binary.LittleEndian.Uint32(b[12:])))
+		require.GreaterOrEqual(t, len(b), 16+n)
+		out = append(out, pcapRecord{ip: b[16 : 16+n]})
+		b = b[16+n:]
+	}
+	return out
+}
+
+// onesSum returns the one's complement sum of b's 16-bit words plus s.
+func onesSum(s uint32, b []byte) uint16 {
+	for ; len(b) >= 2; b = b[2:] {
+		s += uint32(binary.BigEndian.Uint16(b))
+	}
+	if len(b) == 1 {
+		s += uint32(b[0]) << 8
+	}
+	for s > 0xffff {
+		s = s>>16 + s&0xffff
+	}
+	return uint16(s)
+}
+
+func TestWritePCAP_UDPOverIPv4(t *testing.T) {
+	msg := query(t, "example.com")
+	var buf bytes.Buffer
+	require.NoError(t, capture.WritePCAP(&buf, []capture.Packet{
+		{Side: capture.SideClient, Transport: "udp", Src: client, Dst: listener, Data: msg},
+	}))
+
+	records := readPCAP(t, buf.Bytes())
+	require.Len(t, records, 1)
+	ip := records[0].ip
+	require.Len(t, ip, 20+8+len(msg))
+	assert.Equal(t, byte(0x45), ip[0])
+	assert.Equal(t, byte(17), ip[9])
+	assert.Equal(t, uint16(0xffff), onesSum(0, ip[:20]), "IPv4 header checksum")
+	assert.Equal(t, client.Addr().AsSlice(), ip[12:16])
+	assert.Equal(t, listener.Addr().AsSlice(), ip[16:20])
+
+	udp := ip[20:]
+	assert.Equal(t, client.Port(), binary.BigEndian.Uint16(udp[0:]))
+	assert.Equal(t, uint16(53), binary.BigEndian.Uint16(udp[2:]))
+	assert.Equal(t, msg, udp[8:])
+
+	pseudo := append(append([]byte{}, ip[12:20]...), 0, 17, 0, byte(len(udp)))
+	assert.Equal(t, uint16(0xffff), onesSum(uint32(onesSum(0, pseudo)), udp), "UDP checksum")
+}
+
+func TestWritePCAP_TCPSequenceNumbers(t *testing.T) {
+	msg := query(t, "example.com")
+	var buf bytes.Buffer
+	require.NoError(t, capture.WritePCAP(&buf, []capture.Packet{
+		{Transport: "tcp", Src: client, Dst: listener, Data: msg},
+		{Transport: "tcp", Src: listener, Dst: client, Data: msg},
+		{Transport: "tcp", Src: client, Dst: listener, Data: msg},
+	}))
+
+	records := readPCAP(t, buf.Bytes())
+	require.Len(t, records, 3)
+	seq := func(i int) (uint32, uint32) {
+		tcp := records[i].ip[20:]
+		return binary.BigEndian.Uint32(tcp[4:]), binary.BigEndian.Uint32(tcp[8:])
+	}
+	seq0, _ := seq(0)
+	seq1, ack1 := seq(1)
+	seq2, ack2 := seq(2)
+	assert.Equal(t, seq0+uint32(2+len(msg)), seq2, "next segment continues the flow")
+	assert.Equal(t, seq2, ack1, "reply acknowledges the query")
+	assert.Equal(t, seq1+uint32(2+len(msg)), ack2)
+
+	tcp := records[0].ip[20:]
+	assert.Equal(t, uint16(len(msg)), binary.BigEndian.Uint16(tcp[20:]), "length prefix")
+	assert.Equal(t, msg, tcp[22:])
+}
+
+func TestWritePCAP_MixedFamilies(t *testing.T) {
+	msg := query(t, "example.com")
+	var buf bytes.Buffer
+	require.NoError(t, capture.WritePCAP(&buf, []capture.Packet{
+		// IPv4 client on a dual-stack wildcard listener
+		{Transport: "udp", Src: client, Dst: netip.MustParseAddrPort("[::]:53"), Data: msg},
+		// IPv4 upstream reached from an IPv6 address
+		{Transport: "udp", Src: netip.MustParseAddrPort("[2001:db8::1]:5353"), Dst: upstream, Data: msg},
+	}))
+
+	records := readPCAP(t, buf.Bytes())
+	require.Len(t, records, 2)
+	assert.Equal(t, byte(0x45), records[0].ip[0])
+	assert.Equal(t, []byte{0, 0, 0, 0}, records[0].ip[16:20])
+
+	assert.Equal(t, byte(0x60), records[1].ip[0])
+	mapped := netip.AddrFrom16(upstream.Addr().As16()).AsSlice()
+	assert.Equal(t, mapped, records[1].ip[24:40])
+}
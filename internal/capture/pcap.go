@@ -0,0 +1,243 @@
+package capture
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net/netip"
+
+	"github.com/jroosing/hydradns/internal/helpers"
+)
+
+// pcap file format (https://www.tcpdump.org/manpages/pcap-savefile.5.txt),
+// little-endian, microsecond timestamps.
+const (
+	pcapMagic     = 0xa1b2c3d4
+	pcapSnapLen   = 262144
+	linkTypeRaw   = 101 // Packets start with the IPv4 or IPv6 header
+	pcapFileHdr   = 24
+	pcapRecordHdr = 16
+)
+
+// IP protocol numbers and header sizes of the synthetic packets.
+const (
+	protoTCP   = 6
+	protoUDP   = 17
+	ipv4Header = 20
+	ipv6Header = 40
+	udpHeader  = 8
+	tcpHeader  = 20
+	maxIPTotal = 65535
+)
+
+// tcpFlow identifies one direction of a TCP connection, for sequence
+// numbers.
+type tcpFlow struct {
+	src, dst netip.AddrPort
+}
+
+// WritePCAP writes the packets of the latest capture to w as a pcap file
+// (see WritePCAP).
+func (r *Recorder) WritePCAP(w io.Writer) error {
+	return WritePCAP(w, r.Packets())
+}
+
+// WritePCAP writes packets to w as a pcap file with raw IP link type. Each
+// DNS message becomes one IP packet: a UDP datagram, or a TCP segment
+// carrying the message with its length prefix. TCP segments have made-up
+// sequence numbers, continuous per connection, as the capture does not see
+// the handshake. Addresses of different families in one packet are mapped
+// to IPv6; a wildcard listener address becomes the unspecified address of
+// the other side's family. Messages too large for one IP packet are left
+// out.
+func WritePCAP(w io.Writer, packets []Packet) error {
+	bw := bufio.NewWriter(w)
+
+	var hdr [pcapFileHdr]byte
+	binary.LittleEndian.PutUint32(hdr[0:], pcapMagic)
+	binary.LittleEndian.PutUint16(hdr[4:], 2) // Version 2.4
+	binary.LittleEndian.PutUint16(hdr[6:], 4)
+	binary.LittleEndian.PutUint32(hdr[16:], pcapSnapLen)
+	binary.LittleEndian.PutUint32(hdr[20:], linkTypeRaw)
+	if _, err := bw.Write(hdr[:]); err != nil {
+		return err
+	}
+
+	seqs := make(map[tcpFlow]uint32)
+	for _, p := range packets {
+		ip := encodePacket(p, seqs)
+		if ip == nil {
+			continue
+		}
+		var rec [pcapRecordHdr]byte
+		binary.LittleEndian.PutUint32(rec[0:], helpers.ClampIntToUint32(int(p.Time.Unix())))
+		binary.LittleEndian.PutUint32(rec[4:], helpers.ClampIntToUint32(p.Time.Nanosecond()/1000))
+		binary.LittleEndian.PutUint32(rec[8:], helpers.ClampIntToUint32(len(ip)))
+		binary.LittleEndian.PutUint32(rec[12:], helpers.ClampIntToUint32(len(ip)))
+		if _, err := bw.Write(rec[:]); err != nil {
+			return err
+		}
+		if _, err := bw.Write(ip); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// encodePacket returns p as an IP packet, or nil when it does not fit in
+// one. seqs holds the next sequence number of each TCP flow.
+func encodePacket(p Packet, seqs map[tcpFlow]uint32) []byte {
+	src, dst := sameFamily(p.Src.Addr(), p.Dst.Addr())
+	ipHdr := ipv4Header
+	if src.Is6() {
+		ipHdr = ipv6Header
+	}
+
+	var transport []byte
+	var proto byte
+	if p.Transport == "tcp" {
+		proto = protoTCP
+		transport = make([]byte, tcpHeader+2+len(p.Data))
+		flow := tcpFlow{p.Src, p.Dst}
+		seq, ok := seqs[flow]
+		if !ok {
+			seq = 1
+		}
+		ack, ok := seqs[tcpFlow{p.Dst, p.Src}]
+		if !ok {
+			ack = 1
+		}
+		binary.BigEndian.PutUint16(transport[0:], p.Src.Port())
+		binary.BigEndian.PutUint16(transport[2:], p.Dst.Port())
+		binary.BigEndian.PutUint32(transport[4:], seq)
+		binary.BigEndian.PutUint32(transport[8:], ack)
+		transport[12] = (tcpHeader / 4) << 4
+		transport[13] = 0x18 // PSH, ACK
+		binary.BigEndian.PutUint16(transport[14:], 65535)
+		binary.BigEndian.PutUint16(transport[tcpHeader:], helpers.ClampIntToUint16(len(p.Data)))
+		copy(transport[tcpHeader+2:], p.Data)
+		seqs[flow] = seq + helpers.ClampIntToUint32(2+len(p.Data))
+	} else {
+		proto = protoUDP
+		transport = make([]byte, udpHeader+len(p.Data))
+		binary.BigEndian.PutUint16(transport[0:], p.Src.Port())
+		binary.BigEndian.PutUint16(transport[2:], p.Dst.Port())
+		binary.BigEndian.PutUint16(transport[4:], helpers.ClampIntToUint16(len(transport)))
+		copy(transport[udpHeader:], p.Data)
+	}
+	if ipHdr+len(transport) > maxIPTotal {
+		return nil
+	}
+
+	// Checksum field: TCP at 16, UDP at 6. A zero UDP checksum means none.
+	csumOff := 6
+	if proto == protoTCP {
+		csumOff = 16
+	}
+	csum := checksum(pseudoHeaderSum(src, dst, proto, len(transport)), transport)
+	if csum == 0 && proto == protoUDP {
+		csum = 0xffff
+	}
+	binary.BigEndian.PutUint16(transport[csumOff:], csum)
+
+	out := make([]byte, ipHdr, ipHdr+len(transport))
+	if src.Is4() {
+		out[0] = 0x45 // Version 4, 5 words
+		binary.BigEndian.PutUint16(out[2:], helpers.ClampIntToUint16(ipHdr+len(transport)))
+		binary.BigEndian.PutUint16(out[6:], 0x4000) // Don't fragment
+		out[8] = 64
+		out[9] = proto
+		s4, d4 := src.As4(), dst.As4()
+		copy(out[12:], s4[:])
+		copy(out[16:], d4[:])
+		binary.BigEndian.PutUint16(out[10:], checksum(0, out))
+	} else {
+		out[0] = 0x60 // Version 6
+		binary.BigEndian.PutUint16(out[4:], helpers.ClampIntToUint16(len(transport)))
+		out[6] = proto
+		out[7] = 64
+		s16, d16 := src.As16(), dst.As16()
+		copy(out[8:], s16[:])
+		copy(out[24:], d16[:])
+	}
+	return append(out, transport...)
+}
+
+// sameFamily returns src and dst in one address family: IPv4 when both
+// are, IPv6 otherwise. An unspecified address, such as that of a wildcard
+// listener, takes the other's family instead.
+func sameFamily(src, dst netip.Addr) (netip.Addr, netip.Addr) {
+	src, dst = src.Unmap(), dst.Unmap()
+	if !src.IsValid() {
+		src = netip.IPv4Unspecified()
+	}
+	if !dst.IsValid() {
+		dst = netip.IPv4Unspecified()
+	}
+	if src.Is4() == dst.Is4() {
+		return src, dst
+	}
+	v4 := false
+	switch {
+	case dst.IsUnspecified():
+		v4 = src.Is4()
+	case src.IsUnspecified():
+		v4 = dst.Is4()
+	}
+	return toFamily(src, v4), toFamily(dst, v4)
+}
+
+// toFamily returns a as an IPv4 address when v4 is set, IPv6 otherwise. A
+// must be unspecified or IPv4.
+func toFamily(a netip.Addr, v4 bool) netip.Addr {
+	switch {
+	case a.Is4() == v4:
+		return a
+	case !a.IsUnspecified():
+		return netip.AddrFrom16(a.As16())
+	case v4:
+		return netip.IPv4Unspecified()
+	default:
+		return netip.IPv6Unspecified()
+	}
+}
+
+// pseudoHeaderSum returns the sum of the pseudo-header covered by TCP and
+// UDP checksums (RFC 793, RFC 8200 section 8.1).
+func pseudoHeaderSum(src, dst netip.Addr, proto byte, length int) uint32 {
+	var b []byte
+	if src.Is4() {
+		s, d := src.As4(), dst.As4()
+		b = append(append(b, s[:]...), d[:]...)
+		b = append(b, 0, proto, byte(length>>8), byte(length))
+	} else {
+		s, d := src.As16(), dst.As16()
+		b = append(append(b, s[:]...), d[:]...)
+		b = binary.BigEndian.AppendUint32(b, helpers.ClampIntToUint32(length))
+		b = append(b, 0, 0, 0, proto)
+	}
+	return sum16(0, b)
+}
+
+// checksum returns the Internet checksum (RFC 1071) of b, starting from
+// the partial sum initial.
+func checksum(initial uint32, b []byte) uint16 {
+	s := sum16(initial, b)
+	for s > 0xffff {
+		s = s>>16 + s&0xffff
+	}
+	return ^uint16(s)
+}
+
+// sum16 adds the big-endian 16-bit words of b to s, padding an odd
+// length with a zero byte.
+func sum16(s uint32, b []byte) uint32 {
+	for len(b) >= 2 {
+		s += uint32(binary.BigEndian.Uint16(b))
+		b = b[2:]
+	}
+	if len(b) == 1 {
+		s += uint32(b[0]) << 8
+	}
+	return s
+}
@@ -0,0 +1,326 @@
+// Package check implements the `hydradns check` self-test.
+//
+// A check loads nothing itself: the caller exports the configuration from the
+// database and hands it to Run, which validates it, optionally fetches every
+// blocklist, and probes each upstream server. The resulting Report is meant
+// to be printed before (re)starting the service; any failed item means the
+// server would not start or would not work as configured.
+//
+// CompareUpstreams, behind `hydradns compare-upstreams` and the API, sends
+// one query to every upstream and reports where their answers differ.
+package check
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/jroosing/hydradns/internal/config"
+	"github.com/jroosing/hydradns/internal/dns"
+	"github.com/jroosing/hydradns/internal/filtering"
+	"github.com/jroosing/hydradns/internal/resolvers"
+)
+
+// DefaultTimeout bounds each network probe (upstream query, blocklist fetch).
+const DefaultTimeout = 5 * time.Second
+
+// Status is the outcome of a single check.
+type Status int
+
+const (
+	// StatusOK means the check passed.
+	StatusOK Status = iota
+	// StatusWarn means the check passed with a caveat or was skipped.
+	StatusWarn
+	// StatusFail means the check failed.
+	StatusFail
+)
+
+// String returns the label printed in reports.
+func (s Status) String() string {
+	switch s {
+	case StatusOK:
+		return "OK"
+	case StatusWarn:
+		return "WARN"
+	default:
+		return "FAIL"
+	}
+}
+
+// Result is the outcome of one named check.
+type Result struct {
+	Name   string
+	Status Status
+	Detail string
+}
+
+// Report collects the results of a self-test run, in execution order.
+type Report struct {
+	Results []Result
+}
+
+// Failed reports whether any check failed.
+func (r *Report) Failed() bool {
+	return r.Failures() > 0
+}
+
+// Failures returns the number of failed checks.
+func (r *Report) Failures() int {
+	n := 0
+	for _, res := range r.Results {
+		if res.Status == StatusFail {
+			n++
+		}
+	}
+	return n
+}
+
+// Add appends a result to the report.
+func (r *Report) Add(name string, status Status, format string, args ...any) {
+	r.Results = append(r.Results, Result{Name: name, Status: status, Detail: fmt.Sprintf(format, args...)})
+}
+
+// WriteTo prints the report, one line per check followed by a summary.
+func (r *Report) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+	for _, res := range r.Results {
+		n, err := fmt.Fprintf(w, "[%-4s] %s: %s\n", res.Status, res.Name, res.Detail)
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+	summary := "all checks passed"
+	if f := r.Failures(); f > 0 {
+		summary = fmt.Sprintf("%d of %d checks failed", f, len(r.Results))
+	}
+	n, err := fmt.Fprintln(w, summary)
+	return total + int64(n), err
+}
+
+// Options controls which checks Run performs.
+type Options struct {
+	// FetchBlocklists downloads and parses every configured blocklist.
+	FetchBlocklists bool
+	// SkipUpstreams disables the upstream reachability probes.
+	SkipUpstreams bool
+	// Timeout bounds each network probe (default: DefaultTimeout).
+	Timeout time.Duration
+}
+
+// Run checks cfg and returns the report. cfg is not modified.
+func Run(ctx context.Context, cfg *config.Config, opts Options) *Report {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	r := &Report{}
+
+	checkConfig(r, cfg)
+	checkCluster(r, cfg)
+	checkCustomDNS(r, cfg)
+	checkBlocklists(ctx, r, cfg, opts)
+	if opts.SkipUpstreams {
+		r.Add("upstreams", StatusWarn, "reachability not checked")
+	} else {
+		for _, up := range cfg.Upstream.Servers {
+			checkUpstream(ctx, r, up, opts.Timeout)
+		}
+	}
+	return r
+}
+
+// checkConfig runs the same validation as the server at startup and
+// reports each invalid setting on its own line.
+func checkConfig(r *Report, cfg *config.Config) {
+	c := *cfg
+	c.Cache.ZoneOverrides = append([]config.CacheZoneOverride(nil), cfg.Cache.ZoneOverrides...)
+	err := c.Validate()
+	if err == nil {
+		r.Add("config", StatusOK, "valid")
+		return
+	}
+	var invalid *config.ValidationError
+	if !errors.As(err, &invalid) {
+		r.Add("config", StatusFail, "%v", err)
+		return
+	}
+	for _, p := range invalid.Problems {
+		r.Add(p.Field, StatusFail, "%v", p.Err)
+	}
+	r.Add("config", StatusFail, "%d invalid settings", len(invalid.Problems))
+}
+
+// minSharedSecretLength is the shortest cluster shared secret not reported
+// as weak.
+const minSharedSecretLength = 16
+
+// checkCluster verifies the cluster mode and the settings it depends on.
+func checkCluster(r *Report, cfg *config.Config) {
+	switch cfg.Cluster.Mode {
+	case "", config.ClusterModeStandalone:
+		r.Add("cluster", StatusOK, "standalone")
+	case config.ClusterModePrimary:
+		switch {
+		case cfg.Cluster.SharedSecret == "":
+			r.Add("cluster", StatusWarn, "primary without a shared secret accepts unauthenticated sync requests")
+		case len(cfg.Cluster.SharedSecret) < minSharedSecretLength:
+			r.Add("cluster", StatusWarn, "shared_secret is shorter than %d characters and easy to guess", minSharedSecretLength)
+		default:
+			r.Add("cluster", StatusOK, "primary")
+		}
+	case config.ClusterModeSecondary:
+		u, err := url.Parse(cfg.Cluster.PrimaryURL)
+		switch {
+		case cfg.Cluster.PrimaryURL == "":
+			r.Add("cluster", StatusFail, "secondary mode requires primary_url")
+		case err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "":
+			r.Add("cluster", StatusFail, "invalid primary_url %q", cfg.Cluster.PrimaryURL)
+		case cfg.Cluster.SharedSecret == "":
+			r.Add("cluster", StatusFail, "secondary mode requires shared_secret")
+		case len(cfg.Cluster.SharedSecret) < minSharedSecretLength:
+			r.Add("cluster", StatusWarn, "shared_secret is shorter than %d characters and easy to guess", minSharedSecretLength)
+		default:
+			r.Add("cluster", StatusOK, "secondary of %s", cfg.Cluster.PrimaryURL)
+		}
+	default:
+		r.Add("cluster", StatusFail, "unknown mode %q", cfg.Cluster.Mode)
+	}
+}
+
+// checkCustomDNS builds the custom DNS resolver and resolves every alias to
+// catch CNAME loops and overlong chains before clients do.
+func checkCustomDNS(r *Report, cfg *config.Config) {
+	hosts, cnames := cfg.CustomDNS.Hosts, cfg.CustomDNS.CNAMEs
+	res, err := resolvers.NewCustomDNSResolver(hosts, cnames)
+	if err != nil {
+		r.Add("custom_dns", StatusFail, "%v", err)
+		return
+	}
+	res.SetMaxCNAMEChain(cfg.Server.MaxCNAMEChain)
+
+	broken := 0
+	for _, alias := range res.DuplicateAliases() {
+		r.Add("custom_dns "+alias, StatusWarn, "configured under several spellings with different targets")
+	}
+	for _, alias := range slices.Sorted(maps.Keys(cnames)) {
+		req := dns.Packet{
+			Header:    dns.Header{Flags: dns.RDFlag},
+			Questions: []dns.Question{{Name: alias, Type: uint16(dns.TypeA), Class: uint16(dns.ClassIN)}},
+		}
+		if _, err := res.Resolve(context.Background(), req, nil); errors.Is(err, resolvers.ErrCNAMEChain) {
+			r.Add("custom_dns "+alias, StatusFail, "%v", err)
+			broken++
+		}
+	}
+	if broken == 0 {
+		r.Add("custom_dns", StatusOK, "%d hosts, %d CNAMEs", len(hosts), len(cnames))
+	}
+}
+
+// checkBlocklists validates blocklist settings and optionally fetches them.
+func checkBlocklists(ctx context.Context, r *Report, cfg *config.Config, opts Options) {
+	for _, bl := range cfg.Filtering.Blocklists {
+		name := "blocklist " + bl.Name
+		format, ok := filtering.ParseListFormat(bl.Format)
+		if !ok {
+			r.Add(name, StatusFail, "unknown format %q", bl.Format)
+			continue
+		}
+		if filtering.IsBuiltinURL(bl.URL) {
+			r.Add(name, StatusOK, "%s (built in)", bl.URL)
+			continue
+		}
+		u, err := url.Parse(bl.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			r.Add(name, StatusFail, "invalid URL %q", bl.URL)
+			continue
+		}
+		if !opts.FetchBlocklists {
+			r.Add(name, StatusOK, "%s (not fetched)", bl.URL)
+			continue
+		}
+		if ctx.Err() != nil {
+			r.Add(name, StatusFail, "%v", ctx.Err())
+			continue
+		}
+
+		p := filtering.NewParser()
+		p.SetTimeout(int(opts.Timeout / time.Millisecond))
+		start := time.Now()
+		trie, err := p.ParseURLContext(ctx, bl.URL, format)
+		if err != nil {
+			r.Add(name, StatusFail, "%v", err)
+			continue
+		}
+		if trie.Size() == 0 {
+			r.Add(name, StatusWarn, "fetched in %s but contains no domains", time.Since(start).Round(time.Millisecond))
+			continue
+		}
+		r.Add(name, StatusOK, "%d domains in %s", trie.Size(), time.Since(start).Round(time.Millisecond))
+	}
+}
+
+// checkUpstream sends a root NS query to an upstream server over UDP and
+// expects a well-formed answer to the same question.
+func checkUpstream(ctx context.Context, r *Report, upstream string, timeout time.Duration) {
+	name := "upstream " + upstream
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", net.JoinHostPort(upstream, "53"))
+	if err != nil {
+		r.Add(name, StatusFail, "%v", err)
+		return
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	probeID := dns.NewTransactionID()
+	req := dns.Packet{
+		Header:    dns.Header{ID: probeID, Flags: dns.RDFlag},
+		Questions: []dns.Question{{Name: ".", Type: uint16(dns.TypeNS), Class: uint16(dns.ClassIN)}},
+	}
+	reqBytes, err := req.Marshal()
+	if err != nil {
+		r.Add(name, StatusFail, "%v", err)
+		return
+	}
+
+	start := time.Now()
+	if _, err := conn.Write(reqBytes); err != nil {
+		r.Add(name, StatusFail, "%v", err)
+		return
+	}
+	buf := make([]byte, dns.MaxIncomingDNSMessageSize)
+	n, err := conn.Read(buf)
+	if err != nil {
+		r.Add(name, StatusFail, "no response: %v", err)
+		return
+	}
+	rtt := time.Since(start).Round(time.Millisecond)
+
+	resp, err := dns.ParsePacket(buf[:n])
+	if err != nil {
+		r.Add(name, StatusFail, "malformed response: %v", err)
+		return
+	}
+	if resp.Header.ID != probeID || resp.Header.Flags&dns.QRFlag == 0 {
+		r.Add(name, StatusFail, "response does not match the query")
+		return
+	}
+	if rcode := dns.RCodeFromFlags(resp.Header.Flags); rcode != dns.RCodeNoError {
+		r.Add(name, StatusWarn, "answered with rcode %d in %s", rcode, rtt)
+		return
+	}
+	r.Add(name, StatusOK, "answered in %s", rtt)
+}
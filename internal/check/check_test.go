@@ -0,0 +1,384 @@
+package check_test
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jroosing/hydradns/internal/check"
+	"github.com/jroosing/hydradns/internal/config"
+	"github.com/jroosing/hydradns/internal/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newConfig() *config.Config {
+	return &config.Config{
+		Server: config.ServerConfig{
+			Host:       "0.0.0.0",
+			Port:       53,
+			WorkersRaw: "auto",
+			EnableTCP:  true,
+		},
+		Upstream: config.UpstreamConfig{
+			Servers:    []string{"9.9.9.9"},
+			UDPTimeout: "3s",
+			TCPTimeout: "5s",
+			MaxRetries: 3,
+		},
+		CustomDNS: config.CustomDNSConfig{
+			Hosts:  map[string][]string{"nas.home": {"192.168.1.10"}},
+			CNAMEs: map[string]string{"files.home": "nas.home"},
+		},
+		Logging:   config.LoggingConfig{Level: "INFO"},
+		Filtering: config.FilteringConfig{RefreshInterval: "24h"},
+		API:       config.APIConfig{Enabled: true, Host: "0.0.0.0", Port: 8080},
+	}
+}
+
+func statusOf(t *testing.T, r *check.Report, name string) check.Status {
+	t.Helper()
+	for _, res := range r.Results {
+		if res.Name == name {
+			return res.Status
+		}
+	}
+	t.Fatalf("no result named %q in %+v", name, r.Results)
+	return check.StatusFail
+}
+
+// =============================================================================
+// Configuration Tests
+// =============================================================================
+
+func TestRun_ValidConfigPasses(t *testing.T) {
+	r := check.Run(context.Background(), newConfig(), check.Options{SkipUpstreams: true})
+
+	assert.False(t, r.Failed(), "%+v", r.Results)
+	assert.Equal(t, check.StatusOK, statusOf(t, r, "config"))
+	assert.Equal(t, check.StatusOK, statusOf(t, r, "custom_dns"))
+	assert.Equal(t, check.StatusWarn, statusOf(t, r, "upstreams"))
+}
+
+func TestRun_DoesNotModifyConfig(t *testing.T) {
+	cfg := newConfig()
+	cfg.Logging.Level = "debug"
+
+	check.Run(context.Background(), cfg, check.Options{SkipUpstreams: true})
+
+	assert.Equal(t, "debug", cfg.Logging.Level)
+}
+
+func TestRun_InvalidConfigFails(t *testing.T) {
+	cfg := newConfig()
+	cfg.Server.Port = 70000
+
+	r := check.Run(context.Background(), cfg, check.Options{SkipUpstreams: true})
+
+	assert.True(t, r.Failed())
+	assert.Equal(t, check.StatusFail, statusOf(t, r, "config"))
+}
+
+func TestRun_InvalidDurationFails(t *testing.T) {
+	cfg := newConfig()
+	cfg.Filtering.RefreshInterval = "daily"
+
+	r := check.Run(context.Background(), cfg, check.Options{SkipUpstreams: true})
+
+	assert.Equal(t, check.StatusFail, statusOf(t, r, "filtering.refresh_interval"))
+}
+
+func TestRun_SecondaryWithoutPrimaryFails(t *testing.T) {
+	cfg := newConfig()
+	cfg.Cluster.Mode = config.ClusterModeSecondary
+	cfg.Cluster.SharedSecret = "secret"
+
+	r := check.Run(context.Background(), cfg, check.Options{SkipUpstreams: true})
+
+	assert.Equal(t, check.StatusFail, statusOf(t, r, "cluster"))
+}
+
+func TestRun_ReportsEachInvalidSetting(t *testing.T) {
+	cfg := newConfig()
+	cfg.Server.Port = 70000
+	cfg.Upstream.Servers = []string{"dns.quad9.net"}
+
+	r := check.Run(context.Background(), cfg, check.Options{SkipUpstreams: true})
+
+	assert.Equal(t, check.StatusFail, statusOf(t, r, "server.port"))
+	assert.Equal(t, check.StatusFail, statusOf(t, r, "upstream.servers[0]"))
+	assert.Equal(t, check.StatusFail, statusOf(t, r, "config"))
+}
+
+func TestRun_ShortSharedSecretWarns(t *testing.T) {
+	cfg := newConfig()
+	cfg.Cluster.Mode = config.ClusterModePrimary
+	cfg.Cluster.SharedSecret = "secret"
+
+	r := check.Run(context.Background(), cfg, check.Options{SkipUpstreams: true})
+
+	assert.Equal(t, check.StatusWarn, statusOf(t, r, "cluster"))
+}
+
+func TestRun_CNAMELoopFails(t *testing.T) {
+	cfg := newConfig()
+	cfg.CustomDNS.CNAMEs = map[string]string{"a.home": "b.home", "b.home": "a.home"}
+
+	r := check.Run(context.Background(), cfg, check.Options{SkipUpstreams: true})
+
+	assert.True(t, r.Failed())
+	assert.Equal(t, check.StatusFail, statusOf(t, r, "custom_dns a.home"))
+	assert.Equal(t, check.StatusFail, statusOf(t, r, "custom_dns b.home"))
+}
+
+// =============================================================================
+// Blocklist Tests
+// =============================================================================
+
+func TestRun_UnknownBlocklistFormatFails(t *testing.T) {
+	cfg := newConfig()
+	cfg.Filtering.Blocklists = []config.BlocklistConfig{{Name: "bad", URL: "https://example.com/list", Format: "csv"}}
+
+	r := check.Run(context.Background(), cfg, check.Options{SkipUpstreams: true})
+
+	assert.Equal(t, check.StatusFail, statusOf(t, r, "blocklist bad"))
+}
+
+func TestRun_InvalidBlocklistURLFails(t *testing.T) {
+	cfg := newConfig()
+	cfg.Filtering.Blocklists = []config.BlocklistConfig{{Name: "bad", URL: "ftp://example.com/list"}}
+
+	r := check.Run(context.Background(), cfg, check.Options{SkipUpstreams: true})
+
+	assert.Equal(t, check.StatusFail, statusOf(t, r, "blocklist bad"))
+}
+
+func TestRun_FetchesBlocklists(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("0.0.0.0 ads.example.com\n0.0.0.0 tracker.example.com\n"))
+	}))
+	defer srv.Close()
+
+	cfg := newConfig()
+	cfg.Filtering.Blocklists = []config.BlocklistConfig{
+		{Name: "good", URL: srv.URL + "/hosts", Format: "hosts"},
+		{Name: "missing", URL: srv.URL + "/missing", Format: "hosts"},
+	}
+
+	r := check.Run(context.Background(), cfg, check.Options{SkipUpstreams: true, FetchBlocklists: true})
+
+	assert.Equal(t, check.StatusOK, statusOf(t, r, "blocklist good"))
+	assert.Equal(t, check.StatusFail, statusOf(t, r, "blocklist missing"))
+	for _, res := range r.Results {
+		if res.Name == "blocklist good" {
+			assert.Contains(t, res.Detail, "2 domains")
+		}
+	}
+}
+
+// =============================================================================
+// Upstream Tests
+// =============================================================================
+
+const fakeUpstreamAddr = "127.0.0.153"
+
+// startFakeUpstream answers every query on fakeUpstreamAddr:53 with rcode.
+func startFakeUpstream(t *testing.T, rcode dns.RCode) {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP(fakeUpstreamAddr), Port: 53})
+	if err != nil {
+		t.Skipf("cannot bind fake upstream: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	t.Cleanup(func() {
+		_ = conn.Close()
+		wg.Wait()
+	})
+	wg.Go(func() {
+		buf := make([]byte, 4096)
+		for {
+			n, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			req, err := dns.ParsePacket(buf[:n])
+			if err != nil {
+				continue
+			}
+			// The root question parses back as "", which does not marshal;
+			// the probe only looks at the header.
+			resp := dns.Packet{Header: req.Header}
+			resp.Header.Flags |= dns.QRFlag | uint16(rcode)
+			b, err := resp.Marshal()
+			if err != nil {
+				continue
+			}
+			_, _ = conn.WriteToUDP(b, addr)
+		}
+	})
+}
+
+func TestRun_UpstreamReachable(t *testing.T) {
+	startFakeUpstream(t, dns.RCodeNoError)
+	cfg := newConfig()
+	cfg.Upstream.Servers = []string{fakeUpstreamAddr}
+
+	r := check.Run(context.Background(), cfg, check.Options{Timeout: time.Second})
+
+	assert.Equal(t, check.StatusOK, statusOf(t, r, "upstream "+fakeUpstreamAddr), "%+v", r.Results)
+}
+
+func TestRun_UpstreamErrorRcodeWarns(t *testing.T) {
+	startFakeUpstream(t, dns.RCodeRefused)
+	cfg := newConfig()
+	cfg.Upstream.Servers = []string{fakeUpstreamAddr}
+
+	r := check.Run(context.Background(), cfg, check.Options{Timeout: time.Second})
+
+	assert.Equal(t, check.StatusWarn, statusOf(t, r, "upstream "+fakeUpstreamAddr))
+	assert.False(t, r.Failed())
+}
+
+func TestRun_UpstreamUnreachableFails(t *testing.T) {
+	// Nothing listens here; the probe times out or is refused.
+	cfg := newConfig()
+	cfg.Upstream.Servers = []string{"127.0.0.154"}
+
+	r := check.Run(context.Background(), cfg, check.Options{Timeout: 200 * time.Millisecond})
+
+	assert.Equal(t, check.StatusFail, statusOf(t, r, "upstream 127.0.0.154"))
+}
+
+// =============================================================================
+// Upstream Comparison Tests
+// =============================================================================
+
+// startAnsweringUpstream answers A queries on addr:53 with ips, setting the
+// extra header flags.
+func startAnsweringUpstream(t *testing.T, addr string, flags uint16, ttl uint32, ips ...string) {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP(addr), Port: 53})
+	if err != nil {
+		t.Skipf("cannot bind fake upstream: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	t.Cleanup(func() {
+		_ = conn.Close()
+		wg.Wait()
+	})
+	wg.Go(func() {
+		buf := make([]byte, 4096)
+		for {
+			n, from, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			req, err := dns.ParsePacket(buf[:n])
+			if err != nil || len(req.Questions) != 1 {
+				continue
+			}
+			resp := dns.Packet{Header: req.Header, Questions: req.Questions}
+			resp.Header.Flags |= dns.QRFlag | flags
+			for _, ip := range ips {
+				h := dns.NewRRHeader(req.Questions[0].Name, dns.ClassIN, ttl)
+				resp.Answers = append(resp.Answers, dns.NewIPRecord(h, net.ParseIP(ip)))
+			}
+			b, err := resp.Marshal()
+			if err != nil {
+				continue
+			}
+			_, _ = conn.WriteToUDP(b, from)
+		}
+	})
+}
+
+func TestCompareUpstreams_FlagsDifferingAnswer(t *testing.T) {
+	startAnsweringUpstream(t, "127.0.0.153", 0, 300, "192.0.2.1", "192.0.2.2")
+	startAnsweringUpstream(t, "127.0.0.155", 0, 60, "192.0.2.2", "192.0.2.1")
+	startAnsweringUpstream(t, "127.0.0.156", 0, 300, "203.0.113.9")
+
+	c := check.CompareUpstreams(context.Background(),
+		[]string{"127.0.0.153", "127.0.0.155", "127.0.0.156"}, "example.com", dns.TypeA, time.Second)
+
+	require.Len(t, c.Upstreams, 3)
+	assert.False(t, c.Consistent())
+	for _, a := range c.Upstreams {
+		require.NoError(t, a.Err, a.Upstream)
+		assert.Equal(t, dns.RCodeNoError, a.RCode)
+		assert.Equal(t, check.DNSSECUnsigned, a.DNSSEC)
+		assert.Positive(t, a.RTT)
+	}
+	assert.False(t, c.Upstreams[0].Differs)
+	assert.False(t, c.Upstreams[1].Differs, "order and TTLs do not count")
+	assert.True(t, c.Upstreams[2].Differs)
+	assert.Equal(t, []string{
+		"example.com.\t300\tIN\tA\t192.0.2.1",
+		"example.com.\t300\tIN\tA\t192.0.2.2",
+	}, c.Upstreams[0].Answers)
+	assert.Equal(t, []string{"example.com.\t300\tIN\tA\t203.0.113.9"}, c.Upstreams[2].Answers)
+
+	var buf bytes.Buffer
+	_, err := c.WriteTo(&buf)
+	require.NoError(t, err)
+	out := buf.String()
+	assert.Contains(t, out, "example.com. 300 IN A 203.0.113.9  <- differs")
+	assert.Contains(t, out, "example.com A: 1 of 3 upstreams answered differently")
+}
+
+func TestCompareUpstreams_ReportsDNSSECAndFailures(t *testing.T) {
+	startAnsweringUpstream(t, "127.0.0.153", dns.ADFlag, 300, "192.0.2.1")
+	startAnsweringUpstream(t, "127.0.0.155", 0, 300, "192.0.2.1")
+
+	// Nothing listens on 127.0.0.154
+	c := check.CompareUpstreams(context.Background(),
+		[]string{"127.0.0.153", "127.0.0.154", "127.0.0.155"}, "example.com", dns.TypeA, 300*time.Millisecond)
+
+	require.Len(t, c.Upstreams, 3)
+	assert.Equal(t, check.DNSSECValidated, c.Upstreams[0].DNSSEC)
+	assert.Equal(t, check.DNSSECUnsigned, c.Upstreams[2].DNSSEC)
+	assert.Error(t, c.Upstreams[1].Err)
+	assert.False(t, c.Upstreams[1].Differs, "failures are reported, not compared")
+	assert.True(t, c.Consistent())
+}
+
+// =============================================================================
+// Report Tests
+// =============================================================================
+
+func TestReport_WriteTo(t *testing.T) {
+	r := &check.Report{}
+	r.Add("config", check.StatusOK, "valid")
+	r.Add("upstream 9.9.9.9", check.StatusFail, "no response: %s", "timeout")
+
+	var buf bytes.Buffer
+	n, err := r.WriteTo(&buf)
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(buf.Len()), n)
+	assert.Equal(t, "[OK  ] config: valid\n[FAIL] upstream 9.9.9.9: no response: timeout\n1 of 2 checks failed\n", buf.String())
+	assert.True(t, r.Failed())
+	assert.Equal(t, 1, r.Failures())
+}
+
+func TestReport_WriteToAllPassed(t *testing.T) {
+	r := &check.Report{}
+	r.Add("config", check.StatusWarn, "skipped")
+
+	var buf bytes.Buffer
+	_, err := r.WriteTo(&buf)
+	require.NoError(t, err)
+
+	assert.Contains(t, buf.String(), "all checks passed")
+	assert.False(t, r.Failed())
+}
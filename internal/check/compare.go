@@ -0,0 +1,289 @@
+package check
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jroosing/hydradns/internal/dns"
+	"github.com/jroosing/hydradns/internal/helpers"
+)
+
+// compareUDPSize is the EDNS UDP payload size advertised by comparison
+// queries; larger answers are retried over TCP.
+const compareUDPSize = 1232
+
+// errMismatch is returned for an upstream answer to a different query.
+var errMismatch = errors.New("response does not match the query")
+
+// DNSSECStatus is what an upstream's answer says about DNSSEC.
+type DNSSECStatus string
+
+const (
+	// DNSSECValidated means the upstream set the AD flag: it validated the
+	// answer.
+	DNSSECValidated DNSSECStatus = "validated"
+	// DNSSECSigned means the answer carries RRSIGs the upstream did not
+	// validate.
+	DNSSECSigned DNSSECStatus = "signed"
+	// DNSSECUnsigned means the answer carries no DNSSEC data.
+	DNSSECUnsigned DNSSECStatus = "unsigned"
+)
+
+// UpstreamAnswer is how one upstream answered a compared query.
+type UpstreamAnswer struct {
+	Upstream string
+	Err      error // Set when the upstream did not answer; other fields are zero
+	RTT      time.Duration
+	RCode    dns.RCode
+	// Answers are the answer records other than RRSIGs as zone-file lines,
+	// sorted so differently ordered answers compare equal
+	Answers       []string
+	DNSSEC        DNSSECStatus
+	ExtendedError *dns.ExtendedError // Extended DNS Error (RFC 8914) returned, if any
+	// Differs is set when the response code or records (TTLs aside) differ
+	// from those most upstreams returned
+	Differs bool
+}
+
+// Comparison is the answers of every upstream to one query.
+type Comparison struct {
+	Name      string
+	Type      dns.RecordType
+	Upstreams []UpstreamAnswer // In the order given
+}
+
+// Consistent reports whether every upstream that answered returned the
+// same response code and records.
+func (c Comparison) Consistent() bool {
+	return !slices.ContainsFunc(c.Upstreams, func(a UpstreamAnswer) bool { return a.Differs })
+}
+
+// WriteTo prints the comparison as a table, one upstream per row and one
+// answer record per line, followed by a summary.
+func (c Comparison) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	tw := tabwriter.NewWriter(cw, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "UPSTREAM\tRTT\tRCODE\tDNSSEC\tANSWER")
+	differing := 0
+	for _, a := range c.Upstreams {
+		if a.Err != nil {
+			fmt.Fprintf(tw, "%s\t-\t-\t-\terror: %v\n", a.Upstream, a.Err)
+			continue
+		}
+		lines := make([]string, 0, len(a.Answers)+1)
+		for _, rr := range a.Answers {
+			lines = append(lines, strings.ReplaceAll(rr, "\t", " "))
+		}
+		if a.ExtendedError != nil {
+			lines = append(lines, fmt.Sprintf("EDE %d: %s", a.ExtendedError.InfoCode, a.ExtendedError.ExtraText))
+		}
+		if len(lines) == 0 {
+			lines = append(lines, "(no records)")
+		}
+		if a.Differs {
+			differing++
+			lines[0] += "  <- differs"
+		}
+		rtt := a.RTT.Round(time.Millisecond).String()
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", a.Upstream, rtt, a.RCode, a.DNSSEC, lines[0])
+		for _, l := range lines[1:] {
+			fmt.Fprintf(tw, "\t\t\t\t%s\n", l)
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return cw.n, err
+	}
+
+	summary := fmt.Sprintf("%s %s: all upstreams agree", c.Name, c.Type)
+	if differing > 0 {
+		summary = fmt.Sprintf("%s %s: %d of %d upstreams answered differently", c.Name, c.Type, differing, len(c.Upstreams))
+	}
+	_, err := fmt.Fprintln(cw, summary)
+	return cw.n, err
+}
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// CompareUpstreams sends the query name/qtype to every upstream in
+// parallel, with the DNSSEC OK bit set, and returns their answers side by
+// side, flagging those that differ from the majority. Upstreams are
+// addresses queried on port 53, over UDP and again over TCP when the
+// answer is truncated; each is given timeout.
+func CompareUpstreams(
+	ctx context.Context,
+	upstreams []string,
+	name string,
+	qtype dns.RecordType,
+	timeout time.Duration,
+) Comparison {
+	c := Comparison{Name: name, Type: qtype, Upstreams: make([]UpstreamAnswer, len(upstreams))}
+	var wg sync.WaitGroup
+	for i, up := range upstreams {
+		wg.Go(func() {
+			c.Upstreams[i] = queryForComparison(ctx, up, name, qtype, timeout)
+		})
+	}
+	wg.Wait()
+	markDifferences(c.Upstreams)
+	return c
+}
+
+// queryForComparison sends the query to one upstream and summarizes its
+// answer.
+func queryForComparison(
+	ctx context.Context,
+	upstream, name string,
+	qtype dns.RecordType,
+	timeout time.Duration,
+) UpstreamAnswer {
+	out := UpstreamAnswer{Upstream: upstream}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	opt := dns.CreateOPT(compareUDPSize)
+	opt.DNSSECOk = true
+	req := dns.Packet{
+		Header:      dns.Header{ID: dns.NewTransactionID(), Flags: dns.RDFlag},
+		Questions:   []dns.Question{{Name: name, Type: uint16(qtype), Class: uint16(dns.ClassIN)}},
+		Additionals: []dns.Record{opt.Record()},
+	}
+	reqBytes, err := req.Marshal()
+	if err != nil {
+		out.Err = err
+		return out
+	}
+
+	start := time.Now()
+	respBytes, err := exchange(ctx, "udp", upstream, reqBytes)
+	if err == nil && dns.IsTruncated(respBytes) {
+		respBytes, err = exchange(ctx, "tcp", upstream, reqBytes)
+	}
+	out.RTT = time.Since(start)
+	if err != nil {
+		out.Err = err
+		return out
+	}
+	resp, err := dns.ParsePacket(respBytes)
+	if err != nil {
+		out.Err = err
+		return out
+	}
+	if resp.Header.ID != req.Header.ID || resp.Header.Flags&dns.QRFlag == 0 {
+		out.Err = errMismatch
+		return out
+	}
+
+	out.RCode = dns.RCodeFromFlags(resp.Header.Flags)
+	out.DNSSEC = DNSSECUnsigned
+	for _, r := range resp.Answers {
+		if r.Type() == dns.TypeRRSIG {
+			out.DNSSEC = DNSSECSigned
+			continue
+		}
+		out.Answers = append(out.Answers, dns.FormatRecord(r))
+	}
+	slices.SortFunc(out.Answers, func(a, b string) int { return strings.Compare(withoutTTL(a), withoutTTL(b)) })
+	if resp.Header.Flags&dns.ADFlag != 0 {
+		out.DNSSEC = DNSSECValidated
+	}
+	if ede, ok := dns.ExtractExtendedError(resp.Additionals); ok {
+		out.ExtendedError = &ede
+	}
+	return out
+}
+
+// exchange sends req to upstream port 53 over network ("udp" or "tcp")
+// and returns the response.
+func exchange(ctx context.Context, network, upstream string, req []byte) ([]byte, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, net.JoinHostPort(upstream, "53"))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	if network == "udp" {
+		if _, err := conn.Write(req); err != nil {
+			return nil, err
+		}
+		buf := make([]byte, dns.MaxIncomingDNSMessageSize)
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+
+	msg := binary.BigEndian.AppendUint16(nil, helpers.ClampIntToUint16(len(req)))
+	if _, err := conn.Write(append(msg, req...)); err != nil {
+		return nil, err
+	}
+	var prefix [2]byte
+	if _, err := io.ReadFull(conn, prefix[:]); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, binary.BigEndian.Uint16(prefix[:]))
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// markDifferences sets Differs on the answers whose response code and
+// records differ from the most common ones. Ties go to the answer of the
+// earliest upstream, the preferred one in failover order.
+func markDifferences(answers []UpstreamAnswer) {
+	keys := make([]string, len(answers))
+	counts := make(map[string]int)
+	for i, a := range answers {
+		if a.Err != nil {
+			continue
+		}
+		var b strings.Builder
+		b.WriteString(a.RCode.String())
+		for _, rr := range a.Answers {
+			b.WriteByte('\n')
+			b.WriteString(withoutTTL(rr))
+		}
+		keys[i] = b.String()
+		counts[keys[i]]++
+	}
+	majority, best := "", 0
+	for i, a := range answers {
+		if a.Err == nil && counts[keys[i]] > best {
+			majority, best = keys[i], counts[keys[i]]
+		}
+	}
+	for i, a := range answers {
+		answers[i].Differs = a.Err == nil && keys[i] != majority
+	}
+}
+
+// withoutTTL returns a FormatRecord line without its TTL field.
+func withoutTTL(line string) string {
+	name, rest, _ := strings.Cut(line, "\t")
+	_, rest, _ = strings.Cut(rest, "\t")
+	return strings.ToLower(name) + "\t" + rest
+}
@@ -0,0 +1,29 @@
+package clock_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jroosing/hydradns/internal/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSystem_FollowsWallClock(t *testing.T) {
+	before := time.Now()
+	now := clock.System.Now()
+	assert.False(t, now.Before(before))
+	assert.False(t, now.After(time.Now()))
+}
+
+func TestManual_MovesOnlyWhenTold(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := clock.NewManual(start)
+	assert.Equal(t, start, c.Now())
+	assert.Equal(t, start, c.Now(), "does not move by itself")
+
+	c.Advance(90 * time.Second)
+	assert.Equal(t, start.Add(90*time.Second), c.Now())
+
+	c.Set(start)
+	assert.Equal(t, start, c.Now())
+}
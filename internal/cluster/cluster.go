@@ -12,16 +12,17 @@ package cluster
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"log/slog"
+	"maps"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/jroosing/hydradns/internal/config"
+	"github.com/jroosing/hydradns/pkg/apiclient"
 )
 
 // ExportData represents the configuration data exchanged during sync.
@@ -44,8 +45,26 @@ type ExportData struct {
 
 	// Filtering contains domain filtering configuration.
 	Filtering config.FilteringConfig `json:"filtering"`
+
+	// Sections holds the hash of each section above, keyed by section name
+	// (see SectionHashes).
+	Sections map[string]string `json:"sections,omitempty"`
+
+	// Journal and Seq are the primary's journal position this export
+	// corresponds to; later syncs fetch the changes after it (see Journal).
+	Journal string `json:"journal,omitempty"`
+	Seq     int64  `json:"seq,omitempty"`
+
+	// Members lists the cluster members known to the primary (see Members).
+	Members []Member `json:"members,omitempty"`
 }
 
+// Kinds of syncs (see SyncStatus.LastSyncKind).
+const (
+	SyncKindFull    = "full"
+	SyncKindChanges = "changes"
+)
+
 // SyncStatus represents the current synchronization status.
 type SyncStatus struct {
 	// Mode is the cluster mode (standalone, primary, secondary).
@@ -63,6 +82,10 @@ type SyncStatus struct {
 	// LastSyncVersion is the config version from the last successful sync.
 	LastSyncVersion int64 `json:"last_sync_version,omitempty"`
 
+	// LastSyncKind is SyncKindFull when the last successful sync fetched
+	// the full export, SyncKindChanges when it fetched changes only.
+	LastSyncKind string `json:"last_sync_kind,omitempty"`
+
 	// LastSyncError is the error message from the last sync attempt (if any).
 	LastSyncError string `json:"last_sync_error,omitempty"`
 
@@ -77,6 +100,10 @@ type SyncStatus struct {
 
 	// ConfigVersion is the current local config version.
 	ConfigVersion int64 `json:"config_version"`
+
+	// PrimarySections holds the primary's section hashes as of the last
+	// successful fetch.
+	PrimarySections map[string]string `json:"primary_sections,omitempty"`
 }
 
 // ImportFunc is a callback function that imports configuration from ExportData.
@@ -90,6 +117,14 @@ type ReloadFunc func() error
 // VersionFunc is a callback function that returns the current config version.
 type VersionFunc func() (int64, error)
 
+// ApplyChangesFunc is a callback function that applies the changes of an
+// incremental sync to the local database and returns any error.
+type ApplyChangesFunc func(changes *Changes) error
+
+// SectionsFunc is a callback function that returns the section hashes applied
+// by previous imports, keyed by section name.
+type SectionsFunc func() (map[string]string, error)
+
 // Syncer handles configuration synchronization for secondary nodes.
 type Syncer struct {
 	cfg         *config.ClusterConfig
@@ -97,16 +132,23 @@ type Syncer struct {
 	importFunc  ImportFunc
 	reloadFunc  ReloadFunc
 	versionFunc VersionFunc
-	httpClient  *http.Client
-
-	mu              sync.RWMutex
-	running         bool
-	lastSyncTime    *time.Time
-	lastSyncVersion int64
-	lastSyncError   string
-	nextSyncTime    *time.Time
-	syncCount       int64
-	errorCount      int64
+	client      *apiclient.Client
+
+	mu               sync.RWMutex
+	sectionsFunc     SectionsFunc
+	applyChangesFunc ApplyChangesFunc
+	members          *Members
+	primarySections  map[string]string
+	journal          string // Primary journal position of the applied config
+	seq              int64
+	running          bool
+	lastSyncTime     *time.Time
+	lastSyncVersion  int64
+	lastSyncKind     string
+	lastSyncError    string
+	nextSyncTime     *time.Time
+	syncCount        int64
+	errorCount       int64
 
 	stopCh chan struct{}
 	doneCh chan struct{}
@@ -128,25 +170,91 @@ func NewSyncer(
 		return nil, errors.New("primary_url is required for secondary mode")
 	}
 
-	syncTimeout, err := time.ParseDuration(cfg.SyncTimeout)
-	if err != nil {
-		syncTimeout = 30 * time.Second
-	}
-
 	return &Syncer{
 		cfg:         cfg,
 		logger:      logger,
 		importFunc:  importFunc,
 		reloadFunc:  reloadFunc,
 		versionFunc: versionFunc,
-		httpClient: &http.Client{
-			Timeout: syncTimeout,
-		},
-		stopCh: make(chan struct{}),
-		doneCh: make(chan struct{}),
+		client:      newPrimaryClient(cfg),
+		stopCh:      make(chan struct{}),
+		doneCh:      make(chan struct{}),
 	}, nil
 }
 
+// newPrimaryClient returns an API client for the primary in cfg.
+func newPrimaryClient(cfg *config.ClusterConfig) *apiclient.Client {
+	syncTimeout, err := time.ParseDuration(cfg.SyncTimeout)
+	if err != nil {
+		syncTimeout = 30 * time.Second
+	}
+
+	client := apiclient.New(cfg.PrimaryURL)
+	client.HTTPClient.Timeout = syncTimeout
+	client.ClusterSecret = cfg.SharedSecret
+	client.NodeID = cfg.NodeID
+	return client
+}
+
+// CheckPrimary asks the primary in cfg for its export. It returns nil when
+// the primary answers, i.e. it is up and still acting as primary.
+func CheckPrimary(ctx context.Context, cfg *config.ClusterConfig) error {
+	var data ExportData
+	return newPrimaryClient(cfg).ClusterExport(ctx, &data)
+}
+
+// SetSectionsFunc sets the callback reporting the section hashes applied on
+// this node. When set, a sync also imports if the primary's section hashes
+// differ from the applied ones, even if the local version is not older; local
+// edits bump the local version and would otherwise hide primary changes.
+func (s *Syncer) SetSectionsFunc(fn SectionsFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sectionsFunc = fn
+}
+
+// SetApplyChangesFunc sets the callback applying incremental changes. When
+// set, syncs after the first fetch only the changes made on the primary
+// since the previous sync, and fall back to the full export when the
+// primary's journal no longer has them.
+func (s *Syncer) SetApplyChangesFunc(fn ApplyChangesFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.applyChangesFunc = fn
+}
+
+// SetMembers sets the member list kept up to date from the primary. Syncs
+// then describe this node to the primary, which publishes it along with
+// the other members. Call it before Start.
+func (s *Syncer) SetMembers(m *Members) {
+	self := m.Self()
+	header := make(http.Header)
+	if self.Address != "" {
+		header.Set("X-Node-Address", self.Address)
+	}
+	if self.DNSPort != 0 {
+		header.Set("X-Node-Dns-Port", strconv.Itoa(self.DNSPort))
+	}
+	if self.APIPort != 0 {
+		header.Set("X-Node-Api-Port", strconv.Itoa(self.APIPort))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.members = m
+	s.client.Header = header
+}
+
+// updateMembers takes over the member list received from the primary.
+func (s *Syncer) updateMembers(members []Member) {
+	s.mu.RLock()
+	m := s.members
+	s.mu.RUnlock()
+	if m != nil && members != nil {
+		m.Replace(members)
+	}
+}
+
 // Start begins the periodic synchronization process.
 func (s *Syncer) Start(ctx context.Context) error {
 	s.mu.Lock()
@@ -206,11 +314,13 @@ func (s *Syncer) Status() SyncStatus {
 		PrimaryURL:      s.cfg.PrimaryURL,
 		LastSyncTime:    s.lastSyncTime,
 		LastSyncVersion: s.lastSyncVersion,
+		LastSyncKind:    s.lastSyncKind,
 		LastSyncError:   s.lastSyncError,
 		NextSyncTime:    s.nextSyncTime,
 		SyncCount:       s.syncCount,
 		ErrorCount:      s.errorCount,
 		ConfigVersion:   localVersion,
+		PrimarySections: s.primarySections,
 	}
 }
 
@@ -248,6 +358,10 @@ func (s *Syncer) runLoop(ctx context.Context, interval time.Duration) {
 func (s *Syncer) doSync(ctx context.Context) error {
 	s.logger.DebugContext(ctx, "starting config sync", "primary", s.cfg.PrimaryURL)
 
+	if done, err := s.syncChanges(ctx); done {
+		return err
+	}
+
 	// Fetch config from primary
 	data, err := s.fetchConfig(ctx)
 	if err != nil {
@@ -255,14 +369,21 @@ func (s *Syncer) doSync(ctx context.Context) error {
 		return fmt.Errorf("fetch config: %w", err)
 	}
 
+	primarySections := data.SectionHashes()
+	s.mu.Lock()
+	s.primarySections = primarySections
+	s.mu.Unlock()
+	s.updateMembers(data.Members)
+
 	// Check if we already have this version
 	currentVersion, _ := s.versionFunc()
-	if data.Version <= currentVersion {
+	if data.Version <= currentVersion && !s.sectionsChanged(ctx, primarySections) {
 		s.logger.DebugContext(ctx, "config already up to date",
 			"local_version", currentVersion,
 			"remote_version", data.Version,
 		)
-		s.recordSuccess(data.Version)
+		s.setJournal(data.Journal, data.Seq)
+		s.recordSuccess(data.Version, SyncKindFull)
 		return nil
 	}
 
@@ -286,56 +407,121 @@ func (s *Syncer) doSync(ctx context.Context) error {
 		}
 	}
 
-	s.recordSuccess(data.Version)
+	s.setJournal(data.Journal, data.Seq)
+	s.recordSuccess(data.Version, SyncKindFull)
 	s.logger.InfoContext(ctx, "config sync completed", "version", data.Version)
 
 	return nil
 }
 
-func (s *Syncer) fetchConfig(ctx context.Context) (*ExportData, error) {
-	url := s.cfg.PrimaryURL + "/api/v1/cluster/export"
+// syncChanges fetches and applies the changes made on the primary since the
+// last sync. It reports false when a full sync is needed instead: on the
+// first sync, when the primary's journal no longer has the changes (it was
+// truncated or the primary restarted), when the primary does not support
+// incremental sync, or when the changes cannot be applied.
+func (s *Syncer) syncChanges(ctx context.Context) (bool, error) {
+	s.mu.RLock()
+	apply, journal, seq := s.applyChangesFunc, s.journal, s.seq
+	s.mu.RUnlock()
+	if apply == nil || journal == "" {
+		return false, nil
+	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("create request: %w", err)
+	var changes Changes
+	if err := s.client.ClusterChanges(ctx, journal, seq, &changes); err != nil {
+		if apiclient.IsStatus(err, http.StatusGone) || apiclient.IsStatus(err, http.StatusNotFound) {
+			s.logger.InfoContext(ctx, "changes unavailable, fetching full config", "err", err)
+			return false, nil
+		}
+		s.recordError(err)
+		return true, fmt.Errorf("fetch changes: %w", err)
+	}
+
+	s.mu.Lock()
+	s.primarySections = changes.Sections
+	s.mu.Unlock()
+	s.updateMembers(changes.Members)
+
+	if len(changes.Changes) == 0 {
+		if s.sectionsChanged(ctx, changes.Sections) {
+			// The applied sections drifted from the primary without a
+			// journaled change, e.g. after a failed import
+			return false, nil
+		}
+		s.logger.DebugContext(ctx, "config already up to date", "seq", changes.Seq)
+		s.setJournal(changes.Journal, changes.Seq)
+		s.recordSuccess(changes.Version, SyncKindChanges)
+		return true, nil
 	}
 
-	// Add shared secret for authentication
-	if s.cfg.SharedSecret != "" {
-		req.Header.Set("X-Cluster-Secret", s.cfg.SharedSecret)
+	s.logger.InfoContext(ctx, "applying changes from primary",
+		"changes", len(changes.Changes),
+		"since", changes.Since,
+		"seq", changes.Seq,
+		"remote_version", changes.Version,
+	)
+
+	if err := apply(&changes); err != nil {
+		s.logger.WarnContext(ctx, "applying changes failed, fetching full config", "err", err)
+		s.setJournal("", 0)
+		return false, nil
 	}
 
-	req.Header.Set("Accept", "application/json")
-	if s.cfg.NodeID != "" {
-		req.Header.Set("X-Node-Id", s.cfg.NodeID)
+	if s.reloadFunc != nil {
+		if err := s.reloadFunc(); err != nil {
+			s.logger.WarnContext(ctx, "reload after sync failed", "err", err)
+		}
 	}
 
-	resp, err := s.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("http request: %w", err)
+	s.setJournal(changes.Journal, changes.Seq)
+	s.recordSuccess(changes.Version, SyncKindChanges)
+	s.logger.InfoContext(ctx, "config sync completed", "version", changes.Version, "seq", changes.Seq)
+	return true, nil
+}
+
+// setJournal records the primary journal position of the applied config.
+func (s *Syncer) setJournal(journal string, seq int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.journal = journal
+	s.seq = seq
+}
+
+// sectionsChanged reports whether any of the primary's section hashes differ
+// from the hashes applied on this node. Without a SectionsFunc only the
+// version is compared.
+func (s *Syncer) sectionsChanged(ctx context.Context, primary map[string]string) bool {
+	s.mu.RLock()
+	fn := s.sectionsFunc
+	s.mu.RUnlock()
+	if fn == nil {
+		return false
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
-		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
+	applied, err := fn()
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to read applied section hashes", "err", err)
+		return true
 	}
+	return !maps.Equal(primary, applied)
+}
 
+func (s *Syncer) fetchConfig(ctx context.Context) (*ExportData, error) {
 	var data ExportData
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return nil, fmt.Errorf("decode response: %w", err)
+	if err := s.client.ClusterExport(ctx, &data); err != nil {
+		return nil, err
 	}
-
 	return &data, nil
 }
 
-func (s *Syncer) recordSuccess(version int64) {
+func (s *Syncer) recordSuccess(version int64, kind string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	now := time.Now()
 	s.lastSyncTime = &now
 	s.lastSyncVersion = version
+	s.lastSyncKind = kind
 	s.lastSyncError = ""
 	s.syncCount++
 }
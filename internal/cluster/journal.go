@@ -0,0 +1,266 @@
+package cluster
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"maps"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jroosing/hydradns/internal/config"
+)
+
+// Kinds of journal changes (see Change.Kind).
+const (
+	// ChangeSettings replaces a section's settings: the whole upstream
+	// section, or the filtering section without its domain lists and
+	// temporary allows.
+	ChangeSettings  = "settings"
+	ChangeHost      = "host"
+	ChangeCNAME     = "cname"
+	ChangeWhitelist = "whitelist"
+	ChangeBlacklist = "blacklist"
+	// ChangeTemporaryAllow adds, extends or removes a temporary allow.
+	ChangeTemporaryAllow = "temporary_allow"
+)
+
+// DefaultJournalSize is the number of changes a Journal keeps by default.
+const DefaultJournalSize = 10000
+
+// Change is one change to a synced section. Host records, CNAMEs and list
+// domains change one by one, so a large record set or blacklist does not
+// have to be sent again when a single entry changes; the remaining settings
+// of a section are replaced as a whole.
+type Change struct {
+	// Seq orders changes within a journal.
+	Seq     int64  `json:"seq"`
+	Section string `json:"section"`
+	Kind    string `json:"kind"`
+	// Key is the host name, CNAME alias or list or allowed domain; empty
+	// for settings.
+	Key string `json:"key,omitempty"`
+	// Deleted is set when the host, CNAME or domain was removed.
+	Deleted bool `json:"deleted,omitempty"`
+
+	IPs       []string                `json:"ips,omitempty"`        // Host addresses
+	Target    string                  `json:"target,omitempty"`     // CNAME target
+	ExpiresAt *time.Time              `json:"expires_at,omitempty"` // Temporary allow expiry
+	Upstream  *config.UpstreamConfig  `json:"upstream,omitempty"`   // Upstream settings
+	Filtering *config.FilteringConfig `json:"filtering,omitempty"`  // Filtering settings, without domain lists
+}
+
+// Changes is the payload of an incremental sync: the changes made on the
+// primary after Since, up to Seq.
+type Changes struct {
+	// Journal identifies the primary's journal; sequence numbers of other
+	// journals (e.g. from before a primary restart) do not apply.
+	Journal string `json:"journal"`
+	Since   int64  `json:"since"`
+	Seq     int64  `json:"seq"`
+
+	// Version, Timestamp and NodeID are as in ExportData.
+	Version   int64     `json:"version"`
+	Timestamp time.Time `json:"timestamp"`
+	NodeID    string    `json:"node_id"`
+
+	// Sections holds the primary's section hashes after the changes.
+	Sections map[string]string `json:"sections"`
+
+	// Changes are in order; only the last change of each entry is kept.
+	Changes []Change `json:"changes"`
+
+	// Members lists the cluster members known to the primary (see Members).
+	Members []Member `json:"members,omitempty"`
+}
+
+// Journal records the changes to the synced sections on the primary, so
+// secondaries can fetch what changed since their last sync instead of the
+// full export. Changes are found by comparing each observed export with the
+// previous one. The journal is kept in memory: after a restart secondaries
+// fall back to a full export once.
+//
+// Thread-safe for concurrent use.
+type Journal struct {
+	mu      sync.Mutex
+	id      string
+	size    int
+	last    *ExportData // Last observed export; nil before the first
+	seq     int64       // Sequence number of the last change
+	floor   int64       // Sequence number of the last dropped change
+	changes []Change
+}
+
+// NewJournal creates a journal keeping up to size changes (DefaultJournalSize
+// if size <= 0). Once full, the oldest changes are dropped and secondaries
+// that have not seen them get a full export.
+func NewJournal(size int) *Journal {
+	if size <= 0 {
+		size = DefaultJournalSize
+	}
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return &Journal{id: hex.EncodeToString(b), size: size}
+}
+
+// Observe records the changes from the previously observed export to data
+// and returns the journal ID and sequence number data corresponds to.
+func (j *Journal) Observe(data *ExportData) (string, int64) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	snapshot := cloneSections(data)
+	if j.last != nil {
+		for _, c := range diffExports(j.last, snapshot) {
+			j.seq++
+			c.Seq = j.seq
+			j.changes = append(j.changes, c)
+		}
+		if over := len(j.changes) - j.size; over > 0 {
+			j.floor = j.changes[over-1].Seq
+			j.changes = slices.Delete(j.changes, 0, over)
+		}
+	}
+	j.last = snapshot
+	return j.id, j.seq
+}
+
+// Since returns the changes after seq in journal id, keeping only the last
+// change of each entry. It reports false when id is not this journal's or
+// changes after seq were dropped; the caller then needs a full export.
+func (j *Journal) Since(id string, seq int64) ([]Change, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if id != j.id || seq < j.floor || seq > j.seq {
+		return nil, false
+	}
+
+	type entry struct{ section, kind, key string }
+	latest := make(map[entry]int)
+	out := []Change{}
+	for _, c := range j.changes {
+		if c.Seq <= seq {
+			continue
+		}
+		e := entry{c.Section, c.Kind, c.Key}
+		if i, ok := latest[e]; ok {
+			out[i].Seq = 0 // Superseded
+		}
+		latest[e] = len(out)
+		out = append(out, c)
+	}
+	return slices.DeleteFunc(out, func(c Change) bool { return c.Seq == 0 }), true
+}
+
+// diffExports returns the changes from old to cur, both normalized.
+func diffExports(old, cur *ExportData) []Change {
+	var changes []Change
+
+	if !reflect.DeepEqual(old.Upstream, cur.Upstream) {
+		u := cur.Upstream
+		changes = append(changes, Change{Section: SectionUpstream, Kind: ChangeSettings, Upstream: &u})
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(mergeKeys(old.CustomDNS.Hosts, cur.CustomDNS.Hosts))) {
+		before, after := old.CustomDNS.Hosts[name], cur.CustomDNS.Hosts[name]
+		switch {
+		case len(after) == 0 && len(before) > 0:
+			changes = append(changes, Change{Section: SectionCustomDNS, Kind: ChangeHost, Key: name, Deleted: true})
+		case len(after) > 0 && !slices.Equal(before, after):
+			changes = append(changes, Change{Section: SectionCustomDNS, Kind: ChangeHost, Key: name, IPs: after})
+		}
+	}
+	for _, alias := range slices.Sorted(maps.Keys(mergeKeys(old.CustomDNS.CNAMEs, cur.CustomDNS.CNAMEs))) {
+		before, hadBefore := old.CustomDNS.CNAMEs[alias]
+		after, hasAfter := cur.CustomDNS.CNAMEs[alias]
+		switch {
+		case !hasAfter:
+			changes = append(changes, Change{Section: SectionCustomDNS, Kind: ChangeCNAME, Key: alias, Deleted: true})
+		case !hadBefore || before != after:
+			changes = append(changes, Change{Section: SectionCustomDNS, Kind: ChangeCNAME, Key: alias, Target: after})
+		}
+	}
+
+	oldSettings, curSettings := filteringSettings(old.Filtering), filteringSettings(cur.Filtering)
+	if !reflect.DeepEqual(oldSettings, curSettings) {
+		changes = append(changes, Change{Section: SectionFiltering, Kind: ChangeSettings, Filtering: &curSettings})
+	}
+	changes = append(changes, diffDomains(ChangeWhitelist, old.Filtering.WhitelistDomains, cur.Filtering.WhitelistDomains)...)
+	changes = append(changes, diffDomains(ChangeBlacklist, old.Filtering.BlacklistDomains, cur.Filtering.BlacklistDomains)...)
+
+	oldAllows, curAllows := old.Filtering.TemporaryAllows, cur.Filtering.TemporaryAllows
+	for _, domain := range slices.Sorted(maps.Keys(mergeKeys(oldAllows, curAllows))) {
+		before, hadBefore := oldAllows[domain]
+		after, hasAfter := curAllows[domain]
+		switch {
+		case !hasAfter:
+			changes = append(changes, Change{Section: SectionFiltering, Kind: ChangeTemporaryAllow, Key: domain, Deleted: true})
+		case !hadBefore || !before.Equal(after):
+			changes = append(changes, Change{Section: SectionFiltering, Kind: ChangeTemporaryAllow, Key: domain, ExpiresAt: &after})
+		}
+	}
+
+	return changes
+}
+
+// diffDomains returns the changes from the sorted domain list old to cur.
+func diffDomains(kind string, old, cur []string) []Change {
+	var changes []Change
+	for _, d := range old {
+		if _, ok := slices.BinarySearch(cur, d); !ok {
+			changes = append(changes, Change{Section: SectionFiltering, Kind: kind, Key: d, Deleted: true})
+		}
+	}
+	for _, d := range cur {
+		if _, ok := slices.BinarySearch(old, d); !ok {
+			changes = append(changes, Change{Section: SectionFiltering, Kind: kind, Key: d})
+		}
+	}
+	return changes
+}
+
+// filteringSettings returns f without its domain lists and temporary
+// allows.
+func filteringSettings(f config.FilteringConfig) config.FilteringConfig {
+	f.WhitelistDomains = nil
+	f.BlacklistDomains = nil
+	f.TemporaryAllows = nil
+	return f
+}
+
+// cloneSections returns a deep copy of the synced sections of data,
+// normalized as for SectionHashes, so later changes to the configuration
+// data was built from do not change the copy.
+func cloneSections(data *ExportData) *ExportData {
+	// Marshaling plain structs, maps and slices cannot fail
+	b, _ := json.Marshal(ExportData{Upstream: data.Upstream, CustomDNS: data.CustomDNS, Filtering: data.Filtering})
+	var c ExportData
+	_ = json.Unmarshal(b, &c)
+
+	hosts := make(map[string][]string, len(c.CustomDNS.Hosts))
+	for name, ips := range c.CustomDNS.Hosts {
+		if len(ips) > 0 {
+			hosts[name] = sortedUnique(ips)
+		}
+	}
+	return &ExportData{
+		Upstream:  normalizeUpstream(c.Upstream),
+		CustomDNS: config.CustomDNSConfig{Hosts: hosts, CNAMEs: c.CustomDNS.CNAMEs},
+		Filtering: normalizeFiltering(c.Filtering),
+	}
+}
+
+// mergeKeys returns the union of the keys of a and b.
+func mergeKeys[V any](a, b map[string]V) map[string]struct{} {
+	keys := make(map[string]struct{}, len(a)+len(b))
+	for k := range a {
+		keys[k] = struct{}{}
+	}
+	for k := range b {
+		keys[k] = struct{}{}
+	}
+	return keys
+}
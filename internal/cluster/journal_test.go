@@ -0,0 +1,207 @@
+package cluster_test
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jroosing/hydradns/internal/cluster"
+	"github.com/jroosing/hydradns/internal/config"
+)
+
+func journalExport() *cluster.ExportData {
+	return &cluster.ExportData{
+		Upstream: config.UpstreamConfig{Servers: []string{"1.1.1.1"}},
+		CustomDNS: config.CustomDNSConfig{
+			Hosts:  map[string][]string{"nas.home": {"10.0.0.1"}},
+			CNAMEs: map[string]string{"www.home": "nas.home"},
+		},
+		Filtering: config.FilteringConfig{BlacklistDomains: []string{"ads.example"}},
+	}
+}
+
+func TestJournal_RecordsChangesPerEntry(t *testing.T) {
+	j := cluster.NewJournal(0)
+	data := journalExport()
+	id, start := j.Observe(data)
+
+	data.CustomDNS.Hosts["printer.home"] = []string{"10.0.0.50"}
+	delete(data.CustomDNS.CNAMEs, "www.home")
+	data.Filtering.BlacklistDomains = append(data.Filtering.BlacklistDomains, "tracker.example")
+	data.Filtering.Enabled = true
+	if _, seq := j.Observe(data); seq != start+4 {
+		t.Fatalf("expected 4 changes, got seq %d", seq)
+	}
+
+	changes, ok := j.Since(id, start)
+	if !ok {
+		t.Fatal("expected changes to be available")
+	}
+	got := make(map[string]cluster.Change)
+	for _, c := range changes {
+		got[c.Kind+" "+c.Key] = c
+	}
+	if c := got["host printer.home"]; len(c.IPs) != 1 || c.IPs[0] != "10.0.0.50" {
+		t.Errorf("unexpected host change: %+v", c)
+	}
+	if c := got["cname www.home"]; !c.Deleted {
+		t.Errorf("expected CNAME deletion, got %+v", c)
+	}
+	if _, ok := got["blacklist tracker.example"]; !ok {
+		t.Error("expected blacklist addition")
+	}
+	if c := got["settings "]; c.Filtering == nil || !c.Filtering.Enabled || len(c.Filtering.BlacklistDomains) != 0 {
+		t.Errorf("expected filtering settings without lists, got %+v", c)
+	}
+	if _, ok := got["blacklist ads.example"]; ok {
+		t.Error("unchanged domain must not be journaled")
+	}
+}
+
+func TestJournal_RecordsTemporaryAllows(t *testing.T) {
+	j := cluster.NewJournal(0)
+	data := journalExport()
+	id, start := j.Observe(data)
+
+	expiry := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	data.Filtering.TemporaryAllows = map[string]time.Time{"a.example": expiry}
+	_, added := j.Observe(data)
+	changes, _ := j.Since(id, start)
+	if len(changes) != 1 || changes[0].Kind != cluster.ChangeTemporaryAllow ||
+		changes[0].ExpiresAt == nil || !changes[0].ExpiresAt.Equal(expiry) {
+		t.Fatalf("expected only the allow with its expiry, got %+v", changes)
+	}
+
+	data.Filtering.TemporaryAllows = nil
+	j.Observe(data)
+	changes, _ = j.Since(id, added)
+	if len(changes) != 1 || changes[0].Key != "a.example" || !changes[0].Deleted {
+		t.Fatalf("expected the allow to be removed, got %+v", changes)
+	}
+}
+
+func TestJournal_KeepsLastChangePerEntry(t *testing.T) {
+	j := cluster.NewJournal(0)
+	data := journalExport()
+	id, start := j.Observe(data)
+
+	data.CustomDNS.Hosts["nas.home"] = []string{"10.0.0.2"}
+	j.Observe(data)
+	data.CustomDNS.Hosts["nas.home"] = []string{"10.0.0.3"}
+	j.Observe(data)
+
+	changes, _ := j.Since(id, start)
+	if len(changes) != 1 || changes[0].IPs[0] != "10.0.0.3" {
+		t.Fatalf("expected only the last change, got %+v", changes)
+	}
+}
+
+func TestJournal_UnavailableChanges(t *testing.T) {
+	j := cluster.NewJournal(2)
+	data := journalExport()
+	id, start := j.Observe(data)
+
+	if _, ok := j.Since("other", start); ok {
+		t.Error("another journal's position must not be served")
+	}
+
+	data.Filtering.BlacklistDomains = []string{"a.example", "b.example", "c.example"}
+	_, seq := j.Observe(data)
+	if _, ok := j.Since(id, start); ok {
+		t.Error("truncated changes must not be served")
+	}
+	if changes, ok := j.Since(id, seq); !ok || len(changes) != 0 {
+		t.Errorf("expected no changes at the current position, got %v %v", changes, ok)
+	}
+}
+
+func TestSyncer_FetchesChangesAfterFullSync(t *testing.T) {
+	var exports, changeFetches atomic.Int32
+	var gone atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/cluster/export":
+			exports.Add(1)
+			json.NewEncoder(w).Encode(cluster.ExportData{Version: 5, Journal: "j1", Seq: 3})
+		case "/api/v1/cluster/changes":
+			changeFetches.Add(1)
+			if gone.Load() {
+				w.WriteHeader(http.StatusGone)
+				return
+			}
+			if r.URL.Query().Get("journal") != "j1" || r.URL.Query().Get("since") != "3" {
+				t.Errorf("unexpected journal position: %s", r.URL.RawQuery)
+			}
+			json.NewEncoder(w).Encode(cluster.Changes{
+				Journal: "j1",
+				Since:   3,
+				Seq:     4,
+				Version: 6,
+				Changes: []cluster.Change{{Seq: 4, Section: cluster.SectionFiltering, Kind: cluster.ChangeBlacklist, Key: "ads.example"}},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	cfg := &config.ClusterConfig{
+		Mode:         config.ClusterModeSecondary,
+		PrimaryURL:   server.URL,
+		SyncInterval: "1h",
+		SyncTimeout:  "5s",
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	var version atomic.Int64
+	importFunc := func(data *cluster.ExportData) error {
+		version.Store(data.Version)
+		return nil
+	}
+	versionFunc := func() (int64, error) { return version.Load(), nil }
+
+	syncer, err := cluster.NewSyncer(cfg, logger, importFunc, nil, versionFunc)
+	if err != nil {
+		t.Fatalf("NewSyncer failed: %v", err)
+	}
+	var applied []cluster.Change
+	syncer.SetApplyChangesFunc(func(changes *cluster.Changes) error {
+		applied = append(applied, changes.Changes...)
+		version.Store(changes.Version)
+		return nil
+	})
+
+	ctx := context.Background()
+	for range 2 {
+		if err := syncer.ForceSync(ctx); err != nil {
+			t.Fatalf("ForceSync failed: %v", err)
+		}
+	}
+	if exports.Load() != 1 || changeFetches.Load() != 1 {
+		t.Fatalf("expected one full export and one changes fetch, got %d and %d", exports.Load(), changeFetches.Load())
+	}
+	if len(applied) != 1 || applied[0].Key != "ads.example" {
+		t.Fatalf("unexpected applied changes: %+v", applied)
+	}
+	if st := syncer.Status(); st.LastSyncKind != cluster.SyncKindChanges || st.LastSyncVersion != 6 {
+		t.Errorf("unexpected status: kind %q, version %d", st.LastSyncKind, st.LastSyncVersion)
+	}
+
+	// Once the primary no longer has the changes, the full export is used
+	gone.Store(true)
+	if err := syncer.ForceSync(ctx); err != nil {
+		t.Fatalf("ForceSync failed: %v", err)
+	}
+	if exports.Load() != 2 {
+		t.Fatalf("expected a full export after 410 Gone, got %d exports", exports.Load())
+	}
+	if st := syncer.Status(); st.LastSyncKind != cluster.SyncKindFull {
+		t.Errorf("expected a full sync, got %q", st.LastSyncKind)
+	}
+}
@@ -0,0 +1,156 @@
+package cluster
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jroosing/hydradns/internal/config"
+)
+
+// Member is one node of the cluster, as published by cluster discovery.
+type Member struct {
+	NodeID string             `json:"node_id"`
+	Role   config.ClusterMode `json:"role"`
+	// Address is the node's IP address; empty when not known.
+	Address string `json:"address,omitempty"`
+	// DNSPort and APIPort are the node's DNS and management API ports;
+	// 0 when not known.
+	DNSPort int `json:"dns_port,omitempty"`
+	APIPort int `json:"api_port,omitempty"`
+	// LastSeen is when the primary last heard from the node.
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// Members tracks the members of the cluster. The primary records each
+// secondary when it syncs and sends the list along with every export;
+// secondaries take the list over from the primary. Every change to the
+// published members (not just to LastSeen) bumps a generation number, so
+// users such as the discovery resolver know when to rebuild.
+//
+// Thread-safe for concurrent use.
+type Members struct {
+	mu         sync.Mutex
+	self       Member
+	peers      map[string]Member
+	timeout    time.Duration
+	generation uint64
+}
+
+// NewMembers creates a member list holding this node, self. Other members
+// are dropped once they have not been seen for timeout; a timeout <= 0
+// keeps them until they are replaced.
+func NewMembers(self Member, timeout time.Duration) *Members {
+	return &Members{self: self, peers: make(map[string]Member), timeout: timeout, generation: 1}
+}
+
+// Self returns this node.
+func (m *Members) Self() Member {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.self
+}
+
+// SetRole changes this node's role, e.g. after a promotion. Other members
+// with the primary role are dropped when this node becomes the primary.
+func (m *Members) SetRole(role config.ClusterMode) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.self.Role == role {
+		return
+	}
+	m.self.Role = role
+	if role == config.ClusterModePrimary {
+		maps.DeleteFunc(m.peers, func(_ string, p Member) bool { return p.Role == config.ClusterModePrimary })
+	}
+	m.generation++
+}
+
+// Seen records that member synced just now. Used on the primary.
+func (m *Members) Seen(member Member) {
+	if member.NodeID == "" {
+		return
+	}
+	member.LastSeen = time.Now().UTC()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if member.NodeID == m.self.NodeID {
+		return
+	}
+	if old, ok := m.peers[member.NodeID]; !ok || !sameMember(old, member) {
+		m.generation++
+	}
+	m.peers[member.NodeID] = member
+}
+
+// Replace replaces the other members by members, as received from the
+// primary. Used on secondaries. The entry for this node itself only fills
+// in its address when none is configured, so it carries the address the
+// primary sees it at.
+func (m *Members) Replace(members []Member) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	peers := make(map[string]Member, len(members))
+	for _, member := range members {
+		switch member.NodeID {
+		case "":
+		case m.self.NodeID:
+			if m.self.Address == "" && member.Address != "" {
+				m.self.Address = member.Address
+				m.generation++
+			}
+		default:
+			peers[member.NodeID] = member
+		}
+	}
+
+	changed := len(peers) != len(m.peers)
+	for id, p := range peers {
+		if old, ok := m.peers[id]; !ok || !sameMember(old, p) {
+			changed = true
+		}
+	}
+	if changed {
+		m.generation++
+	}
+	m.peers = peers
+}
+
+// List returns the members, this node first and the others by node ID, and
+// the generation they belong to. Members not seen within the timeout are
+// dropped first.
+func (m *Members) List() ([]Member, uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().UTC()
+	if m.timeout > 0 {
+		n := len(m.peers)
+		maps.DeleteFunc(m.peers, func(_ string, p Member) bool { return now.Sub(p.LastSeen) > m.timeout })
+		if len(m.peers) != n {
+			m.generation++
+		}
+	}
+
+	self := m.self
+	self.LastSeen = now
+	out := make([]Member, 0, len(m.peers)+1)
+	out = append(out, self)
+	for _, p := range m.peers {
+		out = append(out, p)
+	}
+	slices.SortFunc(out[1:], func(a, b Member) int { return strings.Compare(a.NodeID, b.NodeID) })
+	return out, m.generation
+}
+
+// sameMember reports whether a and b publish the same records.
+func sameMember(a, b Member) bool {
+	a.LastSeen, b.LastSeen = time.Time{}, time.Time{}
+	return a == b
+}
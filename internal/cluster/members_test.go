@@ -0,0 +1,111 @@
+package cluster_test
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jroosing/hydradns/internal/cluster"
+	"github.com/jroosing/hydradns/internal/config"
+)
+
+func TestMembers_TracksSyncingNodes(t *testing.T) {
+	m := cluster.NewMembers(cluster.Member{NodeID: "primary", Role: config.ClusterModePrimary}, time.Minute)
+	_, start := m.List()
+
+	m.Seen(cluster.Member{NodeID: "b", Role: config.ClusterModeSecondary, Address: "10.0.0.2"})
+	m.Seen(cluster.Member{NodeID: "a", Role: config.ClusterModeSecondary, Address: "10.0.0.1"})
+	list, gen := m.List()
+	if len(list) != 3 || list[0].NodeID != "primary" || list[1].NodeID != "a" || list[2].NodeID != "b" {
+		t.Fatalf("unexpected members: %+v", list)
+	}
+	if gen == start {
+		t.Error("new members must change the generation")
+	}
+
+	// Syncing again only updates LastSeen
+	m.Seen(cluster.Member{NodeID: "a", Role: config.ClusterModeSecondary, Address: "10.0.0.1"})
+	if _, again := m.List(); again != gen {
+		t.Error("an unchanged member must not change the generation")
+	}
+	m.Seen(cluster.Member{NodeID: "a", Role: config.ClusterModeSecondary, Address: "10.0.0.9"})
+	if _, moved := m.List(); moved == gen {
+		t.Error("a changed address must change the generation")
+	}
+}
+
+func TestMembers_DropsSilentNodes(t *testing.T) {
+	m := cluster.NewMembers(cluster.Member{NodeID: "primary"}, time.Nanosecond)
+	m.Seen(cluster.Member{NodeID: "a"})
+	time.Sleep(time.Millisecond)
+	if list, _ := m.List(); len(list) != 1 {
+		t.Fatalf("expected the silent member to be dropped, got %+v", list)
+	}
+}
+
+func TestMembers_ReplaceFromPrimary(t *testing.T) {
+	m := cluster.NewMembers(cluster.Member{NodeID: "self", Role: config.ClusterModeSecondary, DNSPort: 53}, 0)
+	m.Replace([]cluster.Member{
+		{NodeID: "primary", Role: config.ClusterModePrimary, Address: "10.0.0.1", LastSeen: time.Now()},
+		{NodeID: "self", Role: config.ClusterModeSecondary, Address: "10.0.0.2", DNSPort: 5353},
+	})
+	list, _ := m.List()
+	if len(list) != 2 || list[1].NodeID != "primary" {
+		t.Fatalf("unexpected members: %+v", list)
+	}
+	if self := list[0]; self.Address != "10.0.0.2" || self.DNSPort != 53 {
+		t.Errorf("expected only the primary-seen address to be taken over, got %+v", self)
+	}
+
+	// After a promotion, the old primary is no longer published
+	m.SetRole(config.ClusterModePrimary)
+	if list, _ := m.List(); len(list) != 1 || list[0].Role != config.ClusterModePrimary {
+		t.Errorf("expected only this node as primary, got %+v", list)
+	}
+}
+
+func TestSyncer_ExchangesMembers(t *testing.T) {
+	var headers http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(cluster.ExportData{
+			Version: 1,
+			Members: []cluster.Member{{NodeID: "primary", Role: config.ClusterModePrimary, Address: "10.0.0.1"}},
+		})
+	}))
+	defer server.Close()
+
+	cfg := &config.ClusterConfig{
+		Mode:         config.ClusterModeSecondary,
+		NodeID:       "self",
+		PrimaryURL:   server.URL,
+		SyncInterval: "1h",
+		SyncTimeout:  "5s",
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	importFunc := func(*cluster.ExportData) error { return nil }
+	versionFunc := func() (int64, error) { return 0, nil }
+	syncer, err := cluster.NewSyncer(cfg, logger, importFunc, nil, versionFunc)
+	if err != nil {
+		t.Fatalf("NewSyncer failed: %v", err)
+	}
+	members := cluster.NewMembers(cluster.Member{NodeID: "self", Address: "10.0.0.2", DNSPort: 53, APIPort: 8080}, 0)
+	syncer.SetMembers(members)
+
+	if err := syncer.ForceSync(context.Background()); err != nil {
+		t.Fatalf("ForceSync failed: %v", err)
+	}
+	if headers.Get("X-Node-Id") != "self" || headers.Get("X-Node-Address") != "10.0.0.2" ||
+		headers.Get("X-Node-Dns-Port") != "53" || headers.Get("X-Node-Api-Port") != "8080" {
+		t.Errorf("unexpected node headers: %v", headers)
+	}
+	if list, _ := members.List(); len(list) != 2 || list[1].NodeID != "primary" {
+		t.Errorf("expected the primary's members, got %+v", list)
+	}
+}
@@ -0,0 +1,82 @@
+package cluster
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"maps"
+	"slices"
+
+	"github.com/jroosing/hydradns/internal/config"
+)
+
+// Names of the configuration sections synced from the primary.
+const (
+	SectionUpstream  = "upstream"
+	SectionCustomDNS = "custom_dns"
+	SectionFiltering = "filtering"
+)
+
+// SectionHashes returns a content hash for each synced section of d, keyed by
+// section name.
+//
+// Hashes are computed over a normalized form (host addresses, list domains
+// and blocklists sorted and deduplicated), so a section hashes the same on the
+// primary and on a secondary that imported it. Upstream server order is kept
+// since it sets server priority.
+func (d *ExportData) SectionHashes() map[string]string {
+	return map[string]string{
+		SectionUpstream:  hashSection(normalizeUpstream(d.Upstream)),
+		SectionCustomDNS: hashSection(normalizeCustomDNS(d.CustomDNS)),
+		SectionFiltering: hashSection(normalizeFiltering(d.Filtering)),
+	}
+}
+
+// SectionNames returns the names of the synced sections in sorted order.
+func SectionNames() []string {
+	return []string{SectionCustomDNS, SectionFiltering, SectionUpstream}
+}
+
+func hashSection(v any) string {
+	// Marshaling plain structs, maps and slices cannot fail
+	b, _ := json.Marshal(v)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func normalizeUpstream(u config.UpstreamConfig) config.UpstreamConfig {
+	// Servers is not omitempty; an empty and a missing list must hash the same
+	u.Servers = append([]string{}, u.Servers...)
+	return u
+}
+
+func normalizeCustomDNS(c config.CustomDNSConfig) any {
+	hosts := make(map[string][]string, len(c.Hosts))
+	for name, ips := range c.Hosts {
+		if len(ips) > 0 {
+			hosts[name] = sortedUnique(ips)
+		}
+	}
+	cnames := make(map[string]string, len(c.CNAMEs))
+	maps.Copy(cnames, c.CNAMEs)
+
+	return struct {
+		Hosts  map[string][]string `json:"hosts"`
+		CNAMEs map[string]string   `json:"cnames"`
+	}{hosts, cnames}
+}
+
+func normalizeFiltering(f config.FilteringConfig) config.FilteringConfig {
+	f.WhitelistDomains = sortedUnique(f.WhitelistDomains)
+	f.BlacklistDomains = sortedUnique(f.BlacklistDomains)
+	f.Blocklists = slices.SortedFunc(slices.Values(f.Blocklists), func(a, b config.BlocklistConfig) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+	return f
+}
+
+// sortedUnique returns a sorted copy of items without duplicates.
+func sortedUnique(items []string) []string {
+	return slices.Compact(slices.Sorted(slices.Values(items)))
+}
@@ -0,0 +1,141 @@
+package cluster_test
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jroosing/hydradns/internal/cluster"
+	"github.com/jroosing/hydradns/internal/config"
+)
+
+func sampleExport() *cluster.ExportData {
+	return &cluster.ExportData{
+		Version: 3,
+		Upstream: config.UpstreamConfig{
+			Servers:    []string{"8.8.8.8", "1.1.1.1"},
+			UDPTimeout: "3s",
+		},
+		CustomDNS: config.CustomDNSConfig{
+			Hosts: map[string][]string{"nas.home": {"10.0.0.2", "10.0.0.1"}},
+		},
+		Filtering: config.FilteringConfig{
+			Enabled:          true,
+			WhitelistDomains: []string{"b.example", "a.example"},
+			Blocklists: []config.BlocklistConfig{
+				{Name: "trackers", URL: "https://example.com/t.txt"},
+				{Name: "ads", URL: "https://example.com/a.txt"},
+			},
+		},
+	}
+}
+
+func TestSectionHashes_IgnoreListOrder(t *testing.T) {
+	a := sampleExport()
+	b := sampleExport()
+	b.CustomDNS.Hosts["nas.home"] = []string{"10.0.0.1", "10.0.0.2", "10.0.0.1"}
+	b.Filtering.WhitelistDomains = []string{"a.example", "b.example"}
+	b.Filtering.Blocklists[0], b.Filtering.Blocklists[1] = b.Filtering.Blocklists[1], b.Filtering.Blocklists[0]
+
+	if !maps.Equal(a.SectionHashes(), b.SectionHashes()) {
+		t.Fatalf("equivalent configurations hash differently:\n%v\n%v", a.SectionHashes(), b.SectionHashes())
+	}
+
+	// Empty and missing values are equivalent too
+	empty := &cluster.ExportData{}
+	blank := &cluster.ExportData{
+		Upstream:  config.UpstreamConfig{Servers: []string{}},
+		CustomDNS: config.CustomDNSConfig{Hosts: map[string][]string{}, CNAMEs: map[string]string{}},
+	}
+	if !maps.Equal(empty.SectionHashes(), blank.SectionHashes()) {
+		t.Fatal("empty and missing sections hash differently")
+	}
+}
+
+func TestSectionHashes_DetectChangedSection(t *testing.T) {
+	base := sampleExport().SectionHashes()
+
+	// Upstream order sets priority, so it counts
+	reordered := sampleExport()
+	reordered.Upstream.Servers = []string{"1.1.1.1", "8.8.8.8"}
+	got := reordered.SectionHashes()
+	if got[cluster.SectionUpstream] == base[cluster.SectionUpstream] {
+		t.Error("upstream reorder did not change the upstream hash")
+	}
+
+	changed := sampleExport()
+	changed.Filtering.BlacklistDomains = []string{"ads.example"}
+	got = changed.SectionHashes()
+	if got[cluster.SectionFiltering] == base[cluster.SectionFiltering] {
+		t.Error("blacklist change did not change the filtering hash")
+	}
+	if got[cluster.SectionUpstream] != base[cluster.SectionUpstream] ||
+		got[cluster.SectionCustomDNS] != base[cluster.SectionCustomDNS] {
+		t.Error("filtering change affected other sections")
+	}
+
+	if len(base) != len(cluster.SectionNames()) {
+		t.Errorf("expected %d sections, got %d", len(cluster.SectionNames()), len(base))
+	}
+}
+
+func TestSyncer_ImportsWhenSectionsDiffer(t *testing.T) {
+	exported := sampleExport()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(exported)
+	}))
+	defer server.Close()
+
+	cfg := &config.ClusterConfig{
+		Mode:         config.ClusterModeSecondary,
+		PrimaryURL:   server.URL,
+		SyncInterval: "1h",
+		SyncTimeout:  "5s",
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+	var imports atomic.Int32
+	var applied map[string]string
+	importFunc := func(data *cluster.ExportData) error {
+		imports.Add(1)
+		applied = data.SectionHashes()
+		return nil
+	}
+
+	// Local edits pushed the local version past the primary's
+	versionFunc := func() (int64, error) { return 10, nil }
+
+	syncer, err := cluster.NewSyncer(cfg, logger, importFunc, nil, versionFunc)
+	if err != nil {
+		t.Fatalf("NewSyncer failed: %v", err)
+	}
+	syncer.SetSectionsFunc(func() (map[string]string, error) { return applied, nil })
+
+	ctx := context.Background()
+	if err := syncer.ForceSync(ctx); err != nil {
+		t.Fatalf("ForceSync failed: %v", err)
+	}
+	if imports.Load() != 1 {
+		t.Fatalf("expected an import for unapplied sections, got %d", imports.Load())
+	}
+
+	// Nothing changed on the primary: no import
+	if err := syncer.ForceSync(ctx); err != nil {
+		t.Fatalf("ForceSync failed: %v", err)
+	}
+	if imports.Load() != 1 {
+		t.Fatalf("expected no import for applied sections, got %d imports", imports.Load())
+	}
+
+	status := syncer.Status()
+	if !maps.Equal(status.PrimarySections, applied) {
+		t.Errorf("expected primary sections %v, got %v", applied, status.PrimarySections)
+	}
+}
@@ -9,16 +9,62 @@
 package config
 
 import (
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/netip"
+	"net/url"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/jroosing/hydradns/internal/dns"
+)
+
+// MaxNegativeCacheTTL is the upper bound for negative and SERVFAIL cache TTLs.
+const MaxNegativeCacheTTL = time.Hour
+
+// Default response cache limits.
+const (
+	// DefaultCacheMaxEntries is the default number of cached responses.
+	DefaultCacheMaxEntries = 20000
+	// DefaultCacheMaxBytes is the default total size of cached responses.
+	DefaultCacheMaxBytes = 64 << 20
+	// DefaultCacheMaxEntryBytes is the default size of the largest cached response.
+	DefaultCacheMaxEntryBytes = 16 << 10
+	// DefaultSlowQueryMaxPerMinute is the default cap on slow queries logged per minute.
+	DefaultSlowQueryMaxPerMinute = 60
 )
 
 // Validate validates and normalizes the configuration.
+//
+// Every setting is checked, not just up to the first problem: the error is a
+// *ValidationError listing each invalid setting as a *FieldError.
 func (cfg *Config) Validate() error {
+	var v validation
+
 	// Validate port
 	if cfg.Server.Port <= 0 || cfg.Server.Port > 65535 {
-		return errors.New("server.port must be 1..65535")
+		v.add(fieldErrorf("server.port", "must be 1..65535"))
+	}
+
+	// Default and validate the shutdown drain
+	if cfg.Server.ShutdownDrain == "" {
+		cfg.Server.ShutdownDrain = "0s"
+	}
+	if d, err := time.ParseDuration(cfg.Server.ShutdownDrain); err != nil || d < 0 {
+		v.add(fieldErrorf("server.shutdown_drain", "invalid duration %q", cfg.Server.ShutdownDrain))
+	}
+
+	// Validate UDP socket buffers; 0 picks the defaults
+	if cfg.Server.UDPRecvBuffer < 0 {
+		v.add(fieldErrorf("server.udp_recv_buffer", "must be >= 0"))
+	}
+	if cfg.Server.UDPSendBuffer < 0 {
+		v.add(fieldErrorf("server.udp_send_buffer", "must be >= 0"))
 	}
 
 	// Default upstream servers
@@ -26,10 +72,55 @@ func (cfg *Config) Validate() error {
 		cfg.Upstream.Servers = []string{"8.8.8.8"}
 	}
 
-	// Limit to 3 upstream servers (strict-order failover)
+	// Limit to 3 upstream servers
 	if len(cfg.Upstream.Servers) > 3 {
 		cfg.Upstream.Servers = cfg.Upstream.Servers[:3]
 	}
+	cfg.Upstream.normalizeServers(&v)
+
+	// Normalize upstream selection strategy
+	cfg.Upstream.Strategy = strings.ToLower(strings.TrimSpace(cfg.Upstream.Strategy))
+	switch cfg.Upstream.Strategy {
+	case "":
+		cfg.Upstream.Strategy = "sequential"
+	case "sequential", "round_robin", "latency", "random":
+	default:
+		v.add(fieldErrorf("upstream.strategy", "%q must be sequential, round_robin, latency, or random", cfg.Upstream.Strategy))
+	}
+
+	// Validate the upstream stage budget; empty leaves it unbounded
+	if cfg.Upstream.StageTimeout != "" {
+		if d, err := time.ParseDuration(cfg.Upstream.StageTimeout); err != nil || d <= 0 {
+			v.add(fieldErrorf("upstream.stage_timeout", "invalid duration %q", cfg.Upstream.StageTimeout))
+		}
+	}
+
+	// Normalize EDNS option rules
+	v.add(normalizeEDNSOptions(cfg.Upstream.EDNSOptions))
+
+	// Normalize DoH servers and transport rules
+	v.add(cfg.Upstream.normalizeTransports())
+
+	// Normalize TCP server limits
+	v.add(cfg.Server.normalizeTCP())
+
+	// Normalize recursion ACL
+	v.add(cfg.Server.normalizeRecursion())
+
+	// Normalize listener profiles
+	v.add(cfg.Server.normalizeListenerProfiles())
+
+	// Normalize qtype rules
+	v.add(cfg.Server.normalizeQTypeRules())
+
+	// Normalize response pipeline
+	v.add(cfg.Server.normalizeResponsePipeline())
+
+	// Normalize rate limits
+	v.add(cfg.RateLimit.normalize())
+
+	// Normalize cache
+	v.add(cfg.Cache.normalize())
 
 	// Normalize logging
 	if cfg.Logging.Level == "" {
@@ -42,25 +133,200 @@ func (cfg *Config) Validate() error {
 	if cfg.Logging.ExtraFields == nil {
 		cfg.Logging.ExtraFields = map[string]string{}
 	}
+	v.add(cfg.Logging.normalizeSlowQueries())
 
 	// Normalize filtering
-	if cfg.Filtering.RefreshInterval == "" {
-		cfg.Filtering.RefreshInterval = "24h"
-	}
+	v.add(cfg.Filtering.normalize())
+
+	// Normalize custom DNS search domains
+	v.add(cfg.CustomDNS.normalize())
+
+	// Normalize block page
+	v.add(cfg.BlockPage.normalize())
+
+	// Normalize captive portal
+	v.add(cfg.CaptivePortal.normalize())
+
+	// Normalize AAAA filtering
+	v.add(cfg.FilterAAAA.normalize())
+
+	// Normalize designated resolver discovery
+	v.add(cfg.DDR.normalize())
+
+	// Normalize PTR synthesis
+	v.add(cfg.SynthPTR.normalize())
+
+	// Normalize GeoIP
+	v.add(cfg.GeoIP.normalize())
+
+	// Normalize anomaly detection
+	v.add(cfg.Anomaly.normalize())
+
+	// Normalize threat intelligence
+	v.add(cfg.ThreatIntel.normalize())
+
+	// Normalize newly registered domain detection
+	v.add(cfg.NRD.normalize())
+
+	// Normalize server identity
+	v.add(cfg.Identity.normalize())
+
+	// Normalize outbound query auditing
+	v.add(cfg.Audit.normalize())
+
+	// Normalize privacy
+	v.add(cfg.Privacy.normalize())
+
+	// Normalize health canary
+	v.add(cfg.Canary.normalize())
+
+	// Normalize shadow upstreams
+	v.add(cfg.Shadow.normalize())
+
+	// Normalize DNS advertisement
+	v.add(cfg.Advertise.normalize())
 
 	// Normalize management API
-	if cfg.API.Host == "" {
-		cfg.API.Host = "0.0.0.0"
+	v.add(cfg.API.normalize())
+
+	// Validate cluster settings
+	v.add(cfg.Cluster.normalize())
+
+	// Normalize cluster discovery
+	v.add(cfg.ClusterDiscovery.normalize())
+
+	// Durations that would otherwise silently fall back to defaults
+	cfg.validateDurations(&v)
+
+	// Listeners competing for the same address
+	v.add(cfg.validateListeners())
+
+	// Parse workers
+	cfg.Server.Workers = parseWorkers(cfg.Server.WorkersRaw)
+
+	return v.err()
+}
+
+// normalizeServers checks that every upstream server is an IP address;
+// upstreams are always queried on port 53.
+func (u *UpstreamConfig) normalizeServers(v *validation) {
+	for i, raw := range u.Servers {
+		ip, err := netip.ParseAddr(strings.TrimSpace(raw))
+		if err != nil {
+			v.add(fieldErrorf(fmt.Sprintf("upstream.servers[%d]", i), "%q is not an IP address", raw))
+			continue
+		}
+		u.Servers[i] = ip.Unmap().String()
 	}
-	if cfg.API.Enabled {
-		if cfg.API.Port <= 0 || cfg.API.Port > 65535 {
-			return errors.New("api.port must be 1..65535")
+}
+
+// normalize checks the settings the cluster mode depends on. A secondary
+// must reach its primary over http(s) and authenticate with the shared
+// secret.
+func (c *ClusterConfig) normalize() error {
+	switch c.Mode {
+	case "", ClusterModeStandalone, ClusterModePrimary:
+		return nil
+	case ClusterModeSecondary:
+		if c.PrimaryURL == "" {
+			return fieldErrorf("cluster.primary_url", "is required in secondary mode")
 		}
+		if u, err := url.Parse(c.PrimaryURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fieldErrorf("cluster.primary_url", "%q must be an http(s) URL", c.PrimaryURL)
+		}
+		if c.SharedSecret == "" {
+			return fieldErrorf("cluster.shared_secret", "is required in secondary mode")
+		}
+		return nil
+	default:
+		return fieldErrorf("cluster.mode", "%q must be standalone, primary, or secondary", c.Mode)
 	}
+}
 
-	// Parse workers
-	cfg.Server.Workers = parseWorkers(cfg.Server.WorkersRaw)
+// DefaultClusterDiscoveryTTL is the TTL of cluster discovery answers when
+// none is configured.
+const DefaultClusterDiscoveryTTL = 60
+
+// DefaultClusterMemberTimeout is how long, in seconds, a member that stopped
+// syncing is published when no timeout is configured.
+const DefaultClusterMemberTimeout = 900
 
+// normalize applies the cluster discovery defaults and validates the zone
+// and address.
+func (d *ClusterDiscoveryConfig) normalize() error {
+	if d.TTL < 0 {
+		return fieldErrorf("cluster_discovery.ttl", "must be >= 0")
+	}
+	if d.TTL == 0 {
+		d.TTL = DefaultClusterDiscoveryTTL
+	}
+	if d.MemberTimeout < 0 {
+		return fieldErrorf("cluster_discovery.member_timeout", "must be >= 0")
+	}
+	if d.MemberTimeout == 0 {
+		d.MemberTimeout = DefaultClusterMemberTimeout
+	}
+	if d.Zone != "" {
+		name, err := dns.CanonicalName(d.Zone)
+		if err == nil && name != "" {
+			_, err = dns.EncodeName(name)
+		}
+		if err != nil || name == "" {
+			return fieldErrorf("cluster_discovery.zone", "invalid zone %q", d.Zone)
+		}
+		d.Zone = name
+	}
+	if d.Address != "" {
+		ip, err := netip.ParseAddr(strings.TrimSpace(d.Address))
+		if err != nil {
+			return fieldErrorf("cluster_discovery.address", "%q is not an IP address", d.Address)
+		}
+		d.Address = ip.Unmap().String()
+	}
+	if d.Enabled && d.Zone == "" {
+		return fieldErrorf("cluster_discovery.zone", "is required when cluster discovery is enabled")
+	}
+	return nil
+}
+
+// validateDurations checks duration settings that components would
+// otherwise silently replace by their defaults when they do not parse.
+func (cfg *Config) validateDurations(v *validation) {
+	fields := []struct{ name, value string }{
+		{"upstream.udp_timeout", cfg.Upstream.UDPTimeout},
+		{"upstream.tcp_timeout", cfg.Upstream.TCPTimeout},
+		{"filtering.refresh_interval", cfg.Filtering.RefreshInterval},
+		{"cluster.sync_interval", cfg.Cluster.SyncInterval},
+		{"cluster.sync_timeout", cfg.Cluster.SyncTimeout},
+	}
+	for _, f := range fields {
+		if f.value == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(f.value); err != nil || d < 0 {
+			v.add(fieldErrorf(f.name, "invalid duration %q", f.value))
+		}
+	}
+}
+
+// validateListeners rejects a management API listener on the address of a
+// DNS TCP listener. Listener profiles are checked against each other by
+// normalizeListenerProfiles.
+func (cfg *Config) validateListeners() error {
+	if !cfg.API.Enabled || !cfg.Server.EnableTCP {
+		return nil
+	}
+	api := listenerAddr{host: cfg.API.Host, port: cfg.API.Port}
+	listeners := []listenerAddr{{host: cfg.Server.Host, port: cfg.Server.Port, name: "the DNS listener"}}
+	for _, p := range cfg.Server.ListenerProfiles {
+		listeners = append(listeners, listenerAddr{host: p.Host, port: p.Port, name: fmt.Sprintf("listener profile %q", p.Name)})
+	}
+	for _, l := range listeners {
+		if api.overlaps(l) {
+			return fieldErrorf("api.port", "%d is already used by %s on %s",
+				api.port, l.name, net.JoinHostPort(l.host, strconv.Itoa(l.port)))
+		}
+	}
 	return nil
 }
 
@@ -75,3 +341,1297 @@ func parseWorkers(raw string) WorkerSetting {
 	}
 	return WorkerSetting{Mode: WorkersAuto}
 }
+
+// ednsOptionNames maps the option names accepted in EDNSOptionRule to their
+// codes.
+var ednsOptionNames = map[string]uint16{
+	"ecs":     dns.EDNSOptionECS,
+	"cookie":  dns.EDNSOptionCookie,
+	"padding": dns.EDNSOptionPadding,
+	"ede":     dns.EDNSOptionEDE,
+}
+
+// Code returns the EDNS option code the rule applies to.
+func (r EDNSOptionRule) Code() (uint16, error) {
+	if code, ok := ednsOptionNames[r.Option]; ok {
+		return code, nil
+	}
+	code, err := strconv.ParseUint(r.Option, 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("unknown EDNS option %q", r.Option)
+	}
+	return uint16(code), nil
+}
+
+// RewriteData returns the option data a rewrite rule sends upstream.
+func (r EDNSOptionRule) RewriteData() ([]byte, error) {
+	if r.Option == "ecs" {
+		prefix, err := netip.ParsePrefix(r.Value)
+		if err != nil {
+			return nil, fmt.Errorf("ecs value %q is not a CIDR prefix", r.Value)
+		}
+		return dns.ECSOption(prefix).Data, nil
+	}
+	data, err := hex.DecodeString(r.Value)
+	if err != nil {
+		return nil, fmt.Errorf("value %q is not hex", r.Value)
+	}
+	return data, nil
+}
+
+// normalizeEDNSOptions lowercases option names and actions and validates
+// each rule. Each option may have one rule.
+func normalizeEDNSOptions(rules []EDNSOptionRule) error {
+	seen := make(map[uint16]bool, len(rules))
+	for i := range rules {
+		r := &rules[i]
+		r.Option = strings.ToLower(strings.TrimSpace(r.Option))
+		r.Action = strings.ToLower(strings.TrimSpace(r.Action))
+		r.Value = strings.TrimSpace(r.Value)
+
+		code, err := r.Code()
+		if err != nil {
+			return fieldErrorf("upstream.edns_options", "%w", err)
+		}
+		if seen[code] {
+			return fieldErrorf("upstream.edns_options", "duplicate rule for option %q", r.Option)
+		}
+		seen[code] = true
+
+		switch r.Action {
+		case EDNSActionPass, EDNSActionStrip:
+		case EDNSActionRewrite:
+			if _, err := r.RewriteData(); err != nil {
+				return fieldErrorf(fmt.Sprintf("upstream.edns_options[%s]", r.Option), "%w", err)
+			}
+		default:
+			return fieldErrorf(fmt.Sprintf("upstream.edns_options[%s]", r.Option), "action must be pass, strip, or rewrite")
+		}
+	}
+	return nil
+}
+
+// normalizeTransports validates the DoH servers and canonicalizes the
+// transport rules. Each domain may have one rule.
+func (u *UpstreamConfig) normalizeTransports() error {
+	servers := make([]string, 0, len(u.DoHServers))
+	for _, s := range u.DoHServers {
+		s = strings.TrimSpace(s)
+		parsed, err := url.Parse(s)
+		if err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+			return fieldErrorf("upstream.doh_servers", "%q must be an https URL", s)
+		}
+		if !slices.Contains(servers, s) {
+			servers = append(servers, s)
+		}
+	}
+	if len(servers) == 0 {
+		servers = nil
+	}
+	u.DoHServers = servers
+
+	seen := make(map[string]bool, len(u.TransportRules))
+	for i := range u.TransportRules {
+		r := &u.TransportRules[i]
+		name, err := dns.CanonicalName(r.Domain)
+		if err == nil && name != "" {
+			_, err = dns.EncodeName(name)
+		}
+		if err != nil || name == "" {
+			return fieldErrorf("upstream.transport_rules", "invalid domain %q", r.Domain)
+		}
+		if seen[name] {
+			return fieldErrorf("upstream.transport_rules", "duplicate rule for %q", name)
+		}
+		seen[name] = true
+		r.Domain = name
+
+		r.Transport = strings.ToLower(strings.TrimSpace(r.Transport))
+		switch r.Transport {
+		case TransportTCP:
+		case TransportDoH:
+			if len(u.DoHServers) == 0 {
+				return fieldErrorf(fmt.Sprintf("upstream.transport_rules[%s]", name), "doh requires upstream.doh_servers")
+			}
+		default:
+			return fieldErrorf(fmt.Sprintf("upstream.transport_rules[%s]", name), "transport must be tcp or doh")
+		}
+	}
+	return nil
+}
+
+// normalizeTCP applies TCP server defaults and validates its limits.
+func (s *ServerConfig) normalizeTCP() error {
+	if s.TCPReadTimeout == "" {
+		s.TCPReadTimeout = "10s"
+	}
+	if s.TCPIdleTimeout == "" {
+		s.TCPIdleTimeout = "30s"
+	}
+	if s.TCPMaxConnsPerIP == 0 {
+		s.TCPMaxConnsPerIP = 10
+	}
+	if s.TCPMaxQueriesPerConn == 0 {
+		s.TCPMaxQueriesPerConn = 100
+	}
+
+	for field, raw := range map[string]string{
+		"server.tcp_read_timeout": s.TCPReadTimeout,
+		"server.tcp_idle_timeout": s.TCPIdleTimeout,
+	} {
+		if d, err := time.ParseDuration(raw); err != nil || d <= 0 {
+			return fieldErrorf(field, "invalid duration %q", raw)
+		}
+	}
+	if s.TCPMaxConnsPerIP < 0 {
+		return fieldErrorf("server.tcp_max_conns_per_ip", "must be >= 0")
+	}
+	if s.TCPMaxQueriesPerConn < 0 {
+		return fieldErrorf("server.tcp_max_queries_per_conn", "must be >= 0")
+	}
+	if s.TCPListeners < 0 {
+		return fieldErrorf("server.tcp_listeners", "must be >= 0")
+	}
+
+	for i, raw := range s.ProxyProtocolTrusted {
+		p, err := parsePrefixOrAddr(raw)
+		if err != nil {
+			return fieldErrorf("server.proxy_protocol_trusted", "%q is not an IP address or CIDR prefix", raw)
+		}
+		s.ProxyProtocolTrusted[i] = p.String()
+	}
+	return nil
+}
+
+// normalizeRecursion validates the recursion client list.
+func (s *ServerConfig) normalizeRecursion() error {
+	for i, raw := range s.RecursionClients {
+		p, err := parsePrefixOrAddr(raw)
+		if err != nil {
+			return fieldErrorf("server.recursion_clients", "%q is not an IP address or CIDR prefix", raw)
+		}
+		s.RecursionClients[i] = p.String()
+	}
+	return nil
+}
+
+// normalizeListenerProfiles applies the default host and rejects profiles
+// without a name or port and profiles sharing a name or address with another
+// listener.
+func (s *ServerConfig) normalizeListenerProfiles() error {
+	names := make(map[string]bool, len(s.ListenerProfiles))
+	addrs := map[string]string{
+		net.JoinHostPort(s.Host, strconv.Itoa(s.Port)): "the default listener",
+	}
+	for i := range s.ListenerProfiles {
+		p := &s.ListenerProfiles[i]
+		p.Name = strings.ToLower(strings.TrimSpace(p.Name))
+		p.Host = strings.TrimSpace(p.Host)
+		if p.Name == "" {
+			return fieldErrorf(fmt.Sprintf("server.listener_profiles[%d]", i), "name is required")
+		}
+		if names[p.Name] {
+			return fieldErrorf("server.listener_profiles", "duplicate name %q", p.Name)
+		}
+		names[p.Name] = true
+		if p.Host == "" {
+			p.Host = s.Host
+		}
+		if p.Port <= 0 || p.Port > 65535 {
+			return fieldErrorf(fmt.Sprintf("server.listener_profiles[%s].port", p.Name), "must be 1..65535")
+		}
+		if other, ok := addrs[p.Addr()]; ok {
+			return fieldErrorf(fmt.Sprintf("server.listener_profiles[%s]", p.Name), "%s is already used by %s", p.Addr(), other)
+		}
+		addrs[p.Addr()] = fmt.Sprintf("profile %q", p.Name)
+	}
+	return nil
+}
+
+// normalizeQTypeRules canonicalizes qtype rule types, clients, and actions.
+func (s *ServerConfig) normalizeQTypeRules() error {
+	for i := range s.QTypeRules {
+		r := &s.QTypeRules[i]
+		if len(r.QTypes) == 0 {
+			return fieldErrorf(fmt.Sprintf("server.qtype_rules[%d]", i), "qtypes is required")
+		}
+		qtypes := make([]string, 0, len(r.QTypes))
+		for _, raw := range r.QTypes {
+			t, err := dns.ParseRecordType(raw)
+			if err != nil {
+				return fieldErrorf(fmt.Sprintf("server.qtype_rules[%d]", i), "%w", err)
+			}
+			if !slices.Contains(qtypes, t.String()) {
+				qtypes = append(qtypes, t.String())
+			}
+		}
+		r.QTypes = qtypes
+
+		for j, raw := range r.Clients {
+			p, err := parsePrefixOrAddr(raw)
+			if err != nil {
+				return fieldErrorf(fmt.Sprintf("server.qtype_rules[%d]", i), "client %q is not an IP address or CIDR prefix", raw)
+			}
+			r.Clients[j] = p.String()
+		}
+
+		r.Action = strings.ToLower(strings.TrimSpace(r.Action))
+		switch r.Action {
+		case "":
+			r.Action = "refused"
+		case "refused", "nxdomain", "nodata", "drop":
+		default:
+			return fieldErrorf(fmt.Sprintf("server.qtype_rules[%d]", i), "action %q must be refused, nxdomain, nodata, or drop", r.Action)
+		}
+	}
+	return nil
+}
+
+// normalizeResponsePipeline normalizes the response pipeline steps and
+// rejects ones that are incomplete or invalid for their type.
+func (s *ServerConfig) normalizeResponsePipeline() error {
+	for i := range s.ResponsePipeline {
+		st := &s.ResponsePipeline[i]
+		field := fmt.Sprintf("server.response_pipeline[%d]", i)
+
+		for j, raw := range st.Clients {
+			p, err := parsePrefixOrAddr(raw)
+			if err != nil {
+				return fieldErrorf(field, "client %q is not an IP address or CIDR prefix", raw)
+			}
+			st.Clients[j] = p.String()
+		}
+
+		st.Type = strings.ToLower(strings.TrimSpace(st.Type))
+		switch st.Type {
+		case ResponseStepTTLClamp:
+			if st.MinTTL < 0 || st.MaxTTL < 0 {
+				return fieldErrorf(field, "min_ttl and max_ttl must be >= 0")
+			}
+			if st.MinTTL == 0 && st.MaxTTL == 0 {
+				return fieldErrorf(field, "min_ttl or max_ttl is required")
+			}
+			if st.MaxTTL > 0 && st.MinTTL > st.MaxTTL {
+				return fieldErrorf(field, "min_ttl must not exceed max_ttl")
+			}
+		case ResponseStepFilterAAAA:
+		case ResponseStepReorder:
+			st.Order = strings.ToLower(strings.TrimSpace(st.Order))
+			switch st.Order {
+			case "":
+				st.Order = "round_robin"
+			case "round_robin", "random":
+			default:
+				return fieldErrorf(field, "order %q must be round_robin or random", st.Order)
+			}
+		case ResponseStepEDE:
+			if st.RCode == "" {
+				st.RCode = "SERVFAIL"
+			}
+			rc, err := dns.ParseRCode(st.RCode)
+			if err != nil {
+				return fieldErrorf(field, "%w", err)
+			}
+			st.RCode = rc.String()
+			if st.Code < 0 || st.Code > 65535 {
+				return fieldErrorf(field, "code must be 0..65535")
+			}
+		case ResponseStepScrub:
+			if len(st.RecordTypes) == 0 {
+				return fieldErrorf(field, "record_types is required")
+			}
+			types := make([]string, 0, len(st.RecordTypes))
+			for _, raw := range st.RecordTypes {
+				t, err := dns.ParseRecordType(raw)
+				if err != nil {
+					return fieldErrorf(field, "%w", err)
+				}
+				if t == dns.TypeOPT {
+					return fieldErrorf(field, "OPT records cannot be scrubbed")
+				}
+				if !slices.Contains(types, t.String()) {
+					types = append(types, t.String())
+				}
+			}
+			st.RecordTypes = types
+		default:
+			return fieldErrorf(field, "type %q must be ttl_clamp, filter_aaaa, reorder, ede, or scrub", st.Type)
+		}
+	}
+	return nil
+}
+
+// ClientSubnetPrefixes returns ClientSubnets as prefixes.
+func (a AdvertiseConfig) ClientSubnetPrefixes() []netip.Prefix {
+	return parsePrefixes(a.ClientSubnets)
+}
+
+// ClientPrefixes returns Clients as prefixes.
+func (st ResponseStep) ClientPrefixes() []netip.Prefix {
+	return parsePrefixes(st.Clients)
+}
+
+// RecursionPrefixes returns RecursionClients as prefixes, or nil when every
+// client is offered recursion.
+func (s ServerConfig) RecursionPrefixes() []netip.Prefix {
+	return parsePrefixes(s.RecursionClients)
+}
+
+// ClientPrefixes returns Clients as prefixes.
+func (r QTypeRule) ClientPrefixes() []netip.Prefix {
+	return parsePrefixes(r.Clients)
+}
+
+// ProxyProtocolPrefixes returns ProxyProtocolTrusted as prefixes.
+func (s ServerConfig) ProxyProtocolPrefixes() []netip.Prefix {
+	return parsePrefixes(s.ProxyProtocolTrusted)
+}
+
+// parsePrefixes parses each entry of raw with parsePrefixOrAddr. Entries
+// that do not parse are skipped; Validate rejects them.
+func parsePrefixes(raw []string) []netip.Prefix {
+	var out []netip.Prefix
+	for _, r := range raw {
+		if p, err := parsePrefixOrAddr(r); err == nil {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// parsePrefixOrAddr parses a CIDR prefix, or a single address as a
+// full-length prefix. IPv4-mapped IPv6 addresses are unmapped.
+func parsePrefixOrAddr(raw string) (netip.Prefix, error) {
+	raw = strings.TrimSpace(raw)
+	if strings.Contains(raw, "/") {
+		p, err := netip.ParsePrefix(raw)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		return p.Masked(), nil
+	}
+	ip, err := netip.ParseAddr(raw)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	ip = ip.Unmap()
+	return netip.PrefixFrom(ip, ip.BitLen()), nil
+}
+
+// DefaultAPIMaxBodyBytes is the default management API request body limit.
+const DefaultAPIMaxBodyBytes = 1 << 20
+
+// normalize applies management API defaults and validates its limits.
+func (a *APIConfig) normalize() error {
+	if a.Host == "" {
+		a.Host = "0.0.0.0"
+	}
+	if a.Enabled {
+		if a.Port <= 0 || a.Port > 65535 {
+			return fieldErrorf("api.port", "must be 1..65535")
+		}
+	}
+
+	if a.RateLimitRPS < 0 {
+		return fieldErrorf("api.rate_limit_rps", "must be >= 0")
+	}
+	if a.RateLimitBurst < 0 {
+		return fieldErrorf("api.rate_limit_burst", "must be >= 0")
+	}
+	if a.RateLimitBurst == 0 && a.RateLimitRPS > 0 {
+		a.RateLimitBurst = max(1, int(math.Ceil(2*a.RateLimitRPS)))
+	}
+	if a.MaxBodyBytes < 0 {
+		return fieldErrorf("api.max_body_bytes", "must be >= 0")
+	}
+	if a.MaxBodyBytes == 0 {
+		a.MaxBodyBytes = DefaultAPIMaxBodyBytes
+	}
+	return nil
+}
+
+// normalize canonicalizes the search domains, drops duplicates, and
+// validates the answer order.
+func (c *CustomDNSConfig) normalize() error {
+	domains := make([]string, 0, len(c.SearchDomains))
+	for _, d := range c.SearchDomains {
+		name, err := dns.CanonicalName(d)
+		if err == nil && name != "" {
+			_, err = dns.EncodeName(name)
+		}
+		if err != nil || name == "" {
+			return fieldErrorf("custom_dns.search_domains", "invalid domain %q", d)
+		}
+		if !slices.Contains(domains, name) {
+			domains = append(domains, name)
+		}
+	}
+	if len(domains) == 0 {
+		domains = nil
+	}
+	c.SearchDomains = domains
+
+	c.AnswerOrder = strings.ToLower(strings.TrimSpace(c.AnswerOrder))
+	switch c.AnswerOrder {
+	case "":
+		c.AnswerOrder = "fixed"
+	case "fixed", "round_robin", "random":
+	default:
+		return fieldErrorf("custom_dns.answer_order", "%q must be fixed, round_robin, or random", c.AnswerOrder)
+	}
+
+	seen := make(map[string]bool, len(c.HealthChecks))
+	for i := range c.HealthChecks {
+		if err := c.HealthChecks[i].normalize(); err != nil {
+			return fieldErrorf(fmt.Sprintf("custom_dns.health_checks[%d]", i), "%w", err)
+		}
+		name := c.HealthChecks[i].Name
+		if seen[name] {
+			return fieldErrorf("custom_dns.health_checks", "duplicate check for %q", name)
+		}
+		seen[name] = true
+	}
+
+	clear(seen)
+	for i := range c.Steering {
+		if err := c.Steering[i].Normalize(); err != nil {
+			return fieldErrorf(fmt.Sprintf("custom_dns.steering[%d]", i), "%w", err)
+		}
+		name := c.Steering[i].Name
+		if seen[name] {
+			return fieldErrorf("custom_dns.steering", "duplicate steering for %q", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// Normalize canonicalizes the name, addresses, and clients of a steered
+// host.
+func (s *HostSteering) Normalize() error {
+	name, err := dns.CanonicalName(s.Name)
+	if err == nil && name != "" {
+		_, err = dns.EncodeName(name)
+	}
+	if err != nil || name == "" {
+		return fmt.Errorf("invalid name %q", s.Name)
+	}
+	s.Name = name
+
+	if len(s.Addrs) == 0 {
+		return errors.New("addrs is required")
+	}
+	seen := make(map[netip.Addr]bool, len(s.Addrs))
+	for i := range s.Addrs {
+		a := &s.Addrs[i]
+		addr, err := netip.ParseAddr(strings.TrimSpace(a.Addr))
+		if err != nil {
+			return fmt.Errorf("invalid address %q", a.Addr)
+		}
+		addr = addr.Unmap()
+		if seen[addr] {
+			return fmt.Errorf("duplicate address %s", addr)
+		}
+		seen[addr] = true
+		a.Addr = addr.String()
+
+		if a.Weight < 0 {
+			return fmt.Errorf("address %s: weight must not be negative", a.Addr)
+		}
+		for j, raw := range a.Clients {
+			p, err := parsePrefixOrAddr(raw)
+			if err != nil {
+				return fmt.Errorf("address %s: client %q is not an IP address or CIDR prefix", a.Addr, raw)
+			}
+			a.Clients[j] = p.String()
+		}
+	}
+	return nil
+}
+
+// ClientPrefixes returns Clients as prefixes.
+func (a SteeredAddr) ClientPrefixes() []netip.Prefix {
+	return parsePrefixes(a.Clients)
+}
+
+// normalize applies health check defaults and validates the check.
+func (h *RecordHealthCheck) normalize() error {
+	name, err := dns.CanonicalName(h.Name)
+	if err == nil && name != "" {
+		_, err = dns.EncodeName(name)
+	}
+	if err != nil || name == "" {
+		return fmt.Errorf("invalid name %q", h.Name)
+	}
+	h.Name = name
+
+	h.Type = strings.ToLower(strings.TrimSpace(h.Type))
+	switch h.Type {
+	case HealthCheckTCP:
+		h.Path = ""
+	case HealthCheckHTTP:
+		if h.Path == "" {
+			h.Path = "/"
+		}
+		if !strings.HasPrefix(h.Path, "/") {
+			return fmt.Errorf("path %q must start with /", h.Path)
+		}
+	default:
+		return fmt.Errorf("type %q must be tcp or http", h.Type)
+	}
+	if h.Port <= 0 || h.Port > 65535 {
+		return errors.New("port must be 1..65535")
+	}
+
+	if h.Interval == "" {
+		h.Interval = "10s"
+	}
+	if h.Timeout == "" {
+		h.Timeout = "2s"
+	}
+	if d, err := time.ParseDuration(h.Interval); err != nil || d < time.Second {
+		return fmt.Errorf("interval %q must be a duration of at least 1s", h.Interval)
+	}
+	if d, err := time.ParseDuration(h.Timeout); err != nil || d <= 0 {
+		return fmt.Errorf("timeout %q must be a positive duration", h.Timeout)
+	}
+	if h.Failures == 0 {
+		h.Failures = 2
+	}
+	if h.Failures < 0 {
+		return errors.New("failures must not be negative")
+	}
+	return nil
+}
+
+// normalize applies block page defaults and validates its addresses.
+func (b *BlockPageConfig) normalize() error {
+	if b.HTTPAddr == "" {
+		b.HTTPAddr = ":80"
+	}
+	if b.HTTPSAddr == "" {
+		b.HTTPSAddr = ":443"
+	}
+	if !b.Enabled {
+		return nil
+	}
+
+	if b.IPv4 == "" && b.IPv6 == "" {
+		return fieldErrorf("block_page", "requires ipv4 or ipv6")
+	}
+	if b.IPv4 != "" {
+		if ip, err := netip.ParseAddr(b.IPv4); err != nil || !ip.Is4() {
+			return fieldErrorf("block_page.ipv4", "%q is not an IPv4 address", b.IPv4)
+		}
+	}
+	if b.IPv6 != "" {
+		if ip, err := netip.ParseAddr(b.IPv6); err != nil || !ip.Is6() || ip.Is4In6() {
+			return fieldErrorf("block_page.ipv6", "%q is not an IPv6 address", b.IPv6)
+		}
+	}
+	if (b.TLSCert == "") != (b.TLSKey == "") {
+		return fieldErrorf("block_page.tls_cert", "must be set together with block_page.tls_key")
+	}
+	return nil
+}
+
+// DefaultBlockTTL is the TTL of block page answers and of blocked negative
+// answers when none is configured.
+const DefaultBlockTTL = 10
+
+// MaxBlockTTL bounds the block TTLs.
+const MaxBlockTTL = 86400
+
+// normalize applies the filtering defaults and validates the block TTLs.
+func (f *FilteringConfig) normalize() error {
+	if f.RefreshInterval == "" {
+		f.RefreshInterval = "24h"
+	}
+	if f.BlockTTL < 0 || f.BlockTTL > MaxBlockTTL {
+		return fieldErrorf("filtering.block_ttl", "must be between 0 and %d", MaxBlockTTL)
+	}
+	if f.NegativeTTL < 0 || f.NegativeTTL > MaxBlockTTL {
+		return fieldErrorf("filtering.negative_ttl", "must be between 0 and %d", MaxBlockTTL)
+	}
+	if f.BlockTTL == 0 {
+		f.BlockTTL = DefaultBlockTTL
+	}
+	if f.NegativeTTL == 0 {
+		f.NegativeTTL = DefaultBlockTTL
+	}
+	return nil
+}
+
+// DefaultCaptivePortalTTL is the TTL of captive portal answers when none is
+// configured.
+const DefaultCaptivePortalTTL = 10
+
+// normalize applies the captive portal defaults and validates its clients,
+// addresses and allowed domains.
+func (c *CaptivePortalConfig) normalize() error {
+	if c.TTL < 0 {
+		return fieldErrorf("captive_portal.ttl", "must be >= 0")
+	}
+	if c.TTL == 0 {
+		c.TTL = DefaultCaptivePortalTTL
+	}
+	for i, raw := range c.Clients {
+		p, err := parsePrefixOrAddr(raw)
+		if err != nil {
+			return fieldErrorf(fmt.Sprintf("captive_portal.clients[%d]", i), "%q is not an IP address or CIDR prefix", raw)
+		}
+		c.Clients[i] = p.String()
+	}
+	for i, d := range c.AllowDomains {
+		name, err := dns.CanonicalName(d)
+		if err == nil && name != "" {
+			_, err = dns.EncodeName(name)
+		}
+		if err != nil || name == "" {
+			return fieldErrorf(fmt.Sprintf("captive_portal.allow_domains[%d]", i), "invalid domain %q", d)
+		}
+		c.AllowDomains[i] = name
+	}
+	if !c.Enabled {
+		return nil
+	}
+
+	if len(c.Clients) == 0 {
+		return fieldErrorf("captive_portal.clients", "is required when the captive portal is enabled")
+	}
+	if c.IPv4 == "" && c.IPv6 == "" {
+		return fieldErrorf("captive_portal", "requires ipv4 or ipv6")
+	}
+	if c.IPv4 != "" {
+		if ip, err := netip.ParseAddr(c.IPv4); err != nil || !ip.Is4() {
+			return fieldErrorf("captive_portal.ipv4", "%q is not an IPv4 address", c.IPv4)
+		}
+	}
+	if c.IPv6 != "" {
+		if ip, err := netip.ParseAddr(c.IPv6); err != nil || !ip.Is6() || ip.Is4In6() {
+			return fieldErrorf("captive_portal.ipv6", "%q is not an IPv6 address", c.IPv6)
+		}
+	}
+	return nil
+}
+
+// ClientPrefixes returns Clients as prefixes.
+func (c CaptivePortalConfig) ClientPrefixes() []netip.Prefix {
+	return parsePrefixes(c.Clients)
+}
+
+// DefaultDDRTTL is the TTL of DDR answers when none is configured.
+const DefaultDDRTTL = 300
+
+// DefaultDoHPath is the DoH URI template advertised when none is
+// configured (RFC 8484 §4.1).
+const DefaultDoHPath = "/dns-query{?dns}"
+
+// normalize applies the DDR defaults and validates the target, ports,
+// DoH path and address hints.
+func (d *DDRConfig) normalize() error {
+	if d.TTL < 0 {
+		return fieldErrorf("ddr.ttl", "must be >= 0")
+	}
+	if d.TTL == 0 {
+		d.TTL = DefaultDDRTTL
+	}
+	if d.DoHPort < 0 || d.DoHPort > 65535 {
+		return fieldErrorf("ddr.doh_port", "%d must be between 0 and 65535", d.DoHPort)
+	}
+	if d.DoTPort < 0 || d.DoTPort > 65535 {
+		return fieldErrorf("ddr.dot_port", "%d must be between 0 and 65535", d.DoTPort)
+	}
+	d.DoHPath = strings.TrimSpace(d.DoHPath)
+	if d.DoHPath == "" {
+		d.DoHPath = DefaultDoHPath
+	}
+	// RFC 9461 §5: a relative URI template containing the "dns" variable
+	if !strings.HasPrefix(d.DoHPath, "/") || !strings.Contains(d.DoHPath, "{?dns}") {
+		return fieldErrorf("ddr.doh_path", "%q must start with / and contain {?dns}", d.DoHPath)
+	}
+	if d.Target != "" {
+		name, err := dns.CanonicalName(d.Target)
+		if err == nil && name != "" {
+			_, err = dns.EncodeName(name)
+		}
+		if err != nil || name == "" {
+			return fieldErrorf("ddr.target", "invalid hostname %q", d.Target)
+		}
+		d.Target = name
+	}
+	for i, raw := range d.IPv4Hints {
+		ip, err := netip.ParseAddr(strings.TrimSpace(raw))
+		if err != nil || !ip.Is4() {
+			return fieldErrorf(fmt.Sprintf("ddr.ipv4_hints[%d]", i), "%q is not an IPv4 address", raw)
+		}
+		d.IPv4Hints[i] = ip.String()
+	}
+	for i, raw := range d.IPv6Hints {
+		ip, err := netip.ParseAddr(strings.TrimSpace(raw))
+		if err != nil || !ip.Is6() || ip.Is4In6() {
+			return fieldErrorf(fmt.Sprintf("ddr.ipv6_hints[%d]", i), "%q is not an IPv6 address", raw)
+		}
+		d.IPv6Hints[i] = ip.String()
+	}
+	if !d.Enabled {
+		return nil
+	}
+
+	if d.Target == "" {
+		return fieldErrorf("ddr.target", "is required when DDR is enabled")
+	}
+	if d.DoHPort == 0 && d.DoTPort == 0 {
+		return fieldErrorf("ddr", "requires doh_port or dot_port")
+	}
+	return nil
+}
+
+// Hints returns the IPv4 and IPv6 hints as addresses. Entries that do not
+// parse are skipped; Validate rejects them.
+func (d DDRConfig) Hints() (ipv4, ipv6 []netip.Addr) {
+	for _, raw := range d.IPv4Hints {
+		if ip, err := netip.ParseAddr(raw); err == nil {
+			ipv4 = append(ipv4, ip)
+		}
+	}
+	for _, raw := range d.IPv6Hints {
+		if ip, err := netip.ParseAddr(raw); err == nil {
+			ipv6 = append(ipv6, ip)
+		}
+	}
+	return ipv4, ipv6
+}
+
+// DefaultSynthPTRTTL is the TTL of synthesized PTR answers when none is
+// configured.
+const DefaultSynthPTRTTL = 300
+
+// normalize applies the PTR synthesis defaults and validates the prefixes
+// and hostname template.
+func (s *SynthPTRConfig) normalize() error {
+	if s.TTL < 0 {
+		return fieldErrorf("synth_ptr.ttl", "must be >= 0")
+	}
+	if s.TTL == 0 {
+		s.TTL = DefaultSynthPTRTTL
+	}
+	for i, raw := range s.Prefixes {
+		p, err := parsePrefixOrAddr(raw)
+		if err != nil || !p.Addr().Is6() || p.Addr().Is4In6() {
+			return fieldErrorf(fmt.Sprintf("synth_ptr.prefixes[%d]", i), "%q is not an IPv6 prefix", raw)
+		}
+		s.Prefixes[i] = p.String()
+	}
+	s.Template = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(s.Template), "."))
+	if s.Template != "" {
+		// The longest expansion of {ip} must still be a valid name
+		_, err := dns.EncodeName(strings.ReplaceAll(s.Template, "{ip}", "ffff-ffff-ffff-ffff-ffff-ffff-ffff-ffff"))
+		if !strings.Contains(s.Template, "{ip}") || err != nil {
+			return fieldErrorf("synth_ptr.template", "%q must be a hostname containing {ip}", s.Template)
+		}
+	}
+	if !s.Enabled {
+		return nil
+	}
+
+	if len(s.Prefixes) == 0 {
+		return fieldErrorf("synth_ptr.prefixes", "is required when PTR synthesis is enabled")
+	}
+	if s.Template == "" {
+		return fieldErrorf("synth_ptr.template", "is required when PTR synthesis is enabled")
+	}
+	return nil
+}
+
+// PrefixList returns Prefixes as prefixes.
+func (s SynthPTRConfig) PrefixList() []netip.Prefix {
+	return parsePrefixes(s.Prefixes)
+}
+
+// normalize canonicalizes the filtered clients.
+func (f *FilterAAAAConfig) normalize() error {
+	for i, raw := range f.Clients {
+		p, err := parsePrefixOrAddr(raw)
+		if err != nil {
+			return fieldErrorf(fmt.Sprintf("filter_aaaa.clients[%d]", i), "%q is not an IP address or CIDR prefix", raw)
+		}
+		f.Clients[i] = p.String()
+	}
+	return nil
+}
+
+// ClientPrefixes returns Clients as prefixes.
+func (f FilterAAAAConfig) ClientPrefixes() []netip.Prefix {
+	return parsePrefixes(f.Clients)
+}
+
+// normalize upper-cases and validates blocked country codes and checks that
+// each block list has the database it needs.
+func (g *GeoIPConfig) normalize() error {
+	for i, cc := range g.BlockCountries {
+		cc = strings.ToUpper(strings.TrimSpace(cc))
+		if len(cc) != 2 || cc[0] < 'A' || cc[0] > 'Z' || cc[1] < 'A' || cc[1] > 'Z' {
+			return fieldErrorf("geoip.block_countries", "%q is not a two-letter country code", g.BlockCountries[i])
+		}
+		g.BlockCountries[i] = cc
+	}
+	if len(g.BlockCountries) > 0 && g.CountryDB == "" {
+		return fieldErrorf("geoip.block_countries", "requires geoip.country_db")
+	}
+	if slices.Contains(g.BlockASNs, 0) {
+		return fieldErrorf("geoip.block_asns", "0 is not a valid ASN")
+	}
+	if len(g.BlockASNs) > 0 && g.ASNDB == "" {
+		return fieldErrorf("geoip.block_asns", "requires geoip.asn_db")
+	}
+	return nil
+}
+
+// normalize applies anomaly detection defaults and validates thresholds.
+func (a *AnomalyConfig) normalize() error {
+	if a.Window == "" {
+		a.Window = "1m"
+	}
+	if d, err := time.ParseDuration(a.Window); err != nil || d < time.Second {
+		return fieldErrorf("anomaly.window", "%q must be a duration of at least 1s", a.Window)
+	}
+	if a.MinQueries < 0 {
+		return fieldErrorf("anomaly.min_queries", "must be >= 0")
+	}
+	if a.MinQueries == 0 {
+		a.MinQueries = 50
+	}
+	if a.NXDOMAINRatio < 0 || a.NXDOMAINRatio > 1 {
+		return fieldErrorf("anomaly.nxdomain_ratio", "must be in [0,1]")
+	}
+	if a.NXDOMAINRatio == 0 {
+		a.NXDOMAINRatio = 0.5
+	}
+	if a.TXTRatio < 0 || a.TXTRatio > 1 {
+		return fieldErrorf("anomaly.txt_ratio", "must be in [0,1]")
+	}
+	if a.TXTRatio == 0 {
+		a.TXTRatio = 0.5
+	}
+	if a.MaxLabelLength < 0 || a.MaxLabelLength > 63 {
+		return fieldErrorf("anomaly.max_label_length", "must be 0..63")
+	}
+	if a.MaxLabelLength == 0 {
+		a.MaxLabelLength = 50
+	}
+	if a.MinLabelEntropy < 0 {
+		return fieldErrorf("anomaly.min_label_entropy", "must be >= 0")
+	}
+	if a.MinLabelEntropy == 0 {
+		a.MinLabelEntropy = 4.0
+	}
+	return nil
+}
+
+// normalize applies threat intelligence defaults and validates the
+// provider and durations.
+func (t *ThreatIntelConfig) normalize() error {
+	t.Provider = strings.ToLower(strings.TrimSpace(t.Provider))
+	if t.Provider == "" {
+		t.Provider = "urlhaus"
+	}
+	if t.Provider != "urlhaus" {
+		return fieldErrorf("threat_intel.provider", "%q is not supported", t.Provider)
+	}
+	if t.URL != "" {
+		if u, err := url.Parse(t.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fieldErrorf("threat_intel.url", "%q must be an http(s) URL", t.URL)
+		}
+	}
+	if t.BlockTTL == "" {
+		t.BlockTTL = "24h"
+	}
+	if d, err := time.ParseDuration(t.BlockTTL); err != nil || d < time.Minute {
+		return fieldErrorf("threat_intel.block_ttl", "%q must be a duration of at least 1m", t.BlockTTL)
+	}
+	if t.CacheTTL == "" {
+		t.CacheTTL = "6h"
+	}
+	cacheTTL, err := time.ParseDuration(t.CacheTTL)
+	if err != nil || cacheTTL < time.Minute {
+		return fieldErrorf("threat_intel.cache_ttl", "%q must be a duration of at least 1m", t.CacheTTL)
+	}
+	// A cached verdict stops a domain being checked again, so a block that
+	// expired before it would leave a known malicious domain unblocked
+	if blockTTL, _ := time.ParseDuration(t.BlockTTL); cacheTTL > blockTTL {
+		return fieldErrorf("threat_intel.cache_ttl", "%q must not exceed block_ttl %q", t.CacheTTL, t.BlockTTL)
+	}
+	if t.RateLimit < 0 {
+		return fieldErrorf("threat_intel.rate_limit", "must be >= 0")
+	}
+	if t.RateLimit == 0 {
+		t.RateLimit = 1
+	}
+	return nil
+}
+
+// normalize applies NRD defaults and validates the action and window.
+func (n *NRDConfig) normalize() error {
+	n.Action = strings.ToLower(strings.TrimSpace(n.Action))
+	if n.Action == "" {
+		n.Action = "flag"
+	}
+	if n.Action != "flag" && n.Action != "block" {
+		return fieldErrorf("nrd.action", "%q must be flag or block", n.Action)
+	}
+	if n.Days < 0 {
+		return fieldErrorf("nrd.days", "must be >= 0")
+	}
+	if n.Days == 0 {
+		n.Days = 30
+	}
+	return nil
+}
+
+// maxIdentityLength is the longest value a single TXT character-string can
+// hold.
+const maxIdentityLength = 255
+
+// normalize trims the identity values and checks they fit in a TXT record.
+func (i *IdentityConfig) normalize() error {
+	i.Version = strings.TrimSpace(i.Version)
+	i.Hostname = strings.TrimSpace(i.Hostname)
+	i.ServerID = strings.TrimSpace(i.ServerID)
+	for name, v := range map[string]string{
+		"identity.version":   i.Version,
+		"identity.hostname":  i.Hostname,
+		"identity.server_id": i.ServerID,
+	} {
+		if len(v) > maxIdentityLength {
+			return fieldErrorf(name, "must be at most %d bytes", maxIdentityLength)
+		}
+	}
+	return nil
+}
+
+// normalize checks that an enabled audit log has a path.
+func (a *AuditConfig) normalize() error {
+	a.Path = strings.TrimSpace(a.Path)
+	if a.Enabled && a.Path == "" {
+		return fieldErrorf("audit.path", "is required when auditing is enabled")
+	}
+	return nil
+}
+
+// normalize applies the privacy defaults and validates the level and
+// anonymization method.
+func (p *PrivacyConfig) normalize() error {
+	p.Level = strings.ToLower(strings.TrimSpace(p.Level))
+	p.Anonymize = strings.ToLower(strings.TrimSpace(p.Anonymize))
+	if p.Level == "" {
+		p.Level = "full"
+	}
+	if p.Anonymize == "" {
+		p.Anonymize = "truncate"
+	}
+	switch p.Level {
+	case "full", "anonymized", "domains", "none":
+	default:
+		return fieldErrorf("privacy.level", "%q must be full, anonymized, domains, or none", p.Level)
+	}
+	if p.Anonymize != "truncate" && p.Anonymize != "hash" {
+		return fieldErrorf("privacy.anonymize", "%q must be truncate or hash", p.Anonymize)
+	}
+	return nil
+}
+
+// normalizeSlowQueries applies slow query log defaults and validates them.
+func (l *LoggingConfig) normalizeSlowQueries() error {
+	if l.SlowQueryThreshold == "" {
+		l.SlowQueryThreshold = "0s"
+	}
+	if d, err := time.ParseDuration(l.SlowQueryThreshold); err != nil || d < 0 {
+		return fieldErrorf("logging.slow_query_threshold", "invalid duration %q", l.SlowQueryThreshold)
+	}
+	if l.SlowQueryMaxPerMinute < 0 {
+		return fieldErrorf("logging.slow_query_max_per_minute", "must be >= 0")
+	}
+	if l.SlowQueryMaxPerMinute == 0 {
+		l.SlowQueryMaxPerMinute = DefaultSlowQueryMaxPerMinute
+	}
+	return nil
+}
+
+// DefaultCanaryDomains are the names the health canary resolves when none
+// are configured.
+var DefaultCanaryDomains = []string{"example.com", "iana.org"}
+
+// normalize applies health canary defaults, validates the durations, and
+// canonicalizes the domains.
+func (c *CanaryConfig) normalize() error {
+	if c.Interval == "" {
+		c.Interval = "30s"
+	}
+	if d, err := time.ParseDuration(c.Interval); err != nil || d < time.Second {
+		return fieldErrorf("canary.interval", "%q must be a duration of at least 1s", c.Interval)
+	}
+	if c.Timeout == "" {
+		c.Timeout = "5s"
+	}
+	if d, err := time.ParseDuration(c.Timeout); err != nil || d <= 0 {
+		return fieldErrorf("canary.timeout", "%q must be a positive duration", c.Timeout)
+	}
+	if c.FailureThreshold < 0 {
+		return fieldErrorf("canary.failure_threshold", "must be >= 0")
+	}
+	if c.FailureThreshold == 0 {
+		c.FailureThreshold = 3
+	}
+
+	domains := make([]string, 0, len(c.Domains))
+	for _, d := range c.Domains {
+		name, err := dns.CanonicalName(d)
+		if err == nil && name != "" {
+			_, err = dns.EncodeName(name)
+		}
+		if err != nil || name == "" {
+			return fieldErrorf("canary.domains", "invalid domain %q", d)
+		}
+		if !slices.Contains(domains, name) {
+			domains = append(domains, name)
+		}
+	}
+	if len(domains) == 0 {
+		domains = slices.Clone(DefaultCanaryDomains)
+	}
+	c.Domains = domains
+	return nil
+}
+
+// normalize applies the shadow upstream defaults and checks that an enabled
+// shadow has IP address servers.
+func (s *ShadowConfig) normalize() error {
+	for i, raw := range s.Servers {
+		ip, err := netip.ParseAddr(strings.TrimSpace(raw))
+		if err != nil {
+			return fieldErrorf(fmt.Sprintf("shadow.servers[%d]", i), "%q is not an IP address", raw)
+		}
+		s.Servers[i] = ip.Unmap().String()
+	}
+	if s.Enabled && len(s.Servers) == 0 {
+		return fieldErrorf("shadow.servers", "is required when shadowing is enabled")
+	}
+	if s.Percent == 0 {
+		s.Percent = 10
+	}
+	if s.Percent < 0 || s.Percent > 100 {
+		return fieldErrorf("shadow.percent", "%v must be above 0 and at most 100", s.Percent)
+	}
+	if s.Timeout == "" {
+		s.Timeout = "2s"
+	}
+	if d, err := time.ParseDuration(s.Timeout); err != nil || d <= 0 {
+		return fieldErrorf("shadow.timeout", "%q must be a positive duration", s.Timeout)
+	}
+	return nil
+}
+
+// normalize applies the advertisement defaults and checks that an enabled
+// advertisement has an http(s) webhook URL.
+func (a *AdvertiseConfig) normalize() error {
+	a.WebhookURL = strings.TrimSpace(a.WebhookURL)
+	if a.WebhookURL != "" {
+		if u, err := url.Parse(a.WebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fieldErrorf("advertise.webhook_url", "%q must be an http or https URL", a.WebhookURL)
+		}
+	}
+	if a.Enabled && a.WebhookURL == "" {
+		return fieldErrorf("advertise.webhook_url", "is required when advertisement is enabled")
+	}
+	for i, name := range a.Interfaces {
+		a.Interfaces[i] = strings.TrimSpace(name)
+		if a.Interfaces[i] == "" {
+			return fieldErrorf(fmt.Sprintf("advertise.interfaces[%d]", i), "must not be empty")
+		}
+	}
+	if a.Interval == "" {
+		a.Interval = "30s"
+	}
+	if d, err := time.ParseDuration(a.Interval); err != nil || d < time.Second {
+		return fieldErrorf("advertise.interval", "%q must be a duration of at least 1s", a.Interval)
+	}
+	for i, raw := range a.ClientSubnets {
+		p, err := parsePrefixOrAddr(raw)
+		if err != nil {
+			return fieldErrorf("advertise.client_subnets", "%q is not an IP address or CIDR prefix", raw)
+		}
+		a.ClientSubnets[i] = p.String()
+	}
+	return nil
+}
+
+// normalize validates slip and applies the default prefix lengths.
+func (r *RateLimitConfig) normalize() error {
+	if r.Slip < 0 {
+		return fieldErrorf("rate_limit.slip", "must be >= 0")
+	}
+	if r.IPv4PrefixLen == 0 {
+		r.IPv4PrefixLen = 24
+	}
+	if r.IPv6PrefixLen == 0 {
+		r.IPv6PrefixLen = 64
+	}
+	if r.IPv4PrefixLen < 1 || r.IPv4PrefixLen > 32 {
+		return fieldErrorf("rate_limit.ipv4_prefix_len", "%d must be 1..32", r.IPv4PrefixLen)
+	}
+	if r.IPv6PrefixLen < 1 || r.IPv6PrefixLen > 128 {
+		return fieldErrorf("rate_limit.ipv6_prefix_len", "%d must be 1..128", r.IPv6PrefixLen)
+	}
+	return nil
+}
+
+// normalize applies cache defaults, validates size, eviction policy, TTLs,
+// and zone overrides, and canonicalizes the bypass domains.
+func (c *CacheConfig) normalize() error {
+	if c.MaxEntries == 0 {
+		c.MaxEntries = DefaultCacheMaxEntries
+	}
+	if c.MaxEntries < 0 {
+		return fieldErrorf("cache.max_entries", "must be positive, got %d", c.MaxEntries)
+	}
+	if c.MaxBytes == 0 {
+		c.MaxBytes = DefaultCacheMaxBytes
+	}
+	if c.MaxEntryBytes == 0 {
+		c.MaxEntryBytes = DefaultCacheMaxEntryBytes
+	}
+	if c.MaxBytes < -1 {
+		return fieldErrorf("cache.max_bytes", "must be positive, or -1 for no limit")
+	}
+	if c.MaxEntryBytes < -1 {
+		return fieldErrorf("cache.max_entry_bytes", "must be positive, or -1 for no limit")
+	}
+	if c.MaxBytes > 0 && c.MaxEntryBytes > c.MaxBytes {
+		return fieldErrorf("cache.max_entry_bytes", "%d must not exceed cache.max_bytes (%d)", c.MaxEntryBytes, c.MaxBytes)
+	}
+	c.EvictionPolicy = strings.ToLower(strings.TrimSpace(c.EvictionPolicy))
+	switch c.EvictionPolicy {
+	case "":
+		c.EvictionPolicy = "lru"
+	case "lru", "lfu", "ttl":
+	default:
+		return fieldErrorf("cache.eviction_policy", "%q must be lru, lfu, or ttl", c.EvictionPolicy)
+	}
+	if c.ServfailTTL == "" {
+		c.ServfailTTL = "30s"
+	}
+	if c.NegativeTTL == "" {
+		c.NegativeTTL = "5m"
+	}
+	if err := validateCacheTTL("cache.servfail_ttl", c.ServfailTTL); err != nil {
+		return err
+	}
+	if err := validateCacheTTL("cache.negative_ttl", c.NegativeTTL); err != nil {
+		return err
+	}
+
+	seen := make(map[string]struct{}, len(c.ZoneOverrides))
+	for i := range c.ZoneOverrides {
+		o := &c.ZoneOverrides[i]
+		if err := o.Normalize(); err != nil {
+			var fe *FieldError
+			if errors.As(err, &fe) {
+				return fieldErrorf("cache.zone_overrides["+o.Zone+"]."+fe.Field, "%w", fe.Err)
+			}
+			return fieldErrorf("cache.zone_overrides", "%w", err)
+		}
+		if _, dup := seen[o.Zone]; dup {
+			return fieldErrorf("cache.zone_overrides", "duplicate zone %q", o.Zone)
+		}
+		seen[o.Zone] = struct{}{}
+	}
+
+	bypass := make([]string, 0, len(c.BypassDomains))
+	for _, d := range c.BypassDomains {
+		name, err := dns.CanonicalName(d)
+		if err == nil && name != "" {
+			_, err = dns.EncodeName(name)
+		}
+		if err != nil || name == "" {
+			return fieldErrorf("cache.bypass_domains", "invalid domain %q", d)
+		}
+		if !slices.Contains(bypass, name) {
+			bypass = append(bypass, name)
+		}
+	}
+	if len(bypass) == 0 {
+		bypass = nil
+	}
+	c.BypassDomains = bypass
+	return c.Shared.normalize()
+}
+
+// Normalize canonicalizes the zone of a cache override and checks its TTLs.
+func (o *CacheZoneOverride) Normalize() error {
+	o.Zone = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(o.Zone)), ".")
+	if o.Zone == "" {
+		return errors.New("zone must not be empty")
+	}
+	if o.ServfailTTL != "" {
+		if err := validateCacheTTL("servfail_ttl", o.ServfailTTL); err != nil {
+			return err
+		}
+	}
+	if o.NegativeTTL != "" {
+		if err := validateCacheTTL("negative_ttl", o.NegativeTTL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// normalize validates the shared cache settings and applies defaults when
+// a backend is set.
+func (s *SharedCacheConfig) normalize() error {
+	s.Backend = strings.ToLower(strings.TrimSpace(s.Backend))
+	switch s.Backend {
+	case "":
+		return nil
+	case SharedCacheRedis:
+	case SharedCacheMemcached:
+		if s.Password != "" {
+			return fieldErrorf("cache.shared.password", "is not supported by memcached")
+		}
+	default:
+		return fieldErrorf("cache.shared.backend", "%q must be redis or memcached", s.Backend)
+	}
+	s.Address = strings.TrimSpace(s.Address)
+	if _, port, err := net.SplitHostPort(s.Address); err != nil || port == "" {
+		return fieldErrorf("cache.shared.address", "%q must be host:port", s.Address)
+	}
+	if s.Timeout == "" {
+		s.Timeout = "50ms"
+	}
+	if d, err := time.ParseDuration(s.Timeout); err != nil || d <= 0 {
+		return fieldErrorf("cache.shared.timeout", "%q must be a positive duration", s.Timeout)
+	}
+	if s.KeyPrefix == "" {
+		s.KeyPrefix = "hydradns"
+	}
+	if len(s.KeyPrefix) > 64 {
+		return fieldErrorf("cache.shared.key_prefix", "%q must be at most 64 characters", s.KeyPrefix)
+	}
+	// Keys go on the wire unquoted; memcached keys end at whitespace
+	if strings.IndexFunc(s.KeyPrefix, func(r rune) bool { return r <= ' ' || r == 0x7f }) >= 0 {
+		return fieldErrorf("cache.shared.key_prefix", "%q must not contain spaces or control characters", s.KeyPrefix)
+	}
+	return nil
+}
+
+// validateCacheTTL checks that raw is a duration in [0, MaxNegativeCacheTTL].
+func validateCacheTTL(field, raw string) error {
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		return fieldErrorf(field, "invalid duration %q", raw)
+	}
+	if d < 0 || d > MaxNegativeCacheTTL {
+		return fieldErrorf(field, "must be between 0s and %s", MaxNegativeCacheTTL)
+	}
+	return nil
+}
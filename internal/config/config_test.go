@@ -1,6 +1,8 @@
 package config_test
 
 import (
+	"net/netip"
+	"strings"
 	"testing"
 
 	"github.com/jroosing/hydradns/internal/config"
@@ -132,6 +134,77 @@ func TestValidate_InvalidAPIPort(t *testing.T) {
 	assert.Error(t, err, "API port 0 should be invalid when API enabled")
 }
 
+func TestValidate_AggregatesFieldErrors(t *testing.T) {
+	cfg := newConfig()
+	cfg.Server.Port = 0
+	cfg.Upstream.UDPTimeout = "soon"
+	cfg.Upstream.Servers = []string{"9.9.9.9", "dns.google"}
+
+	err := cfg.Validate()
+	var verr *config.ValidationError
+	require.ErrorAs(t, err, &verr)
+
+	fields := make([]string, len(verr.Problems))
+	for i, p := range verr.Problems {
+		fields[i] = p.Field
+	}
+	assert.ElementsMatch(t, []string{"server.port", "upstream.servers[1]", "upstream.udp_timeout"}, fields)
+	assert.Contains(t, err.Error(), `upstream.udp_timeout: invalid duration "soon"`)
+
+	var ferr *config.FieldError
+	require.ErrorAs(t, err, &ferr, "problems are reachable with errors.As")
+}
+
+func TestValidate_UpstreamServersMustBeIPs(t *testing.T) {
+	cfg := newConfig()
+	cfg.Upstream.Servers = []string{" ::ffff:1.1.1.1 ", "2606:4700:4700:0:0:0:0:1111"}
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, []string{"1.1.1.1", "2606:4700:4700::1111"}, cfg.Upstream.Servers)
+
+	cfg.Upstream.Servers = []string{"https://dns.example"}
+	assert.ErrorContains(t, cfg.Validate(), "upstream.servers[0]")
+}
+
+func TestValidate_Cluster(t *testing.T) {
+	tests := map[string]struct {
+		cluster config.ClusterConfig
+		field   string
+	}{
+		"unknown mode":       {config.ClusterConfig{Mode: "leader"}, "cluster.mode"},
+		"no primary url":     {config.ClusterConfig{Mode: config.ClusterModeSecondary, SharedSecret: "s"}, "cluster.primary_url"},
+		"bad primary url":    {config.ClusterConfig{Mode: config.ClusterModeSecondary, PrimaryURL: "primary:8080", SharedSecret: "s"}, "cluster.primary_url"},
+		"no shared secret":   {config.ClusterConfig{Mode: config.ClusterModeSecondary, PrimaryURL: "http://primary:8080"}, "cluster.shared_secret"},
+		"bad sync interval":  {config.ClusterConfig{Mode: config.ClusterModePrimary, SyncInterval: "often"}, "cluster.sync_interval"},
+		"negative sync time": {config.ClusterConfig{Mode: config.ClusterModePrimary, SyncTimeout: "-1s"}, "cluster.sync_timeout"},
+	}
+	for name, tt := range tests {
+		cfg := newConfig()
+		cfg.Cluster = tt.cluster
+		assert.ErrorContains(t, cfg.Validate(), tt.field+":", name)
+	}
+
+	cfg := newConfig()
+	cfg.Cluster = config.ClusterConfig{Mode: config.ClusterModeSecondary, PrimaryURL: "https://primary:8080", SharedSecret: "s"}
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestValidate_OverlappingListeners(t *testing.T) {
+	cfg := newConfig()
+	cfg.API.Port = 53
+	assert.ErrorContains(t, cfg.Validate(), "api.port: 53 is already used by the DNS listener")
+
+	cfg.API.Host = "127.0.0.1"
+	assert.Error(t, cfg.Validate(), "the DNS listener binds every address")
+
+	cfg.Server.Host = "192.0.2.1"
+	assert.NoError(t, cfg.Validate(), "different hosts")
+
+	cfg = newConfig()
+	cfg.API.Port = 53
+	cfg.Server.EnableTCP = false
+	assert.NoError(t, cfg.Validate(), "the API only competes with the TCP listener")
+}
+
 func TestValidate_EmptyUpstreamServers(t *testing.T) {
 	cfg := newConfig()
 	cfg.Upstream.Servers = []string{}
@@ -148,6 +221,33 @@ func TestValidate_LimitsUpstreamTo3(t *testing.T) {
 	assert.Len(t, cfg.Upstream.Servers, 3, "Should limit to 3 upstream servers")
 }
 
+func TestValidate_RateLimitPrefixLengths(t *testing.T) {
+	cfg := newConfig()
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, 24, cfg.RateLimit.IPv4PrefixLen, "Should default to /24")
+	assert.Equal(t, 64, cfg.RateLimit.IPv6PrefixLen, "Should default to /64")
+
+	cfg.RateLimit.IPv4PrefixLen = 33
+	assert.Error(t, cfg.Validate())
+
+	cfg = newConfig()
+	cfg.RateLimit.IPv6PrefixLen = -1
+	assert.Error(t, cfg.Validate())
+}
+
+func TestValidate_UpstreamStrategy(t *testing.T) {
+	cfg := newConfig()
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, "sequential", cfg.Upstream.Strategy, "Should default to sequential")
+
+	cfg.Upstream.Strategy = " Round_Robin "
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, "round_robin", cfg.Upstream.Strategy)
+
+	cfg.Upstream.Strategy = "fastest"
+	assert.Error(t, cfg.Validate())
+}
+
 func TestValidate_NormalizesLogLevel(t *testing.T) {
 	cfg := newConfig()
 	cfg.Logging.Level = "debug"
@@ -173,6 +273,665 @@ func TestValidate_WorkersAutoDefault(t *testing.T) {
 	assert.Equal(t, config.WorkersAuto, cfg.Server.Workers.Mode)
 }
 
+func TestValidate_CacheDefaults(t *testing.T) {
+	cfg := newConfig()
+	require.NoError(t, cfg.Validate())
+	assert.False(t, cfg.Cache.DisableNegative)
+	assert.Equal(t, "30s", cfg.Cache.ServfailTTL)
+	assert.Equal(t, "5m", cfg.Cache.NegativeTTL)
+	assert.Equal(t, config.DefaultCacheMaxEntries, cfg.Cache.MaxEntries)
+	assert.Equal(t, "lru", cfg.Cache.EvictionPolicy)
+	assert.Equal(t, config.DefaultCacheMaxBytes, cfg.Cache.MaxBytes)
+	assert.Equal(t, config.DefaultCacheMaxEntryBytes, cfg.Cache.MaxEntryBytes)
+}
+
+func TestValidate_CacheByteLimits(t *testing.T) {
+	cfg := newConfig()
+	cfg.Cache.MaxBytes = -1
+	cfg.Cache.MaxEntryBytes = -1
+	require.NoError(t, cfg.Validate(), "-1 means no limit")
+
+	cfg = newConfig()
+	cfg.Cache.MaxBytes = 1024
+	cfg.Cache.MaxEntryBytes = 4096
+	assert.Error(t, cfg.Validate(), "an entry may not exceed the total budget")
+
+	cfg = newConfig()
+	cfg.Cache.MaxBytes = -2
+	assert.Error(t, cfg.Validate())
+}
+
+func TestValidate_CacheEvictionPolicy(t *testing.T) {
+	cfg := newConfig()
+	cfg.Cache.EvictionPolicy = " LFU "
+	cfg.Cache.MaxEntries = 500
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, "lfu", cfg.Cache.EvictionPolicy)
+	assert.Equal(t, 500, cfg.Cache.MaxEntries)
+
+	cfg = newConfig()
+	cfg.Cache.EvictionPolicy = "random"
+	assert.Error(t, cfg.Validate())
+
+	cfg = newConfig()
+	cfg.Cache.MaxEntries = -1
+	assert.Error(t, cfg.Validate())
+}
+
+func TestValidate_CacheAllowsZeroTTL(t *testing.T) {
+	cfg := newConfig()
+	cfg.Cache.ServfailTTL = "0s"
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, "0s", cfg.Cache.ServfailTTL)
+}
+
+func TestValidate_CacheRejectsInvalidTTL(t *testing.T) {
+	for _, ttl := range []string{"soon", "-1s", "2h"} {
+		cfg := newConfig()
+		cfg.Cache.NegativeTTL = ttl
+		assert.Error(t, cfg.Validate(), "negative_ttl %q should be rejected", ttl)
+	}
+}
+
+func TestValidate_CacheBypassDomains(t *testing.T) {
+	cfg := newConfig()
+	cfg.Cache.BypassDomains = []string{" Dyn.Example. ", "dyn.example", "health.corp.example"}
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, []string{"dyn.example", "health.corp.example"}, cfg.Cache.BypassDomains)
+
+	cfg = newConfig()
+	cfg.Cache.BypassDomains = []string{""}
+	assert.Error(t, cfg.Validate(), "empty domain should be rejected")
+
+	cfg = newConfig()
+	cfg.Cache.BypassDomains = []string{"bad..example"}
+	assert.Error(t, cfg.Validate())
+}
+
+func TestValidate_CacheZoneOverrides(t *testing.T) {
+	cfg := newConfig()
+	cfg.Cache.ZoneOverrides = []config.CacheZoneOverride{{Zone: " Corp.Example. ", ServfailTTL: "0s"}}
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, "corp.example", cfg.Cache.ZoneOverrides[0].Zone, "zone should be normalized")
+
+	cfg = newConfig()
+	cfg.Cache.ZoneOverrides = []config.CacheZoneOverride{{Zone: "a.example"}, {Zone: "A.example."}}
+	assert.Error(t, cfg.Validate(), "duplicate zones should be rejected")
+
+	cfg = newConfig()
+	cfg.Cache.ZoneOverrides = []config.CacheZoneOverride{{Zone: ""}}
+	assert.Error(t, cfg.Validate(), "empty zone should be rejected")
+
+	cfg = newConfig()
+	cfg.Cache.ZoneOverrides = []config.CacheZoneOverride{{Zone: "a.example", NegativeTTL: "forever"}}
+	assert.Error(t, cfg.Validate(), "invalid zone TTL should be rejected")
+}
+
+func TestValidate_CacheShared(t *testing.T) {
+	cfg := newConfig()
+	cfg.Cache.Shared = config.SharedCacheConfig{Backend: " Redis ", Address: "10.0.0.5:6379"}
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, config.SharedCacheConfig{
+		Backend:   config.SharedCacheRedis,
+		Address:   "10.0.0.5:6379",
+		Timeout:   "50ms",
+		KeyPrefix: "hydradns",
+	}, cfg.Cache.Shared)
+
+	cfg = newConfig()
+	cfg.Cache.Shared = config.SharedCacheConfig{Address: "ignored"}
+	require.NoError(t, cfg.Validate(), "settings without a backend are unused")
+
+	invalid := []config.SharedCacheConfig{
+		{Backend: "etcd", Address: "10.0.0.5:2379"},
+		{Backend: "redis"},
+		{Backend: "redis", Address: "10.0.0.5"},
+		{Backend: "redis", Address: "10.0.0.5:6379", Timeout: "0s"},
+		{Backend: "memcached", Address: "10.0.0.5:11211", KeyPrefix: "has space"},
+		{Backend: "memcached", Address: "10.0.0.5:11211", Password: "s3cret"},
+	}
+	for _, shared := range invalid {
+		cfg = newConfig()
+		cfg.Cache.Shared = shared
+		assert.Error(t, cfg.Validate(), "%+v should be rejected", shared)
+	}
+}
+
+func TestValidate_EDNSOptions(t *testing.T) {
+	cfg := newConfig()
+	cfg.Upstream.EDNSOptions = []config.EDNSOptionRule{
+		{Option: " Cookie ", Action: "STRIP"},
+		{Option: "ecs", Action: "rewrite", Value: "192.0.2.0/24"},
+		{Option: "65001", Action: "rewrite", Value: "beef"},
+		{Option: "padding", Action: "pass"},
+	}
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, config.EDNSOptionRule{Option: "cookie", Action: "strip"}, cfg.Upstream.EDNSOptions[0])
+
+	code, err := cfg.Upstream.EDNSOptions[2].Code()
+	require.NoError(t, err)
+	assert.Equal(t, uint16(65001), code)
+	data, err := cfg.Upstream.EDNSOptions[1].RewriteData()
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0, 1, 24, 0, 192, 0, 2}, data)
+}
+
+func TestValidate_EDNSOptionsRejectsInvalid(t *testing.T) {
+	tests := map[string][]config.EDNSOptionRule{
+		"unknown option": {{Option: "nsid2", Action: "strip"}},
+		"code too large": {{Option: "70000", Action: "strip"}},
+		"unknown action": {{Option: "ecs", Action: "drop"}},
+		"bad ecs value":  {{Option: "ecs", Action: "rewrite", Value: "192.0.2.1"}},
+		"bad hex value":  {{Option: "cookie", Action: "rewrite", Value: "xyz"}},
+		"duplicate code": {{Option: "ecs", Action: "strip"}, {Option: "8", Action: "pass"}},
+		"missing action": {{Option: "ecs"}},
+	}
+	for name, rules := range tests {
+		cfg := newConfig()
+		cfg.Upstream.EDNSOptions = rules
+		assert.Error(t, cfg.Validate(), name)
+	}
+}
+
+func TestValidate_BlockPageDefaults(t *testing.T) {
+	cfg := newConfig()
+	require.NoError(t, cfg.Validate(), "disabled block page needs no addresses")
+	assert.Equal(t, ":80", cfg.BlockPage.HTTPAddr)
+	assert.Equal(t, ":443", cfg.BlockPage.HTTPSAddr)
+
+	cfg = newConfig()
+	cfg.BlockPage = config.BlockPageConfig{Enabled: true, IPv6: "fd00::53"}
+	require.NoError(t, cfg.Validate())
+}
+
+func TestValidate_BlockPageRejectsInvalid(t *testing.T) {
+	tests := map[string]config.BlockPageConfig{
+		"no address":   {Enabled: true},
+		"ipv6 as ipv4": {Enabled: true, IPv4: "fd00::53"},
+		"ipv4 as ipv6": {Enabled: true, IPv6: "192.168.1.53"},
+		"bad address":  {Enabled: true, IPv4: "block.lan"},
+		"cert only":    {Enabled: true, IPv4: "192.168.1.53", TLSCert: "cert.pem"},
+		"mapped ipv4":  {Enabled: true, IPv6: "::ffff:192.168.1.53"},
+	}
+	for name, bp := range tests {
+		cfg := newConfig()
+		cfg.BlockPage = bp
+		assert.Error(t, cfg.Validate(), name)
+	}
+}
+
+func TestValidate_CaptivePortal(t *testing.T) {
+	cfg := newConfig()
+	cfg.CaptivePortal = config.CaptivePortalConfig{
+		Enabled:      true,
+		Clients:      []string{"10.20.0.0/16", " 10.30.0.7 "},
+		IPv4:         "10.20.0.1",
+		AllowDomains: []string{"Portal.Example.NET."},
+	}
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, []string{"10.20.0.0/16", "10.30.0.7/32"}, cfg.CaptivePortal.Clients)
+	assert.Equal(t, []string{"portal.example.net"}, cfg.CaptivePortal.AllowDomains)
+	assert.Equal(t, config.DefaultCaptivePortalTTL, cfg.CaptivePortal.TTL)
+	assert.Len(t, cfg.CaptivePortal.ClientPrefixes(), 2)
+}
+
+func TestValidate_CaptivePortalRejectsInvalid(t *testing.T) {
+	tests := map[string]struct {
+		portal config.CaptivePortalConfig
+		field  string
+	}{
+		"no clients":   {config.CaptivePortalConfig{Enabled: true, IPv4: "10.20.0.1"}, "captive_portal.clients"},
+		"bad client":   {config.CaptivePortalConfig{Clients: []string{"guests"}}, "captive_portal.clients[0]"},
+		"no address":   {config.CaptivePortalConfig{Enabled: true, Clients: []string{"10.20.0.0/16"}}, "captive_portal"},
+		"ipv6 as ipv4": {config.CaptivePortalConfig{Enabled: true, Clients: []string{"10.20.0.0/16"}, IPv4: "fd00::1"}, "captive_portal.ipv4"},
+		"bad domain":   {config.CaptivePortalConfig{AllowDomains: []string{"bad..name"}}, "captive_portal.allow_domains[0]"},
+		"negative ttl": {config.CaptivePortalConfig{TTL: -1}, "captive_portal.ttl"},
+	}
+	for name, tt := range tests {
+		cfg := newConfig()
+		cfg.CaptivePortal = tt.portal
+		assert.ErrorContains(t, cfg.Validate(), tt.field+":", name)
+	}
+}
+
+func TestValidate_FilterAAAA(t *testing.T) {
+	cfg := newConfig()
+	cfg.FilterAAAA = config.FilterAAAAConfig{Enabled: true, Clients: []string{" 10.30.0.0/16", "fd00::7"}}
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, []string{"10.30.0.0/16", "fd00::7/128"}, cfg.FilterAAAA.Clients)
+	assert.Len(t, cfg.FilterAAAA.ClientPrefixes(), 2)
+
+	cfg = newConfig()
+	cfg.FilterAAAA = config.FilterAAAAConfig{Enabled: true, Clients: []string{"10.30.0.0/16", "iot"}}
+	assert.ErrorContains(t, cfg.Validate(), "filter_aaaa.clients[1]:")
+}
+
+func TestValidate_DDR(t *testing.T) {
+	cfg := newConfig()
+	cfg.DDR = config.DDRConfig{
+		Enabled:   true,
+		Target:    "DNS.Example.NET.",
+		DoHPort:   443,
+		IPv4Hints: []string{" 192.0.2.53 "},
+		IPv6Hints: []string{"2001:db8::53"},
+	}
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, "dns.example.net", cfg.DDR.Target)
+	assert.Equal(t, config.DefaultDoHPath, cfg.DDR.DoHPath)
+	assert.Equal(t, config.DefaultDDRTTL, cfg.DDR.TTL)
+	ipv4, ipv6 := cfg.DDR.Hints()
+	assert.Len(t, ipv4, 1)
+	assert.Len(t, ipv6, 1)
+}
+
+func TestValidate_DDRRejectsInvalid(t *testing.T) {
+	tests := map[string]struct {
+		ddr   config.DDRConfig
+		field string
+	}{
+		"no target":    {config.DDRConfig{Enabled: true, DoTPort: 853}, "ddr.target"},
+		"bad target":   {config.DDRConfig{Target: "bad..name"}, "ddr.target"},
+		"no ports":     {config.DDRConfig{Enabled: true, Target: "dns.example.net"}, "ddr"},
+		"bad port":     {config.DDRConfig{DoTPort: 70000}, "ddr.dot_port"},
+		"bad path":     {config.DDRConfig{DoHPath: "/dns-query"}, "ddr.doh_path"},
+		"ipv6 as ipv4": {config.DDRConfig{IPv4Hints: []string{"fd00::1"}}, "ddr.ipv4_hints[0]"},
+		"negative ttl": {config.DDRConfig{TTL: -1}, "ddr.ttl"},
+	}
+	for name, tt := range tests {
+		cfg := newConfig()
+		cfg.DDR = tt.ddr
+		assert.ErrorContains(t, cfg.Validate(), tt.field+":", name)
+	}
+}
+
+func TestValidate_ClusterDiscovery(t *testing.T) {
+	cfg := newConfig()
+	cfg.ClusterDiscovery = config.ClusterDiscoveryConfig{
+		Enabled: true,
+		Zone:    "Cluster.Home.ARPA.",
+		Address: " ::ffff:192.0.2.53",
+	}
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, "cluster.home.arpa", cfg.ClusterDiscovery.Zone)
+	assert.Equal(t, "192.0.2.53", cfg.ClusterDiscovery.Address)
+	assert.Equal(t, config.DefaultClusterDiscoveryTTL, cfg.ClusterDiscovery.TTL)
+	assert.Equal(t, config.DefaultClusterMemberTimeout, cfg.ClusterDiscovery.MemberTimeout)
+
+	tests := map[string]struct {
+		discovery config.ClusterDiscoveryConfig
+		field     string
+	}{
+		"no zone":        {config.ClusterDiscoveryConfig{Enabled: true}, "cluster_discovery.zone"},
+		"bad zone":       {config.ClusterDiscoveryConfig{Zone: "bad..zone"}, "cluster_discovery.zone"},
+		"bad address":    {config.ClusterDiscoveryConfig{Address: "node1"}, "cluster_discovery.address"},
+		"negative ttl":   {config.ClusterDiscoveryConfig{TTL: -1}, "cluster_discovery.ttl"},
+		"negative limit": {config.ClusterDiscoveryConfig{MemberTimeout: -1}, "cluster_discovery.member_timeout"},
+	}
+	for name, tt := range tests {
+		cfg := newConfig()
+		cfg.ClusterDiscovery = tt.discovery
+		assert.ErrorContains(t, cfg.Validate(), tt.field+":", name)
+	}
+}
+
+func TestValidate_SynthPTR(t *testing.T) {
+	cfg := newConfig()
+	cfg.SynthPTR = config.SynthPTRConfig{
+		Enabled:  true,
+		Prefixes: []string{" 2001:db8:1::/64"},
+		Template: "{ip}.LAN.home.arpa.",
+	}
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, []string{"2001:db8:1::/64"}, cfg.SynthPTR.Prefixes)
+	assert.Equal(t, "{ip}.lan.home.arpa", cfg.SynthPTR.Template)
+	assert.Equal(t, config.DefaultSynthPTRTTL, cfg.SynthPTR.TTL)
+	assert.Len(t, cfg.SynthPTR.PrefixList(), 1)
+
+	tests := map[string]struct {
+		synth config.SynthPTRConfig
+		field string
+	}{
+		"no prefixes":    {config.SynthPTRConfig{Enabled: true, Template: "{ip}.lan"}, "synth_ptr.prefixes"},
+		"ipv4 prefix":    {config.SynthPTRConfig{Prefixes: []string{"10.0.0.0/8"}}, "synth_ptr.prefixes[0]"},
+		"no template":    {config.SynthPTRConfig{Enabled: true, Prefixes: []string{"fd00::/64"}}, "synth_ptr.template"},
+		"no placeholder": {config.SynthPTRConfig{Template: "host.lan"}, "synth_ptr.template"},
+		"invalid name":   {config.SynthPTRConfig{Template: "{ip}..lan"}, "synth_ptr.template"},
+		"negative ttl":   {config.SynthPTRConfig{TTL: -1}, "synth_ptr.ttl"},
+	}
+	for name, tt := range tests {
+		cfg := newConfig()
+		cfg.SynthPTR = tt.synth
+		assert.ErrorContains(t, cfg.Validate(), tt.field+":", name)
+	}
+}
+
+func TestValidate_FilteringBlockTTLs(t *testing.T) {
+	cfg := newConfig()
+	cfg.Filtering.NegativeTTL = 300
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, config.DefaultBlockTTL, cfg.Filtering.BlockTTL)
+	assert.Equal(t, 300, cfg.Filtering.NegativeTTL)
+
+	cfg = newConfig()
+	cfg.Filtering.BlockTTL = -1
+	assert.ErrorContains(t, cfg.Validate(), "filtering.block_ttl:")
+}
+
+func TestValidate_Privacy(t *testing.T) {
+	cfg := newConfig()
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, config.PrivacyConfig{Level: "full", Anonymize: "truncate"}, cfg.Privacy)
+
+	cfg = newConfig()
+	cfg.Privacy = config.PrivacyConfig{Level: " Anonymized", Anonymize: "HASH"}
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, config.PrivacyConfig{Level: "anonymized", Anonymize: "hash"}, cfg.Privacy)
+
+	cfg = newConfig()
+	cfg.Privacy.Level = "partial"
+	assert.ErrorContains(t, cfg.Validate(), "privacy.level:")
+}
+
+func TestValidate_GeoIPNormalizesCountries(t *testing.T) {
+	cfg := newConfig()
+	cfg.GeoIP = config.GeoIPConfig{CountryDB: "country.mmdb", BlockCountries: []string{"nl", " De "}}
+
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, []string{"NL", "DE"}, cfg.GeoIP.BlockCountries)
+}
+
+func TestValidate_GeoIPRejectsInvalid(t *testing.T) {
+	tests := map[string]config.GeoIPConfig{
+		"bad country":        {CountryDB: "country.mmdb", BlockCountries: []string{"NLD"}},
+		"countries no db":    {ASNDB: "asn.mmdb", BlockCountries: []string{"NL"}},
+		"asn zero":           {ASNDB: "asn.mmdb", BlockASNs: []uint32{0}},
+		"asns no db":         {CountryDB: "country.mmdb", BlockASNs: []uint32{64500}},
+		"non-letter country": {CountryDB: "country.mmdb", BlockCountries: []string{"N1"}},
+	}
+	for name, g := range tests {
+		cfg := newConfig()
+		cfg.GeoIP = g
+		assert.Error(t, cfg.Validate(), name)
+	}
+}
+
+func TestValidate_AnomalyDefaults(t *testing.T) {
+	cfg := newConfig()
+	require.NoError(t, cfg.Validate())
+
+	assert.Equal(t, config.AnomalyConfig{
+		Window:          "1m",
+		MinQueries:      50,
+		NXDOMAINRatio:   0.5,
+		TXTRatio:        0.5,
+		MaxLabelLength:  50,
+		MinLabelEntropy: 4.0,
+	}, cfg.Anomaly)
+}
+
+func TestValidate_AnomalyRejectsInvalid(t *testing.T) {
+	tests := map[string]config.AnomalyConfig{
+		"bad window":       {Window: "soon"},
+		"short window":     {Window: "10ms"},
+		"negative queries": {MinQueries: -1},
+		"ratio above 1":    {NXDOMAINRatio: 1.5},
+		"negative ratio":   {TXTRatio: -0.1},
+		"label too long":   {MaxLabelLength: 64},
+		"negative entropy": {MinLabelEntropy: -1},
+	}
+	for name, a := range tests {
+		cfg := newConfig()
+		cfg.Anomaly = a
+		assert.Error(t, cfg.Validate(), name)
+	}
+}
+
+func TestValidate_ThreatIntelDefaults(t *testing.T) {
+	cfg := newConfig()
+	require.NoError(t, cfg.Validate())
+
+	assert.Equal(t, config.ThreatIntelConfig{
+		Provider:  "urlhaus",
+		BlockTTL:  "24h",
+		CacheTTL:  "6h",
+		RateLimit: 1,
+	}, cfg.ThreatIntel)
+}
+
+func TestValidate_ThreatIntelRejectsInvalid(t *testing.T) {
+	tests := map[string]config.ThreatIntelConfig{
+		"unknown provider":    {Provider: "virustotal"},
+		"bad url":             {URL: "ftp://example.com"},
+		"bad block ttl":       {BlockTTL: "forever"},
+		"short cache ttl":     {CacheTTL: "5s"},
+		"cache > block ttl":   {BlockTTL: "1h", CacheTTL: "2h"},
+		"negative rate limit": {RateLimit: -1},
+	}
+	for name, ti := range tests {
+		cfg := newConfig()
+		cfg.ThreatIntel = ti
+		assert.Error(t, cfg.Validate(), name)
+	}
+}
+
+func TestValidate_SearchDomains(t *testing.T) {
+	cfg := newConfig()
+	cfg.CustomDNS.SearchDomains = []string{"Home.Arpa.", "lan", "home.arpa"}
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, []string{"home.arpa", "lan"}, cfg.CustomDNS.SearchDomains)
+
+	cfg = newConfig()
+	cfg.CustomDNS.SearchDomains = []string{"bad..name"}
+	assert.Error(t, cfg.Validate())
+}
+
+func TestValidate_AnswerOrder(t *testing.T) {
+	cfg := newConfig()
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, "fixed", cfg.CustomDNS.AnswerOrder)
+
+	cfg = newConfig()
+	cfg.CustomDNS.AnswerOrder = " Round_Robin "
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, "round_robin", cfg.CustomDNS.AnswerOrder)
+
+	cfg = newConfig()
+	cfg.CustomDNS.AnswerOrder = "weighted"
+	assert.Error(t, cfg.Validate())
+}
+
+func TestValidate_RecordHealthChecks(t *testing.T) {
+	cfg := newConfig()
+	cfg.CustomDNS.HealthChecks = []config.RecordHealthCheck{
+		{Name: "Web.Lan.", Type: "HTTP", Port: 8080},
+		{Name: "db.lan", Type: "tcp", Port: 5432, Path: "/ignored", Interval: "30s", Failures: 5},
+	}
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, []config.RecordHealthCheck{
+		{Name: "web.lan", Type: "http", Port: 8080, Path: "/", Interval: "10s", Timeout: "2s", Failures: 2},
+		{Name: "db.lan", Type: "tcp", Port: 5432, Interval: "30s", Timeout: "2s", Failures: 5},
+	}, cfg.CustomDNS.HealthChecks)
+}
+
+func TestValidate_Steering(t *testing.T) {
+	cfg := newConfig()
+	cfg.CustomDNS.Steering = []config.HostSteering{{
+		Name: "App.Lan.",
+		Addrs: []config.SteeredAddr{
+			{Addr: " 10.0.1.5", Weight: 3, Clients: []string{"192.168.1.77/24", "::ffff:10.9.9.9"}},
+			{Addr: "::ffff:10.0.2.5"},
+		},
+	}}
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, []config.HostSteering{{
+		Name: "app.lan",
+		Addrs: []config.SteeredAddr{
+			{Addr: "10.0.1.5", Weight: 3, Clients: []string{"192.168.1.0/24", "10.9.9.9/32"}},
+			{Addr: "10.0.2.5"},
+		},
+	}}, cfg.CustomDNS.Steering)
+
+	for name, s := range map[string][]config.HostSteering{
+		"no addrs":        {{Name: "app.lan"}},
+		"bad addr":        {{Name: "app.lan", Addrs: []config.SteeredAddr{{Addr: "app"}}}},
+		"negative weight": {{Name: "app.lan", Addrs: []config.SteeredAddr{{Addr: "10.0.1.5", Weight: -1}}}},
+		"bad client":      {{Name: "app.lan", Addrs: []config.SteeredAddr{{Addr: "10.0.1.5", Clients: []string{"lan"}}}}},
+		"duplicate addr":  {{Name: "app.lan", Addrs: []config.SteeredAddr{{Addr: "10.0.1.5"}, {Addr: "::ffff:10.0.1.5"}}}},
+		"duplicate name": {
+			{Name: "app.lan", Addrs: []config.SteeredAddr{{Addr: "10.0.1.5"}}},
+			{Name: "APP.lan", Addrs: []config.SteeredAddr{{Addr: "10.0.2.5"}}},
+		},
+	} {
+		cfg := newConfig()
+		cfg.CustomDNS.Steering = s
+		assert.Error(t, cfg.Validate(), name)
+	}
+}
+
+func TestValidate_RecordHealthChecksRejectsInvalid(t *testing.T) {
+	for name, checks := range map[string][]config.RecordHealthCheck{
+		"unknown type":   {{Name: "web.lan", Type: "icmp", Port: 80}},
+		"no port":        {{Name: "web.lan", Type: "tcp"}},
+		"port too large": {{Name: "web.lan", Type: "tcp", Port: 70000}},
+		"relative path":  {{Name: "web.lan", Type: "http", Port: 80, Path: "healthz"}},
+		"short interval": {{Name: "web.lan", Type: "tcp", Port: 80, Interval: "500ms"}},
+		"bad timeout":    {{Name: "web.lan", Type: "tcp", Port: 80, Timeout: "soon"}},
+		"empty name":     {{Type: "tcp", Port: 80}},
+		"duplicate": {
+			{Name: "web.lan", Type: "tcp", Port: 80},
+			{Name: "WEB.lan.", Type: "tcp", Port: 443},
+		},
+	} {
+		cfg := newConfig()
+		cfg.CustomDNS.HealthChecks = checks
+		assert.Error(t, cfg.Validate(), name)
+	}
+}
+
+func TestValidate_NRD(t *testing.T) {
+	cfg := newConfig()
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, config.NRDConfig{Action: "flag", Days: 30}, cfg.NRD)
+
+	cfg = newConfig()
+	cfg.NRD = config.NRDConfig{Enabled: true, Action: " Block "}
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, "block", cfg.NRD.Action)
+
+	for name, n := range map[string]config.NRDConfig{
+		"unknown action": {Action: "quarantine"},
+		"negative days":  {Days: -1},
+	} {
+		cfg := newConfig()
+		cfg.NRD = n
+		assert.Error(t, cfg.Validate(), name)
+	}
+}
+
+func TestValidate_AuditRequiresPath(t *testing.T) {
+	cfg := newConfig()
+	cfg.Audit = config.AuditConfig{Enabled: true, Path: "  "}
+	require.Error(t, cfg.Validate())
+
+	cfg = newConfig()
+	cfg.Audit = config.AuditConfig{Enabled: true, Path: " /var/log/hydradns/upstream.log "}
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, "/var/log/hydradns/upstream.log", cfg.Audit.Path)
+}
+
+func TestValidate_Canary(t *testing.T) {
+	cfg := newConfig()
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, config.CanaryConfig{
+		Domains:          []string{"example.com", "iana.org"},
+		Interval:         "30s",
+		Timeout:          "5s",
+		FailureThreshold: 3,
+	}, cfg.Canary)
+
+	cfg = newConfig()
+	cfg.Canary.Domains = []string{"Health.Example.", "health.example", "iana.org"}
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, []string{"health.example", "iana.org"}, cfg.Canary.Domains)
+
+	for name, c := range map[string]config.CanaryConfig{
+		"short interval":    {Interval: "500ms"},
+		"bad timeout":       {Timeout: "soon"},
+		"zero timeout":      {Timeout: "0s"},
+		"negative failures": {FailureThreshold: -1},
+		"invalid domain":    {Domains: []string{"bad..name"}},
+	} {
+		cfg := newConfig()
+		cfg.Canary = c
+		assert.Error(t, cfg.Validate(), name)
+	}
+}
+
+func TestValidate_Shadow(t *testing.T) {
+	cfg := newConfig()
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, config.ShadowConfig{Percent: 10, Timeout: "2s"}, cfg.Shadow)
+
+	cfg = newConfig()
+	cfg.Shadow = config.ShadowConfig{Enabled: true, Servers: []string{" 9.9.9.9", "::ffff:1.1.1.1"}, Percent: 2.5}
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, []string{"9.9.9.9", "1.1.1.1"}, cfg.Shadow.Servers)
+	assert.InDelta(t, 2.5, cfg.Shadow.Percent, 1e-9)
+
+	for name, s := range map[string]config.ShadowConfig{
+		"no servers":       {Enabled: true},
+		"hostname server":  {Servers: []string{"dns.quad9.net"}},
+		"negative percent": {Percent: -1},
+		"over 100 percent": {Percent: 150},
+		"zero timeout":     {Timeout: "0s"},
+	} {
+		cfg := newConfig()
+		cfg.Shadow = s
+		assert.Error(t, cfg.Validate(), name)
+	}
+}
+
+func TestValidate_Advertise(t *testing.T) {
+	cfg := newConfig()
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, config.AdvertiseConfig{Interval: "30s"}, cfg.Advertise)
+
+	cfg = newConfig()
+	cfg.Advertise = config.AdvertiseConfig{
+		Enabled:       true,
+		WebhookURL:    " https://router.lan/hooks/dns ",
+		Interfaces:    []string{" eth0"},
+		ClientSubnets: []string{"192.168.1.7/24", "::ffff:10.0.0.1"},
+	}
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, "https://router.lan/hooks/dns", cfg.Advertise.WebhookURL)
+	assert.Equal(t, []string{"eth0"}, cfg.Advertise.Interfaces)
+	assert.Equal(t, []string{"192.168.1.0/24", "10.0.0.1/32"}, cfg.Advertise.ClientSubnets)
+
+	for name, a := range map[string]config.AdvertiseConfig{
+		"no webhook":      {Enabled: true},
+		"ftp webhook":     {WebhookURL: "ftp://router.lan/dns"},
+		"empty interface": {Interfaces: []string{" "}},
+		"short interval":  {Interval: "500ms"},
+		"bad subnet":      {ClientSubnets: []string{"lan"}},
+	} {
+		cfg := newConfig()
+		cfg.Advertise = a
+		assert.Error(t, cfg.Validate(), name)
+	}
+}
+
+func TestValidate_Identity(t *testing.T) {
+	cfg := newConfig()
+	cfg.Identity = config.IdentityConfig{Version: " HydraDNS ", Hostname: "dns-ams-1\n"}
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, config.IdentityConfig{Version: "HydraDNS", Hostname: "dns-ams-1"}, cfg.Identity)
+
+	cfg = newConfig()
+	cfg.Identity.ServerID = strings.Repeat("x", 256)
+	assert.Error(t, cfg.Validate())
+}
+
 // =============================================================================
 // Rate Limit Configuration Tests
 // =============================================================================
@@ -189,6 +948,256 @@ func TestSeedDefaults_RateLimitDefaults(t *testing.T) {
 	assert.Equal(t, 20000, cfg.RateLimit.PrefixBurst)
 	assert.InDelta(t, 5000.0, cfg.RateLimit.IPQPS, 0.001)
 	assert.Equal(t, 10000, cfg.RateLimit.IPBurst)
+	assert.Zero(t, cfg.RateLimit.Slip, "rate-limited queries are dropped by default")
+}
+
+func TestValidate_TransportRules(t *testing.T) {
+	cfg := newConfig()
+	cfg.Upstream.DoHServers = []string{" https://1.1.1.1/dns-query ", "https://1.1.1.1/dns-query"}
+	cfg.Upstream.TransportRules = []config.TransportRule{
+		{Domain: "Bank.Example.", Transport: "DoH"},
+		{Domain: "corp.example", Transport: "tcp"},
+	}
+	require.NoError(t, cfg.Validate())
+
+	assert.Equal(t, []string{"https://1.1.1.1/dns-query"}, cfg.Upstream.DoHServers)
+	assert.Equal(t, []config.TransportRule{
+		{Domain: "bank.example", Transport: config.TransportDoH},
+		{Domain: "corp.example", Transport: config.TransportTCP},
+	}, cfg.Upstream.TransportRules)
+}
+
+func TestValidate_TransportRulesRejectsInvalid(t *testing.T) {
+	tests := map[string]config.UpstreamConfig{
+		"plain http DoH server":   {DoHServers: []string{"http://1.1.1.1/dns-query"}},
+		"DoH server without host": {DoHServers: []string{"https:///dns-query"}},
+		"invalid domain":          {TransportRules: []config.TransportRule{{Domain: "bad..example", Transport: "tcp"}}},
+		"unknown transport":       {TransportRules: []config.TransportRule{{Domain: "bank.example", Transport: "dot"}}},
+		"doh without servers":     {TransportRules: []config.TransportRule{{Domain: "bank.example", Transport: "doh"}}},
+		"duplicate domain": {TransportRules: []config.TransportRule{
+			{Domain: "corp.example", Transport: "tcp"},
+			{Domain: "CORP.example.", Transport: "tcp"},
+		}},
+	}
+	for name, upstream := range tests {
+		cfg := newConfig()
+		cfg.Upstream.DoHServers = upstream.DoHServers
+		cfg.Upstream.TransportRules = upstream.TransportRules
+		assert.Error(t, cfg.Validate(), name)
+	}
+}
+
+func TestValidate_UpstreamStageTimeout(t *testing.T) {
+	cfg := newConfig()
+	require.NoError(t, cfg.Validate())
+	assert.Empty(t, cfg.Upstream.StageTimeout, "the stage is unbounded by default")
+
+	cfg.Upstream.StageTimeout = "4s"
+	require.NoError(t, cfg.Validate())
+
+	for _, bad := range []string{"soon", "0s", "-1s"} {
+		cfg := newConfig()
+		cfg.Upstream.StageTimeout = bad
+		assert.Error(t, cfg.Validate(), bad)
+	}
+}
+
+func TestValidate_TCPServerDefaults(t *testing.T) {
+	cfg := newConfig()
+	cfg.Server.TCPReadTimeout = ""
+	cfg.Server.TCPIdleTimeout = ""
+	cfg.Server.TCPMaxConnsPerIP = 0
+	cfg.Server.TCPMaxQueriesPerConn = 0
+	require.NoError(t, cfg.Validate())
+
+	assert.Equal(t, "10s", cfg.Server.TCPReadTimeout)
+	assert.Equal(t, "30s", cfg.Server.TCPIdleTimeout)
+	assert.Equal(t, 10, cfg.Server.TCPMaxConnsPerIP)
+	assert.Equal(t, 100, cfg.Server.TCPMaxQueriesPerConn)
+	assert.Zero(t, cfg.Server.TCPListeners)
+}
+
+func TestValidate_RejectsInvalidTCPServerLimits(t *testing.T) {
+	tests := map[string]func(*config.ServerConfig){
+		"bad read timeout":   func(s *config.ServerConfig) { s.TCPReadTimeout = "soon" },
+		"zero idle timeout":  func(s *config.ServerConfig) { s.TCPIdleTimeout = "0s" },
+		"negative conns":     func(s *config.ServerConfig) { s.TCPMaxConnsPerIP = -1 },
+		"negative queries":   func(s *config.ServerConfig) { s.TCPMaxQueriesPerConn = -1 },
+		"negative listeners": func(s *config.ServerConfig) { s.TCPListeners = -1 },
+	}
+	for name, mutate := range tests {
+		cfg := newConfig()
+		mutate(&cfg.Server)
+		assert.Error(t, cfg.Validate(), name)
+	}
+}
+
+func TestValidate_SlowQueries(t *testing.T) {
+	cfg := newConfig()
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, "0s", cfg.Logging.SlowQueryThreshold)
+	assert.Equal(t, config.DefaultSlowQueryMaxPerMinute, cfg.Logging.SlowQueryMaxPerMinute)
+
+	tests := map[string]func(*config.LoggingConfig){
+		"bad threshold":      func(l *config.LoggingConfig) { l.SlowQueryThreshold = "slow" },
+		"negative threshold": func(l *config.LoggingConfig) { l.SlowQueryThreshold = "-1ms" },
+		"negative cap":       func(l *config.LoggingConfig) { l.SlowQueryMaxPerMinute = -1 },
+	}
+	for name, mutate := range tests {
+		cfg := newConfig()
+		mutate(&cfg.Logging)
+		assert.Error(t, cfg.Validate(), name)
+	}
+}
+
+func TestValidate_ShutdownDrain(t *testing.T) {
+	cfg := newConfig()
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, "0s", cfg.Server.ShutdownDrain)
+
+	cfg.Server.ShutdownDrain = "15s"
+	require.NoError(t, cfg.Validate())
+
+	for _, bad := range []string{"-1s", "soon"} {
+		cfg.Server.ShutdownDrain = bad
+		assert.Error(t, cfg.Validate(), bad)
+	}
+}
+
+func TestValidate_UDPSocketBuffers(t *testing.T) {
+	cfg := newConfig()
+	cfg.Server.UDPRecvBuffer = 8 << 20
+	require.NoError(t, cfg.Validate())
+
+	cfg.Server.UDPRecvBuffer = -1
+	assert.Error(t, cfg.Validate())
+	cfg.Server.UDPRecvBuffer = 0
+	cfg.Server.UDPSendBuffer = -1
+	assert.Error(t, cfg.Validate())
+}
+
+func TestValidate_ResponsePipeline(t *testing.T) {
+	cfg := newConfig()
+	cfg.Server.ResponsePipeline = []config.ResponseStep{
+		{Type: " TTL_Clamp ", MaxTTL: 300, Clients: []string{"192.168.1.7/24"}},
+		{Type: "reorder"},
+		{Type: "ede", Code: 22},
+		{Type: "scrub", RecordTypes: []string{"txt", "TXT", "13"}},
+	}
+	require.NoError(t, cfg.Validate())
+	steps := cfg.Server.ResponsePipeline
+	assert.Equal(t, "ttl_clamp", steps[0].Type)
+	assert.Equal(t, []string{"192.168.1.0/24"}, steps[0].Clients)
+	assert.Equal(t, "round_robin", steps[1].Order)
+	assert.Equal(t, "SERVFAIL", steps[2].RCode)
+	assert.Equal(t, []string{"TXT", "TYPE13"}, steps[3].RecordTypes)
+
+	for _, bad := range []config.ResponseStep{
+		{Type: "rewrite"},
+		{Type: "ttl_clamp"},
+		{Type: "ttl_clamp", MinTTL: 600, MaxTTL: 60},
+		{Type: "reorder", Order: "fixed"},
+		{Type: "ede", RCode: "BOGUS"},
+		{Type: "ede", Code: 70000},
+		{Type: "scrub"},
+		{Type: "scrub", RecordTypes: []string{"OPT"}},
+		{Type: "filter_aaaa", Clients: []string{"lan"}},
+	} {
+		cfg.Server.ResponsePipeline = []config.ResponseStep{bad}
+		assert.Error(t, cfg.Validate(), bad.Type)
+	}
+}
+
+func TestValidate_NormalizesProxyProtocolTrusted(t *testing.T) {
+	cfg := newConfig()
+	cfg.Server.ProxyProtocolTrusted = []string{"10.0.0.1", "192.168.1.7/24", " ::ffff:172.16.0.1 "}
+	require.NoError(t, cfg.Validate())
+
+	assert.Equal(t, []string{"10.0.0.1/32", "192.168.1.0/24", "172.16.0.1/32"}, cfg.Server.ProxyProtocolTrusted)
+	assert.Len(t, cfg.Server.ProxyProtocolPrefixes(), 3)
+
+	cfg.Server.ProxyProtocolTrusted = []string{"lb.internal"}
+	assert.Error(t, cfg.Validate())
+}
+
+func TestValidate_NormalizesRecursionClients(t *testing.T) {
+	cfg := newConfig()
+	cfg.Server.RecursionClients = []string{"10.0.0.1", " 192.168.1.7/24 "}
+	require.NoError(t, cfg.Validate())
+
+	assert.Equal(t, []string{"10.0.0.1/32", "192.168.1.0/24"}, cfg.Server.RecursionClients)
+	assert.Len(t, cfg.Server.RecursionPrefixes(), 2)
+
+	cfg.Server.RecursionClients = []string{"lb.internal"}
+	assert.Error(t, cfg.Validate())
+}
+
+func TestValidate_ListenerProfiles(t *testing.T) {
+	cfg := newConfig()
+	cfg.Server.ListenerProfiles = []config.ListenerProfile{
+		{Name: " Admin ", Port: 5354, Unfiltered: true},
+		{Name: "lan", Host: "192.168.1.1", Port: 53},
+	}
+	require.NoError(t, cfg.Validate())
+
+	assert.Equal(t, config.ListenerProfile{Name: "admin", Host: "0.0.0.0", Port: 5354, Unfiltered: true},
+		cfg.Server.ListenerProfiles[0], "host defaults to server.host")
+	assert.Equal(t, "0.0.0.0:5354", cfg.Server.ListenerProfiles[0].Addr())
+}
+
+func TestValidate_ListenerProfilesRejectsInvalid(t *testing.T) {
+	tests := map[string][]config.ListenerProfile{
+		"missing name":          {{Port: 5354}},
+		"missing port":          {{Name: "admin"}},
+		"duplicate name":        {{Name: "admin", Port: 5354}, {Name: "ADMIN", Port: 5355}},
+		"same as default":       {{Name: "admin", Port: 53}},
+		"same address as other": {{Name: "a", Port: 5354}, {Name: "b", Host: "0.0.0.0", Port: 5354}},
+	}
+	for name, profiles := range tests {
+		cfg := newConfig()
+		cfg.Server.ListenerProfiles = profiles
+		assert.Error(t, cfg.Validate(), name)
+	}
+}
+
+func TestValidate_QTypeRules(t *testing.T) {
+	cfg := newConfig()
+	cfg.Server.QTypeRules = []config.QTypeRule{
+		{QTypes: []string{"any", " TYPE65535 ", "255"}},
+		{QTypes: []string{"ptr"}, Clients: []string{"192.168.50.0/24", "10.0.0.1"}, Action: "NXDOMAIN"},
+	}
+	require.NoError(t, cfg.Validate())
+
+	assert.Equal(t, []string{"ANY", "TYPE65535"}, cfg.Server.QTypeRules[0].QTypes, "canonical and deduplicated")
+	assert.Equal(t, "refused", cfg.Server.QTypeRules[0].Action, "action defaults to refused")
+	assert.Empty(t, cfg.Server.QTypeRules[0].ClientPrefixes())
+	assert.Equal(t, []string{"PTR"}, cfg.Server.QTypeRules[1].QTypes)
+	assert.Equal(t, "nxdomain", cfg.Server.QTypeRules[1].Action)
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("192.168.50.0/24"),
+		netip.MustParsePrefix("10.0.0.1/32"),
+	}, cfg.Server.QTypeRules[1].ClientPrefixes())
+}
+
+func TestValidate_QTypeRulesRejectsInvalid(t *testing.T) {
+	tests := map[string]config.QTypeRule{
+		"no qtypes":      {Action: "refused"},
+		"unknown qtype":  {QTypes: []string{"BOGUS"}},
+		"qtype too big":  {QTypes: []string{"TYPE65536"}},
+		"bad client":     {QTypes: []string{"ANY"}, Clients: []string{"lan"}},
+		"unknown action": {QTypes: []string{"ANY"}, Action: "servfail"},
+	}
+	for name, rule := range tests {
+		cfg := newConfig()
+		cfg.Server.QTypeRules = []config.QTypeRule{rule}
+		assert.Error(t, cfg.Validate(), name)
+	}
+}
+
+func TestValidate_RejectsNegativeSlip(t *testing.T) {
+	cfg := newConfig()
+	cfg.RateLimit.Slip = -1
+	assert.Error(t, cfg.Validate())
 }
 
 // =============================================================================
@@ -215,3 +1224,33 @@ func TestWorkerSetting_String(t *testing.T) {
 	fixed := config.WorkerSetting{Mode: config.WorkersFixed, Value: 4}
 	assert.Equal(t, "4", fixed.String())
 }
+
+func TestValidate_APILimitDefaults(t *testing.T) {
+	cfg := newConfig()
+	cfg.API.RateLimitRPS = 5
+	require.NoError(t, cfg.Validate())
+
+	assert.Equal(t, 10, cfg.API.RateLimitBurst, "burst defaults to twice the rate")
+	assert.Equal(t, config.DefaultAPIMaxBodyBytes, cfg.API.MaxBodyBytes)
+}
+
+func TestValidate_APIRateLimitDisabled(t *testing.T) {
+	cfg := newConfig()
+	require.NoError(t, cfg.Validate())
+
+	assert.Zero(t, cfg.API.RateLimitRPS)
+	assert.Zero(t, cfg.API.RateLimitBurst)
+}
+
+func TestValidate_RejectsInvalidAPILimits(t *testing.T) {
+	tests := map[string]func(*config.APIConfig){
+		"negative rate":  func(a *config.APIConfig) { a.RateLimitRPS = -1 },
+		"negative burst": func(a *config.APIConfig) { a.RateLimitBurst = -1 },
+		"negative body":  func(a *config.APIConfig) { a.MaxBodyBytes = -1 },
+	}
+	for name, mutate := range tests {
+		cfg := newConfig()
+		mutate(&cfg.API)
+		assert.Error(t, cfg.Validate(), name)
+	}
+}
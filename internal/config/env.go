@@ -0,0 +1,675 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix is the prefix of every environment variable that overrides a
+// configuration field.
+const EnvPrefix = "HYDRADNS_"
+
+// envVar maps one environment variable (without EnvPrefix) to a config field.
+type envVar struct {
+	name  string
+	apply func(cfg *Config, value string) error
+}
+
+// envVars lists the supported overrides. List values are comma-separated.
+var envVars = []envVar{
+	// Server
+	{"HOST", envString(func(c *Config) *string { return &c.Server.Host })},
+	{"PORT", envInt(func(c *Config) *int { return &c.Server.Port })},
+	{"WORKERS", func(c *Config, v string) error {
+		c.Server.WorkersRaw = v
+		return c.Server.ParseWorkers()
+	}},
+	{"MAX_CONCURRENCY", envInt(func(c *Config) *int { return &c.Server.MaxConcurrency })},
+	{"UDP_LISTENERS", envInt(func(c *Config) *int { return &c.Server.UDPListeners })},
+	{"UDP_RECV_BUFFER", envInt(func(c *Config) *int { return &c.Server.UDPRecvBuffer })},
+	{"UDP_SEND_BUFFER", envInt(func(c *Config) *int { return &c.Server.UDPSendBuffer })},
+	{"TCP_LISTENERS", envInt(func(c *Config) *int { return &c.Server.TCPListeners })},
+	{"TCP_READ_TIMEOUT", envString(func(c *Config) *string { return &c.Server.TCPReadTimeout })},
+	{"TCP_IDLE_TIMEOUT", envString(func(c *Config) *string { return &c.Server.TCPIdleTimeout })},
+	{"TCP_MAX_CONNS_PER_IP", envInt(func(c *Config) *int { return &c.Server.TCPMaxConnsPerIP })},
+	{"TCP_MAX_QUERIES_PER_CONN", envInt(func(c *Config) *int { return &c.Server.TCPMaxQueriesPerConn })},
+	{"SHUTDOWN_DRAIN", envString(func(c *Config) *string { return &c.Server.ShutdownDrain })},
+	{"PROXY_PROTOCOL_TRUSTED", envList(func(c *Config) *[]string { return &c.Server.ProxyProtocolTrusted })},
+	{"RECURSION_CLIENTS", envList(func(c *Config) *[]string { return &c.Server.RecursionClients })},
+	{"LISTENER_PROFILES", func(c *Config, v string) error {
+		profiles, err := parseEnvListenerProfiles(v)
+		if err != nil {
+			return err
+		}
+		c.Server.ListenerProfiles = profiles
+		return nil
+	}},
+	{"QTYPE_RULES", func(c *Config, v string) error {
+		rules, err := parseEnvQTypeRules(v)
+		if err != nil {
+			return err
+		}
+		c.Server.QTypeRules = rules
+		return nil
+	}},
+	{"RESPONSE_PIPELINE", func(c *Config, v string) error {
+		steps, err := parseEnvResponsePipeline(v)
+		if err != nil {
+			return err
+		}
+		c.Server.ResponsePipeline = steps
+		return nil
+	}},
+	{"ENABLE_TCP", envBool(func(c *Config) *bool { return &c.Server.EnableTCP })},
+	{"TCP_FALLBACK", envBool(func(c *Config) *bool { return &c.Server.TCPFallback })},
+	{"MAX_CNAME_CHAIN", envInt(func(c *Config) *int { return &c.Server.MaxCNAMEChain })},
+
+	// Upstream
+	{"UPSTREAMS", envList(func(c *Config) *[]string { return &c.Upstream.Servers })},
+	{"UPSTREAM_UDP_TIMEOUT", envString(func(c *Config) *string { return &c.Upstream.UDPTimeout })},
+	{"UPSTREAM_TCP_TIMEOUT", envString(func(c *Config) *string { return &c.Upstream.TCPTimeout })},
+	{"UPSTREAM_MAX_RETRIES", envInt(func(c *Config) *int { return &c.Upstream.MaxRetries })},
+	{"UPSTREAM_STRATEGY", envString(func(c *Config) *string { return &c.Upstream.Strategy })},
+	{"UPSTREAM_STAGE_TIMEOUT", envString(func(c *Config) *string { return &c.Upstream.StageTimeout })},
+	{"UPSTREAM_DOH_SERVERS", envList(func(c *Config) *[]string { return &c.Upstream.DoHServers })},
+	{"UPSTREAM_TRANSPORT_RULES", func(c *Config, v string) error {
+		rules, err := parseEnvTransportRules(v)
+		if err != nil {
+			return err
+		}
+		c.Upstream.TransportRules = rules
+		return nil
+	}},
+	{"UPSTREAM_EDNS_OPTIONS", func(c *Config, v string) error {
+		rules, err := parseEnvEDNSOptions(v)
+		if err != nil {
+			return err
+		}
+		c.Upstream.EDNSOptions = rules
+		return nil
+	}},
+
+	// Cache
+	{"CACHE_DISABLE_NEGATIVE", envBool(func(c *Config) *bool { return &c.Cache.DisableNegative })},
+	{"CACHE_NEGATIVE_TTL", envString(func(c *Config) *string { return &c.Cache.NegativeTTL })},
+	{"CACHE_SERVFAIL_TTL", envString(func(c *Config) *string { return &c.Cache.ServfailTTL })},
+	{"CACHE_MAX_ENTRIES", envInt(func(c *Config) *int { return &c.Cache.MaxEntries })},
+	{"CACHE_MAX_BYTES", envInt(func(c *Config) *int { return &c.Cache.MaxBytes })},
+	{"CACHE_MAX_ENTRY_BYTES", envInt(func(c *Config) *int { return &c.Cache.MaxEntryBytes })},
+	{"CACHE_EVICTION_POLICY", envString(func(c *Config) *string { return &c.Cache.EvictionPolicy })},
+	{"CACHE_AGGRESSIVE_NSEC", envBool(func(c *Config) *bool { return &c.Cache.AggressiveNSEC })},
+	{"CACHE_BYPASS_DOMAINS", envList(func(c *Config) *[]string { return &c.Cache.BypassDomains })},
+	{"CACHE_SHARED_BACKEND", envString(func(c *Config) *string { return &c.Cache.Shared.Backend })},
+	{"CACHE_SHARED_ADDRESS", envString(func(c *Config) *string { return &c.Cache.Shared.Address })},
+	{"CACHE_SHARED_PASSWORD", envString(func(c *Config) *string { return &c.Cache.Shared.Password })},
+	{"CACHE_SHARED_TIMEOUT", envString(func(c *Config) *string { return &c.Cache.Shared.Timeout })},
+	{"CACHE_SHARED_KEY_PREFIX", envString(func(c *Config) *string { return &c.Cache.Shared.KeyPrefix })},
+
+	// Logging
+	{"LOG_LEVEL", envString(func(c *Config) *string { return &c.Logging.Level })},
+	{"LOG_STRUCTURED", envBool(func(c *Config) *bool { return &c.Logging.Structured })},
+	{"LOG_FORMAT", envString(func(c *Config) *string { return &c.Logging.StructuredFormat })},
+	{"LOG_SLOW_QUERY_THRESHOLD", envString(func(c *Config) *string { return &c.Logging.SlowQueryThreshold })},
+	{"LOG_SLOW_QUERY_MAX_PER_MINUTE", envInt(func(c *Config) *int { return &c.Logging.SlowQueryMaxPerMinute })},
+
+	// Filtering
+	{"FILTERING_ENABLED", envBool(func(c *Config) *bool { return &c.Filtering.Enabled })},
+	{"FILTERING_LOG_BLOCKED", envBool(func(c *Config) *bool { return &c.Filtering.LogBlocked })},
+	{"FILTERING_LOG_ALLOWED", envBool(func(c *Config) *bool { return &c.Filtering.LogAllowed })},
+	{"FILTERING_WHITELIST", envList(func(c *Config) *[]string { return &c.Filtering.WhitelistDomains })},
+	{"FILTERING_BLACKLIST", envList(func(c *Config) *[]string { return &c.Filtering.BlacklistDomains })},
+	{"FILTERING_REFRESH_INTERVAL", envString(func(c *Config) *string { return &c.Filtering.RefreshInterval })},
+	{"FILTERING_BLOCK_TTL", envInt(func(c *Config) *int { return &c.Filtering.BlockTTL })},
+	{"FILTERING_NEGATIVE_TTL", envInt(func(c *Config) *int { return &c.Filtering.NegativeTTL })},
+	{"BLOCKLISTS", func(c *Config, v string) error {
+		c.Filtering.Blocklists = parseEnvBlocklists(v)
+		return nil
+	}},
+
+	// Block page
+	{"BLOCK_PAGE_ENABLED", envBool(func(c *Config) *bool { return &c.BlockPage.Enabled })},
+	{"BLOCK_PAGE_A", envString(func(c *Config) *string { return &c.BlockPage.IPv4 })},
+	{"BLOCK_PAGE_AAAA", envString(func(c *Config) *string { return &c.BlockPage.IPv6 })},
+	{"BLOCK_PAGE_HTTP_ADDR", envString(func(c *Config) *string { return &c.BlockPage.HTTPAddr })},
+	{"BLOCK_PAGE_HTTPS_ADDR", envString(func(c *Config) *string { return &c.BlockPage.HTTPSAddr })},
+	{"BLOCK_PAGE_TLS_CERT", envString(func(c *Config) *string { return &c.BlockPage.TLSCert })},
+	{"BLOCK_PAGE_TLS_KEY", envString(func(c *Config) *string { return &c.BlockPage.TLSKey })},
+
+	// Captive portal
+	{"CAPTIVE_PORTAL_ENABLED", envBool(func(c *Config) *bool { return &c.CaptivePortal.Enabled })},
+	{"CAPTIVE_PORTAL_CLIENTS", envList(func(c *Config) *[]string { return &c.CaptivePortal.Clients })},
+	{"CAPTIVE_PORTAL_A", envString(func(c *Config) *string { return &c.CaptivePortal.IPv4 })},
+	{"CAPTIVE_PORTAL_AAAA", envString(func(c *Config) *string { return &c.CaptivePortal.IPv6 })},
+	{"CAPTIVE_PORTAL_ALLOW_DOMAINS", envList(func(c *Config) *[]string { return &c.CaptivePortal.AllowDomains })},
+	{"CAPTIVE_PORTAL_TTL", envInt(func(c *Config) *int { return &c.CaptivePortal.TTL })},
+
+	// AAAA filtering
+	{"FILTER_AAAA_ENABLED", envBool(func(c *Config) *bool { return &c.FilterAAAA.Enabled })},
+	{"FILTER_AAAA_CLIENTS", envList(func(c *Config) *[]string { return &c.FilterAAAA.Clients })},
+
+	// Discovery of Designated Resolvers
+	{"DDR_ENABLED", envBool(func(c *Config) *bool { return &c.DDR.Enabled })},
+	{"DDR_TARGET", envString(func(c *Config) *string { return &c.DDR.Target })},
+	{"DDR_DOH_PORT", envInt(func(c *Config) *int { return &c.DDR.DoHPort })},
+	{"DDR_DOH_PATH", envString(func(c *Config) *string { return &c.DDR.DoHPath })},
+	{"DDR_DOT_PORT", envInt(func(c *Config) *int { return &c.DDR.DoTPort })},
+	{"DDR_A_HINTS", envList(func(c *Config) *[]string { return &c.DDR.IPv4Hints })},
+	{"DDR_AAAA_HINTS", envList(func(c *Config) *[]string { return &c.DDR.IPv6Hints })},
+	{"DDR_TTL", envInt(func(c *Config) *int { return &c.DDR.TTL })},
+
+	// Synthesized IPv6 PTR answers
+	{"SYNTH_PTR_ENABLED", envBool(func(c *Config) *bool { return &c.SynthPTR.Enabled })},
+	{"SYNTH_PTR_PREFIXES", envList(func(c *Config) *[]string { return &c.SynthPTR.Prefixes })},
+	{"SYNTH_PTR_TEMPLATE", envString(func(c *Config) *string { return &c.SynthPTR.Template })},
+	{"SYNTH_PTR_TTL", envInt(func(c *Config) *int { return &c.SynthPTR.TTL })},
+
+	// GeoIP
+	{"GEOIP_COUNTRY_DB", envString(func(c *Config) *string { return &c.GeoIP.CountryDB })},
+	{"GEOIP_ASN_DB", envString(func(c *Config) *string { return &c.GeoIP.ASNDB })},
+	{"GEOIP_BLOCK_ASNS", func(c *Config, v string) error {
+		asns, err := parseEnvASNs(v)
+		if err != nil {
+			return err
+		}
+		c.GeoIP.BlockASNs = asns
+		return nil
+	}},
+	{"GEOIP_BLOCK_COUNTRIES", envList(func(c *Config) *[]string { return &c.GeoIP.BlockCountries })},
+
+	// Anomaly detection
+	{"ANOMALY_ENABLED", envBool(func(c *Config) *bool { return &c.Anomaly.Enabled })},
+	{"ANOMALY_WINDOW", envString(func(c *Config) *string { return &c.Anomaly.Window })},
+	{"ANOMALY_MIN_QUERIES", envInt(func(c *Config) *int { return &c.Anomaly.MinQueries })},
+	{"ANOMALY_NXDOMAIN_RATIO", envFloat(func(c *Config) *float64 { return &c.Anomaly.NXDOMAINRatio })},
+	{"ANOMALY_TXT_RATIO", envFloat(func(c *Config) *float64 { return &c.Anomaly.TXTRatio })},
+	{"ANOMALY_MAX_LABEL_LENGTH", envInt(func(c *Config) *int { return &c.Anomaly.MaxLabelLength })},
+	{"ANOMALY_MIN_LABEL_ENTROPY", envFloat(func(c *Config) *float64 { return &c.Anomaly.MinLabelEntropy })},
+
+	// Threat intelligence
+	{"THREAT_INTEL_ENABLED", envBool(func(c *Config) *bool { return &c.ThreatIntel.Enabled })},
+	{"THREAT_INTEL_PROVIDER", envString(func(c *Config) *string { return &c.ThreatIntel.Provider })},
+	{"THREAT_INTEL_URL", envString(func(c *Config) *string { return &c.ThreatIntel.URL })},
+	{"THREAT_INTEL_AUTH_KEY", envString(func(c *Config) *string { return &c.ThreatIntel.AuthKey })},
+	{"THREAT_INTEL_BLOCK_TTL", envString(func(c *Config) *string { return &c.ThreatIntel.BlockTTL })},
+	{"THREAT_INTEL_CACHE_TTL", envString(func(c *Config) *string { return &c.ThreatIntel.CacheTTL })},
+	{"THREAT_INTEL_RATE_LIMIT", envFloat(func(c *Config) *float64 { return &c.ThreatIntel.RateLimit })},
+
+	// Custom DNS
+	{"SEARCH_DOMAINS", envList(func(c *Config) *[]string { return &c.CustomDNS.SearchDomains })},
+	{"CUSTOM_DNS_ANSWER_ORDER", envString(func(c *Config) *string { return &c.CustomDNS.AnswerOrder })},
+	{"CUSTOM_DNS_STEERING", func(c *Config, v string) error {
+		steering, err := parseEnvSteering(v)
+		if err != nil {
+			return err
+		}
+		c.CustomDNS.Steering = steering
+		return nil
+	}},
+	{"CUSTOM_DNS_HEALTH_CHECKS", func(c *Config, v string) error {
+		checks, err := parseEnvHealthChecks(v)
+		if err != nil {
+			return err
+		}
+		c.CustomDNS.HealthChecks = checks
+		return nil
+	}},
+
+	// Newly registered domains
+	{"NRD_ENABLED", envBool(func(c *Config) *bool { return &c.NRD.Enabled })},
+	{"NRD_ACTION", envString(func(c *Config) *string { return &c.NRD.Action })},
+	{"NRD_DAYS", envInt(func(c *Config) *int { return &c.NRD.Days })},
+
+	// Server identity
+	{"IDENTITY_VERSION", envString(func(c *Config) *string { return &c.Identity.Version })},
+	{"IDENTITY_HOSTNAME", envString(func(c *Config) *string { return &c.Identity.Hostname })},
+	{"IDENTITY_SERVER_ID", envString(func(c *Config) *string { return &c.Identity.ServerID })},
+
+	// Outbound query auditing
+	{"AUDIT_ENABLED", envBool(func(c *Config) *bool { return &c.Audit.Enabled })},
+	{"AUDIT_PATH", envString(func(c *Config) *string { return &c.Audit.Path })},
+
+	// Query log privacy
+	{"PRIVACY_LEVEL", envString(func(c *Config) *string { return &c.Privacy.Level })},
+	{"PRIVACY_ANONYMIZE", envString(func(c *Config) *string { return &c.Privacy.Anonymize })},
+
+	// Health canary
+	{"CANARY_ENABLED", envBool(func(c *Config) *bool { return &c.Canary.Enabled })},
+	{"CANARY_DOMAINS", envList(func(c *Config) *[]string { return &c.Canary.Domains })},
+	{"CANARY_INTERVAL", envString(func(c *Config) *string { return &c.Canary.Interval })},
+	{"CANARY_TIMEOUT", envString(func(c *Config) *string { return &c.Canary.Timeout })},
+	{"CANARY_FAILURE_THRESHOLD", envInt(func(c *Config) *int { return &c.Canary.FailureThreshold })},
+
+	// Shadow upstreams
+	{"SHADOW_ENABLED", envBool(func(c *Config) *bool { return &c.Shadow.Enabled })},
+	{"SHADOW_SERVERS", envList(func(c *Config) *[]string { return &c.Shadow.Servers })},
+	{"SHADOW_PERCENT", envFloat(func(c *Config) *float64 { return &c.Shadow.Percent })},
+	{"SHADOW_TIMEOUT", envString(func(c *Config) *string { return &c.Shadow.Timeout })},
+
+	// DNS advertisement
+	{"ADVERTISE_ENABLED", envBool(func(c *Config) *bool { return &c.Advertise.Enabled })},
+	{"ADVERTISE_WEBHOOK_URL", envString(func(c *Config) *string { return &c.Advertise.WebhookURL })},
+	{"ADVERTISE_WEBHOOK_SECRET", envString(func(c *Config) *string { return &c.Advertise.WebhookSecret })},
+	{"ADVERTISE_INTERFACES", envList(func(c *Config) *[]string { return &c.Advertise.Interfaces })},
+	{"ADVERTISE_INTERVAL", envString(func(c *Config) *string { return &c.Advertise.Interval })},
+	{"ADVERTISE_CLIENT_SUBNETS", envList(func(c *Config) *[]string { return &c.Advertise.ClientSubnets })},
+
+	// Rate limiting
+	{"RATE_LIMIT_GLOBAL_QPS", envFloat(func(c *Config) *float64 { return &c.RateLimit.GlobalQPS })},
+	{"RATE_LIMIT_GLOBAL_BURST", envInt(func(c *Config) *int { return &c.RateLimit.GlobalBurst })},
+	{"RATE_LIMIT_PREFIX_QPS", envFloat(func(c *Config) *float64 { return &c.RateLimit.PrefixQPS })},
+	{"RATE_LIMIT_PREFIX_BURST", envInt(func(c *Config) *int { return &c.RateLimit.PrefixBurst })},
+	{"RATE_LIMIT_IP_QPS", envFloat(func(c *Config) *float64 { return &c.RateLimit.IPQPS })},
+	{"RATE_LIMIT_IP_BURST", envInt(func(c *Config) *int { return &c.RateLimit.IPBurst })},
+	{"RATE_LIMIT_SLIP", envInt(func(c *Config) *int { return &c.RateLimit.Slip })},
+	{"RATE_LIMIT_PREFIX_LENGTHS", func(c *Config, v string) error {
+		v4, v6, err := parseEnvPrefixLengths(v)
+		if err != nil {
+			return err
+		}
+		c.RateLimit.IPv4PrefixLen, c.RateLimit.IPv6PrefixLen = v4, v6
+		return nil
+	}},
+	{"RATE_LIMIT_EXEMPT_LOCAL", envBool(func(c *Config) *bool { return &c.RateLimit.ExemptLocal })},
+
+	// Management API
+	{"API_HOST", envString(func(c *Config) *string { return &c.API.Host })},
+	{"API_PORT", envInt(func(c *Config) *int { return &c.API.Port })},
+	{"API_KEY", envString(func(c *Config) *string { return &c.API.APIKey })},
+	{"API_RATE_LIMIT_RPS", envFloat(func(c *Config) *float64 { return &c.API.RateLimitRPS })},
+	{"API_RATE_LIMIT_BURST", envInt(func(c *Config) *int { return &c.API.RateLimitBurst })},
+	{"API_MAX_BODY_BYTES", envInt(func(c *Config) *int { return &c.API.MaxBodyBytes })},
+
+	// Cluster
+	{"CLUSTER_MODE", func(c *Config, v string) error {
+		c.Cluster.Mode = ClusterMode(strings.ToLower(v))
+		return nil
+	}},
+	{"CLUSTER_NODE_ID", envString(func(c *Config) *string { return &c.Cluster.NodeID })},
+	{"CLUSTER_PRIMARY_URL", envString(func(c *Config) *string { return &c.Cluster.PrimaryURL })},
+	{"CLUSTER_SECRET", envString(func(c *Config) *string { return &c.Cluster.SharedSecret })},
+	{"CLUSTER_SYNC_INTERVAL", envString(func(c *Config) *string { return &c.Cluster.SyncInterval })},
+	{"CLUSTER_SYNC_TIMEOUT", envString(func(c *Config) *string { return &c.Cluster.SyncTimeout })},
+	{"CLUSTER_DISCOVERY_ENABLED", envBool(func(c *Config) *bool { return &c.ClusterDiscovery.Enabled })},
+	{"CLUSTER_DISCOVERY_ZONE", envString(func(c *Config) *string { return &c.ClusterDiscovery.Zone })},
+	{"CLUSTER_DISCOVERY_ADDRESS", envString(func(c *Config) *string { return &c.ClusterDiscovery.Address })},
+	{"CLUSTER_DISCOVERY_TTL", envInt(func(c *Config) *int { return &c.ClusterDiscovery.TTL })},
+	{"CLUSTER_DISCOVERY_MEMBER_TIMEOUT", envInt(func(c *Config) *int { return &c.ClusterDiscovery.MemberTimeout })},
+}
+
+// EnvVarNames returns the names of all supported environment variables,
+// including EnvPrefix, in documentation order.
+func EnvVarNames() []string {
+	names := make([]string, len(envVars))
+	for i, ev := range envVars {
+		names[i] = EnvPrefix + ev.name
+	}
+	return names
+}
+
+// ApplyEnv overrides configuration fields from environment variables named
+// HYDRADNS_<FIELD> (see EnvVarNames). lookup is typically os.LookupEnv.
+//
+// Overrides apply on top of the database configuration and are never
+// persisted. Variables that are unset or empty are ignored. All malformed
+// values are reported together; fields with valid values are still applied.
+func (cfg *Config) ApplyEnv(lookup func(string) (string, bool)) error {
+	var errs []error
+	for _, ev := range envVars {
+		value, ok := lookup(EnvPrefix + ev.name)
+		value = strings.TrimSpace(value)
+		if !ok || value == "" {
+			continue
+		}
+		if err := ev.apply(cfg, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s%s: %w", EnvPrefix, ev.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func envString(field func(*Config) *string) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		*field(c) = v
+		return nil
+	}
+}
+
+func envInt(field func(*Config) *int) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", v)
+		}
+		*field(c) = n
+		return nil
+	}
+}
+
+func envFloat(field func(*Config) *float64) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", v)
+		}
+		*field(c) = f
+		return nil
+	}
+}
+
+func envBool(field func(*Config) *bool) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", v)
+		}
+		*field(c) = b
+		return nil
+	}
+}
+
+func envList(field func(*Config) *[]string) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		*field(c) = splitEnvList(v)
+		return nil
+	}
+}
+
+// splitEnvList splits a comma-separated list, dropping empty entries.
+func splitEnvList(v string) []string {
+	var out []string
+	for item := range strings.SplitSeq(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// parseEnvPrefixLengths parses "v4,v6" prefix lengths, such as "24,56".
+func parseEnvPrefixLengths(v string) (int, int, error) {
+	items := splitEnvList(v)
+	if len(items) != 2 {
+		return 0, 0, fmt.Errorf("invalid prefix lengths %q, want IPv4,IPv6", v)
+	}
+	v4, err4 := strconv.Atoi(items[0])
+	v6, err6 := strconv.Atoi(items[1])
+	if err4 != nil || err6 != nil {
+		return 0, 0, fmt.Errorf("invalid prefix lengths %q, want IPv4,IPv6", v)
+	}
+	return v4, v6, nil
+}
+
+// parseEnvBlocklists parses a comma-separated list of blocklist URLs, each
+// optionally prefixed with "name=". Unnamed lists are named after their URL
+// and use automatic format detection.
+func parseEnvBlocklists(v string) []BlocklistConfig {
+	items := splitEnvList(v)
+	out := make([]BlocklistConfig, 0, len(items))
+	for _, item := range items {
+		bl := BlocklistConfig{Name: item, URL: item, Format: "auto"}
+		if name, url, ok := strings.Cut(item, "="); ok && !strings.Contains(name, "/") {
+			bl.Name, bl.URL = strings.TrimSpace(name), strings.TrimSpace(url)
+		}
+		out = append(out, bl)
+	}
+	return out
+}
+
+// parseEnvEDNSOptions parses a comma-separated list of EDNS option rules of
+// the form option=action or option=rewrite:value, e.g.
+// "cookie=strip,ecs=rewrite:192.0.2.0/24". Rules are validated by
+// Config.Validate.
+func parseEnvEDNSOptions(v string) ([]EDNSOptionRule, error) {
+	items := splitEnvList(v)
+	out := make([]EDNSOptionRule, 0, len(items))
+	for _, item := range items {
+		option, action, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid EDNS option rule %q (want option=action)", item)
+		}
+		rule := EDNSOptionRule{Option: strings.TrimSpace(option), Action: strings.TrimSpace(action)}
+		if a, value, ok := strings.Cut(rule.Action, ":"); ok {
+			rule.Action, rule.Value = strings.TrimSpace(a), strings.TrimSpace(value)
+		}
+		out = append(out, rule)
+	}
+	return out, nil
+}
+
+// parseEnvSteering parses a comma-separated list of steered hosts of the
+// form name=addr[*weight][@clients];addr..., with clients separated by "|",
+// e.g. "app.lan=10.0.1.5*3@192.168.1.0/24;10.0.2.5*1@192.168.2.0/24".
+// Steering is validated by Config.Validate.
+func parseEnvSteering(v string) ([]HostSteering, error) {
+	items := splitEnvList(v)
+	out := make([]HostSteering, 0, len(items))
+	for _, item := range items {
+		name, addrs, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid steering %q (want name=addr[*weight][@clients];...)", item)
+		}
+		s := HostSteering{Name: strings.TrimSpace(name)}
+		for raw := range strings.SplitSeq(addrs, ";") {
+			if raw = strings.TrimSpace(raw); raw == "" {
+				continue
+			}
+			spec, clients, _ := strings.Cut(raw, "@")
+			addr, weight, hasWeight := strings.Cut(spec, "*")
+			a := SteeredAddr{Addr: strings.TrimSpace(addr)}
+			if hasWeight {
+				n, err := strconv.Atoi(strings.TrimSpace(weight))
+				if err != nil {
+					return nil, fmt.Errorf("invalid steering weight in %q", raw)
+				}
+				a.Weight = n
+			}
+			for c := range strings.SplitSeq(clients, "|") {
+				if c = strings.TrimSpace(c); c != "" {
+					a.Clients = append(a.Clients, c)
+				}
+			}
+			s.Addrs = append(s.Addrs, a)
+		}
+		if len(s.Addrs) == 0 {
+			return nil, fmt.Errorf("steering %q has no addresses", item)
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// parseEnvHealthChecks parses a comma-separated list of custom DNS health
+// checks of the form name=tcp:port or name=http:port/path, e.g.
+// "web.lan=http:8080/healthz,db.lan=tcp:5432". Intervals, timeouts, and
+// failure counts keep their defaults. Checks are validated by
+// Config.Validate.
+func parseEnvHealthChecks(v string) ([]RecordHealthCheck, error) {
+	items := splitEnvList(v)
+	out := make([]RecordHealthCheck, 0, len(items))
+	for _, item := range items {
+		name, probe, ok := strings.Cut(item, "=")
+		typ, target, ok2 := strings.Cut(probe, ":")
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("invalid health check %q (want name=tcp:port or name=http:port/path)", item)
+		}
+		check := RecordHealthCheck{Name: strings.TrimSpace(name), Type: strings.TrimSpace(typ)}
+		port := strings.TrimSpace(target)
+		if i := strings.IndexByte(port, '/'); i >= 0 {
+			port, check.Path = port[:i], port[i:]
+		}
+		n, err := strconv.Atoi(port)
+		if err != nil {
+			return nil, fmt.Errorf("invalid health check port in %q", item)
+		}
+		check.Port = n
+		out = append(out, check)
+	}
+	return out, nil
+}
+
+// parseEnvTransportRules parses a comma-separated list of transport rules
+// of the form domain=transport, e.g. "bank.example=doh,corp.example=tcp".
+// Rules are validated by Config.Validate.
+func parseEnvTransportRules(v string) ([]TransportRule, error) {
+	items := splitEnvList(v)
+	out := make([]TransportRule, 0, len(items))
+	for _, item := range items {
+		domain, transport, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid transport rule %q (want domain=transport)", item)
+		}
+		out = append(out, TransportRule{Domain: strings.TrimSpace(domain), Transport: strings.TrimSpace(transport)})
+	}
+	return out, nil
+}
+
+// parseEnvListenerProfiles parses a comma-separated list of listener
+// profiles, each "name=host:port" with an optional "/unfiltered" suffix,
+// e.g. "admin=:5354/unfiltered". An empty host selects server.host.
+func parseEnvListenerProfiles(v string) ([]ListenerProfile, error) {
+	items := splitEnvList(v)
+	out := make([]ListenerProfile, 0, len(items))
+	for _, item := range items {
+		name, addr, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid listener profile %q (want name=host:port)", item)
+		}
+		p := ListenerProfile{Name: strings.TrimSpace(name)}
+		addr = strings.TrimSpace(addr)
+		if a, ok := strings.CutSuffix(addr, "/unfiltered"); ok {
+			addr, p.Unfiltered = a, true
+		}
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid listener profile %q: %w", item, err)
+		}
+		if p.Port, err = strconv.Atoi(port); err != nil {
+			return nil, fmt.Errorf("invalid listener profile %q: bad port %q", item, port)
+		}
+		p.Host = host
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// parseEnvQTypeRules parses a comma-separated list of qtype rules, each
+// "qtypes[@clients]=action" with "|" between multiple qtypes or clients,
+// e.g. "ANY|TYPE65535=refused,PTR@192.168.50.0/24=nxdomain". Rules are
+// validated by Config.Validate.
+func parseEnvQTypeRules(v string) ([]QTypeRule, error) {
+	items := splitEnvList(v)
+	out := make([]QTypeRule, 0, len(items))
+	for _, item := range items {
+		match, action, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid qtype rule %q (want qtypes[@clients]=action)", item)
+		}
+		rule := QTypeRule{Action: strings.TrimSpace(action)}
+		qtypes, clients, _ := strings.Cut(match, "@")
+		for t := range strings.SplitSeq(qtypes, "|") {
+			if t = strings.TrimSpace(t); t != "" {
+				rule.QTypes = append(rule.QTypes, t)
+			}
+		}
+		if len(rule.QTypes) == 0 {
+			return nil, fmt.Errorf("qtype rule %q has no qtypes", item)
+		}
+		for c := range strings.SplitSeq(clients, "|") {
+			if c = strings.TrimSpace(c); c != "" {
+				rule.Clients = append(rule.Clients, c)
+			}
+		}
+		out = append(out, rule)
+	}
+	return out, nil
+}
+
+// parseEnvResponsePipeline parses a comma-separated list of response
+// pipeline steps, each "type[@clients][=args]" with "|" between clients,
+// e.g. "ttl_clamp=60:3600,filter_aaaa@192.168.1.0/24,reorder=random,
+// ede=SERVFAIL:22:upstream unreachable,scrub=TXT|TYPE13". Arguments are
+// "min:max" for ttl_clamp, the order for reorder, "rcode:code[:text]" for
+// ede, and "|"-separated types for scrub. Steps are validated by
+// Config.Validate.
+func parseEnvResponsePipeline(v string) ([]ResponseStep, error) {
+	items := splitEnvList(v)
+	out := make([]ResponseStep, 0, len(items))
+	for _, item := range items {
+		spec, args, _ := strings.Cut(item, "=")
+		typ, clients, _ := strings.Cut(spec, "@")
+		st := ResponseStep{Type: strings.TrimSpace(typ)}
+		if st.Type == "" {
+			return nil, fmt.Errorf("response step %q has no type", item)
+		}
+		for c := range strings.SplitSeq(clients, "|") {
+			if c = strings.TrimSpace(c); c != "" {
+				st.Clients = append(st.Clients, c)
+			}
+		}
+		args = strings.TrimSpace(args)
+		switch strings.ToLower(st.Type) {
+		case ResponseStepTTLClamp:
+			minTTL, maxTTL, _ := strings.Cut(args, ":")
+			var err error
+			if st.MinTTL, err = envAtoiOrZero(minTTL); err != nil {
+				return nil, fmt.Errorf("invalid min TTL in %q", item)
+			}
+			if st.MaxTTL, err = envAtoiOrZero(maxTTL); err != nil {
+				return nil, fmt.Errorf("invalid max TTL in %q", item)
+			}
+		case ResponseStepReorder:
+			st.Order = args
+		case ResponseStepEDE:
+			parts := strings.SplitN(args, ":", 3)
+			st.RCode = strings.TrimSpace(parts[0])
+			if len(parts) > 1 {
+				code, err := envAtoiOrZero(parts[1])
+				if err != nil {
+					return nil, fmt.Errorf("invalid EDE code in %q", item)
+				}
+				st.Code = code
+			}
+			if len(parts) > 2 {
+				st.Text = strings.TrimSpace(parts[2])
+			}
+		case ResponseStepScrub:
+			for t := range strings.SplitSeq(args, "|") {
+				if t = strings.TrimSpace(t); t != "" {
+					st.RecordTypes = append(st.RecordTypes, t)
+				}
+			}
+		}
+		out = append(out, st)
+	}
+	return out, nil
+}
+
+// envAtoiOrZero parses a decimal integer, or returns 0 for an empty string.
+func envAtoiOrZero(s string) (int, error) {
+	if s = strings.TrimSpace(s); s == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(s)
+}
+
+// parseEnvASNs parses a comma-separated list of autonomous system numbers,
+// each optionally prefixed with "AS", e.g. "AS13335,15169".
+func parseEnvASNs(v string) ([]uint32, error) {
+	items := splitEnvList(v)
+	out := make([]uint32, 0, len(items))
+	for _, item := range items {
+		digits := item
+		if len(digits) > 2 && strings.EqualFold(digits[:2], "AS") {
+			digits = digits[2:]
+		}
+		n, err := strconv.ParseUint(digits, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ASN %q", item)
+		}
+		out = append(out, uint32(n))
+	}
+	return out, nil
+}
@@ -0,0 +1,303 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/jroosing/hydradns/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func envLookup(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+}
+
+// =============================================================================
+// Environment Override Tests
+// =============================================================================
+
+func TestApplyEnv_NoVariables(t *testing.T) {
+	cfg := newConfig()
+	want := newConfig()
+
+	require.NoError(t, cfg.ApplyEnv(envLookup(nil)))
+	assert.Equal(t, want, cfg)
+}
+
+func TestApplyEnv_OverridesFields(t *testing.T) {
+	cfg := newConfig()
+	err := cfg.ApplyEnv(envLookup(map[string]string{
+		"HYDRADNS_PORT":                   "5353",
+		"HYDRADNS_WORKERS":                "4",
+		"HYDRADNS_ENABLE_TCP":             "false",
+		"HYDRADNS_UPSTREAMS":              " 10.0.0.1, 10.0.0.2 ,",
+		"HYDRADNS_LOG_LEVEL":              "debug",
+		"HYDRADNS_FILTERING_ENABLED":      "true",
+		"HYDRADNS_FILTERING_WHITELIST":    "good.example.com",
+		"HYDRADNS_RATE_LIMIT_IP_QPS":      "12.5",
+		"HYDRADNS_API_KEY":                "s3cret",
+		"HYDRADNS_CLUSTER_MODE":           "Secondary",
+		"HYDRADNS_CLUSTER_PRIMARY_URL":    "http://primary:8080",
+		"HYDRADNS_CACHE_DISABLE_NEGATIVE": "1",
+	}))
+	require.NoError(t, err)
+
+	assert.Equal(t, 5353, cfg.Server.Port)
+	assert.Equal(t, "4", cfg.Server.WorkersRaw)
+	assert.Equal(t, config.WorkerSetting{Mode: config.WorkersFixed, Value: 4}, cfg.Server.Workers)
+	assert.False(t, cfg.Server.EnableTCP)
+	assert.Equal(t, []string{"10.0.0.1", "10.0.0.2"}, cfg.Upstream.Servers)
+	assert.Equal(t, "debug", cfg.Logging.Level)
+	assert.True(t, cfg.Filtering.Enabled)
+	assert.Equal(t, []string{"good.example.com"}, cfg.Filtering.WhitelistDomains)
+	assert.InDelta(t, 12.5, cfg.RateLimit.IPQPS, 1e-9)
+	assert.Equal(t, "s3cret", cfg.API.APIKey)
+	assert.Equal(t, config.ClusterModeSecondary, cfg.Cluster.Mode)
+	assert.Equal(t, "http://primary:8080", cfg.Cluster.PrimaryURL)
+	assert.True(t, cfg.Cache.DisableNegative)
+
+	// Untouched fields keep their database values
+	assert.Equal(t, "0.0.0.0", cfg.Server.Host)
+	assert.Equal(t, "3s", cfg.Upstream.UDPTimeout)
+}
+
+func TestApplyEnv_EmptyValueIsIgnored(t *testing.T) {
+	cfg := newConfig()
+
+	require.NoError(t, cfg.ApplyEnv(envLookup(map[string]string{"HYDRADNS_UPSTREAMS": "  "})))
+	assert.Equal(t, []string{"9.9.9.9", "1.1.1.1", "8.8.8.8"}, cfg.Upstream.Servers)
+}
+
+func TestApplyEnv_Blocklists(t *testing.T) {
+	cfg := newConfig()
+	err := cfg.ApplyEnv(envLookup(map[string]string{
+		"HYDRADNS_BLOCKLISTS": "ads=https://example.com/ads.txt,https://example.com/list?format=hosts",
+	}))
+	require.NoError(t, err)
+
+	assert.Equal(t, []config.BlocklistConfig{
+		{Name: "ads", URL: "https://example.com/ads.txt", Format: "auto"},
+		{Name: "https://example.com/list?format=hosts", URL: "https://example.com/list?format=hosts", Format: "auto"},
+	}, cfg.Filtering.Blocklists)
+}
+
+func TestApplyEnv_EDNSOptions(t *testing.T) {
+	cfg := newConfig()
+	err := cfg.ApplyEnv(envLookup(map[string]string{
+		"HYDRADNS_UPSTREAM_EDNS_OPTIONS": "cookie=strip, ecs=rewrite:2001:db8::/32",
+	}))
+	require.NoError(t, err)
+
+	assert.Equal(t, []config.EDNSOptionRule{
+		{Option: "cookie", Action: "strip"},
+		{Option: "ecs", Action: "rewrite", Value: "2001:db8::/32"},
+	}, cfg.Upstream.EDNSOptions)
+
+	cfg = newConfig()
+	err = cfg.ApplyEnv(envLookup(map[string]string{"HYDRADNS_UPSTREAM_EDNS_OPTIONS": "cookie"}))
+	assert.Error(t, err, "a rule needs an action")
+}
+
+func TestApplyEnv_GeoIP(t *testing.T) {
+	cfg := newConfig()
+	err := cfg.ApplyEnv(envLookup(map[string]string{
+		"HYDRADNS_GEOIP_ASN_DB":          "/data/GeoLite2-ASN.mmdb",
+		"HYDRADNS_GEOIP_BLOCK_ASNS":      "AS64500, as64501,64502",
+		"HYDRADNS_GEOIP_BLOCK_COUNTRIES": "nl,DE",
+	}))
+	require.NoError(t, err)
+
+	assert.Equal(t, "/data/GeoLite2-ASN.mmdb", cfg.GeoIP.ASNDB)
+	assert.Equal(t, []uint32{64500, 64501, 64502}, cfg.GeoIP.BlockASNs)
+	assert.Equal(t, []string{"nl", "DE"}, cfg.GeoIP.BlockCountries)
+
+	cfg = newConfig()
+	err = cfg.ApplyEnv(envLookup(map[string]string{"HYDRADNS_GEOIP_BLOCK_ASNS": "ASX"}))
+	assert.Error(t, err)
+}
+
+func TestApplyEnv_ListenerProfiles(t *testing.T) {
+	cfg := newConfig()
+	err := cfg.ApplyEnv(envLookup(map[string]string{
+		"HYDRADNS_LISTENER_PROFILES": "admin=:5354/unfiltered, kids=[::1]:5355",
+	}))
+	require.NoError(t, err)
+
+	assert.Equal(t, []config.ListenerProfile{
+		{Name: "admin", Port: 5354, Unfiltered: true},
+		{Name: "kids", Host: "::1", Port: 5355},
+	}, cfg.Server.ListenerProfiles)
+
+	for _, v := range []string{"admin", "admin=5354", "admin=:dns"} {
+		cfg = newConfig()
+		err = cfg.ApplyEnv(envLookup(map[string]string{"HYDRADNS_LISTENER_PROFILES": v}))
+		assert.Error(t, err, v)
+	}
+}
+
+func TestApplyEnv_TransportRules(t *testing.T) {
+	cfg := newConfig()
+	err := cfg.ApplyEnv(envLookup(map[string]string{
+		"HYDRADNS_UPSTREAM_DOH_SERVERS":     "https://1.1.1.1/dns-query, https://9.9.9.9/dns-query",
+		"HYDRADNS_UPSTREAM_TRANSPORT_RULES": "bank.example=doh, corp.example=tcp",
+	}))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"https://1.1.1.1/dns-query", "https://9.9.9.9/dns-query"}, cfg.Upstream.DoHServers)
+	assert.Equal(t, []config.TransportRule{
+		{Domain: "bank.example", Transport: "doh"},
+		{Domain: "corp.example", Transport: "tcp"},
+	}, cfg.Upstream.TransportRules)
+
+	cfg = newConfig()
+	err = cfg.ApplyEnv(envLookup(map[string]string{"HYDRADNS_UPSTREAM_TRANSPORT_RULES": "bank.example"}))
+	assert.Error(t, err)
+}
+
+func TestApplyEnv_RecordHealthChecks(t *testing.T) {
+	cfg := newConfig()
+	err := cfg.ApplyEnv(envLookup(map[string]string{
+		"HYDRADNS_CUSTOM_DNS_HEALTH_CHECKS": "web.lan=http:8080/healthz, db.lan=tcp:5432",
+	}))
+	require.NoError(t, err)
+
+	assert.Equal(t, []config.RecordHealthCheck{
+		{Name: "web.lan", Type: "http", Port: 8080, Path: "/healthz"},
+		{Name: "db.lan", Type: "tcp", Port: 5432},
+	}, cfg.CustomDNS.HealthChecks)
+
+	for _, v := range []string{"web.lan", "web.lan=http", "web.lan=tcp:port"} {
+		cfg = newConfig()
+		err = cfg.ApplyEnv(envLookup(map[string]string{"HYDRADNS_CUSTOM_DNS_HEALTH_CHECKS": v}))
+		assert.Error(t, err, v)
+	}
+}
+
+func TestApplyEnv_Steering(t *testing.T) {
+	cfg := newConfig()
+	err := cfg.ApplyEnv(envLookup(map[string]string{
+		"HYDRADNS_CUSTOM_DNS_STEERING": "app.lan=10.0.1.5*3@192.168.1.0/24|10.9.9.9;10.0.2.5, web.lan=10.0.3.1*1",
+	}))
+	require.NoError(t, err)
+
+	assert.Equal(t, []config.HostSteering{
+		{Name: "app.lan", Addrs: []config.SteeredAddr{
+			{Addr: "10.0.1.5", Weight: 3, Clients: []string{"192.168.1.0/24", "10.9.9.9"}},
+			{Addr: "10.0.2.5"},
+		}},
+		{Name: "web.lan", Addrs: []config.SteeredAddr{{Addr: "10.0.3.1", Weight: 1}}},
+	}, cfg.CustomDNS.Steering)
+
+	for _, v := range []string{"app.lan", "app.lan=", "app.lan=10.0.1.5*heavy"} {
+		cfg = newConfig()
+		err = cfg.ApplyEnv(envLookup(map[string]string{"HYDRADNS_CUSTOM_DNS_STEERING": v}))
+		assert.Error(t, err, v)
+	}
+}
+
+func TestApplyEnv_SharedCache(t *testing.T) {
+	cfg := newConfig()
+	require.NoError(t, cfg.ApplyEnv(envLookup(map[string]string{
+		"HYDRADNS_CACHE_SHARED_BACKEND":    "memcached",
+		"HYDRADNS_CACHE_SHARED_ADDRESS":    "cache.lan:11211",
+		"HYDRADNS_CACHE_SHARED_TIMEOUT":    "20ms",
+		"HYDRADNS_CACHE_SHARED_KEY_PREFIX": "dns-edge",
+	})))
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, config.SharedCacheConfig{
+		Backend:   config.SharedCacheMemcached,
+		Address:   "cache.lan:11211",
+		Timeout:   "20ms",
+		KeyPrefix: "dns-edge",
+	}, cfg.Cache.Shared)
+}
+
+func TestApplyEnv_QTypeRules(t *testing.T) {
+	cfg := newConfig()
+	err := cfg.ApplyEnv(envLookup(map[string]string{
+		"HYDRADNS_QTYPE_RULES": "ANY|TYPE65535=refused, PTR@192.168.50.0/24|10.0.0.1=nxdomain",
+	}))
+	require.NoError(t, err)
+
+	assert.Equal(t, []config.QTypeRule{
+		{QTypes: []string{"ANY", "TYPE65535"}, Action: "refused"},
+		{QTypes: []string{"PTR"}, Clients: []string{"192.168.50.0/24", "10.0.0.1"}, Action: "nxdomain"},
+	}, cfg.Server.QTypeRules)
+
+	for _, v := range []string{"ANY", "=drop", "@10.0.0.1=drop"} {
+		cfg = newConfig()
+		err = cfg.ApplyEnv(envLookup(map[string]string{"HYDRADNS_QTYPE_RULES": v}))
+		assert.Error(t, err, v)
+	}
+}
+
+func TestApplyEnv_ResponsePipeline(t *testing.T) {
+	cfg := newConfig()
+	err := cfg.ApplyEnv(envLookup(map[string]string{
+		"HYDRADNS_RESPONSE_PIPELINE": "ttl_clamp=60:3600, filter_aaaa@192.168.1.0/24|10.0.0.1, reorder=random, " +
+			"ede=SERVFAIL:22:upstream: unreachable, scrub=TXT|TYPE13",
+	}))
+	require.NoError(t, err)
+
+	assert.Equal(t, []config.ResponseStep{
+		{Type: "ttl_clamp", MinTTL: 60, MaxTTL: 3600},
+		{Type: "filter_aaaa", Clients: []string{"192.168.1.0/24", "10.0.0.1"}},
+		{Type: "reorder", Order: "random"},
+		{Type: "ede", RCode: "SERVFAIL", Code: 22, Text: "upstream: unreachable"},
+		{Type: "scrub", RecordTypes: []string{"TXT", "TYPE13"}},
+	}, cfg.Server.ResponsePipeline)
+	require.NoError(t, cfg.Validate())
+
+	for _, v := range []string{"=60:3600", "ttl_clamp=x", "ede=SERVFAIL:x"} {
+		cfg = newConfig()
+		err = cfg.ApplyEnv(envLookup(map[string]string{"HYDRADNS_RESPONSE_PIPELINE": v}))
+		assert.Error(t, err, v)
+	}
+}
+
+func TestApplyEnv_RateLimitPrefixLengths(t *testing.T) {
+	cfg := newConfig()
+	err := cfg.ApplyEnv(envLookup(map[string]string{
+		"HYDRADNS_RATE_LIMIT_PREFIX_LENGTHS": "32, 56",
+		"HYDRADNS_RATE_LIMIT_EXEMPT_LOCAL":   "true",
+	}))
+	require.NoError(t, err)
+
+	assert.Equal(t, 32, cfg.RateLimit.IPv4PrefixLen)
+	assert.Equal(t, 56, cfg.RateLimit.IPv6PrefixLen)
+	assert.True(t, cfg.RateLimit.ExemptLocal)
+
+	for _, v := range []string{"24", "24,64,48", "x,64"} {
+		cfg = newConfig()
+		err = cfg.ApplyEnv(envLookup(map[string]string{"HYDRADNS_RATE_LIMIT_PREFIX_LENGTHS": v}))
+		assert.Error(t, err, v)
+	}
+}
+
+func TestApplyEnv_ReportsAllInvalidValues(t *testing.T) {
+	cfg := newConfig()
+	err := cfg.ApplyEnv(envLookup(map[string]string{
+		"HYDRADNS_PORT":              "dns",
+		"HYDRADNS_FILTERING_ENABLED": "maybe",
+		"HYDRADNS_HOST":              "127.0.0.1",
+	}))
+	require.Error(t, err)
+
+	assert.Contains(t, err.Error(), "HYDRADNS_PORT")
+	assert.Contains(t, err.Error(), "HYDRADNS_FILTERING_ENABLED")
+	assert.Equal(t, 53, cfg.Server.Port, "invalid value leaves the field unchanged")
+	assert.Equal(t, "127.0.0.1", cfg.Server.Host, "valid values are still applied")
+}
+
+func TestEnvVarNames(t *testing.T) {
+	names := config.EnvVarNames()
+
+	assert.Contains(t, names, "HYDRADNS_UPSTREAMS")
+	assert.Contains(t, names, "HYDRADNS_FILTERING_ENABLED")
+	for _, n := range names {
+		assert.Regexp(t, `^HYDRADNS_[A-Z_]+$`, n)
+	}
+}
@@ -1,8 +1,10 @@
 package config
 
 import (
+	"net"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // WorkersAutoStr is the string constant for automatic worker mode.
@@ -57,6 +59,120 @@ type ServerConfig struct {
 	UpstreamSocketPoolSize int           `json:"upstream_socket_pool_size"`
 	EnableTCP              bool          `json:"enable_tcp"`
 	TCPFallback            bool          `json:"tcp_fallback"`
+	// MaxCNAMEChain is the maximum number of CNAMEs followed for one query
+	// before answering SERVFAIL (default: 8)
+	MaxCNAMEChain int `json:"max_cname_chain"`
+	// UDPListeners is the number of SO_REUSEPORT UDP sockets, independent of
+	// GOMAXPROCS (default: 0, one per CPU)
+	UDPListeners int `json:"udp_listeners"`
+	// UDPRecvBuffer is the SO_RCVBUF size in bytes of each UDP socket
+	// (default: 0, start at 4 MiB and grow on kernel drops up to 32 MiB)
+	UDPRecvBuffer int `json:"udp_recv_buffer"`
+	// UDPSendBuffer is the SO_SNDBUF size in bytes of each UDP socket (default: 0, 4 MiB)
+	UDPSendBuffer int `json:"udp_send_buffer"`
+	// TCPReadTimeout bounds reading one message from a TCP client (default: "10s")
+	TCPReadTimeout string `json:"tcp_read_timeout"`
+	// TCPIdleTimeout closes TCP connections without a query for this long (default: "30s")
+	TCPIdleTimeout string `json:"tcp_idle_timeout"`
+	// TCPMaxConnsPerIP is the maximum concurrent TCP connections per client IP (default: 10)
+	TCPMaxConnsPerIP int `json:"tcp_max_conns_per_ip"`
+	// TCPMaxQueriesPerConn closes a TCP connection after this many queries (default: 100)
+	TCPMaxQueriesPerConn int `json:"tcp_max_queries_per_conn"`
+	// TCPListeners is the number of SO_REUSEPORT TCP listeners (default: 0, one per CPU)
+	TCPListeners int `json:"tcp_listeners"`
+	// ShutdownDrain keeps answering queries for this long after a shutdown
+	// signal while /ready reports not ready, so orchestrators stop routing
+	// to the node before its listeners close (default: "0s")
+	ShutdownDrain string `json:"shutdown_drain"`
+	// ProxyProtocolTrusted lists load balancers (IP addresses or CIDR
+	// prefixes) whose TCP connections start with a PROXY protocol v2
+	// header carrying the real client address (default: none)
+	ProxyProtocolTrusted []string `json:"proxy_protocol_trusted,omitempty"`
+	// RecursionClients lists the clients (IP addresses or CIDR prefixes)
+	// offered recursion. Other clients only get answers from custom DNS and
+	// filtering, and REFUSED otherwise (default: none, meaning all clients)
+	RecursionClients []string `json:"recursion_clients,omitempty"`
+	// ListenerProfiles are additional listeners, each with its own resolver
+	// chain (default: none)
+	ListenerProfiles []ListenerProfile `json:"listener_profiles,omitempty"`
+	// QTypeRules answer queries of the listed types without resolving
+	// them; the first matching rule wins (default: none)
+	QTypeRules []QTypeRule `json:"qtype_rules,omitempty"`
+	// ResponsePipeline rewrites responses after resolution, before they are
+	// sent; steps run in order (default: none)
+	ResponsePipeline []ResponseStep `json:"response_pipeline,omitempty"`
+}
+
+// Response pipeline step types for ResponseStep.
+const (
+	ResponseStepTTLClamp   = "ttl_clamp"   // Clamp record TTLs to MinTTL..MaxTTL
+	ResponseStepFilterAAAA = "filter_aaaa" // Remove AAAA answers to AAAA queries
+	ResponseStepReorder    = "reorder"     // Reorder the records of each answer RRset
+	ResponseStepEDE        = "ede"         // Add an Extended DNS Error to responses with RCode
+	ResponseStepScrub      = "scrub"       // Remove records of RecordTypes from every section
+)
+
+// ResponseStep is one step of the response pipeline. Type selects what it
+// does; the other fields configure the types that use them.
+//
+// Response steps are per node and are not synced between cluster nodes.
+type ResponseStep struct {
+	// Type is "ttl_clamp", "filter_aaaa", "reorder", "ede", or "scrub".
+	Type string `json:"type"`
+	// Clients limits the step to these IP addresses or CIDR prefixes
+	// (default: none, meaning all clients)
+	Clients []string `json:"clients,omitempty"`
+	// MinTTL and MaxTTL are the ttl_clamp bounds in seconds; 0 leaves a
+	// bound unset, but one is required
+	MinTTL int `json:"min_ttl,omitempty"`
+	MaxTTL int `json:"max_ttl,omitempty"`
+	// Order is the reorder order: "round_robin" (default) or "random"
+	Order string `json:"order,omitempty"`
+	// RCode is the response code ede adds its error to (default: "SERVFAIL")
+	RCode string `json:"rcode,omitempty"`
+	// Code and Text are the Extended DNS Error info code and extra text ede adds
+	Code int    `json:"code,omitempty"`
+	Text string `json:"text,omitempty"`
+	// RecordTypes are the types scrub removes, e.g. ["TXT", "TYPE13"]
+	RecordTypes []string `json:"record_types,omitempty"`
+}
+
+// QTypeRule answers queries for some record types from some clients with a
+// fixed response before resolution, e.g. refusing ANY everywhere or PTR
+// from a guest VLAN.
+//
+// QType rules are per node and are not synced between cluster nodes.
+type QTypeRule struct {
+	// QTypes are type mnemonics ("ANY", "TXT") or numbers ("TYPE65535")
+	QTypes []string `json:"qtypes"`
+	// Clients limits the rule to these IP addresses or CIDR prefixes
+	// (default: none, meaning all clients)
+	Clients []string `json:"clients,omitempty"`
+	// Action is the response: "refused" (default), "nxdomain", "nodata"
+	// (NOERROR without answers), or "drop" (no response)
+	Action string `json:"action"`
+}
+
+// ListenerProfile is an additional DNS listener (UDP, and TCP when enabled)
+// with its own resolver chain, such as an unfiltered port for an admin VLAN
+// next to the filtered default listener. Custom DNS, upstreams, the cache,
+// and rate limits are shared with the default listener.
+//
+// Listener profiles are per node and are not synced between cluster nodes.
+type ListenerProfile struct {
+	// Name identifies the profile in logs and stats
+	Name string `json:"name"`
+	// Host is the bind address (default: server.host)
+	Host string `json:"host"`
+	Port int    `json:"port"`
+	// Unfiltered answers queries on this listener without the filtering
+	// policy
+	Unfiltered bool `json:"unfiltered"`
+}
+
+// Addr returns the host:port the profile listens on.
+func (p ListenerProfile) Addr() string {
+	return net.JoinHostPort(p.Host, strconv.Itoa(p.Port))
 }
 
 // UpstreamConfig contains upstream DNS server settings.
@@ -65,6 +181,54 @@ type UpstreamConfig struct {
 	UDPTimeout string   `json:"udp_timeout"` // Timeout for UDP queries (e.g., "3s")
 	TCPTimeout string   `json:"tcp_timeout"` // Timeout for TCP queries (e.g., "5s")
 	MaxRetries int      `json:"max_retries"` // Max retries per upstream on timeout
+	// Strategy picks the order upstreams are tried in: sequential (default),
+	// round_robin, latency, or random.
+	Strategy string `json:"strategy"`
+	// StageTimeout bounds the upstream stage of the resolver chain (e.g.
+	// "4s"), across all retries and servers. Empty: only the query's own
+	// deadline applies.
+	StageTimeout string `json:"stage_timeout,omitempty"`
+	// EDNSOptions controls which EDNS options are forwarded upstream.
+	// Options without a rule pass through unchanged.
+	EDNSOptions []EDNSOptionRule `json:"edns_options,omitempty"`
+	// DoHServers are DNS-over-HTTPS endpoints (RFC 8484), e.g.
+	// "https://1.1.1.1/dns-query", tried in order for names a transport
+	// rule sends over DoH.
+	DoHServers []string `json:"doh_servers,omitempty"`
+	// TransportRules force the transport used for names at or below a
+	// domain, whatever the servers and strategy above.
+	TransportRules []TransportRule `json:"transport_rules,omitempty"`
+}
+
+// Transports for TransportRule.
+const (
+	TransportTCP = "tcp" // Query the upstream servers over TCP only
+	TransportDoH = "doh" // Query the DoH servers only
+)
+
+// TransportRule forces the transport for a domain and the names below it.
+type TransportRule struct {
+	Domain string `json:"domain"`
+	// Transport is "tcp" or "doh". Queries never fall back to plain UDP.
+	Transport string `json:"transport"`
+}
+
+// EDNS option actions for EDNSOptionRule.
+const (
+	EDNSActionPass    = "pass"    // Forward the option unchanged
+	EDNSActionStrip   = "strip"   // Remove the option from queries and responses
+	EDNSActionRewrite = "rewrite" // Send the option upstream with Value as its data
+)
+
+// EDNSOptionRule sets how the forwarder treats one EDNS option.
+type EDNSOptionRule struct {
+	// Option is "ecs", "cookie", "padding", "ede", or a numeric option code.
+	Option string `json:"option"`
+	// Action is "pass", "strip", or "rewrite".
+	Action string `json:"action"`
+	// Value is the rewritten option data: a CIDR prefix for ecs
+	// (e.g. "192.0.2.0/24"), hex-encoded bytes for other options.
+	Value string `json:"value,omitempty"`
 }
 
 // CustomDNSConfig contains simple custom DNS mappings for homelab use.
@@ -77,6 +241,152 @@ type CustomDNSConfig struct {
 	// CNAMEs maps alias names to canonical names
 	// Example: "www.homelab.local": "homelab.local"
 	CNAMEs map[string]string `json:"cnames,omitempty"`
+
+	// SearchDomains are appended, in order, to single-label queries that
+	// match no custom record, so "nas" answers as "nas.home.arpa" for
+	// clients without working search settings. Search domains are per node.
+	// Example: ["home.arpa", "lan"]
+	SearchDomains []string `json:"search_domains,omitempty"`
+
+	// AnswerOrder orders the addresses of names with several A or AAAA
+	// records in each response: fixed (default, configured order),
+	// round_robin (rotated by one per query), or random. Per node.
+	AnswerOrder string `json:"answer_order,omitempty"`
+
+	// HealthChecks probe the addresses of custom hosts. Addresses that fail
+	// are left out of answers until they pass again. Per node.
+	HealthChecks []RecordHealthCheck `json:"health_checks,omitempty"`
+
+	// Steering selects which addresses of custom hosts are answered, by
+	// client subnet and weight, e.g. to steer traffic between two sites.
+	// Per node.
+	Steering []HostSteering `json:"steering,omitempty"`
+}
+
+// HostSteering selects the addresses answered for one custom DNS host.
+//
+// Clients are first matched to the addresses listing their subnet; when
+// none does, to the addresses without clients; when there are none of
+// those either, to all addresses. If any of the matched addresses has a
+// weight, one of those with a weight is answered, chosen in proportion to
+// the weights. Otherwise all matched addresses are answered.
+type HostSteering struct {
+	// Name is the custom host being steered.
+	Name  string        `json:"name"`
+	Addrs []SteeredAddr `json:"addrs"`
+}
+
+// SteeredAddr is how one address of a steered host is answered. Addresses
+// of the host that are not listed have no clients and no weight.
+type SteeredAddr struct {
+	Addr string `json:"addr"`
+	// Weight is the address's share of answers. Addresses without a weight
+	// are only answered when no matched address has one.
+	Weight int `json:"weight,omitempty"`
+	// Clients are the IP addresses or CIDR prefixes this address is
+	// answered to. Empty means clients matching no other address.
+	Clients []string `json:"clients,omitempty"`
+}
+
+// Health check types for RecordHealthCheck.
+const (
+	HealthCheckTCP  = "tcp"  // Connect to the port
+	HealthCheckHTTP = "http" // GET the path; 2xx and 3xx are healthy
+)
+
+// RecordHealthCheck probes every address of one custom DNS host.
+type RecordHealthCheck struct {
+	// Name is the custom host whose addresses are probed.
+	Name string `json:"name"`
+	// Type is "tcp" or "http".
+	Type string `json:"type"`
+	Port int    `json:"port"`
+	// Path is the HTTP request path (default: "/").
+	Path string `json:"path,omitempty"`
+	// Interval is the time between probes of an address (default: "10s").
+	Interval string `json:"interval,omitempty"`
+	// Timeout bounds each probe (default: "2s").
+	Timeout string `json:"timeout,omitempty"`
+	// Failures is the number of failed probes in a row after which an
+	// address is left out (default: 2). One passing probe brings it back.
+	Failures int `json:"failures,omitempty"`
+}
+
+// CacheConfig controls the response cache size and eviction, and negative
+// and SERVFAIL response caching.
+//
+// TTLs are Go duration strings. A TTL of "0s" disables caching for that
+// response type. Positive responses always use the record TTLs.
+type CacheConfig struct {
+	// MaxEntries is the maximum number of cached responses (default: 20000)
+	MaxEntries int `json:"max_entries"`
+	// MaxBytes caps the total size of cached responses in bytes
+	// (default: 67108864, 64 MiB); -1 removes the cap
+	MaxBytes int `json:"max_bytes"`
+	// MaxEntryBytes is the largest response that is cached, in bytes
+	// (default: 16384); larger responses are always forwarded. -1 removes
+	// the limit
+	MaxEntryBytes int `json:"max_entry_bytes"`
+	// EvictionPolicy picks the entry removed when the cache is full:
+	// "lru" (least recently used, default), "lfu" (least frequently used),
+	// or "ttl" (closest to expiry)
+	EvictionPolicy string `json:"eviction_policy"`
+	// DisableNegative turns off caching of NXDOMAIN, NODATA, and SERVFAIL responses.
+	DisableNegative bool `json:"disable_negative"`
+	// ServfailTTL is how long upstream SERVFAIL responses are cached (default: "30s")
+	ServfailTTL string `json:"servfail_ttl"`
+	// NegativeTTL is the NXDOMAIN/NODATA TTL when the response has no SOA (default: "5m")
+	NegativeTTL string `json:"negative_ttl"`
+	// ZoneOverrides apply different settings to names at or below a zone.
+	// The most specific (longest) matching zone wins.
+	ZoneOverrides []CacheZoneOverride `json:"zone_overrides,omitempty"`
+	// BypassDomains are never cached, e.g. dynamic DNS names or health
+	// checks that must always reflect upstream. Each entry covers the name
+	// and everything below it.
+	// Example: ["dyn.example.com", "health.corp.example"]
+	BypassDomains []string `json:"bypass_domains,omitempty"`
+	// AggressiveNSEC answers queries from NSEC records of earlier
+	// DNSSEC-validated negative answers (RFC 8198) instead of asking
+	// upstream. Requires a validating upstream resolver.
+	AggressiveNSEC bool `json:"aggressive_nsec"`
+	// Shared is an optional external cache that HydraDNS nodes behind a
+	// load balancer share as a second tier behind the in-memory cache.
+	Shared SharedCacheConfig `json:"shared"`
+}
+
+// Shared cache backends for SharedCacheConfig.
+const (
+	SharedCacheRedis     = "redis"
+	SharedCacheMemcached = "memcached"
+)
+
+// SharedCacheConfig connects the response cache to a redis or memcached
+// server. Responses missing from the local cache are looked up there
+// before querying upstream, and every cacheable upstream response is
+// stored there too, so one node's answers (positive and negative) serve
+// the others.
+type SharedCacheConfig struct {
+	// Backend is "redis" or "memcached"; empty disables the shared cache
+	Backend string `json:"backend,omitempty"`
+	// Address is the server's host:port, e.g. "10.0.0.5:6379"
+	Address string `json:"address,omitempty"`
+	// Password authenticates to redis (AUTH). Not supported by memcached.
+	Password string `json:"password,omitempty"`
+	// Timeout bounds each lookup or store; a slow or unreachable server
+	// then costs at most this much per cache miss (default: "50ms")
+	Timeout string `json:"timeout,omitempty"`
+	// KeyPrefix starts every key, separating HydraDNS entries from other
+	// users of the server (default: "hydradns")
+	KeyPrefix string `json:"key_prefix,omitempty"`
+}
+
+// CacheZoneOverride overrides CacheConfig for a zone and its subdomains.
+// Unset fields inherit the global setting.
+type CacheZoneOverride struct {
+	Zone            string `json:"zone"`
+	DisableNegative *bool  `json:"disable_negative,omitempty"`
+	ServfailTTL     string `json:"servfail_ttl,omitempty"`
+	NegativeTTL     string `json:"negative_ttl,omitempty"`
 }
 
 // LoggingConfig contains logging settings.
@@ -86,6 +396,13 @@ type LoggingConfig struct {
 	StructuredFormat string            `json:"structured_format"`
 	IncludePID       bool              `json:"include_pid"`
 	ExtraFields      map[string]string `json:"extra_fields,omitempty"`
+	// SlowQueryThreshold logs queries answered slower than this end to
+	// end, or whose upstream exchange took longer, whatever the log level
+	// (default: "0s", disabled)
+	SlowQueryThreshold string `json:"slow_query_threshold"`
+	// SlowQueryMaxPerMinute caps the slow queries logged per minute; the
+	// number left out is logged with the next one (default: 60)
+	SlowQueryMaxPerMinute int `json:"slow_query_max_per_minute"`
 }
 
 // FilteringConfig controls domain filtering (blocklists/whitelists).
@@ -97,6 +414,16 @@ type FilteringConfig struct {
 	BlacklistDomains []string          `json:"blacklist_domains,omitempty"`
 	Blocklists       []BlocklistConfig `json:"blocklists,omitempty"`
 	RefreshInterval  string            `json:"refresh_interval"`
+	// BlockTTL is the TTL in seconds of block page answers, and
+	// NegativeTTL the negative caching TTL of blocked NXDOMAIN and NODATA
+	// answers (default: 10 each). Short TTLs keep clients from caching a
+	// block for long after the domain is unblocked.
+	BlockTTL    int `json:"block_ttl"`
+	NegativeTTL int `json:"negative_ttl"`
+	// TemporaryAllows maps domains allowed for a limited time, but not
+	// their subdomains, to when the allow expires. Set through
+	// POST /api/v1/filtering/allow-temporarily.
+	TemporaryAllows map[string]time.Time `json:"temporary_allows,omitempty"`
 }
 
 // BlocklistConfig defines a remote blocklist source.
@@ -106,6 +433,300 @@ type BlocklistConfig struct {
 	Format string `json:"format"` // "auto", "adblock", "hosts", "domains"
 }
 
+// BlockPageConfig controls the optional block page server. When enabled,
+// blocked A and AAAA queries are answered with the block page addresses
+// instead of NXDOMAIN, so browsers show a "blocked by HydraDNS" page.
+//
+// The block page is per node and is not synced between cluster nodes.
+type BlockPageConfig struct {
+	Enabled bool `json:"enabled"`
+	// IPv4 and IPv6 are returned for blocked A and AAAA queries and should
+	// reach this node. At least one is required when enabled.
+	IPv4 string `json:"ipv4,omitempty"`
+	IPv6 string `json:"ipv6,omitempty"`
+	// HTTPAddr is the HTTP listen address (default: ":80")
+	HTTPAddr string `json:"http_addr"`
+	// HTTPSAddr is the HTTPS listen address, used only when TLSCert and
+	// TLSKey are set (default: ":443")
+	HTTPSAddr string `json:"https_addr"`
+	TLSCert   string `json:"tls_cert,omitempty"`
+	TLSKey    string `json:"tls_key,omitempty"`
+}
+
+// CaptivePortalConfig redirects clients on onboarding networks, such as a
+// guest or IoT VLAN, to a captive portal: every A and AAAA query from the
+// listed clients is answered with the portal addresses, except for allowed
+// domains, which resolve normally.
+//
+// The captive portal is per node and is not synced between cluster nodes.
+type CaptivePortalConfig struct {
+	Enabled bool `json:"enabled"`
+	// Clients are the redirected client addresses or CIDR prefixes.
+	// Required when enabled.
+	Clients []string `json:"clients"`
+	// IPv4 and IPv6 are the portal addresses returned for A and AAAA
+	// queries. At least one is required when enabled.
+	IPv4 string `json:"ipv4,omitempty"`
+	IPv6 string `json:"ipv6,omitempty"`
+	// AllowDomains resolve normally for redirected clients, with their
+	// subdomains, e.g. the portal's own name or an identity provider.
+	AllowDomains []string `json:"allow_domains,omitempty"`
+	// TTL is the TTL of redirect answers in seconds (default: 10), short so
+	// clients resolve normally soon after they leave the portal network.
+	TTL int `json:"ttl"`
+}
+
+// DDRConfig advertises encrypted transports to clients through Discovery
+// of Designated Resolvers (RFC 9462): SVCB queries for _dns.resolver.arpa
+// (and _dns.<target>) are answered with the DoH and DoT endpoints, so
+// clients that find this server over plain DNS upgrade to an encrypted
+// transport. The endpoints themselves are served by a TLS front end, such
+// as a reverse proxy forwarding to HydraDNS (see ServerConfig.ProxyProtocolTrusted).
+//
+// DDR settings are per node and are not synced between cluster nodes.
+type DDRConfig struct {
+	Enabled bool `json:"enabled"`
+	// Target is the resolver hostname clients connect to, and must be
+	// covered by the endpoint's TLS certificate. Required when enabled.
+	Target string `json:"target"`
+	// DoHPort is the DNS-over-HTTPS port; 0 advertises no DoH endpoint
+	DoHPort int `json:"doh_port,omitempty"`
+	// DoHPath is the DoH URI template (default: "/dns-query{?dns}")
+	DoHPath string `json:"doh_path,omitempty"`
+	// DoTPort is the DNS-over-TLS port; 0 advertises no DoT endpoint
+	DoTPort int `json:"dot_port,omitempty"`
+	// IPv4Hints and IPv6Hints are the target's addresses, sent along so
+	// clients need no extra lookup of Target.
+	IPv4Hints []string `json:"ipv4_hints,omitempty"`
+	IPv6Hints []string `json:"ipv6_hints,omitempty"`
+	// TTL is the TTL of DDR answers in seconds (default: 300)
+	TTL int `json:"ttl"`
+}
+
+// SynthPTRConfig synthesizes PTR answers for addresses in local IPv6
+// prefixes, whose SLAAC and DHCPv6 addresses have no configured names, so
+// their reverse lookups do not all fail: the ip6.arpa name of an address
+// in one of Prefixes is answered with Template, "{ip}" replaced by the
+// address with its colons as dashes, e.g. "2001-db8--1.lan.home.arpa".
+//
+// PTR synthesis is per node and is not synced between cluster nodes.
+type SynthPTRConfig struct {
+	Enabled bool `json:"enabled"`
+	// Prefixes are the local IPv6 prefixes, e.g. "2001:db8:1::/64".
+	// Required when enabled.
+	Prefixes []string `json:"prefixes"`
+	// Template is the hostname answered; it must contain "{ip}".
+	// Required when enabled.
+	Template string `json:"template"`
+	// TTL is the TTL of synthesized answers in seconds (default: 300)
+	TTL int `json:"ttl"`
+}
+
+// FilterAAAAConfig removes AAAA records from the answers given to clients
+// on networks where IPv6 is broken, like dnsmasq's filter-AAAA: their AAAA
+// queries get an empty NOERROR (NODATA) answer, so they fall back to IPv4
+// at once instead of timing out on IPv6 first.
+//
+// AAAA filtering is per node and is not synced between cluster nodes.
+type FilterAAAAConfig struct {
+	Enabled bool `json:"enabled"`
+	// Clients are the filtered client addresses or CIDR prefixes. Empty
+	// filters every client.
+	Clients []string `json:"clients,omitempty"`
+}
+
+// GeoIPConfig configures optional MaxMind GeoIP2/GeoLite2 lookups. With a
+// database loaded, the country and ASN of upstream servers and of forwarded
+// answer addresses are logged, and answers resolving into a blocked ASN or
+// country are refused with NXDOMAIN.
+//
+// GeoIP settings are per node and are not synced between cluster nodes.
+type GeoIPConfig struct {
+	// CountryDB is the path of a Country (or City) .mmdb database
+	CountryDB string `json:"country_db,omitempty"`
+	// ASNDB is the path of an ASN .mmdb database
+	ASNDB string `json:"asn_db,omitempty"`
+	// BlockASNs blocks answers containing an address in these autonomous
+	// systems (requires ASNDB)
+	BlockASNs []uint32 `json:"block_asns,omitempty"`
+	// BlockCountries blocks answers containing an address in these
+	// countries, as ISO 3166-1 alpha-2 codes (requires CountryDB)
+	BlockCountries []string `json:"block_countries,omitempty"`
+}
+
+// AnomalyConfig controls detection of DNS tunneling and other anomalous
+// query patterns. Flagged clients are logged as warnings, listed by the
+// anomalies API endpoint, and counted in the DNS statistics.
+//
+// Anomaly settings are per node and are not synced between cluster nodes.
+type AnomalyConfig struct {
+	Enabled bool `json:"enabled"`
+	// Window is the per-client period over which query ratios are measured,
+	// and the minimum time between two alerts of one kind for a client
+	// (default: "1m")
+	Window string `json:"window"`
+	// MinQueries is the number of queries a client must send in a window
+	// before its NXDOMAIN and TXT ratios are judged (default: 50)
+	MinQueries int `json:"min_queries"`
+	// NXDOMAINRatio flags clients whose share of NXDOMAIN answers reaches
+	// this value (default: 0.5)
+	NXDOMAINRatio float64 `json:"nxdomain_ratio"`
+	// TXTRatio flags clients whose share of TXT and NULL queries reaches
+	// this value (default: 0.5)
+	TXTRatio float64 `json:"txt_ratio"`
+	// MaxLabelLength flags query names with a longer label (default: 50)
+	MaxLabelLength int `json:"max_label_length"`
+	// MinLabelEntropy flags labels of 24 or more characters whose Shannon
+	// entropy in bits per character reaches this value (default: 4.0)
+	MinLabelEntropy float64 `json:"min_label_entropy"`
+}
+
+// ThreatIntelConfig controls lookups of newly observed domains against an
+// external threat intelligence feed. Domains the feed lists as malicious are
+// blocked for BlockTTL through the filtering policy.
+//
+// Threat intelligence settings are per node and are not synced between
+// cluster nodes.
+// Note: AuthKey is a secret and should not be returned by API endpoints.
+type ThreatIntelConfig struct {
+	Enabled bool `json:"enabled"`
+	// Provider is the feed to query; only "urlhaus" is supported (default)
+	Provider string `json:"provider"`
+	// URL overrides the provider's API endpoint
+	URL string `json:"url,omitempty"`
+	// AuthKey is sent to the provider when set
+	AuthKey string `json:"auth_key,omitempty"`
+	// BlockTTL is how long a malicious domain stays blocked (default: "24h")
+	BlockTTL string `json:"block_ttl"`
+	// CacheTTL is how long a verdict is remembered before a domain is
+	// checked again (default: "6h")
+	CacheTTL string `json:"cache_ttl"`
+	// RateLimit is the maximum number of feed lookups per second (default: 1)
+	RateLimit float64 `json:"rate_limit"`
+}
+
+// NRDConfig controls newly registered domain (NRD) detection: domains first
+// queried on this node within the last Days days are flagged or blocked, as
+// most phishing campaigns use freshly registered domains. Domains are tracked
+// by registrable name (e.g. example.co.uk) in the database.
+//
+// NRD settings are per node and are not synced between cluster nodes.
+type NRDConfig struct {
+	Enabled bool `json:"enabled"`
+	// Action is "flag" to log new domains (default) or "block" to block them
+	Action string `json:"action"`
+	// Days is how long a domain counts as new after it was first seen
+	// (default: 30)
+	Days int `json:"days"`
+}
+
+// IdentityConfig sets the answers to CHAOS-class TXT queries that identify
+// the server, such as "dig CH TXT hostname.bind", which tell which anycast or
+// cluster node answered. An empty value refuses the query.
+//
+// Identity settings are per node and are not synced between cluster nodes.
+type IdentityConfig struct {
+	// Version answers version.bind and version.server
+	Version string `json:"version"`
+	// Hostname answers hostname.bind
+	Hostname string `json:"hostname"`
+	// ServerID answers id.server (RFC 4892); defaults to Hostname
+	ServerID string `json:"server_id"`
+}
+
+// AuditConfig controls the outbound query audit log: every query sent to an
+// upstream server is appended to Path with its domain, upstream, and
+// transport, in a hash chain that shows later tampering. The log is kept
+// apart from the client query logs.
+//
+// Audit settings are per node and are not synced between cluster nodes.
+type AuditConfig struct {
+	Enabled bool `json:"enabled"`
+	// Path is the audit log file; required when enabled
+	Path string `json:"path"`
+}
+
+// PrivacyConfig controls what the client query logs keep: the debug query
+// log, the live query event stream, and recorded anomaly alerts.
+//
+// Privacy settings are per node and are not synced between cluster nodes.
+type PrivacyConfig struct {
+	// Level is "full" (default), "anonymized" (anonymized client
+	// addresses), "domains" (query names only), or "none".
+	Level string `json:"level"`
+	// Anonymize is how the anonymized level hides client addresses:
+	// "truncate" (default) to their /24 (IPv4) or /48 (IPv6) network, or
+	// "hash" with a key that changes on restart.
+	Anonymize string `json:"anonymize"`
+}
+
+// CanaryConfig controls the DNS health canary: a background self-check that
+// resolves Domains through the resolver chain every Interval and records
+// success and latency for the deep health endpoint. When every domain
+// fails for FailureThreshold rounds in a row, DNS is reported down and an
+// alert is logged, even though the process itself is alive.
+//
+// Canary settings are per node and are not synced between cluster nodes.
+type CanaryConfig struct {
+	Enabled bool `json:"enabled"`
+	// Domains are the names resolved in each round (default: example.com
+	// and iana.org). They must not be blocked by filtering.
+	Domains []string `json:"domains"`
+	// Interval is the time between rounds (default: "30s")
+	Interval string `json:"interval"`
+	// Timeout bounds each canary query (default: "5s")
+	Timeout string `json:"timeout"`
+	// FailureThreshold is the number of failed rounds in a row after which
+	// DNS is reported down (default: 3)
+	FailureThreshold int `json:"failure_threshold"`
+}
+
+// ShadowConfig controls shadow upstreams: a share of the queries the
+// configured upstreams answer is sent again, in the background, to the
+// shadow Servers, and the answers and latencies are compared. Clients always
+// get the primary answer; differing answers are logged. Use it to try a new
+// upstream provider on live traffic before switching to it.
+//
+// Shadow settings are per node and are not synced between cluster nodes.
+type ShadowConfig struct {
+	Enabled bool `json:"enabled"`
+	// Servers are the shadow upstream IP addresses, queried on port 53 in
+	// failover order; required when enabled
+	Servers []string `json:"servers"`
+	// Percent is the share of upstream-answered queries mirrored, above 0
+	// and at most 100 (default: 10)
+	Percent float64 `json:"percent"`
+	// Timeout bounds each shadow query (default: "2s")
+	Timeout string `json:"timeout"`
+}
+
+// AdvertiseConfig controls DNS advertisement to the network's router or
+// DHCP server: when the addresses HydraDNS listens on change, they are
+// POSTed to WebhookURL so the DHCP DNS server options (option 6 for IPv4,
+// option 23 for DHCPv6) can be updated. ClientSubnets, when set, also logs
+// a warning the first time a client queries from a subnet outside them,
+// whether or not the webhook is enabled.
+//
+// Advertise settings are per node and are not synced between cluster nodes.
+// Note: WebhookSecret is a secret and should not be returned by API endpoints.
+type AdvertiseConfig struct {
+	Enabled bool `json:"enabled"`
+	// WebhookURL receives the addresses as JSON; required when enabled
+	WebhookURL string `json:"webhook_url"`
+	// WebhookSecret, when set, signs each request body with HMAC-SHA256 in
+	// the X-HydraDNS-Signature header
+	WebhookSecret string `json:"webhook_secret,omitempty"`
+	// Interfaces limits the advertised addresses to these network
+	// interfaces (default: all but loopback)
+	Interfaces []string `json:"interfaces"`
+	// Interval is how often the listening addresses are checked for
+	// changes, at least 1s (default: "30s")
+	Interval string `json:"interval"`
+	// ClientSubnets are the IP addresses or CIDR prefixes expected to query
+	// this server
+	ClientSubnets []string `json:"client_subnets"`
+}
+
 // RateLimitConfig controls rate limiting settings.
 type RateLimitConfig struct {
 	// CleanupSeconds is how often stale entries are cleaned up (default: 60)
@@ -126,6 +747,20 @@ type RateLimitConfig struct {
 	IPQPS float64 `json:"ip_qps"`
 	// IPBurst is the per-IP burst size (default: 6000)
 	IPBurst int `json:"ip_burst"`
+	// Slip answers every Nth UDP query refused by the prefix or IP limit with
+	// an empty truncated (TC=1) response instead of dropping it, so real
+	// clients behind a spoofed source retry over TCP (default: 0 = always
+	// drop, 1 = always truncate)
+	Slip int `json:"slip"`
+	// IPv4PrefixLen is the IPv4 network size the prefix limit groups
+	// clients by (default: 24)
+	IPv4PrefixLen int `json:"ipv4_prefix_len"`
+	// IPv6PrefixLen is the IPv6 network size the prefix limit groups
+	// clients by (default: 64)
+	IPv6PrefixLen int `json:"ipv6_prefix_len"`
+	// ExemptLocal exempts link-local and unique local (fc00::/7) clients
+	// from the prefix and per-IP limits (default: false)
+	ExemptLocal bool `json:"exempt_local"`
 }
 
 // APIConfig contains management API settings.
@@ -136,6 +771,13 @@ type APIConfig struct {
 	Host    string `json:"host"`
 	Port    int    `json:"port"`
 	APIKey  string `json:"api_key,omitempty"`
+	// RateLimitRPS is the per-client-IP request rate allowed under /api/v1
+	// (default: 20, 0 = disabled)
+	RateLimitRPS float64 `json:"rate_limit_rps"`
+	// RateLimitBurst is the per-client-IP burst size (default: 2x RateLimitRPS)
+	RateLimitBurst int `json:"rate_limit_burst"`
+	// MaxBodyBytes is the largest request body accepted (default: 1 MiB)
+	MaxBodyBytes int `json:"max_body_bytes"`
 }
 
 // ClusterMode specifies the clustering mode for this instance.
@@ -177,14 +819,57 @@ type ClusterConfig struct {
 	SyncTimeout string `json:"sync_timeout,omitempty"`
 }
 
+// ClusterDiscoveryConfig publishes the cluster members in a local DNS
+// zone, so other tooling can find every HydraDNS node through DNS itself:
+// SRV records at _dns._udp.<zone> and _hydradns._tcp.<zone> point to each
+// node's DNS and API ports, and each node's name carries its addresses and
+// a TXT record with its role. The primary learns the members from their
+// syncs and passes the list on to the secondaries.
+//
+// Cluster discovery settings are per node and are not synced between
+// cluster nodes.
+type ClusterDiscoveryConfig struct {
+	Enabled bool `json:"enabled"`
+	// Zone is the zone the records are published in, e.g.
+	// "cluster.home.arpa". Required when enabled.
+	Zone string `json:"zone"`
+	// Address is the address other nodes and tools reach this node at.
+	// When empty, the primary uses the address syncs come from, and the
+	// primary itself is published without an address.
+	Address string `json:"address,omitempty"`
+	// TTL is the TTL of discovery answers in seconds (default: 60)
+	TTL int `json:"ttl"`
+	// MemberTimeout is how long, in seconds, a member that stopped syncing
+	// is still published (default: 900)
+	MemberTimeout int `json:"member_timeout"`
+}
+
 // Config is the root configuration structure.
 type Config struct {
-	Server    ServerConfig    `json:"server"`
-	Upstream  UpstreamConfig  `json:"upstream"`
-	CustomDNS CustomDNSConfig `json:"custom_dns"`
-	Logging   LoggingConfig   `json:"logging"`
-	Filtering FilteringConfig `json:"filtering"`
-	RateLimit RateLimitConfig `json:"rate_limit"`
-	API       APIConfig       `json:"api"`
-	Cluster   ClusterConfig   `json:"cluster"`
+	Server        ServerConfig        `json:"server"`
+	Upstream      UpstreamConfig      `json:"upstream"`
+	CustomDNS     CustomDNSConfig     `json:"custom_dns"`
+	Cache         CacheConfig         `json:"cache"`
+	Logging       LoggingConfig       `json:"logging"`
+	Filtering     FilteringConfig     `json:"filtering"`
+	BlockPage     BlockPageConfig     `json:"block_page"`
+	CaptivePortal CaptivePortalConfig `json:"captive_portal"`
+	FilterAAAA    FilterAAAAConfig    `json:"filter_aaaa"`
+	DDR           DDRConfig           `json:"ddr"`
+	SynthPTR      SynthPTRConfig      `json:"synth_ptr"`
+	GeoIP         GeoIPConfig         `json:"geoip"`
+	Anomaly       AnomalyConfig       `json:"anomaly"`
+	ThreatIntel   ThreatIntelConfig   `json:"threat_intel"`
+	NRD           NRDConfig           `json:"nrd"`
+	Identity      IdentityConfig      `json:"identity"`
+	Audit         AuditConfig         `json:"audit"`
+	Privacy       PrivacyConfig       `json:"privacy"`
+	Canary        CanaryConfig        `json:"canary"`
+	Shadow        ShadowConfig        `json:"shadow"`
+	Advertise     AdvertiseConfig     `json:"advertise"`
+	RateLimit     RateLimitConfig     `json:"rate_limit"`
+	API           APIConfig           `json:"api"`
+	Cluster       ClusterConfig       `json:"cluster"`
+
+	ClusterDiscovery ClusterDiscoveryConfig `json:"cluster_discovery"`
 }
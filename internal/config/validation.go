@@ -0,0 +1,99 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// FieldError is a problem with one setting.
+type FieldError struct {
+	// Field is the setting's path as used in the API and documentation,
+	// e.g. "upstream.udp_timeout" or "server.qtype_rules[2]".
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// fieldErrorf returns a *FieldError for field with a formatted message.
+func fieldErrorf(field, format string, args ...any) error {
+	return &FieldError{Field: field, Err: fmt.Errorf(format, args...)}
+}
+
+// ValidationError is returned by Config.Validate and lists every problem it
+// found, so that all of them can be fixed in one go.
+type ValidationError struct {
+	Problems []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		msgs[i] = p.Error()
+	}
+	return "invalid configuration: " + strings.Join(msgs, "; ")
+}
+
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, len(e.Problems))
+	for i, p := range e.Problems {
+		errs[i] = p
+	}
+	return errs
+}
+
+// validation collects the problems found by Validate.
+type validation struct {
+	problems []*FieldError
+}
+
+// add records err, if any. Errors that do not name a field are recorded
+// under "config".
+func (v *validation) add(err error) {
+	if err == nil {
+		return
+	}
+	var fe *FieldError
+	if !errors.As(err, &fe) {
+		fe = &FieldError{Field: "config", Err: err}
+	}
+	v.problems = append(v.problems, fe)
+}
+
+// err returns the collected problems as a *ValidationError, or nil.
+func (v *validation) err() error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: v.problems}
+}
+
+// listenerAddr is an address a listener binds.
+type listenerAddr struct {
+	host string
+	port int
+	name string
+}
+
+// overlaps reports whether two listeners would compete for the same
+// socket: same port, and the same host or a wildcard one.
+func (a listenerAddr) overlaps(b listenerAddr) bool {
+	return a.port == b.port && (a.host == b.host || isWildcardHost(a.host) || isWildcardHost(b.host))
+}
+
+// isWildcardHost reports whether host listens on every address.
+func isWildcardHost(host string) bool {
+	if host == "" {
+		return true
+	}
+	ip, err := netip.ParseAddr(host)
+	return err == nil && ip.IsUnspecified()
+}
@@ -0,0 +1,37 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jroosing/hydradns/internal/config"
+)
+
+// GetAdvertiseConfig retrieves the DNS advertisement configuration.
+func (db *DB) GetAdvertiseConfig(ctx context.Context) (*config.AdvertiseConfig, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	cfg := &config.AdvertiseConfig{}
+	var interfaces, subnets string
+	err := db.conn.QueryRowContext(ctx, `
+		SELECT enabled, webhook_url, webhook_secret, interfaces, interval, client_subnets
+		FROM config_advertise WHERE id = 1
+	`).Scan(&cfg.Enabled, &cfg.WebhookURL, &cfg.WebhookSecret, &interfaces, &cfg.Interval, &subnets)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read advertise config: %w", err)
+	}
+	for s := range strings.SplitSeq(interfaces, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			cfg.Interfaces = append(cfg.Interfaces, s)
+		}
+	}
+	for s := range strings.SplitSeq(subnets, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			cfg.ClientSubnets = append(cfg.ClientSubnets, s)
+		}
+	}
+
+	return cfg, nil
+}
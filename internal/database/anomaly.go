@@ -0,0 +1,26 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jroosing/hydradns/internal/config"
+)
+
+// GetAnomalyConfig retrieves the anomaly detection configuration.
+func (db *DB) GetAnomalyConfig(ctx context.Context) (*config.AnomalyConfig, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	cfg := &config.AnomalyConfig{}
+	err := db.conn.QueryRowContext(ctx, `
+		SELECT enabled, window_duration, min_queries, nxdomain_ratio, txt_ratio, max_label_length, min_label_entropy
+		FROM config_anomaly WHERE id = 1
+	`).Scan(&cfg.Enabled, &cfg.Window, &cfg.MinQueries, &cfg.NXDOMAINRatio, &cfg.TXTRatio,
+		&cfg.MaxLabelLength, &cfg.MinLabelEntropy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read anomaly config: %w", err)
+	}
+
+	return cfg, nil
+}
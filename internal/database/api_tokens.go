@@ -0,0 +1,138 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrAPITokenExists is returned by CreateAPIToken when a token with the same
+// name already exists.
+var ErrAPITokenExists = errors.New("API token already exists")
+
+// APIToken is a named API token with limited scopes. The token itself is
+// never stored, only its hash.
+type APIToken struct {
+	Name      string
+	TokenHash string    // Hex-encoded SHA-256 of the token
+	Scopes    []string  // See middleware.ValidScope
+	ExpiresAt time.Time // Zero if the token does not expire
+	CreatedAt string
+}
+
+// CreateAPIToken stores a new API token.
+func (db *DB) CreateAPIToken(ctx context.Context, t APIToken) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	var exists bool
+	err := db.writer.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM api_tokens WHERE name = ?)", t.Name).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check API token %s: %w", t.Name, err)
+	}
+	if exists {
+		return ErrAPITokenExists
+	}
+
+	var expiresAt int64
+	if !t.ExpiresAt.IsZero() {
+		expiresAt = t.ExpiresAt.Unix()
+	}
+	_, err = db.writer.ExecContext(ctx, `
+		INSERT INTO api_tokens (name, token_hash, scopes, expires_at)
+		VALUES (?, ?, ?, ?)
+	`, t.Name, t.TokenHash, strings.Join(t.Scopes, ","), expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to create API token %s: %w", t.Name, err)
+	}
+	return nil
+}
+
+// ListAPITokens returns all API tokens ordered by name.
+func (db *DB) ListAPITokens(ctx context.Context) ([]APIToken, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	rows, err := db.conn.QueryContext(ctx, `
+		SELECT name, token_hash, scopes, expires_at, created_at
+		FROM api_tokens ORDER BY name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query API tokens: %w", err)
+	}
+	defer rows.Close()
+
+	var tokens []APIToken
+	for rows.Next() {
+		t, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating API tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+// GetAPITokenByHash returns the token with the given hash. ok is false if
+// there is none.
+func (db *DB) GetAPITokenByHash(ctx context.Context, hash string) (APIToken, bool, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	row := db.conn.QueryRowContext(ctx, `
+		SELECT name, token_hash, scopes, expires_at, created_at
+		FROM api_tokens WHERE token_hash = ?
+	`, hash)
+	t, err := scanAPIToken(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return APIToken{}, false, nil
+	}
+	if err != nil {
+		return APIToken{}, false, err
+	}
+	return t, true, nil
+}
+
+// DeleteAPIToken removes the named token. It reports whether the token
+// existed.
+func (db *DB) DeleteAPIToken(ctx context.Context, name string) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	result, err := db.writer.ExecContext(ctx, "DELETE FROM api_tokens WHERE name = ?", name)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete API token %s: %w", name, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete API token %s: %w", name, err)
+	}
+	return n > 0, nil
+}
+
+func scanAPIToken(row interface{ Scan(...any) error }) (APIToken, error) {
+	var t APIToken
+	var scopes string
+	var expiresAt int64
+	var createdAt sql.NullString
+	if err := row.Scan(&t.Name, &t.TokenHash, &scopes, &expiresAt, &createdAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return APIToken{}, err
+		}
+		return APIToken{}, fmt.Errorf("failed to scan API token: %w", err)
+	}
+	if scopes != "" {
+		t.Scopes = strings.Split(scopes, ",")
+	}
+	if expiresAt > 0 {
+		t.ExpiresAt = time.Unix(expiresAt, 0).UTC()
+	}
+	t.CreatedAt = createdAt.String
+	return t, nil
+}
@@ -0,0 +1,24 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jroosing/hydradns/internal/config"
+)
+
+// GetAuditConfig retrieves the outbound query audit configuration.
+func (db *DB) GetAuditConfig(ctx context.Context) (*config.AuditConfig, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	cfg := &config.AuditConfig{}
+	err := db.conn.QueryRowContext(ctx, `
+		SELECT enabled, path FROM config_audit WHERE id = 1
+	`).Scan(&cfg.Enabled, &cfg.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit config: %w", err)
+	}
+
+	return cfg, nil
+}
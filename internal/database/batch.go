@@ -0,0 +1,63 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// Batch is a set of custom DNS and filtering changes applied together.
+type Batch struct {
+	// CustomDNS replaces the records of the names it holds or removes.
+	CustomDNS CustomDNSImport
+	// WhitelistAdd and BlacklistAdd are added with CustomDNS.Local as their
+	// local flag; WhitelistRemove and BlacklistRemove are removed.
+	WhitelistAdd    []string
+	WhitelistRemove []string
+	BlacklistAdd    []string
+	BlacklistRemove []string
+}
+
+// ApplyBatch applies b in a single transaction, so either all of its
+// changes are made or none are.
+func (db *DB) ApplyBatch(ctx context.Context, b Batch) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	tx, err := db.writer.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := importCustomDNSTx(ctx, tx, b.CustomDNS); err != nil {
+		return err
+	}
+
+	// Adding a temporarily allowed domain to the whitelist makes it
+	// permanent; removing whitelist domains leaves temporary allows
+	lists := []struct {
+		table, cond, update string
+		add, remove         []string
+	}{
+		{"filtering_whitelist", permanentEntries, "local = excluded.local, expires_at = 0", b.WhitelistAdd, b.WhitelistRemove},
+		{"filtering_blacklist", allEntries, "local = excluded.local", b.BlacklistAdd, b.BlacklistRemove},
+	}
+	for _, list := range lists {
+		for _, domain := range list.remove {
+			_, err := tx.ExecContext(ctx, "DELETE FROM "+list.table+" WHERE domain = ? AND "+list.cond, domain)
+			if err != nil {
+				return fmt.Errorf("failed to delete %s domain %s: %w", list.table, domain, err)
+			}
+		}
+		for _, domain := range list.add {
+			_, err := tx.ExecContext(ctx, `
+				INSERT INTO `+list.table+` (domain, local) VALUES (?, ?)
+				ON CONFLICT(domain) DO UPDATE SET `+list.update, domain, b.CustomDNS.Local)
+			if err != nil {
+				return fmt.Errorf("failed to add %s domain %s: %w", list.table, domain, err)
+			}
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,25 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jroosing/hydradns/internal/config"
+)
+
+// GetBlockPageConfig retrieves the block page server configuration.
+func (db *DB) GetBlockPageConfig(ctx context.Context) (*config.BlockPageConfig, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	cfg := &config.BlockPageConfig{}
+	err := db.conn.QueryRowContext(ctx, `
+		SELECT enabled, ipv4, ipv6, http_addr, https_addr, tls_cert, tls_key
+		FROM config_block_page WHERE id = 1
+	`).Scan(&cfg.Enabled, &cfg.IPv4, &cfg.IPv6, &cfg.HTTPAddr, &cfg.HTTPSAddr, &cfg.TLSCert, &cfg.TLSKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read block page config: %w", err)
+	}
+
+	return cfg, nil
+}
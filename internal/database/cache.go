@@ -0,0 +1,119 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/jroosing/hydradns/internal/config"
+)
+
+// GetCacheConfig retrieves the cache size, eviction policy, and
+// negative/SERVFAIL configuration, including per-zone overrides ordered by zone.
+func (db *DB) GetCacheConfig(ctx context.Context) (*config.CacheConfig, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	cfg := &config.CacheConfig{}
+	var disableNegative, aggressiveNSEC int
+	var bypass string
+	err := db.conn.QueryRowContext(ctx, `
+		SELECT max_entries, max_bytes, max_entry_bytes, eviction_policy,
+		       disable_negative, servfail_ttl, negative_ttl, bypass_domains,
+		       shared_backend, shared_address, shared_password, shared_timeout, shared_key_prefix,
+		       aggressive_nsec
+		FROM config_cache WHERE id = 1
+	`).Scan(&cfg.MaxEntries, &cfg.MaxBytes, &cfg.MaxEntryBytes, &cfg.EvictionPolicy, &disableNegative, &cfg.ServfailTTL, &cfg.NegativeTTL, &bypass,
+		&cfg.Shared.Backend, &cfg.Shared.Address, &cfg.Shared.Password, &cfg.Shared.Timeout, &cfg.Shared.KeyPrefix,
+		&aggressiveNSEC)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache config: %w", err)
+	}
+	cfg.DisableNegative = disableNegative != 0
+	cfg.AggressiveNSEC = aggressiveNSEC != 0
+	for s := range strings.SplitSeq(bypass, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			cfg.BypassDomains = append(cfg.BypassDomains, s)
+		}
+	}
+
+	rows, err := db.conn.QueryContext(ctx, `
+		SELECT zone, disable_negative, servfail_ttl, negative_ttl
+		FROM cache_zone_overrides
+		ORDER BY zone
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cache zone overrides: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var o config.CacheZoneOverride
+		var disable sql.NullBool
+		if err := rows.Scan(&o.Zone, &disable, &o.ServfailTTL, &o.NegativeTTL); err != nil {
+			return nil, fmt.Errorf("failed to scan cache zone override: %w", err)
+		}
+		if disable.Valid {
+			v := disable.Bool
+			o.DisableNegative = &v
+		}
+		cfg.ZoneOverrides = append(cfg.ZoneOverrides, o)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating cache zone overrides: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// SetCacheZoneOverride adds or replaces the cache override for a zone.
+func (db *DB) SetCacheZoneOverride(ctx context.Context, o config.CacheZoneOverride) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	var disable sql.NullBool
+	if o.DisableNegative != nil {
+		disable = sql.NullBool{Bool: *o.DisableNegative, Valid: true}
+	}
+
+	query := `
+		INSERT INTO cache_zone_overrides (zone, disable_negative, servfail_ttl, negative_ttl, updated_at)
+		VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(zone) DO UPDATE SET
+			disable_negative = excluded.disable_negative,
+			servfail_ttl = excluded.servfail_ttl,
+			negative_ttl = excluded.negative_ttl,
+			updated_at = CURRENT_TIMESTAMP
+	`
+
+	_, err := db.writer.ExecContext(ctx, query, o.Zone, disable, o.ServfailTTL, o.NegativeTTL)
+	if err != nil {
+		return fmt.Errorf("failed to set cache zone override %s: %w", o.Zone, err)
+	}
+
+	return nil
+}
+
+// DeleteCacheZoneOverride removes the cache override for a zone.
+func (db *DB) DeleteCacheZoneOverride(ctx context.Context, zone string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	result, err := db.writer.ExecContext(ctx, "DELETE FROM cache_zone_overrides WHERE zone = ?", zone)
+	if err != nil {
+		return fmt.Errorf("failed to delete cache zone override: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("cache zone override not found: %s", zone)
+	}
+
+	return nil
+}
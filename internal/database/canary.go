@@ -0,0 +1,32 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jroosing/hydradns/internal/config"
+)
+
+// GetCanaryConfig retrieves the DNS health canary configuration.
+func (db *DB) GetCanaryConfig(ctx context.Context) (*config.CanaryConfig, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	cfg := &config.CanaryConfig{}
+	var domains string
+	err := db.conn.QueryRowContext(ctx, `
+		SELECT enabled, domains, interval, timeout, failure_threshold
+		FROM config_canary WHERE id = 1
+	`).Scan(&cfg.Enabled, &domains, &cfg.Interval, &cfg.Timeout, &cfg.FailureThreshold)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read canary config: %w", err)
+	}
+	for s := range strings.SplitSeq(domains, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			cfg.Domains = append(cfg.Domains, s)
+		}
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,37 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jroosing/hydradns/internal/config"
+)
+
+// GetCaptivePortalConfig retrieves the captive portal configuration.
+func (db *DB) GetCaptivePortalConfig(ctx context.Context) (*config.CaptivePortalConfig, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	cfg := &config.CaptivePortalConfig{}
+	var clients, allowDomains string
+	err := db.conn.QueryRowContext(ctx, `
+		SELECT enabled, clients, ipv4, ipv6, allow_domains, ttl
+		FROM config_captive_portal WHERE id = 1
+	`).Scan(&cfg.Enabled, &clients, &cfg.IPv4, &cfg.IPv6, &allowDomains, &cfg.TTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read captive portal config: %w", err)
+	}
+	for s := range strings.SplitSeq(clients, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			cfg.Clients = append(cfg.Clients, s)
+		}
+	}
+	for s := range strings.SplitSeq(allowDomains, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			cfg.AllowDomains = append(cfg.AllowDomains, s)
+		}
+	}
+
+	return cfg, nil
+}
@@ -3,8 +3,11 @@ package database
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"net"
+	"strings"
+	"time"
 
 	"github.com/jroosing/hydradns/internal/cluster"
 	"github.com/jroosing/hydradns/internal/config"
@@ -13,7 +16,7 @@ import (
 // ImportFromCluster imports configuration data from a cluster export.
 // This is used by secondary nodes to sync configuration from the primary.
 // It replaces the following configuration sections:
-//   - Upstream servers
+//   - Upstream servers and EDNS option rules
 //   - Custom DNS (hosts and CNAMEs)
 //   - Filtering (whitelist, blacklist, enabled state)
 //
@@ -23,11 +26,17 @@ import (
 //   - Cluster settings
 //   - Rate limit settings (node-specific)
 //   - Logging settings (node-specific)
+//
+// Custom DNS records and whitelist/blacklist domains marked local are kept,
+// and primary records for a name with local records are skipped, so the
+// local records act as per-site overrides.
+//
+// The hash of each imported section is recorded (see GetAppliedSections).
 func (db *DB) ImportFromCluster(ctx context.Context, data *cluster.ExportData) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
-	tx, err := db.conn.BeginTx(ctx, nil)
+	tx, err := db.writer.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
@@ -48,6 +57,10 @@ func (db *DB) ImportFromCluster(ctx context.Context, data *cluster.ExportData) e
 		return fmt.Errorf("import filtering: %w", err)
 	}
 
+	if err := recordSectionsTx(ctx, tx, data.SectionHashes(), data.Version); err != nil {
+		return err
+	}
+
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
@@ -55,6 +68,135 @@ func (db *DB) ImportFromCluster(ctx context.Context, data *cluster.ExportData) e
 	return nil
 }
 
+// ApplyClusterChanges applies the changes of an incremental cluster sync,
+// keeping local records and domains as ImportFromCluster does. The
+// primary's section hashes are recorded as applied (see GetAppliedSections).
+func (db *DB) ApplyClusterChanges(ctx context.Context, changes *cluster.Changes) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	tx, err := db.writer.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	overridden, err := localCustomDNSNamesTx(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range changes.Changes {
+		if err := db.applyClusterChangeTx(ctx, tx, c, overridden); err != nil {
+			return fmt.Errorf("apply %s %s change %q: %w", c.Section, c.Kind, c.Key, err)
+		}
+	}
+
+	if err := recordSectionsTx(ctx, tx, changes.Sections, changes.Version); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (db *DB) applyClusterChangeTx(ctx context.Context, tx *sql.Tx, c cluster.Change, overridden map[string]bool) error {
+	switch c.Kind {
+	case cluster.ChangeSettings:
+		switch {
+		case c.Upstream != nil:
+			return db.importUpstreamTx(ctx, tx, *c.Upstream)
+		case c.Filtering != nil:
+			return db.importFilteringSettingsTx(ctx, tx, *c.Filtering)
+		}
+		return errors.New("missing settings")
+
+	case cluster.ChangeHost:
+		_, err := tx.ExecContext(ctx,
+			"DELETE FROM custom_dns_records WHERE local = 0 AND source = ? AND type IN ('A', 'AAAA')", c.Key)
+		if err != nil || c.Deleted || overridden[normalizeName(c.Key)] {
+			return err
+		}
+		return insertSyncedHostTx(ctx, tx, c.Key, c.IPs)
+
+	case cluster.ChangeCNAME:
+		_, err := tx.ExecContext(ctx,
+			"DELETE FROM custom_dns_records WHERE local = 0 AND source = ? AND type = 'CNAME'", c.Key)
+		if err != nil || c.Deleted || overridden[normalizeName(c.Key)] {
+			return err
+		}
+		return insertSyncedCNAMETx(ctx, tx, c.Key, c.Target)
+
+	case cluster.ChangeWhitelist:
+		if c.Deleted {
+			_, err := tx.ExecContext(ctx,
+				"DELETE FROM filtering_whitelist WHERE local = 0 AND expires_at = 0 AND domain = ?", c.Key)
+			return err
+		}
+		// A synced temporary allow of the domain becomes permanent
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO filtering_whitelist (domain) VALUES (?)
+			ON CONFLICT(domain) DO UPDATE SET expires_at = 0 WHERE local = 0
+		`, c.Key)
+		return err
+
+	case cluster.ChangeBlacklist:
+		if c.Deleted {
+			_, err := tx.ExecContext(ctx, "DELETE FROM filtering_blacklist WHERE local = 0 AND domain = ?", c.Key)
+			return err
+		}
+		_, err := tx.ExecContext(ctx, "INSERT OR IGNORE INTO filtering_blacklist (domain) VALUES (?)", c.Key)
+		return err
+
+	case cluster.ChangeTemporaryAllow:
+		if c.Deleted || c.ExpiresAt == nil {
+			_, err := tx.ExecContext(ctx,
+				"DELETE FROM filtering_whitelist WHERE local = 0 AND expires_at <> 0 AND domain = ?", c.Key)
+			return err
+		}
+		return insertSyncedAllowTx(ctx, tx, c.Key, *c.ExpiresAt)
+	}
+	return errors.New("unknown change kind")
+}
+
+// insertSyncedAllowTx stores a temporary allow synced from the primary,
+// replacing the expiry of a synced one. Whitelisted domains and local
+// allows are left unchanged.
+func insertSyncedAllowTx(ctx context.Context, tx *sql.Tx, domain string, expiresAt time.Time) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO filtering_whitelist (domain, expires_at) VALUES (?, ?)
+		ON CONFLICT(domain) DO UPDATE SET expires_at = excluded.expires_at
+		WHERE local = 0 AND expires_at <> 0
+	`, domain, expiresAt.Unix())
+	if err != nil {
+		return fmt.Errorf("insert temporary allow %s: %w", domain, err)
+	}
+	return nil
+}
+
+// recordSectionsTx records hashes as the applied section hashes at version.
+// A section keeps its version and time while its hash is unchanged.
+func recordSectionsTx(ctx context.Context, tx *sql.Tx, hashes map[string]string, version int64) error {
+	for section, hash := range hashes {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO cluster_sections (section, hash, version, applied_at)
+			VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+			ON CONFLICT(section) DO UPDATE SET
+				hash = excluded.hash,
+				version = excluded.version,
+				applied_at = excluded.applied_at
+			WHERE hash <> excluded.hash
+		`, section, hash, version)
+		if err != nil {
+			return fmt.Errorf("record section %s: %w", section, err)
+		}
+	}
+	return nil
+}
+
 func (db *DB) importUpstreamTx(ctx context.Context, tx *sql.Tx, upstream config.UpstreamConfig) error {
 	// Clear existing upstream servers
 	if _, err := tx.ExecContext(ctx, "DELETE FROM upstream_servers"); err != nil {
@@ -78,96 +220,212 @@ func (db *DB) importUpstreamTx(ctx context.Context, tx *sql.Tx, upstream config.
 			udp_timeout = ?,
 			tcp_timeout = ?,
 			max_retries = ?,
+			strategy = ?,
+			stage_timeout = ?,
 			updated_at = CURRENT_TIMESTAMP
 		WHERE id = 1
-	`, upstream.UDPTimeout, upstream.TCPTimeout, upstream.MaxRetries); err != nil {
+	`, upstream.UDPTimeout, upstream.TCPTimeout, upstream.MaxRetries, upstream.Strategy, upstream.StageTimeout); err != nil {
 		return fmt.Errorf("update upstream config: %w", err)
 	}
 
+	// Replace EDNS option rules
+	if _, err := tx.ExecContext(ctx, "DELETE FROM upstream_edns_options"); err != nil {
+		return fmt.Errorf("clear EDNS option rules: %w", err)
+	}
+	for _, r := range upstream.EDNSOptions {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO upstream_edns_options (option_name, action, value, updated_at)
+			VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+		`, r.Option, r.Action, r.Value)
+		if err != nil {
+			return fmt.Errorf("insert EDNS option rule %s: %w", r.Option, err)
+		}
+	}
+
+	// Replace DoH servers and transport rules
+	if _, err := tx.ExecContext(ctx, "DELETE FROM upstream_doh_servers"); err != nil {
+		return fmt.Errorf("clear DoH servers: %w", err)
+	}
+	for i, u := range upstream.DoHServers {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO upstream_doh_servers (url, priority, updated_at)
+			VALUES (?, ?, CURRENT_TIMESTAMP)
+		`, u, i)
+		if err != nil {
+			return fmt.Errorf("insert DoH server %s: %w", u, err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM upstream_transport_rules"); err != nil {
+		return fmt.Errorf("clear transport rules: %w", err)
+	}
+	for _, r := range upstream.TransportRules {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO upstream_transport_rules (domain, transport, updated_at)
+			VALUES (?, ?, CURRENT_TIMESTAMP)
+		`, r.Domain, r.Transport)
+		if err != nil {
+			return fmt.Errorf("insert transport rule %s: %w", r.Domain, err)
+		}
+	}
+
 	return nil
 }
 
 func (db *DB) importCustomDNSTx(ctx context.Context, tx *sql.Tx, customDNS config.CustomDNSConfig) error {
-	// Clear existing custom DNS records
-	if _, err := tx.ExecContext(ctx, "DELETE FROM custom_dns_records"); err != nil {
+	// Clear synced custom DNS records, keeping local ones
+	if _, err := tx.ExecContext(ctx, "DELETE FROM custom_dns_records WHERE local = 0"); err != nil {
 		return fmt.Errorf("clear custom DNS records: %w", err)
 	}
 
+	overridden, err := localCustomDNSNamesTx(ctx, tx)
+	if err != nil {
+		return err
+	}
+
 	// Insert host records
 	for hostname, ips := range customDNS.Hosts {
-		for _, ipStr := range ips {
-			ip := net.ParseIP(ipStr)
-			if ip == nil {
-				continue // Skip invalid IPs
-			}
-
-			recordType := RecordTypeAAAA
-			if ip.To4() != nil {
-				recordType = RecordTypeA
-			}
-
-			_, err := tx.ExecContext(ctx, `
-				INSERT INTO custom_dns_records (source, type, target, updated_at)
-				VALUES (?, ?, ?, CURRENT_TIMESTAMP)
-			`, hostname, recordType, ipStr)
-			if err != nil {
-				return fmt.Errorf("insert host %s: %w", hostname, err)
-			}
+		if overridden[normalizeName(hostname)] {
+			continue
+		}
+		if err := insertSyncedHostTx(ctx, tx, hostname, ips); err != nil {
+			return err
 		}
 	}
 
 	// Insert CNAME records
 	for alias, target := range customDNS.CNAMEs {
+		if overridden[normalizeName(alias)] {
+			continue
+		}
+		if err := insertSyncedCNAMETx(ctx, tx, alias, target); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// insertSyncedHostTx inserts synced A/AAAA records for hostname.
+func insertSyncedHostTx(ctx context.Context, tx *sql.Tx, hostname string, ips []string) error {
+	for _, ipStr := range ips {
+		ip := net.ParseIP(ipStr)
+		if ip == nil {
+			continue // Skip invalid IPs
+		}
+
+		recordType := RecordTypeAAAA
+		if ip.To4() != nil {
+			recordType = RecordTypeA
+		}
+
 		_, err := tx.ExecContext(ctx, `
 			INSERT INTO custom_dns_records (source, type, target, updated_at)
-			VALUES (?, 'CNAME', ?, CURRENT_TIMESTAMP)
-		`, alias, target)
+			VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+		`, hostname, recordType, ipStr)
 		if err != nil {
-			return fmt.Errorf("insert CNAME %s: %w", alias, err)
+			return fmt.Errorf("insert host %s: %w", hostname, err)
 		}
 	}
+	return nil
+}
 
+// insertSyncedCNAMETx inserts a synced CNAME record.
+func insertSyncedCNAMETx(ctx context.Context, tx *sql.Tx, alias, target string) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO custom_dns_records (source, type, target, updated_at)
+		VALUES (?, 'CNAME', ?, CURRENT_TIMESTAMP)
+	`, alias, target)
+	if err != nil {
+		return fmt.Errorf("insert CNAME %s: %w", alias, err)
+	}
 	return nil
 }
 
+// localCustomDNSNamesTx returns the normalized names that have local records.
+func localCustomDNSNamesTx(ctx context.Context, tx *sql.Tx) (map[string]bool, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT DISTINCT source FROM custom_dns_records WHERE local = 1")
+	if err != nil {
+		return nil, fmt.Errorf("query local custom DNS records: %w", err)
+	}
+	defer rows.Close()
+
+	names := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("scan local custom DNS record: %w", err)
+		}
+		names[normalizeName(name)] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate local custom DNS records: %w", err)
+	}
+
+	return names, nil
+}
+
+// normalizeName lowercases a name and strips its trailing dot so spellings of
+// the same name compare equal.
+func normalizeName(name string) string {
+	return strings.TrimSuffix(strings.ToLower(name), ".")
+}
+
 func (db *DB) importFilteringTx(ctx context.Context, tx *sql.Tx, filtering config.FilteringConfig) error {
-	// Update filtering config
-	if _, err := tx.ExecContext(ctx, `
-		UPDATE config_filtering SET
-			enabled = ?,
-			log_blocked = ?,
-			log_allowed = ?,
-			refresh_interval = ?,
-			updated_at = CURRENT_TIMESTAMP
-		WHERE id = 1
-	`, filtering.Enabled, filtering.LogBlocked, filtering.LogAllowed, filtering.RefreshInterval); err != nil {
-		return fmt.Errorf("update filtering config: %w", err)
+	if err := db.importFilteringSettingsTx(ctx, tx, filtering); err != nil {
+		return err
 	}
 
-	// Clear and repopulate whitelist
-	if _, err := tx.ExecContext(ctx, "DELETE FROM filtering_whitelist"); err != nil {
+	// Clear and repopulate whitelist, keeping local domains
+	if _, err := tx.ExecContext(ctx, "DELETE FROM filtering_whitelist WHERE local = 0"); err != nil {
 		return fmt.Errorf("clear whitelist: %w", err)
 	}
 
 	for _, domain := range filtering.WhitelistDomains {
-		_, err := tx.ExecContext(ctx, "INSERT INTO filtering_whitelist (domain) VALUES (?)", domain)
+		_, err := tx.ExecContext(ctx, "INSERT OR IGNORE INTO filtering_whitelist (domain) VALUES (?)", domain)
 		if err != nil {
 			return fmt.Errorf("insert whitelist domain %s: %w", domain, err)
 		}
 	}
+	for domain, expiresAt := range filtering.TemporaryAllows {
+		if err := insertSyncedAllowTx(ctx, tx, domain, expiresAt); err != nil {
+			return err
+		}
+	}
 
-	// Clear and repopulate blacklist
-	if _, err := tx.ExecContext(ctx, "DELETE FROM filtering_blacklist"); err != nil {
+	// Clear and repopulate blacklist, keeping local domains
+	if _, err := tx.ExecContext(ctx, "DELETE FROM filtering_blacklist WHERE local = 0"); err != nil {
 		return fmt.Errorf("clear blacklist: %w", err)
 	}
 
 	for _, domain := range filtering.BlacklistDomains {
-		_, err := tx.ExecContext(ctx, "INSERT INTO filtering_blacklist (domain) VALUES (?)", domain)
+		_, err := tx.ExecContext(ctx, "INSERT OR IGNORE INTO filtering_blacklist (domain) VALUES (?)", domain)
 		if err != nil {
 			return fmt.Errorf("insert blacklist domain %s: %w", domain, err)
 		}
 	}
 
+	return nil
+}
+
+// importFilteringSettingsTx imports the filtering settings and blocklists,
+// leaving the whitelist and blacklist alone.
+func (db *DB) importFilteringSettingsTx(ctx context.Context, tx *sql.Tx, filtering config.FilteringConfig) error {
+	// Update filtering config
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE config_filtering SET
+			enabled = ?,
+			log_blocked = ?,
+			log_allowed = ?,
+			refresh_interval = ?,
+			block_ttl = ?,
+			negative_ttl = ?,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = 1
+	`, filtering.Enabled, filtering.LogBlocked, filtering.LogAllowed, filtering.RefreshInterval,
+		filtering.BlockTTL, filtering.NegativeTTL); err != nil {
+		return fmt.Errorf("update filtering config: %w", err)
+	}
+
 	// Clear and repopulate blocklists
 	if _, err := tx.ExecContext(ctx, "DELETE FROM filtering_blocklists"); err != nil {
 		return fmt.Errorf("clear blocklists: %w", err)
@@ -186,12 +444,49 @@ func (db *DB) importFilteringTx(ctx context.Context, tx *sql.Tx, filtering confi
 	return nil
 }
 
+// AppliedSection describes a configuration section last imported from the
+// cluster primary.
+type AppliedSection struct {
+	Hash      string // Section hash (see cluster.ExportData.SectionHashes)
+	Version   int64  // Primary config version the section last changed at
+	AppliedAt string // When the section last changed
+}
+
+// GetAppliedSections returns the sections imported from the cluster primary,
+// keyed by section name. It is empty on nodes that never synced.
+func (db *DB) GetAppliedSections(ctx context.Context) (map[string]AppliedSection, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	rows, err := db.conn.QueryContext(ctx, "SELECT section, hash, version, applied_at FROM cluster_sections")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cluster sections: %w", err)
+	}
+	defer rows.Close()
+
+	sections := make(map[string]AppliedSection)
+	for rows.Next() {
+		var name string
+		var s AppliedSection
+		if err := rows.Scan(&name, &s.Hash, &s.Version, &s.AppliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan cluster section: %w", err)
+		}
+		sections[name] = s
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating cluster sections: %w", err)
+	}
+
+	return sections, nil
+}
+
 // SetClusterConfig updates cluster configuration settings.
 func (db *DB) SetClusterConfig(ctx context.Context, cfg *config.ClusterConfig) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
-	_, err := db.conn.ExecContext(ctx, `
+	_, err := db.writer.ExecContext(ctx, `
 		UPDATE config_cluster SET
 			mode = ?,
 			node_id = ?,
@@ -214,14 +509,16 @@ func (db *DB) SetUpstreamConfigTyped(ctx context.Context, cfg *config.UpstreamCo
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
-	_, err := db.conn.ExecContext(ctx, `
+	_, err := db.writer.ExecContext(ctx, `
 		UPDATE config_upstream SET
 			udp_timeout = ?,
 			tcp_timeout = ?,
 			max_retries = ?,
+			strategy = ?,
+			stage_timeout = ?,
 			updated_at = CURRENT_TIMESTAMP
 		WHERE id = 1
-	`, cfg.UDPTimeout, cfg.TCPTimeout, cfg.MaxRetries)
+	`, cfg.UDPTimeout, cfg.TCPTimeout, cfg.MaxRetries, cfg.Strategy, cfg.StageTimeout)
 
 	if err != nil {
 		return fmt.Errorf("failed to update upstream config: %w", err)
@@ -235,15 +532,17 @@ func (db *DB) SetFilteringConfigTyped(ctx context.Context, cfg *config.Filtering
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
-	_, err := db.conn.ExecContext(ctx, `
+	_, err := db.writer.ExecContext(ctx, `
 		UPDATE config_filtering SET
 			enabled = ?,
 			log_blocked = ?,
 			log_allowed = ?,
 			refresh_interval = ?,
+			block_ttl = ?,
+			negative_ttl = ?,
 			updated_at = CURRENT_TIMESTAMP
 		WHERE id = 1
-	`, cfg.Enabled, cfg.LogBlocked, cfg.LogAllowed, cfg.RefreshInterval)
+	`, cfg.Enabled, cfg.LogBlocked, cfg.LogAllowed, cfg.RefreshInterval, cfg.BlockTTL, cfg.NegativeTTL)
 
 	if err != nil {
 		return fmt.Errorf("failed to update filtering config: %w", err)
@@ -285,7 +584,7 @@ func (db *DB) IncrementVersion(ctx context.Context) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
-	_, err := db.conn.ExecContext(ctx,
+	_, err := db.writer.ExecContext(ctx,
 		"UPDATE config_version SET version = version + 1, updated_at = CURRENT_TIMESTAMP WHERE id = 1",
 	)
 	if err != nil {
@@ -301,7 +600,7 @@ func (db *DB) SetVersion(ctx context.Context, version int64) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
-	_, err := db.conn.ExecContext(ctx,
+	_, err := db.writer.ExecContext(ctx,
 		"UPDATE config_version SET version = ?, updated_at = CURRENT_TIMESTAMP WHERE id = 1",
 		version,
 	)
@@ -0,0 +1,25 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jroosing/hydradns/internal/config"
+)
+
+// GetClusterDiscoveryConfig retrieves the cluster discovery configuration.
+func (db *DB) GetClusterDiscoveryConfig(ctx context.Context) (*config.ClusterDiscoveryConfig, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	cfg := &config.ClusterDiscoveryConfig{}
+	err := db.conn.QueryRowContext(ctx, `
+		SELECT enabled, zone, address, ttl, member_timeout
+		FROM config_cluster_discovery WHERE id = 1
+	`).Scan(&cfg.Enabled, &cfg.Zone, &cfg.Address, &cfg.TTL, &cfg.MemberTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cluster discovery config: %w", err)
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,101 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// Promotion records a promotion of this node from secondary to primary.
+type Promotion struct {
+	ID                 int64
+	NodeID             string
+	PreviousPrimaryURL string
+	Reason             string
+	// Forced is set when safeguards were overridden: the previous primary
+	// was still reachable, or this node had never synced from it.
+	Forced bool
+	// RequestedBy names the API token that requested the promotion, or
+	// "admin" for the admin key.
+	RequestedBy   string
+	ClientIP      string
+	ConfigVersion int64 // Config version after the promotion
+	CreatedAt     string
+}
+
+// PromoteToPrimary switches the cluster mode to primary, clearing the
+// primary URL, and records p in the promotion audit trail in the same
+// transaction. Updating the cluster config bumps the config version, so
+// the promoted node's configuration is newer than the one it synced. It
+// returns p as recorded.
+func (db *DB) PromoteToPrimary(ctx context.Context, p Promotion) (Promotion, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	tx, err := db.writer.BeginTx(ctx, nil)
+	if err != nil {
+		return p, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE config_cluster SET
+			mode = 'primary',
+			primary_url = '',
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = 1
+	`)
+	if err != nil {
+		return p, fmt.Errorf("failed to update cluster config: %w", err)
+	}
+
+	if err := tx.QueryRowContext(ctx, "SELECT version FROM config_version WHERE id = 1").Scan(&p.ConfigVersion); err != nil {
+		return p, fmt.Errorf("failed to read config version: %w", err)
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO cluster_promotions
+			(node_id, previous_primary_url, reason, forced, requested_by, client_ip, config_version)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		RETURNING id, created_at
+	`, p.NodeID, p.PreviousPrimaryURL, p.Reason, p.Forced, p.RequestedBy, p.ClientIP, p.ConfigVersion).
+		Scan(&p.ID, &p.CreatedAt)
+	if err != nil {
+		return p, fmt.Errorf("failed to record promotion: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return p, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return p, nil
+}
+
+// GetPromotions returns the recorded promotions, newest first.
+func (db *DB) GetPromotions(ctx context.Context) ([]Promotion, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	rows, err := db.conn.QueryContext(ctx, `
+		SELECT id, node_id, previous_primary_url, reason, forced, requested_by,
+			client_ip, config_version, created_at
+		FROM cluster_promotions ORDER BY id DESC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cluster promotions: %w", err)
+	}
+	defer rows.Close()
+
+	var promotions []Promotion
+	for rows.Next() {
+		var p Promotion
+		if err := rows.Scan(&p.ID, &p.NodeID, &p.PreviousPrimaryURL, &p.Reason, &p.Forced,
+			&p.RequestedBy, &p.ClientIP, &p.ConfigVersion, &p.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan cluster promotion: %w", err)
+		}
+		promotions = append(promotions, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating cluster promotions: %w", err)
+	}
+
+	return promotions, nil
+}
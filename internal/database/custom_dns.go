@@ -4,7 +4,12 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"maps"
 	"net"
+	"slices"
+	"strings"
+
+	"github.com/jroosing/hydradns/internal/config"
 )
 
 // DNS record type constants for database storage.
@@ -20,6 +25,7 @@ type CustomDNSHost struct {
 	Hostname   string
 	IPAddress  string
 	RecordType string // RecordTypeA or RecordTypeAAAA
+	Local      bool   // Kept when syncing from a cluster primary
 }
 
 // CustomDNSCNAME represents a CNAME record.
@@ -27,10 +33,12 @@ type CustomDNSCNAME struct {
 	ID     int64
 	Alias  string
 	Target string
+	Local  bool // Kept when syncing from a cluster primary
 }
 
-// AddHost adds a custom DNS A or AAAA record.
-func (db *DB) AddHost(ctx context.Context, hostname, ipAddress string) error {
+// AddHost adds a custom DNS A or AAAA record. Local records are kept when a
+// secondary syncs configuration from the cluster primary.
+func (db *DB) AddHost(ctx context.Context, hostname, ipAddress string, local bool) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
@@ -46,13 +54,14 @@ func (db *DB) AddHost(ctx context.Context, hostname, ipAddress string) error {
 	}
 
 	query := `
-		INSERT INTO custom_dns_records (source, type, target, updated_at)
-		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+		INSERT INTO custom_dns_records (source, type, target, local, updated_at)
+		VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
 		ON CONFLICT(source, target, type) DO UPDATE SET
+			local = excluded.local,
 			updated_at = CURRENT_TIMESTAMP
 	`
 
-	_, err := db.conn.ExecContext(ctx, query, hostname, recordType, ipAddress)
+	_, err := db.writer.ExecContext(ctx, query, hostname, recordType, ipAddress, local)
 	if err != nil {
 		return fmt.Errorf("failed to add host %s: %w", hostname, err)
 	}
@@ -66,7 +75,7 @@ func (db *DB) GetHosts(ctx context.Context, hostname string) ([]CustomDNSHost, e
 	defer db.mu.RUnlock()
 
 	query := `
-		SELECT id, source, target, type
+		SELECT id, source, target, type, local
 		FROM custom_dns_records
 		WHERE source = ? AND type IN ('A','AAAA')
 		ORDER BY type, target
@@ -81,7 +90,7 @@ func (db *DB) GetHosts(ctx context.Context, hostname string) ([]CustomDNSHost, e
 	var hosts []CustomDNSHost
 	for rows.Next() {
 		var h CustomDNSHost
-		if err := rows.Scan(&h.ID, &h.Hostname, &h.IPAddress, &h.RecordType); err != nil {
+		if err := rows.Scan(&h.ID, &h.Hostname, &h.IPAddress, &h.RecordType, &h.Local); err != nil {
 			return nil, fmt.Errorf("failed to scan host: %w", err)
 		}
 		hosts = append(hosts, h)
@@ -100,7 +109,7 @@ func (db *DB) GetAllHosts(ctx context.Context) ([]CustomDNSHost, error) {
 	defer db.mu.RUnlock()
 
 	query := `
-		SELECT id, source, target, type
+		SELECT id, source, target, type, local
 		FROM custom_dns_records
 		WHERE type IN ('A','AAAA')
 		ORDER BY source, type, target
@@ -115,7 +124,7 @@ func (db *DB) GetAllHosts(ctx context.Context) ([]CustomDNSHost, error) {
 	var hosts []CustomDNSHost
 	for rows.Next() {
 		var h CustomDNSHost
-		if err := rows.Scan(&h.ID, &h.Hostname, &h.IPAddress, &h.RecordType); err != nil {
+		if err := rows.Scan(&h.ID, &h.Hostname, &h.IPAddress, &h.RecordType, &h.Local); err != nil {
 			return nil, fmt.Errorf("failed to scan host: %w", err)
 		}
 		hosts = append(hosts, h)
@@ -144,7 +153,7 @@ func (db *DB) DeleteHost(ctx context.Context, hostname, ipAddress string) error
 	}
 
 	query := "DELETE FROM custom_dns_records WHERE source = ? AND target = ? AND type = ?"
-	result, err := db.conn.ExecContext(ctx, query, hostname, ipAddress, recordType)
+	result, err := db.writer.ExecContext(ctx, query, hostname, ipAddress, recordType)
 	if err != nil {
 		return fmt.Errorf("failed to delete host: %w", err)
 	}
@@ -166,7 +175,7 @@ func (db *DB) DeleteAllHostsForHostname(ctx context.Context, hostname string) er
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
-	_, err := db.conn.ExecContext(
+	_, err := db.writer.ExecContext(
 		ctx,
 		"DELETE FROM custom_dns_records WHERE source = ? AND type IN ('A','AAAA')",
 		hostname,
@@ -178,22 +187,23 @@ func (db *DB) DeleteAllHostsForHostname(ctx context.Context, hostname string) er
 	return nil
 }
 
-// AddCNAME adds a custom DNS CNAME record.
-func (db *DB) AddCNAME(ctx context.Context, alias, target string) error {
+// AddCNAME adds a custom DNS CNAME record. Local records are kept when a
+// secondary syncs configuration from the cluster primary.
+func (db *DB) AddCNAME(ctx context.Context, alias, target string, local bool) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
 	// For CNAME, enforce a single target: delete any existing, then insert new
-	if _, err := db.conn.ExecContext(ctx, "DELETE FROM custom_dns_records WHERE source = ? AND type = 'CNAME'", alias); err != nil {
+	if _, err := db.writer.ExecContext(ctx, "DELETE FROM custom_dns_records WHERE source = ? AND type = 'CNAME'", alias); err != nil {
 		return fmt.Errorf("failed to clear existing CNAME %s: %w", alias, err)
 	}
 
 	query := `
-		INSERT INTO custom_dns_records (source, type, target, updated_at)
-		VALUES (?, 'CNAME', ?, CURRENT_TIMESTAMP)
+		INSERT INTO custom_dns_records (source, type, target, local, updated_at)
+		VALUES (?, 'CNAME', ?, ?, CURRENT_TIMESTAMP)
 	`
 
-	_, err := db.conn.ExecContext(ctx, query, alias, target)
+	_, err := db.writer.ExecContext(ctx, query, alias, target, local)
 	if err != nil {
 		return fmt.Errorf("failed to add CNAME %s: %w", alias, err)
 	}
@@ -224,7 +234,7 @@ func (db *DB) GetAllCNAMEs(ctx context.Context) ([]CustomDNSCNAME, error) {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
-	query := "SELECT id, source AS alias, target FROM custom_dns_records WHERE type = 'CNAME' ORDER BY source"
+	query := "SELECT id, source AS alias, target, local FROM custom_dns_records WHERE type = 'CNAME' ORDER BY source"
 
 	rows, err := db.conn.QueryContext(ctx, query)
 	if err != nil {
@@ -235,7 +245,7 @@ func (db *DB) GetAllCNAMEs(ctx context.Context) ([]CustomDNSCNAME, error) {
 	var cnames []CustomDNSCNAME
 	for rows.Next() {
 		var c CustomDNSCNAME
-		if err := rows.Scan(&c.ID, &c.Alias, &c.Target); err != nil {
+		if err := rows.Scan(&c.ID, &c.Alias, &c.Target, &c.Local); err != nil {
 			return nil, fmt.Errorf("failed to scan CNAME: %w", err)
 		}
 		cnames = append(cnames, c)
@@ -253,7 +263,7 @@ func (db *DB) DeleteCNAME(ctx context.Context, alias string) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
-	result, err := db.conn.ExecContext(ctx, "DELETE FROM custom_dns_records WHERE source = ? AND type = 'CNAME'", alias)
+	result, err := db.writer.ExecContext(ctx, "DELETE FROM custom_dns_records WHERE source = ? AND type = 'CNAME'", alias)
 	if err != nil {
 		return fmt.Errorf("failed to delete CNAME: %w", err)
 	}
@@ -269,3 +279,258 @@ func (db *DB) DeleteCNAME(ctx context.Context, alias string) error {
 
 	return nil
 }
+
+// GetLocalCustomDNSNames returns the names (host names and CNAME aliases) that
+// have local records.
+func (db *DB) GetLocalCustomDNSNames(ctx context.Context) ([]string, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	rows, err := db.conn.QueryContext(ctx, "SELECT DISTINCT source FROM custom_dns_records WHERE local = 1 ORDER BY source")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query local records: %w", err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan local record: %w", err)
+		}
+		names = append(names, name)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating local records: %w", err)
+	}
+
+	return names, nil
+}
+
+// GetSearchDomains returns the search domains tried for single-label custom
+// DNS queries, in order.
+func (db *DB) GetSearchDomains(ctx context.Context) ([]string, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var raw string
+	err := db.conn.QueryRowContext(ctx,
+		"SELECT search_domains FROM config_custom_dns WHERE id = 1",
+	).Scan(&raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read search domains: %w", err)
+	}
+
+	var domains []string
+	for s := range strings.SplitSeq(raw, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			domains = append(domains, s)
+		}
+	}
+	return domains, nil
+}
+
+// GetAnswerOrder returns how addresses of names with several custom DNS
+// records are ordered in responses.
+func (db *DB) GetAnswerOrder(ctx context.Context) (string, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var order string
+	err := db.conn.QueryRowContext(ctx,
+		"SELECT answer_order FROM config_custom_dns WHERE id = 1",
+	).Scan(&order)
+	if err != nil {
+		return "", fmt.Errorf("failed to read answer order: %w", err)
+	}
+	return order, nil
+}
+
+// GetRecordHealthChecks returns the health checks of custom DNS hosts.
+func (db *DB) GetRecordHealthChecks(ctx context.Context) ([]config.RecordHealthCheck, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	rows, err := db.conn.QueryContext(ctx, `
+		SELECT name, type, port, path, interval, timeout, failures
+		FROM custom_dns_health_checks ORDER BY id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query record health checks: %w", err)
+	}
+	defer rows.Close()
+
+	var checks []config.RecordHealthCheck
+	for rows.Next() {
+		var c config.RecordHealthCheck
+		if err := rows.Scan(&c.Name, &c.Type, &c.Port, &c.Path, &c.Interval, &c.Timeout, &c.Failures); err != nil {
+			return nil, fmt.Errorf("failed to scan record health check: %w", err)
+		}
+		checks = append(checks, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating record health checks: %w", err)
+	}
+
+	return checks, nil
+}
+
+// GetSteering returns the steering of custom DNS hosts, with hosts and
+// their addresses in the order they were stored.
+func (db *DB) GetSteering(ctx context.Context) ([]config.HostSteering, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	rows, err := db.conn.QueryContext(ctx, `
+		SELECT name, addr, weight, clients FROM custom_dns_steering ORDER BY id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query steering: %w", err)
+	}
+	defer rows.Close()
+
+	var steering []config.HostSteering
+	index := make(map[string]int)
+	for rows.Next() {
+		var (
+			name, clients string
+			a             config.SteeredAddr
+		)
+		if err := rows.Scan(&name, &a.Addr, &a.Weight, &clients); err != nil {
+			return nil, fmt.Errorf("failed to scan steering: %w", err)
+		}
+		for c := range strings.SplitSeq(clients, ",") {
+			if c = strings.TrimSpace(c); c != "" {
+				a.Clients = append(a.Clients, c)
+			}
+		}
+		i, ok := index[name]
+		if !ok {
+			i = len(steering)
+			index[name] = i
+			steering = append(steering, config.HostSteering{Name: name})
+		}
+		steering[i].Addrs = append(steering[i].Addrs, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating steering: %w", err)
+	}
+
+	return steering, nil
+}
+
+// SetHostSteering replaces the steering of s.Name.
+func (db *DB) SetHostSteering(ctx context.Context, s config.HostSteering) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	tx, err := db.writer.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM custom_dns_steering WHERE name = ?", s.Name); err != nil {
+		return fmt.Errorf("failed to clear steering for %s: %w", s.Name, err)
+	}
+	for _, a := range s.Addrs {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO custom_dns_steering (name, addr, weight, clients) VALUES (?, ?, ?, ?)",
+			s.Name, a.Addr, a.Weight, strings.Join(a.Clients, ","),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to add steering for %s: %w", s.Name, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteHostSteering removes the steering of name.
+func (db *DB) DeleteHostSteering(ctx context.Context, name string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, err := db.writer.ExecContext(ctx, "DELETE FROM custom_dns_steering WHERE name = ?", name); err != nil {
+		return fmt.Errorf("failed to delete steering for %s: %w", name, err)
+	}
+	return nil
+}
+
+// CustomDNSImport is a bulk change of custom DNS records.
+type CustomDNSImport struct {
+	// Replace removes all records first; otherwise only the records of
+	// Remove and of the imported names are.
+	Replace bool
+	// Remove are names whose records are removed, e.g. other spellings of
+	// imported names.
+	Remove []string
+	Hosts  map[string][]string
+	CNAMEs map[string]string
+	// Local marks the imported records as local (see AddHost).
+	Local bool
+}
+
+// ImportCustomDNS applies imp in a single transaction: imported names
+// replace all records of the same name.
+func (db *DB) ImportCustomDNS(ctx context.Context, imp CustomDNSImport) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	tx, err := db.writer.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := importCustomDNSTx(ctx, tx, imp); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// importCustomDNSTx applies imp within tx.
+func importCustomDNSTx(ctx context.Context, tx *sql.Tx, imp CustomDNSImport) error {
+	if imp.Replace {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM custom_dns_records"); err != nil {
+			return fmt.Errorf("failed to clear custom DNS records: %w", err)
+		}
+	}
+	remove := slices.Concat(imp.Remove, slices.Collect(maps.Keys(imp.Hosts)), slices.Collect(maps.Keys(imp.CNAMEs)))
+	for _, name := range remove {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM custom_dns_records WHERE source = ?", name); err != nil {
+			return fmt.Errorf("failed to delete records for %s: %w", name, err)
+		}
+	}
+
+	for hostname, ips := range imp.Hosts {
+		for _, ipStr := range ips {
+			ip := net.ParseIP(ipStr)
+			if ip == nil {
+				return fmt.Errorf("invalid IP address: %s", ipStr)
+			}
+			recordType := RecordTypeAAAA
+			if ip.To4() != nil {
+				recordType = RecordTypeA
+			}
+			_, err := tx.ExecContext(ctx, `
+				INSERT INTO custom_dns_records (source, type, target, local, updated_at)
+				VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+			`, hostname, recordType, ipStr, imp.Local)
+			if err != nil {
+				return fmt.Errorf("failed to add host %s: %w", hostname, err)
+			}
+		}
+	}
+	for alias, target := range imp.CNAMEs {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO custom_dns_records (source, type, target, local, updated_at)
+			VALUES (?, 'CNAME', ?, ?, CURRENT_TIMESTAMP)
+		`, alias, target, imp.Local)
+		if err != nil {
+			return fmt.Errorf("failed to add CNAME %s: %w", alias, err)
+		}
+	}
+
+	return nil
+}
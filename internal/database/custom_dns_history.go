@@ -0,0 +1,183 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// MaxCustomDNSVersions is how many custom DNS versions are kept; older
+// ones are pruned when a new one is saved.
+const MaxCustomDNSVersions = 100
+
+// CustomDNSRecord is one row of custom_dns_records as kept in a version.
+type CustomDNSRecord struct {
+	Source string `json:"source"`
+	Type   string `json:"type"`
+	Target string `json:"target"`
+	Local  bool   `json:"local,omitempty"`
+}
+
+// CustomDNSVersion is a snapshot of the custom DNS records taken before a
+// change. Rolling back to it undoes that change and every later one.
+type CustomDNSVersion struct {
+	ID int64
+	// Action describes the change made after the snapshot, e.g.
+	// "delete host nas.home.arpa".
+	Action    string
+	CreatedAt string
+	Records   []CustomDNSRecord
+}
+
+// SaveCustomDNSVersion snapshots the current custom DNS records before a
+// change described by action, and prunes all but the newest
+// MaxCustomDNSVersions versions.
+func (db *DB) SaveCustomDNSVersion(ctx context.Context, action string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	tx, err := db.writer.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := saveCustomDNSVersionTx(ctx, tx, action); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetCustomDNSHistory returns the saved custom DNS versions, newest first.
+func (db *DB) GetCustomDNSHistory(ctx context.Context) ([]CustomDNSVersion, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	rows, err := db.conn.QueryContext(ctx, `
+		SELECT id, action, records, created_at
+		FROM custom_dns_history ORDER BY id DESC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query custom DNS history: %w", err)
+	}
+	defer rows.Close()
+
+	var versions []CustomDNSVersion
+	for rows.Next() {
+		v, err := scanCustomDNSVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating custom DNS history: %w", err)
+	}
+
+	return versions, nil
+}
+
+// RollbackCustomDNS replaces the custom DNS records with those of version
+// id. The records replaced are saved as a new version first, so the
+// rollback can be undone too. It reports whether the version exists.
+func (db *DB) RollbackCustomDNS(ctx context.Context, id int64) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	tx, err := db.writer.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	v, err := scanCustomDNSVersion(tx.QueryRowContext(ctx, `
+		SELECT id, action, records, created_at
+		FROM custom_dns_history WHERE id = ?
+	`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := saveCustomDNSVersionTx(ctx, tx, fmt.Sprintf("rollback to version %d", id)); err != nil {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM custom_dns_records"); err != nil {
+		return false, fmt.Errorf("failed to clear custom DNS records: %w", err)
+	}
+	for _, r := range v.Records {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO custom_dns_records (source, type, target, local, updated_at)
+			VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+		`, r.Source, r.Type, r.Target, r.Local)
+		if err != nil {
+			return false, fmt.Errorf("failed to restore %s record %s: %w", r.Type, r.Source, err)
+		}
+	}
+
+	return true, tx.Commit()
+}
+
+// saveCustomDNSVersionTx snapshots the custom DNS records within tx.
+func saveCustomDNSVersionTx(ctx context.Context, tx *sql.Tx, action string) error {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT source, type, target, local
+		FROM custom_dns_records ORDER BY source, type, target
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to query custom DNS records: %w", err)
+	}
+	records := []CustomDNSRecord{}
+	for rows.Next() {
+		var r CustomDNSRecord
+		if err := rows.Scan(&r.Source, &r.Type, &r.Target, &r.Local); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan custom DNS record: %w", err)
+		}
+		records = append(records, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating custom DNS records: %w", err)
+	}
+
+	b, err := json.Marshal(records)
+	if err != nil {
+		return fmt.Errorf("failed to encode custom DNS version: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO custom_dns_history (action, records) VALUES (?, ?)", action, string(b),
+	); err != nil {
+		return fmt.Errorf("failed to save custom DNS version: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM custom_dns_history WHERE id NOT IN (
+			SELECT id FROM custom_dns_history ORDER BY id DESC LIMIT ?
+		)
+	`, MaxCustomDNSVersions)
+	if err != nil {
+		return fmt.Errorf("failed to prune custom DNS history: %w", err)
+	}
+	return nil
+}
+
+func scanCustomDNSVersion(row interface{ Scan(...any) error }) (CustomDNSVersion, error) {
+	var v CustomDNSVersion
+	var records string
+	var createdAt sql.NullString
+	if err := row.Scan(&v.ID, &v.Action, &records, &createdAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return CustomDNSVersion{}, err
+		}
+		return CustomDNSVersion{}, fmt.Errorf("failed to scan custom DNS version: %w", err)
+	}
+	if err := json.Unmarshal([]byte(records), &v.Records); err != nil {
+		return CustomDNSVersion{}, fmt.Errorf("failed to decode custom DNS version %d: %w", v.ID, err)
+	}
+	v.CreatedAt = createdAt.String
+	return v, nil
+}
@@ -13,6 +13,12 @@
 // Config Version Tracking:
 // Every modification to the database increments a global version counter
 // via SQLite triggers. This enables efficient sync checks between nodes.
+//
+// Connections:
+// The database runs in WAL mode so reads never wait for writes. Reads use a
+// connection pool; writes go through a single connection, so concurrent
+// writers queue up instead of failing with SQLITE_BUSY. A busy timeout
+// covers locks held by other processes, such as "hydradns check".
 package database
 
 import (
@@ -20,8 +26,8 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"net/url"
 	"sync"
-	"time"
 
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/sqlite"
@@ -34,40 +40,70 @@ import (
 
 // DB wraps a SQLite database connection with thread-safe operations.
 type DB struct {
-	conn *sql.DB
-	mu   sync.RWMutex // Protects config reads/writes
+	conn   *sql.DB      // Read pool
+	writer *sql.DB      // Single connection; queues concurrent writes
+	mu     sync.RWMutex // Protects config reads/writes
 }
 
-// Open opens or creates a SQLite database at the given path.
-// If the database doesn't exist, it will be created with the schema.
+// Open opens or creates a SQLite database at the given path with default
+// Options. If the database doesn't exist, it will be created with the schema.
 func Open(path string) (*DB, error) {
-	// Use WAL mode for better concurrency
-	dsn := fmt.Sprintf("file:%s?_journal_mode=WAL&_busy_timeout=5000&_synchronous=NORMAL", path)
+	return OpenWithOptions(path, Options{})
+}
+
+// OpenWithOptions opens or creates a SQLite database at the given path.
+// Zero fields of opts use their defaults.
+func OpenWithOptions(path string, opts Options) (*DB, error) {
+	opts = opts.withDefaults()
 
-	conn, err := sql.Open("sqlite", dsn)
+	conn, err := sql.Open("sqlite", dsn(path, opts, false))
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
+	conn.SetMaxOpenConns(opts.MaxOpenConns)
+	conn.SetMaxIdleConns(opts.MaxIdleConns)
+	conn.SetConnMaxLifetime(opts.ConnMaxLifetime)
+
+	// Write transactions start with BEGIN IMMEDIATE so they take the write
+	// lock up front, where the busy timeout applies, instead of failing when
+	// upgrading a read lock mid-transaction
+	writer, err := sql.Open("sqlite", dsn(path, opts, true))
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	writer.SetMaxOpenConns(1)
+	writer.SetMaxIdleConns(1)
+	writer.SetConnMaxLifetime(opts.ConnMaxLifetime)
 
-	// Set reasonable connection pool limits
-	conn.SetMaxOpenConns(10)
-	conn.SetMaxIdleConns(5)
-	conn.SetConnMaxLifetime(time.Hour)
-
-	db := &DB{conn: conn}
+	db := &DB{conn: conn, writer: writer}
 
 	// Run migrations
 	if err := db.runMigrations(); err != nil {
-		_ = conn.Close() // Ignore close error on failed migration
+		_ = db.Close() // Ignore close error on failed migration
 		return nil, fmt.Errorf("failed to run migrations: %w", err)
 	}
 
 	return db, nil
 }
 
-// Close closes the database connection.
+// dsn returns the modernc.org/sqlite data source name for path. The pragmas
+// are applied to every new connection.
+func dsn(path string, opts Options, immediate bool) string {
+	q := url.Values{}
+	q.Add("_pragma", "journal_mode(WAL)")
+	q.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", opts.BusyTimeout.Milliseconds()))
+	q.Add("_pragma", "foreign_keys(1)")
+	q.Add("_pragma", "synchronous(NORMAL)")
+	if immediate {
+		q.Set("_txlock", "immediate")
+	}
+	return "file:" + path + "?" + q.Encode()
+}
+
+// Close closes the database connections.
 func (db *DB) Close() error {
-	return db.conn.Close()
+	return errors.Join(db.writer.Close(), db.conn.Close())
 }
 
 // runMigrations runs database migrations using golang-migrate.
@@ -79,7 +115,7 @@ func (db *DB) runMigrations() error {
 	}
 
 	// Create database driver
-	dbDriver, err := sqlite.WithInstance(db.conn, &sqlite.Config{})
+	dbDriver, err := sqlite.WithInstance(db.writer, &sqlite.Config{})
 	if err != nil {
 		return fmt.Errorf("failed to create database driver: %w", err)
 	}
@@ -109,9 +145,9 @@ func (db *DB) GetVersion(ctx context.Context) (int64, error) {
 	return version, nil
 }
 
-// BeginTx starts a transaction for atomic multi-table operations.
+// BeginTx starts a write transaction for atomic multi-table operations.
 func (db *DB) BeginTx(ctx context.Context) (*sql.Tx, error) {
-	return db.conn.BeginTx(ctx, nil)
+	return db.writer.BeginTx(ctx, nil)
 }
 
 // Health checks database connectivity.
@@ -0,0 +1,37 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jroosing/hydradns/internal/config"
+)
+
+// GetDDRConfig retrieves the designated resolver discovery configuration.
+func (db *DB) GetDDRConfig(ctx context.Context) (*config.DDRConfig, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	cfg := &config.DDRConfig{}
+	var ipv4Hints, ipv6Hints string
+	err := db.conn.QueryRowContext(ctx, `
+		SELECT enabled, target, doh_port, doh_path, dot_port, ipv4_hints, ipv6_hints, ttl
+		FROM config_ddr WHERE id = 1
+	`).Scan(&cfg.Enabled, &cfg.Target, &cfg.DoHPort, &cfg.DoHPath, &cfg.DoTPort, &ipv4Hints, &ipv6Hints, &cfg.TTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read DDR config: %w", err)
+	}
+	for s := range strings.SplitSeq(ipv4Hints, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			cfg.IPv4Hints = append(cfg.IPv4Hints, s)
+		}
+	}
+	for s := range strings.SplitSeq(ipv6Hints, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			cfg.IPv6Hints = append(cfg.IPv6Hints, s)
+		}
+	}
+
+	return cfg, nil
+}
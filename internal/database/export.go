@@ -3,6 +3,7 @@ package database
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/jroosing/hydradns/internal/config"
 )
@@ -17,6 +18,27 @@ func (db *DB) ExportToConfig(ctx context.Context) (*config.Config, error) {
 		return nil, err
 	}
 
+	// Export listener profiles
+	profiles, err := db.GetListenerProfiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Server.ListenerProfiles = profiles
+
+	// Export qtype rules
+	qtypeRules, err := db.GetQTypeRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Server.QTypeRules = qtypeRules
+
+	// Export response pipeline
+	responseSteps, err := db.GetResponsePipeline(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Server.ResponsePipeline = responseSteps
+
 	// Export upstream config
 	if err := db.exportUpstreamConfig(ctx, cfg); err != nil {
 		return nil, err
@@ -27,6 +49,11 @@ func (db *DB) ExportToConfig(ctx context.Context) (*config.Config, error) {
 		return nil, err
 	}
 
+	// Export cache config
+	if err := db.exportCacheConfig(ctx, cfg); err != nil {
+		return nil, err
+	}
+
 	// Export logging config
 	if err := db.exportLoggingConfig(ctx, cfg); err != nil {
 		return nil, err
@@ -37,6 +64,81 @@ func (db *DB) ExportToConfig(ctx context.Context) (*config.Config, error) {
 		return nil, err
 	}
 
+	// Export block page config
+	if err := db.exportBlockPageConfig(ctx, cfg); err != nil {
+		return nil, err
+	}
+
+	// Export captive portal config
+	if err := db.exportCaptivePortalConfig(ctx, cfg); err != nil {
+		return nil, err
+	}
+
+	// Export AAAA filtering config
+	if err := db.exportFilterAAAAConfig(ctx, cfg); err != nil {
+		return nil, err
+	}
+
+	// Export DDR config
+	if err := db.exportDDRConfig(ctx, cfg); err != nil {
+		return nil, err
+	}
+
+	// Export PTR synthesis config
+	if err := db.exportSynthPTRConfig(ctx, cfg); err != nil {
+		return nil, err
+	}
+
+	// Export GeoIP config
+	if err := db.exportGeoIPConfig(ctx, cfg); err != nil {
+		return nil, err
+	}
+
+	// Export anomaly detection config
+	if err := db.exportAnomalyConfig(ctx, cfg); err != nil {
+		return nil, err
+	}
+
+	// Export threat intelligence config
+	if err := db.exportThreatIntelConfig(ctx, cfg); err != nil {
+		return nil, err
+	}
+
+	// Export newly registered domain config
+	if err := db.exportNRDConfig(ctx, cfg); err != nil {
+		return nil, err
+	}
+
+	// Export identity config
+	if err := db.exportIdentityConfig(ctx, cfg); err != nil {
+		return nil, err
+	}
+
+	// Export audit config
+	if err := db.exportAuditConfig(ctx, cfg); err != nil {
+		return nil, err
+	}
+
+	// Export privacy config
+	if err := db.exportPrivacyConfig(ctx, cfg); err != nil {
+		return nil, err
+	}
+
+	// Export health canary config
+	if err := db.exportCanaryConfig(ctx, cfg); err != nil {
+		return nil, err
+	}
+
+	// Export shadow upstream config
+	if err := db.exportShadowConfig(ctx, cfg); err != nil {
+		return nil, err
+	}
+
+	// Export DNS advertisement config
+	if err := db.exportAdvertiseConfig(ctx, cfg); err != nil {
+		return nil, err
+	}
+
 	// Export rate limit config
 	if err := db.exportRateLimitConfig(ctx, cfg); err != nil {
 		return nil, err
@@ -52,6 +154,11 @@ func (db *DB) ExportToConfig(ctx context.Context) (*config.Config, error) {
 		return nil, err
 	}
 
+	// Export cluster discovery config
+	if err := db.exportClusterDiscoveryConfig(ctx, cfg); err != nil {
+		return nil, err
+	}
+
 	return cfg, nil
 }
 
@@ -60,8 +167,13 @@ func (db *DB) exportServerConfig(ctx context.Context, cfg *config.Config) error
 	defer db.mu.RUnlock()
 
 	var enableTCP, tcpFallback int
+	var proxyTrusted, recursionClients string
 	err := db.conn.QueryRowContext(ctx, `
-		SELECT host, port, workers, max_concurrency, upstream_socket_pool_size, enable_tcp, tcp_fallback
+		SELECT host, port, workers, max_concurrency, upstream_socket_pool_size, enable_tcp, tcp_fallback,
+		       max_cname_chain, udp_listeners, tcp_read_timeout, tcp_idle_timeout,
+		       tcp_max_conns_per_ip, tcp_max_queries_per_conn, tcp_listeners,
+		       proxy_protocol_trusted, recursion_clients, shutdown_drain,
+		       udp_recv_buffer, udp_send_buffer
 		FROM config_server WHERE id = 1
 	`).Scan(
 		&cfg.Server.Host,
@@ -71,12 +183,34 @@ func (db *DB) exportServerConfig(ctx context.Context, cfg *config.Config) error
 		&cfg.Server.UpstreamSocketPoolSize,
 		&enableTCP,
 		&tcpFallback,
+		&cfg.Server.MaxCNAMEChain,
+		&cfg.Server.UDPListeners,
+		&cfg.Server.TCPReadTimeout,
+		&cfg.Server.TCPIdleTimeout,
+		&cfg.Server.TCPMaxConnsPerIP,
+		&cfg.Server.TCPMaxQueriesPerConn,
+		&cfg.Server.TCPListeners,
+		&proxyTrusted,
+		&recursionClients,
+		&cfg.Server.ShutdownDrain,
+		&cfg.Server.UDPRecvBuffer,
+		&cfg.Server.UDPSendBuffer,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to read server config: %w", err)
 	}
 
 	cfg.Server.EnableTCP = enableTCP != 0
+	for p := range strings.SplitSeq(proxyTrusted, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			cfg.Server.ProxyProtocolTrusted = append(cfg.Server.ProxyProtocolTrusted, p)
+		}
+	}
+	for p := range strings.SplitSeq(recursionClients, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			cfg.Server.RecursionClients = append(cfg.Server.RecursionClients, p)
+		}
+	}
 	cfg.Server.TCPFallback = tcpFallback != 0
 
 	if err := cfg.Server.ParseWorkers(); err != nil {
@@ -91,9 +225,10 @@ func (db *DB) exportUpstreamConfig(ctx context.Context, cfg *config.Config) erro
 	defer db.mu.RUnlock()
 
 	err := db.conn.QueryRowContext(ctx, `
-		SELECT udp_timeout, tcp_timeout, max_retries
+		SELECT udp_timeout, tcp_timeout, max_retries, strategy, stage_timeout
 		FROM config_upstream WHERE id = 1
-	`).Scan(&cfg.Upstream.UDPTimeout, &cfg.Upstream.TCPTimeout, &cfg.Upstream.MaxRetries)
+	`).Scan(&cfg.Upstream.UDPTimeout, &cfg.Upstream.TCPTimeout, &cfg.Upstream.MaxRetries, &cfg.Upstream.Strategy,
+		&cfg.Upstream.StageTimeout)
 	if err != nil {
 		return fmt.Errorf("failed to read upstream config: %w", err)
 	}
@@ -111,6 +246,30 @@ func (db *DB) exportUpstreamConfig(ctx context.Context, cfg *config.Config) erro
 		cfg.Upstream.Servers[i] = server.ServerAddress
 	}
 
+	db.mu.RUnlock()
+	rules, err := db.GetEDNSOptionRules(ctx)
+	db.mu.RLock()
+	if err != nil {
+		return fmt.Errorf("failed to get EDNS option rules: %w", err)
+	}
+	cfg.Upstream.EDNSOptions = rules
+
+	db.mu.RUnlock()
+	dohServers, err := db.GetDoHServers(ctx)
+	db.mu.RLock()
+	if err != nil {
+		return fmt.Errorf("failed to get DoH servers: %w", err)
+	}
+	cfg.Upstream.DoHServers = dohServers
+
+	db.mu.RUnlock()
+	transportRules, err := db.GetTransportRules(ctx)
+	db.mu.RLock()
+	if err != nil {
+		return fmt.Errorf("failed to get transport rules: %w", err)
+	}
+	cfg.Upstream.TransportRules = transportRules
+
 	return nil
 }
 
@@ -140,6 +299,174 @@ func (db *DB) exportCustomDNS(ctx context.Context, cfg *config.Config) error {
 	}
 	cfg.CustomDNS.CNAMEs = cnamesMap
 
+	searchDomains, err := db.GetSearchDomains(ctx)
+	if err != nil {
+		return err
+	}
+	cfg.CustomDNS.SearchDomains = searchDomains
+
+	answerOrder, err := db.GetAnswerOrder(ctx)
+	if err != nil {
+		return err
+	}
+	cfg.CustomDNS.AnswerOrder = answerOrder
+
+	healthChecks, err := db.GetRecordHealthChecks(ctx)
+	if err != nil {
+		return err
+	}
+	cfg.CustomDNS.HealthChecks = healthChecks
+
+	steering, err := db.GetSteering(ctx)
+	if err != nil {
+		return err
+	}
+	cfg.CustomDNS.Steering = steering
+
+	return nil
+}
+
+func (db *DB) exportCacheConfig(ctx context.Context, cfg *config.Config) error {
+	cacheCfg, err := db.GetCacheConfig(ctx)
+	if err != nil {
+		return err
+	}
+	cfg.Cache = *cacheCfg
+	return nil
+}
+
+func (db *DB) exportBlockPageConfig(ctx context.Context, cfg *config.Config) error {
+	blockPageCfg, err := db.GetBlockPageConfig(ctx)
+	if err != nil {
+		return err
+	}
+	cfg.BlockPage = *blockPageCfg
+	return nil
+}
+
+func (db *DB) exportGeoIPConfig(ctx context.Context, cfg *config.Config) error {
+	geoIPCfg, err := db.GetGeoIPConfig(ctx)
+	if err != nil {
+		return err
+	}
+	cfg.GeoIP = *geoIPCfg
+	return nil
+}
+
+func (db *DB) exportAnomalyConfig(ctx context.Context, cfg *config.Config) error {
+	anomalyCfg, err := db.GetAnomalyConfig(ctx)
+	if err != nil {
+		return err
+	}
+	cfg.Anomaly = *anomalyCfg
+	return nil
+}
+
+func (db *DB) exportThreatIntelConfig(ctx context.Context, cfg *config.Config) error {
+	threatCfg, err := db.GetThreatIntelConfig(ctx)
+	if err != nil {
+		return err
+	}
+	cfg.ThreatIntel = *threatCfg
+	return nil
+}
+
+func (db *DB) exportNRDConfig(ctx context.Context, cfg *config.Config) error {
+	nrdCfg, err := db.GetNRDConfig(ctx)
+	if err != nil {
+		return err
+	}
+	cfg.NRD = *nrdCfg
+	return nil
+}
+
+func (db *DB) exportIdentityConfig(ctx context.Context, cfg *config.Config) error {
+	identityCfg, err := db.GetIdentityConfig(ctx)
+	if err != nil {
+		return err
+	}
+	cfg.Identity = *identityCfg
+	return nil
+}
+
+func (db *DB) exportAuditConfig(ctx context.Context, cfg *config.Config) error {
+	auditCfg, err := db.GetAuditConfig(ctx)
+	if err != nil {
+		return err
+	}
+	cfg.Audit = *auditCfg
+	return nil
+}
+
+func (db *DB) exportCaptivePortalConfig(ctx context.Context, cfg *config.Config) error {
+	captiveCfg, err := db.GetCaptivePortalConfig(ctx)
+	if err != nil {
+		return err
+	}
+	cfg.CaptivePortal = *captiveCfg
+	return nil
+}
+
+func (db *DB) exportFilterAAAAConfig(ctx context.Context, cfg *config.Config) error {
+	filterCfg, err := db.GetFilterAAAAConfig(ctx)
+	if err != nil {
+		return err
+	}
+	cfg.FilterAAAA = *filterCfg
+	return nil
+}
+
+func (db *DB) exportDDRConfig(ctx context.Context, cfg *config.Config) error {
+	ddrCfg, err := db.GetDDRConfig(ctx)
+	if err != nil {
+		return err
+	}
+	cfg.DDR = *ddrCfg
+	return nil
+}
+
+func (db *DB) exportSynthPTRConfig(ctx context.Context, cfg *config.Config) error {
+	synthCfg, err := db.GetSynthPTRConfig(ctx)
+	if err != nil {
+		return err
+	}
+	cfg.SynthPTR = *synthCfg
+	return nil
+}
+
+func (db *DB) exportPrivacyConfig(ctx context.Context, cfg *config.Config) error {
+	privacyCfg, err := db.GetPrivacyConfig(ctx)
+	if err != nil {
+		return err
+	}
+	cfg.Privacy = *privacyCfg
+	return nil
+}
+
+func (db *DB) exportCanaryConfig(ctx context.Context, cfg *config.Config) error {
+	canaryCfg, err := db.GetCanaryConfig(ctx)
+	if err != nil {
+		return err
+	}
+	cfg.Canary = *canaryCfg
+	return nil
+}
+
+func (db *DB) exportShadowConfig(ctx context.Context, cfg *config.Config) error {
+	shadowCfg, err := db.GetShadowConfig(ctx)
+	if err != nil {
+		return err
+	}
+	cfg.Shadow = *shadowCfg
+	return nil
+}
+
+func (db *DB) exportAdvertiseConfig(ctx context.Context, cfg *config.Config) error {
+	advertiseCfg, err := db.GetAdvertiseConfig(ctx)
+	if err != nil {
+		return err
+	}
+	cfg.Advertise = *advertiseCfg
 	return nil
 }
 
@@ -149,9 +476,17 @@ func (db *DB) exportLoggingConfig(ctx context.Context, cfg *config.Config) error
 
 	var structured, includePID int
 	err := db.conn.QueryRowContext(ctx, `
-		SELECT level, structured, structured_format, include_pid
+		SELECT level, structured, structured_format, include_pid,
+		       slow_query_threshold, slow_query_max_per_minute
 		FROM config_logging WHERE id = 1
-	`).Scan(&cfg.Logging.Level, &structured, &cfg.Logging.StructuredFormat, &includePID)
+	`).Scan(
+		&cfg.Logging.Level,
+		&structured,
+		&cfg.Logging.StructuredFormat,
+		&includePID,
+		&cfg.Logging.SlowQueryThreshold,
+		&cfg.Logging.SlowQueryMaxPerMinute,
+	)
 	if err != nil {
 		return fmt.Errorf("failed to read logging config: %w", err)
 	}
@@ -176,6 +511,8 @@ func (db *DB) exportFilteringConfig(ctx context.Context, cfg *config.Config) err
 	cfg.Filtering.LogBlocked = filteringCfg.LogBlocked
 	cfg.Filtering.LogAllowed = filteringCfg.LogAllowed
 	cfg.Filtering.RefreshInterval = filteringCfg.RefreshInterval
+	cfg.Filtering.BlockTTL = filteringCfg.BlockTTL
+	cfg.Filtering.NegativeTTL = filteringCfg.NegativeTTL
 
 	// Get whitelist domains
 	whitelist, err := db.GetWhitelistDomains(ctx)
@@ -184,6 +521,14 @@ func (db *DB) exportFilteringConfig(ctx context.Context, cfg *config.Config) err
 	}
 	cfg.Filtering.WhitelistDomains = whitelist
 
+	allows, err := db.GetTemporaryAllows(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get temporary allows: %w", err)
+	}
+	if len(allows) > 0 {
+		cfg.Filtering.TemporaryAllows = allows
+	}
+
 	// Get blacklist domains
 	blacklist, err := db.GetBlacklistDomains(ctx)
 	if err != nil {
@@ -218,9 +563,11 @@ func (db *DB) exportRateLimitConfig(ctx context.Context, cfg *config.Config) err
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
+	var exemptLocal int
 	err := db.conn.QueryRowContext(ctx, `
 		SELECT cleanup_seconds, max_ip_entries, max_prefix_entries,
-		       global_qps, global_burst, prefix_qps, prefix_burst, ip_qps, ip_burst
+		       global_qps, global_burst, prefix_qps, prefix_burst, ip_qps, ip_burst, slip,
+		       ipv4_prefix_len, ipv6_prefix_len, exempt_local
 		FROM config_rate_limit WHERE id = 1
 	`).Scan(
 		&cfg.RateLimit.CleanupSeconds,
@@ -232,10 +579,15 @@ func (db *DB) exportRateLimitConfig(ctx context.Context, cfg *config.Config) err
 		&cfg.RateLimit.PrefixBurst,
 		&cfg.RateLimit.IPQPS,
 		&cfg.RateLimit.IPBurst,
+		&cfg.RateLimit.Slip,
+		&cfg.RateLimit.IPv4PrefixLen,
+		&cfg.RateLimit.IPv6PrefixLen,
+		&exemptLocal,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to read rate limit config: %w", err)
 	}
+	cfg.RateLimit.ExemptLocal = exemptLocal != 0
 
 	return nil
 }
@@ -246,9 +598,10 @@ func (db *DB) exportAPIConfig(ctx context.Context, cfg *config.Config) error {
 
 	var enabled int
 	err := db.conn.QueryRowContext(ctx, `
-		SELECT enabled, host, port, api_key
+		SELECT enabled, host, port, api_key, rate_limit_rps, rate_limit_burst, max_body_bytes
 		FROM config_api WHERE id = 1
-	`).Scan(&enabled, &cfg.API.Host, &cfg.API.Port, &cfg.API.APIKey)
+	`).Scan(&enabled, &cfg.API.Host, &cfg.API.Port, &cfg.API.APIKey,
+		&cfg.API.RateLimitRPS, &cfg.API.RateLimitBurst, &cfg.API.MaxBodyBytes)
 	if err != nil {
 		return fmt.Errorf("failed to read API config: %w", err)
 	}
@@ -282,3 +635,12 @@ func (db *DB) exportClusterConfig(ctx context.Context, cfg *config.Config) error
 
 	return nil
 }
+
+func (db *DB) exportClusterDiscoveryConfig(ctx context.Context, cfg *config.Config) error {
+	discoveryCfg, err := db.GetClusterDiscoveryConfig(ctx)
+	if err != nil {
+		return err
+	}
+	cfg.ClusterDiscovery = *discoveryCfg
+	return nil
+}
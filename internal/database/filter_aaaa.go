@@ -0,0 +1,32 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jroosing/hydradns/internal/config"
+)
+
+// GetFilterAAAAConfig retrieves the AAAA filtering configuration.
+func (db *DB) GetFilterAAAAConfig(ctx context.Context) (*config.FilterAAAAConfig, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	cfg := &config.FilterAAAAConfig{}
+	var clients string
+	err := db.conn.QueryRowContext(ctx, `
+		SELECT enabled, clients
+		FROM config_filter_aaaa WHERE id = 1
+	`).Scan(&cfg.Enabled, &clients)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read AAAA filtering config: %w", err)
+	}
+	for s := range strings.SplitSeq(clients, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			cfg.Clients = append(cfg.Clients, s)
+		}
+	}
+
+	return cfg, nil
+}
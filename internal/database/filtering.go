@@ -2,8 +2,12 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
+	"time"
+
+	"github.com/jroosing/hydradns/internal/filtering"
 )
 
 // Blocklist represents a remote blocklist source.
@@ -16,14 +20,19 @@ type Blocklist struct {
 	LastFetched *string
 }
 
-// AddWhitelistDomain adds a domain to the whitelist.
-func (db *DB) AddWhitelistDomain(ctx context.Context, domain string) error {
+// AddWhitelistDomain adds a domain to the whitelist, making a temporary allow
+// of it permanent. Local domains are kept when a secondary syncs configuration
+// from the cluster primary.
+func (db *DB) AddWhitelistDomain(ctx context.Context, domain string, local bool) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
-	query := "INSERT OR IGNORE INTO filtering_whitelist (domain) VALUES (?)"
+	query := `
+		INSERT INTO filtering_whitelist (domain, local) VALUES (?, ?)
+		ON CONFLICT(domain) DO UPDATE SET local = excluded.local, expires_at = 0
+	`
 
-	_, err := db.conn.ExecContext(ctx, query, domain)
+	_, err := db.writer.ExecContext(ctx, query, domain, local)
 	if err != nil {
 		return fmt.Errorf("failed to add whitelist domain %s: %w", domain, err)
 	}
@@ -31,12 +40,14 @@ func (db *DB) AddWhitelistDomain(ctx context.Context, domain string) error {
 	return nil
 }
 
-// GetWhitelistDomains retrieves all whitelisted domains.
+// GetWhitelistDomains retrieves all whitelisted domains, without temporary
+// allows.
 func (db *DB) GetWhitelistDomains(ctx context.Context) ([]string, error) {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
-	rows, err := db.conn.QueryContext(ctx, "SELECT domain FROM filtering_whitelist ORDER BY domain")
+	rows, err := db.conn.QueryContext(ctx,
+		"SELECT domain FROM filtering_whitelist WHERE expires_at = 0 ORDER BY domain")
 	if err != nil {
 		return nil, fmt.Errorf("failed to query whitelist: %w", err)
 	}
@@ -58,12 +69,18 @@ func (db *DB) GetWhitelistDomains(ctx context.Context) ([]string, error) {
 	return domains, nil
 }
 
+// GetLocalWhitelistDomains retrieves the local whitelisted domains.
+func (db *DB) GetLocalWhitelistDomains(ctx context.Context) ([]string, error) {
+	return db.getLocalDomains(ctx, "filtering_whitelist", permanentEntries)
+}
+
 // DeleteWhitelistDomain removes a domain from the whitelist.
 func (db *DB) DeleteWhitelistDomain(ctx context.Context, domain string) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
-	result, err := db.conn.ExecContext(ctx, "DELETE FROM filtering_whitelist WHERE domain = ?", domain)
+	result, err := db.writer.ExecContext(ctx,
+		"DELETE FROM filtering_whitelist WHERE domain = ? AND expires_at = 0", domain)
 	if err != nil {
 		return fmt.Errorf("failed to delete whitelist domain: %w", err)
 	}
@@ -80,14 +97,91 @@ func (db *DB) DeleteWhitelistDomain(ctx context.Context, domain string) error {
 	return nil
 }
 
-// AddBlacklistDomain adds a domain to the blacklist.
-func (db *DB) AddBlacklistDomain(ctx context.Context, domain string) error {
+// AllowWhitelistDomainUntil stores a temporary allow of domain, which matches
+// the domain but not its subdomains, until expiresAt. A later expiry replaces
+// an earlier one; a domain already on the whitelist is left unchanged.
+// Expired temporary allows are removed. Local allows are kept when a
+// secondary syncs configuration from the cluster primary.
+func (db *DB) AllowWhitelistDomainUntil(ctx context.Context, domain string, expiresAt time.Time, local bool) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	tx, err := db.writer.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := deleteExpiredAllowsTx(ctx, tx, time.Now()); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO filtering_whitelist (domain, local, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT(domain) DO UPDATE SET local = excluded.local, expires_at = excluded.expires_at
+		WHERE filtering_whitelist.expires_at <> 0
+	`, domain, local, expiresAt.Unix())
+	if err != nil {
+		return fmt.Errorf("failed to allow whitelist domain %s: %w", domain, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// GetTemporaryAllows returns the expiry of each unexpired temporary allow.
+func (db *DB) GetTemporaryAllows(ctx context.Context) (map[string]time.Time, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	rows, err := db.conn.QueryContext(ctx,
+		"SELECT domain, expires_at FROM filtering_whitelist WHERE expires_at > ? ORDER BY domain",
+		time.Now().Unix())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query temporary allows: %w", err)
+	}
+	defer rows.Close()
+
+	allows := make(map[string]time.Time)
+	for rows.Next() {
+		var domain string
+		var expiresAt int64
+		if err := rows.Scan(&domain, &expiresAt); err != nil {
+			return nil, fmt.Errorf("failed to scan temporary allow: %w", err)
+		}
+		allows[domain] = time.Unix(expiresAt, 0).UTC()
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating temporary allows: %w", err)
+	}
+
+	return allows, nil
+}
+
+// deleteExpiredAllowsTx removes the temporary allows expired at now.
+func deleteExpiredAllowsTx(ctx context.Context, tx *sql.Tx, now time.Time) error {
+	_, err := tx.ExecContext(ctx,
+		"DELETE FROM filtering_whitelist WHERE expires_at <> 0 AND expires_at <= ?", now.Unix())
+	if err != nil {
+		return fmt.Errorf("failed to delete expired temporary allows: %w", err)
+	}
+	return nil
+}
+
+// AddBlacklistDomain adds a domain to the blacklist. Local domains are kept when a
+// secondary syncs configuration from the cluster primary.
+func (db *DB) AddBlacklistDomain(ctx context.Context, domain string, local bool) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
-	query := "INSERT OR IGNORE INTO filtering_blacklist (domain) VALUES (?)"
+	query := `
+		INSERT INTO filtering_blacklist (domain, local) VALUES (?, ?)
+		ON CONFLICT(domain) DO UPDATE SET local = excluded.local
+	`
 
-	_, err := db.conn.ExecContext(ctx, query, domain)
+	_, err := db.writer.ExecContext(ctx, query, domain, local)
 	if err != nil {
 		return fmt.Errorf("failed to add blacklist domain %s: %w", domain, err)
 	}
@@ -122,12 +216,17 @@ func (db *DB) GetBlacklistDomains(ctx context.Context) ([]string, error) {
 	return domains, nil
 }
 
+// GetLocalBlacklistDomains retrieves the local blacklisted domains.
+func (db *DB) GetLocalBlacklistDomains(ctx context.Context) ([]string, error) {
+	return db.getLocalDomains(ctx, "filtering_blacklist", allEntries)
+}
+
 // DeleteBlacklistDomain removes a domain from the blacklist.
 func (db *DB) DeleteBlacklistDomain(ctx context.Context, domain string) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
-	result, err := db.conn.ExecContext(ctx, "DELETE FROM filtering_blacklist WHERE domain = ?", domain)
+	result, err := db.writer.ExecContext(ctx, "DELETE FROM filtering_blacklist WHERE domain = ?", domain)
 	if err != nil {
 		return fmt.Errorf("failed to delete blacklist domain: %w", err)
 	}
@@ -144,6 +243,93 @@ func (db *DB) DeleteBlacklistDomain(ctx context.Context, domain string) error {
 	return nil
 }
 
+// Conditions selecting the rows of a whitelist or blacklist table that are
+// listed: the whitelist table also holds temporary allows.
+const (
+	allEntries       = "1"
+	permanentEntries = "expires_at = 0"
+)
+
+// getLocalDomains retrieves the local domains of a whitelist or blacklist
+// table, of the rows matching cond.
+func (db *DB) getLocalDomains(ctx context.Context, table, cond string) ([]string, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	rows, err := db.conn.QueryContext(ctx,
+		"SELECT domain FROM "+table+" WHERE local = 1 AND "+cond+" ORDER BY domain")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query local domains: %w", err)
+	}
+	defer rows.Close()
+
+	var domains []string
+	for rows.Next() {
+		var domain string
+		if err := rows.Scan(&domain); err != nil {
+			return nil, fmt.Errorf("failed to scan local domain: %w", err)
+		}
+		domains = append(domains, domain)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating local domains: %w", err)
+	}
+
+	return domains, nil
+}
+
+// DomainEntry is a whitelist or blacklist domain. IDs increase in the
+// order domains were added.
+type DomainEntry struct {
+	ID        int64
+	Domain    string
+	Local     bool
+	CreatedAt string
+}
+
+// GetWhitelistEntries retrieves all whitelist entries, without temporary
+// allows, ordered by domain.
+func (db *DB) GetWhitelistEntries(ctx context.Context) ([]DomainEntry, error) {
+	return db.getDomainEntries(ctx, "filtering_whitelist", permanentEntries)
+}
+
+// GetBlacklistEntries retrieves all blacklist entries, ordered by domain.
+func (db *DB) GetBlacklistEntries(ctx context.Context) ([]DomainEntry, error) {
+	return db.getDomainEntries(ctx, "filtering_blacklist", allEntries)
+}
+
+// getDomainEntries retrieves the entries of a whitelist or blacklist table,
+// of the rows matching cond.
+func (db *DB) getDomainEntries(ctx context.Context, table, cond string) ([]DomainEntry, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	rows, err := db.conn.QueryContext(ctx,
+		"SELECT id, domain, local, created_at FROM "+table+" WHERE "+cond+" ORDER BY domain")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query domains: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []DomainEntry
+	for rows.Next() {
+		var e DomainEntry
+		var createdAt sql.NullString
+		if err := rows.Scan(&e.ID, &e.Domain, &e.Local, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan domain: %w", err)
+		}
+		e.CreatedAt = createdAt.String
+		entries = append(entries, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating domains: %w", err)
+	}
+
+	return entries, nil
+}
+
 // AddBlocklist adds a remote blocklist source.
 func (db *DB) AddBlocklist(ctx context.Context, name, url, format string) error {
 	db.mu.Lock()
@@ -158,7 +344,7 @@ func (db *DB) AddBlocklist(ctx context.Context, name, url, format string) error
 			updated_at = CURRENT_TIMESTAMP
 	`
 
-	_, err := db.conn.ExecContext(ctx, query, name, url, format)
+	_, err := db.writer.ExecContext(ctx, query, name, url, format)
 	if err != nil {
 		return fmt.Errorf("failed to add blocklist %s: %w", name, err)
 	}
@@ -204,7 +390,7 @@ func (db *DB) DeleteBlocklist(ctx context.Context, name string) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
-	result, err := db.conn.ExecContext(ctx, "DELETE FROM filtering_blocklists WHERE name = ?", name)
+	result, err := db.writer.ExecContext(ctx, "DELETE FROM filtering_blocklists WHERE name = ?", name)
 	if err != nil {
 		return fmt.Errorf("failed to delete blocklist: %w", err)
 	}
@@ -228,7 +414,7 @@ func (db *DB) EnableBlocklist(ctx context.Context, name string, enabled bool) er
 
 	query := "UPDATE filtering_blocklists SET enabled = ?, updated_at = CURRENT_TIMESTAMP WHERE name = ?"
 
-	result, err := db.conn.ExecContext(ctx, query, enabled, name)
+	result, err := db.writer.ExecContext(ctx, query, enabled, name)
 	if err != nil {
 		return fmt.Errorf("failed to update blocklist: %w", err)
 	}
@@ -252,7 +438,7 @@ func (db *DB) UpdateBlocklistFetchTime(ctx context.Context, name string) error {
 
 	query := "UPDATE filtering_blocklists SET last_fetched = CURRENT_TIMESTAMP WHERE name = ?"
 
-	result, err := db.conn.ExecContext(ctx, query, name)
+	result, err := db.writer.ExecContext(ctx, query, name)
 	if err != nil {
 		return fmt.Errorf("failed to update blocklist fetch time: %w", err)
 	}
@@ -269,6 +455,49 @@ func (db *DB) UpdateBlocklistFetchTime(ctx context.Context, name string) error {
 	return nil
 }
 
+// GetCachedList returns the cached download of a blocklist URL, if any.
+// It implements filtering.FetchCache.
+func (db *DB) GetCachedList(ctx context.Context, url string) (filtering.CachedList, bool, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var list filtering.CachedList
+	err := db.conn.QueryRowContext(ctx,
+		"SELECT etag, last_modified, body FROM blocklist_cache WHERE url = ?", url,
+	).Scan(&list.ETag, &list.LastModified, &list.Body)
+	if errors.Is(err, sql.ErrNoRows) {
+		return filtering.CachedList{}, false, nil
+	}
+	if err != nil {
+		return filtering.CachedList{}, false, fmt.Errorf("failed to read cached blocklist %s: %w", url, err)
+	}
+
+	return list, true, nil
+}
+
+// PutCachedList stores the download of a blocklist URL with its HTTP
+// validators, replacing any earlier copy. It implements filtering.FetchCache.
+func (db *DB) PutCachedList(ctx context.Context, url string, list filtering.CachedList) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	query := `
+		INSERT INTO blocklist_cache (url, etag, last_modified, body, fetched_at)
+		VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(url) DO UPDATE SET
+			etag = excluded.etag,
+			last_modified = excluded.last_modified,
+			body = excluded.body,
+			fetched_at = CURRENT_TIMESTAMP
+	`
+
+	if _, err := db.writer.ExecContext(ctx, query, url, list.ETag, list.LastModified, list.Body); err != nil {
+		return fmt.Errorf("failed to cache blocklist %s: %w", url, err)
+	}
+
+	return nil
+}
+
 // GetFilteringEnabled returns whether filtering is enabled.
 func (db *DB) GetFilteringEnabled(ctx context.Context) (bool, error) {
 	db.mu.RLock()
@@ -290,7 +519,7 @@ func (db *DB) SetFilteringEnabled(ctx context.Context, enabled bool) error {
 
 	query := "UPDATE config_filtering SET enabled = ?, updated_at = CURRENT_TIMESTAMP WHERE id = 1"
 
-	result, err := db.conn.ExecContext(ctx, query, enabled)
+	result, err := db.writer.ExecContext(ctx, query, enabled)
 	if err != nil {
 		return fmt.Errorf("failed to set filtering enabled state: %w", err)
 	}
@@ -307,12 +536,40 @@ func (db *DB) SetFilteringEnabled(ctx context.Context, enabled bool) error {
 	return nil
 }
 
+// SetFilteringTTLs sets the TTLs of block page answers and blocked negative
+// answers, in seconds.
+func (db *DB) SetFilteringTTLs(ctx context.Context, blockTTL, negativeTTL int) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	result, err := db.writer.ExecContext(ctx, `
+		UPDATE config_filtering SET block_ttl = ?, negative_ttl = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = 1
+	`, blockTTL, negativeTTL)
+	if err != nil {
+		return fmt.Errorf("failed to set filtering TTLs: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		return errors.New("config_filtering row not found")
+	}
+
+	return nil
+}
+
 // FilteringConfig holds the filtering configuration from the typed table.
 type FilteringConfig struct {
 	Enabled         bool
 	LogBlocked      bool
 	LogAllowed      bool
 	RefreshInterval string
+	BlockTTL        int
+	NegativeTTL     int
 }
 
 // GetFilteringConfig retrieves the full filtering configuration.
@@ -322,9 +579,9 @@ func (db *DB) GetFilteringConfig(ctx context.Context) (FilteringConfig, error) {
 
 	var cfg FilteringConfig
 	err := db.conn.QueryRowContext(ctx, `
-		SELECT enabled, log_blocked, log_allowed, refresh_interval 
+		SELECT enabled, log_blocked, log_allowed, refresh_interval, block_ttl, negative_ttl
 		FROM config_filtering WHERE id = 1
-	`).Scan(&cfg.Enabled, &cfg.LogBlocked, &cfg.LogAllowed, &cfg.RefreshInterval)
+	`).Scan(&cfg.Enabled, &cfg.LogBlocked, &cfg.LogAllowed, &cfg.RefreshInterval, &cfg.BlockTTL, &cfg.NegativeTTL)
 	if err != nil {
 		return FilteringConfig{}, fmt.Errorf("failed to get filtering config: %w", err)
 	}
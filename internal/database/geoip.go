@@ -0,0 +1,44 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jroosing/hydradns/internal/config"
+)
+
+// GetGeoIPConfig retrieves the GeoIP configuration.
+func (db *DB) GetGeoIPConfig(ctx context.Context) (*config.GeoIPConfig, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	cfg := &config.GeoIPConfig{}
+	var blockASNs, blockCountries string
+	err := db.conn.QueryRowContext(ctx, `
+		SELECT country_db, asn_db, block_asns, block_countries
+		FROM config_geoip WHERE id = 1
+	`).Scan(&cfg.CountryDB, &cfg.ASNDB, &blockASNs, &blockCountries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read geoip config: %w", err)
+	}
+
+	for s := range strings.SplitSeq(blockASNs, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		n, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid geoip block_asns entry %q", s)
+		}
+		cfg.BlockASNs = append(cfg.BlockASNs, uint32(n))
+	}
+	for s := range strings.SplitSeq(blockCountries, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			cfg.BlockCountries = append(cfg.BlockCountries, s)
+		}
+	}
+
+	return cfg, nil
+}
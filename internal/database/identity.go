@@ -0,0 +1,24 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jroosing/hydradns/internal/config"
+)
+
+// GetIdentityConfig retrieves the server identity configuration.
+func (db *DB) GetIdentityConfig(ctx context.Context) (*config.IdentityConfig, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	cfg := &config.IdentityConfig{}
+	err := db.conn.QueryRowContext(ctx, `
+		SELECT version, hostname, server_id FROM config_identity WHERE id = 1
+	`).Scan(&cfg.Version, &cfg.Hostname, &cfg.ServerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read identity config: %w", err)
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,37 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jroosing/hydradns/internal/config"
+)
+
+// GetListenerProfiles retrieves the additional DNS listener profiles,
+// ordered by name.
+func (db *DB) GetListenerProfiles(ctx context.Context) ([]config.ListenerProfile, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	rows, err := db.conn.QueryContext(ctx, `
+		SELECT name, host, port, unfiltered FROM listener_profiles ORDER BY name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query listener profiles: %w", err)
+	}
+	defer rows.Close()
+
+	var profiles []config.ListenerProfile
+	for rows.Next() {
+		var p config.ListenerProfile
+		if err := rows.Scan(&p.Name, &p.Host, &p.Port, &p.Unfiltered); err != nil {
+			return nil, fmt.Errorf("failed to scan listener profile: %w", err)
+		}
+		profiles = append(profiles, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating listener profiles: %w", err)
+	}
+
+	return profiles, nil
+}
@@ -0,0 +1,32 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// SetLoggingLevel sets the log level and whether logs are structured.
+func (db *DB) SetLoggingLevel(ctx context.Context, level string, structured bool) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	result, err := db.writer.ExecContext(ctx, `
+		UPDATE config_logging SET level = ?, structured = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = 1
+	`, level, structured)
+	if err != nil {
+		return fmt.Errorf("failed to set logging level: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rows == 0 {
+		return errors.New("config_logging row not found")
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jroosing/hydradns/internal/config"
+)
+
+// GetNRDConfig retrieves the newly registered domain configuration.
+func (db *DB) GetNRDConfig(ctx context.Context) (*config.NRDConfig, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	cfg := &config.NRDConfig{}
+	err := db.conn.QueryRowContext(ctx, `
+		SELECT enabled, action, days FROM config_nrd WHERE id = 1
+	`).Scan(&cfg.Enabled, &cfg.Action, &cfg.Days)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read nrd config: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// LoadFirstSeen returns when each tracked registrable domain was first
+// queried. It implements filtering.FirstSeenStore.
+func (db *DB) LoadFirstSeen(ctx context.Context) (map[string]time.Time, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	rows, err := db.conn.QueryContext(ctx, "SELECT domain, first_seen FROM domain_first_seen")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query first-seen domains: %w", err)
+	}
+	defer rows.Close()
+
+	out := make(map[string]time.Time)
+	for rows.Next() {
+		var domain string
+		var seen int64
+		if err := rows.Scan(&domain, &seen); err != nil {
+			return nil, fmt.Errorf("failed to scan first-seen domain: %w", err)
+		}
+		out[domain] = time.Unix(seen, 0)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read first-seen domains: %w", err)
+	}
+
+	return out, nil
+}
+
+// RecordFirstSeen stores first-seen times, keeping the earlier time for
+// domains already stored. It implements filtering.FirstSeenStore.
+func (db *DB) RecordFirstSeen(ctx context.Context, firstSeen map[string]time.Time) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	tx, err := db.writer.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO domain_first_seen (domain, first_seen) VALUES (?, ?)
+		ON CONFLICT(domain) DO UPDATE SET first_seen = MIN(first_seen, excluded.first_seen)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for domain, seen := range firstSeen {
+		if _, err := stmt.ExecContext(ctx, domain, seen.Unix()); err != nil {
+			return fmt.Errorf("failed to record first-seen domain %s: %w", domain, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jroosing/hydradns/internal/config"
+)
+
+// Default connection settings.
+const (
+	DefaultBusyTimeout     = 5 * time.Second
+	DefaultMaxOpenConns    = 10
+	DefaultMaxIdleConns    = 5
+	DefaultConnMaxLifetime = time.Hour
+)
+
+// Options configures the SQLite connections. Zero fields use the defaults
+// above.
+//
+// Options cannot live in the database itself, so they are set through
+// environment variables (see ApplyEnv).
+type Options struct {
+	// BusyTimeout is how long a statement waits for a lock held by another
+	// connection or process before failing with SQLITE_BUSY.
+	BusyTimeout time.Duration
+	// MaxOpenConns limits the read connection pool. Writes always use a
+	// single connection.
+	MaxOpenConns int
+	// MaxIdleConns is the number of idle read connections kept open.
+	MaxIdleConns int
+	// ConnMaxLifetime is how long a connection is reused before reopening.
+	ConnMaxLifetime time.Duration
+}
+
+func (o Options) withDefaults() Options {
+	if o.BusyTimeout <= 0 {
+		o.BusyTimeout = DefaultBusyTimeout
+	}
+	if o.MaxOpenConns <= 0 {
+		o.MaxOpenConns = DefaultMaxOpenConns
+	}
+	if o.MaxIdleConns <= 0 {
+		o.MaxIdleConns = DefaultMaxIdleConns
+	}
+	if o.ConnMaxLifetime <= 0 {
+		o.ConnMaxLifetime = DefaultConnMaxLifetime
+	}
+	return o
+}
+
+// ApplyEnv overrides options from the HYDRADNS_DB_BUSY_TIMEOUT,
+// HYDRADNS_DB_MAX_OPEN_CONNS, HYDRADNS_DB_MAX_IDLE_CONNS and
+// HYDRADNS_DB_CONN_MAX_LIFETIME environment variables. lookup is typically
+// os.LookupEnv. Unset or empty variables are ignored; all malformed values
+// are reported together.
+func (o *Options) ApplyEnv(lookup func(string) (string, bool)) error {
+	var errs []error
+	envDuration := func(name string, field *time.Duration) {
+		if v, ok := lookup(config.EnvPrefix + name); ok && v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				errs = append(errs, fmt.Errorf("%s%s: invalid duration %q", config.EnvPrefix, name, v))
+				return
+			}
+			*field = d
+		}
+	}
+	envInt := func(name string, field *int) {
+		if v, ok := lookup(config.EnvPrefix + name); ok && v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				errs = append(errs, fmt.Errorf("%s%s: invalid count %q", config.EnvPrefix, name, v))
+				return
+			}
+			*field = n
+		}
+	}
+
+	envDuration("DB_BUSY_TIMEOUT", &o.BusyTimeout)
+	envInt("DB_MAX_OPEN_CONNS", &o.MaxOpenConns)
+	envInt("DB_MAX_IDLE_CONNS", &o.MaxIdleConns)
+	envDuration("DB_CONN_MAX_LIFETIME", &o.ConnMaxLifetime)
+	return errors.Join(errs...)
+}
@@ -0,0 +1,44 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jroosing/hydradns/internal/config"
+)
+
+// GetPrivacyConfig retrieves the query log privacy configuration.
+func (db *DB) GetPrivacyConfig(ctx context.Context) (*config.PrivacyConfig, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	cfg := &config.PrivacyConfig{}
+	err := db.conn.QueryRowContext(ctx, `
+		SELECT level, anonymize
+		FROM config_privacy WHERE id = 1
+	`).Scan(&cfg.Level, &cfg.Anonymize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read privacy config: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// SetPrivacyConfig updates the query log privacy configuration.
+func (db *DB) SetPrivacyConfig(ctx context.Context, cfg *config.PrivacyConfig) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	_, err := db.writer.ExecContext(ctx, `
+		UPDATE config_privacy SET
+			level = ?,
+			anonymize = ?,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = 1
+	`, cfg.Level, cfg.Anonymize)
+	if err != nil {
+		return fmt.Errorf("failed to update privacy config: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jroosing/hydradns/internal/config"
+)
+
+// GetQTypeRules retrieves the qtype rules in the order they apply.
+func (db *DB) GetQTypeRules(ctx context.Context) ([]config.QTypeRule, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	rows, err := db.conn.QueryContext(ctx, `
+		SELECT qtypes, clients, action FROM qtype_rules ORDER BY id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query qtype rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []config.QTypeRule
+	for rows.Next() {
+		var (
+			r               config.QTypeRule
+			qtypes, clients string
+		)
+		if err := rows.Scan(&qtypes, &clients, &r.Action); err != nil {
+			return nil, fmt.Errorf("failed to scan qtype rule: %w", err)
+		}
+		for t := range strings.SplitSeq(qtypes, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				r.QTypes = append(r.QTypes, t)
+			}
+		}
+		for c := range strings.SplitSeq(clients, ",") {
+			if c = strings.TrimSpace(c); c != "" {
+				r.Clients = append(r.Clients, c)
+			}
+		}
+		rules = append(rules, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating qtype rules: %w", err)
+	}
+
+	return rules, nil
+}
@@ -0,0 +1,53 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jroosing/hydradns/internal/config"
+)
+
+// GetResponsePipeline retrieves the response pipeline steps in the order
+// they run.
+func (db *DB) GetResponsePipeline(ctx context.Context) ([]config.ResponseStep, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	rows, err := db.conn.QueryContext(ctx, `
+		SELECT type, clients, min_ttl, max_ttl, answer_order, rcode, ede_code, ede_text, record_types
+		FROM response_pipeline ORDER BY id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query response pipeline: %w", err)
+	}
+	defer rows.Close()
+
+	var steps []config.ResponseStep
+	for rows.Next() {
+		var (
+			st                   config.ResponseStep
+			clients, recordTypes string
+		)
+		if err := rows.Scan(&st.Type, &clients, &st.MinTTL, &st.MaxTTL, &st.Order,
+			&st.RCode, &st.Code, &st.Text, &recordTypes); err != nil {
+			return nil, fmt.Errorf("failed to scan response pipeline step: %w", err)
+		}
+		for c := range strings.SplitSeq(clients, ",") {
+			if c = strings.TrimSpace(c); c != "" {
+				st.Clients = append(st.Clients, c)
+			}
+		}
+		for t := range strings.SplitSeq(recordTypes, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				st.RecordTypes = append(st.RecordTypes, t)
+			}
+		}
+		steps = append(steps, st)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating response pipeline: %w", err)
+	}
+
+	return steps, nil
+}
@@ -0,0 +1,109 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrSetupCompleted is returned by CompleteSetup when first-run setup is not
+// pending.
+var ErrSetupCompleted = errors.New("setup already completed")
+
+// BootstrapPending reports whether no generated API key has been stored
+// yet: the database is new, or its API was never protected by a key. It
+// stays set until Bootstrap succeeds, so a start interrupted before the key
+// was stored bootstraps again.
+func (db *DB) BootstrapPending(ctx context.Context) (bool, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var pending bool
+	err := db.conn.QueryRowContext(ctx, "SELECT bootstrap_pending FROM config_api WHERE id = 1").Scan(&pending)
+	if err != nil {
+		return false, fmt.Errorf("failed to get bootstrap state: %w", err)
+	}
+	return pending, nil
+}
+
+// Bootstrap stores apiKey as the management API key, marks first-run setup
+// as pending and clears the pending bootstrap, in one statement. It is
+// called while BootstrapPending reports true.
+func (db *DB) Bootstrap(ctx context.Context, apiKey string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	_, err := db.writer.ExecContext(ctx, `
+		UPDATE config_api SET api_key = ?, setup_pending = 1, bootstrap_pending = 0,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = 1
+	`, apiKey)
+	if err != nil {
+		return fmt.Errorf("failed to bootstrap API config: %w", err)
+	}
+	return nil
+}
+
+// SetupPending reports whether first-run setup has not been completed yet.
+func (db *DB) SetupPending(ctx context.Context) (bool, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var pending bool
+	err := db.conn.QueryRowContext(ctx, "SELECT setup_pending FROM config_api WHERE id = 1").Scan(&pending)
+	if err != nil {
+		return false, fmt.Errorf("failed to get setup state: %w", err)
+	}
+	return pending, nil
+}
+
+// CompleteSetup stores the initial upstream servers and filtering state and
+// clears the pending first-run setup flag, in one transaction. Nil servers
+// or filteringEnabled leave that setting unchanged. It returns
+// ErrSetupCompleted, without writing anything, if setup was not pending, so
+// concurrent setup requests cannot both apply their settings.
+func (db *DB) CompleteSetup(ctx context.Context, servers []string, filteringEnabled *bool) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	tx, err := db.writer.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// Claim setup first so a request that lost the race writes nothing
+	result, err := tx.ExecContext(ctx, `
+		UPDATE config_api SET setup_pending = 0, updated_at = CURRENT_TIMESTAMP
+		WHERE id = 1 AND setup_pending = 1
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to complete setup: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if rows == 0 {
+		return ErrSetupCompleted
+	}
+
+	if servers != nil {
+		if err := replaceUpstreamServers(ctx, tx, servers); err != nil {
+			return err
+		}
+	}
+	if filteringEnabled != nil {
+		_, err := tx.ExecContext(ctx,
+			"UPDATE config_filtering SET enabled = ?, updated_at = CURRENT_TIMESTAMP WHERE id = 1",
+			*filteringEnabled)
+		if err != nil {
+			return fmt.Errorf("failed to set filtering enabled state: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jroosing/hydradns/internal/config"
+)
+
+// GetShadowConfig retrieves the shadow upstream configuration.
+func (db *DB) GetShadowConfig(ctx context.Context) (*config.ShadowConfig, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	cfg := &config.ShadowConfig{}
+	var servers string
+	err := db.conn.QueryRowContext(ctx, `
+		SELECT enabled, servers, percent, timeout
+		FROM config_shadow WHERE id = 1
+	`).Scan(&cfg.Enabled, &servers, &cfg.Percent, &cfg.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read shadow config: %w", err)
+	}
+	for s := range strings.SplitSeq(servers, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			cfg.Servers = append(cfg.Servers, s)
+		}
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,32 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jroosing/hydradns/internal/config"
+)
+
+// GetSynthPTRConfig retrieves the PTR synthesis configuration.
+func (db *DB) GetSynthPTRConfig(ctx context.Context) (*config.SynthPTRConfig, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	cfg := &config.SynthPTRConfig{}
+	var prefixes string
+	err := db.conn.QueryRowContext(ctx, `
+		SELECT enabled, prefixes, template, ttl
+		FROM config_synth_ptr WHERE id = 1
+	`).Scan(&cfg.Enabled, &prefixes, &cfg.Template, &cfg.TTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PTR synthesis config: %w", err)
+	}
+	for s := range strings.SplitSeq(prefixes, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			cfg.Prefixes = append(cfg.Prefixes, s)
+		}
+	}
+
+	return cfg, nil
+}
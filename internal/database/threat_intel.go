@@ -0,0 +1,25 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jroosing/hydradns/internal/config"
+)
+
+// GetThreatIntelConfig retrieves the threat intelligence configuration.
+func (db *DB) GetThreatIntelConfig(ctx context.Context) (*config.ThreatIntelConfig, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	cfg := &config.ThreatIntelConfig{}
+	err := db.conn.QueryRowContext(ctx, `
+		SELECT enabled, provider, url, auth_key, block_ttl, cache_ttl, rate_limit
+		FROM config_threat_intel WHERE id = 1
+	`).Scan(&cfg.Enabled, &cfg.Provider, &cfg.URL, &cfg.AuthKey, &cfg.BlockTTL, &cfg.CacheTTL, &cfg.RateLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read threat intel config: %w", err)
+	}
+
+	return cfg, nil
+}
@@ -2,7 +2,10 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+
+	"github.com/jroosing/hydradns/internal/config"
 )
 
 // UpstreamServer represents an upstream DNS server.
@@ -26,7 +29,7 @@ func (db *DB) AddUpstreamServer(ctx context.Context, serverAddress string, prior
 			updated_at = CURRENT_TIMESTAMP
 	`
 
-	_, err := db.conn.ExecContext(ctx, query, serverAddress, priority)
+	_, err := db.writer.ExecContext(ctx, query, serverAddress, priority)
 	if err != nil {
 		return fmt.Errorf("failed to add upstream server %s: %w", serverAddress, err)
 	}
@@ -74,15 +77,29 @@ func (db *DB) SetUpstreamServers(ctx context.Context, servers []string) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
-	tx, err := db.conn.BeginTx(ctx, nil)
+	tx, err := db.writer.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer func() { _ = tx.Rollback() }()
 
+	if err := replaceUpstreamServers(ctx, tx, servers); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// replaceUpstreamServers replaces all upstream servers with servers within
+// tx, in priority order.
+func replaceUpstreamServers(ctx context.Context, tx *sql.Tx, servers []string) error {
 	// Delete all existing servers
-	if _, execErr := tx.ExecContext(ctx, "DELETE FROM upstream_servers"); execErr != nil {
-		return fmt.Errorf("failed to delete existing servers: %w", execErr)
+	if _, err := tx.ExecContext(ctx, "DELETE FROM upstream_servers"); err != nil {
+		return fmt.Errorf("failed to delete existing servers: %w", err)
 	}
 
 	// Insert new servers
@@ -100,11 +117,6 @@ func (db *DB) SetUpstreamServers(ctx context.Context, servers []string) error {
 			return fmt.Errorf("failed to insert server %s: %w", server, err)
 		}
 	}
-
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
-
 	return nil
 }
 
@@ -113,7 +125,7 @@ func (db *DB) DeleteUpstreamServer(ctx context.Context, serverAddress string) er
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
-	result, err := db.conn.ExecContext(ctx, "DELETE FROM upstream_servers WHERE server_address = ?", serverAddress)
+	result, err := db.writer.ExecContext(ctx, "DELETE FROM upstream_servers WHERE server_address = ?", serverAddress)
 	if err != nil {
 		return fmt.Errorf("failed to delete upstream server: %w", err)
 	}
@@ -137,7 +149,7 @@ func (db *DB) EnableUpstreamServer(ctx context.Context, serverAddress string, en
 
 	query := "UPDATE upstream_servers SET enabled = ?, updated_at = CURRENT_TIMESTAMP WHERE server_address = ?"
 
-	result, err := db.conn.ExecContext(ctx, query, enabled, serverAddress)
+	result, err := db.writer.ExecContext(ctx, query, enabled, serverAddress)
 	if err != nil {
 		return fmt.Errorf("failed to update upstream server: %w", err)
 	}
@@ -153,3 +165,98 @@ func (db *DB) EnableUpstreamServer(ctx context.Context, serverAddress string, en
 
 	return nil
 }
+
+// GetEDNSOptionRules retrieves the EDNS option rules for forwarded queries,
+// ordered by option.
+func (db *DB) GetEDNSOptionRules(ctx context.Context) ([]config.EDNSOptionRule, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	rows, err := db.conn.QueryContext(ctx, `
+		SELECT option_name, action, value
+		FROM upstream_edns_options
+		ORDER BY option_name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query EDNS option rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []config.EDNSOptionRule
+	for rows.Next() {
+		var r config.EDNSOptionRule
+		if err := rows.Scan(&r.Option, &r.Action, &r.Value); err != nil {
+			return nil, fmt.Errorf("failed to scan EDNS option rule: %w", err)
+		}
+		rules = append(rules, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating EDNS option rules: %w", err)
+	}
+
+	return rules, nil
+}
+
+// GetDoHServers retrieves the DNS-over-HTTPS server URLs in priority order.
+func (db *DB) GetDoHServers(ctx context.Context) ([]string, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	rows, err := db.conn.QueryContext(ctx, `
+		SELECT url
+		FROM upstream_doh_servers
+		ORDER BY priority, id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query DoH servers: %w", err)
+	}
+	defer rows.Close()
+
+	var servers []string
+	for rows.Next() {
+		var u string
+		if err := rows.Scan(&u); err != nil {
+			return nil, fmt.Errorf("failed to scan DoH server: %w", err)
+		}
+		servers = append(servers, u)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating DoH servers: %w", err)
+	}
+
+	return servers, nil
+}
+
+// GetTransportRules retrieves the forced upstream transports, ordered by
+// domain.
+func (db *DB) GetTransportRules(ctx context.Context) ([]config.TransportRule, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	rows, err := db.conn.QueryContext(ctx, `
+		SELECT domain, transport
+		FROM upstream_transport_rules
+		ORDER BY domain
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transport rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []config.TransportRule
+	for rows.Next() {
+		var r config.TransportRule
+		if err := rows.Scan(&r.Domain, &r.Transport); err != nil {
+			return nil, fmt.Errorf("failed to scan transport rule: %w", err)
+		}
+		rules = append(rules, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating transport rules: %w", err)
+	}
+
+	return rules, nil
+}
@@ -1,6 +1,7 @@
 package dns
 
 import (
+	"cmp"
 	"encoding/binary"
 	"fmt"
 	"strings"
@@ -14,6 +15,41 @@ func NormalizeName(name string) string {
 	return strings.ToLower(strings.TrimSuffix(name, "."))
 }
 
+// CanonicalName returns the form used as a key for configured names:
+// surrounding whitespace trimmed, Unicode labels converted to A-labels,
+// lowercased, and without a trailing dot. Two names that resolve the same
+// way have the same canonical form.
+func CanonicalName(name string) (string, error) {
+	a, err := ToASCII(strings.TrimSpace(name))
+	if err != nil {
+		return "", err
+	}
+	return NormalizeName(a), nil
+}
+
+// CompareNames orders names canonically (RFC 4034 §6.1), as NSEC chains
+// are sorted: label by label starting from the root, each label compared
+// as lowercase bytes, with a name sorting before its subdomains. It returns
+// -1, 0, or +1.
+func CompareNames(a, b string) int {
+	la := nameLabels(NormalizeName(a))
+	lb := nameLabels(NormalizeName(b))
+	for i := 1; i <= len(la) && i <= len(lb); i++ {
+		if c := strings.Compare(la[len(la)-i], lb[len(lb)-i]); c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(la), len(lb))
+}
+
+// nameLabels splits a normalized name into labels; the root has none.
+func nameLabels(name string) []string {
+	if name == "" {
+		return nil
+	}
+	return strings.Split(name, ".")
+}
+
 // EncodeName encodes a domain name to DNS wire format (RFC 1035 Section 3.1).
 //
 // DNS names are encoded as a sequence of labels, where each label is:
@@ -79,8 +115,8 @@ func EncodeName(domain string) ([]byte, error) {
 	}
 	out = append(out, 0) // Terminating zero-length label
 
-	if len(out) > 255 {
-		return nil, fmt.Errorf("%w: encoded domain name too long (%d > 255)", ErrDNSError, len(out))
+	if len(out) > maxNameWireLength {
+		return nil, fmt.Errorf("%w (%d > %d)", ErrNameTooLong, len(out), maxNameWireLength)
 	}
 	return out, nil
 }
@@ -112,16 +148,26 @@ func DecodeName(msg []byte, off *int) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	// Wire length is one length byte per label plus the root label, i.e.
+	// the dotted form plus two bytes. Pointers let a name exceed the limit
+	// without the message itself being large.
+	if name != "" && len(name)+2 > maxNameWireLength {
+		return "", fmt.Errorf("%w (%d > %d)", ErrNameTooLong, len(name)+2, maxNameWireLength)
+	}
 	return name, nil
 }
 
+// maxNameWireLength is the longest name allowed in wire format (RFC 1035
+// Section 3.1).
+const maxNameWireLength = 255
+
 // decodeName is the recursive implementation of DecodeName.
 // It tracks recursion depth and visited offsets to detect compression loops.
 func decodeName(msg []byte, off *int, depth int, visited map[int]struct{}) (string, error) {
 	const maxCompressionDepth = 20
 
 	if depth > maxCompressionDepth {
-		return "", fmt.Errorf("%w: too many DNS compression pointer indirections", ErrDNSError)
+		return "", fmt.Errorf("%w: too many indirections", ErrCompressionLoop)
 	}
 	if *off < 0 || *off >= len(msg) {
 		return "", fmt.Errorf("%w: unexpected EOF while decoding DNS name", ErrDNSError)
@@ -202,7 +248,7 @@ func followCompressionPointer(
 		return "", fmt.Errorf("%w: DNS compression pointer out of bounds", ErrDNSError)
 	}
 	if _, ok := visited[ptr]; ok {
-		return "", fmt.Errorf("%w: DNS compression pointer loop detected", ErrDNSError)
+		return "", ErrCompressionLoop
 	}
 	visited[ptr] = struct{}{}
 
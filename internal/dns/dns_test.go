@@ -2,6 +2,7 @@ package dns_test
 
 import (
 	"encoding/binary"
+	"net/netip"
 	"testing"
 
 	"github.com/jroosing/hydradns/internal/dns"
@@ -142,6 +143,15 @@ func TestPacket_MarshalAndParse_MultipleRecordTypes(t *testing.T) {
 // DNS Header Flag Tests
 // =============================================================================
 
+func TestNewTransactionID(t *testing.T) {
+	seen := map[uint16]bool{}
+	for range 100 {
+		seen[dns.NewTransactionID()] = true
+	}
+	// 100 random 16-bit values repeat rarely; a counter or constant would not
+	assert.Greater(t, len(seen), 90)
+}
+
 func TestHeader_Flags(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -313,6 +323,46 @@ func TestQuestion_MarshalAndParse(t *testing.T) {
 	}
 }
 
+func TestParseRecordType(t *testing.T) {
+	tests := map[string]dns.RecordType{
+		"AAAA":      dns.TypeAAAA,
+		" txt ":     dns.TypeTXT,
+		"any":       dns.TypeANY,
+		"TYPE65535": dns.RecordType(65535),
+		"type64":    dns.RecordType(64),
+		"12":        dns.TypePTR,
+	}
+	for in, want := range tests {
+		got, err := dns.ParseRecordType(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+
+	for _, in := range []string{"", "BOGUS", "TYPE65536", "-1"} {
+		_, err := dns.ParseRecordType(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestParseRCode(t *testing.T) {
+	tests := map[string]dns.RCode{
+		"SERVFAIL":  dns.RCodeServFail,
+		" nxdomain": dns.RCodeNXDomain,
+		"RCODE9":    dns.RCode(9),
+		"5":         dns.RCodeRefused,
+	}
+	for in, want := range tests {
+		got, err := dns.ParseRCode(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+
+	for _, in := range []string{"", "BOGUS", "RCODE16", "-1"} {
+		_, err := dns.ParseRCode(in)
+		assert.Error(t, err, in)
+	}
+}
+
 // =============================================================================
 // DNS Parsing Error Tests
 // =============================================================================
@@ -344,6 +394,71 @@ func TestParsePacket_TruncatedData(t *testing.T) {
 	}
 }
 
+// rawQuery builds a single-question query header followed by name, which
+// must already be in wire format, and QTYPE A / QCLASS IN.
+func rawQuery(name []byte) []byte {
+	msg := []byte{0x12, 0x34, 0x01, 0x00, 0, 1, 0, 0, 0, 0, 0, 0}
+	msg = append(msg, name...)
+	return append(msg, 0, 1, 0, 1)
+}
+
+func TestParseRequestBounded_CompressionLoop(t *testing.T) {
+	// The question name is a pointer to itself
+	_, err := dns.ParseRequestBounded(rawQuery([]byte{0xC0, 0x0C}))
+	require.ErrorIs(t, err, dns.ErrCompressionLoop)
+	require.ErrorIs(t, err, dns.ErrDNSError)
+}
+
+func TestParseRequestBounded_NameTooLong(t *testing.T) {
+	var name []byte
+	for range 4 {
+		name = append(name, 63)
+		for range 63 {
+			name = append(name, 'a')
+		}
+	}
+	name = append(name, 0) // 257 octets
+
+	_, err := dns.ParseRequestBounded(rawQuery(name))
+	require.ErrorIs(t, err, dns.ErrNameTooLong)
+
+	// 255 octets is the limit and still parses
+	name = append(name[:64*3], 61)
+	for range 61 {
+		name = append(name, 'a')
+	}
+	name = append(name, 0)
+	_, err = dns.ParseRequestBounded(rawQuery(name))
+	require.NoError(t, err)
+}
+
+func TestParseStats_CountsRejections(t *testing.T) {
+	var stats dns.ParseStats
+
+	valid, err := dns.Packet{
+		Header:    dns.Header{ID: 1},
+		Questions: []dns.Question{{Name: "example.com", Type: 1, Class: 1}},
+	}.Marshal()
+	require.NoError(t, err)
+
+	_, err = stats.ParseRequest(valid)
+	require.NoError(t, err)
+	_, err = stats.ParseRequest(valid[:15])
+	require.Error(t, err)
+	_, err = stats.ParseRequest(rawQuery([]byte{0xC0, 0x0C}))
+	require.Error(t, err)
+	_, err = stats.ParseRequest(make([]byte, dns.MaxIncomingDNSMessageSize+1))
+	require.ErrorIs(t, err, dns.ErrMessageTooLarge)
+
+	stats.Record(nil)
+
+	assert.Equal(t, dns.ParseStatsSnapshot{
+		Malformed:         3,
+		PointerLoops:      1,
+		OversizedMessages: 1,
+	}, stats.Snapshot())
+}
+
 // =============================================================================
 // DNS Record Data Tests
 // =============================================================================
@@ -499,6 +614,109 @@ func TestMarshalEDNSOptions_SkipsOversized(t *testing.T) {
 	assert.Equal(t, []byte("ok"), parsed[0].Data)
 }
 
+func TestRewriteEDNSOptions_SeesAllOptions(t *testing.T) {
+	rdata := append(marshalTestEDNSOption(dns.EDNSOptionECS, []byte{0, 1, 24, 0, 192, 0, 2}),
+		marshalTestEDNSOption(dns.EDNSOptionCookie, []byte("abcdefgh"))...)
+	msg := ednsQueryWithOptions(t, "WwW.Example.COM", rdata)
+
+	var seen []uint16
+	out := dns.RewriteEDNSOptions(msg, func(opts []dns.EDNSOption) []dns.EDNSOption {
+		for _, o := range opts {
+			seen = append(seen, o.Code)
+		}
+		return opts[1:] // drop ECS
+	})
+
+	assert.Equal(t, []uint16{dns.EDNSOptionECS, dns.EDNSOptionCookie}, seen)
+	const qnameEnd = 12 + 17 // header + encoded "WwW.Example.COM"
+	assert.Equal(t, msg[:qnameEnd], out[:qnameEnd], "header and QNAME are copied byte for byte")
+
+	var after []uint16
+	dns.RewriteEDNSOptions(out, func(opts []dns.EDNSOption) []dns.EDNSOption {
+		for _, o := range opts {
+			after = append(after, o.Code)
+		}
+		return opts
+	})
+	assert.Equal(t, []uint16{dns.EDNSOptionCookie}, after)
+
+	parsed, err := dns.ParsePacket(out)
+	require.NoError(t, err)
+	assert.NotNil(t, dns.ExtractOPT(parsed.Additionals))
+}
+
+func TestRewriteEDNSOptions_NoOPT(t *testing.T) {
+	req := dns.Packet{
+		Header:    dns.Header{ID: 1},
+		Questions: []dns.Question{{Name: "example.com", Type: uint16(dns.TypeA), Class: uint16(dns.ClassIN)}},
+	}
+	msg, err := req.Marshal()
+	require.NoError(t, err)
+
+	called := false
+	out := dns.RewriteEDNSOptions(msg, func(opts []dns.EDNSOption) []dns.EDNSOption {
+		called = true
+		return opts
+	})
+	assert.False(t, called)
+	assert.Equal(t, msg, out)
+}
+
+func TestAppendEDNSOption(t *testing.T) {
+	nsid := dns.EDNSOption{Code: dns.EDNSOptionNSID, Data: []byte("ns1")}
+
+	// Added to an existing OPT record
+	msg := ednsQueryWithOptions(t, "example.com", marshalTestEDNSOption(dns.EDNSOptionCookie, []byte("abcdefgh")))
+	parsed, err := dns.ParsePacket(dns.AppendEDNSOption(msg, nsid))
+	require.NoError(t, err)
+	require.Len(t, parsed.Additionals, 1)
+	assert.True(t, dns.HasEDNSOption(parsed, dns.EDNSOptionCookie))
+	assert.True(t, dns.HasEDNSOption(parsed, dns.EDNSOptionNSID))
+
+	// Carried by a new OPT record
+	req := dns.Packet{
+		Header:    dns.Header{ID: 1},
+		Questions: []dns.Question{{Name: "example.com", Type: uint16(dns.TypeA), Class: uint16(dns.ClassIN)}},
+	}
+	msg, err = req.Marshal()
+	require.NoError(t, err)
+	assert.False(t, dns.HasEDNSOption(req, dns.EDNSOptionNSID))
+	parsed, err = dns.ParsePacket(dns.AppendEDNSOption(msg, nsid))
+	require.NoError(t, err)
+	opt := dns.ExtractOPT(parsed.Additionals)
+	require.NotNil(t, opt)
+	assert.Equal(t, uint16(dns.EDNSDefaultUDPPayloadSize), opt.UDPPayloadSize)
+	assert.True(t, dns.HasEDNSOption(parsed, dns.EDNSOptionNSID))
+}
+
+func TestECSOption(t *testing.T) {
+	opt := dns.ECSOption(netip.MustParsePrefix("192.0.2.77/24"))
+	assert.Equal(t, dns.EDNSOptionECS, opt.Code)
+	assert.Equal(t, []byte{0, 1, 24, 0, 192, 0, 2}, opt.Data, "host bits are cleared and truncated")
+
+	opt = dns.ECSOption(netip.MustParsePrefix("2001:db8::/32"))
+	assert.Equal(t, []byte{0, 2, 32, 0, 0x20, 0x01, 0x0d, 0xb8}, opt.Data)
+
+	opt = dns.ECSOption(netip.MustParsePrefix("0.0.0.0/0"))
+	assert.Equal(t, []byte{0, 1, 0, 0}, opt.Data)
+}
+
+// ednsQueryWithOptions builds a wire-format A query for name whose OPT record
+// carries rdata.
+func ednsQueryWithOptions(t *testing.T, name string, rdata []byte) []byte {
+	t.Helper()
+	req := dns.Packet{
+		Header:    dns.Header{ID: 1, Flags: dns.RDFlag},
+		Questions: []dns.Question{{Name: name, Type: uint16(dns.TypeA), Class: uint16(dns.ClassIN)}},
+		Additionals: []dns.Record{
+			dns.NewOpaqueRecord(dns.RRHeader{Class: dns.EDNSDefaultUDPPayloadSize}, dns.TypeOPT, rdata),
+		},
+	}
+	msg, err := req.Marshal()
+	require.NoError(t, err)
+	return msg
+}
+
 func marshalTestEDNSOption(code uint16, data []byte) []byte {
 	buf := make([]byte, 4+len(data))
 	binary.BigEndian.PutUint16(buf[0:2], code)
@@ -0,0 +1,348 @@
+package dns
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DNSKEYRecord represents a DNSSEC public key (RFC 4034 §2).
+type DNSKEYRecord struct {
+	H         RRHeader
+	Flags     uint16 // 256 = zone key (ZSK), 257 = zone key + SEP (KSK)
+	Protocol  uint8  // Always 3
+	Algorithm uint8  // DNSSEC algorithm number (e.g., 8 = RSASHA256, 13 = ECDSAP256SHA256)
+	PublicKey []byte
+}
+
+// NewDNSKEYRecord creates a new DNSKEY record.
+func NewDNSKEYRecord(h RRHeader, flags uint16, protocol, algorithm uint8, publicKey []byte) *DNSKEYRecord {
+	return &DNSKEYRecord{H: h, Flags: flags, Protocol: protocol, Algorithm: algorithm, PublicKey: publicKey}
+}
+
+// Type returns TypeDNSKEY.
+func (r *DNSKEYRecord) Type() RecordType { return TypeDNSKEY }
+
+// Header returns the record header.
+func (r *DNSKEYRecord) Header() RRHeader { return r.H }
+
+// SetHeader sets the record header.
+func (r *DNSKEYRecord) SetHeader(h RRHeader) { r.H = h }
+
+// MarshalRData marshals the key to wire format.
+func (r *DNSKEYRecord) MarshalRData() ([]byte, error) {
+	b := make([]byte, 4, 4+len(r.PublicKey))
+	binary.BigEndian.PutUint16(b[0:2], r.Flags)
+	b[2] = r.Protocol
+	b[3] = r.Algorithm
+	return append(b, r.PublicKey...), nil
+}
+
+// KeyTag computes the key tag used by DS and RRSIG records to refer to
+// this key (RFC 4034 Appendix B).
+func (r *DNSKEYRecord) KeyTag() uint16 {
+	rdata, _ := r.MarshalRData()
+	var ac uint32
+	for i, b := range rdata {
+		if i&1 == 0 {
+			ac += uint32(b) << 8
+		} else {
+			ac += uint32(b)
+		}
+	}
+	ac += ac >> 16 & 0xFFFF
+	return uint16(ac & 0xFFFF)
+}
+
+// String returns the RDATA in presentation format: "257 3 8 <base64 key>".
+func (r *DNSKEYRecord) String() string {
+	return fmt.Sprintf("%d %d %d %s", r.Flags, r.Protocol, r.Algorithm, base64.StdEncoding.EncodeToString(r.PublicKey))
+}
+
+// ParseDNSKEYRData parses DNSKEY record RDATA from wire format.
+func ParseDNSKEYRData(msg []byte, off *int, rdlen int) (*DNSKEYRecord, error) {
+	if rdlen < 4 {
+		return nil, fmt.Errorf("%w: DNSKEY record too short (RFC 4034 §2.1), got %d bytes", ErrDNSError, rdlen)
+	}
+	b := msg[*off : *off+rdlen]
+	*off += rdlen
+	return &DNSKEYRecord{
+		Flags:     binary.BigEndian.Uint16(b[0:2]),
+		Protocol:  b[2],
+		Algorithm: b[3],
+		PublicKey: cloneBytes(b[4:]),
+	}, nil
+}
+
+// DSRecord represents a DNSSEC delegation signer (RFC 4034 §5).
+type DSRecord struct {
+	H          RRHeader
+	KeyTag     uint16 // Key tag of the referenced DNSKEY
+	Algorithm  uint8  // Algorithm of the referenced DNSKEY
+	DigestType uint8  // 1 = SHA-1, 2 = SHA-256, 4 = SHA-384
+	Digest     []byte
+}
+
+// NewDSRecord creates a new DS record.
+func NewDSRecord(h RRHeader, keyTag uint16, algorithm, digestType uint8, digest []byte) *DSRecord {
+	return &DSRecord{H: h, KeyTag: keyTag, Algorithm: algorithm, DigestType: digestType, Digest: digest}
+}
+
+// Type returns TypeDS.
+func (r *DSRecord) Type() RecordType { return TypeDS }
+
+// Header returns the record header.
+func (r *DSRecord) Header() RRHeader { return r.H }
+
+// SetHeader sets the record header.
+func (r *DSRecord) SetHeader(h RRHeader) { r.H = h }
+
+// MarshalRData marshals the delegation signer to wire format.
+func (r *DSRecord) MarshalRData() ([]byte, error) {
+	b := make([]byte, 4, 4+len(r.Digest))
+	binary.BigEndian.PutUint16(b[0:2], r.KeyTag)
+	b[2] = r.Algorithm
+	b[3] = r.DigestType
+	return append(b, r.Digest...), nil
+}
+
+// String returns the RDATA in presentation format: "12345 8 2 <HEX digest>".
+func (r *DSRecord) String() string {
+	return fmt.Sprintf("%d %d %d %s", r.KeyTag, r.Algorithm, r.DigestType, upperHex(r.Digest))
+}
+
+// ParseDSRData parses DS record RDATA from wire format.
+func ParseDSRData(msg []byte, off *int, rdlen int) (*DSRecord, error) {
+	if rdlen < 4 {
+		return nil, fmt.Errorf("%w: DS record too short (RFC 4034 §5.1), got %d bytes", ErrDNSError, rdlen)
+	}
+	b := msg[*off : *off+rdlen]
+	*off += rdlen
+	return &DSRecord{
+		KeyTag:     binary.BigEndian.Uint16(b[0:2]),
+		Algorithm:  b[2],
+		DigestType: b[3],
+		Digest:     cloneBytes(b[4:]),
+	}, nil
+}
+
+// rrsigFixedLen is the size of the RRSIG RDATA fields before the signer name.
+const rrsigFixedLen = 18
+
+// RRSIGRecord represents a DNSSEC signature over an RRset (RFC 4034 §3).
+type RRSIGRecord struct {
+	H           RRHeader
+	TypeCovered RecordType // Type of the signed RRset
+	Algorithm   uint8
+	Labels      uint8  // Label count of the original owner name
+	OriginalTTL uint32 // TTL of the RRset as signed
+	Expiration  uint32 // Seconds since the epoch, serial number arithmetic
+	Inception   uint32 // Seconds since the epoch, serial number arithmetic
+	KeyTag      uint16
+	SignerName  string
+	Signature   []byte
+}
+
+// Type returns TypeRRSIG.
+func (r *RRSIGRecord) Type() RecordType { return TypeRRSIG }
+
+// Header returns the record header.
+func (r *RRSIGRecord) Header() RRHeader { return r.H }
+
+// SetHeader sets the record header.
+func (r *RRSIGRecord) SetHeader(h RRHeader) { r.H = h }
+
+// MarshalRData marshals the signature to wire format.
+// The signer name is never compressed (RFC 4034 §3.1.7).
+func (r *RRSIGRecord) MarshalRData() ([]byte, error) {
+	signer, err := EncodeName(r.SignerName)
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, rrsigFixedLen, rrsigFixedLen+len(signer)+len(r.Signature))
+	binary.BigEndian.PutUint16(b[0:2], uint16(r.TypeCovered))
+	b[2] = r.Algorithm
+	b[3] = r.Labels
+	binary.BigEndian.PutUint32(b[4:8], r.OriginalTTL)
+	binary.BigEndian.PutUint32(b[8:12], r.Expiration)
+	binary.BigEndian.PutUint32(b[12:16], r.Inception)
+	binary.BigEndian.PutUint16(b[16:18], r.KeyTag)
+	b = append(b, signer...)
+	return append(b, r.Signature...), nil
+}
+
+// String returns the RDATA in presentation format, with timestamps as
+// YYYYMMDDHHmmSS in UTC (RFC 4034 §3.2):
+// "A 8 2 300 20260101000000 20251201000000 12345 example.com. <base64 sig>".
+func (r *RRSIGRecord) String() string {
+	return fmt.Sprintf("%s %d %d %d %s %s %d %s %s",
+		r.TypeCovered, r.Algorithm, r.Labels, r.OriginalTTL,
+		formatSigTime(r.Expiration), formatSigTime(r.Inception),
+		r.KeyTag, fqdn(r.SignerName), base64.StdEncoding.EncodeToString(r.Signature))
+}
+
+// ParseRRSIGRData parses RRSIG record RDATA from wire format.
+func ParseRRSIGRData(msg []byte, off *int, start, rdlen int) (*RRSIGRecord, error) {
+	if rdlen < rrsigFixedLen+1 {
+		return nil, fmt.Errorf("%w: RRSIG record too short (RFC 4034 §3.1), got %d bytes", ErrDNSError, rdlen)
+	}
+	b := msg[start : start+rdlen]
+	r := &RRSIGRecord{
+		TypeCovered: RecordType(binary.BigEndian.Uint16(b[0:2])),
+		Algorithm:   b[2],
+		Labels:      b[3],
+		OriginalTTL: binary.BigEndian.Uint32(b[4:8]),
+		Expiration:  binary.BigEndian.Uint32(b[8:12]),
+		Inception:   binary.BigEndian.Uint32(b[12:16]),
+		KeyTag:      binary.BigEndian.Uint16(b[16:18]),
+	}
+	*off = start + rrsigFixedLen
+	signer, err := DecodeName(msg, off)
+	if err != nil {
+		return nil, err
+	}
+	if *off > start+rdlen {
+		return nil, fmt.Errorf("%w: RRSIG signer name overruns RDATA (RFC 4034 §3.1.7)", ErrDNSError)
+	}
+	r.SignerName = signer
+	r.Signature = cloneBytes(msg[*off : start+rdlen])
+	*off = start + rdlen
+	return r, nil
+}
+
+// NSECRecord names the next owner name in a signed zone and the types
+// present at its own owner name (RFC 4034 §4). Together with its RRSIG it
+// proves that no name sorts between the two, and that the owner name has
+// no other types.
+type NSECRecord struct {
+	H          RRHeader
+	NextDomain string       // Next owner name in canonical order; the apex for the last NSEC
+	Types      []RecordType // Types present at the owner name, ascending
+}
+
+// Type returns TypeNSEC.
+func (r *NSECRecord) Type() RecordType { return TypeNSEC }
+
+// Header returns the record header.
+func (r *NSECRecord) Header() RRHeader { return r.H }
+
+// SetHeader sets the record header.
+func (r *NSECRecord) SetHeader(h RRHeader) { r.H = h }
+
+// HasType reports whether the type bitmap lists t.
+func (r *NSECRecord) HasType(t RecordType) bool {
+	return slices.Contains(r.Types, t)
+}
+
+// MarshalRData marshals the record to wire format. The next domain name is
+// never compressed (RFC 4034 §4.1.1).
+func (r *NSECRecord) MarshalRData() ([]byte, error) {
+	next, err := EncodeName(fqdn(r.NextDomain))
+	if err != nil {
+		return nil, err
+	}
+	return append(next, marshalTypeBitmap(r.Types)...), nil
+}
+
+// String returns the RDATA in presentation format:
+// "host.example.com. A RRSIG NSEC".
+func (r *NSECRecord) String() string {
+	var sb strings.Builder
+	sb.WriteString(fqdn(r.NextDomain))
+	for _, t := range r.Types {
+		sb.WriteByte(' ')
+		sb.WriteString(t.String())
+	}
+	return sb.String()
+}
+
+// ParseNSECRData parses NSEC record RDATA from wire format.
+func ParseNSECRData(msg []byte, off *int, start, rdlen int) (*NSECRecord, error) {
+	*off = start
+	next, err := DecodeName(msg, off)
+	if err != nil {
+		return nil, err
+	}
+	if *off > start+rdlen {
+		return nil, fmt.Errorf("%w: NSEC next domain name overruns RDATA (RFC 4034 §4.1.1)", ErrDNSError)
+	}
+	types, err := parseTypeBitmap(msg[*off : start+rdlen])
+	if err != nil {
+		return nil, err
+	}
+	*off = start + rdlen
+	return &NSECRecord{NextDomain: next, Types: types}, nil
+}
+
+// marshalTypeBitmap encodes types as NSEC type bitmap windows
+// (RFC 4034 §4.1.2).
+func marshalTypeBitmap(types []RecordType) []byte {
+	sorted := slices.Clone(types)
+	slices.Sort(sorted)
+	sorted = slices.Compact(sorted)
+
+	var out []byte
+	for i := 0; i < len(sorted); {
+		window := byte(sorted[i] >> 8)
+		var bitmap [32]byte
+		n := 0
+		for ; i < len(sorted) && byte(sorted[i]>>8) == window; i++ {
+			low := byte(sorted[i])
+			bitmap[low/8] |= 0x80 >> (low % 8)
+			n = int(low/8) + 1
+		}
+		out = append(out, window, byte(n))
+		out = append(out, bitmap[:n]...)
+	}
+	return out
+}
+
+// parseTypeBitmap decodes NSEC type bitmap windows (RFC 4034 §4.1.2).
+func parseTypeBitmap(b []byte) ([]RecordType, error) {
+	var types []RecordType
+	for len(b) > 0 {
+		if len(b) < 2 || b[1] == 0 || b[1] > 32 || len(b) < 2+int(b[1]) {
+			return nil, fmt.Errorf("%w: malformed NSEC type bitmap (RFC 4034 §4.1.2)", ErrDNSError)
+		}
+		window, bitmap := uint16(b[0]), b[2:2+int(b[1])]
+		for i, octet := range bitmap {
+			for bit := range 8 {
+				if octet&(0x80>>bit) != 0 {
+					types = append(types, RecordType(window<<8|uint16(i*8+bit)))
+				}
+			}
+		}
+		b = b[2+int(b[1]):]
+	}
+	return types, nil
+}
+
+// formatSigTime formats an RRSIG timestamp as YYYYMMDDHHmmSS (UTC).
+func formatSigTime(t uint32) string {
+	return time.Unix(int64(t), 0).UTC().Format("20060102150405")
+}
+
+// upperHex encodes b as uppercase hexadecimal, the customary presentation
+// for digests and fingerprints.
+func upperHex(b []byte) string {
+	return strings.ToUpper(hex.EncodeToString(b))
+}
+
+// cloneBytes returns a copy of b that does not alias the message buffer.
+func cloneBytes(b []byte) []byte {
+	out := make([]byte, len(b))
+	copy(out, b)
+	return out
+}
+
+// fqdn returns name with a trailing dot; the root is ".".
+func fqdn(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
@@ -0,0 +1,141 @@
+package dns_test
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/jroosing/hydradns/internal/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Key and digest from RFC 4034 §5.4 (dskey.example.com, key tag 60485).
+const (
+	rfc4034Key = "AQOeiiR0GOMYkDshWoSKz9XzfwJr1AYtsmx3TGkJaNXVbfi/2pHm822aJ5iI9BMzNXxeYCmZ" +
+		"DRD99WYwYqUSdjMmmAphXdvxegXd/M5+X7OrzKBaMbCVdFLUUh6DhweJBjEVv5f2wwjM9Xzc" +
+		"nOf+EPbtG9DMBmADjFDc2w/rljwvFw=="
+	rfc4034Digest = "2BB183AF5F22588179A53B0A98631FAD1A292118"
+)
+
+// roundTrip marshals r into a response packet and parses it back.
+func roundTrip(t *testing.T, r dns.Record) dns.Record {
+	t.Helper()
+	p := dns.Packet{
+		Header:    dns.Header{ID: 1, Flags: dns.QRFlag},
+		Questions: []dns.Question{{Name: r.Header().Name, Type: uint16(r.Type()), Class: uint16(dns.ClassIN)}},
+		Answers:   []dns.Record{r},
+	}
+	b, err := p.Marshal()
+	require.NoError(t, err)
+	parsed, err := dns.ParsePacket(b)
+	require.NoError(t, err)
+	require.Len(t, parsed.Answers, 1)
+	return parsed.Answers[0]
+}
+
+func TestDNSKEYRecord_RoundTripAndKeyTag(t *testing.T) {
+	key, err := base64.StdEncoding.DecodeString(rfc4034Key)
+	require.NoError(t, err)
+	h := dns.NewRRHeader("dskey.example.com", dns.ClassIN, 86400)
+	rec := dns.NewDNSKEYRecord(h, 256, 3, 5, key)
+
+	assert.Equal(t, uint16(60485), rec.KeyTag())
+
+	got, ok := roundTrip(t, rec).(*dns.DNSKEYRecord)
+	require.True(t, ok, "DNSKEY should parse as a typed record")
+	assert.Equal(t, rec.Flags, got.Flags)
+	assert.Equal(t, rec.Algorithm, got.Algorithm)
+	assert.Equal(t, key, got.PublicKey)
+	assert.Equal(t, "256 3 5 "+rfc4034Key, got.String())
+}
+
+func TestDSRecord_RoundTripAndString(t *testing.T) {
+	digest, err := hex.DecodeString(rfc4034Digest)
+	require.NoError(t, err)
+	rec := dns.NewDSRecord(dns.NewRRHeader("dskey.example.com", dns.ClassIN, 86400), 60485, 5, 1, digest)
+
+	got, ok := roundTrip(t, rec).(*dns.DSRecord)
+	require.True(t, ok, "DS should parse as a typed record")
+	assert.Equal(t, "60485 5 1 "+rfc4034Digest, got.String())
+	assert.Equal(t, "dskey.example.com.\t86400\tIN\tDS\t60485 5 1 "+rfc4034Digest, dns.FormatRecord(got))
+}
+
+func TestRRSIGRecord_RoundTripAndString(t *testing.T) {
+	rec := &dns.RRSIGRecord{
+		H:           dns.NewRRHeader("host.example.com", dns.ClassIN, 3600),
+		TypeCovered: dns.TypeA,
+		Algorithm:   13,
+		Labels:      3,
+		OriginalTTL: 3600,
+		Expiration:  1767225600, // 2026-01-01 00:00:00 UTC
+		Inception:   1764547200, // 2025-12-01 00:00:00 UTC
+		KeyTag:      12345,
+		SignerName:  "example.com",
+		Signature:   []byte{0xDE, 0xAD, 0xBE, 0xEF},
+	}
+
+	got, ok := roundTrip(t, rec).(*dns.RRSIGRecord)
+	require.True(t, ok, "RRSIG should parse as a typed record")
+	assert.Equal(t, rec.SignerName, got.SignerName)
+	assert.Equal(t, rec.Signature, got.Signature)
+	assert.Equal(t, "A 13 3 3600 20260101000000 20251201000000 12345 example.com. 3q2+7w==", got.String())
+}
+
+func TestNSECRecord_RoundTripAndString(t *testing.T) {
+	// RFC 4034 §4.3
+	rec := &dns.NSECRecord{
+		H:          dns.NewRRHeader("alfa.example.com", dns.ClassIN, 86400),
+		NextDomain: "host.example.com",
+		Types:      []dns.RecordType{dns.TypeA, dns.TypeMX, dns.TypeRRSIG, dns.TypeNSEC, dns.RecordType(1234)},
+	}
+	rdata, err := rec.MarshalRData()
+	require.NoError(t, err)
+	bitmap := "0006400100000003" + "041b" + strings.Repeat("00", 26) + "20"
+	assert.Equal(t, bitmap, hex.EncodeToString(rdata[len(rdata)-len(bitmap)/2:]))
+
+	got, ok := roundTrip(t, rec).(*dns.NSECRecord)
+	require.True(t, ok, "NSEC should parse as a typed record")
+	assert.Equal(t, rec.Types, got.Types)
+	assert.True(t, got.HasType(dns.TypeMX))
+	assert.False(t, got.HasType(dns.TypeAAAA))
+	assert.Equal(t, "host.example.com. A MX RRSIG NSEC TYPE1234", got.String())
+}
+
+func TestParseNSECRData_BadBitmap(t *testing.T) {
+	msg := []byte{0x00, 0x00, 0x21} // Root next name, window 0 with 33 octets
+	off := 0
+	_, err := dns.ParseNSECRData(msg, &off, 0, len(msg))
+	require.ErrorIs(t, err, dns.ErrDNSError)
+}
+
+func TestCompareNames_CanonicalOrder(t *testing.T) {
+	// RFC 4034 §6.1
+	ordered := []string{
+		"example", "a.example", "yljkjljk.a.example", "Z.a.example",
+		"zABC.a.EXAMPLE", "z.example", "*.z.example", "a.z.example",
+	}
+	for i := range len(ordered) - 1 {
+		assert.Equal(t, -1, dns.CompareNames(ordered[i], ordered[i+1]), "%s < %s", ordered[i], ordered[i+1])
+		assert.Equal(t, 1, dns.CompareNames(ordered[i+1], ordered[i]))
+	}
+	assert.Equal(t, 0, dns.CompareNames("Example.COM.", "example.com"))
+	assert.Equal(t, -1, dns.CompareNames("", "com"), "the root sorts first")
+}
+
+func TestParseDNSSECRData_TooShort(t *testing.T) {
+	msg := []byte{0x01, 0x02, 0x03}
+
+	off := 0
+	_, err := dns.ParseDNSKEYRData(msg, &off, 3)
+	require.ErrorIs(t, err, dns.ErrDNSError)
+
+	off = 0
+	_, err = dns.ParseDSRData(msg, &off, 3)
+	require.ErrorIs(t, err, dns.ErrDNSError)
+
+	off = 0
+	_, err = dns.ParseRRSIGRData(msg, &off, 0, 3)
+	require.ErrorIs(t, err, dns.ErrDNSError)
+}
@@ -0,0 +1,86 @@
+package dns
+
+import "encoding/binary"
+
+// EDNSOptionEDE is the EDNS option code for Extended DNS Errors (RFC 8914).
+const EDNSOptionEDE uint16 = 15
+
+// Extended DNS Error info codes (RFC 8914 §4). Only the codes HydraDNS
+// emits are listed.
+const (
+	EDEOther                uint16 = 0  // Error not covered by another code; see ExtraText
+	EDEBlocked              uint16 = 15 // Blocked by a blocklist
+	EDEProhibited           uint16 = 18 // Refused by policy
+	EDENoReachableAuthority uint16 = 22 // No upstream could be reached
+)
+
+// ExtendedError is an Extended DNS Error (RFC 8914) carried in the OPT record.
+type ExtendedError struct {
+	InfoCode  uint16 // EDE info code
+	ExtraText string // Optional UTF-8 text for operators
+}
+
+// Option returns the error encoded as an EDNS option.
+func (e ExtendedError) Option() EDNSOption {
+	data := make([]byte, 2, 2+len(e.ExtraText))
+	binary.BigEndian.PutUint16(data, e.InfoCode)
+	return EDNSOption{Code: EDNSOptionEDE, Data: append(data, e.ExtraText...)}
+}
+
+// ExtractExtendedError returns the first Extended DNS Error in the OPT
+// record of additionals, if any.
+func ExtractExtendedError(additionals []Record) (ExtendedError, bool) {
+	for _, r := range additionals {
+		opaque, ok := r.(*OpaqueRecord)
+		if !ok || opaque.Type() != TypeOPT {
+			continue
+		}
+		raw, _ := opaque.Data.([]byte)
+		for i := 0; i+ednsOptionHeaderLen <= len(raw); {
+			code := binary.BigEndian.Uint16(raw[i : i+2])
+			ln := int(binary.BigEndian.Uint16(raw[i+2 : i+4]))
+			i += ednsOptionHeaderLen
+			if i+ln > len(raw) {
+				break
+			}
+			if code == EDNSOptionEDE && ln >= 2 {
+				return ExtendedError{
+					InfoCode:  binary.BigEndian.Uint16(raw[i : i+2]),
+					ExtraText: string(raw[i+2 : i+ln]),
+				}, true
+			}
+			i += ln
+		}
+	}
+	return ExtendedError{}, false
+}
+
+// AddExtendedError attaches an OPT record carrying ede to resp.
+//
+// Per RFC 8914 §3 the error is only sent to clients that used EDNS; when req
+// has no OPT record resp is returned unchanged. Any OPT record already in
+// resp is replaced.
+func AddExtendedError(resp, req Packet, ede ExtendedError) Packet {
+	clientOPT := ExtractOPT(req.Additionals)
+	if clientOPT == nil {
+		return resp
+	}
+
+	opt := CreateOPT(EDNSDefaultUDPPayloadSize)
+	opt.DNSSECOk = clientOPT.DNSSECOk
+	rdata := ede.Option().Marshal()
+
+	additionals := make([]Record, 0, len(resp.Additionals)+1)
+	for _, r := range resp.Additionals {
+		if r.Type() != TypeOPT {
+			additionals = append(additionals, r)
+		}
+	}
+	h := RRHeader{
+		Name:  "",
+		Class: opt.UDPPayloadSize,
+		TTL:   packOPTTTL(opt.ExtendedRCode, opt.Version, opt.DNSSECOk),
+	}
+	resp.Additionals = append(additionals, NewOpaqueRecord(h, TypeOPT, rdata))
+	return resp
+}
@@ -0,0 +1,53 @@
+package dns_test
+
+import (
+	"testing"
+
+	"github.com/jroosing/hydradns/internal/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ednsQuery(t *testing.T, withOPT bool) dns.Packet {
+	t.Helper()
+	req := dns.Packet{
+		Header:    dns.Header{ID: 7, Flags: dns.RDFlag},
+		Questions: []dns.Question{{Name: "loop.example", Type: uint16(dns.TypeA), Class: uint16(dns.ClassIN)}},
+	}
+	b, err := req.Marshal()
+	require.NoError(t, err)
+	if withOPT {
+		b = dns.AddEDNSToRequestBytes(req, b, dns.EDNSDefaultUDPPayloadSize)
+	}
+	parsed, err := dns.ParsePacket(b)
+	require.NoError(t, err)
+	return parsed
+}
+
+func TestAddExtendedError_RoundTrip(t *testing.T) {
+	req := ednsQuery(t, true)
+	ede := dns.ExtendedError{InfoCode: dns.EDEOther, ExtraText: "CNAME loop"}
+
+	resp := dns.AddExtendedError(dns.BuildErrorResponse(req, uint16(dns.RCodeServFail)), req, ede)
+	b, err := resp.Marshal()
+	require.NoError(t, err)
+
+	parsed, err := dns.ParsePacket(b)
+	require.NoError(t, err)
+	require.Len(t, parsed.Additionals, 1)
+	got, ok := dns.ExtractExtendedError(parsed.Additionals)
+	require.True(t, ok)
+	assert.Equal(t, ede, got)
+	assert.Equal(t, dns.RCodeServFail, dns.RCodeFromFlags(parsed.Header.Flags))
+}
+
+func TestAddExtendedError_RequiresClientEDNS(t *testing.T) {
+	req := ednsQuery(t, false)
+	resp := dns.BuildErrorResponse(req, uint16(dns.RCodeServFail))
+
+	out := dns.AddExtendedError(resp, req, dns.ExtendedError{InfoCode: dns.EDEOther})
+
+	assert.Empty(t, out.Additionals, "no OPT record is sent to non-EDNS clients")
+	_, ok := dns.ExtractExtendedError(out.Additionals)
+	assert.False(t, ok)
+}
@@ -2,6 +2,7 @@ package dns
 
 import (
 	"encoding/binary"
+	"net/netip"
 
 	"github.com/jroosing/hydradns/internal/helpers"
 )
@@ -20,6 +21,15 @@ type EDNSOption struct {
 	Data []byte // Option data
 }
 
+// EDNS option codes (IANA "DNS EDNS0 Option Codes" registry). EDNSOptionEDE
+// is defined with the Extended DNS Error helpers.
+const (
+	EDNSOptionNSID    uint16 = 3  // Name Server Identifier (RFC 5001)
+	EDNSOptionECS     uint16 = 8  // Client Subnet (RFC 7871)
+	EDNSOptionCookie  uint16 = 10 // DNS Cookie (RFC 7873)
+	EDNSOptionPadding uint16 = 12 // Padding (RFC 7830)
+)
+
 const (
 	ednsOptionHeaderLen   = 4
 	ednsMaxOptionDataSize = EDNSMaxUDPPayloadSize // defensive cap for option payloads
@@ -27,15 +37,15 @@ const (
 
 func isAllowedEDNSOption(code uint16) bool {
 	switch code {
-	case 10: // COOKIE
-		return true
-	case 12: // PADDING
+	case EDNSOptionCookie, EDNSOptionPadding:
 		return true
 	default:
 		return false
 	}
 }
 
+func anyEDNSOption(uint16) bool { return true }
+
 // Marshal serializes an EDNS option to wire format.
 func (o EDNSOption) Marshal() []byte {
 	b := make([]byte, 4+len(o.Data))
@@ -48,6 +58,10 @@ func (o EDNSOption) Marshal() []byte {
 // ParseEDNSOptions extracts allowed EDNS options from raw RDATA, skipping
 // unknown or oversized options. Truncated options end parsing early.
 func ParseEDNSOptions(rdata []byte) []EDNSOption {
+	return parseEDNSOptions(rdata, isAllowedEDNSOption)
+}
+
+func parseEDNSOptions(rdata []byte, allowed func(uint16) bool) []EDNSOption {
 	// Pre-allocate for typical case of 1-2 options (COOKIE, PADDING)
 	opts := make([]EDNSOption, 0, 2)
 	for i := 0; i < len(rdata); {
@@ -68,7 +82,7 @@ func ParseEDNSOptions(rdata []byte) []EDNSOption {
 		if i+ln > len(rdata) {
 			break
 		}
-		if !isAllowedEDNSOption(code) {
+		if !allowed(code) {
 			i += ln
 			continue
 		}
@@ -105,6 +119,126 @@ func MarshalEDNSOptions(opts []EDNSOption) []byte {
 	return out
 }
 
+// RewriteEDNSOptions passes every option of the OPT record in msg to fn and
+// returns a copy of msg carrying the options fn returns. Unlike
+// ParseEDNSOptions, fn sees all options, whatever their code. The rest of
+// the message is copied byte for byte, so name case is preserved.
+//
+// msg is returned unchanged, without calling fn, if it has no OPT record or
+// cannot be parsed.
+func RewriteEDNSOptions(msg []byte, fn func([]EDNSOption) []EDNSOption) []byte {
+	rdStart, rdEnd, ok := findOPTRData(msg)
+	if !ok {
+		return msg
+	}
+
+	opts := fn(parseEDNSOptions(msg[rdStart:rdEnd], anyEDNSOption))
+	rdata := MarshalEDNSOptions(opts)
+
+	out := make([]byte, 0, len(msg)-(rdEnd-rdStart)+len(rdata))
+	out = append(out, msg[:rdStart]...)
+	binary.BigEndian.PutUint16(out[rdStart-2:rdStart], helpers.ClampIntToUint16(len(rdata)))
+	out = append(out, rdata...)
+	return append(out, msg[rdEnd:]...)
+}
+
+// AppendEDNSOption returns a copy of msg with opt added to its OPT record.
+// When msg has no OPT record, one advertising EDNSDefaultUDPPayloadSize is
+// added to carry opt.
+func AppendEDNSOption(msg []byte, opt EDNSOption) []byte {
+	if _, _, ok := findOPTRData(msg); ok {
+		return RewriteEDNSOptions(msg, func(opts []EDNSOption) []EDNSOption {
+			return append(opts, opt)
+		})
+	}
+	if len(msg) < HeaderSize {
+		return msg
+	}
+	rec := CreateOPT(EDNSDefaultUDPPayloadSize)
+	rec.Options = []EDNSOption{opt}
+	optBytes := rec.Marshal()
+
+	ar := binary.BigEndian.Uint16(msg[10:12])
+	if ar == 65535 {
+		return msg
+	}
+	out := make([]byte, 0, len(msg)+len(optBytes))
+	out = append(out, msg...)
+	binary.BigEndian.PutUint16(out[10:12], ar+1)
+	return append(out, optBytes...)
+}
+
+// HasEDNSOption reports whether the OPT record of req carries an option
+// with code, such as the NSID request "dig +nsid" sends.
+func HasEDNSOption(req Packet, code uint16) bool {
+	for _, r := range req.Additionals {
+		opaque, ok := r.(*OpaqueRecord)
+		if !ok || opaque.Type() != TypeOPT {
+			continue
+		}
+		raw, _ := opaque.Data.([]byte)
+		for i := 0; i+ednsOptionHeaderLen <= len(raw); {
+			if binary.BigEndian.Uint16(raw[i:i+2]) == code {
+				return true
+			}
+			i += ednsOptionHeaderLen + int(binary.BigEndian.Uint16(raw[i+2:i+4]))
+		}
+	}
+	return false
+}
+
+// findOPTRData returns the bounds of the RDATA of the OPT record in msg.
+func findOPTRData(msg []byte) (start, end int, ok bool) {
+	off := 0
+	h, err := ParseHeader(msg, &off)
+	if err != nil {
+		return 0, 0, false
+	}
+	for range h.QDCount {
+		if _, err := ParseQuestion(msg, &off); err != nil {
+			return 0, 0, false
+		}
+	}
+
+	records := int(h.ANCount) + int(h.NSCount) + int(h.ARCount)
+	for i := range records {
+		if _, err := DecodeName(msg, &off); err != nil || off+10 > len(msg) {
+			return 0, 0, false
+		}
+		rrType := RecordType(binary.BigEndian.Uint16(msg[off : off+2]))
+		rdlen := int(binary.BigEndian.Uint16(msg[off+8 : off+10]))
+		off += 10
+		if off+rdlen > len(msg) {
+			return 0, 0, false
+		}
+		// OPT is only valid in the additional section
+		if rrType == TypeOPT && i >= int(h.ANCount)+int(h.NSCount) {
+			return off, off + rdlen, true
+		}
+		off += rdlen
+	}
+	return 0, 0, false
+}
+
+// ECSOption encodes prefix as an EDNS Client Subnet option (RFC 7871 §6)
+// with a scope prefix length of 0. Address bits beyond the prefix length
+// are cleared.
+func ECSOption(prefix netip.Prefix) EDNSOption {
+	prefix = prefix.Masked()
+	family := uint16(1)
+	if prefix.Addr().Is6() {
+		family = 2
+	}
+	bits := prefix.Bits()
+	addr := prefix.Addr().AsSlice()
+
+	data := make([]byte, 4, 4+(bits+7)/8)
+	binary.BigEndian.PutUint16(data[0:2], family)
+	data[2] = uint8(bits) //nolint:gosec // SOURCE PREFIX-LENGTH, at most 128
+	data[3] = 0           // SCOPE PREFIX-LENGTH
+	return EDNSOption{Code: EDNSOptionECS, Data: append(data, addr[:(bits+7)/8]...)}
+}
+
 // OPTRecord represents an EDNS OPT pseudo-record (RFC 6891).
 //
 // The OPT record uses a non-standard encoding:
@@ -165,6 +299,17 @@ func (o OPTRecord) Marshal() []byte {
 	return b
 }
 
+// Record returns the OPT record as a Record for a packet's additional
+// section.
+func (o OPTRecord) Record() Record {
+	h := RRHeader{Class: o.UDPPayloadSize, TTL: packOPTTTL(o.ExtendedRCode, o.Version, o.DNSSECOk)}
+	var rdata []byte
+	for _, opt := range o.Options {
+		rdata = append(rdata, opt.Marshal()...)
+	}
+	return NewOpaqueRecord(h, TypeOPT, rdata)
+}
+
 // packOPTTTL constructs the 32-bit TTL field for an OPT record.
 //
 // Layout:
@@ -2,6 +2,9 @@ package dns
 
 import (
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 )
 
 // DNS header flags and masks (RFC 1035 Section 4.1.1)
@@ -45,18 +48,25 @@ const (
 	TypeNS         RecordType = 2   // Authoritative name server
 	TypeCNAME      RecordType = 5   // Canonical name (alias)
 	TypeSOA        RecordType = 6   // Start of Authority
+	TypeNULL       RecordType = 10  // Arbitrary data (experimental, RFC 1035)
 	TypePTR        RecordType = 12  // Domain name pointer (reverse DNS)
 	TypeMX         RecordType = 15  // Mail exchange
 	TypeTXT        RecordType = 16  // Text strings
 	TypeAAAA       RecordType = 28  // IPv6 address (RFC 3596)
+	TypeLOC        RecordType = 29  // Geographic location (RFC 1876)
 	TypeSRV        RecordType = 33  // Service locator (RFC 2782)
 	TypeOPT        RecordType = 41  // EDNS pseudo-record (RFC 6891)
 	TypeDS         RecordType = 43  // Delegation Signer (DNSSEC, RFC 4034)
+	TypeSSHFP      RecordType = 44  // SSH key fingerprint (RFC 4255)
 	TypeRRSIG      RecordType = 46  // DNSSEC signature (RFC 4034)
 	TypeNSEC       RecordType = 47  // Next Secure record (DNSSEC, RFC 4034)
 	TypeDNSKEY     RecordType = 48  // DNS Public Key (DNSSEC, RFC 4034)
 	TypeNSEC3      RecordType = 50  // NSEC version 3 (DNSSEC, RFC 5155)
 	TypeNSEC3PARAM RecordType = 51  // NSEC3 Parameters (DNSSEC, RFC 5155)
+	TypeTLSA       RecordType = 52  // DANE TLS certificate association (RFC 6698)
+	TypeSVCB       RecordType = 64  // Service binding (RFC 9460)
+	TypeHTTPS      RecordType = 65  // HTTPS service binding (RFC 9460)
+	TypeANY        RecordType = 255 // QTYPE only: all records (RFC 1035, RFC 8482)
 	TypeCAA        RecordType = 257 // Certification Authority Authorization (RFC 8659)
 )
 
@@ -65,6 +75,7 @@ type RecordClass uint16
 
 const (
 	ClassIN RecordClass = 1 // Internet class
+	ClassCH RecordClass = 3 // CHAOS class, used for server identity queries
 )
 
 // RCode represents DNS response codes (RFC 1035).
@@ -79,6 +90,46 @@ const (
 	RCodeRefused  RCode = 5 // Query refused by policy
 )
 
+// String returns the mnemonic of the response code, e.g. "NXDOMAIN".
+func (rc RCode) String() string {
+	switch rc {
+	case RCodeNoError:
+		return "NOERROR"
+	case RCodeFormErr:
+		return "FORMERR"
+	case RCodeServFail:
+		return "SERVFAIL"
+	case RCodeNXDomain:
+		return "NXDOMAIN"
+	case RCodeNotImp:
+		return "NOTIMP"
+	case RCodeRefused:
+		return "REFUSED"
+	default:
+		return fmt.Sprintf("RCODE%d", uint16(rc))
+	}
+}
+
+// namedRCodes are the response codes with a mnemonic, for ParseRCode.
+var namedRCodes = []RCode{RCodeNoError, RCodeFormErr, RCodeServFail, RCodeNXDomain, RCodeNotImp, RCodeRefused}
+
+// ParseRCode parses a response code mnemonic such as "SERVFAIL", case
+// insensitively, or a header response code (0-15) written as "RCODE9" or
+// "9".
+func ParseRCode(s string) (RCode, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	for _, rc := range namedRCodes {
+		if rc.String() == s {
+			return rc, nil
+		}
+	}
+	n, err := strconv.ParseUint(strings.TrimPrefix(s, "RCODE"), 10, 16)
+	if err != nil || n > uint64(RCodeMask) {
+		return 0, fmt.Errorf("unknown response code %q", s)
+	}
+	return RCode(n), nil
+}
+
 // RCodeFromFlags extracts the response code from the DNS header flags.
 // The RCODE occupies the low 4 bits of the flags field.
 func RCodeFromFlags(flags uint16) RCode {
@@ -96,6 +147,8 @@ func (rt RecordType) String() string {
 		return "CNAME"
 	case TypeSOA:
 		return "SOA"
+	case TypeNULL:
+		return "NULL"
 	case TypePTR:
 		return "PTR"
 	case TypeMX:
@@ -104,12 +157,16 @@ func (rt RecordType) String() string {
 		return "TXT"
 	case TypeAAAA:
 		return "AAAA"
+	case TypeLOC:
+		return "LOC"
 	case TypeSRV:
 		return "SRV"
 	case TypeOPT:
 		return "OPT"
 	case TypeDS:
 		return "DS"
+	case TypeSSHFP:
+		return "SSHFP"
 	case TypeRRSIG:
 		return "RRSIG"
 	case TypeNSEC:
@@ -120,6 +177,14 @@ func (rt RecordType) String() string {
 		return "NSEC3"
 	case TypeNSEC3PARAM:
 		return "NSEC3PARAM"
+	case TypeTLSA:
+		return "TLSA"
+	case TypeSVCB:
+		return "SVCB"
+	case TypeHTTPS:
+		return "HTTPS"
+	case TypeANY:
+		return "ANY"
 	case TypeCAA:
 		return "CAA"
 	default:
@@ -127,11 +192,43 @@ func (rt RecordType) String() string {
 	}
 }
 
+// namedRecordTypes are the types with a mnemonic, for ParseRecordType.
+var namedRecordTypes = []RecordType{
+	TypeA, TypeNS, TypeCNAME, TypeSOA, TypeNULL, TypePTR, TypeMX, TypeTXT,
+	TypeAAAA, TypeLOC, TypeSRV, TypeOPT, TypeDS, TypeSSHFP, TypeRRSIG,
+	TypeNSEC, TypeDNSKEY, TypeNSEC3, TypeNSEC3PARAM, TypeTLSA, TypeSVCB, TypeHTTPS,
+	TypeANY, TypeCAA,
+}
+
+// NamedRecordTypes returns the record types with a mnemonic.
+func NamedRecordTypes() []RecordType {
+	return slices.Clone(namedRecordTypes)
+}
+
+// ParseRecordType parses a record type mnemonic such as "AAAA", case
+// insensitively, or a numeric type written as "TYPE65535" (RFC 3597) or
+// "65535".
+func ParseRecordType(s string) (RecordType, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	for _, t := range namedRecordTypes {
+		if t.String() == s {
+			return t, nil
+		}
+	}
+	n, err := strconv.ParseUint(strings.TrimPrefix(s, "TYPE"), 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("unknown record type %q", s)
+	}
+	return RecordType(n), nil
+}
+
 // String returns the human-readable name of the record class.
 func (rc RecordClass) String() string {
 	switch rc {
 	case ClassIN:
 		return "IN"
+	case ClassCH:
+		return "CH"
 	default:
 		return fmt.Sprintf("CLASS%d", rc)
 	}
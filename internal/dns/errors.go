@@ -23,10 +23,24 @@
 // This preserves error chains while adding operational context.
 package dns
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
 	// ErrDNSError is a sentinel error type for DNS protocol violations.
 	// Wrap this with fmt.Errorf("context: %w", ErrDNSError) to add context.
 	ErrDNSError = errors.New("dns wire error")
+
+	// ErrMessageTooLarge reports a message longer than MaxIncomingDNSMessageSize.
+	ErrMessageTooLarge = fmt.Errorf("%w: dns message too large", ErrDNSError)
+
+	// ErrCompressionLoop reports compression pointers that loop or nest
+	// too deeply, a common way to make naive parsers spin.
+	ErrCompressionLoop = fmt.Errorf("%w: DNS compression pointer loop", ErrDNSError)
+
+	// ErrNameTooLong reports a decoded name longer than 255 octets in wire
+	// format (RFC 1035 Section 3.1).
+	ErrNameTooLong = fmt.Errorf("%w: DNS name too long", ErrDNSError)
 )
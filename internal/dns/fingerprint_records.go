@@ -0,0 +1,95 @@
+package dns
+
+import "fmt"
+
+// SSHFPRecord represents an SSH host key fingerprint (RFC 4255).
+type SSHFPRecord struct {
+	H           RRHeader
+	Algorithm   uint8 // 1 = RSA, 2 = DSA, 3 = ECDSA, 4 = Ed25519
+	FPType      uint8 // 1 = SHA-1, 2 = SHA-256
+	Fingerprint []byte
+}
+
+// NewSSHFPRecord creates a new SSHFP record.
+func NewSSHFPRecord(h RRHeader, algorithm, fpType uint8, fingerprint []byte) *SSHFPRecord {
+	return &SSHFPRecord{H: h, Algorithm: algorithm, FPType: fpType, Fingerprint: fingerprint}
+}
+
+// Type returns TypeSSHFP.
+func (r *SSHFPRecord) Type() RecordType { return TypeSSHFP }
+
+// Header returns the record header.
+func (r *SSHFPRecord) Header() RRHeader { return r.H }
+
+// SetHeader sets the record header.
+func (r *SSHFPRecord) SetHeader(h RRHeader) { r.H = h }
+
+// MarshalRData marshals the fingerprint to wire format.
+func (r *SSHFPRecord) MarshalRData() ([]byte, error) {
+	b := make([]byte, 2, 2+len(r.Fingerprint))
+	b[0] = r.Algorithm
+	b[1] = r.FPType
+	return append(b, r.Fingerprint...), nil
+}
+
+// String returns the RDATA in presentation format: "4 2 <HEX fingerprint>".
+func (r *SSHFPRecord) String() string {
+	return fmt.Sprintf("%d %d %s", r.Algorithm, r.FPType, upperHex(r.Fingerprint))
+}
+
+// ParseSSHFPRData parses SSHFP record RDATA from wire format.
+func ParseSSHFPRData(msg []byte, off *int, rdlen int) (*SSHFPRecord, error) {
+	if rdlen < 2 {
+		return nil, fmt.Errorf("%w: SSHFP record too short (RFC 4255 §3.1), got %d bytes", ErrDNSError, rdlen)
+	}
+	b := msg[*off : *off+rdlen]
+	*off += rdlen
+	return &SSHFPRecord{Algorithm: b[0], FPType: b[1], Fingerprint: cloneBytes(b[2:])}, nil
+}
+
+// TLSARecord represents a DANE TLS certificate association (RFC 6698).
+type TLSARecord struct {
+	H            RRHeader
+	Usage        uint8 // 0 = PKIX-TA, 1 = PKIX-EE, 2 = DANE-TA, 3 = DANE-EE
+	Selector     uint8 // 0 = full certificate, 1 = SubjectPublicKeyInfo
+	MatchingType uint8 // 0 = exact, 1 = SHA-256, 2 = SHA-512
+	CertData     []byte
+}
+
+// NewTLSARecord creates a new TLSA record.
+func NewTLSARecord(h RRHeader, usage, selector, matchingType uint8, certData []byte) *TLSARecord {
+	return &TLSARecord{H: h, Usage: usage, Selector: selector, MatchingType: matchingType, CertData: certData}
+}
+
+// Type returns TypeTLSA.
+func (r *TLSARecord) Type() RecordType { return TypeTLSA }
+
+// Header returns the record header.
+func (r *TLSARecord) Header() RRHeader { return r.H }
+
+// SetHeader sets the record header.
+func (r *TLSARecord) SetHeader(h RRHeader) { r.H = h }
+
+// MarshalRData marshals the certificate association to wire format.
+func (r *TLSARecord) MarshalRData() ([]byte, error) {
+	b := make([]byte, 3, 3+len(r.CertData))
+	b[0] = r.Usage
+	b[1] = r.Selector
+	b[2] = r.MatchingType
+	return append(b, r.CertData...), nil
+}
+
+// String returns the RDATA in presentation format: "3 1 1 <HEX data>".
+func (r *TLSARecord) String() string {
+	return fmt.Sprintf("%d %d %d %s", r.Usage, r.Selector, r.MatchingType, upperHex(r.CertData))
+}
+
+// ParseTLSARData parses TLSA record RDATA from wire format.
+func ParseTLSARData(msg []byte, off *int, rdlen int) (*TLSARecord, error) {
+	if rdlen < 3 {
+		return nil, fmt.Errorf("%w: TLSA record too short (RFC 6698 §2.1), got %d bytes", ErrDNSError, rdlen)
+	}
+	b := msg[*off : *off+rdlen]
+	*off += rdlen
+	return &TLSARecord{Usage: b[0], Selector: b[1], MatchingType: b[2], CertData: cloneBytes(b[3:])}, nil
+}
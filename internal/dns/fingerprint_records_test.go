@@ -0,0 +1,40 @@
+package dns_test
+
+import (
+	"testing"
+
+	"github.com/jroosing/hydradns/internal/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSSHFPRecord_RoundTripAndString(t *testing.T) {
+	fp := []byte{0x12, 0x34, 0xAB, 0xCD}
+	rec := dns.NewSSHFPRecord(dns.NewRRHeader("host.example.com", dns.ClassIN, 300), 4, 2, fp)
+
+	got, ok := roundTrip(t, rec).(*dns.SSHFPRecord)
+	require.True(t, ok, "SSHFP should parse as a typed record")
+	assert.Equal(t, fp, got.Fingerprint)
+	assert.Equal(t, "4 2 1234ABCD", got.String())
+}
+
+func TestTLSARecord_RoundTripAndString(t *testing.T) {
+	data := []byte{0x0A, 0x0B, 0x0C}
+	rec := dns.NewTLSARecord(dns.NewRRHeader("_443._tcp.example.com", dns.ClassIN, 300), 3, 1, 1, data)
+
+	got, ok := roundTrip(t, rec).(*dns.TLSARecord)
+	require.True(t, ok, "TLSA should parse as a typed record")
+	assert.Equal(t, data, got.CertData)
+	assert.Equal(t, "3 1 1 0A0B0C", got.String())
+	assert.Equal(t, "_443._tcp.example.com.\t300\tIN\tTLSA\t3 1 1 0A0B0C", dns.FormatRecord(got))
+}
+
+func TestParseFingerprintRData_TooShort(t *testing.T) {
+	off := 0
+	_, err := dns.ParseSSHFPRData([]byte{0x01}, &off, 1)
+	require.ErrorIs(t, err, dns.ErrDNSError)
+
+	off = 0
+	_, err = dns.ParseTLSARData([]byte{0x01, 0x02}, &off, 2)
+	require.ErrorIs(t, err, dns.ErrDNSError)
+}
@@ -1,6 +1,7 @@
 package dns
 
 import (
+	"crypto/rand"
 	"encoding/binary"
 	"fmt"
 )
@@ -28,6 +29,15 @@ type Header struct {
 // HeaderSize is the fixed size of a DNS header in bytes.
 const HeaderSize = 12
 
+// NewTransactionID returns a transaction ID from crypto/rand. Queries sent
+// to other servers must use unpredictable IDs: with a guessable ID, an
+// off-path attacker only needs the port to spoof the answer (RFC 5452).
+func NewTransactionID() uint16 {
+	var b [2]byte
+	_, _ = rand.Read(b[:]) // crypto/rand.Read never fails
+	return binary.BigEndian.Uint16(b[:])
+}
+
 // Marshal serializes the header to wire format (big-endian, 12 bytes).
 func (h Header) Marshal() ([]byte, error) {
 	b := make([]byte, HeaderSize)
@@ -0,0 +1,56 @@
+package dns
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// ToASCII converts a domain name with Unicode labels to its A-label
+// (punycode) form, e.g. "пример.рф" → "xn--e1afmkfd.xn--p1ai" (RFC 5891).
+//
+// Unicode labels are mapped per UTS #46 (lowercased and normalized) before
+// encoding. Pure ASCII names are returned unchanged, so existing names with
+// underscores or mixed case are not affected. A trailing dot is preserved.
+func ToASCII(name string) (string, error) {
+	if isASCII(name) {
+		return name, nil
+	}
+	a, err := idna.Lookup.ToASCII(name)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid internationalized domain name %q: %w", ErrDNSError, name, err)
+	}
+	return a, nil
+}
+
+// ToUnicode converts A-labels in a domain name back to Unicode for display,
+// e.g. "xn--e1afmkfd.xn--p1ai" → "пример.рф". Other labels are returned
+// untouched, as are A-labels that do not decode to a Unicode label.
+func ToUnicode(name string) string {
+	if !strings.Contains(strings.ToLower(name), "xn--") {
+		return name
+	}
+	labels := strings.Split(name, ".")
+	for i, label := range labels {
+		if len(label) < 4 || !strings.EqualFold(label[:4], "xn--") {
+			continue
+		}
+		u, err := idna.Punycode.ToUnicode(strings.ToLower(label))
+		if err != nil || isASCII(u) {
+			continue
+		}
+		labels[i] = u
+	}
+	return strings.Join(labels, ".")
+}
+
+// isASCII reports whether s contains only ASCII characters.
+func isASCII(s string) bool {
+	for i := range len(s) {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
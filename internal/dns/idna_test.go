@@ -0,0 +1,65 @@
+package dns_test
+
+import (
+	"testing"
+
+	"github.com/jroosing/hydradns/internal/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToASCII(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"cyrillic", "пример.рф", "xn--e1afmkfd.xn--p1ai"},
+		{"mixed labels", "www.münchen.de", "www.xn--mnchen-3ya.de"},
+		{"uppercase unicode is mapped", "ÉCOLE.fr", "xn--cole-9oa.fr"},
+		{"trailing dot kept", "bücher.example.", "xn--bcher-kva.example."},
+		{"ascii unchanged", "Example.COM", "Example.COM"},
+		{"ascii underscore unchanged", "_443._tcp.example.com", "_443._tcp.example.com"},
+		{"a-label unchanged", "xn--e1afmkfd.xn--p1ai", "xn--e1afmkfd.xn--p1ai"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := dns.ToASCII(tt.in)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestToASCII_Invalid(t *testing.T) {
+	_, err := dns.ToASCII("bad_ü.example")
+	require.ErrorIs(t, err, dns.ErrDNSError)
+}
+
+func TestToUnicode(t *testing.T) {
+	assert.Equal(t, "пример.рф", dns.ToUnicode("xn--e1afmkfd.xn--p1ai"))
+	assert.Equal(t, "www.münchen.de", dns.ToUnicode("www.XN--mnchen-3ya.de"))
+	assert.Equal(t, "_443._tcp.рф", dns.ToUnicode("_443._tcp.xn--p1ai"))
+	assert.Equal(t, "Example.com", dns.ToUnicode("Example.com"))
+	assert.Equal(t, "xn--zz-.example", dns.ToUnicode("xn--zz-.example"), "undecodable labels are left as-is")
+}
+
+func TestCanonicalName(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Test.Local", "test.local"},
+		{" test.local. ", "test.local"},
+		{"Файл.LAN.", "xn--80asg7a.lan"},
+		{"XN--80ASG7A.lan", "xn--80asg7a.lan"},
+	}
+	for _, tt := range tests {
+		got, err := dns.CanonicalName(tt.in)
+		require.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, got, tt.in)
+	}
+
+	_, err := dns.CanonicalName("bad_ü.example")
+	require.ErrorIs(t, err, dns.ErrDNSError)
+}
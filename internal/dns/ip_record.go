@@ -42,6 +42,9 @@ func (r *IPRecord) MarshalRData() ([]byte, error) {
 	return nil, fmt.Errorf("%w: invalid IP address", ErrDNSError)
 }
 
+// String returns the address in presentation format.
+func (r *IPRecord) String() string { return r.Addr.String() }
+
 // ParseIPRData parses A or AAAA record RDATA from wire format.
 func ParseIPRData(msg []byte, off *int, rdlen int) (*IPRecord, error) {
 	if rdlen != 4 && rdlen != 16 {
@@ -0,0 +1,114 @@
+package dns
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// LOC wire format constants (RFC 1876 §2).
+const (
+	locRDataLen     = 16
+	locEquator      = 1 << 31  // Latitude/longitude origin, in thousandths of an arc second
+	locAltitudeBase = 10000000 // Altitude origin: 100,000m below the WGS 84 spheroid, in cm
+)
+
+// LOCRecord represents a geographic location (RFC 1876).
+//
+// Coordinates are kept in their wire encoding; String renders them in the
+// degrees/minutes/seconds presentation format.
+type LOCRecord struct {
+	H         RRHeader
+	Version   uint8  // Always 0
+	Size      uint8  // Diameter of the enclosing sphere, mantissa/exponent in cm
+	HorizPre  uint8  // Horizontal precision, mantissa/exponent in cm
+	VertPre   uint8  // Vertical precision, mantissa/exponent in cm
+	Latitude  uint32 // Thousandths of an arc second, 2^31 = equator
+	Longitude uint32 // Thousandths of an arc second, 2^31 = prime meridian
+	Altitude  uint32 // Centimeters above 100,000m below the WGS 84 spheroid
+}
+
+// Type returns TypeLOC.
+func (r *LOCRecord) Type() RecordType { return TypeLOC }
+
+// Header returns the record header.
+func (r *LOCRecord) Header() RRHeader { return r.H }
+
+// SetHeader sets the record header.
+func (r *LOCRecord) SetHeader(h RRHeader) { r.H = h }
+
+// MarshalRData marshals the location to wire format.
+func (r *LOCRecord) MarshalRData() ([]byte, error) {
+	b := make([]byte, locRDataLen)
+	b[0] = r.Version
+	b[1] = r.Size
+	b[2] = r.HorizPre
+	b[3] = r.VertPre
+	binary.BigEndian.PutUint32(b[4:8], r.Latitude)
+	binary.BigEndian.PutUint32(b[8:12], r.Longitude)
+	binary.BigEndian.PutUint32(b[12:16], r.Altitude)
+	return b, nil
+}
+
+// String returns the RDATA in presentation format (RFC 1876 §3):
+// "52 22 23.000 N 4 53 32.000 E -2.00m 1m 10000m 10m".
+func (r *LOCRecord) String() string {
+	alt := int64(r.Altitude) - locAltitudeBase
+	sign := ""
+	if alt < 0 {
+		sign = "-"
+		alt = -alt
+	}
+	return fmt.Sprintf("%s %s %s%d.%02dm %s %s %s",
+		formatLOCAngle(r.Latitude, 'N', 'S'),
+		formatLOCAngle(r.Longitude, 'E', 'W'),
+		sign, alt/100, alt%100,
+		formatLOCPrecision(r.Size), formatLOCPrecision(r.HorizPre), formatLOCPrecision(r.VertPre))
+}
+
+// ParseLOCRData parses LOC record RDATA from wire format. Bytes after the
+// 16 defined by RFC 1876 are skipped.
+func ParseLOCRData(msg []byte, off *int, rdlen int) (*LOCRecord, error) {
+	if rdlen < locRDataLen {
+		return nil, fmt.Errorf("%w: LOC record too short (RFC 1876 §2), got %d bytes", ErrDNSError, rdlen)
+	}
+	b := msg[*off : *off+rdlen]
+	*off += rdlen
+	return &LOCRecord{
+		Version:   b[0],
+		Size:      b[1],
+		HorizPre:  b[2],
+		VertPre:   b[3],
+		Latitude:  binary.BigEndian.Uint32(b[4:8]),
+		Longitude: binary.BigEndian.Uint32(b[8:12]),
+		Altitude:  binary.BigEndian.Uint32(b[12:16]),
+	}, nil
+}
+
+// formatLOCAngle renders a wire-encoded latitude or longitude as
+// "deg min sec.mmm H", using pos/neg as the hemisphere letter.
+func formatLOCAngle(v uint32, pos, neg byte) string {
+	ms := int64(v) - locEquator
+	hemi := pos
+	if ms < 0 {
+		hemi = neg
+		ms = -ms
+	}
+	deg := ms / 3600000
+	ms %= 3600000
+	minutes := ms / 60000
+	ms %= 60000
+	return fmt.Sprintf("%d %d %d.%03d %c", deg, minutes, ms/1000, ms%1000, hemi)
+}
+
+// formatLOCPrecision renders a size/precision byte (high nibble mantissa,
+// low nibble power of ten, in cm) in meters.
+func formatLOCPrecision(b byte) string {
+	cm := uint64(b >> 4)
+	for range b & 0x0F {
+		cm *= 10
+	}
+	if cm%100 == 0 {
+		return fmt.Sprintf("%dm", cm/100)
+	}
+	return fmt.Sprintf("%d.%02dm", cm/100, cm%100)
+}
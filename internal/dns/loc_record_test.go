@@ -0,0 +1,74 @@
+package dns_test
+
+import (
+	"testing"
+
+	"github.com/jroosing/hydradns/internal/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// locAngle encodes degrees/minutes/seconds as a LOC wire coordinate.
+func locAngle(deg, minutes, sec int, positive bool) uint32 {
+	ms := int64((deg*3600 + minutes*60 + sec) * 1000)
+	if !positive {
+		ms = -ms
+	}
+	return uint32(int64(1<<31) + ms)
+}
+
+func TestLOCRecord_RoundTripAndString(t *testing.T) {
+	// RFC 1876 §4 example: cambridge-net.kei.com LOC 42 21 54 N 71 06 18 W -24m 30m
+	rec := &dns.LOCRecord{
+		H:         dns.NewRRHeader("cambridge-net.kei.com", dns.ClassIN, 300),
+		Size:      0x33, // 3e3 cm = 30m
+		HorizPre:  0x16, // 1e6 cm = 10000m
+		VertPre:   0x13, // 1e3 cm = 10m
+		Latitude:  locAngle(42, 21, 54, true),
+		Longitude: locAngle(71, 6, 18, false),
+		Altitude:  10000000 - 2400,
+	}
+
+	got, ok := roundTrip(t, rec).(*dns.LOCRecord)
+	require.True(t, ok, "LOC should parse as a typed record")
+	assert.Equal(t, *rec, *got)
+	assert.Equal(t, "42 21 54.000 N 71 6 18.000 W -24.00m 30m 10000m 10m", got.String())
+}
+
+func TestLOCRecord_StringSubMeterPrecision(t *testing.T) {
+	rec := &dns.LOCRecord{
+		Size:      0x12, // 1e2 cm = 1m
+		HorizPre:  0x51, // 5e1 cm = 0.50m
+		VertPre:   0x10, // 1 cm
+		Latitude:  1 << 31,
+		Longitude: 1<<31 + 1500,
+		Altitude:  10000000 + 12345,
+	}
+	assert.Equal(t, "0 0 0.000 N 0 0 1.500 E 123.45m 1m 0.50m 0.01m", rec.String())
+}
+
+func TestParseLOCRData_WrongLength(t *testing.T) {
+	off := 0
+	_, err := dns.ParseLOCRData(make([]byte, 15), &off, 15)
+	require.ErrorIs(t, err, dns.ErrDNSError)
+}
+
+func TestParseLOCRData_SkipsTrailingBytes(t *testing.T) {
+	b := make([]byte, 18)
+	b[1] = 0x12
+	off := 0
+	rec, err := dns.ParseLOCRData(b, &off, len(b))
+	require.NoError(t, err)
+	assert.Equal(t, uint8(0x12), rec.Size)
+	assert.Equal(t, 18, off)
+}
+
+func TestParsePacket_MalformedLOCKeptOpaque(t *testing.T) {
+	h := dns.NewRRHeader("loc.example", dns.ClassIN, 300)
+	got := roundTrip(t, dns.NewOpaqueRecord(h, dns.TypeLOC, make([]byte, 15)))
+
+	opaque, ok := got.(*dns.OpaqueRecord)
+	require.True(t, ok, "a short LOC record should not fail the message")
+	assert.Equal(t, dns.TypeLOC, opaque.Type())
+	assert.Len(t, opaque.Data, 15)
+}
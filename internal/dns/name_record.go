@@ -43,6 +43,9 @@ func (r *NameRecord) MarshalRData() ([]byte, error) {
 	return EncodeName(r.Target)
 }
 
+// String returns the target as a fully qualified name.
+func (r *NameRecord) String() string { return fqdn(r.Target) }
+
 // ParseNameRData parses CNAME, NS, or PTR record RDATA from wire format.
 func ParseNameRData(msg []byte, off *int, start, rdlen int, rt RecordType) (*NameRecord, error) {
 	n, err := DecodeName(msg, off)
@@ -35,6 +35,16 @@ func (r *OpaqueRecord) MarshalRData() ([]byte, error) {
 	return b, nil
 }
 
+// String returns the RDATA in the generic presentation format used for
+// unknown types (RFC 3597 §5): "\# <length> <HEX data>".
+func (r *OpaqueRecord) String() string {
+	b, _ := r.Data.([]byte)
+	if len(b) == 0 {
+		return `\# 0`
+	}
+	return fmt.Sprintf(`\# %d %s`, len(b), upperHex(b))
+}
+
 // ParseOpaqueRData parses raw opaque RDATA (for TXT, OPT, and unknown types).
 func ParseOpaqueRData(msg []byte, off *int, rdlen int, rt RecordType) (*OpaqueRecord, error) {
 	b := make([]byte, rdlen)
@@ -0,0 +1,63 @@
+package dns
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// ParseStats counts requests rejected by the parser. A sudden rise usually
+// means someone is scanning or fuzzing the server rather than a client bug.
+// All methods are safe for concurrent use; the zero value is ready to use.
+type ParseStats struct {
+	malformed         atomic.Uint64
+	pointerLoops      atomic.Uint64
+	oversizedNames    atomic.Uint64
+	oversizedMessages atomic.Uint64
+}
+
+// ParseStatsSnapshot is a point-in-time snapshot of ParseStats.
+//
+// Malformed counts every rejected request; the other counters break out
+// specific causes and are included in Malformed.
+type ParseStatsSnapshot struct {
+	Malformed         uint64
+	PointerLoops      uint64
+	OversizedNames    uint64
+	OversizedMessages uint64
+}
+
+// ParseRequest parses msg with ParseRequestBounded and records the reason
+// if it is rejected.
+func (s *ParseStats) ParseRequest(msg []byte) (Packet, error) {
+	p, err := ParseRequestBounded(msg)
+	if err != nil {
+		s.Record(err)
+	}
+	return p, err
+}
+
+// Record counts a parse error.
+func (s *ParseStats) Record(err error) {
+	if err == nil {
+		return
+	}
+	s.malformed.Add(1)
+	switch {
+	case errors.Is(err, ErrCompressionLoop):
+		s.pointerLoops.Add(1)
+	case errors.Is(err, ErrNameTooLong):
+		s.oversizedNames.Add(1)
+	case errors.Is(err, ErrMessageTooLarge):
+		s.oversizedMessages.Add(1)
+	}
+}
+
+// Snapshot returns the current counters.
+func (s *ParseStats) Snapshot() ParseStatsSnapshot {
+	return ParseStatsSnapshot{
+		Malformed:         s.malformed.Load(),
+		PointerLoops:      s.pointerLoops.Load(),
+		OversizedNames:    s.oversizedNames.Load(),
+		OversizedMessages: s.oversizedMessages.Load(),
+	}
+}
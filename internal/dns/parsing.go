@@ -26,7 +26,7 @@ const (
 //   - Question or RR counts exceed limits
 func ParseRequestBounded(msg []byte) (Packet, error) {
 	if len(msg) > MaxIncomingDNSMessageSize {
-		return Packet{}, errors.New("dns message too large")
+		return Packet{}, ErrMessageTooLarge
 	}
 	p, err := ParsePacket(msg)
 	if err != nil {
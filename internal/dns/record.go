@@ -69,6 +69,9 @@ func ParseRecord(msg []byte, off *int) (Record, error) {
 //
 // For a forwarding DNS server, we only parse record types needed for:
 //   - Custom DNS construction: A, AAAA, CNAME, NS, PTR
+//   - Authoritative serving and display: LOC, SSHFP, TLSA, DNSKEY, DS, RRSIG
+//   - Aggressive negative caching: NSEC
+//   - Designated resolver discovery: SVCB
 //   - Everything else uses OpaqueRecord for transparent forwarding
 func parseRData(rt RecordType, msg []byte, off *int, start, rdlen int) (Record, error) {
 	switch rt {
@@ -76,6 +79,27 @@ func parseRData(rt RecordType, msg []byte, off *int, start, rdlen int) (Record,
 		return ParseIPRData(msg, off, rdlen)
 	case TypeCNAME, TypeNS, TypePTR:
 		return ParseNameRData(msg, off, start, rdlen, rt)
+	case TypeLOC:
+		// A malformed LOC record is kept opaque instead of failing the
+		// whole message
+		if rec, err := ParseLOCRData(msg, off, rdlen); err == nil {
+			return rec, nil
+		}
+		return ParseOpaqueRData(msg, off, rdlen, rt)
+	case TypeSSHFP:
+		return ParseSSHFPRData(msg, off, rdlen)
+	case TypeTLSA:
+		return ParseTLSARData(msg, off, rdlen)
+	case TypeDNSKEY:
+		return ParseDNSKEYRData(msg, off, rdlen)
+	case TypeDS:
+		return ParseDSRData(msg, off, rdlen)
+	case TypeRRSIG:
+		return ParseRRSIGRData(msg, off, start, rdlen)
+	case TypeNSEC:
+		return ParseNSECRData(msg, off, start, rdlen)
+	case TypeSVCB:
+		return ParseSVCBRData(msg, off, start, rdlen)
 	default:
 		// All other record types (MX, SRV, CAA, TXT, OPT, DNSSEC, etc.)
 		// are passed through opaquely for forwarding
@@ -83,6 +107,22 @@ func parseRData(rt RecordType, msg []byte, off *int, start, rdlen int) (Record,
 	}
 }
 
+// FormatRecord renders a record as a zone-file line:
+// "<name>. <ttl> <class> <type> <rdata>".
+//
+// Records that implement fmt.Stringer supply their RDATA presentation;
+// any other record is rendered in the RFC 3597 generic format.
+func FormatRecord(r Record) string {
+	h := r.Header()
+	var rdata string
+	if s, ok := r.(fmt.Stringer); ok {
+		rdata = s.String()
+	} else if b, err := r.MarshalRData(); err == nil {
+		rdata = (&OpaqueRecord{Data: b}).String()
+	}
+	return fmt.Sprintf("%s\t%d\t%s\t%s\t%s", fqdn(h.Name), h.TTL, RecordClass(h.Class), r.Type(), rdata)
+}
+
 // MarshalRecord converts a Record to wire-format bytes.
 func MarshalRecord(r Record) ([]byte, error) {
 	rdata, err := r.MarshalRData()
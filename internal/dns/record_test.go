@@ -84,3 +84,14 @@ func TestOpaqueRecord_TypedData(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, testData, rdata)
 }
+
+func TestFormatRecord(t *testing.T) {
+	a := dns.NewIPRecord(dns.NewRRHeader("example.com", dns.ClassIN, 300), net.ParseIP("192.0.2.1"))
+	assert.Equal(t, "example.com.\t300\tIN\tA\t192.0.2.1", dns.FormatRecord(a))
+
+	cname := dns.NewCNAMERecord(dns.NewRRHeader("www.example.com", dns.ClassIN, 60), "example.com")
+	assert.Equal(t, "www.example.com.\t60\tIN\tCNAME\texample.com.", dns.FormatRecord(cname))
+
+	unknown := dns.NewOpaqueRecord(dns.NewRRHeader("example.com", dns.ClassIN, 60), dns.RecordType(65280), []byte{0x0A, 0x00})
+	assert.Equal(t, "example.com.\t60\tIN\tTYPE65280\t\\# 2 0A00", dns.FormatRecord(unknown))
+}
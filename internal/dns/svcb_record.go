@@ -0,0 +1,258 @@
+package dns
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// SvcParamKey is an SVCB service parameter key (RFC 9460 §14.3.2).
+type SvcParamKey uint16
+
+const (
+	SvcParamMandatory     SvcParamKey = 0 // Keys the client must understand
+	SvcParamALPN          SvcParamKey = 1 // Supported protocols, e.g. "h2" or "dot"
+	SvcParamNoDefaultALPN SvcParamKey = 2 // The default protocol is not supported
+	SvcParamPort          SvcParamKey = 3 // TCP or UDP port
+	SvcParamIPv4Hint      SvcParamKey = 4 // IPv4 addresses of the target
+	SvcParamECH           SvcParamKey = 5 // Encrypted ClientHello configuration
+	SvcParamIPv6Hint      SvcParamKey = 6 // IPv6 addresses of the target
+	SvcParamDoHPath       SvcParamKey = 7 // DoH URI template path (RFC 9461)
+)
+
+// String returns the presentation name of the key, e.g. "alpn", or
+// "keyNNNNN" for keys without one.
+func (k SvcParamKey) String() string {
+	switch k {
+	case SvcParamMandatory:
+		return "mandatory"
+	case SvcParamALPN:
+		return "alpn"
+	case SvcParamNoDefaultALPN:
+		return "no-default-alpn"
+	case SvcParamPort:
+		return "port"
+	case SvcParamIPv4Hint:
+		return "ipv4hint"
+	case SvcParamECH:
+		return "ech"
+	case SvcParamIPv6Hint:
+		return "ipv6hint"
+	case SvcParamDoHPath:
+		return "dohpath"
+	default:
+		return "key" + strconv.Itoa(int(k))
+	}
+}
+
+// SvcParam is a service parameter with its value in wire format.
+type SvcParam struct {
+	Key   SvcParamKey
+	Value []byte
+}
+
+// NewSvcParamALPN returns an alpn parameter listing the protocol IDs.
+func NewSvcParamALPN(ids ...string) SvcParam {
+	var v []byte
+	for _, id := range ids {
+		v = append(v, byte(len(id)))
+		v = append(v, id...)
+	}
+	return SvcParam{Key: SvcParamALPN, Value: v}
+}
+
+// NewSvcParamPort returns a port parameter.
+func NewSvcParamPort(port uint16) SvcParam {
+	return SvcParam{Key: SvcParamPort, Value: binary.BigEndian.AppendUint16(nil, port)}
+}
+
+// NewSvcParamIPv4Hint returns an ipv4hint parameter. Addresses that are
+// not IPv4 are left out.
+func NewSvcParamIPv4Hint(addrs ...netip.Addr) SvcParam {
+	var v []byte
+	for _, a := range addrs {
+		if a.Unmap().Is4() {
+			v = append(v, a.Unmap().AsSlice()...)
+		}
+	}
+	return SvcParam{Key: SvcParamIPv4Hint, Value: v}
+}
+
+// NewSvcParamIPv6Hint returns an ipv6hint parameter. Addresses that are
+// not IPv6 are left out.
+func NewSvcParamIPv6Hint(addrs ...netip.Addr) SvcParam {
+	var v []byte
+	for _, a := range addrs {
+		if a.Is6() && !a.Is4In6() {
+			v = append(v, a.AsSlice()...)
+		}
+	}
+	return SvcParam{Key: SvcParamIPv6Hint, Value: v}
+}
+
+// NewSvcParamDoHPath returns a dohpath parameter holding a relative DoH URI
+// template such as "/dns-query{?dns}" (RFC 9461 §5).
+func NewSvcParamDoHPath(template string) SvcParam {
+	return SvcParam{Key: SvcParamDoHPath, Value: []byte(template)}
+}
+
+// SVCBRecord represents a service binding record (RFC 9460), as used for
+// Discovery of Designated Resolvers (RFC 9462).
+type SVCBRecord struct {
+	H        RRHeader
+	Priority uint16 // 0 = AliasMode; otherwise ServiceMode, lower preferred
+	Target   string // Target name; "" is the root, i.e. the owner name
+	Params   []SvcParam
+}
+
+// NewSVCBRecord creates a new SVCB record. params must be in strictly
+// increasing key order.
+func NewSVCBRecord(h RRHeader, priority uint16, target string, params ...SvcParam) *SVCBRecord {
+	return &SVCBRecord{H: h, Priority: priority, Target: target, Params: params}
+}
+
+// Type returns TypeSVCB.
+func (r *SVCBRecord) Type() RecordType { return TypeSVCB }
+
+// Header returns the record header.
+func (r *SVCBRecord) Header() RRHeader { return r.H }
+
+// SetHeader sets the record header.
+func (r *SVCBRecord) SetHeader(h RRHeader) { r.H = h }
+
+// Param returns the value of the parameter with key.
+func (r *SVCBRecord) Param(key SvcParamKey) ([]byte, bool) {
+	for _, p := range r.Params {
+		if p.Key == key {
+			return p.Value, true
+		}
+	}
+	return nil, false
+}
+
+// MarshalRData marshals the record to wire format. The target name is
+// never compressed (RFC 9460 §2.2).
+func (r *SVCBRecord) MarshalRData() ([]byte, error) {
+	target, err := EncodeName(fqdn(r.Target))
+	if err != nil {
+		return nil, err
+	}
+	b := binary.BigEndian.AppendUint16(nil, r.Priority)
+	b = append(b, target...)
+	for i, p := range r.Params {
+		if i > 0 && p.Key <= r.Params[i-1].Key {
+			return nil, fmt.Errorf("%w: SVCB keys must be in strictly increasing order (RFC 9460 §2.2)", ErrDNSError)
+		}
+		if len(p.Value) > 0xFFFF {
+			return nil, fmt.Errorf("%w: SVCB %s value too long", ErrDNSError, p.Key)
+		}
+		b = binary.BigEndian.AppendUint16(b, uint16(p.Key))
+		b = binary.BigEndian.AppendUint16(b, uint16(len(p.Value)))
+		b = append(b, p.Value...)
+	}
+	return b, nil
+}
+
+// String returns the RDATA in presentation format, e.g.
+// `1 dns.example. alpn=dot port=853 ipv4hint=192.0.2.53`.
+func (r *SVCBRecord) String() string {
+	var sb strings.Builder
+	sb.WriteString(strconv.Itoa(int(r.Priority)))
+	sb.WriteByte(' ')
+	sb.WriteString(fqdn(r.Target))
+	for _, p := range r.Params {
+		sb.WriteByte(' ')
+		sb.WriteString(p.Key.String())
+		if v := svcParamString(p); v != "" {
+			sb.WriteByte('=')
+			sb.WriteString(v)
+		}
+	}
+	return sb.String()
+}
+
+// svcParamString returns the presentation value of p.
+func svcParamString(p SvcParam) string {
+	v := p.Value
+	var parts []string
+	switch p.Key {
+	case SvcParamMandatory:
+		for i := 0; i+1 < len(v); i += 2 {
+			parts = append(parts, SvcParamKey(binary.BigEndian.Uint16(v[i:])).String())
+		}
+	case SvcParamALPN:
+		for i := 0; i < len(v); {
+			n := int(v[i])
+			end := min(i+1+n, len(v))
+			parts = append(parts, svcEscape(v[i+1:end], true))
+			i = end
+		}
+	case SvcParamPort:
+		if len(v) == 2 {
+			return strconv.Itoa(int(binary.BigEndian.Uint16(v)))
+		}
+	case SvcParamIPv4Hint, SvcParamIPv6Hint:
+		size := 4
+		if p.Key == SvcParamIPv6Hint {
+			size = 16
+		}
+		for i := 0; i+size <= len(v); i += size {
+			a, _ := netip.AddrFromSlice(v[i : i+size])
+			parts = append(parts, a.String())
+		}
+	default:
+		return svcEscape(v, false)
+	}
+	return strings.Join(parts, ",")
+}
+
+// svcEscape escapes a value for presentation: non-printable bytes, quotes,
+// backslashes and spaces as \DDD, and commas too within a list.
+func svcEscape(b []byte, list bool) string {
+	var sb strings.Builder
+	for _, c := range b {
+		if c <= ' ' || c >= 0x7F || c == '"' || c == '\\' || c == ';' || (list && c == ',') {
+			fmt.Fprintf(&sb, "\\%03d", c)
+			continue
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String()
+}
+
+// ParseSVCBRData parses SVCB record RDATA from wire format.
+func ParseSVCBRData(msg []byte, off *int, start, rdlen int) (*SVCBRecord, error) {
+	end := start + rdlen
+	if rdlen < 3 {
+		return nil, fmt.Errorf("%w: SVCB record too short (RFC 9460 §2.2), got %d bytes", ErrDNSError, rdlen)
+	}
+	r := &SVCBRecord{Priority: binary.BigEndian.Uint16(msg[*off:])}
+	*off += 2
+	target, err := DecodeName(msg, off)
+	if err != nil {
+		return nil, err
+	}
+	r.Target = target
+	for *off < end {
+		if end-*off < 4 {
+			return nil, fmt.Errorf("%w: truncated SVCB parameter (RFC 9460 §2.2)", ErrDNSError)
+		}
+		key := SvcParamKey(binary.BigEndian.Uint16(msg[*off:]))
+		n := int(binary.BigEndian.Uint16(msg[*off+2:]))
+		*off += 4
+		if n > end-*off {
+			return nil, fmt.Errorf("%w: SVCB %s value exceeds RDATA (RFC 9460 §2.2)", ErrDNSError, key)
+		}
+		if len(r.Params) > 0 && key <= r.Params[len(r.Params)-1].Key {
+			return nil, fmt.Errorf("%w: SVCB keys must be in strictly increasing order (RFC 9460 §2.2)", ErrDNSError)
+		}
+		r.Params = append(r.Params, SvcParam{Key: key, Value: cloneBytes(msg[*off : *off+n])})
+		*off += n
+	}
+	if *off != end {
+		return nil, fmt.Errorf("%w: SVCB record RDATA length mismatch (RFC 9460 §2.2)", ErrDNSError)
+	}
+	return r, nil
+}
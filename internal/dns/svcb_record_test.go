@@ -0,0 +1,52 @@
+package dns_test
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/jroosing/hydradns/internal/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSVCBRecord_RoundTripAndString(t *testing.T) {
+	h := dns.NewRRHeader("_dns.resolver.arpa", dns.ClassIN, 300)
+	rec := dns.NewSVCBRecord(h, 1, "dns.example.net",
+		dns.NewSvcParamALPN("h2"),
+		dns.NewSvcParamPort(443),
+		dns.NewSvcParamIPv4Hint(netip.MustParseAddr("192.0.2.53")),
+		dns.NewSvcParamIPv6Hint(netip.MustParseAddr("2001:db8::53")),
+		dns.NewSvcParamDoHPath("/dns-query{?dns}"),
+	)
+
+	got, ok := roundTrip(t, rec).(*dns.SVCBRecord)
+	require.True(t, ok, "SVCB should parse as a typed record")
+	assert.Equal(t, uint16(1), got.Priority)
+	assert.Equal(t, "dns.example.net", got.Target)
+	assert.Equal(t, rec.Params, got.Params)
+	assert.Equal(t,
+		"1 dns.example.net. alpn=h2 port=443 ipv4hint=192.0.2.53 ipv6hint=2001:db8::53 dohpath=/dns-query{?dns}",
+		got.String())
+
+	path, ok := got.Param(dns.SvcParamDoHPath)
+	require.True(t, ok)
+	assert.Equal(t, "/dns-query{?dns}", string(path))
+}
+
+func TestSVCBRecord_RejectsUnorderedKeys(t *testing.T) {
+	rec := dns.NewSVCBRecord(dns.NewRRHeader("_dns.resolver.arpa", dns.ClassIN, 300), 1, "dns.example.net",
+		dns.NewSvcParamPort(853),
+		dns.NewSvcParamALPN("dot"),
+	)
+	_, err := rec.MarshalRData()
+	require.ErrorIs(t, err, dns.ErrDNSError)
+}
+
+func TestSVCBRecord_RootTarget(t *testing.T) {
+	rec := dns.NewSVCBRecord(dns.NewRRHeader("_dns.dns.example.net", dns.ClassIN, 300), 2, "",
+		dns.NewSvcParamALPN("dot"))
+
+	got, ok := roundTrip(t, rec).(*dns.SVCBRecord)
+	require.True(t, ok)
+	assert.Equal(t, "2 . alpn=dot", got.String())
+}
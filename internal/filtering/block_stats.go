@@ -0,0 +1,113 @@
+package filtering
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+)
+
+// maxTrackedDomains bounds the domains whose blocks are counted per list.
+// Once a list has this many, a newly blocked domain replaces the least
+// blocked one and inherits its count (the Space-Saving algorithm), so
+// frequently blocked domains stay in the top while memory stays bounded.
+const maxTrackedDomains = 1000
+
+// ListBlockStats counts the queries one list blocked.
+type ListBlockStats struct {
+	Name string
+	// Blocked counts the queries blocked with the list among the matching
+	// lists; Unique counts those no other list would have blocked.
+	Blocked uint64
+	Unique  uint64
+	// TopDomains are the most blocked domains, most blocked first. Counts
+	// are upper bounds once more than maxTrackedDomains domains were
+	// blocked by the list.
+	TopDomains []DomainCount
+}
+
+// DomainCount is a domain and how often it was blocked.
+type DomainCount struct {
+	Domain string
+	Count  uint64
+}
+
+// blockStats counts blocks per list. The zero value is ready to use.
+type blockStats struct {
+	mu    sync.Mutex
+	lists map[string]*listBlocks
+}
+
+// listBlocks counts the blocks of one list.
+type listBlocks struct {
+	blocked uint64
+	unique  uint64
+	domains map[string]uint64
+}
+
+// record counts a block of domain by lists.
+func (s *blockStats) record(domain string, lists []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lists == nil {
+		s.lists = make(map[string]*listBlocks)
+	}
+	for _, name := range lists {
+		l := s.lists[name]
+		if l == nil {
+			l = &listBlocks{domains: make(map[string]uint64)}
+			s.lists[name] = l
+		}
+		l.blocked++
+		if len(lists) == 1 {
+			l.unique++
+		}
+		l.count(domain)
+	}
+}
+
+// count counts a block of domain, evicting the least blocked domain when
+// the list tracks maxTrackedDomains already.
+func (l *listBlocks) count(domain string) {
+	if _, ok := l.domains[domain]; ok || len(l.domains) < maxTrackedDomains {
+		l.domains[domain]++
+		return
+	}
+	var minDomain string
+	var minCount uint64
+	for d, n := range l.domains {
+		if minDomain == "" || n < minCount {
+			minDomain, minCount = d, n
+		}
+	}
+	delete(l.domains, minDomain)
+	l.domains[domain] = minCount + 1
+}
+
+// snapshot returns the counts of every list that blocked a query, most
+// blocking first, with up to top domains each.
+func (s *blockStats) snapshot(top int) []ListBlockStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := make([]ListBlockStats, 0, len(s.lists))
+	for name, l := range s.lists {
+		domains := make([]DomainCount, 0, len(l.domains))
+		for d, n := range l.domains {
+			domains = append(domains, DomainCount{Domain: d, Count: n})
+		}
+		slices.SortFunc(domains, func(a, b DomainCount) int {
+			return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Domain, b.Domain))
+		})
+		stats = append(stats, ListBlockStats{
+			Name:       name,
+			Blocked:    l.blocked,
+			Unique:     l.unique,
+			TopDomains: domains[:min(top, len(domains))],
+		})
+	}
+	slices.SortFunc(stats, func(a, b ListBlockStats) int {
+		return cmp.Or(cmp.Compare(b.Blocked, a.Blocked), cmp.Compare(a.Name, b.Name))
+	})
+	return stats
+}
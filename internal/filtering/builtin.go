@@ -0,0 +1,39 @@
+package filtering
+
+import (
+	_ "embed"
+	"strings"
+)
+
+// BuiltinScheme prefixes the URL of a blocklist compiled into the binary,
+// e.g. "builtin:default". Built-in lists are never downloaded.
+const BuiltinScheme = "builtin:"
+
+// DefaultBlocklistURL is the URL of the built-in default blocklist, a small
+// list of advertising and tracking domains for trying out filtering.
+const DefaultBlocklistURL = BuiltinScheme + "default"
+
+//go:embed builtin/default.txt
+var defaultBlocklist []byte
+
+// builtinLists maps built-in list names to their contents.
+var builtinLists = map[string][]byte{
+	"default": defaultBlocklist,
+}
+
+// IsBuiltinURL reports whether url names a blocklist compiled into the
+// binary.
+func IsBuiltinURL(url string) bool {
+	_, ok := builtinList(url)
+	return ok
+}
+
+// builtinList returns the contents of the built-in list named by url.
+func builtinList(url string) ([]byte, bool) {
+	name, ok := strings.CutPrefix(url, BuiltinScheme)
+	if !ok {
+		return nil, false
+	}
+	body, ok := builtinLists[name]
+	return body, ok
+}
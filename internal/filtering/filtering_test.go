@@ -1,8 +1,15 @@
 package filtering_test
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -123,6 +130,31 @@ func TestDomainTrie_Merge(t *testing.T) {
 	assert.Equal(t, 3, trie1.Size())
 }
 
+func TestDomainTrie_Sources(t *testing.T) {
+	list := filtering.NewDomainTrie()
+	list.Add("ads.example.com", false)
+
+	trie := filtering.NewDomainTrie()
+	trie.AddSource("example.com", true, 1)
+	trie.MergeSource(list, 2)
+	trie.Add("plain.org", false)
+
+	sources, ok := trie.Lookup("ads.example.com")
+	assert.True(t, ok)
+	assert.Equal(t, uint64(0b110), sources, "the wildcard parent and the exact entry both match")
+
+	sources, ok = trie.Lookup("cdn.example.com")
+	assert.True(t, ok)
+	assert.Equal(t, uint64(0b010), sources)
+
+	sources, ok = trie.Lookup("plain.org")
+	assert.True(t, ok)
+	assert.Zero(t, sources, "entries added without a source have none")
+
+	_, ok = trie.Lookup("example.org")
+	assert.False(t, ok)
+}
+
 func TestDomainTrie_EmptyDomain(t *testing.T) {
 	trie := filtering.NewDomainTrie()
 
@@ -138,6 +170,36 @@ func TestDomainTrie_TrailingDot(t *testing.T) {
 	assert.True(t, trie.Contains("example.com."), "Should match with trailing dot")
 }
 
+func TestDomainTrie_Walk(t *testing.T) {
+	trie := filtering.NewDomainTrie()
+	trie.Add("b.example.com", false)
+	trie.Add("example.com", true)
+	trie.Add("ads.org", false)
+
+	var got []string
+	trie.Walk(func(domain string, wildcard bool) {
+		if wildcard {
+			domain = "*." + domain
+		}
+		got = append(got, domain)
+	})
+
+	assert.Equal(t, []string{"*.example.com", "b.example.com", "ads.org"}, got,
+		"Should visit domains grouped by TLD, parents before subdomains")
+}
+
+func TestDomainTrie_WalkDoesNotHoldLock(t *testing.T) {
+	trie := filtering.NewDomainTrie()
+	trie.Add("example.com", false)
+
+	// A writer inside the callback would deadlock if Walk held the lock
+	trie.Walk(func(string, bool) {
+		trie.Add("added.example", false)
+	})
+
+	assert.True(t, trie.Contains("added.example"))
+}
+
 // =============================================================================
 // PolicyEngine Tests
 // =============================================================================
@@ -193,6 +255,74 @@ func TestPolicyEngine_Whitelist(t *testing.T) {
 	assert.Equal(t, "whitelist", result.ListName)
 }
 
+func TestPolicyEngine_AllowFor(t *testing.T) {
+	pe := filtering.NewPolicyEngine(filtering.PolicyEngineConfig{
+		Enabled:          true,
+		BlockAction:      filtering.ActionBlock,
+		BlacklistDomains: []string{"ads.example.com"},
+	})
+	defer pe.Close()
+
+	pe.AllowFor("Ads.Example.com.", time.Hour)
+
+	result := pe.Evaluate("ads.example.com")
+	assert.Equal(t, filtering.ActionAllow, result.Action)
+	assert.Equal(t, "temporary", result.ListName)
+	assert.Equal(t, filtering.ActionBlock, pe.Evaluate("cdn.ads.example.com").Action,
+		"Temporary allows should not cover subdomains")
+}
+
+func TestPolicyEngine_AllowForExpires(t *testing.T) {
+	pe := filtering.NewPolicyEngine(filtering.PolicyEngineConfig{
+		Enabled:          true,
+		BlockAction:      filtering.ActionBlock,
+		BlacklistDomains: []string{"ads.example.com"},
+	})
+	defer pe.Close()
+
+	pe.AllowFor("ads.example.com", 20*time.Millisecond)
+	assert.Equal(t, filtering.ActionAllow, pe.Evaluate("ads.example.com").Action)
+
+	assert.Eventually(t, func() bool {
+		return pe.Evaluate("ads.example.com").Action == filtering.ActionBlock
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestPolicyEngine_BlockFor(t *testing.T) {
+	pe := filtering.NewPolicyEngine(filtering.PolicyEngineConfig{
+		Enabled:          true,
+		BlockAction:      filtering.ActionBlock,
+		WhitelistDomains: []string{"safe.example.com"},
+	})
+	defer pe.Close()
+
+	pe.BlockFor("Bad.Example.com.", time.Hour, "threat-intel")
+	pe.BlockFor("safe.example.com", time.Hour, "threat-intel")
+
+	result := pe.Evaluate("bad.example.com")
+	assert.Equal(t, filtering.ActionBlock, result.Action)
+	assert.Equal(t, "threat-intel", result.ListName)
+	assert.Equal(t, filtering.ActionAllow, pe.Evaluate("cdn.bad.example.com").Action,
+		"Temporary blocks should not cover subdomains")
+	assert.Equal(t, filtering.ActionAllow, pe.Evaluate("safe.example.com").Action,
+		"Whitelist should take priority over temporary blocks")
+}
+
+func TestPolicyEngine_BlockForExpires(t *testing.T) {
+	pe := filtering.NewPolicyEngine(filtering.PolicyEngineConfig{
+		Enabled:     true,
+		BlockAction: filtering.ActionBlock,
+	})
+	defer pe.Close()
+
+	pe.BlockFor("bad.example.com", 20*time.Millisecond, "threat-intel")
+	assert.Equal(t, filtering.ActionBlock, pe.Evaluate("bad.example.com").Action)
+
+	assert.Eventually(t, func() bool {
+		return pe.Evaluate("bad.example.com").Action == filtering.ActionAllow
+	}, time.Second, 10*time.Millisecond)
+}
+
 func TestPolicyEngine_WhitelistTakesPriority(t *testing.T) {
 	// Domain is both whitelisted and blacklisted - whitelist wins
 	pe := filtering.NewPolicyEngine(filtering.PolicyEngineConfig{
@@ -272,6 +402,114 @@ func TestPolicyEngine_Statistics(t *testing.T) {
 	assert.Equal(t, uint64(2), stats.QueriesAllowed)
 }
 
+func TestPolicyEngine_Check(t *testing.T) {
+	pe := filtering.NewPolicyEngine(filtering.PolicyEngineConfig{
+		Enabled:          true,
+		BlockAction:      filtering.ActionBlock,
+		WhitelistDomains: []string{"ok.ads.example.com"},
+		BlacklistDomains: []string{"ads.example.com"},
+	})
+	defer pe.Close()
+
+	d := pe.Check("cdn.ads.example.com")
+	assert.Equal(t, filtering.ActionBlock, d.Action)
+	assert.Equal(t, filtering.ReasonBlacklist, d.Reason)
+	assert.Equal(t, "blacklist", d.ListName)
+
+	d = pe.Check("ok.ads.example.com")
+	assert.Equal(t, filtering.ActionAllow, d.Action)
+	assert.Equal(t, filtering.ReasonWhitelist, d.Reason)
+	assert.Equal(t, []string{"blacklist"}, d.Lists)
+
+	pe.BlockFor("bad.example.org", time.Minute, "threat-intel")
+	d = pe.Check("bad.example.org")
+	assert.Equal(t, filtering.ReasonTemporaryBlock, d.Reason)
+	assert.Equal(t, "threat-intel", d.ListName)
+
+	assert.Equal(t, filtering.ReasonNoMatch, pe.Check("example.org").Reason)
+
+	pe.SetEnabled(false)
+	assert.Equal(t, filtering.ReasonDisabled, pe.Check("ads.example.com").Reason)
+
+	assert.Equal(t, uint64(0), pe.Stats().QueriesTotal)
+	assert.Empty(t, pe.BlockStats(10))
+}
+
+func TestPolicyEngine_BlockStats(t *testing.T) {
+	lists := map[string]string{
+		"/ads.txt":      "ads.example.com\ntracker.example.com\n",
+		"/trackers.txt": "tracker.example.com\n",
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(lists[r.URL.Path]))
+	}))
+	defer srv.Close()
+
+	pe := filtering.NewPolicyEngine(filtering.PolicyEngineConfig{
+		Enabled:          true,
+		BlockAction:      filtering.ActionBlock,
+		BlacklistDomains: []string{"manual.example.com"},
+		BlocklistURLs: []filtering.BlocklistURL{
+			{Name: "ads", URL: srv.URL + "/ads.txt", Format: filtering.FormatDomains},
+			{Name: "trackers", URL: srv.URL + "/trackers.txt", Format: filtering.FormatDomains},
+		},
+	})
+	defer pe.Close()
+	require.Eventually(t, func() bool { return len(pe.ListInfo()) == 2 }, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, "ads", pe.Evaluate("ads.example.com").ListName)
+	pe.Evaluate("ads.example.com")
+	assert.Equal(t, "ads", pe.Evaluate("Tracker.Example.com.").ListName, "the first matching list is reported")
+	assert.Equal(t, "blacklist", pe.Evaluate("manual.example.com").ListName)
+	pe.BlockFor("malware.example", time.Hour, "threat-intel")
+	pe.Evaluate("malware.example")
+	pe.Evaluate("allowed.example.com")
+
+	stats := pe.BlockStats(1)
+	require.Len(t, stats, 4)
+	assert.Equal(t, filtering.ListBlockStats{
+		Name:       "ads",
+		Blocked:    3,
+		Unique:     2,
+		TopDomains: []filtering.DomainCount{{Domain: "ads.example.com", Count: 2}},
+	}, stats[0])
+	assert.Equal(t, "blacklist", stats[1].Name)
+	assert.Equal(t, "threat-intel", stats[2].Name)
+	assert.Equal(t, filtering.ListBlockStats{
+		Name:       "trackers",
+		Blocked:    1,
+		Unique:     0,
+		TopDomains: []filtering.DomainCount{{Domain: "tracker.example.com", Count: 1}},
+	}, stats[3])
+}
+
+func TestPolicyEngine_BlocklistsLoaded(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		<-release
+		_, _ = w.Write([]byte("ads.example.com\n"))
+	}))
+	defer srv.Close()
+
+	pe := filtering.NewPolicyEngine(filtering.PolicyEngineConfig{
+		Enabled:     true,
+		BlockAction: filtering.ActionBlock,
+		BlocklistURLs: []filtering.BlocklistURL{
+			{Name: "ads", URL: srv.URL + "/ads.txt", Format: filtering.FormatDomains},
+		},
+	})
+	defer pe.Close()
+	assert.False(t, pe.BlocklistsLoaded(), "the list is still downloading")
+
+	close(release)
+	require.Eventually(t, pe.BlocklistsLoaded, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, filtering.ActionBlock, pe.Evaluate("ads.example.com").Action)
+
+	noLists := filtering.NewPolicyEngine(filtering.PolicyEngineConfig{Enabled: true})
+	defer noLists.Close()
+	assert.True(t, noLists.BlocklistsLoaded())
+}
+
 func TestPolicyEngine_Close(t *testing.T) {
 	pe := filtering.NewPolicyEngine(filtering.PolicyEngineConfig{
 		Enabled: true,
@@ -281,6 +519,77 @@ func TestPolicyEngine_Close(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+// =============================================================================
+// RPZ Export Tests
+// =============================================================================
+
+func writeRPZ(t *testing.T, pe *filtering.PolicyEngine, origin string) string {
+	t.Helper()
+	var b strings.Builder
+	require.NoError(t, pe.WriteRPZ(&b, origin, 42))
+	return b.String()
+}
+
+func TestPolicyEngine_WriteRPZ(t *testing.T) {
+	pe := filtering.NewPolicyEngine(filtering.PolicyEngineConfig{
+		Enabled:          true,
+		BlacklistDomains: []string{"ads.example.com", "tracker.net"},
+		WhitelistDomains: []string{"good.tracker.net"},
+	})
+	defer pe.Close()
+
+	zone := writeRPZ(t, pe, "")
+
+	assert.Contains(t, zone, "$ORIGIN rpz.hydradns.\n")
+	assert.Contains(t, zone, "@ IN SOA localhost. hostmaster.localhost. 42 ")
+	assert.Contains(t, zone, "@ IN NS localhost.\n")
+	assert.Contains(t, zone, "ads.example.com CNAME .\n")
+	assert.Contains(t, zone, "*.ads.example.com CNAME .\n")
+	assert.Contains(t, zone, "tracker.net CNAME .\n")
+	assert.Contains(t, zone, "good.tracker.net CNAME rpz-passthru.\n")
+	assert.Contains(t, zone, "*.good.tracker.net CNAME rpz-passthru.\n")
+}
+
+func TestPolicyEngine_WriteRPZ_WhitelistOverrides(t *testing.T) {
+	pe := filtering.NewPolicyEngine(filtering.PolicyEngineConfig{
+		Enabled:          true,
+		BlacklistDomains: []string{"ads.example.com", "example.com"},
+		WhitelistDomains: []string{"example.com"},
+	})
+	defer pe.Close()
+
+	zone := writeRPZ(t, pe, "")
+
+	// An exact RPZ rule would beat the passthru wildcard, so blocked domains
+	// the whitelist covers must not be exported at all
+	assert.NotContains(t, zone, "CNAME .\n")
+	assert.Contains(t, zone, "*.example.com CNAME rpz-passthru.\n")
+}
+
+func TestPolicyEngine_WriteRPZ_Disabled(t *testing.T) {
+	pe := filtering.NewPolicyEngine(filtering.PolicyEngineConfig{
+		Enabled:          false,
+		BlacklistDomains: []string{"ads.example.com"},
+	})
+	defer pe.Close()
+
+	zone := writeRPZ(t, pe, "policy.example.")
+
+	assert.Contains(t, zone, "$ORIGIN policy.example.\n")
+	assert.NotContains(t, zone, "ads.example.com", "Disabled filtering blocks nothing")
+}
+
+func TestPolicyEngine_WriteRPZ_InvalidOrigin(t *testing.T) {
+	pe := filtering.NewPolicyEngine(filtering.PolicyEngineConfig{Enabled: true})
+	defer pe.Close()
+
+	var b strings.Builder
+	for _, origin := range []string{"bad..origin", "rpz;evil", "rpz hydradns"} {
+		require.Error(t, pe.WriteRPZ(&b, origin, 1), origin)
+	}
+	assert.Empty(t, b.String(), "Nothing should be written for an invalid origin")
+}
+
 // =============================================================================
 // Action Tests
 // =============================================================================
@@ -376,6 +685,43 @@ func TestParser_ParseAdblock(t *testing.T) {
 	assert.True(t, trie.Contains("tracker.example.org"))
 }
 
+func TestParser_IDNDomains(t *testing.T) {
+	parser := filtering.NewParser()
+
+	// Queries arrive as A-labels on the wire.
+	tests := []struct {
+		format filtering.ListFormat
+		line   string
+		want   string
+	}{
+		{filtering.FormatDomains, "трекер.рф", "xn--e1aaowdh.xn--p1ai"},
+		{filtering.FormatHosts, "0.0.0.0 реклама.example", "xn--80aanufhx.example"},
+		{filtering.FormatAdblock, "||werbung.münchen.de^", "werbung.xn--mnchen-3ya.de"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			trie, err := parser.Parse(strings.NewReader(tt.line), tt.format)
+			require.NoError(t, err)
+			assert.True(t, trie.Contains(tt.want))
+		})
+	}
+}
+
+func TestPolicyEngine_BlacklistIDN(t *testing.T) {
+	pe := filtering.NewPolicyEngine(filtering.PolicyEngineConfig{
+		Enabled:          true,
+		BlockAction:      filtering.ActionBlock,
+		BlacklistDomains: []string{"трекер.рф"},
+	})
+	defer pe.Close()
+
+	assert.Equal(t, filtering.ActionBlock, pe.Evaluate("xn--e1aaowdh.xn--p1ai").Action)
+	assert.Equal(t, filtering.ActionBlock, pe.Evaluate("cdn.xn--e1aaowdh.xn--p1ai").Action)
+
+	pe.AddToWhitelist("cdn.трекер.рф")
+	assert.Equal(t, filtering.ActionAllow, pe.Evaluate("cdn.xn--e1aaowdh.xn--p1ai").Action)
+}
+
 func TestParser_AutoDetect(t *testing.T) {
 	parser := filtering.NewParser()
 
@@ -386,6 +732,184 @@ func TestParser_AutoDetect(t *testing.T) {
 	assert.GreaterOrEqual(t, trie.Size(), 0)
 }
 
+// =============================================================================
+// Parser Fetch Tests
+// =============================================================================
+
+// memCache is an in-memory filtering.FetchCache.
+type memCache struct {
+	mu    sync.Mutex
+	lists map[string]filtering.CachedList
+}
+
+func (c *memCache) GetCachedList(_ context.Context, url string) (filtering.CachedList, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	list, ok := c.lists[url]
+	return list, ok, nil
+}
+
+func (c *memCache) PutCachedList(_ context.Context, url string, list filtering.CachedList) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lists == nil {
+		c.lists = make(map[string]filtering.CachedList)
+	}
+	c.lists[url] = list
+	return nil
+}
+
+func TestParser_ParseURL_ConditionalFetch(t *testing.T) {
+	var full, notModified atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full.Add(1)
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte("ads.example.com\ntracker.example.org\n"))
+	}))
+	defer srv.Close()
+
+	parser := filtering.NewParser()
+	parser.Cache = &memCache{}
+
+	for range 2 {
+		trie, err := parser.ParseURL(srv.URL, filtering.FormatDomains)
+		require.NoError(t, err)
+		assert.True(t, trie.Contains("ads.example.com"))
+		assert.Equal(t, 2, trie.Size())
+	}
+
+	assert.Equal(t, int32(1), full.Load(), "Unchanged list should be downloaded once")
+	assert.Equal(t, int32(1), notModified.Load(), "Second fetch should be answered from the cache")
+}
+
+func TestParser_ParseURL_IfModifiedSince(t *testing.T) {
+	const lastModified = "Mon, 02 Jan 2006 15:04:05 GMT"
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("If-Modified-Since")
+		w.Header().Set("Last-Modified", lastModified)
+		_, _ = w.Write([]byte("ads.example.com\n"))
+	}))
+	defer srv.Close()
+
+	cache := &memCache{}
+	parser := filtering.NewParser()
+	parser.Cache = cache
+
+	_, err := parser.ParseURL(srv.URL, filtering.FormatDomains)
+	require.NoError(t, err)
+	assert.Empty(t, got, "First fetch should be unconditional")
+
+	_, err = parser.ParseURL(srv.URL, filtering.FormatDomains)
+	require.NoError(t, err)
+	assert.Equal(t, lastModified, got)
+}
+
+func TestParser_ParseURL_Gzip(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, _ = zw.Write([]byte("ads.example.com\n"))
+	require.NoError(t, zw.Close())
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/gzip")
+		_, _ = w.Write(buf.Bytes())
+	}))
+	defer srv.Close()
+
+	trie, err := filtering.NewParser().ParseURL(srv.URL+"/list.txt.gz", filtering.FormatDomains)
+	require.NoError(t, err)
+	assert.True(t, trie.Contains("ads.example.com"))
+}
+
+func TestParser_ParseURL_MaxSize(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(strings.Repeat("ads.example.com\n", 100)))
+	}))
+	defer srv.Close()
+
+	parser := filtering.NewParser()
+	parser.MaxSize = 64
+
+	_, err := parser.ParseURL(srv.URL, filtering.FormatDomains)
+	require.ErrorIs(t, err, filtering.ErrListTooLarge)
+}
+
+func TestParser_ParseURL_RetriesServerErrors(t *testing.T) {
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ads.example.com\n"))
+	}))
+	defer srv.Close()
+
+	parser := filtering.NewParser()
+	parser.RetryDelay = time.Millisecond
+
+	trie, err := parser.ParseURL(srv.URL, filtering.FormatDomains)
+	require.NoError(t, err)
+	assert.True(t, trie.Contains("ads.example.com"))
+	assert.Equal(t, int32(3), attempts.Load())
+}
+
+func TestParser_ParseURL_NoRetryOnClientError(t *testing.T) {
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	parser := filtering.NewParser()
+	parser.RetryDelay = time.Millisecond
+
+	_, err := parser.ParseURL(srv.URL, filtering.FormatDomains)
+	require.Error(t, err)
+	assert.Equal(t, int32(1), attempts.Load())
+}
+
+func TestParser_ParseURL_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	parser := filtering.NewParser()
+	parser.SetTimeout(50)
+	parser.MaxRetries = 0
+
+	start := time.Now()
+	_, err := parser.ParseURL(srv.URL, filtering.FormatDomains)
+	require.Error(t, err)
+	assert.Less(t, time.Since(start), 5*time.Second, "A hung server should not stall the fetch")
+}
+
+func TestParser_ParseURL_Builtin(t *testing.T) {
+	parser := filtering.NewParser()
+	parser.Cache = &memCache{}
+
+	trie, err := parser.ParseURL(filtering.DefaultBlocklistURL, filtering.FormatDomains)
+	require.NoError(t, err)
+	assert.Positive(t, trie.Size())
+	assert.True(t, trie.Contains("doubleclick.net"))
+	assert.True(t, trie.Contains("stats.doubleclick.net"), "entries block their subdomains")
+
+	assert.True(t, filtering.IsBuiltinURL(filtering.DefaultBlocklistURL))
+	assert.False(t, filtering.IsBuiltinURL("builtin:missing"))
+	_, err = parser.ParseURL("builtin:missing", filtering.FormatDomains)
+	assert.Error(t, err, "unknown built-in lists are not found")
+}
+
 // =============================================================================
 // Concurrent Access Tests
 // =============================================================================
@@ -443,3 +967,139 @@ func TestPolicyEngine_ConcurrentEvaluate(t *testing.T) {
 		}
 	}
 }
+
+// =============================================================================
+// Newly Registered Domain Tests
+// =============================================================================
+
+// memFirstSeenStore is an in-memory FirstSeenStore.
+type memFirstSeenStore struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+func (s *memFirstSeenStore) LoadFirstSeen(context.Context) (map[string]time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]time.Time, len(s.seen))
+	for d, t := range s.seen {
+		out[d] = t
+	}
+	return out, nil
+}
+
+func (s *memFirstSeenStore) RecordFirstSeen(_ context.Context, firstSeen map[string]time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for d, t := range firstSeen {
+		if cur, ok := s.seen[d]; !ok || t.Before(cur) {
+			s.seen[d] = t
+		}
+	}
+	return nil
+}
+
+func (s *memFirstSeenStore) Has(domain string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.seen[domain]
+	return ok
+}
+
+// newNRDPolicy returns a policy engine whose NRD tracking started 60 days
+// ago and has seen example.com since then.
+func newNRDPolicy(t *testing.T, action filtering.Action) (*filtering.PolicyEngine, *memFirstSeenStore) {
+	t.Helper()
+	return newNRDPolicyWithLogger(t, action, nil)
+}
+
+// newNRDPolicyWithLogger is newNRDPolicy logging to logger.
+func newNRDPolicyWithLogger(t *testing.T, action filtering.Action, logger *slog.Logger) (*filtering.PolicyEngine, *memFirstSeenStore) {
+	t.Helper()
+	store := &memFirstSeenStore{seen: map[string]time.Time{
+		"example.com": time.Now().Add(-60 * 24 * time.Hour),
+	}}
+	pe := filtering.NewPolicyEngine(filtering.PolicyEngineConfig{
+		Enabled:     true,
+		BlockAction: filtering.ActionBlock,
+		NRD:         &filtering.NRDConfig{Action: action, Window: 30 * 24 * time.Hour, Store: store},
+		Logger:      logger,
+	})
+	// Wait for the stored times to load
+	require.Eventually(t, func() bool {
+		return pe.Evaluate("www.new-domain.com").ListName == "nrd"
+	}, time.Second, 5*time.Millisecond)
+	return pe, store
+}
+
+func TestPolicyEngine_NRDBlocksNewDomains(t *testing.T) {
+	pe, _ := newNRDPolicy(t, filtering.ActionBlock)
+	defer pe.Close()
+
+	result := pe.Evaluate("mail.new-domain.com")
+	assert.Equal(t, filtering.ActionBlock, result.Action)
+	assert.Equal(t, "nrd", result.ListName)
+	assert.Equal(t, "new-domain.com", result.Rule)
+
+	result = pe.Evaluate("cdn.example.com")
+	assert.Equal(t, filtering.ActionAllow, result.Action, "Domains seen long ago are not new")
+	assert.Empty(t, result.ListName)
+}
+
+func TestPolicyEngine_NRDFlagsNewDomains(t *testing.T) {
+	pe, _ := newNRDPolicy(t, filtering.ActionLog)
+	defer pe.Close()
+
+	result := pe.Evaluate("phish.co.uk")
+	assert.Equal(t, filtering.ActionLog, result.Action)
+	assert.Equal(t, "phish.co.uk", result.Rule)
+	assert.Equal(t, uint64(0), pe.Stats().QueriesBlocked)
+}
+
+func TestPolicyEngine_NRDLogsOncePerDomain(t *testing.T) {
+	var buf bytes.Buffer
+	pe, _ := newNRDPolicyWithLogger(t, filtering.ActionLog, slog.New(slog.NewTextHandler(&buf, nil)))
+	defer pe.Close()
+
+	buf.Reset()
+	for _, name := range []string{"phish.co.uk", "www.phish.co.uk", "phish.co.uk"} {
+		assert.Equal(t, filtering.ActionLog, pe.Evaluate(name).Action, name)
+	}
+	assert.Equal(t, 1, strings.Count(buf.String(), "Newly registered domain"))
+}
+
+func TestPolicyEngine_NRDIgnoresLocalNames(t *testing.T) {
+	pe, store := newNRDPolicy(t, filtering.ActionBlock)
+
+	for _, name := range []string{"printer.lan", "nas.home.arpa", "localhost", "com"} {
+		assert.Equal(t, filtering.ActionAllow, pe.Evaluate(name).Action, name)
+	}
+	require.NoError(t, pe.Close())
+	assert.False(t, store.Has("printer.lan"))
+	assert.False(t, store.Has("home.arpa"))
+}
+
+func TestPolicyEngine_NRDStoresFirstSeenOnClose(t *testing.T) {
+	pe, store := newNRDPolicy(t, filtering.ActionBlock)
+	pe.Evaluate("www.other.org")
+
+	require.NoError(t, pe.Close())
+	assert.True(t, store.Has("new-domain.com"))
+	assert.True(t, store.Has("other.org"))
+}
+
+func TestPolicyEngine_NRDLearningPeriod(t *testing.T) {
+	// Nothing stored yet: tracking starts now, so no domain is judged new
+	store := &memFirstSeenStore{seen: map[string]time.Time{}}
+	pe := filtering.NewPolicyEngine(filtering.PolicyEngineConfig{
+		Enabled:     true,
+		BlockAction: filtering.ActionBlock,
+		NRD:         &filtering.NRDConfig{Action: filtering.ActionBlock, Window: time.Hour, Store: store},
+	})
+
+	assert.Never(t, func() bool {
+		return pe.Evaluate("brand-new.com").Action == filtering.ActionBlock
+	}, 50*time.Millisecond, 5*time.Millisecond)
+	require.NoError(t, pe.Close())
+	assert.True(t, store.Has("brand-new.com"), "Domains are recorded while learning")
+}
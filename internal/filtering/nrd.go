@@ -0,0 +1,231 @@
+package filtering
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// FirstSeenStore persists when each registrable domain was first queried, so
+// newly registered domain (NRD) tracking survives restarts.
+type FirstSeenStore interface {
+	LoadFirstSeen(ctx context.Context) (map[string]time.Time, error)
+	// RecordFirstSeen stores first-seen times, keeping any earlier time
+	// already stored for a domain.
+	RecordFirstSeen(ctx context.Context, firstSeen map[string]time.Time) error
+}
+
+// NRDConfig configures newly registered domain tracking.
+//
+// Domains are tracked by registrable name (eTLD+1, e.g. example.co.uk): a
+// query for any name under a registrable domain first seen less than Window
+// ago gets Action. Until tracking has been running for Window, every domain
+// looks new, so nothing is flagged during that learning period.
+type NRDConfig struct {
+	// Action is ActionBlock to block new domains or ActionLog to only log
+	// them.
+	Action Action
+	// Window is how long a domain counts as new after it was first seen.
+	Window time.Duration
+	// Store persists first-seen times. If nil, tracking is in memory only.
+	Store FirstSeenStore
+}
+
+const (
+	// maxNRDDomains bounds the first-seen map; further domains are not
+	// tracked (and never count as new).
+	maxNRDDomains = 1 << 20
+	// nrdFlushInterval is how often new first-seen times are stored.
+	nrdFlushInterval = 30 * time.Second
+)
+
+// nrdTracker remembers when registrable domains were first queried.
+type nrdTracker struct {
+	action Action
+	window time.Duration
+	store  FirstSeenStore
+
+	// loaded is set once stored first-seen times are in firstSeen; until
+	// then every domain would look new, so none is judged.
+	loaded atomic.Bool
+
+	mu        sync.Mutex
+	since     time.Time        // when tracking started
+	firstSeen map[string]int64 // Unix seconds by registrable domain
+	pending   map[string]time.Time
+}
+
+func newNRDTracker(cfg NRDConfig) *nrdTracker {
+	return &nrdTracker{
+		action:    cfg.Action,
+		window:    cfg.Window,
+		store:     cfg.Store,
+		since:     time.Now(),
+		firstSeen: make(map[string]int64),
+		pending:   make(map[string]time.Time),
+	}
+}
+
+// load reads stored first-seen times. Tracking started at the earliest one.
+func (t *nrdTracker) load(ctx context.Context) error {
+	if t.store == nil {
+		t.loaded.Store(true)
+		return nil
+	}
+	stored, err := t.store.LoadFirstSeen(ctx)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	for domain, seen := range stored {
+		if cur, ok := t.firstSeen[domain]; !ok || seen.Unix() < cur {
+			t.firstSeen[domain] = seen.Unix()
+		}
+		if seen.Before(t.since) {
+			t.since = seen
+		}
+	}
+	t.mu.Unlock()
+	t.loaded.Store(true)
+	return nil
+}
+
+// observe records a query for domain and reports whether its registrable
+// domain is new, and that registrable domain. recorded is true for the
+// query that first recorded the registrable domain.
+func (t *nrdTracker) observe(domain string) (key string, isNew, recorded bool) {
+	if !t.loaded.Load() {
+		return "", false, false
+	}
+	key = registrableDomain(domain)
+	if key == "" {
+		return "", false, false
+	}
+
+	now := time.Now()
+	t.mu.Lock()
+	first, ok := t.firstSeen[key]
+	if !ok {
+		first = now.Unix()
+		if len(t.firstSeen) < maxNRDDomains {
+			t.firstSeen[key] = first
+			t.pending[key] = now
+			recorded = true
+		}
+	}
+	learning := now.Before(t.since.Add(t.window))
+	t.mu.Unlock()
+
+	if learning {
+		return key, false, recorded
+	}
+	return key, now.Sub(time.Unix(first, 0)) < t.window, recorded
+}
+
+// peek is like observe but does not record domain as seen. A domain never
+// seen before is reported as new (outside the learning window), as observe
+// would on its first query.
+func (t *nrdTracker) peek(domain string) (string, bool) {
+	if !t.loaded.Load() {
+		return "", false
+	}
+	key := registrableDomain(domain)
+	if key == "" {
+		return "", false
+	}
+
+	now := time.Now()
+	t.mu.Lock()
+	first, ok := t.firstSeen[key]
+	t.mu.Unlock()
+	if now.Before(t.since.Add(t.window)) {
+		return key, false
+	}
+	return key, !ok || now.Sub(time.Unix(first, 0)) < t.window
+}
+
+// flush stores the first-seen times recorded since the last flush. On
+// failure they are kept for the next attempt.
+func (t *nrdTracker) flush(ctx context.Context) error {
+	if t.store == nil {
+		return nil
+	}
+	t.mu.Lock()
+	if len(t.pending) == 0 {
+		t.mu.Unlock()
+		return nil
+	}
+	batch := t.pending
+	t.pending = make(map[string]time.Time)
+	t.mu.Unlock()
+
+	if err := t.store.RecordFirstSeen(ctx, batch); err != nil {
+		t.mu.Lock()
+		for domain, seen := range batch {
+			if _, ok := t.pending[domain]; !ok {
+				t.pending[domain] = seen
+			}
+		}
+		t.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// registrableDomain returns the eTLD+1 of domain, or "" for names under
+// a suffix that is not publicly registrable (such as home.arpa or a private
+// single-label TLD) and for public suffixes themselves.
+func registrableDomain(domain string) string {
+	domain = normalizeDomain(domain)
+	if domain == "" {
+		return ""
+	}
+	suffix, icann := publicsuffix.PublicSuffix(domain)
+	if !icann && !strings.Contains(suffix, ".") {
+		// Unknown TLD: not on the public internet
+		return ""
+	}
+	if strings.HasSuffix(domain, ".arpa") {
+		return ""
+	}
+	key, err := publicsuffix.EffectiveTLDPlusOne(domain)
+	if err != nil {
+		return ""
+	}
+	return key
+}
+
+// runNRD loads the stored first-seen times and stores new ones periodically
+// until ctx is canceled, then stores the rest.
+func (pe *PolicyEngine) runNRD(ctx context.Context, done chan<- struct{}) {
+	defer close(done)
+	t := pe.nrd
+
+	if err := t.load(ctx); err != nil {
+		pe.logger.Warn("Failed to load first-seen domains; NRD tracking disabled", "error", err)
+		return
+	}
+
+	ticker := time.NewTicker(nrdFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := t.flush(ctx); err != nil {
+				pe.logger.Warn("Failed to store first-seen domains", "error", err)
+			}
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := t.flush(flushCtx); err != nil {
+				pe.logger.Warn("Failed to store first-seen domains", "error", err)
+			}
+			cancel()
+			return
+		}
+	}
+}
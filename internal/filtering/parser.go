@@ -2,7 +2,10 @@ package filtering
 
 import (
 	"bufio"
+	"bytes"
+	"compress/gzip"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -31,6 +34,56 @@ const (
 	FormatAdblock
 )
 
+// ParseListFormat converts a configured format name ("auto", "domains",
+// "hosts", "adblock") to a ListFormat. An empty name means auto-detect.
+func ParseListFormat(name string) (ListFormat, bool) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "auto":
+		return FormatAuto, true
+	case "domains":
+		return FormatDomains, true
+	case "hosts":
+		return FormatHosts, true
+	case "adblock":
+		return FormatAdblock, true
+	default:
+		return FormatAuto, false
+	}
+}
+
+// Default HTTP settings for fetching remote blocklists.
+const (
+	// DefaultFetchTimeout bounds a whole blocklist download, so a hung server
+	// cannot stall a load or refresh.
+	DefaultFetchTimeout = 60 * time.Second
+	// DefaultFetchRetries is the number of retries after a failed download.
+	DefaultFetchRetries = 2
+	// DefaultFetchRetryDelay is the delay before the first retry; it doubles
+	// for each further retry.
+	DefaultFetchRetryDelay = time.Second
+	// DefaultMaxListSize is the largest blocklist accepted, in bytes after
+	// decompression.
+	DefaultMaxListSize = 64 << 20
+)
+
+// ErrListTooLarge is returned when a blocklist exceeds Parser.MaxSize.
+var ErrListTooLarge = errors.New("blocklist exceeds maximum size")
+
+// CachedList is a previously downloaded blocklist with the HTTP validators
+// needed to ask the server whether it changed.
+type CachedList struct {
+	ETag         string
+	LastModified string
+	Body         []byte
+}
+
+// FetchCache stores downloaded blocklists by URL so unchanged lists are
+// revalidated with a conditional request instead of downloaded again.
+type FetchCache interface {
+	GetCachedList(ctx context.Context, url string) (CachedList, bool, error)
+	PutCachedList(ctx context.Context, url string, list CachedList) error
+}
+
 // Parser provides methods to parse various blocklist formats.
 type Parser struct {
 	// IgnoreComments determines whether to skip comment lines.
@@ -39,6 +92,18 @@ type Parser struct {
 	TrimWhitespace bool
 	// Timeout is the HTTP request timeout in milliseconds. Default is 60000 (60s).
 	Timeout int
+	// MaxRetries is the number of retries after a network error or a
+	// 429/5xx response. Negative disables retries.
+	MaxRetries int
+	// RetryDelay is the delay before the first retry, doubled for each
+	// further retry.
+	RetryDelay time.Duration
+	// MaxSize is the largest accepted blocklist in bytes, applied after
+	// decompression. Zero means DefaultMaxListSize.
+	MaxSize int64
+	// Cache, if set, enables conditional fetches with ETag/If-Modified-Since.
+	// Cache errors are not fatal; the list is then downloaded in full.
+	Cache FetchCache
 }
 
 // NewParser creates a new parser with default settings.
@@ -46,7 +111,10 @@ func NewParser() *Parser {
 	return &Parser{
 		IgnoreComments: true,
 		TrimWhitespace: true,
-		Timeout:        60000, // 60 seconds default
+		Timeout:        int(DefaultFetchTimeout / time.Millisecond),
+		MaxRetries:     DefaultFetchRetries,
+		RetryDelay:     DefaultFetchRetryDelay,
+		MaxSize:        DefaultMaxListSize,
 	}
 }
 
@@ -56,6 +124,7 @@ func (p *Parser) SetTimeout(ms int) {
 }
 
 // ParseFile parses a blocklist file and returns a trie containing the domains.
+// Gzip-compressed files are decompressed transparently.
 func (p *Parser) ParseFile(path string, format ListFormat) (*DomainTrie, error) {
 	file, err := os.Open(path)
 	if err != nil {
@@ -63,36 +132,173 @@ func (p *Parser) ParseFile(path string, format ListFormat) (*DomainTrie, error)
 	}
 	defer file.Close()
 
-	return p.Parse(file, format)
+	return p.parseBody(file, format)
 }
 
 // ParseURL fetches and parses a blocklist from a URL.
 func (p *Parser) ParseURL(url string, format ListFormat) (*DomainTrie, error) {
+	return p.ParseURLContext(context.Background(), url, format)
+}
+
+// ParseURLContext fetches and parses a blocklist from a URL, aborting when
+// ctx is canceled.
+//
+// Failed downloads are retried (see MaxRetries). With a Cache, the request
+// carries the validators of the cached copy and a 304 Not Modified response
+// is answered from the cache. Gzip-compressed lists are decompressed,
+// whether sent with Content-Encoding or as a .gz file. Built-in lists (see
+// BuiltinScheme) are parsed from the binary.
+func (p *Parser) ParseURLContext(ctx context.Context, url string, format ListFormat) (*DomainTrie, error) {
+	body, err := p.fetch(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return p.parseBody(bytes.NewReader(body), format)
+}
+
+// fetch downloads url, retrying failed attempts, and returns the list body.
+// Built-in lists are returned without a download.
+func (p *Parser) fetch(ctx context.Context, url string) ([]byte, error) {
+	if body, ok := builtinList(url); ok {
+		return body, nil
+	}
 	timeout := time.Duration(p.Timeout) * time.Millisecond
 	if timeout <= 0 {
-		timeout = 60 * time.Second
+		timeout = DefaultFetchTimeout
 	}
+	client := &http.Client{Timeout: timeout}
 
-	client := &http.Client{
-		Timeout: timeout,
+	var cached CachedList
+	var haveCached bool
+	if p.Cache != nil {
+		// A failed cache lookup only costs a full download
+		cached, haveCached, _ = p.Cache.GetCachedList(ctx, url)
 	}
 
-	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
+	delay := p.RetryDelay
+	var lastErr error
+	for attempt := 0; attempt <= max(p.MaxRetries, 0); attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(delay):
+			}
+			delay *= 2
+		}
+
+		body, retry, err := p.fetchOnce(ctx, client, url, cached, haveCached)
+		if err == nil {
+			return body, nil
+		}
+		lastErr = err
+		if !retry || ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, lastErr
+}
+
+// fetchOnce makes one request for url and reports whether a failure is
+// worth retrying.
+func (p *Parser) fetchOnce(
+	ctx context.Context,
+	client *http.Client,
+	url string,
+	cached CachedList,
+	haveCached bool,
+) ([]byte, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to build request: %w", err)
+		return nil, false, fmt.Errorf("failed to build request: %w", err)
+	}
+	if haveCached {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
 	}
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch URL: %w", err)
+		return nil, true, fmt.Errorf("failed to fetch URL: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP error: %s", resp.Status)
+	switch {
+	case resp.StatusCode == http.StatusNotModified && haveCached:
+		return cached.Body, false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError:
+		return nil, true, fmt.Errorf("HTTP error: %s", resp.Status)
+	case resp.StatusCode != http.StatusOK:
+		return nil, false, fmt.Errorf("HTTP error: %s", resp.Status)
 	}
 
-	return p.Parse(resp.Body, format)
+	// The transport already undid any Content-Encoding; a .gz list is kept
+	// compressed here and decompressed while parsing
+	body, err := io.ReadAll(limitReader(resp.Body, p.maxSize()))
+	if err != nil {
+		return nil, !errors.Is(err, ErrListTooLarge), fmt.Errorf("failed to read response: %w", err)
+	}
+
+	etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if p.Cache != nil && (etag != "" || lastModified != "") {
+		// Best effort: without a cached copy the next fetch is unconditional
+		_ = p.Cache.PutCachedList(ctx, url, CachedList{ETag: etag, LastModified: lastModified, Body: body})
+	}
+	return body, false, nil
+}
+
+// parseBody parses a list that may be gzip-compressed.
+func (p *Parser) parseBody(r io.Reader, format ListFormat) (*DomainTrie, error) {
+	br := bufio.NewReader(r)
+	var src io.Reader = br
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress list: %w", err)
+		}
+		defer zr.Close()
+		src = zr
+	}
+	return p.Parse(limitReader(src, p.maxSize()), format)
+}
+
+func (p *Parser) maxSize() int64 {
+	if p.MaxSize <= 0 {
+		return DefaultMaxListSize
+	}
+	return p.MaxSize
+}
+
+// limitReader returns a reader that fails with ErrListTooLarge once more
+// than n bytes have been read from r.
+func limitReader(r io.Reader, n int64) io.Reader {
+	return &sizeLimitedReader{r: r, remaining: n}
+}
+
+type sizeLimitedReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (l *sizeLimitedReader) Read(b []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, ErrListTooLarge
+	}
+	// Read one byte past the limit to tell a list of exactly n bytes apart
+	// from a longer one
+	if int64(len(b)) > l.remaining+1 {
+		b = b[:l.remaining+1]
+	}
+	n, err := l.r.Read(b)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return 0, ErrListTooLarge
+	}
+	return n, err
 }
 
 // Parse parses a blocklist from a reader.
@@ -59,18 +59,59 @@ type PolicyEngine struct {
 	queriesTotal   atomic.Uint64
 	queriesBlocked atomic.Uint64
 	queriesAllowed atomic.Uint64
+	blocks         blockStats
+
+	// sourceNames names the sources of blacklist entries (see
+	// DomainTrie.AddSource): the blacklist itself, then the blocklists in
+	// configured order. It does not change after NewPolicyEngine.
+	sourceNames []string
 
 	// List metadata
 	listSources map[string]ListSource
+	// listsLoaded is closed once every configured blocklist has been
+	// fetched for the first time, successfully or not.
+	listsLoaded chan struct{}
 	mu          sync.RWMutex
 
+	// Temporary allows by domain with their expiry, guarded by mu.
+	// hasTempAllow is set while tempAllow is non-empty, so Evaluate can
+	// skip the lock.
+	tempAllow    map[string]time.Time
+	hasTempAllow atomic.Bool
+
+	// Temporary blocks (e.g. from threat intelligence) by domain, guarded
+	// by mu, with hasTempBlock as the lock-free fast path.
+	tempBlock    map[string]tempBlock
+	hasTempBlock atomic.Bool
+
+	// Newly registered domain tracking; nil when disabled. nrdDone is
+	// closed when its background loop has stored everything.
+	nrd     *nrdTracker
+	nrdDone chan struct{}
+
 	// Configuration
 	enabled       atomic.Bool
+	blockTTL      atomic.Uint32
+	negativeTTL   atomic.Uint32
 	blockAction   Action
 	logBlocked    bool
 	logAllowed    bool
 	refreshTicker *time.Ticker
 	refreshStop   chan struct{}
+
+	// fetchCtx is canceled by Close to abort in-flight blocklist downloads
+	fetchCtx    context.Context
+	cancelFetch context.CancelFunc
+}
+
+// blacklistSource is the source of blacklist entries added directly rather
+// than from a blocklist.
+const blacklistSource = 0
+
+// tempBlock is a BlockFor entry.
+type tempBlock struct {
+	expiry   time.Time
+	listName string
 }
 
 // ListSource tracks metadata about a blocklist source.
@@ -100,18 +141,34 @@ type PolicyEngineConfig struct {
 	// LogAllowed enables logging of allowed queries (verbose).
 	LogAllowed bool
 
+	// BlockTTL and NegativeTTL are the TTLs, in seconds, of block page
+	// answers and of blocked negative answers. Zero leaves the choice to
+	// the resolver answering blocked queries.
+	BlockTTL    uint32
+	NegativeTTL uint32
+
 	// WhitelistDomains is a list of domains to always allow.
 	WhitelistDomains []string
 
 	// BlacklistDomains is a list of domains to always block.
 	BlacklistDomains []string
 
+	// TemporaryAllows maps domains to allow until a time (see AllowUntil).
+	TemporaryAllows map[string]time.Time
+
 	// BlocklistURLs is a list of remote blocklists to fetch.
 	BlocklistURLs []BlocklistURL
 
 	// RefreshInterval is how often to refresh remote blocklists.
 	// Zero means no automatic refresh.
 	RefreshInterval time.Duration
+
+	// FetchCache, if set, stores downloaded blocklists so unchanged lists are
+	// revalidated instead of downloaded again (see Parser.Cache).
+	FetchCache FetchCache
+
+	// NRD, if set, flags or blocks domains first seen recently.
+	NRD *NRDConfig
 }
 
 // BlocklistURL represents a remote blocklist configuration.
@@ -133,30 +190,53 @@ func NewPolicyEngine(cfg PolicyEngineConfig) *PolicyEngine {
 		whitelist:   NewDomainTrie(),
 		blacklist:   NewDomainTrie(),
 		listSources: make(map[string]ListSource),
+		listsLoaded: make(chan struct{}),
+		tempAllow:   make(map[string]time.Time),
+		tempBlock:   make(map[string]tempBlock),
 		blockAction: cfg.BlockAction,
 		logBlocked:  cfg.LogBlocked,
 		logAllowed:  cfg.LogAllowed,
+		sourceNames: []string{"blacklist"},
+	}
+	for _, bl := range cfg.BlocklistURLs {
+		pe.sourceNames = append(pe.sourceNames, bl.Name)
 	}
 	pe.enabled.Store(cfg.Enabled)
+	pe.SetBlockTTLs(cfg.BlockTTL, cfg.NegativeTTL)
+	pe.fetchCtx, pe.cancelFetch = context.WithCancel(context.Background())
 
 	// Add configured whitelist domains
 	parser := NewParser()
+	parser.Cache = cfg.FetchCache
 	if len(cfg.WhitelistDomains) > 0 {
 		for _, domain := range cfg.WhitelistDomains {
 			pe.whitelist.Add(domain, true)
 		}
 	}
 
+	for domain, expiry := range cfg.TemporaryAllows {
+		pe.AllowUntil(domain, expiry)
+	}
+
 	// Add configured blacklist domains
 	if len(cfg.BlacklistDomains) > 0 {
 		for _, domain := range cfg.BlacklistDomains {
-			pe.blacklist.Add(domain, true)
+			pe.blacklist.AddSource(domain, true, blacklistSource)
 		}
 	}
 
 	// Fetch remote blocklists (in background for startup speed)
 	if len(cfg.BlocklistURLs) > 0 {
 		go pe.loadBlocklists(parser, cfg.BlocklistURLs)
+	} else {
+		close(pe.listsLoaded)
+	}
+
+	// Track first-seen domains (loads stored times in the background)
+	if cfg.NRD != nil && cfg.NRD.Window > 0 {
+		pe.nrd = newNRDTracker(*cfg.NRD)
+		pe.nrdDone = make(chan struct{})
+		go pe.runNRD(pe.fetchCtx, pe.nrdDone)
 	}
 
 	// Start refresh timer if configured
@@ -171,37 +251,57 @@ func NewPolicyEngine(cfg PolicyEngineConfig) *PolicyEngine {
 
 // loadBlocklists fetches and parses all configured blocklists.
 func (pe *PolicyEngine) loadBlocklists(parser *Parser, urls []BlocklistURL) {
-	for _, bl := range urls {
-		pe.loadBlocklist(parser, bl)
+	defer close(pe.listsLoaded)
+	for i, bl := range urls {
+		pe.loadBlocklist(parser, bl, blocklistSource(i))
+	}
+}
+
+// BlocklistsLoaded reports whether every configured blocklist has been
+// fetched at least once, successfully or not. Until then, domains on lists
+// still loading are not blocked.
+func (pe *PolicyEngine) BlocklistsLoaded() bool {
+	select {
+	case <-pe.listsLoaded:
+		return true
+	default:
+		return false
 	}
 }
 
-// loadBlocklist fetches and parses a single blocklist.
-func (pe *PolicyEngine) loadBlocklist(parser *Parser, bl BlocklistURL) {
-	source := ListSource{
+// blocklistSource returns the source of the entries of the i-th configured
+// blocklist (see PolicyEngine.sourceNames).
+func blocklistSource(i int) int {
+	return i + 1
+}
+
+// loadBlocklist fetches and parses a single blocklist, adding its domains
+// to the blacklist as source.
+func (pe *PolicyEngine) loadBlocklist(parser *Parser, bl BlocklistURL, source int) {
+	info := ListSource{
 		Name:       bl.Name,
 		URL:        bl.URL,
 		Format:     bl.Format,
 		LastUpdate: time.Now(),
 	}
 
-	trie, err := parser.ParseURL(bl.URL, bl.Format)
+	trie, err := parser.ParseURLContext(pe.fetchCtx, bl.URL, bl.Format)
 	if err != nil {
-		source.LastError = err
+		info.LastError = err
 		pe.logger.Warn("Failed to load blocklist",
 			"name", bl.Name,
 			"url", bl.URL,
 			"error", err)
 	} else {
-		source.DomainCount = trie.Size()
-		pe.blacklist.Merge(trie)
+		info.DomainCount = trie.Size()
+		pe.blacklist.MergeSource(trie, source)
 		pe.logger.Info("Loaded blocklist",
 			"name", bl.Name,
 			"domains", trie.Size())
 	}
 
 	pe.mu.Lock()
-	pe.listSources[bl.Name] = source
+	pe.listSources[bl.Name] = info
 	pe.mu.Unlock()
 }
 
@@ -218,15 +318,15 @@ func (pe *PolicyEngine) refreshLoop(parser *Parser, urls []BlocklistURL) {
 			// (We don't track them separately, so we can't restore them here.
 			// In a production system, you'd want to track static vs dynamic entries.)
 
-			for _, bl := range urls {
-				trie, err := parser.ParseURL(bl.URL, bl.Format)
+			for i, bl := range urls {
+				trie, err := parser.ParseURLContext(pe.fetchCtx, bl.URL, bl.Format)
 				if err != nil {
 					pe.logger.Warn("Failed to refresh blocklist",
 						"name", bl.Name,
 						"error", err)
 					continue
 				}
-				newBlacklist.Merge(trie)
+				newBlacklist.MergeSource(trie, blocklistSource(i))
 			}
 
 			pe.mu.Lock()
@@ -264,16 +364,63 @@ func (pe *PolicyEngine) Evaluate(domain string) PolicyResult {
 		}
 	}
 
+	if pe.temporarilyAllowed(domain) {
+		pe.queriesAllowed.Add(1)
+		return PolicyResult{
+			Action:   ActionAllow,
+			Rule:     domain,
+			ListName: "temporary",
+		}
+	}
+
 	// Check blacklist
-	if pe.blacklist.Contains(domain) {
+	if sources, ok := pe.blacklist.Lookup(domain); ok {
+		lists := pe.listNames(sources)
+		pe.queriesBlocked.Add(1)
+		pe.blocks.record(normalizeDomain(domain), lists)
+		if pe.logBlocked {
+			pe.logger.Info("Domain blocked", "domain", domain, "list", lists[0])
+		}
+		return PolicyResult{
+			Action:   pe.blockAction,
+			Rule:     domain,
+			ListName: lists[0],
+		}
+	}
+
+	if listName, ok := pe.temporarilyBlocked(domain); ok {
 		pe.queriesBlocked.Add(1)
+		pe.blocks.record(normalizeDomain(domain), []string{listName})
 		if pe.logBlocked {
-			pe.logger.Info("Domain blocked", "domain", domain)
+			pe.logger.Info("Domain blocked", "domain", domain, "list", listName)
 		}
 		return PolicyResult{
 			Action:   pe.blockAction,
 			Rule:     domain,
-			ListName: "blacklist",
+			ListName: listName,
+		}
+	}
+
+	if pe.nrd != nil {
+		if key, isNew, recorded := pe.nrd.observe(domain); isNew {
+			if pe.nrd.action == ActionBlock {
+				pe.queriesBlocked.Add(1)
+				pe.blocks.record(normalizeDomain(domain), []string{"nrd"})
+				if pe.logBlocked {
+					pe.logger.Info("Domain blocked", "domain", domain, "list", "nrd")
+				}
+				return PolicyResult{Action: pe.blockAction, Rule: key, ListName: "nrd"}
+			}
+			pe.queriesAllowed.Add(1)
+			// Logged once per registrable domain; later queries in the
+			// window only when allowed queries are logged
+			switch {
+			case recorded:
+				pe.logger.Info("Newly registered domain", "domain", domain, "registered_domain", key)
+			case pe.logAllowed:
+				pe.logger.Debug("Newly registered domain", "domain", domain, "registered_domain", key)
+			}
+			return PolicyResult{Action: ActionLog, Rule: key, ListName: "nrd"}
 		}
 	}
 
@@ -292,14 +439,161 @@ func (pe *PolicyEngine) EvaluateWithContext(ctx context.Context, domain string)
 	}
 }
 
+// Decision reasons (see Decision.Reason).
+const (
+	ReasonDisabled       = "disabled"
+	ReasonWhitelist      = "whitelist"
+	ReasonTemporaryAllow = "temporary_allow"
+	ReasonBlacklist      = "blacklist"
+	ReasonTemporaryBlock = "temporary_block"
+	ReasonNRD            = "nrd"
+	ReasonNoMatch        = "no_match"
+)
+
+// Decision explains how the policy treats a domain.
+type Decision struct {
+	PolicyResult
+	// Reason is the step of the policy that decided, one of the Reason
+	// constants.
+	Reason string
+	// Lists names every list blocking the domain (the blacklist and
+	// blocklists), even when an earlier step such as the whitelist decided.
+	Lists []string
+}
+
+// Check reports how Evaluate would treat domain, without counting the query
+// or recording it as seen for newly registered domain detection.
+func (pe *PolicyEngine) Check(domain string) Decision {
+	var lists []string
+	sources, listed := pe.blacklist.Lookup(domain)
+	if listed {
+		lists = pe.listNames(sources)
+	}
+	decide := func(reason string, result PolicyResult) Decision {
+		return Decision{PolicyResult: result, Reason: reason, Lists: lists}
+	}
+
+	if !pe.enabled.Load() {
+		return decide(ReasonDisabled, PolicyResult{Action: ActionAllow})
+	}
+	if pe.whitelist.Contains(domain) {
+		return decide(ReasonWhitelist, PolicyResult{Action: ActionAllow, Rule: domain, ListName: "whitelist"})
+	}
+	if pe.temporarilyAllowed(domain) {
+		return decide(ReasonTemporaryAllow, PolicyResult{Action: ActionAllow, Rule: domain, ListName: "temporary"})
+	}
+	if listed {
+		return decide(ReasonBlacklist, PolicyResult{Action: pe.blockAction, Rule: domain, ListName: lists[0]})
+	}
+	if listName, ok := pe.temporarilyBlocked(domain); ok {
+		return decide(ReasonTemporaryBlock, PolicyResult{Action: pe.blockAction, Rule: domain, ListName: listName})
+	}
+	if pe.nrd != nil {
+		if key, isNew := pe.nrd.peek(domain); isNew {
+			action := ActionLog
+			if pe.nrd.action == ActionBlock {
+				action = pe.blockAction
+			}
+			return decide(ReasonNRD, PolicyResult{Action: action, Rule: key, ListName: "nrd"})
+		}
+	}
+	return decide(ReasonNoMatch, PolicyResult{Action: ActionAllow})
+}
+
 // AddToWhitelist adds a domain to the whitelist.
 func (pe *PolicyEngine) AddToWhitelist(domain string) {
 	pe.whitelist.Add(domain, true)
 }
 
+// AllowFor allows domain (but not its subdomains) for duration d, even if it
+// is blacklisted, and returns when the allow expires. Temporary allows are
+// not persisted. An invalid domain is ignored and yields the zero time.
+func (pe *PolicyEngine) AllowFor(domain string, d time.Duration) time.Time {
+	expiry := time.Now().Add(d)
+	if !pe.AllowUntil(domain, expiry) {
+		return time.Time{}
+	}
+	return expiry
+}
+
+// AllowUntil allows domain (but not its subdomains) until expiry, like
+// AllowFor. It reports false for an invalid domain, which is ignored.
+func (pe *PolicyEngine) AllowUntil(domain string, expiry time.Time) bool {
+	domain = normalizeDomain(domain)
+	if domain == "" {
+		return false
+	}
+
+	now := time.Now()
+	pe.mu.Lock()
+	defer pe.mu.Unlock()
+
+	for name, e := range pe.tempAllow {
+		if !now.Before(e) {
+			delete(pe.tempAllow, name)
+		}
+	}
+	pe.tempAllow[domain] = expiry
+	pe.hasTempAllow.Store(true)
+	return true
+}
+
+// temporarilyAllowed reports whether domain has an unexpired AllowFor entry.
+func (pe *PolicyEngine) temporarilyAllowed(domain string) bool {
+	if !pe.hasTempAllow.Load() {
+		return false
+	}
+	domain = normalizeDomain(domain)
+
+	pe.mu.RLock()
+	expiry, ok := pe.tempAllow[domain]
+	pe.mu.RUnlock()
+	return ok && time.Now().Before(expiry)
+}
+
+// BlockFor blocks domain (but not its subdomains) for duration d, reporting
+// listName as the matching list. The whitelist and temporary allows still
+// take priority. Temporary blocks are not persisted. An invalid domain is
+// ignored.
+func (pe *PolicyEngine) BlockFor(domain string, d time.Duration, listName string) {
+	domain = normalizeDomain(domain)
+	if domain == "" {
+		return
+	}
+
+	now := time.Now()
+	pe.mu.Lock()
+	defer pe.mu.Unlock()
+
+	for name, b := range pe.tempBlock {
+		if !now.Before(b.expiry) {
+			delete(pe.tempBlock, name)
+		}
+	}
+	pe.tempBlock[domain] = tempBlock{expiry: now.Add(d), listName: listName}
+	pe.hasTempBlock.Store(true)
+}
+
+// temporarilyBlocked returns the list name of domain's unexpired BlockFor
+// entry, if any.
+func (pe *PolicyEngine) temporarilyBlocked(domain string) (string, bool) {
+	if !pe.hasTempBlock.Load() {
+		return "", false
+	}
+	domain = normalizeDomain(domain)
+
+	pe.mu.RLock()
+	b, ok := pe.tempBlock[domain]
+	pe.mu.RUnlock()
+	if !ok || !time.Now().Before(b.expiry) {
+		return "", false
+	}
+	return b.listName, true
+}
+
 // AddToBlacklist adds a domain to the blacklist.
 func (pe *PolicyEngine) AddToBlacklist(domain string) {
-	pe.blacklist.Add(domain, true)
+	pe.blacklist.AddSource(domain, true, blacklistSource)
 }
 
 // RemoveFromWhitelist removes a domain from the whitelist.
@@ -324,6 +618,31 @@ func (pe *PolicyEngine) Stats() PolicyStats {
 	}
 }
 
+// BlockStats returns how many queries each list blocked, most blocking
+// first, with up to top of its most blocked domains. Lists are the
+// blacklist, the blocklists by name, and the names given to BlockFor and
+// newly registered domain blocking ("nrd"). A query matching several lists
+// counts for each of them.
+func (pe *PolicyEngine) BlockStats(top int) []ListBlockStats {
+	return pe.blocks.snapshot(top)
+}
+
+// listNames returns the names of the blacklist sources in sources, the
+// first being the one reported as the matching list. Entries without a
+// tracked source are attributed to the blacklist.
+func (pe *PolicyEngine) listNames(sources uint64) []string {
+	var names []string
+	for i, name := range pe.sourceNames {
+		if sources&sourceBit(i) != 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return []string{pe.sourceNames[blacklistSource]}
+	}
+	return names
+}
+
 // PolicyStats contains filtering statistics.
 type PolicyStats struct {
 	QueriesTotal   uint64
@@ -351,8 +670,25 @@ func (pe *PolicyEngine) SetEnabled(enabled bool) {
 	pe.enabled.Store(enabled)
 }
 
-// Close stops any background goroutines.
+// SetBlockTTLs sets the TTLs of block page answers and of blocked negative
+// answers, in seconds. Queries answered afterwards use the new TTLs.
+func (pe *PolicyEngine) SetBlockTTLs(blockTTL, negativeTTL uint32) {
+	pe.blockTTL.Store(blockTTL)
+	pe.negativeTTL.Store(negativeTTL)
+}
+
+// BlockTTLs returns the TTLs of block page answers and of blocked negative
+// answers, in seconds.
+func (pe *PolicyEngine) BlockTTLs() (blockTTL, negativeTTL uint32) {
+	return pe.blockTTL.Load(), pe.negativeTTL.Load()
+}
+
+// Close stops any background goroutines and aborts blocklist downloads.
 func (pe *PolicyEngine) Close() error {
+	pe.cancelFetch()
+	if pe.nrdDone != nil {
+		<-pe.nrdDone
+	}
 	if pe.refreshTicker != nil {
 		pe.refreshTicker.Stop()
 	}
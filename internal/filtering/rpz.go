@@ -0,0 +1,107 @@
+package filtering
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/jroosing/hydradns/internal/dns"
+)
+
+// DefaultRPZOrigin is the zone name used for the RPZ feed when none is given.
+const DefaultRPZOrigin = "rpz.hydradns"
+
+// RPZ policy actions, expressed as CNAME targets (see the DNS RPZ draft,
+// draft-vixie-dnsop-dns-rpz).
+const (
+	rpzNXDOMAIN = "."
+	rpzPassthru = "rpz-passthru."
+)
+
+// rpzTTL is the TTL of every record in the feed. Consumers refresh the
+// policy via the SOA timers, so records are kept short-lived.
+const rpzTTL = 60
+
+// WriteRPZ writes the effective policy as a Response Policy Zone in master
+// file format, so other resolvers can enforce the same blocking decisions.
+//
+// Blacklisted domains map to NXDOMAIN, matching how blocked queries are
+// answered, and whitelisted domains map to PASSTHRU. Because RPZ prefers
+// exact matches over wildcards while HydraDNS always lets the whitelist win,
+// blacklist entries the whitelist overrides are left out. When filtering is
+// disabled the zone holds only its SOA and NS records.
+//
+// origin is the zone name (DefaultRPZOrigin if empty) and serial the SOA
+// serial. An invalid origin is reported before anything is written.
+func (pe *PolicyEngine) WriteRPZ(w io.Writer, origin string, serial uint32) error {
+	if origin == "" {
+		origin = DefaultRPZOrigin
+	}
+	origin, err := dns.CanonicalName(origin)
+	if err != nil {
+		return fmt.Errorf("invalid RPZ origin: %w", err)
+	}
+	if origin == "" || !zoneSafe(origin) {
+		return fmt.Errorf("invalid RPZ origin: %q", origin)
+	}
+	if _, err := dns.EncodeName(origin); err != nil {
+		return fmt.Errorf("invalid RPZ origin: %w", err)
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "$ORIGIN %s.\n", origin)
+	fmt.Fprintf(bw, "$TTL %d\n", rpzTTL)
+	fmt.Fprintf(bw, "@ IN SOA localhost. hostmaster.localhost. %d 3600 600 86400 %d\n", serial, rpzTTL)
+	fmt.Fprintln(bw, "@ IN NS localhost.")
+
+	if !pe.enabled.Load() {
+		return bw.Flush()
+	}
+
+	pe.mu.RLock()
+	blacklist := pe.blacklist
+	pe.mu.RUnlock()
+
+	fmt.Fprintln(bw, "\n; whitelist")
+	pe.whitelist.Walk(func(domain string, wildcard bool) {
+		writeRPZRule(bw, domain, wildcard, rpzPassthru)
+	})
+
+	fmt.Fprintln(bw, "\n; blacklist")
+	blacklist.Walk(func(domain string, wildcard bool) {
+		// Whitelist entries always match subdomains, so the whitelist
+		// overrides this rule for the domain and everything below it
+		if pe.whitelist.Contains(domain) {
+			return
+		}
+		writeRPZRule(bw, domain, wildcard, rpzNXDOMAIN)
+	})
+
+	return bw.Flush()
+}
+
+// writeRPZRule writes a QNAME trigger for domain, and for its subdomains when
+// wildcard is set. Owner names are relative to the zone origin.
+func writeRPZRule(w io.Writer, domain string, wildcard bool, target string) {
+	// Blocklists are loosely parsed; skip names that would corrupt the zone
+	if !zoneSafe(domain) {
+		return
+	}
+	fmt.Fprintf(w, "%s CNAME %s\n", domain, target)
+	if wildcard {
+		fmt.Fprintf(w, "*.%s CNAME %s\n", domain, target)
+	}
+}
+
+// zoneSafe reports whether name consists only of hostname characters, which
+// need no escaping in a master file.
+func zoneSafe(name string) bool {
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
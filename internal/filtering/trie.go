@@ -3,9 +3,12 @@
 package filtering
 
 import (
+	"maps"
 	"slices"
 	"strings"
 	"sync"
+
+	"github.com/jroosing/hydradns/internal/dns"
 )
 
 // DomainTrie is a high-performance trie for domain name matching.
@@ -45,8 +48,22 @@ type DomainTrie struct {
 // Memory-optimized: uses a map for sparse children (most nodes have few children).
 type trieNode struct {
 	children map[string]*trieNode
-	isEnd    bool // marks end of a complete domain
-	isWild   bool // marks wildcard match (blocks all subdomains)
+	isEnd    bool   // marks end of a complete domain
+	isWild   bool   // marks wildcard match (blocks all subdomains)
+	sources  uint64 // bit i set when source i added the domain (see AddSource)
+}
+
+// MaxSources is the number of sources a DomainTrie can tell apart (see
+// AddSource). Domains added from higher sources match without one.
+const MaxSources = 64
+
+// sourceBit returns the bit of source in trieNode.sources, or 0 when the
+// trie cannot track it.
+func sourceBit(source int) uint64 {
+	if source < 0 || source >= MaxSources {
+		return 0
+	}
+	return 1 << source
 }
 
 // NewDomainTrie creates an empty domain trie.
@@ -66,6 +83,17 @@ func newTrieNode() *trieNode {
 // The domain should be in standard format (e.g., "ads.example.com").
 // If wildcard is true, all subdomains will also match.
 func (t *DomainTrie) Add(domain string, wildcard bool) {
+	t.add(domain, wildcard, 0)
+}
+
+// AddSource is like Add, and also records source (0 to MaxSources-1) as a
+// source of the domain, as reported by Lookup. Sources let one trie hold
+// several lists while telling which of them contain a domain.
+func (t *DomainTrie) AddSource(domain string, wildcard bool, source int) {
+	t.add(domain, wildcard, sourceBit(source))
+}
+
+func (t *DomainTrie) add(domain string, wildcard bool, sources uint64) {
 	domain = normalizeDomain(domain)
 	if domain == "" {
 		return
@@ -96,6 +124,7 @@ func (t *DomainTrie) Add(domain string, wildcard bool) {
 	if wildcard {
 		node.isWild = true
 	}
+	node.sources |= sources
 }
 
 // Contains checks if a domain matches any entry in the trie.
@@ -110,14 +139,22 @@ func (t *DomainTrie) Add(domain string, wildcard bool) {
 //   - Contains("ads.example.com") -> true
 //   - Contains("sub.ads.example.com") -> false (unless wildcard was set)
 func (t *DomainTrie) Contains(domain string) bool {
+	_, ok := t.Lookup(domain)
+	return ok
+}
+
+// Lookup is like Contains, and also returns the sources (see AddSource) of
+// all matching entries as a bit set: bit i is set when source i added the
+// domain or a wildcard parent of it.
+func (t *DomainTrie) Lookup(domain string) (sources uint64, ok bool) {
 	domain = normalizeDomain(domain)
 	if domain == "" {
-		return false
+		return 0, false
 	}
 
 	labels := reversedLabels(domain)
 	if len(labels) == 0 {
-		return false
+		return 0, false
 	}
 
 	t.mu.RLock()
@@ -127,7 +164,7 @@ func (t *DomainTrie) Contains(domain string) bool {
 	for i, label := range labels {
 		child, exists := node.children[label]
 		if !exists {
-			return false
+			return sources, ok
 		}
 		node = child
 
@@ -135,12 +172,17 @@ func (t *DomainTrie) Contains(domain string) bool {
 		// A wildcard means all subdomains match, so if we're not at the end
 		// of the input domain, a wildcard here means it matches
 		if node.isWild && i < len(labels)-1 {
-			return true
+			sources |= node.sources
+			ok = true
 		}
 	}
 
 	// Exact match at the end
-	return node.isEnd
+	if node.isEnd {
+		sources |= node.sources
+		ok = true
+	}
+	return sources, ok
 }
 
 // Size returns the number of domains in the trie.
@@ -186,6 +228,7 @@ func (t *DomainTrie) Remove(domain string) bool {
 	}
 	node.isEnd = false
 	node.isWild = false
+	node.sources = 0
 	t.size--
 
 	// Cleanup: remove orphan nodes without children and not endpoints
@@ -215,8 +258,19 @@ func (t *DomainTrie) Clear() {
 	t.size = 0
 }
 
-// Merge adds all domains from another trie into this one.
+// Merge adds all domains from another trie into this one, keeping their
+// sources.
 func (t *DomainTrie) Merge(other *DomainTrie) {
+	t.merge(other, 0)
+}
+
+// MergeSource is like Merge, and also records source as a source of every
+// merged domain (see AddSource).
+func (t *DomainTrie) MergeSource(other *DomainTrie, source int) {
+	t.merge(other, sourceBit(source))
+}
+
+func (t *DomainTrie) merge(other *DomainTrie, sources uint64) {
 	if other == nil {
 		return
 	}
@@ -227,10 +281,10 @@ func (t *DomainTrie) Merge(other *DomainTrie) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	t.mergeNode(t.root, other.root, nil)
+	t.mergeNode(t.root, other.root, nil, sources)
 }
 
-func (t *DomainTrie) mergeNode(dst, src *trieNode, path []string) {
+func (t *DomainTrie) mergeNode(dst, src *trieNode, path []string, sources uint64) {
 	for label, srcChild := range src.children {
 		dstChild, exists := dst.children[label]
 		if !exists {
@@ -247,16 +301,70 @@ func (t *DomainTrie) mergeNode(dst, src *trieNode, path []string) {
 		if srcChild.isWild {
 			dstChild.isWild = true
 		}
+		if srcChild.isEnd {
+			dstChild.sources |= srcChild.sources | sources
+		}
+
+		t.mergeNode(dstChild, srcChild, newPath, sources)
+	}
+}
+
+// Walk calls fn for every domain in the trie, with wildcard reporting whether
+// its subdomains match too. Domains are visited in reversed-label order, so
+// a domain is visited before its subdomains.
+//
+// The domains are copied under the read lock and fn is called after it is
+// released, so a slow fn (such as a write to a stalled client) does not
+// block writers or the lookups queued behind them.
+func (t *DomainTrie) Walk(fn func(domain string, wildcard bool)) {
+	type entry struct {
+		domain   string
+		wildcard bool
+	}
+	t.mu.RLock()
+	entries := make([]entry, 0, t.size)
+	walkNode(t.root, nil, func(domain string, wildcard bool) {
+		entries = append(entries, entry{domain, wildcard})
+	})
+	t.mu.RUnlock()
+
+	for _, e := range entries {
+		fn(e.domain, e.wildcard)
+	}
+}
+
+func walkNode(node *trieNode, path []string, fn func(string, bool)) {
+	for _, label := range slices.Sorted(maps.Keys(node.children)) {
+		child := node.children[label]
+		labels := append(path, label)
+		if child.isEnd {
+			fn(joinReversed(labels), child.isWild)
+		}
+		walkNode(child, labels, fn)
+	}
+}
 
-		t.mergeNode(dstChild, srcChild, newPath)
+// joinReversed is the inverse of reversedLabels.
+func joinReversed(labels []string) string {
+	var b strings.Builder
+	for i := len(labels) - 1; i >= 0; i-- {
+		b.WriteString(labels[i])
+		if i > 0 {
+			b.WriteByte('.')
+		}
 	}
+	return b.String()
 }
 
 // normalizeDomain converts a domain to lowercase and removes trailing dots.
+// Unicode (IDN) domains are converted to their A-label form so they match
+// the names seen on the wire; invalid IDNs normalize to "".
 func normalizeDomain(domain string) string {
-	domain = strings.ToLower(strings.TrimSpace(domain))
-	domain = strings.TrimSuffix(domain, ".")
-	return domain
+	c, err := dns.CanonicalName(domain)
+	if err != nil {
+		return ""
+	}
+	return c
 }
 
 // reversedLabels splits a domain into labels in reverse order.
@@ -0,0 +1,134 @@
+// Package geoip looks up the country and autonomous system of IP addresses
+// in MaxMind GeoIP2/GeoLite2 databases (.mmdb files).
+//
+// The country and ASN data ship as separate databases; either may be
+// omitted. A City database can be used in place of a Country database.
+package geoip
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strconv"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// Info is what is known about one address. Fields are empty when the
+// corresponding database is not loaded or has no entry.
+type Info struct {
+	Country string // ISO 3166-1 alpha-2 code, e.g. "NL"
+	ASN     uint32 // Autonomous system number
+	ASOrg   string // Autonomous system organization
+}
+
+// IsZero reports whether nothing is known about the address.
+func (i Info) IsZero() bool {
+	return i.Country == "" && i.ASN == 0
+}
+
+// String formats the info for logs, e.g. "NL AS1136 (KPN B.V.)".
+func (i Info) String() string {
+	s := i.Country
+	if i.ASN != 0 {
+		if s != "" {
+			s += " "
+		}
+		s += "AS" + strconv.FormatUint(uint64(i.ASN), 10)
+		if i.ASOrg != "" {
+			s += " (" + i.ASOrg + ")"
+		}
+	}
+	return s
+}
+
+// DB holds the opened databases. A nil *DB is valid and knows nothing.
+type DB struct {
+	country *maxminddb.Reader
+	asn     *maxminddb.Reader
+}
+
+// countryRecord is the part of a Country or City record that is used.
+type countryRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	RegisteredCountry struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+}
+
+// asnRecord is an ASN database record.
+type asnRecord struct {
+	Number       uint32 `maxminddb:"autonomous_system_number"`
+	Organization string `maxminddb:"autonomous_system_organization"`
+}
+
+// Open opens the country and ASN databases at the given paths. Empty
+// paths are skipped; with both empty, Open returns a nil *DB.
+func Open(countryPath, asnPath string) (*DB, error) {
+	if countryPath == "" && asnPath == "" {
+		return nil, nil
+	}
+
+	db := &DB{}
+	if countryPath != "" {
+		r, err := maxminddb.Open(countryPath)
+		if err != nil {
+			return nil, fmt.Errorf("open country database: %w", err)
+		}
+		db.country = r
+	}
+	if asnPath != "" {
+		r, err := maxminddb.Open(asnPath)
+		if err != nil {
+			_ = db.Close()
+			return nil, fmt.Errorf("open ASN database: %w", err)
+		}
+		db.asn = r
+	}
+	return db, nil
+}
+
+// Lookup returns what the databases know about ip. Lookup errors are
+// treated as no entry.
+func (db *DB) Lookup(ip netip.Addr) Info {
+	var info Info
+	if db == nil || !ip.IsValid() {
+		return info
+	}
+	netIP := ip.Unmap().AsSlice()
+
+	if db.country != nil {
+		var rec countryRecord
+		if db.country.Lookup(netIP, &rec) == nil {
+			info.Country = rec.Country.ISOCode
+			if info.Country == "" {
+				info.Country = rec.RegisteredCountry.ISOCode
+			}
+		}
+	}
+	if db.asn != nil {
+		var rec asnRecord
+		if db.asn.Lookup(netIP, &rec) == nil {
+			info.ASN = rec.Number
+			info.ASOrg = rec.Organization
+		}
+	}
+	return info
+}
+
+// Close closes the databases.
+func (db *DB) Close() error {
+	if db == nil {
+		return nil
+	}
+	var errs []error
+	if db.country != nil {
+		errs = append(errs, db.country.Close())
+	}
+	if db.asn != nil {
+		errs = append(errs, db.asn.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,120 @@
+package geoip_test
+
+import (
+	"net/netip"
+	"path/filepath"
+	"testing"
+
+	"github.com/jroosing/hydradns/internal/geoip"
+	"github.com/jroosing/hydradns/internal/geoip/geoiptest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// openTestDB writes a country and an ASN database and opens them.
+func openTestDB(t *testing.T) *geoip.DB {
+	t.Helper()
+	dir := t.TempDir()
+	countryPath := filepath.Join(dir, "country.mmdb")
+	asnPath := filepath.Join(dir, "asn.mmdb")
+
+	require.NoError(t, geoiptest.Write(countryPath, "GeoLite2-Country", map[netip.Prefix]map[string]any{
+		netip.MustParsePrefix("192.0.2.0/24"):    geoiptest.Country("NL"),
+		netip.MustParsePrefix("2001:db8::/32"):   geoiptest.Country("DE"),
+		netip.MustParsePrefix("198.51.100.0/24"): {"registered_country": map[string]any{"iso_code": "US"}},
+	}))
+	require.NoError(t, geoiptest.Write(asnPath, "GeoLite2-ASN", map[netip.Prefix]map[string]any{
+		netip.MustParsePrefix("192.0.2.0/25"): geoiptest.ASN(64500, "Example Net"),
+	}))
+
+	db, err := geoip.Open(countryPath, asnPath)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+// ============================================================================
+// Lookup Tests
+// ============================================================================
+
+func TestLookup_CountryAndASN(t *testing.T) {
+	db := openTestDB(t)
+
+	info := db.Lookup(netip.MustParseAddr("192.0.2.10"))
+
+	assert.Equal(t, geoip.Info{Country: "NL", ASN: 64500, ASOrg: "Example Net"}, info)
+	assert.Equal(t, "NL AS64500 (Example Net)", info.String())
+}
+
+func TestLookup_CountryOnly(t *testing.T) {
+	db := openTestDB(t)
+
+	info := db.Lookup(netip.MustParseAddr("192.0.2.200"))
+
+	assert.Equal(t, geoip.Info{Country: "NL"}, info)
+	assert.Equal(t, "NL", info.String())
+}
+
+func TestLookup_IPv6(t *testing.T) {
+	db := openTestDB(t)
+
+	assert.Equal(t, "DE", db.Lookup(netip.MustParseAddr("2001:db8::1")).Country)
+}
+
+func TestLookup_IPv4Mapped(t *testing.T) {
+	db := openTestDB(t)
+
+	assert.Equal(t, "NL", db.Lookup(netip.MustParseAddr("::ffff:192.0.2.10")).Country)
+}
+
+func TestLookup_RegisteredCountryFallback(t *testing.T) {
+	db := openTestDB(t)
+
+	assert.Equal(t, "US", db.Lookup(netip.MustParseAddr("198.51.100.1")).Country)
+}
+
+func TestLookup_Unknown(t *testing.T) {
+	db := openTestDB(t)
+
+	info := db.Lookup(netip.MustParseAddr("203.0.113.1"))
+
+	assert.True(t, info.IsZero())
+	assert.Empty(t, info.String())
+}
+
+func TestLookup_NilDB(t *testing.T) {
+	var db *geoip.DB
+
+	assert.True(t, db.Lookup(netip.MustParseAddr("192.0.2.10")).IsZero())
+	assert.NoError(t, db.Close())
+}
+
+// ============================================================================
+// Open Tests
+// ============================================================================
+
+func TestOpen_NoPaths(t *testing.T) {
+	db, err := geoip.Open("", "")
+
+	require.NoError(t, err)
+	assert.Nil(t, db)
+}
+
+func TestOpen_MissingFile(t *testing.T) {
+	_, err := geoip.Open(filepath.Join(t.TempDir(), "missing.mmdb"), "")
+
+	assert.ErrorContains(t, err, "country database")
+}
+
+func TestOpen_ASNOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "asn.mmdb")
+	require.NoError(t, geoiptest.Write(path, "GeoLite2-ASN", map[netip.Prefix]map[string]any{
+		netip.MustParsePrefix("192.0.2.0/24"): geoiptest.ASN(64501, ""),
+	}))
+
+	db, err := geoip.Open("", path)
+	require.NoError(t, err)
+	defer db.Close()
+
+	assert.Equal(t, geoip.Info{ASN: 64501}, db.Lookup(netip.MustParseAddr("192.0.2.1")))
+}
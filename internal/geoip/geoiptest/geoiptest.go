@@ -0,0 +1,195 @@
+// Package geoiptest writes small MaxMind DB (.mmdb) files for tests.
+//
+// Only what the geoip package reads is supported: an IPv6 search tree with
+// 24-bit records (IPv4 networks are stored in the IPv4-mapped ::/96 subtree)
+// and map, string, and unsigned integer data.
+package geoiptest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"maps"
+	"net/netip"
+	"os"
+	"slices"
+)
+
+// Country returns the data of a Country database record for code.
+func Country(code string) map[string]any {
+	return map[string]any{"country": map[string]any{"iso_code": code}}
+}
+
+// ASN returns the data of an ASN database record.
+func ASN(number uint32, org string) map[string]any {
+	return map[string]any{
+		"autonomous_system_number":       number,
+		"autonomous_system_organization": org,
+	}
+}
+
+// Write writes a database of type dbType (e.g. "GeoLite2-Country") that maps
+// each network to its data. Networks must not overlap.
+func Write(path, dbType string, networks map[netip.Prefix]map[string]any) error {
+	b, err := Build(dbType, networks)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o600)
+}
+
+// recordSize is the search tree record size in bits.
+const recordSize = 24
+
+// Build returns the bytes of a database of type dbType that maps each
+// network to its data. Networks must not overlap.
+func Build(dbType string, networks map[netip.Prefix]map[string]any) ([]byte, error) {
+	// Each node holds two records. A record is the index of another node,
+	// empty (-1), or data (data offset encoded as -2-offset).
+	nodes := [][2]int{{-1, -1}}
+	var data bytes.Buffer
+	prefixes := slices.SortedFunc(maps.Keys(networks), func(a, b netip.Prefix) int {
+		return a.Addr().Compare(b.Addr())
+	})
+	for _, p := range prefixes {
+		if !p.IsValid() || p.Bits() == 0 {
+			return nil, fmt.Errorf("geoiptest: invalid network %v", p)
+		}
+		offset := data.Len()
+		if err := encode(&data, networks[p]); err != nil {
+			return nil, err
+		}
+
+		ip := p.Masked().Addr().As16()
+		bits := p.Bits()
+		if p.Addr().Is4() {
+			// ::a.b.c.d, not ::ffff:a.b.c.d
+			var v4 [16]byte
+			copy(v4[12:], ip[12:])
+			ip = v4
+			bits += 96
+		}
+		node := 0
+		for i := range bits {
+			bit := int(ip[i/8]>>(7-i%8)) & 1
+			if i == bits-1 {
+				nodes[node][bit] = -2 - offset
+				break
+			}
+			next := nodes[node][bit]
+			if next < 0 {
+				nodes = append(nodes, [2]int{-1, -1})
+				next = len(nodes) - 1
+				nodes[node][bit] = next
+			}
+			node = next
+		}
+	}
+
+	nodeCount := len(nodes)
+	var out bytes.Buffer
+	for _, n := range nodes {
+		for _, rec := range n {
+			v := rec
+			switch {
+			case rec == -1:
+				v = nodeCount
+			case rec < -1:
+				v = nodeCount + 16 + (-2 - rec)
+			}
+			out.Write([]byte{byte(v >> 16), byte(v >> 8), byte(v)})
+		}
+	}
+	out.Write(make([]byte, 16))
+	out.Write(data.Bytes())
+
+	out.WriteString("\xAB\xCD\xEFMaxMind.com")
+	err := encode(&out, map[string]any{
+		"node_count":                  uint32(nodeCount),
+		"record_size":                 uint16(recordSize),
+		"ip_version":                  uint16(6),
+		"database_type":               dbType,
+		"languages":                   []any{"en"},
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(0),
+		"description":                 map[string]any{"en": "HydraDNS test database"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// MaxMind DB data section type numbers.
+const (
+	typeString = 2
+	typeUint16 = 5
+	typeUint32 = 6
+	typeMap    = 7
+	typeUint64 = 9
+	typeArray  = 11
+)
+
+// encode appends v in MaxMind DB data section format.
+func encode(buf *bytes.Buffer, v any) error {
+	switch v := v.(type) {
+	case string:
+		writeControl(buf, typeString, len(v))
+		buf.WriteString(v)
+	case uint16:
+		writeUint(buf, typeUint16, uint64(v))
+	case uint32:
+		writeUint(buf, typeUint32, uint64(v))
+	case uint64:
+		writeUint(buf, typeUint64, v)
+	case []any:
+		writeControl(buf, typeArray, len(v))
+		for _, item := range v {
+			if err := encode(buf, item); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		writeControl(buf, typeMap, len(v))
+		for _, k := range slices.Sorted(maps.Keys(v)) {
+			writeControl(buf, typeString, len(k))
+			buf.WriteString(k)
+			if err := encode(buf, v[k]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("geoiptest: unsupported type %T", v)
+	}
+	return nil
+}
+
+// writeUint writes an unsigned integer using as few bytes as possible.
+func writeUint(buf *bytes.Buffer, typ int, v uint64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	trimmed := bytes.TrimLeft(b[:], "\x00")
+	writeControl(buf, typ, len(trimmed))
+	buf.Write(trimmed)
+}
+
+// writeControl writes the control byte(s) for a value of typ and size.
+// Sizes up to 284 are supported.
+func writeControl(buf *bytes.Buffer, typ, size int) {
+	ctrlType := typ
+	if typ > 7 {
+		ctrlType = 0
+	}
+	sizeBits, extra := size, -1
+	if size >= 29 {
+		sizeBits, extra = 29, size-29
+	}
+	buf.WriteByte(byte(ctrlType<<5 | sizeBits))
+	if typ > 7 {
+		buf.WriteByte(byte(typ - 7))
+	}
+	if extra >= 0 {
+		buf.WriteByte(byte(extra))
+	}
+}
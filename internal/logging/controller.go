@@ -0,0 +1,218 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Controller changes the level and format of the loggers built from it
+// while they are in use, including loggers derived with With and
+// WithGroup. A level change can be temporary, reverting on its own.
+type Controller struct {
+	out    io.Writer
+	attrs  []slog.Attr
+	format string // Structured format: "json" or "text"
+
+	level slog.LevelVar
+	root  atomic.Pointer[rootHandler]
+
+	mu        sync.Mutex
+	base      slog.Level  // Level restored when a temporary change ends
+	revertAt  time.Time   // When the temporary change ends; zero if none
+	revert    *time.Timer // Pending revert of a temporary change
+	revertGen uint64      // Incremented per level change, so stale reverts are ignored
+}
+
+// rootHandler is the handler for the current format. gen identifies it, so
+// derived handlers know when to rebuild.
+type rootHandler struct {
+	gen        uint64
+	structured bool
+	h          slog.Handler
+}
+
+// LevelStatus describes the current level and format.
+type LevelStatus struct {
+	Level      slog.Level
+	Structured bool
+	// RevertAt is when a temporary level change ends and RevertLevel the
+	// level restored then; RevertAt is zero without one.
+	RevertAt    time.Time
+	RevertLevel slog.Level
+}
+
+// NewController returns a Controller logging as cfg describes.
+func NewController(cfg Config) *Controller {
+	out := cfg.Output
+	if out == nil {
+		out = os.Stderr
+	}
+	c := &Controller{out: out, format: strings.ToLower(cfg.StructuredFormat)}
+	for k, v := range cfg.ExtraFields {
+		c.attrs = append(c.attrs, slog.String(k, v))
+	}
+	if cfg.IncludePID {
+		c.attrs = append(c.attrs, slog.Int("pid", os.Getpid()))
+	}
+	c.base = parseLevel(cfg.Level)
+	c.level.Set(c.base)
+	c.setStructured(cfg.Structured)
+	return c
+}
+
+// Logger returns a logger that follows the controller's settings.
+func (c *Controller) Logger() *slog.Logger {
+	return slog.New(&dynamicHandler{c: c})
+}
+
+// Status returns the current level and format.
+func (c *Controller) Status() LevelStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := LevelStatus{Level: c.level.Level(), Structured: c.root.Load().structured}
+	if !c.revertAt.IsZero() {
+		s.RevertAt = c.revertAt
+		s.RevertLevel = c.base
+	}
+	return s
+}
+
+// SetLevel changes the level, ending any temporary change.
+func (c *Controller) SetLevel(level slog.Level) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stopRevertLocked()
+	c.base = level
+	c.level.Set(level)
+}
+
+// SetLevelFor changes the level for d, then restores the level in effect
+// before the first of any overlapping temporary changes.
+func (c *Controller) SetLevelFor(level slog.Level, d time.Duration) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stopRevertLocked()
+	c.level.Set(level)
+	c.revertAt = time.Now().Add(d)
+	gen := c.revertGen
+	c.revert = time.AfterFunc(d, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.revertGen != gen {
+			return // superseded by a later change
+		}
+		c.revertAt = time.Time{}
+		c.revert = nil
+		c.level.Set(c.base)
+	})
+	return c.revertAt
+}
+
+// stopRevertLocked cancels a pending revert. Must be called with c.mu held.
+func (c *Controller) stopRevertLocked() {
+	c.revertGen++
+	if c.revert != nil {
+		c.revert.Stop()
+		c.revert = nil
+	}
+	c.revertAt = time.Time{}
+}
+
+// SetStructured switches between structured output in the configured
+// format and plain text.
+func (c *Controller) SetStructured(structured bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setStructured(structured)
+}
+
+func (c *Controller) setStructured(structured bool) {
+	opts := &slog.HandlerOptions{Level: &c.level}
+	var h slog.Handler
+	if structured && c.format == "json" {
+		h = slog.NewJSONHandler(c.out, opts)
+	} else {
+		// key=value-ish output
+		h = slog.NewTextHandler(c.out, opts)
+	}
+	if len(c.attrs) > 0 {
+		h = h.WithAttrs(c.attrs)
+	}
+	var gen uint64
+	if old := c.root.Load(); old != nil {
+		gen = old.gen + 1
+	}
+	c.root.Store(&rootHandler{gen: gen, structured: structured, h: h})
+}
+
+// ParseLevel parses a level name: DEBUG, INFO, WARN (or WARNING), or
+// ERROR, in any case.
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "DEBUG":
+		return slog.LevelDebug, nil
+	case "INFO":
+		return slog.LevelInfo, nil
+	case "WARN", "WARNING":
+		return slog.LevelWarn, nil
+	case "ERROR":
+		return slog.LevelError, nil
+	default:
+		return slog.LevelInfo, fmt.Errorf("unknown log level %q", s)
+	}
+}
+
+// dynamicHandler passes records to the controller's current handler, with
+// the attributes and groups added through WithAttrs and WithGroup.
+type dynamicHandler struct {
+	c     *Controller
+	ops   []func(slog.Handler) slog.Handler // WithAttrs and WithGroup, in order
+	built atomic.Pointer[rootHandler]       // ops applied to the root of the same gen
+}
+
+func (h *dynamicHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.c.level.Level()
+}
+
+func (h *dynamicHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.current().Handle(ctx, r)
+}
+
+func (h *dynamicHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(inner slog.Handler) slog.Handler { return inner.WithAttrs(attrs) })
+}
+
+func (h *dynamicHandler) WithGroup(name string) slog.Handler {
+	return h.with(func(inner slog.Handler) slog.Handler { return inner.WithGroup(name) })
+}
+
+func (h *dynamicHandler) with(op func(slog.Handler) slog.Handler) *dynamicHandler {
+	ops := make([]func(slog.Handler) slog.Handler, len(h.ops), len(h.ops)+1)
+	copy(ops, h.ops)
+	return &dynamicHandler{c: h.c, ops: append(ops, op)}
+}
+
+// current returns the root handler with ops applied, rebuilding it when
+// the format has changed.
+func (h *dynamicHandler) current() slog.Handler {
+	root := h.c.root.Load()
+	if len(h.ops) == 0 {
+		return root.h
+	}
+	if b := h.built.Load(); b != nil && b.gen == root.gen {
+		return b.h
+	}
+	inner := root.h
+	for _, op := range h.ops {
+		inner = op(inner)
+	}
+	h.built.Store(&rootHandler{gen: root.gen, structured: root.structured, h: inner})
+	return inner
+}
@@ -6,8 +6,6 @@ package logging
 import (
 	"io"
 	"log/slog"
-	"os"
-	"strings"
 )
 
 type Config struct {
@@ -16,52 +14,20 @@ type Config struct {
 	StructuredFormat string
 	IncludePID       bool
 	ExtraFields      map[string]string
+	Output           io.Writer // Where logs are written; nil means stderr
 }
 
+// Configure builds the logger cfg describes, makes it the slog default,
+// and returns it. Use NewController for a logger whose level and format
+// can change later.
 func Configure(cfg Config) *slog.Logger {
-	level := parseLevel(cfg.Level)
-	var handler slog.Handler
-	out := io.Writer(os.Stderr)
-
-	attrs := make([]slog.Attr, 0, len(cfg.ExtraFields)+1)
-	for k, v := range cfg.ExtraFields {
-		attrs = append(attrs, slog.String(k, v))
-	}
-	if cfg.IncludePID {
-		attrs = append(attrs, slog.Int("pid", os.Getpid()))
-	}
-
-	if cfg.Structured {
-		if strings.ToLower(cfg.StructuredFormat) == "json" {
-			handler = slog.NewJSONHandler(out, &slog.HandlerOptions{Level: level})
-		} else {
-			// key=value-ish output
-			handler = slog.NewTextHandler(out, &slog.HandlerOptions{Level: level})
-		}
-	} else {
-		handler = slog.NewTextHandler(out, &slog.HandlerOptions{Level: level})
-	}
-
-	if len(attrs) > 0 {
-		handler = handler.WithAttrs(attrs)
-	}
-	logger := slog.New(handler)
+	logger := NewController(cfg).Logger()
 	slog.SetDefault(logger)
 	return logger
 }
 
+// parseLevel is ParseLevel, defaulting to INFO for unknown names.
 func parseLevel(s string) slog.Level {
-	s = strings.ToUpper(strings.TrimSpace(s))
-	switch s {
-	case "DEBUG":
-		return slog.LevelDebug
-	case "INFO":
-		return slog.LevelInfo
-	case "WARN", "WARNING":
-		return slog.LevelWarn
-	case "ERROR":
-		return slog.LevelError
-	default:
-		return slog.LevelInfo
-	}
+	level, _ := ParseLevel(s)
+	return level
 }
@@ -1,7 +1,11 @@
 package logging_test
 
 import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
 	"testing"
+	"time"
 
 	"github.com/jroosing/hydradns/internal/logging"
 	"github.com/stretchr/testify/assert"
@@ -101,3 +105,88 @@ func TestConfigure_EmptyLevel(t *testing.T) {
 	logger := logging.Configure(cfg)
 	assert.NotNil(t, logger, "Empty level should default to INFO")
 }
+
+// =============================================================================
+// Controller Tests
+// =============================================================================
+
+func TestController_SetLevelAppliesToDerivedLoggers(t *testing.T) {
+	var buf bytes.Buffer
+	c := logging.NewController(logging.Config{Level: "INFO", Output: &buf})
+	logger := c.Logger().With("component", "test")
+
+	logger.Debug("hidden")
+	assert.Empty(t, buf.String())
+
+	c.SetLevel(slog.LevelDebug)
+	logger.Debug("shown")
+	assert.Contains(t, buf.String(), "shown")
+	assert.Contains(t, buf.String(), "component=test")
+	assert.Equal(t, slog.LevelDebug, c.Status().Level)
+}
+
+func TestController_SetStructuredSwitchesFormat(t *testing.T) {
+	var buf bytes.Buffer
+	c := logging.NewController(logging.Config{
+		Level:            "INFO",
+		StructuredFormat: "json",
+		ExtraFields:      map[string]string{"app": "hydradns"},
+		Output:           &buf,
+	})
+	logger := c.Logger().WithGroup("dns").With("port", 53)
+
+	logger.Info("text")
+	assert.Contains(t, buf.String(), "dns.port=53")
+	assert.False(t, c.Status().Structured)
+
+	buf.Reset()
+	c.SetStructured(true)
+	logger.Info("json")
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "json", entry["msg"])
+	assert.Equal(t, "hydradns", entry["app"])
+	assert.Equal(t, map[string]any{"port": float64(53)}, entry["dns"])
+	assert.True(t, c.Status().Structured)
+}
+
+func TestController_SetLevelForReverts(t *testing.T) {
+	var buf bytes.Buffer
+	c := logging.NewController(logging.Config{Level: "WARN", Output: &buf})
+
+	revertAt := c.SetLevelFor(slog.LevelDebug, 50*time.Millisecond)
+	// A second temporary change still reverts to the level before both
+	c.SetLevelFor(slog.LevelInfo, 50*time.Millisecond)
+	s := c.Status()
+	assert.Equal(t, slog.LevelInfo, s.Level)
+	assert.Equal(t, slog.LevelWarn, s.RevertLevel)
+	assert.False(t, s.RevertAt.Before(revertAt))
+
+	assert.Eventually(t, func() bool { return c.Status().Level == slog.LevelWarn }, time.Second, 5*time.Millisecond)
+	assert.True(t, c.Status().RevertAt.IsZero())
+}
+
+func TestController_SetLevelCancelsRevert(t *testing.T) {
+	c := logging.NewController(logging.Config{Level: "INFO", Output: &bytes.Buffer{}})
+
+	c.SetLevelFor(slog.LevelDebug, 20*time.Millisecond)
+	c.SetLevel(slog.LevelError)
+	time.Sleep(50 * time.Millisecond)
+
+	s := c.Status()
+	assert.Equal(t, slog.LevelError, s.Level)
+	assert.True(t, s.RevertAt.IsZero())
+}
+
+func TestParseLevel(t *testing.T) {
+	for name, want := range map[string]slog.Level{
+		"debug": slog.LevelDebug, "INFO": slog.LevelInfo, "Warning": slog.LevelWarn, " error ": slog.LevelError,
+	} {
+		got, err := logging.ParseLevel(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, want, got, name)
+	}
+	_, err := logging.ParseLevel("verbose")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "verbose")
+}
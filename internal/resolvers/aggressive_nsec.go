@@ -0,0 +1,432 @@
+package resolvers
+
+import (
+	"encoding/binary"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jroosing/hydradns/internal/dns"
+)
+
+// maxNSECRanges bounds the NSEC records held for aggressive negative
+// caching across all zones.
+const maxNSECRanges = 10000
+
+// nsecCache holds DNSSEC-validated NSEC records for aggressive use of
+// the DNSSEC-validated cache (RFC 8198). Every NSEC record proves that no
+// name sorts between its owner and next name, so one NXDOMAIN answer can
+// answer queries for many other names in the same zone without asking
+// upstream again.
+//
+// Records are learned only from negative answers that the upstream marked
+// as authenticated (AD). HydraDNS does not validate signatures itself; the
+// upstream must be a validating resolver. NSEC3 proofs are not used, since
+// their hashed names cannot be matched without the zone's hash parameters
+// and opt-out makes their gaps unreliable for NXDOMAIN.
+type nsecCache struct {
+	mu     sync.Mutex
+	zones  map[string]*nsecZone // By zone apex
+	ranges int                  // NSEC records held across all zones
+	now    func() time.Time
+}
+
+// nsecZone is the cached denial data of one signed zone.
+type nsecZone struct {
+	soa     []dns.Record // Zone SOA and its RRSIGs
+	expires time.Time    // When the SOA expires
+	ranges  []*nsecRange // Sorted by owner in canonical order
+}
+
+// nsecRange is one NSEC record with the RRSIGs that cover it.
+type nsecRange struct {
+	owner   string
+	nsec    *dns.NSECRecord
+	sigs    []dns.Record
+	expires time.Time
+}
+
+func newNSECCache() *nsecCache {
+	return &nsecCache{zones: map[string]*nsecZone{}, now: time.Now}
+}
+
+// learn stores the NSEC records of an authenticated NXDOMAIN or NODATA
+// response in wire format.
+func (c *nsecCache) learn(msg []byte) {
+	if len(msg) < dns.HeaderSize {
+		return
+	}
+	flags := binary.BigEndian.Uint16(msg[2:4])
+	if rc := dns.RCodeFromFlags(flags); flags&dns.ADFlag == 0 || (rc != dns.RCodeNXDomain && rc != dns.RCodeNoError) {
+		return
+	}
+	resp, err := dns.ParsePacket(msg)
+	if err != nil || len(resp.Answers) > 0 {
+		return
+	}
+
+	soa, ok := uncompressedSOA(resp, msg)
+	if !ok {
+		return
+	}
+	zone := dns.NormalizeName(soa.Header().Name)
+	soaSigs := coveringSigs(resp.Authorities, zone, dns.TypeSOA, zone)
+	if len(soaSigs) == 0 {
+		return
+	}
+	// RFC 9077: negative answers live no longer than the SOA TTL or MINIMUM
+	ttl := min(soa.Header().TTL, uint32(extractSOAMinimum(resp)))
+	if ttl == 0 {
+		return
+	}
+
+	now := c.now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	z := c.zones[zone]
+	for _, r := range resp.Authorities {
+		nsec, ok := r.(*dns.NSECRecord)
+		if !ok {
+			continue
+		}
+		owner := dns.NormalizeName(nsec.H.Name)
+		if !isSubdomain(owner, zone) || !isSubdomain(dns.NormalizeName(nsec.NextDomain), zone) {
+			continue
+		}
+		sigs := coveringSigs(resp.Authorities, owner, dns.TypeNSEC, zone)
+		if len(sigs) == 0 {
+			continue
+		}
+		if z == nil {
+			z = &nsecZone{}
+			c.zones[zone] = z
+		}
+		expires := now.Add(time.Duration(min(nsec.H.TTL, ttl)) * time.Second)
+		c.store(z, &nsecRange{owner: owner, nsec: nsec, sigs: sigs, expires: expires}, now)
+	}
+	if z != nil {
+		z.soa = append([]dns.Record{soa}, soaSigs...)
+		z.expires = now.Add(time.Duration(ttl) * time.Second)
+	}
+}
+
+// store adds or replaces r in z, making room by dropping expired records
+// when the cache is full. Caller must hold c.mu.
+func (c *nsecCache) store(z *nsecZone, r *nsecRange, now time.Time) {
+	i, found := slices.BinarySearchFunc(z.ranges, r.owner, compareRangeOwner)
+	if found {
+		z.ranges[i] = r
+		return
+	}
+	if c.ranges >= maxNSECRanges {
+		c.purgeExpired(now)
+		if c.ranges >= maxNSECRanges {
+			return
+		}
+		i, _ = slices.BinarySearchFunc(z.ranges, r.owner, compareRangeOwner)
+	}
+	z.ranges = slices.Insert(z.ranges, i, r)
+	c.ranges++
+}
+
+// purgeExpired drops expired records and zones left without any. Caller
+// must hold c.mu.
+func (c *nsecCache) purgeExpired(now time.Time) {
+	for name, z := range c.zones {
+		before := len(z.ranges)
+		z.ranges = slices.DeleteFunc(z.ranges, func(r *nsecRange) bool { return !now.Before(r.expires) })
+		c.ranges -= before - len(z.ranges)
+		if len(z.ranges) == 0 {
+			delete(c.zones, name)
+		}
+	}
+}
+
+// synthesize answers req from the cached NSEC records when they prove the
+// name does not exist (NXDOMAIN) or has no records of the queried type
+// (NODATA). It reports false when the cache holds no such proof.
+//
+// NSEC and RRSIG records are included in the authority section only for
+// clients that set the DO bit; the SOA is always included so clients can
+// cache the negative answer (RFC 2308).
+func (c *nsecCache) synthesize(req dns.Packet) ([]byte, bool) {
+	if len(req.Questions) != 1 {
+		return nil, false
+	}
+	q := req.Questions[0]
+	qtype := dns.RecordType(q.Type)
+	if q.Class != uint16(dns.ClassIN) || qtype == dns.TypeANY {
+		return nil, false
+	}
+	qname := dns.NormalizeName(q.Name)
+
+	now := c.now()
+	c.mu.Lock()
+	zone, z := c.zoneFor(qname, now)
+	if z == nil {
+		c.mu.Unlock()
+		return nil, false
+	}
+	rcode, proof := z.deny(zone, qname, qtype, now)
+	soa := z.soa
+	soaExpires := z.expires
+	c.mu.Unlock()
+	if proof == nil {
+		return nil, false
+	}
+
+	opt := dns.ExtractOPT(req.Additionals)
+	do := opt != nil && opt.DNSSECOk
+	flags := buildBlockedFlags(req.Header.Flags, rcode)
+	if do || req.Header.Flags&dns.ADFlag != 0 {
+		flags |= dns.ADFlag // RFC 6840 §5.7: the data came from authenticated records
+	}
+	flags |= req.Header.Flags & dns.CDFlag
+
+	ttlLeft := func(expires time.Time) uint32 {
+		return uint32(max(expires.Sub(now)/time.Second, 1))
+	}
+	soaTTL := ttlLeft(soaExpires)
+	var authorities []dns.Record
+	for i, r := range soa {
+		if i > 0 && !do {
+			break
+		}
+		authorities = append(authorities, withTTL(r, soaTTL))
+	}
+	if do {
+		for _, r := range proof {
+			ttl := min(ttlLeft(r.expires), soaTTL)
+			authorities = append(authorities, withTTL(r.nsec, ttl))
+			for _, sig := range r.sigs {
+				authorities = append(authorities, withTTL(sig, ttl))
+			}
+		}
+	}
+
+	resp := dns.Packet{
+		Header:      dns.Header{ID: req.Header.ID, Flags: flags},
+		Questions:   req.Questions,
+		Authorities: authorities,
+	}
+	if opt != nil {
+		respOPT := dns.CreateOPT(dns.EDNSDefaultUDPPayloadSize)
+		respOPT.DNSSECOk = do
+		resp.Additionals = []dns.Record{respOPT.Record()}
+	}
+	b, err := resp.Marshal()
+	if err != nil {
+		return nil, false
+	}
+	return b, true
+}
+
+// zoneFor returns the closest cached zone enclosing qname whose SOA has
+// not expired. Caller must hold c.mu.
+func (c *nsecCache) zoneFor(qname string, now time.Time) (string, *nsecZone) {
+	for name := qname; ; {
+		if z, ok := c.zones[name]; ok && now.Before(z.expires) {
+			return name, z
+		}
+		if name == "" {
+			return "", nil
+		}
+		_, name, _ = strings.Cut(name, ".")
+	}
+}
+
+// deny returns the response code and the NSEC records that deny qtype at
+// qname in the zone, or nil records when the cached chain proves nothing.
+func (z *nsecZone) deny(zone, qname string, qtype dns.RecordType, now time.Time) (dns.RCode, []*nsecRange) {
+	match, cover := z.find(qname, now)
+	if match != nil {
+		if !nodataAllowed(match, zone, qtype) {
+			return dns.RCodeNoError, nil
+		}
+		return dns.RCodeNoError, []*nsecRange{match}
+	}
+	if cover == nil || delegatesBelow(cover, zone, qname) {
+		return dns.RCodeNoError, nil
+	}
+
+	// The name does not exist, but a wildcard at its closest encloser
+	// would still answer it (RFC 4592), so the wildcard must be denied too
+	encloser := commonAncestor(qname, cover.owner)
+	if next := commonAncestor(qname, dns.NormalizeName(cover.nsec.NextDomain)); len(next) > len(encloser) {
+		encloser = next
+	}
+	wildcard := "*." + encloser
+	if encloser == "" {
+		wildcard = "*"
+	}
+	wmatch, wcover := z.find(wildcard, now)
+	if wmatch != nil || wcover == nil || delegatesBelow(wcover, zone, wildcard) {
+		return dns.RCodeNoError, nil
+	}
+	if wcover == cover {
+		return dns.RCodeNXDomain, []*nsecRange{cover}
+	}
+	return dns.RCodeNXDomain, []*nsecRange{cover, wcover}
+}
+
+// find returns the unexpired NSEC record owned by name, or else the one
+// whose span covers name. Either may be nil.
+func (z *nsecZone) find(name string, now time.Time) (match, cover *nsecRange) {
+	i, found := slices.BinarySearchFunc(z.ranges, name, compareRangeOwner)
+	if found {
+		if r := z.ranges[i]; now.Before(r.expires) {
+			return r, nil
+		}
+		return nil, nil
+	}
+	if i == 0 {
+		return nil, nil
+	}
+	r := z.ranges[i-1]
+	next := dns.NormalizeName(r.nsec.NextDomain)
+	// The last NSEC of a zone points back at the apex
+	last := dns.CompareNames(next, r.owner) <= 0
+	if !now.Before(r.expires) || (!last && dns.CompareNames(name, next) >= 0) {
+		return nil, nil
+	}
+	return nil, r
+}
+
+// nodataAllowed reports whether an NSEC record owned by the query name
+// proves that qtype does not exist there.
+func nodataAllowed(r *nsecRange, zone string, qtype dns.RecordType) bool {
+	if r.nsec.HasType(qtype) || r.nsec.HasType(dns.TypeCNAME) {
+		return false
+	}
+	if qtype == dns.TypeDS {
+		// DS lives in the parent zone; the child's apex NSEC cannot deny it
+		return r.owner != zone
+	}
+	// At a delegation the parent's NSEC says nothing about the child zone
+	return !isDelegation(r.nsec) || r.owner == zone
+}
+
+// delegatesBelow reports whether the covering NSEC r is owned by a
+// delegation or DNAME above name, so that name is answered by another zone.
+func delegatesBelow(r *nsecRange, zone, name string) bool {
+	if r.owner == zone || r.owner == name || !isSubdomain(name, r.owner) {
+		return false
+	}
+	return isDelegation(r.nsec) || r.nsec.HasType(typeDNAME)
+}
+
+// isDelegation reports whether an NSEC record marks a zone cut: NS
+// without SOA.
+func isDelegation(nsec *dns.NSECRecord) bool {
+	return nsec.HasType(dns.TypeNS) && !nsec.HasType(dns.TypeSOA)
+}
+
+// commonAncestor returns the longest name that both a and b are at or
+// below.
+func commonAncestor(a, b string) string {
+	la, lb := strings.Split(a, "."), strings.Split(b, ".")
+	n := 0
+	for n < len(la) && n < len(lb) && la[len(la)-1-n] == lb[len(lb)-1-n] {
+		n++
+	}
+	return strings.Join(la[len(la)-n:], ".")
+}
+
+// compareRangeOwner orders an NSEC record against a name for binary search.
+func compareRangeOwner(r *nsecRange, name string) int {
+	return dns.CompareNames(r.owner, name)
+}
+
+// coveringSigs returns the RRSIGs over the type rrType at owner made by
+// the zone's keys.
+func coveringSigs(records []dns.Record, owner string, rrType dns.RecordType, zone string) []dns.Record {
+	var sigs []dns.Record
+	for _, r := range records {
+		sig, ok := r.(*dns.RRSIGRecord)
+		if ok && sig.TypeCovered == rrType &&
+			dns.NormalizeName(sig.H.Name) == owner && dns.NormalizeName(sig.SignerName) == zone {
+			sigs = append(sigs, sig)
+		}
+	}
+	return sigs
+}
+
+// uncompressedSOA returns the authority SOA of resp with the names in its
+// RDATA decompressed against msg, so it can be copied into another message.
+func uncompressedSOA(resp dns.Packet, msg []byte) (dns.Record, bool) {
+	off := 0
+	h, err := dns.ParseHeader(msg, &off)
+	if err != nil {
+		return nil, false
+	}
+	for range h.QDCount {
+		if _, err := dns.ParseQuestion(msg, &off); err != nil {
+			return nil, false
+		}
+	}
+	rrs, ok := walkRecords(msg, off, h)
+	if !ok {
+		return nil, false
+	}
+	for _, rr := range rrs {
+		if rr.section != 1 || rr.rrType != dns.TypeSOA {
+			continue
+		}
+		nameOff := rr.rdStart
+		mname, err := dns.DecodeName(msg, &nameOff)
+		if err != nil {
+			return nil, false
+		}
+		rname, err := dns.DecodeName(msg, &nameOff)
+		if err != nil || nameOff+20 != rr.rdEnd {
+			return nil, false
+		}
+		rdata, err := dns.EncodeName(fqdnName(mname))
+		if err != nil {
+			return nil, false
+		}
+		rnameWire, err := dns.EncodeName(fqdnName(rname))
+		if err != nil {
+			return nil, false
+		}
+		rdata = append(append(rdata, rnameWire...), msg[nameOff:rr.rdEnd]...)
+
+		for _, r := range resp.Authorities {
+			if r.Type() == dns.TypeSOA {
+				return dns.NewOpaqueRecord(r.Header(), dns.TypeSOA, rdata), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// fqdnName returns name with a trailing dot, so the root encodes as ".".
+func fqdnName(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+// withTTL returns a copy of r with the given TTL.
+func withTTL(r dns.Record, ttl uint32) dns.Record {
+	var c dns.Record
+	switch v := r.(type) {
+	case *dns.NSECRecord:
+		cp := *v
+		c = &cp
+	case *dns.RRSIGRecord:
+		cp := *v
+		c = &cp
+	case *dns.OpaqueRecord:
+		cp := *v
+		c = &cp
+	default:
+		return r
+	}
+	h := c.Header()
+	h.TTL = ttl
+	c.SetHeader(h)
+	return c
+}
@@ -1,10 +1,14 @@
 package resolvers
 
 import (
+	"container/heap"
 	"container/list"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/jroosing/hydradns/internal/clock"
 )
 
 // CacheEntryType categorizes cached DNS responses for different TTL handling.
@@ -33,13 +37,117 @@ func (cet CacheEntryType) String() string {
 	}
 }
 
-// cacheEntry holds a cached value with expiration and LRU tracking.
-type cacheEntry[V any] struct {
+// EvictionPolicy selects which entry TTLCache removes when it is full.
+type EvictionPolicy int
+
+const (
+	EvictLRU EvictionPolicy = iota // Least recently used entry
+	EvictLFU                       // Least frequently used entry; ties go to the least recently used
+	EvictTTL                       // Entry closest to expiry; ties go to the least recently used
+)
+
+// String returns the configuration name of the eviction policy.
+func (p EvictionPolicy) String() string {
+	switch p {
+	case EvictLRU:
+		return "lru"
+	case EvictLFU:
+		return "lfu"
+	case EvictTTL:
+		return "ttl"
+	default:
+		return fmt.Sprintf("unknown(%d)", p)
+	}
+}
+
+// ParseEvictionPolicy parses "lru", "lfu", or "ttl" (case-insensitive).
+// An empty string selects LRU.
+func ParseEvictionPolicy(s string) (EvictionPolicy, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "lru":
+		return EvictLRU, nil
+	case "lfu":
+		return EvictLFU, nil
+	case "ttl":
+		return EvictTTL, nil
+	default:
+		return EvictLRU, fmt.Errorf("unknown cache eviction policy %q", s)
+	}
+}
+
+// cacheEntry holds a cached value with expiration and usage tracking.
+type cacheEntry[K comparable, V any] struct {
+	key       K
 	value     V
 	cachedAt  time.Time // When the entry was cached
 	expiresAt time.Time // When the entry expires
 	entryType CacheEntryType
 	elem      *list.Element // Position in LRU list
+	size      int           // Bytes counted against the memory budget
+	uses      uint64        // Number of Set and Get hits, for LFU
+	lastUsed  uint64        // Cache tick of the last use, breaks LFU/TTL ties
+	index     int           // Position in the eviction heap (LFU/TTL only)
+}
+
+// entryHeap orders entries so the next one to evict is at the root. It is
+// only maintained for the LFU and TTL policies; LRU uses the list.
+type entryHeap[K comparable, V any] struct {
+	items  []*cacheEntry[K, V]
+	policy EvictionPolicy
+}
+
+func (h *entryHeap[K, V]) Len() int { return len(h.items) }
+
+func (h *entryHeap[K, V]) Less(i, j int) bool {
+	a, b := h.items[i], h.items[j]
+	switch h.policy {
+	case EvictLFU:
+		if a.uses != b.uses {
+			return a.uses < b.uses
+		}
+	case EvictTTL:
+		if !a.expiresAt.Equal(b.expiresAt) {
+			return a.expiresAt.Before(b.expiresAt)
+		}
+	}
+	return a.lastUsed < b.lastUsed
+}
+
+func (h *entryHeap[K, V]) Swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+	h.items[i].index = i
+	h.items[j].index = j
+}
+
+func (h *entryHeap[K, V]) Push(x any) {
+	e := x.(*cacheEntry[K, V])
+	e.index = len(h.items)
+	h.items = append(h.items, e)
+}
+
+func (h *entryHeap[K, V]) Pop() any {
+	n := len(h.items) - 1
+	e := h.items[n]
+	h.items[n] = nil
+	h.items = h.items[:n]
+	e.index = -1
+	return e
+}
+
+// CacheStats is a point-in-time snapshot of TTLCache counters.
+type CacheStats struct {
+	Policy       EvictionPolicy
+	Entries      int     // Entries currently cached, including expired ones not yet removed
+	MaxEntries   int     // Capacity
+	Bytes        int     // Size of the cached values; 0 without a size function
+	MaxBytes     int     // Memory budget; 0 when unlimited
+	Oversized    uint64  // Values not cached because they exceed the per-entry or total budget
+	Hits         uint64  // Lookups answered from the cache
+	Misses       uint64  // Lookups not answered, including expired entries
+	NegativeHits uint64  // Hits on NXDOMAIN, NODATA, or SERVFAIL entries; included in Hits
+	Evictions    uint64  // Live entries removed to make room
+	Expirations  uint64  // Expired entries removed on lookup or to make room
+	HitRatio     float64 // Hits / (Hits + Misses); 0 before the first lookup
 }
 
 // TTLCache is a thread-safe, TTL-aware LRU cache for DNS responses.
@@ -47,9 +155,10 @@ type cacheEntry[V any] struct {
 // Features:
 //   - Per-entry TTL based on DNS record TTLs (respects the minimum TTL in response)
 //   - Configurable TTL caps (maxTTL for positive, maxNegativeTTL for negative)
-//   - LRU eviction when capacity is reached (least recently used entry is removed)
+//   - Eviction when capacity is reached: LRU (default), LFU, or TTL-priority
+//   - Optional memory budget in bytes, total and per entry
 //   - Negative caching for NXDOMAIN, NODATA, SERVFAIL (RFC 2308 compliant)
-//   - Hit/miss statistics for monitoring
+//   - Hit, miss, eviction, and expiration statistics for monitoring
 //   - Thread-safe concurrent access with mutex
 //
 // Entry Types and TTL Strategy:
@@ -70,9 +179,25 @@ type cacheEntry[V any] struct {
 //
 // Capacity Management:
 //
-// When the cache reaches maxEntries, the least recently used entry is evicted.
-// "Recently used" is updated both on Get (read) and Set (write) operations.
-// This ensures hot entries stay in the cache while cold entries are evicted.
+// When the cache reaches maxEntries, an entry is removed to make room for a new
+// one. Which entry depends on the eviction policy:
+//
+//   - LRU: the least recently used entry. "Used" covers both Get (read) and
+//     Set (write), so hot entries stay while cold entries are evicted.
+//   - LFU: the entry with the fewest uses. This protects a stable hot set
+//     from scans of one-off names, but a new name must be looked up again
+//     before it outranks older entries.
+//   - TTL: the entry closest to expiry, which loses the least cache time.
+//
+// An expired entry chosen for removal counts as an expiration, not an eviction,
+// so a high eviction count means maxEntries is too small for the working set.
+//
+// Memory Budget:
+//
+// Counting entries alone lets a few huge values (large TXT or DNSSEC answers)
+// use far more memory than their share. SetByteLimits adds a budget in bytes:
+// entries are evicted by the same policy until the total size fits, and a
+// value larger than the per-entry limit is not cached at all.
 type TTLCache[K comparable, V any] struct {
 	mu sync.Mutex
 
@@ -84,12 +209,25 @@ type TTLCache[K comparable, V any] struct {
 	servfailTTL     time.Duration // TTL for SERVFAIL responses
 	maxNegativeTTL  time.Duration // Maximum TTL cap for negative entries
 
-	lru  *list.List           // LRU list (front = oldest, back = newest)
-	data map[K]*cacheEntry[V] // Key -> entry mapping
+	policy EvictionPolicy
+	lru    *list.List              // LRU list (front = oldest, back = newest)
+	heap   *entryHeap[K, V]        // Eviction order for LFU and TTL; nil for LRU
+	data   map[K]*cacheEntry[K, V] // Key -> entry mapping
+	tick   uint64                  // Incremented on every use, for lastUsed
 
-	hits         int // Cache hit count
-	misses       int // Cache miss count
-	negativeHits int // Negative cache hit count
+	hits         uint64 // Cache hit count
+	misses       uint64 // Cache miss count
+	negativeHits uint64 // Negative cache hit count
+	evictions    uint64 // Live entries evicted for capacity
+	expirations  uint64 // Expired entries removed
+	oversized    uint64 // Values rejected for their size
+
+	sizeOf        func(V) int // Size of a value in bytes; nil disables the budget
+	maxBytes      int         // Total budget in bytes; 0 is unlimited
+	maxEntryBytes int         // Largest cacheable value in bytes; 0 is unlimited
+	bytes         int         // Current total size of cached values
+
+	clock clock.Clock // Source of the current time for TTLs and ages
 }
 
 // NewTTLCache creates a new TTL cache with the specified maximum entries.
@@ -106,51 +244,108 @@ func NewTTLCache[K comparable, V any](maxEntries int) *TTLCache[K, V] {
 		servfailTTL:     30 * time.Second,
 		maxNegativeTTL:  1 * time.Hour,
 		lru:             list.New(),
-		data:            map[K]*cacheEntry[V]{},
+		data:            map[K]*cacheEntry[K, V]{},
+		clock:           clock.System,
 	}
 }
 
-// Get retrieves a value from the cache.
-// Returns (value, found, entryType). Expired entries are removed and count as misses.
-func (c *TTLCache[K, V]) Get(key K) (V, bool, CacheEntryType) {
-	var zero V
-	now := time.Now()
+// SetClock sets the clock TTLs and ages are measured against; by default
+// the system clock. Entries already cached keep their expiry times.
+func (c *TTLCache[K, V]) SetClock(clk clock.Clock) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clock = clk
+}
 
+// SetEvictionPolicy changes how entries are chosen for eviction. Usage
+// counts gathered so far are kept, so switching policies does not flush the
+// cache.
+func (c *TTLCache[K, V]) SetEvictionPolicy(p EvictionPolicy) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	e := c.data[key]
-	if e == nil {
-		c.misses++
-		return zero, false, CachePositive
+	c.policy = p
+	if p == EvictLRU {
+		c.heap = nil
+		return
 	}
+	h := &entryHeap[K, V]{items: make([]*cacheEntry[K, V], 0, len(c.data)), policy: p}
+	for _, e := range c.data {
+		e.index = len(h.items)
+		h.items = append(h.items, e)
+	}
+	heap.Init(h)
+	c.heap = h
+}
 
-	// Check expiration
-	if !e.expiresAt.After(now) {
-		c.lru.Remove(e.elem)
-		delete(c.data, key)
-		c.misses++
-		return zero, false, CachePositive
+// SetByteLimits sets a memory budget measured by size: at most maxBytes
+// for all values together and maxEntryBytes for any one value. Zero leaves
+// that limit off, and a nil size turns the budget off altogether. Entries
+// already cached are measured and evicted as needed to fit.
+func (c *TTLCache[K, V]) SetByteLimits(maxBytes, maxEntryBytes int, size func(V) int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.sizeOf = size
+	c.maxBytes = max(maxBytes, 0)
+	c.maxEntryBytes = max(maxEntryBytes, 0)
+	c.bytes = 0
+	for _, e := range c.data {
+		e.size = c.valueSize(e.value)
+		c.bytes += e.size
+	}
+	if size == nil {
+		return
 	}
+	for _, e := range c.data {
+		if c.tooLarge(e.size) {
+			c.remove(e)
+			c.oversized++
+		}
+	}
+	c.evict(c.maxEntries, c.byteLimit(0), c.clock.Now())
+}
 
-	// Move to back of LRU (most recently used)
-	c.lru.MoveToBack(e.elem)
-	c.hits++
-	if e.entryType != CachePositive {
-		c.negativeHits++
+// Stats returns a snapshot of the cache counters.
+func (c *TTLCache[K, V]) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := CacheStats{
+		Policy:       c.policy,
+		Entries:      len(c.data),
+		MaxEntries:   c.maxEntries,
+		Bytes:        c.bytes,
+		MaxBytes:     c.maxBytes,
+		Oversized:    c.oversized,
+		Hits:         c.hits,
+		Misses:       c.misses,
+		NegativeHits: c.negativeHits,
+		Evictions:    c.evictions,
+		Expirations:  c.expirations,
+	}
+	if total := c.hits + c.misses; total > 0 {
+		s.HitRatio = float64(c.hits) / float64(total)
 	}
-	return e.value, true, e.entryType
+	return s
+}
+
+// Get retrieves a value from the cache.
+// Returns (value, found, entryType). Expired entries are removed and count as misses.
+func (c *TTLCache[K, V]) Get(key K) (V, bool, CacheEntryType) {
+	v, _, ok, entryType := c.GetWithAge(key)
+	return v, ok, entryType
 }
 
 // GetWithAge retrieves a value from the cache along with its age.
 // Returns (value, age, found, entryType). Age is the duration since caching.
 func (c *TTLCache[K, V]) GetWithAge(key K) (V, time.Duration, bool, CacheEntryType) {
 	var zero V
-	now := time.Now()
 
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	now := c.clock.Now()
 	e := c.data[key]
 	if e == nil {
 		c.misses++
@@ -159,22 +354,18 @@ func (c *TTLCache[K, V]) GetWithAge(key K) (V, time.Duration, bool, CacheEntryTy
 
 	// Check expiration
 	if !e.expiresAt.After(now) {
-		c.lru.Remove(e.elem)
-		delete(c.data, key)
+		c.remove(e)
+		c.expirations++
 		c.misses++
 		return zero, 0, false, CachePositive
 	}
 
-	// Calculate age
-	age := now.Sub(e.cachedAt)
-
-	// Move to back of LRU (most recently used)
-	c.lru.MoveToBack(e.elem)
+	c.touch(e)
 	c.hits++
 	if e.entryType != CachePositive {
 		c.negativeHits++
 	}
-	return e.value, age, true, e.entryType
+	return e.value, now.Sub(e.cachedAt), true, e.entryType
 }
 
 // Set stores a value in the cache with the specified TTL and entry type.
@@ -190,28 +381,66 @@ func (c *TTLCache[K, V]) Set(key K, val V, ttl time.Duration, entryType CacheEnt
 		return
 	}
 
-	expires := time.Now().Add(ttl)
-
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	now := c.clock.Now()
+	expires := now.Add(ttl)
+
+	size := c.valueSize(val)
+	existing := c.data[key]
+	if c.tooLarge(size) {
+		// Drop the previous answer too rather than serve it in place of this one
+		if existing != nil {
+			c.remove(existing)
+		}
+		c.oversized++
+		return
+	}
+
 	// Update existing entry
-	if existing := c.data[key]; existing != nil {
+	if existing != nil {
+		c.bytes += size - existing.size
 		existing.value = val
-		existing.cachedAt = time.Now()
+		existing.size = size
+		existing.cachedAt = now
 		existing.expiresAt = expires
 		existing.entryType = entryType
-		c.lru.MoveToBack(existing.elem)
+		c.touch(existing)
+		c.evict(c.maxEntries, c.byteLimit(0), now)
 		return
 	}
 
+	// Make room first so the new entry is never its own victim
+	c.evict(c.maxEntries-1, c.byteLimit(size), now)
+
 	// Create new entry
-	e := &cacheEntry[V]{value: val, cachedAt: time.Now(), expiresAt: expires, entryType: entryType}
+	e := &cacheEntry[K, V]{key: key, value: val, size: size, cachedAt: now, expiresAt: expires, entryType: entryType}
 	e.elem = c.lru.PushBack(key)
 	c.data[key] = e
+	c.bytes += size
+	if c.heap != nil {
+		c.tick++
+		e.uses = 1
+		e.lastUsed = c.tick
+		heap.Push(c.heap, e)
+	}
+}
 
-	// Evict oldest entries if over capacity
-	c.evictOldest()
+// DeleteFunc removes every entry whose key satisfies del and returns the
+// number of entries removed.
+func (c *TTLCache[K, V]) DeleteFunc(del func(K) bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := 0
+	for k, e := range c.data {
+		if del(k) {
+			c.remove(e)
+			n++
+		}
+	}
+	return n
 }
 
 // capTTL applies TTL caps based on entry type.
@@ -240,15 +469,66 @@ func (c *TTLCache[K, V]) capTTL(ttl time.Duration, entryType CacheEntryType) tim
 	return ttl
 }
 
-// evictOldest removes the oldest entries until under capacity.
-func (c *TTLCache[K, V]) evictOldest() {
-	for len(c.data) > c.maxEntries {
-		front := c.lru.Front()
-		if front == nil {
-			break
+// touch records a use of e. Must be called with mu held.
+func (c *TTLCache[K, V]) touch(e *cacheEntry[K, V]) {
+	c.lru.MoveToBack(e.elem)
+	if c.heap != nil {
+		c.tick++
+		e.uses++
+		e.lastUsed = c.tick
+		heap.Fix(c.heap, e.index)
+	}
+}
+
+// remove deletes e from the cache. Must be called with mu held.
+func (c *TTLCache[K, V]) remove(e *cacheEntry[K, V]) {
+	c.lru.Remove(e.elem)
+	if c.heap != nil {
+		heap.Remove(c.heap, e.index)
+	}
+	delete(c.data, e.key)
+	c.bytes -= e.size
+}
+
+// valueSize returns the budgeted size of v, or 0 without a size function.
+func (c *TTLCache[K, V]) valueSize(v V) int {
+	if c.sizeOf == nil {
+		return 0
+	}
+	return c.sizeOf(v)
+}
+
+// tooLarge reports whether a value of size bytes may not be cached at all.
+func (c *TTLCache[K, V]) tooLarge(size int) bool {
+	return (c.maxEntryBytes > 0 && size > c.maxEntryBytes) ||
+		(c.maxBytes > 0 && size > c.maxBytes)
+}
+
+// byteLimit returns how many bytes existing entries may use so that reserve
+// more bytes still fit, or -1 when there is no budget.
+func (c *TTLCache[K, V]) byteLimit(reserve int) int {
+	if c.sizeOf == nil || c.maxBytes == 0 {
+		return -1
+	}
+	return c.maxBytes - reserve
+}
+
+// evict removes entries chosen by the eviction policy until at most limit
+// remain and, unless byteLimit is negative, they use at most byteLimit
+// bytes. Must be called with mu held.
+func (c *TTLCache[K, V]) evict(limit, byteLimit int, now time.Time) {
+	for len(c.data) > 0 && (len(c.data) > limit || (byteLimit >= 0 && c.bytes > byteLimit)) {
+		var victim *cacheEntry[K, V]
+		if c.heap != nil {
+			victim = c.heap.items[0]
+		} else {
+			victim = c.data[c.lru.Front().Value.(K)]
+		}
+		if victim.expiresAt.After(now) {
+			c.evictions++
+		} else {
+			c.expirations++
 		}
-		k := front.Value.(K)
-		c.lru.Remove(front)
-		delete(c.data, k)
+		c.remove(victim)
 	}
 }
@@ -0,0 +1,79 @@
+package resolvers
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jroosing/hydradns/internal/dns"
+)
+
+// CacheBypass is a set of zones whose answers are never cached, e.g. dynamic
+// DNS names or internal health checks that must always reflect upstream.
+//
+// A zone applies to itself and all names below it, so one entry covers a
+// single host or a whole zone. A nil *CacheBypass matches nothing.
+type CacheBypass struct {
+	zones map[string]struct{}
+}
+
+// NewCacheBypass returns a bypass set for domains. Returns nil when domains
+// is empty.
+func NewCacheBypass(domains []string) *CacheBypass {
+	if len(domains) == 0 {
+		return nil
+	}
+	b := &CacheBypass{zones: make(map[string]struct{}, len(domains))}
+	for _, d := range domains {
+		if d = dns.NormalizeName(strings.TrimSpace(d)); d != "" {
+			b.zones[d] = struct{}{}
+		}
+	}
+	return b
+}
+
+// Matches reports whether qname is at or below a bypassed zone.
+func (b *CacheBypass) Matches(qname string) bool {
+	if b == nil || len(b.zones) == 0 {
+		return false
+	}
+	name := dns.NormalizeName(qname)
+	for name != "" {
+		if _, ok := b.zones[name]; ok {
+			return true
+		}
+		i := strings.IndexByte(name, '.')
+		if i < 0 {
+			break
+		}
+		name = name[i+1:]
+	}
+	return false
+}
+
+// SetCacheBypass replaces the zones whose answers are never cached. Cached
+// answers for names in the new zones are dropped, so the change applies to
+// the next query.
+func (f *ForwardingResolver) SetCacheBypass(domains []string) {
+	b := NewCacheBypass(domains)
+	f.cacheBypass.Store(b)
+	if b != nil {
+		f.cache.DeleteFunc(func(k cacheKey) bool { return b.Matches(k.q.QName) })
+	}
+}
+
+// freshKey marks contexts of queries that must not be answered from cache.
+type freshKey struct{}
+
+// WithoutCache returns a context marking the query to be answered by an
+// upstream rather than the response cache. The fresh answer is still
+// cached. Health checks use it so a cached answer cannot hide an upstream
+// outage.
+func WithoutCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, freshKey{}, true)
+}
+
+// cacheSkipped reports whether ctx was marked with WithoutCache.
+func cacheSkipped(ctx context.Context) bool {
+	fresh, _ := ctx.Value(freshKey{}).(bool)
+	return fresh
+}
@@ -0,0 +1,108 @@
+package resolvers
+
+import (
+	"context"
+	"net/netip"
+
+	"github.com/jroosing/hydradns/internal/dns"
+)
+
+// CaptivePortal configures a CaptivePortalResolver.
+type CaptivePortal struct {
+	// Clients are the redirected clients.
+	Clients []netip.Prefix
+	// IPv4 and IPv6 answer A and AAAA queries. An invalid (zero) address
+	// leaves that family without an answer.
+	IPv4, IPv6 netip.Addr
+	// AllowDomains, with their subdomains, resolve normally.
+	AllowDomains []string
+	// TTL is the TTL of redirect answers.
+	TTL uint32
+}
+
+// CaptivePortalResolver sends clients on onboarding networks to a captive
+// portal. Queries from the configured clients are answered before
+// filtering or forwarding: A and AAAA queries with the portal addresses,
+// other types with an empty NOERROR (NODATA) response. Allowed domains and
+// queries from other clients go to the next resolver.
+//
+// The client is taken from the query context (see WithClient); queries
+// without a client, such as canary queries, are never redirected.
+type CaptivePortalResolver struct {
+	next   Resolver
+	portal CaptivePortal
+	allow  []string
+}
+
+// NewCaptivePortalResolver creates a captive portal redirect in front of
+// next.
+func NewCaptivePortalResolver(portal CaptivePortal, next Resolver) *CaptivePortalResolver {
+	allow := make([]string, 0, len(portal.AllowDomains))
+	for _, d := range portal.AllowDomains {
+		allow = append(allow, normalizeZone(d))
+	}
+	return &CaptivePortalResolver{next: next, portal: portal, allow: allow}
+}
+
+// Resolve answers queries from redirected clients with the portal and
+// passes all others to the next resolver.
+func (c *CaptivePortalResolver) Resolve(ctx context.Context, req dns.Packet, reqBytes []byte) (Result, error) {
+	if len(req.Questions) == 0 || !c.redirects(ctx, req.Questions[0].Name) {
+		return c.next.Resolve(ctx, req, reqBytes)
+	}
+
+	q := req.Questions[0]
+	var answers []dns.Record
+	switch {
+	case q.Type == uint16(dns.TypeA) && c.portal.IPv4.IsValid():
+		h := dns.NewRRHeader(q.Name, dns.RecordClass(q.Class), c.portal.TTL)
+		answers = append(answers, dns.NewIPRecord(h, c.portal.IPv4.AsSlice()))
+	case q.Type == uint16(dns.TypeAAAA) && c.portal.IPv6.IsValid():
+		h := dns.NewRRHeader(q.Name, dns.RecordClass(q.Class), c.portal.TTL)
+		answers = append(answers, dns.NewIPRecord(h, c.portal.IPv6.AsSlice()))
+	}
+
+	resp := dns.Packet{
+		Header: dns.Header{
+			ID:    req.Header.ID,
+			Flags: buildBlockedFlags(req.Header.Flags, dns.RCodeNoError),
+		},
+		Questions: req.Questions,
+		Answers:   answers,
+	}
+	b, err := resp.Marshal()
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{ResponseBytes: b, Source: "captive-portal"}, nil
+}
+
+// redirects reports whether a query for name in ctx goes to the portal.
+func (c *CaptivePortalResolver) redirects(ctx context.Context, name string) bool {
+	client, ok := ClientFromContext(ctx)
+	if !ok || !c.redirectsClient(client) {
+		return false
+	}
+	name = normalizeZone(name)
+	for _, zone := range c.allow {
+		if isSubdomain(name, zone) {
+			return false
+		}
+	}
+	return true
+}
+
+// redirectsClient reports whether client is on a redirected network.
+func (c *CaptivePortalResolver) redirectsClient(client netip.Addr) bool {
+	for _, p := range c.portal.Clients {
+		if p.Contains(client) {
+			return true
+		}
+	}
+	return false
+}
+
+// Close closes the next resolver.
+func (c *CaptivePortalResolver) Close() error {
+	return c.next.Close()
+}
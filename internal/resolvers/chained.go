@@ -1,8 +1,13 @@
 package resolvers
 
 import (
+	"cmp"
 	"context"
 	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/jroosing/hydradns/internal/dns"
 )
@@ -17,8 +22,20 @@ import (
 //  3. ForwardingResolver - forward to upstream if not found locally
 //
 // The first resolver to return a successful result (without error) wins.
-// If a resolver blocks the query, that result is returned immediately.
-// If all resolvers fail, the last error is returned.
+// A resolver without data for the name returns ErrNotInZone, and the next
+// resolver is asked. Any other error ends the chain and is returned as a
+// *StageError naming the resolver: the resolver is responsible for the name
+// but cannot answer it, e.g. a broken CNAME chain (ErrCNAMEChain), a block
+// (ErrBlocked), or upstream failure (ErrNoUpstream), so asking the next
+// resolver would be wrong. If every resolver returns ErrNotInZone, the last
+// error is returned.
+//
+// Stages:
+//
+// Resolvers are tried first, as plain stages, then Stages. A Stage adds a
+// name for error reports, a timeout budget, and a skip condition that is
+// checked before the resolver is called, so a query a stage cannot answer,
+// such as a name outside a local zone, moves on without paying for a lookup.
 //
 // Context Handling:
 //
@@ -26,6 +43,66 @@ import (
 // This allows graceful shutdown during concurrent query processing.
 type Chained struct {
 	Resolvers []Resolver
+	Stages    []Stage
+}
+
+// Stage is a resolver in a Chained resolver with its own limits.
+type Stage struct {
+	// Name identifies the stage in a StageError, e.g. "custom-dns".
+	Name     string
+	Resolver Resolver
+
+	// Timeout bounds the stage through its context; a stage that runs out
+	// of time fails with ErrTimeout instead of holding up the query. Zero
+	// leaves only the caller's deadline.
+	Timeout time.Duration
+
+	// Skip, if set, reports whether to pass req on to the next stage
+	// without calling the resolver, as if it had returned ErrNotInZone. It
+	// runs on every query and must be cheap.
+	Skip func(req dns.Packet) bool
+}
+
+// StageError is the error of the stage that ended a Chained resolution. It
+// wraps the resolver's error, so errors.Is and errors.As see through it.
+type StageError struct {
+	Stage string // Stage name, or "#<n>" for an unnamed stage at index n
+	Err   error
+}
+
+func (e *StageError) Error() string {
+	return "resolver stage " + e.Stage + ": " + e.Err.Error()
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+// SkipUnlessSuffix returns a Stage.Skip condition that skips queries for
+// names outside the given zones. Zones are compared case-insensitively and
+// without a trailing dot; a query without a question is not skipped.
+func SkipUnlessSuffix(zones ...string) func(req dns.Packet) bool {
+	normalized := make([]string, 0, len(zones))
+	for _, z := range zones {
+		normalized = append(normalized, normalizeZone(z))
+	}
+	return func(req dns.Packet) bool {
+		if len(req.Questions) == 0 {
+			return false
+		}
+		name := normalizeZone(req.Questions[0].Name)
+		for _, z := range normalized {
+			if isSubdomain(name, z) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// normalizeZone lowercases name and drops its trailing dot.
+func normalizeZone(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
 }
 
 // Resolve tries each resolver in order until one succeeds.
@@ -33,25 +110,54 @@ type Chained struct {
 func (c *Chained) Resolve(ctx context.Context, req dns.Packet, reqBytes []byte) (Result, error) {
 	var lastErr error
 
-	for _, r := range c.Resolvers {
+	n := len(c.Resolvers) + len(c.Stages)
+	for i := range n {
 		// Check for cancellation before each resolver
 		if ctx.Err() != nil {
 			return Result{}, ctx.Err()
 		}
 
-		res, err := r.Resolve(ctx, req, reqBytes)
+		var st Stage
+		if i < len(c.Resolvers) {
+			st.Resolver = c.Resolvers[i]
+		} else {
+			st = c.Stages[i-len(c.Resolvers)]
+		}
+		if st.Skip != nil && st.Skip(req) {
+			continue
+		}
+
+		res, err := st.resolve(ctx, req, reqBytes)
 		if err == nil {
 			return res, nil
 		}
+		if !errors.Is(err, ErrNotInZone) {
+			return Result{}, &StageError{Stage: cmp.Or(st.Name, "#"+strconv.Itoa(i)), Err: err}
+		}
 		lastErr = err
 	}
 
 	if lastErr == nil {
-		lastErr = errors.New("no resolver could answer")
+		lastErr = fmt.Errorf("no resolver could answer: %w", ErrNotInZone)
 	}
 	return Result{}, lastErr
 }
 
+// resolve calls the stage's resolver within its timeout budget.
+func (st Stage) resolve(ctx context.Context, req dns.Packet, reqBytes []byte) (Result, error) {
+	if st.Timeout <= 0 {
+		return st.Resolver.Resolve(ctx, req, reqBytes)
+	}
+	stageCtx, cancel := context.WithTimeout(ctx, st.Timeout)
+	defer cancel()
+	res, err := st.Resolver.Resolve(stageCtx, req, reqBytes)
+	if err != nil && ctx.Err() == nil && stageCtx.Err() != nil && !errors.Is(err, ErrTimeout) {
+		// The budget ran out, not the caller's time
+		err = fmt.Errorf("%w after %v: %w", ErrTimeout, st.Timeout, err)
+	}
+	return res, err
+}
+
 // Close releases resources from all child resolvers.
 // Returns the last error encountered (all resolvers are closed regardless of errors).
 func (c *Chained) Close() error {
@@ -61,5 +167,10 @@ func (c *Chained) Close() error {
 			lastErr = err
 		}
 	}
+	for _, st := range c.Stages {
+		if err := st.Resolver.Close(); err != nil {
+			lastErr = err
+		}
+	}
 	return lastErr
 }
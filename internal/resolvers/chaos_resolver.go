@@ -0,0 +1,108 @@
+package resolvers
+
+import (
+	"context"
+
+	"github.com/jroosing/hydradns/internal/dns"
+)
+
+// qtypeANY is the QTYPE asking for all records of a name (RFC 1035 §3.2.3).
+const qtypeANY = 255
+
+// ChaosIdentity holds the answers to CHAOS-class identity queries. An empty
+// value refuses the query.
+type ChaosIdentity struct {
+	// Version answers version.bind and version.server
+	Version string
+	// Hostname answers hostname.bind
+	Hostname string
+	// ServerID answers id.server (RFC 4892); Hostname is used when empty
+	ServerID string
+}
+
+// ChaosResolver answers CHAOS-class queries itself and passes every other
+// query to the next resolver. It sits in front of the whole chain so CHAOS
+// queries are never filtered or forwarded: an upstream's version.bind would
+// identify the wrong server.
+//
+// TXT (and ANY) queries for a configured identity name get that value;
+// other types get an empty answer. Identity names without a value and all
+// other CHAOS names are REFUSED.
+type ChaosResolver struct {
+	next    Resolver
+	answers map[string]string
+}
+
+// NewChaosResolver creates a resolver answering CHAOS identity queries from
+// id in front of next.
+func NewChaosResolver(id ChaosIdentity, next Resolver) *ChaosResolver {
+	serverID := id.ServerID
+	if serverID == "" {
+		serverID = id.Hostname
+	}
+	answers := make(map[string]string, 4)
+	for name, value := range map[string]string{
+		"version.bind":   id.Version,
+		"version.server": id.Version,
+		"hostname.bind":  id.Hostname,
+		"id.server":      serverID,
+	} {
+		if value != "" {
+			answers[name] = value
+		}
+	}
+	return &ChaosResolver{next: next, answers: answers}
+}
+
+// Resolve answers CHAOS-class queries and passes others to the next resolver.
+func (c *ChaosResolver) Resolve(ctx context.Context, req dns.Packet, reqBytes []byte) (Result, error) {
+	if len(req.Questions) == 0 || req.Questions[0].Class != uint16(dns.ClassCH) {
+		return c.next.Resolve(ctx, req, reqBytes)
+	}
+
+	q := req.Questions[0]
+	value, ok := c.answers[normalizeName(q.Name)]
+	if !ok {
+		b, err := dns.BuildErrorResponse(req, uint16(dns.RCodeRefused)).Marshal()
+		if err != nil {
+			return Result{}, err
+		}
+		return Result{ResponseBytes: b, Source: "chaos"}, nil
+	}
+
+	var answers []dns.Record
+	if q.Type == uint16(dns.TypeTXT) || q.Type == qtypeANY {
+		header := dns.NewRRHeader(q.Name, dns.ClassCH, 0)
+		answers = append(answers, dns.NewOpaqueRecord(header, dns.TypeTXT, txtRData(value)))
+	}
+
+	resp := dns.Packet{
+		Header: dns.Header{
+			ID:    req.Header.ID,
+			Flags: buildCustomDNSFlags(req.Header.Flags),
+		},
+		Questions: []dns.Question{q},
+		Answers:   answers,
+	}
+	b, err := resp.Marshal()
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{ResponseBytes: b, Source: "chaos"}, nil
+}
+
+// Close closes the next resolver.
+func (c *ChaosResolver) Close() error {
+	return c.next.Close()
+}
+
+// txtRData encodes s as TXT RDATA holding a single character-string. The
+// config limits identity values to the 255 bytes one string can hold.
+func txtRData(s string) []byte {
+	if len(s) > 255 {
+		s = s[:255]
+	}
+	b := make([]byte, 0, len(s)+1)
+	b = append(b, byte(len(s)))
+	return append(b, s...)
+}
@@ -0,0 +1,52 @@
+package resolvers
+
+import (
+	"context"
+	"net/netip"
+)
+
+// ClientInfo describes the client that sent a query and how it arrived. The
+// query handler sets it on the context passed to resolvers, so features
+// depending on the client (views, per-client policy, logging) read it the
+// same way.
+type ClientInfo struct {
+	Addr      netip.Addr // Client address, IPv4-mapped addresses unmapped; invalid when unknown
+	Transport string     // "udp" or "tcp"
+	Listener  string     // Listener profile the query arrived on; empty for the main listeners
+	TraceID   string     // Identifies the query across log lines
+}
+
+// clientKey carries the ClientInfo of the client that sent a query.
+type clientKey struct{}
+
+// WithClientInfo returns a context carrying info about the client that sent
+// the query.
+func WithClientInfo(ctx context.Context, info ClientInfo) context.Context {
+	info.Addr = info.Addr.Unmap()
+	return context.WithValue(ctx, clientKey{}, info)
+}
+
+// ClientInfoFromContext returns the ClientInfo set with WithClientInfo or
+// WithClient. ok is false for queries the server makes itself, such as
+// canary queries.
+func ClientInfoFromContext(ctx context.Context) (info ClientInfo, ok bool) {
+	info, ok = ctx.Value(clientKey{}).(ClientInfo)
+	return info, ok
+}
+
+// WithClient returns a context carrying the address of the client that sent
+// the query, for resolvers whose answers depend on the client. Other
+// ClientInfo already on ctx is kept.
+func WithClient(ctx context.Context, addr netip.Addr) context.Context {
+	info, _ := ClientInfoFromContext(ctx)
+	info.Addr = addr
+	return WithClientInfo(ctx, info)
+}
+
+// ClientFromContext returns the client address set with WithClient or
+// WithClientInfo. ok is false for queries the server makes itself, such as
+// canary queries, and when the address is unknown.
+func ClientFromContext(ctx context.Context) (addr netip.Addr, ok bool) {
+	info, _ := ClientInfoFromContext(ctx)
+	return info.Addr, info.Addr.IsValid()
+}
@@ -0,0 +1,235 @@
+package resolvers
+
+import (
+	"context"
+	"encoding/binary"
+	"net/netip"
+	"strings"
+	"sync"
+
+	"github.com/jroosing/hydradns/internal/dns"
+)
+
+// ClusterMember is one cluster node published by a ClusterDiscoveryResolver.
+type ClusterMember struct {
+	NodeID string
+	// Role is the node's cluster role, e.g. "primary" or "secondary".
+	Role string
+	// Addr is the node's address; nodes without one get no SRV records.
+	Addr netip.Addr
+	// DNSPort and APIPort are the node's DNS and API ports; 0 leaves out
+	// the node's record for that service.
+	DNSPort, APIPort uint16
+}
+
+// ClusterMembersFunc returns the cluster members and a generation number
+// that changes whenever they do.
+type ClusterMembersFunc func() ([]ClusterMember, uint64)
+
+// ClusterDiscovery configures a ClusterDiscoveryResolver.
+type ClusterDiscovery struct {
+	// Zone is the zone the records are published in.
+	Zone string
+	// TTL is the TTL of discovery answers.
+	TTL uint32
+	// Members returns the members to publish.
+	Members ClusterMembersFunc
+}
+
+// clusterSecondaryPriority is the SRV priority of API endpoints on
+// secondaries; the primary's has priority 0, so tools prefer it.
+const clusterSecondaryPriority = 10
+
+// ClusterDiscoveryResolver publishes the cluster members in a local zone,
+// so tools can find every HydraDNS node through DNS itself:
+//
+//	_dns._udp.<zone>       SRV  0 0 <dns port> <node>.<zone>
+//	_hydradns._tcp.<zone>  SRV  0|10 0 <api port> <node>.<zone>
+//	<node>.<zone>          A/AAAA <address>
+//	<node>.<zone>          TXT  "role=<role>" "node_id=<id>"
+//
+// where <node> is the node ID made into a DNS label. SRV answers carry the
+// targets' addresses as additional data. The records are rebuilt whenever
+// the member generation changes. Other types at these names, and the zone
+// itself, get an empty answer; every other name under the zone gets
+// NXDOMAIN. All other queries go to the next resolver.
+type ClusterDiscoveryResolver struct {
+	next Resolver
+	disc ClusterDiscovery
+
+	mu         sync.Mutex
+	built      bool
+	generation uint64
+	records    map[string][]discoveryRecord // By owner name; names without records exist too
+}
+
+// discoveryRecord is a published record and, for SRV records, the target
+// whose addresses go in the additional section.
+type discoveryRecord struct {
+	rr     dns.Record
+	target string
+}
+
+// NewClusterDiscoveryResolver creates a resolver publishing the members of
+// disc in front of next.
+func NewClusterDiscoveryResolver(disc ClusterDiscovery, next Resolver) *ClusterDiscoveryResolver {
+	disc.Zone = normalizeZone(disc.Zone)
+	return &ClusterDiscoveryResolver{next: next, disc: disc}
+}
+
+// Resolve answers queries in the discovery zone and passes others to the
+// next resolver.
+func (d *ClusterDiscoveryResolver) Resolve(ctx context.Context, req dns.Packet, reqBytes []byte) (Result, error) {
+	if len(req.Questions) == 0 || req.Questions[0].Class != uint16(dns.ClassIN) {
+		return d.next.Resolve(ctx, req, reqBytes)
+	}
+
+	q := req.Questions[0]
+	name := normalizeZone(q.Name)
+	if !isSubdomain(name, d.disc.Zone) {
+		return d.next.Resolve(ctx, req, reqBytes)
+	}
+
+	zone := d.zone()
+	records, ok := zone[name]
+	if !ok {
+		b, err := dns.BuildErrorResponse(req, uint16(dns.RCodeNXDomain)).Marshal()
+		if err != nil {
+			return Result{}, err
+		}
+		return Result{ResponseBytes: b, Source: "cluster-discovery"}, nil
+	}
+
+	resp := dns.Packet{
+		Header: dns.Header{
+			ID:    req.Header.ID,
+			Flags: buildCustomDNSFlags(req.Header.Flags),
+		},
+		Questions: []dns.Question{q},
+	}
+	for _, r := range records {
+		if q.Type != qtypeANY && uint16(r.rr.Type()) != q.Type {
+			continue
+		}
+		resp.Answers = append(resp.Answers, r.rr)
+		if r.target != "" {
+			resp.Additionals = append(resp.Additionals, addressRecords(zone, r.target)...)
+		}
+	}
+	b, err := resp.Marshal()
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{ResponseBytes: b, Source: "cluster-discovery"}, nil
+}
+
+// zone returns the records by owner name, rebuilt if the members changed.
+func (d *ClusterDiscoveryResolver) zone() map[string][]discoveryRecord {
+	members, generation := d.disc.Members()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.built || generation != d.generation {
+		d.records = d.build(members)
+		d.generation = generation
+		d.built = true
+	}
+	return d.records
+}
+
+// build returns the records publishing members. Members whose node ID
+// yields no usable name, or the name of an earlier member, are left out.
+func (d *ClusterDiscoveryResolver) build(members []ClusterMember) map[string][]discoveryRecord {
+	dnsName := "_dns._udp." + d.disc.Zone
+	apiName := "_hydradns._tcp." + d.disc.Zone
+	records := map[string][]discoveryRecord{
+		d.disc.Zone:           nil,
+		"_udp." + d.disc.Zone: nil,
+		"_tcp." + d.disc.Zone: nil,
+		dnsName:               nil,
+		apiName:               nil,
+	}
+
+	for _, m := range members {
+		label := memberLabel(m.NodeID)
+		if label == "" {
+			continue
+		}
+		target := label + "." + d.disc.Zone
+		if _, taken := records[target]; taken {
+			continue
+		}
+		encoded, err := dns.EncodeName(target)
+		if err != nil {
+			continue
+		}
+
+		h := dns.NewRRHeader(target, dns.ClassIN, d.disc.TTL)
+		txt := append(txtRData("role="+m.Role), txtRData("node_id="+m.NodeID)...)
+		records[target] = []discoveryRecord{{rr: dns.NewOpaqueRecord(h, dns.TypeTXT, txt)}}
+		if !m.Addr.IsValid() {
+			continue
+		}
+		records[target] = append(records[target], discoveryRecord{rr: dns.NewIPRecord(h, m.Addr.Unmap().AsSlice())})
+
+		if m.DNSPort != 0 {
+			records[dnsName] = append(records[dnsName],
+				discoveryRecord{rr: d.srv(dnsName, 0, m.DNSPort, encoded), target: target})
+		}
+		if m.APIPort != 0 {
+			var priority uint16
+			if m.Role != "primary" {
+				priority = clusterSecondaryPriority
+			}
+			records[apiName] = append(records[apiName],
+				discoveryRecord{rr: d.srv(apiName, priority, m.APIPort, encoded), target: target})
+		}
+	}
+	return records
+}
+
+// srv returns an SRV record (RFC 2782) owned by name pointing to the
+// encoded target.
+func (d *ClusterDiscoveryResolver) srv(name string, priority, port uint16, target []byte) dns.Record {
+	rdata := make([]byte, 6, 6+len(target))
+	binary.BigEndian.PutUint16(rdata[0:], priority)
+	binary.BigEndian.PutUint16(rdata[2:], 0) // Weight
+	binary.BigEndian.PutUint16(rdata[4:], port)
+	return dns.NewOpaqueRecord(dns.NewRRHeader(name, dns.ClassIN, d.disc.TTL), dns.TypeSRV, append(rdata, target...))
+}
+
+// addressRecords returns the address records of target in zone, for the
+// additional section.
+func addressRecords(zone map[string][]discoveryRecord, target string) []dns.Record {
+	var out []dns.Record
+	for _, r := range zone[target] {
+		if r.rr.Type() == dns.TypeA || r.rr.Type() == dns.TypeAAAA {
+			out = append(out, r.rr)
+		}
+	}
+	return out
+}
+
+// memberLabel makes nodeID into a DNS label: lowercased, with characters
+// other than letters, digits and hyphens replaced by hyphens, and at most
+// 63 characters.
+func memberLabel(nodeID string) string {
+	var sb strings.Builder
+	for _, c := range strings.ToLower(nodeID) {
+		if c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' {
+			sb.WriteRune(c)
+		} else {
+			sb.WriteByte('-')
+		}
+	}
+	label := strings.Trim(sb.String(), "-")
+	if len(label) > 63 {
+		label = strings.TrimRight(label[:63], "-")
+	}
+	return label
+}
+
+// Close closes the next resolver.
+func (d *ClusterDiscoveryResolver) Close() error {
+	return d.next.Close()
+}
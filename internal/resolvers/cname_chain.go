@@ -0,0 +1,73 @@
+package resolvers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jroosing/hydradns/internal/dns"
+)
+
+// DefaultMaxCNAMEChain is the default maximum number of CNAME records
+// followed from the query name before resolution is abandoned.
+const DefaultMaxCNAMEChain = 8
+
+// ErrCNAMEChain is returned when a CNAME chain loops back on itself or is
+// longer than the configured maximum. Such queries are answered with
+// SERVFAIL instead of a partial answer, and are not passed on to the next
+// resolver in a Chained resolver.
+var ErrCNAMEChain = errors.New("CNAME chain loops or is too long")
+
+// followCNAMEChain walks a CNAME chain from name using next, which returns
+// the target of a name's CNAME, and calls visit for every hop. It returns
+// the final name of the chain.
+//
+// An error wrapping ErrCNAMEChain is returned when a name repeats or more
+// than maxChain CNAMEs would be followed.
+func followCNAMEChain(name string, maxChain int, next func(string) (string, bool), visit func(owner, target string)) (string, error) {
+	if maxChain <= 0 {
+		maxChain = DefaultMaxCNAMEChain
+	}
+	start := name
+	seen := map[string]struct{}{name: {}}
+	for hops := 0; ; hops++ {
+		target, ok := next(name)
+		if !ok {
+			return name, nil
+		}
+		if _, loop := seen[target]; loop {
+			return "", fmt.Errorf("%w: loop at %s", ErrCNAMEChain, target)
+		}
+		if hops == maxChain {
+			return "", fmt.Errorf("%w: more than %d CNAMEs from %s", ErrCNAMEChain, maxChain, start)
+		}
+		seen[target] = struct{}{}
+		if visit != nil {
+			visit(name, target)
+		}
+		name = target
+	}
+}
+
+// checkCNAMEChain verifies that the CNAME records in an upstream answer
+// section form a finite chain from qname of at most maxChain links.
+func checkCNAMEChain(qname string, answers []dns.Record, maxChain int) error {
+	var links map[string]string
+	for _, rr := range answers {
+		cname, ok := rr.(*dns.NameRecord)
+		if !ok || cname.Type() != dns.TypeCNAME {
+			continue
+		}
+		if links == nil {
+			links = make(map[string]string)
+		}
+		links[dns.NormalizeName(cname.Header().Name)] = dns.NormalizeName(cname.Target)
+	}
+	if links == nil {
+		return nil
+	}
+	_, err := followCNAMEChain(dns.NormalizeName(qname), maxChain, func(name string) (string, bool) {
+		target, ok := links[name]
+		return target, ok
+	}, nil)
+	return err
+}
@@ -3,10 +3,16 @@ package resolvers
 import (
 	"context"
 	"errors"
+	"fmt"
+	"maps"
+	"math/rand/v2"
 	"net/netip"
+	"slices"
 	"strings"
+	"sync/atomic"
 
 	"github.com/jroosing/hydradns/internal/dns"
+	"github.com/jroosing/hydradns/internal/helpers"
 )
 
 // CustomDNSResolver provides simple A/AAAA/CNAME resolution from configuration.
@@ -20,9 +26,77 @@ import (
 // Responses are marked as authoritative (AA flag set) for configured domains.
 // Name normalization converts domains to lowercase without trailing dots,
 // making lookups case-insensitive per RFC 1035.
+//
+// Names with several addresses are answered in the order set with
+// SetAnswerOrder, so clients that use the first address spread over them.
+// Addresses reported unhealthy by the AddressHealth set with
+// SetAddressHealth are left out, and names steered with SetSteering are
+// answered by client subnet and weight.
 type CustomDNSResolver struct {
 	hosts  map[string][]netip.Addr // normalized name -> IP addresses
 	cnames map[string]string       // normalized alias -> normalized canonical name
+	zones  []string                // Parent zones of the configured names, for Skip
+
+	duplicateAliases []string // Aliases configured under several spellings with different targets
+
+	maxCNAMEChain int      // Maximum CNAMEs followed per query
+	searchDomains []string // Normalized suffixes tried for single-label names
+
+	answerOrder AnswerOrder
+	rotations   map[string]*atomic.Uint32 // Round-robin position of names with several addresses
+
+	health   AddressHealth                         // nil: all addresses are healthy
+	steering map[string]map[netip.Addr]SteeredAddr // normalized name -> steered addresses
+}
+
+// SteeredAddr is how one address of a steered name is answered (see
+// config.HostSteering).
+type SteeredAddr struct {
+	Addr    netip.Addr
+	Weight  int            // Share of answers; 0 answers only when no match has a weight
+	Clients []netip.Prefix // Clients answered this address; empty: clients matching no other
+}
+
+// AddressHealth reports whether an address of a custom host passes its
+// health check. Addresses without a check are healthy.
+type AddressHealth interface {
+	Healthy(name string, addr netip.Addr) bool
+	// Checked reports whether name has a health check.
+	Checked(name string) bool
+}
+
+// customTTL is the TTL of custom answers. Addresses of names with a health
+// check get checkedTTL instead, so clients notice a failover quickly.
+const (
+	customTTL  = 3600
+	checkedTTL = 10
+)
+
+// AnswerOrder is the order of the addresses in a custom DNS response.
+type AnswerOrder int
+
+const (
+	// AnswerOrderFixed answers in configured order.
+	AnswerOrderFixed AnswerOrder = iota
+	// AnswerOrderRoundRobin rotates the addresses by one for each query.
+	AnswerOrderRoundRobin
+	// AnswerOrderRandom shuffles the addresses for each query.
+	AnswerOrderRandom
+)
+
+// ParseAnswerOrder parses "fixed", "round_robin", or "random". An empty
+// string selects AnswerOrderFixed.
+func ParseAnswerOrder(s string) (AnswerOrder, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "fixed":
+		return AnswerOrderFixed, nil
+	case "round_robin":
+		return AnswerOrderRoundRobin, nil
+	case "random":
+		return AnswerOrderRandom, nil
+	default:
+		return AnswerOrderFixed, fmt.Errorf("unknown answer order %q", s)
+	}
 }
 
 // NewCustomDNSResolver creates a CustomDNSResolver from host and CNAME mappings.
@@ -37,85 +111,314 @@ func NewCustomDNSResolver(hosts map[string][]string, cnames map[string]string) (
 		cnames: make(map[string]string),
 	}
 
-	// Parse and normalize hosts
-	for name, ips := range hosts {
+	// Parse and normalize hosts. Keys are visited in sorted order so that
+	// spellings differing only in case or a trailing dot ("Test.Local" and
+	// "test.local.") merge deterministically into one entry.
+	for _, name := range slices.Sorted(maps.Keys(hosts)) {
 		normalized := normalizeName(name)
-		var addrs []netip.Addr
-		for _, ip := range ips {
+		addrs := r.hosts[normalized]
+		for _, ip := range hosts[name] {
 			addr, err := netip.ParseAddr(strings.TrimSpace(ip))
 			if err != nil {
 				return nil, errors.New("invalid IP address for " + name + ": " + ip)
 			}
-			addrs = append(addrs, addr)
+			if !slices.Contains(addrs, addr) {
+				addrs = append(addrs, addr)
+			}
 		}
 		if len(addrs) > 0 {
 			r.hosts[normalized] = addrs
 		}
 	}
 
-	// Normalize CNAMEs
-	for alias, target := range cnames {
-		r.cnames[normalizeName(alias)] = normalizeName(target)
+	r.rotations = make(map[string]*atomic.Uint32)
+	for name, addrs := range r.hosts {
+		if len(addrs) > 1 {
+			r.rotations[name] = new(atomic.Uint32)
+		}
 	}
 
+	// Normalize CNAMEs. The API rejects aliases that differ only in case,
+	// but older databases may hold them: when their targets disagree, the
+	// canonical spelling wins, else the first in sorted order.
+	for _, alias := range slices.Sorted(maps.Keys(cnames)) {
+		normalized := normalizeName(alias)
+		target := normalizeName(cnames[alias])
+		if existing, ok := r.cnames[normalized]; ok && existing != target {
+			if !slices.Contains(r.duplicateAliases, normalized) {
+				r.duplicateAliases = append(r.duplicateAliases, normalized)
+			}
+			if alias != normalized {
+				continue
+			}
+		}
+		r.cnames[normalized] = target
+	}
+
+	r.zones = configuredZones(r.hosts, r.cnames)
 	return r, nil
 }
 
+// configuredZones returns the sorted parent zones of the configured names.
+// A single-label name is its own zone.
+func configuredZones(hosts map[string][]netip.Addr, cnames map[string]string) []string {
+	seen := make(map[string]struct{}, len(hosts)+len(cnames))
+	add := func(name string) {
+		if _, parent, ok := strings.Cut(name, "."); ok {
+			name = parent
+		}
+		seen[name] = struct{}{}
+	}
+	for name := range hosts {
+		add(name)
+	}
+	for name := range cnames {
+		add(name)
+	}
+	return slices.Sorted(maps.Keys(seen))
+}
+
+// DuplicateAliases returns the aliases configured under several spellings
+// with different targets, of which only one is answered.
+func (r *CustomDNSResolver) DuplicateAliases() []string {
+	return r.duplicateAliases
+}
+
+// SetMaxCNAMEChain sets the maximum number of CNAMEs followed for one
+// query. Non-positive values select DefaultMaxCNAMEChain.
+// Must be called before the resolver starts serving queries.
+func (r *CustomDNSResolver) SetMaxCNAMEChain(n int) {
+	r.maxCNAMEChain = n
+}
+
+// SetSearchDomains sets the domains appended, in order, to single-label
+// queries that match no configured name. A match is answered with a CNAME
+// from the short name to the expanded one, followed by its records.
+// Must be called before the resolver starts serving queries.
+func (r *CustomDNSResolver) SetSearchDomains(domains []string) {
+	r.searchDomains = make([]string, 0, len(domains))
+	for _, d := range domains {
+		if d = normalizeName(d); d != "" {
+			r.searchDomains = append(r.searchDomains, d)
+		}
+	}
+}
+
+// SetAnswerOrder sets the order of the addresses of names with several A or
+// AAAA records. The default is AnswerOrderFixed.
+// Must be called before the resolver starts serving queries.
+func (r *CustomDNSResolver) SetAnswerOrder(o AnswerOrder) {
+	r.answerOrder = o
+}
+
+// SetAddressHealth sets the health of the configured addresses. Unhealthy
+// addresses are left out of answers, unless all addresses of a name for the
+// query type are unhealthy: then all are answered, since a possibly broken
+// address is more useful to clients than none. Addresses of checked names
+// are answered with a short TTL.
+// Must be called before the resolver starts serving queries.
+func (r *CustomDNSResolver) SetAddressHealth(h AddressHealth) {
+	r.health = h
+}
+
+// SetSteering sets the addresses steered per name. For each query for a
+// steered name, the client is matched to the addresses listing its subnet,
+// else to those without clients, else to all. If a matched address has a
+// weight, one address is answered, chosen among those with a weight in
+// proportion to the weights; otherwise all matched addresses are answered
+// in answer order. The client is taken from the query context (see
+// WithClient); queries without one match the addresses without clients.
+// Must be called before the resolver starts serving queries.
+func (r *CustomDNSResolver) SetSteering(steering map[string][]SteeredAddr) {
+	r.steering = make(map[string]map[netip.Addr]SteeredAddr, len(steering))
+	for name, addrs := range steering {
+		m := make(map[netip.Addr]SteeredAddr, len(addrs))
+		for _, a := range addrs {
+			a.Addr = a.Addr.Unmap()
+			m[a.Addr] = a
+		}
+		r.steering[normalizeName(name)] = m
+	}
+}
+
+// steer returns the addresses answered to client among addrs, and whether
+// one was chosen by weight.
+func steer(addrs []netip.Addr, steering map[netip.Addr]SteeredAddr, client netip.Addr) ([]netip.Addr, bool) {
+	var local, open []netip.Addr
+	for _, addr := range addrs {
+		s := steering[addr.Unmap()]
+		switch {
+		case len(s.Clients) == 0:
+			open = append(open, addr)
+		case client.IsValid() && slices.ContainsFunc(s.Clients, func(p netip.Prefix) bool { return p.Contains(client) }):
+			local = append(local, addr)
+		}
+	}
+	matched := addrs
+	if len(local) > 0 {
+		matched = local
+	} else if len(open) > 0 {
+		matched = open
+	}
+
+	total := 0
+	for _, addr := range matched {
+		total += steering[addr.Unmap()].Weight
+	}
+	if total == 0 {
+		return matched, false
+	}
+	n := rand.IntN(total)
+	for _, addr := range matched {
+		w := steering[addr.Unmap()].Weight
+		if n < w {
+			return []netip.Addr{addr}, true
+		}
+		n -= w
+	}
+	return matched[:1], true // unreachable: n < total
+}
+
+// addrTTL returns the TTL of the address records of name.
+func (r *CustomDNSResolver) addrTTL(name string) uint32 {
+	if r.health != nil && r.health.Checked(name) {
+		return checkedTTL
+	}
+	return customTTL
+}
+
+// orderAddrs returns the healthy addresses of name matching qtype that are
+// answered to client, in answer order.
+func (r *CustomDNSResolver) orderAddrs(name string, qtype uint16, client netip.Addr) []netip.Addr {
+	var addrs []netip.Addr
+	for _, addr := range r.hosts[name] {
+		if matchesQueryType(addr, qtype) {
+			addrs = append(addrs, addr)
+		}
+	}
+	if r.health != nil {
+		healthy := make([]netip.Addr, 0, len(addrs))
+		for _, addr := range addrs {
+			if r.health.Healthy(name, addr) {
+				healthy = append(healthy, addr)
+			}
+		}
+		if len(healthy) > 0 {
+			addrs = healthy
+		}
+	}
+	if s, ok := r.steering[name]; ok && len(addrs) > 0 {
+		var weighted bool
+		if addrs, weighted = steer(addrs, s, client); weighted {
+			return addrs
+		}
+	}
+	if len(addrs) < 2 {
+		return addrs
+	}
+	switch r.answerOrder {
+	case AnswerOrderRoundRobin:
+		// One position per name, shared by A and AAAA queries
+		n := int((r.rotations[name].Add(1) - 1) % helpers.ClampIntToUint32(len(addrs)))
+		addrs = append(addrs[n:], addrs[:n]...)
+	case AnswerOrderRandom:
+		rand.Shuffle(len(addrs), func(i, j int) { addrs[i], addrs[j] = addrs[j], addrs[i] })
+	}
+	return addrs
+}
+
 // Close is a no-op (implements Resolver interface).
 func (r *CustomDNSResolver) Close() error {
 	return nil
 }
 
 // Resolve answers DNS queries from configured hosts and CNAMEs.
-func (r *CustomDNSResolver) Resolve(_ context.Context, req dns.Packet, _ []byte) (Result, error) {
+func (r *CustomDNSResolver) Resolve(ctx context.Context, req dns.Packet, _ []byte) (Result, error) {
 	if len(req.Questions) == 0 {
-		return Result{}, errors.New("no question in request")
+		return Result{}, fmt.Errorf("%w: no question in request", ErrNotInZone)
 	}
+	client, _ := ClientFromContext(ctx)
 
 	q := req.Questions[0]
 	qname := normalizeName(q.Name)
 
 	// Check for CNAME first
-	if target, ok := r.cnames[qname]; ok {
-		return r.buildCNAMEResponse(req, q, target)
+	if _, ok := r.cnames[qname]; ok {
+		return r.buildCNAMEResponse(req, q, qname, "", client)
 	}
 
 	// Check for A/AAAA records
-	if addrs, ok := r.hosts[qname]; ok {
-		return r.buildAddressResponse(req, q, addrs)
+	if _, ok := r.hosts[qname]; ok {
+		return r.buildAddressResponse(req, q, qname, client)
+	}
+
+	// Try the search domains for single-label names
+	if expanded, ok := r.expandSearch(qname); ok {
+		return r.buildCNAMEResponse(req, q, qname, expanded, client)
 	}
 
 	// Name not found
-	return Result{}, errors.New("name not in custom DNS configuration")
+	return Result{}, fmt.Errorf("%w: not in custom DNS configuration", ErrNotInZone)
 }
 
-// buildCNAMEResponse constructs a CNAME response.
-func (r *CustomDNSResolver) buildCNAMEResponse(req dns.Packet, q dns.Question, target string) (Result, error) {
-	// Create CNAME record
-	header := dns.NewRRHeader(q.Name, dns.RecordClass(q.Class), 3600)
-	cname := dns.NewNameRecord(header, dns.TypeCNAME, target)
+// expandSearch returns the first search domain expansion of a single-label
+// name that is configured.
+func (r *CustomDNSResolver) expandSearch(qname string) (string, bool) {
+	if qname == "" || strings.Contains(qname, ".") {
+		return "", false
+	}
+	for _, domain := range r.searchDomains {
+		name := qname + "." + domain
+		if r.ContainsDomain(name) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// buildCNAMEResponse constructs a CNAME response, following chained
+// aliases. For A/AAAA queries the addresses of the final name are appended
+// when it is a configured host. A non-empty expanded is the search domain
+// expansion of qname, answered as the first CNAME of the chain.
+//
+// Chains that loop or are longer than the configured maximum fail with
+// ErrCNAMEChain rather than returning a partial answer.
+func (r *CustomDNSResolver) buildCNAMEResponse(
+	req dns.Packet,
+	q dns.Question,
+	qname, expanded string,
+	client netip.Addr,
+) (Result, error) {
+	var answers []dns.Record
+	final, err := followCNAMEChain(qname, r.maxCNAMEChain, func(name string) (string, bool) {
+		if name == qname && expanded != "" {
+			return expanded, true
+		}
+		target, ok := r.cnames[name]
+		return target, ok
+	}, func(owner, target string) {
+		header := dns.NewRRHeader(owner, dns.RecordClass(q.Class), customTTL)
+		answers = append(answers, dns.NewNameRecord(header, dns.TypeCNAME, target))
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	// If querying for A/AAAA, try to resolve the end of the chain
+	if q.Type == uint16(dns.TypeA) || q.Type == uint16(dns.TypeAAAA) {
+		for _, addr := range r.orderAddrs(final, q.Type, client) {
+			h := dns.NewRRHeader(final, dns.RecordClass(q.Class), r.addrTTL(final))
+			answers = append(answers, dns.NewIPRecord(h, addr.AsSlice()))
+		}
+	}
 
-	// Build response with CNAME
 	resp := dns.Packet{
 		Header: dns.Header{
 			ID:    req.Header.ID,
 			Flags: buildCustomDNSFlags(req.Header.Flags),
 		},
 		Questions: []dns.Question{q},
-		Answers:   []dns.Record{cname},
-	}
-
-	// If querying for A/AAAA, try to resolve the target
-	if q.Type == uint16(dns.TypeA) || q.Type == uint16(dns.TypeAAAA) {
-		targetNorm := normalizeName(target)
-		if addrs, ok := r.hosts[targetNorm]; ok {
-			for _, addr := range addrs {
-				if matchesQueryType(addr, q.Type) {
-					h := dns.NewRRHeader(target, dns.RecordClass(q.Class), 3600)
-					resp.Additionals = append(resp.Additionals, dns.NewIPRecord(h, addr.AsSlice()))
-				}
-			}
-		}
+		Answers:   answers,
 	}
 
 	b, err := resp.Marshal()
@@ -125,20 +428,24 @@ func (r *CustomDNSResolver) buildCNAMEResponse(req dns.Packet, q dns.Question, t
 	return Result{ResponseBytes: b, Source: "custom-dns"}, nil
 }
 
-// buildAddressResponse constructs an A or AAAA response.
-func (r *CustomDNSResolver) buildAddressResponse(req dns.Packet, q dns.Question, addrs []netip.Addr) (Result, error) {
+// buildAddressResponse constructs an A or AAAA response for the configured
+// host qname, answered to client.
+func (r *CustomDNSResolver) buildAddressResponse(
+	req dns.Packet,
+	q dns.Question,
+	qname string,
+	client netip.Addr,
+) (Result, error) {
 	var answers []dns.Record
 
-	for _, addr := range addrs {
-		if matchesQueryType(addr, q.Type) {
-			header := dns.NewRRHeader(q.Name, dns.RecordClass(q.Class), 3600)
-			answers = append(answers, dns.NewIPRecord(header, addr.AsSlice()))
-		}
+	for _, addr := range r.orderAddrs(qname, q.Type, client) {
+		header := dns.NewRRHeader(q.Name, dns.RecordClass(q.Class), r.addrTTL(qname))
+		answers = append(answers, dns.NewIPRecord(header, addr.AsSlice()))
 	}
 
-	// No matching addresses found
+	// No matching addresses found; upstream may have other record types
 	if len(answers) == 0 {
-		return Result{}, errors.New("no matching address records")
+		return Result{}, fmt.Errorf("%w: no matching address records", ErrNotInZone)
 	}
 
 	resp := dns.Packet{
@@ -169,16 +476,21 @@ func matchesQueryType(addr netip.Addr, qtype uint16) bool {
 }
 
 // buildCustomDNSFlags constructs response flags for custom DNS responses.
-// Sets QR (response) and AA (authoritative). Does not set RA or preserve RD
-// since these are authoritative responses, not recursive lookups.
-func buildCustomDNSFlags(_ uint16) uint16 {
-	return dns.QRFlag | dns.AAFlag
+// Sets QR (response) and AA (authoritative) and copies RD from the request
+// (RFC 1035 Section 4.1.1). RA is left to the query handler, which knows
+// whether recursion is offered to the client.
+func buildCustomDNSFlags(reqFlags uint16) uint16 {
+	return dns.QRFlag | dns.AAFlag | reqFlags&dns.RDFlag
 }
 
-// normalizeName converts a domain name to lowercase and removes trailing dot.
+// normalizeName converts a domain name to its canonical form (lowercase,
+// A-labels, no trailing dot). Invalid IDNs are only lowercased and will
+// simply never match a query.
 func normalizeName(name string) string {
-	name = strings.ToLower(strings.TrimSpace(name))
-	return strings.TrimSuffix(name, ".")
+	if c, err := dns.CanonicalName(name); err == nil {
+		return c
+	}
+	return dns.NormalizeName(strings.TrimSpace(name))
 }
 
 // ContainsDomain checks if a domain is configured in custom DNS.
@@ -193,3 +505,23 @@ func (r *CustomDNSResolver) ContainsDomain(name string) bool {
 func (r *CustomDNSResolver) IsEmpty() bool {
 	return len(r.hosts) == 0 && len(r.cnames) == 0
 }
+
+// Skip reports whether req is for a name outside every zone of the
+// configured names, so Resolve could not answer it. Single-label names are
+// not skipped while search domains are set; a query without a question is
+// not skipped.
+func (r *CustomDNSResolver) Skip(req dns.Packet) bool {
+	if len(req.Questions) == 0 {
+		return false
+	}
+	qname := normalizeName(req.Questions[0].Name)
+	if len(r.searchDomains) > 0 && !strings.Contains(qname, ".") {
+		return false
+	}
+	for _, zone := range r.zones {
+		if isSubdomain(qname, zone) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,144 @@
+package resolvers
+
+import (
+	"context"
+	"net/netip"
+
+	"github.com/jroosing/hydradns/internal/dns"
+)
+
+// ddrZone is the special-use domain of designated resolver discovery
+// (RFC 9462 §6.4); its names are never forwarded.
+const ddrZone = "resolver.arpa"
+
+// ddrName is the name clients query for the designated resolvers of the
+// resolver they know only by address (RFC 9462 §4).
+const ddrName = "_dns." + ddrZone
+
+// DesignatedResolver configures a DDRResolver: the encrypted endpoints
+// advertised to clients.
+type DesignatedResolver struct {
+	// Target is the resolver hostname, which the endpoints' TLS
+	// certificate must cover.
+	Target string
+	// DoHPort and DoTPort are the DoH and DoT ports; 0 leaves that
+	// transport out.
+	DoHPort, DoTPort uint16
+	// DoHPath is the DoH URI template, e.g. "/dns-query{?dns}".
+	DoHPath string
+	// IPv4Hints and IPv6Hints are the addresses of Target.
+	IPv4Hints, IPv6Hints []netip.Addr
+	// TTL is the TTL of DDR answers.
+	TTL uint32
+}
+
+// DDRResolver answers Discovery of Designated Resolvers queries (RFC 9462)
+// itself, so clients that know this server only by address learn its DoH
+// and DoT endpoints and upgrade to an encrypted transport.
+//
+// SVCB (and ANY) queries for _dns.resolver.arpa and for _dns.<target> get
+// one ServiceMode record per endpoint, DoH preferred, with the target's A
+// and AAAA records as additional data. Other types at _dns.resolver.arpa
+// get an empty answer, and every other name under resolver.arpa gets
+// NXDOMAIN. All other queries go to the next resolver.
+type DDRResolver struct {
+	next       Resolver
+	ddr        DesignatedResolver
+	targetName string // _dns.<target>
+}
+
+// NewDDRResolver creates a resolver advertising ddr in front of next.
+func NewDDRResolver(ddr DesignatedResolver, next Resolver) *DDRResolver {
+	ddr.Target = normalizeZone(ddr.Target)
+	return &DDRResolver{next: next, ddr: ddr, targetName: "_dns." + ddr.Target}
+}
+
+// Resolve answers DDR queries and passes others to the next resolver.
+func (d *DDRResolver) Resolve(ctx context.Context, req dns.Packet, reqBytes []byte) (Result, error) {
+	if len(req.Questions) == 0 || req.Questions[0].Class != uint16(dns.ClassIN) {
+		return d.next.Resolve(ctx, req, reqBytes)
+	}
+
+	q := req.Questions[0]
+	name := normalizeZone(q.Name)
+	switch {
+	case name == ddrName:
+	case name == d.targetName && (q.Type == uint16(dns.TypeSVCB) || q.Type == qtypeANY):
+	case isSubdomain(name, ddrZone):
+		b, err := dns.BuildErrorResponse(req, uint16(dns.RCodeNXDomain)).Marshal()
+		if err != nil {
+			return Result{}, err
+		}
+		return Result{ResponseBytes: b, Source: "ddr"}, nil
+	default:
+		return d.next.Resolve(ctx, req, reqBytes)
+	}
+
+	resp := dns.Packet{
+		Header: dns.Header{
+			ID:    req.Header.ID,
+			Flags: buildCustomDNSFlags(req.Header.Flags),
+		},
+		Questions: []dns.Question{q},
+	}
+	if q.Type == uint16(dns.TypeSVCB) || q.Type == qtypeANY {
+		resp.Answers = d.records(q.Name)
+		resp.Additionals = d.hints()
+	}
+	b, err := resp.Marshal()
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{ResponseBytes: b, Source: "ddr"}, nil
+}
+
+// records returns the SVCB records for the configured endpoints, owned by
+// name.
+func (d *DDRResolver) records(name string) []dns.Record {
+	h := dns.NewRRHeader(name, dns.ClassIN, d.ddr.TTL)
+	var records []dns.Record
+	var priority uint16
+	if d.ddr.DoHPort != 0 {
+		priority++
+		records = append(records, dns.NewSVCBRecord(h, priority, d.ddr.Target,
+			d.params(dns.NewSvcParamALPN("h2"), d.ddr.DoHPort, dns.NewSvcParamDoHPath(d.ddr.DoHPath))...))
+	}
+	if d.ddr.DoTPort != 0 {
+		priority++
+		records = append(records, dns.NewSVCBRecord(h, priority, d.ddr.Target,
+			d.params(dns.NewSvcParamALPN("dot"), d.ddr.DoTPort)...))
+	}
+	return records
+}
+
+// params returns the parameters of one endpoint in key order: alpn, port,
+// the address hints, then any extra parameters with higher keys.
+func (d *DDRResolver) params(alpn dns.SvcParam, port uint16, extra ...dns.SvcParam) []dns.SvcParam {
+	params := []dns.SvcParam{alpn, dns.NewSvcParamPort(port)}
+	if len(d.ddr.IPv4Hints) > 0 {
+		params = append(params, dns.NewSvcParamIPv4Hint(d.ddr.IPv4Hints...))
+	}
+	if len(d.ddr.IPv6Hints) > 0 {
+		params = append(params, dns.NewSvcParamIPv6Hint(d.ddr.IPv6Hints...))
+	}
+	return append(params, extra...)
+}
+
+// hints returns the target's A and AAAA records for the additional section
+// (RFC 9462 §4).
+func (d *DDRResolver) hints() []dns.Record {
+	h := dns.NewRRHeader(d.ddr.Target, dns.ClassIN, d.ddr.TTL)
+	var records []dns.Record
+	for _, ip := range d.ddr.IPv4Hints {
+		records = append(records, dns.NewIPRecord(h, ip.AsSlice()))
+	}
+	for _, ip := range d.ddr.IPv6Hints {
+		records = append(records, dns.NewIPRecord(h, ip.AsSlice()))
+	}
+	return records
+}
+
+// Close closes the next resolver.
+func (d *DDRResolver) Close() error {
+	return d.next.Close()
+}
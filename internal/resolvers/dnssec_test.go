@@ -1,7 +1,10 @@
 package resolvers_test
 
 import (
+	"context"
+	"encoding/binary"
 	"testing"
+	"time"
 
 	"github.com/jroosing/hydradns/internal/dns"
 	"github.com/jroosing/hydradns/internal/resolvers"
@@ -171,3 +174,146 @@ func TestPatchTransactionIDPreservesFlags(t *testing.T) {
 	assert.True(t, parsed.Header.RecursionAvailable(), "RA flag should be preserved")
 	assert.False(t, parsed.Header.CheckingDisabled(), "CD flag should not be set")
 }
+
+// ============================================================================
+// Aggressive NSEC Tests
+// ============================================================================
+
+// signedNXDomain answers every query with an NXDOMAIN from the signed zone
+// example.com, whose only other name is m.example.com. The proof holds
+// both NSEC records of the zone; ad sets the AD flag.
+func signedNXDomain(ad bool) func(dns.Packet) dns.Packet {
+	const zone = "example.com"
+	h := func(name string, ttl uint32) dns.RRHeader { return dns.NewRRHeader(name, dns.ClassIN, ttl) }
+	sig := func(name string, covered dns.RecordType) dns.Record {
+		return &dns.RRSIGRecord{
+			H: h(name, 3600), TypeCovered: covered, Algorithm: 13, Labels: 2,
+			OriginalTTL: 3600, KeyTag: 1, SignerName: zone, Signature: []byte{1, 2, 3, 4},
+		}
+	}
+	mname, _ := dns.EncodeName("ns1.example.com.")
+	rname, _ := dns.EncodeName("hostmaster.example.com.")
+	soa := append(append(mname, rname...), make([]byte, 20)...)
+	binary.BigEndian.PutUint32(soa[len(soa)-4:], 300) // MINIMUM
+
+	return func(req dns.Packet) dns.Packet {
+		resp := req
+		resp.Header.Flags |= uint16(dns.RCodeNXDomain)
+		if ad {
+			resp.Header.Flags |= dns.ADFlag
+		}
+		resp.Authorities = []dns.Record{
+			dns.NewOpaqueRecord(h(zone, 3600), dns.TypeSOA, soa),
+			sig(zone, dns.TypeSOA),
+			&dns.NSECRecord{H: h(zone, 3600), NextDomain: "m.example.com", Types: []dns.RecordType{
+				dns.TypeNS, dns.TypeSOA, dns.TypeRRSIG, dns.TypeNSEC, dns.TypeDNSKEY,
+			}},
+			sig(zone, dns.TypeNSEC),
+			&dns.NSECRecord{H: h("m.example.com", 3600), NextDomain: zone, Types: []dns.RecordType{
+				dns.TypeA, dns.TypeRRSIG, dns.TypeNSEC,
+			}},
+			sig("m.example.com", dns.TypeNSEC),
+		}
+		return resp
+	}
+}
+
+func newNSECQuery(t *testing.T, name string, qtype dns.RecordType, do bool) (dns.Packet, []byte) {
+	t.Helper()
+	req := dns.Packet{
+		Header:    dns.Header{ID: 7, Flags: dns.RDFlag},
+		Questions: []dns.Question{{Name: name, Type: uint16(qtype), Class: uint16(dns.ClassIN)}},
+	}
+	if do {
+		opt := dns.CreateOPT(1232)
+		opt.DNSSECOk = true
+		req.Additionals = []dns.Record{opt.Record()}
+	}
+	b, err := req.Marshal()
+	require.NoError(t, err)
+	return req, b
+}
+
+func TestForwardingResolver_AggressiveNSEC(t *testing.T) {
+	queries := startFakeUpstreamPacket(t, 0, signedNXDomain(true))
+	f := resolvers.NewForwardingResolver([]string{fakeUpstreamAddr}, 1, 0, false, time.Second, 0, 1)
+	f.SetAggressiveNSEC(true)
+	t.Cleanup(func() { _ = f.Close() })
+
+	resolve := func(name string, qtype dns.RecordType, do bool) (resolvers.Result, dns.Packet) {
+		t.Helper()
+		req, b := newNSECQuery(t, name, qtype, do)
+		res, err := f.Resolve(context.Background(), req, b)
+		require.NoError(t, err)
+		parsed, err := dns.ParsePacket(res.ResponseBytes)
+		require.NoError(t, err)
+		return res, parsed
+	}
+
+	res, _ := resolve("c.example.com", dns.TypeA, true)
+	assert.Equal(t, "upstream", res.Source)
+	require.Equal(t, int32(1), queries.Load())
+
+	tests := []struct {
+		name  string
+		qtype dns.RecordType
+		rcode dns.RCode
+	}{
+		{"d.example.com", dns.TypeA, dns.RCodeNXDomain},     // Between the apex and m
+		{"zz.example.com", dns.TypeAAAA, dns.RCodeNXDomain}, // After m, wrapping to the apex
+		{"x.c.example.com", dns.TypeA, dns.RCodeNXDomain},   // Below a name that does not exist
+		{"m.example.com", dns.TypeTXT, dns.RCodeNoError},    // NODATA: m has only A
+		{"example.com", dns.TypeMX, dns.RCodeNoError},       // NODATA at the apex
+	}
+	for _, tt := range tests {
+		res, resp := resolve(tt.name, tt.qtype, true)
+		assert.Equal(t, "upstream-nsec", res.Source, tt.name)
+		assert.Equal(t, tt.rcode, dns.RCodeFromFlags(resp.Header.Flags), tt.name)
+		assert.Equal(t, uint16(7), resp.Header.ID, tt.name)
+		assert.True(t, resp.Header.AuthenticData(), tt.name)
+		assert.Empty(t, resp.Answers, tt.name)
+		assert.Equal(t, dns.TypeSOA, resp.Authorities[0].Type(), tt.name)
+		var nsecs int
+		for _, r := range resp.Authorities {
+			if _, ok := r.(*dns.NSECRecord); ok {
+				nsecs++
+			}
+		}
+		assert.Positive(t, nsecs, "%s: DO clients get the proof", tt.name)
+	}
+	assert.Equal(t, int32(1), queries.Load(), "denied names are not sent upstream")
+
+	// Without DO the answer carries only the SOA
+	_, resp := resolve("e.example.com", dns.TypeA, false)
+	require.Len(t, resp.Authorities, 1)
+	assert.Equal(t, dns.TypeSOA, resp.Authorities[0].Type())
+	assert.False(t, resp.Header.AuthenticData())
+
+	// Names and types the chain does not deny still go upstream
+	for _, q := range []struct {
+		name  string
+		qtype dns.RecordType
+	}{
+		{"m.example.com", dns.TypeA},
+		{"other.test", dns.TypeA},
+	} {
+		res, _ := resolve(q.name, q.qtype, true)
+		assert.Equal(t, "upstream", res.Source, q.name)
+	}
+	assert.Equal(t, int32(3), queries.Load())
+}
+
+func TestForwardingResolver_AggressiveNSECNeedsAD(t *testing.T) {
+	queries := startFakeUpstreamPacket(t, 0, signedNXDomain(false))
+	f := resolvers.NewForwardingResolver([]string{fakeUpstreamAddr}, 1, 0, false, time.Second, 0, 1)
+	f.SetAggressiveNSEC(true)
+	t.Cleanup(func() { _ = f.Close() })
+
+	for _, name := range []string{"c.example.com", "d.example.com"} {
+		req, b := newNSECQuery(t, name, dns.TypeA, true)
+		res, err := f.Resolve(context.Background(), req, b)
+		require.NoError(t, err)
+		assert.Equal(t, "upstream", res.Source, name)
+	}
+	assert.Equal(t, int32(2), queries.Load(), "unauthenticated NSEC records are not used")
+}
@@ -0,0 +1,90 @@
+package resolvers
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/jroosing/hydradns/internal/dns"
+)
+
+// EDNSAction is what the forwarder does with an EDNS option.
+type EDNSAction int
+
+const (
+	// EDNSPass forwards the option unchanged. Options without a rule pass.
+	EDNSPass EDNSAction = iota
+	// EDNSStrip removes the option from queries sent upstream and from
+	// upstream responses.
+	EDNSStrip
+	// EDNSRewrite sends the option upstream with fixed data, replacing the
+	// client's value or adding the option if the client did not send it.
+	EDNSRewrite
+)
+
+// EDNSOptionRule is the forwarding rule for one EDNS option.
+type EDNSOptionRule struct {
+	Action EDNSAction
+	Data   []byte // Option data sent upstream for EDNSRewrite
+}
+
+// EDNSPolicy maps EDNS option codes to forwarding rules.
+//
+// Rules apply to the OPT record of each query sent upstream; queries
+// without EDNS are not changed. Strip rules also apply to upstream
+// responses before they are cached, so clients never see the option.
+type EDNSPolicy map[uint16]EDNSOptionRule
+
+// applyQuery applies the policy to a query about to be sent upstream.
+func (p EDNSPolicy) applyQuery(msg []byte) []byte {
+	if len(p) == 0 {
+		return msg
+	}
+	return dns.RewriteEDNSOptions(msg, func(opts []dns.EDNSOption) []dns.EDNSOption {
+		out := opts[:0]
+		rewritten := make(map[uint16]bool)
+		for _, o := range opts {
+			rule := p[o.Code]
+			switch rule.Action {
+			case EDNSStrip:
+				continue
+			case EDNSRewrite:
+				if rewritten[o.Code] {
+					continue
+				}
+				o.Data = rule.Data
+				rewritten[o.Code] = true
+			}
+			out = append(out, o)
+		}
+
+		// Rewritten options are sent even if the client left them out
+		for _, code := range slices.Sorted(maps.Keys(p)) {
+			if rule := p[code]; rule.Action == EDNSRewrite && !rewritten[code] {
+				out = append(out, dns.EDNSOption{Code: code, Data: rule.Data})
+			}
+		}
+		return out
+	})
+}
+
+// applyResponse removes stripped options from an upstream response.
+func (p EDNSPolicy) applyResponse(msg []byte) []byte {
+	if !p.strips() {
+		return msg
+	}
+	return dns.RewriteEDNSOptions(msg, func(opts []dns.EDNSOption) []dns.EDNSOption {
+		return slices.DeleteFunc(opts, func(o dns.EDNSOption) bool {
+			return p[o.Code].Action == EDNSStrip
+		})
+	})
+}
+
+// strips reports whether any option is stripped.
+func (p EDNSPolicy) strips() bool {
+	for _, rule := range p {
+		if rule.Action == EDNSStrip {
+			return true
+		}
+	}
+	return false
+}
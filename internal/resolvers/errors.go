@@ -0,0 +1,66 @@
+package resolvers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// Resolver errors. They are matched with errors.Is and may be wrapped with
+// more detail.
+//
+// Chained asks the next resolver only after ErrNotInZone; any other error
+// ends the chain. server.QueryHandler turns the errors into response codes
+// and Extended DNS Errors in one place, so resolvers return them instead of
+// building error responses themselves.
+var (
+	// ErrNotInZone means the resolver has no data for the name, e.g. a name
+	// without custom DNS records, and another resolver should be asked.
+	ErrNotInZone = errors.New("name not served by this resolver")
+
+	// ErrNoUpstream means no upstream server answered: all of them are
+	// marked failed, or every attempt failed.
+	ErrNoUpstream = errors.New("no upstream server answered")
+
+	// ErrTimeout means the answer did not arrive in time. Upstream failures
+	// caused by a timeout match both ErrNoUpstream and ErrTimeout.
+	ErrTimeout = errors.New("query timed out")
+
+	// ErrBlocked means policy blocked the query or its answer. It is
+	// returned as a *BlockedError saying why.
+	ErrBlocked = errors.New("blocked by policy")
+)
+
+// BlockedError is a query or answer blocked by policy. It matches
+// ErrBlocked.
+type BlockedError struct {
+	Source string // Result source of the block, e.g. "filtered-blocked"
+	Reason string // Why, for the Extended DNS Error text; may be empty
+
+	// NegativeTTL, when set, is the negative caching TTL of the NXDOMAIN
+	// answer: it carries a SOA (see BlockedSOA) with this TTL and MINIMUM.
+	NegativeTTL uint32
+}
+
+func (e *BlockedError) Error() string {
+	if e.Reason == "" {
+		return ErrBlocked.Error()
+	}
+	return ErrBlocked.Error() + ": " + e.Reason
+}
+
+// Is makes errors.Is(err, ErrBlocked) match.
+func (e *BlockedError) Is(target error) bool {
+	return target == ErrBlocked
+}
+
+// upstreamError wraps the last error of a failed upstream query in
+// ErrNoUpstream, and in ErrTimeout as well when that query timed out.
+func upstreamError(err error) error {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return fmt.Errorf("%w: %w: %w", ErrNoUpstream, ErrTimeout, err)
+	}
+	return fmt.Errorf("%w: %w", ErrNoUpstream, err)
+}
@@ -0,0 +1,91 @@
+package resolvers
+
+import (
+	"context"
+	"net/netip"
+
+	"github.com/jroosing/hydradns/internal/dns"
+)
+
+// AAAAFilterResolver removes AAAA records from the answers to AAAA queries
+// from clients on networks where IPv6 is broken, like dnsmasq's
+// filter-AAAA. The client gets an empty NOERROR (NODATA) answer, or just
+// the CNAME chain, and falls back to IPv4 at once instead of trying IPv6
+// first. NXDOMAIN and other answers pass through unchanged.
+//
+// It post-processes the answers of the next resolver, so local records and
+// forwarded answers are filtered alike.
+type AAAAFilterResolver struct {
+	next    Resolver
+	clients []netip.Prefix
+}
+
+// NewAAAAFilterResolver creates an AAAA filter in front of next. An empty
+// clients list filters every query; otherwise the client is taken from the
+// query context (see WithClient) and queries without one pass through.
+func NewAAAAFilterResolver(clients []netip.Prefix, next Resolver) *AAAAFilterResolver {
+	return &AAAAFilterResolver{next: next, clients: clients}
+}
+
+// Resolve resolves the query with the next resolver and removes AAAA
+// records from the answer for filtered clients.
+func (a *AAAAFilterResolver) Resolve(ctx context.Context, req dns.Packet, reqBytes []byte) (Result, error) {
+	res, err := a.next.Resolve(ctx, req, reqBytes)
+	if err != nil || len(req.Questions) == 0 || req.Questions[0].Type != uint16(dns.TypeAAAA) || !a.filters(ctx) {
+		return res, err
+	}
+
+	resp, err := dns.ParsePacket(res.ResponseBytes)
+	if err != nil {
+		return res, nil
+	}
+	kept := StripAAAA(resp.Answers)
+	if len(kept) == len(resp.Answers) {
+		return res, nil
+	}
+	resp.Answers = kept
+	b, err := resp.Marshal()
+	if err != nil {
+		return Result{}, err
+	}
+	res.ResponseBytes = b
+	return res, nil
+}
+
+// StripAAAA returns answers without AAAA records and the RRSIGs covering
+// them.
+func StripAAAA(answers []dns.Record) []dns.Record {
+	kept := make([]dns.Record, 0, len(answers))
+	for _, r := range answers {
+		if r.Type() == dns.TypeAAAA {
+			continue
+		}
+		if sig, ok := r.(*dns.RRSIGRecord); ok && sig.TypeCovered == dns.TypeAAAA {
+			continue
+		}
+		kept = append(kept, r)
+	}
+	return kept
+}
+
+// filters reports whether the client of the query in ctx is filtered.
+func (a *AAAAFilterResolver) filters(ctx context.Context) bool {
+	if len(a.clients) == 0 {
+		return true
+	}
+	client, ok := ClientFromContext(ctx)
+	if !ok {
+		return false
+	}
+	for _, p := range a.clients {
+		if p.Contains(client) {
+			return true
+		}
+	}
+	return false
+}
+
+// Close closes the next resolver.
+func (a *AAAAFilterResolver) Close() error {
+	return a.next.Close()
+}
@@ -1,33 +1,75 @@
 package resolvers
 
 import (
+	"cmp"
 	"context"
+	"encoding/binary"
+	"net/netip"
+	"slices"
 
 	"github.com/jroosing/hydradns/internal/dns"
 	"github.com/jroosing/hydradns/internal/filtering"
 )
 
 // FilteringResolver applies domain filtering before passing queries to the next resolver.
-// Blocked domains receive an NXDOMAIN response immediately.
+// Blocked domains fail immediately with a *BlockedError.
 //
 // Filtering Decision Flow:
 //
 // 1. Check whitelist (allowed domains) → pass through immediately
-// 2. Check blacklist/blocklists (blocked domains) → return NXDOMAIN
+// 2. Check blacklist/blocklists (blocked domains) → return ErrBlocked
 // 3. Default allow → pass to next resolver
 //
 // Blocking Response:
 //
-// Blocked queries return an NXDOMAIN response (RCODE=3). The response:
-//   - Echoes the original question
-//   - Sets the QR (response), RA (recursive), and AA flags
-//   - Contains no answer, authority, or additional sections
+// Blocked queries return a *BlockedError (matching ErrBlocked) with source
+// "filtered-blocked", which the query handler answers with NXDOMAIN and an
+// Extended DNS Error naming the matching list.
+//
+// With block page addresses set (see SetBlockPageAddrs), blocked A and AAAA
+// queries are instead answered with those addresses so browsers reach the
+// block page. Other query types, and address families without a block page
+// address, get an empty NOERROR (NODATA) response.
+//
+// Block page answers and blocked negative answers have the short TTLs set on
+// the policy engine (see filtering.PolicyEngine.SetBlockTTLs), so a client
+// resolves a domain normally soon after it is unblocked. Negative answers
+// carry a SOA (see BlockedSOA) for that.
 //
 // This resolver MUST be placed first in the resolver chain to ensure
 // all queries pass through the filter before any other resolution.
 type FilteringResolver struct {
 	policy *filtering.PolicyEngine
 	next   Resolver
+
+	blockPageV4 netip.Addr
+	blockPageV6 netip.Addr
+}
+
+// BlockPageTTL is the TTL of block page answers and of blocked negative
+// answers when the policy sets none (see filtering.PolicyEngine.SetBlockTTLs).
+// It is short so a domain allowed from the block page resolves normally soon
+// after.
+const BlockPageTTL = 10
+
+// blockedSOAData is the RDATA of the SOA of blocked negative answers up to
+// its MINIMUM field: MNAME "localhost.", RNAME "nobody.invalid." and the
+// SERIAL, REFRESH, RETRY and EXPIRE values Unbound uses for local zones.
+var blockedSOAData = []byte{
+	9, 'l', 'o', 'c', 'a', 'l', 'h', 'o', 's', 't', 0,
+	6, 'n', 'o', 'b', 'o', 'd', 'y', 7, 'i', 'n', 'v', 'a', 'l', 'i', 'd', 0,
+	0, 0, 0, 1, // SERIAL 1
+	0, 0, 0x0e, 0x10, // REFRESH 3600
+	0, 0, 0x04, 0xb0, // RETRY 1200
+	0, 0x09, 0x3a, 0x80, // EXPIRE 604800
+}
+
+// BlockedSOA returns the SOA placed in the authority section of a blocked
+// negative answer for name. Its TTL and MINIMUM are ttl, so clients cache
+// the block for ttl seconds (RFC 2308).
+func BlockedSOA(name string, class dns.RecordClass, ttl uint32) dns.Record {
+	rdata := binary.BigEndian.AppendUint32(slices.Clone(blockedSOAData), ttl)
+	return dns.NewOpaqueRecord(dns.NewRRHeader(name, class, ttl), dns.TypeSOA, rdata)
 }
 
 // NewFilteringResolver creates a filtering resolver with the given policy engine.
@@ -39,8 +81,18 @@ func NewFilteringResolver(policy *filtering.PolicyEngine, next Resolver) *Filter
 	}
 }
 
+// SetBlockPageAddrs makes blocked A and AAAA queries resolve to ipv4 and
+// ipv6. Invalid (zero) addresses leave that family without an answer; when
+// both are invalid blocked queries get NXDOMAIN.
+// Must be called before the resolver starts serving queries.
+func (f *FilteringResolver) SetBlockPageAddrs(ipv4, ipv6 netip.Addr) {
+	f.blockPageV4 = ipv4
+	f.blockPageV6 = ipv6
+}
+
 // Resolve checks the domain against the filtering policy.
-// Blocked domains return NXDOMAIN immediately; allowed domains pass through to the next resolver.
+// Blocked domains are answered immediately (see buildBlockedResponse); allowed
+// domains pass through to the next resolver.
 func (f *FilteringResolver) Resolve(ctx context.Context, req dns.Packet, reqBytes []byte) (Result, error) {
 	// Extract the query name
 	if len(req.Questions) == 0 {
@@ -55,8 +107,13 @@ func (f *FilteringResolver) Resolve(ctx context.Context, req dns.Packet, reqByte
 
 	switch result.Action {
 	case filtering.ActionBlock:
-		// Return NXDOMAIN for blocked domains
-		resp := buildBlockedResponse(req)
+		blockTTL, negativeTTL := f.policy.BlockTTLs()
+		negativeTTL = cmp.Or(negativeTTL, BlockPageTTL)
+		if !f.blockPageV4.IsValid() && !f.blockPageV6.IsValid() {
+			return Result{}, &BlockedError{Source: "filtered-blocked", Reason: blockReason(result), NegativeTTL: negativeTTL}
+		}
+		// Answer with the block page address
+		resp := f.buildBlockPageResponse(req, cmp.Or(blockTTL, BlockPageTTL), negativeTTL)
 		respBytes, err := resp.Marshal()
 		if err != nil {
 			return Result{}, err
@@ -100,28 +157,52 @@ func (f *FilteringResolver) Policy() *filtering.PolicyEngine {
 	return f.policy
 }
 
-// buildBlockedResponse creates an NXDOMAIN response for a blocked domain.
-func buildBlockedResponse(req dns.Packet) dns.Packet {
+// blockReason describes which list blocked a query, for the Extended DNS
+// Error text.
+func blockReason(result filtering.PolicyResult) string {
+	if result.ListName == "" {
+		return ""
+	}
+	return "listed in " + result.ListName
+}
+
+// buildBlockPageResponse creates the response for a blocked domain when a
+// block page address is configured. Block page answers have blockTTL; empty
+// (NODATA) answers carry a SOA with negativeTTL.
+func (f *FilteringResolver) buildBlockPageResponse(req dns.Packet, blockTTL, negativeTTL uint32) dns.Packet {
+	q := req.Questions[0]
+	var answers, authorities []dns.Record
+	switch {
+	case q.Type == uint16(dns.TypeA) && f.blockPageV4.IsValid():
+		h := dns.NewRRHeader(q.Name, dns.RecordClass(q.Class), blockTTL)
+		answers = append(answers, dns.NewIPRecord(h, f.blockPageV4.AsSlice()))
+	case q.Type == uint16(dns.TypeAAAA) && f.blockPageV6.IsValid():
+		h := dns.NewRRHeader(q.Name, dns.RecordClass(q.Class), blockTTL)
+		answers = append(answers, dns.NewIPRecord(h, f.blockPageV6.AsSlice()))
+	default:
+		authorities = append(authorities, BlockedSOA(q.Name, dns.RecordClass(q.Class), negativeTTL))
+	}
+
 	return dns.Packet{
 		Header: dns.Header{
 			ID:    req.Header.ID,
-			Flags: buildBlockedFlags(req.Header.Flags),
+			Flags: buildBlockedFlags(req.Header.Flags, dns.RCodeNoError),
 		},
-		Questions: req.Questions,
-		Answers:   nil,
+		Questions:   req.Questions,
+		Answers:     answers,
+		Authorities: authorities,
 	}
 }
 
-// buildBlockedFlags creates response flags for NXDOMAIN.
-func buildBlockedFlags(reqFlags uint16) uint16 {
+// buildBlockedFlags creates response flags for a blocked query with rcode.
+func buildBlockedFlags(reqFlags uint16, rcode dns.RCode) uint16 {
 	// Set QR (response), copy opcode, set RA (recursion available)
-	// Set RCODE to NXDOMAIN (3)
 	flags := uint16(1 << 15)   // QR = 1 (response)
 	flags |= reqFlags & 0x7800 // Copy opcode (bits 11-14)
 	if reqFlags&(1<<8) != 0 {  // RD bit was set
 		flags |= 1 << 8 // RD = 1
 		flags |= 1 << 7 // RA = 1 (recursion available)
 	}
-	flags |= uint16(dns.RCodeNXDomain) // RCODE = NXDOMAIN (3)
+	flags |= uint16(rcode)
 	return flags
 }
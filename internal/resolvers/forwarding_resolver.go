@@ -1,6 +1,7 @@
 package resolvers
 
 import (
+	"bytes"
 	"context"
 	"encoding/binary"
 	"errors"
@@ -8,10 +9,14 @@ import (
 	"io"
 	"math"
 	"net"
+	"net/http"
+	"net/netip"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/jroosing/hydradns/internal/clock"
 	"github.com/jroosing/hydradns/internal/dns"
 	"github.com/jroosing/hydradns/internal/helpers"
 )
@@ -31,8 +36,16 @@ const (
 	DefaultTCPTimeout = 5 * time.Second
 	// DefaultMaxRetries is the maximum query retries per upstream.
 	DefaultMaxRetries = 3
+	// DefaultMaxInflightWaiters caps how many queries may wait on one shared upstream call.
+	DefaultMaxInflightWaiters = 1024
+	// DefaultInflightTimeout bounds how long a shared upstream call may run.
+	DefaultInflightTimeout = 10 * time.Second
 )
 
+// ErrTooManyInflightWaiters is returned when a question already has the
+// maximum number of queries waiting on its upstream call.
+var ErrTooManyInflightWaiters = errors.New("too many queries waiting on upstream")
+
 // ForwardingResolver forwards DNS queries to upstream servers.
 //
 // Features:
@@ -40,10 +53,12 @@ const (
 //   - Singleflight deduplication (coalesces concurrent identical queries)
 //   - UDP connection pooling for reduced latency
 //   - TCP fallback when responses are truncated
+//   - Per-zone forced TCP or DNS-over-HTTPS (see SetTransportRules)
 //   - Upstream health tracking with automatic failover
 //   - EDNS support for larger UDP responses
 //   - DNSSEC-aware (preserves DO, AD, CD flags)
 //   - Response validation (verifies response matches request)
+//   - Response scrubbing (drops out-of-bailiwick records, see scrubResponse)
 //
 // Caching Strategy:
 //
@@ -54,16 +69,30 @@ const (
 //
 // Cached entry types:
 //   - Positive: Successful responses with answers (respects record TTLs)
-//   - NXDOMAIN: Non-existent domain (RFC 2308, 5 minute cache)
-//   - NODATA: Name exists but no data for query type (RFC 2308, 5 minute cache)
+//   - NXDOMAIN: Non-existent domain (RFC 2308, SOA minimum or 5 minutes)
+//   - NODATA: Name exists but no data for query type (RFC 2308, SOA minimum or 5 minutes)
 //   - SERVFAIL: Server error (short cache, 30 seconds)
 //
+// Negative and SERVFAIL TTLs are configurable globally and per zone via
+// SetNegativeCacheRules. Zones set with SetCacheBypass are never cached.
+// A cache shared with other nodes can be added behind the in-memory one
+// with SetSharedCache. With SetAggressiveNSEC, authenticated NSEC records
+// from negative answers also answer queries for other names they deny.
+//
 // Singleflight Deduplication:
 //
 // Multiple concurrent queries for the same question share a single upstream
 // request. This prevents thundering herd problems and reduces upstream load
 // during cache misses. The response is cached once and shared with all waiters.
 //
+// The key is the question and upstream only, so identical questions arriving
+// over different transports (UDP, TCP, ...) are coalesced as well. The shared
+// call runs detached from any single client's context with its own timeout
+// (inflightTimeout), so one client giving up does not fail the others. The
+// number of waiters per call is capped (maxInflightWaiters); excess queries
+// fail fast with ErrTooManyInflightWaiters instead of piling up behind a
+// stuck upstream.
+//
 // TCP Fallback:
 //
 // If a UDP response has the truncation (TC) bit set and TCP is enabled,
@@ -74,8 +103,14 @@ const (
 //
 // Failed upstreams are marked as failed for 1 hour. After that, they are
 // automatically tried again. Failover prioritizes upstreams in order.
+//
+// Runtime Updates:
+//
+// The upstream list can be replaced while serving via SetUpstreams. The cache
+// is kept; entries keyed to removed upstreams simply expire.
 type ForwardingResolver struct {
-	upstreams []string // Upstream server IPs (port is always 53)
+	upstreamsMu sync.RWMutex
+	upstreams   []string // Upstream server IPs (port is always 53); replaced, never mutated
 
 	udpTimeout  time.Duration // Timeout for UDP queries
 	recvSize    int           // UDP receive buffer size
@@ -85,19 +120,40 @@ type ForwardingResolver struct {
 	ednsUDPSize int           // Advertised EDNS UDP buffer size
 	ednsEnabled bool          // Whether to add EDNS OPT record to queries
 
-	cache *TTLCache[cacheKey, []byte] // Response cache
+	maxCNAMEChain int            // Maximum CNAMEs accepted in an upstream answer
+	ednsPolicy    EDNSPolicy     // EDNS option forwarding rules
+	auditor       QueryAuditor   // Optional record of every upstream query
+	capture       PacketCapture  // Optional packet capture of upstream messages
+	dialer        UpstreamDialer // Opens upstream UDP and TCP connections
+
+	transportRules *TransportRules // Transports forced per zone
+	dohServers     []string        // DoH server URLs for names forced over DoH
+	dohClient      *http.Client
+
+	cache         *TTLCache[cacheKey, []byte]        // Response cache
+	negativeRules atomic.Pointer[NegativeCacheRules] // NXDOMAIN/NODATA/SERVFAIL caching rules
+	cacheBypass   atomic.Pointer[CacheBypass]        // Zones whose answers are never cached
+	shared        *sharedTier                        // Optional cache shared with other nodes
+	nsec          *nsecCache                         // Optional aggressive NSEC negative cache
 
 	// Singleflight: coalesce concurrent queries for the same question
-	inflightMu sync.Mutex
-	inflight   map[cacheKey]*inflightCall
+	inflightMu         sync.Mutex
+	inflight           map[cacheKey]*inflightCall
+	maxInflightWaiters int            // Max queries waiting on one shared call
+	inflightTimeout    time.Duration  // Upper bound for one shared upstream call
+	inflightWG         sync.WaitGroup // Tracks detached shared calls for Close
+
+	// Upstream health tracking and selection
+	health   *upstreamHealth
+	strategy UpstreamStrategy
 
-	// Upstream health tracking
-	healthMu         sync.Mutex
-	upstreamFailedAt map[string]time.Time
+	// Per-upstream RTT and availability statistics
+	statsMu       sync.Mutex
+	upstreamStats map[string]*upstreamStats
 
 	// UDP connection pool per upstream
 	poolMu   sync.Mutex
-	udpPools map[string]chan *net.UDPConn
+	udpPools map[string]chan net.Conn
 	poolSize int
 }
 
@@ -109,9 +165,13 @@ type cacheKey struct {
 
 // inflightCall tracks an in-progress query for singleflight deduplication.
 type inflightCall struct {
-	done chan struct{} // Closed when query completes
-	resp []byte        // Response (if successful)
-	err  error         // Error (if failed)
+	done    chan struct{} // Closed when query completes
+	resp    []byte        // Response (if successful)
+	err     error         // Error (if failed)
+	shared  bool          // Answered from the shared cache, not upstream
+	up      string        // Upstream that answered (if queried)
+	upTime  time.Duration // Time spent querying upstreams
+	waiters int           // Queries currently waiting (guarded by inflightMu)
 }
 
 // NewForwardingResolver creates a ForwardingResolver with the given configuration.
@@ -132,12 +192,7 @@ func NewForwardingResolver(
 	udpTimeout, tcpTimeout time.Duration,
 	maxRetries int,
 ) *ForwardingResolver {
-	if len(upstreams) == 0 {
-		upstreams = []string{"8.8.8.8"}
-	}
-	if len(upstreams) > maxUpstreams {
-		upstreams = upstreams[:maxUpstreams]
-	}
+	upstreams = normalizeUpstreams(upstreams)
 	if poolSize <= 0 {
 		poolSize = DefaultUDPPoolSize
 	}
@@ -153,25 +208,116 @@ func NewForwardingResolver(
 	if maxRetries <= 0 {
 		maxRetries = DefaultMaxRetries
 	}
-	return &ForwardingResolver{
-		upstreams:        upstreams,
-		udpTimeout:       udpTimeout,
-		recvSize:         4096,
-		tcpFallback:      tcpFallback,
-		tcpTimeout:       tcpTimeout,
-		maxRetries:       maxRetries,
-		ednsUDPSize:      dns.EDNSDefaultUDPPayloadSize,
-		ednsEnabled:      true,
-		cache:            NewTTLCache[cacheKey, []byte](cacheMaxEntries),
-		inflight:         map[cacheKey]*inflightCall{},
-		upstreamFailedAt: map[string]time.Time{},
-		udpPools:         map[string]chan *net.UDPConn{},
-		poolSize:         poolSize,
+	f := &ForwardingResolver{
+		upstreams:          upstreams,
+		udpTimeout:         udpTimeout,
+		recvSize:           4096,
+		tcpFallback:        tcpFallback,
+		tcpTimeout:         tcpTimeout,
+		maxRetries:         maxRetries,
+		ednsUDPSize:        dns.EDNSDefaultUDPPayloadSize,
+		ednsEnabled:        true,
+		cache:              NewTTLCache[cacheKey, []byte](cacheMaxEntries),
+		inflight:           map[cacheKey]*inflightCall{},
+		maxInflightWaiters: DefaultMaxInflightWaiters,
+		inflightTimeout:    DefaultInflightTimeout,
+		health:             newUpstreamHealth(),
+		strategy:           SequentialStrategy{},
+		upstreamStats:      map[string]*upstreamStats{},
+		udpPools:           map[string]chan net.Conn{},
+		poolSize:           poolSize,
+		dialer:             &net.Dialer{},
+	}
+	f.negativeRules.Store(&NegativeCacheRules{Default: DefaultNegativeCachePolicy()})
+	return f
+}
+
+// normalizeUpstreams returns a private copy of the upstream list, defaulting
+// to 8.8.8.8 when empty and capping it at maxUpstreams entries.
+func normalizeUpstreams(upstreams []string) []string {
+	if len(upstreams) == 0 {
+		return []string{"8.8.8.8"}
+	}
+	out := make([]string, min(len(upstreams), maxUpstreams))
+	copy(out, upstreams)
+	return out
+}
+
+// Upstreams returns a copy of the current upstream server list in priority order.
+func (f *ForwardingResolver) Upstreams() []string {
+	ups := f.upstreamList()
+	out := make([]string, len(ups))
+	copy(out, ups)
+	return out
+}
+
+// SetUpstreams atomically replaces the upstream server list.
+//
+// This is safe to call while queries are in flight: queries already in
+// progress finish against the list they started with. Health state and
+// pooled connections for upstreams that are no longer configured are
+// discarded.
+func (f *ForwardingResolver) SetUpstreams(upstreams []string) {
+	ups := normalizeUpstreams(upstreams)
+
+	f.upstreamsMu.Lock()
+	f.upstreams = ups
+	f.upstreamsMu.Unlock()
+
+	keep := make(map[string]struct{}, len(ups))
+	for _, u := range ups {
+		keep[u] = struct{}{}
+	}
+
+	f.health.retain(keep)
+
+	f.statsMu.Lock()
+	for u := range f.upstreamStats {
+		if _, ok := keep[u]; !ok {
+			delete(f.upstreamStats, u)
+		}
+	}
+	f.statsMu.Unlock()
+
+	// Drain pools of removed upstreams. The channels are left open so that
+	// connections still in use can be released without panicking; the few
+	// that land back in an orphaned channel are reclaimed by the GC.
+	f.poolMu.Lock()
+	for u, ch := range f.udpPools {
+		if _, ok := keep[u]; ok {
+			continue
+		}
+		delete(f.udpPools, u)
+		for drained := false; !drained; {
+			select {
+			case c := <-ch:
+				_ = c.Close()
+			default:
+				drained = true
+			}
+		}
 	}
+	f.poolMu.Unlock()
+}
+
+// upstreamList returns the current upstream slice. Callers must not modify it.
+func (f *ForwardingResolver) upstreamList() []string {
+	f.upstreamsMu.RLock()
+	defer f.upstreamsMu.RUnlock()
+	return f.upstreams
 }
 
-// Close releases all pooled UDP connections.
+// Close waits for shared upstream calls to finish and releases all pooled
+// UDP connections.
 func (f *ForwardingResolver) Close() error {
+	f.inflightWG.Wait()
+	var err error
+	if f.shared != nil {
+		err = f.shared.close()
+	}
+	if f.dohClient != nil {
+		f.dohClient.CloseIdleConnections()
+	}
 	f.poolMu.Lock()
 	defer f.poolMu.Unlock()
 	for _, ch := range f.udpPools {
@@ -180,31 +326,45 @@ func (f *ForwardingResolver) Close() error {
 			_ = c.Close()
 		}
 	}
-	f.udpPools = map[string]chan *net.UDPConn{}
-	return nil
+	f.udpPools = map[string]chan net.Conn{}
+	return err
 }
 
 // Resolve forwards a DNS query to an upstream server.
 //
 // Resolution strategy:
-//  1. Check cache for existing response
+//  1. Check cache for existing response, or a cached NSEC denial
 //  2. Join existing inflight query if one exists (singleflight)
-//  3. Query upstream servers with failover
-//  4. Cache and return the response
+//  3. Check the shared cache, if set
+//  4. Query upstream servers with failover
+//  5. Cache and return the response
 //
-// Goroutine lifecycle: No goroutines spawned by this method.
-// All network I/O is synchronous and respects context cancellation.
+// Goroutine lifecycle: On a cache miss with no inflight call, spawns one
+// goroutine to run the shared upstream query. It exits when the query
+// completes or inflightTimeout elapses, independent of ctx.
 func (f *ForwardingResolver) Resolve(ctx context.Context, req dns.Packet, reqBytes []byte) (Result, error) {
-	txid := req.Header.ID
-	up := f.selectUpstream()
-	key := f.cacheKey(req, up)
+	if RecursionRefused(ctx) {
+		return Result{}, ErrRecursionRefused
+	}
 
-	if v, age, ok, _ := f.cache.GetWithAge(key); ok {
-		// Adjust TTLs in cached response to account for time spent in cache.
-		// The cached bytes contain txid=0, which is irrelevant and gets overwritten
-		// by PatchTransactionID to match the client's original txid.
-		adjusted := adjustTTLs(v, age)
-		return Result{ResponseBytes: PatchTransactionID(adjusted, txid), Source: "upstream-cache"}, nil
+	txid := req.Header.ID
+	key := f.cacheKey(req, f.selectUpstream())
+
+	// Bypassed names are checked on lookup too, so entries cached before the
+	// bypass list changed are never served
+	if !cacheSkipped(ctx) && !f.cacheBypass.Load().Matches(key.q.QName) {
+		if v, age, ok, _ := f.cache.GetWithAge(key); ok {
+			// Adjust TTLs in cached response to account for time spent in cache.
+			// The cached bytes contain txid=0, which is irrelevant and gets overwritten
+			// by PatchTransactionID to match the client's original txid.
+			adjusted := adjustTTLs(v, age)
+			return Result{ResponseBytes: PatchTransactionID(adjusted, txid), Source: "upstream-cache"}, nil
+		}
+		if f.nsec != nil {
+			if resp, ok := f.nsec.synthesize(req); ok {
+				return Result{ResponseBytes: resp, Source: "upstream-nsec"}, nil
+			}
+		}
 	}
 
 	// Check context before starting network operations
@@ -214,94 +374,283 @@ func (f *ForwardingResolver) Resolve(ctx context.Context, req dns.Packet, reqByt
 
 	// singleflight
 	f.inflightMu.Lock()
-	if call := f.inflight[key]; call != nil {
+	source := "upstream-inflight"
+	call := f.inflight[key]
+	switch {
+	case call == nil:
+		call = &inflightCall{done: make(chan struct{})}
+		f.inflight[key] = call
+		source = "upstream"
+		// The shared call may outlive this caller, whose buffer can be reused.
+		reqCopy := bytes.Clone(reqBytes)
+		f.inflightWG.Go(func() { f.runInflight(ctx, key, call, req, reqCopy) })
+	case call.waiters >= f.maxInflightWaiters:
 		f.inflightMu.Unlock()
-		select {
-		case <-call.done:
-			if call.err != nil {
-				return Result{}, call.err
-			}
-			return Result{ResponseBytes: PatchTransactionID(call.resp, txid), Source: "upstream-inflight"}, nil
-		case <-ctx.Done():
-			return Result{}, ctx.Err()
+		return Result{}, ErrTooManyInflightWaiters
+	}
+	call.waiters++
+	f.inflightMu.Unlock()
+
+	defer func() {
+		f.inflightMu.Lock()
+		call.waiters--
+		f.inflightMu.Unlock()
+	}()
+
+	select {
+	case <-call.done:
+		if call.err != nil {
+			return Result{}, call.err
 		}
+		if call.shared {
+			source = "upstream-shared-cache"
+		}
+		return Result{
+			ResponseBytes: PatchTransactionID(call.resp, txid),
+			Source:        source,
+			Upstream:      call.up,
+			UpstreamTime:  call.upTime,
+		}, nil
+	case <-ctx.Done():
+		return Result{}, ctx.Err()
 	}
-	call := &inflightCall{done: make(chan struct{})}
-	f.inflight[key] = call
+}
+
+// runInflight performs the shared upstream query for a singleflight call.
+// The query is detached from the originating client's cancellation and
+// bounded by inflightTimeout instead.
+func (f *ForwardingResolver) runInflight(
+	ctx context.Context,
+	key cacheKey,
+	call *inflightCall,
+	req dns.Packet,
+	reqBytes []byte,
+) {
+	callCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), f.inflightTimeout)
+	defer cancel()
+
+	resp, shared := f.lookupShared(callCtx, key, req)
+	var (
+		up     string
+		upTime time.Duration
+		err    error
+	)
+	if !shared {
+		start := time.Now()
+		resp, up, err = f.queryAndCache(callCtx, key, req, reqBytes)
+		upTime = time.Since(start)
+	}
+
+	f.inflightMu.Lock()
+	delete(f.inflight, key)
 	f.inflightMu.Unlock()
 
-	resp, err := f.queryAndCache(ctx, key, req, reqBytes)
 	call.resp = resp
 	call.err = err
+	call.shared = shared
+	call.up = up
+	call.upTime = upTime
 	close(call.done)
+}
 
-	f.inflightMu.Lock()
-	delete(f.inflight, key)
-	f.inflightMu.Unlock()
-	if err != nil {
-		return Result{}, err
+// SetInflightLimits configures singleflight protection: the maximum number
+// of queries that may wait on one shared upstream call and the maximum
+// duration of that call. Non-positive values keep the current setting.
+// Must be called before the resolver starts serving queries.
+func (f *ForwardingResolver) SetInflightLimits(maxWaiters int, timeout time.Duration) {
+	if maxWaiters > 0 {
+		f.maxInflightWaiters = maxWaiters
 	}
-	return Result{ResponseBytes: PatchTransactionID(resp, txid), Source: "upstream"}, nil
+	if timeout > 0 {
+		f.inflightTimeout = timeout
+	}
+}
+
+// SetAggressiveNSEC turns on aggressive use of DNSSEC-validated NSEC
+// records (RFC 8198): NSEC records from negative answers the upstream
+// marked as authenticated are kept, and later queries for names or types
+// they deny are answered from them without asking upstream.
+// Must be called before the resolver starts serving queries.
+func (f *ForwardingResolver) SetAggressiveNSEC(enabled bool) {
+	f.nsec = nil
+	if enabled {
+		f.nsec = newNSECCache()
+	}
+}
+
+// SetMaxCNAMEChain sets the maximum number of CNAMEs accepted in an
+// upstream answer. Non-positive values select DefaultMaxCNAMEChain.
+// Must be called before the resolver starts serving queries.
+func (f *ForwardingResolver) SetMaxCNAMEChain(n int) {
+	f.maxCNAMEChain = n
+}
+
+// SetEDNSPolicy sets the rules for EDNS options forwarded upstream.
+// Must be called before the resolver starts serving queries.
+func (f *ForwardingResolver) SetEDNSPolicy(p EDNSPolicy) {
+	f.ednsPolicy = p
+}
+
+// SetUpstreamStrategy sets the strategy that orders upstreams for each
+// query. The default is SequentialStrategy. Must be called before the
+// resolver starts serving queries.
+func (f *ForwardingResolver) SetUpstreamStrategy(s UpstreamStrategy) {
+	f.strategy = s
+}
+
+// SetAuditor sets the auditor told about every query sent upstream,
+// including retries and TCP fallbacks. Must be called before the resolver
+// starts serving queries.
+func (f *ForwardingResolver) SetAuditor(a QueryAuditor) {
+	f.auditor = a
+}
+
+// SetPacketCapture sets the packet capture told about every message
+// exchanged with upstreams over UDP and TCP. DoH exchanges are not
+// captured. Must be called before the resolver starts serving queries.
+func (f *ForwardingResolver) SetPacketCapture(c PacketCapture) {
+	f.capture = c
+}
+
+// SetDialer sets the dialer used to reach upstream servers over UDP and
+// TCP, including lookups of DoH server names; by default a net.Dialer. DoH
+// queries themselves use the HTTP client given to SetDoHServers. Must be
+// called before the resolver starts serving queries.
+func (f *ForwardingResolver) SetDialer(d UpstreamDialer) {
+	f.dialer = d
+}
+
+// SetClock sets the clock that cache TTLs and upstream failure cooldowns
+// are measured against; by default the system clock. Must be called before
+// the resolver starts serving queries.
+func (f *ForwardingResolver) SetClock(clk clock.Clock) {
+	f.cache.SetClock(clk)
+	f.health.setClock(clk)
+}
+
+// PurgeName removes all cached responses for name, whatever their type or
+// upstream, and returns the number of in-memory entries removed. The
+// entries of the shared cache are deleted too, for the IN class and the
+// record types with a mnemonic, so the next miss goes upstream instead of
+// refilling from them.
+func (f *ForwardingResolver) PurgeName(name string) int {
+	name = dns.NormalizeName(name)
+	n := f.cache.DeleteFunc(func(k cacheKey) bool { return k.q.QName == name })
+	f.purgeShared(name)
+	return n
+}
+
+// SetCacheEvictionPolicy changes which cached response is evicted when the
+// cache is full. The default is LRU.
+func (f *ForwardingResolver) SetCacheEvictionPolicy(p EvictionPolicy) {
+	f.cache.SetEvictionPolicy(p)
+}
+
+// SetCacheByteLimits caps the memory used by cached responses at maxBytes
+// in total and maxEntryBytes per response, measured in wire bytes. Larger
+// responses are not cached. Zero leaves a limit off.
+func (f *ForwardingResolver) SetCacheByteLimits(maxBytes, maxEntryBytes int) {
+	f.cache.SetByteLimits(maxBytes, maxEntryBytes, func(b []byte) int { return len(b) })
+}
+
+// CacheStats returns the response cache counters.
+func (f *ForwardingResolver) CacheStats() CacheStats {
+	return f.cache.Stats()
 }
 
 // queryAndCache queries upstream servers with failover and caches the result.
 //
-// The method tries each upstream in order, starting from the preferred one.
-// On success, it validates the response to prevent cache poisoning, normalizes
-// the transaction ID, and stores it in the cache.
+// The method tries the healthy upstreams in the order the upstream strategy
+// picks, failing over to the next on error. Names with a forced transport
+// are sent over TCP to the same upstreams, or to the DoH servers instead.
+// On success, it validates the response to prevent cache poisoning, scrubs
+// out-of-bailiwick records, normalizes the transaction ID, and stores it in
+// the cache. It returns the upstream that answered. When no upstream
+// answers, the error matches ErrNoUpstream (and ErrTimeout if the last
+// attempt timed out).
 func (f *ForwardingResolver) queryAndCache(
 	ctx context.Context,
 	key cacheKey,
 	req dns.Packet,
 	reqBytes []byte,
-) ([]byte, error) {
+) ([]byte, string, error) {
 	queryBytes := f.prepareQueryBytes(req, reqBytes)
 
-	startIdx := f.findUpstreamIndex(key.up)
+	transport := f.transportRules.Match(key.q.QName)
+	if transport == TransportDoH {
+		resp, up, err := f.queryDoH(ctx, queryBytes)
+		if err != nil {
+			return nil, "", upstreamError(err)
+		}
+		resp, err = f.acceptResponse(key, req, resp)
+		return resp, up, err
+	}
+
+	ups := f.strategy.Order(f.health.candidates(f.upstreamList()))
 	lastErr := error(nil)
 
-	for j := range len(f.upstreams) {
+	for _, u := range ups {
 		if ctx.Err() != nil {
-			return nil, ctx.Err()
+			return nil, "", ctx.Err()
 		}
-		i := (startIdx + j) % len(f.upstreams)
-		u := f.upstreams[i]
-
-		if !f.canTryUpstream(u) {
+		// Another query may have failed u since the order was picked
+		if !f.health.canTry(u) {
 			continue
 		}
 
-		resp, err := f.queryOne(ctx, u, queryBytes)
+		stats := f.statsFor(u)
+		start := time.Now()
+		resp, err := f.queryOne(ctx, u, queryBytes, transport)
 		if err != nil {
 			lastErr = err
-			f.markFailed(u)
+			f.health.markFailed(u)
+			// Client cancellation says nothing about the upstream's health
+			if ctx.Err() == nil {
+				stats.recordFailure(err)
+				f.strategy.Observe(u, 0, err)
+			}
 			continue
 		}
-		f.markHealthy(u)
+		rtt := time.Since(start)
+		stats.recordSuccess(rtt)
+		f.strategy.Observe(u, rtt, nil)
+		f.health.markHealthy(u)
+		resp, err = f.acceptResponse(key, req, resp)
+		return resp, u, err
+	}
 
-		// Validate that the response matches our query to prevent cache poisoning
-		if err := validateResponse(req, resp); err != nil {
-			return nil, err
-		}
+	if lastErr != nil {
+		return nil, "", upstreamError(lastErr)
+	}
+	return nil, "", ErrNoUpstream
+}
 
-		// Normalize transaction ID to 0 for cache storage
-		// (actual txid is patched back when returning to client)
-		norm := PatchTransactionID(resp, 0)
-		f.storeInCache(key, norm)
-		return norm, nil
+// acceptResponse validates, scrubs, and caches an upstream response.
+func (f *ForwardingResolver) acceptResponse(key cacheKey, req dns.Packet, resp []byte) ([]byte, error) {
+	// Validate that the response matches our query to prevent cache poisoning
+	if err := validateResponse(req, resp, f.maxCNAMEChain); err != nil {
+		return nil, err
 	}
 
-	if lastErr != nil {
-		return nil, lastErr
+	// Drop out-of-bailiwick records before anything is cached
+	resp, _ = scrubResponse(resp, f.maxCNAMEChain)
+
+	// Normalize transaction ID to 0 for cache storage
+	// (actual txid is patched back when returning to client)
+	norm := PatchTransactionID(f.ednsPolicy.applyResponse(resp), 0)
+	f.storeInCache(key, norm)
+	if f.nsec != nil && !f.cacheBypass.Load().Matches(key.q.QName) {
+		f.nsec.learn(norm)
 	}
-	return nil, errors.New("no upstream servers available")
+	return norm, nil
 }
 
-// prepareQueryBytes normalizes the transaction ID to 0 for upstream reuse
-// and ensures EDNS is present (preserving DO flag if client sent it).
+// prepareQueryBytes normalizes the transaction ID to 0 for upstream reuse,
+// ensures EDNS is present (preserving DO flag if client sent it), and
+// applies the EDNS option policy.
 // Zeroing the txid ensures cache hits are shared across clients while the
 // original client txid is restored by PatchTransactionID before sending
-// the response back.
+// the response back. UDP and TCP queries get a random txid when sent.
 func (f *ForwardingResolver) prepareQueryBytes(req dns.Packet, reqBytes []byte) []byte {
 	// Ensure we have space for the txid
 	if len(reqBytes) < 2 {
@@ -316,29 +665,13 @@ func (f *ForwardingResolver) prepareQueryBytes(req dns.Packet, reqBytes []byte)
 	copy(out, reqBytes)
 	out[0], out[1] = 0, 0
 
-	// Preserve EDNS from client (including DO flag) or add our own
+	// Preserve EDNS from client (including DO flag) or add our own;
+	// AddEDNSToRequestBytes keeps an OPT record the client sent
 	if f.ednsEnabled {
-		// Check if client sent EDNS with DO flag
-		clientOPT := dns.ExtractOPT(req.Additionals)
-		if clientOPT != nil && clientOPT.DNSSECOk {
-			// Client supports DNSSEC - preserve their OPT record as-is
-			return out
-		}
-		// Add EDNS without DO flag (client didn't request DNSSEC)
-		return dns.AddEDNSToRequestBytes(req, out, f.ednsUDPSize)
+		out = dns.AddEDNSToRequestBytes(req, out, f.ednsUDPSize)
 	}
 
-	return out
-}
-
-// findUpstreamIndex returns the index of the given upstream server.
-func (f *ForwardingResolver) findUpstreamIndex(upstream string) int {
-	for i, u := range f.upstreams {
-		if u == upstream {
-			return i
-		}
-	}
-	return 0
+	return f.ednsPolicy.applyQuery(out)
 }
 
 // cacheKey generates a cache key from a request and upstream.
@@ -346,7 +679,7 @@ func (f *ForwardingResolver) findUpstreamIndex(upstream string) int {
 func (f *ForwardingResolver) cacheKey(req dns.Packet, upstream string) cacheKey {
 	q := normalizeQuestionKey(req)
 	// Use first upstream for cache key to share cache across failover
-	up := f.upstreams[0]
+	up := f.upstreamList()[0]
 	if upstream != "" {
 		up = upstream
 	}
@@ -354,94 +687,51 @@ func (f *ForwardingResolver) cacheKey(req dns.Packet, upstream string) cacheKey
 }
 
 // normalizeQuestionKey extracts a DNS question for caching.
-// The question name is already normalized (lowercase) during DNS parsing.
+// Parsed names are already lowercase; normalizing again keeps the key
+// canonical for packets built in code. The wire request sent upstream
+// keeps the client's original case.
 func normalizeQuestionKey(req dns.Packet) QuestionKey {
 	if len(req.Questions) == 0 {
 		return QuestionKey{}
 	}
 	return QuestionKey{
-		QName:  req.Questions[0].Name,
+		QName:  dns.NormalizeName(req.Questions[0].Name),
 		QType:  req.Questions[0].Type,
 		QClass: req.Questions[0].Class,
 	}
 }
 
-// canTryUpstream checks if an upstream is healthy or has recovered.
-// An upstream is considered recovered after upstreamRecoveryDuration.
-func (f *ForwardingResolver) canTryUpstream(up string) bool {
-	f.healthMu.Lock()
-	defer f.healthMu.Unlock()
-
-	failedAt, ok := f.upstreamFailedAt[up]
-	if !ok {
-		return true // never failed
-	}
-	if time.Since(failedAt) >= upstreamRecoveryDuration {
-		delete(f.upstreamFailedAt, up)
-		return true // recovered
-	}
-	return false // still in cooldown
-}
-
-// selectUpstream returns the best upstream server to use.
-// Prefers healthy upstreams in order; if all have failed, clears the failure
-// state and returns the first upstream.
+// selectUpstream returns the upstream whose cache entries a query uses:
+// the first healthy upstream in configured order. Keying on it rather than
+// the upstream the strategy picks keeps one cache across all strategies and
+// across failover. If all upstreams have failed, the failure state is
+// cleared and the first upstream is returned.
 func (f *ForwardingResolver) selectUpstream() string {
-	for _, u := range f.upstreams {
-		if f.canTryUpstream(u) {
-			return u
-		}
-	}
-
-	// All upstreams have failed - clear state and retry from first
-	f.healthMu.Lock()
-	f.upstreamFailedAt = map[string]time.Time{}
-	f.healthMu.Unlock()
-	return f.upstreams[0]
-}
-
-// markFailed records the current time as the failure timestamp for an upstream.
-// Only marks failure once; subsequent failures don't update the timestamp.
-func (f *ForwardingResolver) markFailed(up string) {
-	f.healthMu.Lock()
-	defer f.healthMu.Unlock()
-	if _, ok := f.upstreamFailedAt[up]; !ok {
-		f.upstreamFailedAt[up] = time.Now()
-	}
-}
-
-// markHealthy clears the failure state for an upstream.
-func (f *ForwardingResolver) markHealthy(up string) {
-	f.healthMu.Lock()
-	defer f.healthMu.Unlock()
-	delete(f.upstreamFailedAt, up)
+	return f.health.candidates(f.upstreamList())[0]
 }
 
 // ensurePool returns or creates the UDP connection pool for an upstream.
 // Connections are pre-dialed and stored in a buffered channel.
-func (f *ForwardingResolver) ensurePool(up string) (chan *net.UDPConn, error) {
+func (f *ForwardingResolver) ensurePool(up string) chan net.Conn {
 	f.poolMu.Lock()
 	if ch, ok := f.udpPools[up]; ok {
 		f.poolMu.Unlock()
-		return ch, nil
+		return ch
 	}
-	ch := make(chan *net.UDPConn, f.poolSize)
+	ch := make(chan net.Conn, f.poolSize)
 	f.udpPools[up] = ch
 	f.poolMu.Unlock()
 
 	// Pre-dial connections for the pool
-	addr, err := net.ResolveUDPAddr("udp", net.JoinHostPort(up, "53"))
-	if err != nil {
-		return nil, err
-	}
+	addr := net.JoinHostPort(up, "53")
 	for range f.poolSize {
-		c, _ := net.DialUDP("udp", nil, addr)
-		if c == nil {
+		c, err := f.dialer.DialContext(context.Background(), "udp", addr)
+		if err != nil {
 			break // partial pool is acceptable
 		}
 		ch <- c
 	}
-	return ch, nil
+	return ch
 }
 
 // queryOne sends a DNS query to a single upstream with retries.
@@ -453,13 +743,16 @@ func (f *ForwardingResolver) ensurePool(up string) (chan *net.UDPConn, error) {
 //
 // If the UDP response is truncated and tcpFallback is enabled,
 // automatically retries with TCP. On timeout errors, retries up to
-// maxRetries times before giving up.
-func (f *ForwardingResolver) queryOne(ctx context.Context, up string, req []byte) ([]byte, error) {
-	pool, err := f.ensurePool(up)
-	if err != nil {
-		return nil, err
+// maxRetries times before giving up. Names forced over TCP skip UDP.
+func (f *ForwardingResolver) queryOne(ctx context.Context, up string, req []byte, transport Transport) ([]byte, error) {
+	if transport == TransportTCP {
+		req = PatchTransactionID(req, dns.NewTransactionID())
+		f.audit(req, up, "tcp")
+		return f.queryUpstreamTCP(ctx, req, up)
 	}
 
+	pool := f.ensurePool(up)
+
 	var lastErr error
 	for range f.maxRetries {
 		if ctx.Err() != nil {
@@ -489,10 +782,14 @@ func isTimeoutError(err error) bool {
 	return errors.As(err, &netErr)
 }
 
-// queryOneAttempt sends a single query attempt to an upstream server.
+// queryOneAttempt sends a single query attempt to an upstream server under
+// a fresh random transaction ID. Datagrams that do not match it (see
+// outstandingQuery), such as late answers to an earlier query on a pooled
+// socket or spoofed ones, are counted and dropped while waiting for the
+// real answer.
 func (f *ForwardingResolver) queryOneAttempt(
 	ctx context.Context,
-	pool chan *net.UDPConn,
+	pool chan net.Conn,
 	up string,
 	req []byte,
 ) ([]byte, error) {
@@ -514,33 +811,84 @@ func (f *ForwardingResolver) queryOneAttempt(
 	_ = c.SetDeadline(deadline)
 
 	// Send query
+	req = PatchTransactionID(req, dns.NewTransactionID())
+	f.audit(req, up, "udp")
 	if _, writeErr := c.Write(req); writeErr != nil {
 		connOK = false
 		return nil, writeErr
 	}
+	f.captureMessage(ctx, "udp", c.LocalAddr(), c.RemoteAddr(), req)
 
 	// Receive response with fixed buffer size
+	pending := newOutstandingQuery(req)
 	buf := make([]byte, f.recvSize)
-	n, err := c.Read(buf)
-	if err != nil {
-		connOK = false
-		return nil, err
+	var resp []byte
+	for resp == nil {
+		n, err := c.Read(buf)
+		if err != nil {
+			connOK = false
+			return nil, err
+		}
+		if !pending.matches(buf[:n]) {
+			f.statsFor(up).recordMismatch()
+			continue
+		}
+		resp = buf[:n:n] // Limit capacity to prevent reuse of buffer tail
 	}
-	resp := buf[:n:n] // Limit capacity to prevent reuse of buffer tail
+	f.captureMessage(ctx, "udp", c.RemoteAddr(), c.LocalAddr(), resp)
 
 	// Retry with TCP if response is truncated
 	if f.tcpFallback && dns.IsTruncated(resp) {
-		return queryUpstreamTCP(ctx, req, up, f.tcpTimeout)
+		f.audit(req, up, "tcp")
+		return f.queryUpstreamTCP(ctx, req, up)
 	}
 	return resp, nil
 }
 
+// audit tells the auditor, if any, about the query req sent to up.
+func (f *ForwardingResolver) audit(req []byte, up, transport string) {
+	if f.auditor == nil {
+		return
+	}
+	off := 0
+	if _, err := dns.ParseHeader(req, &off); err != nil {
+		return
+	}
+	q, err := dns.ParseQuestion(req, &off)
+	if err != nil {
+		return
+	}
+	f.auditor.Record(q.Name, dns.RecordType(q.Type).String(), up, transport)
+}
+
+// captureMessage tells the packet capture, if one runs, about msg sent
+// from src to dst over transport.
+func (f *ForwardingResolver) captureMessage(ctx context.Context, transport string, src, dst net.Addr, msg []byte) {
+	if f.capture == nil || !f.capture.Capturing() {
+		return
+	}
+	client, _ := ClientFromContext(ctx)
+	f.capture.RecordUpstream(transport, client, addrPortOf(src), addrPortOf(dst), msg)
+}
+
+// addrPortOf returns the address and port of a UDP or TCP address, or the
+// zero AddrPort for other addresses.
+func addrPortOf(a net.Addr) netip.AddrPort {
+	switch a := a.(type) {
+	case *net.UDPAddr:
+		return a.AddrPort()
+	case *net.TCPAddr:
+		return a.AddrPort()
+	}
+	return netip.AddrPort{}
+}
+
 // acquireConnection gets a connection from the pool or creates a transient one.
 func (f *ForwardingResolver) acquireConnection(
 	ctx context.Context,
-	pool chan *net.UDPConn,
+	pool chan net.Conn,
 	up string,
-) (*net.UDPConn, bool, error) {
+) (net.Conn, bool, error) {
 	select {
 	case c := <-pool:
 		return c, true, nil // pooled connection
@@ -548,11 +896,7 @@ func (f *ForwardingResolver) acquireConnection(
 		return nil, false, ctx.Err()
 	default:
 		// Pool empty - create transient connection
-		addr, err := net.ResolveUDPAddr("udp", net.JoinHostPort(up, "53"))
-		if err != nil {
-			return nil, false, err
-		}
-		c, err := net.DialUDP("udp", nil, addr)
+		c, err := f.dialer.DialContext(ctx, "udp", net.JoinHostPort(up, "53"))
 		if err != nil {
 			return nil, false, err
 		}
@@ -561,7 +905,7 @@ func (f *ForwardingResolver) acquireConnection(
 }
 
 // releaseConnection returns a connection to the pool or closes it.
-func (f *ForwardingResolver) releaseConnection(c *net.UDPConn, pool chan *net.UDPConn, fromPool, connOK bool) {
+func (f *ForwardingResolver) releaseConnection(c net.Conn, pool chan net.Conn, fromPool, connOK bool) {
 	if !connOK {
 		_ = c.Close()
 		return
@@ -589,12 +933,11 @@ func (f *ForwardingResolver) releaseConnection(c *net.UDPConn, pool chan *net.UD
 //	| DNS  | Variable length DNS message
 //	|      |
 //	+------+
-func queryUpstreamTCP(ctx context.Context, req []byte, host string, timeout time.Duration) ([]byte, error) {
-	ctx, cancel := context.WithTimeout(ctx, timeout)
+func (f *ForwardingResolver) queryUpstreamTCP(ctx context.Context, req []byte, host string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, f.tcpTimeout)
 	defer cancel()
 
-	d := net.Dialer{}
-	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(host, "53"))
+	conn, err := f.dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, "53"))
 	if err != nil {
 		return nil, err
 	}
@@ -610,6 +953,7 @@ func queryUpstreamTCP(ctx context.Context, req []byte, host string, timeout time
 	if _, err := conn.Write(req); err != nil {
 		return nil, err
 	}
+	f.captureMessage(ctx, "tcp", conn.LocalAddr(), conn.RemoteAddr(), req)
 
 	// Read response length
 	if _, err := io.ReadFull(conn, prefix[:]); err != nil {
@@ -625,6 +969,10 @@ func queryUpstreamTCP(ctx context.Context, req []byte, host string, timeout time
 	if _, err := io.ReadFull(conn, resp); err != nil {
 		return nil, err
 	}
+	f.captureMessage(ctx, "tcp", conn.RemoteAddr(), conn.LocalAddr(), resp)
+	if !newOutstandingQuery(req).matches(resp) {
+		return nil, errResponseMismatch
+	}
 	return resp, nil
 }
 
@@ -634,7 +982,10 @@ func queryUpstreamTCP(ctx context.Context, req []byte, host string, timeout time
 //   - QNAME matches (case-insensitive)
 //   - QTYPE matches
 //   - QCLASS matches
-func validateResponse(req dns.Packet, respBytes []byte) error {
+//
+// It also rejects answers whose CNAME chain loops or exceeds maxCNAMEChain
+// links, so that they are neither cached nor returned to clients.
+func validateResponse(req dns.Packet, respBytes []byte, maxCNAMEChain int) error {
 	resp, err := dns.ParsePacket(respBytes)
 	if err != nil {
 		return fmt.Errorf("failed to parse upstream response: %w", err)
@@ -656,7 +1007,7 @@ func validateResponse(req dns.Packet, respBytes []byte) error {
 	if reqQ.Class != resQ.Class {
 		return fmt.Errorf("QCLASS mismatch: expected %d, got %d", reqQ.Class, resQ.Class)
 	}
-	return nil
+	return checkCNAMEChain(reqQ.Name, resp.Answers, maxCNAMEChain)
 }
 
 // equalDNSNames compares two DNS names case-insensitively, ignoring trailing dots.
@@ -670,14 +1021,20 @@ func equalDNSNames(a, b string) bool {
 // Different response types (positive, NXDOMAIN, NODATA, SERVFAIL) are
 // cached with different TTLs based on RFC 2308 guidance.
 func (f *ForwardingResolver) storeInCache(key cacheKey, resp []byte) {
-	decision := analyzeCacheDecision(resp)
+	if f.cacheBypass.Load().Matches(key.q.QName) {
+		return
+	}
+	policy := f.negativeRules.Load().PolicyFor(key.q.QName)
+	decision := analyzeCacheDecision(resp, policy)
 
 	// Only cache if we have a valid TTL
 	if decision.ttlSeconds <= 0 {
 		return
 	}
 
-	f.cache.Set(key, resp, time.Duration(decision.ttlSeconds)*time.Second, decision.entryType)
+	ttl := time.Duration(decision.ttlSeconds) * time.Second
+	f.cache.Set(key, resp, ttl, decision.entryType)
+	f.storeShared(key, resp, ttl, decision.entryType)
 }
 
 // cacheDecision contains the result of analyzing a response for caching.
@@ -689,11 +1046,13 @@ type cacheDecision struct {
 // analyzeCacheDecision determines caching parameters from a DNS response.
 //
 // Caching rules (based on RFC 2308):
-//   - SERVFAIL: Cache for 30 seconds
-//   - NXDOMAIN: Use SOA MINIMUM field, or 300 seconds if no SOA
-//   - NODATA (no answers): Use SOA MINIMUM field, or 300 seconds if no SOA
+//   - SERVFAIL: Cache for policy.ServfailTTL
+//   - NXDOMAIN: Use SOA MINIMUM field, or policy.NegativeTTL if no SOA
+//   - NODATA (no answers): Use SOA MINIMUM field, or policy.NegativeTTL if no SOA
 //   - Success: Use minimum TTL from answer records
-func analyzeCacheDecision(respBytes []byte) cacheDecision {
+//
+// When policy.Enabled is false, negative and SERVFAIL responses are not cached.
+func analyzeCacheDecision(respBytes []byte, policy NegativeCachePolicy) cacheDecision {
 	resp, err := dns.ParsePacket(respBytes)
 	if err != nil {
 		return cacheDecision{ttlSeconds: 0, entryType: CachePositive}
@@ -703,15 +1062,14 @@ func analyzeCacheDecision(respBytes []byte) cacheDecision {
 
 	// Handle error responses
 	if rcode == dns.RCodeServFail {
-		return cacheDecision{ttlSeconds: 30, entryType: CacheSERVFAIL}
+		if !policy.Enabled {
+			return cacheDecision{ttlSeconds: 0, entryType: CacheSERVFAIL}
+		}
+		return cacheDecision{ttlSeconds: int(policy.ServfailTTL / time.Second), entryType: CacheSERVFAIL}
 	}
 
 	if rcode == dns.RCodeNXDomain {
-		ttl := extractSOAMinimum(resp)
-		if ttl <= 0 {
-			ttl = 300 // default negative cache TTL
-		}
-		return cacheDecision{ttlSeconds: ttl, entryType: CacheNXDOMAIN}
+		return negativeCacheDecision(resp, policy, CacheNXDOMAIN)
 	}
 
 	if rcode != dns.RCodeNoError {
@@ -720,11 +1078,7 @@ func analyzeCacheDecision(respBytes []byte) cacheDecision {
 
 	// NODATA: success but no answers
 	if len(resp.Answers) == 0 {
-		ttl := extractSOAMinimum(resp)
-		if ttl <= 0 {
-			ttl = 300 // default negative cache TTL
-		}
-		return cacheDecision{ttlSeconds: ttl, entryType: CacheNODATA}
+		return negativeCacheDecision(resp, policy, CacheNODATA)
 	}
 
 	// Positive response: use minimum TTL from answers
@@ -732,6 +1086,19 @@ func analyzeCacheDecision(respBytes []byte) cacheDecision {
 	return cacheDecision{ttlSeconds: minTTL, entryType: CachePositive}
 }
 
+// negativeCacheDecision computes the TTL for an NXDOMAIN or NODATA response:
+// the SOA MINIMUM if present, otherwise the policy's default negative TTL.
+func negativeCacheDecision(resp dns.Packet, policy NegativeCachePolicy, entryType CacheEntryType) cacheDecision {
+	if !policy.Enabled {
+		return cacheDecision{ttlSeconds: 0, entryType: entryType}
+	}
+	ttl := extractSOAMinimum(resp)
+	if ttl <= 0 {
+		ttl = int(policy.NegativeTTL / time.Second)
+	}
+	return cacheDecision{ttlSeconds: ttl, entryType: entryType}
+}
+
 // findMinimumTTL returns the smallest non-zero TTL from a list of records.
 // Returns 0 if no valid TTLs are found.
 func findMinimumTTL(answers []dns.Record) int {
@@ -0,0 +1,93 @@
+package resolvers
+
+import (
+	"context"
+	"net/netip"
+	"slices"
+	"strconv"
+
+	"github.com/jroosing/hydradns/internal/dns"
+	"github.com/jroosing/hydradns/internal/geoip"
+)
+
+// GeoIPResolver refuses answers that resolve into blocked autonomous systems
+// or countries. It wraps the forwarding resolver, so locally configured
+// custom DNS records are never checked.
+//
+// Each A and AAAA answer of a successful response is looked up in the GeoIP
+// databases; if any address is in a blocked ASN or country the client gets
+// NXDOMAIN, with an Extended DNS Error (Blocked) naming the match for clients
+// that sent EDNS. Responses the databases know nothing about pass through.
+type GeoIPResolver struct {
+	db             *geoip.DB
+	next           Resolver
+	blockASNs      []uint32
+	blockCountries []string
+}
+
+// NewGeoIPResolver creates a GeoIP policy resolver in front of next.
+// Countries are ISO 3166-1 alpha-2 codes in upper case.
+func NewGeoIPResolver(db *geoip.DB, next Resolver, blockASNs []uint32, blockCountries []string) *GeoIPResolver {
+	return &GeoIPResolver{
+		db:             db,
+		next:           next,
+		blockASNs:      blockASNs,
+		blockCountries: blockCountries,
+	}
+}
+
+// Resolve asks the next resolver and fails with a *BlockedError when an
+// answer address is blocked.
+func (g *GeoIPResolver) Resolve(ctx context.Context, req dns.Packet, reqBytes []byte) (Result, error) {
+	res, err := g.next.Resolve(ctx, req, reqBytes)
+	if err != nil || len(res.ResponseBytes) < 4 || res.ResponseBytes[3]&0x0F != uint8(dns.RCodeNoError) {
+		return res, err
+	}
+
+	resp, err := dns.ParsePacket(res.ResponseBytes)
+	if err != nil {
+		// Let the client deal with what upstream sent
+		return res, nil
+	}
+	reason, blocked := g.blockedAnswer(resp.Answers)
+	if !blocked {
+		return res, nil
+	}
+	return Result{}, &BlockedError{Source: "geoip-blocked", Reason: "answer in blocked " + reason}
+}
+
+// blockedAnswer reports whether any address in answers is in a blocked ASN
+// or country, and which one ("AS64500" or "country NL").
+func (g *GeoIPResolver) blockedAnswer(answers []dns.Record) (string, bool) {
+	for _, ip := range AnswerAddrs(answers) {
+		info := g.db.Lookup(ip)
+		if info.ASN != 0 && slices.Contains(g.blockASNs, info.ASN) {
+			return "AS" + strconv.FormatUint(uint64(info.ASN), 10), true
+		}
+		if info.Country != "" && slices.Contains(g.blockCountries, info.Country) {
+			return "country " + info.Country, true
+		}
+	}
+	return "", false
+}
+
+// Close closes the next resolver. The GeoIP databases are owned by the
+// caller.
+func (g *GeoIPResolver) Close() error {
+	return g.next.Close()
+}
+
+// AnswerAddrs returns the addresses of the A and AAAA records in answers.
+func AnswerAddrs(answers []dns.Record) []netip.Addr {
+	var out []netip.Addr
+	for _, rr := range answers {
+		rec, ok := rr.(*dns.IPRecord)
+		if !ok {
+			continue
+		}
+		if ip, ok := netip.AddrFromSlice(rec.Addr); ok {
+			out = append(out, ip.Unmap())
+		}
+	}
+	return out
+}
@@ -0,0 +1,65 @@
+package resolvers
+
+import (
+	"strings"
+	"time"
+)
+
+// Default negative caching TTLs.
+const (
+	// DefaultServfailTTL is how long SERVFAIL responses are cached.
+	DefaultServfailTTL = 30 * time.Second
+	// DefaultNegativeTTL is the NXDOMAIN/NODATA TTL used when the response has no SOA.
+	DefaultNegativeTTL = 5 * time.Minute
+)
+
+// NegativeCachePolicy controls how NXDOMAIN, NODATA, and SERVFAIL responses are cached.
+type NegativeCachePolicy struct {
+	Enabled     bool          // Cache negative and SERVFAIL responses at all
+	ServfailTTL time.Duration // SERVFAIL TTL; 0 disables SERVFAIL caching
+	NegativeTTL time.Duration // NXDOMAIN/NODATA TTL when no SOA is present; 0 disables
+}
+
+// DefaultNegativeCachePolicy returns the built-in negative caching behavior.
+func DefaultNegativeCachePolicy() NegativeCachePolicy {
+	return NegativeCachePolicy{
+		Enabled:     true,
+		ServfailTTL: DefaultServfailTTL,
+		NegativeTTL: DefaultNegativeTTL,
+	}
+}
+
+// NegativeCacheRules is a global policy plus per-zone overrides.
+//
+// Zone keys are lowercase names without a trailing dot. A zone applies to
+// itself and all names below it; the most specific zone wins.
+type NegativeCacheRules struct {
+	Default NegativeCachePolicy
+	Zones   map[string]NegativeCachePolicy
+}
+
+// PolicyFor returns the policy that applies to qname.
+func (r *NegativeCacheRules) PolicyFor(qname string) NegativeCachePolicy {
+	if len(r.Zones) == 0 {
+		return r.Default
+	}
+
+	name := strings.TrimSuffix(strings.ToLower(qname), ".")
+	for name != "" {
+		if p, ok := r.Zones[name]; ok {
+			return p
+		}
+		i := strings.IndexByte(name, '.')
+		if i < 0 {
+			break
+		}
+		name = name[i+1:]
+	}
+	return r.Default
+}
+
+// SetNegativeCacheRules replaces the negative caching rules.
+// Entries already in the cache keep their original TTL.
+func (f *ForwardingResolver) SetNegativeCacheRules(rules NegativeCacheRules) {
+	f.negativeRules.Store(&rules)
+}
@@ -0,0 +1,48 @@
+package resolvers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jroosing/hydradns/internal/dns"
+)
+
+// ErrRecursionRefused is returned by resolvers that would have to recurse
+// (forward upstream) for a query marked with WithoutRecursion.
+var ErrRecursionRefused = errors.New("recursion not offered for this query")
+
+// noRecursionKey marks contexts of queries that must not be forwarded.
+type noRecursionKey struct{}
+
+// WithoutRecursion returns a context marking the query as not allowed to
+// recurse: the client did not set RD, or recursion is not offered to it.
+// Only local answers (custom DNS, filtering) are given for such queries.
+func WithoutRecursion(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noRecursionKey{}, true)
+}
+
+// RecursionRefused reports whether ctx was marked with WithoutRecursion.
+func RecursionRefused(ctx context.Context) bool {
+	refused, _ := ctx.Value(noRecursionKey{}).(bool)
+	return refused
+}
+
+// SetRecursionAvailable returns msg with the RA flag set to ra. msg is copied
+// when the flag changes, as it may be shared with the cache.
+func SetRecursionAvailable(msg []byte, ra bool) []byte {
+	if len(msg) < 4 {
+		return msg
+	}
+	const raBit = byte(dns.RAFlag)
+	if (msg[3]&raBit != 0) == ra {
+		return msg
+	}
+	out := make([]byte, len(msg))
+	copy(out, msg)
+	if ra {
+		out[3] |= raBit
+	} else {
+		out[3] &^= raBit
+	}
+	return out
+}
@@ -2,14 +2,15 @@ package resolvers
 
 import (
 	"context"
-	"errors"
+	"fmt"
 	"sync"
 
 	"github.com/jroosing/hydradns/internal/dns"
 )
 
-// ErrNoCustomDNS is returned when no custom DNS resolver is configured.
-var ErrNoCustomDNS = errors.New("no custom DNS resolver configured")
+// ErrNoCustomDNS is returned when no custom DNS resolver is configured. It
+// matches ErrNotInZone, so Chained asks the next resolver.
+var ErrNoCustomDNS = fmt.Errorf("no custom DNS resolver configured: %w", ErrNotInZone)
 
 // ReloadableCustomDNSResolver wraps a CustomDNSResolver and allows atomic replacement.
 // This enables runtime updates to custom DNS configuration without server restart.
@@ -89,3 +90,17 @@ func (r *ReloadableCustomDNSResolver) ContainsDomain(name string) bool {
 	}
 	return r.resolver.ContainsDomain(name)
 }
+
+// Skip reports whether the current CustomDNSResolver could not answer req
+// (see CustomDNSResolver.Skip). It follows reloads, so it can be used as the
+// Stage.Skip of the custom DNS stage. Everything is skipped while no custom
+// DNS is configured.
+func (r *ReloadableCustomDNSResolver) Skip(req dns.Packet) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.resolver == nil {
+		return true
+	}
+	return r.resolver.Skip(req)
+}
@@ -2,12 +2,27 @@
 package resolvers_test
 
 import (
+	"bytes"
 	"context"
+	"encoding/binary"
 	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/jroosing/hydradns/internal/capture"
+	"github.com/jroosing/hydradns/internal/clock"
 	"github.com/jroosing/hydradns/internal/dns"
+	"github.com/jroosing/hydradns/internal/filtering"
+	"github.com/jroosing/hydradns/internal/geoip"
+	"github.com/jroosing/hydradns/internal/geoip/geoiptest"
 	"github.com/jroosing/hydradns/internal/resolvers"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -54,6 +69,45 @@ func TestPatchTransactionID_EmptyMessage(t *testing.T) {
 	assert.Equal(t, msg, result)
 }
 
+// ============================================================================
+// RestoreQuestionCase Tests
+// ============================================================================
+
+func TestRestoreQuestionCase_EchoesClientCase(t *testing.T) {
+	_, req := newAQuery(t, 1, "WwW.Example.COM")
+	_, resp := newAQuery(t, 1, "www.example.com")
+	orig := append([]byte(nil), resp...)
+
+	got := resolvers.RestoreQuestionCase(resp, req)
+
+	assert.Equal(t, req[12:], got[12:])
+	assert.Equal(t, orig, resp, "input must not be modified")
+}
+
+func TestRestoreQuestionCase_SameCase_NoAllocation(t *testing.T) {
+	_, req := newAQuery(t, 1, "Example.com")
+	_, resp := newAQuery(t, 1, "Example.com")
+
+	got := resolvers.RestoreQuestionCase(resp, req)
+	assert.Same(t, &resp[0], &got[0])
+}
+
+func TestRestoreQuestionCase_DifferentName_Unchanged(t *testing.T) {
+	_, req := newAQuery(t, 1, "EXAMPLE.com")
+	_, resp := newAQuery(t, 1, "example.net")
+
+	got := resolvers.RestoreQuestionCase(resp, req)
+	assert.Same(t, &resp[0], &got[0])
+}
+
+func TestRestoreQuestionCase_NoQuestion_Unchanged(t *testing.T) {
+	_, req := newAQuery(t, 1, "EXAMPLE.com")
+	resp := []byte{0x12, 0x34, 0x81, 0x80, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+
+	assert.Equal(t, resp, resolvers.RestoreQuestionCase(resp, req))
+	assert.Nil(t, resolvers.RestoreQuestionCase(nil, req))
+}
+
 // ============================================================================
 // TTLCache Tests
 // ============================================================================
@@ -90,6 +144,24 @@ func TestTTLCache_Expiration(t *testing.T) {
 	assert.False(t, found, "Entry should be expired")
 }
 
+func TestTTLCache_ExpirationWithClock(t *testing.T) {
+	clk := clock.NewManual(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	cache := resolvers.NewTTLCache[string, []byte](100)
+	cache.SetClock(clk)
+
+	cache.Set("key1", []byte("value1"), time.Minute, resolvers.CachePositive)
+
+	clk.Advance(59 * time.Second)
+	_, age, found, _ := cache.GetWithAge("key1")
+	assert.True(t, found)
+	assert.Equal(t, 59*time.Second, age)
+
+	clk.Advance(time.Second)
+	_, found, _ = cache.Get("key1")
+	assert.False(t, found, "expires exactly at its TTL")
+	assert.Equal(t, uint64(1), cache.Stats().Expirations)
+}
+
 func TestTTLCache_LRUEviction(t *testing.T) {
 	cache := resolvers.NewTTLCache[int, []byte](3)
 
@@ -155,6 +227,189 @@ func TestTTLCache_ZeroTTL_NotStored(t *testing.T) {
 	assert.False(t, found, "Entry with TTL=0 should not be stored")
 }
 
+func TestTTLCache_DeleteFunc(t *testing.T) {
+	cache := resolvers.NewTTLCache[string, int](10)
+	cache.Set("a.example.com", 1, time.Minute, resolvers.CachePositive)
+	cache.Set("b.example.com", 2, time.Minute, resolvers.CacheNXDOMAIN)
+	cache.Set("c.example.org", 3, time.Minute, resolvers.CachePositive)
+
+	n := cache.DeleteFunc(func(k string) bool { return strings.HasSuffix(k, ".example.com") })
+	assert.Equal(t, 2, n)
+
+	_, found, _ := cache.Get("a.example.com")
+	assert.False(t, found)
+	_, found, _ = cache.Get("c.example.org")
+	assert.True(t, found)
+}
+
+func TestTTLCache_LFUEviction(t *testing.T) {
+	cache := resolvers.NewTTLCache[int, []byte](3)
+	cache.SetEvictionPolicy(resolvers.EvictLFU)
+
+	cache.Set(1, []byte("one"), time.Minute, resolvers.CachePositive)
+	cache.Set(2, []byte("two"), time.Minute, resolvers.CachePositive)
+	cache.Set(3, []byte("three"), time.Minute, resolvers.CachePositive)
+
+	// Key 1 is used most; key 2 is used once more but earliest
+	cache.Get(1)
+	cache.Get(1)
+	cache.Get(2)
+	cache.Get(3)
+	cache.Get(1)
+
+	// Keys 2 and 3 tie on uses; key 2 was used less recently
+	cache.Set(4, []byte("four"), time.Minute, resolvers.CachePositive)
+
+	_, found1, _ := cache.Get(1)
+	_, found2, _ := cache.Get(2)
+	_, found3, _ := cache.Get(3)
+	_, found4, _ := cache.Get(4)
+
+	assert.True(t, found1, "Key 1 should still exist (most used)")
+	assert.False(t, found2, "Key 2 should be evicted (least used, least recent)")
+	assert.True(t, found3, "Key 3 should still exist")
+	assert.True(t, found4, "Key 4 should exist (new entries are never their own victim)")
+}
+
+func TestTTLCache_TTLEviction(t *testing.T) {
+	cache := resolvers.NewTTLCache[int, []byte](3)
+	cache.SetEvictionPolicy(resolvers.EvictTTL)
+
+	cache.Set(1, []byte("one"), time.Hour, resolvers.CachePositive)
+	cache.Set(2, []byte("two"), time.Minute, resolvers.CachePositive)
+	cache.Set(3, []byte("three"), 10*time.Minute, resolvers.CachePositive)
+
+	// Using key 2 does not save it: it expires soonest
+	cache.Get(2)
+	cache.Set(4, []byte("four"), 30*time.Minute, resolvers.CachePositive)
+
+	_, found2, _ := cache.Get(2)
+	assert.False(t, found2, "Key 2 should be evicted (closest to expiry)")
+	for _, k := range []int{1, 3, 4} {
+		_, found, _ := cache.Get(k)
+		assert.True(t, found, "Key %d should still exist", k)
+	}
+}
+
+func TestTTLCache_SetEvictionPolicyKeepsEntries(t *testing.T) {
+	cache := resolvers.NewTTLCache[int, []byte](2)
+	cache.Set(1, []byte("one"), time.Hour, resolvers.CachePositive)
+	cache.Set(2, []byte("two"), time.Minute, resolvers.CachePositive)
+
+	cache.SetEvictionPolicy(resolvers.EvictTTL)
+	assert.Equal(t, 2, cache.Stats().Entries)
+
+	cache.Set(3, []byte("three"), time.Hour, resolvers.CachePositive)
+	_, found2, _ := cache.Get(2)
+	assert.False(t, found2, "Key 2 should be evicted under the new policy")
+
+	cache.SetEvictionPolicy(resolvers.EvictLRU)
+	cache.Set(4, []byte("four"), time.Hour, resolvers.CachePositive)
+	assert.Equal(t, 2, cache.Stats().Entries)
+}
+
+func TestTTLCache_Stats(t *testing.T) {
+	cache := resolvers.NewTTLCache[string, []byte](2)
+
+	cache.Set("a", []byte("a"), time.Minute, resolvers.CachePositive)
+	cache.Set("nx", []byte("nx"), time.Minute, resolvers.CacheNXDOMAIN)
+	cache.Set("short", []byte("s"), time.Millisecond, resolvers.CachePositive) // evicts "a"
+	time.Sleep(5 * time.Millisecond)
+
+	cache.Get("nx")    // negative hit
+	cache.Get("a")     // miss (evicted)
+	cache.Get("short") // miss (expired)
+
+	stats := cache.Stats()
+	assert.Equal(t, resolvers.EvictLRU, stats.Policy)
+	assert.Equal(t, 1, stats.Entries)
+	assert.Equal(t, 2, stats.MaxEntries)
+	assert.Equal(t, uint64(1), stats.Hits)
+	assert.Equal(t, uint64(1), stats.NegativeHits)
+	assert.Equal(t, uint64(2), stats.Misses)
+	assert.Equal(t, uint64(1), stats.Evictions)
+	assert.Equal(t, uint64(1), stats.Expirations)
+	assert.InDelta(t, 1.0/3.0, stats.HitRatio, 1e-9)
+}
+
+func TestTTLCache_ExpiredVictimCountsAsExpiration(t *testing.T) {
+	cache := resolvers.NewTTLCache[string, []byte](1)
+
+	cache.Set("old", []byte("old"), time.Millisecond, resolvers.CachePositive)
+	time.Sleep(5 * time.Millisecond)
+	cache.Set("new", []byte("new"), time.Minute, resolvers.CachePositive)
+
+	stats := cache.Stats()
+	assert.Equal(t, uint64(0), stats.Evictions)
+	assert.Equal(t, uint64(1), stats.Expirations)
+	assert.Zero(t, stats.HitRatio, "no lookups yet")
+}
+
+func TestTTLCache_ByteLimits(t *testing.T) {
+	cache := resolvers.NewTTLCache[string, []byte](100)
+	cache.SetByteLimits(10, 6, func(b []byte) int { return len(b) })
+
+	cache.Set("a", make([]byte, 4), time.Minute, resolvers.CachePositive)
+	cache.Set("b", make([]byte, 4), time.Minute, resolvers.CachePositive)
+	assert.Equal(t, 8, cache.Stats().Bytes)
+
+	// A third 4-byte value exceeds the 10-byte budget; "a" is evicted
+	cache.Set("c", make([]byte, 4), time.Minute, resolvers.CachePositive)
+	_, foundA, _ := cache.Get("a")
+	assert.False(t, foundA)
+
+	// Too large for one entry: not cached
+	cache.Set("huge", make([]byte, 7), time.Minute, resolvers.CachePositive)
+	_, foundHuge, _ := cache.Get("huge")
+	assert.False(t, foundHuge)
+
+	// Growing an entry beyond the limit drops the old value too
+	cache.Set("b", make([]byte, 7), time.Minute, resolvers.CachePositive)
+	_, foundB, _ := cache.Get("b")
+	assert.False(t, foundB)
+
+	stats := cache.Stats()
+	assert.Equal(t, 4, stats.Bytes)
+	assert.Equal(t, 10, stats.MaxBytes)
+	assert.Equal(t, 1, stats.Entries)
+	assert.Equal(t, uint64(1), stats.Evictions)
+	assert.Equal(t, uint64(2), stats.Oversized)
+}
+
+func TestTTLCache_ByteLimitsAppliedToExistingEntries(t *testing.T) {
+	cache := resolvers.NewTTLCache[string, []byte](100)
+	cache.Set("a", make([]byte, 5), time.Minute, resolvers.CachePositive)
+	cache.Set("b", make([]byte, 5), time.Minute, resolvers.CachePositive)
+	cache.Set("big", make([]byte, 20), time.Minute, resolvers.CachePositive)
+	assert.Zero(t, cache.Stats().Bytes, "nothing is measured without a size function")
+
+	cache.SetByteLimits(8, 10, func(b []byte) int { return len(b) })
+	stats := cache.Stats()
+	assert.Equal(t, 1, stats.Entries)
+	assert.Equal(t, 5, stats.Bytes)
+	assert.Equal(t, uint64(1), stats.Oversized)
+
+	cache.DeleteFunc(func(string) bool { return true })
+	assert.Zero(t, cache.Stats().Bytes)
+}
+
+func TestParseEvictionPolicy(t *testing.T) {
+	for in, want := range map[string]resolvers.EvictionPolicy{
+		"":      resolvers.EvictLRU,
+		"lru":   resolvers.EvictLRU,
+		" LFU ": resolvers.EvictLFU,
+		"ttl":   resolvers.EvictTTL,
+	} {
+		got, err := resolvers.ParseEvictionPolicy(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+
+	_, err := resolvers.ParseEvictionPolicy("random")
+	assert.Error(t, err)
+	assert.Equal(t, "lfu", resolvers.EvictLFU.String())
+}
+
 // ============================================================================
 // QuestionKey Tests
 // ============================================================================
@@ -222,7 +477,7 @@ func TestChained_FirstResolverSucceeds(t *testing.T) {
 func TestChained_FallsBackToSecond(t *testing.T) {
 	first := &mockResolver{
 		resolveFunc: func(_ context.Context, _ dns.Packet, _ []byte) (resolvers.Result, error) {
-			return resolvers.Result{}, errors.New("first failed")
+			return resolvers.Result{}, fmt.Errorf("%w: first", resolvers.ErrNotInZone)
 		},
 	}
 	second := &mockResolver{
@@ -242,12 +497,12 @@ func TestChained_FallsBackToSecond(t *testing.T) {
 func TestChained_AllFail(t *testing.T) {
 	first := &mockResolver{
 		resolveFunc: func(_ context.Context, _ dns.Packet, _ []byte) (resolvers.Result, error) {
-			return resolvers.Result{}, errors.New("first failed")
+			return resolvers.Result{}, fmt.Errorf("%w: first", resolvers.ErrNotInZone)
 		},
 	}
 	second := &mockResolver{
 		resolveFunc: func(_ context.Context, _ dns.Packet, _ []byte) (resolvers.Result, error) {
-			return resolvers.Result{}, errors.New("second failed")
+			return resolvers.Result{}, fmt.Errorf("%w: second", resolvers.ErrNotInZone)
 		},
 	}
 
@@ -255,8 +510,125 @@ func TestChained_AllFail(t *testing.T) {
 
 	_, err := chained.Resolve(context.Background(), dns.Packet{}, nil)
 
-	require.Error(t, err)
-	assert.Equal(t, "second failed", err.Error())
+	require.ErrorIs(t, err, resolvers.ErrNotInZone)
+	assert.Contains(t, err.Error(), "second")
+}
+
+func TestChained_StopsOnOtherErrors(t *testing.T) {
+	first := &mockResolver{
+		resolveFunc: func(_ context.Context, _ dns.Packet, _ []byte) (resolvers.Result, error) {
+			return resolvers.Result{}, resolvers.ErrNoUpstream
+		},
+	}
+	secondCalled := false
+	second := &mockResolver{
+		resolveFunc: func(_ context.Context, _ dns.Packet, _ []byte) (resolvers.Result, error) {
+			secondCalled = true
+			return resolvers.Result{Source: "second"}, nil
+		},
+	}
+
+	chained := &resolvers.Chained{Resolvers: []resolvers.Resolver{first, second}}
+
+	_, err := chained.Resolve(context.Background(), dns.Packet{}, nil)
+
+	require.ErrorIs(t, err, resolvers.ErrNoUpstream)
+	assert.False(t, secondCalled, "only ErrNotInZone moves on to the next resolver")
+}
+
+func TestChained_StageErrorNamesStage(t *testing.T) {
+	failing := &mockResolver{
+		resolveFunc: func(_ context.Context, _ dns.Packet, _ []byte) (resolvers.Result, error) {
+			return resolvers.Result{}, resolvers.ErrNoUpstream
+		},
+	}
+
+	chained := &resolvers.Chained{Stages: []resolvers.Stage{{Name: "upstream", Resolver: failing}}}
+	_, err := chained.Resolve(context.Background(), dns.Packet{}, nil)
+
+	var stage *resolvers.StageError
+	require.ErrorAs(t, err, &stage)
+	assert.Equal(t, "upstream", stage.Stage)
+	require.ErrorIs(t, err, resolvers.ErrNoUpstream)
+
+	// Unnamed stages are reported by index
+	chained = &resolvers.Chained{Resolvers: []resolvers.Resolver{&mockResolver{
+		resolveFunc: func(_ context.Context, _ dns.Packet, _ []byte) (resolvers.Result, error) {
+			return resolvers.Result{}, resolvers.ErrNotInZone
+		},
+	}, failing}}
+	_, err = chained.Resolve(context.Background(), dns.Packet{}, nil)
+	require.ErrorAs(t, err, &stage)
+	assert.Equal(t, "#1", stage.Stage)
+}
+
+func TestChained_StageTimeout(t *testing.T) {
+	slow := &mockResolver{
+		resolveFunc: func(ctx context.Context, _ dns.Packet, _ []byte) (resolvers.Result, error) {
+			<-ctx.Done()
+			return resolvers.Result{}, ctx.Err()
+		},
+	}
+	nextCalled := false
+	next := &mockResolver{
+		resolveFunc: func(_ context.Context, _ dns.Packet, _ []byte) (resolvers.Result, error) {
+			nextCalled = true
+			return resolvers.Result{Source: "next"}, nil
+		},
+	}
+
+	chained := &resolvers.Chained{Stages: []resolvers.Stage{
+		{Name: "zone", Resolver: slow, Timeout: 20 * time.Millisecond},
+		{Name: "upstream", Resolver: next},
+	}}
+
+	start := time.Now()
+	_, err := chained.Resolve(context.Background(), dns.Packet{}, nil)
+
+	require.ErrorIs(t, err, resolvers.ErrTimeout)
+	var stage *resolvers.StageError
+	require.ErrorAs(t, err, &stage)
+	assert.Equal(t, "zone", stage.Stage)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.False(t, nextCalled)
+}
+
+func TestChained_StageSkip(t *testing.T) {
+	zoneCalled := false
+	zone := &mockResolver{
+		resolveFunc: func(_ context.Context, _ dns.Packet, _ []byte) (resolvers.Result, error) {
+			zoneCalled = true
+			return resolvers.Result{Source: "zone"}, nil
+		},
+	}
+	upstream := &mockResolver{
+		resolveFunc: func(_ context.Context, _ dns.Packet, _ []byte) (resolvers.Result, error) {
+			return resolvers.Result{Source: "upstream"}, nil
+		},
+	}
+	chained := &resolvers.Chained{Stages: []resolvers.Stage{
+		{Name: "zone", Resolver: zone, Skip: resolvers.SkipUnlessSuffix("Home.Arpa.")},
+		{Name: "upstream", Resolver: upstream},
+	}}
+
+	query := func(name string) dns.Packet {
+		return dns.Packet{Questions: []dns.Question{{Name: name, Type: uint16(dns.TypeA), Class: uint16(dns.ClassIN)}}}
+	}
+
+	res, err := chained.Resolve(context.Background(), query("www.example.com"), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "upstream", res.Source)
+	assert.False(t, zoneCalled, "names outside the zone skip the stage")
+
+	for _, name := range []string{"nas.home.arpa", "HOME.ARPA."} {
+		res, err = chained.Resolve(context.Background(), query(name), nil)
+		require.NoError(t, err)
+		assert.Equal(t, "zone", res.Source, name)
+	}
+
+	res, err = chained.Resolve(context.Background(), query("nothome.arpa"), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "upstream", res.Source)
 }
 
 func TestChained_ContextCancellation(t *testing.T) {
@@ -309,3 +681,2125 @@ func TestChained_EmptyResolvers(t *testing.T) {
 
 	require.Error(t, err)
 }
+
+// ============================================================================
+// CustomDNSResolver Tests
+// ============================================================================
+
+func TestCustomDNSResolver_IDNHost(t *testing.T) {
+	r, err := resolvers.NewCustomDNSResolver(
+		map[string][]string{"файл.lan": {"10.0.0.5"}},
+		map[string]string{"www.файл.lan": "файл.lan"},
+	)
+	require.NoError(t, err)
+
+	assert.True(t, r.ContainsDomain("xn--80asg7a.lan"))
+	assert.True(t, r.ContainsDomain("www.xn--80asg7a.lan"))
+
+	req, b := newAQuery(t, 7, "xn--80asg7a.lan")
+	res, err := r.Resolve(context.Background(), req, b)
+	require.NoError(t, err)
+	resp, err := dns.ParsePacket(res.ResponseBytes)
+	require.NoError(t, err)
+	require.Len(t, resp.Answers, 1)
+	ip, ok := resp.Answers[0].(*dns.IPRecord)
+	require.True(t, ok)
+	assert.Equal(t, "10.0.0.5", ip.Addr.String())
+}
+
+func TestCustomDNSResolver_NoQuestion_NotInZone(t *testing.T) {
+	r, err := resolvers.NewCustomDNSResolver(map[string][]string{"host.lan": {"10.0.0.5"}}, nil)
+	require.NoError(t, err)
+
+	_, err = r.Resolve(context.Background(), dns.Packet{Header: dns.Header{ID: 7}}, nil)
+	require.ErrorIs(t, err, resolvers.ErrNotInZone, "the chain moves on to the next resolver")
+}
+
+func TestCustomDNSResolver_MergesCaseVariants(t *testing.T) {
+	r, err := resolvers.NewCustomDNSResolver(
+		map[string][]string{
+			"Test.Local":  {"10.0.0.1"},
+			"test.local.": {"10.0.0.2", "10.0.0.1"},
+		},
+		map[string]string{
+			"WWW.Test.Local": "Test.Local.",
+			"www.test.local": "test.local",
+		},
+	)
+	require.NoError(t, err)
+
+	req, b := newAQuery(t, 9, "TEST.local")
+	res, err := r.Resolve(context.Background(), req, b)
+	require.NoError(t, err)
+	resp, err := dns.ParsePacket(res.ResponseBytes)
+	require.NoError(t, err)
+
+	var got []string
+	for _, rr := range resp.Answers {
+		ip, ok := rr.(*dns.IPRecord)
+		require.True(t, ok)
+		got = append(got, ip.Addr.String())
+	}
+	assert.Equal(t, []string{"10.0.0.1", "10.0.0.2"}, got)
+	assert.True(t, r.ContainsDomain("www.test.local."))
+}
+
+func TestCustomDNSResolver_ConflictingCNAMECase(t *testing.T) {
+	r, err := resolvers.NewCustomDNSResolver(nil, map[string]string{
+		"WWW.test.local": "a.test.local",
+		"www.test.local": "b.test.local",
+	})
+	require.NoError(t, err, "a case-only duplicate must not stop startup")
+	assert.Equal(t, []string{"www.test.local"}, r.DuplicateAliases())
+
+	req, b := newAQuery(t, 10, "www.test.local")
+	res, err := r.Resolve(context.Background(), req, b)
+	require.NoError(t, err)
+	resp, err := dns.ParsePacket(res.ResponseBytes)
+	require.NoError(t, err)
+	require.NotEmpty(t, resp.Answers)
+	assert.Equal(t, "b.test.local", resp.Answers[0].(*dns.NameRecord).Target, "the canonical spelling wins")
+}
+
+func TestCustomDNSResolver_FollowsCNAMEChain(t *testing.T) {
+	r, err := resolvers.NewCustomDNSResolver(
+		map[string][]string{"nas.lan": {"10.0.0.5"}},
+		map[string]string{"www.lan": "files.lan", "files.lan": "nas.lan"},
+	)
+	require.NoError(t, err)
+
+	req, b := newAQuery(t, 11, "www.lan")
+	res, err := r.Resolve(context.Background(), req, b)
+	require.NoError(t, err)
+	resp, err := dns.ParsePacket(res.ResponseBytes)
+	require.NoError(t, err)
+
+	require.Len(t, resp.Answers, 3)
+	assert.Equal(t, "files.lan", resp.Answers[0].(*dns.NameRecord).Target)
+	assert.Equal(t, "nas.lan", resp.Answers[1].(*dns.NameRecord).Target)
+	ip, ok := resp.Answers[2].(*dns.IPRecord)
+	require.True(t, ok)
+	assert.Equal(t, "nas.lan", ip.Header().Name)
+	assert.Equal(t, "10.0.0.5", ip.Addr.String())
+}
+
+func TestCustomDNSResolver_SearchDomains(t *testing.T) {
+	r, err := resolvers.NewCustomDNSResolver(
+		map[string][]string{"nas.lan": {"10.0.0.5"}, "printer.home.arpa": {"10.0.0.9"}},
+		map[string]string{"files.lan": "nas.lan"},
+	)
+	require.NoError(t, err)
+	r.SetSearchDomains([]string{"home.arpa", "LAN."})
+
+	req, b := newAQuery(t, 15, "NAS")
+	res, err := r.Resolve(context.Background(), req, b)
+	require.NoError(t, err)
+	resp, err := dns.ParsePacket(res.ResponseBytes)
+	require.NoError(t, err)
+	require.Len(t, resp.Answers, 2)
+	assert.Equal(t, "nas.lan", resp.Answers[0].(*dns.NameRecord).Target)
+	assert.Equal(t, "10.0.0.5", resp.Answers[1].(*dns.IPRecord).Addr.String())
+
+	// Expansions follow configured CNAMEs
+	req, b = newAQuery(t, 16, "files")
+	res, err = r.Resolve(context.Background(), req, b)
+	require.NoError(t, err)
+	resp, err = dns.ParsePacket(res.ResponseBytes)
+	require.NoError(t, err)
+	require.Len(t, resp.Answers, 3)
+	assert.Equal(t, "files.lan", resp.Answers[0].(*dns.NameRecord).Target)
+
+	// Search domains are tried in order
+	req, b = newAQuery(t, 17, "printer")
+	res, err = r.Resolve(context.Background(), req, b)
+	require.NoError(t, err)
+	resp, err = dns.ParsePacket(res.ResponseBytes)
+	require.NoError(t, err)
+	assert.Equal(t, "printer.home.arpa", resp.Answers[0].(*dns.NameRecord).Target)
+
+	// Only single-label names are expanded
+	for _, name := range []string{"unknown", "nas.example"} {
+		req, b = newAQuery(t, 18, name)
+		_, err = r.Resolve(context.Background(), req, b)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestCustomDNSResolver_CNAMELoop(t *testing.T) {
+	r, err := resolvers.NewCustomDNSResolver(nil, map[string]string{
+		"a.lan": "b.lan",
+		"b.lan": "a.lan",
+	})
+	require.NoError(t, err)
+
+	req, b := newAQuery(t, 12, "a.lan")
+	_, err = r.Resolve(context.Background(), req, b)
+	require.ErrorIs(t, err, resolvers.ErrCNAMEChain)
+}
+
+func TestCustomDNSResolver_CNAMEChainTooLong(t *testing.T) {
+	r, err := resolvers.NewCustomDNSResolver(nil, map[string]string{
+		"c0.lan": "c1.lan",
+		"c1.lan": "c2.lan",
+		"c2.lan": "c3.lan",
+	})
+	require.NoError(t, err)
+	req, b := newAQuery(t, 13, "c0.lan")
+
+	r.SetMaxCNAMEChain(2)
+	_, err = r.Resolve(context.Background(), req, b)
+	require.ErrorIs(t, err, resolvers.ErrCNAMEChain)
+
+	r.SetMaxCNAMEChain(3)
+	_, err = r.Resolve(context.Background(), req, b)
+	require.NoError(t, err)
+}
+
+// answerAddrs resolves name and returns the addresses answered, in order.
+func answerAddrs(t *testing.T, r resolvers.Resolver, name string) []string {
+	t.Helper()
+	req, b := newAQuery(t, 15, name)
+	res, err := r.Resolve(context.Background(), req, b)
+	require.NoError(t, err)
+	resp, err := dns.ParsePacket(res.ResponseBytes)
+	require.NoError(t, err)
+	var got []string
+	for _, rr := range resp.Answers {
+		if ip, ok := rr.(*dns.IPRecord); ok {
+			got = append(got, ip.Addr.String())
+		}
+	}
+	return got
+}
+
+func TestCustomDNSResolver_AnswerOrder(t *testing.T) {
+	hosts := map[string][]string{
+		"web.lan":    {"10.0.0.1", "10.0.0.2", "10.0.0.3", "fd00::1"},
+		"single.lan": {"10.0.0.9"},
+	}
+	cnames := map[string]string{"www.lan": "web.lan"}
+
+	t.Run("fixed", func(t *testing.T) {
+		r, err := resolvers.NewCustomDNSResolver(hosts, cnames)
+		require.NoError(t, err)
+		for range 3 {
+			assert.Equal(t, []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}, answerAddrs(t, r, "web.lan"))
+		}
+	})
+
+	t.Run("round_robin", func(t *testing.T) {
+		r, err := resolvers.NewCustomDNSResolver(hosts, cnames)
+		require.NoError(t, err)
+		r.SetAnswerOrder(resolvers.AnswerOrderRoundRobin)
+
+		assert.Equal(t, []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}, answerAddrs(t, r, "web.lan"))
+		assert.Equal(t, []string{"10.0.0.2", "10.0.0.3", "10.0.0.1"}, answerAddrs(t, r, "WEB.lan"))
+		// Aliases rotate the name they point to
+		assert.Equal(t, []string{"10.0.0.3", "10.0.0.1", "10.0.0.2"}, answerAddrs(t, r, "www.lan"))
+		assert.Equal(t, []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}, answerAddrs(t, r, "web.lan"))
+		assert.Equal(t, []string{"10.0.0.9"}, answerAddrs(t, r, "single.lan"))
+	})
+
+	t.Run("random", func(t *testing.T) {
+		r, err := resolvers.NewCustomDNSResolver(hosts, cnames)
+		require.NoError(t, err)
+		r.SetAnswerOrder(resolvers.AnswerOrderRandom)
+
+		seen := map[string]bool{}
+		for range 100 {
+			got := answerAddrs(t, r, "web.lan")
+			assert.ElementsMatch(t, []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}, got)
+			seen[got[0]] = true
+		}
+		assert.Len(t, seen, 3, "every address should come first sometimes")
+	})
+}
+
+func TestParseAnswerOrder(t *testing.T) {
+	for s, want := range map[string]resolvers.AnswerOrder{
+		"":             resolvers.AnswerOrderFixed,
+		"fixed":        resolvers.AnswerOrderFixed,
+		" Round_Robin": resolvers.AnswerOrderRoundRobin,
+		"random":       resolvers.AnswerOrderRandom,
+	} {
+		got, err := resolvers.ParseAnswerOrder(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, want, got, s)
+	}
+	_, err := resolvers.ParseAnswerOrder("weighted")
+	assert.Error(t, err)
+}
+
+// downAddrs is an AddressHealth reporting the listed addresses unhealthy.
+type downAddrs map[netip.Addr]bool
+
+func (d downAddrs) Healthy(_ string, addr netip.Addr) bool { return !d[addr] }
+func (d downAddrs) Checked(name string) bool               { return name == "web.lan" }
+
+func TestCustomDNSResolver_AddressHealth(t *testing.T) {
+	r, err := resolvers.NewCustomDNSResolver(map[string][]string{
+		"web.lan": {"10.0.0.1", "10.0.0.2"},
+		"db.lan":  {"10.0.0.5"},
+	}, map[string]string{"www.lan": "web.lan"})
+	require.NoError(t, err)
+
+	down := downAddrs{netip.MustParseAddr("10.0.0.1"): true}
+	r.SetAddressHealth(down)
+	assert.Equal(t, []string{"10.0.0.2"}, answerAddrs(t, r, "web.lan"))
+	assert.Equal(t, []string{"10.0.0.2"}, answerAddrs(t, r, "www.lan"))
+
+	// With every address down, all are answered rather than none
+	down[netip.MustParseAddr("10.0.0.2")] = true
+	assert.Equal(t, []string{"10.0.0.1", "10.0.0.2"}, answerAddrs(t, r, "web.lan"))
+	assert.Equal(t, []string{"10.0.0.5"}, answerAddrs(t, r, "db.lan"))
+
+	// Checked names get a short TTL so clients notice failovers
+	for name, ttl := range map[string]uint32{"web.lan": 10, "db.lan": 3600} {
+		req, b := newAQuery(t, 16, name)
+		res, err := r.Resolve(context.Background(), req, b)
+		require.NoError(t, err)
+		resp, err := dns.ParsePacket(res.ResponseBytes)
+		require.NoError(t, err)
+		require.NotEmpty(t, resp.Answers)
+		assert.Equal(t, ttl, resp.Answers[0].Header().TTL, name)
+	}
+}
+
+// steeredAddrs resolves name for client and returns the answered
+// addresses.
+func steeredAddrs(t *testing.T, r resolvers.Resolver, name, client string) []string {
+	t.Helper()
+	ctx := context.Background()
+	if client != "" {
+		ctx = resolvers.WithClient(ctx, netip.MustParseAddr(client))
+	}
+	req, b := newAQuery(t, 17, name)
+	res, err := r.Resolve(ctx, req, b)
+	require.NoError(t, err)
+	resp, err := dns.ParsePacket(res.ResponseBytes)
+	require.NoError(t, err)
+	var got []string
+	for _, rr := range resp.Answers {
+		if ip, ok := rr.(*dns.IPRecord); ok {
+			got = append(got, ip.Addr.String())
+		}
+	}
+	return got
+}
+
+func TestCustomDNSResolver_SteeringByClientSubnet(t *testing.T) {
+	r, err := resolvers.NewCustomDNSResolver(map[string][]string{
+		"app.lan": {"10.0.1.5", "10.0.2.5", "10.0.3.5"},
+	}, map[string]string{"www.lan": "app.lan"})
+	require.NoError(t, err)
+	r.SetSteering(map[string][]resolvers.SteeredAddr{
+		"App.Lan": {
+			{Addr: netip.MustParseAddr("10.0.1.5"), Clients: []netip.Prefix{netip.MustParsePrefix("192.168.1.0/24")}},
+			{Addr: netip.MustParseAddr("10.0.2.5"), Clients: []netip.Prefix{netip.MustParsePrefix("192.168.2.0/24")}},
+		},
+	})
+
+	assert.Equal(t, []string{"10.0.1.5"}, steeredAddrs(t, r, "app.lan", "192.168.1.10"))
+	assert.Equal(t, []string{"10.0.2.5"}, steeredAddrs(t, r, "www.lan", "::ffff:192.168.2.10"))
+	// Other clients and queries without a client get the unlisted address
+	assert.Equal(t, []string{"10.0.3.5"}, steeredAddrs(t, r, "app.lan", "172.16.0.1"))
+	assert.Equal(t, []string{"10.0.3.5"}, steeredAddrs(t, r, "app.lan", ""))
+}
+
+func TestCustomDNSResolver_SteeringByWeight(t *testing.T) {
+	r, err := resolvers.NewCustomDNSResolver(map[string][]string{
+		"app.lan": {"10.0.1.5", "10.0.2.5", "10.0.3.5"},
+	}, nil)
+	require.NoError(t, err)
+	r.SetSteering(map[string][]resolvers.SteeredAddr{
+		"app.lan": {
+			{Addr: netip.MustParseAddr("10.0.1.5"), Weight: 3},
+			{Addr: netip.MustParseAddr("10.0.2.5"), Weight: 1},
+		},
+	})
+
+	seen := make(map[string]int)
+	for range 200 {
+		got := steeredAddrs(t, r, "app.lan", "192.168.1.10")
+		require.Len(t, got, 1, "weighted names answer one address")
+		seen[got[0]]++
+	}
+	assert.Positive(t, seen["10.0.1.5"])
+	assert.Positive(t, seen["10.0.2.5"])
+	assert.Greater(t, seen["10.0.1.5"], seen["10.0.2.5"])
+	assert.Zero(t, seen["10.0.3.5"], "addresses without a weight are standby")
+
+	// With the weighted addresses down, the standby address answers
+	r.SetAddressHealth(downAddrs{
+		netip.MustParseAddr("10.0.1.5"): true,
+		netip.MustParseAddr("10.0.2.5"): true,
+	})
+	assert.Equal(t, []string{"10.0.3.5"}, steeredAddrs(t, r, "app.lan", "192.168.1.10"))
+}
+
+func TestChained_StopsOnCNAMEChainError(t *testing.T) {
+	custom, err := resolvers.NewCustomDNSResolver(nil, map[string]string{"self.lan": "self.lan"})
+	require.NoError(t, err)
+	next := &countingResolver{}
+	chain := &resolvers.Chained{Resolvers: []resolvers.Resolver{custom, next}}
+
+	req, b := newAQuery(t, 14, "self.lan")
+	_, err = chain.Resolve(context.Background(), req, b)
+
+	require.ErrorIs(t, err, resolvers.ErrCNAMEChain)
+	assert.Zero(t, next.calls, "a looping local name must not fall through to upstream")
+}
+
+func TestCustomDNSResolver_SkipOutsideZones(t *testing.T) {
+	custom, err := resolvers.NewCustomDNSResolver(
+		map[string][]string{"nas.home.arpa": {"192.168.1.10"}, "router": {"192.168.1.1"}},
+		map[string]string{"www.Corp.Example.": "web.corp.example"},
+	)
+	require.NoError(t, err)
+
+	tests := map[string]bool{
+		"nas.home.arpa":       false,
+		"printer.home.arpa":   false,
+		"home.arpa":           false,
+		"router":              false,
+		"mail.corp.example":   false,
+		"corp.example":        false,
+		"example.com":         true,
+		"example":             true,
+		"home.arpa.evil.test": true,
+		"printer":             true,
+	}
+	for name, want := range tests {
+		req, _ := newAQuery(t, 1, name)
+		assert.Equal(t, want, custom.Skip(req), name)
+	}
+
+	assert.False(t, custom.Skip(dns.Packet{}), "a query without a question is not skipped")
+
+	custom.SetSearchDomains([]string{"home.arpa"})
+	req, _ := newAQuery(t, 1, "printer")
+	assert.False(t, custom.Skip(req), "single-label names may expand with a search domain")
+}
+
+func TestReloadableCustomDNSResolver_SkipFollowsReload(t *testing.T) {
+	reloadable := resolvers.NewReloadableCustomDNSResolver(nil)
+	req, _ := newAQuery(t, 1, "nas.home.arpa")
+	assert.True(t, reloadable.Skip(req), "everything is skipped without custom DNS")
+
+	custom, err := resolvers.NewCustomDNSResolver(map[string][]string{"nas.home.arpa": {"192.168.1.10"}}, nil)
+	require.NoError(t, err)
+	require.NoError(t, reloadable.Reload(custom))
+	assert.False(t, reloadable.Skip(req))
+}
+
+// countingResolver records how often it is asked and always fails.
+type countingResolver struct{ calls int }
+
+func (c *countingResolver) Resolve(context.Context, dns.Packet, []byte) (resolvers.Result, error) {
+	c.calls++
+	return resolvers.Result{}, errors.New("unavailable")
+}
+
+func (c *countingResolver) Close() error { return nil }
+
+// ============================================================================
+// ForwardingResolver Upstream Tests
+// ============================================================================
+
+func TestForwardingResolver_SetUpstreams_ReplacesList(t *testing.T) {
+	f := resolvers.NewForwardingResolver([]string{"9.9.9.9", "1.1.1.1"}, 1, 0, false, 0, 0, 0)
+	t.Cleanup(func() { _ = f.Close() })
+
+	f.SetUpstreams([]string{"1.1.1.1", "8.8.8.8"})
+
+	assert.Equal(t, []string{"1.1.1.1", "8.8.8.8"}, f.Upstreams())
+}
+
+func TestForwardingResolver_RefusesWithoutRecursion(t *testing.T) {
+	// Nothing listens on the upstream; a refused query never reaches it
+	f := resolvers.NewForwardingResolver([]string{"127.0.0.1:1"}, 1, 0, false, 0, 0, 0)
+	t.Cleanup(func() { _ = f.Close() })
+
+	req, b := newAQuery(t, 20, "www.example.com")
+	_, err := f.Resolve(resolvers.WithoutRecursion(context.Background()), req, b)
+	require.ErrorIs(t, err, resolvers.ErrRecursionRefused)
+}
+
+func TestForwardingResolver_SetUpstreams_Normalizes(t *testing.T) {
+	f := resolvers.NewForwardingResolver([]string{"9.9.9.9"}, 1, 0, false, 0, 0, 0)
+	t.Cleanup(func() { _ = f.Close() })
+
+	f.SetUpstreams([]string{"1.1.1.1", "2.2.2.2", "3.3.3.3", "4.4.4.4"})
+	assert.Equal(t, []string{"1.1.1.1", "2.2.2.2", "3.3.3.3"}, f.Upstreams(), "list is capped at 3")
+
+	f.SetUpstreams(nil)
+	assert.Equal(t, []string{"8.8.8.8"}, f.Upstreams(), "empty list falls back to default")
+}
+
+func TestForwardingResolver_Upstreams_ReturnsCopy(t *testing.T) {
+	input := []string{"9.9.9.9"}
+	f := resolvers.NewForwardingResolver(input, 1, 0, false, 0, 0, 0)
+	t.Cleanup(func() { _ = f.Close() })
+
+	got := f.Upstreams()
+	got[0] = "6.6.6.6"
+	input[0] = "7.7.7.7"
+
+	assert.Equal(t, []string{"9.9.9.9"}, f.Upstreams())
+}
+
+func TestForwardingResolver_UpstreamStatus_Initial(t *testing.T) {
+	f := resolvers.NewForwardingResolver([]string{"9.9.9.9", "1.1.1.1"}, 1, 0, false, 0, 0, 0)
+	t.Cleanup(func() { _ = f.Close() })
+
+	status := f.UpstreamStatus()
+	require.Len(t, status, 2)
+	assert.Equal(t, "9.9.9.9", status[0].Address)
+	assert.Equal(t, "1.1.1.1", status[1].Address)
+	for _, s := range status {
+		assert.True(t, s.Healthy)
+		assert.Zero(t, s.Queries)
+		assert.Zero(t, s.SuccessRate)
+		assert.Zero(t, s.RTTP50)
+	}
+}
+
+func TestForwardingResolver_UpstreamStatus_RecordsFailures(t *testing.T) {
+	// 192.0.2.0/24 (TEST-NET-1) is never routed, so every query fails.
+	f := resolvers.NewForwardingResolver([]string{"192.0.2.1"}, 1, 0, false, 50*time.Millisecond, 0, 1)
+	t.Cleanup(func() { _ = f.Close() })
+
+	req := dns.Packet{
+		Header:    dns.Header{ID: 1, Flags: dns.RDFlag},
+		Questions: []dns.Question{{Name: "example.com", Type: uint16(dns.TypeA), Class: uint16(dns.ClassIN)}},
+	}
+	reqBytes, err := req.Marshal()
+	require.NoError(t, err)
+
+	_, err = f.Resolve(context.Background(), req, reqBytes)
+	require.Error(t, err)
+
+	status := f.UpstreamStatus()
+	require.Len(t, status, 1)
+	assert.False(t, status[0].Healthy)
+	assert.Equal(t, uint64(1), status[0].Failures)
+	assert.Equal(t, uint64(1), status[0].ConsecutiveFailures)
+	assert.Zero(t, status[0].SuccessRate)
+	assert.NotEmpty(t, status[0].LastError)
+	assert.False(t, status[0].LastFailure.IsZero())
+}
+
+func TestForwardingResolver_SetUpstreams_DropsRemovedStats(t *testing.T) {
+	f := resolvers.NewForwardingResolver([]string{"9.9.9.9"}, 1, 0, false, 0, 0, 0)
+	t.Cleanup(func() { _ = f.Close() })
+
+	f.SetUpstreams([]string{"1.1.1.1"})
+
+	status := f.UpstreamStatus()
+	require.Len(t, status, 1)
+	assert.Equal(t, "1.1.1.1", status[0].Address)
+}
+
+// ============================================================================
+// Singleflight Tests
+// ============================================================================
+
+// fakeUpstreamAddr is the loopback address the fake upstream binds to.
+// Upstreams are always queried on port 53.
+const fakeUpstreamAddr = "127.0.0.153"
+
+// startFakeUpstream serves A answers for every query after delay and
+// returns a counter of queries received. The test is skipped if port 53
+// cannot be bound.
+func startFakeUpstream(t *testing.T, delay time.Duration) *atomic.Int32 {
+	t.Helper()
+	return startFakeUpstreamFunc(t, delay, func(q dns.Question) []dns.Record {
+		return []dns.Record{dns.NewIPRecord(
+			dns.RRHeader{Name: q.Name, Class: uint16(dns.ClassIN), TTL: 60},
+			net.IPv4(192, 0, 2, 10),
+		)}
+	})
+}
+
+// startFakeUpstreamFunc is like startFakeUpstream but answers each query
+// with the records returned by answer.
+func startFakeUpstreamFunc(t *testing.T, delay time.Duration, answer func(dns.Question) []dns.Record) *atomic.Int32 {
+	t.Helper()
+	return startFakeUpstreamPacket(t, delay, func(req dns.Packet) dns.Packet {
+		resp := req
+		resp.Answers = answer(req.Questions[0])
+		return resp
+	})
+}
+
+// startFakeUpstreamPacket is like startFakeUpstream but answers each query
+// with the packet returned by respond, with QR set.
+func startFakeUpstreamPacket(t *testing.T, delay time.Duration, respond func(dns.Packet) dns.Packet) *atomic.Int32 {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP(fakeUpstreamAddr), Port: 53})
+	if err != nil {
+		t.Skipf("cannot bind fake upstream: %v", err)
+	}
+
+	var queries atomic.Int32
+	var wg sync.WaitGroup
+	t.Cleanup(func() {
+		_ = conn.Close()
+		wg.Wait()
+	})
+
+	wg.Go(func() {
+		buf := make([]byte, 4096)
+		for {
+			n, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			queries.Add(1)
+			req, err := dns.ParsePacket(buf[:n])
+			if err != nil || len(req.Questions) == 0 {
+				continue
+			}
+			wg.Go(func() {
+				time.Sleep(delay)
+				resp := respond(req)
+				resp.Header.Flags |= dns.QRFlag
+				b, err := resp.Marshal()
+				if err != nil {
+					return
+				}
+				_, _ = conn.WriteToUDP(b, addr)
+			})
+		}
+	})
+	return &queries
+}
+
+func newAQuery(t *testing.T, id uint16, name string) (dns.Packet, []byte) {
+	t.Helper()
+	req := dns.Packet{
+		Header:    dns.Header{ID: id, Flags: dns.RDFlag},
+		Questions: []dns.Question{{Name: name, Type: uint16(dns.TypeA), Class: uint16(dns.ClassIN)}},
+	}
+	b, err := req.Marshal()
+	require.NoError(t, err)
+	return req, b
+}
+
+func TestForwardingResolver_CoalescesConcurrentQueries(t *testing.T) {
+	queries := startFakeUpstream(t, 200*time.Millisecond)
+	f := resolvers.NewForwardingResolver([]string{fakeUpstreamAddr}, 1, 0, false, time.Second, 0, 1)
+	t.Cleanup(func() { _ = f.Close() })
+
+	const callers = 8
+	results := make([]resolvers.Result, callers)
+	errs := make([]error, callers)
+	var wg sync.WaitGroup
+	for i := range callers {
+		wg.Go(func() {
+			req, b := newAQuery(t, uint16(100+i), "coalesce.example")
+			results[i], errs[i] = f.Resolve(context.Background(), req, b)
+		})
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), queries.Load())
+	sources := map[string]int{}
+	for i := range callers {
+		require.NoError(t, errs[i])
+		sources[results[i].Source]++
+		resp, err := dns.ParsePacket(results[i].ResponseBytes)
+		require.NoError(t, err)
+		assert.Equal(t, uint16(100+i), resp.Header.ID)
+	}
+	assert.Equal(t, 1, sources["upstream"])
+	assert.Equal(t, callers-1, sources["upstream-inflight"])
+}
+
+func TestForwardingResolver_DropsMismatchedResponses(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP(fakeUpstreamAddr), Port: 53})
+	if err != nil {
+		t.Skipf("cannot bind fake upstream: %v", err)
+	}
+	var wg sync.WaitGroup
+	t.Cleanup(func() {
+		_ = conn.Close()
+		wg.Wait()
+	})
+	answer := func(req dns.Packet, id uint16, ip net.IP) []byte {
+		resp := req
+		resp.Header.ID = id
+		resp.Header.Flags |= dns.QRFlag
+		resp.Answers = []dns.Record{dns.NewIPRecord(
+			dns.RRHeader{Name: req.Questions[0].Name, Class: uint16(dns.ClassIN), TTL: 60}, ip,
+		)}
+		b, err := resp.Marshal()
+		require.NoError(t, err)
+		return b
+	}
+	wg.Go(func() {
+		buf := make([]byte, 4096)
+		n, addr, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		req, err := dns.ParsePacket(buf[:n])
+		if err != nil {
+			return
+		}
+		// A spoofed answer guessing the ID wrong and a late answer to
+		// another question arrive first
+		_, _ = conn.WriteToUDP(answer(req, req.Header.ID+1, net.IPv4(203, 0, 113, 66)), addr)
+		other := req
+		other.Questions = []dns.Question{{Name: "other.example", Type: uint16(dns.TypeA), Class: uint16(dns.ClassIN)}}
+		_, _ = conn.WriteToUDP(answer(other, req.Header.ID, net.IPv4(203, 0, 113, 67)), addr)
+		_, _ = conn.WriteToUDP(answer(req, req.Header.ID, net.IPv4(192, 0, 2, 10)), addr)
+	})
+
+	f := resolvers.NewForwardingResolver([]string{fakeUpstreamAddr}, 1, 0, false, time.Second, 0, 1)
+	t.Cleanup(func() { _ = f.Close() })
+
+	req, b := newAQuery(t, 7, "spoof.example")
+	res, err := f.Resolve(context.Background(), req, b)
+	require.NoError(t, err)
+	resp, err := dns.ParsePacket(res.ResponseBytes)
+	require.NoError(t, err)
+	assert.Equal(t, uint16(7), resp.Header.ID)
+	require.Len(t, resp.Answers, 1)
+	ip, ok := resp.Answers[0].(*dns.IPRecord)
+	require.True(t, ok)
+	assert.Equal(t, "192.0.2.10", ip.Addr.String())
+
+	status := f.UpstreamStatus()
+	require.Len(t, status, 1)
+	assert.Equal(t, uint64(2), status[0].MismatchedResponses)
+}
+
+// recordingAuditor collects audited upstream queries.
+type recordingAuditor struct {
+	mu      sync.Mutex
+	entries []string
+}
+
+func (a *recordingAuditor) Record(domain, qtype, upstream, transport string) {
+	a.mu.Lock()
+	a.entries = append(a.entries, strings.Join([]string{domain, qtype, upstream, transport}, " "))
+	a.mu.Unlock()
+}
+
+func TestForwardingResolver_AuditsUpstreamQueries(t *testing.T) {
+	startFakeUpstream(t, 0)
+	f := resolvers.NewForwardingResolver([]string{fakeUpstreamAddr}, 1, 0, false, time.Second, 0, 1)
+	t.Cleanup(func() { _ = f.Close() })
+	auditor := &recordingAuditor{}
+	f.SetAuditor(auditor)
+
+	for range 2 {
+		req, b := newAQuery(t, 30, "audit.example")
+		_, err := f.Resolve(context.Background(), req, b)
+		require.NoError(t, err)
+	}
+
+	// The second query is a cache hit and never leaves the server
+	assert.Equal(t, []string{"audit.example A " + fakeUpstreamAddr + " udp"}, auditor.entries)
+}
+
+func TestForwardingResolver_CapturesUpstreamMessages(t *testing.T) {
+	startFakeUpstream(t, 0)
+	f := resolvers.NewForwardingResolver([]string{fakeUpstreamAddr}, 1, 0, false, time.Second, 0, 1)
+	t.Cleanup(func() { _ = f.Close() })
+	rec := capture.NewRecorder()
+	f.SetPacketCapture(rec)
+	require.NoError(t, rec.Start(capture.Filter{Name: "capture.example"}, 10))
+
+	client := netip.MustParseAddr("192.0.2.10")
+	req, b := newAQuery(t, 31, "capture.example")
+	_, err := f.Resolve(resolvers.WithClient(context.Background(), client), req, b)
+	require.NoError(t, err)
+	req, b = newAQuery(t, 32, "other.example")
+	_, err = f.Resolve(context.Background(), req, b)
+	require.NoError(t, err)
+
+	packets := rec.Packets()
+	require.Len(t, packets, 2, "query and response, other names filtered out")
+	query, resp := packets[0], packets[1]
+	assert.Equal(t, capture.SideUpstream, query.Side)
+	assert.Equal(t, "udp", query.Transport)
+	assert.Equal(t, fakeUpstreamAddr, query.Dst.Addr().String())
+	assert.Equal(t, uint16(53), query.Dst.Port())
+	assert.Equal(t, query.Dst, resp.Src)
+	assert.Equal(t, query.Src, resp.Dst)
+	parsed, err := dns.ParsePacket(resp.Data)
+	require.NoError(t, err)
+	assert.NotZero(t, parsed.Header.Flags&dns.QRFlag)
+}
+
+func TestForwardingResolver_CacheBypass(t *testing.T) {
+	queries := startFakeUpstream(t, 0)
+	f := resolvers.NewForwardingResolver([]string{fakeUpstreamAddr}, 1, 0, false, time.Second, 0, 1)
+	t.Cleanup(func() { _ = f.Close() })
+
+	resolve := func(name string) string {
+		req, b := newAQuery(t, 40, name)
+		res, err := f.Resolve(context.Background(), req, b)
+		require.NoError(t, err)
+		return res.Source
+	}
+
+	// Cached before the bypass is configured
+	assert.Equal(t, "upstream", resolve("host.dyn.example"))
+	assert.Equal(t, "upstream-cache", resolve("host.dyn.example"))
+
+	f.SetCacheBypass([]string{"DYN.example."})
+	for range 2 {
+		assert.Equal(t, "upstream", resolve("host.dyn.example"), "bypassed names are never served from cache")
+		assert.Equal(t, "upstream", resolve("dyn.example"))
+	}
+	assert.Equal(t, int32(5), queries.Load())
+
+	// Other names are still cached
+	assert.Equal(t, "upstream", resolve("static.example"))
+	assert.Equal(t, "upstream-cache", resolve("static.example"))
+	assert.Equal(t, 1, f.CacheStats().Entries)
+}
+
+func TestForwardingResolver_WithoutCache(t *testing.T) {
+	queries := startFakeUpstream(t, 0)
+	f := resolvers.NewForwardingResolver([]string{fakeUpstreamAddr}, 1, 0, false, time.Second, 0, 1)
+	t.Cleanup(func() { _ = f.Close() })
+
+	req, b := newAQuery(t, 41, "canary.example")
+	res, err := f.Resolve(context.Background(), req, b)
+	require.NoError(t, err)
+	assert.Equal(t, "upstream", res.Source)
+
+	// A cached name is still fetched upstream when the context says so
+	res, err = f.Resolve(resolvers.WithoutCache(context.Background()), req, b)
+	require.NoError(t, err)
+	assert.Equal(t, "upstream", res.Source)
+	assert.Equal(t, int32(2), queries.Load())
+
+	res, err = f.Resolve(context.Background(), req, b)
+	require.NoError(t, err)
+	assert.Equal(t, "upstream-cache", res.Source)
+}
+
+func TestForwardingResolver_ReportsUpstreamTime(t *testing.T) {
+	startFakeUpstream(t, 50*time.Millisecond)
+	f := resolvers.NewForwardingResolver([]string{fakeUpstreamAddr}, 1, 0, false, time.Second, 0, 1)
+	t.Cleanup(func() { _ = f.Close() })
+
+	req, b := newAQuery(t, 42, "slow.example")
+	res, err := f.Resolve(context.Background(), req, b)
+	require.NoError(t, err)
+	assert.Equal(t, fakeUpstreamAddr, res.Upstream)
+	assert.GreaterOrEqual(t, res.UpstreamTime, 50*time.Millisecond)
+
+	res, err = f.Resolve(context.Background(), req, b)
+	require.NoError(t, err)
+	assert.Equal(t, "upstream-cache", res.Source)
+	assert.Empty(t, res.Upstream)
+	assert.Zero(t, res.UpstreamTime)
+}
+
+// memSharedCache is an in-memory resolvers.SharedCache.
+type memSharedCache struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+}
+
+func (m *memSharedCache) Get(_ context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return bytes.Clone(m.entries[key]), nil
+}
+
+func (m *memSharedCache) Set(_ context.Context, key string, value []byte, _ time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[key] = bytes.Clone(value)
+	return nil
+}
+
+func (m *memSharedCache) Delete(_ context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, k := range keys {
+		delete(m.entries, k)
+	}
+	return nil
+}
+
+func (m *memSharedCache) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.entries)
+}
+
+func (m *memSharedCache) Backend() string { return "memory" }
+func (m *memSharedCache) Close() error    { return nil }
+
+func TestForwardingResolver_SharedCache(t *testing.T) {
+	queries := startFakeUpstream(t, 0)
+	shared := &memSharedCache{entries: map[string][]byte{}}
+	newNode := func() *resolvers.ForwardingResolver {
+		f := resolvers.NewForwardingResolver([]string{fakeUpstreamAddr}, 1, 0, false, time.Second, 0, 1)
+		f.SetSharedCache(shared)
+		t.Cleanup(func() { _ = f.Close() })
+		return f
+	}
+	resolve := func(f *resolvers.ForwardingResolver, id uint16) resolvers.Result {
+		req, b := newAQuery(t, id, "shared.example")
+		res, err := f.Resolve(context.Background(), req, b)
+		require.NoError(t, err)
+		return res
+	}
+
+	nodeA, nodeB := newNode(), newNode()
+	assert.Equal(t, "upstream", resolve(nodeA, 50).Source)
+	require.Eventually(t, func() bool { return shared.Len() == 1 }, time.Second, 5*time.Millisecond)
+
+	// The other node answers from the shared entry without going upstream,
+	// then from its own cache
+	res := resolve(nodeB, 51)
+	assert.Equal(t, "upstream-shared-cache", res.Source)
+	resp, err := dns.ParsePacket(res.ResponseBytes)
+	require.NoError(t, err)
+	assert.Equal(t, uint16(51), resp.Header.ID)
+	require.Len(t, resp.Answers, 1)
+	assert.LessOrEqual(t, resp.Answers[0].Header().TTL, uint32(60))
+	assert.Equal(t, "upstream-cache", resolve(nodeB, 52).Source)
+	assert.Equal(t, int32(1), queries.Load())
+
+	stats, ok := nodeB.SharedCacheStats()
+	require.True(t, ok)
+	assert.Equal(t, resolvers.SharedCacheStats{Backend: "memory", Hits: 1}, stats)
+	stats, _ = nodeA.SharedCacheStats()
+	assert.Equal(t, uint64(1), stats.Misses)
+	assert.Equal(t, uint64(1), stats.Stores)
+}
+
+func TestForwardingResolver_PurgeNameDeletesSharedEntries(t *testing.T) {
+	queries := startFakeUpstream(t, 0)
+	shared := &memSharedCache{entries: map[string][]byte{}}
+	f := resolvers.NewForwardingResolver([]string{fakeUpstreamAddr}, 1, 0, false, time.Second, 0, 1)
+	f.SetSharedCache(shared)
+	t.Cleanup(func() { _ = f.Close() })
+
+	req, b := newAQuery(t, 70, "purged.example")
+	_, err := f.Resolve(context.Background(), req, b)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return shared.Len() == 1 }, time.Second, 5*time.Millisecond)
+
+	assert.Equal(t, 1, f.PurgeName("Purged.Example."))
+	assert.Zero(t, shared.Len(), "the shared entry must not refill the purged one")
+
+	res, err := f.Resolve(context.Background(), req, b)
+	require.NoError(t, err)
+	assert.Equal(t, "upstream", res.Source)
+	assert.Equal(t, int32(2), queries.Load())
+}
+
+func TestForwardingResolver_SharedCacheRejectsMismatchedEntry(t *testing.T) {
+	queries := startFakeUpstream(t, 0)
+	shared := &memSharedCache{entries: map[string][]byte{}}
+	f := resolvers.NewForwardingResolver([]string{fakeUpstreamAddr}, 1, 0, false, time.Second, 0, 1)
+	f.SetSharedCache(shared)
+	t.Cleanup(func() { _ = f.Close() })
+
+	req, b := newAQuery(t, 60, "victim.example")
+	_, err := f.Resolve(context.Background(), req, b)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return shared.Len() == 1 }, time.Second, 5*time.Millisecond)
+
+	// Whoever can write to the cache server must not answer for other names
+	shared.mu.Lock()
+	for key, v := range shared.entries {
+		shared.entries[strings.Replace(key, "victim.example", "other.example", 1)] = v
+	}
+	shared.mu.Unlock()
+
+	req, b = newAQuery(t, 61, "other.example")
+	res, err := f.Resolve(context.Background(), req, b)
+	require.NoError(t, err)
+	assert.Equal(t, "upstream", res.Source)
+	assert.Equal(t, int32(2), queries.Load())
+}
+
+func TestCacheBypass_Matches(t *testing.T) {
+	b := resolvers.NewCacheBypass([]string{"dyn.example", " Health.Corp.Example. "})
+
+	assert.True(t, b.Matches("dyn.example"))
+	assert.True(t, b.Matches("a.b.DYN.example."))
+	assert.True(t, b.Matches("health.corp.example"))
+	assert.False(t, b.Matches("corp.example"))
+	assert.False(t, b.Matches("notdyn.example"))
+
+	var none *resolvers.CacheBypass
+	assert.False(t, none.Matches("dyn.example"))
+	assert.Nil(t, resolvers.NewCacheBypass(nil))
+}
+
+func TestForwardingResolver_ScrubsOutOfBailiwickRecords(t *testing.T) {
+	in := func(name string, ttl uint32) dns.RRHeader { return dns.NewRRHeader(name, dns.ClassIN, ttl) }
+	startFakeUpstreamPacket(t, 0, func(req dns.Packet) dns.Packet {
+		resp := req
+		resp.Answers = []dns.Record{
+			dns.NewCNAMERecord(in("www.example.com", 60), "web.example.net"),
+			dns.NewIPRecord(in("web.example.net", 60), net.IPv4(192, 0, 2, 1)),
+			dns.NewIPRecord(in("bank.example.org", 60), net.IPv4(203, 0, 113, 66)), // injected
+		}
+		resp.Authorities = []dns.Record{
+			dns.NewNSRecord(in("example.net", 60), "ns1.example.net"),
+			dns.NewNSRecord(in("example.org", 60), "ns.attacker.test"),        // injected
+			dns.NewIPRecord(in("example.net", 60), net.IPv4(203, 0, 113, 67)), // wrong type
+		}
+		resp.Additionals = append([]dns.Record{
+			dns.NewIPRecord(in("ns1.example.net", 60), net.IPv4(192, 0, 2, 53)),
+			dns.NewIPRecord(in("ns.attacker.test", 60), net.IPv4(203, 0, 113, 68)), // injected
+		}, req.Additionals...)
+		return resp
+	})
+	f := resolvers.NewForwardingResolver([]string{fakeUpstreamAddr}, 1, 0, false, time.Second, 0, 1)
+	t.Cleanup(func() { _ = f.Close() })
+
+	for _, wantSource := range []string{"upstream", "upstream-cache"} {
+		req, b := newAQuery(t, 50, "www.example.com")
+		res, err := f.Resolve(context.Background(), req, b)
+		require.NoError(t, err)
+		require.Equal(t, wantSource, res.Source)
+
+		resp, err := dns.ParsePacket(res.ResponseBytes)
+		require.NoError(t, err)
+		assert.Equal(t, uint16(50), resp.Header.ID)
+
+		require.Len(t, resp.Answers, 2)
+		assert.Equal(t, "web.example.net", resp.Answers[0].(*dns.NameRecord).Target)
+		assert.Equal(t, "web.example.net", resp.Answers[1].Header().Name)
+
+		require.Len(t, resp.Authorities, 1)
+		assert.Equal(t, "ns1.example.net", resp.Authorities[0].(*dns.NameRecord).Target)
+
+		var additional []string
+		for _, rr := range resp.Additionals {
+			additional = append(additional, rr.Header().Name+" "+rr.Type().String())
+		}
+		assert.Equal(t, []string{"ns1.example.net A", " OPT"}, additional)
+	}
+}
+
+func TestForwardingResolver_KeepsInBailiwickResponse(t *testing.T) {
+	mx := append([]byte{0, 10}, mustEncodeName(t, "mail.example.com")...)
+	soa := append(append(mustEncodeName(t, "ns1.example.com"), mustEncodeName(t, "hostmaster.example.com")...), make([]byte, 20)...)
+	startFakeUpstreamPacket(t, 0, func(req dns.Packet) dns.Packet {
+		resp := req
+		resp.Answers = []dns.Record{
+			dns.NewOpaqueRecord(dns.NewRRHeader("example.com", dns.ClassIN, 60), dns.TypeMX, mx),
+		}
+		resp.Authorities = []dns.Record{
+			dns.NewOpaqueRecord(dns.NewRRHeader("example.com", dns.ClassIN, 60), dns.TypeSOA, soa),
+		}
+		resp.Additionals = append([]dns.Record{
+			dns.NewIPRecord(dns.NewRRHeader("mail.example.com", dns.ClassIN, 60), net.IPv4(192, 0, 2, 25)),
+		}, req.Additionals...)
+		return resp
+	})
+	f := resolvers.NewForwardingResolver([]string{fakeUpstreamAddr}, 1, 0, false, time.Second, 0, 1)
+	t.Cleanup(func() { _ = f.Close() })
+
+	req := dns.Packet{
+		Header:    dns.Header{ID: 51, Flags: dns.RDFlag},
+		Questions: []dns.Question{{Name: "example.com", Type: uint16(dns.TypeMX), Class: uint16(dns.ClassIN)}},
+	}
+	b, err := req.Marshal()
+	require.NoError(t, err)
+	res, err := f.Resolve(context.Background(), req, b)
+	require.NoError(t, err)
+
+	resp, err := dns.ParsePacket(res.ResponseBytes)
+	require.NoError(t, err)
+	assert.Len(t, resp.Answers, 1)
+	assert.Len(t, resp.Authorities, 1)
+	assert.Len(t, resp.Additionals, 2, "glue for the MX target and OPT are kept")
+}
+
+func TestForwardingResolver_ScrubExpandsCompressedRData(t *testing.T) {
+	// RDATA names pointing at the question name (offset 12), as upstreams
+	// compress MX and SOA targets
+	ptr := []byte{0xC0, 12}
+	mx := append([]byte{0, 10, 4, 'm', 'a', 'i', 'l'}, ptr...)
+	soa := append(append(append([]byte{3, 'n', 's', '1'}, ptr...), ptr...), make([]byte, 20)...)
+	startFakeUpstreamPacket(t, 0, func(req dns.Packet) dns.Packet {
+		resp := req
+		resp.Answers = []dns.Record{
+			dns.NewOpaqueRecord(dns.NewRRHeader("example.com", dns.ClassIN, 60), dns.TypeMX, mx),
+		}
+		resp.Authorities = []dns.Record{
+			dns.NewOpaqueRecord(dns.NewRRHeader("example.com", dns.ClassIN, 60), dns.TypeSOA, soa),
+		}
+		resp.Additionals = append([]dns.Record{
+			dns.NewOpaqueRecord(dns.NewRRHeader("junk.test", dns.ClassIN, 60), dns.TypeTXT, []byte{2, 'h', 'i'}),
+		}, req.Additionals...)
+		return resp
+	})
+	f := resolvers.NewForwardingResolver([]string{fakeUpstreamAddr}, 1, 0, false, time.Second, 0, 1)
+	t.Cleanup(func() { _ = f.Close() })
+
+	req := dns.Packet{
+		Header:    dns.Header{ID: 52, Flags: dns.RDFlag},
+		Questions: []dns.Question{{Name: "example.com", Type: uint16(dns.TypeMX), Class: uint16(dns.ClassIN)}},
+	}
+	b, err := req.Marshal()
+	require.NoError(t, err)
+	res, err := f.Resolve(context.Background(), req, b)
+	require.NoError(t, err)
+
+	resp, err := dns.ParsePacket(res.ResponseBytes)
+	require.NoError(t, err)
+	require.Len(t, resp.Answers, 1)
+	require.Len(t, resp.Authorities, 1)
+	require.Len(t, resp.Additionals, 1, "the TXT record is dropped, OPT is kept")
+
+	mxData, err := resp.Answers[0].MarshalRData()
+	require.NoError(t, err)
+	assert.Equal(t, append([]byte{0, 10}, mustEncodeName(t, "mail.example.com")...), mxData)
+
+	soaData, err := resp.Authorities[0].MarshalRData()
+	require.NoError(t, err)
+	wantSOA := append(append(mustEncodeName(t, "ns1.example.com"), mustEncodeName(t, "example.com")...), make([]byte, 20)...)
+	assert.Equal(t, wantSOA, soaData)
+}
+
+func mustEncodeName(t *testing.T, name string) []byte {
+	t.Helper()
+	b, err := dns.EncodeName(name)
+	require.NoError(t, err)
+	return b
+}
+
+func TestForwardingResolver_InflightWaiterCap(t *testing.T) {
+	queries := startFakeUpstream(t, 300*time.Millisecond)
+	f := resolvers.NewForwardingResolver([]string{fakeUpstreamAddr}, 1, 0, false, time.Second, 0, 1)
+	t.Cleanup(func() { _ = f.Close() })
+	f.SetInflightLimits(1, 0)
+
+	leaderDone := make(chan error, 1)
+	go func() {
+		req, b := newAQuery(t, 1, "capped.example")
+		_, err := f.Resolve(context.Background(), req, b)
+		leaderDone <- err
+	}()
+	require.Eventually(t, func() bool { return queries.Load() == 1 }, time.Second, 5*time.Millisecond)
+
+	req, b := newAQuery(t, 2, "capped.example")
+	_, err := f.Resolve(context.Background(), req, b)
+	require.ErrorIs(t, err, resolvers.ErrTooManyInflightWaiters)
+
+	require.NoError(t, <-leaderDone)
+}
+
+func TestForwardingResolver_LeaderCancelDoesNotFailWaiters(t *testing.T) {
+	queries := startFakeUpstream(t, 200*time.Millisecond)
+	f := resolvers.NewForwardingResolver([]string{fakeUpstreamAddr}, 1, 0, false, time.Second, 0, 1)
+	t.Cleanup(func() { _ = f.Close() })
+
+	leaderCtx, cancel := context.WithCancel(context.Background())
+	leaderDone := make(chan error, 1)
+	go func() {
+		req, b := newAQuery(t, 1, "detached.example")
+		_, err := f.Resolve(leaderCtx, req, b)
+		leaderDone <- err
+	}()
+	require.Eventually(t, func() bool { return queries.Load() == 1 }, time.Second, 5*time.Millisecond)
+
+	waiterDone := make(chan error, 1)
+	var waiterRes resolvers.Result
+	go func() {
+		req, b := newAQuery(t, 2, "detached.example")
+		var err error
+		waiterRes, err = f.Resolve(context.Background(), req, b)
+		waiterDone <- err
+	}()
+
+	cancel()
+	assert.ErrorIs(t, <-leaderDone, context.Canceled)
+	require.NoError(t, <-waiterDone)
+	assert.Equal(t, "upstream-inflight", waiterRes.Source)
+	assert.Equal(t, int32(1), queries.Load())
+}
+
+func TestForwardingResolver_CacheIsCaseInsensitive(t *testing.T) {
+	queries := startFakeUpstream(t, 0)
+	f := resolvers.NewForwardingResolver([]string{fakeUpstreamAddr}, 1, 0, false, time.Second, 0, 1)
+	t.Cleanup(func() { _ = f.Close() })
+
+	first, firstBytes := newAQuery(t, 1, "Mixed.Example")
+	res, err := f.Resolve(context.Background(), first, firstBytes)
+	require.NoError(t, err)
+	assert.Equal(t, "upstream", res.Source)
+
+	second, secondBytes := newAQuery(t, 2, "mIXED.eXAMPLE")
+	res, err = f.Resolve(context.Background(), second, secondBytes)
+	require.NoError(t, err)
+	assert.Equal(t, "upstream-cache", res.Source)
+	assert.Equal(t, int32(1), queries.Load())
+
+	// The cached answer carries the first client's case until restored.
+	restored := resolvers.RestoreQuestionCase(res.ResponseBytes, secondBytes)
+	assert.Equal(t, secondBytes[12:12+len("mIXED.eXAMPLE")+2], restored[12:12+len("mIXED.eXAMPLE")+2])
+}
+
+func TestForwardingResolver_EDNSPolicy(t *testing.T) {
+	// The fake upstream echoes the query's OPT record, so the response shows
+	// what was sent upstream (minus options stripped from the response).
+	startFakeUpstream(t, 0)
+	f := resolvers.NewForwardingResolver([]string{fakeUpstreamAddr}, 1, 0, false, time.Second, 0, 1)
+	t.Cleanup(func() { _ = f.Close() })
+
+	ecs := dns.ECSOption(netip.MustParsePrefix("198.51.100.0/24"))
+	f.SetEDNSPolicy(resolvers.EDNSPolicy{
+		dns.EDNSOptionCookie: {Action: resolvers.EDNSStrip},
+		dns.EDNSOptionECS:    {Action: resolvers.EDNSRewrite, Data: ecs.Data},
+		65001:                {Action: resolvers.EDNSRewrite, Data: []byte{0xbe, 0xef}},
+	})
+
+	req, b := newEDNSQuery(t, "edns.example", []dns.EDNSOption{
+		{Code: dns.EDNSOptionCookie, Data: []byte("clientck")},
+		dns.ECSOption(netip.MustParsePrefix("192.0.2.0/24")),
+		{Code: dns.EDNSOptionPadding, Data: make([]byte, 4)},
+	})
+	res, err := f.Resolve(context.Background(), req, b)
+	require.NoError(t, err)
+
+	assert.Equal(t, []dns.EDNSOption{
+		ecs,
+		{Code: dns.EDNSOptionPadding, Data: make([]byte, 4)},
+		{Code: 65001, Data: []byte{0xbe, 0xef}},
+	}, ednsOptions(res.ResponseBytes))
+}
+
+// newEDNSQuery builds an A query whose OPT record carries opts.
+func newEDNSQuery(t *testing.T, name string, opts []dns.EDNSOption) (dns.Packet, []byte) {
+	t.Helper()
+	req := dns.Packet{
+		Header:    dns.Header{ID: 1, Flags: dns.RDFlag},
+		Questions: []dns.Question{{Name: name, Type: uint16(dns.TypeA), Class: uint16(dns.ClassIN)}},
+		Additionals: []dns.Record{
+			dns.NewOpaqueRecord(dns.RRHeader{Class: dns.EDNSDefaultUDPPayloadSize}, dns.TypeOPT, dns.MarshalEDNSOptions(opts)),
+		},
+	}
+	b, err := req.Marshal()
+	require.NoError(t, err)
+	parsed, err := dns.ParsePacket(b)
+	require.NoError(t, err)
+	return parsed, b
+}
+
+// ednsOptions returns all EDNS options of the OPT record in msg.
+func ednsOptions(msg []byte) []dns.EDNSOption {
+	var out []dns.EDNSOption
+	dns.RewriteEDNSOptions(msg, func(opts []dns.EDNSOption) []dns.EDNSOption {
+		out = opts
+		return opts
+	})
+	return out
+}
+
+// ============================================================================
+// NegativeCacheRules Tests
+// ============================================================================
+
+func TestNegativeCacheRules_PolicyFor_MostSpecificZoneWins(t *testing.T) {
+	def := resolvers.DefaultNegativeCachePolicy()
+	corp := resolvers.NegativeCachePolicy{Enabled: true, ServfailTTL: 0, NegativeTTL: time.Minute}
+	lab := resolvers.NegativeCachePolicy{Enabled: false}
+	rules := resolvers.NegativeCacheRules{
+		Default: def,
+		Zones: map[string]resolvers.NegativeCachePolicy{
+			"corp.example":     corp,
+			"lab.corp.example": lab,
+		},
+	}
+
+	assert.Equal(t, def, rules.PolicyFor("www.example.com"))
+	assert.Equal(t, corp, rules.PolicyFor("corp.example"))
+	assert.Equal(t, corp, rules.PolicyFor("Host.Corp.Example."))
+	assert.Equal(t, lab, rules.PolicyFor("db.lab.corp.example"))
+	assert.Equal(t, def, rules.PolicyFor("notcorp.example"), "suffix match must be label aligned")
+}
+
+func TestNegativeCacheRules_DefaultPolicy(t *testing.T) {
+	p := resolvers.DefaultNegativeCachePolicy()
+	assert.True(t, p.Enabled)
+	assert.Equal(t, 30*time.Second, p.ServfailTTL)
+	assert.Equal(t, 5*time.Minute, p.NegativeTTL)
+}
+
+func TestForwardingResolver_RejectsUpstreamCNAMELoop(t *testing.T) {
+	queries := startFakeUpstreamFunc(t, 0, func(q dns.Question) []dns.Record {
+		h := func(name string) dns.RRHeader {
+			return dns.RRHeader{Name: name, Class: uint16(dns.ClassIN), TTL: 60}
+		}
+		return []dns.Record{
+			dns.NewCNAMERecord(h(q.Name), "b.example"),
+			dns.NewCNAMERecord(h("b.example"), q.Name),
+		}
+	})
+	f := resolvers.NewForwardingResolver([]string{fakeUpstreamAddr}, 1, 0, false, time.Second, 0, 1)
+	t.Cleanup(func() { _ = f.Close() })
+
+	req, b := newAQuery(t, 15, "a.example")
+	_, err := f.Resolve(context.Background(), req, b)
+	require.ErrorIs(t, err, resolvers.ErrCNAMEChain)
+
+	// The broken answer is not cached.
+	_, err = f.Resolve(context.Background(), req, b)
+	require.ErrorIs(t, err, resolvers.ErrCNAMEChain)
+	assert.Equal(t, int32(2), queries.Load())
+}
+
+// ============================================================================
+// FilteringResolver Tests
+// ============================================================================
+
+func newBlockingResolver(t *testing.T) *resolvers.FilteringResolver {
+	t.Helper()
+	pe := filtering.NewPolicyEngine(filtering.PolicyEngineConfig{
+		Enabled:          true,
+		BlockAction:      filtering.ActionBlock,
+		BlacklistDomains: []string{"ads.example.com"},
+	})
+	next := &mockResolver{
+		resolveFunc: func(context.Context, dns.Packet, []byte) (resolvers.Result, error) {
+			return resolvers.Result{Source: "next"}, nil
+		},
+	}
+	f := resolvers.NewFilteringResolver(pe, next)
+	t.Cleanup(func() { _ = f.Close() })
+	return f
+}
+
+func resolveBlocked(t *testing.T, f *resolvers.FilteringResolver, qtype dns.RecordType) dns.Packet {
+	t.Helper()
+	req, b := newAQuery(t, 9, "ads.example.com")
+	req.Questions[0].Type = uint16(qtype)
+	res, err := f.Resolve(context.Background(), req, b)
+	require.NoError(t, err)
+	assert.Equal(t, "filtered-blocked", res.Source)
+	resp, err := dns.ParsePacket(res.ResponseBytes)
+	require.NoError(t, err)
+	return resp
+}
+
+func TestFilteringResolver_BlockedError(t *testing.T) {
+	f := newBlockingResolver(t)
+
+	req, b := newAQuery(t, 9, "ads.example.com")
+	_, err := f.Resolve(context.Background(), req, b)
+	require.ErrorIs(t, err, resolvers.ErrBlocked)
+	var blocked *resolvers.BlockedError
+	require.ErrorAs(t, err, &blocked)
+	assert.Equal(t, "filtered-blocked", blocked.Source)
+	assert.Equal(t, "listed in blacklist", blocked.Reason)
+}
+
+func TestFilteringResolver_BlockPageAddress(t *testing.T) {
+	f := newBlockingResolver(t)
+	f.SetBlockPageAddrs(netip.MustParseAddr("192.0.2.10"), netip.Addr{})
+
+	resp := resolveBlocked(t, f, dns.TypeA)
+	assert.Equal(t, dns.RCodeNoError, dns.RCodeFromFlags(resp.Header.Flags))
+	require.Len(t, resp.Answers, 1)
+	ip, ok := resp.Answers[0].(*dns.IPRecord)
+	require.True(t, ok)
+	assert.Equal(t, "192.0.2.10", ip.Addr.String())
+	assert.Equal(t, uint32(resolvers.BlockPageTTL), ip.H.TTL)
+
+	// No IPv6 block page address: AAAA gets an empty NOERROR answer
+	resp = resolveBlocked(t, f, dns.TypeAAAA)
+	assert.Equal(t, dns.RCodeNoError, dns.RCodeFromFlags(resp.Header.Flags))
+	assert.Empty(t, resp.Answers)
+}
+
+func TestFilteringResolver_BlockTTLs(t *testing.T) {
+	f := newBlockingResolver(t)
+	f.Policy().SetBlockTTLs(60, 30)
+
+	req, b := newAQuery(t, 9, "ads.example.com")
+	_, err := f.Resolve(context.Background(), req, b)
+	var blocked *resolvers.BlockedError
+	require.ErrorAs(t, err, &blocked)
+	assert.Equal(t, uint32(30), blocked.NegativeTTL)
+
+	f.SetBlockPageAddrs(netip.MustParseAddr("192.0.2.10"), netip.Addr{})
+	resp := resolveBlocked(t, f, dns.TypeA)
+	require.Len(t, resp.Answers, 1)
+	assert.Equal(t, uint32(60), resp.Answers[0].Header().TTL)
+
+	// The empty AAAA answer carries a SOA for negative caching
+	resp = resolveBlocked(t, f, dns.TypeAAAA)
+	require.Len(t, resp.Authorities, 1)
+	assert.Equal(t, dns.TypeSOA, resp.Authorities[0].Type())
+	assert.Equal(t, uint32(30), resp.Authorities[0].Header().TTL)
+}
+
+func TestFilteringResolver_AllowedPassesThrough(t *testing.T) {
+	f := newBlockingResolver(t)
+
+	req, b := newAQuery(t, 10, "www.example.com")
+	res, err := f.Resolve(context.Background(), req, b)
+	require.NoError(t, err)
+	assert.Equal(t, "next", res.Source)
+}
+
+// ============================================================================
+// GeoIPResolver Tests
+// ============================================================================
+
+// newGeoIPResolver returns a GeoIP resolver blocking AS64500 and country DE
+// in front of a resolver answering with addr.
+func newGeoIPResolver(t *testing.T, addr string) *resolvers.GeoIPResolver {
+	t.Helper()
+	dir := t.TempDir()
+	countryPath := filepath.Join(dir, "country.mmdb")
+	asnPath := filepath.Join(dir, "asn.mmdb")
+	require.NoError(t, geoiptest.Write(countryPath, "GeoLite2-Country", map[netip.Prefix]map[string]any{
+		netip.MustParsePrefix("192.0.2.0/24"):    geoiptest.Country("NL"),
+		netip.MustParsePrefix("198.51.100.0/24"): geoiptest.Country("DE"),
+	}))
+	require.NoError(t, geoiptest.Write(asnPath, "GeoLite2-ASN", map[netip.Prefix]map[string]any{
+		netip.MustParsePrefix("192.0.2.0/25"): geoiptest.ASN(64500, "Blocked Net"),
+	}))
+	db, err := geoip.Open(countryPath, asnPath)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	next := &mockResolver{
+		resolveFunc: func(_ context.Context, req dns.Packet, _ []byte) (resolvers.Result, error) {
+			q := req.Questions[0]
+			resp := dns.Packet{
+				Header:    dns.Header{ID: req.Header.ID, Flags: dns.QRFlag | dns.RDFlag | dns.RAFlag},
+				Questions: req.Questions,
+				Answers: []dns.Record{
+					dns.NewIPRecord(dns.NewRRHeader(q.Name, dns.ClassIN, 60), net.ParseIP(addr)),
+				},
+			}
+			b, err := resp.Marshal()
+			return resolvers.Result{ResponseBytes: b, Source: "upstream"}, err
+		},
+	}
+	return resolvers.NewGeoIPResolver(db, next, []uint32{64500}, []string{"DE"})
+}
+
+func TestGeoIPResolver_BlocksASN(t *testing.T) {
+	g := newGeoIPResolver(t, "192.0.2.10")
+
+	req, b := newEDNSQuery(t, "www.example.com", nil)
+	_, err := g.Resolve(context.Background(), req, b)
+	var blocked *resolvers.BlockedError
+	require.ErrorAs(t, err, &blocked)
+	assert.Equal(t, "geoip-blocked", blocked.Source)
+	assert.Equal(t, "answer in blocked AS64500", blocked.Reason)
+}
+
+func TestGeoIPResolver_BlocksCountry(t *testing.T) {
+	g := newGeoIPResolver(t, "198.51.100.7")
+
+	req, b := newAQuery(t, 11, "www.example.com")
+	_, err := g.Resolve(context.Background(), req, b)
+	var blocked *resolvers.BlockedError
+	require.ErrorAs(t, err, &blocked)
+	assert.Equal(t, "geoip-blocked", blocked.Source)
+	assert.Equal(t, "answer in blocked country DE", blocked.Reason)
+}
+
+func TestGeoIPResolver_AllowsOthers(t *testing.T) {
+	for _, addr := range []string{"192.0.2.200", "203.0.113.1"} {
+		g := newGeoIPResolver(t, addr)
+
+		req, b := newAQuery(t, 12, "www.example.com")
+		res, err := g.Resolve(context.Background(), req, b)
+		require.NoError(t, err)
+		assert.Equal(t, "upstream", res.Source, addr)
+	}
+}
+
+// ============================================================================
+// ThreatIntelResolver Tests
+// ============================================================================
+
+func TestThreatIntelResolver_ObservesNoErrorAnswers(t *testing.T) {
+	for rcode, wantObserved := range map[dns.RCode]bool{
+		dns.RCodeNoError:  true,
+		dns.RCodeNXDomain: false,
+	} {
+		next := &mockResolver{
+			resolveFunc: func(_ context.Context, req dns.Packet, _ []byte) (resolvers.Result, error) {
+				resp := dns.Packet{
+					Header:    dns.Header{ID: req.Header.ID, Flags: dns.QRFlag | uint16(rcode)},
+					Questions: req.Questions,
+				}
+				b, err := resp.Marshal()
+				return resolvers.Result{ResponseBytes: b, Source: "upstream"}, err
+			},
+		}
+		var observed []string
+		r := resolvers.NewThreatIntelResolver(next, func(domain string) {
+			observed = append(observed, domain)
+		})
+
+		req, b := newAQuery(t, 13, "www.example.com")
+		res, err := r.Resolve(context.Background(), req, b)
+		require.NoError(t, err)
+		assert.Equal(t, "upstream", res.Source)
+		if wantObserved {
+			assert.Equal(t, []string{"www.example.com"}, observed, rcode)
+		} else {
+			assert.Empty(t, observed, rcode)
+		}
+	}
+}
+
+// ============================================================================
+// ChaosResolver Tests
+// ============================================================================
+
+func newChaosQuery(t *testing.T, name string, qtype dns.RecordType) (dns.Packet, []byte) {
+	t.Helper()
+	req := dns.Packet{
+		Header:    dns.Header{ID: 21, Flags: dns.RDFlag},
+		Questions: []dns.Question{{Name: name, Type: uint16(qtype), Class: uint16(dns.ClassCH)}},
+	}
+	b, err := req.Marshal()
+	require.NoError(t, err)
+	return req, b
+}
+
+func TestChaosResolver_AnswersIdentityQueries(t *testing.T) {
+	next := &countingResolver{}
+	r := resolvers.NewChaosResolver(resolvers.ChaosIdentity{
+		Version:  "HydraDNS 1.2.3",
+		Hostname: "dns-ams-1",
+	}, next)
+
+	tests := map[string]string{
+		"version.bind":   "HydraDNS 1.2.3",
+		"VERSION.SERVER": "HydraDNS 1.2.3",
+		"hostname.bind":  "dns-ams-1",
+		"id.server":      "dns-ams-1",
+	}
+	for name, want := range tests {
+		req, b := newChaosQuery(t, name, dns.TypeTXT)
+		res, err := r.Resolve(context.Background(), req, b)
+		require.NoError(t, err, name)
+		assert.Equal(t, "chaos", res.Source)
+
+		resp, err := dns.ParsePacket(res.ResponseBytes)
+		require.NoError(t, err, name)
+		assert.Equal(t, uint16(dns.RCodeNoError), resp.Header.Flags&0x0F, name)
+		require.Len(t, resp.Answers, 1, name)
+		assert.Equal(t, uint16(dns.ClassCH), resp.Answers[0].Header().Class, name)
+		txt, ok := resp.Answers[0].(*dns.OpaqueRecord)
+		require.True(t, ok, name)
+		assert.Equal(t, append([]byte{byte(len(want))}, want...), txt.Data, name)
+	}
+	assert.Zero(t, next.calls, "CHAOS queries must not reach the next resolver")
+}
+
+func TestChaosResolver_ServerIDOverridesHostname(t *testing.T) {
+	r := resolvers.NewChaosResolver(resolvers.ChaosIdentity{Hostname: "dns-ams-1", ServerID: "ams"}, &countingResolver{})
+
+	req, b := newChaosQuery(t, "id.server", dns.TypeTXT)
+	res, err := r.Resolve(context.Background(), req, b)
+	require.NoError(t, err)
+	resp, err := dns.ParsePacket(res.ResponseBytes)
+	require.NoError(t, err)
+	require.Len(t, resp.Answers, 1)
+	assert.Equal(t, []byte("\x03ams"), resp.Answers[0].(*dns.OpaqueRecord).Data)
+}
+
+func TestChaosResolver_RefusesUnconfiguredNames(t *testing.T) {
+	next := &countingResolver{}
+	r := resolvers.NewChaosResolver(resolvers.ChaosIdentity{Hostname: "dns-ams-1"}, next)
+
+	for _, name := range []string{"version.bind", "authors.bind", "example.com"} {
+		req, b := newChaosQuery(t, name, dns.TypeTXT)
+		res, err := r.Resolve(context.Background(), req, b)
+		require.NoError(t, err, name)
+		resp, err := dns.ParsePacket(res.ResponseBytes)
+		require.NoError(t, err, name)
+		assert.Equal(t, uint16(dns.RCodeRefused), resp.Header.Flags&0x0F, name)
+		assert.Empty(t, resp.Answers, name)
+	}
+	assert.Zero(t, next.calls)
+}
+
+func TestChaosResolver_OtherTypesGetNoData(t *testing.T) {
+	r := resolvers.NewChaosResolver(resolvers.ChaosIdentity{Hostname: "dns-ams-1"}, &countingResolver{})
+
+	req, b := newChaosQuery(t, "hostname.bind", dns.TypeA)
+	res, err := r.Resolve(context.Background(), req, b)
+	require.NoError(t, err)
+	resp, err := dns.ParsePacket(res.ResponseBytes)
+	require.NoError(t, err)
+	assert.Equal(t, uint16(dns.RCodeNoError), resp.Header.Flags&0x0F)
+	assert.Empty(t, resp.Answers)
+}
+
+func TestChaosResolver_PassesInternetClassQueries(t *testing.T) {
+	next := &countingResolver{}
+	r := resolvers.NewChaosResolver(resolvers.ChaosIdentity{Hostname: "dns-ams-1"}, next)
+
+	req, b := newAQuery(t, 22, "hostname.bind")
+	_, err := r.Resolve(context.Background(), req, b)
+	require.Error(t, err)
+	assert.Equal(t, 1, next.calls)
+}
+
+// ============================================================================
+// Captive Portal Resolver Tests
+// ============================================================================
+
+func newCaptivePortalResolver(next resolvers.Resolver) *resolvers.CaptivePortalResolver {
+	return resolvers.NewCaptivePortalResolver(resolvers.CaptivePortal{
+		Clients:      []netip.Prefix{netip.MustParsePrefix("10.20.0.0/16")},
+		IPv4:         netip.MustParseAddr("10.20.0.1"),
+		AllowDomains: []string{"portal.example.net"},
+		TTL:          10,
+	}, next)
+}
+
+func resolveFrom(t *testing.T, r resolvers.Resolver, client, name string, qtype dns.RecordType) (resolvers.Result, error) {
+	t.Helper()
+	req := dns.Packet{
+		Header:    dns.Header{ID: 7, Flags: dns.RDFlag},
+		Questions: []dns.Question{{Name: name, Type: uint16(qtype), Class: uint16(dns.ClassIN)}},
+	}
+	b, err := req.Marshal()
+	require.NoError(t, err)
+	ctx := context.Background()
+	if client != "" {
+		ctx = resolvers.WithClient(ctx, netip.MustParseAddr(client))
+	}
+	return r.Resolve(ctx, req, b)
+}
+
+func TestCaptivePortalResolver_RedirectsClients(t *testing.T) {
+	next := &countingResolver{}
+	r := newCaptivePortalResolver(next)
+
+	res, err := resolveFrom(t, r, "10.20.3.4", "www.example.com", dns.TypeA)
+	require.NoError(t, err)
+	assert.Equal(t, "captive-portal", res.Source)
+	resp, err := dns.ParsePacket(res.ResponseBytes)
+	require.NoError(t, err)
+	assert.Equal(t, uint16(7), resp.Header.ID)
+	require.Len(t, resp.Answers, 1)
+	ip, ok := resp.Answers[0].(*dns.IPRecord)
+	require.True(t, ok)
+	assert.Equal(t, "10.20.0.1", ip.Addr.String())
+	assert.Equal(t, uint32(10), ip.H.TTL)
+
+	// No IPv6 portal address: AAAA gets an empty NOERROR answer
+	res, err = resolveFrom(t, r, "10.20.3.4", "www.example.com", dns.TypeAAAA)
+	require.NoError(t, err)
+	resp, err = dns.ParsePacket(res.ResponseBytes)
+	require.NoError(t, err)
+	assert.Equal(t, dns.RCodeNoError, dns.RCodeFromFlags(resp.Header.Flags))
+	assert.Empty(t, resp.Answers)
+
+	assert.Zero(t, next.calls)
+}
+
+func TestCaptivePortalResolver_PassesOtherQueries(t *testing.T) {
+	next := &countingResolver{}
+	r := newCaptivePortalResolver(next)
+
+	tests := map[string]struct{ client, name string }{
+		"other client":     {"192.168.1.5", "www.example.com"},
+		"allowed domain":   {"10.20.3.4", "portal.example.net"},
+		"allowed sub":      {"10.20.3.4", "login.Portal.Example.NET."},
+		"no client":        {"", "www.example.com"},
+		"mapped other net": {"::ffff:192.168.1.5", "www.example.com"},
+	}
+	for name, tt := range tests {
+		_, err := resolveFrom(t, r, tt.client, tt.name, dns.TypeA)
+		require.Error(t, err, name)
+	}
+	assert.Equal(t, len(tests), next.calls)
+}
+
+// ============================================================================
+// DDR Resolver Tests
+// ============================================================================
+
+func newDDRResolver(next resolvers.Resolver) *resolvers.DDRResolver {
+	return resolvers.NewDDRResolver(resolvers.DesignatedResolver{
+		Target:    "dns.example.net",
+		DoHPort:   443,
+		DoTPort:   853,
+		DoHPath:   "/dns-query{?dns}",
+		IPv4Hints: []netip.Addr{netip.MustParseAddr("192.0.2.53")},
+		TTL:       300,
+	}, next)
+}
+
+func TestDDRResolver_AdvertisesEndpoints(t *testing.T) {
+	next := &countingResolver{}
+	r := newDDRResolver(next)
+
+	for _, name := range []string{"_dns.resolver.arpa", "_DNS.dns.example.net."} {
+		res, err := resolveFrom(t, r, "", name, dns.TypeSVCB)
+		require.NoError(t, err, name)
+		assert.Equal(t, "ddr", res.Source)
+		resp, err := dns.ParsePacket(res.ResponseBytes)
+		require.NoError(t, err)
+		require.Len(t, resp.Answers, 2, name)
+
+		doh, ok := resp.Answers[0].(*dns.SVCBRecord)
+		require.True(t, ok)
+		assert.Equal(t,
+			"1 dns.example.net. alpn=h2 port=443 ipv4hint=192.0.2.53 dohpath=/dns-query{?dns}",
+			doh.String())
+		assert.Equal(t, uint32(300), doh.H.TTL)
+		dot, ok := resp.Answers[1].(*dns.SVCBRecord)
+		require.True(t, ok)
+		assert.Equal(t, "2 dns.example.net. alpn=dot port=853 ipv4hint=192.0.2.53", dot.String())
+
+		require.Len(t, resp.Additionals, 1)
+		ip, ok := resp.Additionals[0].(*dns.IPRecord)
+		require.True(t, ok)
+		assert.Equal(t, "192.0.2.53", ip.Addr.String())
+	}
+	assert.Zero(t, next.calls)
+}
+
+func TestDDRResolver_KeepsResolverArpaLocal(t *testing.T) {
+	next := &countingResolver{}
+	r := newDDRResolver(next)
+
+	// Other types at the DDR name: empty NOERROR answer
+	res, err := resolveFrom(t, r, "", "_dns.resolver.arpa", dns.TypeA)
+	require.NoError(t, err)
+	resp, err := dns.ParsePacket(res.ResponseBytes)
+	require.NoError(t, err)
+	assert.Equal(t, dns.RCodeNoError, dns.RCodeFromFlags(resp.Header.Flags))
+	assert.Empty(t, resp.Answers)
+
+	// Other names under resolver.arpa are never forwarded
+	res, err = resolveFrom(t, r, "", "other.resolver.arpa", dns.TypeSVCB)
+	require.NoError(t, err)
+	resp, err = dns.ParsePacket(res.ResponseBytes)
+	require.NoError(t, err)
+	assert.Equal(t, dns.RCodeNXDomain, dns.RCodeFromFlags(resp.Header.Flags))
+	assert.Zero(t, next.calls)
+
+	// The target's own A records and other names resolve normally
+	_, err = resolveFrom(t, r, "", "_dns.dns.example.net", dns.TypeA)
+	require.Error(t, err)
+	_, err = resolveFrom(t, r, "", "www.example.com", dns.TypeSVCB)
+	require.Error(t, err)
+	assert.Equal(t, 2, next.calls)
+}
+
+// ============================================================================
+// Cluster Discovery Resolver Tests
+// ============================================================================
+
+// clusterMembers is a member source whose generation changes on every set.
+type clusterMembers struct {
+	members    []resolvers.ClusterMember
+	generation uint64
+}
+
+func (c *clusterMembers) set(members ...resolvers.ClusterMember) {
+	c.members = members
+	c.generation++
+}
+
+func (c *clusterMembers) get() ([]resolvers.ClusterMember, uint64) {
+	return c.members, c.generation
+}
+
+func newClusterDiscoveryResolver(next resolvers.Resolver, members *clusterMembers) *resolvers.ClusterDiscoveryResolver {
+	return resolvers.NewClusterDiscoveryResolver(resolvers.ClusterDiscovery{
+		Zone:    "Cluster.Home.Arpa.",
+		TTL:     60,
+		Members: members.get,
+	}, next)
+}
+
+// srvRData returns the SRV RDATA of priority, port and target (weight 0).
+func srvRData(t *testing.T, priority, port uint16, target string) []byte {
+	b := []byte{byte(priority >> 8), byte(priority), 0, 0, byte(port >> 8), byte(port)}
+	return append(b, mustEncodeName(t, target)...)
+}
+
+func TestClusterDiscoveryResolver_PublishesMembers(t *testing.T) {
+	next := &countingResolver{}
+	members := &clusterMembers{}
+	members.set(
+		resolvers.ClusterMember{NodeID: "DNS1", Role: "primary", Addr: netip.MustParseAddr("10.0.0.1"), DNSPort: 53, APIPort: 8080},
+		resolvers.ClusterMember{NodeID: "dns_2", Role: "secondary", Addr: netip.MustParseAddr("fd00::2"), DNSPort: 5353, APIPort: 8080},
+		resolvers.ClusterMember{NodeID: "dns3", Role: "secondary"},
+	)
+	r := newClusterDiscoveryResolver(next, members)
+
+	res, err := resolveFrom(t, r, "", "_hydradns._tcp.cluster.home.arpa", dns.TypeSRV)
+	require.NoError(t, err)
+	assert.Equal(t, "cluster-discovery", res.Source)
+	resp, err := dns.ParsePacket(res.ResponseBytes)
+	require.NoError(t, err)
+	require.Len(t, resp.Answers, 2, "members without an address get no SRV record")
+	assert.Equal(t, srvRData(t, 0, 8080, "dns1.cluster.home.arpa"), resp.Answers[0].(*dns.OpaqueRecord).Data)
+	assert.Equal(t, srvRData(t, 10, 8080, "dns-2.cluster.home.arpa"), resp.Answers[1].(*dns.OpaqueRecord).Data)
+	assert.Equal(t, uint32(60), resp.Answers[0].Header().TTL)
+	require.Len(t, resp.Additionals, 2)
+	assert.Equal(t, "10.0.0.1", resp.Additionals[0].(*dns.IPRecord).Addr.String())
+	assert.Equal(t, "fd00::2", resp.Additionals[1].(*dns.IPRecord).Addr.String())
+
+	res, err = resolveFrom(t, r, "", "_dns._udp.cluster.home.arpa", dns.TypeSRV)
+	require.NoError(t, err)
+	resp, err = dns.ParsePacket(res.ResponseBytes)
+	require.NoError(t, err)
+	require.Len(t, resp.Answers, 2)
+	assert.Equal(t, srvRData(t, 0, 5353, "dns-2.cluster.home.arpa"), resp.Answers[1].(*dns.OpaqueRecord).Data)
+
+	res, err = resolveFrom(t, r, "", "dns3.cluster.home.arpa", dns.TypeTXT)
+	require.NoError(t, err)
+	resp, err = dns.ParsePacket(res.ResponseBytes)
+	require.NoError(t, err)
+	require.Len(t, resp.Answers, 1)
+	assert.Equal(t, []byte("\x0erole=secondary\x0cnode_id=dns3"), resp.Answers[0].(*dns.OpaqueRecord).Data)
+	assert.Zero(t, next.calls)
+}
+
+func TestClusterDiscoveryResolver_FollowsMembershipChanges(t *testing.T) {
+	next := &countingResolver{}
+	members := &clusterMembers{}
+	members.set(resolvers.ClusterMember{NodeID: "dns1", Role: "primary", Addr: netip.MustParseAddr("10.0.0.1")})
+	r := newClusterDiscoveryResolver(next, members)
+
+	rcode := func(name string, qtype dns.RecordType) (dns.RCode, int) {
+		t.Helper()
+		res, err := resolveFrom(t, r, "", name, qtype)
+		require.NoError(t, err)
+		resp, err := dns.ParsePacket(res.ResponseBytes)
+		require.NoError(t, err)
+		return dns.RCodeFromFlags(resp.Header.Flags), len(resp.Answers)
+	}
+
+	code, answers := rcode("dns2.cluster.home.arpa", dns.TypeA)
+	assert.Equal(t, dns.RCodeNXDomain, code)
+	assert.Zero(t, answers)
+
+	members.set(
+		resolvers.ClusterMember{NodeID: "dns1", Role: "primary", Addr: netip.MustParseAddr("10.0.0.1")},
+		resolvers.ClusterMember{NodeID: "dns2", Role: "secondary", Addr: netip.MustParseAddr("10.0.0.2")},
+	)
+	code, answers = rcode("dns2.cluster.home.arpa", dns.TypeA)
+	assert.Equal(t, dns.RCodeNoError, code)
+	assert.Equal(t, 1, answers)
+
+	// The zone and service names exist without records of the type asked
+	for _, name := range []string{"cluster.home.arpa", "_tcp.cluster.home.arpa", "dns2.cluster.home.arpa"} {
+		code, answers = rcode(name, dns.TypeMX)
+		assert.Equal(t, dns.RCodeNoError, code, name)
+		assert.Zero(t, answers, name)
+	}
+
+	// Names outside the zone resolve normally
+	_, err := resolveFrom(t, r, "", "www.example.com", dns.TypeA)
+	require.Error(t, err)
+	assert.Equal(t, 1, next.calls)
+}
+
+// ============================================================================
+// Synthesized PTR Resolver Tests
+// ============================================================================
+
+// ip6Arpa returns the ip6.arpa name of addr.
+func ip6Arpa(addr string) string {
+	b := netip.MustParseAddr(addr).As16()
+	var sb strings.Builder
+	for i := len(b) - 1; i >= 0; i-- {
+		fmt.Fprintf(&sb, "%x.%x.", b[i]&0x0f, b[i]>>4)
+	}
+	return sb.String() + "ip6.arpa."
+}
+
+func TestSynthPTRResolver_AnswersLocalPrefixes(t *testing.T) {
+	next := &countingResolver{}
+	r := resolvers.NewSynthPTRResolver(resolvers.SynthPTR{
+		Prefixes: []netip.Prefix{netip.MustParsePrefix("2001:db8:1::/64")},
+		Template: "{ip}.lan.home.arpa",
+		TTL:      60,
+	}, next)
+
+	res, err := resolveFrom(t, r, "", ip6Arpa("2001:db8:1::21a:2bff:fe3c:4d5e"), dns.TypePTR)
+	require.NoError(t, err)
+	assert.Equal(t, "synth-ptr", res.Source)
+	resp, err := dns.ParsePacket(res.ResponseBytes)
+	require.NoError(t, err)
+	require.Len(t, resp.Answers, 1)
+	ptr, ok := resp.Answers[0].(*dns.NameRecord)
+	require.True(t, ok)
+	assert.Equal(t, "2001-db8-1-0-21a-2bff-fe3c-4d5e.lan.home.arpa", ptr.Target)
+	assert.Equal(t, uint32(60), ptr.H.TTL)
+
+	// Other types get an empty answer
+	res, err = resolveFrom(t, r, "", ip6Arpa("2001:db8:1::1"), dns.TypeTXT)
+	require.NoError(t, err)
+	resp, err = dns.ParsePacket(res.ResponseBytes)
+	require.NoError(t, err)
+	assert.Empty(t, resp.Answers)
+	assert.Zero(t, next.calls)
+
+	// Other prefixes, partial names, and forward names resolve normally
+	for _, name := range []string{ip6Arpa("2001:db8:2::1"), "1.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa", "www.example.com"} {
+		_, err = resolveFrom(t, r, "", name, dns.TypePTR)
+		require.Error(t, err, name)
+	}
+	assert.Equal(t, 3, next.calls)
+}
+
+func TestSynthPTRHost(t *testing.T) {
+	tests := map[string]string{
+		"2001:db8::1": "2001-db8--1.lan",
+		"::1":         "0--1.lan",
+		"fd00::":      "fd00--0.lan",
+	}
+	for addr, want := range tests {
+		assert.Equal(t, want, resolvers.SynthPTRHost("{ip}.lan", netip.MustParseAddr(addr)), addr)
+	}
+}
+
+// ============================================================================
+// AAAA Filter Resolver Tests
+// ============================================================================
+
+// dualStackResolver answers every query with a CNAME to host.example.net
+// and an A or AAAA record for it, matching the query type.
+func dualStackResolver() *mockResolver {
+	return &mockResolver{
+		resolveFunc: func(_ context.Context, req dns.Packet, _ []byte) (resolvers.Result, error) {
+			q := req.Questions[0]
+			ip := net.ParseIP("2001:db8::1")
+			if q.Type == uint16(dns.TypeA) {
+				ip = net.IPv4(192, 0, 2, 1)
+			}
+			resp := req
+			resp.Header.Flags |= dns.QRFlag
+			resp.Answers = []dns.Record{
+				dns.NewCNAMERecord(dns.NewRRHeader(q.Name, dns.ClassIN, 60), "host.example.net"),
+				dns.NewIPRecord(dns.NewRRHeader("host.example.net", dns.ClassIN, 60), ip),
+			}
+			b, err := resp.Marshal()
+			return resolvers.Result{ResponseBytes: b, Source: "upstream"}, err
+		},
+	}
+}
+
+func TestAAAAFilterResolver_RemovesAAAAForClients(t *testing.T) {
+	r := resolvers.NewAAAAFilterResolver([]netip.Prefix{netip.MustParsePrefix("10.30.0.0/16")}, dualStackResolver())
+
+	res, err := resolveFrom(t, r, "10.30.1.2", "www.example.com", dns.TypeAAAA)
+	require.NoError(t, err)
+	assert.Equal(t, "upstream", res.Source)
+	resp, err := dns.ParsePacket(res.ResponseBytes)
+	require.NoError(t, err)
+	assert.Equal(t, dns.RCodeNoError, dns.RCodeFromFlags(resp.Header.Flags))
+	require.Len(t, resp.Answers, 1, "the CNAME is kept")
+	assert.Equal(t, dns.TypeCNAME, resp.Answers[0].Type())
+
+	tests := []struct {
+		client string
+		qtype  dns.RecordType
+	}{
+		{"10.30.1.2", dns.TypeA},    // Only AAAA is filtered
+		{"10.40.1.2", dns.TypeAAAA}, // Other clients
+		{"", dns.TypeAAAA},          // No client
+	}
+	for _, tt := range tests {
+		res, err := resolveFrom(t, r, tt.client, "www.example.com", tt.qtype)
+		require.NoError(t, err)
+		resp, err := dns.ParsePacket(res.ResponseBytes)
+		require.NoError(t, err)
+		assert.Len(t, resp.Answers, 2, "%s %s", tt.client, tt.qtype)
+	}
+}
+
+func TestAAAAFilterResolver_EmptyClientsFiltersAll(t *testing.T) {
+	r := resolvers.NewAAAAFilterResolver(nil, dualStackResolver())
+
+	for _, client := range []string{"192.0.2.7", "2001:db8::7", ""} {
+		res, err := resolveFrom(t, r, client, "www.example.com", dns.TypeAAAA)
+		require.NoError(t, err)
+		resp, err := dns.ParsePacket(res.ResponseBytes)
+		require.NoError(t, err)
+		assert.Len(t, resp.Answers, 1, client)
+	}
+
+	// Errors pass through
+	failing := resolvers.NewAAAAFilterResolver(nil, &countingResolver{})
+	_, err := resolveFrom(t, failing, "", "www.example.com", dns.TypeAAAA)
+	require.Error(t, err)
+}
+
+// ============================================================================
+// ForwardingResolver Dialer Tests
+// ============================================================================
+
+// pipeDialer serves upstream queries in memory over net.Pipe, answering
+// each with the packet respond returns for the network it came over.
+type pipeDialer struct {
+	respond func(network string, req dns.Packet) dns.Packet
+
+	mu     sync.Mutex
+	dialed []string        // "network address" of every dial
+	down   map[string]bool // Addresses whose dials fail
+}
+
+// setDown makes dials to address fail, or succeed again.
+func (d *pipeDialer) setDown(address string, down bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.down == nil {
+		d.down = map[string]bool{}
+	}
+	d.down[address] = down
+}
+
+func (d *pipeDialer) DialContext(_ context.Context, network, address string) (net.Conn, error) {
+	d.mu.Lock()
+	down := d.down[address]
+	if !down {
+		d.dialed = append(d.dialed, network+" "+address)
+	}
+	d.mu.Unlock()
+	if down {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: errors.New("connection refused")}
+	}
+	client, server := net.Pipe()
+	go d.serve(network, server)
+	return client, nil
+}
+
+// serve answers the queries on conn until the resolver closes it.
+func (d *pipeDialer) serve(network string, conn net.Conn) {
+	defer conn.Close()
+	buf := make([]byte, 65535)
+	for {
+		var msg []byte
+		if network == "tcp" {
+			if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+				return
+			}
+			msg = buf[:binary.BigEndian.Uint16(buf)]
+			if _, err := io.ReadFull(conn, msg); err != nil {
+				return
+			}
+		} else {
+			n, err := conn.Read(buf)
+			if err != nil {
+				return
+			}
+			msg = buf[:n]
+		}
+		req, err := dns.ParsePacket(msg)
+		if err != nil {
+			return
+		}
+		resp := d.respond(network, req)
+		resp.Header.Flags |= dns.QRFlag
+		b, err := resp.Marshal()
+		if err != nil {
+			return
+		}
+		if network == "tcp" {
+			b = append(binary.BigEndian.AppendUint16(nil, uint16(len(b))), b...)
+		}
+		if _, err := conn.Write(b); err != nil {
+			return
+		}
+	}
+}
+
+// answerA returns req answered with one A record.
+func answerA(req dns.Packet) dns.Packet {
+	req.Answers = []dns.Record{dns.NewIPRecord(
+		dns.RRHeader{Name: req.Questions[0].Name, Class: uint16(dns.ClassIN), TTL: 60},
+		net.IPv4(192, 0, 2, 20),
+	)}
+	return req
+}
+
+func TestForwardingResolver_QueriesThroughDialer(t *testing.T) {
+	dialer := &pipeDialer{respond: func(_ string, req dns.Packet) dns.Packet { return answerA(req) }}
+	f := resolvers.NewForwardingResolver([]string{"192.0.2.53"}, 1, 0, false, time.Second, 0, 1)
+	f.SetDialer(dialer)
+	t.Cleanup(func() { _ = f.Close() })
+
+	req, b := newAQuery(t, 40, "dialer.example")
+	res, err := f.Resolve(context.Background(), req, b)
+	require.NoError(t, err)
+	assert.Equal(t, "upstream", res.Source)
+	assert.Equal(t, "192.0.2.53", res.Upstream)
+	resp, err := dns.ParsePacket(res.ResponseBytes)
+	require.NoError(t, err)
+	assert.Equal(t, uint16(40), resp.Header.ID)
+	require.Len(t, resp.Answers, 1)
+	assert.Equal(t, "192.0.2.20", resp.Answers[0].(*dns.IPRecord).Addr.String())
+
+	res, err = f.Resolve(context.Background(), req, b)
+	require.NoError(t, err)
+	assert.Equal(t, "upstream-cache", res.Source)
+	assert.Equal(t, []string{"udp 192.0.2.53:53"}, dialer.dialed)
+}
+
+func TestForwardingResolver_DialerTCPFallback(t *testing.T) {
+	dialer := &pipeDialer{respond: func(network string, req dns.Packet) dns.Packet {
+		if network == "udp" {
+			req.Header.Flags |= dns.TCFlag
+			return req
+		}
+		return answerA(req)
+	}}
+	f := resolvers.NewForwardingResolver([]string{"192.0.2.53"}, 1, 0, true, time.Second, time.Second, 1)
+	f.SetDialer(dialer)
+	t.Cleanup(func() { _ = f.Close() })
+
+	req, b := newAQuery(t, 41, "truncated.example")
+	res, err := f.Resolve(context.Background(), req, b)
+	require.NoError(t, err)
+	resp, err := dns.ParsePacket(res.ResponseBytes)
+	require.NoError(t, err)
+	assert.Zero(t, resp.Header.Flags&dns.TCFlag)
+	assert.Len(t, resp.Answers, 1)
+	assert.Equal(t, []string{"udp 192.0.2.53:53", "tcp 192.0.2.53:53"}, dialer.dialed)
+}
+
+func TestForwardingResolver_FailedUpstreamRecoversAfterCooldown(t *testing.T) {
+	dialer := &pipeDialer{respond: func(_ string, req dns.Packet) dns.Packet { return answerA(req) }}
+	dialer.setDown("192.0.2.53:53", true)
+	clk := clock.NewManual(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	f := resolvers.NewForwardingResolver([]string{"192.0.2.53", "192.0.2.54"}, 1, 0, false, time.Second, 0, 1)
+	f.SetDialer(dialer)
+	f.SetClock(clk)
+	t.Cleanup(func() { _ = f.Close() })
+
+	resolve := func(name string) string {
+		t.Helper()
+		req, b := newAQuery(t, 42, name)
+		res, err := f.Resolve(context.Background(), req, b)
+		require.NoError(t, err)
+		return res.Upstream
+	}
+
+	assert.Equal(t, "192.0.2.54", resolve("one.example"), "fails over")
+	dialer.setDown("192.0.2.53:53", false)
+	clk.Advance(59 * time.Minute)
+	assert.Equal(t, "192.0.2.54", resolve("two.example"), "first upstream still cooling down")
+	clk.Advance(time.Minute)
+	assert.Equal(t, "192.0.2.53", resolve("three.example"), "first upstream tried again")
+}
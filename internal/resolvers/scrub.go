@@ -0,0 +1,323 @@
+package resolvers
+
+import (
+	"encoding/binary"
+	"strings"
+
+	"github.com/jroosing/hydradns/internal/dns"
+	"github.com/jroosing/hydradns/internal/helpers"
+)
+
+// typeDNAME is the DNAME record type (RFC 6672).
+const typeDNAME dns.RecordType = 39
+
+// scrubRR is one resource record located in a response.
+type scrubRR struct {
+	section int // 0 answer, 1 authority, 2 additional
+	owner   string
+	rrType  dns.RecordType
+	start   int // Offset of the owner name
+	nameEnd int // Offset of TYPE, just past the owner name
+	rdStart int
+	rdEnd   int
+	target  string // Name in the RDATA of CNAME, DNAME, NS, MX, and SRV records
+}
+
+// scrubResponse removes records that are out of bailiwick for the question
+// from an upstream response, as unbound's scrubber does, so a misbehaving
+// upstream cannot slip unrelated data to clients through the cache. It
+// returns msg itself when nothing is removed, along with the number of
+// records removed.
+//
+// The records kept are:
+//   - Answer: records owned by the query name or a name in the CNAME chain
+//     from it, and DNAMEs (and their RRSIGs) owned by an ancestor of one.
+//   - Authority: SOA and NS records owned by a chain name or an ancestor of
+//     one, and DS, NSEC, NSEC3, and RRSIG records at or below a zone named
+//     by a kept SOA or NS. Without a SOA or NS the zone is unknown, so proof
+//     records are kept for validators to judge. Other types are removed.
+//   - Additional: OPT, and A, AAAA, and RRSIG records for a chain name or a
+//     name that a kept NS, MX, or SRV record points to.
+//
+// Names in the rebuilt message are not compressed, since dropping a record
+// could break compression pointers into it. The response has already been
+// checked by validateResponse; msg is returned unchanged if it cannot be
+// walked.
+func scrubResponse(msg []byte, maxCNAMEChain int) ([]byte, int) {
+	off := 0
+	h, err := dns.ParseHeader(msg, &off)
+	if err != nil || h.QDCount != 1 {
+		return msg, 0
+	}
+	q, err := dns.ParseQuestion(msg, &off)
+	if err != nil {
+		return msg, 0
+	}
+	questionEnd := off
+
+	rrs, ok := walkRecords(msg, off, h)
+	if !ok {
+		return msg, 0
+	}
+
+	// Names the answer is about: the query name and its CNAME chain
+	links := make(map[string]string)
+	for _, rr := range rrs {
+		if rr.section == 0 && rr.rrType == dns.TypeCNAME {
+			links[rr.owner] = rr.target
+		}
+	}
+	chain := map[string]struct{}{}
+	qname := dns.NormalizeName(q.Name)
+	chain[qname] = struct{}{}
+	_, err = followCNAMEChain(qname, maxCNAMEChain, func(name string) (string, bool) {
+		target, ok := links[name]
+		return target, ok
+	}, func(_, target string) { chain[target] = struct{}{} })
+	if err != nil {
+		return msg, 0
+	}
+	ancestorOfChain := func(name string) bool {
+		for c := range chain {
+			if isSubdomain(c, name) {
+				return true
+			}
+		}
+		return false
+	}
+
+	keep := make([]bool, len(rrs))
+	targets := map[string]struct{}{}
+	var zones []string
+
+	// Answer section
+	for i, rr := range rrs {
+		if rr.section != 0 {
+			continue
+		}
+		_, inChain := chain[rr.owner]
+		switch {
+		case inChain:
+			keep[i] = true
+		case rr.rrType == typeDNAME || rr.rrType == dns.TypeRRSIG:
+			keep[i] = ancestorOfChain(rr.owner)
+		}
+		if keep[i] && (rr.rrType == dns.TypeMX || rr.rrType == dns.TypeSRV || rr.rrType == dns.TypeNS) {
+			targets[rr.target] = struct{}{}
+		}
+	}
+
+	// Authority section: zone records first, then the proofs they scope
+	for i, rr := range rrs {
+		if rr.section != 1 || (rr.rrType != dns.TypeSOA && rr.rrType != dns.TypeNS) {
+			continue
+		}
+		if ancestorOfChain(rr.owner) {
+			keep[i] = true
+			zones = append(zones, rr.owner)
+			if rr.rrType == dns.TypeNS {
+				targets[rr.target] = struct{}{}
+			}
+		}
+	}
+	for i, rr := range rrs {
+		if rr.section != 1 {
+			continue
+		}
+		switch rr.rrType {
+		case dns.TypeDS, dns.TypeNSEC, dns.TypeNSEC3, dns.TypeRRSIG:
+			keep[i] = len(zones) == 0
+			for _, z := range zones {
+				if isSubdomain(rr.owner, z) {
+					keep[i] = true
+					break
+				}
+			}
+		}
+	}
+
+	// Additional section
+	for i, rr := range rrs {
+		if rr.section != 2 {
+			continue
+		}
+		switch rr.rrType {
+		case dns.TypeOPT:
+			keep[i] = true
+		case dns.TypeA, dns.TypeAAAA, dns.TypeRRSIG:
+			_, inChain := chain[rr.owner]
+			_, isTarget := targets[rr.owner]
+			keep[i] = inChain || isTarget
+		}
+	}
+
+	removed := 0
+	for _, k := range keep {
+		if !k {
+			removed++
+		}
+	}
+	if removed == 0 {
+		return msg, 0
+	}
+
+	out, ok := rebuildScrubbed(msg, questionEnd, rrs, keep)
+	if !ok {
+		return msg, 0
+	}
+	return out, removed
+}
+
+// walkRecords locates every resource record after the question section.
+func walkRecords(msg []byte, off int, h dns.Header) ([]scrubRR, bool) {
+	counts := [3]int{int(h.ANCount), int(h.NSCount), int(h.ARCount)}
+	rrs := make([]scrubRR, 0, counts[0]+counts[1]+counts[2])
+	for section, n := range counts {
+		for range n {
+			start := off
+			owner, err := dns.DecodeName(msg, &off)
+			if err != nil || off+10 > len(msg) {
+				return nil, false
+			}
+			rr := scrubRR{
+				section: section,
+				owner:   dns.NormalizeName(owner),
+				rrType:  dns.RecordType(binary.BigEndian.Uint16(msg[off : off+2])),
+				start:   start,
+				nameEnd: off,
+			}
+			rdlen := int(binary.BigEndian.Uint16(msg[off+8 : off+10]))
+			rr.rdStart = off + 10
+			rr.rdEnd = rr.rdStart + rdlen
+			if rr.rdEnd > len(msg) {
+				return nil, false
+			}
+			if skip, ok := rdataNameOffset(rr.rrType); ok && skip < rdlen {
+				nameOff := rr.rdStart + skip
+				target, err := dns.DecodeName(msg, &nameOff)
+				if err != nil {
+					return nil, false
+				}
+				rr.target = dns.NormalizeName(target)
+			}
+			rrs = append(rrs, rr)
+			off = rr.rdEnd
+		}
+	}
+	return rrs, true
+}
+
+// rdataNameOffset returns where the target name starts in the RDATA of
+// record types that point at another name.
+func rdataNameOffset(t dns.RecordType) (int, bool) {
+	switch t {
+	case dns.TypeCNAME, dns.TypeNS, typeDNAME:
+		return 0, true
+	case dns.TypeMX:
+		return 2, true // PREFERENCE
+	case dns.TypeSRV:
+		return 6, true // PRIORITY, WEIGHT, PORT
+	default:
+		return 0, false
+	}
+}
+
+// rebuildScrubbed copies the header, question, and kept records of msg into
+// a new message with updated section counts and uncompressed names.
+func rebuildScrubbed(msg []byte, questionEnd int, rrs []scrubRR, keep []bool) ([]byte, bool) {
+	out := make([]byte, 0, len(msg)+64)
+	out = append(out, msg[:questionEnd]...)
+
+	var counts [3]uint16
+	for i, rr := range rrs {
+		if !keep[i] {
+			continue
+		}
+		// Re-read the owner to keep its original case
+		nameOff := rr.start
+		name, err := dns.DecodeName(msg, &nameOff)
+		if err != nil {
+			return nil, false
+		}
+		owner, ok := encodeUncompressed(name)
+		if !ok {
+			return nil, false
+		}
+		out = append(out, owner...)
+		out = append(out, msg[rr.nameEnd:rr.nameEnd+8]...) // TYPE, CLASS, TTL
+
+		rdata, ok := expandRData(msg, rr)
+		if !ok || len(rdata) > 0xFFFF {
+			return nil, false
+		}
+		out = binary.BigEndian.AppendUint16(out, helpers.ClampIntToUint16(len(rdata)))
+		out = append(out, rdata...)
+		counts[rr.section]++
+	}
+
+	binary.BigEndian.PutUint16(out[6:8], counts[0])
+	binary.BigEndian.PutUint16(out[8:10], counts[1])
+	binary.BigEndian.PutUint16(out[10:12], counts[2])
+	return out, true
+}
+
+// encodeUncompressed encodes name in wire format without compression.
+func encodeUncompressed(name string) ([]byte, bool) {
+	if strings.TrimSuffix(name, ".") == "" {
+		return []byte{0}, true
+	}
+	b, err := dns.EncodeName(name)
+	return b, err == nil
+}
+
+// expandRData returns the RDATA of rr with any compressed names expanded.
+// Only the RFC 1035 types that may carry compressed names, plus SRV, are
+// rewritten; other RDATA is copied as is (RFC 3597 §4).
+func expandRData(msg []byte, rr scrubRR) ([]byte, bool) {
+	raw := msg[rr.rdStart:rr.rdEnd]
+	var names int
+	prefix := 0
+	suffix := 0
+	switch rr.rrType {
+	case dns.TypeCNAME, dns.TypeNS, dns.TypePTR, typeDNAME:
+		names = 1
+	case dns.TypeMX:
+		prefix, names = 2, 1
+	case dns.TypeSRV:
+		prefix, names = 6, 1
+	case dns.TypeSOA:
+		names, suffix = 2, 20 // SERIAL, REFRESH, RETRY, EXPIRE, MINIMUM
+	default:
+		return raw, true
+	}
+	if len(raw) < prefix {
+		return nil, false
+	}
+
+	out := append([]byte(nil), raw[:prefix]...)
+	off := rr.rdStart + prefix
+	for range names {
+		name, err := dns.DecodeName(msg, &off)
+		if err != nil {
+			return nil, false
+		}
+		b, ok := encodeUncompressed(name)
+		if !ok {
+			return nil, false
+		}
+		out = append(out, b...)
+	}
+	if off+suffix > rr.rdEnd {
+		return nil, false
+	}
+	return append(out, msg[off:off+suffix]...), true
+}
+
+// isSubdomain reports whether name is zone or below it. Both names must be
+// normalized; the root zone is "".
+func isSubdomain(name, zone string) bool {
+	if zone == "" || name == zone {
+		return true
+	}
+	return strings.HasSuffix(name, "."+zone)
+}
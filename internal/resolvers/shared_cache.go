@@ -0,0 +1,227 @@
+package resolvers
+
+import (
+	"context"
+	"encoding/binary"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jroosing/hydradns/internal/dns"
+)
+
+// SharedCache is an external cache, such as redis or memcached, that
+// several HydraDNS nodes share. See ForwardingResolver.SetSharedCache.
+//
+// Implementations bound every call with their own timeout and must be safe
+// for concurrent use.
+type SharedCache interface {
+	// Get returns the value stored under key, or nil without an error when
+	// there is none.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Set stores value under key for ttl.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete removes the values stored under keys; missing keys are not
+	// an error.
+	Delete(ctx context.Context, keys ...string) error
+	// Backend names the kind of server, e.g. "redis", for statistics.
+	Backend() string
+	// Close releases the connections to the cache server.
+	Close() error
+}
+
+// SharedCacheStats counts the lookups and stores of the shared cache tier.
+type SharedCacheStats struct {
+	Backend string // SharedCache.Backend
+	Hits    uint64 // Lookups answered from the shared cache
+	Misses  uint64 // Lookups that found nothing usable, so upstream was queried
+	Errors  uint64 // Lookups and stores that failed, e.g. on a timeout
+	Stores  uint64 // Responses written to the shared cache
+	Dropped uint64 // Stores skipped because too many were already in flight
+}
+
+const (
+	// sharedEntryVersion starts every shared cache value, so the format can
+	// change without misreading entries written by older nodes.
+	sharedEntryVersion = 1
+	// sharedHeaderSize is the version, entry type, and the stored and
+	// expiry times in Unix milliseconds.
+	sharedHeaderSize = 2 + 8 + 8
+	// maxSharedWrites caps the stores in flight, so a slow cache server
+	// cannot pile up goroutines during a burst of cache misses.
+	maxSharedWrites = 64
+)
+
+// sharedTier is the second-tier cache behind ForwardingResolver.cache.
+type sharedTier struct {
+	cache  SharedCache
+	writes chan struct{} // Semaphore of stores in flight
+	wg     sync.WaitGroup
+
+	hits    atomic.Uint64
+	misses  atomic.Uint64
+	errors  atomic.Uint64
+	stores  atomic.Uint64
+	dropped atomic.Uint64
+}
+
+// SetSharedCache adds c as a second cache tier shared with other nodes,
+// e.g. several HydraDNS instances behind a load balancer.
+//
+// A query missing from the in-memory cache is looked up in c before going
+// upstream; a hit fills the in-memory cache for the entry's remaining
+// lifetime. Every response stored in the in-memory cache is also written
+// to c in the background, positive and negative alike. Entries carry their
+// expiry, so TTLs count down across nodes as they do locally. Failures of
+// c are counted and otherwise ignored: queries then go upstream as if
+// there were no shared cache.
+//
+// The resolver closes c in Close. Must be called before the resolver
+// starts serving queries.
+func (f *ForwardingResolver) SetSharedCache(c SharedCache) {
+	if c == nil {
+		f.shared = nil
+		return
+	}
+	f.shared = &sharedTier{cache: c, writes: make(chan struct{}, maxSharedWrites)}
+}
+
+// SharedCacheStats returns the shared cache counters. ok is false when no
+// shared cache is set.
+func (f *ForwardingResolver) SharedCacheStats() (SharedCacheStats, bool) {
+	t := f.shared
+	if t == nil {
+		return SharedCacheStats{}, false
+	}
+	return SharedCacheStats{
+		Backend: t.cache.Backend(),
+		Hits:    t.hits.Load(),
+		Misses:  t.misses.Load(),
+		Errors:  t.errors.Load(),
+		Stores:  t.stores.Load(),
+		Dropped: t.dropped.Load(),
+	}, true
+}
+
+// sharedKey is the shared cache key of key. The upstream is part of it
+// like in the in-memory cache, so nodes forwarding to different servers
+// do not mix their answers.
+func sharedKey(key cacheKey) string {
+	return "v1:" + key.up + ":" + strconv.Itoa(int(key.q.QClass)) + ":" +
+		strconv.Itoa(int(key.q.QType)) + ":" + key.q.QName
+}
+
+// lookupShared returns the shared cache entry for key, with its TTLs
+// counted down, and copies it into the in-memory cache. Entries that are
+// expired, malformed, or do not answer req are misses.
+func (f *ForwardingResolver) lookupShared(ctx context.Context, key cacheKey, req dns.Packet) ([]byte, bool) {
+	t := f.shared
+	if t == nil || cacheSkipped(ctx) || f.cacheBypass.Load().Matches(key.q.QName) {
+		return nil, false
+	}
+
+	v, err := t.cache.Get(ctx, sharedKey(key))
+	if err != nil {
+		t.errors.Add(1)
+		return nil, false
+	}
+	now := time.Now()
+	resp, entryType, age, ttl, ok := decodeSharedEntry(v, now)
+	// The entry is checked like an upstream response: anyone who can
+	// write to the cache server could otherwise answer for any name
+	if !ok || validateResponse(req, resp, f.maxCNAMEChain) != nil {
+		t.misses.Add(1)
+		return nil, false
+	}
+	t.hits.Add(1)
+
+	resp = adjustTTLs(resp, age)
+	f.cache.Set(key, resp, ttl, entryType)
+	return resp, true
+}
+
+// storeShared writes resp to the shared cache in the background, to
+// expire after ttl.
+func (f *ForwardingResolver) storeShared(key cacheKey, resp []byte, ttl time.Duration, entryType CacheEntryType) {
+	t := f.shared
+	if t == nil {
+		return
+	}
+	select {
+	case t.writes <- struct{}{}:
+	default:
+		t.dropped.Add(1)
+		return
+	}
+
+	value := encodeSharedEntry(resp, entryType, time.Now(), ttl)
+	t.wg.Go(func() {
+		defer func() { <-t.writes }()
+		if err := t.cache.Set(context.Background(), sharedKey(key), value, ttl); err != nil {
+			t.errors.Add(1)
+			return
+		}
+		t.stores.Add(1)
+	})
+}
+
+// purgeShared deletes the shared cache entries for name of every named
+// record type, as cached from any of the configured upstreams, and waits
+// for the deletion.
+func (f *ForwardingResolver) purgeShared(name string) {
+	t := f.shared
+	if t == nil {
+		return
+	}
+	var keys []string
+	for _, up := range f.upstreamList() {
+		for _, rt := range dns.NamedRecordTypes() {
+			q := QuestionKey{QName: name, QType: uint16(rt), QClass: uint16(dns.ClassIN)}
+			keys = append(keys, sharedKey(cacheKey{q: q, up: up}))
+		}
+	}
+	if err := t.cache.Delete(context.Background(), keys...); err != nil {
+		t.errors.Add(1)
+	}
+}
+
+// close waits for stores in flight and closes the cache client.
+func (t *sharedTier) close() error {
+	t.wg.Wait()
+	return t.cache.Close()
+}
+
+// encodeSharedEntry prefixes resp with the header read by
+// decodeSharedEntry.
+func encodeSharedEntry(resp []byte, entryType CacheEntryType, stored time.Time, ttl time.Duration) []byte {
+	out := make([]byte, sharedHeaderSize, sharedHeaderSize+len(resp))
+	out[0] = sharedEntryVersion
+	out[1] = byte(entryType)                                                    //nolint:gosec // CachePositive..CacheSERVFAIL
+	binary.BigEndian.PutUint64(out[2:10], uint64(stored.UnixMilli()))           //nolint:gosec // Unix time after 1970
+	binary.BigEndian.PutUint64(out[10:18], uint64(stored.Add(ttl).UnixMilli())) //nolint:gosec // Unix time after 1970
+	return append(out, resp...)
+}
+
+// decodeSharedEntry splits a shared cache value into the response, its
+// entry type, its age, and its remaining lifetime at now. ok is false for
+// values in another format and for expired entries.
+func decodeSharedEntry(v []byte, now time.Time) ([]byte, CacheEntryType, time.Duration, time.Duration, bool) {
+	if len(v) < sharedHeaderSize+dns.HeaderSize || v[0] != sharedEntryVersion {
+		return nil, 0, 0, 0, false
+	}
+	entryType := CacheEntryType(v[1])
+	if entryType < CachePositive || entryType > CacheSERVFAIL {
+		return nil, 0, 0, 0, false
+	}
+	stored := time.UnixMilli(int64(binary.BigEndian.Uint64(v[2:10])))   //nolint:gosec // written by encodeSharedEntry
+	expires := time.UnixMilli(int64(binary.BigEndian.Uint64(v[10:18]))) //nolint:gosec // written by encodeSharedEntry
+	ttl := expires.Sub(now)
+	if ttl <= 0 {
+		return nil, 0, 0, 0, false
+	}
+	// Clocks of different nodes disagree a little; an entry from the
+	// future has simply not aged yet
+	age := max(now.Sub(stored), 0)
+	return v[sharedHeaderSize:], entryType, age, ttl, true
+}
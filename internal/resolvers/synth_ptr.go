@@ -0,0 +1,137 @@
+package resolvers
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+
+	"github.com/jroosing/hydradns/internal/dns"
+)
+
+// ip6ArpaSuffix is the reverse mapping zone of IPv6 addresses (RFC 3596 §2.5).
+const ip6ArpaSuffix = ".ip6.arpa"
+
+// SynthPTR configures a SynthPTRResolver.
+type SynthPTR struct {
+	// Prefixes are the local IPv6 prefixes whose addresses get PTR answers.
+	Prefixes []netip.Prefix
+	// Template is the hostname answered, with "{ip}" replaced by the
+	// address, e.g. "{ip}.lan.home.arpa" (see SynthPTRHost).
+	Template string
+	// TTL is the TTL of synthesized answers.
+	TTL uint32
+}
+
+// SynthPTRResolver answers reverse lookups of addresses in local IPv6
+// prefixes with a hostname made from the address, like dnsmasq's
+// synth-domain, so reverse lookups of SLAAC and DHCPv6 addresses, which
+// have no configured names, do not all fail.
+//
+// PTR (and ANY) queries for the ip6.arpa name of an address in one of the
+// prefixes get the synthesized hostname; other types at that name get an
+// empty answer. All other queries, including partial ip6.arpa names, go to
+// the next resolver.
+type SynthPTRResolver struct {
+	next  Resolver
+	synth SynthPTR
+}
+
+// NewSynthPTRResolver creates a PTR synthesizer for synth in front of next.
+func NewSynthPTRResolver(synth SynthPTR, next Resolver) *SynthPTRResolver {
+	return &SynthPTRResolver{next: next, synth: synth}
+}
+
+// Resolve answers reverse lookups in the local prefixes and passes others
+// to the next resolver.
+func (s *SynthPTRResolver) Resolve(ctx context.Context, req dns.Packet, reqBytes []byte) (Result, error) {
+	if len(req.Questions) == 0 || req.Questions[0].Class != uint16(dns.ClassIN) {
+		return s.next.Resolve(ctx, req, reqBytes)
+	}
+	q := req.Questions[0]
+	addr, ok := parseIP6Arpa(q.Name)
+	if !ok || !s.covers(addr) {
+		return s.next.Resolve(ctx, req, reqBytes)
+	}
+
+	var answers []dns.Record
+	if q.Type == uint16(dns.TypePTR) || q.Type == qtypeANY {
+		h := dns.NewRRHeader(q.Name, dns.ClassIN, s.synth.TTL)
+		answers = append(answers, dns.NewPTRRecord(h, SynthPTRHost(s.synth.Template, addr)))
+	}
+	resp := dns.Packet{
+		Header: dns.Header{
+			ID:    req.Header.ID,
+			Flags: buildCustomDNSFlags(req.Header.Flags),
+		},
+		Questions: []dns.Question{q},
+		Answers:   answers,
+	}
+	b, err := resp.Marshal()
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{ResponseBytes: b, Source: "synth-ptr"}, nil
+}
+
+// covers reports whether addr is in one of the local prefixes.
+func (s *SynthPTRResolver) covers(addr netip.Addr) bool {
+	for _, p := range s.synth.Prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Close closes the next resolver.
+func (s *SynthPTRResolver) Close() error {
+	return s.next.Close()
+}
+
+// SynthPTRHost returns the hostname template gives addr: "{ip}" replaced by
+// the compressed address with its colons as dashes, and a zero added where
+// it would start or end with one, e.g. "2001-db8--1" or "fd00--0".
+func SynthPTRHost(template string, addr netip.Addr) string {
+	label := strings.ReplaceAll(addr.String(), ":", "-")
+	if strings.HasPrefix(label, "-") {
+		label = "0" + label
+	}
+	if strings.HasSuffix(label, "-") {
+		label += "0"
+	}
+	return strings.ReplaceAll(template, "{ip}", label)
+}
+
+// parseIP6Arpa returns the address of a full ip6.arpa name: 32 nibble
+// labels, least significant first.
+func parseIP6Arpa(name string) (netip.Addr, bool) {
+	name = normalizeZone(name)
+	nibbles, ok := strings.CutSuffix(name, ip6ArpaSuffix)
+	if !ok || len(nibbles) != 63 {
+		return netip.Addr{}, false
+	}
+	var b [16]byte
+	for i := range 32 {
+		c := nibbles[2*i]
+		if i < 31 && nibbles[2*i+1] != '.' {
+			return netip.Addr{}, false
+		}
+		var v byte
+		switch {
+		case c >= '0' && c <= '9':
+			v = c - '0'
+		case c >= 'a' && c <= 'f':
+			v = c - 'a' + 10
+		default:
+			return netip.Addr{}, false
+		}
+		// Nibble i counts from the least significant end
+		pos := 31 - i
+		if pos%2 == 0 {
+			b[pos/2] |= v << 4
+		} else {
+			b[pos/2] |= v
+		}
+	}
+	return netip.AddrFrom16(b), true
+}
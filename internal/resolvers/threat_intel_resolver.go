@@ -0,0 +1,43 @@
+package resolvers
+
+import (
+	"context"
+
+	"github.com/jroosing/hydradns/internal/dns"
+)
+
+// ThreatIntelResolver hands the names of successfully forwarded queries to a
+// threat intelligence checker. It wraps the forwarding resolver, so locally
+// configured custom DNS records are never looked up.
+//
+// The response is returned unchanged: lookups happen in the background, and
+// a domain found to be malicious is blocked by the filtering policy for later
+// queries.
+type ThreatIntelResolver struct {
+	next    Resolver
+	observe func(domain string)
+}
+
+// NewThreatIntelResolver creates a resolver that calls observe for each name
+// next answers with NOERROR. observe must not block.
+func NewThreatIntelResolver(next Resolver, observe func(domain string)) *ThreatIntelResolver {
+	return &ThreatIntelResolver{next: next, observe: observe}
+}
+
+// Resolve asks the next resolver and observes the query name when the answer
+// is NOERROR.
+func (t *ThreatIntelResolver) Resolve(ctx context.Context, req dns.Packet, reqBytes []byte) (Result, error) {
+	res, err := t.next.Resolve(ctx, req, reqBytes)
+	if err != nil || len(res.ResponseBytes) < 4 || res.ResponseBytes[3]&0x0F != uint8(dns.RCodeNoError) {
+		return res, err
+	}
+	if len(req.Questions) > 0 {
+		t.observe(req.Questions[0].Name)
+	}
+	return res, nil
+}
+
+// Close closes the next resolver.
+func (t *ThreatIntelResolver) Close() error {
+	return t.next.Close()
+}
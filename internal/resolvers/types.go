@@ -30,6 +30,9 @@ package resolvers
 
 import (
 	"context"
+	"net"
+	"net/netip"
+	"time"
 
 	"github.com/jroosing/hydradns/internal/dns"
 )
@@ -38,6 +41,14 @@ import (
 type Result struct {
 	ResponseBytes []byte // Wire-format DNS response
 	Source        string // Where the answer came from (e.g., "custom-dns", "upstream-cache", "upstream")
+
+	// Upstream is the upstream server that answered, and UpstreamTime how
+	// long the upstream exchange took, failovers included. Both are unset
+	// when the answer did not need an upstream query, such as cache hits.
+	// Queries that joined an in-flight upstream query report its upstream
+	// and time.
+	Upstream     string
+	UpstreamTime time.Duration
 }
 
 // QuestionKey uniquely identifies a DNS question for caching purposes.
@@ -64,6 +75,33 @@ type Resolver interface {
 	Close() error
 }
 
+// QueryAuditor records queries sent to upstream servers; *audit.Logger
+// implements it. Record must not block for long, as it runs on the query
+// path.
+type QueryAuditor interface {
+	Record(domain, qtype, upstream, transport string)
+}
+
+// PacketCapture records the raw DNS messages exchanged with upstream
+// servers; *capture.Recorder implements it. Capturing is checked first so
+// nothing is done while no capture runs.
+type PacketCapture interface {
+	Capturing() bool
+	// RecordUpstream records msg, sent from src to dst over transport
+	// ("udp" or "tcp") to answer client. client is invalid for queries the
+	// server makes itself.
+	RecordUpstream(transport string, client netip.Addr, src, dst netip.AddrPort, msg []byte)
+}
+
+// UpstreamDialer opens connections to upstream servers; *net.Dialer
+// implements it. The forwarding resolver dials "host:53" over network "udp"
+// for queries, reading and writing one message per datagram, or "tcp" with
+// RFC 1035 length-prefixed framing. Tests and embedders set their own to
+// answer from fake upstreams.
+type UpstreamDialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
 // PatchTransactionID replaces the transaction ID in a DNS message.
 //
 // The transaction ID occupies the first 2 bytes of every DNS message (big-endian).
@@ -90,3 +128,78 @@ func PatchTransactionID(msg []byte, txid uint16) []byte {
 	out[1] = byte(txid)      // Low byte
 	return out
 }
+
+// RestoreQuestionCase copies the client's QNAME bytes from req into the
+// question section of resp so the question is echoed exactly as asked.
+//
+// Names are compared case-insensitively internally (cache keys, lookups),
+// but some validating stub resolvers compare the question section
+// byte-for-byte, and a cached response carries whatever case the first
+// client used. Answer names compressed against the question pick up the
+// client's case as well.
+//
+// resp is returned unchanged if either message has no question, the names
+// differ other than by ASCII case, or the case already matches.
+// Like PatchTransactionID, it copies rather than modifying resp in place.
+func RestoreQuestionCase(resp, req []byte) []byte {
+	reqEnd, ok := questionNameEnd(req)
+	if !ok {
+		return resp
+	}
+	respEnd, ok := questionNameEnd(resp)
+	if !ok || respEnd != reqEnd {
+		return resp
+	}
+
+	want, got := req[dnsHeaderSize:reqEnd], resp[dnsHeaderSize:respEnd]
+	if string(want) == string(got) || !equalFoldASCII(want, got) {
+		return resp
+	}
+	out := make([]byte, len(resp))
+	copy(out, resp)
+	copy(out[dnsHeaderSize:], want)
+	return out
+}
+
+// dnsHeaderSize is the size of the fixed DNS message header.
+const dnsHeaderSize = 12
+
+// questionNameEnd returns the offset just past the first question's QNAME.
+// Compressed or malformed names are rejected.
+func questionNameEnd(msg []byte) (int, bool) {
+	if len(msg) < dnsHeaderSize || msg[4] == 0 && msg[5] == 0 {
+		return 0, false
+	}
+	off := dnsHeaderSize
+	for off < len(msg) {
+		n := int(msg[off])
+		switch {
+		case n == 0:
+			return off + 1, true
+		case n > 63:
+			return 0, false
+		}
+		off += 1 + n
+	}
+	return 0, false
+}
+
+// equalFoldASCII reports whether a and b are equal under ASCII case folding.
+func equalFoldASCII(a, b []byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		ca, cb := a[i], b[i]
+		if 'A' <= ca && ca <= 'Z' {
+			ca += 'a' - 'A'
+		}
+		if 'A' <= cb && cb <= 'Z' {
+			cb += 'a' - 'A'
+		}
+		if ca != cb {
+			return false
+		}
+	}
+	return true
+}
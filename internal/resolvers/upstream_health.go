@@ -0,0 +1,105 @@
+package resolvers
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jroosing/hydradns/internal/clock"
+)
+
+// upstreamHealth tracks upstreams in failure cooldown. An upstream that
+// fails is skipped for upstreamRecoveryDuration and then tried again; a
+// success clears its failure at once.
+type upstreamHealth struct {
+	mu       sync.Mutex
+	failedAt map[string]time.Time
+	clock    clock.Clock
+}
+
+// newUpstreamHealth returns a tracker with every upstream healthy.
+func newUpstreamHealth() *upstreamHealth {
+	return &upstreamHealth{failedAt: map[string]time.Time{}, clock: clock.System}
+}
+
+// canTry checks if an upstream is healthy or has recovered, clearing the
+// failure state of a recovered upstream.
+func (h *upstreamHealth) canTry(up string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	failedAt, ok := h.failedAt[up]
+	if !ok {
+		return true // never failed
+	}
+	if h.clock.Now().Sub(failedAt) >= upstreamRecoveryDuration {
+		delete(h.failedAt, up)
+		return true // recovered
+	}
+	return false // still in cooldown
+}
+
+// isHealthy reports whether up is outside its failure cooldown.
+// Unlike canTry, it does not clear expired failure state.
+func (h *upstreamHealth) isHealthy(up string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	failedAt, ok := h.failedAt[up]
+	return !ok || h.clock.Now().Sub(failedAt) >= upstreamRecoveryDuration
+}
+
+// candidates returns the upstreams in ups that can be tried, in order. If
+// all of them have failed, the failure state is cleared and all of ups is
+// returned, so resolution never stops for lack of an upstream.
+func (h *upstreamHealth) candidates(ups []string) []string {
+	out := make([]string, 0, len(ups))
+	for _, u := range ups {
+		if h.canTry(u) {
+			out = append(out, u)
+		}
+	}
+	if len(out) > 0 {
+		return out
+	}
+
+	// All upstreams have failed - clear state and retry from first
+	h.mu.Lock()
+	h.failedAt = map[string]time.Time{}
+	h.mu.Unlock()
+	return append(out, ups...)
+}
+
+// markFailed records the current time as the failure timestamp for an upstream.
+// Only marks failure once; subsequent failures don't update the timestamp.
+func (h *upstreamHealth) markFailed(up string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.failedAt[up]; !ok {
+		h.failedAt[up] = h.clock.Now()
+	}
+}
+
+// setClock sets the clock failure cooldowns are measured against.
+func (h *upstreamHealth) setClock(clk clock.Clock) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.clock = clk
+}
+
+// markHealthy clears the failure state for an upstream.
+func (h *upstreamHealth) markHealthy(up string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.failedAt, up)
+}
+
+// retain drops the failure state of upstreams not in keep.
+func (h *upstreamHealth) retain(keep map[string]struct{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for u := range h.failedAt {
+		if _, ok := keep[u]; !ok {
+			delete(h.failedAt, u)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package resolvers
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/jroosing/hydradns/internal/dns"
+)
+
+// errResponseMismatch is returned for an upstream response that does not
+// answer the query it should: wrong transaction ID or question.
+var errResponseMismatch = errors.New("response does not match the outstanding query")
+
+// outstandingQuery identifies the query a socket is waiting on.
+//
+// Pooled UDP sockets are reused, so a datagram read from one is not
+// necessarily the answer to the last query written to it: an upstream may
+// answer twice, answer after the previous user gave up, or an attacker may
+// race the real answer. A pooled socket carries one query at a time, so its
+// entry in the outstanding-query table is the transaction ID and question
+// of that query; datagrams matching neither are discarded, not returned.
+type outstandingQuery struct {
+	id    uint16
+	name  string
+	qtype uint16
+	class uint16
+}
+
+// newOutstandingQuery records the query in wire format req. A query without
+// a question matches on the transaction ID alone.
+func newOutstandingQuery(req []byte) outstandingQuery {
+	off := 0
+	h, err := dns.ParseHeader(req, &off)
+	if err != nil {
+		return outstandingQuery{}
+	}
+	q := outstandingQuery{id: h.ID}
+	if h.QDCount > 0 {
+		if question, err := dns.ParseQuestion(req, &off); err == nil {
+			q.name, q.qtype, q.class = question.Name, question.Type, question.Class
+		}
+	}
+	return q
+}
+
+// matches reports whether resp is a response to q: same transaction ID, QR
+// set, and the same question. Names compare case-insensitively, as servers
+// may echo the question in a different case.
+func (q outstandingQuery) matches(resp []byte) bool {
+	if len(resp) < dns.HeaderSize || binary.BigEndian.Uint16(resp) != q.id ||
+		binary.BigEndian.Uint16(resp[2:4])&dns.QRFlag == 0 {
+		return false
+	}
+	if q.name == "" && q.qtype == 0 {
+		return true
+	}
+	off := dns.HeaderSize
+	if binary.BigEndian.Uint16(resp[4:6]) == 0 {
+		// Some servers leave out the question in error responses; the
+		// ID alone has to do, and validateResponse rejects the answer
+		return true
+	}
+	question, err := dns.ParseQuestion(resp, &off)
+	if err != nil {
+		return false
+	}
+	return question.Type == q.qtype && question.Class == q.class && equalDNSNames(question.Name, q.name)
+}
@@ -0,0 +1,143 @@
+package resolvers
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// upstreamRTTWindow is the number of recent RTT samples kept per upstream
+// for percentile calculation.
+const upstreamRTTWindow = 256
+
+// UpstreamStatus is a point-in-time view of an upstream server's health.
+type UpstreamStatus struct {
+	Address             string        // Upstream server IP
+	Healthy             bool          // False while the upstream is in failure cooldown
+	Queries             uint64        // Total queries sent (successes + failures)
+	Successes           uint64        // Queries that returned a response
+	Failures            uint64        // Queries that timed out or errored
+	SuccessRate         float64       // Successes / Queries in [0,1]; 0 when no queries
+	ConsecutiveFailures uint64        // Failures since the last success
+	RTTP50              time.Duration // Median RTT over the recent window
+	RTTP95              time.Duration // 95th percentile RTT over the recent window
+	LastSuccess         time.Time     // Zero if never succeeded
+	LastFailure         time.Time     // Zero if never failed
+	LastError           string        // Most recent error message
+	MismatchedResponses uint64        // UDP datagrams dropped as not answering the outstanding query
+}
+
+// upstreamStats tracks rolling RTT and availability for one upstream.
+type upstreamStats struct {
+	mu                  sync.Mutex
+	rtts                []time.Duration // ring buffer of recent successful RTTs
+	next                int             // next write index in rtts
+	successes           uint64
+	failures            uint64
+	consecutiveFailures uint64
+	lastSuccess         time.Time
+	lastFailure         time.Time
+	lastError           string
+	mismatched          uint64
+}
+
+// recordSuccess records a successful query and its round-trip time.
+func (s *upstreamStats) recordSuccess(rtt time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.rtts) < upstreamRTTWindow {
+		s.rtts = append(s.rtts, rtt)
+	} else {
+		s.rtts[s.next] = rtt
+	}
+	s.next = (s.next + 1) % upstreamRTTWindow
+	s.successes++
+	s.consecutiveFailures = 0
+	s.lastSuccess = time.Now()
+}
+
+// recordFailure records a failed query.
+func (s *upstreamStats) recordFailure(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures++
+	s.consecutiveFailures++
+	s.lastFailure = time.Now()
+	if err != nil {
+		s.lastError = err.Error()
+	}
+}
+
+// recordMismatch records a datagram dropped because it did not answer the
+// query outstanding on its socket.
+func (s *upstreamStats) recordMismatch() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mismatched++
+}
+
+// snapshot returns the current statistics for addr.
+func (s *upstreamStats) snapshot(addr string) UpstreamStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := UpstreamStatus{
+		Address:             addr,
+		Queries:             s.successes + s.failures,
+		Successes:           s.successes,
+		Failures:            s.failures,
+		ConsecutiveFailures: s.consecutiveFailures,
+		LastSuccess:         s.lastSuccess,
+		LastFailure:         s.lastFailure,
+		LastError:           s.lastError,
+		MismatchedResponses: s.mismatched,
+	}
+	if st.Queries > 0 {
+		st.SuccessRate = float64(s.successes) / float64(st.Queries)
+	}
+	if len(s.rtts) > 0 {
+		sorted := slices.Clone(s.rtts)
+		slices.Sort(sorted)
+		st.RTTP50 = percentile(sorted, 50)
+		st.RTTP95 = percentile(sorted, 95)
+	}
+	return st
+}
+
+// percentile returns the p-th percentile (nearest-rank) of sorted samples.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100 // ceil(p/100 * n)
+	rank = max(rank, 1)
+	return sorted[rank-1]
+}
+
+// statsFor returns the stats tracker for an upstream, creating it on first use.
+func (f *ForwardingResolver) statsFor(up string) *upstreamStats {
+	f.statsMu.Lock()
+	defer f.statsMu.Unlock()
+
+	s, ok := f.upstreamStats[up]
+	if !ok {
+		s = &upstreamStats{}
+		f.upstreamStats[up] = s
+	}
+	return s
+}
+
+// UpstreamStatus returns per-upstream latency and availability statistics
+// for the currently configured upstreams, in failover order.
+func (f *ForwardingResolver) UpstreamStatus() []UpstreamStatus {
+	ups := f.upstreamList()
+	out := make([]UpstreamStatus, 0, len(ups))
+	for _, u := range ups {
+		st := f.statsFor(u).snapshot(u)
+		st.Healthy = f.health.isHealthy(u)
+		out = append(out, st)
+	}
+	return out
+}
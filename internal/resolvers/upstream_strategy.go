@@ -0,0 +1,134 @@
+package resolvers
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Upstream selection strategy names, as used in configuration.
+const (
+	StrategySequential = "sequential"  // Configured order; later upstreams only on failover
+	StrategyRoundRobin = "round_robin" // Rotate the first upstream tried on every query
+	StrategyLatency    = "latency"     // Fastest upstream by smoothed RTT first
+	StrategyRandom     = "random"      // Random order on every query
+)
+
+// UpstreamStrategy decides the order in which the forwarding resolver tries
+// upstream servers for a query. The resolver tries them in that order until
+// one answers, so failover is the tail of the order; upstreams in failure
+// cooldown are left out before the strategy sees the list.
+//
+// Implementations must be safe for concurrent use.
+type UpstreamStrategy interface {
+	// Order returns the upstreams to try for one query, most preferred
+	// first. candidates are the upstreams out of cooldown in configured
+	// priority order; the slice is never empty and must not be modified.
+	Order(candidates []string) []string
+
+	// Observe records the outcome of a query sent to up: its round-trip
+	// time on success, or the error.
+	Observe(up string, rtt time.Duration, err error)
+}
+
+// NewUpstreamStrategy returns the strategy with the given name. An empty
+// name selects sequential.
+func NewUpstreamStrategy(name string) (UpstreamStrategy, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", StrategySequential:
+		return SequentialStrategy{}, nil
+	case StrategyRoundRobin:
+		return &RoundRobinStrategy{}, nil
+	case StrategyLatency:
+		return NewLatencyStrategy(), nil
+	case StrategyRandom:
+		return RandomStrategy{}, nil
+	default:
+		return nil, fmt.Errorf("unknown upstream strategy %q", name)
+	}
+}
+
+// SequentialStrategy tries upstreams in configured order: the first healthy
+// upstream gets every query and the others are failover only.
+type SequentialStrategy struct{}
+
+// Order returns candidates unchanged.
+func (SequentialStrategy) Order(candidates []string) []string { return candidates }
+
+// Observe does nothing; the order does not depend on results.
+func (SequentialStrategy) Observe(string, time.Duration, error) {}
+
+// RoundRobinStrategy spreads queries evenly by starting each query at the
+// next upstream in turn and failing over through the rest in order.
+type RoundRobinStrategy struct {
+	next atomic.Uint64
+}
+
+// Order rotates candidates by one position per call.
+func (s *RoundRobinStrategy) Order(candidates []string) []string {
+	n := len(candidates)
+	start := int((s.next.Add(1) - 1) % uint64(n))
+	out := make([]string, 0, n)
+	out = append(out, candidates[start:]...)
+	return append(out, candidates[:start]...)
+}
+
+// Observe does nothing; the order does not depend on results.
+func (*RoundRobinStrategy) Observe(string, time.Duration, error) {}
+
+// RandomStrategy tries upstreams in a random order on every query.
+type RandomStrategy struct{}
+
+// Order returns a shuffled copy of candidates.
+func (RandomStrategy) Order(candidates []string) []string {
+	out := slices.Clone(candidates)
+	rand.Shuffle(len(out), func(i, j int) { out[i], out[j] = out[j], out[i] })
+	return out
+}
+
+// Observe does nothing; the order does not depend on results.
+func (RandomStrategy) Observe(string, time.Duration, error) {}
+
+// LatencyStrategy tries the upstream with the lowest smoothed RTT first.
+// Upstreams without a measurement yet come first, in configured order, so
+// each is measured. Failures are left to the failure cooldown.
+type LatencyStrategy struct {
+	mu   sync.Mutex
+	srtt map[string]time.Duration // Smoothed RTT per upstream
+}
+
+// NewLatencyStrategy returns a latency strategy with no measurements.
+func NewLatencyStrategy() *LatencyStrategy {
+	return &LatencyStrategy{srtt: map[string]time.Duration{}}
+}
+
+// Order returns candidates sorted by smoothed RTT, fastest first. Ties keep
+// the configured order.
+func (s *LatencyStrategy) Order(candidates []string) []string {
+	out := slices.Clone(candidates)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	slices.SortStableFunc(out, func(a, b string) int {
+		return int(s.srtt[a] - s.srtt[b])
+	})
+	return out
+}
+
+// Observe folds a successful query's RTT into the upstream's smoothed RTT
+// with a weight of 1/8, as TCP does (RFC 6298).
+func (s *LatencyStrategy) Observe(up string, rtt time.Duration, err error) {
+	if err != nil {
+		return
+	}
+	rtt = max(rtt, time.Microsecond) // zero means unmeasured
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if prev, ok := s.srtt[up]; ok {
+		rtt = prev + (rtt-prev)/8
+	}
+	s.srtt[up] = rtt
+}
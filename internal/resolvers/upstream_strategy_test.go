@@ -0,0 +1,206 @@
+package resolvers_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jroosing/hydradns/internal/resolvers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ============================================================================
+// NewUpstreamStrategy Tests
+// ============================================================================
+
+func TestNewUpstreamStrategy(t *testing.T) {
+	tests := []struct {
+		name string
+		want resolvers.UpstreamStrategy
+	}{
+		{"", resolvers.SequentialStrategy{}},
+		{"sequential", resolvers.SequentialStrategy{}},
+		{" Round_Robin ", &resolvers.RoundRobinStrategy{}},
+		{"latency", resolvers.NewLatencyStrategy()},
+		{"random", resolvers.RandomStrategy{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := resolvers.NewUpstreamStrategy(tt.name)
+			require.NoError(t, err)
+			assert.IsType(t, tt.want, s)
+		})
+	}
+}
+
+func TestNewUpstreamStrategy_Unknown(t *testing.T) {
+	_, err := resolvers.NewUpstreamStrategy("fastest")
+	assert.Error(t, err)
+}
+
+// ============================================================================
+// Strategy Order Tests
+// ============================================================================
+
+var strategyUpstreams = []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"}
+
+func TestSequentialStrategy_KeepsOrder(t *testing.T) {
+	s := resolvers.SequentialStrategy{}
+	for range 3 {
+		assert.Equal(t, strategyUpstreams, s.Order(strategyUpstreams))
+	}
+}
+
+func TestRoundRobinStrategy_Rotates(t *testing.T) {
+	s := &resolvers.RoundRobinStrategy{}
+
+	assert.Equal(t, []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"}, s.Order(strategyUpstreams))
+	assert.Equal(t, []string{"192.0.2.2", "192.0.2.3", "192.0.2.1"}, s.Order(strategyUpstreams))
+	assert.Equal(t, []string{"192.0.2.3", "192.0.2.1", "192.0.2.2"}, s.Order(strategyUpstreams))
+	assert.Equal(t, []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"}, s.Order(strategyUpstreams))
+	assert.Equal(t, []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"}, strategyUpstreams, "input must not be modified")
+}
+
+func TestRoundRobinStrategy_SpreadsEvenly(t *testing.T) {
+	s := &resolvers.RoundRobinStrategy{}
+	first := map[string]int{}
+	for range 300 {
+		first[s.Order(strategyUpstreams)[0]]++
+	}
+	for _, u := range strategyUpstreams {
+		assert.Equal(t, 100, first[u], u)
+	}
+}
+
+func TestRandomStrategy_ReturnsPermutation(t *testing.T) {
+	s := resolvers.RandomStrategy{}
+	first := map[string]int{}
+	for range 300 {
+		order := s.Order(strategyUpstreams)
+		assert.ElementsMatch(t, strategyUpstreams, order)
+		first[order[0]]++
+	}
+	assert.Len(t, first, 3, "every upstream should come first sometimes")
+	assert.Equal(t, []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"}, strategyUpstreams, "input must not be modified")
+}
+
+func TestLatencyStrategy_PrefersFastest(t *testing.T) {
+	s := resolvers.NewLatencyStrategy()
+	s.Observe("192.0.2.1", 80*time.Millisecond, nil)
+	s.Observe("192.0.2.2", 10*time.Millisecond, nil)
+	s.Observe("192.0.2.3", 40*time.Millisecond, nil)
+
+	assert.Equal(t, []string{"192.0.2.2", "192.0.2.3", "192.0.2.1"}, s.Order(strategyUpstreams))
+}
+
+func TestLatencyStrategy_TriesUnmeasuredFirst(t *testing.T) {
+	s := resolvers.NewLatencyStrategy()
+	s.Observe("192.0.2.1", 10*time.Millisecond, nil)
+
+	assert.Equal(t, []string{"192.0.2.2", "192.0.2.3", "192.0.2.1"}, s.Order(strategyUpstreams))
+}
+
+func TestLatencyStrategy_IgnoresErrors(t *testing.T) {
+	s := resolvers.NewLatencyStrategy()
+	s.Observe("192.0.2.1", 10*time.Millisecond, nil)
+	s.Observe("192.0.2.2", 20*time.Millisecond, nil)
+	s.Observe("192.0.2.1", 0, errors.New("timeout"))
+
+	assert.Equal(t, []string{"192.0.2.1", "192.0.2.2"}, s.Order(strategyUpstreams[:2]))
+}
+
+func TestLatencyStrategy_Smooths(t *testing.T) {
+	s := resolvers.NewLatencyStrategy()
+	s.Observe("192.0.2.1", 10*time.Millisecond, nil)
+	s.Observe("192.0.2.2", 20*time.Millisecond, nil)
+
+	// One slow answer moves the average by an eighth of the difference
+	s.Observe("192.0.2.1", 170*time.Millisecond, nil)
+	assert.Equal(t, []string{"192.0.2.2", "192.0.2.1"}, s.Order(strategyUpstreams[:2]))
+
+	// Fast answers bring it back down
+	for range 10 {
+		s.Observe("192.0.2.1", 10*time.Millisecond, nil)
+	}
+	assert.Equal(t, []string{"192.0.2.1", "192.0.2.2"}, s.Order(strategyUpstreams[:2]))
+}
+
+// ============================================================================
+// ForwardingResolver Strategy Tests
+// ============================================================================
+
+// recordingStrategy tries upstreams in reverse order and records outcomes.
+type recordingStrategy struct {
+	observed atomic.Int32
+	failed   atomic.Int32
+}
+
+func (s *recordingStrategy) Order(candidates []string) []string {
+	out := make([]string, 0, len(candidates))
+	for i := len(candidates) - 1; i >= 0; i-- {
+		out = append(out, candidates[i])
+	}
+	return out
+}
+
+func (s *recordingStrategy) Observe(_ string, _ time.Duration, err error) {
+	s.observed.Add(1)
+	if err != nil {
+		s.failed.Add(1)
+	}
+}
+
+func TestForwardingResolver_UsesUpstreamStrategy(t *testing.T) {
+	startFakeUpstream(t, 0)
+
+	// The unroutable upstream comes first in configured order, but the
+	// strategy tries the working one first, so no failover happens
+	f := resolvers.NewForwardingResolver([]string{"192.0.2.1", fakeUpstreamAddr}, 1, 0, false, time.Second, 0, 1)
+	t.Cleanup(func() { _ = f.Close() })
+	s := &recordingStrategy{}
+	f.SetUpstreamStrategy(s)
+
+	req, reqBytes := newAQuery(t, 7, "example.com")
+	res, err := f.Resolve(context.Background(), req, reqBytes)
+	require.NoError(t, err)
+	assert.Equal(t, "upstream", res.Source)
+
+	assert.Equal(t, int32(1), s.observed.Load())
+	assert.Zero(t, s.failed.Load())
+
+	status := f.UpstreamStatus()
+	require.Len(t, status, 2)
+	assert.Zero(t, status[0].Queries, "unroutable upstream should not be tried")
+	assert.Equal(t, uint64(1), status[1].Queries)
+}
+
+func TestForwardingResolver_StrategyFailover(t *testing.T) {
+	startFakeUpstream(t, 0)
+
+	f := resolvers.NewForwardingResolver([]string{fakeUpstreamAddr, "192.0.2.1"}, 1, 0, false, 50*time.Millisecond, 0, 1)
+	t.Cleanup(func() { _ = f.Close() })
+	s := &recordingStrategy{}
+	f.SetUpstreamStrategy(s)
+
+	req, reqBytes := newAQuery(t, 8, "example.com")
+	res, err := f.Resolve(context.Background(), req, reqBytes)
+	require.NoError(t, err)
+	assert.Equal(t, "upstream", res.Source)
+
+	assert.Equal(t, int32(2), s.observed.Load())
+	assert.Equal(t, int32(1), s.failed.Load())
+
+	status := f.UpstreamStatus()
+	require.Len(t, status, 2)
+	assert.True(t, status[0].Healthy)
+	assert.False(t, status[1].Healthy, "failed upstream should be in cooldown")
+
+	// The cache is keyed on the first healthy upstream, so the answer is
+	// shared whichever upstream the strategy picked
+	res, err = f.Resolve(context.Background(), req, reqBytes)
+	require.NoError(t, err)
+	assert.Equal(t, "upstream-cache", res.Source)
+}
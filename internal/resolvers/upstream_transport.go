@@ -0,0 +1,200 @@
+package resolvers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jroosing/hydradns/internal/dns"
+)
+
+// dohMediaType is the content type of DNS messages over HTTPS (RFC 8484).
+const dohMediaType = "application/dns-message"
+
+// errNoDoHServers is returned for names forced over DoH when no DoH server
+// is configured.
+var errNoDoHServers = errors.New("no DoH servers configured")
+
+// Transport is how queries are sent upstream.
+type Transport int
+
+const (
+	// TransportDefault uses UDP, retrying over TCP when the answer is
+	// truncated and TCP fallback is enabled.
+	TransportDefault Transport = iota
+	// TransportTCP queries the upstream servers over TCP only.
+	TransportTCP
+	// TransportDoH queries the DoH servers only.
+	TransportDoH
+)
+
+// ParseTransport parses "tcp" or "doh".
+func ParseTransport(s string) (Transport, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "tcp":
+		return TransportTCP, nil
+	case "doh":
+		return TransportDoH, nil
+	default:
+		return TransportDefault, fmt.Errorf("unknown transport %q", s)
+	}
+}
+
+// String returns "udp", "tcp", or "doh".
+func (t Transport) String() string {
+	switch t {
+	case TransportTCP:
+		return "tcp"
+	case TransportDoH:
+		return "doh"
+	default:
+		return "udp"
+	}
+}
+
+// TransportRules forces the transport for zones, e.g. DoH for banking
+// domains while the other upstreams are plain UDP.
+//
+// A zone applies to itself and all names below it; the most specific zone
+// wins. A nil *TransportRules forces nothing.
+type TransportRules struct {
+	zones map[string]Transport
+}
+
+// NewTransportRules returns rules for the transport of each zone. Returns
+// nil when zones is empty.
+func NewTransportRules(zones map[string]Transport) *TransportRules {
+	if len(zones) == 0 {
+		return nil
+	}
+	r := &TransportRules{zones: make(map[string]Transport, len(zones))}
+	for zone, t := range zones {
+		if zone = dns.NormalizeName(strings.TrimSpace(zone)); zone != "" {
+			r.zones[zone] = t
+		}
+	}
+	return r
+}
+
+// Match returns the transport forced for qname, or TransportDefault.
+func (r *TransportRules) Match(qname string) Transport {
+	if r == nil || len(r.zones) == 0 {
+		return TransportDefault
+	}
+	name := dns.NormalizeName(qname)
+	for name != "" {
+		if t, ok := r.zones[name]; ok {
+			return t
+		}
+		i := strings.IndexByte(name, '.')
+		if i < 0 {
+			break
+		}
+		name = name[i+1:]
+	}
+	return TransportDefault
+}
+
+// SetTransportRules sets the transports forced per zone. Names forced over
+// TCP use the upstream servers; names forced over DoH use the servers set
+// with SetDoHServers. Neither falls back to plain UDP. Must be called
+// before the resolver starts serving queries.
+func (f *ForwardingResolver) SetTransportRules(r *TransportRules) {
+	f.transportRules = r
+}
+
+// SetDoHServers sets the DNS-over-HTTPS server URLs, tried in order. A nil
+// client selects one that resolves the DoH server names through the plain
+// upstream servers, so the server does not depend on itself to find them.
+// Must be called before the resolver starts serving queries.
+func (f *ForwardingResolver) SetDoHServers(urls []string, client *http.Client) {
+	if client == nil {
+		client = f.newDoHClient()
+	}
+	f.dohServers = append([]string(nil), urls...)
+	f.dohClient = client
+}
+
+// newDoHClient returns an HTTP client that looks up DoH server names at the
+// first healthy upstream server.
+func (f *ForwardingResolver) newDoHClient() *http.Client {
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return f.dialer.DialContext(ctx, network, net.JoinHostPort(f.selectUpstream(), "53"))
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Resolver: resolver}).DialContext
+	transport.MaxIdleConnsPerHost = 16
+	return &http.Client{Transport: transport}
+}
+
+// queryDoH sends a query to the DoH servers in order, failing over to the
+// next on error. It returns the server that answered.
+func (f *ForwardingResolver) queryDoH(ctx context.Context, req []byte) ([]byte, string, error) {
+	if len(f.dohServers) == 0 {
+		return nil, "", errNoDoHServers
+	}
+	var lastErr error
+	for _, u := range f.dohServers {
+		if ctx.Err() != nil {
+			return nil, "", ctx.Err()
+		}
+		f.audit(req, u, "doh")
+		resp, err := queryUpstreamDoH(ctx, f.dohClient, u, req, f.tcpTimeout)
+		if err == nil {
+			return resp, u, nil
+		}
+		lastErr = err
+	}
+	return nil, "", lastErr
+}
+
+// queryUpstreamDoH sends a DNS query to a DoH server as an RFC 8484 POST
+// request. The query's transaction ID is left at 0, as RFC 8484 recommends
+// for cache friendliness; TLS, not the ID, protects the answer.
+func queryUpstreamDoH(ctx context.Context, client *http.Client, url string, req []byte, timeout time.Duration) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(req))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", dohMediaType)
+	httpReq.Header.Set("Accept", dohMediaType)
+
+	httpResp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DoH server %s: %s", url, httpResp.Status)
+	}
+	if mt, _, _ := mime.ParseMediaType(httpResp.Header.Get("Content-Type")); mt != dohMediaType {
+		return nil, fmt.Errorf("DoH server %s: unexpected content type %q", url, mt)
+	}
+
+	// A DNS message is at most 65535 bytes; read one more to detect overflow
+	resp, err := io.ReadAll(io.LimitReader(httpResp.Body, 65536))
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) < dns.HeaderSize || len(resp) > 65535 {
+		return nil, fmt.Errorf("DoH server %s: response length invalid: %d", url, len(resp))
+	}
+	if !newOutstandingQuery(req).matches(resp) {
+		return nil, fmt.Errorf("DoH server %s: %w", url, errResponseMismatch)
+	}
+	return resp, nil
+}
@@ -0,0 +1,252 @@
+package resolvers_test
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jroosing/hydradns/internal/dns"
+	"github.com/jroosing/hydradns/internal/helpers"
+	"github.com/jroosing/hydradns/internal/resolvers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ============================================================================
+// TransportRules Tests
+// ============================================================================
+
+func TestTransportRules_Match(t *testing.T) {
+	rules := resolvers.NewTransportRules(map[string]resolvers.Transport{
+		"Bank.Example.":  resolvers.TransportDoH,
+		"corp.example":   resolvers.TransportTCP,
+		"a.corp.example": resolvers.TransportDoH,
+	})
+
+	tests := map[string]resolvers.Transport{
+		"bank.example":       resolvers.TransportDoH,
+		"WWW.bank.example":   resolvers.TransportDoH,
+		"corp.example":       resolvers.TransportTCP,
+		"x.a.corp.example":   resolvers.TransportDoH,
+		"b.corp.example":     resolvers.TransportTCP,
+		"notbank.example":    resolvers.TransportDefault,
+		"example":            resolvers.TransportDefault,
+		"bank.example.other": resolvers.TransportDefault,
+	}
+	for name, want := range tests {
+		assert.Equal(t, want, rules.Match(name), name)
+	}
+
+	var none *resolvers.TransportRules
+	assert.Equal(t, resolvers.TransportDefault, none.Match("bank.example"))
+	assert.Nil(t, resolvers.NewTransportRules(nil))
+}
+
+func TestParseTransport(t *testing.T) {
+	tr, err := resolvers.ParseTransport(" DoH ")
+	require.NoError(t, err)
+	assert.Equal(t, resolvers.TransportDoH, tr)
+	assert.Equal(t, "doh", tr.String())
+
+	tr, err = resolvers.ParseTransport("tcp")
+	require.NoError(t, err)
+	assert.Equal(t, resolvers.TransportTCP, tr)
+
+	_, err = resolvers.ParseTransport("udp")
+	assert.Error(t, err)
+}
+
+// ============================================================================
+// Forced Transport Tests
+// ============================================================================
+
+// dohAnswer is the address the fake DoH server answers with.
+var dohAnswer = net.IPv4(198, 51, 100, 7)
+
+// startFakeDoH serves RFC 8484 POST requests, answering A queries with
+// dohAnswer, and returns the server and a counter of queries received.
+// While fail is set, it answers 502 instead.
+func startFakeDoH(t *testing.T, fail *atomic.Bool) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var queries atomic.Int32
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries.Add(1)
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/dns-message" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if fail != nil && fail.Load() {
+			http.Error(w, "upstream down", http.StatusBadGateway)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req, err := dns.ParsePacket(body)
+		if err != nil || len(req.Questions) == 0 {
+			http.Error(w, "malformed query", http.StatusBadRequest)
+			return
+		}
+		resp := req
+		resp.Header.Flags |= dns.QRFlag
+		resp.Answers = []dns.Record{dns.NewIPRecord(
+			dns.RRHeader{Name: req.Questions[0].Name, Class: uint16(dns.ClassIN), TTL: 60},
+			dohAnswer,
+		)}
+		b, err := resp.Marshal()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/dns-message")
+		_, _ = w.Write(b)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &queries
+}
+
+// startFakeTCPUpstream serves A answers over TCP on the fake upstream
+// address and returns a counter of queries received. The test is skipped
+// if port 53 cannot be bound.
+func startFakeTCPUpstream(t *testing.T) *atomic.Int32 {
+	t.Helper()
+	ln, err := net.Listen("tcp", net.JoinHostPort(fakeUpstreamAddr, "53"))
+	if err != nil {
+		t.Skipf("cannot bind fake TCP upstream: %v", err)
+	}
+
+	var queries atomic.Int32
+	var wg sync.WaitGroup
+	t.Cleanup(func() {
+		_ = ln.Close()
+		wg.Wait()
+	})
+
+	wg.Go(func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			wg.Go(func() {
+				defer conn.Close()
+				var prefix [2]byte
+				if _, err := io.ReadFull(conn, prefix[:]); err != nil {
+					return
+				}
+				msg := make([]byte, binary.BigEndian.Uint16(prefix[:]))
+				if _, err := io.ReadFull(conn, msg); err != nil {
+					return
+				}
+				queries.Add(1)
+				req, err := dns.ParsePacket(msg)
+				if err != nil || len(req.Questions) == 0 {
+					return
+				}
+				resp := req
+				resp.Header.Flags |= dns.QRFlag
+				resp.Answers = []dns.Record{dns.NewIPRecord(
+					dns.RRHeader{Name: req.Questions[0].Name, Class: uint16(dns.ClassIN), TTL: 60},
+					net.IPv4(192, 0, 2, 10),
+				)}
+				b, err := resp.Marshal()
+				if err != nil {
+					return
+				}
+				binary.BigEndian.PutUint16(prefix[:], helpers.ClampIntToUint16(len(b)))
+				_, _ = conn.Write(append(prefix[:], b...))
+			})
+		}
+	})
+	return &queries
+}
+
+func TestForwardingResolver_ForcedDoH(t *testing.T) {
+	udpQueries := startFakeUpstream(t, 0)
+	var fail atomic.Bool
+	srv, dohQueries := startFakeDoH(t, &fail)
+
+	f := resolvers.NewForwardingResolver([]string{fakeUpstreamAddr}, 1, 0, false, time.Second, time.Second, 1)
+	t.Cleanup(func() { _ = f.Close() })
+	f.SetDoHServers([]string{srv.URL + "/dns-query"}, srv.Client())
+	f.SetTransportRules(resolvers.NewTransportRules(map[string]resolvers.Transport{
+		"bank.example": resolvers.TransportDoH,
+	}))
+
+	req, b := newAQuery(t, 0x1111, "www.bank.example")
+	res, err := f.Resolve(context.Background(), req, b)
+	require.NoError(t, err)
+	resp, err := dns.ParsePacket(res.ResponseBytes)
+	require.NoError(t, err)
+	assert.Equal(t, uint16(0x1111), resp.Header.ID)
+	require.Len(t, resp.Answers, 1)
+	ip, ok := resp.Answers[0].(*dns.IPRecord)
+	require.True(t, ok)
+	assert.Equal(t, dohAnswer.String(), ip.Addr.String())
+	assert.Equal(t, int32(1), dohQueries.Load())
+	assert.Equal(t, int32(0), udpQueries.Load(), "forced names never use plain UDP")
+
+	// Other names keep using the plain upstreams
+	req, b = newAQuery(t, 0x2222, "other.example")
+	_, err = f.Resolve(context.Background(), req, b)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), udpQueries.Load())
+	assert.Equal(t, int32(1), dohQueries.Load())
+
+	// A failing DoH server is an error, not a fallback to UDP
+	fail.Store(true)
+	req, b = newAQuery(t, 0x3333, "login.bank.example")
+	_, err = f.Resolve(context.Background(), req, b)
+	require.Error(t, err)
+	assert.Equal(t, int32(1), udpQueries.Load())
+}
+
+func TestForwardingResolver_ForcedDoHWithoutServers(t *testing.T) {
+	udpQueries := startFakeUpstream(t, 0)
+	f := resolvers.NewForwardingResolver([]string{fakeUpstreamAddr}, 1, 0, false, time.Second, time.Second, 1)
+	t.Cleanup(func() { _ = f.Close() })
+	f.SetTransportRules(resolvers.NewTransportRules(map[string]resolvers.Transport{
+		"bank.example": resolvers.TransportDoH,
+	}))
+
+	req, b := newAQuery(t, 1, "bank.example")
+	_, err := f.Resolve(context.Background(), req, b)
+	require.Error(t, err)
+	assert.Equal(t, int32(0), udpQueries.Load())
+}
+
+func TestForwardingResolver_ForcedTCP(t *testing.T) {
+	udpQueries := startFakeUpstream(t, 0)
+	tcpQueries := startFakeTCPUpstream(t)
+
+	f := resolvers.NewForwardingResolver([]string{fakeUpstreamAddr}, 1, 0, false, time.Second, time.Second, 1)
+	t.Cleanup(func() { _ = f.Close() })
+	f.SetTransportRules(resolvers.NewTransportRules(map[string]resolvers.Transport{
+		"corp.example": resolvers.TransportTCP,
+	}))
+
+	req, b := newAQuery(t, 0x4444, "intranet.corp.example")
+	res, err := f.Resolve(context.Background(), req, b)
+	require.NoError(t, err)
+	resp, err := dns.ParsePacket(res.ResponseBytes)
+	require.NoError(t, err)
+	assert.Equal(t, uint16(0x4444), resp.Header.ID)
+	assert.Len(t, resp.Answers, 1)
+	assert.Equal(t, int32(1), tcpQueries.Load())
+	assert.Equal(t, int32(0), udpQueries.Load())
+
+	req, b = newAQuery(t, 0x5555, "other.example")
+	_, err = f.Resolve(context.Background(), req, b)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), tcpQueries.Load())
+	assert.Equal(t, int32(1), udpQueries.Load())
+}
@@ -0,0 +1,378 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AdvertiseSignatureHeader carries the HMAC-SHA256 of an advertisement
+// webhook body, as "sha256=<hex>", when a webhook secret is configured.
+const AdvertiseSignatureHeader = "X-HydraDNS-Signature"
+
+// advertiseTimeout bounds each webhook request.
+const advertiseTimeout = 10 * time.Second
+
+// AdvertiseSettings contains DNS advertisement settings (see
+// config.AdvertiseConfig).
+type AdvertiseSettings struct {
+	WebhookURL    string
+	WebhookSecret string
+	Interfaces    []string // Interfaces advertised; empty for all
+	Interval      time.Duration
+	// Host and Port are the main listening address. A wildcard host
+	// advertises every address; 0.0.0.0 only IPv4 ones.
+	Host string
+	Port int
+	Node string // Names this server in webhook requests; optional
+}
+
+// AdvertisedInterface lists the addresses of one network interface, sorted.
+type AdvertisedInterface struct {
+	Name string
+	IPv4 []netip.Addr
+	IPv6 []netip.Addr
+}
+
+// InterfaceLookup lists the network interfaces that are up with their
+// addresses.
+type InterfaceLookup func() ([]AdvertisedInterface, error)
+
+// AdvertiseStatus reports the advertised addresses and the last webhook
+// delivery.
+type AdvertiseStatus struct {
+	Port       int
+	Interfaces []AdvertisedInterface // Current listening addresses
+	Changed    time.Time             // When the addresses last changed
+	// Delivered is set once the webhook accepted the current addresses;
+	// until then, delivery is retried every interval.
+	Delivered   bool
+	LastAttempt time.Time
+	LastSuccess time.Time
+	LastError   string // Error of the last attempt; empty if it succeeded
+}
+
+// Advertiser tells the network's router or DHCP server which addresses
+// HydraDNS listens on, so it can hand them out as DNS servers (DHCP option
+// 6, DHCPv6 option 23). Every interval it lists the listening addresses
+// and, when they differ from the last ones delivered, POSTs them to the
+// webhook as JSON. Loopback and link-local addresses are never advertised.
+//
+// Status is safe for concurrent use; Run and Check must not run
+// concurrently.
+type Advertiser struct {
+	settings AdvertiseSettings
+	host     netip.Addr // Invalid for a wildcard host
+	lookup   InterfaceLookup
+	client   *http.Client
+	logger   *slog.Logger
+
+	sent []AdvertisedInterface // Addresses the webhook last accepted
+
+	mu     sync.Mutex
+	status AdvertiseStatus
+}
+
+// NewAdvertiser creates an advertiser that lists addresses with
+// SystemInterfaces.
+func NewAdvertiser(s AdvertiseSettings, logger *slog.Logger) *Advertiser {
+	if s.Interval <= 0 {
+		s.Interval = 30 * time.Second
+	}
+	a := &Advertiser{
+		settings: s,
+		lookup:   SystemInterfaces,
+		client: &http.Client{
+			Timeout: advertiseTimeout,
+			// A redirect would turn the POST into a GET; report it instead
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		logger: logger,
+	}
+	if host, err := netip.ParseAddr(s.Host); err == nil && !host.IsUnspecified() {
+		a.host = host.Unmap()
+	}
+	a.status.Port = s.Port
+	return a
+}
+
+// SetLookup replaces the interface lookup. It must be called before Run.
+func (a *Advertiser) SetLookup(lookup InterfaceLookup) {
+	a.lookup = lookup
+}
+
+// Run checks at once and then every interval until ctx is canceled.
+func (a *Advertiser) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.settings.Interval)
+	defer ticker.Stop()
+
+	for {
+		a.Check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check lists the listening addresses and delivers them to the webhook if
+// the webhook does not have them yet.
+func (a *Advertiser) Check(ctx context.Context) {
+	all, err := a.lookup()
+	if err != nil {
+		if a.logger != nil {
+			a.logger.WarnContext(ctx, "failed to list network interfaces", "err", err)
+		}
+		return
+	}
+	current := a.listening(all)
+
+	a.mu.Lock()
+	changed := a.status.Changed.IsZero() || !slices.EqualFunc(current, a.status.Interfaces, equalInterface)
+	if changed {
+		a.status.Interfaces = current
+		a.status.Changed = time.Now()
+		a.status.Delivered = slices.EqualFunc(current, a.sent, equalInterface) && a.sent != nil
+	}
+	delivered := a.status.Delivered
+	a.mu.Unlock()
+
+	if changed && a.logger != nil {
+		if len(current) == 0 {
+			a.logger.WarnContext(ctx, "no dns listening addresses to advertise")
+		} else {
+			a.logger.InfoContext(ctx, "dns listening addresses", "addresses", formatInterfaces(current))
+		}
+	}
+	// Withdrawing every address would leave clients without DNS; wait for
+	// one to come up instead
+	if delivered || len(current) == 0 {
+		return
+	}
+
+	err = a.deliver(ctx, current)
+	if ctx.Err() != nil {
+		return // shutting down; the next start delivers again
+	}
+
+	a.mu.Lock()
+	hadError := a.status.LastError != ""
+	a.status.LastAttempt = time.Now()
+	if err == nil {
+		a.sent = current
+		a.status.Delivered = true
+		a.status.LastSuccess = a.status.LastAttempt
+		a.status.LastError = ""
+	} else {
+		a.status.LastError = err.Error()
+	}
+	a.mu.Unlock()
+
+	if a.logger == nil {
+		return
+	}
+	switch {
+	case err == nil:
+		a.logger.InfoContext(ctx, "dns addresses advertised", "webhook", a.settings.WebhookURL)
+	case hadError:
+		a.logger.DebugContext(ctx, "dns address advertisement still failing", "err", err)
+	default:
+		a.logger.WarnContext(ctx, "failed to advertise dns addresses; retrying every interval",
+			"webhook", a.settings.WebhookURL,
+			"interval", a.settings.Interval.String(),
+			"err", err,
+		)
+	}
+}
+
+// listening returns the addresses of all that the main listeners accept
+// queries on and that may be advertised, leaving out interfaces without
+// any.
+func (a *Advertiser) listening(all []AdvertisedInterface) []AdvertisedInterface {
+	var out []AdvertisedInterface
+	for _, iface := range all {
+		if len(a.settings.Interfaces) > 0 && !slices.Contains(a.settings.Interfaces, iface.Name) {
+			continue
+		}
+		v4 := slices.DeleteFunc(slices.Clone(iface.IPv4), func(ip netip.Addr) bool { return !a.advertisable(ip) })
+		v6 := slices.DeleteFunc(slices.Clone(iface.IPv6), func(ip netip.Addr) bool { return !a.advertisable(ip) })
+		if len(v4) == 0 && len(v6) == 0 {
+			continue
+		}
+		slices.SortFunc(v4, netip.Addr.Compare)
+		slices.SortFunc(v6, netip.Addr.Compare)
+		out = append(out, AdvertisedInterface{Name: iface.Name, IPv4: slices.Compact(v4), IPv6: slices.Compact(v6)})
+	}
+	slices.SortFunc(out, func(x, y AdvertisedInterface) int { return strings.Compare(x.Name, y.Name) })
+	return out
+}
+
+// advertisable reports whether clients can reach the main listeners at ip.
+func (a *Advertiser) advertisable(ip netip.Addr) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() || ip.IsMulticast() {
+		return false
+	}
+	if a.host.IsValid() {
+		return ip == a.host
+	}
+	// Go binds 0.0.0.0 to IPv4 only, and "" and :: to both families
+	return ip.Is4() || a.settings.Host != "0.0.0.0"
+}
+
+// advertisePayload is the webhook request body.
+type advertisePayload struct {
+	Node       string               `json:"node,omitempty"`
+	Port       int                  `json:"port"`
+	Interfaces []advertiseInterface `json:"interfaces"`
+	// Previous are the addresses the webhook last accepted, empty on the
+	// first delivery after startup
+	Previous []advertiseInterface `json:"previous"`
+	Time     time.Time            `json:"time"`
+}
+
+// advertiseInterface is an interface in the webhook request body. IPv4
+// addresses are for DHCP option 6, IPv6 ones for DHCPv6 option 23 and
+// router advertisement RDNSS.
+type advertiseInterface struct {
+	Name string   `json:"name"`
+	IPv4 []string `json:"ipv4"`
+	IPv6 []string `json:"ipv6"`
+}
+
+// deliver POSTs the addresses to the webhook.
+func (a *Advertiser) deliver(ctx context.Context, current []AdvertisedInterface) error {
+	body, err := json.Marshal(advertisePayload{
+		Node:       a.settings.Node,
+		Port:       a.settings.Port,
+		Interfaces: payloadInterfaces(current),
+		Previous:   payloadInterfaces(a.sent),
+		Time:       time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.settings.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "HydraDNS-advertise")
+	if a.settings.WebhookSecret != "" {
+		mac := hmac.New(sha256.New, []byte(a.settings.WebhookSecret))
+		mac.Write(body)
+		req.Header.Set(AdvertiseSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// payloadInterfaces converts interfaces to their webhook form, with empty
+// lists rather than nulls.
+func payloadInterfaces(ifaces []AdvertisedInterface) []advertiseInterface {
+	out := make([]advertiseInterface, 0, len(ifaces))
+	for _, iface := range ifaces {
+		p := advertiseInterface{
+			Name: iface.Name,
+			IPv4: make([]string, 0, len(iface.IPv4)),
+			IPv6: make([]string, 0, len(iface.IPv6)),
+		}
+		for _, ip := range iface.IPv4 {
+			p.IPv4 = append(p.IPv4, ip.String())
+		}
+		for _, ip := range iface.IPv6 {
+			p.IPv6 = append(p.IPv6, ip.String())
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// formatInterfaces renders interfaces for logging as
+// "eth0=192.0.2.1,2001:db8::1 wlan0=...".
+func formatInterfaces(ifaces []AdvertisedInterface) string {
+	parts := make([]string, 0, len(ifaces))
+	for _, iface := range ifaces {
+		addrs := make([]string, 0, len(iface.IPv4)+len(iface.IPv6))
+		for _, ip := range slices.Concat(iface.IPv4, iface.IPv6) {
+			addrs = append(addrs, ip.String())
+		}
+		parts = append(parts, iface.Name+"="+strings.Join(addrs, ","))
+	}
+	return strings.Join(parts, " ")
+}
+
+// equalInterface reports whether x and y have the same name and addresses.
+func equalInterface(x, y AdvertisedInterface) bool {
+	return x.Name == y.Name && slices.Equal(x.IPv4, y.IPv4) && slices.Equal(x.IPv6, y.IPv6)
+}
+
+// Status returns the advertised addresses and the last delivery.
+func (a *Advertiser) Status() AdvertiseStatus {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	s := a.status
+	s.Interfaces = slices.Clone(s.Interfaces)
+	return s
+}
+
+// SystemInterfaces lists the network interfaces of this host that are up,
+// with their addresses.
+func SystemInterfaces() ([]AdvertisedInterface, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	var out []AdvertisedInterface
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", iface.Name, err)
+		}
+		ai := AdvertisedInterface{Name: iface.Name}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			ip, ok := netip.AddrFromSlice(ipNet.IP)
+			if !ok {
+				continue
+			}
+			if ip = ip.Unmap(); ip.Is4() {
+				ai.IPv4 = append(ai.IPv4, ip)
+			} else {
+				ai.IPv6 = append(ai.IPv6, ip)
+			}
+		}
+		out = append(out, ai)
+	}
+	return out, nil
+}
@@ -0,0 +1,284 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jroosing/hydradns/internal/dns"
+)
+
+// AnomalyKind names a suspicious query pattern.
+type AnomalyKind string
+
+const (
+	// AnomalyNXDOMAINRate is a client whose share of NXDOMAIN answers is
+	// abnormally high, e.g. a tunnel or DGA malware probing random names.
+	AnomalyNXDOMAINRate AnomalyKind = "nxdomain_rate"
+	// AnomalyTXTRate is a client sending an abnormal share of TXT and NULL
+	// queries, the record types tunnels use to carry data downstream.
+	AnomalyTXTRate AnomalyKind = "txt_rate"
+	// AnomalyLongLabel is a query name with an overly long label.
+	AnomalyLongLabel AnomalyKind = "long_label"
+	// AnomalyHighEntropy is a query name with a long, random-looking label,
+	// typical of encoded payloads.
+	AnomalyHighEntropy AnomalyKind = "high_entropy"
+)
+
+// AnomalyKinds lists every anomaly kind, in report order.
+var AnomalyKinds = []AnomalyKind{AnomalyNXDOMAINRate, AnomalyTXTRate, AnomalyLongLabel, AnomalyHighEntropy}
+
+// Anomaly is an alert raised for a client.
+type Anomaly struct {
+	Time   time.Time
+	Client string
+	Kind   AnomalyKind
+	Name   string // Query name that raised the alert
+	Detail string // Human-readable measurement, e.g. "label length 60"
+}
+
+// AnomalySettings contains anomaly detection thresholds (see
+// config.AnomalyConfig).
+type AnomalySettings struct {
+	Window          time.Duration
+	MinQueries      int
+	NXDOMAINRatio   float64
+	TXTRatio        float64
+	MaxLabelLength  int
+	MinLabelEntropy float64
+}
+
+const (
+	// anomalyMaxClients bounds the number of clients tracked at once.
+	anomalyMaxClients = 16384
+	// anomalyRecent is the number of recent alerts kept for the API.
+	anomalyRecent = 100
+	// entropyMinLabelLen is the shortest label whose entropy is judged;
+	// shorter labels cannot reach a meaningful entropy.
+	entropyMinLabelLen = 24
+)
+
+// AnomalyDetector flags clients showing DNS tunneling indicators: high
+// NXDOMAIN or TXT/NULL query ratios within a window, and long or
+// high-entropy labels.
+//
+// Alerts are rate limited to one per client and kind per window. All methods
+// are safe for concurrent use.
+type AnomalyDetector struct {
+	settings AnomalySettings
+	privacy  *Privacy // Redacts recorded alerts; nil keeps them whole
+
+	mu      sync.Mutex
+	clients map[string]*anomalyClient
+	counts  map[AnomalyKind]uint64
+	recent  []Anomaly // ring buffer of the latest alerts
+	next    int       // next write index in recent
+}
+
+// anomalyClient is one client's counters for the current window.
+type anomalyClient struct {
+	start     time.Time
+	queries   int
+	nxdomain  int
+	txt       int
+	lastAlert map[AnomalyKind]time.Time
+}
+
+// NewAnomalyDetector creates a detector with the given thresholds.
+func NewAnomalyDetector(s AnomalySettings) *AnomalyDetector {
+	return &AnomalyDetector{
+		settings: s,
+		clients:  make(map[string]*anomalyClient),
+		counts:   make(map[AnomalyKind]uint64),
+	}
+}
+
+// SetPrivacy makes the detector record and return alerts redacted by p.
+// Clients are still tracked by their full address. Must be called before
+// the detector observes queries.
+func (d *AnomalyDetector) SetPrivacy(p *Privacy) {
+	d.privacy = p
+}
+
+// Redact redacts the recorded alerts again at the current privacy level,
+// e.g. after the level was raised.
+func (d *AnomalyDetector) Redact() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, a := range d.recent {
+		d.recent[i] = d.privacy.Anomaly(a)
+	}
+}
+
+// Observe records an answered query and returns the alerts it raised.
+func (d *AnomalyDetector) Observe(ev QueryEvent) []Anomaly {
+	// Label checks need no shared state
+	var found []Anomaly
+	if label := longestLabel(ev.Name); len(label) > d.settings.MaxLabelLength {
+		found = append(found, d.anomaly(ev, AnomalyLongLabel, fmt.Sprintf("label length %d", len(label))))
+	} else if len(label) >= entropyMinLabelLen {
+		if h := labelEntropy(label); h >= d.settings.MinLabelEntropy {
+			found = append(found, d.anomaly(ev, AnomalyHighEntropy, fmt.Sprintf("label entropy %.2f bits/char", h)))
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	c := d.client(ev.Client, ev.Time)
+	if c == nil {
+		return d.raise(nil, found)
+	}
+	c.queries++
+	if ev.RCode == dns.RCodeNXDomain {
+		c.nxdomain++
+	}
+	if ev.Type == dns.TypeTXT || ev.Type == dns.TypeNULL {
+		c.txt++
+	}
+	if c.queries >= d.settings.MinQueries {
+		if r := float64(c.nxdomain) / float64(c.queries); r >= d.settings.NXDOMAINRatio {
+			found = append(found, d.anomaly(ev, AnomalyNXDOMAINRate,
+				fmt.Sprintf("%.0f%% NXDOMAIN over %d queries", r*100, c.queries)))
+		}
+		if r := float64(c.txt) / float64(c.queries); r >= d.settings.TXTRatio {
+			found = append(found, d.anomaly(ev, AnomalyTXTRate,
+				fmt.Sprintf("%.0f%% TXT/NULL over %d queries", r*100, c.queries)))
+		}
+	}
+	return d.raise(c, found)
+}
+
+// anomaly builds an alert for ev.
+func (d *AnomalyDetector) anomaly(ev QueryEvent, kind AnomalyKind, detail string) Anomaly {
+	return Anomaly{Time: ev.Time, Client: ev.Client, Kind: kind, Name: ev.Name, Detail: detail}
+}
+
+// raise records the alerts in found that are not suppressed by an earlier
+// alert of the same kind for the client, and returns them. c is nil for
+// untracked clients, whose alerts are never suppressed.
+// Must be called with d.mu held.
+func (d *AnomalyDetector) raise(c *anomalyClient, found []Anomaly) []Anomaly {
+	raised := found[:0]
+	for _, a := range found {
+		if c != nil {
+			if last, ok := c.lastAlert[a.Kind]; ok && a.Time.Sub(last) < d.settings.Window {
+				continue
+			}
+			if c.lastAlert == nil {
+				c.lastAlert = make(map[AnomalyKind]time.Time)
+			}
+			c.lastAlert[a.Kind] = a.Time
+		}
+		d.counts[a.Kind]++
+		a = d.privacy.Anomaly(a)
+		if len(d.recent) < anomalyRecent {
+			d.recent = append(d.recent, a)
+		} else {
+			d.recent[d.next] = a
+		}
+		d.next = (d.next + 1) % anomalyRecent
+		raised = append(raised, a)
+	}
+	return raised
+}
+
+// client returns the counters of addr, starting a new window when the
+// current one has ended. Returns nil when too many clients are tracked.
+// Must be called with d.mu held.
+func (d *AnomalyDetector) client(addr string, now time.Time) *anomalyClient {
+	c, ok := d.clients[addr]
+	if !ok {
+		if len(d.clients) >= anomalyMaxClients {
+			d.evictExpired(now)
+			if len(d.clients) >= anomalyMaxClients {
+				return nil
+			}
+		}
+		c = &anomalyClient{start: now}
+		d.clients[addr] = c
+	}
+	if now.Sub(c.start) >= d.settings.Window {
+		c.start = now
+		c.queries, c.nxdomain, c.txt = 0, 0, 0
+	}
+	return c
+}
+
+// evictExpired forgets clients whose window and alert suppression have
+// both ended. Must be called with d.mu held.
+func (d *AnomalyDetector) evictExpired(now time.Time) {
+	for addr, c := range d.clients {
+		if now.Sub(c.start) < d.settings.Window {
+			continue
+		}
+		expired := true
+		for _, last := range c.lastAlert {
+			if now.Sub(last) < d.settings.Window {
+				expired = false
+				break
+			}
+		}
+		if expired {
+			delete(d.clients, addr)
+		}
+	}
+}
+
+// Counts returns the number of alerts raised per kind.
+func (d *AnomalyDetector) Counts() map[AnomalyKind]uint64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := make(map[AnomalyKind]uint64, len(AnomalyKinds))
+	for _, k := range AnomalyKinds {
+		out[k] = d.counts[k]
+	}
+	return out
+}
+
+// Recent returns the latest alerts, newest first.
+func (d *AnomalyDetector) Recent() []Anomaly {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	n := len(d.recent)
+	out := make([]Anomaly, 0, n)
+	for i := range n {
+		out = append(out, d.recent[(d.next-1-i+n)%n])
+	}
+	return out
+}
+
+// longestLabel returns the longest label of name.
+func longestLabel(name string) string {
+	var longest string
+	for label := range strings.SplitSeq(strings.TrimSuffix(name, "."), ".") {
+		if len(label) > len(longest) {
+			longest = label
+		}
+	}
+	return longest
+}
+
+// labelEntropy returns the Shannon entropy of label in bits per character.
+// Case is ignored, as DNS names are case-insensitive.
+func labelEntropy(label string) float64 {
+	var freq [256]int
+	for i := range len(label) {
+		c := label[i]
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		freq[c]++
+	}
+	n := float64(len(label))
+	var h float64
+	for _, f := range freq {
+		if f > 0 {
+			p := float64(f) / n
+			h -= p * math.Log2(p)
+		}
+	}
+	return h
+}
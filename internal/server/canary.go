@@ -0,0 +1,203 @@
+package server
+
+import (
+	"context"
+	"encoding/binary"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jroosing/hydradns/internal/dns"
+	"github.com/jroosing/hydradns/internal/resolvers"
+)
+
+// CanarySettings contains health canary settings (see config.CanaryConfig).
+type CanarySettings struct {
+	Domains          []string
+	Interval         time.Duration
+	Timeout          time.Duration
+	FailureThreshold int
+}
+
+// CanaryCheck is the latest result for one canary domain.
+type CanaryCheck struct {
+	Domain              string
+	OK                  bool
+	RCode               string // Response code of the last answer; empty on error
+	Latency             time.Duration
+	Error               string // Why the last check failed; empty on success
+	CheckedAt           time.Time
+	LastSuccess         time.Time
+	ConsecutiveFailures int
+}
+
+// CanaryStatus is the health canary's view of DNS resolution.
+type CanaryStatus struct {
+	// Healthy is false once FailedRounds reaches the failure threshold.
+	Healthy bool
+	// LastRound is when the latest round finished; zero before the first.
+	LastRound time.Time
+	// FailedRounds counts consecutive rounds in which no domain resolved.
+	FailedRounds int
+	Checks       []CanaryCheck
+}
+
+// Canary periodically resolves a set of domains through the resolver chain,
+// as a client would, to catch resolution that is broken while the process
+// is alive: unreachable upstreams, a bad config, or a wedged resolver.
+//
+// Canary queries skip the response cache, so a cached answer cannot hide an
+// upstream outage, and are not counted in query statistics. A round fails
+// when no domain resolves with NOERROR; a single failing domain is more
+// likely a problem with that domain than with the server. After
+// FailureThreshold failed rounds in a row the canary reports unhealthy and
+// logs an error, and it logs again when resolution recovers.
+//
+// All methods are safe for concurrent use.
+type Canary struct {
+	resolver resolvers.Resolver
+	settings CanarySettings
+	logger   *slog.Logger
+
+	mu     sync.Mutex
+	status CanaryStatus
+}
+
+// NewCanary creates a canary that resolves through resolver.
+func NewCanary(resolver resolvers.Resolver, s CanarySettings, logger *slog.Logger) *Canary {
+	if s.Interval <= 0 {
+		s.Interval = 30 * time.Second
+	}
+	if s.Timeout <= 0 {
+		s.Timeout = 5 * time.Second
+	}
+	if s.FailureThreshold <= 0 {
+		s.FailureThreshold = 3
+	}
+	c := &Canary{resolver: resolver, settings: s, logger: logger}
+	c.status.Healthy = true
+	c.status.Checks = make([]CanaryCheck, len(s.Domains))
+	for i, d := range s.Domains {
+		c.status.Checks[i].Domain = d
+	}
+	return c
+}
+
+// Run checks at once and then every interval until ctx is canceled.
+func (c *Canary) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.settings.Interval)
+	defer ticker.Stop()
+
+	for {
+		c.Check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check runs one round, resolving every domain concurrently, and updates
+// the status.
+func (c *Canary) Check(ctx context.Context) {
+	results := make([]CanaryCheck, len(c.settings.Domains))
+	var wg sync.WaitGroup
+	for i, d := range c.settings.Domains {
+		wg.Go(func() { results[i] = c.query(ctx, d) })
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return // shutting down; the results say nothing about DNS
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	roundOK := len(results) == 0
+	for i, r := range results {
+		prev := c.status.Checks[i]
+		r.LastSuccess = prev.LastSuccess
+		if r.OK {
+			r.LastSuccess = r.CheckedAt
+			roundOK = true
+		} else {
+			r.ConsecutiveFailures = prev.ConsecutiveFailures + 1
+		}
+		c.status.Checks[i] = r
+	}
+	c.status.LastRound = time.Now()
+
+	wasHealthy := c.status.Healthy
+	if roundOK {
+		c.status.FailedRounds = 0
+	} else {
+		c.status.FailedRounds++
+	}
+	c.status.Healthy = c.status.FailedRounds < c.settings.FailureThreshold
+
+	if c.logger == nil {
+		return
+	}
+	switch {
+	case wasHealthy && !c.status.Healthy:
+		attrs := []any{"failed_rounds", c.status.FailedRounds}
+		for _, r := range results {
+			attrs = append(attrs, r.Domain, r.Error)
+		}
+		c.logger.Error("dns canary failing: no canary domain resolves", attrs...)
+	case !wasHealthy && c.status.Healthy:
+		c.logger.Info("dns canary recovered")
+	}
+}
+
+// query resolves one domain and reports the outcome.
+func (c *Canary) query(ctx context.Context, domain string) CanaryCheck {
+	check := CanaryCheck{Domain: domain}
+
+	req := dns.Packet{
+		Header: dns.Header{ID: dns.NewTransactionID(), Flags: dns.RDFlag},
+		Questions: []dns.Question{
+			{Name: domain, Type: uint16(dns.TypeA), Class: uint16(dns.ClassIN)},
+		},
+	}
+	reqBytes, err := req.Marshal()
+	if err != nil {
+		check.Error = err.Error()
+		check.CheckedAt = time.Now()
+		return check
+	}
+
+	qctx, cancel := context.WithTimeout(resolvers.WithoutCache(ctx), c.settings.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	res, err := c.resolver.Resolve(qctx, req, reqBytes)
+	check.Latency = time.Since(start)
+	check.CheckedAt = time.Now()
+
+	switch {
+	case err != nil:
+		check.Error = err.Error()
+	case len(res.ResponseBytes) < dns.HeaderSize:
+		check.Error = "short response"
+	default:
+		rcode := dns.RCodeFromFlags(binary.BigEndian.Uint16(res.ResponseBytes[2:4]))
+		check.RCode = rcode.String()
+		if rcode == dns.RCodeNoError {
+			check.OK = true
+		} else {
+			check.Error = "answered " + check.RCode
+		}
+	}
+	return check
+}
+
+// Status returns a copy of the canary's latest results.
+func (c *Canary) Status() CanaryStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st := c.status
+	st.Checks = append([]CanaryCheck(nil), c.status.Checks...)
+	return st
+}
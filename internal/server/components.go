@@ -0,0 +1,396 @@
+package server
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/jroosing/hydradns/internal/audit"
+	"github.com/jroosing/hydradns/internal/config"
+	"github.com/jroosing/hydradns/internal/filtering"
+	"github.com/jroosing/hydradns/internal/geoip"
+	"github.com/jroosing/hydradns/internal/resolvers"
+)
+
+// runStack is what the components of one run build and share. Each field is
+// set by the Start of the component that owns it, before any component that
+// uses it starts.
+type runStack struct {
+	cfg     *config.Config
+	maxConc int
+	upPool  int
+
+	policy   *filtering.PolicyEngine
+	geoDB    *geoip.DB
+	anomaly  *AnomalyDetector
+	upstream resolvers.Resolver // shared by the main chain and listener profiles
+	resolver resolvers.Resolver
+	handler  *QueryHandler
+	limiter  *RateLimiter
+}
+
+// dnsComponents returns the DNS server's components in start order.
+// Optional features are left out when they are not configured.
+func (r *Runner) dnsComponents(s *runStack) []Component {
+	cfg := s.cfg
+	cs := []Component{r.filteringComponent(s)}
+	if cfg.GeoIP.CountryDB != "" || cfg.GeoIP.ASNDB != "" {
+		cs = append(cs, r.geoIPComponent(s))
+	}
+	if cfg.Anomaly.Enabled {
+		cs = append(cs, r.anomalyComponent(s))
+	}
+	if cfg.ThreatIntel.Enabled {
+		cs = append(cs, r.threatIntelComponent(s))
+	}
+	if cfg.Audit.Enabled {
+		cs = append(cs, r.auditComponent(s))
+	}
+	cs = append(cs, r.resolverComponent(s))
+	if len(cfg.CustomDNS.HealthChecks) > 0 {
+		cs = append(cs, r.recordHealthComponent())
+	}
+	if cfg.Canary.Enabled {
+		cs = append(cs, r.canaryComponent(s))
+	}
+
+	addr := net.JoinHostPort(cfg.Server.Host, strconv.Itoa(cfg.Server.Port))
+	cs = append(cs, r.udpComponent(s, addr))
+	if cfg.Server.EnableTCP {
+		cs = append(cs, r.tcpComponent(s, addr))
+	}
+	for _, p := range cfg.Server.ListenerProfiles {
+		cs = append(cs, r.profileComponents(s, p)...)
+	}
+	if cfg.Advertise.Enabled {
+		cs = append(cs, r.advertiseComponent(s))
+	}
+	return cs
+}
+
+// filteringComponent provides the filtering policy engine. An engine built
+// here is closed at shutdown, stopping its blocklist refreshes; one set with
+// SetPolicyEngine belongs to the caller.
+func (r *Runner) filteringComponent(s *runStack) Component {
+	var owned *filtering.PolicyEngine
+	return Component{
+		Name: "filtering",
+		Start: func(context.Context) error {
+			if r.policyEngine == nil {
+				owned = BuildPolicyEngine(s.cfg, r.logger, nil)
+				r.policyEngine = owned
+			}
+			s.policy = r.policyEngine
+			r.policy.Store(s.policy)
+			return nil
+		},
+		Stop: func(context.Context) error {
+			r.policy.Store(nil)
+			if owned == nil {
+				return nil
+			}
+			r.policyEngine = nil
+			return owned.Close()
+		},
+	}
+}
+
+// geoIPComponent opens the GeoIP databases.
+func (r *Runner) geoIPComponent(s *runStack) Component {
+	return Component{
+		Name: "geoip",
+		Start: func(context.Context) error {
+			db, err := geoip.Open(s.cfg.GeoIP.CountryDB, s.cfg.GeoIP.ASNDB)
+			if err != nil {
+				return err
+			}
+			s.geoDB = db
+			r.geoIP.Store(db)
+			return nil
+		},
+		Stop: func(context.Context) error {
+			r.geoIP.Store(nil)
+			return s.geoDB.Close()
+		},
+	}
+}
+
+// anomalyComponent sets up anomaly detection.
+func (r *Runner) anomalyComponent(s *runStack) Component {
+	return Component{
+		Name: "anomaly",
+		Start: func(context.Context) error {
+			s.anomaly = BuildAnomalyDetector(s.cfg)
+			s.anomaly.SetPrivacy(r.privacy)
+			r.anomaly.Store(s.anomaly)
+			return nil
+		},
+		Stop: func(context.Context) error {
+			r.anomaly.Store(nil)
+			return nil
+		},
+	}
+}
+
+// threatIntelComponent runs threat intelligence lookups. Without it,
+// forwarded names are simply not checked against the feed.
+func (r *Runner) threatIntelComponent(s *runStack) Component {
+	return Component{
+		Name:   "threat-intel",
+		Policy: Optional,
+		Start: func(context.Context) error {
+			threat := BuildThreatIntelChecker(s.cfg, s.policy, r.logger)
+			if threat == nil {
+				return errors.New("no policy engine to block with")
+			}
+			r.threatIntel.Store(threat)
+			return nil
+		},
+		Run: func(ctx context.Context) error {
+			r.threatIntel.Load().Run(ctx)
+			return nil
+		},
+		Stop: func(context.Context) error {
+			r.threatIntel.Store(nil)
+			return nil
+		},
+	}
+}
+
+// auditComponent opens the outbound query audit log. It is required: an
+// audited server must not forward queries it cannot record.
+func (r *Runner) auditComponent(s *runStack) Component {
+	return Component{
+		Name: "audit",
+		Start: func(context.Context) error {
+			auditLog, err := audit.Open(s.cfg.Audit.Path)
+			if err != nil {
+				return err
+			}
+			r.audit.Store(auditLog)
+			return nil
+		},
+		Stop: func(context.Context) error {
+			if auditLog := r.audit.Load(); auditLog != nil {
+				r.closeAudit(auditLog)
+			}
+			return nil
+		},
+	}
+}
+
+// resolverComponent builds the resolver chain and the query handler the
+// listeners share.
+func (r *Runner) resolverComponent(s *runStack) Component {
+	return Component{
+		Name: "resolver",
+		Start: func(context.Context) error {
+			cfg := s.cfg
+			s.upstream = r.buildUpstream(cfg, s.upPool)
+			s.resolver = r.buildResolverChain(cfg, s.upstream, s.policy)
+			r.logFiltering(cfg)
+
+			s.handler = &QueryHandler{
+				Logger:   r.logger,
+				Resolver: s.resolver,
+				Timeout:  4 * time.Second,
+				Stats:    r.dnsStats,
+				Events:   r.queryEvents,
+				GeoIP:    s.geoDB,
+				Anomaly:  s.anomaly,
+				QTypes:   BuildQTypePolicy(cfg),
+				Privacy:  r.privacy,
+				Slow:     buildSlowQueryLog(cfg, r.logger),
+				Response: BuildResponsePipeline(cfg),
+				ServerID: cmp.Or(cfg.Identity.ServerID, cfg.Identity.Hostname),
+				Shadow:   r.buildShadow(cfg),
+				Coverage: r.buildClientCoverage(cfg),
+
+				RecursionClients: cfg.Server.RecursionPrefixes(),
+			}
+			r.qtypes.Store(s.handler.QTypes)
+			r.shadow.Store(s.handler.Shadow)
+			r.coverage.Store(s.handler.Coverage)
+			s.limiter = NewRateLimiter(RateLimitSettings{
+				CleanupSeconds:   cfg.RateLimit.CleanupSeconds,
+				MaxIPEntries:     cfg.RateLimit.MaxIPEntries,
+				MaxPrefixEntries: cfg.RateLimit.MaxPrefixEntries,
+				GlobalQPS:        cfg.RateLimit.GlobalQPS,
+				GlobalBurst:      cfg.RateLimit.GlobalBurst,
+				PrefixQPS:        cfg.RateLimit.PrefixQPS,
+				PrefixBurst:      cfg.RateLimit.PrefixBurst,
+				IPQPS:            cfg.RateLimit.IPQPS,
+				IPBurst:          cfg.RateLimit.IPBurst,
+				Slip:             cfg.RateLimit.Slip,
+				IPv4PrefixLen:    cfg.RateLimit.IPv4PrefixLen,
+				IPv6PrefixLen:    cfg.RateLimit.IPv6PrefixLen,
+				ExemptLocal:      cfg.RateLimit.ExemptLocal,
+			})
+			return nil
+		},
+		// Closing the main chain releases the upstream resolver that
+		// listener profiles share, so it stops after all listeners
+		Stop: func(context.Context) error {
+			r.forwarder.Store(nil)
+			r.qtypes.Store(nil)
+			r.coverage.Store(nil)
+			if shadow := r.shadow.Swap(nil); shadow != nil {
+				if err := shadow.Close(); err != nil && r.logger != nil {
+					r.logger.Warn("failed to close shadow upstreams", "err", err)
+				}
+			}
+			return s.resolver.Close()
+		},
+	}
+}
+
+// recordHealthComponent probes the addresses of custom DNS hosts with a
+// health check. Targets are set by initCustomDNS and ReloadCustomDNS.
+func (r *Runner) recordHealthComponent() Component {
+	return Component{
+		Name:   "record-health",
+		Policy: Optional,
+		Run: func(ctx context.Context) error {
+			r.recordHealth.Run(ctx)
+			return nil
+		},
+	}
+}
+
+// canaryComponent runs the health canary.
+func (r *Runner) canaryComponent(s *runStack) Component {
+	return Component{
+		Name:   "canary",
+		Policy: Optional,
+		Start: func(context.Context) error {
+			r.canary.Store(BuildCanary(s.cfg, s.resolver, r.logger))
+			return nil
+		},
+		Run: func(ctx context.Context) error {
+			r.canary.Load().Run(ctx)
+			return nil
+		},
+		Stop: func(context.Context) error {
+			r.canary.Store(nil)
+			return nil
+		},
+	}
+}
+
+// advertiseComponent advertises the main listening addresses to the
+// router or DHCP server.
+func (r *Runner) advertiseComponent(s *runStack) Component {
+	return Component{
+		Name:   "advertise",
+		Policy: Optional,
+		Start: func(context.Context) error {
+			r.advertiser.Store(BuildAdvertiser(s.cfg, r.logger))
+			return nil
+		},
+		Run: func(ctx context.Context) error {
+			r.advertiser.Load().Run(ctx)
+			return nil
+		},
+		Stop: func(context.Context) error {
+			r.advertiser.Store(nil)
+			return nil
+		},
+	}
+}
+
+// udpComponent serves DNS over UDP on the main address.
+func (r *Runner) udpComponent(s *runStack, addr string) Component {
+	var udp *UDPServer
+	return Component{
+		Name: "dns-udp",
+		Start: func(context.Context) error {
+			r.logStartup(s.cfg, addr, s.maxConc, s.upPool)
+			udp = &UDPServer{
+				Logger:           r.logger,
+				Handler:          s.handler,
+				Limiter:          s.limiter,
+				WorkersPerSocket: s.maxConc,
+				Listeners:        s.cfg.Server.UDPListeners,
+				RecvBuffer:       s.cfg.Server.UDPRecvBuffer,
+				SendBuffer:       s.cfg.Server.UDPSendBuffer,
+				Capture:          r.capture,
+			}
+			r.udp.Store(udp)
+			return nil
+		},
+		Run: func(ctx context.Context) error { return udp.Run(ctx, addr) },
+		Stop: func(context.Context) error {
+			r.udp.Store(nil)
+			return nil
+		},
+	}
+}
+
+// tcpComponent serves DNS over TCP on the main address.
+func (r *Runner) tcpComponent(s *runStack, addr string) Component {
+	var tcp *TCPServer
+	return Component{
+		Name: "dns-tcp",
+		Start: func(context.Context) error {
+			tcp = r.newTCPServer(s.cfg, s.handler)
+			r.tcp.Store(tcp)
+			return nil
+		},
+		Run: func(ctx context.Context) error { return tcp.Run(ctx, addr) },
+		Stop: func(context.Context) error {
+			r.tcp.Store(nil)
+			return nil
+		},
+	}
+}
+
+// profileComponents serves a listener profile, sharing the rate limiter and
+// statistics with the main listeners. Profiles are optional: a profile that
+// cannot bind leaves the main listeners serving.
+func (r *Runner) profileComponents(s *runStack, p config.ListenerProfile) []Component {
+	var h *QueryHandler
+	cs := []Component{{
+		Name:   "dns-udp:" + p.Name,
+		Policy: Optional,
+		Start: func(context.Context) error {
+			policy := s.policy
+			if p.Unfiltered {
+				policy = nil
+			}
+			ph := *s.handler
+			ph.Resolver = r.buildResolverChain(s.cfg, s.upstream, policy)
+			ph.Listener = p.Name
+			h = &ph
+			if r.logger != nil {
+				r.logger.Info("dns listening", "profile", p.Name, "addr", p.Addr(), "filtering", !p.Unfiltered)
+			}
+			return nil
+		},
+		Run: func(ctx context.Context) error {
+			udp := &UDPServer{
+				Logger:           r.logger,
+				Handler:          h,
+				Limiter:          s.limiter,
+				WorkersPerSocket: s.maxConc,
+				Listeners:        s.cfg.Server.UDPListeners,
+				RecvBuffer:       s.cfg.Server.UDPRecvBuffer,
+				SendBuffer:       s.cfg.Server.UDPSendBuffer,
+				Capture:          r.capture,
+			}
+			return udp.Run(ctx, p.Addr())
+		},
+	}}
+	if s.cfg.Server.EnableTCP {
+		cs = append(cs, Component{
+			Name:   "dns-tcp:" + p.Name,
+			Policy: Optional,
+			Run: func(ctx context.Context) error {
+				return r.newTCPServer(s.cfg, h).Run(ctx, p.Addr())
+			},
+		})
+	}
+	return cs
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"net/netip"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jroosing/hydradns/internal/resolvers"
+)
+
+const (
+	// coverageMaxSubnets bounds the uncovered subnets tracked; queries from
+	// further subnets are only counted.
+	coverageMaxSubnets = 1024
+	// Prefix lengths uncovered clients are grouped by.
+	coverageIPv4Bits = 24
+	coverageIPv6Bits = 64
+)
+
+// UncoveredSubnet is a client network outside the configured client
+// subnets that queried this server.
+type UncoveredSubnet struct {
+	Subnet    string // Redacted as the privacy level prescribes
+	Queries   uint64
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+// CoverageStatus reports the clients that queried from outside the
+// configured client subnets.
+type CoverageStatus struct {
+	ClientSubnets []string
+	Uncovered     []UncoveredSubnet // Most queries first
+	// Untracked counts queries from uncovered subnets not listed because
+	// too many were already tracked.
+	Untracked uint64
+}
+
+// uncoveredStats accumulates the queries from one uncovered subnet.
+type uncoveredStats struct {
+	queries   uint64
+	firstSeen time.Time
+	lastSeen  time.Time
+}
+
+// ClientCoverage notices clients querying from outside the subnets that
+// DNS is advertised to, typically a network whose DHCP server hands out
+// this server without the configuration expecting it. Uncovered clients
+// are grouped by /24 (IPv4) or /64 (IPv6) network, and a warning is logged
+// the first time each network is seen. Loopback clients are ignored.
+//
+// Safe for concurrent use. A nil ClientCoverage observes nothing.
+type ClientCoverage struct {
+	subnets []netip.Prefix
+	logger  *slog.Logger
+	privacy *Privacy
+
+	mu        sync.Mutex
+	seen      map[netip.Prefix]*uncoveredStats
+	untracked uint64
+}
+
+// NewClientCoverage creates a tracker for clients outside subnets. Logged
+// and reported networks are redacted by privacy.
+func NewClientCoverage(subnets []netip.Prefix, logger *slog.Logger, privacy *Privacy) *ClientCoverage {
+	return &ClientCoverage{
+		subnets: subnets,
+		logger:  logger,
+		privacy: privacy,
+		seen:    make(map[netip.Prefix]*uncoveredStats),
+	}
+}
+
+// Observe records a query from addr. ctx carries the query's ClientInfo.
+func (c *ClientCoverage) Observe(ctx context.Context, addr netip.Addr) {
+	if c == nil || !addr.IsValid() || addr.IsLoopback() {
+		return
+	}
+	addr = addr.Unmap()
+	for _, p := range c.subnets {
+		if p.Contains(addr) {
+			return
+		}
+	}
+	bits := coverageIPv4Bits
+	if addr.Is6() {
+		bits = coverageIPv6Bits
+	}
+	subnet, _ := addr.Prefix(bits)
+	now := time.Now()
+
+	c.mu.Lock()
+	s, ok := c.seen[subnet]
+	if !ok {
+		if len(c.seen) >= coverageMaxSubnets {
+			c.untracked++
+			c.mu.Unlock()
+			return
+		}
+		s = &uncoveredStats{firstSeen: now}
+		c.seen[subnet] = s
+	}
+	s.queries++
+	s.lastSeen = now
+	c.mu.Unlock()
+
+	if !ok && c.logger != nil {
+		client, _ := resolvers.ClientInfoFromContext(ctx)
+		c.logger.WarnContext(ctx, "query from a client subnet outside advertise.client_subnets",
+			"subnet", c.privacy.Subnet(subnet),
+			"trace_id", client.TraceID,
+		)
+	}
+}
+
+// Status returns the uncovered subnets. Subnets the privacy level no
+// longer tells apart are merged.
+func (c *ClientCoverage) Status() CoverageStatus {
+	c.mu.Lock()
+	merged := make(map[string]*UncoveredSubnet, len(c.seen))
+	for prefix, s := range c.seen {
+		name := c.privacy.Subnet(prefix)
+		u, ok := merged[name]
+		if !ok {
+			merged[name] = &UncoveredSubnet{Subnet: name, Queries: s.queries, FirstSeen: s.firstSeen, LastSeen: s.lastSeen}
+			continue
+		}
+		u.Queries += s.queries
+		if s.firstSeen.Before(u.FirstSeen) {
+			u.FirstSeen = s.firstSeen
+		}
+		if s.lastSeen.After(u.LastSeen) {
+			u.LastSeen = s.lastSeen
+		}
+	}
+	out := CoverageStatus{Untracked: c.untracked}
+	c.mu.Unlock()
+
+	for _, p := range c.subnets {
+		out.ClientSubnets = append(out.ClientSubnets, p.String())
+	}
+	out.Uncovered = make([]UncoveredSubnet, 0, len(merged))
+	for _, u := range merged {
+		out.Uncovered = append(out.Uncovered, *u)
+	}
+	slices.SortFunc(out.Uncovered, func(x, y UncoveredSubnet) int {
+		return cmp.Or(cmp.Compare(y.Queries, x.Queries), cmp.Compare(x.Subnet, y.Subnet))
+	})
+	return out
+}
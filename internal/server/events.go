@@ -0,0 +1,83 @@
+package server
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jroosing/hydradns/internal/dns"
+)
+
+// QueryEvent describes one answered query, for live dashboards.
+type QueryEvent struct {
+	Time      time.Time
+	Transport string // "udp" or "tcp"
+	Client    string // Client IP address
+	Name      string
+	Type      dns.RecordType
+	RCode     dns.RCode
+	Source    string // Origin of the response (cache, upstream, error type)
+	Duration  time.Duration
+	TraceID   string // Also in the query's log entries
+}
+
+// QueryEvents fans query events out to subscribers.
+//
+// Publishing never blocks the query path: an event is dropped for any
+// subscriber whose buffer is full. The zero value is ready to use.
+type QueryEvents struct {
+	active  atomic.Int32
+	dropped atomic.Uint64
+
+	mu   sync.Mutex
+	subs map[chan QueryEvent]struct{}
+}
+
+// Subscribe returns a channel receiving events until cancel is called,
+// which closes the channel. buffer is the channel capacity.
+func (e *QueryEvents) Subscribe(buffer int) (events <-chan QueryEvent, cancel func()) {
+	ch := make(chan QueryEvent, max(buffer, 1))
+
+	e.mu.Lock()
+	if e.subs == nil {
+		e.subs = make(map[chan QueryEvent]struct{})
+	}
+	e.subs[ch] = struct{}{}
+	e.active.Add(1)
+	e.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			e.mu.Lock()
+			delete(e.subs, ch)
+			e.active.Add(-1)
+			close(ch)
+			e.mu.Unlock()
+		})
+	}
+}
+
+// Active reports whether anyone is subscribed, so callers can skip
+// building events nobody receives.
+func (e *QueryEvents) Active() bool {
+	return e != nil && e.active.Load() > 0
+}
+
+// Publish sends ev to every subscriber with room in its buffer.
+func (e *QueryEvents) Publish(ev QueryEvent) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for ch := range e.subs {
+		select {
+		case ch <- ev:
+		default:
+			e.dropped.Add(1)
+		}
+	}
+}
+
+// Dropped returns the number of events dropped for slow subscribers.
+func (e *QueryEvents) Dropped() uint64 {
+	return e.dropped.Load()
+}
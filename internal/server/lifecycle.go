@@ -0,0 +1,305 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// componentStopTimeout bounds each component's Stop.
+const componentStopTimeout = 5 * time.Second
+
+// errComponentExited reports a component whose Run returned before it was
+// told to stop.
+var errComponentExited = errors.New("exited unexpectedly")
+
+// FailurePolicy decides what a failing component does to the server.
+type FailurePolicy int
+
+const (
+	// Required components stop the server when they fail to start or when
+	// Run returns before shutdown.
+	Required FailurePolicy = iota
+	// Optional components are marked failed and logged; the rest of the
+	// server keeps running without them.
+	Optional
+)
+
+// String returns "required" or "optional".
+func (p FailurePolicy) String() string {
+	if p == Optional {
+		return "optional"
+	}
+	return "required"
+}
+
+// ComponentState is where a component is in its lifecycle.
+type ComponentState string
+
+// Component states.
+const (
+	ComponentPending  ComponentState = "pending"
+	ComponentStarting ComponentState = "starting"
+	ComponentRunning  ComponentState = "running"
+	ComponentStopping ComponentState = "stopping"
+	ComponentStopped  ComponentState = "stopped"
+	ComponentFailed   ComponentState = "failed"
+)
+
+// Component is one long-lived part of the server, such as a DNS listener,
+// the API server, or the cluster syncer. Every hook is optional.
+type Component struct {
+	Name   string
+	Policy FailurePolicy
+	// Start acquires the component's resources. Components start one at a
+	// time in the order they were added, so Start may use whatever earlier
+	// components set up.
+	Start func(ctx context.Context) error
+	// Run serves until ctx is canceled. Returning earlier, even without an
+	// error, is a failure.
+	Run func(ctx context.Context) error
+	// Stop releases what Start acquired. It is called after Run returned,
+	// in reverse start order, and only if Start succeeded.
+	Stop func(ctx context.Context) error
+}
+
+// ComponentHealth is a component's current state.
+type ComponentHealth struct {
+	Name   string
+	Policy FailurePolicy
+	State  ComponentState
+	Error  string    // Why the component failed; empty otherwise
+	Since  time.Time // When the component entered State
+}
+
+// Lifecycle starts components in order, watches them while they run, and
+// stops them in reverse order, so nothing is torn down while a component
+// that depends on it is still running: listeners stop before the resolver
+// they forward to is closed.
+//
+// All methods are safe for concurrent use.
+type Lifecycle struct {
+	logger *slog.Logger
+
+	mu           sync.Mutex
+	components   []*managedComponent
+	drain        time.Duration
+	shuttingDown bool
+}
+
+// managedComponent is a component and its runtime state.
+type managedComponent struct {
+	Component
+	health  ComponentHealth // guarded by Lifecycle.mu
+	started bool
+	cancel  context.CancelFunc // set while Run is running
+	done    chan struct{}      // closed when Run returns
+}
+
+// componentFailure is a Run that returned before shutdown.
+type componentFailure struct {
+	c   *managedComponent
+	err error
+}
+
+// NewLifecycle creates an empty lifecycle.
+func NewLifecycle(logger *slog.Logger) *Lifecycle {
+	return &Lifecycle{logger: logger}
+}
+
+// Add appends a component. Components added after Run has started are
+// ignored by that Run.
+func (l *Lifecycle) Add(c Component) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.components = append(l.components, &managedComponent{
+		Component: c,
+		health:    ComponentHealth{Name: c.Name, Policy: c.Policy, State: ComponentPending, Since: time.Now()},
+	})
+}
+
+// SetDrain makes Run wait d after shutdown is requested before stopping any
+// component, so clients can be moved elsewhere while the server still
+// answers. ShuttingDown reports true from the start of the wait.
+func (l *Lifecycle) SetDrain(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.drain = d
+}
+
+// ShuttingDown reports whether Run has been told to stop, or a required
+// component failed, and the components are draining or stopping.
+func (l *Lifecycle) ShuttingDown() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.shuttingDown
+}
+
+// Health returns the state of every component, in start order.
+func (l *Lifecycle) Health() []ComponentHealth {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := make([]ComponentHealth, len(l.components))
+	for i, c := range l.components {
+		out[i] = c.health
+	}
+	return out
+}
+
+// Run starts every component, blocks until ctx is canceled or a required
+// component fails, and then stops the started components in reverse order.
+// When ctx is canceled after every component started, the components keep
+// running for the drain period first (see SetDrain). It returns the
+// required component's error, if any.
+func (l *Lifecycle) Run(ctx context.Context) error {
+	l.mu.Lock()
+	components := append([]*managedComponent(nil), l.components...)
+	l.mu.Unlock()
+
+	failures := make(chan componentFailure, len(components))
+	runErr := l.start(ctx, components, failures)
+	started := ctx.Err() == nil
+
+	for runErr == nil && ctx.Err() == nil {
+		select {
+		case <-ctx.Done():
+		case f := <-failures:
+			runErr = l.fail(f.c, f.err)
+		}
+	}
+
+	l.mu.Lock()
+	l.shuttingDown = true
+	drain := l.drain
+	l.mu.Unlock()
+	if runErr == nil && started && drain > 0 {
+		runErr = l.drainFor(drain, failures)
+	}
+
+	l.stop(ctx, components)
+	return runErr
+}
+
+// drainFor keeps the components running for d, or until a required one
+// fails.
+func (l *Lifecycle) drainFor(d time.Duration, failures <-chan componentFailure) error {
+	if l.logger != nil {
+		l.logger.Info("draining before shutdown", "duration", d)
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			return nil
+		case f := <-failures:
+			if err := l.fail(f.c, f.err); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// start starts components in order until a required one fails.
+func (l *Lifecycle) start(ctx context.Context, components []*managedComponent, failures chan<- componentFailure) error {
+	for _, c := range components {
+		if ctx.Err() != nil {
+			return nil // shutdown requested during startup
+		}
+		l.setState(c, ComponentStarting, nil)
+		if c.Start != nil {
+			if err := c.Start(ctx); err != nil {
+				if failErr := l.fail(c, err); failErr != nil {
+					return failErr
+				}
+				continue
+			}
+		}
+		c.started = true
+		l.setState(c, ComponentRunning, nil)
+
+		if c.Run == nil {
+			continue
+		}
+		// Components are canceled one by one at shutdown, not all at once
+		// through ctx
+		runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		c.cancel = cancel
+		c.done = make(chan struct{})
+		go func() {
+			defer close(c.done)
+			err := c.Run(runCtx)
+			if runCtx.Err() != nil {
+				return // told to stop
+			}
+			if err == nil {
+				err = errComponentExited
+			}
+			failures <- componentFailure{c: c, err: err}
+		}()
+	}
+	return nil
+}
+
+// fail marks c failed. It returns the error that stops the server when c is
+// required, and nil when the server carries on without it.
+func (l *Lifecycle) fail(c *managedComponent, err error) error {
+	l.setState(c, ComponentFailed, err)
+	if c.Policy == Required {
+		return fmt.Errorf("%s: %w", c.Name, err)
+	}
+	if l.logger != nil {
+		l.logger.Error("component failed; continuing without it", "component", c.Name, "err", err)
+	}
+	return nil
+}
+
+// stop stops the started components in reverse order, waiting for each
+// one's Run to return before calling its Stop.
+func (l *Lifecycle) stop(ctx context.Context, components []*managedComponent) {
+	for i := len(components) - 1; i >= 0; i-- {
+		c := components[i]
+		if !c.started {
+			continue
+		}
+		failed := l.state(c) == ComponentFailed
+		if !failed {
+			l.setState(c, ComponentStopping, nil)
+		}
+		if c.cancel != nil {
+			c.cancel()
+			<-c.done
+		}
+		if c.Stop != nil {
+			stopCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), componentStopTimeout)
+			err := c.Stop(stopCtx)
+			cancel()
+			if err != nil && l.logger != nil {
+				l.logger.Error("component did not stop cleanly", "component", c.Name, "err", err)
+			}
+		}
+		if !failed {
+			l.setState(c, ComponentStopped, nil)
+		}
+	}
+}
+
+func (l *Lifecycle) state(c *managedComponent) ComponentState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return c.health.State
+}
+
+func (l *Lifecycle) setState(c *managedComponent, state ComponentState, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	c.health.State = state
+	c.health.Since = time.Now()
+	c.health.Error = ""
+	if err != nil {
+		c.health.Error = err.Error()
+	}
+}
@@ -0,0 +1,197 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/netip"
+	"sync/atomic"
+
+	"github.com/jroosing/hydradns/internal/capture"
+)
+
+// PrivacyLevel controls what query logs keep about a query: the debug
+// query log, live query events, and anomaly alerts. Levels are ordered
+// from least to most private.
+type PrivacyLevel int32
+
+const (
+	// PrivacyFull keeps client addresses and query names.
+	PrivacyFull PrivacyLevel = iota
+	// PrivacyAnonymized keeps query names and anonymized client addresses.
+	PrivacyAnonymized
+	// PrivacyDomains keeps query names only.
+	PrivacyDomains
+	// PrivacyNone keeps neither; the debug query log is not written.
+	PrivacyNone
+)
+
+// String returns the configuration name of the level.
+func (l PrivacyLevel) String() string {
+	switch l {
+	case PrivacyFull:
+		return "full"
+	case PrivacyAnonymized:
+		return "anonymized"
+	case PrivacyDomains:
+		return "domains"
+	case PrivacyNone:
+		return "none"
+	default:
+		return "unknown"
+	}
+}
+
+// ParsePrivacyLevel parses a privacy level name (see config.PrivacyConfig).
+func ParsePrivacyLevel(s string) (PrivacyLevel, error) {
+	switch s {
+	case "", "full":
+		return PrivacyFull, nil
+	case "anonymized":
+		return PrivacyAnonymized, nil
+	case "domains":
+		return PrivacyDomains, nil
+	case "none":
+		return PrivacyNone, nil
+	default:
+		return 0, fmt.Errorf("unknown privacy level %q", s)
+	}
+}
+
+// Prefix lengths anonymized client addresses are truncated to.
+const (
+	privacyIPv4Bits = 24
+	privacyIPv6Bits = 48
+)
+
+// Privacy redacts client addresses and query names according to a privacy
+// level. At PrivacyAnonymized, client addresses are truncated to their /24
+// (IPv4) or /48 (IPv6) network, or replaced by a keyed hash. The hash key
+// is random per Privacy, so hashes identify a client for as long as the
+// process runs but cannot be reversed by hashing every address.
+//
+// Safe for concurrent use. A nil Privacy keeps everything.
+type Privacy struct {
+	level atomic.Int32
+	hash  atomic.Bool
+	key   [32]byte
+}
+
+// NewPrivacy creates a Privacy at level, hashing anonymized client
+// addresses when hash is set instead of truncating them.
+func NewPrivacy(level PrivacyLevel, hash bool) *Privacy {
+	p := &Privacy{}
+	_, _ = rand.Read(p.key[:])
+	p.Set(level, hash)
+	return p
+}
+
+// Set changes the privacy level. Queries logged afterwards use the new
+// level; see Runner.SetPrivacy for redacting what was logged before.
+func (p *Privacy) Set(level PrivacyLevel, hash bool) {
+	p.level.Store(int32(level))
+	p.hash.Store(hash)
+}
+
+// Level returns the privacy level and whether anonymized client addresses
+// are hashed.
+func (p *Privacy) Level() (PrivacyLevel, bool) {
+	if p == nil {
+		return PrivacyFull, false
+	}
+	return PrivacyLevel(p.level.Load()), p.hash.Load()
+}
+
+// Client returns the client address src as the privacy level allows it to
+// be logged, or "" when it may not be.
+func (p *Privacy) Client(src string) string {
+	level, hash := p.Level()
+	switch {
+	case level == PrivacyFull || src == "":
+		return src
+	case level > PrivacyAnonymized:
+		return ""
+	case hash:
+		mac := hmac.New(sha256.New, p.key[:])
+		mac.Write([]byte(src))
+		return "h:" + hex.EncodeToString(mac.Sum(nil)[:8])
+	}
+	ip, err := netip.ParseAddr(src)
+	if err != nil {
+		// Not an address, e.g. an already hashed one
+		return src
+	}
+	ip = ip.Unmap()
+	bits := privacyIPv4Bits
+	if ip.Is6() {
+		bits = privacyIPv6Bits
+	}
+	prefix, _ := ip.Prefix(bits)
+	return prefix.Addr().String()
+}
+
+// Subnet returns the client network prefix as the privacy level allows it
+// to be logged, or "" when it may not be. At PrivacyAnonymized, prefixes
+// longer than the anonymized networks are shortened to them, or hashed.
+func (p *Privacy) Subnet(prefix netip.Prefix) string {
+	level, hash := p.Level()
+	switch {
+	case level == PrivacyFull:
+		return prefix.String()
+	case level > PrivacyAnonymized:
+		return ""
+	case hash:
+		return p.Client(prefix.String())
+	}
+	bits := privacyIPv4Bits
+	if prefix.Addr().Is6() {
+		bits = privacyIPv6Bits
+	}
+	if prefix.Bits() > bits {
+		prefix, _ = prefix.Addr().Prefix(bits)
+	}
+	return prefix.String()
+}
+
+// Name returns the query name as the privacy level allows it to be logged,
+// or "" when it may not be.
+func (p *Privacy) Name(name string) string {
+	if level, _ := p.Level(); level >= PrivacyNone {
+		return ""
+	}
+	return name
+}
+
+// Event returns ev with its client and name redacted.
+func (p *Privacy) Event(ev QueryEvent) QueryEvent {
+	ev.Client = p.Client(ev.Client)
+	ev.Name = p.Name(ev.Name)
+	return ev
+}
+
+// Anomaly returns a with its client and name redacted.
+func (p *Privacy) Anomaly(a Anomaly) Anomaly {
+	a.Client = p.Client(a.Client)
+	a.Name = p.Name(a.Name)
+	return a
+}
+
+// CaptureRedaction returns how packet captures must redact client
+// addresses at the privacy level. Hashed addresses cannot be written to a
+// pcap, so captures drop client addresses instead; at PrivacyNone no
+// capture is allowed.
+func (p *Privacy) CaptureRedaction() capture.Redaction {
+	level, hash := p.Level()
+	switch {
+	case level >= PrivacyNone:
+		return capture.RedactRefuse
+	case level >= PrivacyDomains, level == PrivacyAnonymized && hash:
+		return capture.RedactClients
+	case level == PrivacyAnonymized:
+		return capture.RedactTruncate
+	default:
+		return capture.RedactNone
+	}
+}
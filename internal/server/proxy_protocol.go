@@ -0,0 +1,92 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+)
+
+// PROXY protocol v2 (https://www.haproxy.org/download/2.9/doc/proxy-protocol.txt).
+//
+// A load balancer in front of HydraDNS opens its own connection, so the
+// server sees the balancer's address instead of the client's. With the
+// PROXY protocol the balancer prefixes the stream with a binary header
+// carrying the original source address:
+//
+//	+------------------+---------+--------+--------+-----------------+
+//	| signature (12 B) | ver/cmd | family | length | addresses, TLVs |
+//	+------------------+---------+--------+--------+-----------------+
+//
+// Only connections from trusted proxies are expected to carry the header;
+// anyone else could use it to claim an arbitrary source address.
+var proxyV2Signature = []byte{0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A}
+
+const (
+	proxyV2HeaderLen = 16   // Signature, ver/cmd, family, and length
+	proxyV2MaxLen    = 2048 // Largest address block accepted, TLVs included
+
+	proxyV2Version = 0x2
+	proxyV2Local   = 0x0 // Connection from the proxy itself (e.g. health check)
+	proxyV2Proxy   = 0x1 // Connection relayed for a client
+
+	proxyV2INET  = 0x1
+	proxyV2INET6 = 0x2
+)
+
+// errProxyHeader reports a missing or malformed PROXY protocol header.
+var errProxyHeader = errors.New("invalid PROXY protocol v2 header")
+
+// readProxyHeader reads a PROXY protocol v2 header from r and returns the
+// original client address. It returns the zero Addr, without error, for
+// LOCAL connections and for address families other than IPv4 and IPv6;
+// the caller then keeps the connection's own address.
+//
+// Exactly the header is consumed, so the DNS stream follows in r.
+func readProxyHeader(r io.Reader) (netip.Addr, error) {
+	var hdr [proxyV2HeaderLen]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return netip.Addr{}, fmt.Errorf("%w: %w", errProxyHeader, err)
+	}
+	if !bytes.Equal(hdr[:12], proxyV2Signature) {
+		return netip.Addr{}, fmt.Errorf("%w: bad signature", errProxyHeader)
+	}
+	if hdr[12]>>4 != proxyV2Version {
+		return netip.Addr{}, fmt.Errorf("%w: unsupported version %d", errProxyHeader, hdr[12]>>4)
+	}
+	cmd := hdr[12] & 0x0F
+	if cmd != proxyV2Local && cmd != proxyV2Proxy {
+		return netip.Addr{}, fmt.Errorf("%w: unsupported command %d", errProxyHeader, cmd)
+	}
+
+	length := int(binary.BigEndian.Uint16(hdr[14:16]))
+	if length > proxyV2MaxLen {
+		return netip.Addr{}, fmt.Errorf("%w: address block too long (%d)", errProxyHeader, length)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return netip.Addr{}, fmt.Errorf("%w: %w", errProxyHeader, err)
+	}
+
+	if cmd == proxyV2Local {
+		return netip.Addr{}, nil
+	}
+
+	// Source address comes first for both families; the rest (destination
+	// address, ports, and TLVs) is not needed.
+	switch hdr[13] >> 4 {
+	case proxyV2INET:
+		if length < 12 {
+			return netip.Addr{}, fmt.Errorf("%w: short IPv4 address block", errProxyHeader)
+		}
+		return netip.AddrFrom4([4]byte(body[:4])), nil
+	case proxyV2INET6:
+		if length < 36 {
+			return netip.Addr{}, fmt.Errorf("%w: short IPv6 address block", errProxyHeader)
+		}
+		return netip.AddrFrom16([16]byte(body[:16])).Unmap(), nil
+	}
+	return netip.Addr{}, nil
+}
@@ -0,0 +1,161 @@
+package server
+
+import (
+	"net/netip"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/jroosing/hydradns/internal/dns"
+)
+
+// QTypeAction is the response to a query matched by a qtype rule.
+type QTypeAction int
+
+const (
+	// QTypeRefuse answers REFUSED with an Extended DNS Error "Prohibited".
+	QTypeRefuse QTypeAction = iota
+	// QTypeNXDomain answers NXDOMAIN.
+	QTypeNXDomain
+	// QTypeNoData answers NOERROR without records.
+	QTypeNoData
+	// QTypeDrop sends no response.
+	QTypeDrop
+)
+
+// ParseQTypeAction parses a config action name; unknown names refuse.
+func ParseQTypeAction(s string) QTypeAction {
+	switch s {
+	case "nxdomain":
+		return QTypeNXDomain
+	case "nodata":
+		return QTypeNoData
+	case "drop":
+		return QTypeDrop
+	default:
+		return QTypeRefuse
+	}
+}
+
+// String returns the config name of the action.
+func (a QTypeAction) String() string {
+	switch a {
+	case QTypeNXDomain:
+		return "nxdomain"
+	case QTypeNoData:
+		return "nodata"
+	case QTypeDrop:
+		return "drop"
+	default:
+		return "refused"
+	}
+}
+
+// QTypeRule matches queries of the given types from the given clients.
+type QTypeRule struct {
+	QTypes  []dns.RecordType
+	Clients []netip.Prefix // nil matches every client
+	Action  QTypeAction
+}
+
+// String describes the rule, e.g. "ANY,TYPE65535 -> refused" or
+// "PTR from 192.168.50.0/24 -> nxdomain". It keys the rule's counter.
+func (r QTypeRule) String() string {
+	var b strings.Builder
+	for i, t := range r.QTypes {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(t.String())
+	}
+	if len(r.Clients) > 0 {
+		b.WriteString(" from ")
+		for i, p := range r.Clients {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(p.String())
+		}
+	}
+	b.WriteString(" -> ")
+	b.WriteString(r.Action.String())
+	return b.String()
+}
+
+// matches reports whether the rule applies to a query of type qtype from
+// client.
+func (r QTypeRule) matches(qtype dns.RecordType, client netip.Addr) bool {
+	if !slices.Contains(r.QTypes, qtype) {
+		return false
+	}
+	if len(r.Clients) == 0 {
+		return true
+	}
+	if !client.IsValid() {
+		return false
+	}
+	for _, p := range r.Clients {
+		if p.Contains(client) {
+			return true
+		}
+	}
+	return false
+}
+
+// QTypeRuleStats is the number of queries a rule has answered.
+type QTypeRuleStats struct {
+	Rule    string
+	Matched uint64
+}
+
+// QTypePolicy answers queries for some record types with a fixed response
+// before resolution. Rules are checked in order and the first match wins.
+// A nil policy matches nothing.
+//
+// All methods are safe for concurrent use.
+type QTypePolicy struct {
+	rules   []QTypeRule
+	matched []atomic.Uint64
+}
+
+// NewQTypePolicy creates a policy from rules, or returns nil when there are
+// none.
+func NewQTypePolicy(rules []QTypeRule) *QTypePolicy {
+	if len(rules) == 0 {
+		return nil
+	}
+	return &QTypePolicy{
+		rules:   slices.Clone(rules),
+		matched: make([]atomic.Uint64, len(rules)),
+	}
+}
+
+// Match returns the action of the first rule matching a query of type qtype
+// from client (an IP address), counting the match.
+func (p *QTypePolicy) Match(qtype uint16, client string) (QTypeAction, bool) {
+	if p == nil {
+		return 0, false
+	}
+	ip, _ := netip.ParseAddr(client)
+	ip = ip.Unmap()
+	for i, r := range p.rules {
+		if r.matches(dns.RecordType(qtype), ip) {
+			p.matched[i].Add(1)
+			return r.Action, true
+		}
+	}
+	return 0, false
+}
+
+// Stats returns the number of queries each rule has answered, in rule
+// order.
+func (p *QTypePolicy) Stats() []QTypeRuleStats {
+	if p == nil {
+		return nil
+	}
+	out := make([]QTypeRuleStats, len(p.rules))
+	for i, r := range p.rules {
+		out[i] = QTypeRuleStats{Rule: r.String(), Matched: p.matched[i].Load()}
+	}
+	return out
+}
@@ -18,11 +18,17 @@
 package server
 
 import (
+	"cmp"
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"math/rand/v2"
+	"net/netip"
 	"time"
 
 	"github.com/jroosing/hydradns/internal/dns"
+	"github.com/jroosing/hydradns/internal/geoip"
 	"github.com/jroosing/hydradns/internal/resolvers"
 )
 
@@ -33,6 +39,23 @@ type QueryHandler struct {
 	Resolver resolvers.Resolver // The resolver chain to process queries
 	Timeout  time.Duration      // Maximum time for query resolution (default: 4s)
 	Stats    *DNSStats          // Optional statistics collector
+	Events   *QueryEvents       // Optional live query event stream
+	GeoIP    *geoip.DB          // Optional; adds answer countries/ASNs to debug logs
+	Anomaly  *AnomalyDetector   // Optional tunneling/anomaly detection
+	QTypes   *QTypePolicy       // Optional fixed responses by query type
+	Privacy  *Privacy           // Optional; redacts clients and names in query logs and events
+	Slow     *SlowQueryLog      // Optional logging of slow queries
+	Response *ResponsePipeline  // Optional rewriting of responses before they are sent
+	Listener string             // Listener profile served; empty for the main listeners
+	ServerID string             // Optional; names this server in NSID responses
+	Shadow   *ShadowMirror      // Optional mirroring of upstream queries to shadow upstreams
+	Coverage *ClientCoverage    // Optional warning about clients outside the advertised subnets
+
+	// RecursionClients are the clients offered recursion; nil offers it to
+	// all. Queries from other clients, and queries without RD, only get
+	// local answers and REFUSED otherwise. RA is set in responses to
+	// clients offered recursion only.
+	RecursionClients []netip.Prefix
 }
 
 // HandleResult contains the outcome of query processing.
@@ -45,23 +68,47 @@ type HandleResult struct {
 
 // Handle processes a DNS request and returns a response.
 //
+// ctx carries the client that sent the request (see
+// resolvers.WithClientInfo). Handle completes its ClientInfo with the
+// listener and a trace ID, and passes it on to the resolvers.
+//
 // Processing steps:
 //  1. Parse the raw request bytes
 //  2. Forward to resolver with timeout
 //  3. Handle errors (parse, timeout, resolver failure) with SERVFAIL
 //  4. Log request details at debug level
 //
-// The context is checked for cancellation (e.g., server shutdown).
-func (h *QueryHandler) Handle(ctx context.Context, transport string, src string, reqBytes []byte) HandleResult {
+// The response echoes the question with the client's original QNAME case.
+// When the query asks for the name server identifier (RFC 5001), the
+// response carries the trace ID so it can be matched to the logs (see
+// traceResponse).
+//
+// The context is checked for cancellation (e.g., server shutdown). A panic
+// while handling the query is logged with its stack trace and answered with
+// SERVFAIL.
+func (h *QueryHandler) Handle(ctx context.Context, reqBytes []byte) (res HandleResult) {
+	ctx, client := h.queryContext(ctx)
+	transport, src := client.Transport, clientString(client.Addr)
+	defer func() {
+		if v := recover(); v != nil {
+			reportPanic(ctx, h.Logger, h.Stats, v, "transport", transport, "client", src, "trace_id", client.TraceID)
+			if h.Stats != nil {
+				h.Stats.RecordError()
+			}
+			res = panicResult(reqBytes)
+		}
+	}()
+
 	start := time.Now()
 
 	// Record query in stats
 	if h.Stats != nil {
 		h.Stats.RecordQuery(transport)
 	}
+	h.Coverage.Observe(ctx, client.Addr)
 
 	// Step 1: Parse request
-	parsed, err := dns.ParseRequestBounded(reqBytes)
+	parsed, err := h.parseRequest(transport, reqBytes)
 	if err != nil {
 		if h.Stats != nil {
 			h.Stats.RecordError()
@@ -72,12 +119,36 @@ func (h *QueryHandler) Handle(ctx context.Context, transport string, src string,
 	// Extract question info for logging
 	qname, qtype := extractQuestionInfo(parsed)
 
-	// Step 2: Resolve with timeout
-	result := h.resolveWithTimeout(ctx, parsed, reqBytes)
+	// Step 2: Resolve with timeout, unless a qtype rule answers first. Only
+	// recurse when the client asked to and recursion is offered to it.
+	recursion := h.recursionOffered(client.Addr)
+	var result resolvers.Result
+	if action, ok := h.matchQType(parsed, src); ok {
+		if action == QTypeDrop {
+			return HandleResult{Source: "qtype-drop", Parsed: parsed, ParsedOK: true}
+		}
+		result = h.qtypeResult(parsed, action)
+	} else {
+		resolveCtx := ctx
+		if !recursion || !parsed.Header.RecursionDesired() {
+			resolveCtx = resolvers.WithoutRecursion(resolveCtx)
+		}
+		result = h.resolveWithTimeout(resolveCtx, parsed, reqBytes)
+		if result.Source == "upstream" {
+			h.Shadow.Mirror(ctx, parsed, result)
+		}
+	}
+	result.ResponseBytes = h.Response.Process(client.Addr, parsed, result.ResponseBytes)
+	result.ResponseBytes = resolvers.SetRecursionAvailable(result.ResponseBytes, recursion)
+	// Parsing lowercases names, and cached answers carry the case of
+	// whichever client asked first; echo the question exactly as asked.
+	result.ResponseBytes = resolvers.RestoreQuestionCase(result.ResponseBytes, reqBytes)
+	result.ResponseBytes = h.traceResponse(parsed, result.ResponseBytes, client.TraceID)
 
 	// Step 3: Record response stats
+	elapsed := time.Since(start)
 	if h.Stats != nil {
-		h.Stats.RecordLatency(time.Since(start).Nanoseconds())
+		h.Stats.RecordLatency(elapsed.Nanoseconds())
 		// Check response code if we have response bytes
 		if len(result.ResponseBytes) >= 4 {
 			// RCODE is in the lower 4 bits of byte 3
@@ -90,8 +161,22 @@ func (h *QueryHandler) Handle(ctx context.Context, transport string, src string,
 		}
 	}
 
-	// Step 4: Log at debug level
-	h.logRequest(ctx, transport, src, parsed, qname, qtype, len(reqBytes), result.Source)
+	// Step 4: Log at debug level, and slow queries whatever the level
+	h.logRequest(ctx, client, parsed, qname, qtype, len(reqBytes), result)
+	if h.Slow.Slow(elapsed, result.UpstreamTime) {
+		h.logSlowQuery(ctx, client, parsed, result, elapsed)
+	}
+
+	if h.Events.Active() || h.Anomaly != nil {
+		ev := newQueryEvent(start, transport, src, parsed, result)
+		ev.TraceID = client.TraceID
+		if h.Events.Active() {
+			h.Events.Publish(h.Privacy.Event(ev))
+		}
+		if h.Anomaly != nil {
+			h.observeAnomalies(ctx, ev)
+		}
+	}
 
 	return HandleResult{
 		ResponseBytes: result.ResponseBytes,
@@ -101,6 +186,92 @@ func (h *QueryHandler) Handle(ctx context.Context, transport string, src string,
 	}
 }
 
+// queryContext returns ctx carrying the ClientInfo of a query, completed
+// with the listener and, unless the caller set one, a new trace ID.
+func (h *QueryHandler) queryContext(ctx context.Context) (context.Context, resolvers.ClientInfo) {
+	client, _ := resolvers.ClientInfoFromContext(ctx)
+	if client.Listener == "" {
+		client.Listener = h.Listener
+	}
+	if client.TraceID == "" {
+		client.TraceID = newTraceID()
+	}
+	return resolvers.WithClientInfo(ctx, client), client
+}
+
+// newTraceID returns a random 64-bit trace ID in hex.
+func newTraceID() string {
+	return fmt.Sprintf("%016x", rand.Uint64())
+}
+
+// traceResponse adds traceID to resp when the query asks for the name
+// server identifier, as "dig +nsid" does: the NSID option carries
+// "trace=<id>", after the server ID when one is set, and the text of any
+// Extended DNS Error ends with it. Other responses are returned unchanged.
+func (h *QueryHandler) traceResponse(parsed dns.Packet, resp []byte, traceID string) []byte {
+	if len(resp) == 0 || !dns.HasEDNSOption(parsed, dns.EDNSOptionNSID) {
+		return resp
+	}
+	trace := "trace=" + traceID
+	resp = dns.RewriteEDNSOptions(resp, func(opts []dns.EDNSOption) []dns.EDNSOption {
+		kept := opts[:0]
+		for _, o := range opts {
+			switch {
+			case o.Code == dns.EDNSOptionNSID:
+				continue // an upstream's identifier
+			case o.Code == dns.EDNSOptionEDE && len(o.Data) >= 2:
+				if len(o.Data) > 2 {
+					o.Data = append(o.Data, "; "...)
+				}
+				o.Data = append(o.Data, trace...)
+			}
+			kept = append(kept, o)
+		}
+		return kept
+	})
+	nsid := trace
+	if h.ServerID != "" {
+		nsid = h.ServerID + " " + trace
+	}
+	return dns.AppendEDNSOption(resp, dns.EDNSOption{Code: dns.EDNSOptionNSID, Data: []byte(nsid)})
+}
+
+// clientString returns addr as text, or "" when it is unknown.
+func clientString(addr netip.Addr) string {
+	if !addr.IsValid() {
+		return ""
+	}
+	return addr.String()
+}
+
+// recursionOffered reports whether the client at ip may use recursion.
+func (h *QueryHandler) recursionOffered(ip netip.Addr) bool {
+	if len(h.RecursionClients) == 0 {
+		return true
+	}
+	if !ip.IsValid() {
+		return false
+	}
+	ip = ip.Unmap()
+	for _, p := range h.RecursionClients {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRequest parses a request, counting rejections per transport when
+// statistics are enabled.
+func (h *QueryHandler) parseRequest(transport string, reqBytes []byte) (dns.Packet, error) {
+	if h.Stats != nil {
+		if ps := h.Stats.ParseStats(transport); ps != nil {
+			return ps.ParseRequest(reqBytes)
+		}
+	}
+	return dns.ParseRequestBounded(reqBytes)
+}
+
 // handleParseError attempts to build an error response from a malformed request.
 // Returns FORMERR if the header/question could be extracted, or nil if not.
 func (h *QueryHandler) handleParseError(reqBytes []byte) HandleResult {
@@ -123,7 +294,8 @@ func extractQuestionInfo(parsed dns.Packet) (string, int) {
 }
 
 // resolveWithTimeout runs the resolver with a timeout.
-// Returns SERVFAIL on timeout, cancellation, or resolver error.
+// Returns SERVFAIL on timeout or cancellation; resolver errors are answered
+// as errorResult maps them.
 //
 // Design note: This spawns a goroutine per query to enforce timeout without blocking
 // the worker pool. An alternative design would make resolvers context-aware and timeout
@@ -142,6 +314,17 @@ func (h *QueryHandler) resolveWithTimeout(ctx context.Context, parsed dns.Packet
 		err error
 	}, 1)
 	go func() {
+		// A panic here would take down the process, not just this query
+		defer func() {
+			if v := recover(); v != nil {
+				qname, _ := extractQuestionInfo(parsed)
+				reportPanic(ctx, h.Logger, h.Stats, v, "qname", qname)
+				resCh <- struct {
+					res resolvers.Result
+					err error
+				}{err: errResolverPanic}
+			}
+		}()
 		res, err := h.Resolver.Resolve(ctx, parsed, reqBytes)
 		resCh <- struct {
 			res resolvers.Result
@@ -162,15 +345,80 @@ func (h *QueryHandler) resolveWithTimeout(ctx context.Context, parsed dns.Packet
 	case <-ctx.Done():
 		return h.buildErrorResult(parsed, "shutdown", dns.RCodeServFail)
 	case <-timer.C:
-		return h.buildErrorResult(parsed, "timeout", dns.RCodeServFail)
+		return h.errorResult(parsed, resolvers.ErrTimeout)
 	case r := <-resCh:
 		if r.err != nil {
-			return h.buildErrorResult(parsed, "servfail", dns.RCodeServFail)
+			h.logResolveError(ctx, parsed, r.err)
+			return h.errorResult(parsed, r.err)
 		}
 		return r.res
 	}
 }
 
+// errorResult answers a query whose resolution failed with err. This is
+// the one place resolver errors (see resolvers.ErrNotInZone and friends)
+// become response codes and Extended DNS Errors:
+//
+//   - blocked (resolvers.ErrBlocked): NXDOMAIN, EDE Blocked with the reason,
+//     and a SOA when the block sets a negative TTL
+//   - recursion refused: REFUSED
+//   - broken CNAME chain: SERVFAIL, EDE Other with the details
+//   - timeout (resolvers.ErrTimeout): SERVFAIL, EDE No Reachable Authority
+//   - no upstream (resolvers.ErrNoUpstream): SERVFAIL, EDE No Reachable Authority
+//   - anything else, including names no resolver serves: SERVFAIL
+func (h *QueryHandler) errorResult(parsed dns.Packet, err error) resolvers.Result {
+	// The failing stage is for logs, not for the client
+	var stage *resolvers.StageError
+	if errors.As(err, &stage) {
+		err = stage.Err
+	}
+	var blocked *resolvers.BlockedError
+	switch {
+	case errors.Is(err, errResolverPanic):
+		return h.buildErrorResult(parsed, "panic", dns.RCodeServFail)
+	case errors.As(err, &blocked):
+		return h.blockedResult(parsed, blocked)
+	case errors.Is(err, resolvers.ErrBlocked):
+		ede := dns.ExtendedError{InfoCode: dns.EDEBlocked}
+		return h.buildExtendedErrorResult(parsed, "blocked", dns.RCodeNXDomain, ede)
+	case errors.Is(err, resolvers.ErrRecursionRefused):
+		return h.buildErrorResult(parsed, "refused", dns.RCodeRefused)
+	case errors.Is(err, resolvers.ErrCNAMEChain):
+		ede := dns.ExtendedError{InfoCode: dns.EDEOther, ExtraText: err.Error()}
+		return h.buildExtendedErrorResult(parsed, "cname-chain", dns.RCodeServFail, ede)
+	case errors.Is(err, resolvers.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		ede := dns.ExtendedError{InfoCode: dns.EDENoReachableAuthority, ExtraText: "timed out"}
+		return h.buildExtendedErrorResult(parsed, "timeout", dns.RCodeServFail, ede)
+	case errors.Is(err, resolvers.ErrNoUpstream):
+		ede := dns.ExtendedError{InfoCode: dns.EDENoReachableAuthority, ExtraText: resolvers.ErrNoUpstream.Error()}
+		return h.buildExtendedErrorResult(parsed, "no-upstream", dns.RCodeServFail, ede)
+	default:
+		return h.buildErrorResult(parsed, "servfail", dns.RCodeServFail)
+	}
+}
+
+// matchQType checks the question against the qtype rules.
+func (h *QueryHandler) matchQType(parsed dns.Packet, src string) (QTypeAction, bool) {
+	if len(parsed.Questions) == 0 {
+		return 0, false
+	}
+	return h.QTypes.Match(parsed.Questions[0].Type, src)
+}
+
+// qtypeResult builds the response a qtype rule prescribes.
+func (h *QueryHandler) qtypeResult(parsed dns.Packet, action QTypeAction) resolvers.Result {
+	source := "qtype-" + action.String()
+	switch action {
+	case QTypeNXDomain:
+		return h.buildErrorResult(parsed, source, dns.RCodeNXDomain)
+	case QTypeNoData:
+		return h.buildErrorResult(parsed, source, dns.RCodeNoError)
+	default:
+		ede := dns.ExtendedError{InfoCode: dns.EDEProhibited, ExtraText: "query type blocked by policy"}
+		return h.buildExtendedErrorResult(parsed, source, dns.RCodeRefused, ede)
+	}
+}
+
 // buildErrorResult builds an error response for a given parsed packet.
 func (h *QueryHandler) buildErrorResult(parsed dns.Packet, source string, rcode dns.RCode) resolvers.Result {
 	return resolvers.Result{
@@ -179,30 +427,177 @@ func (h *QueryHandler) buildErrorResult(parsed dns.Packet, source string, rcode
 	}
 }
 
-// logRequest logs DNS request details at debug level.
+// buildExtendedErrorResult builds an error response carrying an Extended
+// DNS Error (RFC 8914) for clients that sent EDNS.
+func (h *QueryHandler) buildExtendedErrorResult(
+	parsed dns.Packet,
+	source string,
+	rcode dns.RCode,
+	ede dns.ExtendedError,
+) resolvers.Result {
+	resp := dns.AddExtendedError(dns.BuildErrorResponse(parsed, uint16(rcode)), parsed, ede)
+	return resolvers.Result{
+		ResponseBytes: mustMarshal(resp),
+		Source:        source,
+	}
+}
+
+// blockedResult answers a blocked query with NXDOMAIN and an Extended DNS
+// Error naming the reason. With a negative TTL set, the authority section
+// holds a SOA so clients cache the block for that long only.
+func (h *QueryHandler) blockedResult(parsed dns.Packet, blocked *resolvers.BlockedError) resolvers.Result {
+	resp := dns.BuildErrorResponse(parsed, uint16(dns.RCodeNXDomain))
+	if blocked.NegativeTTL > 0 && len(parsed.Questions) > 0 {
+		q := parsed.Questions[0]
+		resp.Authorities = append(resp.Authorities, resolvers.BlockedSOA(q.Name, dns.RecordClass(q.Class), blocked.NegativeTTL))
+	}
+	ede := dns.ExtendedError{InfoCode: dns.EDEBlocked, ExtraText: blocked.Reason}
+	return resolvers.Result{
+		ResponseBytes: mustMarshal(dns.AddExtendedError(resp, parsed, ede)),
+		Source:        cmp.Or(blocked.Source, "blocked"),
+	}
+}
+
+// logResolveError logs a failed resolution at debug level, with the
+// resolver stage that failed when the resolver is a Chained one.
+func (h *QueryHandler) logResolveError(ctx context.Context, parsed dns.Packet, err error) {
+	if h.Logger == nil || !h.Logger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+	qname, qtype := extractQuestionInfo(parsed)
+	attrs := []any{"qname", h.Privacy.Name(qname), "qtype", qtype, "err", err}
+	if client, ok := resolvers.ClientInfoFromContext(ctx); ok {
+		attrs = append(attrs, "trace_id", client.TraceID)
+	}
+	var stage *resolvers.StageError
+	if errors.As(err, &stage) {
+		attrs = append(attrs, "stage", stage.Stage)
+	}
+	h.Logger.DebugContext(ctx, "resolve failed", attrs...)
+}
+
+// logRequest logs DNS request details at debug level, with the client
+// redacted as h.Privacy prescribes, and nothing at PrivacyNone.
 func (h *QueryHandler) logRequest(
 	ctx context.Context,
-	transport, src string,
+	client resolvers.ClientInfo,
 	parsed dns.Packet,
 	qname string,
 	qtype int,
 	reqLen int,
-	source string,
+	result resolvers.Result,
 ) {
 	if h.Logger == nil || !h.Logger.Enabled(ctx, slog.LevelDebug) {
 		return
 	}
-	h.Logger.DebugContext(
-		ctx,
-		"dns request",
-		"transport", transport,
-		"src", src,
+	if level, _ := h.Privacy.Level(); level >= PrivacyNone {
+		return
+	}
+	attrs := []any{
+		"transport", client.Transport,
+		"src", h.Privacy.Client(clientString(client.Addr)),
+		"trace_id", client.TraceID,
 		"id", int(parsed.Header.ID),
 		"qname", qname,
 		"qtype", qtype,
 		"bytes", reqLen,
-		"source", source,
-	)
+		"source", result.Source,
+	}
+	if client.Listener != "" {
+		attrs = append(attrs, "listener", client.Listener)
+	}
+	if geo := h.answerGeoIP(result.ResponseBytes); len(geo) > 0 {
+		attrs = append(attrs, "answer_geo", geo)
+	}
+	h.Logger.DebugContext(ctx, "dns request", attrs...)
+}
+
+// logSlowQuery writes a query that took elapsed to the slow query log, with
+// the client and name redacted as h.Privacy prescribes.
+func (h *QueryHandler) logSlowQuery(
+	ctx context.Context,
+	client resolvers.ClientInfo,
+	parsed dns.Packet,
+	result resolvers.Result,
+	elapsed time.Duration,
+) {
+	q := SlowQuery{
+		Transport:    client.Transport,
+		Client:       h.Privacy.Client(clientString(client.Addr)),
+		TraceID:      client.TraceID,
+		ID:           parsed.Header.ID,
+		Source:       result.Source,
+		Bytes:        len(result.ResponseBytes),
+		Duration:     elapsed,
+		Upstream:     result.Upstream,
+		UpstreamTime: result.UpstreamTime,
+	}
+	if len(parsed.Questions) > 0 {
+		q.Name = h.Privacy.Name(parsed.Questions[0].Name)
+		q.Type = dns.RecordType(parsed.Questions[0].Type)
+	}
+	if len(result.ResponseBytes) >= 4 {
+		q.RCode = dns.RCode(result.ResponseBytes[3] & 0x0F)
+	}
+	h.Slow.Log(ctx, q)
+}
+
+// answerGeoIP returns "address=geo" entries for the A and AAAA answers of
+// resp that the GeoIP databases know about.
+func (h *QueryHandler) answerGeoIP(resp []byte) []string {
+	if h.GeoIP == nil {
+		return nil
+	}
+	parsed, err := dns.ParsePacket(resp)
+	if err != nil {
+		return nil
+	}
+	var out []string
+	for _, ip := range resolvers.AnswerAddrs(parsed.Answers) {
+		if info := h.GeoIP.Lookup(ip); !info.IsZero() {
+			out = append(out, ip.String()+"="+info.String())
+		}
+	}
+	return out
+}
+
+// newQueryEvent describes the outcome of a query.
+func newQueryEvent(
+	start time.Time,
+	transport, src string,
+	parsed dns.Packet,
+	result resolvers.Result,
+) QueryEvent {
+	ev := QueryEvent{
+		Time:      start,
+		Transport: transport,
+		Client:    src,
+		Source:    result.Source,
+		Duration:  time.Since(start),
+	}
+	if len(parsed.Questions) > 0 {
+		ev.Name = parsed.Questions[0].Name
+		ev.Type = dns.RecordType(parsed.Questions[0].Type)
+	}
+	if len(result.ResponseBytes) >= 4 {
+		ev.RCode = dns.RCode(result.ResponseBytes[3] & 0x0F)
+	}
+	return ev
+}
+
+// observeAnomalies feeds a query to the anomaly detector and logs the
+// alerts it raises.
+func (h *QueryHandler) observeAnomalies(ctx context.Context, ev QueryEvent) {
+	for _, a := range h.Anomaly.Observe(ev) {
+		if h.Logger != nil {
+			h.Logger.WarnContext(ctx, "dns anomaly",
+				"kind", string(a.Kind),
+				"client", a.Client,
+				"qname", a.Name,
+				"detail", a.Detail,
+			)
+		}
+	}
 }
 
 // mustMarshal serializes a DNS packet, returning nil on error.
@@ -2,10 +2,14 @@ package server
 
 import (
 	"fmt"
+	"hash/maphash"
 	"math"
 	"net/netip"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/jroosing/hydradns/internal/clock"
 )
 
 // RateLimiter implements pre-parse admission control using token bucket rate limiting.
@@ -25,7 +29,7 @@ import (
 //     - Single global limit for all clients combined
 //     - Prevents total server overload
 //
-//  2. Prefix: Per-network prefix limit (/24 for IPv4, /64 for IPv6)
+//  2. Prefix: Per-network prefix limit (/24 for IPv4, /64 for IPv6 by default)
 //     - Groups clients by network (e.g., 192.0.2.0/24)
 //     - Prevents single subnet from overwhelming the server
 //     - Still allows multiple clients in the same network
@@ -43,12 +47,48 @@ import (
 //
 // A request is allowed if there are available tokens at all three levels.
 // Bursts up to the configured limit are allowed, then rate-limited back to QPS.
+//
+// Slip:
+//
+// A spoofed-source flood exhausts the buckets of the victim's address, so
+// dropping everything over the limit also drops the victim's own queries.
+// With Slip set, AdmitAddr lets every Nth query refused by the prefix or IP
+// limit through as AdmitSlip; the UDP server answers it with an empty
+// truncated response, which is no larger than the query (so useless for
+// amplification) but makes a real client retry over TCP. Queries over the
+// global limit are always dropped, since the server itself is overloaded.
+//
+// Local Exemption:
+//
+// With ExemptLocal set, link-local (169.254.0.0/16, fe80::/10) and unique
+// local (fc00::/7) sources skip the prefix and IP limits. They can only be
+// on-link or inside the site, so they are the LAN's own clients; the global
+// limit still applies.
 type RateLimiter struct {
 	global *TokenBucketRateLimiter // Server-wide rate limit
 	prefix *TokenBucketRateLimiter // Per network prefix rate limit
 	ip     *TokenBucketRateLimiter // Per source IP rate limit
+
+	v4Bits      int  // IPv4 prefix length for the prefix limit
+	v6Bits      int  // IPv6 prefix length for the prefix limit
+	exemptLocal bool // Link-local and ULA sources skip the prefix and IP limits
+
+	slip    uint64        // Every slip-th refused query slips; 0 disables
+	refused atomic.Uint64 // Queries refused by the prefix or IP limit
 }
 
+// Admission is the rate limiter's decision for one query.
+type Admission int
+
+const (
+	// AdmitAllow lets the query through.
+	AdmitAllow Admission = iota
+	// AdmitDrop drops the query without a response.
+	AdmitDrop
+	// AdmitSlip answers the query with an empty truncated response.
+	AdmitSlip
+)
+
 // RateLimitSettings contains rate limiting configuration values.
 // This is used to create a RateLimiter from configuration.
 type RateLimitSettings struct {
@@ -61,8 +101,19 @@ type RateLimitSettings struct {
 	PrefixBurst      int
 	IPQPS            float64
 	IPBurst          int
+	Slip             int         // Every Nth query refused by the prefix or IP limit slips; 0 disables
+	IPv4PrefixLen    int         // IPv4 prefix length for the prefix limit; 0 means 24
+	IPv6PrefixLen    int         // IPv6 prefix length for the prefix limit; 0 means 64
+	ExemptLocal      bool        // Link-local and ULA sources skip the prefix and IP limits
+	Clock            clock.Clock // Time source for token replenishment; nil uses the system clock
 }
 
+// Default prefix lengths for the prefix rate limit.
+const (
+	DefaultIPv4PrefixLen = 24
+	DefaultIPv6PrefixLen = 64
+)
+
 // NewRateLimiter creates a RateLimiter from the provided settings.
 func NewRateLimiter(s RateLimitSettings) *RateLimiter {
 	cleanupInterval := time.Duration(math.Max(0.0, s.CleanupSeconds) * float64(time.Second))
@@ -72,7 +123,13 @@ func NewRateLimiter(s RateLimitSettings) *RateLimiter {
 
 	return &RateLimiter{
 		global: NewTokenBucketRateLimiter(
-			TokenBucketConfig{Rate: s.GlobalQPS, Burst: s.GlobalBurst, CleanupInterval: cleanupInterval, MaxEntries: 1},
+			TokenBucketConfig{
+				Rate:            s.GlobalQPS,
+				Burst:           s.GlobalBurst,
+				CleanupInterval: cleanupInterval,
+				MaxEntries:      1,
+				Clock:           s.Clock,
+			},
 		),
 		prefix: NewTokenBucketRateLimiter(
 			TokenBucketConfig{
@@ -80,6 +137,7 @@ func NewRateLimiter(s RateLimitSettings) *RateLimiter {
 				Burst:           s.PrefixBurst,
 				CleanupInterval: cleanupInterval,
 				MaxEntries:      s.MaxPrefixEntries,
+				Clock:           s.Clock,
 			},
 		),
 		ip: NewTokenBucketRateLimiter(
@@ -88,29 +146,38 @@ func NewRateLimiter(s RateLimitSettings) *RateLimiter {
 				Burst:           s.IPBurst,
 				CleanupInterval: cleanupInterval,
 				MaxEntries:      s.MaxIPEntries,
+				Clock:           s.Clock,
 			},
 		),
+		v4Bits:      prefixLen(s.IPv4PrefixLen, DefaultIPv4PrefixLen, 32),
+		v6Bits:      prefixLen(s.IPv6PrefixLen, DefaultIPv6PrefixLen, 128),
+		exemptLocal: s.ExemptLocal,
+		slip:        uint64(max(0, s.Slip)), //nolint:gosec // clamped to non-negative
 	}
 }
 
+// prefixLen returns bits, or def when bits is outside 1..maxBits.
+func prefixLen(bits, def, maxBits int) int {
+	if bits <= 0 || bits > maxBits {
+		return def
+	}
+	return bits
+}
+
 // Allow checks if a request from srcIP should be allowed.
 // Returns true if the request passes all rate limit levels.
 func (r *RateLimiter) Allow(srcIP string) bool {
 	if r == nil {
 		return true
 	}
-	// Check in order: global -> prefix -> IP
-	// Fail fast: if global limit is exceeded, don't check others
-	if !r.global.Allow("*") {
-		return false
-	}
-	if !r.prefix.Allow(prefixKey(srcIP)) {
-		return false
+	if ip, err := netip.ParseAddr(srcIP); err == nil {
+		return r.AllowAddr(ip)
 	}
-	if !r.ip.Allow(srcIP) {
+	// Unknown format: the address is its own prefix
+	if !r.global.Allow("*") {
 		return false
 	}
-	return true
+	return r.prefix.Allow("ip:"+srcIP) && r.ip.Allow(srcIP)
 }
 
 // AllowAddr checks if a request from the given netip.Addr should be allowed.
@@ -123,9 +190,10 @@ func (r *RateLimiter) AllowAddr(ip netip.Addr) bool {
 	if !r.global.Allow("*") {
 		return false
 	}
-	// For prefix, extract the prefix key without string allocation
-	prefixKey := prefixKeyFromAddr(ip)
-	if !r.prefix.Allow(prefixKey) {
+	if r.exempt(ip) {
+		return true
+	}
+	if !r.prefix.Allow(r.prefixKey(ip)) {
 		return false
 	}
 	// For IP, use the string representation (unavoidable for map key)
@@ -133,17 +201,51 @@ func (r *RateLimiter) AllowAddr(ip netip.Addr) bool {
 	return r.ip.Allow(ipKey)
 }
 
-// prefixKeyFromAddr returns the prefix key for a netip.Addr.
-// Uses /24 for IPv4 and /64 for IPv6.
-func prefixKeyFromAddr(ip netip.Addr) string {
+// AdmitAddr decides whether a query from ip is allowed, dropped, or, when
+// slip is configured and the prefix or IP limit refused it, answered with
+// a truncated response.
+func (r *RateLimiter) AdmitAddr(ip netip.Addr) Admission {
+	if r == nil {
+		return AdmitAllow
+	}
+	if !r.global.Allow("*") {
+		return AdmitDrop
+	}
+	if r.exempt(ip) {
+		return AdmitAllow
+	}
+	if r.prefix.Allow(r.prefixKey(ip)) && r.ip.Allow(ip.String()) {
+		return AdmitAllow
+	}
+	if r.slip > 0 && r.refused.Add(1)%r.slip == 0 {
+		return AdmitSlip
+	}
+	return AdmitDrop
+}
+
+// prefixKey returns the prefix limit key for ip: its network at the
+// configured IPv4 or IPv6 prefix length. IPv4-mapped IPv6 addresses are
+// grouped as IPv4.
+func (r *RateLimiter) prefixKey(ip netip.Addr) string {
+	ip = ip.Unmap()
+	bits := r.v6Bits
 	if ip.Is4() {
-		prefix, _ := ip.Prefix(24)
-		return prefix.String()
+		bits = r.v4Bits
 	}
-	prefix, _ := ip.Prefix(64)
+	prefix, _ := ip.Prefix(bits)
 	return prefix.String()
 }
 
+// exempt reports whether ip skips the prefix and IP limits: a link-local
+// or unique local (fc00::/7) address when ExemptLocal is set.
+func (r *RateLimiter) exempt(ip netip.Addr) bool {
+	if !r.exemptLocal {
+		return false
+	}
+	ip = ip.Unmap()
+	return ip.IsLinkLocalUnicast() || (ip.Is6() && ip.IsPrivate())
+}
+
 // FormatRateLimitsLog returns a human-readable summary of rate limit configuration.
 func FormatRateLimitsLog(s RateLimitSettings) string {
 	fmtLimiter := func(name string, rate float64, burst int) string {
@@ -154,13 +256,17 @@ func FormatRateLimitsLog(s RateLimitSettings) string {
 	}
 
 	return fmt.Sprintf(
-		"%s %s %s cleanup_s=%g max_ip=%d max_prefix=%d",
+		"%s %s %s cleanup_s=%g max_ip=%d max_prefix=%d slip=%d prefix_len=/%d,/%d exempt_local=%t",
 		fmtLimiter("global", s.GlobalQPS, s.GlobalBurst),
 		fmtLimiter("prefix", s.PrefixQPS, s.PrefixBurst),
 		fmtLimiter("ip", s.IPQPS, s.IPBurst),
 		s.CleanupSeconds,
 		s.MaxIPEntries,
 		s.MaxPrefixEntries,
+		s.Slip,
+		prefixLen(s.IPv4PrefixLen, DefaultIPv4PrefixLen, 32),
+		prefixLen(s.IPv6PrefixLen, DefaultIPv6PrefixLen, 128),
+		s.ExemptLocal,
 	)
 }
 
@@ -169,7 +275,8 @@ type TokenBucketConfig struct {
 	Rate            float64       // Tokens replenished per second (queries per second)
 	Burst           int           // Maximum tokens (burst capacity)
 	CleanupInterval time.Duration // How often to clean up stale entries
-	MaxEntries      int           // Maximum tracked keys (prevents memory exhaustion)
+	MaxEntries      int           // Maximum tracked keys, split evenly across shards (prevents memory exhaustion)
+	Clock           clock.Clock   // Time source for replenishment and cleanup; nil uses the system clock
 }
 
 // TokenBucketRateLimiter implements the token bucket algorithm for rate limiting.
@@ -183,16 +290,42 @@ type TokenBucketConfig struct {
 //
 // This allows short bursts up to Burst requests, while limiting
 // the long-term average to Rate requests per second.
+//
+// Keys are spread over up to 64 shards by hash, each with its own lock and
+// an equal share of MaxEntries, so concurrent packets from different
+// clients rarely contend. A limiter with few entries, such as the global
+// one, has a single shard.
 type TokenBucketRateLimiter struct {
 	rate            float64       // Tokens added per second
 	burst           float64       // Maximum tokens in bucket
 	cleanupInterval time.Duration // Time between stale entry cleanup
-	maxEntries      int           // Maximum tracked keys
+	clock           clock.Clock   // Time source for replenishment and cleanup
 
-	mu          sync.Mutex           // Protects all fields below
-	lastCleanup time.Time            // When cleanup was last run
-	lastUpdate  map[string]time.Time // Last access time per key
-	tokens      map[string]float64   // Current token count per key
+	seed   maphash.Seed // Hash seed for picking a key's shard
+	shards []tokenBucketShard
+}
+
+// Sharding bounds for TokenBucketRateLimiter.
+const (
+	maxTokenBucketShards = 64  // Power of two, so a shard is picked by mask
+	minShardEntries      = 256 // Smallest per-shard capacity worth splitting for
+)
+
+// tokenBucketShard holds the buckets for one hash range of keys.
+type tokenBucketShard struct {
+	mu          sync.Mutex              // Protects all fields below
+	lastCleanup time.Time               // When cleanup was last run
+	maxEntries  int                     // Maximum tracked keys in this shard
+	buckets     map[string]*tokenBucket // Bucket per key
+
+	_ [64]byte // Keep neighbouring shards' locks off one cache line
+}
+
+// tokenBucket is the state of one key's bucket. It is updated in place, so
+// an Allow for a known key costs one map lookup and no map write.
+type tokenBucket struct {
+	tokens float64   // Current token count
+	last   time.Time // Last access time
 }
 
 // NewTokenBucketRateLimiter creates a new rate limiter with the given configuration.
@@ -205,14 +338,31 @@ func NewTokenBucketRateLimiter(cfg TokenBucketConfig) *TokenBucketRateLimiter {
 	if ci <= 0 {
 		ci = 60 * time.Second
 	}
+
+	clk := cfg.Clock
+	if clk == nil {
+		clk = clock.System
+	}
+
+	n := 1
+	for n < maxTokenBucketShards && maxEntries/(n*2) >= minShardEntries {
+		n *= 2
+	}
+	now := clk.Now()
+	shards := make([]tokenBucketShard, n)
+	for i := range shards {
+		shards[i].lastCleanup = now
+		shards[i].maxEntries = (maxEntries + n - 1) / n
+		shards[i].buckets = map[string]*tokenBucket{}
+	}
+
 	return &TokenBucketRateLimiter{
 		rate:            cfg.Rate,
 		burst:           float64(cfg.Burst),
 		cleanupInterval: ci,
-		maxEntries:      maxEntries,
-		lastCleanup:     time.Now(),
-		lastUpdate:      map[string]time.Time{},
-		tokens:          map[string]float64{},
+		clock:           clk,
+		seed:            maphash.MakeSeed(),
+		shards:          shards,
 	}
 }
 
@@ -226,104 +376,63 @@ func (l *TokenBucketRateLimiter) Allow(key string) bool {
 		return true
 	}
 
-	now := time.Now()
+	now := l.clock.Now()
+	sh := l.shard(key)
 
-	l.mu.Lock()
-	defer l.mu.Unlock()
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
 
 	// Periodic cleanup of stale entries
-	if now.Sub(l.lastCleanup) > l.cleanupInterval {
-		l.cleanupLocked(now)
+	if now.Sub(sh.lastCleanup) > l.cleanupInterval {
+		sh.cleanupLocked(now, l.cleanupInterval)
 	}
 
-	// Check if this is a new key
-	last, exists := l.lastUpdate[key]
-
-	// Ensure we don't exceed max entries
-	if !exists && len(l.lastUpdate) >= l.maxEntries {
-		l.cleanupLocked(now)
-		if len(l.lastUpdate) >= l.maxEntries {
-			// Still at capacity - deny new entries
-			return false
+	b, exists := sh.buckets[key]
+	if !exists {
+		// Ensure we don't exceed max entries
+		if len(sh.buckets) >= sh.maxEntries {
+			sh.cleanupLocked(now, l.cleanupInterval)
+			if len(sh.buckets) >= sh.maxEntries {
+				// Still at capacity - deny new entries
+				return false
+			}
 		}
 		// Initialize new key with full bucket minus 1 token
-		l.lastUpdate[key] = now
-		l.tokens[key] = l.burst - 1.0
+		sh.buckets[key] = &tokenBucket{tokens: l.burst - 1.0, last: now}
 		return true
 	}
 
-	// Replenish tokens based on elapsed time
-	elapsed := now.Sub(last).Seconds()
-	l.lastUpdate[key] = now
-
-	tokens := l.tokens[key]
-	if elapsed > 0 {
-		// Add tokens for elapsed time, capped at burst
-		tokens = math.Min(l.burst, tokens+(elapsed*l.rate))
+	// Replenish tokens based on elapsed time, capped at burst
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(l.burst, b.tokens+(elapsed*l.rate))
 	}
+	b.last = now
 
 	// Check if we have tokens available
-	if tokens >= 1.0 {
-		l.tokens[key] = tokens - 1.0
+	if b.tokens >= 1.0 {
+		b.tokens--
 		return true
 	}
-
-	l.tokens[key] = tokens
 	return false
 }
 
-// cleanupLocked removes entries that haven't been accessed recently.
-// Must be called with l.mu held.
-func (l *TokenBucketRateLimiter) cleanupLocked(now time.Time) {
-	staleBefore := now.Add(-l.cleanupInterval)
-	for k, last := range l.lastUpdate {
-		if !last.After(staleBefore) {
-			delete(l.lastUpdate, k)
-			delete(l.tokens, k)
-		}
+// shard returns the shard that holds key.
+func (l *TokenBucketRateLimiter) shard(key string) *tokenBucketShard {
+	if len(l.shards) == 1 {
+		return &l.shards[0]
 	}
-	l.lastCleanup = now
+	h := maphash.String(l.seed, key)
+	return &l.shards[h&uint64(len(l.shards)-1)] //nolint:gosec // len is a positive power of two
 }
 
-// prefixKey converts an IP address to a network prefix key.
-// IPv4 addresses are converted to /24 prefixes.
-// IPv6 addresses are converted to /64 prefixes.
-func prefixKey(ip string) string {
-	// Scan once to determine IP type and find dot positions
-	var dotPositions [3]int
-	dotCount := 0
-	hasColon := false
-
-	for i := range len(ip) {
-		switch ip[i] {
-		case '.':
-			if dotCount < 3 {
-				dotPositions[dotCount] = i
-				dotCount++
-			}
-		case ':':
-			hasColon = true
-		}
-	}
-
-	// Fast path for IPv4 (has dots, no colons)
-	if dotCount >= 3 && !hasColon {
-		// Extract first 3 octets without allocation via Split
-		return "v4:" + ip[:dotPositions[2]] + ".0/24"
-	}
-
-	// IPv6 handling
-	if hasColon {
-		addr, err := netip.ParseAddr(ip)
-		if err == nil {
-			pfx, err := addr.Prefix(64)
-			if err == nil {
-				return "v6:" + pfx.Masked().Addr().String() + "/64"
-			}
+// cleanupLocked removes entries that haven't been accessed within interval.
+// Must be called with sh.mu held.
+func (sh *tokenBucketShard) cleanupLocked(now time.Time, interval time.Duration) {
+	staleBefore := now.Add(-interval)
+	for k, b := range sh.buckets {
+		if !b.last.After(staleBefore) {
+			delete(sh.buckets, k)
 		}
-		return "v6:" + ip
 	}
-
-	// Unknown format
-	return "ip:" + ip
+	sh.lastCleanup = now
 }
@@ -0,0 +1,330 @@
+package server
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jroosing/hydradns/internal/config"
+	"github.com/jroosing/hydradns/internal/dns"
+	"github.com/jroosing/hydradns/internal/helpers"
+)
+
+// RecordCheck is the health check of one custom DNS host (see
+// config.RecordHealthCheck).
+type RecordCheck struct {
+	Name     string // Canonical host name
+	Type     string // config.HealthCheckTCP or config.HealthCheckHTTP
+	Port     int
+	Path     string // HTTP request path
+	Interval time.Duration
+	Timeout  time.Duration
+	Failures int // Failed probes in a row after which an address is down
+}
+
+// String describes the probe, e.g. "tcp:5432" or "http:8080/healthz".
+func (c RecordCheck) String() string {
+	return c.Type + ":" + strconv.Itoa(c.Port) + c.Path
+}
+
+// RecordTargetStatus is the health of one address of a checked host.
+type RecordTargetStatus struct {
+	Name                string
+	Addr                netip.Addr
+	Check               string
+	Healthy             bool
+	ConsecutiveFailures int
+	Error               string    // Why the last probe failed; empty on success
+	CheckedAt           time.Time // Zero before the first probe
+	ChangedAt           time.Time // When Healthy last changed; zero if never
+}
+
+// recordTarget is one address of a checked host.
+type recordTarget struct {
+	name string
+	addr netip.Addr
+}
+
+// recordState is the probe state of a recordTarget, guarded by
+// RecordHealth.mu.
+type recordState struct {
+	check     RecordCheck
+	healthy   bool
+	failures  int
+	err       string
+	checkedAt time.Time
+	changedAt time.Time
+	next      time.Time // When the next probe is due
+	probing   bool
+}
+
+// RecordHealth probes the addresses of custom DNS hosts over TCP or HTTP
+// and reports which are healthy, so the custom DNS resolver can leave the
+// others out of answers: failover between two backends of a homelab
+// service without a load balancer.
+//
+// An address goes down after Failures failed probes in a row and comes
+// back after one passing probe. Addresses start healthy, so a restart does
+// not drop answers before the first probe, and addresses without a check
+// are always healthy.
+//
+// All methods are safe for concurrent use. It implements
+// resolvers.AddressHealth.
+type RecordHealth struct {
+	logger *slog.Logger
+	client *http.Client
+
+	mu      sync.RWMutex
+	targets map[recordTarget]*recordState
+	names   map[string]bool // Names with at least one target
+}
+
+// NewRecordHealth creates a prober with no targets.
+func NewRecordHealth(logger *slog.Logger) *RecordHealth {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// Probes are seconds apart; a fresh connection each time also checks
+	// that the backend still accepts them.
+	transport.DisableKeepAlives = true
+	transport.Proxy = nil
+	return &RecordHealth{
+		logger: logger,
+		client: &http.Client{
+			Transport: transport,
+			// A redirect answers the probe; following it would check
+			// another server.
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		targets: make(map[recordTarget]*recordState),
+	}
+}
+
+// SetTargets replaces the checks and the hosts they apply to; hosts maps
+// names to addresses as in config.CustomDNSConfig.Hosts. Addresses whose
+// check is unchanged keep their health; new ones start healthy and are
+// probed at the next tick of Run.
+func (h *RecordHealth) SetTargets(checks []RecordCheck, hosts map[string][]string) {
+	byName := make(map[string]RecordCheck, len(checks))
+	for _, c := range checks {
+		byName[c.Name] = c
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	targets := make(map[recordTarget]*recordState)
+	names := make(map[string]bool)
+	for raw, ips := range hosts {
+		name, err := dns.CanonicalName(raw)
+		if err != nil {
+			continue
+		}
+		check, ok := byName[name]
+		if !ok {
+			continue
+		}
+		for _, ip := range ips {
+			addr, err := netip.ParseAddr(strings.TrimSpace(ip))
+			if err != nil {
+				continue
+			}
+			t := recordTarget{name: name, addr: addr.Unmap()}
+			names[name] = true
+			if st, ok := h.targets[t]; ok && st.check == check {
+				targets[t] = st
+				continue
+			}
+			targets[t] = &recordState{check: check, healthy: true}
+		}
+	}
+	h.targets = targets
+	h.names = names
+}
+
+// Healthy reports whether addr of name passes its health check. Addresses
+// without a check are healthy.
+func (h *RecordHealth) Healthy(name string, addr netip.Addr) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	st, ok := h.targets[recordTarget{name: name, addr: addr.Unmap()}]
+	return !ok || st.healthy
+}
+
+// Checked reports whether name has addresses with a health check.
+func (h *RecordHealth) Checked(name string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.names[name]
+}
+
+// Run probes each address every interval of its check until ctx is
+// canceled.
+func (h *RecordHealth) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		for t, st := range h.due(time.Now()) {
+			wg.Go(func() { h.probe(ctx, t, st) })
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check probes every address at once and waits for the results.
+func (h *RecordHealth) Check(ctx context.Context) {
+	var wg sync.WaitGroup
+	for t, st := range h.due(time.Time{}) {
+		wg.Go(func() { h.probe(ctx, t, st) })
+	}
+	wg.Wait()
+}
+
+// due marks the targets whose next probe is due at now as being probed and
+// returns them. A zero now selects every target not being probed.
+func (h *RecordHealth) due(now time.Time) map[recordTarget]*recordState {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	out := make(map[recordTarget]*recordState)
+	for t, st := range h.targets {
+		if st.probing || (!now.IsZero() && now.Before(st.next)) {
+			continue
+		}
+		st.probing = true
+		out[t] = st
+	}
+	return out
+}
+
+// probe checks one target and records the result.
+func (h *RecordHealth) probe(ctx context.Context, t recordTarget, st *recordState) {
+	h.mu.RLock()
+	check := st.check
+	h.mu.RUnlock()
+
+	pctx, cancel := context.WithTimeout(ctx, check.Timeout)
+	err := probeAddr(pctx, h.client, t, check)
+	cancel()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	st.probing = false
+	if ctx.Err() != nil {
+		return // shutting down; the result says nothing about the backend
+	}
+	now := time.Now()
+	st.checkedAt = now
+	st.next = now.Add(check.Interval)
+	if h.targets[t] != st {
+		return // replaced by SetTargets while probing
+	}
+
+	wasHealthy := st.healthy
+	if err == nil {
+		st.err = ""
+		st.failures = 0
+		st.healthy = true
+	} else {
+		st.err = err.Error()
+		st.failures++
+		if st.failures >= check.Failures {
+			st.healthy = false
+		}
+	}
+	if st.healthy == wasHealthy {
+		return
+	}
+	st.changedAt = now
+
+	if h.logger == nil {
+		return
+	}
+	if st.healthy {
+		h.logger.Info("custom DNS address recovered", "name", t.name, "addr", t.addr, "check", check.String())
+	} else {
+		h.logger.Warn("custom DNS address unhealthy, leaving it out of answers",
+			"name", t.name, "addr", t.addr, "check", check.String(),
+			"failures", st.failures, "err", st.err)
+	}
+}
+
+// probeAddr runs check against one address.
+func probeAddr(ctx context.Context, client *http.Client, t recordTarget, check RecordCheck) error {
+	hostPort := netip.AddrPortFrom(t.addr, helpers.ClampIntToUint16(check.Port)).String()
+
+	if check.Type != config.HealthCheckHTTP {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", hostPort)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+hostPort+check.Path, nil)
+	if err != nil {
+		return err
+	}
+	// Name-based virtual hosts answer for the checked name
+	req.Host = net.JoinHostPort(t.name, strconv.Itoa(check.Port))
+	if check.Port == 80 {
+		req.Host = t.name
+	}
+	req.Header.Set("User-Agent", "HydraDNS-health-check")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("HTTP status %s", resp.Status)
+	}
+	return nil
+}
+
+// Status returns the health of every checked address, sorted by name and
+// address.
+func (h *RecordHealth) Status() []RecordTargetStatus {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	out := make([]RecordTargetStatus, 0, len(h.targets))
+	for t, st := range h.targets {
+		out = append(out, RecordTargetStatus{
+			Name:                t.name,
+			Addr:                t.addr,
+			Check:               st.check.String(),
+			Healthy:             st.healthy,
+			ConsecutiveFailures: st.failures,
+			Error:               st.err,
+			CheckedAt:           st.checkedAt,
+			ChangedAt:           st.changedAt,
+		})
+	}
+	slices.SortFunc(out, func(a, b RecordTargetStatus) int {
+		return cmp.Or(strings.Compare(a.Name, b.Name), a.Addr.Compare(b.Addr))
+	})
+	return out
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"runtime/debug"
+
+	"github.com/jroosing/hydradns/internal/dns"
+)
+
+// errResolverPanic reports a resolver that panicked; the query is answered
+// with SERVFAIL.
+var errResolverPanic = errors.New("resolver panicked")
+
+// reportPanic logs a panic recovered while serving a query, with the stack
+// trace, and counts it. A bug triggered by one packet must cost that query
+// only, not the listener goroutine or the process.
+//
+// Callers recover themselves, since recover only works in the deferred
+// function:
+//
+//	defer func() {
+//		if v := recover(); v != nil {
+//			reportPanic(ctx, logger, stats, v, "transport", "udp")
+//		}
+//	}()
+func reportPanic(ctx context.Context, logger *slog.Logger, stats *DNSStats, v any, attrs ...any) {
+	if stats != nil {
+		stats.RecordPanic()
+	}
+	if logger != nil {
+		attrs = append(attrs, "panic", v, "stack", string(debug.Stack()))
+		logger.ErrorContext(ctx, "recovered from panic while serving a query", attrs...)
+	}
+}
+
+// panicResult answers a query whose handling panicked with SERVFAIL, or
+// drops it when not even the header can be read.
+func panicResult(reqBytes []byte) (res HandleResult) {
+	res.Source = "panic"
+	defer func() { _ = recover() }() // the request itself may be what panicked
+	res.ResponseBytes = tryBuildErrorFromRaw(reqBytes, uint16(dns.RCodeServFail))
+	return res
+}
+
+// handlerStats returns h's statistics collector, if any.
+func handlerStats(h *QueryHandler) *DNSStats {
+	if h == nil {
+		return nil
+	}
+	return h.Stats
+}
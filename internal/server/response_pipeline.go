@@ -0,0 +1,255 @@
+package server
+
+import (
+	"math/rand/v2"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/jroosing/hydradns/internal/dns"
+	"github.com/jroosing/hydradns/internal/resolvers"
+)
+
+// ResponseStep rewrites a response after resolution. Rewrite changes resp,
+// the response to req, in place and reports whether it changed anything.
+//
+// Implementations must be safe for concurrent use.
+type ResponseStep interface {
+	Rewrite(req dns.Packet, resp *dns.Packet) bool
+}
+
+// ResponseStage is a step of a ResponsePipeline, limited to some clients.
+type ResponseStage struct {
+	Step    ResponseStep
+	Clients []netip.Prefix // nil applies the step to every client
+}
+
+// applies reports whether the stage rewrites responses to client.
+func (s ResponseStage) applies(client netip.Addr) bool {
+	if len(s.Clients) == 0 {
+		return true
+	}
+	if !client.IsValid() {
+		return false
+	}
+	for _, p := range s.Clients {
+		if p.Contains(client) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResponsePipeline rewrites responses after resolution, before they are
+// sent, by running its steps in order. Response rewriting features (TTL
+// clamping, AAAA filtering, answer reordering, EDE insertion, record
+// scrubbing) are steps, so they compose without each changing
+// QueryHandler. Responses are rewritten as sent only: cached answers keep
+// what the resolvers returned.
+//
+// A nil pipeline leaves responses unchanged. Safe for concurrent use.
+type ResponsePipeline struct {
+	stages []ResponseStage
+}
+
+// NewResponsePipeline creates a pipeline running stages in order, or
+// returns nil when there are none.
+func NewResponsePipeline(stages []ResponseStage) *ResponsePipeline {
+	if len(stages) == 0 {
+		return nil
+	}
+	return &ResponsePipeline{stages: slices.Clone(stages)}
+}
+
+// Process runs the steps applying to client over resp, the response to req,
+// and returns the rewritten response. resp is returned unchanged when no
+// step changes it or it cannot be parsed.
+func (p *ResponsePipeline) Process(client netip.Addr, req dns.Packet, resp []byte) []byte {
+	if p == nil || len(resp) == 0 {
+		return resp
+	}
+	client = client.Unmap()
+
+	var parsed dns.Packet
+	parsedOK, changed := false, false
+	for _, s := range p.stages {
+		if !s.applies(client) {
+			continue
+		}
+		if !parsedOK {
+			var err error
+			if parsed, err = dns.ParsePacket(resp); err != nil {
+				return resp
+			}
+			parsedOK = true
+		}
+		if s.Step.Rewrite(req, &parsed) {
+			changed = true
+		}
+	}
+	if !changed {
+		return resp
+	}
+	b, err := parsed.Marshal()
+	if err != nil {
+		return resp
+	}
+	return b
+}
+
+// TTLClamp raises record TTLs below Min and lowers those above Max; a zero
+// bound is not enforced. The OPT record is left alone.
+type TTLClamp struct {
+	Min uint32
+	Max uint32
+}
+
+// Rewrite clamps the TTLs of the records in every section.
+func (c TTLClamp) Rewrite(_ dns.Packet, resp *dns.Packet) bool {
+	changed := false
+	for _, section := range [][]dns.Record{resp.Answers, resp.Authorities, resp.Additionals} {
+		for _, r := range section {
+			if r.Type() == dns.TypeOPT {
+				continue
+			}
+			h := r.Header()
+			ttl := h.TTL
+			if c.Min > 0 {
+				ttl = max(ttl, c.Min)
+			}
+			if c.Max > 0 {
+				ttl = min(ttl, c.Max)
+			}
+			if ttl != h.TTL {
+				h.TTL = ttl
+				r.SetHeader(h)
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// AAAAFilter removes AAAA records from the answers to AAAA queries, as
+// resolvers.AAAAFilterResolver does, leaving an empty NOERROR (NODATA)
+// answer or just the CNAME chain.
+type AAAAFilter struct{}
+
+// Rewrite removes AAAA records from the answer to an AAAA query.
+func (AAAAFilter) Rewrite(req dns.Packet, resp *dns.Packet) bool {
+	if len(req.Questions) == 0 || req.Questions[0].Type != uint16(dns.TypeAAAA) {
+		return false
+	}
+	kept := resolvers.StripAAAA(resp.Answers)
+	if len(kept) == len(resp.Answers) {
+		return false
+	}
+	resp.Answers = kept
+	return true
+}
+
+// AnswerReorder reorders the records of each RRset in the answer section
+// with several records, whatever resolver answered: round robin rotates
+// them by one per response, random shuffles them.
+type AnswerReorder struct {
+	Order resolvers.AnswerOrder
+	next  atomic.Uint64 // Rotation of the next response, for round robin
+}
+
+// Rewrite reorders the records of each RRset in the answer section.
+func (a *AnswerReorder) Rewrite(_ dns.Packet, resp *dns.Packet) bool {
+	if a.Order == resolvers.AnswerOrderFixed || len(resp.Answers) < 2 {
+		return false
+	}
+	type rrset struct {
+		name string
+		typ  dns.RecordType
+	}
+	sets := make(map[rrset][]int)
+	for i, r := range resp.Answers {
+		key := rrset{strings.ToLower(r.Header().Name), r.Type()}
+		sets[key] = append(sets[key], i)
+	}
+
+	rotation := 0
+	if a.Order == resolvers.AnswerOrderRoundRobin {
+		rotation = int(a.next.Add(1) - 1)
+	}
+	changed := false
+	for _, idx := range sets {
+		if len(idx) < 2 {
+			continue
+		}
+		records := make([]dns.Record, len(idx))
+		for j, i := range idx {
+			records[j] = resp.Answers[i]
+		}
+		switch a.Order {
+		case resolvers.AnswerOrderRoundRobin:
+			n := rotation % len(records)
+			records = append(records[n:], records[:n]...)
+		case resolvers.AnswerOrderRandom:
+			rand.Shuffle(len(records), func(i, j int) { records[i], records[j] = records[j], records[i] })
+		}
+		for j, i := range idx {
+			resp.Answers[i] = records[j]
+		}
+		changed = true
+	}
+	return changed
+}
+
+// ExtendedErrorInsert adds an Extended DNS Error (RFC 8914) to responses
+// with response code RCode that carry none, e.g. to tell clients why a
+// SERVFAIL happened. As with other EDEs, clients that did not send EDNS get
+// the response unchanged.
+type ExtendedErrorInsert struct {
+	RCode dns.RCode
+	Error dns.ExtendedError
+}
+
+// Rewrite adds the Extended DNS Error to a response with a matching
+// response code.
+func (e ExtendedErrorInsert) Rewrite(req dns.Packet, resp *dns.Packet) bool {
+	if dns.RCodeFromFlags(resp.Header.Flags) != e.RCode || dns.ExtractOPT(req.Additionals) == nil {
+		return false
+	}
+	if _, ok := dns.ExtractExtendedError(resp.Additionals); ok {
+		return false
+	}
+	*resp = dns.AddExtendedError(*resp, req, e.Error)
+	return true
+}
+
+// Scrub removes records of the given types, and the RRSIGs covering them,
+// from every section, e.g. TXT records leaking internal details. The OPT
+// record is never removed.
+type Scrub struct {
+	Types []dns.RecordType
+}
+
+// Rewrite removes the scrubbed record types from every section.
+func (s Scrub) Rewrite(_ dns.Packet, resp *dns.Packet) bool {
+	changed := false
+	for _, section := range []*[]dns.Record{&resp.Answers, &resp.Authorities, &resp.Additionals} {
+		kept := slices.DeleteFunc(slices.Clone(*section), s.scrubbed)
+		if len(kept) != len(*section) {
+			*section = kept
+			changed = true
+		}
+	}
+	return changed
+}
+
+// scrubbed reports whether r is removed.
+func (s Scrub) scrubbed(r dns.Record) bool {
+	if r.Type() == dns.TypeOPT {
+		return false
+	}
+	if slices.Contains(s.Types, r.Type()) {
+		return true
+	}
+	sig, ok := r.(*dns.RRSIGRecord)
+	return ok && slices.Contains(s.Types, sig.TypeCovered)
+}
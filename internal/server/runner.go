@@ -1,19 +1,27 @@
 package server
 
 import (
+	"cmp"
 	"context"
 	"log/slog"
-	"net"
+	"net/netip"
 	"os"
 	"os/signal"
 	"runtime"
-	"strconv"
+	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/jroosing/hydradns/internal/audit"
+	"github.com/jroosing/hydradns/internal/capture"
 	"github.com/jroosing/hydradns/internal/config"
+	"github.com/jroosing/hydradns/internal/dns"
 	"github.com/jroosing/hydradns/internal/filtering"
+	"github.com/jroosing/hydradns/internal/geoip"
 	"github.com/jroosing/hydradns/internal/resolvers"
+	"github.com/jroosing/hydradns/internal/sharedcache"
+	"github.com/jroosing/hydradns/internal/threatintel"
 )
 
 // Runner orchestrates the DNS server startup, configuration, and shutdown.
@@ -21,16 +29,42 @@ type Runner struct {
 	logger         *slog.Logger
 	policyEngine   *filtering.PolicyEngine
 	dnsStats       *DNSStats
+	queryEvents    *QueryEvents
+	privacy        *Privacy
 	customResolver *resolvers.ReloadableCustomDNSResolver
+	recordHealth   *RecordHealth
+	capture        *capture.Recorder
+	clusterMembers resolvers.ClusterMembersFunc                 // published by cluster discovery
+	policy         atomic.Pointer[filtering.PolicyEngine]       // set while running
+	forwarder      atomic.Pointer[resolvers.ForwardingResolver] // set while running
+	geoIP          atomic.Pointer[geoip.DB]                     // set while running with GeoIP configured
+	anomaly        atomic.Pointer[AnomalyDetector]              // set while running with anomaly detection enabled
+	threatIntel    atomic.Pointer[threatintel.Checker]          // set while running with threat intelligence enabled
+	audit          atomic.Pointer[audit.Logger]                 // set while running with outbound auditing enabled
+	canary         atomic.Pointer[Canary]                       // set while running with the health canary enabled
+	shadow         atomic.Pointer[ShadowMirror]                 // set while running with shadow upstreams enabled
+	advertiser     atomic.Pointer[Advertiser]                   // set while running with DNS advertisement enabled
+	coverage       atomic.Pointer[ClientCoverage]               // set while running with client subnets configured
+	qtypes         atomic.Pointer[QTypePolicy]                  // set while running with qtype rules
+	udp            atomic.Pointer[UDPServer]                    // set while running
+	tcp            atomic.Pointer[TCPServer]                    // set while running with TCP enabled
+	lifecycle      atomic.Pointer[Lifecycle]                    // latest run's components
+	extra          []Component                                  // added by AddComponent
 }
 
 // NewRunner creates a new server runner with the given logger.
 func NewRunner(logger *slog.Logger) *Runner {
-	return &Runner{
+	r := &Runner{
 		logger:         logger,
 		dnsStats:       NewDNSStats(),
+		queryEvents:    &QueryEvents{},
+		privacy:        NewPrivacy(PrivacyFull, false),
 		customResolver: resolvers.NewReloadableCustomDNSResolver(nil),
+		recordHealth:   NewRecordHealth(logger),
+		capture:        capture.NewRecorder(),
 	}
+	r.capture.SetRedaction(r.privacy.CaptureRedaction)
+	return r
 }
 
 // DNSStats returns the DNS statistics collector.
@@ -39,40 +73,116 @@ func (r *Runner) DNSStats() *DNSStats {
 	return r.dnsStats
 }
 
+// QueryEvents returns the live query event stream.
+func (r *Runner) QueryEvents() *QueryEvents {
+	return r.queryEvents
+}
+
+// PacketCapture returns the recorder of the packet captures started from
+// the API. It records the messages exchanged with clients on every
+// listener and with upstream servers.
+func (r *Runner) PacketCapture() *capture.Recorder {
+	return r.capture
+}
+
+// SetPrivacy changes what query logs keep about clients and names (see
+// Privacy). Alerts the anomaly detector, divergences the shadow mirror
+// and packets the capture recorder have recorded are redacted again, so
+// raising the level also anonymizes what was logged before.
+func (r *Runner) SetPrivacy(level PrivacyLevel, hash bool) {
+	r.privacy.Set(level, hash)
+	if d := r.anomaly.Load(); d != nil {
+		d.Redact()
+	}
+	if m := r.shadow.Load(); m != nil {
+		m.Redact()
+	}
+	r.capture.Redact()
+}
+
 // SetPolicyEngine injects a shared policy engine for both DNS resolution and the API.
 // If nil, RunWithContext will build one from the current config.
 func (r *Runner) SetPolicyEngine(pe *filtering.PolicyEngine) {
 	r.policyEngine = pe
 }
 
+// SetClusterMembersFunc sets the source of the cluster members published
+// when cluster discovery is enabled. Without it, no members are published.
+func (r *Runner) SetClusterMembersFunc(fn resolvers.ClusterMembersFunc) {
+	r.clusterMembers = fn
+}
+
+// AddComponent registers a component to run alongside the DNS server, such
+// as the API server or the cluster syncer. Added components start after the
+// DNS listeners, in the order they were added, and stop before them. Call it
+// before RunWithContext.
+func (r *Runner) AddComponent(c Component) {
+	r.extra = append(r.extra, c)
+}
+
+// Components returns the state of every component of the latest run, in
+// start order, or nil before the first run.
+func (r *Runner) Components() []ComponentHealth {
+	if lc := r.lifecycle.Load(); lc != nil {
+		return lc.Health()
+	}
+	return nil
+}
+
+// NotReady returns why the server should not receive traffic, or nil when
+// it is ready: before the first run, while components are starting, while
+// blocklists are loading for the first time (domains on them would not be
+// blocked yet), and once shutdown began, including the drain period.
+func (r *Runner) NotReady() []string {
+	lc := r.lifecycle.Load()
+	if lc == nil {
+		return []string{"server is starting"}
+	}
+	if lc.ShuttingDown() {
+		return []string{"shutting down"}
+	}
+
+	var reasons []string
+	for _, c := range lc.Health() {
+		if c.State == ComponentPending || c.State == ComponentStarting {
+			reasons = append(reasons, c.Name+" is starting")
+		}
+	}
+	if pe := r.policy.Load(); pe != nil && !pe.BlocklistsLoaded() {
+		reasons = append(reasons, "blocklists are loading")
+	}
+	return reasons
+}
+
 // Run starts the DNS server with the given configuration.
 //
 // Server lifecycle:
 //  1. Configure runtime (GOMAXPROCS based on workers setting)
 //  2. Initialize custom DNS resolver (if configured)
-//  3. Build resolver chain (custom DNS -> forwarding)
-//  4. Start UDP and optionally TCP servers
-//  5. Wait for shutdown signal (SIGINT/SIGTERM)
-//  6. Gracefully stop servers with timeout
+//  3. Start components in order: filtering, GeoIP, anomaly detection,
+//     threat intelligence, audit log, resolver chain, canary, DNS listeners,
+//     then added components
+//  4. Wait for shutdown signal (SIGINT/SIGTERM) or a required component failure
+//  5. Keep serving for server.shutdown_drain while reporting not ready
+//  6. Stop components in reverse order
 func (r *Runner) Run(cfg *config.Config) error {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 	return r.RunWithContext(ctx, cfg)
 }
 
-// RunWithContext starts the DNS server and blocks until ctx is canceled or a server error occurs.
+// RunWithContext starts the DNS server and blocks until ctx is canceled or a
+// required component fails.
 //
 // This enables callers (e.g. a management API) to share the same shutdown signal.
 //
-// Goroutine lifecycle: Spawns two long-lived goroutines:
-// 1. UDP server (udp.Run) - exits when context cancelled
-// 2. TCP server (tcp.Run) - exits when context cancelled (if enabled)
-// Both servers spawn their own worker goroutines internally.
-// Cleanup: Resolvers closed, rate limiter cleanup triggered by context cancellation.
+// Each component runs in its own goroutine under a Lifecycle, which stops
+// them one by one in reverse start order: listeners drain and release their
+// sockets before the resolver chain closes, so the address can be rebound
+// as soon as this returns. Optional components (the canary, threat
+// intelligence, listener profiles) are logged and reported as failed
+// without stopping the server.
 func (r *Runner) RunWithContext(ctx context.Context, cfg *config.Config) error {
-	ctx, cancelRun := context.WithCancel(ctx)
-	defer cancelRun()
-
 	// Configure GOMAXPROCS based on worker settings
 	desiredProcs := r.configureRuntime(cfg)
 
@@ -83,65 +193,22 @@ func (r *Runner) RunWithContext(ctx context.Context, cfg *config.Config) error {
 	// Initialize custom DNS resolver (loads into reloadable wrapper)
 	r.initCustomDNS(cfg)
 
-	// Build or reuse filtering policy
-	policy := r.policyEngine
-	if policy == nil {
-		policy = BuildPolicyEngine(cfg, r.logger)
-		r.policyEngine = policy
-	}
-
-	// Build resolver chain
-	resolver := r.buildResolverChain(cfg, upPool, policy)
-	defer resolver.Close()
-
-	// Create server components
-	h := &QueryHandler{Logger: r.logger, Resolver: resolver, Timeout: 4 * time.Second, Stats: r.dnsStats}
-	limiter := NewRateLimiter(RateLimitSettings{
-		CleanupSeconds:   cfg.RateLimit.CleanupSeconds,
-		MaxIPEntries:     cfg.RateLimit.MaxIPEntries,
-		MaxPrefixEntries: cfg.RateLimit.MaxPrefixEntries,
-		GlobalQPS:        cfg.RateLimit.GlobalQPS,
-		GlobalBurst:      cfg.RateLimit.GlobalBurst,
-		PrefixQPS:        cfg.RateLimit.PrefixQPS,
-		PrefixBurst:      cfg.RateLimit.PrefixBurst,
-		IPQPS:            cfg.RateLimit.IPQPS,
-		IPBurst:          cfg.RateLimit.IPBurst,
-	})
-
-	addr := net.JoinHostPort(cfg.Server.Host, strconv.Itoa(cfg.Server.Port))
-	r.logStartup(cfg, addr, maxConc, upPool)
-
-	// Start servers
-	udp := &UDPServer{Logger: r.logger, Handler: h, Limiter: limiter, WorkersPerSocket: maxConc}
-	var tcp *TCPServer
-	if cfg.Server.EnableTCP {
-		tcp = &TCPServer{Logger: r.logger, Handler: h}
-	}
+	// The level was checked by config.Validate
+	privacyLevel, _ := ParsePrivacyLevel(cfg.Privacy.Level)
+	r.SetPrivacy(privacyLevel, cfg.Privacy.Anonymize == "hash")
 
-	errCh := make(chan error, 2)
-	go func() { errCh <- udp.Run(ctx, addr) }()
-	if tcp != nil {
-		go func() { errCh <- tcp.Run(ctx, addr) }()
+	lc := NewLifecycle(r.logger)
+	for _, c := range r.dnsComponents(&runStack{cfg: cfg, maxConc: maxConc, upPool: upPool}) {
+		lc.Add(c)
 	}
-
-	// Wait for shutdown or error
-	select {
-	case <-ctx.Done():
-		// shutdown requested via signal
-	case err := <-errCh:
-		if err != nil {
-			cancelRun()
-			return err
-		}
+	for _, c := range r.extra {
+		lc.Add(c)
 	}
-
-	// Graceful shutdown
-	stopTimeout := 5 * time.Second
-	_ = udp.Stop(stopTimeout)
-	if tcp != nil {
-		_ = tcp.Stop(stopTimeout)
-	}
-	return nil
+	// The duration was checked by config.Validate
+	drain, _ := time.ParseDuration(cfg.Server.ShutdownDrain)
+	lc.SetDrain(drain)
+	r.lifecycle.Store(lc)
+	return lc.Run(ctx)
 }
 
 // configureRuntime sets GOMAXPROCS based on worker configuration.
@@ -193,8 +260,20 @@ func (r *Runner) calculateUpstreamPoolSize(cfg *config.Config, maxConc int) int
 	return upPool
 }
 
+// warnDuplicateAliases logs the CNAME aliases of res stored under several
+// spellings with different targets; only one of each is answered.
+func (r *Runner) warnDuplicateAliases(res *resolvers.CustomDNSResolver) {
+	if r.logger == nil {
+		return
+	}
+	for _, alias := range res.DuplicateAliases() {
+		r.logger.Warn("CNAME configured under several spellings with different targets", "alias", alias)
+	}
+}
+
 // initCustomDNS loads custom DNS configuration into the reloadable resolver.
 func (r *Runner) initCustomDNS(cfg *config.Config) {
+	r.recordHealth.SetTargets(BuildRecordChecks(cfg), cfg.CustomDNS.Hosts)
 	if len(cfg.CustomDNS.Hosts) == 0 && len(cfg.CustomDNS.CNAMEs) == 0 {
 		// Empty config - clear the resolver
 		_ = r.customResolver.Reload(nil)
@@ -208,6 +287,14 @@ func (r *Runner) initCustomDNS(cfg *config.Config) {
 		}
 		return
 	}
+	r.warnDuplicateAliases(customResolver)
+	customResolver.SetMaxCNAMEChain(cfg.Server.MaxCNAMEChain)
+	customResolver.SetSearchDomains(cfg.CustomDNS.SearchDomains)
+	customResolver.SetAnswerOrder(buildAnswerOrder(cfg))
+	customResolver.SetSteering(buildSteering(cfg))
+	if len(cfg.CustomDNS.HealthChecks) > 0 {
+		customResolver.SetAddressHealth(r.recordHealth)
+	}
 
 	if err := r.customResolver.Reload(customResolver); err != nil {
 		if r.logger != nil {
@@ -224,9 +311,65 @@ func (r *Runner) initCustomDNS(cfg *config.Config) {
 	}
 }
 
+// buildSlowQueryLog returns the slow query log, or nil when it is disabled.
+// The threshold was checked by config.Validate.
+func buildSlowQueryLog(cfg *config.Config, logger *slog.Logger) *SlowQueryLog {
+	threshold, _ := time.ParseDuration(cfg.Logging.SlowQueryThreshold)
+	return NewSlowQueryLog(logger, threshold, cfg.Logging.SlowQueryMaxPerMinute)
+}
+
+// buildAnswerOrder returns the custom DNS answer order. The order was
+// checked by config.Validate; an unset one means fixed.
+func buildAnswerOrder(cfg *config.Config) resolvers.AnswerOrder {
+	order, _ := resolvers.ParseAnswerOrder(cfg.CustomDNS.AnswerOrder)
+	return order
+}
+
+// buildSteering returns the steered addresses of custom DNS hosts by name.
+// Addresses were checked by config.Validate; ones that do not parse are
+// skipped.
+func buildSteering(cfg *config.Config) map[string][]resolvers.SteeredAddr {
+	steering := make(map[string][]resolvers.SteeredAddr, len(cfg.CustomDNS.Steering))
+	for _, s := range cfg.CustomDNS.Steering {
+		for _, a := range s.Addrs {
+			addr, err := netip.ParseAddr(a.Addr)
+			if err != nil {
+				continue
+			}
+			steering[s.Name] = append(steering[s.Name], resolvers.SteeredAddr{
+				Addr:    addr,
+				Weight:  a.Weight,
+				Clients: a.ClientPrefixes(),
+			})
+		}
+	}
+	return steering
+}
+
+// BuildRecordChecks returns the custom DNS health checks. The checks were
+// normalized by config.Validate.
+func BuildRecordChecks(cfg *config.Config) []RecordCheck {
+	checks := make([]RecordCheck, 0, len(cfg.CustomDNS.HealthChecks))
+	for _, c := range cfg.CustomDNS.HealthChecks {
+		interval, _ := time.ParseDuration(c.Interval)
+		timeout, _ := time.ParseDuration(c.Timeout)
+		checks = append(checks, RecordCheck{
+			Name:     c.Name,
+			Type:     c.Type,
+			Port:     c.Port,
+			Path:     c.Path,
+			Interval: max(interval, time.Second),
+			Timeout:  cmp.Or(timeout, 2*time.Second),
+			Failures: max(c.Failures, 1),
+		})
+	}
+	return checks
+}
+
 // ReloadCustomDNS atomically replaces the custom DNS configuration.
 // This is safe to call while the server is running.
 func (r *Runner) ReloadCustomDNS(cfg *config.Config) error {
+	r.recordHealth.SetTargets(BuildRecordChecks(cfg), cfg.CustomDNS.Hosts)
 	if len(cfg.CustomDNS.Hosts) == 0 && len(cfg.CustomDNS.CNAMEs) == 0 {
 		// Empty config - clear the resolver
 		return r.customResolver.Reload(nil)
@@ -236,6 +379,14 @@ func (r *Runner) ReloadCustomDNS(cfg *config.Config) error {
 	if err != nil {
 		return err
 	}
+	r.warnDuplicateAliases(newResolver)
+	newResolver.SetMaxCNAMEChain(cfg.Server.MaxCNAMEChain)
+	newResolver.SetSearchDomains(cfg.CustomDNS.SearchDomains)
+	newResolver.SetAnswerOrder(buildAnswerOrder(cfg))
+	newResolver.SetSteering(buildSteering(cfg))
+	if len(cfg.CustomDNS.HealthChecks) > 0 {
+		newResolver.SetAddressHealth(r.recordHealth)
+	}
 
 	if err := r.customResolver.Reload(newResolver); err != nil {
 		return err
@@ -250,19 +401,189 @@ func (r *Runner) ReloadCustomDNS(cfg *config.Config) error {
 	return nil
 }
 
-// buildResolverChain creates the resolver chain: filtering -> custom DNS -> forwarding.
-// The custom DNS resolver is always included (it returns an error when empty,
-// allowing the chain to fall through to forwarding).
-func (r *Runner) buildResolverChain(
-	cfg *config.Config,
-	upPool int,
-	policy *filtering.PolicyEngine,
-) resolvers.Resolver {
-	resList := make([]resolvers.Resolver, 0, 2)
+// ReloadUpstreams applies cfg.Upstream.Servers to the running forwarding
+// resolver without a restart. The response cache is preserved.
+// If the server is not running, this is a no-op; the next run reads cfg.
+func (r *Runner) ReloadUpstreams(cfg *config.Config) error {
+	fwd := r.forwarder.Load()
+	if fwd == nil {
+		return nil
+	}
+
+	fwd.SetUpstreams(cfg.Upstream.Servers)
+
+	if r.logger != nil {
+		r.logger.Info("upstream servers reloaded", "upstreams", fwd.Upstreams())
+	}
+	if geoDB := r.geoIP.Load(); geoDB != nil {
+		r.logUpstreamGeoIP(cfg, geoDB)
+	}
+	return nil
+}
+
+// ReloadNegativeCacheRules applies the negative and SERVFAIL cache settings
+// in cfg.Cache, including zone overrides, to the running forwarding resolver.
+// Responses already cached keep the TTL they were stored with.
+// If the server is not running, this is a no-op; the next run reads cfg.
+func (r *Runner) ReloadNegativeCacheRules(cfg *config.Config) error {
+	fwd := r.forwarder.Load()
+	if fwd == nil {
+		return nil
+	}
 
-	// Always include the reloadable custom DNS resolver
-	resList = append(resList, r.customResolver)
+	fwd.SetNegativeCacheRules(BuildNegativeCacheRules(cfg))
+
+	if r.logger != nil {
+		r.logger.Info("negative cache rules reloaded", "zone_overrides", len(cfg.Cache.ZoneOverrides))
+	}
+	return nil
+}
+
+// PurgeCache drops cached upstream responses for name and returns how many
+// were removed. Returns 0 when the server is not running.
+func (r *Runner) PurgeCache(name string) int {
+	fwd := r.forwarder.Load()
+	if fwd == nil {
+		return 0
+	}
+	return fwd.PurgeName(name)
+}
+
+// UDPListenerStats returns per-listener UDP counters.
+// Returns nil when the server is not running.
+func (r *Runner) UDPListenerStats() []UDPListenerStats {
+	udp := r.udp.Load()
+	if udp == nil {
+		return nil
+	}
+	return udp.ListenerStats()
+}
+
+// TCPConnectionsPerIP returns the number of active TCP connections per
+// client IP. Returns nil when the TCP server is not running.
+func (r *Runner) TCPConnectionsPerIP() map[string]int {
+	tcp := r.tcp.Load()
+	if tcp == nil {
+		return nil
+	}
+	return tcp.ConnectionsPerIP()
+}
+
+// UpstreamStatus returns per-upstream latency and availability statistics.
+// Returns nil when the server is not running.
+func (r *Runner) UpstreamStatus() []resolvers.UpstreamStatus {
+	fwd := r.forwarder.Load()
+	if fwd == nil {
+		return nil
+	}
+	return fwd.UpstreamStatus()
+}
+
+// CacheStats returns the response cache counters. The second value is
+// false when the server is not running.
+func (r *Runner) CacheStats() (resolvers.CacheStats, bool) {
+	fwd := r.forwarder.Load()
+	if fwd == nil {
+		return resolvers.CacheStats{}, false
+	}
+	return fwd.CacheStats(), true
+}
+
+// SharedCacheStats returns the shared cache counters. ok is false when no
+// shared cache is configured or the server is not running.
+func (r *Runner) SharedCacheStats() (resolvers.SharedCacheStats, bool) {
+	fwd := r.forwarder.Load()
+	if fwd == nil {
+		return resolvers.SharedCacheStats{}, false
+	}
+	return fwd.SharedCacheStats()
+}
+
+// RecordHealthStatus returns the health of the addresses of custom DNS
+// hosts with a health check.
+func (r *Runner) RecordHealthStatus() []RecordTargetStatus {
+	return r.recordHealth.Status()
+}
 
+// CanaryStatus returns the health canary's latest results. ok is false when
+// the canary is disabled or the server is not running.
+func (r *Runner) CanaryStatus() (CanaryStatus, bool) {
+	c := r.canary.Load()
+	if c == nil {
+		return CanaryStatus{}, false
+	}
+	return c.Status(), true
+}
+
+// ShadowStatus returns how the shadow upstreams compare with the primary
+// ones. ok is false when shadowing is disabled or the server is not
+// running.
+func (r *Runner) ShadowStatus() (ShadowStatus, bool) {
+	m := r.shadow.Load()
+	if m == nil {
+		return ShadowStatus{}, false
+	}
+	return m.Status(), true
+}
+
+// AdvertiseStatus returns the advertised DNS addresses and the last
+// webhook delivery. ok is false when advertisement is disabled or the
+// server is not running.
+func (r *Runner) AdvertiseStatus() (AdvertiseStatus, bool) {
+	a := r.advertiser.Load()
+	if a == nil {
+		return AdvertiseStatus{}, false
+	}
+	return a.Status(), true
+}
+
+// CoverageStatus returns the client subnets that queried from outside the
+// configured ones. ok is false when no client subnets are configured or
+// the server is not running.
+func (r *Runner) CoverageStatus() (CoverageStatus, bool) {
+	c := r.coverage.Load()
+	if c == nil {
+		return CoverageStatus{}, false
+	}
+	return c.Status(), true
+}
+
+// QTypeRuleStats returns the number of queries each qtype rule has
+// answered, or nil when no rules are configured or the server is not
+// running.
+func (r *Runner) QTypeRuleStats() []QTypeRuleStats {
+	return r.qtypes.Load().Stats()
+}
+
+// AnomalyCounts returns the number of anomaly alerts raised per kind.
+// Returns nil when anomaly detection is disabled or the server is not running.
+func (r *Runner) AnomalyCounts() map[AnomalyKind]uint64 {
+	d := r.anomaly.Load()
+	if d == nil {
+		return nil
+	}
+	return d.Counts()
+}
+
+// RecentAnomalies returns the latest anomaly alerts, newest first.
+// Returns nil when anomaly detection is disabled or the server is not running.
+func (r *Runner) RecentAnomalies() []Anomaly {
+	d := r.anomaly.Load()
+	if d == nil {
+		return nil
+	}
+	return d.Recent()
+}
+
+// GeoIPLookup returns what the GeoIP databases know about ip. The result is
+// empty when GeoIP is not configured or the server is not running.
+func (r *Runner) GeoIPLookup(ip netip.Addr) geoip.Info {
+	return r.geoIP.Load().Lookup(ip)
+}
+
+// buildUpstream creates the forwarding resolver, wrapped with GeoIP answer
+// blocking and threat intelligence lookups when configured.
+func (r *Runner) buildUpstream(cfg *config.Config, upPool int) resolvers.Resolver {
 	// Parse upstream timeouts
 	udpTimeout, _ := time.ParseDuration(cfg.Upstream.UDPTimeout)
 	tcpTimeout, _ := time.ParseDuration(cfg.Upstream.TCPTimeout)
@@ -270,50 +591,200 @@ func (r *Runner) buildResolverChain(
 	fwd := resolvers.NewForwardingResolver(
 		cfg.Upstream.Servers,
 		upPool,
-		0,
+		cfg.Cache.MaxEntries,
 		cfg.Server.TCPFallback,
 		udpTimeout,
 		tcpTimeout,
 		cfg.Upstream.MaxRetries,
 	)
-	resList = append(resList, fwd)
+	fwd.SetNegativeCacheRules(BuildNegativeCacheRules(cfg))
+	// The policy was checked by config.Validate; an unset one means LRU
+	evictionPolicy, _ := resolvers.ParseEvictionPolicy(cfg.Cache.EvictionPolicy)
+	fwd.SetCacheEvictionPolicy(evictionPolicy)
+	fwd.SetCacheBypass(cfg.Cache.BypassDomains)
+	fwd.SetAggressiveNSEC(cfg.Cache.AggressiveNSEC)
+	// -1 (no limit) is treated like 0 by the cache
+	fwd.SetCacheByteLimits(cfg.Cache.MaxBytes, cfg.Cache.MaxEntryBytes)
+	fwd.SetEDNSPolicy(BuildEDNSPolicy(cfg))
+	fwd.SetMaxCNAMEChain(cfg.Server.MaxCNAMEChain)
+	// The strategy was checked by config.Validate; an unset one means sequential
+	strategy, _ := resolvers.NewUpstreamStrategy(cfg.Upstream.Strategy)
+	fwd.SetUpstreamStrategy(strategy)
+	if len(cfg.Upstream.DoHServers) > 0 {
+		fwd.SetDoHServers(cfg.Upstream.DoHServers, nil)
+	}
+	fwd.SetTransportRules(BuildTransportRules(cfg))
+	if cfg.Cache.Shared.Backend != "" {
+		r.setSharedCache(cfg, fwd)
+	}
+	if auditLog := r.audit.Load(); auditLog != nil {
+		fwd.SetAuditor(auditLog)
+	}
+	fwd.SetPacketCapture(r.capture)
+	r.forwarder.Store(fwd)
 
-	var chain resolvers.Resolver = &resolvers.Chained{Resolvers: resList}
+	// Check forwarded answers against the GeoIP block lists
+	var upstream resolvers.Resolver = fwd
+	if geoDB := r.geoIP.Load(); geoDB != nil {
+		r.logUpstreamGeoIP(cfg, geoDB)
+		if len(cfg.GeoIP.BlockASNs) > 0 || len(cfg.GeoIP.BlockCountries) > 0 {
+			upstream = resolvers.NewGeoIPResolver(geoDB, fwd, cfg.GeoIP.BlockASNs, cfg.GeoIP.BlockCountries)
+		}
+	}
 
-	// Always wrap with filtering; the policy's enabled flag controls behavior.
-	if policy != nil {
-		chain = resolvers.NewFilteringResolver(policy, chain)
+	// Look up newly observed forwarded names in the threat feed
+	if threat := r.threatIntel.Load(); threat != nil {
+		upstream = resolvers.NewThreatIntelResolver(upstream, threat.Observe)
+	}
+	return upstream
+}
+
+// setSharedCache connects fwd to the configured shared cache. Without it,
+// the node simply caches on its own.
+func (r *Runner) setSharedCache(cfg *config.Config, fwd *resolvers.ForwardingResolver) {
+	client, err := sharedcache.New(cfg.Cache.Shared)
+	if err != nil {
 		if r.logger != nil {
-			r.logger.Info("filtering configured",
-				"enabled", cfg.Filtering.Enabled,
-				"whitelist_count", len(cfg.Filtering.WhitelistDomains),
-				"blacklist_count", len(cfg.Filtering.BlacklistDomains),
-				"blocklists", len(cfg.Filtering.Blocklists),
-			)
+			r.logger.Warn("shared cache disabled", "err", err)
+		}
+		return
+	}
+	fwd.SetSharedCache(client)
+	if r.logger != nil {
+		r.logger.Info("shared cache configured",
+			"backend", client.Backend(),
+			"address", cfg.Cache.Shared.Address,
+			"timeout", cfg.Cache.Shared.Timeout)
+	}
+}
+
+// buildResolverChain creates the resolver chain: CHAOS identity -> captive
+// portal -> filtering -> custom DNS -> upstream.
+// The custom DNS resolver is always included (it returns ErrNotInZone when
+// empty, allowing the chain to fall through to upstream); queries outside
+// its zones skip it. The upstream stage is bounded by
+// upstream.stage_timeout when set. A nil policy leaves out filtering.
+func (r *Runner) buildResolverChain(
+	cfg *config.Config,
+	upstream resolvers.Resolver,
+	policy *filtering.PolicyEngine,
+) resolvers.Resolver {
+	// Validated by config.Validate; empty leaves the stage unbounded
+	stageTimeout, _ := time.ParseDuration(cfg.Upstream.StageTimeout)
+	var chain resolvers.Resolver = &resolvers.Chained{Stages: []resolvers.Stage{
+		{Name: "custom-dns", Resolver: r.customResolver, Skip: r.customResolver.Skip},
+		{Name: "upstream", Resolver: upstream, Timeout: stageTimeout},
+	}}
+
+	// Wrap with filtering; the policy's enabled flag controls behavior.
+	if policy != nil {
+		fr := resolvers.NewFilteringResolver(policy, chain)
+		if cfg.BlockPage.Enabled {
+			// Addresses were checked by config.Validate; unset ones stay invalid
+			ipv4, _ := netip.ParseAddr(cfg.BlockPage.IPv4)
+			ipv6, _ := netip.ParseAddr(cfg.BlockPage.IPv6)
+			fr.SetBlockPageAddrs(ipv4, ipv6)
 		}
+		chain = fr
 	}
 
+	// Name local SLAAC and DHCPv6 addresses; reverse lookups of them are
+	// never blocked or forwarded
+	if cfg.SynthPTR.Enabled {
+		chain = resolvers.NewSynthPTRResolver(resolvers.SynthPTR{
+			Prefixes: cfg.SynthPTR.PrefixList(),
+			Template: cfg.SynthPTR.Template,
+			TTL:      uint32(cfg.SynthPTR.TTL),
+		}, chain)
+	}
+
+	// Strip AAAA answers, whether local, blocked, or forwarded, for clients
+	// with broken IPv6
+	if cfg.FilterAAAA.Enabled {
+		chain = resolvers.NewAAAAFilterResolver(cfg.FilterAAAA.ClientPrefixes(), chain)
+	}
+
+	// Send clients on onboarding networks to the captive portal before
+	// anything else is looked up for them
+	if cfg.CaptivePortal.Enabled {
+		// Addresses were checked by config.Validate; unset ones stay invalid
+		ipv4, _ := netip.ParseAddr(cfg.CaptivePortal.IPv4)
+		ipv6, _ := netip.ParseAddr(cfg.CaptivePortal.IPv6)
+		chain = resolvers.NewCaptivePortalResolver(resolvers.CaptivePortal{
+			Clients:      cfg.CaptivePortal.ClientPrefixes(),
+			IPv4:         ipv4,
+			IPv6:         ipv6,
+			AllowDomains: cfg.CaptivePortal.AllowDomains,
+			TTL:          uint32(cfg.CaptivePortal.TTL),
+		}, chain)
+	}
+
+	// Advertise the encrypted endpoints to every client, portal ones too,
+	// and keep resolver.arpa from being forwarded
+	if cfg.DDR.Enabled {
+		ipv4, ipv6 := cfg.DDR.Hints()
+		chain = resolvers.NewDDRResolver(resolvers.DesignatedResolver{
+			Target:    cfg.DDR.Target,
+			DoHPort:   uint16(cfg.DDR.DoHPort),
+			DoTPort:   uint16(cfg.DDR.DoTPort),
+			DoHPath:   cfg.DDR.DoHPath,
+			IPv4Hints: ipv4,
+			IPv6Hints: ipv6,
+			TTL:       uint32(cfg.DDR.TTL),
+		}, chain)
+	}
+
+	// Publish the cluster members for discovery tools, keeping their zone
+	// from being filtered or forwarded
+	if cfg.ClusterDiscovery.Enabled && r.clusterMembers != nil {
+		chain = resolvers.NewClusterDiscoveryResolver(resolvers.ClusterDiscovery{
+			Zone:    cfg.ClusterDiscovery.Zone,
+			TTL:     uint32(cfg.ClusterDiscovery.TTL),
+			Members: r.clusterMembers,
+		}, chain)
+	}
+
+	// Answer CHAOS identity queries before filtering or forwarding
+	chain = resolvers.NewChaosResolver(resolvers.ChaosIdentity{
+		Version:  cfg.Identity.Version,
+		Hostname: cfg.Identity.Hostname,
+		ServerID: cfg.Identity.ServerID,
+	}, chain)
+
 	return chain
 }
 
+// logFiltering logs the filtering configuration.
+func (r *Runner) logFiltering(cfg *config.Config) {
+	if r.logger != nil {
+		r.logger.Info("filtering configured",
+			"enabled", cfg.Filtering.Enabled,
+			"whitelist_count", len(cfg.Filtering.WhitelistDomains),
+			"blacklist_count", len(cfg.Filtering.BlacklistDomains),
+			"blocklists", len(cfg.Filtering.Blocklists),
+		)
+	}
+}
+
+// PolicyStore is the persistence used by the policy engine; *database.DB
+// implements it.
+type PolicyStore interface {
+	filtering.FetchCache
+	filtering.FirstSeenStore
+}
+
 // BuildPolicyEngine constructs a filtering policy engine from the config.
 // The returned engine may be disabled based on cfg.Filtering.Enabled but remains usable for stats and toggling.
-func BuildPolicyEngine(cfg *config.Config, logger *slog.Logger) *filtering.PolicyEngine {
+// store, if non-nil, caches blocklist downloads for conditional refetches
+// and persists first-seen domains for NRD detection.
+func BuildPolicyEngine(cfg *config.Config, logger *slog.Logger, store PolicyStore) *filtering.PolicyEngine {
 	if cfg == nil {
 		return nil
 	}
 
 	blocklists := make([]filtering.BlocklistURL, 0, len(cfg.Filtering.Blocklists))
 	for _, bl := range cfg.Filtering.Blocklists {
-		format := filtering.FormatAuto
-		switch bl.Format {
-		case "adblock":
-			format = filtering.FormatAdblock
-		case "hosts":
-			format = filtering.FormatHosts
-		case "domains":
-			format = filtering.FormatDomains
-		}
+		format, _ := filtering.ParseListFormat(bl.Format)
 		blocklists = append(blocklists, filtering.BlocklistURL{
 			Name:   bl.Name,
 			URL:    bl.URL,
@@ -328,19 +799,347 @@ func BuildPolicyEngine(cfg *config.Config, logger *slog.Logger) *filtering.Polic
 		}
 	}
 
+	var nrd *filtering.NRDConfig
+	if cfg.NRD.Enabled {
+		nrd = &filtering.NRDConfig{
+			Action: filtering.ActionLog,
+			Window: time.Duration(cfg.NRD.Days) * 24 * time.Hour,
+			Store:  store,
+		}
+		if cfg.NRD.Action == "block" {
+			nrd.Action = filtering.ActionBlock
+		}
+	}
+
 	return filtering.NewPolicyEngine(filtering.PolicyEngineConfig{
 		Logger:           logger,
 		Enabled:          cfg.Filtering.Enabled,
 		BlockAction:      filtering.ActionBlock,
 		LogBlocked:       cfg.Filtering.LogBlocked,
 		LogAllowed:       cfg.Filtering.LogAllowed,
+		BlockTTL:         uint32(cfg.Filtering.BlockTTL),
+		NegativeTTL:      uint32(cfg.Filtering.NegativeTTL),
 		WhitelistDomains: cfg.Filtering.WhitelistDomains,
 		BlacklistDomains: cfg.Filtering.BlacklistDomains,
+		TemporaryAllows:  cfg.Filtering.TemporaryAllows,
 		BlocklistURLs:    blocklists,
 		RefreshInterval:  refreshInterval,
+		FetchCache:       store,
+		NRD:              nrd,
+	})
+}
+
+// BuildCanary constructs a health canary resolving through resolver, or
+// returns nil when the canary is disabled.
+func BuildCanary(cfg *config.Config, resolver resolvers.Resolver, logger *slog.Logger) *Canary {
+	if !cfg.Canary.Enabled {
+		return nil
+	}
+	// The durations were checked by config.Validate
+	interval, _ := time.ParseDuration(cfg.Canary.Interval)
+	timeout, _ := time.ParseDuration(cfg.Canary.Timeout)
+	return NewCanary(resolver, CanarySettings{
+		Domains:          cfg.Canary.Domains,
+		Interval:         interval,
+		Timeout:          timeout,
+		FailureThreshold: cfg.Canary.FailureThreshold,
+	}, logger)
+}
+
+// buildShadow constructs the shadow upstream mirror, or returns nil when
+// shadowing is disabled. Shadow queries use the upstream timeouts and EDNS
+// policy of the primary upstreams, and are audited like them.
+func (r *Runner) buildShadow(cfg *config.Config) *ShadowMirror {
+	if !cfg.Shadow.Enabled {
+		return nil
+	}
+	// The durations were checked by config.Validate
+	timeout, _ := time.ParseDuration(cfg.Shadow.Timeout)
+	udpTimeout, _ := time.ParseDuration(cfg.Upstream.UDPTimeout)
+	tcpTimeout, _ := time.ParseDuration(cfg.Upstream.TCPTimeout)
+
+	// Shadow queries skip the cache, so it is kept minimal
+	fwd := resolvers.NewForwardingResolver(
+		cfg.Shadow.Servers,
+		0,
+		1,
+		cfg.Server.TCPFallback,
+		udpTimeout,
+		tcpTimeout,
+		cfg.Upstream.MaxRetries,
+	)
+	fwd.SetInflightLimits(0, timeout)
+	fwd.SetEDNSPolicy(BuildEDNSPolicy(cfg))
+	fwd.SetMaxCNAMEChain(cfg.Server.MaxCNAMEChain)
+	if auditLog := r.audit.Load(); auditLog != nil {
+		fwd.SetAuditor(auditLog)
+	}
+	return NewShadowMirror(fwd, ShadowSettings{
+		Servers: cfg.Shadow.Servers,
+		Percent: cfg.Shadow.Percent,
+		Timeout: timeout,
+	}, r.logger, r.privacy)
+}
+
+// BuildAdvertiser constructs the DNS address advertiser for the main
+// listeners, or returns nil when advertisement is disabled.
+func BuildAdvertiser(cfg *config.Config, logger *slog.Logger) *Advertiser {
+	if !cfg.Advertise.Enabled {
+		return nil
+	}
+	// The interval was checked by config.Validate
+	interval, _ := time.ParseDuration(cfg.Advertise.Interval)
+	return NewAdvertiser(AdvertiseSettings{
+		WebhookURL:    cfg.Advertise.WebhookURL,
+		WebhookSecret: cfg.Advertise.WebhookSecret,
+		Interfaces:    cfg.Advertise.Interfaces,
+		Interval:      interval,
+		Host:          cfg.Server.Host,
+		Port:          cfg.Server.Port,
+		Node:          cmp.Or(cfg.Identity.ServerID, cfg.Identity.Hostname, cfg.Cluster.NodeID),
+	}, logger)
+}
+
+// buildClientCoverage constructs the client subnet coverage tracker, or
+// returns nil when no client subnets are configured.
+func (r *Runner) buildClientCoverage(cfg *config.Config) *ClientCoverage {
+	subnets := cfg.Advertise.ClientSubnetPrefixes()
+	if len(subnets) == 0 {
+		return nil
+	}
+	return NewClientCoverage(subnets, r.logger, r.privacy)
+}
+
+// BuildTransportRules converts the upstream transport rules, or returns nil
+// when there are none.
+func BuildTransportRules(cfg *config.Config) *resolvers.TransportRules {
+	if len(cfg.Upstream.TransportRules) == 0 {
+		return nil
+	}
+	zones := make(map[string]resolvers.Transport, len(cfg.Upstream.TransportRules))
+	for _, r := range cfg.Upstream.TransportRules {
+		// Transports were checked by config.Validate
+		if t, err := resolvers.ParseTransport(r.Transport); err == nil {
+			zones[r.Domain] = t
+		}
+	}
+	return resolvers.NewTransportRules(zones)
+}
+
+// BuildQTypePolicy converts the qtype rules into a policy, or returns nil
+// when there are none.
+func BuildQTypePolicy(cfg *config.Config) *QTypePolicy {
+	rules := make([]QTypeRule, 0, len(cfg.Server.QTypeRules))
+	for _, r := range cfg.Server.QTypeRules {
+		rule := QTypeRule{
+			Clients: r.ClientPrefixes(),
+			Action:  ParseQTypeAction(r.Action),
+		}
+		// Types were checked by config.Validate
+		for _, name := range r.QTypes {
+			if t, err := dns.ParseRecordType(name); err == nil {
+				rule.QTypes = append(rule.QTypes, t)
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return NewQTypePolicy(rules)
+}
+
+// BuildResponsePipeline converts the response pipeline steps into a
+// pipeline, or returns nil when there are none.
+func BuildResponsePipeline(cfg *config.Config) *ResponsePipeline {
+	stages := make([]ResponseStage, 0, len(cfg.Server.ResponsePipeline))
+	for _, st := range cfg.Server.ResponsePipeline {
+		// Steps were checked by config.Validate
+		var step ResponseStep
+		switch st.Type {
+		case config.ResponseStepTTLClamp:
+			step = TTLClamp{Min: uint32(st.MinTTL), Max: uint32(st.MaxTTL)}
+		case config.ResponseStepFilterAAAA:
+			step = AAAAFilter{}
+		case config.ResponseStepReorder:
+			order, _ := resolvers.ParseAnswerOrder(st.Order)
+			step = &AnswerReorder{Order: order}
+		case config.ResponseStepEDE:
+			rcode, _ := dns.ParseRCode(st.RCode)
+			step = ExtendedErrorInsert{
+				RCode: rcode,
+				Error: dns.ExtendedError{InfoCode: uint16(st.Code), ExtraText: st.Text},
+			}
+		case config.ResponseStepScrub:
+			var types []dns.RecordType
+			for _, name := range st.RecordTypes {
+				if t, err := dns.ParseRecordType(name); err == nil {
+					types = append(types, t)
+				}
+			}
+			step = Scrub{Types: types}
+		default:
+			continue
+		}
+		stages = append(stages, ResponseStage{Step: step, Clients: st.ClientPrefixes()})
+	}
+	return NewResponsePipeline(stages)
+}
+
+// BuildAnomalyDetector constructs an anomaly detector from the config, or
+// returns nil when anomaly detection is disabled.
+func BuildAnomalyDetector(cfg *config.Config) *AnomalyDetector {
+	if !cfg.Anomaly.Enabled {
+		return nil
+	}
+	// The window was checked by config.Validate
+	window, _ := time.ParseDuration(cfg.Anomaly.Window)
+	return NewAnomalyDetector(AnomalySettings{
+		Window:          window,
+		MinQueries:      cfg.Anomaly.MinQueries,
+		NXDOMAINRatio:   cfg.Anomaly.NXDOMAINRatio,
+		TXTRatio:        cfg.Anomaly.TXTRatio,
+		MaxLabelLength:  cfg.Anomaly.MaxLabelLength,
+		MinLabelEntropy: cfg.Anomaly.MinLabelEntropy,
 	})
 }
 
+// closeAudit closes the audit log, reporting entries that could not be
+// written.
+func (r *Runner) closeAudit(l *audit.Logger) {
+	r.audit.Store(nil)
+	if err := l.Err(); err != nil && r.logger != nil {
+		r.logger.Error("audit log is incomplete: entries could not be written", "error", err)
+	}
+	if err := l.Close(); err != nil && r.logger != nil {
+		r.logger.Error("failed to close audit log", "error", err)
+	}
+}
+
+// BuildThreatIntelChecker creates the threat intelligence checker for cfg,
+// or returns nil when threat intelligence is disabled. Malicious domains are
+// blocked through policy for the configured block TTL.
+func BuildThreatIntelChecker(cfg *config.Config, policy *filtering.PolicyEngine, logger *slog.Logger) *threatintel.Checker {
+	if !cfg.ThreatIntel.Enabled || policy == nil {
+		return nil
+	}
+	// Durations were checked by config.Validate
+	blockTTL, _ := time.ParseDuration(cfg.ThreatIntel.BlockTTL)
+	cacheTTL, _ := time.ParseDuration(cfg.ThreatIntel.CacheTTL)
+
+	feed := &threatintel.URLhaus{URL: cfg.ThreatIntel.URL, AuthKey: cfg.ThreatIntel.AuthKey}
+	return threatintel.NewChecker(feed, threatintel.Settings{
+		CacheTTL:  cacheTTL,
+		RateLimit: cfg.ThreatIntel.RateLimit,
+	}, func(domain string) {
+		policy.BlockFor(domain, blockTTL, "threat-intel")
+		if logger != nil {
+			logger.Warn("threat intel: blocking malicious domain",
+				"domain", domain,
+				"provider", cfg.ThreatIntel.Provider,
+				"ttl", blockTTL.String(),
+			)
+		}
+	})
+}
+
+// BuildNegativeCacheRules converts the cache config into resolver rules.
+// Zone overrides inherit any unset field from the global settings. Invalid
+// durations fall back to the built-in defaults (config.Validate rejects them).
+func BuildNegativeCacheRules(cfg *config.Config) resolvers.NegativeCacheRules {
+	def := resolvers.DefaultNegativeCachePolicy()
+	def.Enabled = !cfg.Cache.DisableNegative
+	if d, err := time.ParseDuration(cfg.Cache.ServfailTTL); err == nil {
+		def.ServfailTTL = d
+	}
+	if d, err := time.ParseDuration(cfg.Cache.NegativeTTL); err == nil {
+		def.NegativeTTL = d
+	}
+
+	rules := resolvers.NegativeCacheRules{Default: def}
+	if len(cfg.Cache.ZoneOverrides) == 0 {
+		return rules
+	}
+
+	rules.Zones = make(map[string]resolvers.NegativeCachePolicy, len(cfg.Cache.ZoneOverrides))
+	for _, o := range cfg.Cache.ZoneOverrides {
+		p := def
+		if o.DisableNegative != nil {
+			p.Enabled = !*o.DisableNegative
+		}
+		if d, err := time.ParseDuration(o.ServfailTTL); err == nil {
+			p.ServfailTTL = d
+		}
+		if d, err := time.ParseDuration(o.NegativeTTL); err == nil {
+			p.NegativeTTL = d
+		}
+		zone := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(o.Zone)), ".")
+		rules.Zones[zone] = p
+	}
+	return rules
+}
+
+// BuildEDNSPolicy converts the upstream EDNS option rules into a resolver
+// policy. Invalid rules are skipped (config.Validate rejects them).
+func BuildEDNSPolicy(cfg *config.Config) resolvers.EDNSPolicy {
+	if len(cfg.Upstream.EDNSOptions) == 0 {
+		return nil
+	}
+
+	policy := make(resolvers.EDNSPolicy, len(cfg.Upstream.EDNSOptions))
+	for _, r := range cfg.Upstream.EDNSOptions {
+		code, err := r.Code()
+		if err != nil {
+			continue
+		}
+		switch r.Action {
+		case config.EDNSActionStrip:
+			policy[code] = resolvers.EDNSOptionRule{Action: resolvers.EDNSStrip}
+		case config.EDNSActionRewrite:
+			data, err := r.RewriteData()
+			if err != nil {
+				continue
+			}
+			policy[code] = resolvers.EDNSOptionRule{Action: resolvers.EDNSRewrite, Data: data}
+		default:
+			policy[code] = resolvers.EDNSOptionRule{Action: resolvers.EDNSPass}
+		}
+	}
+	return policy
+}
+
+// newTCPServer creates the TCP server from the server configuration.
+// Invalid timeouts have been rejected by config validation; zero values
+// select the TCPServer defaults.
+func (r *Runner) newTCPServer(cfg *config.Config, h *QueryHandler) *TCPServer {
+	readTimeout, _ := time.ParseDuration(cfg.Server.TCPReadTimeout)
+	idleTimeout, _ := time.ParseDuration(cfg.Server.TCPIdleTimeout)
+	return &TCPServer{
+		Logger:            r.logger,
+		Handler:           h,
+		ReadTimeout:       readTimeout,
+		IdleTimeout:       idleTimeout,
+		MaxConnsPerIP:     cfg.Server.TCPMaxConnsPerIP,
+		MaxQueriesPerConn: cfg.Server.TCPMaxQueriesPerConn,
+		Listeners:         cfg.Server.TCPListeners,
+		ProxyProtocolFrom: cfg.Server.ProxyProtocolPrefixes(),
+		Capture:           r.capture,
+	}
+}
+
+// logUpstreamGeoIP logs the country and ASN of each upstream server.
+func (r *Runner) logUpstreamGeoIP(cfg *config.Config, db *geoip.DB) {
+	if r.logger == nil {
+		return
+	}
+	for _, server := range cfg.Upstream.Servers {
+		ip, err := netip.ParseAddr(server)
+		if err != nil {
+			continue
+		}
+		if info := db.Lookup(ip); !info.IsZero() {
+			r.logger.Info("upstream geoip", "upstream", server, "geo", info.String())
+		}
+	}
+}
+
 // logStartup logs server configuration at startup.
 func (r *Runner) logStartup(cfg *config.Config, addr string, maxConc, upPool int) {
 	if r.logger != nil {
@@ -351,6 +1150,8 @@ func (r *Runner) logStartup(cfg *config.Config, addr string, maxConc, upPool int
 			"tcp", cfg.Server.EnableTCP,
 			"upstreams", cfg.Upstream.Servers,
 			"max_concurrency", maxConc,
+			"udp_listeners", cfg.Server.UDPListeners,
+			"tcp_listeners", cfg.Server.TCPListeners,
 			"upstream_pool", upPool,
 		)
 	}
@@ -2,13 +2,34 @@
 package server_test
 
 import (
+	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/jroosing/hydradns/internal/capture"
+	"github.com/jroosing/hydradns/internal/clock"
+	"github.com/jroosing/hydradns/internal/config"
 	"github.com/jroosing/hydradns/internal/dns"
+	"github.com/jroosing/hydradns/internal/filtering"
 	"github.com/jroosing/hydradns/internal/resolvers"
 	"github.com/jroosing/hydradns/internal/server"
 	"github.com/stretchr/testify/assert"
@@ -137,6 +158,72 @@ func TestRateLimiter_PrefixLimit(t *testing.T) {
 	assert.False(t, limiter.Allow("192.168.1.4"), "Should be prefix-limited")
 }
 
+func TestRateLimiter_IPv6PrefixLimit(t *testing.T) {
+	limiter := server.NewRateLimiter(server.RateLimitSettings{
+		MaxPrefixEntries: 100,
+		PrefixQPS:        0.001,
+		PrefixBurst:      1,
+	})
+
+	// The default /64 groups both addresses
+	assert.True(t, limiter.AllowAddr(netip.MustParseAddr("2001:db8:0:1::1")))
+	assert.False(t, limiter.AllowAddr(netip.MustParseAddr("2001:db8:0:1::2")), "Should share the /64")
+	assert.True(t, limiter.AllowAddr(netip.MustParseAddr("2001:db8:0:2::1")), "Another /64 has its own bucket")
+}
+
+func TestRateLimiter_PrefixLengths(t *testing.T) {
+	limiter := server.NewRateLimiter(server.RateLimitSettings{
+		MaxPrefixEntries: 100,
+		PrefixQPS:        0.001,
+		PrefixBurst:      1,
+		IPv4PrefixLen:    32,
+		IPv6PrefixLen:    56,
+	})
+
+	// /32 gives every IPv4 address its own prefix bucket
+	assert.True(t, limiter.Allow("192.168.1.1"))
+	assert.True(t, limiter.Allow("192.168.1.2"))
+	assert.False(t, limiter.Allow("192.168.1.1"))
+
+	// /56 groups neighbouring /64s
+	assert.True(t, limiter.AllowAddr(netip.MustParseAddr("2001:db8:0:1::1")))
+	assert.False(t, limiter.AllowAddr(netip.MustParseAddr("2001:db8:0:2::1")), "Should share the /56")
+	assert.True(t, limiter.AllowAddr(netip.MustParseAddr("2001:db8:0:100::1")), "Another /56 has its own bucket")
+}
+
+func TestRateLimiter_ExemptLocal(t *testing.T) {
+	settings := server.RateLimitSettings{
+		MaxIPEntries:     100,
+		MaxPrefixEntries: 100,
+		PrefixQPS:        0.001,
+		PrefixBurst:      1,
+		IPQPS:            0.001,
+		IPBurst:          1,
+	}
+	local := []string{"fe80::1", "fd00::1", "169.254.1.1"}
+
+	limiter := server.NewRateLimiter(settings)
+	for _, ip := range local {
+		addr := netip.MustParseAddr(ip)
+		assert.True(t, limiter.AllowAddr(addr), ip)
+		assert.False(t, limiter.AllowAddr(addr), "%s should be limited without the exemption", ip)
+	}
+
+	settings.ExemptLocal = true
+	limiter = server.NewRateLimiter(settings)
+	for _, ip := range local {
+		addr := netip.MustParseAddr(ip)
+		for range 3 {
+			assert.Equal(t, server.AdmitAllow, limiter.AdmitAddr(addr), ip)
+		}
+	}
+
+	// Other addresses are still limited
+	addr := netip.MustParseAddr("2001:db8::1")
+	assert.True(t, limiter.AllowAddr(addr))
+	assert.False(t, limiter.AllowAddr(addr))
+}
+
 func TestRateLimiter_GlobalLimit(t *testing.T) {
 	limiter := server.NewRateLimiter(server.RateLimitSettings{
 		GlobalQPS:   10,
@@ -207,6 +294,31 @@ func TestTokenBucket_TokenReplenishment(t *testing.T) {
 	assert.True(t, tb.Allow("key1"), "Should have replenished tokens")
 }
 
+func TestTokenBucket_ReplenishesWithClock(t *testing.T) {
+	clk := clock.NewManual(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	tb := server.NewTokenBucketRateLimiter(server.TokenBucketConfig{
+		Rate:       2.0,
+		Burst:      2,
+		MaxEntries: 100,
+		Clock:      clk,
+	})
+
+	assert.True(t, tb.Allow("key1"))
+	assert.True(t, tb.Allow("key1"))
+	assert.False(t, tb.Allow("key1"), "burst used up")
+
+	clk.Advance(250 * time.Millisecond)
+	assert.False(t, tb.Allow("key1"), "half a token replenished")
+	clk.Advance(250 * time.Millisecond)
+	assert.True(t, tb.Allow("key1"), "one token replenished")
+	assert.False(t, tb.Allow("key1"))
+
+	clk.Advance(time.Hour)
+	assert.True(t, tb.Allow("key1"))
+	assert.True(t, tb.Allow("key1"))
+	assert.False(t, tb.Allow("key1"), "replenishment is capped at the burst")
+}
+
 func TestTokenBucket_DisabledWithZeroRate(t *testing.T) {
 	tb := server.NewTokenBucketRateLimiter(server.TokenBucketConfig{
 		Rate:       0, // Disabled
@@ -219,6 +331,78 @@ func TestTokenBucket_DisabledWithZeroRate(t *testing.T) {
 	assert.True(t, tb.Allow("key1"), "Should allow when rate is 0 (disabled)")
 }
 
+func TestTokenBucket_MaxEntries(t *testing.T) {
+	tb := server.NewTokenBucketRateLimiter(server.TokenBucketConfig{
+		Rate:       1.0,
+		Burst:      5,
+		MaxEntries: 2,
+	})
+
+	assert.True(t, tb.Allow("key1"))
+	assert.True(t, tb.Allow("key2"))
+	assert.False(t, tb.Allow("key3"), "New keys should be denied at capacity")
+	assert.True(t, tb.Allow("key1"), "Known keys are still served")
+}
+
+func TestTokenBucket_ShardedKeysIndependent(t *testing.T) {
+	tb := server.NewTokenBucketRateLimiter(server.TokenBucketConfig{
+		Rate:       0.001,
+		Burst:      2,
+		MaxEntries: 65536,
+	})
+
+	for i := range 1000 {
+		key := "10.0." + strconv.Itoa(i/256) + "." + strconv.Itoa(i%256)
+		assert.True(t, tb.Allow(key), key)
+		assert.True(t, tb.Allow(key), key)
+		assert.False(t, tb.Allow(key), "%s should be limited after its burst", key)
+	}
+}
+
+func TestTokenBucket_ConcurrentAllow(t *testing.T) {
+	tb := server.NewTokenBucketRateLimiter(server.TokenBucketConfig{
+		Rate:       0.001,
+		Burst:      100,
+		MaxEntries: 65536,
+	})
+
+	var allowed atomic.Int32
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Go(func() {
+			for range 50 {
+				if tb.Allow("shared") {
+					allowed.Add(1)
+				}
+				tb.Allow("other")
+			}
+		})
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(100), allowed.Load(), "Exactly the burst should be allowed")
+}
+
+func BenchmarkTokenBucket_AllowParallel(b *testing.B) {
+	tb := server.NewTokenBucketRateLimiter(server.TokenBucketConfig{
+		Rate:       1e9,
+		Burst:      1e9,
+		MaxEntries: 65536,
+	})
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = "10.0." + strconv.Itoa(i/256) + "." + strconv.Itoa(i%256)
+	}
+
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			tb.Allow(keys[i%len(keys)])
+			i++
+		}
+	})
+}
+
 // ============================================================================
 // RateLimitSettings Tests
 // ============================================================================
@@ -306,13 +490,128 @@ func TestQueryHandler_SuccessfulResolve(t *testing.T) {
 		Timeout:  5 * time.Second,
 	}
 
-	result := handler.Handle(context.Background(), "udp", "127.0.0.1:12345", createValidDNSRequest(t))
+	result := handler.Handle(withClient(context.Background(), "udp", "127.0.0.1"), createValidDNSRequest(t))
 
 	assert.True(t, result.ParsedOK, "Should successfully parse request")
 	assert.Equal(t, responseBytes, result.ResponseBytes)
 	assert.Equal(t, "test", result.Source)
 }
 
+// withClient returns ctx carrying a query from ip over transport, as the
+// UDP and TCP servers pass it to the query handler.
+func withClient(ctx context.Context, transport, ip string) context.Context {
+	return resolvers.WithClientInfo(ctx, resolvers.ClientInfo{Addr: netip.MustParseAddr(ip), Transport: transport})
+}
+
+func TestQueryHandler_PassesClientToResolver(t *testing.T) {
+	var got []resolvers.ClientInfo
+	resolver := &mockResolver{
+		resolveFunc: func(ctx context.Context, _ dns.Packet, _ []byte) (resolvers.Result, error) {
+			info, ok := resolvers.ClientInfoFromContext(ctx)
+			require.True(t, ok)
+			got = append(got, info)
+			return resolvers.Result{}, errors.New("no answer")
+		},
+	}
+	handler := &server.QueryHandler{Resolver: resolver, Timeout: time.Second, Listener: "guest"}
+
+	ctx := withClient(context.Background(), "tcp", "::ffff:192.168.1.10")
+	handler.Handle(ctx, createValidDNSRequest(t))
+	handler.Handle(ctx, createValidDNSRequest(t))
+	require.Len(t, got, 2)
+	assert.Equal(t, netip.MustParseAddr("192.168.1.10"), got[0].Addr, "IPv4-mapped addresses are unmapped")
+	assert.Equal(t, "tcp", got[0].Transport)
+	assert.Equal(t, "guest", got[0].Listener)
+	assert.Len(t, got[0].TraceID, 16)
+	assert.NotEqual(t, got[0].TraceID, got[1].TraceID, "each query gets its own trace ID")
+
+	// A trace ID set by the caller is kept
+	ctx = resolvers.WithClientInfo(context.Background(), resolvers.ClientInfo{Transport: "udp", TraceID: "abc"})
+	handler.Handle(ctx, createValidDNSRequest(t))
+	require.Len(t, got, 3)
+	assert.Equal(t, "abc", got[2].TraceID)
+	assert.False(t, got[2].Addr.IsValid())
+}
+
+func TestQueryHandler_RecoversResolverPanic(t *testing.T) {
+	var calls atomic.Int32
+	resolver := &mockResolver{
+		resolveFunc: func(_ context.Context, req dns.Packet, _ []byte) (resolvers.Result, error) {
+			if calls.Add(1) == 1 {
+				var m map[string]int
+				m["boom"]++ // nil map write
+			}
+			resp := req
+			resp.Header.Flags |= dns.QRFlag
+			b, err := resp.Marshal()
+			return resolvers.Result{ResponseBytes: b, Source: "test"}, err
+		},
+	}
+	stats := server.NewDNSStats()
+	handler := &server.QueryHandler{Resolver: resolver, Timeout: time.Second, Stats: stats}
+
+	result := handler.Handle(withClient(context.Background(), "udp", "127.0.0.1"), createValidDNSRequest(t))
+	assert.Equal(t, "panic", result.Source)
+	require.GreaterOrEqual(t, len(result.ResponseBytes), dns.HeaderSize)
+	rcode := dns.RCodeFromFlags(binary.BigEndian.Uint16(result.ResponseBytes[2:4]))
+	assert.Equal(t, dns.RCodeServFail, rcode)
+
+	snap := stats.Snapshot()
+	assert.Equal(t, uint64(1), snap.Panics)
+	assert.Equal(t, uint64(1), snap.ResponsesErr)
+
+	// The next query is served normally
+	result = handler.Handle(withClient(context.Background(), "udp", "127.0.0.1"), createValidDNSRequest(t))
+	assert.Equal(t, "test", result.Source)
+}
+
+func TestQueryHandler_PreservesQNAMECase(t *testing.T) {
+	// Resolvers answer from the parsed (lowercased) request.
+	resolver := &mockResolver{
+		resolveFunc: func(_ context.Context, req dns.Packet, _ []byte) (resolvers.Result, error) {
+			resp := req
+			resp.Header.Flags |= dns.QRFlag
+			b, err := resp.Marshal()
+			return resolvers.Result{ResponseBytes: b, Source: "test"}, err
+		},
+	}
+	handler := &server.QueryHandler{Resolver: resolver, Timeout: 5 * time.Second}
+
+	req := dns.Packet{
+		Header:    dns.Header{ID: 0x1234, Flags: dns.RDFlag},
+		Questions: []dns.Question{{Name: "ExAmPlE.CoM", Type: uint16(dns.TypeA), Class: uint16(dns.ClassIN)}},
+	}
+	reqBytes, err := req.Marshal()
+	require.NoError(t, err)
+
+	result := handler.Handle(withClient(context.Background(), "udp", "127.0.0.1"), reqBytes)
+
+	require.True(t, result.ParsedOK)
+	assert.Equal(t, "example.com", result.Parsed.Questions[0].Name)
+	// Header aside, the response question is byte-identical to the request.
+	assert.Equal(t, reqBytes[12:], result.ResponseBytes[12:])
+}
+
+func TestQueryHandler_RecordsParseStatsPerTransport(t *testing.T) {
+	stats := server.NewDNSStats()
+	handler := &server.QueryHandler{
+		Resolver: &mockResolver{},
+		Timeout:  5 * time.Second,
+		Stats:    stats,
+	}
+
+	// Question name is a compression pointer to itself
+	loop := []byte{0x12, 0x34, 0x01, 0x00, 0, 1, 0, 0, 0, 0, 0, 0, 0xC0, 0x0C, 0, 1, 0, 1}
+	result := handler.Handle(withClient(context.Background(), "tcp", "127.0.0.1"), loop)
+	assert.False(t, result.ParsedOK)
+
+	snap := stats.Snapshot()
+	assert.Equal(t, uint64(1), snap.ParseTCP.Malformed)
+	assert.Equal(t, uint64(1), snap.ParseTCP.PointerLoops)
+	assert.Zero(t, snap.ParseUDP.Malformed)
+	assert.Equal(t, uint64(1), snap.ResponsesErr)
+}
+
 func TestQueryHandler_ResolverError(t *testing.T) {
 	resolver := &mockResolver{
 		resolveFunc: func(_ context.Context, _ dns.Packet, _ []byte) (resolvers.Result, error) {
@@ -325,7 +624,7 @@ func TestQueryHandler_ResolverError(t *testing.T) {
 		Timeout:  5 * time.Second,
 	}
 
-	result := handler.Handle(context.Background(), "udp", "127.0.0.1:12345", createValidDNSRequest(t))
+	result := handler.Handle(withClient(context.Background(), "udp", "127.0.0.1"), createValidDNSRequest(t))
 
 	assert.True(t, result.ParsedOK)
 	assert.Equal(t, "servfail", result.Source)
@@ -333,6 +632,122 @@ func TestQueryHandler_ResolverError(t *testing.T) {
 	assert.NotNil(t, result.ResponseBytes)
 }
 
+func TestQueryHandler_CNAMEChainError(t *testing.T) {
+	resolver := &mockResolver{
+		resolveFunc: func(_ context.Context, _ dns.Packet, _ []byte) (resolvers.Result, error) {
+			return resolvers.Result{}, fmt.Errorf("%w: loop at a.example", resolvers.ErrCNAMEChain)
+		},
+	}
+	handler := &server.QueryHandler{Resolver: resolver, Timeout: 5 * time.Second}
+
+	req := dns.Packet{
+		Header:    dns.Header{ID: 0x1234, Flags: dns.RDFlag},
+		Questions: []dns.Question{{Name: "a.example", Type: uint16(dns.TypeA), Class: uint16(dns.ClassIN)}},
+	}
+	reqBytes, err := req.Marshal()
+	require.NoError(t, err)
+	reqBytes = dns.AddEDNSToRequestBytes(req, reqBytes, dns.EDNSDefaultUDPPayloadSize)
+
+	result := handler.Handle(withClient(context.Background(), "udp", "127.0.0.1"), reqBytes)
+
+	assert.Equal(t, "cname-chain", result.Source)
+	resp, err := dns.ParsePacket(result.ResponseBytes)
+	require.NoError(t, err)
+	assert.Equal(t, dns.RCodeServFail, dns.RCodeFromFlags(resp.Header.Flags))
+	ede, ok := dns.ExtractExtendedError(resp.Additionals)
+	require.True(t, ok)
+	assert.Equal(t, dns.EDEOther, ede.InfoCode)
+	assert.Contains(t, ede.ExtraText, "loop at a.example")
+}
+
+func TestQueryHandler_TypedErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		source string
+		rcode  dns.RCode
+		ede    uint16
+		text   string
+	}{
+		{
+			name:   "blocked",
+			err:    &resolvers.BlockedError{Source: "filtered-blocked", Reason: "listed in ads"},
+			source: "filtered-blocked",
+			rcode:  dns.RCodeNXDomain,
+			ede:    dns.EDEBlocked,
+			text:   "listed in ads",
+		},
+		{
+			name:   "no upstream",
+			err:    fmt.Errorf("%w: connection refused", resolvers.ErrNoUpstream),
+			source: "no-upstream",
+			rcode:  dns.RCodeServFail,
+			ede:    dns.EDENoReachableAuthority,
+			text:   "no upstream server answered",
+		},
+		{
+			name:   "upstream timeout",
+			err:    fmt.Errorf("%w: %w", resolvers.ErrNoUpstream, resolvers.ErrTimeout),
+			source: "timeout",
+			rcode:  dns.RCodeServFail,
+			ede:    dns.EDENoReachableAuthority,
+			text:   "timed out",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := &mockResolver{
+				resolveFunc: func(_ context.Context, _ dns.Packet, _ []byte) (resolvers.Result, error) {
+					return resolvers.Result{}, tt.err
+				},
+			}
+			handler := &server.QueryHandler{Resolver: resolver, Timeout: 5 * time.Second}
+
+			req := dns.Packet{
+				Header:    dns.Header{ID: 0x1234, Flags: dns.RDFlag},
+				Questions: []dns.Question{{Name: "a.example", Type: uint16(dns.TypeA), Class: uint16(dns.ClassIN)}},
+			}
+			reqBytes, err := req.Marshal()
+			require.NoError(t, err)
+			reqBytes = dns.AddEDNSToRequestBytes(req, reqBytes, dns.EDNSDefaultUDPPayloadSize)
+
+			result := handler.Handle(withClient(context.Background(), "udp", "127.0.0.1"), reqBytes)
+
+			assert.Equal(t, tt.source, result.Source)
+			resp, err := dns.ParsePacket(result.ResponseBytes)
+			require.NoError(t, err)
+			assert.Equal(t, tt.rcode, dns.RCodeFromFlags(resp.Header.Flags))
+			ede, ok := dns.ExtractExtendedError(resp.Additionals)
+			require.True(t, ok)
+			assert.Equal(t, tt.ede, ede.InfoCode)
+			assert.Equal(t, tt.text, ede.ExtraText)
+		})
+	}
+}
+
+func TestQueryHandler_BlockedNegativeTTL(t *testing.T) {
+	resolver := &mockResolver{
+		resolveFunc: func(_ context.Context, _ dns.Packet, _ []byte) (resolvers.Result, error) {
+			return resolvers.Result{}, &resolvers.BlockedError{Source: "filtered-blocked", NegativeTTL: 30}
+		},
+	}
+	handler := &server.QueryHandler{Resolver: resolver, Timeout: 5 * time.Second}
+
+	result := handler.Handle(withClient(context.Background(), "udp", "127.0.0.1"), createValidDNSRequest(t))
+
+	resp, err := dns.ParsePacket(result.ResponseBytes)
+	require.NoError(t, err)
+	assert.Equal(t, dns.RCodeNXDomain, dns.RCodeFromFlags(resp.Header.Flags))
+	require.Len(t, resp.Authorities, 1)
+	soa, ok := resp.Authorities[0].(*dns.OpaqueRecord)
+	require.True(t, ok)
+	assert.Equal(t, dns.TypeSOA, soa.Type())
+	assert.Equal(t, uint32(30), soa.H.TTL)
+	rdata, ok := soa.Data.([]byte)
+	require.True(t, ok)
+	assert.Equal(t, uint32(30), binary.BigEndian.Uint32(rdata[len(rdata)-4:]), "SOA MINIMUM")
+}
+
 func TestQueryHandler_Timeout(t *testing.T) {
 	resolver := &mockResolver{
 		resolveFunc: func(_ context.Context, _ dns.Packet, _ []byte) (resolvers.Result, error) {
@@ -346,7 +761,7 @@ func TestQueryHandler_Timeout(t *testing.T) {
 		Timeout:  10 * time.Millisecond, // Very short timeout
 	}
 
-	result := handler.Handle(context.Background(), "udp", "127.0.0.1:12345", createValidDNSRequest(t))
+	result := handler.Handle(withClient(context.Background(), "udp", "127.0.0.1"), createValidDNSRequest(t))
 
 	assert.True(t, result.ParsedOK)
 	assert.Equal(t, "timeout", result.Source)
@@ -361,7 +776,7 @@ func TestQueryHandler_InvalidRequest(t *testing.T) {
 	}
 
 	// Send garbage that can't be parsed
-	result := handler.Handle(context.Background(), "udp", "127.0.0.1:12345", []byte{0x00})
+	result := handler.Handle(withClient(context.Background(), "udp", "127.0.0.1"), []byte{0x00})
 
 	assert.False(t, result.ParsedOK)
 	assert.Contains(t, []string{"parse-error", "formerr"}, result.Source)
@@ -386,12 +801,446 @@ func TestQueryHandler_ContextCancellation(t *testing.T) {
 		cancel()
 	}()
 
-	result := handler.Handle(ctx, "udp", "127.0.0.1:12345", createValidDNSRequest(t))
+	result := handler.Handle(withClient(ctx, "udp", "127.0.0.1"), createValidDNSRequest(t))
 
 	// Should handle cancellation gracefully
 	assert.True(t, result.ParsedOK)
 }
 
+func TestQueryHandler_PublishesQueryEvents(t *testing.T) {
+	events := &server.QueryEvents{}
+	handler := &server.QueryHandler{
+		Resolver: &mockResolver{}, // Fails, so the client gets SERVFAIL
+		Timeout:  time.Second,
+		Events:   events,
+	}
+
+	// Nothing is published without subscribers.
+	handler.Handle(withClient(context.Background(), "udp", "192.0.2.1"), createValidDNSRequest(t))
+
+	ch, cancel := events.Subscribe(4)
+	defer cancel()
+	handler.Handle(withClient(context.Background(), "tcp", "192.0.2.7"), createValidDNSRequest(t))
+
+	require.Len(t, ch, 1)
+	ev := <-ch
+	assert.Equal(t, "tcp", ev.Transport)
+	assert.Equal(t, "192.0.2.7", ev.Client)
+	assert.Equal(t, "example.com", ev.Name)
+	assert.Equal(t, dns.TypeA, ev.Type)
+	assert.Equal(t, dns.RCodeServFail, ev.RCode)
+	assert.Equal(t, "servfail", ev.Source)
+	assert.Len(t, ev.TraceID, 16)
+}
+
+// nsidQuery returns an A query for a.example asking for the name server
+// identifier.
+func nsidQuery(t *testing.T) []byte {
+	t.Helper()
+	opt := dns.CreateOPT(dns.EDNSDefaultUDPPayloadSize)
+	opt.Options = []dns.EDNSOption{{Code: dns.EDNSOptionNSID}}
+	req := dns.Packet{
+		Header:      dns.Header{ID: 0x1234, Flags: dns.RDFlag},
+		Questions:   []dns.Question{{Name: "a.example", Type: uint16(dns.TypeA), Class: uint16(dns.ClassIN)}},
+		Additionals: []dns.Record{opt.Record()},
+	}
+	b, err := req.Marshal()
+	require.NoError(t, err)
+	return b
+}
+
+// responseOption returns the data of the option with code in resp's OPT
+// record.
+func responseOption(t *testing.T, resp []byte, code uint16) ([]byte, bool) {
+	t.Helper()
+	var data []byte
+	found := false
+	dns.RewriteEDNSOptions(resp, func(opts []dns.EDNSOption) []dns.EDNSOption {
+		for _, o := range opts {
+			if o.Code == code {
+				data, found = o.Data, true
+			}
+		}
+		return opts
+	})
+	return data, found
+}
+
+func TestQueryHandler_NSIDCarriesTraceID(t *testing.T) {
+	resolver := &mockResolver{
+		resolveFunc: func(_ context.Context, _ dns.Packet, _ []byte) (resolvers.Result, error) {
+			return resolvers.Result{}, &resolvers.BlockedError{Source: "filtered-blocked", Reason: "listed in ads"}
+		},
+	}
+	handler := &server.QueryHandler{Resolver: resolver, Timeout: 5 * time.Second, ServerID: "ns1"}
+	ctx := resolvers.WithClientInfo(context.Background(), resolvers.ClientInfo{
+		Addr:      netip.MustParseAddr("192.0.2.1"),
+		Transport: "udp",
+		TraceID:   "00000000feedf00d",
+	})
+
+	result := handler.Handle(ctx, nsidQuery(t))
+
+	nsid, ok := responseOption(t, result.ResponseBytes, dns.EDNSOptionNSID)
+	require.True(t, ok)
+	assert.Equal(t, "ns1 trace=00000000feedf00d", string(nsid))
+	resp, err := dns.ParsePacket(result.ResponseBytes)
+	require.NoError(t, err)
+	ede, ok := dns.ExtractExtendedError(resp.Additionals)
+	require.True(t, ok)
+	assert.Equal(t, "listed in ads; trace=00000000feedf00d", ede.ExtraText)
+
+	// Without the NSID request, responses carry no trace
+	req, err := dns.ParsePacket(nsidQuery(t))
+	require.NoError(t, err)
+	plain, err := dns.Packet{Header: req.Header, Questions: req.Questions}.Marshal()
+	require.NoError(t, err)
+	plain = dns.AddEDNSToRequestBytes(dns.Packet{}, plain, dns.EDNSDefaultUDPPayloadSize)
+	result = handler.Handle(ctx, plain)
+	_, ok = responseOption(t, result.ResponseBytes, dns.EDNSOptionNSID)
+	assert.False(t, ok)
+	resp, err = dns.ParsePacket(result.ResponseBytes)
+	require.NoError(t, err)
+	ede, ok = dns.ExtractExtendedError(resp.Additionals)
+	require.True(t, ok)
+	assert.Equal(t, "listed in ads", ede.ExtraText)
+}
+
+func TestQueryHandler_NSIDAddsOPT(t *testing.T) {
+	// Answers built without an OPT record get one to carry the NSID
+	answer := dns.Packet{
+		Header:    dns.Header{ID: 0x1234, Flags: dns.QRFlag | dns.RDFlag},
+		Questions: []dns.Question{{Name: "a.example", Type: uint16(dns.TypeA), Class: uint16(dns.ClassIN)}},
+	}
+	respBytes, err := answer.Marshal()
+	require.NoError(t, err)
+	resolver := &mockResolver{
+		resolveFunc: func(_ context.Context, _ dns.Packet, _ []byte) (resolvers.Result, error) {
+			return resolvers.Result{ResponseBytes: respBytes, Source: "custom-dns"}, nil
+		},
+	}
+	handler := &server.QueryHandler{Resolver: resolver, Timeout: 5 * time.Second}
+
+	result := handler.Handle(withClient(context.Background(), "udp", "192.0.2.1"), nsidQuery(t))
+
+	nsid, ok := responseOption(t, result.ResponseBytes, dns.EDNSOptionNSID)
+	require.True(t, ok)
+	assert.Regexp(t, `^trace=[0-9a-f]{16}$`, string(nsid))
+	resp, err := dns.ParsePacket(result.ResponseBytes)
+	require.NoError(t, err)
+	assert.NotNil(t, dns.ExtractOPT(resp.Additionals))
+}
+
+// ============================================================================
+// QueryEvents Tests
+// ============================================================================
+
+func TestQueryEvents_FansOutAndDropsForSlowSubscribers(t *testing.T) {
+	var events server.QueryEvents
+	assert.False(t, events.Active())
+
+	fast, cancelFast := events.Subscribe(3)
+	slow, cancelSlow := events.Subscribe(1)
+	assert.True(t, events.Active())
+
+	for i := range 3 {
+		events.Publish(server.QueryEvent{Name: fmt.Sprintf("q%d.example", i)})
+	}
+
+	assert.Len(t, fast, 3)
+	assert.Len(t, slow, 1)
+	assert.Equal(t, uint64(2), events.Dropped())
+	assert.Equal(t, "q0.example", (<-slow).Name)
+
+	cancelSlow()
+	cancelSlow() // Idempotent
+	_, open := <-slow
+	assert.False(t, open, "cancel closes the channel")
+
+	cancelFast()
+	assert.False(t, events.Active())
+	events.Publish(server.QueryEvent{}) // No subscribers left
+	assert.Len(t, fast, 3)
+}
+
+// ============================================================================
+// AnomalyDetector Tests
+// ============================================================================
+
+func newAnomalyDetector() *server.AnomalyDetector {
+	return server.NewAnomalyDetector(server.AnomalySettings{
+		Window:          time.Minute,
+		MinQueries:      10,
+		NXDOMAINRatio:   0.5,
+		TXTRatio:        0.5,
+		MaxLabelLength:  50,
+		MinLabelEntropy: 4.0,
+	})
+}
+
+func TestAnomalyDetector_NXDOMAINRate(t *testing.T) {
+	d := newAnomalyDetector()
+	now := time.Now()
+
+	var raised []server.Anomaly
+	for i := range 20 {
+		raised = append(raised, d.Observe(server.QueryEvent{
+			Time:   now.Add(time.Duration(i) * time.Second),
+			Client: "192.0.2.1",
+			Name:   fmt.Sprintf("host%d.example.com", i),
+			Type:   dns.TypeA,
+			RCode:  dns.RCodeNXDomain,
+		})...)
+	}
+
+	require.Len(t, raised, 1, "one alert per client and kind per window")
+	assert.Equal(t, server.AnomalyNXDOMAINRate, raised[0].Kind)
+	assert.Equal(t, "192.0.2.1", raised[0].Client)
+	assert.Equal(t, "100% NXDOMAIN over 10 queries", raised[0].Detail)
+	assert.Equal(t, uint64(1), d.Counts()[server.AnomalyNXDOMAINRate])
+
+	// A new window starts counting from zero
+	raised = d.Observe(server.QueryEvent{Time: now.Add(2 * time.Minute), Client: "192.0.2.1", RCode: dns.RCodeNXDomain})
+	assert.Empty(t, raised)
+}
+
+func TestAnomalyDetector_TXTRate(t *testing.T) {
+	d := newAnomalyDetector()
+	now := time.Now()
+
+	var raised []server.Anomaly
+	for i := range 10 {
+		qtype := dns.TypeTXT
+		if i%2 == 0 {
+			qtype = dns.TypeNULL
+		}
+		raised = append(raised, d.Observe(server.QueryEvent{Time: now, Client: "192.0.2.2", Name: "t.example", Type: qtype})...)
+	}
+
+	require.Len(t, raised, 1)
+	assert.Equal(t, server.AnomalyTXTRate, raised[0].Kind)
+}
+
+func TestAnomalyDetector_NormalClient(t *testing.T) {
+	d := newAnomalyDetector()
+	now := time.Now()
+
+	for i := range 100 {
+		rcode := dns.RCodeNoError
+		if i%5 == 0 {
+			rcode = dns.RCodeNXDomain
+		}
+		raised := d.Observe(server.QueryEvent{Time: now, Client: "192.0.2.3", Name: "www.example.com", Type: dns.TypeA, RCode: rcode})
+		require.Empty(t, raised)
+	}
+	assert.Empty(t, d.Recent())
+}
+
+func TestAnomalyDetector_LabelChecks(t *testing.T) {
+	d := newAnomalyDetector()
+	now := time.Now()
+
+	long := strings.Repeat("a", 55) + ".tunnel.example"
+	raised := d.Observe(server.QueryEvent{Time: now, Client: "192.0.2.4", Name: long})
+	require.Len(t, raised, 1)
+	assert.Equal(t, server.AnomalyLongLabel, raised[0].Kind)
+	assert.Equal(t, "label length 55", raised[0].Detail)
+
+	random := "mzxw6ytboi2dqnrvgq4tmnzsha3dembq.tunnel.example"
+	raised = d.Observe(server.QueryEvent{Time: now, Client: "192.0.2.5", Name: random})
+	require.Len(t, raised, 1)
+	assert.Equal(t, server.AnomalyHighEntropy, raised[0].Kind)
+
+	readable := "my-company-intranet-portal-service.example.com"
+	assert.Empty(t, d.Observe(server.QueryEvent{Time: now, Client: "192.0.2.6", Name: readable}))
+
+	recent := d.Recent()
+	require.Len(t, recent, 2)
+	assert.Equal(t, "192.0.2.5", recent[0].Client, "newest first")
+}
+
+func TestQueryHandler_ObservesAnomalies(t *testing.T) {
+	d := newAnomalyDetector()
+	handler := &server.QueryHandler{
+		Resolver: &mockResolver{},
+		Timeout:  time.Second,
+		Anomaly:  d,
+	}
+
+	req := dns.Packet{
+		Header:    dns.Header{ID: 1, Flags: dns.RDFlag},
+		Questions: []dns.Question{{Name: strings.Repeat("x", 60) + ".example", Type: uint16(dns.TypeTXT), Class: uint16(dns.ClassIN)}},
+	}
+	b, err := req.Marshal()
+	require.NoError(t, err)
+	handler.Handle(withClient(context.Background(), "udp", "192.0.2.9"), b)
+
+	recent := d.Recent()
+	require.Len(t, recent, 1)
+	assert.Equal(t, server.AnomalyLongLabel, recent[0].Kind)
+	assert.Equal(t, "192.0.2.9", recent[0].Client)
+}
+
+// ============================================================================
+// Privacy Tests
+// ============================================================================
+
+func TestPrivacy_Redacts(t *testing.T) {
+	tests := []struct {
+		level      server.PrivacyLevel
+		client, v6 string
+		name       string
+	}{
+		{server.PrivacyFull, "192.0.2.77", "2001:db8:1:2::7", "www.example.com"},
+		{server.PrivacyAnonymized, "192.0.2.0", "2001:db8:1::", "www.example.com"},
+		{server.PrivacyDomains, "", "", "www.example.com"},
+		{server.PrivacyNone, "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.level.String(), func(t *testing.T) {
+			p := server.NewPrivacy(tt.level, false)
+			assert.Equal(t, tt.client, p.Client("192.0.2.77"))
+			assert.Equal(t, tt.v6, p.Client("2001:db8:1:2::7"))
+			assert.Equal(t, tt.name, p.Name("www.example.com"))
+		})
+	}
+
+	// Hashes are stable per Privacy and hide the address
+	p := server.NewPrivacy(server.PrivacyAnonymized, true)
+	hashed := p.Client("192.0.2.77")
+	assert.Equal(t, hashed, p.Client("192.0.2.77"))
+	assert.NotEqual(t, hashed, p.Client("192.0.2.78"))
+	assert.NotContains(t, hashed, "192.0.2")
+
+	var nilPrivacy *server.Privacy
+	assert.Equal(t, "192.0.2.77", nilPrivacy.Client("192.0.2.77"))
+}
+
+func TestPrivacy_CaptureRedaction(t *testing.T) {
+	tests := []struct {
+		level server.PrivacyLevel
+		hash  bool
+		want  capture.Redaction
+	}{
+		{server.PrivacyFull, false, capture.RedactNone},
+		{server.PrivacyAnonymized, false, capture.RedactTruncate},
+		{server.PrivacyAnonymized, true, capture.RedactClients},
+		{server.PrivacyDomains, false, capture.RedactClients},
+		{server.PrivacyNone, false, capture.RedactRefuse},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, server.NewPrivacy(tt.level, tt.hash).CaptureRedaction(), tt.level.String())
+	}
+
+	// The runner's recorder follows the privacy level
+	r := server.NewRunner(nil)
+	require.NoError(t, r.PacketCapture().Start(capture.Filter{}, 10))
+	r.SetPrivacy(server.PrivacyNone, false)
+	assert.False(t, r.PacketCapture().Capturing())
+	assert.ErrorIs(t, r.PacketCapture().Start(capture.Filter{}, 10), capture.ErrRefused)
+}
+
+func TestAnomalyDetector_RedactsWhenPrivacyRaised(t *testing.T) {
+	p := server.NewPrivacy(server.PrivacyFull, false)
+	d := newAnomalyDetector()
+	d.SetPrivacy(p)
+
+	long := strings.Repeat("a", 55) + ".tunnel.example"
+	d.Observe(server.QueryEvent{Time: time.Now(), Client: "192.0.2.4", Name: long})
+	require.Equal(t, "192.0.2.4", d.Recent()[0].Client)
+
+	p.Set(server.PrivacyAnonymized, false)
+	d.Redact()
+	assert.Equal(t, "192.0.2.0", d.Recent()[0].Client)
+	assert.Equal(t, long, d.Recent()[0].Name)
+
+	// New alerts are recorded redacted
+	p.Set(server.PrivacyNone, false)
+	raised := d.Observe(server.QueryEvent{Time: time.Now(), Client: "192.0.2.5", Name: long})
+	require.Len(t, raised, 1)
+	assert.Empty(t, raised[0].Client)
+	assert.Empty(t, raised[0].Name)
+}
+
+func TestQueryHandler_PrivacyRedactsEvents(t *testing.T) {
+	events := &server.QueryEvents{}
+	ch, cancel := events.Subscribe(1)
+	defer cancel()
+	handler := &server.QueryHandler{
+		Resolver: &mockResolver{},
+		Timeout:  time.Second,
+		Events:   events,
+		Privacy:  server.NewPrivacy(server.PrivacyDomains, false),
+	}
+
+	handler.Handle(withClient(context.Background(), "udp", "192.0.2.9"), createValidDNSRequest(t))
+
+	ev := <-ch
+	assert.Empty(t, ev.Client)
+	assert.NotEmpty(t, ev.Name)
+}
+
+func TestQueryHandler_LogsSlowQueries(t *testing.T) {
+	var buf bytes.Buffer
+	// Slow queries are logged even when the level would drop warnings
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelError}))
+	upstreamTime := 5 * time.Millisecond
+	handler := &server.QueryHandler{
+		Logger: logger,
+		Resolver: &mockResolver{
+			resolveFunc: func(_ context.Context, req dns.Packet, _ []byte) (resolvers.Result, error) {
+				resp, err := dns.BuildErrorResponse(req, uint16(dns.RCodeNoError)).Marshal()
+				return resolvers.Result{
+					ResponseBytes: resp,
+					Source:        "upstream",
+					Upstream:      "192.0.2.53:53",
+					UpstreamTime:  upstreamTime,
+				}, err
+			},
+		},
+		Timeout: time.Second,
+		Privacy: server.NewPrivacy(server.PrivacyAnonymized, false),
+		Slow:    server.NewSlowQueryLog(logger, 100*time.Millisecond, 10),
+	}
+
+	handler.Handle(withClient(context.Background(), "udp", "192.0.2.9"), createValidDNSRequest(t))
+	assert.Empty(t, buf.String(), "fast queries are not logged")
+
+	upstreamTime = 250 * time.Millisecond
+	handler.Handle(withClient(context.Background(), "udp", "192.0.2.9"), createValidDNSRequest(t))
+
+	var line map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, "slow query", line["msg"])
+	assert.Equal(t, "WARN", line["level"])
+	assert.Equal(t, "example.com", line["qname"])
+	assert.Equal(t, "A", line["qtype"])
+	assert.Equal(t, "NOERROR", line["rcode"])
+	assert.Equal(t, "192.0.2.0", line["src"], "the client is redacted")
+	assert.Equal(t, "192.0.2.53:53", line["upstream"])
+	assert.InDelta(t, 250.0, line["upstream_ms"], 0.001)
+}
+
+func TestSlowQueryLog_CapsLinesPerMinute(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	slow := server.NewSlowQueryLog(logger, time.Millisecond, 2)
+
+	assert.False(t, slow.Slow(time.Millisecond, 0), "the threshold itself is not slow")
+	assert.True(t, slow.Slow(2*time.Millisecond, 0))
+	assert.True(t, slow.Slow(0, 2*time.Millisecond), "upstream time alone counts")
+
+	for range 5 {
+		slow.Log(context.Background(), server.SlowQuery{Name: "example.com", Duration: time.Second})
+	}
+	assert.Equal(t, 2, strings.Count(buf.String(), "slow query"))
+
+	var disabled *server.SlowQueryLog
+	assert.Nil(t, server.NewSlowQueryLog(logger, 0, 2))
+	assert.False(t, disabled.Slow(time.Hour, time.Hour))
+	disabled.Log(context.Background(), server.SlowQuery{})
+}
+
 // ============================================================================
 // HandleResult Tests
 // ============================================================================
@@ -430,12 +1279,445 @@ func TestTruncation_LargeResponse(t *testing.T) {
 }
 
 // ============================================================================
-// Integration-style Tests
+// UDPServer Listener Stats Tests
 // ============================================================================
 
-func TestRateLimiter_ConcurrentAccess(_ *testing.T) {
-	limiter := server.NewRateLimiter(server.RateLimitSettings{
-		GlobalQPS:   10000,
+func TestUDPServer_ListenerStats(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+
+	resolver := &mockResolver{
+		resolveFunc: func(_ context.Context, req dns.Packet, _ []byte) (resolvers.Result, error) {
+			resp := req
+			resp.Header.Flags |= dns.QRFlag
+			b, err := resp.Marshal()
+			return resolvers.Result{ResponseBytes: b, Source: "test"}, err
+		},
+	}
+	udp := &server.UDPServer{
+		Handler:          &server.QueryHandler{Resolver: resolver, Timeout: time.Second},
+		Limiter:          server.NewRateLimiter(server.RateLimitSettings{IPQPS: 0.001, IPBurst: 1}),
+		WorkersPerSocket: 2,
+	}
+	assert.Nil(t, udp.ListenerStats(), "no listeners before the server runs")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- udp.RunOnConn(ctx, conn) }()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+		_ = udp.Stop(time.Second)
+	})
+
+	client, err := net.DialUDP("udp", nil, conn.LocalAddr().(*net.UDPAddr))
+	require.NoError(t, err)
+	defer client.Close()
+
+	req := createValidDNSRequest(t)
+	for range 3 {
+		_, err = client.Write(req)
+		require.NoError(t, err)
+	}
+	require.NoError(t, client.SetReadDeadline(time.Now().Add(2*time.Second)))
+	_, err = client.Read(make([]byte, 512))
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		stats := udp.ListenerStats()
+		return len(stats) == 1 && stats[0].PacketsRead == 3 && stats[0].PacketsHandled == 1
+	}, 2*time.Second, 10*time.Millisecond)
+
+	stats := udp.ListenerStats()[0]
+	assert.Equal(t, uint64(2), stats.RateLimited, "burst of 1 admits only the first packet")
+	assert.Zero(t, stats.PacketsDropped)
+	assert.Equal(t, 0, stats.QueueDepth)
+	assert.Equal(t, 2, stats.QueueCapacity)
+}
+
+func TestUDPServer_ReportsSocketBuffers(t *testing.T) {
+	probe, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	addr := probe.LocalAddr().String()
+	require.NoError(t, probe.Close())
+
+	udp := &server.UDPServer{
+		Handler:          &server.QueryHandler{Resolver: &mockResolver{}, Timeout: time.Second},
+		WorkersPerSocket: 1,
+		Listeners:        2,
+		RecvBuffer:       64 << 10,
+		SendBuffer:       32 << 10,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- udp.Run(ctx, addr) }()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+		_ = udp.Stop(time.Second)
+	})
+
+	require.Eventually(t, func() bool { return len(udp.ListenerStats()) == 2 }, 2*time.Second, 10*time.Millisecond)
+	for _, stats := range udp.ListenerStats() {
+		assert.Equal(t, 64<<10, stats.RecvBuffer, "small sizes are granted as requested")
+		assert.Equal(t, 32<<10, stats.SendBuffer)
+		assert.Zero(t, stats.KernelDrops)
+		assert.Zero(t, stats.RecvQueueBytes)
+	}
+}
+
+func TestUDPServer_CapturesClientMessages(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+
+	resolver := &mockResolver{
+		resolveFunc: func(_ context.Context, req dns.Packet, _ []byte) (resolvers.Result, error) {
+			resp := req
+			resp.Header.Flags |= dns.QRFlag
+			b, err := resp.Marshal()
+			return resolvers.Result{ResponseBytes: b, Source: "test"}, err
+		},
+	}
+	rec := capture.NewRecorder()
+	require.NoError(t, rec.Start(capture.Filter{Client: netip.MustParsePrefix("127.0.0.0/8")}, 2))
+	udp := &server.UDPServer{
+		Handler:          &server.QueryHandler{Resolver: resolver, Timeout: time.Second},
+		WorkersPerSocket: 1,
+		Capture:          rec,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- udp.RunOnConn(ctx, conn) }()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+		_ = udp.Stop(time.Second)
+	})
+
+	client, err := net.DialUDP("udp", nil, conn.LocalAddr().(*net.UDPAddr))
+	require.NoError(t, err)
+	defer client.Close()
+
+	req := createValidDNSRequest(t)
+	_, err = client.Write(req)
+	require.NoError(t, err)
+	require.NoError(t, client.SetReadDeadline(time.Now().Add(2*time.Second)))
+	buf := make([]byte, 512)
+	n, err := client.Read(buf)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool { return !rec.Capturing() }, 2*time.Second, 10*time.Millisecond)
+	packets := rec.Packets()
+	require.Len(t, packets, 2)
+	clientAddr := client.LocalAddr().(*net.UDPAddr).AddrPort()
+	serverAddr := conn.LocalAddr().(*net.UDPAddr).AddrPort()
+	assert.Equal(t, capture.Packet{
+		Time: packets[0].Time, Side: capture.SideClient, Transport: "udp",
+		Src: clientAddr, Dst: serverAddr, Data: req,
+	}, packets[0])
+	assert.Equal(t, capture.Packet{
+		Time: packets[1].Time, Side: capture.SideClient, Transport: "udp",
+		Src: serverAddr, Dst: clientAddr, Data: buf[:n],
+	}, packets[1])
+}
+
+func TestRateLimiter_AdmitAddrSlip(t *testing.T) {
+	limiter := server.NewRateLimiter(server.RateLimitSettings{IPQPS: 0.001, IPBurst: 1, Slip: 2})
+	ip := netip.MustParseAddr("192.0.2.1")
+
+	var got []server.Admission
+	for range 5 {
+		got = append(got, limiter.AdmitAddr(ip))
+	}
+	assert.Equal(t, []server.Admission{
+		server.AdmitAllow,
+		server.AdmitDrop, server.AdmitSlip,
+		server.AdmitDrop, server.AdmitSlip,
+	}, got)
+}
+
+func TestRateLimiter_GlobalLimitNeverSlips(t *testing.T) {
+	limiter := server.NewRateLimiter(server.RateLimitSettings{GlobalQPS: 0.001, GlobalBurst: 1, Slip: 1})
+	ip := netip.MustParseAddr("192.0.2.1")
+
+	assert.Equal(t, server.AdmitAllow, limiter.AdmitAddr(ip))
+	assert.Equal(t, server.AdmitDrop, limiter.AdmitAddr(ip))
+}
+
+func TestUDPServer_SlipsRateLimitedQueries(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+
+	udp := &server.UDPServer{
+		Handler:          &server.QueryHandler{Resolver: &mockResolver{}, Timeout: time.Second},
+		Limiter:          server.NewRateLimiter(server.RateLimitSettings{IPQPS: 0.001, IPBurst: 1, Slip: 1}),
+		WorkersPerSocket: 2,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- udp.RunOnConn(ctx, conn) }()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+		_ = udp.Stop(time.Second)
+	})
+
+	client, err := net.DialUDP("udp", nil, conn.LocalAddr().(*net.UDPAddr))
+	require.NoError(t, err)
+	defer client.Close()
+
+	req := createValidDNSRequest(t)
+	for range 3 {
+		_, err = client.Write(req)
+		require.NoError(t, err)
+	}
+
+	// One SERVFAIL from the resolver and two slipped truncated responses,
+	// in no particular order
+	truncated := 0
+	require.NoError(t, client.SetReadDeadline(time.Now().Add(2*time.Second)))
+	for range 3 {
+		buf := make([]byte, 512)
+		n, err := client.Read(buf)
+		require.NoError(t, err)
+		resp, err := dns.ParsePacket(buf[:n])
+		require.NoError(t, err)
+		if resp.Header.Flags&dns.TCFlag != 0 {
+			truncated++
+			assert.Equal(t, req[:2], buf[:2], "transaction ID is echoed")
+			assert.Equal(t, req[dns.HeaderSize:], buf[dns.HeaderSize:n], "question is echoed")
+			assert.Empty(t, resp.Answers)
+		}
+	}
+	assert.Equal(t, 2, truncated)
+
+	stats := udp.ListenerStats()[0]
+	assert.Equal(t, uint64(2), stats.RateLimited)
+	assert.Equal(t, uint64(2), stats.Slipped)
+}
+
+func TestUDPServer_AttachesRetransmittedQueries(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	resolver := &mockResolver{
+		resolveFunc: func(_ context.Context, req dns.Packet, _ []byte) (resolvers.Result, error) {
+			if calls.Add(1) == 1 {
+				<-release
+			}
+			resp := req
+			resp.Header.Flags |= dns.QRFlag
+			b, err := resp.Marshal()
+			return resolvers.Result{ResponseBytes: b, Source: "test"}, err
+		},
+	}
+	udp := &server.UDPServer{
+		Handler:          &server.QueryHandler{Resolver: resolver, Timeout: 5 * time.Second},
+		WorkersPerSocket: 4,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- udp.RunOnConn(ctx, conn) }()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+		_ = udp.Stop(time.Second)
+	})
+
+	client, err := net.DialUDP("udp", nil, conn.LocalAddr().(*net.UDPAddr))
+	require.NoError(t, err)
+	defer client.Close()
+
+	req := createValidDNSRequest(t)
+	for range 3 {
+		_, err = client.Write(req)
+		require.NoError(t, err)
+	}
+	require.Eventually(t, func() bool {
+		stats := udp.ListenerStats()
+		return len(stats) == 1 && stats[0].Duplicates == 2
+	}, 2*time.Second, 10*time.Millisecond)
+
+	// A query with another transaction ID is resolved on its own
+	other := bytes.Clone(req)
+	other[1]++
+	_, err = client.Write(other)
+	require.NoError(t, err)
+	require.NoError(t, client.SetReadDeadline(time.Now().Add(2*time.Second)))
+	buf := make([]byte, 512)
+	_, err = client.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, other[:2], buf[:2])
+
+	close(release)
+	for range 3 {
+		_, err = client.Read(buf)
+		require.NoError(t, err)
+		assert.Equal(t, req[:2], buf[:2], "every retransmission gets the answer")
+	}
+	assert.Equal(t, int32(2), calls.Load(), "retransmissions are not resolved again")
+
+	// Once answered, the same query is resolved again
+	_, err = client.Write(req)
+	require.NoError(t, err)
+	_, err = client.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), calls.Load())
+	assert.Equal(t, uint64(2), udp.ListenerStats()[0].Duplicates)
+}
+
+// ============================================================================
+// TCPServer Limit Tests
+// ============================================================================
+
+// tcpExchange sends req over conn with the TCP length prefix and reads the
+// response.
+func tcpExchange(conn net.Conn, req []byte) ([]byte, error) {
+	msg := binary.BigEndian.AppendUint16(nil, uint16(len(req)))
+	if _, err := conn.Write(append(msg, req...)); err != nil {
+		return nil, err
+	}
+	lenBuf := make([]byte, 2)
+	if _, err := io.ReadFull(conn, lenBuf); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, binary.BigEndian.Uint16(lenBuf))
+	_, err := io.ReadFull(conn, resp)
+	return resp, err
+}
+
+func TestTCPServer_ConnectionLimits(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	resolver := &mockResolver{
+		resolveFunc: func(_ context.Context, req dns.Packet, _ []byte) (resolvers.Result, error) {
+			resp := req
+			resp.Header.Flags |= dns.QRFlag
+			b, err := resp.Marshal()
+			return resolvers.Result{ResponseBytes: b, Source: "test"}, err
+		},
+	}
+	tcp := &server.TCPServer{
+		Handler:           &server.QueryHandler{Resolver: resolver, Timeout: time.Second},
+		ReadTimeout:       time.Second,
+		IdleTimeout:       2 * time.Second,
+		MaxConnsPerIP:     1,
+		MaxQueriesPerConn: 2,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- tcp.RunOnListener(ctx, ln) }()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	req := createValidDNSRequest(t)
+	first, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer first.Close()
+	require.NoError(t, first.SetDeadline(time.Now().Add(2*time.Second)))
+
+	_, err = tcpExchange(first, req)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"127.0.0.1": 1}, tcp.ConnectionsPerIP())
+
+	// A second connection from the same IP is closed straight away
+	second, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer second.Close()
+	require.NoError(t, second.SetDeadline(time.Now().Add(2*time.Second)))
+	_, err = tcpExchange(second, req)
+	require.Error(t, err)
+
+	// The first connection is closed after MaxQueriesPerConn queries
+	_, err = tcpExchange(first, req)
+	require.NoError(t, err)
+	_, err = tcpExchange(first, req)
+	require.Error(t, err)
+
+	require.Eventually(t, func() bool {
+		return len(tcp.ConnectionsPerIP()) == 0
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+// proxyV2Header builds a PROXY protocol v2 PROXY header for a TCP over IPv4
+// connection from src.
+func proxyV2Header(src netip.Addr) []byte {
+	h := []byte{0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A, 0x21, 0x11, 0, 12}
+	h = append(h, src.AsSlice()...)
+	h = append(h, 127, 0, 0, 1) // destination
+	return append(h, 0xC3, 0x50, 0, 53)
+}
+
+func startProxiedTCPServer(t *testing.T) (*server.TCPServer, string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	resolver := &mockResolver{
+		resolveFunc: func(_ context.Context, req dns.Packet, _ []byte) (resolvers.Result, error) {
+			resp := req
+			resp.Header.Flags |= dns.QRFlag
+			b, err := resp.Marshal()
+			return resolvers.Result{ResponseBytes: b, Source: "test"}, err
+		},
+	}
+	tcp := &server.TCPServer{
+		Handler:           &server.QueryHandler{Resolver: resolver, Timeout: time.Second},
+		ReadTimeout:       time.Second,
+		ProxyProtocolFrom: []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- tcp.RunOnListener(ctx, ln) }()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return tcp, ln.Addr().String()
+}
+
+func TestTCPServer_ProxyProtocolClientAddress(t *testing.T) {
+	tcp, addr := startProxiedTCPServer(t)
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.SetDeadline(time.Now().Add(2*time.Second)))
+
+	_, err = conn.Write(proxyV2Header(netip.MustParseAddr("203.0.113.7")))
+	require.NoError(t, err)
+	_, err = tcpExchange(conn, createValidDNSRequest(t))
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]int{"203.0.113.7": 1}, tcp.ConnectionsPerIP())
+}
+
+func TestTCPServer_ProxyProtocolRequiredFromTrustedProxy(t *testing.T) {
+	_, addr := startProxiedTCPServer(t)
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.SetDeadline(time.Now().Add(2*time.Second)))
+
+	// A plain DNS query where the header should be is rejected
+	req := createValidDNSRequest(t)
+	_, err = tcpExchange(conn, append(req, make([]byte, 16)...))
+	require.Error(t, err)
+}
+
+// ============================================================================
+// Integration-style Tests
+// ============================================================================
+
+func TestRateLimiter_ConcurrentAccess(_ *testing.T) {
+	limiter := server.NewRateLimiter(server.RateLimitSettings{
+		GlobalQPS:   10000,
 		GlobalBurst: 1000,
 		PrefixQPS:   1000,
 		PrefixBurst: 100,
@@ -476,7 +1758,7 @@ func TestQueryHandler_SequentialRequests(t *testing.T) {
 	}
 
 	for range 5 {
-		result := handler.Handle(context.Background(), "udp", "127.0.0.1:12345", createValidDNSRequest(t))
+		result := handler.Handle(withClient(context.Background(), "udp", "127.0.0.1"), createValidDNSRequest(t))
 		assert.True(t, result.ParsedOK)
 		assert.Equal(t, "test", result.Source)
 	}
@@ -506,3 +1788,1239 @@ func TestTokenBucket_ConcurrentAccess(_ *testing.T) {
 		<-done
 	}
 }
+
+// ============================================================================
+// Negative Cache Rules Tests
+// ============================================================================
+
+func TestBuildNegativeCacheRules_GlobalSettings(t *testing.T) {
+	cfg := &config.Config{Cache: config.CacheConfig{ServfailTTL: "0s", NegativeTTL: "90s"}}
+
+	rules := server.BuildNegativeCacheRules(cfg)
+
+	assert.True(t, rules.Default.Enabled)
+	assert.Equal(t, time.Duration(0), rules.Default.ServfailTTL)
+	assert.Equal(t, 90*time.Second, rules.Default.NegativeTTL)
+	assert.Empty(t, rules.Zones)
+}
+
+func TestBuildNegativeCacheRules_ZoneOverridesInherit(t *testing.T) {
+	enable := false
+	cfg := &config.Config{Cache: config.CacheConfig{
+		DisableNegative: true,
+		ServfailTTL:     "30s",
+		NegativeTTL:     "5m",
+		ZoneOverrides: []config.CacheZoneOverride{
+			{Zone: "corp.example", DisableNegative: &enable, ServfailTTL: "5s"},
+		},
+	}}
+
+	rules := server.BuildNegativeCacheRules(cfg)
+
+	assert.False(t, rules.Default.Enabled)
+	zone := rules.PolicyFor("host.corp.example")
+	assert.True(t, zone.Enabled, "zone override re-enables negative caching")
+	assert.Equal(t, 5*time.Second, zone.ServfailTTL)
+	assert.Equal(t, 5*time.Minute, zone.NegativeTTL, "unset fields inherit the global value")
+}
+
+// ============================================================================
+// EDNS Policy Tests
+// ============================================================================
+
+func TestBuildEDNSPolicy(t *testing.T) {
+	cfg := &config.Config{Upstream: config.UpstreamConfig{EDNSOptions: []config.EDNSOptionRule{
+		{Option: "cookie", Action: config.EDNSActionStrip},
+		{Option: "ecs", Action: config.EDNSActionRewrite, Value: "192.0.2.0/24"},
+		{Option: "padding", Action: config.EDNSActionPass},
+	}}}
+
+	policy := server.BuildEDNSPolicy(cfg)
+
+	assert.Equal(t, resolvers.EDNSPolicy{
+		dns.EDNSOptionCookie:  {Action: resolvers.EDNSStrip},
+		dns.EDNSOptionECS:     {Action: resolvers.EDNSRewrite, Data: []byte{0, 1, 24, 0, 192, 0, 2}},
+		dns.EDNSOptionPadding: {Action: resolvers.EDNSPass},
+	}, policy)
+	assert.Nil(t, server.BuildEDNSPolicy(&config.Config{}))
+}
+
+// ============================================================================
+// Threat Intelligence Tests
+// ============================================================================
+
+func TestBuildThreatIntelChecker_BlocksMaliciousDomains(t *testing.T) {
+	feed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := "no_results"
+		if r.PostFormValue("host") == "malware.example" {
+			status = "ok"
+		}
+		_, _ = fmt.Fprintf(w, `{"query_status":%q}`, status)
+	}))
+	defer feed.Close()
+
+	cfg := &config.Config{ThreatIntel: config.ThreatIntelConfig{
+		Enabled:   true,
+		Provider:  "urlhaus",
+		URL:       feed.URL,
+		BlockTTL:  "1h",
+		CacheTTL:  "1h",
+		RateLimit: 100,
+	}}
+	policy := filtering.NewPolicyEngine(filtering.PolicyEngineConfig{
+		Enabled:     true,
+		BlockAction: filtering.ActionBlock,
+	})
+	defer policy.Close()
+
+	checker := server.BuildThreatIntelChecker(cfg, policy, nil)
+	require.NotNil(t, checker)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go checker.Run(ctx)
+
+	checker.Observe("malware.example.")
+	checker.Observe("www.example.com.")
+
+	assert.Eventually(t, func() bool {
+		return policy.Evaluate("malware.example").Action == filtering.ActionBlock
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "threat-intel", policy.Evaluate("malware.example").ListName)
+	assert.Eventually(t, func() bool { return checker.Stats().Checked == 2 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, filtering.ActionAllow, policy.Evaluate("www.example.com").Action)
+
+	assert.Nil(t, server.BuildThreatIntelChecker(&config.Config{}, policy, nil))
+}
+
+// ============================================================================
+// Recursion Flag Tests
+// ============================================================================
+
+// TestQueryHandler_RecursionFlags checks RA/RD/AA and the rcode for every
+// combination of client ACL, RD, and local or remote name.
+func TestQueryHandler_RecursionFlags(t *testing.T) {
+	custom, err := resolvers.NewCustomDNSResolver(map[string][]string{"nas.lan": {"10.0.0.5"}}, nil)
+	require.NoError(t, err)
+	upstream := &mockResolver{
+		resolveFunc: func(ctx context.Context, req dns.Packet, _ []byte) (resolvers.Result, error) {
+			if resolvers.RecursionRefused(ctx) {
+				return resolvers.Result{}, resolvers.ErrRecursionRefused
+			}
+			resp := dns.Packet{
+				Header:    dns.Header{ID: req.Header.ID, Flags: dns.QRFlag | dns.RDFlag | dns.RAFlag},
+				Questions: req.Questions,
+			}
+			b, err := resp.Marshal()
+			return resolvers.Result{ResponseBytes: b, Source: "upstream"}, err
+		},
+	}
+	h := &server.QueryHandler{
+		Resolver:         &resolvers.Chained{Resolvers: []resolvers.Resolver{custom, upstream}},
+		Timeout:          time.Second,
+		RecursionClients: []netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")},
+	}
+
+	tests := []struct {
+		client    string
+		rd        bool
+		name      string
+		wantRCode dns.RCode
+		wantRA    bool
+		wantAA    bool
+	}{
+		{"192.0.2.1", true, "nas.lan", dns.RCodeNoError, true, true},
+		{"192.0.2.1", true, "example.com", dns.RCodeNoError, true, false},
+		{"192.0.2.1", false, "nas.lan", dns.RCodeNoError, true, true},
+		{"192.0.2.1", false, "example.com", dns.RCodeRefused, true, false},
+		{"198.51.100.1", true, "nas.lan", dns.RCodeNoError, false, true},
+		{"198.51.100.1", true, "example.com", dns.RCodeRefused, false, false},
+		{"198.51.100.1", false, "nas.lan", dns.RCodeNoError, false, true},
+		{"198.51.100.1", false, "example.com", dns.RCodeRefused, false, false},
+	}
+	for _, tt := range tests {
+		name := fmt.Sprintf("%s/rd=%v/%s", tt.client, tt.rd, tt.name)
+		t.Run(name, func(t *testing.T) {
+			var flags uint16
+			if tt.rd {
+				flags = dns.RDFlag
+			}
+			req, err := dns.Packet{
+				Header:    dns.Header{ID: 7, Flags: flags},
+				Questions: []dns.Question{{Name: tt.name, Type: uint16(dns.TypeA), Class: uint16(dns.ClassIN)}},
+			}.Marshal()
+			require.NoError(t, err)
+
+			res := h.Handle(withClient(context.Background(), "udp", tt.client), req)
+			resp, err := dns.ParsePacket(res.ResponseBytes)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.wantRCode, dns.RCodeFromFlags(resp.Header.Flags))
+			assert.Equal(t, tt.wantRA, resp.Header.RecursionAvailable(), "RA")
+			assert.Equal(t, tt.rd, resp.Header.RecursionDesired(), "RD is copied from the query")
+			assert.Equal(t, tt.wantAA, resp.Header.Flags&dns.AAFlag != 0, "AA")
+		})
+	}
+}
+
+func TestQueryHandler_RecursionOfferedToAllByDefault(t *testing.T) {
+	h := &server.QueryHandler{
+		Resolver: &mockResolver{
+			resolveFunc: func(_ context.Context, req dns.Packet, _ []byte) (resolvers.Result, error) {
+				// An upstream that does not set RA
+				resp := dns.Packet{Header: dns.Header{ID: req.Header.ID, Flags: dns.QRFlag | dns.RDFlag}, Questions: req.Questions}
+				b, err := resp.Marshal()
+				return resolvers.Result{ResponseBytes: b, Source: "upstream"}, err
+			},
+		},
+		Timeout: time.Second,
+	}
+
+	res := h.Handle(withClient(context.Background(), "udp", "203.0.113.9"), createValidDNSRequest(t))
+	resp, err := dns.ParsePacket(res.ResponseBytes)
+	require.NoError(t, err)
+	assert.True(t, resp.Header.RecursionAvailable())
+}
+
+// ============================================================================
+// Query Type Rule Tests
+// ============================================================================
+
+func qtypeRequest(t *testing.T, qtype dns.RecordType) []byte {
+	t.Helper()
+	req := dns.Packet{
+		Header:    dns.Header{ID: 0x4242, Flags: dns.RDFlag},
+		Questions: []dns.Question{{Name: "example.com", Type: uint16(qtype), Class: uint16(dns.ClassIN)}},
+	}
+	b, err := req.Marshal()
+	require.NoError(t, err)
+	return dns.AddEDNSToRequestBytes(req, b, dns.EDNSDefaultUDPPayloadSize)
+}
+
+func TestQueryHandler_QTypeRules(t *testing.T) {
+	var resolved atomic.Int32
+	policy := server.NewQTypePolicy([]server.QTypeRule{
+		{QTypes: []dns.RecordType{dns.TypeANY}, Action: server.QTypeRefuse},
+		{QTypes: []dns.RecordType{dns.TypePTR}, Clients: []netip.Prefix{netip.MustParsePrefix("192.168.50.0/24")}, Action: server.QTypeNXDomain},
+		{QTypes: []dns.RecordType{dns.TypeTXT}, Action: server.QTypeNoData},
+		{QTypes: []dns.RecordType{dns.RecordType(65535)}, Action: server.QTypeDrop},
+	})
+	h := &server.QueryHandler{
+		Resolver: &mockResolver{
+			resolveFunc: func(_ context.Context, req dns.Packet, _ []byte) (resolvers.Result, error) {
+				resolved.Add(1)
+				resp := dns.Packet{Header: dns.Header{ID: req.Header.ID, Flags: dns.QRFlag | dns.RDFlag}, Questions: req.Questions}
+				b, err := resp.Marshal()
+				return resolvers.Result{ResponseBytes: b, Source: "upstream"}, err
+			},
+		},
+		Timeout: time.Second,
+		QTypes:  policy,
+	}
+
+	tests := []struct {
+		client     string
+		qtype      dns.RecordType
+		wantSource string
+		wantRCode  dns.RCode
+	}{
+		{"203.0.113.9", dns.TypeANY, "qtype-refused", dns.RCodeRefused},
+		{"192.168.50.7", dns.TypePTR, "qtype-nxdomain", dns.RCodeNXDomain},
+		{"::ffff:192.168.50.7", dns.TypePTR, "qtype-nxdomain", dns.RCodeNXDomain},
+		{"203.0.113.9", dns.TypePTR, "upstream", dns.RCodeNoError},
+		{"203.0.113.9", dns.TypeTXT, "qtype-nodata", dns.RCodeNoError},
+		{"203.0.113.9", dns.TypeA, "upstream", dns.RCodeNoError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.client+"/"+tt.qtype.String(), func(t *testing.T) {
+			res := h.Handle(withClient(context.Background(), "udp", tt.client), qtypeRequest(t, tt.qtype))
+			assert.Equal(t, tt.wantSource, res.Source)
+			resp, err := dns.ParsePacket(res.ResponseBytes)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantRCode, dns.RCodeFromFlags(resp.Header.Flags))
+			assert.Equal(t, uint16(0x4242), resp.Header.ID)
+			if tt.wantSource != "upstream" {
+				assert.Empty(t, resp.Answers)
+			}
+			if tt.wantRCode == dns.RCodeRefused {
+				ede, ok := dns.ExtractExtendedError(resp.Additionals)
+				require.True(t, ok)
+				assert.Equal(t, dns.EDEProhibited, ede.InfoCode)
+			}
+		})
+	}
+	assert.Equal(t, int32(2), resolved.Load(), "only unmatched queries are resolved")
+
+	res := h.Handle(withClient(context.Background(), "udp", "203.0.113.9"), qtypeRequest(t, dns.RecordType(65535)))
+	assert.Equal(t, "qtype-drop", res.Source)
+	assert.True(t, res.ParsedOK)
+	assert.Empty(t, res.ResponseBytes, "dropped queries get no response")
+
+	assert.Equal(t, []server.QTypeRuleStats{
+		{Rule: "ANY -> refused", Matched: 1},
+		{Rule: "PTR from 192.168.50.0/24 -> nxdomain", Matched: 2},
+		{Rule: "TXT -> nodata", Matched: 1},
+		{Rule: "TYPE65535 -> drop", Matched: 1},
+	}, policy.Stats())
+}
+
+func TestQTypePolicy_Empty(t *testing.T) {
+	policy := server.NewQTypePolicy(nil)
+	assert.Nil(t, policy)
+	_, ok := policy.Match(uint16(dns.TypeANY), "127.0.0.1")
+	assert.False(t, ok)
+	assert.Nil(t, policy.Stats())
+}
+
+// ============================================================================
+// Response Pipeline Tests
+// ============================================================================
+
+// answerResponse answers req with records.
+func answerResponse(t *testing.T, req dns.Packet, rcode dns.RCode, records ...dns.Record) []byte {
+	t.Helper()
+	resp := dns.Packet{
+		Header:    dns.Header{ID: req.Header.ID, Flags: dns.QRFlag | dns.RDFlag | uint16(rcode)},
+		Questions: req.Questions,
+		Answers:   records,
+	}
+	b, err := resp.Marshal()
+	require.NoError(t, err)
+	return b
+}
+
+func aRecord(name string, ttl uint32, ip string) dns.Record {
+	return dns.NewIPRecord(dns.NewRRHeader(name, dns.ClassIN, ttl), net.ParseIP(ip))
+}
+
+func TestQueryHandler_RunsResponsePipeline(t *testing.T) {
+	resolver := &mockResolver{
+		resolveFunc: func(_ context.Context, req dns.Packet, _ []byte) (resolvers.Result, error) {
+			b := answerResponse(t, req, dns.RCodeNoError,
+				aRecord("example.com", 5, "192.0.2.1"),
+				aRecord("example.com", 86400, "192.0.2.2"),
+			)
+			return resolvers.Result{ResponseBytes: b, Source: "test"}, nil
+		},
+	}
+	h := &server.QueryHandler{
+		Resolver: resolver,
+		Timeout:  time.Second,
+		Response: server.NewResponsePipeline([]server.ResponseStage{
+			{Step: server.TTLClamp{Min: 60, Max: 3600}},
+			{Step: server.Scrub{Types: []dns.RecordType{dns.TypeA}}, Clients: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}},
+		}),
+	}
+
+	res := h.Handle(withClient(context.Background(), "udp", "192.0.2.53"), createValidDNSRequest(t))
+	resp, err := dns.ParsePacket(res.ResponseBytes)
+	require.NoError(t, err)
+	require.Len(t, resp.Answers, 2, "scrub applies to 10.0.0.0/8 only")
+	assert.Equal(t, uint32(60), resp.Answers[0].Header().TTL)
+	assert.Equal(t, uint32(3600), resp.Answers[1].Header().TTL)
+	assert.Equal(t, uint16(0x1234), resp.Header.ID)
+
+	res = h.Handle(withClient(context.Background(), "udp", "10.1.2.3"), createValidDNSRequest(t))
+	resp, err = dns.ParsePacket(res.ResponseBytes)
+	require.NoError(t, err)
+	assert.Empty(t, resp.Answers)
+}
+
+func TestResponsePipeline_NilLeavesResponseUnchanged(t *testing.T) {
+	var p *server.ResponsePipeline
+	assert.Nil(t, server.NewResponsePipeline(nil))
+	resp := []byte{1, 2, 3}
+	assert.Equal(t, resp, p.Process(netip.Addr{}, dns.Packet{}, resp))
+}
+
+func TestAnswerReorder_RoundRobin(t *testing.T) {
+	req, err := dns.ParsePacket(createValidDNSRequest(t))
+	require.NoError(t, err)
+	resp := answerResponse(t, req, dns.RCodeNoError,
+		dns.NewCNAMERecord(dns.NewRRHeader("example.com", dns.ClassIN, 60), "web.example.com"),
+		aRecord("web.example.com", 60, "192.0.2.1"),
+		aRecord("web.example.com", 60, "192.0.2.2"),
+		aRecord("web.example.com", 60, "192.0.2.3"),
+	)
+	p := server.NewResponsePipeline([]server.ResponseStage{
+		{Step: &server.AnswerReorder{Order: resolvers.AnswerOrderRoundRobin}},
+	})
+
+	var firsts []string
+	for range 4 {
+		out, err := dns.ParsePacket(p.Process(netip.Addr{}, req, resp))
+		require.NoError(t, err)
+		require.Len(t, out.Answers, 4)
+		assert.Equal(t, dns.TypeCNAME, out.Answers[0].Type(), "the CNAME stays first")
+		firsts = append(firsts, out.Answers[1].(*dns.IPRecord).Addr.String())
+	}
+	assert.Equal(t, []string{"192.0.2.1", "192.0.2.2", "192.0.2.3", "192.0.2.1"}, firsts)
+}
+
+func TestAAAAFilter_RemovesAAAAAnswers(t *testing.T) {
+	req, err := dns.ParsePacket(qtypeRequest(t, dns.TypeAAAA))
+	require.NoError(t, err)
+	resp := answerResponse(t, req, dns.RCodeNoError,
+		dns.NewIPRecord(dns.NewRRHeader("example.com", dns.ClassIN, 60), net.ParseIP("2001:db8::1")),
+	)
+	p := server.NewResponsePipeline([]server.ResponseStage{{Step: server.AAAAFilter{}}})
+
+	out, err := dns.ParsePacket(p.Process(netip.Addr{}, req, resp))
+	require.NoError(t, err)
+	assert.Empty(t, out.Answers)
+	assert.Equal(t, dns.RCodeNoError, dns.RCodeFromFlags(out.Header.Flags))
+
+	// Other query types pass through
+	aReq, err := dns.ParsePacket(createValidDNSRequest(t))
+	require.NoError(t, err)
+	aResp := answerResponse(t, aReq, dns.RCodeNoError, aRecord("example.com", 60, "192.0.2.1"))
+	assert.Equal(t, aResp, p.Process(netip.Addr{}, aReq, aResp))
+}
+
+func TestExtendedErrorInsert_AddsEDEToMatchingResponses(t *testing.T) {
+	ednsReq, err := dns.ParsePacket(qtypeRequest(t, dns.TypeA))
+	require.NoError(t, err)
+	p := server.NewResponsePipeline([]server.ResponseStage{{Step: server.ExtendedErrorInsert{
+		RCode: dns.RCodeServFail,
+		Error: dns.ExtendedError{InfoCode: dns.EDENoReachableAuthority, ExtraText: "upstream unreachable"},
+	}}})
+
+	out, err := dns.ParsePacket(p.Process(netip.Addr{}, ednsReq, answerResponse(t, ednsReq, dns.RCodeServFail)))
+	require.NoError(t, err)
+	ede, ok := dns.ExtractExtendedError(out.Additionals)
+	require.True(t, ok)
+	assert.Equal(t, dns.EDENoReachableAuthority, ede.InfoCode)
+	assert.Equal(t, "upstream unreachable", ede.ExtraText)
+
+	// Other response codes, and clients without EDNS, are left alone
+	noError := answerResponse(t, ednsReq, dns.RCodeNoError)
+	assert.Equal(t, noError, p.Process(netip.Addr{}, ednsReq, noError))
+	plainReq, err := dns.ParsePacket(createValidDNSRequest(t))
+	require.NoError(t, err)
+	servfail := answerResponse(t, plainReq, dns.RCodeServFail)
+	assert.Equal(t, servfail, p.Process(netip.Addr{}, plainReq, servfail))
+}
+
+// ============================================================================
+// Health Canary Tests
+// ============================================================================
+
+func canaryResponse(t *testing.T, req dns.Packet, rcode dns.RCode) resolvers.Result {
+	t.Helper()
+	resp := dns.Packet{
+		Header:    dns.Header{ID: req.Header.ID, Flags: dns.QRFlag | uint16(rcode)},
+		Questions: req.Questions,
+	}
+	b, err := resp.Marshal()
+	require.NoError(t, err)
+	return resolvers.Result{ResponseBytes: b}
+}
+
+func TestCanary_HealthyWhileAnyDomainResolves(t *testing.T) {
+	resolver := &mockResolver{
+		resolveFunc: func(_ context.Context, req dns.Packet, _ []byte) (resolvers.Result, error) {
+			if req.Questions[0].Name == "broken.test" {
+				return canaryResponse(t, req, dns.RCodeServFail), nil
+			}
+			return canaryResponse(t, req, dns.RCodeNoError), nil
+		},
+	}
+	c := server.NewCanary(resolver, server.CanarySettings{
+		Domains:          []string{"example.com", "broken.test"},
+		FailureThreshold: 1,
+	}, nil)
+
+	c.Check(context.Background())
+
+	st := c.Status()
+	assert.True(t, st.Healthy)
+	assert.Zero(t, st.FailedRounds)
+	assert.False(t, st.LastRound.IsZero())
+	require.Len(t, st.Checks, 2)
+
+	assert.True(t, st.Checks[0].OK)
+	assert.Equal(t, "NOERROR", st.Checks[0].RCode)
+	assert.False(t, st.Checks[0].LastSuccess.IsZero())
+
+	assert.False(t, st.Checks[1].OK)
+	assert.Equal(t, "SERVFAIL", st.Checks[1].RCode)
+	assert.NotEmpty(t, st.Checks[1].Error)
+	assert.Equal(t, 1, st.Checks[1].ConsecutiveFailures)
+	assert.True(t, st.Checks[1].LastSuccess.IsZero())
+}
+
+func TestCanary_FailureThresholdAndRecovery(t *testing.T) {
+	var failing atomic.Bool
+	failing.Store(true)
+	resolver := &mockResolver{
+		resolveFunc: func(_ context.Context, req dns.Packet, _ []byte) (resolvers.Result, error) {
+			if failing.Load() {
+				return resolvers.Result{}, errors.New("i/o timeout")
+			}
+			return canaryResponse(t, req, dns.RCodeNoError), nil
+		},
+	}
+	c := server.NewCanary(resolver, server.CanarySettings{
+		Domains:          []string{"example.com"},
+		FailureThreshold: 2,
+	}, nil)
+
+	c.Check(context.Background())
+	st := c.Status()
+	assert.True(t, st.Healthy, "one failed round is below the threshold")
+	assert.Equal(t, 1, st.FailedRounds)
+	assert.Equal(t, "i/o timeout", st.Checks[0].Error)
+
+	c.Check(context.Background())
+	st = c.Status()
+	assert.False(t, st.Healthy)
+	assert.Equal(t, 2, st.FailedRounds)
+	assert.Equal(t, 2, st.Checks[0].ConsecutiveFailures)
+
+	failing.Store(false)
+	c.Check(context.Background())
+	st = c.Status()
+	assert.True(t, st.Healthy)
+	assert.Zero(t, st.FailedRounds)
+	assert.Zero(t, st.Checks[0].ConsecutiveFailures)
+	assert.Empty(t, st.Checks[0].Error)
+}
+
+func TestCanary_QueriesSkipCacheWithDeadline(t *testing.T) {
+	resolver := &mockResolver{
+		resolveFunc: func(ctx context.Context, req dns.Packet, _ []byte) (resolvers.Result, error) {
+			_, hasDeadline := ctx.Deadline()
+			assert.True(t, hasDeadline)
+			assert.True(t, req.Header.Flags&dns.RDFlag != 0)
+			return canaryResponse(t, req, dns.RCodeNoError), nil
+		},
+	}
+	c := server.NewCanary(resolver, server.CanarySettings{Domains: []string{"example.com"}}, nil)
+
+	c.Check(context.Background())
+	assert.True(t, c.Status().Checks[0].OK)
+}
+
+func TestCanary_CanceledRoundLeavesStatus(t *testing.T) {
+	resolver := &mockResolver{
+		resolveFunc: func(ctx context.Context, _ dns.Packet, _ []byte) (resolvers.Result, error) {
+			return resolvers.Result{}, ctx.Err()
+		},
+	}
+	c := server.NewCanary(resolver, server.CanarySettings{
+		Domains:          []string{"example.com"},
+		FailureThreshold: 1,
+	}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.Check(ctx)
+
+	st := c.Status()
+	assert.True(t, st.Healthy)
+	assert.True(t, st.LastRound.IsZero())
+}
+
+// ============================================================================
+// Shadow Upstream Tests
+// ============================================================================
+
+// shadowResolver answers A queries with the addresses in answers, or fails
+// when answers is nil.
+func shadowResolver(t *testing.T, ttl uint32, answers ...string) *mockResolver {
+	return &mockResolver{
+		resolveFunc: func(_ context.Context, req dns.Packet, _ []byte) (resolvers.Result, error) {
+			if answers == nil {
+				return resolvers.Result{}, resolvers.ErrNoUpstream
+			}
+			var records []dns.Record
+			for _, ip := range answers {
+				records = append(records, aRecord(req.Questions[0].Name, ttl, ip))
+			}
+			return resolvers.Result{
+				ResponseBytes: answerResponse(t, req, dns.RCodeNoError, records...),
+				Source:        "upstream",
+				Upstream:      "192.0.2.53",
+				UpstreamTime:  30 * time.Millisecond,
+			}, nil
+		},
+	}
+}
+
+func TestShadowMirror_ComparesAnswers(t *testing.T) {
+	primary := shadowResolver(t, 300, "192.0.2.1", "192.0.2.2")
+	tests := []struct {
+		name   string
+		shadow *mockResolver
+		want   func(server.ShadowStatus) uint64
+	}{
+		{"same records, other TTL and order", shadowResolver(t, 60, "192.0.2.2", "192.0.2.1"),
+			func(s server.ShadowStatus) uint64 { return s.Matched }},
+		{"other records", shadowResolver(t, 300, "198.51.100.1"),
+			func(s server.ShadowStatus) uint64 { return s.Diverged }},
+		{"no answer", shadowResolver(t, 300),
+			func(s server.ShadowStatus) uint64 { return s.Failed }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := server.NewShadowMirror(tt.shadow, server.ShadowSettings{
+				Servers: []string{"192.0.2.53"},
+				Percent: 100,
+				Timeout: time.Second,
+			}, nil, nil)
+			req, err := dns.ParsePacket(createValidDNSRequest(t))
+			require.NoError(t, err)
+			result, err := primary.Resolve(context.Background(), req, nil)
+			require.NoError(t, err)
+			result.UpstreamTime = 10 * time.Millisecond
+
+			m.Mirror(context.Background(), req, result)
+			require.NoError(t, m.Close())
+
+			st := m.Status()
+			assert.Equal(t, uint64(1), st.Mirrored)
+			assert.Equal(t, uint64(1), tt.want(st))
+		})
+	}
+}
+
+func TestShadowMirror_RecordsDivergences(t *testing.T) {
+	privacy := server.NewPrivacy(server.PrivacyFull, false)
+	m := server.NewShadowMirror(shadowResolver(t, 300, "198.51.100.1"), server.ShadowSettings{
+		Servers: []string{"192.0.2.53"},
+		Percent: 100,
+		Timeout: time.Second,
+	}, nil, privacy)
+	handler := &server.QueryHandler{
+		Resolver: shadowResolver(t, 300, "192.0.2.1"),
+		Timeout:  time.Second,
+		Shadow:   m,
+	}
+	ctx := resolvers.WithClientInfo(context.Background(), resolvers.ClientInfo{
+		Addr:      netip.MustParseAddr("192.0.2.7"),
+		Transport: "udp",
+		TraceID:   "00000000feedf00d",
+	})
+
+	result := handler.Handle(ctx, createValidDNSRequest(t))
+	require.NoError(t, m.Close())
+
+	resp, err := dns.ParsePacket(result.ResponseBytes)
+	require.NoError(t, err)
+	require.Len(t, resp.Answers, 1)
+	assert.Equal(t, "192.0.2.1", resp.Answers[0].(*dns.IPRecord).Addr.String(), "the client gets the primary answer")
+
+	st := m.Status()
+	assert.Equal(t, uint64(1), st.Diverged)
+	assert.Equal(t, 30*time.Millisecond, st.ShadowLatency)
+	require.Len(t, st.Recent, 1)
+	d := st.Recent[0]
+	assert.Equal(t, "example.com", d.Name)
+	assert.Equal(t, "A", d.Type)
+	assert.Equal(t, "00000000feedf00d", d.TraceID)
+	require.Len(t, d.Primary.Answers, 1)
+	assert.Contains(t, d.Primary.Answers[0], "192.0.2.1")
+	assert.NotContains(t, d.Primary.Answers[0], "300", "TTLs are left out")
+	require.Len(t, d.Shadow.Answers, 1)
+	assert.Contains(t, d.Shadow.Answers[0], "198.51.100.1")
+	assert.Equal(t, "192.0.2.53", d.Shadow.Upstream)
+
+	// Raising the privacy level redacts recorded divergences
+	privacy.Set(server.PrivacyNone, false)
+	m.Redact()
+	assert.Empty(t, m.Status().Recent[0].Name)
+}
+
+func TestShadowMirror_OnlyMirrorsUpstreamAnswers(t *testing.T) {
+	var shadowQueries atomic.Int32
+	shadow := &mockResolver{
+		resolveFunc: func(context.Context, dns.Packet, []byte) (resolvers.Result, error) {
+			shadowQueries.Add(1)
+			return resolvers.Result{}, resolvers.ErrNoUpstream
+		},
+	}
+	m := server.NewShadowMirror(shadow, server.ShadowSettings{Percent: 100, Timeout: time.Second}, nil, nil)
+	cached := shadowResolver(t, 300, "192.0.2.1")
+	resolveFunc := cached.resolveFunc
+	cached.resolveFunc = func(ctx context.Context, req dns.Packet, b []byte) (resolvers.Result, error) {
+		res, err := resolveFunc(ctx, req, b)
+		res.Source = "upstream-cache"
+		return res, err
+	}
+	handler := &server.QueryHandler{Resolver: cached, Timeout: time.Second, Shadow: m}
+
+	handler.Handle(withClient(context.Background(), "udp", "192.0.2.7"), createValidDNSRequest(t))
+	require.NoError(t, m.Close())
+
+	assert.Equal(t, int32(0), shadowQueries.Load())
+	assert.Equal(t, uint64(0), m.Status().Mirrored)
+}
+
+// ============================================================================
+// DNS Advertisement Tests
+// ============================================================================
+
+// advertiseWebhook records the bodies POSTed to it and answers with the
+// status codes in statuses, then 204.
+type advertiseWebhook struct {
+	mu         sync.Mutex
+	bodies     [][]byte
+	signatures []string
+	statuses   []int
+}
+
+func (w *advertiseWebhook) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.bodies = append(w.bodies, body)
+	w.signatures = append(w.signatures, r.Header.Get(server.AdvertiseSignatureHeader))
+	status := http.StatusNoContent
+	if len(w.statuses) > 0 {
+		status, w.statuses = w.statuses[0], w.statuses[1:]
+	}
+	rw.WriteHeader(status)
+}
+
+// advertiseBody is the part of a webhook body the tests check.
+type advertiseBody struct {
+	Port       int `json:"port"`
+	Interfaces []struct {
+		Name string   `json:"name"`
+		IPv4 []string `json:"ipv4"`
+		IPv6 []string `json:"ipv6"`
+	} `json:"interfaces"`
+	Previous []struct {
+		Name string   `json:"name"`
+		IPv4 []string `json:"ipv4"`
+	} `json:"previous"`
+}
+
+// staticInterfaces returns a lookup listing ifaces, which tests may change.
+func staticInterfaces(ifaces *[]server.AdvertisedInterface) server.InterfaceLookup {
+	return func() ([]server.AdvertisedInterface, error) { return *ifaces, nil }
+}
+
+func TestAdvertiser_DeliversChangedAddresses(t *testing.T) {
+	hook := &advertiseWebhook{}
+	srv := httptest.NewServer(hook)
+	defer srv.Close()
+
+	ifaces := []server.AdvertisedInterface{
+		{Name: "lo", IPv4: []netip.Addr{netip.MustParseAddr("127.0.0.1")}, IPv6: []netip.Addr{netip.IPv6Loopback()}},
+		{Name: "eth0", IPv4: []netip.Addr{netip.MustParseAddr("192.168.1.2")}, IPv6: []netip.Addr{
+			netip.MustParseAddr("fe80::1"), netip.MustParseAddr("2001:db8::2"),
+		}},
+		{Name: "docker0", IPv4: []netip.Addr{netip.MustParseAddr("172.17.0.1")}},
+	}
+	a := server.NewAdvertiser(server.AdvertiseSettings{
+		WebhookURL:    srv.URL,
+		WebhookSecret: "s3cret",
+		Interfaces:    []string{"lo", "eth0"},
+		Port:          53,
+	}, nil)
+	a.SetLookup(staticInterfaces(&ifaces))
+
+	a.Check(context.Background())
+	a.Check(context.Background())
+	require.Len(t, hook.bodies, 1, "unchanged addresses are not delivered again")
+
+	var body advertiseBody
+	require.NoError(t, json.Unmarshal(hook.bodies[0], &body))
+	assert.Equal(t, 53, body.Port)
+	require.Len(t, body.Interfaces, 1, "loopback and unlisted interfaces are left out")
+	assert.Equal(t, "eth0", body.Interfaces[0].Name)
+	assert.Equal(t, []string{"192.168.1.2"}, body.Interfaces[0].IPv4)
+	assert.Equal(t, []string{"2001:db8::2"}, body.Interfaces[0].IPv6, "link-local addresses are left out")
+	assert.Empty(t, body.Previous)
+
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(hook.bodies[0])
+	assert.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), hook.signatures[0])
+
+	ifaces[1].IPv4 = []netip.Addr{netip.MustParseAddr("192.168.1.3")}
+	a.Check(context.Background())
+	require.Len(t, hook.bodies, 2)
+	body = advertiseBody{}
+	require.NoError(t, json.Unmarshal(hook.bodies[1], &body))
+	assert.Equal(t, []string{"192.168.1.3"}, body.Interfaces[0].IPv4)
+	require.Len(t, body.Previous, 1)
+	assert.Equal(t, []string{"192.168.1.2"}, body.Previous[0].IPv4)
+
+	st := a.Status()
+	assert.True(t, st.Delivered)
+	assert.Empty(t, st.LastError)
+	require.Len(t, st.Interfaces, 1)
+	assert.Equal(t, "192.168.1.3", st.Interfaces[0].IPv4[0].String())
+}
+
+func TestAdvertiser_RetriesFailedDelivery(t *testing.T) {
+	hook := &advertiseWebhook{statuses: []int{http.StatusBadGateway}}
+	srv := httptest.NewServer(hook)
+	defer srv.Close()
+
+	ifaces := []server.AdvertisedInterface{{Name: "eth0", IPv4: []netip.Addr{netip.MustParseAddr("192.168.1.2")}}}
+	a := server.NewAdvertiser(server.AdvertiseSettings{WebhookURL: srv.URL, Port: 53}, nil)
+	a.SetLookup(staticInterfaces(&ifaces))
+
+	a.Check(context.Background())
+	st := a.Status()
+	assert.False(t, st.Delivered)
+	assert.Contains(t, st.LastError, "502")
+
+	a.Check(context.Background())
+	st = a.Status()
+	assert.True(t, st.Delivered)
+	assert.Empty(t, st.LastError)
+	assert.Len(t, hook.bodies, 2)
+	assert.Empty(t, hook.signatures[1], "unsigned without a secret")
+}
+
+func TestAdvertiser_OnlyListeningAddresses(t *testing.T) {
+	ifaces := []server.AdvertisedInterface{
+		{Name: "eth0", IPv4: []netip.Addr{netip.MustParseAddr("192.168.1.2")}, IPv6: []netip.Addr{netip.MustParseAddr("2001:db8::2")}},
+		{Name: "eth1", IPv4: []netip.Addr{netip.MustParseAddr("10.0.0.2")}},
+	}
+	tests := []struct {
+		host string
+		want []string
+	}{
+		{"", []string{"192.168.1.2", "2001:db8::2", "10.0.0.2"}},
+		{"::", []string{"192.168.1.2", "2001:db8::2", "10.0.0.2"}},
+		{"0.0.0.0", []string{"192.168.1.2", "10.0.0.2"}},
+		{"10.0.0.2", []string{"10.0.0.2"}},
+		{"127.0.0.1", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			hook := &advertiseWebhook{}
+			srv := httptest.NewServer(hook)
+			defer srv.Close()
+			a := server.NewAdvertiser(server.AdvertiseSettings{WebhookURL: srv.URL, Host: tt.host, Port: 53}, nil)
+			a.SetLookup(staticInterfaces(&ifaces))
+
+			a.Check(context.Background())
+
+			var got []string
+			for _, iface := range a.Status().Interfaces {
+				for _, ip := range slices.Concat(iface.IPv4, iface.IPv6) {
+					got = append(got, ip.String())
+				}
+			}
+			assert.Equal(t, tt.want, got)
+			if tt.want == nil {
+				assert.Empty(t, hook.bodies, "no addresses are not advertised")
+			}
+		})
+	}
+}
+
+func TestClientCoverage_WarnsAboutUncoveredSubnets(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	privacy := server.NewPrivacy(server.PrivacyFull, false)
+	coverage := server.NewClientCoverage([]netip.Prefix{netip.MustParsePrefix("192.168.1.0/24")}, logger, privacy)
+	handler := &server.QueryHandler{
+		Resolver: shadowResolver(t, 300, "192.0.2.1"),
+		Timeout:  time.Second,
+		Coverage: coverage,
+	}
+
+	for _, client := range []string{"192.168.1.7", "127.0.0.1", "10.0.5.1", "10.0.5.2", "2001:db8:0:1::7"} {
+		handler.Handle(withClient(context.Background(), "udp", client), createValidDNSRequest(t))
+	}
+
+	st := coverage.Status()
+	assert.Equal(t, []string{"192.168.1.0/24"}, st.ClientSubnets)
+	require.Len(t, st.Uncovered, 2)
+	assert.Equal(t, "10.0.5.0/24", st.Uncovered[0].Subnet)
+	assert.Equal(t, uint64(2), st.Uncovered[0].Queries)
+	assert.Equal(t, "2001:db8:0:1::/64", st.Uncovered[1].Subnet)
+	assert.Equal(t, 1, strings.Count(logs.String(), "subnet=10.0.5.0/24"), "warned once per subnet")
+	assert.NotContains(t, logs.String(), "192.168.1.")
+	assert.NotContains(t, logs.String(), "127.0.0.1")
+
+	// Raising the privacy level merges subnets it no longer tells apart
+	handler.Handle(withClient(context.Background(), "udp", "2001:db8:0:2::7"), createValidDNSRequest(t))
+	privacy.Set(server.PrivacyAnonymized, false)
+	st = coverage.Status()
+	require.Len(t, st.Uncovered, 2)
+	assert.Equal(t, "10.0.5.0/24", st.Uncovered[0].Subnet)
+	assert.Equal(t, "2001:db8::/48", st.Uncovered[1].Subnet)
+	assert.Equal(t, uint64(2), st.Uncovered[1].Queries)
+
+	privacy.Set(server.PrivacyDomains, false)
+	st = coverage.Status()
+	require.Len(t, st.Uncovered, 1)
+	assert.Empty(t, st.Uncovered[0].Subnet)
+	assert.Equal(t, uint64(4), st.Uncovered[0].Queries)
+}
+
+// ============================================================================
+// Record Health Tests
+// ============================================================================
+
+// closedPort returns a local TCP port nothing listens on.
+func closedPort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := ln.Addr().(*net.TCPAddr).Port
+	require.NoError(t, ln.Close())
+	return port
+}
+
+func TestRecordHealth_HTTPFailoverAndRecovery(t *testing.T) {
+	var failing atomic.Bool
+	var host atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host.Store(r.Host)
+		if r.URL.Path != "/healthz" || failing.Load() {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(srv.Close)
+	port := srv.Listener.Addr().(*net.TCPAddr).Port
+
+	h := server.NewRecordHealth(nil)
+	h.SetTargets([]server.RecordCheck{{
+		Name: "web.lan", Type: "http", Port: port, Path: "/healthz",
+		Interval: time.Second, Timeout: time.Second, Failures: 2,
+	}}, map[string][]string{"Web.Lan": {"127.0.0.1"}, "db.lan": {"127.0.0.2"}})
+
+	addr := netip.MustParseAddr("127.0.0.1")
+	h.Check(context.Background())
+	assert.True(t, h.Healthy("web.lan", addr))
+	assert.Equal(t, "web.lan:"+strconv.Itoa(port), host.Load(), "probes carry the checked name")
+
+	failing.Store(true)
+	h.Check(context.Background())
+	assert.True(t, h.Healthy("web.lan", addr), "one failure is below the threshold")
+	h.Check(context.Background())
+	assert.False(t, h.Healthy("web.lan", addr))
+
+	st := h.Status()
+	require.Len(t, st, 1, "hosts without a check are not probed")
+	assert.Equal(t, "http:"+strconv.Itoa(port)+"/healthz", st[0].Check)
+	assert.Equal(t, 2, st[0].ConsecutiveFailures)
+	assert.Contains(t, st[0].Error, "503")
+	assert.False(t, st[0].ChangedAt.IsZero())
+
+	// Unchanged checks keep their state across reloads
+	h.SetTargets([]server.RecordCheck{{
+		Name: "web.lan", Type: "http", Port: port, Path: "/healthz",
+		Interval: time.Second, Timeout: time.Second, Failures: 2,
+	}}, map[string][]string{"web.lan": {"127.0.0.1"}})
+	assert.False(t, h.Healthy("web.lan", addr))
+
+	failing.Store(false)
+	h.Check(context.Background())
+	assert.True(t, h.Healthy("web.lan", addr), "one passing probe recovers the address")
+	assert.True(t, h.Healthy("db.lan", netip.MustParseAddr("127.0.0.2")))
+	assert.True(t, h.Checked("web.lan"))
+	assert.False(t, h.Checked("db.lan"))
+}
+
+func TestRecordHealth_TCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	open := ln.Addr().(*net.TCPAddr).Port
+	h := server.NewRecordHealth(nil)
+	h.SetTargets([]server.RecordCheck{
+		{Name: "up.lan", Type: "tcp", Port: open, Interval: time.Second, Timeout: time.Second, Failures: 1},
+		{Name: "down.lan", Type: "tcp", Port: closedPort(t), Interval: time.Second, Timeout: time.Second, Failures: 1},
+	}, map[string][]string{"up.lan": {"127.0.0.1"}, "down.lan": {"127.0.0.1"}})
+
+	h.Check(context.Background())
+	addr := netip.MustParseAddr("127.0.0.1")
+	assert.True(t, h.Healthy("up.lan", addr))
+	assert.False(t, h.Healthy("down.lan", addr))
+}
+
+// ============================================================================
+// Lifecycle Tests
+// ============================================================================
+
+// recordingComponent returns a component that appends its start and stop to
+// events and serves until canceled.
+func recordingComponent(name string, events *[]string, mu *sync.Mutex) server.Component {
+	record := func(ev string) {
+		mu.Lock()
+		defer mu.Unlock()
+		*events = append(*events, ev)
+	}
+	return server.Component{
+		Name: name,
+		Start: func(context.Context) error {
+			record("start " + name)
+			return nil
+		},
+		Run: func(ctx context.Context) error {
+			<-ctx.Done()
+			record("canceled " + name)
+			return nil
+		},
+		Stop: func(context.Context) error {
+			record("stop " + name)
+			return nil
+		},
+	}
+}
+
+func componentStates(lc *server.Lifecycle) map[string]server.ComponentState {
+	states := make(map[string]server.ComponentState)
+	for _, c := range lc.Health() {
+		states[c.Name] = c.State
+	}
+	return states
+}
+
+func TestLifecycle_StartsInOrderAndStopsInReverse(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		events []string
+	)
+	lc := server.NewLifecycle(nil)
+	lc.Add(recordingComponent("resolver", &events, &mu))
+	lc.Add(recordingComponent("listener", &events, &mu))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- lc.Run(ctx) }()
+
+	require.Eventually(t, func() bool {
+		return componentStates(lc)["listener"] == server.ComponentRunning
+	}, time.Second, 5*time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+
+	assert.Equal(t, []string{
+		"start resolver", "start listener",
+		"canceled listener", "stop listener",
+		"canceled resolver", "stop resolver",
+	}, events)
+	for _, c := range lc.Health() {
+		assert.Equal(t, server.ComponentStopped, c.State, c.Name)
+	}
+}
+
+func TestLifecycle_RequiredStartFailureStopsStarted(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		events []string
+	)
+	lc := server.NewLifecycle(nil)
+	lc.Add(recordingComponent("resolver", &events, &mu))
+	lc.Add(server.Component{
+		Name:  "audit",
+		Start: func(context.Context) error { return errors.New("disk full") },
+	})
+	lc.Add(recordingComponent("listener", &events, &mu))
+
+	err := lc.Run(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "audit: disk full")
+
+	assert.Equal(t, []string{"start resolver", "canceled resolver", "stop resolver"}, events)
+	states := componentStates(lc)
+	assert.Equal(t, server.ComponentFailed, states["audit"])
+	assert.Equal(t, server.ComponentPending, states["listener"], "never started")
+}
+
+func TestLifecycle_OptionalFailureKeepsRunning(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		events []string
+	)
+	lc := server.NewLifecycle(nil)
+	lc.Add(recordingComponent("listener", &events, &mu))
+	lc.Add(server.Component{
+		Name:   "profile",
+		Policy: server.Optional,
+		Run:    func(context.Context) error { return errors.New("address already in use") },
+	})
+	lc.Add(server.Component{
+		Name:   "syncer",
+		Policy: server.Optional,
+		Start:  func(context.Context) error { return errors.New("primary unreachable") },
+		Stop: func(context.Context) error {
+			t.Error("Stop called for a component that did not start")
+			return nil
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- lc.Run(ctx) }()
+
+	require.Eventually(t, func() bool {
+		return componentStates(lc)["profile"] == server.ComponentFailed
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, server.ComponentRunning, componentStates(lc)["listener"])
+
+	cancel()
+	require.NoError(t, <-done)
+
+	health := lc.Health()
+	require.Len(t, health, 3)
+	assert.Equal(t, server.Optional, health[1].Policy)
+	assert.Equal(t, "address already in use", health[1].Error)
+	assert.Equal(t, server.ComponentFailed, health[2].State)
+	assert.Equal(t, "primary unreachable", health[2].Error)
+}
+
+func TestLifecycle_RequiredRunExitStopsServer(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		events []string
+	)
+	lc := server.NewLifecycle(nil)
+	lc.Add(recordingComponent("resolver", &events, &mu))
+	lc.Add(server.Component{
+		Name: "api",
+		Run:  func(context.Context) error { return nil },
+	})
+
+	err := lc.Run(context.Background())
+	require.Error(t, err, "returning before shutdown is a failure")
+	assert.Contains(t, err.Error(), "api")
+	assert.Equal(t, []string{"start resolver", "canceled resolver", "stop resolver"}, events)
+}
+
+func TestLifecycle_DrainsBeforeStopping(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		events []string
+	)
+	lc := server.NewLifecycle(nil)
+	lc.SetDrain(500 * time.Millisecond)
+	lc.Add(recordingComponent("listener", &events, &mu))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- lc.Run(ctx) }()
+
+	require.Eventually(t, func() bool {
+		return componentStates(lc)["listener"] == server.ComponentRunning
+	}, time.Second, 5*time.Millisecond)
+	assert.False(t, lc.ShuttingDown())
+
+	cancel()
+	require.Eventually(t, lc.ShuttingDown, time.Second, 5*time.Millisecond)
+	assert.Equal(t, server.ComponentRunning, componentStates(lc)["listener"], "still serving while draining")
+
+	require.NoError(t, <-done)
+	assert.Equal(t, []string{"start listener", "canceled listener", "stop listener"}, events)
+}
+
+func TestRunner_NotReady(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	require.NoError(t, conn.Close())
+
+	cfg := &config.Config{
+		Server: config.ServerConfig{
+			Host: "127.0.0.1", Port: port, WorkersRaw: "1", MaxConcurrency: 4,
+			ShutdownDrain: "500ms",
+		},
+		Upstream: config.UpstreamConfig{Servers: []string{"127.0.0.1"}},
+	}
+	require.NoError(t, cfg.Validate())
+
+	release := make(chan struct{})
+	lists := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		<-release
+		_, _ = w.Write([]byte("ads.example.com\n"))
+	}))
+	defer lists.Close()
+	pe := filtering.NewPolicyEngine(filtering.PolicyEngineConfig{
+		Enabled:       true,
+		BlocklistURLs: []filtering.BlocklistURL{{Name: "ads", URL: lists.URL, Format: filtering.FormatDomains}},
+	})
+	defer pe.Close()
+
+	started := make(chan struct{})
+	runner := server.NewRunner(nil)
+	runner.SetPolicyEngine(pe)
+	runner.AddComponent(server.Component{
+		Name: "api",
+		Start: func(context.Context) error {
+			<-started
+			return nil
+		},
+	})
+	assert.Equal(t, []string{"server is starting"}, runner.NotReady())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- runner.RunWithContext(ctx, cfg) }()
+
+	require.Eventually(t, func() bool {
+		return slices.Equal(runner.NotReady(), []string{"api is starting", "blocklists are loading"})
+	}, 2*time.Second, 5*time.Millisecond, "got %v", runner.NotReady())
+	close(started)
+	require.Eventually(t, func() bool {
+		return slices.Equal(runner.NotReady(), []string{"blocklists are loading"})
+	}, 2*time.Second, 5*time.Millisecond)
+	close(release)
+	require.Eventually(t, func() bool { return runner.NotReady() == nil }, 5*time.Second, 5*time.Millisecond)
+
+	cancel()
+	assert.Eventually(t, func() bool {
+		return slices.Equal(runner.NotReady(), []string{"shutting down"})
+	}, time.Second, 5*time.Millisecond)
+	for _, c := range runner.Components() {
+		if c.Name == "dns-udp" {
+			assert.Equal(t, server.ComponentRunning, c.State, "listeners keep answering while draining")
+		}
+	}
+	require.NoError(t, <-done)
+}
+
+func TestRunner_Components(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	require.NoError(t, conn.Close())
+
+	cfg := &config.Config{
+		Server:   config.ServerConfig{Host: "127.0.0.1", Port: port, WorkersRaw: "1", MaxConcurrency: 4},
+		Upstream: config.UpstreamConfig{Servers: []string{"127.0.0.1"}},
+	}
+	require.NoError(t, cfg.Validate())
+
+	runner := server.NewRunner(nil)
+	assert.Nil(t, runner.Components())
+	runner.AddComponent(server.Component{Name: "extra", Policy: server.Optional})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- runner.RunWithContext(ctx, cfg) }()
+
+	require.Eventually(t, func() bool {
+		c := runner.Components()
+		return len(c) > 0 && c[len(c)-1].State == server.ComponentRunning
+	}, 2*time.Second, 5*time.Millisecond)
+
+	var names []string
+	for _, c := range runner.Components() {
+		names = append(names, c.Name)
+	}
+	assert.Equal(t, []string{"filtering", "resolver", "dns-udp", "extra"}, names)
+
+	cancel()
+	require.NoError(t, <-done)
+}
@@ -0,0 +1,286 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jroosing/hydradns/internal/dns"
+	"github.com/jroosing/hydradns/internal/resolvers"
+)
+
+const (
+	// shadowMaxInflight bounds the shadow queries running at once; sampled
+	// queries beyond it are skipped rather than queued.
+	shadowMaxInflight = 64
+	// shadowRecentDivergences is the number of divergences kept for Status.
+	shadowRecentDivergences = 20
+)
+
+// ShadowSettings contains shadow upstream settings (see config.ShadowConfig).
+type ShadowSettings struct {
+	Servers []string
+	Percent float64
+	Timeout time.Duration
+}
+
+// ShadowAnswer is one side of a compared query.
+type ShadowAnswer struct {
+	Upstream string
+	RCode    string
+	Answers  []string // Records as zone-file lines without TTLs, sorted
+	Latency  time.Duration
+}
+
+// ShadowDivergence is a query the shadow upstreams answered differently.
+type ShadowDivergence struct {
+	Time    time.Time
+	Name    string
+	Type    string
+	TraceID string
+	Primary ShadowAnswer
+	Shadow  ShadowAnswer
+}
+
+// ShadowStatus summarizes the comparison of primary and shadow upstreams.
+type ShadowStatus struct {
+	Servers []string
+	Percent float64
+	// Mirrored counts the queries sent to the shadow upstreams. Each ends
+	// up Matched, Diverged, or Failed, when no shadow upstream answered.
+	Mirrored uint64
+	Matched  uint64
+	Diverged uint64
+	Failed   uint64
+	// Skipped counts sampled queries not mirrored because too many shadow
+	// queries were already running.
+	Skipped uint64
+	// PrimaryLatency and ShadowLatency are the mean upstream times of the
+	// compared queries.
+	PrimaryLatency time.Duration
+	ShadowLatency  time.Duration
+	Recent         []ShadowDivergence // Newest first
+}
+
+// ShadowMirror sends a share of the queries the primary upstreams answer to
+// shadow upstreams and compares the answers, without affecting the
+// response to the client: shadow queries run in the background, after the
+// primary answer is in.
+//
+// Only queries answered by an upstream query of their own are mirrored, so
+// both sides are fresh and their latencies comparable. Shadow queries skip
+// the shadow resolver's cache. Answers match when their response codes and
+// answer records agree, ignoring TTLs, record order, and RRSIGs.
+//
+// All methods are safe for concurrent use.
+type ShadowMirror struct {
+	resolver resolvers.Resolver
+	settings ShadowSettings
+	logger   *slog.Logger
+	privacy  *Privacy
+
+	slots chan struct{}
+	wg    sync.WaitGroup
+
+	mirrored, matched, diverged, failed, skipped atomic.Uint64
+	primaryTime, shadowTime                      atomic.Int64 // Sums over compared queries, in ns
+
+	mu     sync.Mutex
+	recent []ShadowDivergence // Oldest first
+}
+
+// NewShadowMirror returns a mirror comparing answers with those of
+// resolver, which queries the shadow upstreams. Divergences are logged
+// with names redacted by privacy.
+func NewShadowMirror(resolver resolvers.Resolver, settings ShadowSettings, logger *slog.Logger, privacy *Privacy) *ShadowMirror {
+	return &ShadowMirror{
+		resolver: resolver,
+		settings: settings,
+		logger:   logger,
+		privacy:  privacy,
+		slots:    make(chan struct{}, shadowMaxInflight),
+	}
+}
+
+// Mirror samples a query the primary upstreams answered with result and,
+// if chosen, resolves it through the shadow upstreams in the background.
+// ctx carries the query's ClientInfo; its cancellation is ignored. A nil
+// mirror does nothing.
+func (m *ShadowMirror) Mirror(ctx context.Context, req dns.Packet, result resolvers.Result) {
+	if m == nil || len(req.Questions) == 0 || rand.Float64()*100 >= m.settings.Percent {
+		return
+	}
+	select {
+	case m.slots <- struct{}{}:
+	default:
+		m.skipped.Add(1)
+		return
+	}
+	m.mirrored.Add(1)
+	primary := bytes.Clone(result.ResponseBytes)
+	m.wg.Go(func() {
+		defer func() { <-m.slots }()
+		m.compare(context.WithoutCancel(ctx), req, primary, result.Upstream, result.UpstreamTime)
+	})
+}
+
+// compare resolves req through the shadow upstreams and records how the
+// answer compares with the primary one.
+func (m *ShadowMirror) compare(
+	ctx context.Context,
+	req dns.Packet,
+	primaryBytes []byte,
+	primaryUpstream string,
+	primaryTime time.Duration,
+) {
+	ctx, cancel := context.WithTimeout(resolvers.WithoutCache(ctx), m.settings.Timeout)
+	defer cancel()
+	client, _ := resolvers.ClientInfoFromContext(ctx)
+	q := req.Questions[0]
+
+	reqBytes, err := req.Marshal()
+	if err != nil {
+		m.failed.Add(1)
+		return
+	}
+	start := time.Now()
+	res, err := m.resolver.Resolve(ctx, req, reqBytes)
+	if err == nil && res.UpstreamTime == 0 {
+		res.UpstreamTime = time.Since(start)
+	}
+	var shadow ShadowAnswer
+	if err == nil {
+		shadow, err = summarizeShadowAnswer(res.ResponseBytes)
+	}
+	if err != nil {
+		m.failed.Add(1)
+		if m.logger != nil {
+			m.logger.DebugContext(ctx, "shadow query failed",
+				"qname", m.privacy.Name(q.Name),
+				"qtype", dns.RecordType(q.Type).String(),
+				"trace_id", client.TraceID,
+				"err", err,
+			)
+		}
+		return
+	}
+	primary, err := summarizeShadowAnswer(primaryBytes)
+	if err != nil {
+		m.failed.Add(1)
+		return
+	}
+	primary.Upstream, primary.Latency = primaryUpstream, primaryTime
+	shadow.Upstream, shadow.Latency = res.Upstream, res.UpstreamTime
+
+	m.primaryTime.Add(int64(primary.Latency))
+	m.shadowTime.Add(int64(shadow.Latency))
+	if primary.RCode == shadow.RCode && slices.Equal(primary.Answers, shadow.Answers) {
+		m.matched.Add(1)
+		return
+	}
+	m.diverged.Add(1)
+
+	d := ShadowDivergence{
+		Time:    time.Now(),
+		Name:    m.privacy.Name(q.Name),
+		Type:    dns.RecordType(q.Type).String(),
+		TraceID: client.TraceID,
+		Primary: primary,
+		Shadow:  shadow,
+	}
+	m.mu.Lock()
+	if len(m.recent) == shadowRecentDivergences {
+		m.recent = slices.Delete(m.recent, 0, 1)
+	}
+	m.recent = append(m.recent, d)
+	m.mu.Unlock()
+
+	if m.logger != nil {
+		m.logger.InfoContext(ctx, "shadow answer differs",
+			"qname", d.Name,
+			"qtype", d.Type,
+			"trace_id", d.TraceID,
+			"primary_upstream", primary.Upstream,
+			"primary_rcode", primary.RCode,
+			"primary_answers", strings.Join(primary.Answers, "; "),
+			"primary_ms", primary.Latency.Milliseconds(),
+			"shadow_upstream", shadow.Upstream,
+			"shadow_rcode", shadow.RCode,
+			"shadow_answers", strings.Join(shadow.Answers, "; "),
+			"shadow_ms", shadow.Latency.Milliseconds(),
+		)
+	}
+}
+
+// summarizeShadowAnswer returns the response code and answer records of
+// resp in comparable form.
+func summarizeShadowAnswer(resp []byte) (ShadowAnswer, error) {
+	p, err := dns.ParsePacket(resp)
+	if err != nil {
+		return ShadowAnswer{}, err
+	}
+	out := ShadowAnswer{RCode: dns.RCodeFromFlags(p.Header.Flags).String()}
+	for _, r := range p.Answers {
+		if r.Type() == dns.TypeRRSIG {
+			continue
+		}
+		out.Answers = append(out.Answers, withoutTTL(dns.FormatRecord(r)))
+	}
+	slices.Sort(out.Answers)
+	return out, nil
+}
+
+// withoutTTL returns a FormatRecord line without its TTL field, with the
+// owner name lowercased.
+func withoutTTL(line string) string {
+	name, rest, _ := strings.Cut(line, "\t")
+	_, rest, _ = strings.Cut(rest, "\t")
+	return strings.ToLower(name) + "\t" + rest
+}
+
+// Status returns the comparison counters and the recent divergences.
+func (m *ShadowMirror) Status() ShadowStatus {
+	s := ShadowStatus{
+		Servers:  slices.Clone(m.settings.Servers),
+		Percent:  m.settings.Percent,
+		Mirrored: m.mirrored.Load(),
+		Matched:  m.matched.Load(),
+		Diverged: m.diverged.Load(),
+		Failed:   m.failed.Load(),
+		Skipped:  m.skipped.Load(),
+	}
+	if compared := int64(s.Matched + s.Diverged); compared > 0 {
+		s.PrimaryLatency = time.Duration(m.primaryTime.Load() / compared)
+		s.ShadowLatency = time.Duration(m.shadowTime.Load() / compared)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s.Recent = make([]ShadowDivergence, 0, len(m.recent))
+	for i := len(m.recent) - 1; i >= 0; i-- {
+		s.Recent = append(s.Recent, m.recent[i])
+	}
+	return s
+}
+
+// Redact redacts the recorded divergences again at the current privacy
+// level, e.g. after it was raised.
+func (m *ShadowMirror) Redact() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.recent {
+		m.recent[i].Name = m.privacy.Name(m.recent[i].Name)
+	}
+}
+
+// Close waits for running shadow queries and closes the shadow resolver.
+// Mirror must not be called after Close.
+func (m *ShadowMirror) Close() error {
+	m.wg.Wait()
+	return m.resolver.Close()
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jroosing/hydradns/internal/dns"
+)
+
+// SlowQuery describes a query for the slow query log. Client and Name are
+// already redacted as the privacy level prescribes.
+type SlowQuery struct {
+	Transport    string
+	Client       string
+	TraceID      string
+	ID           uint16
+	Name         string
+	Type         dns.RecordType
+	RCode        dns.RCode
+	Source       string
+	Bytes        int           // Response size
+	Duration     time.Duration // End to end
+	Upstream     string        // Upstream that answered, if any
+	UpstreamTime time.Duration // Time spent querying upstreams
+}
+
+// SlowQueryLog logs queries that took longer than a threshold, end to end
+// or waiting on upstreams, to catch intermittent upstream latency. Lines
+// go to the log handler directly, so they are written whatever the log
+// level, at most maxPerMinute a minute; slow queries left out are counted
+// and reported with the next line.
+//
+// A nil *SlowQueryLog logs nothing. Thread-safe for concurrent use.
+type SlowQueryLog struct {
+	logger       *slog.Logger
+	threshold    time.Duration
+	maxPerMinute int
+
+	mu         sync.Mutex
+	window     time.Time // Start of the current minute
+	logged     int       // Lines logged in the current minute
+	suppressed int       // Slow queries left out since the last line
+}
+
+// NewSlowQueryLog creates a slow query log writing to logger. It returns
+// nil, logging nothing, when logger is nil or threshold is not positive.
+func NewSlowQueryLog(logger *slog.Logger, threshold time.Duration, maxPerMinute int) *SlowQueryLog {
+	if logger == nil || threshold <= 0 {
+		return nil
+	}
+	return &SlowQueryLog{logger: logger, threshold: threshold, maxPerMinute: max(maxPerMinute, 1)}
+}
+
+// Slow reports whether a query that took total, of which upstream was
+// spent querying upstreams, is slow.
+func (l *SlowQueryLog) Slow(total, upstream time.Duration) bool {
+	return l != nil && (total > l.threshold || upstream > l.threshold)
+}
+
+// Log logs q, unless this minute's cap has been reached.
+func (l *SlowQueryLog) Log(ctx context.Context, q SlowQuery) {
+	if l == nil {
+		return
+	}
+	now := time.Now()
+
+	l.mu.Lock()
+	if now.Sub(l.window) >= time.Minute {
+		l.window = now
+		l.logged = 0
+	}
+	if l.logged >= l.maxPerMinute {
+		l.suppressed++
+		l.mu.Unlock()
+		return
+	}
+	l.logged++
+	suppressed := l.suppressed
+	l.suppressed = 0
+	l.mu.Unlock()
+
+	rec := slog.NewRecord(now, slog.LevelWarn, "slow query", 0)
+	rec.AddAttrs(
+		slog.String("transport", q.Transport),
+		slog.String("src", q.Client),
+		slog.String("trace_id", q.TraceID),
+		slog.Int("id", int(q.ID)),
+		slog.String("qname", q.Name),
+		slog.String("qtype", q.Type.String()),
+		slog.String("rcode", q.RCode.String()),
+		slog.String("source", q.Source),
+		slog.Int("bytes", q.Bytes),
+		slog.Float64("duration_ms", milliseconds(q.Duration)),
+		slog.Duration("threshold", l.threshold),
+	)
+	if q.Upstream != "" {
+		rec.AddAttrs(
+			slog.String("upstream", q.Upstream),
+			slog.Float64("upstream_ms", milliseconds(q.UpstreamTime)),
+		)
+	}
+	if suppressed > 0 {
+		rec.AddAttrs(slog.Int("suppressed", suppressed))
+	}
+	_ = l.logger.Handler().Handle(ctx, rec)
+}
+
+// milliseconds returns d in milliseconds, to the microsecond.
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
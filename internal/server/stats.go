@@ -2,6 +2,8 @@ package server
 
 import (
 	"sync/atomic"
+
+	"github.com/jroosing/hydradns/internal/dns"
 )
 
 // DNSStats collects DNS query statistics.
@@ -12,7 +14,11 @@ type DNSStats struct {
 	queriesTCP     atomic.Uint64
 	responsesNX    atomic.Uint64
 	responsesErr   atomic.Uint64
+	panics         atomic.Uint64
 	latencyTotalNs atomic.Uint64
+
+	parseUDP dns.ParseStats
+	parseTCP dns.ParseStats
 }
 
 // NewDNSStats creates a new DNS statistics collector.
@@ -31,6 +37,18 @@ func (s *DNSStats) RecordQuery(transport string) {
 	}
 }
 
+// ParseStats returns the parser statistics for the given transport (udp or
+// tcp), or nil for an unknown transport.
+func (s *DNSStats) ParseStats(transport string) *dns.ParseStats {
+	switch transport {
+	case "udp":
+		return &s.parseUDP
+	case "tcp":
+		return &s.parseTCP
+	}
+	return nil
+}
+
 // RecordNXDOMAIN records an NXDOMAIN response.
 func (s *DNSStats) RecordNXDOMAIN() {
 	s.responsesNX.Add(1)
@@ -41,6 +59,11 @@ func (s *DNSStats) RecordError() {
 	s.responsesErr.Add(1)
 }
 
+// RecordPanic records a panic recovered while serving a query.
+func (s *DNSStats) RecordPanic() {
+	s.panics.Add(1)
+}
+
 // RecordLatency records query latency in nanoseconds.
 func (s *DNSStats) RecordLatency(ns int64) {
 	if ns > 0 {
@@ -55,7 +78,10 @@ type DNSStatsSnapshot struct {
 	QueriesTCP   uint64
 	ResponsesNX  uint64
 	ResponsesErr uint64
+	Panics       uint64 // Panics recovered while serving queries
 	AvgLatencyMs float64
+	ParseUDP     dns.ParseStatsSnapshot // Requests rejected by the parser over UDP
+	ParseTCP     dns.ParseStatsSnapshot // Requests rejected by the parser over TCP
 }
 
 // Snapshot returns the current statistics.
@@ -74,6 +100,9 @@ func (s *DNSStats) Snapshot() DNSStatsSnapshot {
 		QueriesTCP:   s.queriesTCP.Load(),
 		ResponsesNX:  s.responsesNX.Load(),
 		ResponsesErr: s.responsesErr.Load(),
+		Panics:       s.panics.Load(),
 		AvgLatencyMs: avgLatencyMs,
+		ParseUDP:     s.parseUDP.Snapshot(),
+		ParseTCP:     s.parseTCP.Snapshot(),
 	}
 }
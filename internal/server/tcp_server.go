@@ -6,7 +6,9 @@ import (
 	"errors"
 	"io"
 	"log/slog"
+	"maps"
 	"net"
+	"net/netip"
 	"runtime"
 	"sync"
 	"syscall"
@@ -14,7 +16,9 @@ import (
 
 	"golang.org/x/sys/unix"
 
+	"github.com/jroosing/hydradns/internal/capture"
 	"github.com/jroosing/hydradns/internal/pool"
+	"github.com/jroosing/hydradns/internal/resolvers"
 )
 
 // lenBufPool reduces allocations for TCP length prefix reads/writes.
@@ -24,19 +28,27 @@ var lenBufPool = pool.New(func() *[]byte {
 	return &buf
 })
 
-// TCP server configuration constants.
+// maxTCPMessageSize is the maximum DNS message size over TCP.
+const maxTCPMessageSize = 65535
+
+// tcpFastOpenQueueLen bounds pending TCP Fast Open connections per listener
+// whose handshake has not completed.
+const tcpFastOpenQueueLen = 256
+
+// Defaults for TCPServer fields left at zero.
 const (
-	maxTCPMessageSize        = 65535            // Maximum DNS message size over TCP
-	tcpReadTimeout           = 10 * time.Second // Read timeout per message
-	tcpConnectionIdleTimeout = 30 * time.Second // Idle timeout for connection
-	maxTCPConnectionsPerIP   = 10               // Max concurrent connections per IP
-	maxQueriesPerConnection  = 100              // Max queries before closing connection
+	DefaultTCPReadTimeout       = 10 * time.Second // Read timeout per message
+	DefaultTCPIdleTimeout       = 30 * time.Second // Idle timeout for connection
+	DefaultTCPMaxConnsPerIP     = 10               // Max concurrent connections per IP
+	DefaultTCPMaxQueriesPerConn = 100              // Max queries before closing connection
 )
 
 // TCPServer handles DNS queries over TCP with connection pipelining.
 //
 // Features:
 //   - SO_REUSEPORT for multi-core scalability (multiple listeners per address)
+//   - TCP Fast Open, so returning clients send their query in the SYN
+//   - PROXY protocol v2 from trusted load balancers (see ProxyProtocolFrom)
 //   - Per-IP connection limiting to prevent resource exhaustion
 //   - Connection pipelining (multiple queries per connection)
 //   - Idle timeout to free unused connections
@@ -47,19 +59,30 @@ const (
 //
 // Goroutine Lifecycle:
 //
-// For each CPU core, Run() spawns:
+// For each listener socket (one per CPU core unless Listeners is set), Run() spawns:
 //   - 1 listener goroutine: Accepts incoming TCP connections
 //
 // For each accepted connection:
 //   - 1 handler goroutine: Reads queries, invokes handler, sends responses
 //
 // All goroutines share the same context and exit when it is cancelled.
-// Per-IP limits prevent a single client from exhausting resources.
+// Per-IP limits prevent a single client from exhausting resources; active
+// connection counts are available from ConnectionsPerIP while running.
 type TCPServer struct {
-	Logger  *slog.Logger  // Optional logger
-	Handler *QueryHandler // Query processor
-
-	listeners []net.Listener // TCP listeners (one per CPU core with SO_REUSEPORT)
+	Logger            *slog.Logger  // Optional logger
+	Handler           *QueryHandler // Query processor
+	ReadTimeout       time.Duration // Read/write timeout per message (default DefaultTCPReadTimeout)
+	IdleTimeout       time.Duration // Idle timeout for a connection (default DefaultTCPIdleTimeout)
+	MaxConnsPerIP     int           // Max concurrent connections per IP (default DefaultTCPMaxConnsPerIP)
+	MaxQueriesPerConn int           // Max queries before closing a connection (default DefaultTCPMaxQueriesPerConn)
+	Listeners         int           // SO_REUSEPORT listeners to open (default: runtime.NumCPU())
+	// ProxyProtocolFrom lists proxies whose connections start with a PROXY
+	// protocol v2 header; the client address from the header replaces the
+	// proxy's for connection limits and query handling. Empty disables it.
+	ProxyProtocolFrom []netip.Prefix
+	Capture           *capture.Recorder // Optional packet capture of queries and responses
+
+	listeners []net.Listener // TCP listeners sharing the address with SO_REUSEPORT
 
 	wg sync.WaitGroup // Tracks active connections
 
@@ -68,9 +91,15 @@ type TCPServer struct {
 }
 
 // Run starts the TCP server with multiple listeners using SO_REUSEPORT.
-// Creates one listener per CPU core for better multi-core scalability.
+// Creates one listener per CPU core, unless Listeners is set, for better
+// multi-core scalability.
 func (s *TCPServer) Run(ctx context.Context, addr string) error {
-	socketCount := runtime.NumCPU()
+	s.applyDefaults()
+
+	socketCount := s.Listeners
+	if socketCount <= 0 {
+		socketCount = runtime.NumCPU()
+	}
 	s.listeners = make([]net.Listener, 0, socketCount)
 
 	s.mu.Lock()
@@ -102,6 +131,42 @@ func (s *TCPServer) Run(ctx context.Context, addr string) error {
 	return s.Stop(5 * time.Second)
 }
 
+// RunOnListener runs the server on an existing listener.
+// This is useful for testing and when the caller manages the socket.
+func (s *TCPServer) RunOnListener(ctx context.Context, ln net.Listener) error {
+	s.applyDefaults()
+
+	s.mu.Lock()
+	if s.connPerIP == nil {
+		s.connPerIP = map[string]int{}
+	}
+	s.mu.Unlock()
+
+	s.listeners = []net.Listener{ln}
+	s.wg.Go(func() {
+		s.acceptLoop(ctx, ln)
+	})
+
+	<-ctx.Done()
+	return s.Stop(5 * time.Second)
+}
+
+// applyDefaults fills in zero-valued limits.
+func (s *TCPServer) applyDefaults() {
+	if s.ReadTimeout <= 0 {
+		s.ReadTimeout = DefaultTCPReadTimeout
+	}
+	if s.IdleTimeout <= 0 {
+		s.IdleTimeout = DefaultTCPIdleTimeout
+	}
+	if s.MaxConnsPerIP <= 0 {
+		s.MaxConnsPerIP = DefaultTCPMaxConnsPerIP
+	}
+	if s.MaxQueriesPerConn <= 0 {
+		s.MaxQueriesPerConn = DefaultTCPMaxQueriesPerConn
+	}
+}
+
 // acceptLoop accepts connections on a single listener until context is cancelled.
 // Goroutine lifecycle: Started in Run() for each listener, exits when ctx is cancelled
 // or listener is closed. No cleanup needed beyond connection tracking.
@@ -116,6 +181,16 @@ func (s *TCPServer) acceptLoop(ctx context.Context, ln net.Listener) {
 			return
 		}
 
+		// The client address of proxied connections is only known once
+		// the header has been read, which must not block accepting.
+		if s.fromTrustedProxy(c.RemoteAddr()) {
+			conn := c
+			s.wg.Go(func() {
+				s.handleProxiedConnection(ctx, conn)
+			})
+			continue
+		}
+
 		remoteIP := remoteIPString(c.RemoteAddr())
 
 		// Enforce per-IP connection limit
@@ -135,6 +210,56 @@ func (s *TCPServer) acceptLoop(ctx context.Context, ln net.Listener) {
 	}
 }
 
+// fromTrustedProxy reports whether addr is one of ProxyProtocolFrom.
+func (s *TCPServer) fromTrustedProxy(addr net.Addr) bool {
+	if len(s.ProxyProtocolFrom) == 0 {
+		return false
+	}
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	ip, ok := netip.AddrFromSlice(tcpAddr.IP)
+	if !ok {
+		return false
+	}
+	ip = ip.Unmap()
+	for _, p := range s.ProxyProtocolFrom {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleProxiedConnection reads the PROXY protocol header of a connection
+// from a trusted proxy, then serves it as coming from the client named in
+// the header. Connections without a valid header are closed.
+func (s *TCPServer) handleProxiedConnection(ctx context.Context, conn net.Conn) {
+	_ = conn.SetReadDeadline(time.Now().Add(s.ReadTimeout))
+	client, err := readProxyHeader(conn)
+	if err != nil {
+		if s.Logger != nil {
+			s.Logger.DebugContext(ctx, "tcp proxy protocol rejected", "proxy", remoteIPString(conn.RemoteAddr()), "err", err)
+		}
+		_ = conn.Close()
+		return
+	}
+
+	ip := remoteIPString(conn.RemoteAddr())
+	if client.IsValid() {
+		ip = client.String()
+	}
+	if !s.tryAcquireConn(ip) {
+		if s.Logger != nil {
+			s.Logger.WarnContext(ctx, "tcp connection limit exceeded", "ip", ip)
+		}
+		_ = conn.Close()
+		return
+	}
+	s.handleConnection(ctx, conn, ip)
+}
+
 // handleConnection processes DNS queries on a single TCP connection.
 // Supports pipelining: multiple queries can be sent on the same connection.
 //
@@ -143,15 +268,23 @@ func (s *TCPServer) acceptLoop(ctx context.Context, ln net.Listener) {
 // - Connection idle timeout expires
 // - Read/write error occurs
 // - Max queries per connection reached
+// - A panic occurs (recovered and logged; the connection is closed)
 // Cleanup: Connection released from per-IP tracking, socket closed via defer.
 func (s *TCPServer) handleConnection(ctx context.Context, conn net.Conn, ip string) {
+	defer func() {
+		if v := recover(); v != nil {
+			reportPanic(ctx, s.Logger, handlerStats(s.Handler), v, "transport", "tcp", "client", ip)
+		}
+	}()
 	defer s.releaseConn(ip)
 	defer conn.Close()
 
 	// Set initial idle timeout
-	_ = conn.SetDeadline(time.Now().Add(tcpConnectionIdleTimeout))
+	_ = conn.SetDeadline(time.Now().Add(s.IdleTimeout))
 
-	for range maxQueriesPerConnection {
+	addr, _ := netip.ParseAddr(ip)
+	queryCtx := resolvers.WithClientInfo(ctx, resolvers.ClientInfo{Addr: addr, Transport: "tcp"})
+	for range s.MaxQueriesPerConn {
 		if ctx.Err() != nil {
 			return
 		}
@@ -165,14 +298,14 @@ func (s *TCPServer) handleConnection(ctx context.Context, conn net.Conn, ip stri
 		}
 
 		// Reset idle timeout after activity
-		_ = conn.SetDeadline(time.Now().Add(tcpConnectionIdleTimeout))
+		_ = conn.SetDeadline(time.Now().Add(s.IdleTimeout))
 
 		if s.Handler == nil {
 			return
 		}
 
-		remoteIP := remoteIPString(conn.RemoteAddr())
-		res := s.Handler.Handle(ctx, "tcp", remoteIP, msg)
+		s.captureMessage(conn, addr, msg, false)
+		res := s.Handler.Handle(queryCtx, msg)
 		if len(res.ResponseBytes) == 0 {
 			continue
 		}
@@ -180,9 +313,26 @@ func (s *TCPServer) handleConnection(ctx context.Context, conn net.Conn, ip stri
 		if !s.writeMessage(conn, res.ResponseBytes) {
 			return
 		}
+		s.captureMessage(conn, addr, res.ResponseBytes, true)
 	}
 }
 
+// captureMessage records msg, received from client on conn or sent to it
+// when sent is set, if a packet capture runs. The addresses recorded are
+// those of the connection, a proxy's when the PROXY protocol is used.
+func (s *TCPServer) captureMessage(conn net.Conn, client netip.Addr, msg []byte, sent bool) {
+	if !s.Capture.Capturing() {
+		return
+	}
+	local, _ := conn.LocalAddr().(*net.TCPAddr)
+	remote, _ := conn.RemoteAddr().(*net.TCPAddr)
+	src, dst := remote.AddrPort(), local.AddrPort()
+	if sent {
+		src, dst = dst, src
+	}
+	s.Capture.RecordClient("tcp", client, src, dst, msg)
+}
+
 // readMessage reads a length-prefixed DNS message from the connection.
 // Returns nil, false on error or if the message is too large.
 //
@@ -195,7 +345,7 @@ func (s *TCPServer) handleConnection(ctx context.Context, conn net.Conn, ip stri
 //	+------+
 func (s *TCPServer) readMessage(conn net.Conn) ([]byte, bool) {
 	// Read 2-byte length prefix using pooled buffer
-	_ = conn.SetReadDeadline(time.Now().Add(tcpReadTimeout))
+	_ = conn.SetReadDeadline(time.Now().Add(s.ReadTimeout))
 	lenBufPtr := lenBufPool.Get()
 	lenBuf := *lenBufPtr
 	_, err := io.ReadFull(conn, lenBuf)
@@ -215,7 +365,7 @@ func (s *TCPServer) readMessage(conn net.Conn) ([]byte, bool) {
 	}
 
 	// Read message body
-	_ = conn.SetReadDeadline(time.Now().Add(tcpReadTimeout))
+	_ = conn.SetReadDeadline(time.Now().Add(s.ReadTimeout))
 	msg := make([]byte, msgLen)
 	if _, err := io.ReadFull(conn, msg); err != nil {
 		return nil, false
@@ -232,7 +382,7 @@ func (s *TCPServer) writeMessage(conn net.Conn, response []byte) bool {
 		return false
 	}
 
-	_ = conn.SetWriteDeadline(time.Now().Add(tcpReadTimeout))
+	_ = conn.SetWriteDeadline(time.Now().Add(s.ReadTimeout))
 
 	// Write length prefix and message body using writev (net.Buffers)
 	lenBufPtr := lenBufPool.Get()
@@ -276,11 +426,19 @@ func (s *TCPServer) Stop(timeout time.Duration) error {
 // listenTCPReusePort creates a TCP listener with SO_REUSEPORT enabled.
 // This allows multiple listeners to bind to the same address, with the kernel
 // distributing incoming connections across them for better multi-core scalability.
+//
+// TCP Fast Open (RFC 7413) is enabled as well. Stub resolvers that reconnect
+// often (e.g. after TC=1) then carry the query in the SYN and skip a round
+// trip. DNS queries are safe to replay, which TFO data may be. The option is
+// best effort: on Linux the kernel only honors it when net.ipv4.tcp_fastopen
+// has the server bit (2) set, and connections fall back to the normal
+// handshake otherwise.
 func listenTCPReusePort(ctx context.Context, addr string) (net.Listener, error) {
 	lc := net.ListenConfig{
 		Control: func(_, _ string, c syscall.RawConn) error {
 			return c.Control(func(fd uintptr) {
 				_ = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+				_ = unix.SetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_FASTOPEN, tcpFastOpenQueueLen)
 			})
 		},
 	}
@@ -308,7 +466,7 @@ func (s *TCPServer) tryAcquireConn(ip string) bool {
 	defer s.mu.Unlock()
 
 	cur := s.connPerIP[ip]
-	if cur >= maxTCPConnectionsPerIP {
+	if cur >= s.MaxConnsPerIP {
 		return false
 	}
 	s.connPerIP[ip] = cur + 1
@@ -327,3 +485,11 @@ func (s *TCPServer) releaseConn(ip string) {
 	}
 	s.connPerIP[ip] = cur - 1
 }
+
+// ConnectionsPerIP returns the number of active connections per client IP.
+// The map is a copy; it is empty or nil when no connections are open.
+func (s *TCPServer) ConnectionsPerIP() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return maps.Clone(s.connPerIP)
+}
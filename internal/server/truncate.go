@@ -146,3 +146,32 @@ func skipQNAME(msg []byte, pos int) int {
 	}
 	return pos
 }
+
+// slipResponse builds the empty truncated response sent for a rate-limited
+// query that slips (see RateLimiter). It works on the raw request, which has
+// not been parsed yet: the header is echoed with QR and TC set, RD and the
+// opcode kept, and only the question section follows.
+//
+// Returns nil for anything that is not a single-question query, so nothing
+// is sent back to junk or to packets that are themselves responses.
+func slipResponse(req []byte) []byte {
+	if len(req) < dns.HeaderSize || binary.BigEndian.Uint16(req[2:4])&dns.QRFlag != 0 {
+		return nil
+	}
+	if extractQuestionCount(req) != 1 {
+		return nil
+	}
+	questionEnd := findQuestionSectionEnd(req, 1)
+	if questionEnd <= dns.HeaderSize+4 || questionEnd > len(req) {
+		return nil
+	}
+
+	flags := binary.BigEndian.Uint16(req[2:4])
+	flags = dns.QRFlag | dns.TCFlag | flags&(dns.OpcodeMask|dns.RDFlag)
+
+	out := make([]byte, dns.HeaderSize, questionEnd)
+	out[0], out[1] = req[0], req[1]
+	binary.BigEndian.PutUint16(out[2:4], flags)
+	binary.BigEndian.PutUint16(out[4:6], 1)
+	return append(out, req[dns.HeaderSize:questionEnd]...)
+}
@@ -0,0 +1,237 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// UDP socket buffer sizes. Listeners without a configured receive buffer
+// start at DefaultUDPRecvBuffer and autotune up to MaxAutotunedUDPRecvBuffer.
+const (
+	DefaultUDPRecvBuffer      = 4 << 20
+	DefaultUDPSendBuffer      = 4 << 20
+	MaxAutotunedUDPRecvBuffer = 32 << 20
+)
+
+// DefaultUDPTuneInterval is how often receive buffer autotuning checks the
+// kernel's drop counters.
+const DefaultUDPTuneInterval = 10 * time.Second
+
+// procNetUDP are the kernel's UDP socket tables, with per-socket drop
+// counters. They exist on Linux only; elsewhere kernel drops are not
+// reported and receive buffers are not autotuned.
+var procNetUDP = []string{"/proc/net/udp", "/proc/net/udp6"}
+
+// udpSocketInfo is the kernel's view of one UDP socket.
+type udpSocketInfo struct {
+	drops   uint64 // Datagrams dropped, mostly because the receive buffer was full
+	rxQueue int    // Bytes waiting in the receive buffer
+}
+
+// setSocketBuffer sets the socket buffer opt (unix.SO_RCVBUF or
+// unix.SO_SNDBUF) of conn to size bytes and returns the size the kernel
+// granted. Linux silently caps the size at net.core.rmem_max (or
+// wmem_max), so the granted size can be smaller.
+func setSocketBuffer(conn *net.UDPConn, opt, size int) (int, error) {
+	var err error
+	if opt == unix.SO_RCVBUF {
+		err = conn.SetReadBuffer(size)
+	} else {
+		err = conn.SetWriteBuffer(size)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return socketBuffer(conn, opt)
+}
+
+// socketBuffer returns the size of the socket buffer opt of conn.
+func socketBuffer(conn *net.UDPConn, opt int) (int, error) {
+	rc, err := conn.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var size int
+	var sockErr error
+	err = rc.Control(func(fd uintptr) {
+		size, sockErr = unix.GetsockoptInt(int(fd), unix.SOL_SOCKET, opt)
+	})
+	if err = errors.Join(err, sockErr); err != nil {
+		return 0, err
+	}
+	// Linux reports twice the size set, the extra half being bookkeeping
+	if runtime.GOOS == "linux" {
+		size /= 2
+	}
+	return size, nil
+}
+
+// orZero returns n, or 0 when err is set.
+func orZero(n int, err error) int {
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// socketInode returns the inode of conn's socket, which identifies it in
+// the kernel's socket tables.
+func socketInode(conn *net.UDPConn) uint64 {
+	rc, err := conn.SyscallConn()
+	if err != nil {
+		return 0
+	}
+	var st unix.Stat_t
+	var statErr error
+	if err := rc.Control(func(fd uintptr) { statErr = unix.Fstat(int(fd), &st) }); err != nil || statErr != nil {
+		return 0
+	}
+	return st.Ino
+}
+
+// readUDPSocketInfo returns the kernel's counters of every UDP socket by
+// inode, or nil where the socket tables cannot be read.
+func readUDPSocketInfo() map[uint64]udpSocketInfo {
+	var out map[uint64]udpSocketInfo
+	for _, path := range procNetUDP {
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		if out == nil {
+			out = make(map[uint64]udpSocketInfo)
+		}
+		parseProcNetUDP(f, out)
+		_ = f.Close()
+	}
+	return out
+}
+
+// parseProcNetUDP adds the sockets of a /proc/net/udp table to into. Each
+// line after the header reads:
+//
+//	sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode ref pointer drops
+func parseProcNetUDP(r io.Reader, into map[uint64]udpSocketInfo) {
+	sc := bufio.NewScanner(r)
+	sc.Scan() // Header
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 13 {
+			continue
+		}
+		inode, err := strconv.ParseUint(fields[9], 10, 64)
+		if err != nil || inode == 0 {
+			continue
+		}
+		drops, _ := strconv.ParseUint(fields[12], 10, 64)
+		var rxQueue int64
+		if _, rx, ok := strings.Cut(fields[4], ":"); ok {
+			rxQueue, _ = strconv.ParseInt(rx, 16, 64)
+		}
+		into[inode] = udpSocketInfo{drops: drops, rxQueue: int(rxQueue)}
+	}
+}
+
+// configureBuffers sets the socket buffers of a new listener and records
+// what the kernel granted, warning when it capped a size.
+func (s *UDPServer) configureBuffers(l *udpListener, warn bool) {
+	recv := s.RecvBuffer
+	if recv <= 0 {
+		recv = DefaultUDPRecvBuffer
+	}
+	send := s.SendBuffer
+	if send <= 0 {
+		send = DefaultUDPSendBuffer
+	}
+
+	if granted, err := setSocketBuffer(l.conn, unix.SO_RCVBUF, recv); err == nil {
+		l.recvBuffer.Store(int64(granted))
+		if warn && granted < recv {
+			s.warnCapped("receive", "net.core.rmem_max", recv, granted)
+		}
+	}
+	if granted, err := setSocketBuffer(l.conn, unix.SO_SNDBUF, send); err == nil {
+		l.sendBuffer = granted
+		if warn && granted < send {
+			s.warnCapped("send", "net.core.wmem_max", send, granted)
+		}
+	}
+}
+
+// warnCapped logs that the kernel granted a smaller socket buffer than
+// requested.
+func (s *UDPServer) warnCapped(buffer, sysctl string, requested, granted int) {
+	if s.Logger != nil {
+		s.Logger.Warn("udp socket "+buffer+" buffer capped by the kernel; raise "+sysctl+" to allow more",
+			"requested", requested,
+			"granted", granted,
+		)
+	}
+}
+
+// autotune doubles the receive buffer of listeners whose socket dropped
+// datagrams since the last check, up to MaxAutotunedUDPRecvBuffer or the
+// kernel's cap. It returns at once where kernel drops cannot be read.
+func (s *UDPServer) autotune(ctx context.Context, listeners []*udpListener) {
+	if readUDPSocketInfo() == nil {
+		return
+	}
+	interval := s.TuneInterval
+	if interval <= 0 {
+		interval = DefaultUDPTuneInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	lastDrops := make([]uint64, len(listeners))
+	capped := make([]bool, len(listeners))
+	for i, l := range listeners {
+		capped[i] = l.recvBuffer.Load() < DefaultUDPRecvBuffer
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info := readUDPSocketInfo()
+		for i, l := range listeners {
+			drops := info[l.inode].drops
+			dropped := drops > lastDrops[i]
+			lastDrops[i] = drops
+
+			current := int(l.recvBuffer.Load())
+			if !dropped || capped[i] || current >= MaxAutotunedUDPRecvBuffer {
+				continue
+			}
+			size := min(current*2, MaxAutotunedUDPRecvBuffer)
+			granted, err := setSocketBuffer(l.conn, unix.SO_RCVBUF, size)
+			if err != nil {
+				continue
+			}
+			l.recvBuffer.Store(int64(granted))
+			if granted < size {
+				capped[i] = true
+				s.warnCapped("receive", "net.core.rmem_max", size, granted)
+			}
+			if s.Logger != nil && granted > current {
+				s.Logger.Info("udp receive buffer grown after kernel drops",
+					"listener", i,
+					"kernel_drops", drops,
+					"bytes", granted,
+				)
+			}
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"net"
+	"net/netip"
+	"sync"
+
+	"github.com/jroosing/hydradns/internal/dns"
+)
+
+// udpQueryKey identifies a query on one UDP socket: the client address and
+// port, and the transaction ID and question as received. With the socket
+// this is the query's 5-tuple, so a stub resolver retransmitting an
+// unanswered query produces the same key.
+type udpQueryKey struct {
+	peer     netip.AddrPort
+	question string // Transaction ID followed by the question section
+}
+
+// udpQueryKeyOf returns the key of a query datagram. It reports false for
+// messages that are not a plain query with one question, which are never
+// treated as duplicates.
+func udpQueryKeyOf(msg []byte, peer *net.UDPAddr) (udpQueryKey, bool) {
+	// QR and OPCODE must be zero (a standard query), QDCOUNT one
+	if len(msg) < dns.HeaderSize || msg[2]&0xF8 != 0 || msg[4] != 0 || msg[5] != 1 {
+		return udpQueryKey{}, false
+	}
+	// Walk the question name; queries have no reason to compress it
+	off := dns.HeaderSize
+	for off < len(msg) && msg[off] != 0 {
+		if msg[off]&0xC0 != 0 {
+			return udpQueryKey{}, false
+		}
+		off += 1 + int(msg[off])
+	}
+	end := off + 1 + 4 // Root label, QTYPE, QCLASS
+	if end > len(msg) {
+		return udpQueryKey{}, false
+	}
+	question := make([]byte, 0, 2+end-dns.HeaderSize)
+	question = append(question, msg[:2]...)
+	question = append(question, msg[dns.HeaderSize:end]...)
+	return udpQueryKey{peer: peer.AddrPort(), question: string(question)}, true
+}
+
+// pendingQuery is a query being resolved.
+type pendingQuery struct {
+	attached int // Retransmissions waiting for its answer
+}
+
+// udpPending tracks the queries a UDP socket is resolving, so
+// retransmissions can be attached to them instead of being resolved again.
+type udpPending struct {
+	mu      sync.Mutex
+	queries map[udpQueryKey]*pendingQuery
+}
+
+// begin starts resolving the query with key and returns it. When the same
+// query is already being resolved, the retransmission is attached to it
+// and begin returns nil.
+func (u *udpPending) begin(key udpQueryKey) *pendingQuery {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if q, ok := u.queries[key]; ok {
+		q.attached++
+		return nil
+	}
+	if u.queries == nil {
+		u.queries = make(map[udpQueryKey]*pendingQuery)
+	}
+	q := &pendingQuery{}
+	u.queries[key] = q
+	return q
+}
+
+// end finishes q and returns the number of retransmissions attached to
+// it. Later calls for the same q return 0.
+func (u *udpPending) end(key udpQueryKey, q *pendingQuery) int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.queries[key] != q {
+		return 0
+	}
+	delete(u.queries, key)
+	return q.attached
+}
@@ -8,19 +8,16 @@ import (
 	"net/netip"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"golang.org/x/sys/unix"
 
+	"github.com/jroosing/hydradns/internal/capture"
 	"github.com/jroosing/hydradns/internal/dns"
 	"github.com/jroosing/hydradns/internal/pool"
-)
-
-// Socket buffer sizes for high throughput (4MB each).
-const (
-	socketRecvBufferSize = 4 * 1024 * 1024
-	socketSendBufferSize = 4 * 1024 * 1024
+	"github.com/jroosing/hydradns/internal/resolvers"
 )
 
 // DefaultWorkersPerSocket is the default number of worker goroutines per UDP socket.
@@ -41,26 +38,72 @@ var bufferPool = pool.New(func() *[]byte {
 //   - Buffer pooling to reduce GC pressure under load
 //   - Non-blocking receive path (drops packets if workers are busy)
 //   - Rate limiting per source IP (using netip.Addr to avoid allocations)
+//   - Retransmitted queries attached to the one already being resolved
 //   - EDNS-aware response truncation
 //   - Graceful shutdown with timeout
-//   - Large socket buffers for burst handling
+//   - Large socket buffers for burst handling, autotuned on kernel drops
 //
 // Goroutine Lifecycle:
 //
-// For each CPU core, Run() spawns:
+// For each listener socket (one per CPU core unless Listeners is set), Run() spawns:
 //   - 1 receiver goroutine: Reads incoming UDP packets from socket
 //   - N worker goroutines: Process packets and write responses (N = WorkersPerSocket)
 //
 // All goroutines share the same context and exit when it is cancelled.
 // Graceful shutdown waits up to 5 seconds for in-flight queries.
+//
+// Per-listener counters are available from ListenerStats while running.
 type UDPServer struct {
 	Logger           *slog.Logger  // Optional logger
 	Handler          *QueryHandler // Query processor
 	Limiter          *RateLimiter  // Optional per-IP rate limiter
 	WorkersPerSocket int           // Worker goroutines per socket (default 1024)
+	Listeners        int           // SO_REUSEPORT sockets to open (default: runtime.NumCPU())
+	// RecvBuffer is the SO_RCVBUF size in bytes. 0 autotunes it: sockets
+	// start at DefaultUDPRecvBuffer, and one that the kernel drops
+	// datagrams for has it doubled, up to MaxAutotunedUDPRecvBuffer.
+	RecvBuffer   int
+	SendBuffer   int               // SO_SNDBUF size in bytes (default DefaultUDPSendBuffer)
+	TuneInterval time.Duration     // How often autotuning checks for kernel drops (default DefaultUDPTuneInterval)
+	Capture      *capture.Recorder // Optional packet capture of queries and responses
+
+	listeners atomic.Pointer[[]*udpListener] // Set once all sockets are open
+	wg        sync.WaitGroup                 // Tracks receiver and worker goroutines
+}
+
+// udpListener is one UDP socket with its packet queue and counters.
+type udpListener struct {
+	conn    *net.UDPConn
+	queue   chan packet
+	pending udpPending // Queries being resolved, for duplicate suppression
+	inode   uint64     // Identifies the socket in the kernel's tables
+
+	recvBuffer atomic.Int64 // SO_RCVBUF size granted by the kernel
+	sendBuffer int          // SO_SNDBUF size granted by the kernel
+
+	read        atomic.Uint64 // Datagrams received
+	handled     atomic.Uint64 // Datagrams processed by a worker
+	dropped     atomic.Uint64 // Datagrams dropped because the queue was full
+	rateLimited atomic.Uint64 // Datagrams refused by the rate limiter
+	slipped     atomic.Uint64 // Refused datagrams answered with TC=1
+	duplicates  atomic.Uint64 // Retransmissions attached to a query being resolved
+}
 
-	conns []*net.UDPConn // UDP sockets (one per CPU core)
-	wg    sync.WaitGroup // Tracks receiver and worker goroutines
+// UDPListenerStats is a point-in-time snapshot of one UDP listener's counters.
+type UDPListenerStats struct {
+	Listener       int    // Listener index
+	PacketsRead    uint64 // Datagrams received from the socket
+	PacketsHandled uint64 // Datagrams processed by a worker
+	PacketsDropped uint64 // Datagrams dropped because all workers were busy
+	RateLimited    uint64 // Datagrams refused by the rate limiter
+	Slipped        uint64 // Refused datagrams answered with TC=1 (included in RateLimited)
+	Duplicates     uint64 // Retransmissions answered with the original query (included in PacketsHandled)
+	QueueDepth     int    // Datagrams waiting for a worker
+	QueueCapacity  int    // Size of the packet queue
+	KernelDrops    uint64 // Datagrams the kernel dropped, mostly for a full receive buffer (Linux only)
+	RecvQueueBytes int    // Bytes waiting in the socket receive buffer (Linux only)
+	RecvBuffer     int    // Socket receive buffer size in bytes
+	SendBuffer     int    // Socket send buffer size in bytes
 }
 
 // packet represents a received UDP packet pending processing.
@@ -74,7 +117,7 @@ type packet struct {
 // Each socket has its own fixed pool of worker goroutines.
 //
 // Goroutine Behavior:
-//   - Spawns 1 receiver + N workers per CPU core (total = NumCPU * (1 + WorkersPerSocket))
+//   - Spawns 1 receiver + N workers per socket (total = Listeners * (1 + WorkersPerSocket))
 //   - All goroutines read context and exit when ctx is cancelled
 //   - Close() or context cancellation triggers graceful shutdown
 //
@@ -84,41 +127,35 @@ func (s *UDPServer) Run(ctx context.Context, addr string) error {
 		s.WorkersPerSocket = DefaultWorkersPerSocket
 	}
 
-	socketCount := runtime.NumCPU()
-	s.conns = make([]*net.UDPConn, 0, socketCount)
+	socketCount := s.Listeners
+	if socketCount <= 0 {
+		socketCount = runtime.NumCPU()
+	}
+	listeners := make([]*udpListener, 0, socketCount)
 
 	for range socketCount {
 		conn, err := listenReusePort(addr)
 		if err != nil {
 			// Close any already-opened sockets
-			for _, c := range s.conns {
-				_ = c.Close()
+			for _, l := range listeners {
+				_ = l.conn.Close()
 			}
 			return err
 		}
 
-		// Set large socket buffers for burst handling
-		_ = conn.SetReadBuffer(socketRecvBufferSize)
-		_ = conn.SetWriteBuffer(socketSendBufferSize)
-
-		s.conns = append(s.conns, conn)
-
 		// Buffered channel for packet handoff (2x workers for headroom)
-		packetCh := make(chan packet, s.WorkersPerSocket*2)
-		c := conn
-		ch := packetCh
-
-		// Receiver goroutine (never blocks on worker availability)
-		s.wg.Go(func() {
-			s.recvLoop(ctx, c, ch)
-		})
+		l := &udpListener{conn: conn, queue: make(chan packet, s.WorkersPerSocket*2), inode: socketInode(conn)}
+		// Set large socket buffers for burst handling, warning once if capped
+		s.configureBuffers(l, len(listeners) == 0)
+		listeners = append(listeners, l)
+	}
+	s.listeners.Store(&listeners)
 
-		// Fixed worker pool for this socket
-		for range s.WorkersPerSocket {
-			s.wg.Go(func() {
-				s.workerLoop(ctx, c, ch)
-			})
-		}
+	for _, l := range listeners {
+		s.startListener(ctx, l)
+	}
+	if s.RecvBuffer <= 0 {
+		s.wg.Go(func() { s.autotune(ctx, listeners) })
 	}
 
 	<-ctx.Done()
@@ -132,23 +169,57 @@ func (s *UDPServer) RunOnConn(ctx context.Context, conn *net.UDPConn) error {
 		s.WorkersPerSocket = DefaultWorkersPerSocket
 	}
 
-	s.conns = []*net.UDPConn{conn}
-	packetCh := make(chan packet, s.WorkersPerSocket)
-	c := conn
-	ch := packetCh
+	l := &udpListener{conn: conn, queue: make(chan packet, s.WorkersPerSocket), inode: socketInode(conn)}
+	l.recvBuffer.Store(int64(orZero(socketBuffer(conn, unix.SO_RCVBUF))))
+	l.sendBuffer = orZero(socketBuffer(conn, unix.SO_SNDBUF))
+	s.listeners.Store(&[]*udpListener{l})
+	s.startListener(ctx, l)
+
+	<-ctx.Done()
+	return nil
+}
 
+// startListener starts the receiver goroutine (which never blocks on worker
+// availability) and the fixed worker pool for one socket.
+func (s *UDPServer) startListener(ctx context.Context, l *udpListener) {
 	s.wg.Go(func() {
-		s.recvLoop(ctx, c, ch)
+		s.recvLoop(ctx, l)
 	})
-
 	for range s.WorkersPerSocket {
 		s.wg.Go(func() {
-			s.workerLoop(ctx, c, ch)
+			s.workerLoop(ctx, l)
 		})
 	}
+}
 
-	<-ctx.Done()
-	return nil
+// ListenerStats returns a snapshot of the per-listener counters, or nil if
+// the server has not started.
+func (s *UDPServer) ListenerStats() []UDPListenerStats {
+	lp := s.listeners.Load()
+	if lp == nil {
+		return nil
+	}
+	kernel := readUDPSocketInfo()
+	out := make([]UDPListenerStats, len(*lp))
+	for i, l := range *lp {
+		info := kernel[l.inode]
+		out[i] = UDPListenerStats{
+			Listener:       i,
+			PacketsRead:    l.read.Load(),
+			PacketsHandled: l.handled.Load(),
+			PacketsDropped: l.dropped.Load(),
+			RateLimited:    l.rateLimited.Load(),
+			Slipped:        l.slipped.Load(),
+			Duplicates:     l.duplicates.Load(),
+			QueueDepth:     len(l.queue),
+			QueueCapacity:  cap(l.queue),
+			KernelDrops:    info.drops,
+			RecvQueueBytes: info.rxQueue,
+			RecvBuffer:     int(l.recvBuffer.Load()),
+			SendBuffer:     l.sendBuffer,
+		}
+	}
+	return out
 }
 
 // recvLoop reads packets from the socket and dispatches to workers.
@@ -158,12 +229,12 @@ func (s *UDPServer) RunOnConn(ctx context.Context, conn *net.UDPConn) error {
 // - Context is cancelled (server shutdown)
 // - Socket is closed
 // Cleanup: Returns buffers to pool, socket closed by caller.
-func (s *UDPServer) recvLoop(ctx context.Context, conn *net.UDPConn, out chan<- packet) {
+func (s *UDPServer) recvLoop(ctx context.Context, l *udpListener) {
 	for {
 		bufPtr := bufferPool.Get()
 		buf := *bufPtr
 
-		n, peer, err := conn.ReadFromUDP(buf)
+		n, peer, err := l.conn.ReadFromUDP(buf)
 		if err != nil {
 			bufferPool.Put(bufPtr)
 			// Check if we're shutting down
@@ -173,11 +244,19 @@ func (s *UDPServer) recvLoop(ctx context.Context, conn *net.UDPConn, out chan<-
 			// Socket closed or other error
 			return
 		}
+		l.read.Add(1)
 
 		// Apply rate limiting using netip.Addr to avoid string allocation
 		if s.Limiter != nil {
-			ip, ok := netipAddrFromUDPAddr(peer)
-			if !ok || !s.Limiter.AllowAddr(ip) {
+			admission := AdmitDrop
+			if ip, ok := netipAddrFromUDPAddr(peer); ok {
+				admission = s.Limiter.AdmitAddr(ip)
+			}
+			if admission != AdmitAllow {
+				l.rateLimited.Add(1)
+				if admission == AdmitSlip {
+					s.slip(l, buf[:n], peer)
+				}
 				bufferPool.Put(bufPtr)
 				continue
 			}
@@ -185,15 +264,28 @@ func (s *UDPServer) recvLoop(ctx context.Context, conn *net.UDPConn, out chan<-
 
 		// Non-blocking dispatch to worker pool
 		select {
-		case out <- packet{bufPtr, n, peer}:
+		case l.queue <- packet{bufPtr, n, peer}:
 			// Successfully queued
 		default:
 			// All workers busy, drop packet to keep receive path fast
+			l.dropped.Add(1)
 			bufferPool.Put(bufPtr)
 		}
 	}
 }
 
+// slip answers a rate-limited query with an empty truncated response.
+// It runs on the receive path, so it does no parsing beyond the header and
+// question.
+func (s *UDPServer) slip(l *udpListener, req []byte, peer *net.UDPAddr) {
+	resp := slipResponse(req)
+	if resp == nil {
+		return
+	}
+	l.slipped.Add(1)
+	_, _ = l.conn.WriteToUDP(resp, peer)
+}
+
 // workerLoop processes packets from the channel.
 //
 // Goroutine lifecycle: WorkersPerSocket instances started per UDP socket in Run().
@@ -201,22 +293,33 @@ func (s *UDPServer) recvLoop(ctx context.Context, conn *net.UDPConn, out chan<-
 // - Context is cancelled (server shutdown)
 // - Packet channel is closed
 // Cleanup: Returns packet buffers to pool after processing.
-func (s *UDPServer) workerLoop(ctx context.Context, conn *net.UDPConn, in <-chan packet) {
+func (s *UDPServer) workerLoop(ctx context.Context, l *udpListener) {
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case pkt, ok := <-in:
+		case pkt, ok := <-l.queue:
 			if !ok {
 				return
 			}
-			s.handlePacket(ctx, conn, pkt)
+			s.handlePacket(ctx, l, pkt)
+			l.handled.Add(1)
 		}
 	}
 }
 
 // handlePacket processes a single DNS request.
-func (s *UDPServer) handlePacket(ctx context.Context, conn *net.UDPConn, p packet) {
+// A panic is recovered so the worker keeps serving; the packet is dropped.
+//
+// A retransmission of a query that is still being resolved (same client
+// address and port, transaction ID, and question) is not resolved again:
+// it is attached to the original, and the answer is sent once for each.
+func (s *UDPServer) handlePacket(ctx context.Context, l *udpListener, p packet) {
+	defer func() {
+		if v := recover(); v != nil {
+			reportPanic(ctx, s.Logger, handlerStats(s.Handler), v, "transport", "udp", "client", p.peer.String())
+		}
+	}()
 	defer bufferPool.Put(p.bufPtr)
 
 	if s.Handler == nil {
@@ -224,9 +327,20 @@ func (s *UDPServer) handlePacket(ctx context.Context, conn *net.UDPConn, p packe
 	}
 
 	payload := (*p.bufPtr)[:p.n]
-	// Extract IP from peer address to avoid String() allocation
-	peerIP := p.peer.IP.String()
-	res := s.Handler.Handle(ctx, "udp", peerIP, payload)
+	s.captureMessage(l, payload, p.peer, false)
+	key, dedup := udpQueryKeyOf(payload, p.peer)
+	var pending *pendingQuery
+	if dedup {
+		if pending = l.pending.begin(key); pending == nil {
+			l.duplicates.Add(1)
+			return
+		}
+		// Also ends the query if the handler panics
+		defer l.pending.end(key, pending)
+	}
+
+	client := resolvers.ClientInfo{Addr: p.peer.AddrPort().Addr(), Transport: "udp"}
+	res := s.Handler.Handle(resolvers.WithClientInfo(ctx, client), payload)
 	if len(res.ResponseBytes) == 0 {
 		return
 	}
@@ -238,15 +352,39 @@ func (s *UDPServer) handlePacket(ctx context.Context, conn *net.UDPConn, p packe
 		resp = truncateUDPResponse(resp, maxSize)
 	}
 
-	_, _ = conn.WriteToUDP(resp, p.peer)
+	// Retransmissions that arrived while resolving get the answer too
+	copies := 1
+	if pending != nil {
+		copies += l.pending.end(key, pending)
+	}
+	for range copies {
+		_, _ = l.conn.WriteToUDP(resp, p.peer)
+		s.captureMessage(l, resp, p.peer, true)
+	}
+}
+
+// captureMessage records msg, received from peer or sent to it when sent
+// is set, if a packet capture runs.
+func (s *UDPServer) captureMessage(l *udpListener, msg []byte, peer *net.UDPAddr, sent bool) {
+	if !s.Capture.Capturing() {
+		return
+	}
+	local, _ := l.conn.LocalAddr().(*net.UDPAddr)
+	src, dst := peer.AddrPort(), local.AddrPort()
+	if sent {
+		src, dst = dst, src
+	}
+	s.Capture.RecordClient("udp", peer.AddrPort().Addr(), src, dst, msg)
 }
 
 // Stop gracefully shuts down the UDP server.
 // Closes all sockets and waits up to the specified timeout for goroutines to exit.
 func (s *UDPServer) Stop(timeout time.Duration) error {
 	// Close all sockets to unblock receive loops
-	for _, c := range s.conns {
-		_ = c.Close()
+	if lp := s.listeners.Load(); lp != nil {
+		for _, l := range *lp {
+			_ = l.conn.Close()
+		}
 	}
 
 	if timeout <= 0 {
@@ -296,13 +434,16 @@ func netipAddrFromUDPAddr(addr *net.UDPAddr) (netip.Addr, bool) {
 //
 // Implementation:
 //
-// HydraDNS creates one UDP socket per CPU core, each with WorkersPerSocket
-// goroutines handling packets. This gives optimal throughput on multi-core systems.
+// HydraDNS creates one UDP socket per CPU core by default, each with
+// WorkersPerSocket goroutines handling packets. This gives optimal throughput
+// on multi-core systems; UDPServer.Listeners pins the count instead, e.g. to
+// match the cores of one NUMA node.
 //
 // Large Socket Buffers:
 //
-// Each socket has 4MB send and receive buffers for burst handling. This allows
-// the kernel to queue incoming packets while userspace is busy processing.
+// Each socket gets large send and receive buffers (see UDPServer.RecvBuffer)
+// for burst handling. This allows the kernel to queue incoming packets while
+// userspace is busy processing.
 func listenReusePort(addr string) (*net.UDPConn, error) {
 	udpAddr, err := net.ResolveUDPAddr("udp", addr)
 	if err != nil {
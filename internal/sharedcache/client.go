@@ -0,0 +1,216 @@
+// Package sharedcache implements minimal redis and memcached clients for
+// the response cache that HydraDNS nodes share (see
+// resolvers.ForwardingResolver.SetSharedCache).
+//
+// Only what the cache needs is supported: GET, SET with an expiry, DELETE,
+// and redis AUTH. Connections are pooled and every call is bounded by the
+// configured timeout, so an unreachable server costs a cache miss, not a
+// stalled query.
+package sharedcache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/jroosing/hydradns/internal/config"
+)
+
+// maxIdleConns is the number of idle connections kept for reuse.
+const maxIdleConns = 16
+
+// maxValueBytes bounds the values read from the server. Cached DNS
+// responses are at most 64 KiB; anything larger is not ours.
+const maxValueBytes = 1 << 20
+
+// ErrClosed is returned by calls on a closed Client.
+var ErrClosed = errors.New("shared cache client closed")
+
+// protocol speaks one server's wire protocol over a connection.
+type protocol interface {
+	// auth authenticates a new connection.
+	auth(c *conn, password string) error
+	get(c *conn, key string) ([]byte, error)
+	set(c *conn, key string, value []byte, ttl time.Duration) error
+	del(c *conn, keys []string) error
+	// key turns a cache key into one the server accepts.
+	key(prefix, key string) string
+}
+
+// conn is a pooled connection to the server.
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+// Client is a redis or memcached client. It is safe for concurrent use and
+// implements resolvers.SharedCache.
+type Client struct {
+	backend  string
+	proto    protocol
+	addr     string
+	password string
+	timeout  time.Duration
+	prefix   string
+
+	mu     sync.Mutex
+	idle   []*conn
+	closed bool
+}
+
+// New creates a client for cfg, which must have been normalized by
+// config.Validate. No connection is made until the first call.
+func New(cfg config.SharedCacheConfig) (*Client, error) {
+	c := &Client{
+		backend:  cfg.Backend,
+		addr:     cfg.Address,
+		password: cfg.Password,
+		prefix:   cfg.KeyPrefix,
+	}
+	switch cfg.Backend {
+	case config.SharedCacheRedis:
+		c.proto = redis{}
+	case config.SharedCacheMemcached:
+		c.proto = memcached{}
+	default:
+		return nil, fmt.Errorf("unknown shared cache backend %q", cfg.Backend)
+	}
+	timeout, err := time.ParseDuration(cfg.Timeout)
+	if err != nil || timeout <= 0 {
+		return nil, fmt.Errorf("invalid shared cache timeout %q", cfg.Timeout)
+	}
+	c.timeout = timeout
+	return c, nil
+}
+
+// Backend returns config.SharedCacheRedis or config.SharedCacheMemcached.
+func (c *Client) Backend() string {
+	return c.backend
+}
+
+// Get returns the value stored under key, or nil without an error when
+// there is none.
+func (c *Client) Get(ctx context.Context, key string) ([]byte, error) {
+	var value []byte
+	err := c.do(ctx, func(cn *conn) error {
+		var err error
+		value, err = c.proto.get(cn, c.proto.key(c.prefix, key))
+		return err
+	})
+	return value, err
+}
+
+// Set stores value under key for ttl. Values with a TTL under a
+// millisecond are not stored.
+func (c *Client) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if ttl < time.Millisecond {
+		return nil
+	}
+	return c.do(ctx, func(cn *conn) error {
+		return c.proto.set(cn, c.proto.key(c.prefix, key), value, ttl)
+	})
+}
+
+// Delete removes the values stored under keys. Missing keys are not an
+// error.
+func (c *Client) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	serverKeys := make([]string, len(keys))
+	for i, k := range keys {
+		serverKeys[i] = c.proto.key(c.prefix, k)
+	}
+	return c.do(ctx, func(cn *conn) error {
+		return c.proto.del(cn, serverKeys)
+	})
+}
+
+// Close closes the idle connections. Calls in progress finish and close
+// their connection; later calls return ErrClosed.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	for _, cn := range c.idle {
+		_ = cn.Close()
+	}
+	c.idle = nil
+	return nil
+}
+
+// do runs fn on a pooled connection with a deadline of the timeout or
+// ctx's deadline, whichever comes first. The connection is dropped after
+// an error, since the protocol state is then unknown.
+func (c *Client) do(ctx context.Context, fn func(*conn) error) error {
+	deadline := time.Now().Add(c.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+
+	cn, err := c.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	if err := cn.SetDeadline(deadline); err != nil {
+		_ = cn.Close()
+		return err
+	}
+	if err := fn(cn); err != nil {
+		_ = cn.Close()
+		return err
+	}
+	c.release(cn)
+	return nil
+}
+
+// acquire returns an idle connection or dials a new one.
+func (c *Client) acquire(ctx context.Context) (*conn, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, ErrClosed
+	}
+	if n := len(c.idle); n > 0 {
+		cn := c.idle[n-1]
+		c.idle = c.idle[:n-1]
+		c.mu.Unlock()
+		return cn, nil
+	}
+	c.mu.Unlock()
+
+	var d net.Dialer
+	nc, err := d.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, err
+	}
+	cn := &conn{Conn: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+	if c.password != "" {
+		if deadline, ok := ctx.Deadline(); ok {
+			_ = nc.SetDeadline(deadline)
+		}
+		if err := c.proto.auth(cn, c.password); err != nil {
+			_ = nc.Close()
+			return nil, fmt.Errorf("shared cache authentication failed: %w", err)
+		}
+	}
+	return cn, nil
+}
+
+// release returns a healthy connection to the pool.
+func (c *Client) release(cn *conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed || len(c.idle) >= maxIdleConns {
+		_ = cn.Close()
+		return
+	}
+	c.idle = append(c.idle, cn)
+}
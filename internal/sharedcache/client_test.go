@@ -0,0 +1,405 @@
+package sharedcache_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jroosing/hydradns/internal/config"
+	"github.com/jroosing/hydradns/internal/sharedcache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ============================================================================
+// Fake servers
+// ============================================================================
+
+// fakeStore is the key space of a fake cache server.
+type fakeStore struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+	ttls    map[string]string // Expiry argument of the last store of each key
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{entries: map[string][]byte{}, ttls: map[string]string{}}
+}
+
+func (s *fakeStore) get(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.entries[key]
+	return v, ok
+}
+
+func (s *fakeStore) set(key string, value []byte, ttl string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = value
+	s.ttls[key] = ttl
+}
+
+// del deletes key and reports whether it was stored.
+func (s *fakeStore) del(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.entries[key]
+	delete(s.entries, key)
+	return ok
+}
+
+func (s *fakeStore) ttl(key string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ttls[key]
+}
+
+func (s *fakeStore) keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0, len(s.entries))
+	for k := range s.entries {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// serve accepts connections on a loopback listener and runs handle on
+// each until the test ends. It returns the listener address.
+func serve(t *testing.T, handle func(*bufio.Reader, net.Conn)) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var conns []net.Conn
+	t.Cleanup(func() {
+		_ = ln.Close()
+		mu.Lock()
+		for _, c := range conns {
+			_ = c.Close()
+		}
+		mu.Unlock()
+		wg.Wait()
+	})
+	wg.Go(func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns = append(conns, c)
+			mu.Unlock()
+			wg.Go(func() { handle(bufio.NewReader(c), c) })
+		}
+	})
+	return ln.Addr().String()
+}
+
+// startFakeRedis serves GET, SET with PX, DEL, and AUTH with password, if set.
+func startFakeRedis(t *testing.T, store *fakeStore, password string) string {
+	t.Helper()
+	return serve(t, func(r *bufio.Reader, c net.Conn) {
+		authed := password == ""
+		for {
+			args, err := readRESPArray(r)
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(args[0])
+			switch {
+			case cmd == "AUTH":
+				if args[1] != password {
+					_, _ = io.WriteString(c, "-WRONGPASS invalid password\r\n")
+					continue
+				}
+				authed = true
+				_, _ = io.WriteString(c, "+OK\r\n")
+			case !authed:
+				_, _ = io.WriteString(c, "-NOAUTH Authentication required.\r\n")
+			case cmd == "GET":
+				v, ok := store.get(args[1])
+				if !ok {
+					_, _ = io.WriteString(c, "$-1\r\n")
+					continue
+				}
+				_, _ = fmt.Fprintf(c, "$%d\r\n%s\r\n", len(v), v)
+			case cmd == "SET" && len(args) == 5 && strings.EqualFold(args[3], "PX"):
+				store.set(args[1], []byte(args[2]), args[4])
+				_, _ = io.WriteString(c, "+OK\r\n")
+			case cmd == "DEL":
+				n := 0
+				for _, k := range args[1:] {
+					if store.del(k) {
+						n++
+					}
+				}
+				_, _ = fmt.Fprintf(c, ":%d\r\n", n)
+			default:
+				_, _ = io.WriteString(c, "-ERR unknown command\r\n")
+			}
+		}
+	})
+}
+
+func readRESPArray(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		if line, err = r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+// startFakeMemcached serves the text protocol's get, set, and delete.
+func startFakeMemcached(t *testing.T, store *fakeStore) string {
+	t.Helper()
+	return serve(t, func(r *bufio.Reader, c net.Conn) {
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			fields := strings.Fields(line)
+			switch {
+			case len(fields) == 2 && fields[0] == "get":
+				if v, ok := store.get(fields[1]); ok {
+					_, _ = fmt.Fprintf(c, "VALUE %s 0 %d\r\n%s\r\n", fields[1], len(v), v)
+				}
+				_, _ = io.WriteString(c, "END\r\n")
+			case len(fields) == 5 && fields[0] == "set":
+				size, _ := strconv.Atoi(fields[4])
+				buf := make([]byte, size+2)
+				if _, err := io.ReadFull(r, buf); err != nil {
+					return
+				}
+				store.set(fields[1], buf[:size], fields[3])
+				_, _ = io.WriteString(c, "STORED\r\n")
+			case len(fields) == 2 && fields[0] == "delete":
+				if store.del(fields[1]) {
+					_, _ = io.WriteString(c, "DELETED\r\n")
+				} else {
+					_, _ = io.WriteString(c, "NOT_FOUND\r\n")
+				}
+			default:
+				_, _ = io.WriteString(c, "ERROR\r\n")
+			}
+		}
+	})
+}
+
+func newClient(t *testing.T, backend, addr, password string) *sharedcache.Client {
+	t.Helper()
+	c, err := sharedcache.New(config.SharedCacheConfig{
+		Backend:   backend,
+		Address:   addr,
+		Password:  password,
+		Timeout:   "200ms",
+		KeyPrefix: "hydradns",
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+// ============================================================================
+// Redis
+// ============================================================================
+
+func TestRedis_GetSet(t *testing.T) {
+	store := newFakeStore()
+	c := newClient(t, config.SharedCacheRedis, startFakeRedis(t, store, ""), "")
+	ctx := context.Background()
+
+	v, err := c.Get(ctx, "v1:1.1.1.1:1:1:example.com")
+	require.NoError(t, err)
+	assert.Nil(t, v, "a miss is not an error")
+
+	value := []byte("binary\r\n\x00value")
+	require.NoError(t, c.Set(ctx, "v1:1.1.1.1:1:1:example.com", value, 90*time.Second))
+	stored, _ := store.get("hydradns:v1:1.1.1.1:1:1:example.com")
+	assert.Equal(t, value, stored)
+	assert.Equal(t, "90000", store.ttl("hydradns:v1:1.1.1.1:1:1:example.com"))
+
+	v, err = c.Get(ctx, "v1:1.1.1.1:1:1:example.com")
+	require.NoError(t, err)
+	assert.Equal(t, value, v)
+}
+
+func TestRedis_Delete(t *testing.T) {
+	store := newFakeStore()
+	c := newClient(t, config.SharedCacheRedis, startFakeRedis(t, store, ""), "")
+	ctx := context.Background()
+
+	require.NoError(t, c.Set(ctx, "a", []byte("1"), time.Minute))
+	require.NoError(t, c.Set(ctx, "b", []byte("2"), time.Minute))
+	require.NoError(t, c.Delete(ctx, "a", "missing"))
+
+	assert.Equal(t, []string{"hydradns:b"}, store.keys())
+}
+
+func TestRedis_Auth(t *testing.T) {
+	store := newFakeStore()
+	addr := startFakeRedis(t, store, "s3cret")
+
+	c := newClient(t, config.SharedCacheRedis, addr, "s3cret")
+	require.NoError(t, c.Set(context.Background(), "k", []byte("v"), time.Minute))
+
+	wrong := newClient(t, config.SharedCacheRedis, addr, "guess")
+	_, err := wrong.Get(context.Background(), "k")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "WRONGPASS")
+}
+
+func TestRedis_ErrorReply(t *testing.T) {
+	addr := serve(t, func(r *bufio.Reader, c net.Conn) {
+		for {
+			if _, err := readRESPArray(r); err != nil {
+				return
+			}
+			_, _ = io.WriteString(c, "-OOM command not allowed\r\n")
+		}
+	})
+	c := newClient(t, config.SharedCacheRedis, addr, "")
+	err := c.Set(context.Background(), "k", []byte("v"), time.Minute)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "OOM")
+}
+
+// ============================================================================
+// Memcached
+// ============================================================================
+
+func TestMemcached_GetSet(t *testing.T) {
+	store := newFakeStore()
+	c := newClient(t, config.SharedCacheMemcached, startFakeMemcached(t, store), "")
+	ctx := context.Background()
+
+	v, err := c.Get(ctx, "v1:9.9.9.9:1:28:example.org")
+	require.NoError(t, err)
+	assert.Nil(t, v)
+
+	value := []byte("line\r\nbreaks\x00")
+	require.NoError(t, c.Set(ctx, "v1:9.9.9.9:1:28:example.org", value, 1500*time.Millisecond))
+	assert.Equal(t, "2", store.ttl("hydradns:v1:9.9.9.9:1:28:example.org"), "expiry rounds up to whole seconds")
+
+	v, err = c.Get(ctx, "v1:9.9.9.9:1:28:example.org")
+	require.NoError(t, err)
+	assert.Equal(t, value, v)
+}
+
+func TestMemcached_Delete(t *testing.T) {
+	store := newFakeStore()
+	c := newClient(t, config.SharedCacheMemcached, startFakeMemcached(t, store), "")
+	ctx := context.Background()
+
+	require.NoError(t, c.Set(ctx, "a", []byte("1"), time.Minute))
+	require.NoError(t, c.Set(ctx, "b", []byte("2"), time.Minute))
+	require.NoError(t, c.Delete(ctx, "a", "missing"), "a missing key is not an error")
+
+	assert.Equal(t, []string{"hydradns:b"}, store.keys())
+	v, err := c.Get(ctx, "b")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("2"), v, "the connection is still in sync after the pipelined deletes")
+}
+
+func TestMemcached_HashesUnsafeKeys(t *testing.T) {
+	store := newFakeStore()
+	c := newClient(t, config.SharedCacheMemcached, startFakeMemcached(t, store), "")
+	ctx := context.Background()
+
+	keys := []string{"v1:9.9.9.9:1:1:with space.example", "v1:" + strings.Repeat("a", 300)}
+	for _, key := range keys {
+		require.NoError(t, c.Set(ctx, key, []byte(key), time.Minute))
+		v, err := c.Get(ctx, key)
+		require.NoError(t, err)
+		assert.Equal(t, []byte(key), v)
+	}
+	for _, k := range store.keys() {
+		assert.True(t, strings.HasPrefix(k, "hydradns:sha256:"), k)
+		assert.LessOrEqual(t, len(k), 250)
+	}
+}
+
+// ============================================================================
+// Client
+// ============================================================================
+
+func TestClient_TimesOutOnSilentServer(t *testing.T) {
+	addr := serve(t, func(r *bufio.Reader, _ net.Conn) {
+		_, _ = io.Copy(io.Discard, r)
+	})
+	c := newClient(t, config.SharedCacheRedis, addr, "")
+
+	start := time.Now()
+	_, err := c.Get(context.Background(), "k")
+	require.Error(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestClient_ReusesConnections(t *testing.T) {
+	var mu sync.Mutex
+	accepted := 0
+	addr := serve(t, func(r *bufio.Reader, c net.Conn) {
+		mu.Lock()
+		accepted++
+		mu.Unlock()
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if strings.HasPrefix(line, "get ") {
+				_, _ = io.WriteString(c, "END\r\n")
+			}
+		}
+	})
+	c := newClient(t, config.SharedCacheMemcached, addr, "")
+	for range 5 {
+		_, err := c.Get(context.Background(), "k")
+		require.NoError(t, err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 1, accepted)
+}
+
+func TestClient_Closed(t *testing.T) {
+	c := newClient(t, config.SharedCacheRedis, startFakeRedis(t, newFakeStore(), ""), "")
+	require.NoError(t, c.Close())
+	_, err := c.Get(context.Background(), "k")
+	require.ErrorIs(t, err, sharedcache.ErrClosed)
+}
+
+func TestNew_UnknownBackend(t *testing.T) {
+	_, err := sharedcache.New(config.SharedCacheConfig{Backend: "etcd", Address: "127.0.0.1:1", Timeout: "1s"})
+	require.Error(t, err)
+}
@@ -0,0 +1,128 @@
+package sharedcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maxMemcachedKey is the longest key memcached accepts.
+	maxMemcachedKey = 250
+	// maxMemcachedTTL is the longest relative expiry; memcached reads
+	// larger values as Unix times.
+	maxMemcachedTTL = 30 * 24 * time.Hour
+)
+
+// memcached speaks the memcached text protocol.
+type memcached struct{}
+
+func (memcached) auth(*conn, string) error {
+	return errors.New("memcached: authentication is not supported")
+}
+
+func (memcached) get(c *conn, key string) ([]byte, error) {
+	if _, err := fmt.Fprintf(c.w, "get %s\r\n", key); err != nil {
+		return nil, err
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+
+	line, err := readLine(c)
+	if err != nil {
+		return nil, err
+	}
+	if line == "END" {
+		return nil, nil
+	}
+	// VALUE <key> <flags> <bytes>
+	fields := strings.Fields(line)
+	if len(fields) < 4 || fields[0] != "VALUE" {
+		return nil, memcachedError(line)
+	}
+	n, err := strconv.Atoi(fields[3])
+	if err != nil || n < 0 || n > maxValueBytes {
+		return nil, fmt.Errorf("memcached: bad value length %q", fields[3])
+	}
+	buf := make([]byte, n+2)
+	if _, err := io.ReadFull(c.r, buf); err != nil {
+		return nil, err
+	}
+	if string(buf[n:]) != "\r\n" {
+		return nil, errors.New("memcached: value not terminated")
+	}
+	if line, err = readLine(c); err != nil {
+		return nil, err
+	}
+	if line != "END" {
+		return nil, memcachedError(line)
+	}
+	return buf[:n], nil
+}
+
+func (memcached) set(c *conn, key string, value []byte, ttl time.Duration) error {
+	// Expiry is in whole seconds; rounding up keeps short TTLs from
+	// meaning "never expires" (0)
+	exptime := int64((min(ttl, maxMemcachedTTL) + time.Second - 1) / time.Second)
+	if _, err := fmt.Fprintf(c.w, "set %s 0 %d %d\r\n", key, exptime, len(value)); err != nil {
+		return err
+	}
+	_, _ = c.w.Write(value)
+	_, _ = c.w.WriteString("\r\n")
+	if err := c.w.Flush(); err != nil {
+		return err
+	}
+
+	line, err := readLine(c)
+	if err != nil {
+		return err
+	}
+	if line != "STORED" {
+		return memcachedError(line)
+	}
+	return nil
+}
+
+// del deletes keys in one round trip: the commands are written together,
+// then their responses read.
+func (memcached) del(c *conn, keys []string) error {
+	for _, k := range keys {
+		fmt.Fprintf(c.w, "delete %s\r\n", k)
+	}
+	if err := c.w.Flush(); err != nil {
+		return err
+	}
+	for range keys {
+		line, err := readLine(c)
+		if err != nil {
+			return err
+		}
+		if line != "DELETED" && line != "NOT_FOUND" {
+			return memcachedError(line)
+		}
+	}
+	return nil
+}
+
+// key joins prefix and key. Keys that are too long or contain spaces or
+// control characters, which memcached rejects, are replaced by their hash.
+func (memcached) key(prefix, key string) string {
+	k := prefix + ":" + key
+	if len(k) <= maxMemcachedKey && strings.IndexFunc(k, func(r rune) bool { return r <= ' ' || r == 0x7f }) < 0 {
+		return k
+	}
+	sum := sha256.Sum256([]byte(key))
+	return prefix + ":sha256:" + hex.EncodeToString(sum[:])
+}
+
+// memcachedError describes an unexpected response line, such as
+// "SERVER_ERROR out of memory".
+func memcachedError(line string) error {
+	return fmt.Errorf("memcached: unexpected response %q", line)
+}
@@ -0,0 +1,114 @@
+package sharedcache
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// redis speaks RESP, the redis serialization protocol.
+type redis struct{}
+
+func (redis) auth(c *conn, password string) error {
+	if err := writeCommand(c, "AUTH", []byte(password)); err != nil {
+		return err
+	}
+	_, err := readReply(c)
+	return err
+}
+
+func (redis) get(c *conn, key string) ([]byte, error) {
+	if err := writeCommand(c, "GET", []byte(key)); err != nil {
+		return nil, err
+	}
+	return readReply(c)
+}
+
+func (redis) set(c *conn, key string, value []byte, ttl time.Duration) error {
+	px := strconv.FormatInt(ttl.Milliseconds(), 10)
+	if err := writeCommand(c, "SET", []byte(key), value, []byte("PX"), []byte(px)); err != nil {
+		return err
+	}
+	_, err := readReply(c)
+	return err
+}
+
+func (redis) del(c *conn, keys []string) error {
+	args := make([][]byte, len(keys))
+	for i, k := range keys {
+		args[i] = []byte(k)
+	}
+	if err := writeCommand(c, "DEL", args...); err != nil {
+		return err
+	}
+	_, err := readReply(c)
+	return err
+}
+
+// key joins prefix and key; redis keys are binary safe.
+func (redis) key(prefix, key string) string {
+	return prefix + ":" + key
+}
+
+// writeCommand sends a command as an array of bulk strings.
+func writeCommand(c *conn, name string, args ...[]byte) error {
+	fmt.Fprintf(c.w, "*%d\r\n$%d\r\n%s\r\n", len(args)+1, len(name), name)
+	for _, a := range args {
+		fmt.Fprintf(c.w, "$%d\r\n", len(a))
+		_, _ = c.w.Write(a)
+		_, _ = c.w.WriteString("\r\n")
+	}
+	return c.w.Flush()
+}
+
+// readReply reads one reply. A null bulk string is returned as nil; simple
+// strings and integers are returned as their text; error replies become
+// errors.
+func readReply(c *conn) ([]byte, error) {
+	line, err := readLine(c)
+	if err != nil {
+		return nil, err
+	}
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+	switch line[0] {
+	case '+', ':':
+		return []byte(line[1:]), nil
+	case '-':
+		return nil, fmt.Errorf("redis: %s", line[1:])
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < -1 || n > maxValueBytes {
+			return nil, fmt.Errorf("redis: bad bulk length %q", line[1:])
+		}
+		if n == -1 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, err
+		}
+		if string(buf[n:]) != "\r\n" {
+			return nil, errors.New("redis: bulk string not terminated")
+		}
+		return buf[:n], nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply %q", line)
+	}
+}
+
+// readLine reads a CRLF-terminated line without the terminator.
+func readLine(c *conn) (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasSuffix(line, "\r\n") {
+		return "", errors.New("line not terminated by CRLF")
+	}
+	return line[:len(line)-2], nil
+}
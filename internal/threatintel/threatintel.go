@@ -0,0 +1,196 @@
+// Package threatintel checks newly observed domains against external threat
+// intelligence feeds such as abuse.ch URLhaus.
+//
+// Lookups happen asynchronously: the DNS path only hands names to a Checker,
+// which queues them, remembers verdicts for a while so each domain is looked
+// up at most once per cache period, and rate limits calls to the feed.
+// Malicious domains are reported to a callback, which typically blocks them
+// for a limited time through the filtering policy.
+package threatintel
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Feed looks up whether a domain is known to be malicious.
+type Feed interface {
+	Check(ctx context.Context, domain string) (bool, error)
+}
+
+const (
+	defaultQueueSize = 1024
+	maxVerdicts      = 100000
+	lookupTimeout    = 10 * time.Second
+
+	// errorRetry is how long a domain whose lookup failed waits before it
+	// is checked again.
+	errorRetry = time.Minute
+)
+
+// Settings configures a Checker.
+type Settings struct {
+	// CacheTTL is how long a verdict is remembered before a domain is
+	// checked again.
+	CacheTTL time.Duration
+	// RateLimit is the maximum number of feed lookups per second.
+	RateLimit float64
+	// QueueSize bounds the number of domains waiting for a lookup; more are
+	// dropped and retried the next time they are observed (default: 1024).
+	QueueSize int
+}
+
+// Stats contains Checker counters.
+type Stats struct {
+	Checked   uint64 // Feed lookups made
+	Malicious uint64 // Lookups that found a malicious domain
+	Errors    uint64 // Lookups that failed
+	Dropped   uint64 // Domains not queued because the queue was full
+}
+
+// Checker looks up observed domains in a feed in the background.
+type Checker struct {
+	feed        Feed
+	settings    Settings
+	onMalicious func(domain string)
+
+	queue chan string
+
+	// recheck holds, per domain, the time after which it may be looked up
+	// again. Queued domains are included so they are not queued twice.
+	mu      sync.Mutex
+	recheck map[string]time.Time
+
+	checked   atomic.Uint64
+	malicious atomic.Uint64
+	errors    atomic.Uint64
+	dropped   atomic.Uint64
+}
+
+// NewChecker creates a Checker for feed. onMalicious is called from the
+// lookup goroutine for each domain the feed lists. Lookups only happen while
+// Run is running.
+func NewChecker(feed Feed, s Settings, onMalicious func(domain string)) *Checker {
+	if s.QueueSize <= 0 {
+		s.QueueSize = defaultQueueSize
+	}
+	if s.RateLimit <= 0 {
+		s.RateLimit = 1
+	}
+	return &Checker{
+		feed:        feed,
+		settings:    s,
+		onMalicious: onMalicious,
+		queue:       make(chan string, s.QueueSize),
+		recheck:     make(map[string]time.Time),
+	}
+}
+
+// Observe queues domain for a lookup unless it was checked recently. It never
+// blocks. Single-label names and reverse lookup names are ignored.
+func (c *Checker) Observe(domain string) {
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+	if !strings.Contains(domain, ".") || strings.HasSuffix(domain, ".arpa") {
+		return
+	}
+
+	now := time.Now()
+	c.mu.Lock()
+	if until, ok := c.recheck[domain]; ok && now.Before(until) {
+		c.mu.Unlock()
+		return
+	}
+	if len(c.recheck) >= maxVerdicts {
+		c.pruneLocked(now)
+		if len(c.recheck) >= maxVerdicts {
+			c.mu.Unlock()
+			c.dropped.Add(1)
+			return
+		}
+	}
+	c.recheck[domain] = now.Add(c.settings.CacheTTL)
+	c.mu.Unlock()
+
+	select {
+	case c.queue <- domain:
+	default:
+		c.forget(domain)
+		c.dropped.Add(1)
+	}
+}
+
+// Run looks up queued domains, at most RateLimit per second, until ctx is
+// canceled.
+func (c *Checker) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / c.settings.RateLimit))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case domain := <-c.queue:
+			c.check(ctx, domain)
+		}
+	}
+}
+
+// Stats returns a snapshot of the counters.
+func (c *Checker) Stats() Stats {
+	return Stats{
+		Checked:   c.checked.Load(),
+		Malicious: c.malicious.Load(),
+		Errors:    c.errors.Load(),
+		Dropped:   c.dropped.Load(),
+	}
+}
+
+// check looks up one domain and records the verdict.
+func (c *Checker) check(ctx context.Context, domain string) {
+	lookupCtx, cancel := context.WithTimeout(ctx, lookupTimeout)
+	malicious, err := c.feed.Check(lookupCtx, domain)
+	cancel()
+	if ctx.Err() != nil {
+		c.forget(domain)
+		return
+	}
+
+	c.checked.Add(1)
+	if err != nil {
+		c.errors.Add(1)
+		c.mu.Lock()
+		c.recheck[domain] = time.Now().Add(errorRetry)
+		c.mu.Unlock()
+		return
+	}
+	if malicious {
+		c.malicious.Add(1)
+		if c.onMalicious != nil {
+			c.onMalicious(domain)
+		}
+	}
+}
+
+// forget drops the verdict for domain so the next observation queues it.
+func (c *Checker) forget(domain string) {
+	c.mu.Lock()
+	delete(c.recheck, domain)
+	c.mu.Unlock()
+}
+
+// pruneLocked removes expired verdicts. c.mu must be held.
+func (c *Checker) pruneLocked(now time.Time) {
+	for domain, until := range c.recheck {
+		if !now.Before(until) {
+			delete(c.recheck, domain)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package threatintel_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jroosing/hydradns/internal/threatintel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeURLhaus serves URLhaus host lookups, listing the given hosts, and
+// records the hosts it was asked about.
+type fakeURLhaus struct {
+	mu      sync.Mutex
+	listed  map[string]bool
+	queried []string
+	authKey string
+}
+
+func (f *fakeURLhaus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	host := r.PostFormValue("host")
+	f.mu.Lock()
+	f.queried = append(f.queried, host)
+	f.authKey = r.Header.Get("Auth-Key")
+	listed := f.listed[host]
+	f.mu.Unlock()
+
+	status := "no_results"
+	if listed {
+		status = "ok"
+	}
+	_ = json.NewEncoder(w).Encode(map[string]string{"query_status": status})
+}
+
+func (f *fakeURLhaus) Queried() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.queried...)
+}
+
+// malicious collects domains reported by a Checker.
+type malicious struct {
+	mu      sync.Mutex
+	domains []string
+}
+
+func (m *malicious) add(domain string) {
+	m.mu.Lock()
+	m.domains = append(m.domains, domain)
+	m.mu.Unlock()
+}
+
+func (m *malicious) Domains() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.domains...)
+}
+
+// ============================================================================
+// URLhaus Tests
+// ============================================================================
+
+func TestURLhaus_Check(t *testing.T) {
+	fake := &fakeURLhaus{listed: map[string]bool{"bad.example": true}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	feed := &threatintel.URLhaus{URL: srv.URL, AuthKey: "key"}
+
+	bad, err := feed.Check(context.Background(), "bad.example")
+	require.NoError(t, err)
+	assert.True(t, bad)
+	assert.Equal(t, "key", fake.authKey)
+
+	bad, err = feed.Check(context.Background(), "good.example")
+	require.NoError(t, err)
+	assert.False(t, bad)
+}
+
+func TestURLhaus_CheckErrors(t *testing.T) {
+	tests := map[string]http.HandlerFunc{
+		"http error": func(w http.ResponseWriter, _ *http.Request) {
+			http.Error(w, "nope", http.StatusUnauthorized)
+		},
+		"bad json": func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte("<html>"))
+		},
+		"unknown status": func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(`{"query_status":"unknown_auth_key"}`))
+		},
+	}
+	for name, handler := range tests {
+		srv := httptest.NewServer(handler)
+		feed := &threatintel.URLhaus{URL: srv.URL}
+		_, err := feed.Check(context.Background(), "bad.example")
+		assert.Error(t, err, name)
+		srv.Close()
+	}
+}
+
+// ============================================================================
+// Checker Tests
+// ============================================================================
+
+func TestChecker_ReportsMaliciousDomains(t *testing.T) {
+	fake := &fakeURLhaus{listed: map[string]bool{"bad.example": true}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	var found malicious
+	c := threatintel.NewChecker(&threatintel.URLhaus{URL: srv.URL}, threatintel.Settings{
+		CacheTTL:  time.Hour,
+		RateLimit: 100,
+	}, found.add)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	c.Observe("Bad.Example.")
+	c.Observe("good.example")
+
+	assert.Eventually(t, func() bool { return c.Stats().Checked == 2 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"bad.example"}, found.Domains())
+	assert.Equal(t, uint64(1), c.Stats().Malicious)
+}
+
+func TestChecker_CachesVerdicts(t *testing.T) {
+	fake := &fakeURLhaus{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	c := threatintel.NewChecker(&threatintel.URLhaus{URL: srv.URL}, threatintel.Settings{
+		CacheTTL:  time.Hour,
+		RateLimit: 100,
+	}, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	for range 5 {
+		c.Observe("example.com")
+	}
+	assert.Eventually(t, func() bool { return c.Stats().Checked == 1 }, time.Second, 10*time.Millisecond)
+	c.Observe("example.com")
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, []string{"example.com"}, fake.Queried())
+}
+
+func TestChecker_IgnoresLocalNames(t *testing.T) {
+	fake := &fakeURLhaus{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	c := threatintel.NewChecker(&threatintel.URLhaus{URL: srv.URL}, threatintel.Settings{
+		CacheTTL:  time.Hour,
+		RateLimit: 100,
+	}, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	c.Observe("localhost")
+	c.Observe("1.2.0.192.in-addr.arpa")
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(t, fake.Queried())
+}
+
+func TestChecker_DropsWhenQueueFull(t *testing.T) {
+	c := threatintel.NewChecker(&threatintel.URLhaus{URL: "http://127.0.0.1:1"}, threatintel.Settings{
+		CacheTTL:  time.Hour,
+		RateLimit: 1,
+		QueueSize: 2,
+	}, nil)
+
+	// Not running, so nothing drains the queue
+	c.Observe("a.example")
+	c.Observe("b.example")
+	c.Observe("c.example")
+
+	assert.Equal(t, uint64(1), c.Stats().Dropped)
+}
+
+func TestChecker_RateLimitsLookups(t *testing.T) {
+	fake := &fakeURLhaus{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	c := threatintel.NewChecker(&threatintel.URLhaus{URL: srv.URL}, threatintel.Settings{
+		CacheTTL:  time.Hour,
+		RateLimit: 10,
+	}, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	for _, d := range []string{"a.example", "b.example", "c.example", "d.example", "e.example"} {
+		c.Observe(d)
+	}
+
+	time.Sleep(250 * time.Millisecond)
+	assert.LessOrEqual(t, len(fake.Queried()), 3, "10/s should allow at most 3 lookups in 250ms")
+}
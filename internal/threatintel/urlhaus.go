@@ -0,0 +1,79 @@
+package threatintel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultURLhausURL is the URLhaus host lookup endpoint.
+const DefaultURLhausURL = "https://urlhaus-api.abuse.ch/v1/host/"
+
+// maxResponseSize bounds how much of a feed response is read.
+const maxResponseSize = 1 << 20
+
+// URLhaus looks up domains in the abuse.ch URLhaus database of hosts serving
+// malware. A domain is malicious when URLhaus knows any URL on it.
+type URLhaus struct {
+	// URL is the host lookup endpoint (default: DefaultURLhausURL)
+	URL string
+	// AuthKey is sent in the Auth-Key header when set. abuse.ch requires
+	// one for its public API.
+	AuthKey string
+	// Client makes the requests (default: a client with a 10s timeout)
+	Client *http.Client
+}
+
+// urlhausResponse is the part of a host lookup response that matters here.
+type urlhausResponse struct {
+	QueryStatus string `json:"query_status"`
+}
+
+// Check reports whether URLhaus lists domain.
+func (u *URLhaus) Check(ctx context.Context, domain string) (bool, error) {
+	endpoint := u.URL
+	if endpoint == "" {
+		endpoint = DefaultURLhausURL
+	}
+	client := u.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	form := url.Values{"host": {domain}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if u.AuthKey != "" {
+		req.Header.Set("Auth-Key", u.AuthKey)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("urlhaus lookup failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("urlhaus lookup failed: HTTP %s", resp.Status)
+	}
+
+	var body urlhausResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&body); err != nil {
+		return false, fmt.Errorf("failed to decode urlhaus response: %w", err)
+	}
+	switch body.QueryStatus {
+	case "ok":
+		return true, nil
+	case "no_results", "invalid_host":
+		return false, nil
+	default:
+		return false, fmt.Errorf("urlhaus lookup failed: query_status %q", body.QueryStatus)
+	}
+}
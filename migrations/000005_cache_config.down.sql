@@ -0,0 +1,7 @@
+-- Remove cache configuration tables
+DROP TRIGGER IF EXISTS trg_config_version_increment_cache_zone_delete;
+DROP TRIGGER IF EXISTS trg_config_version_increment_cache_zone_update;
+DROP TRIGGER IF EXISTS trg_config_version_increment_cache_zone;
+DROP TRIGGER IF EXISTS trg_config_version_increment_cache;
+DROP TABLE IF EXISTS cache_zone_overrides;
+DROP TABLE IF EXISTS config_cache;
@@ -0,0 +1,48 @@
+-- Negative / SERVFAIL cache configuration
+CREATE TABLE IF NOT EXISTS config_cache (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    disable_negative BOOLEAN NOT NULL DEFAULT 0,
+    servfail_ttl TEXT NOT NULL DEFAULT '30s',
+    negative_ttl TEXT NOT NULL DEFAULT '5m',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Per-zone overrides; NULL / empty values inherit from config_cache
+CREATE TABLE IF NOT EXISTS cache_zone_overrides (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    zone TEXT NOT NULL UNIQUE,
+    disable_negative BOOLEAN,
+    servfail_ttl TEXT NOT NULL DEFAULT '',
+    negative_ttl TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO config_cache (id, disable_negative, servfail_ttl, negative_ttl, created_at, updated_at)
+VALUES (1, 0, '30s', '5m', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+ON CONFLICT(id) DO NOTHING;
+
+CREATE TRIGGER IF NOT EXISTS trg_config_version_increment_cache
+AFTER UPDATE ON config_cache
+BEGIN
+    UPDATE config_version SET version = version + 1, updated_at = CURRENT_TIMESTAMP WHERE id = 1;
+END;
+
+CREATE TRIGGER IF NOT EXISTS trg_config_version_increment_cache_zone
+AFTER INSERT ON cache_zone_overrides
+BEGIN
+    UPDATE config_version SET version = version + 1, updated_at = CURRENT_TIMESTAMP WHERE id = 1;
+END;
+
+CREATE TRIGGER IF NOT EXISTS trg_config_version_increment_cache_zone_update
+AFTER UPDATE ON cache_zone_overrides
+BEGIN
+    UPDATE config_version SET version = version + 1, updated_at = CURRENT_TIMESTAMP WHERE id = 1;
+END;
+
+CREATE TRIGGER IF NOT EXISTS trg_config_version_increment_cache_zone_delete
+AFTER DELETE ON cache_zone_overrides
+BEGIN
+    UPDATE config_version SET version = version + 1, updated_at = CURRENT_TIMESTAMP WHERE id = 1;
+END;
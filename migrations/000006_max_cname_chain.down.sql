@@ -0,0 +1,2 @@
+-- Remove CNAME chain limit
+ALTER TABLE config_server DROP COLUMN max_cname_chain;
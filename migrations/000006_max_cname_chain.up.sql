@@ -0,0 +1,2 @@
+-- Maximum number of CNAMEs followed when answering one query
+ALTER TABLE config_server ADD COLUMN max_cname_chain INTEGER NOT NULL DEFAULT 8;
@@ -0,0 +1,2 @@
+-- Remove UDP listener count
+ALTER TABLE config_server DROP COLUMN udp_listeners;
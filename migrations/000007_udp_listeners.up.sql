@@ -0,0 +1,2 @@
+-- Number of SO_REUSEPORT UDP listeners; 0 opens one per CPU
+ALTER TABLE config_server ADD COLUMN udp_listeners INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+-- Remove first-run setup state
+ALTER TABLE config_api DROP COLUMN setup_pending;
@@ -0,0 +1,3 @@
+-- First-run setup state; set when a fresh database is bootstrapped with a
+-- generated API key and cleared by POST /api/v1/setup
+ALTER TABLE config_api ADD COLUMN setup_pending BOOLEAN NOT NULL DEFAULT 0;
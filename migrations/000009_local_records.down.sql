@@ -0,0 +1,4 @@
+-- Remove local entry flags
+ALTER TABLE filtering_blacklist DROP COLUMN local;
+ALTER TABLE filtering_whitelist DROP COLUMN local;
+ALTER TABLE custom_dns_records DROP COLUMN local;
@@ -0,0 +1,5 @@
+-- Entries marked local on a secondary are kept when configuration is synced
+-- from the primary, allowing per-site overrides
+ALTER TABLE custom_dns_records ADD COLUMN local BOOLEAN NOT NULL DEFAULT 0;
+ALTER TABLE filtering_whitelist ADD COLUMN local BOOLEAN NOT NULL DEFAULT 0;
+ALTER TABLE filtering_blacklist ADD COLUMN local BOOLEAN NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+-- Remove applied cluster section hashes
+DROP TABLE IF EXISTS cluster_sections;
@@ -0,0 +1,8 @@
+-- Hash and primary version of each configuration section last imported from
+-- the cluster primary, used to detect drift per section
+CREATE TABLE IF NOT EXISTS cluster_sections (
+    section TEXT PRIMARY KEY,
+    hash TEXT NOT NULL,
+    version INTEGER NOT NULL,
+    applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,2 @@
+-- Remove cached blocklist downloads
+DROP TABLE IF EXISTS blocklist_cache;
@@ -0,0 +1,10 @@
+-- Last downloaded body of each remote blocklist with its HTTP validators, so
+-- unchanged lists are revalidated instead of downloaded again. Keyed by URL
+-- since lists may also come from the environment. Not part of cluster sync.
+CREATE TABLE IF NOT EXISTS blocklist_cache (
+    url TEXT PRIMARY KEY,
+    etag TEXT NOT NULL DEFAULT '',
+    last_modified TEXT NOT NULL DEFAULT '',
+    body BLOB NOT NULL,
+    fetched_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
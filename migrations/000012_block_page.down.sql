@@ -0,0 +1,2 @@
+-- Remove block page settings
+DROP TABLE IF EXISTS config_block_page;
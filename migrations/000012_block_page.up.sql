@@ -0,0 +1,18 @@
+-- Block page server settings. Per node: not tracked by config_version, so
+-- changes are not synced to cluster secondaries.
+CREATE TABLE IF NOT EXISTS config_block_page (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    enabled BOOLEAN NOT NULL DEFAULT 0,
+    ipv4 TEXT NOT NULL DEFAULT '',
+    ipv6 TEXT NOT NULL DEFAULT '',
+    http_addr TEXT NOT NULL DEFAULT ':80',
+    https_addr TEXT NOT NULL DEFAULT ':443',
+    tls_cert TEXT NOT NULL DEFAULT '',
+    tls_key TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO config_block_page (id, created_at, updated_at)
+VALUES (1, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+ON CONFLICT(id) DO NOTHING;
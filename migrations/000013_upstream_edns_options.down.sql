@@ -0,0 +1,5 @@
+-- Remove EDNS option rules
+DROP TRIGGER IF EXISTS trg_config_version_increment_edns_options_delete;
+DROP TRIGGER IF EXISTS trg_config_version_increment_edns_options_update;
+DROP TRIGGER IF EXISTS trg_config_version_increment_edns_options;
+DROP TABLE IF EXISTS upstream_edns_options;
@@ -0,0 +1,27 @@
+-- EDNS option rules for queries forwarded upstream; options without a rule pass
+CREATE TABLE IF NOT EXISTS upstream_edns_options (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    option_name TEXT NOT NULL UNIQUE,
+    action TEXT NOT NULL,
+    value TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TRIGGER IF NOT EXISTS trg_config_version_increment_edns_options
+AFTER INSERT ON upstream_edns_options
+BEGIN
+    UPDATE config_version SET version = version + 1, updated_at = CURRENT_TIMESTAMP WHERE id = 1;
+END;
+
+CREATE TRIGGER IF NOT EXISTS trg_config_version_increment_edns_options_update
+AFTER UPDATE ON upstream_edns_options
+BEGIN
+    UPDATE config_version SET version = version + 1, updated_at = CURRENT_TIMESTAMP WHERE id = 1;
+END;
+
+CREATE TRIGGER IF NOT EXISTS trg_config_version_increment_edns_options_delete
+AFTER DELETE ON upstream_edns_options
+BEGIN
+    UPDATE config_version SET version = version + 1, updated_at = CURRENT_TIMESTAMP WHERE id = 1;
+END;
@@ -0,0 +1,2 @@
+-- Remove rate limit slip
+ALTER TABLE config_rate_limit DROP COLUMN slip;
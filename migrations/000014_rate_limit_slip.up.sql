@@ -0,0 +1,2 @@
+-- Answer every Nth rate-limited UDP query with TC=1; 0 always drops
+ALTER TABLE config_rate_limit ADD COLUMN slip INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,6 @@
+-- Remove TCP server timeouts and limits
+ALTER TABLE config_server DROP COLUMN tcp_listeners;
+ALTER TABLE config_server DROP COLUMN tcp_max_queries_per_conn;
+ALTER TABLE config_server DROP COLUMN tcp_max_conns_per_ip;
+ALTER TABLE config_server DROP COLUMN tcp_idle_timeout;
+ALTER TABLE config_server DROP COLUMN tcp_read_timeout;
@@ -0,0 +1,6 @@
+-- TCP server timeouts and limits; tcp_listeners 0 opens one per CPU
+ALTER TABLE config_server ADD COLUMN tcp_read_timeout TEXT NOT NULL DEFAULT '10s';
+ALTER TABLE config_server ADD COLUMN tcp_idle_timeout TEXT NOT NULL DEFAULT '30s';
+ALTER TABLE config_server ADD COLUMN tcp_max_conns_per_ip INTEGER NOT NULL DEFAULT 10;
+ALTER TABLE config_server ADD COLUMN tcp_max_queries_per_conn INTEGER NOT NULL DEFAULT 100;
+ALTER TABLE config_server ADD COLUMN tcp_listeners INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+-- Remove PROXY protocol trusted sources
+ALTER TABLE config_server DROP COLUMN proxy_protocol_trusted;
@@ -0,0 +1,2 @@
+-- Comma-separated addresses/CIDRs of load balancers sending PROXY protocol v2
+ALTER TABLE config_server ADD COLUMN proxy_protocol_trusted TEXT NOT NULL DEFAULT '';
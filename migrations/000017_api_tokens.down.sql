@@ -0,0 +1,2 @@
+-- Remove API tokens
+DROP TABLE IF EXISTS api_tokens;
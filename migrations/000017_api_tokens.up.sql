@@ -0,0 +1,11 @@
+-- Named, scoped API tokens. Per node: not tracked by config_version, so
+-- tokens are not synced to cluster secondaries. Only a SHA-256 hash of each
+-- token is stored; expires_at is a Unix timestamp, 0 for no expiry.
+CREATE TABLE IF NOT EXISTS api_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    token_hash TEXT NOT NULL UNIQUE,
+    scopes TEXT NOT NULL,
+    expires_at INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
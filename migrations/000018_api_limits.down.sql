@@ -0,0 +1,4 @@
+-- Remove management API limits
+ALTER TABLE config_api DROP COLUMN max_body_bytes;
+ALTER TABLE config_api DROP COLUMN rate_limit_burst;
+ALTER TABLE config_api DROP COLUMN rate_limit_rps;
@@ -0,0 +1,5 @@
+-- Management API request rate (per client IP) and body size limits;
+-- rate_limit_rps 0 disables rate limiting
+ALTER TABLE config_api ADD COLUMN rate_limit_rps REAL NOT NULL DEFAULT 20;
+ALTER TABLE config_api ADD COLUMN rate_limit_burst INTEGER NOT NULL DEFAULT 40;
+ALTER TABLE config_api ADD COLUMN max_body_bytes INTEGER NOT NULL DEFAULT 1048576;
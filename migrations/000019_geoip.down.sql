@@ -0,0 +1,2 @@
+-- Remove GeoIP settings
+DROP TABLE IF EXISTS config_geoip;
@@ -0,0 +1,16 @@
+-- GeoIP database paths and ASN/country answer blocking. Per node: not
+-- tracked by config_version, so changes are not synced to cluster
+-- secondaries. block_asns and block_countries are comma-separated.
+CREATE TABLE IF NOT EXISTS config_geoip (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    country_db TEXT NOT NULL DEFAULT '',
+    asn_db TEXT NOT NULL DEFAULT '',
+    block_asns TEXT NOT NULL DEFAULT '',
+    block_countries TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO config_geoip (id, created_at, updated_at)
+VALUES (1, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+ON CONFLICT(id) DO NOTHING;
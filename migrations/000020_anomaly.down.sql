@@ -0,0 +1,2 @@
+-- Remove anomaly detection settings
+DROP TABLE IF EXISTS config_anomaly;
@@ -0,0 +1,18 @@
+-- DNS tunneling / anomalous query detection. Per node: not tracked by
+-- config_version, so changes are not synced to cluster secondaries.
+CREATE TABLE IF NOT EXISTS config_anomaly (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    enabled BOOLEAN NOT NULL DEFAULT 1,
+    window_duration TEXT NOT NULL DEFAULT '1m',
+    min_queries INTEGER NOT NULL DEFAULT 50,
+    nxdomain_ratio REAL NOT NULL DEFAULT 0.5,
+    txt_ratio REAL NOT NULL DEFAULT 0.5,
+    max_label_length INTEGER NOT NULL DEFAULT 50,
+    min_label_entropy REAL NOT NULL DEFAULT 4.0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO config_anomaly (id, created_at, updated_at)
+VALUES (1, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+ON CONFLICT(id) DO NOTHING;
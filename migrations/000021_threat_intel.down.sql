@@ -0,0 +1,2 @@
+-- Remove threat intelligence settings
+DROP TABLE IF EXISTS config_threat_intel;
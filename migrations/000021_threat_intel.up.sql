@@ -0,0 +1,18 @@
+-- Threat intelligence feed lookups. Per node: not tracked by config_version,
+-- so changes are not synced to cluster secondaries.
+CREATE TABLE IF NOT EXISTS config_threat_intel (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    enabled BOOLEAN NOT NULL DEFAULT 0,
+    provider TEXT NOT NULL DEFAULT 'urlhaus',
+    url TEXT NOT NULL DEFAULT '',
+    auth_key TEXT NOT NULL DEFAULT '',
+    block_ttl TEXT NOT NULL DEFAULT '24h',
+    cache_ttl TEXT NOT NULL DEFAULT '6h',
+    rate_limit REAL NOT NULL DEFAULT 1.0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO config_threat_intel (id, created_at, updated_at)
+VALUES (1, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+ON CONFLICT(id) DO NOTHING;
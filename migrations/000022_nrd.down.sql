@@ -0,0 +1,3 @@
+-- Remove newly registered domain detection
+DROP TABLE IF EXISTS domain_first_seen;
+DROP TABLE IF EXISTS config_nrd;
@@ -0,0 +1,21 @@
+-- Newly registered domain (NRD) detection. Per node: not tracked by
+-- config_version, so changes are not synced to cluster secondaries.
+CREATE TABLE IF NOT EXISTS config_nrd (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    enabled BOOLEAN NOT NULL DEFAULT 0,
+    action TEXT NOT NULL DEFAULT 'flag',
+    days INTEGER NOT NULL DEFAULT 30,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO config_nrd (id, created_at, updated_at)
+VALUES (1, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+ON CONFLICT(id) DO NOTHING;
+
+-- When each registrable domain (eTLD+1) was first queried on this node,
+-- in Unix seconds.
+CREATE TABLE IF NOT EXISTS domain_first_seen (
+    domain TEXT PRIMARY KEY,
+    first_seen INTEGER NOT NULL
+);
@@ -0,0 +1,2 @@
+-- Remove custom DNS search domains
+DROP TABLE IF EXISTS config_custom_dns;
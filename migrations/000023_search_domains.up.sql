@@ -0,0 +1,12 @@
+-- Search domains tried for single-label custom DNS queries. Per node: not
+-- tracked by config_version, so changes are not synced to cluster secondaries.
+CREATE TABLE IF NOT EXISTS config_custom_dns (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    search_domains TEXT NOT NULL DEFAULT '', -- comma-separated, tried in order
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO config_custom_dns (id, created_at, updated_at)
+VALUES (1, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+ON CONFLICT(id) DO NOTHING;
@@ -0,0 +1,2 @@
+-- Remove the recursion client list
+ALTER TABLE config_server DROP COLUMN recursion_clients;
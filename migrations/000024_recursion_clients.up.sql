@@ -0,0 +1,2 @@
+-- Comma-separated addresses/CIDRs of clients offered recursion (empty: all)
+ALTER TABLE config_server ADD COLUMN recursion_clients TEXT NOT NULL DEFAULT '';
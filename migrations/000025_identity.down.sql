@@ -0,0 +1,2 @@
+-- Remove server identity settings
+DROP TABLE IF EXISTS config_identity;
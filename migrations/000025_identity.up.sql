@@ -0,0 +1,15 @@
+-- Answers to CHAOS-class TXT identity queries (version.bind, hostname.bind,
+-- id.server). Per node: not tracked by config_version, so changes are not
+-- synced to cluster secondaries.
+CREATE TABLE IF NOT EXISTS config_identity (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    version TEXT NOT NULL DEFAULT '',
+    hostname TEXT NOT NULL DEFAULT '',
+    server_id TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO config_identity (id, created_at, updated_at)
+VALUES (1, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+ON CONFLICT(id) DO NOTHING;
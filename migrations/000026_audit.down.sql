@@ -0,0 +1,2 @@
+-- Remove outbound query audit settings
+DROP TABLE IF EXISTS config_audit;
@@ -0,0 +1,2 @@
+-- Remove listener profiles
+DROP TABLE IF EXISTS listener_profiles;
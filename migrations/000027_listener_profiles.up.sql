@@ -0,0 +1,11 @@
+-- Additional DNS listeners, each with its own resolver chain. Per node: not
+-- tracked by config_version, so changes are not synced to cluster
+-- secondaries. An empty host binds to config_server.host.
+CREATE TABLE IF NOT EXISTS listener_profiles (
+    name TEXT PRIMARY KEY,
+    host TEXT NOT NULL DEFAULT '',
+    port INTEGER NOT NULL,
+    unfiltered BOOLEAN NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,3 @@
+-- Remove the response cache size and eviction policy
+ALTER TABLE config_cache DROP COLUMN eviction_policy;
+ALTER TABLE config_cache DROP COLUMN max_entries;
@@ -0,0 +1,3 @@
+-- Response cache size and eviction policy ('lru', 'lfu', or 'ttl')
+ALTER TABLE config_cache ADD COLUMN max_entries INTEGER NOT NULL DEFAULT 20000;
+ALTER TABLE config_cache ADD COLUMN eviction_policy TEXT NOT NULL DEFAULT 'lru';
@@ -0,0 +1,2 @@
+-- Remove the cache bypass domains
+ALTER TABLE config_cache DROP COLUMN bypass_domains;
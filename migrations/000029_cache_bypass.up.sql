@@ -0,0 +1,2 @@
+-- Comma-separated domains whose answers are never cached
+ALTER TABLE config_cache ADD COLUMN bypass_domains TEXT NOT NULL DEFAULT '';
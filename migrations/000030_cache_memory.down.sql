@@ -0,0 +1,3 @@
+-- Remove the response cache memory budget
+ALTER TABLE config_cache DROP COLUMN max_entry_bytes;
+ALTER TABLE config_cache DROP COLUMN max_bytes;
@@ -0,0 +1,3 @@
+-- Response cache memory budget in bytes (-1: no limit)
+ALTER TABLE config_cache ADD COLUMN max_bytes INTEGER NOT NULL DEFAULT 67108864;
+ALTER TABLE config_cache ADD COLUMN max_entry_bytes INTEGER NOT NULL DEFAULT 16384;
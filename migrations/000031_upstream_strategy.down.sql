@@ -0,0 +1,2 @@
+-- Remove the upstream selection strategy
+ALTER TABLE config_upstream DROP COLUMN strategy;
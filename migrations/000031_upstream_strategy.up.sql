@@ -0,0 +1,2 @@
+-- Upstream selection strategy: sequential, round_robin, latency, or random
+ALTER TABLE config_upstream ADD COLUMN strategy TEXT NOT NULL DEFAULT 'sequential';
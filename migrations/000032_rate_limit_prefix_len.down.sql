@@ -0,0 +1,4 @@
+-- Remove the prefix limit network sizes and local exemption
+ALTER TABLE config_rate_limit DROP COLUMN exempt_local;
+ALTER TABLE config_rate_limit DROP COLUMN ipv6_prefix_len;
+ALTER TABLE config_rate_limit DROP COLUMN ipv4_prefix_len;
@@ -0,0 +1,4 @@
+-- Prefix limit network sizes and exemption of link-local/ULA clients
+ALTER TABLE config_rate_limit ADD COLUMN ipv4_prefix_len INTEGER NOT NULL DEFAULT 24;
+ALTER TABLE config_rate_limit ADD COLUMN ipv6_prefix_len INTEGER NOT NULL DEFAULT 64;
+ALTER TABLE config_rate_limit ADD COLUMN exempt_local INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+-- Remove DNS health canary settings
+DROP TABLE IF EXISTS config_canary;
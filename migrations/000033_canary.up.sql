@@ -0,0 +1,16 @@
+-- DNS health canary self-queries. Per node: not tracked by config_version,
+-- so changes are not synced to cluster secondaries.
+CREATE TABLE IF NOT EXISTS config_canary (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    enabled BOOLEAN NOT NULL DEFAULT 0,
+    domains TEXT NOT NULL DEFAULT 'example.com,iana.org',
+    interval TEXT NOT NULL DEFAULT '30s',
+    timeout TEXT NOT NULL DEFAULT '5s',
+    failure_threshold INTEGER NOT NULL DEFAULT 3,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO config_canary (id, created_at, updated_at)
+VALUES (1, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+ON CONFLICT(id) DO NOTHING;
@@ -0,0 +1,2 @@
+-- Remove qtype rules
+DROP TABLE IF EXISTS qtype_rules;
@@ -0,0 +1,12 @@
+-- Fixed responses for queries by record type, e.g. refusing ANY or PTR from
+-- a guest VLAN. Per node: not tracked by config_version, so changes are not
+-- synced to cluster secondaries. Rules apply in id order; qtypes and clients
+-- are comma-separated, and no clients means all clients.
+CREATE TABLE IF NOT EXISTS qtype_rules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    qtypes TEXT NOT NULL,
+    clients TEXT NOT NULL DEFAULT '',
+    action TEXT NOT NULL DEFAULT 'refused',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,9 @@
+-- Remove DoH servers and transport rules
+DROP TRIGGER IF EXISTS trg_config_version_increment_transport_rules_delete;
+DROP TRIGGER IF EXISTS trg_config_version_increment_transport_rules_update;
+DROP TRIGGER IF EXISTS trg_config_version_increment_transport_rules;
+DROP TRIGGER IF EXISTS trg_config_version_increment_doh_servers_delete;
+DROP TRIGGER IF EXISTS trg_config_version_increment_doh_servers_update;
+DROP TRIGGER IF EXISTS trg_config_version_increment_doh_servers;
+DROP TABLE IF EXISTS upstream_transport_rules;
+DROP TABLE IF EXISTS upstream_doh_servers;
@@ -0,0 +1,53 @@
+-- DNS-over-HTTPS servers, tried in priority order for names a transport rule sends over DoH
+CREATE TABLE IF NOT EXISTS upstream_doh_servers (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    url TEXT NOT NULL UNIQUE,
+    priority INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Transports forced for a domain and the names below it: tcp or doh
+CREATE TABLE IF NOT EXISTS upstream_transport_rules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    domain TEXT NOT NULL UNIQUE,
+    transport TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TRIGGER IF NOT EXISTS trg_config_version_increment_doh_servers
+AFTER INSERT ON upstream_doh_servers
+BEGIN
+    UPDATE config_version SET version = version + 1, updated_at = CURRENT_TIMESTAMP WHERE id = 1;
+END;
+
+CREATE TRIGGER IF NOT EXISTS trg_config_version_increment_doh_servers_update
+AFTER UPDATE ON upstream_doh_servers
+BEGIN
+    UPDATE config_version SET version = version + 1, updated_at = CURRENT_TIMESTAMP WHERE id = 1;
+END;
+
+CREATE TRIGGER IF NOT EXISTS trg_config_version_increment_doh_servers_delete
+AFTER DELETE ON upstream_doh_servers
+BEGIN
+    UPDATE config_version SET version = version + 1, updated_at = CURRENT_TIMESTAMP WHERE id = 1;
+END;
+
+CREATE TRIGGER IF NOT EXISTS trg_config_version_increment_transport_rules
+AFTER INSERT ON upstream_transport_rules
+BEGIN
+    UPDATE config_version SET version = version + 1, updated_at = CURRENT_TIMESTAMP WHERE id = 1;
+END;
+
+CREATE TRIGGER IF NOT EXISTS trg_config_version_increment_transport_rules_update
+AFTER UPDATE ON upstream_transport_rules
+BEGIN
+    UPDATE config_version SET version = version + 1, updated_at = CURRENT_TIMESTAMP WHERE id = 1;
+END;
+
+CREATE TRIGGER IF NOT EXISTS trg_config_version_increment_transport_rules_delete
+AFTER DELETE ON upstream_transport_rules
+BEGIN
+    UPDATE config_version SET version = version + 1, updated_at = CURRENT_TIMESTAMP WHERE id = 1;
+END;
@@ -0,0 +1,2 @@
+-- Remove the custom DNS answer order
+ALTER TABLE config_custom_dns DROP COLUMN answer_order;
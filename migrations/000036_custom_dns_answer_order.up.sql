@@ -0,0 +1,3 @@
+-- Order of the addresses of names with several custom DNS records: fixed,
+-- round_robin, or random. Per node, like the search domains.
+ALTER TABLE config_custom_dns ADD COLUMN answer_order TEXT NOT NULL DEFAULT 'fixed';
@@ -0,0 +1,2 @@
+-- Remove custom DNS health checks
+DROP TABLE IF EXISTS custom_dns_health_checks;
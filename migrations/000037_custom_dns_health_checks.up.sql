@@ -0,0 +1,15 @@
+-- TCP/HTTP health checks for the addresses of custom DNS hosts. Addresses
+-- that fail are left out of answers until they pass again. Per node: not
+-- tracked by config_version, since each node probes from its own network.
+CREATE TABLE IF NOT EXISTS custom_dns_health_checks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    type TEXT NOT NULL DEFAULT 'tcp',
+    port INTEGER NOT NULL,
+    path TEXT NOT NULL DEFAULT '',
+    interval TEXT NOT NULL DEFAULT '10s',
+    timeout TEXT NOT NULL DEFAULT '2s',
+    failures INTEGER NOT NULL DEFAULT 2,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,2 @@
+-- Remove custom DNS steering
+DROP TABLE IF EXISTS custom_dns_steering;
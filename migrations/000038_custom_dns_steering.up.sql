@@ -0,0 +1,14 @@
+-- Steering of custom DNS answers by client subnet and weight, one row per
+-- address of a steered host. clients is comma-separated; empty means the
+-- clients matching no other address. Per node: not tracked by
+-- config_version, so changes are not synced to cluster secondaries.
+CREATE TABLE IF NOT EXISTS custom_dns_steering (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    addr TEXT NOT NULL,
+    weight INTEGER NOT NULL DEFAULT 0,
+    clients TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(name, addr)
+);
@@ -0,0 +1,6 @@
+-- Remove the external shared response cache settings
+ALTER TABLE config_cache DROP COLUMN shared_key_prefix;
+ALTER TABLE config_cache DROP COLUMN shared_timeout;
+ALTER TABLE config_cache DROP COLUMN shared_password;
+ALTER TABLE config_cache DROP COLUMN shared_address;
+ALTER TABLE config_cache DROP COLUMN shared_backend;
@@ -0,0 +1,7 @@
+-- External shared (L2) response cache: backend is '', 'redis', or
+-- 'memcached'; empty timeout and key prefix use the defaults
+ALTER TABLE config_cache ADD COLUMN shared_backend TEXT NOT NULL DEFAULT '';
+ALTER TABLE config_cache ADD COLUMN shared_address TEXT NOT NULL DEFAULT '';
+ALTER TABLE config_cache ADD COLUMN shared_password TEXT NOT NULL DEFAULT '';
+ALTER TABLE config_cache ADD COLUMN shared_timeout TEXT NOT NULL DEFAULT '';
+ALTER TABLE config_cache ADD COLUMN shared_key_prefix TEXT NOT NULL DEFAULT '';
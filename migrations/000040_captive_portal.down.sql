@@ -0,0 +1,2 @@
+-- Remove captive portal settings
+DROP TABLE IF EXISTS config_captive_portal;
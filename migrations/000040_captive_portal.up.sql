@@ -0,0 +1,17 @@
+-- Captive portal redirect for onboarding networks. Per node: not tracked by
+-- config_version, so changes are not synced to cluster secondaries.
+CREATE TABLE IF NOT EXISTS config_captive_portal (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    enabled BOOLEAN NOT NULL DEFAULT 0,
+    clients TEXT NOT NULL DEFAULT '',
+    ipv4 TEXT NOT NULL DEFAULT '',
+    ipv6 TEXT NOT NULL DEFAULT '',
+    allow_domains TEXT NOT NULL DEFAULT '',
+    ttl INTEGER NOT NULL DEFAULT 10,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO config_captive_portal (id, created_at, updated_at)
+VALUES (1, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+ON CONFLICT(id) DO NOTHING;
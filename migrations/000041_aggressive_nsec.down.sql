@@ -0,0 +1,2 @@
+-- Remove the aggressive NSEC setting
+ALTER TABLE config_cache DROP COLUMN aggressive_nsec;
@@ -0,0 +1,2 @@
+-- Aggressive use of DNSSEC-validated NSEC records (RFC 8198), off by default
+ALTER TABLE config_cache ADD COLUMN aggressive_nsec INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+-- Remove AAAA filtering settings
+DROP TABLE IF EXISTS config_filter_aaaa;
@@ -0,0 +1,14 @@
+-- AAAA answer filtering for clients on networks with broken IPv6. Per node:
+-- not tracked by config_version, so changes are not synced to cluster
+-- secondaries.
+CREATE TABLE IF NOT EXISTS config_filter_aaaa (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    enabled BOOLEAN NOT NULL DEFAULT 0,
+    clients TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO config_filter_aaaa (id, created_at, updated_at)
+VALUES (1, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+ON CONFLICT(id) DO NOTHING;
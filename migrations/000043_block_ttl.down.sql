@@ -0,0 +1,3 @@
+-- Remove the block TTL settings
+ALTER TABLE config_filtering DROP COLUMN negative_ttl;
+ALTER TABLE config_filtering DROP COLUMN block_ttl;
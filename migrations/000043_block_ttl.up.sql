@@ -0,0 +1,3 @@
+-- TTLs of block page answers and blocked negative answers, in seconds
+ALTER TABLE config_filtering ADD COLUMN block_ttl INTEGER NOT NULL DEFAULT 10;
+ALTER TABLE config_filtering ADD COLUMN negative_ttl INTEGER NOT NULL DEFAULT 10;
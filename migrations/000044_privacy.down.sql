@@ -0,0 +1,2 @@
+-- Remove query log privacy settings
+DROP TABLE IF EXISTS config_privacy;
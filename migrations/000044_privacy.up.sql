@@ -0,0 +1,14 @@
+-- What client query logs keep about clients and names. Per node: not
+-- tracked by config_version, so changes are not synced to cluster
+-- secondaries.
+CREATE TABLE IF NOT EXISTS config_privacy (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    level TEXT NOT NULL DEFAULT 'full',
+    anonymize TEXT NOT NULL DEFAULT 'truncate',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO config_privacy (id, created_at, updated_at)
+VALUES (1, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+ON CONFLICT(id) DO NOTHING;
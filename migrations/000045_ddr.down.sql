@@ -0,0 +1,2 @@
+-- Remove designated resolver discovery settings
+DROP TABLE IF EXISTS config_ddr;
@@ -0,0 +1,19 @@
+-- Discovery of Designated Resolvers (RFC 9462). Per node: not tracked by
+-- config_version, so changes are not synced to cluster secondaries.
+CREATE TABLE IF NOT EXISTS config_ddr (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    enabled BOOLEAN NOT NULL DEFAULT 0,
+    target TEXT NOT NULL DEFAULT '',
+    doh_port INTEGER NOT NULL DEFAULT 0,
+    doh_path TEXT NOT NULL DEFAULT '/dns-query{?dns}',
+    dot_port INTEGER NOT NULL DEFAULT 0,
+    ipv4_hints TEXT NOT NULL DEFAULT '',
+    ipv6_hints TEXT NOT NULL DEFAULT '',
+    ttl INTEGER NOT NULL DEFAULT 300,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO config_ddr (id, created_at, updated_at)
+VALUES (1, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+ON CONFLICT(id) DO NOTHING;
@@ -0,0 +1,2 @@
+-- Remove PTR synthesis settings
+DROP TABLE IF EXISTS config_synth_ptr;
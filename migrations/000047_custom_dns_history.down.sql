@@ -0,0 +1,2 @@
+-- Remove custom DNS history
+DROP TABLE IF EXISTS custom_dns_history;
@@ -0,0 +1,10 @@
+-- Snapshots of the custom DNS records, taken before each change made
+-- through the API, so a change can be rolled back. The history itself is
+-- not tracked by config_version; a rollback changes custom_dns_records,
+-- which is.
+CREATE TABLE IF NOT EXISTS custom_dns_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    action TEXT NOT NULL,
+    records TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,2 @@
+-- Remove the cluster promotion audit trail
+DROP TABLE IF EXISTS cluster_promotions;
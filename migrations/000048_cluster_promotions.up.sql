@@ -0,0 +1,13 @@
+-- Promotions of this node from secondary to primary, kept as an audit
+-- trail (see POST /api/v1/cluster/promote).
+CREATE TABLE IF NOT EXISTS cluster_promotions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    node_id TEXT NOT NULL,
+    previous_primary_url TEXT NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    forced INTEGER NOT NULL DEFAULT 0,
+    requested_by TEXT NOT NULL DEFAULT '',
+    client_ip TEXT NOT NULL DEFAULT '',
+    config_version INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,2 @@
+-- Remove cluster discovery settings
+DROP TABLE IF EXISTS config_cluster_discovery;
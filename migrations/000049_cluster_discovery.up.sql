@@ -0,0 +1,16 @@
+-- DNS service discovery records for cluster members. Per node: not tracked
+-- by config_version, so changes are not synced to cluster secondaries.
+CREATE TABLE IF NOT EXISTS config_cluster_discovery (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    enabled BOOLEAN NOT NULL DEFAULT 0,
+    zone TEXT NOT NULL DEFAULT '',
+    address TEXT NOT NULL DEFAULT '',
+    ttl INTEGER NOT NULL DEFAULT 60,
+    member_timeout INTEGER NOT NULL DEFAULT 900,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO config_cluster_discovery (id, created_at, updated_at)
+VALUES (1, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+ON CONFLICT(id) DO NOTHING;
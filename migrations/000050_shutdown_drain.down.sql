@@ -0,0 +1,2 @@
+-- Remove the shutdown drain
+ALTER TABLE config_server DROP COLUMN shutdown_drain;
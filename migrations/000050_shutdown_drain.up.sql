@@ -0,0 +1,2 @@
+-- Time to keep serving, while reporting not ready, after a shutdown signal
+ALTER TABLE config_server ADD COLUMN shutdown_drain TEXT NOT NULL DEFAULT '0s';
@@ -0,0 +1,3 @@
+-- Remove the slow query log
+ALTER TABLE config_logging DROP COLUMN slow_query_max_per_minute;
+ALTER TABLE config_logging DROP COLUMN slow_query_threshold;
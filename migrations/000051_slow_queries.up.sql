@@ -0,0 +1,3 @@
+-- Slow query log; a threshold of 0s disables it
+ALTER TABLE config_logging ADD COLUMN slow_query_threshold TEXT NOT NULL DEFAULT '0s';
+ALTER TABLE config_logging ADD COLUMN slow_query_max_per_minute INTEGER NOT NULL DEFAULT 60;
@@ -0,0 +1,3 @@
+-- Remove the UDP socket buffer sizes
+ALTER TABLE config_server DROP COLUMN udp_send_buffer;
+ALTER TABLE config_server DROP COLUMN udp_recv_buffer;
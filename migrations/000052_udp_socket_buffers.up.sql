@@ -0,0 +1,3 @@
+-- UDP socket buffer sizes in bytes; 0 picks the defaults and autotunes the receive buffer
+ALTER TABLE config_server ADD COLUMN udp_recv_buffer INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE config_server ADD COLUMN udp_send_buffer INTEGER NOT NULL DEFAULT 0;
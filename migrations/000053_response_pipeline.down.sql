@@ -0,0 +1,2 @@
+-- Remove the response pipeline
+DROP TABLE IF EXISTS response_pipeline;
@@ -0,0 +1,18 @@
+-- Response pipeline steps, rewriting responses before they are sent. Per
+-- node: not tracked by config_version, so changes are not synced to cluster
+-- secondaries. Steps run in id order; clients and record types are
+-- comma-separated, and no clients means all clients.
+CREATE TABLE IF NOT EXISTS response_pipeline (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    type TEXT NOT NULL,
+    clients TEXT NOT NULL DEFAULT '',
+    min_ttl INTEGER NOT NULL DEFAULT 0,
+    max_ttl INTEGER NOT NULL DEFAULT 0,
+    answer_order TEXT NOT NULL DEFAULT '',
+    rcode TEXT NOT NULL DEFAULT '',
+    ede_code INTEGER NOT NULL DEFAULT 0,
+    ede_text TEXT NOT NULL DEFAULT '',
+    record_types TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
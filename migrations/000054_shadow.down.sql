@@ -0,0 +1,2 @@
+-- Remove shadow upstream settings
+DROP TABLE IF EXISTS config_shadow;
@@ -0,0 +1,16 @@
+-- Shadow upstreams that a share of live queries is mirrored to. Per node:
+-- not tracked by config_version, so changes are not synced to cluster
+-- secondaries.
+CREATE TABLE IF NOT EXISTS config_shadow (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    enabled BOOLEAN NOT NULL DEFAULT 0,
+    servers TEXT NOT NULL DEFAULT '',
+    percent REAL NOT NULL DEFAULT 10,
+    timeout TEXT NOT NULL DEFAULT '2s',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO config_shadow (id, created_at, updated_at)
+VALUES (1, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+ON CONFLICT(id) DO NOTHING;
@@ -0,0 +1,2 @@
+-- Remove DNS advertisement settings
+DROP TABLE IF EXISTS config_advertise;
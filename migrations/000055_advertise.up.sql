@@ -0,0 +1,18 @@
+-- DNS advertisement to the router or DHCP server and client subnet
+-- coverage. Per node: not tracked by config_version, so changes are not
+-- synced to cluster secondaries.
+CREATE TABLE IF NOT EXISTS config_advertise (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    enabled BOOLEAN NOT NULL DEFAULT 0,
+    webhook_url TEXT NOT NULL DEFAULT '',
+    webhook_secret TEXT NOT NULL DEFAULT '',
+    interfaces TEXT NOT NULL DEFAULT '',
+    interval TEXT NOT NULL DEFAULT '30s',
+    client_subnets TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO config_advertise (id, created_at, updated_at)
+VALUES (1, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+ON CONFLICT(id) DO NOTHING;
@@ -0,0 +1,2 @@
+-- Remove the upstream stage budget
+ALTER TABLE config_upstream DROP COLUMN stage_timeout;
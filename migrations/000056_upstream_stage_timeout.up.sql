@@ -0,0 +1,2 @@
+-- Upstream stage budget of the resolver chain; empty leaves it unbounded
+ALTER TABLE config_upstream ADD COLUMN stage_timeout TEXT NOT NULL DEFAULT '';
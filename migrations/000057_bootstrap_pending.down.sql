@@ -0,0 +1,2 @@
+-- Remove the pending bootstrap flag
+ALTER TABLE config_api DROP COLUMN bootstrap_pending;
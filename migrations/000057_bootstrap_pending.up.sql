@@ -0,0 +1,5 @@
+-- Set until a generated API key has been stored. Databases whose API was
+-- never protected by a key are bootstrapped on the next start, so a crash
+-- between creating the schema and storing the key cannot leave it open
+ALTER TABLE config_api ADD COLUMN bootstrap_pending BOOLEAN NOT NULL DEFAULT 0;
+UPDATE config_api SET bootstrap_pending = 1 WHERE api_key = '';
@@ -0,0 +1,3 @@
+-- Remove temporary allows and their expiry
+DELETE FROM filtering_whitelist WHERE expires_at <> 0;
+ALTER TABLE filtering_whitelist DROP COLUMN expires_at;
@@ -0,0 +1,3 @@
+-- Expiry of temporary allows, in Unix seconds; 0 for permanent whitelist
+-- entries. Temporary allows match the exact domain only
+ALTER TABLE filtering_whitelist ADD COLUMN expires_at INTEGER NOT NULL DEFAULT 0;
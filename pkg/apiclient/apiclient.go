@@ -0,0 +1,389 @@
+// Package apiclient is a Go client for the HydraDNS management API.
+//
+// The request and response types are those of the API server, so the
+// client always matches the OpenAPI document served at /openapi.json:
+//
+//	c := apiclient.New("http://dns1:8080")
+//	c.APIKey = key
+//	stats, err := c.FilteringStats(ctx)
+//
+// Errors returned by the server are reported as *APIError, carrying the
+// HTTP status and the server's error message.
+package apiclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jroosing/hydradns/internal/api/models"
+)
+
+// DefaultTimeout is the HTTP timeout of clients created by New.
+const DefaultTimeout = 30 * time.Second
+
+// maxErrorBody bounds how much of an error response body is read.
+const maxErrorBody = 1024
+
+// API types re-exported from the server's models package.
+type (
+	// StatusResponse is the generic {"status": ...} response.
+	StatusResponse = models.StatusResponse
+	// ServerStatsResponse is the response of GET /stats.
+	ServerStatsResponse = models.ServerStatsResponse
+	// ConfigResponse is the response of GET /config.
+	ConfigResponse = models.ConfigResponse
+	// AnomaliesResponse is the response of GET /anomalies.
+	AnomaliesResponse = models.AnomaliesResponse
+	// UpstreamsResponse lists upstream servers in failover order.
+	UpstreamsResponse = models.UpstreamsResponse
+	// UpstreamStatusResponse is the response of GET /upstreams/status.
+	UpstreamStatusResponse = models.UpstreamStatusResponse
+	// CustomDNSRecordsResponse lists custom hosts and CNAMEs.
+	CustomDNSRecordsResponse = models.CustomDNSRecordsResponse
+	// CustomDNSOperationResponse is returned by custom DNS changes.
+	CustomDNSOperationResponse = models.CustomDNSOperationResponse
+	// BatchOperation is one change of a batch.
+	BatchOperation = models.BatchOperation
+	// BatchResponse is the response of POST /batch.
+	BatchResponse = models.BatchResponse
+	// FilteringStatsResponse is the response of GET /filtering/stats.
+	FilteringStatsResponse = models.FilteringStatsResponse
+	// DomainListResponse lists whitelist or blacklist domains.
+	DomainListResponse = models.DomainListResponse
+	// TemporaryAllowResponse describes an active temporary allow.
+	TemporaryAllowResponse = models.TemporaryAllowResponse
+	// ClusterStatusResponse is the response of GET /cluster/status.
+	ClusterStatusResponse = models.ClusterStatusResponse
+	// ClusterPromoteRequest confirms a promotion to primary.
+	ClusterPromoteRequest = models.ClusterPromoteRequest
+	// ClusterPromotion is a recorded promotion to primary.
+	ClusterPromotion = models.ClusterPromotion
+	// APITokenListResponse lists API tokens.
+	APITokenListResponse = models.APITokenListResponse
+	// CreateAPITokenRequest creates an API token.
+	CreateAPITokenRequest = models.CreateAPITokenRequest
+	// CreateAPITokenResponse carries a newly created API token.
+	CreateAPITokenResponse = models.CreateAPITokenResponse
+)
+
+// APIError is a non-2xx response from the API.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("unexpected status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("unexpected status %d: %s", e.StatusCode, e.Message)
+}
+
+// IsStatus reports whether err is an *APIError with the given status code.
+func IsStatus(err error, code int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == code
+}
+
+// Client calls the management API of one HydraDNS node.
+//
+// Fields may be set after New but not while requests are in flight.
+type Client struct {
+	// BaseURL is the node's API address, e.g. "http://dns1:8080".
+	BaseURL string
+	// HTTPClient sends the requests.
+	HTTPClient *http.Client
+	// APIKey is sent as X-API-Key: the admin key or a scoped API token.
+	APIKey string
+	// ClusterSecret is sent as X-Cluster-Secret for cluster export.
+	ClusterSecret string
+	// NodeID is sent as X-Node-Id to identify this node to a primary.
+	NodeID string
+	// Header holds extra headers sent with every request.
+	Header http.Header
+}
+
+// New returns a client for the API at baseURL.
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: &http.Client{Timeout: DefaultTimeout},
+	}
+}
+
+// Health calls GET /health.
+func (c *Client) Health(ctx context.Context) (*StatusResponse, error) {
+	return call[StatusResponse](ctx, c, http.MethodGet, "/health", nil)
+}
+
+// Stats calls GET /stats.
+func (c *Client) Stats(ctx context.Context) (*ServerStatsResponse, error) {
+	return call[ServerStatsResponse](ctx, c, http.MethodGet, "/stats", nil)
+}
+
+// Anomalies calls GET /anomalies.
+func (c *Client) Anomalies(ctx context.Context) (*AnomaliesResponse, error) {
+	return call[AnomaliesResponse](ctx, c, http.MethodGet, "/anomalies", nil)
+}
+
+// Config calls GET /config.
+func (c *Client) Config(ctx context.Context) (*ConfigResponse, error) {
+	return call[ConfigResponse](ctx, c, http.MethodGet, "/config", nil)
+}
+
+// ReloadConfig calls POST /config/reload.
+func (c *Client) ReloadConfig(ctx context.Context) error {
+	_, err := call[StatusResponse](ctx, c, http.MethodPost, "/config/reload", nil)
+	return err
+}
+
+// Upstreams calls GET /upstreams.
+func (c *Client) Upstreams(ctx context.Context) (*UpstreamsResponse, error) {
+	return call[UpstreamsResponse](ctx, c, http.MethodGet, "/upstreams", nil)
+}
+
+// SetUpstreams calls PUT /upstreams, replacing the upstream list.
+func (c *Client) SetUpstreams(ctx context.Context, servers []string) (*UpstreamsResponse, error) {
+	return call[UpstreamsResponse](ctx, c, http.MethodPut, "/upstreams",
+		models.UpdateUpstreamsRequest{Servers: servers})
+}
+
+// UpstreamStatus calls GET /upstreams/status.
+func (c *Client) UpstreamStatus(ctx context.Context) (*UpstreamStatusResponse, error) {
+	return call[UpstreamStatusResponse](ctx, c, http.MethodGet, "/upstreams/status", nil)
+}
+
+// CustomDNS calls GET /custom-dns.
+func (c *Client) CustomDNS(ctx context.Context) (*CustomDNSRecordsResponse, error) {
+	return call[CustomDNSRecordsResponse](ctx, c, http.MethodGet, "/custom-dns", nil)
+}
+
+// AddHost calls POST /custom-dns/hosts.
+func (c *Client) AddHost(ctx context.Context, name string, ips []string) (*CustomDNSOperationResponse, error) {
+	return call[CustomDNSOperationResponse](ctx, c, http.MethodPost, "/custom-dns/hosts",
+		models.AddHostRequest{Name: name, IPs: ips})
+}
+
+// UpdateHost calls PUT /custom-dns/hosts/{name}.
+func (c *Client) UpdateHost(ctx context.Context, name string, ips []string) (*CustomDNSOperationResponse, error) {
+	return call[CustomDNSOperationResponse](ctx, c, http.MethodPut, "/custom-dns/hosts/"+url.PathEscape(name),
+		models.UpdateHostRequest{IPs: ips})
+}
+
+// DeleteHost calls DELETE /custom-dns/hosts/{name}.
+func (c *Client) DeleteHost(ctx context.Context, name string) (*CustomDNSOperationResponse, error) {
+	return call[CustomDNSOperationResponse](ctx, c, http.MethodDelete, "/custom-dns/hosts/"+url.PathEscape(name), nil)
+}
+
+// AddCNAME calls POST /custom-dns/cnames.
+func (c *Client) AddCNAME(ctx context.Context, alias, target string) (*CustomDNSOperationResponse, error) {
+	return call[CustomDNSOperationResponse](ctx, c, http.MethodPost, "/custom-dns/cnames",
+		models.AddCNAMERequest{Alias: alias, Target: target})
+}
+
+// UpdateCNAME calls PUT /custom-dns/cnames/{alias}.
+func (c *Client) UpdateCNAME(ctx context.Context, alias, target string) (*CustomDNSOperationResponse, error) {
+	return call[CustomDNSOperationResponse](ctx, c, http.MethodPut, "/custom-dns/cnames/"+url.PathEscape(alias),
+		models.UpdateCNAMERequest{Target: target})
+}
+
+// DeleteCNAME calls DELETE /custom-dns/cnames/{alias}.
+func (c *Client) DeleteCNAME(ctx context.Context, alias string) (*CustomDNSOperationResponse, error) {
+	return call[CustomDNSOperationResponse](ctx, c, http.MethodDelete, "/custom-dns/cnames/"+url.PathEscape(alias), nil)
+}
+
+// ApplyBatch calls POST /batch, applying ops all or nothing. When any
+// operation cannot be applied nothing changes, and the *APIError's message
+// holds the BatchResponse listing the errors.
+func (c *Client) ApplyBatch(ctx context.Context, ops ...BatchOperation) (*BatchResponse, error) {
+	return call[BatchResponse](ctx, c, http.MethodPost, "/batch", models.BatchRequest{Operations: ops})
+}
+
+// FilteringStats calls GET /filtering/stats.
+func (c *Client) FilteringStats(ctx context.Context) (*FilteringStatsResponse, error) {
+	return call[FilteringStatsResponse](ctx, c, http.MethodGet, "/filtering/stats", nil)
+}
+
+// SetFilteringEnabled calls PUT /filtering/enabled.
+func (c *Client) SetFilteringEnabled(ctx context.Context, enabled bool) error {
+	_, err := call[StatusResponse](ctx, c, http.MethodPut, "/filtering/enabled",
+		models.FilteringEnabledRequest{Enabled: enabled})
+	return err
+}
+
+// Whitelist calls GET /filtering/whitelist.
+func (c *Client) Whitelist(ctx context.Context) (*DomainListResponse, error) {
+	return call[DomainListResponse](ctx, c, http.MethodGet, "/filtering/whitelist", nil)
+}
+
+// AddWhitelist calls POST /filtering/whitelist.
+func (c *Client) AddWhitelist(ctx context.Context, domains ...string) error {
+	_, err := call[StatusResponse](ctx, c, http.MethodPost, "/filtering/whitelist",
+		models.DomainRequest{Domains: domains})
+	return err
+}
+
+// RemoveWhitelist calls DELETE /filtering/whitelist.
+func (c *Client) RemoveWhitelist(ctx context.Context, domains ...string) error {
+	_, err := call[StatusResponse](ctx, c, http.MethodDelete, "/filtering/whitelist",
+		models.DomainDeleteRequest{Domains: domains})
+	return err
+}
+
+// Blacklist calls GET /filtering/blacklist.
+func (c *Client) Blacklist(ctx context.Context) (*DomainListResponse, error) {
+	return call[DomainListResponse](ctx, c, http.MethodGet, "/filtering/blacklist", nil)
+}
+
+// AddBlacklist calls POST /filtering/blacklist.
+func (c *Client) AddBlacklist(ctx context.Context, domains ...string) error {
+	_, err := call[StatusResponse](ctx, c, http.MethodPost, "/filtering/blacklist",
+		models.DomainRequest{Domains: domains})
+	return err
+}
+
+// RemoveBlacklist calls DELETE /filtering/blacklist.
+func (c *Client) RemoveBlacklist(ctx context.Context, domains ...string) error {
+	_, err := call[StatusResponse](ctx, c, http.MethodDelete, "/filtering/blacklist",
+		models.DomainDeleteRequest{Domains: domains})
+	return err
+}
+
+// AllowTemporarily calls POST /filtering/allow-temporarily.
+func (c *Client) AllowTemporarily(ctx context.Context, domain string, d time.Duration) (*TemporaryAllowResponse, error) {
+	return call[TemporaryAllowResponse](ctx, c, http.MethodPost, "/filtering/allow-temporarily",
+		models.TemporaryAllowRequest{Domain: domain, Duration: d.String()})
+}
+
+// ClusterStatus calls GET /cluster/status.
+func (c *Client) ClusterStatus(ctx context.Context) (*ClusterStatusResponse, error) {
+	return call[ClusterStatusResponse](ctx, c, http.MethodGet, "/cluster/status", nil)
+}
+
+// ClusterExport calls GET /cluster/export on a primary and decodes the
+// export into out, normally a *cluster.ExportData. ClusterSecret and NodeID
+// are sent when set.
+func (c *Client) ClusterExport(ctx context.Context, out any) error {
+	return c.do(ctx, http.MethodGet, "/cluster/export", nil, out)
+}
+
+// ClusterChanges calls GET /cluster/changes on a primary and decodes the
+// changes after seq in journal into out, normally a *cluster.Changes. The
+// primary answers 410 Gone when the journal no longer has them.
+func (c *Client) ClusterChanges(ctx context.Context, journal string, seq int64, out any) error {
+	q := url.Values{"journal": {journal}, "since": {strconv.FormatInt(seq, 10)}}
+	return c.do(ctx, http.MethodGet, "/cluster/changes?"+q.Encode(), nil, out)
+}
+
+// ClusterSync calls POST /cluster/sync on a secondary.
+func (c *Client) ClusterSync(ctx context.Context) error {
+	_, err := call[StatusResponse](ctx, c, http.MethodPost, "/cluster/sync", nil)
+	return err
+}
+
+// ClusterPromote calls POST /cluster/promote on a secondary.
+func (c *Client) ClusterPromote(ctx context.Context, req ClusterPromoteRequest) (*ClusterPromotion, error) {
+	return call[ClusterPromotion](ctx, c, http.MethodPost, "/cluster/promote", req)
+}
+
+// APITokens calls GET /tokens.
+func (c *Client) APITokens(ctx context.Context) (*APITokenListResponse, error) {
+	return call[APITokenListResponse](ctx, c, http.MethodGet, "/tokens", nil)
+}
+
+// CreateAPIToken calls POST /tokens.
+func (c *Client) CreateAPIToken(ctx context.Context, req CreateAPITokenRequest) (*CreateAPITokenResponse, error) {
+	return call[CreateAPITokenResponse](ctx, c, http.MethodPost, "/tokens", req)
+}
+
+// DeleteAPIToken calls DELETE /tokens/{name}.
+func (c *Client) DeleteAPIToken(ctx context.Context, name string) error {
+	_, err := call[StatusResponse](ctx, c, http.MethodDelete, "/tokens/"+url.PathEscape(name), nil)
+	return err
+}
+
+// call sends a request and decodes the JSON response into a new T.
+func call[T any](ctx context.Context, c *Client, method, path string, in any) (*T, error) {
+	var out T
+	if err := c.do(ctx, method, path, in, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// do sends a request under /api/v1 with in as JSON body (if non-nil) and
+// decodes the JSON response into out (if non-nil).
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("encode request: %w", err)
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+"/api/v1"+path, body)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.APIKey != "" {
+		req.Header.Set("X-API-Key", c.APIKey)
+	}
+	if c.ClusterSecret != "" {
+		req.Header.Set("X-Cluster-Secret", c.ClusterSecret)
+	}
+	if c.NodeID != "" {
+		req.Header.Set("X-Node-Id", c.NodeID)
+	}
+	for name, values := range c.Header {
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("http request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return decodeError(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// decodeError builds an *APIError from a failed response, preferring the
+// server's ErrorResponse message over the raw body.
+func decodeError(resp *http.Response) error {
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+
+	var e models.ErrorResponse
+	if json.Unmarshal(raw, &e) == nil && e.Error != "" {
+		apiErr.Message = e.Error
+	} else {
+		apiErr.Message = strings.TrimSpace(string(raw))
+	}
+	return apiErr
+}
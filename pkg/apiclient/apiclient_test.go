@@ -0,0 +1,126 @@
+package apiclient_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jroosing/hydradns/internal/api"
+	"github.com/jroosing/hydradns/internal/config"
+	"github.com/jroosing/hydradns/internal/database"
+	"github.com/jroosing/hydradns/pkg/apiclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// newTestServer starts the real API server over a temp database.
+func newTestServer(t *testing.T, apiKey string) *apiclient.Client {
+	t.Helper()
+
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	cfg := &config.Config{
+		Upstream: config.UpstreamConfig{Servers: []string{"9.9.9.9"}},
+		CustomDNS: config.CustomDNSConfig{
+			Hosts:  map[string][]string{},
+			CNAMEs: map[string]string{},
+		},
+		API: config.APIConfig{Enabled: true, APIKey: apiKey},
+	}
+	ts := httptest.NewServer(api.New(cfg, db, nil).Engine())
+	t.Cleanup(ts.Close)
+
+	c := apiclient.New(ts.URL + "/")
+	c.APIKey = apiKey
+	return c
+}
+
+// ============================================================================
+// Round Trip Tests
+// ============================================================================
+
+func TestClient_Health(t *testing.T) {
+	c := newTestServer(t, "admin")
+
+	resp, err := c.Health(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "ok", resp.Status)
+}
+
+func TestClient_CustomDNS(t *testing.T) {
+	c := newTestServer(t, "admin")
+	ctx := context.Background()
+
+	_, err := c.AddHost(ctx, "nas.lan", []string{"192.168.1.10"})
+	require.NoError(t, err)
+	_, err = c.AddCNAME(ctx, "files.lan", "nas.lan")
+	require.NoError(t, err)
+
+	records, err := c.CustomDNS(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"192.168.1.10"}, records.Hosts["nas.lan"])
+	assert.Equal(t, "nas.lan", records.CNAMEs["files.lan"])
+
+	_, err = c.DeleteHost(ctx, "nas.lan")
+	require.NoError(t, err)
+}
+
+func TestClient_APITokens(t *testing.T) {
+	c := newTestServer(t, "admin")
+	ctx := context.Background()
+
+	created, err := c.CreateAPIToken(ctx, apiclient.CreateAPITokenRequest{
+		Name:   "ci",
+		Scopes: []string{"upstreams:read"},
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, created.Token)
+
+	// The token reaches its scope and nothing else.
+	scoped := *c
+	scoped.APIKey = created.Token
+	upstreams, err := scoped.Upstreams(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"9.9.9.9"}, upstreams.Servers)
+
+	_, err = scoped.SetUpstreams(ctx, []string{"1.1.1.1"})
+	assert.True(t, apiclient.IsStatus(err, http.StatusForbidden), "got %v", err)
+
+	require.NoError(t, c.DeleteAPIToken(ctx, "ci"))
+	tokens, err := c.APITokens(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, tokens.Tokens)
+}
+
+// ============================================================================
+// Error Tests
+// ============================================================================
+
+func TestClient_APIError(t *testing.T) {
+	c := newTestServer(t, "admin")
+	c.APIKey = "wrong"
+
+	_, err := c.Stats(context.Background())
+	require.Error(t, err)
+
+	var apiErr *apiclient.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+	assert.Equal(t, "unauthorized", apiErr.Message)
+}
+
+func TestClient_NotFound(t *testing.T) {
+	c := newTestServer(t, "admin")
+
+	err := c.DeleteAPIToken(context.Background(), "missing")
+	assert.True(t, apiclient.IsStatus(err, http.StatusNotFound), "got %v", err)
+}
@@ -0,0 +1,220 @@
+// Package hydradns exposes HydraDNS as an embeddable library.
+//
+// Other Go programs can run a HydraDNS server in-process instead of exec'ing
+// the hydradns binary:
+//
+//	cfg := &hydradns.Config{
+//		Server:   hydradns.ServerConfig{Host: "127.0.0.1", Port: 5353, EnableTCP: true},
+//		Upstream: hydradns.UpstreamConfig{Servers: []string{"9.9.9.9"}},
+//	}
+//	srv, err := hydradns.New(cfg, logger)
+//	if err != nil { ... }
+//	if err := srv.Start(ctx); err != nil { ... }
+//	defer srv.Stop(5 * time.Second)
+//
+// The configuration types are aliases of the internal config package so the
+// embedded server behaves exactly like the standalone binary. The library does
+// not open the SQLite database or start the management API; callers own the
+// configuration and pass it in directly.
+package hydradns
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jroosing/hydradns/internal/config"
+	"github.com/jroosing/hydradns/internal/filtering"
+	"github.com/jroosing/hydradns/internal/server"
+)
+
+// Configuration types re-exported from the internal config package.
+type (
+	// Config is the complete server configuration.
+	Config = config.Config
+	// ServerConfig contains listener and concurrency settings.
+	ServerConfig = config.ServerConfig
+	// UpstreamConfig contains upstream forwarding settings.
+	UpstreamConfig = config.UpstreamConfig
+	// CustomDNSConfig contains local hosts and CNAME records.
+	CustomDNSConfig = config.CustomDNSConfig
+	// CacheConfig contains negative and SERVFAIL caching settings.
+	CacheConfig = config.CacheConfig
+	// CacheZoneOverride overrides CacheConfig for a zone.
+	CacheZoneOverride = config.CacheZoneOverride
+	// LoggingConfig contains logging settings.
+	LoggingConfig = config.LoggingConfig
+	// FilteringConfig contains domain filtering settings.
+	FilteringConfig = config.FilteringConfig
+	// BlocklistConfig describes a remote blocklist.
+	BlocklistConfig = config.BlocklistConfig
+	// RateLimitConfig contains rate limiting settings.
+	RateLimitConfig = config.RateLimitConfig
+)
+
+// Stats is a point-in-time snapshot of DNS query statistics.
+type Stats = server.DNSStatsSnapshot
+
+// UDPListenerStats is a point-in-time snapshot of one UDP listener socket.
+type UDPListenerStats = server.UDPListenerStats
+
+var (
+	// ErrNilConfig is returned by New when no configuration is supplied.
+	ErrNilConfig = errors.New("hydradns: config is nil")
+	// ErrAlreadyRunning is returned by Start when the server is already running.
+	ErrAlreadyRunning = errors.New("hydradns: server already running")
+	// ErrNotRunning is returned by Stop when the server is not running.
+	ErrNotRunning = errors.New("hydradns: server not running")
+	// ErrStopTimeout is returned by Stop when the server does not exit in time.
+	ErrStopTimeout = errors.New("hydradns: timed out waiting for server to stop")
+)
+
+// Server is an embeddable HydraDNS server.
+//
+// A Server can be started and stopped repeatedly; statistics accumulate
+// across runs. All methods are safe for concurrent use.
+type Server struct {
+	cfg    *config.Config
+	runner *server.Runner
+	policy *filtering.PolicyEngine
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+}
+
+// New validates cfg and creates a server. The config is normalized in place
+// (see config.Config.Validate). A nil logger disables logging.
+func New(cfg *Config, logger *slog.Logger) (*Server, error) {
+	if cfg == nil {
+		return nil, ErrNilConfig
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	policy := server.BuildPolicyEngine(cfg, logger, nil)
+	runner := server.NewRunner(logger)
+	runner.SetPolicyEngine(policy)
+
+	return &Server{
+		cfg:    cfg,
+		runner: runner,
+		policy: policy,
+	}, nil
+}
+
+// Start launches the DNS listeners in the background and returns immediately.
+// The server runs until Stop is called or ctx is canceled. Use Wait to observe
+// the result of a run that ends on its own (e.g. a bind failure).
+func (s *Server) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.done != nil {
+		select {
+		case <-s.done:
+			// previous run finished; allow restart
+		default:
+			return ErrAlreadyRunning
+		}
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	s.cancel = cancel
+	s.done = done
+	s.err = nil
+
+	// Run against a copy so ReloadCustomDNS can update s.cfg concurrently.
+	cfg := *s.cfg
+	go func() {
+		err := s.runner.RunWithContext(runCtx, &cfg)
+		s.mu.Lock()
+		s.err = err
+		s.mu.Unlock()
+		close(done)
+	}()
+	return nil
+}
+
+// Stop signals the server to shut down and waits up to timeout for it to exit.
+// It returns the error the run ended with, if any.
+func (s *Server) Stop(timeout time.Duration) error {
+	s.mu.Lock()
+	cancel, done := s.cancel, s.done
+	s.mu.Unlock()
+
+	if done == nil {
+		return ErrNotRunning
+	}
+	cancel()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+		return ErrStopTimeout
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Wait blocks until the current run exits and returns its error.
+// It returns ErrNotRunning if the server was never started.
+func (s *Server) Wait() error {
+	s.mu.Lock()
+	done := s.done
+	s.mu.Unlock()
+
+	if done == nil {
+		return ErrNotRunning
+	}
+	<-done
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close stops the server if it is running and releases the filtering engine.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	cancel, done := s.cancel, s.done
+	s.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+	if s.policy != nil {
+		return s.policy.Close()
+	}
+	return nil
+}
+
+// Stats returns a snapshot of the query statistics.
+func (s *Server) Stats() Stats {
+	return s.runner.DNSStats().Snapshot()
+}
+
+// UDPListenerStats returns per-socket UDP counters (packets read, handled,
+// dropped, and queue depth). Returns nil when the server is not running.
+func (s *Server) UDPListenerStats() []UDPListenerStats {
+	return s.runner.UDPListenerStats()
+}
+
+// ReloadCustomDNS atomically replaces the local hosts and CNAME records.
+// This is safe to call while the server is running.
+func (s *Server) ReloadCustomDNS(custom CustomDNSConfig) error {
+	s.mu.Lock()
+	s.cfg.CustomDNS = custom
+	s.mu.Unlock()
+	return s.runner.ReloadCustomDNS(&config.Config{CustomDNS: custom})
+}
@@ -0,0 +1,198 @@
+// Package hydradns_test provides behavior tests for the embeddable library API.
+package hydradns_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jroosing/hydradns/internal/dns"
+	"github.com/jroosing/hydradns/pkg/hydradns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// freePort returns a UDP port that is currently unused on loopback.
+func freePort(t *testing.T) int {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	require.NoError(t, conn.Close())
+	return port
+}
+
+func newTestConfig(port int) *hydradns.Config {
+	return &hydradns.Config{
+		Server: hydradns.ServerConfig{
+			Host:           "127.0.0.1",
+			Port:           port,
+			WorkersRaw:     "1",
+			MaxConcurrency: 4,
+		},
+		Upstream: hydradns.UpstreamConfig{
+			Servers:    []string{"127.0.0.1"},
+			UDPTimeout: "100ms",
+			TCPTimeout: "100ms",
+			MaxRetries: 1,
+		},
+		CustomDNS: hydradns.CustomDNSConfig{
+			Hosts: map[string][]string{"embedded.lan": {"10.0.0.42"}},
+		},
+	}
+}
+
+// queryA sends an A query over UDP, retrying until the listener is up.
+func queryA(t *testing.T, port int, name string) dns.Packet {
+	t.Helper()
+	req := dns.Packet{
+		Header:    dns.Header{ID: 0x4242, Flags: dns.RDFlag},
+		Questions: []dns.Question{{Name: name, Type: uint16(dns.TypeA), Class: uint16(dns.ClassIN)}},
+	}
+	reqBytes, err := req.Marshal()
+	require.NoError(t, err)
+
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}
+	buf := make([]byte, 4096)
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		conn, err := net.DialUDP("udp", nil, addr)
+		require.NoError(t, err)
+		// Before the server binds, the kernel may hand the client the very
+		// port freePort released; the query then loops back to the client.
+		if conn.LocalAddr().(*net.UDPAddr).Port == port {
+			_ = conn.Close()
+			continue
+		}
+		_ = conn.SetDeadline(time.Now().Add(200 * time.Millisecond))
+		_, _ = conn.Write(reqBytes)
+		n, err := conn.Read(buf)
+		_ = conn.Close()
+		if err == nil {
+			resp, err := dns.ParsePacket(buf[:n])
+			require.NoError(t, err)
+			if resp.Header.Flags&dns.QRFlag != 0 {
+				return resp
+			}
+		}
+	}
+	t.Fatalf("no response from embedded server on port %d", port)
+	return dns.Packet{}
+}
+
+func TestNew_NilConfig(t *testing.T) {
+	srv, err := hydradns.New(nil, nil)
+	assert.Nil(t, srv)
+	assert.ErrorIs(t, err, hydradns.ErrNilConfig)
+}
+
+func TestNew_InvalidConfig(t *testing.T) {
+	cfg := newTestConfig(0)
+	srv, err := hydradns.New(cfg, nil)
+	assert.Nil(t, srv)
+	assert.Error(t, err)
+}
+
+func TestServer_StopWithoutStart(t *testing.T) {
+	srv, err := hydradns.New(newTestConfig(freePort(t)), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = srv.Close() })
+
+	assert.ErrorIs(t, srv.Stop(time.Second), hydradns.ErrNotRunning)
+	assert.ErrorIs(t, srv.Wait(), hydradns.ErrNotRunning)
+}
+
+func TestServer_ServesCustomDNSAndReportsStats(t *testing.T) {
+	port := freePort(t)
+	srv, err := hydradns.New(newTestConfig(port), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = srv.Close() })
+
+	require.NoError(t, srv.Start(context.Background()))
+	assert.ErrorIs(t, srv.Start(context.Background()), hydradns.ErrAlreadyRunning)
+
+	resp := queryA(t, port, "embedded.lan")
+	require.Len(t, resp.Answers, 1)
+	ip, ok := resp.Answers[0].(*dns.IPRecord)
+	require.True(t, ok)
+	assert.Equal(t, "10.0.0.42", ip.Addr.String())
+
+	stats := srv.Stats()
+	assert.GreaterOrEqual(t, stats.QueriesTotal, uint64(1))
+	assert.GreaterOrEqual(t, stats.QueriesUDP, uint64(1))
+
+	require.NoError(t, srv.Stop(5*time.Second))
+}
+
+func TestServer_PinnedUDPListeners(t *testing.T) {
+	port := freePort(t)
+	cfg := newTestConfig(port)
+	cfg.Server.UDPListeners = 3
+	srv, err := hydradns.New(cfg, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = srv.Close() })
+
+	assert.Nil(t, srv.UDPListenerStats(), "no listeners before Start")
+	require.NoError(t, srv.Start(context.Background()))
+	_ = queryA(t, port, "embedded.lan")
+
+	listeners := srv.UDPListenerStats()
+	require.Len(t, listeners, 3)
+	var read uint64
+	for i, l := range listeners {
+		assert.Equal(t, i, l.Listener)
+		assert.Equal(t, 8, l.QueueCapacity, "queue holds 2x the workers per socket")
+		read += l.PacketsRead
+	}
+	assert.GreaterOrEqual(t, read, uint64(1))
+	assert.Eventually(t, func() bool {
+		var handled uint64
+		for _, l := range srv.UDPListenerStats() {
+			handled += l.PacketsHandled
+		}
+		return handled >= 1
+	}, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, srv.Stop(5*time.Second))
+	assert.Nil(t, srv.UDPListenerStats())
+}
+
+func TestServer_ReloadCustomDNS(t *testing.T) {
+	port := freePort(t)
+	srv, err := hydradns.New(newTestConfig(port), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = srv.Close() })
+
+	require.NoError(t, srv.Start(context.Background()))
+	_ = queryA(t, port, "embedded.lan")
+
+	require.NoError(t, srv.ReloadCustomDNS(hydradns.CustomDNSConfig{
+		Hosts: map[string][]string{"reloaded.lan": {"10.0.0.7"}},
+	}))
+
+	resp := queryA(t, port, "reloaded.lan")
+	require.Len(t, resp.Answers, 1)
+	ip, ok := resp.Answers[0].(*dns.IPRecord)
+	require.True(t, ok)
+	assert.Equal(t, "10.0.0.7", ip.Addr.String())
+
+	require.NoError(t, srv.Stop(5*time.Second))
+}
+
+func TestServer_Restart(t *testing.T) {
+	port := freePort(t)
+	srv, err := hydradns.New(newTestConfig(port), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = srv.Close() })
+
+	require.NoError(t, srv.Start(context.Background()))
+	_ = queryA(t, port, "embedded.lan")
+	require.NoError(t, srv.Stop(5*time.Second))
+
+	require.NoError(t, srv.Start(context.Background()))
+	_ = queryA(t, port, "embedded.lan")
+	require.NoError(t, srv.Stop(5*time.Second))
+
+	assert.GreaterOrEqual(t, srv.Stats().QueriesTotal, uint64(2))
+}